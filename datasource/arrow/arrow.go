@@ -0,0 +1,239 @@
+// Arrow package implements a Datasource of Apache Arrow ipc streams and
+// files (Feather v2), whose record batches are decoded into rows and sent
+// as row batches.  The exec operators run on those rows, not on the arrow
+// columns, so queries are not executed on the record batches directly.
+package arrow
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/files"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Different Features of this Arrow Data Source
+	_ schema.Source            = (*ArrowSource)(nil)
+	_ datasource.MeteredSource = (*ArrowSource)(nil)
+	_ schema.ConnScanner       = (*ArrowSource)(nil)
+	_ schema.ConnBatchScanner  = (*ArrowSource)(nil)
+	_ schema.ConnColumns       = (*ArrowSource)(nil)
+	_ schema.IteratorErr       = (*ArrowSource)(nil)
+	_ files.FileScanner        = (*ArrowSource)(nil)
+
+	fileMagic = []byte("ARROW1")
+)
+
+// the headers of the MessageHeader union of Message.fbs
+const (
+	headerSchema          = 1
+	headerDictionaryBatch = 2
+	headerRecordBatch     = 3
+)
+
+func init() {
+	files.RegisterFormat("arrow", func(table string, r io.Reader, settings u.JsonHelper) (files.FileScanner, error) {
+		return NewArrowSource(table, r)
+	}, "arrow", "arrows", "feather", "ipc")
+}
+
+// ArrowSource a DataSource of the record batches of an arrow ipc stream or
+// file (Feather v2) as a single table of the fields of its schema.
+//   - struct fields are flattened into dotted columns, ie user.name
+//   - decimals are numbers, dates and timestamps times (UTC)
+//   - each record batch is read column by column and decoded into rows,
+//     sent on whole as a row batch to the exec operators that take batches
+//     (where, projection), which evaluate them row by row
+//   - dictionary encoded fields and compressed bodies are not supported
+//   - forward only single pass, not thread-safe, read only
+//
+// Files of the ".arrow" and ".feather" extensions are read by the files source.
+type ArrowSource struct {
+	name     string
+	r        *bufio.Reader
+	tbl      *schema.Table
+	fields   []*field
+	cols     []*column
+	colindex map[string]int
+	rows     []schema.Message // of the batch read by Next
+	rowct    uint64
+	err      error
+	metrics  datasource.Metrics
+}
+
+// NewArrowSource read the schema of the ipc stream or file of r
+func NewArrowSource(name string, r io.Reader) (*ArrowSource, error) {
+	m := &ArrowSource{name: name, r: bufio.NewReader(r)}
+	if head, _ := m.r.Peek(len(fileMagic)); bytes.Equal(head, fileMagic) {
+		// the file format is the stream format after the (padded) magic
+		if _, err := m.r.Discard(8); err != nil {
+			return nil, err
+		}
+	}
+	header, typ, _, err := m.readMessage()
+	if err != nil {
+		return nil, fmt.Errorf("could not read arrow schema of %q: %v", name, err)
+	}
+	if typ != headerSchema {
+		return nil, fmt.Errorf("arrow stream of %q does not start with a schema", name)
+	}
+	if header.int16(0, 0) != 0 {
+		return nil, fmt.Errorf("big endian arrow stream of %q is not supported", name)
+	}
+	if m.fields, err = parseFields(header.tables(1)); err != nil {
+		return nil, err
+	}
+	m.cols = columns(m.fields, nil)
+	m.tbl = schema.NewTable(name)
+	names := make([]string, len(m.cols))
+	for i, col := range m.cols {
+		names[i] = col.name
+		m.tbl.AddField(schema.NewFieldBase(col.name, col.typ, 64, col.typ.String()))
+	}
+	m.tbl.SetColumns(names)
+	m.colindex = m.tbl.FieldPositions
+	return m, nil
+}
+
+// readMessage read the next encapsulated message, its header and body,
+// io.EOF at the end of the stream
+func (m *ArrowSource) readMessage() (*table, int, []byte, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(m.r, b[:]); err != nil {
+		return nil, 0, nil, err
+	}
+	size := binary.LittleEndian.Uint32(b[:])
+	if size == 0xFFFFFFFF {
+		// continuation marker, then the size
+		if _, err := io.ReadFull(m.r, b[:]); err != nil {
+			return nil, 0, nil, err
+		}
+		size = binary.LittleEndian.Uint32(b[:])
+	}
+	if size == 0 {
+		return nil, 0, nil, io.EOF
+	}
+	meta := make([]byte, size)
+	if _, err := io.ReadFull(m.r, meta); err != nil {
+		return nil, 0, nil, err
+	}
+	msg, err := root(meta)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	body := make([]byte, msg.int64(3, 0))
+	if _, err := io.ReadFull(m.r, body); err != nil {
+		return nil, 0, nil, err
+	}
+	header := msg.table(2)
+	if header == nil {
+		return nil, 0, nil, fmt.Errorf("arrow message has no header")
+	}
+	return header, int(msg.uint8(1, 0)), body, nil
+}
+
+// NextBatch the rows of the next record batch
+func (m *ArrowSource) NextBatch() []schema.Message {
+	if len(m.rows) > 0 {
+		rows := m.rows
+		m.rows = nil
+		return rows
+	}
+	for m.err == nil {
+		header, typ, body, err := m.readMessage()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			m.err = fmt.Errorf("could not read arrow record batch of %q: %v", m.name, err)
+			return nil
+		}
+		switch typ {
+		case headerRecordBatch:
+			rows, err := m.batchRows(header, body)
+			if err != nil {
+				m.err = err
+				return nil
+			}
+			return rows
+		case headerDictionaryBatch:
+			m.err = fmt.Errorf("dictionary batches of %q are not supported", m.name)
+		}
+		// other messages (tensors) are not rows
+	}
+	return nil
+}
+
+// batchRows decode the rows of a RecordBatch: length, nodes, buffers,
+// compression
+func (m *ArrowSource) batchRows(header *table, body []byte) ([]schema.Message, error) {
+	if header.table(3) != nil {
+		return nil, fmt.Errorf("compressed arrow record batches of %q are not supported", m.name)
+	}
+	n := int(header.int64(0, 0))
+	b := &batch{body: body, nodes: header.structs(1, 2), buffers: header.structs(2, 2)}
+	arrays := make(map[string][]interface{}, len(m.fields))
+	for _, f := range m.fields {
+		vals, err := b.array(f)
+		if err != nil {
+			return nil, fmt.Errorf("could not read arrow field %q of %q: %v", f.name, m.name, err)
+		}
+		arrays[f.name] = vals
+	}
+
+	// the values of all the rows of the batch, by column
+	ncols := len(m.cols)
+	vals := make([]driver.Value, n*ncols)
+	for ci, col := range m.cols {
+		array := arrays[col.path[0]]
+		for i := 0; i < n && i < len(array); i++ {
+			v := array[i]
+			for _, name := range col.path[1:] {
+				rec, ok := v.(map[string]interface{})
+				if !ok {
+					v = nil
+					break
+				}
+				v = rec[name]
+			}
+			vals[i*ncols+ci] = v
+		}
+	}
+	rows := make([]schema.Message, n)
+	for i := range rows {
+		m.rowct++
+		rows[i] = datasource.NewSqlDriverMessageMap(m.rowct, vals[i*ncols:(i+1)*ncols:(i+1)*ncols], m.colindex)
+	}
+	return rows, nil
+}
+
+// Next the next row, of the batch of the rows not yet read
+func (m *ArrowSource) Next() schema.Message {
+	for len(m.rows) == 0 {
+		if m.rows = m.NextBatch(); m.rows == nil {
+			return nil
+		}
+	}
+	row := m.rows[0]
+	m.rows = m.rows[1:]
+	return row
+}
+
+func (m *ArrowSource) Tables() []string                    { return []string{m.name} }
+func (m *ArrowSource) Table(string) (*schema.Table, error) { return m.tbl, nil }
+func (m *ArrowSource) Open(string) (schema.Conn, error)    { return m, nil }
+func (m *ArrowSource) Columns() []string                   { return m.tbl.Columns() }
+func (m *ArrowSource) Close() error                        { return nil }
+func (m *ArrowSource) Metrics() *datasource.Metrics        { return &m.metrics }
+
+// Err the error reading the stream, nil if all batches were read
+func (m *ArrowSource) Err() error { return m.err }
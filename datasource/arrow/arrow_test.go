@@ -0,0 +1,315 @@
+package arrow_test
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/arrow"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+)
+
+// the flatbuffers values of the ipc metadata of the tests, tables are
+// written before the tables, vectors and strings they refer to
+type (
+	u8      uint8
+	i16     int16
+	i32     int32
+	i64     int64
+	str     string
+	tbl     []interface{} // fields by index, nil not set
+	tbls    []tbl
+	structs [][]int64
+)
+
+type builder struct{ buf []byte }
+
+func (b *builder) put(v interface{}) {
+	switch v := v.(type) {
+	case u8:
+		b.buf = append(b.buf, byte(v))
+	case i16:
+		b.buf = append(b.buf, 0, 0)
+		binary.LittleEndian.PutUint16(b.buf[len(b.buf)-2:], uint16(v))
+	case i32:
+		b.buf = append(b.buf, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(b.buf[len(b.buf)-4:], uint32(v))
+	case i64:
+		b.buf = append(b.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint64(b.buf[len(b.buf)-8:], uint64(v))
+	}
+}
+
+// ref set the reference at pos to the position of the value written by w
+func (b *builder) ref(pos int, w func() int) {
+	to := w()
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(to-pos))
+}
+
+func (b *builder) write(v interface{}) int {
+	pos := len(b.buf)
+	switch v := v.(type) {
+	case str:
+		b.put(i32(len(v)))
+		b.buf = append(append(b.buf, v...), 0)
+	case structs:
+		b.put(i32(len(v)))
+		for _, s := range v {
+			for _, l := range s {
+				b.put(i64(l))
+			}
+		}
+	case tbls:
+		b.put(i32(len(v)))
+		b.buf = append(b.buf, make([]byte, 4*len(v))...)
+		for i, t := range v {
+			t := t
+			b.ref(pos+4+4*i, func() int { return b.write(t) })
+		}
+	case tbl:
+		vtable := pos
+		b.buf = append(b.buf, make([]byte, 4+2*len(v))...)
+		pos = len(b.buf)
+		b.put(i32(pos - vtable))
+		type pending struct {
+			at int
+			v  interface{}
+		}
+		refs := make([]pending, 0)
+		for i, f := range v {
+			if f == nil {
+				continue
+			}
+			binary.LittleEndian.PutUint16(b.buf[vtable+4+2*i:], uint16(len(b.buf)-pos))
+			switch f.(type) {
+			case u8, i16, i32, i64:
+				b.put(f)
+			default:
+				refs = append(refs, pending{len(b.buf), f})
+				b.put(i32(0))
+			}
+		}
+		binary.LittleEndian.PutUint16(b.buf[vtable:], uint16(4+2*len(v)))
+		binary.LittleEndian.PutUint16(b.buf[vtable+2:], uint16(len(b.buf)-pos))
+		for _, r := range refs {
+			r := r
+			b.ref(r.at, func() int { return b.write(r.v) })
+		}
+	}
+	return pos
+}
+
+// message an encapsulated ipc message, of header type and header
+func message(typ u8, header tbl, body []byte) []byte {
+	b := &builder{buf: make([]byte, 4)}
+	b.ref(0, func() int { return b.write(tbl{i16(4), typ, header, i64(len(body))}) })
+	for len(b.buf)%8 != 0 {
+		b.buf = append(b.buf, 0)
+	}
+	msg := []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(msg[4:], uint32(len(b.buf)))
+	return append(append(msg, b.buf...), body...)
+}
+
+func field(name string, typ u8, t tbl, children ...tbl) tbl {
+	return tbl{str(name), u8(1), typ, t, nil, tbls(children)}
+}
+
+var fields = tbls{
+	field("id", 2, tbl{i32(64), u8(1)}),
+	field("name", 5, tbl{}),
+	field("price", 7, tbl{i32(9), i32(2), i32(128)}),
+	field("created", 10, tbl{i16(1), str("UTC")}),
+	field("user", 13, tbl{},
+		field("city", 5, tbl{}),
+		field("tags", 12, tbl{}, field("item", 5, tbl{}))),
+	field("active", 6, tbl{}),
+}
+
+// body the buffers of a record batch
+type body struct {
+	buf     []byte
+	nodes   structs
+	buffers structs
+}
+
+func (m *body) node(n int) { m.nodes = append(m.nodes, []int64{int64(n), 0}) }
+func (m *body) buffer(b []byte) {
+	m.buffers = append(m.buffers, []int64{int64(len(m.buf)), int64(len(b))})
+	m.buf = append(m.buf, b...)
+	for len(m.buf)%8 != 0 {
+		m.buf = append(m.buf, 0)
+	}
+}
+func (m *body) bitmap(bits ...bool) {
+	b := make([]byte, (len(bits)+7)/8)
+	for i, set := range bits {
+		if set {
+			b[i/8] |= 1 << uint(i%8)
+		}
+	}
+	m.buffer(b)
+}
+func (m *body) longs(vals ...int64) {
+	b := make([]byte, 8*len(vals))
+	for i, v := range vals {
+		binary.LittleEndian.PutUint64(b[8*i:], uint64(v))
+	}
+	m.buffer(b)
+}
+func (m *body) decimals(vals ...int64) {
+	b := make([]byte, 16*len(vals))
+	for i, v := range vals {
+		binary.LittleEndian.PutUint64(b[16*i:], uint64(v))
+		if v < 0 {
+			binary.LittleEndian.PutUint64(b[16*i+8:], ^uint64(0))
+		}
+	}
+	m.buffer(b)
+}
+func (m *body) strings(valid []bool, strs ...string) {
+	m.node(len(strs))
+	m.bitmap(valid...)
+	offsets := make([]byte, 4*(len(strs)+1))
+	data := make([]byte, 0)
+	for i, s := range strs {
+		data = append(data, s...)
+		binary.LittleEndian.PutUint32(offsets[4*(i+1):], uint32(len(data)))
+	}
+	m.buffer(offsets)
+	m.buffer(data)
+}
+
+type order struct {
+	id      int64
+	name    string // empty is null
+	price   int64  // cents
+	created time.Time
+	city    string
+	tags    []string
+	active  bool
+}
+
+func recordBatch(orders ...order) []byte {
+	n := len(orders)
+	b := &body{}
+	valid := func(f func(o order) bool) []bool {
+		bits := make([]bool, n)
+		for i, o := range orders {
+			bits[i] = f(o)
+		}
+		return bits
+	}
+	all := valid(func(order) bool { return true })
+	ids, prices, created := make([]int64, n), make([]int64, n), make([]int64, n)
+	names, cities, tags := make([]string, n), make([]string, n), make([]string, 0)
+	tagOffsets := make([]byte, 4*(n+1))
+	for i, o := range orders {
+		ids[i], prices[i], created[i] = o.id, o.price, o.created.UnixNano()/1e6
+		names[i], cities[i] = o.name, o.city
+		tags = append(tags, o.tags...)
+		binary.LittleEndian.PutUint32(tagOffsets[4*(i+1):], uint32(len(tags)))
+	}
+	b.node(n)
+	b.bitmap()
+	b.longs(ids...)
+	b.strings(valid(func(o order) bool { return o.name != "" }), names...)
+	b.node(n)
+	b.bitmap()
+	b.decimals(prices...)
+	b.node(n)
+	b.bitmap()
+	b.longs(created...)
+	// user struct, of city and tags
+	b.node(n)
+	b.bitmap(all...)
+	b.strings(all, cities...)
+	b.node(n)
+	b.bitmap(all...)
+	b.buffer(tagOffsets)
+	b.strings(nil, tags...)
+	b.node(n)
+	b.bitmap()
+	b.bitmap(valid(func(o order) bool { return o.active })...)
+	return message(3, tbl{i64(n), b.nodes, b.buffers}, b.buf)
+}
+
+var created = time.Date(2016, 5, 4, 10, 30, 0, 0, time.UTC)
+
+func stream() []byte {
+	s := message(1, tbl{nil, fields}, nil)
+	s = append(s, recordBatch(
+		order{1, "aaron", 1999, created, "sf", []string{"vip", "new"}, true},
+		order{2, "", -250, created.Add(time.Hour), "nyc", nil, false},
+		order{3, "carol", 10000, created.Add(2 * time.Hour), "sf", []string{"vip"}, true},
+	)...)
+	s = append(s, recordBatch(order{4, "dan", 500, created, "la", nil, false})...)
+	return append(s, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0)
+}
+
+func TestArrowSource(t *testing.T) {
+	src, err := arrow.NewArrowSource("orders", bytes.NewReader(stream()))
+	assert.Tf(t, err == nil, "no error %v", err)
+	table, _ := src.Table("orders")
+	assert.Equal(t, []string{"id", "name", "price", "created", "user.city", "user.tags", "active"}, table.Columns())
+	assert.Equal(t, "number", table.FieldMap["price"].Type.String())
+	assert.Equal(t, "[]string", table.FieldMap["user.tags"].Type.String())
+
+	// a record batch is read whole
+	rows := src.NextBatch()
+	assert.Equal(t, 3, len(rows))
+	assert.Equal(t, []driver.Value{int64(2), nil, -2.5, created.Add(time.Hour), "nyc", []string{}, false},
+		rows[1].(*datasource.SqlDriverMessageMap).Values())
+	assert.Equal(t, 1, len(src.NextBatch()))
+	assert.T(t, src.NextBatch() == nil)
+	assert.Tf(t, src.Err() == nil, "no error %v", src.Err())
+
+	// the file format is the stream after the magic, then a footer
+	file := append([]byte("ARROW1\x00\x00"), stream()...)
+	file = append(append(file, 0, 0, 0, 0), []byte("ARROW1")...)
+	for i, data := range [][]byte{stream(), file} {
+		for _, batchSize := range []int{1, 2} {
+			name := fmt.Sprintf("arroworders%d%d", i, batchSize)
+			src, err := arrow.NewArrowSource("orders", bytes.NewReader(data))
+			assert.Tf(t, err == nil, "no error %v", err)
+			ctx := plan.NewContext(`SELECT id, name, price, user.city AS city, user.tags AS tags
+				FROM orders WHERE active = true OR price > 4`)
+			ctx.Schema = datasource.RegisterSchemaSource(name, name, src)
+			ctx.RowBatchSize = batchSize
+			job, err := exec.BuildSqlJob(ctx)
+			assert.Tf(t, err == nil, "no error %v", err)
+			out := exec.NewResultRows(ctx, []string{"id", "name", "price", "city", "tags"})
+			job.RootTask.Add(out)
+			assert.T(t, job.Setup() == nil)
+			go job.Run()
+			got := make([][]driver.Value, 0)
+			for {
+				dest := make([]driver.Value, 5)
+				if out.Next(dest) != nil {
+					break
+				}
+				got = append(got, dest)
+			}
+			job.Close()
+			assert.Equalf(t, [][]driver.Value{
+				{int64(1), "aaron", 19.99, "sf", []string{"vip", "new"}},
+				{int64(3), "carol", 100.0, "sf", []string{"vip"}},
+				{int64(4), "dan", 5.0, "la", nil},
+			}, got, "batch size %d", batchSize)
+		}
+	}
+
+	// unsupported encodings are an error
+	dict := tbls{tbl{str("d"), u8(1), u8(5), tbl{}, tbl{i64(0)}, tbls{}}}
+	_, err = arrow.NewArrowSource("orders", bytes.NewReader(message(1, tbl{nil, dict}, nil)))
+	assert.T(t, err != nil)
+	_, err = arrow.NewArrowSource("orders", bytes.NewReader([]byte("not arrow")))
+	assert.T(t, err != nil)
+}
@@ -0,0 +1,265 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/araddon/qlbridge/value"
+)
+
+// batch the buffers of a record batch, read field by field (depth first)
+// as the arrays of the fields
+type batch struct {
+	body    []byte
+	nodes   [][]int64 // length, null count
+	buffers [][]int64 // offset, length in body
+	node    int
+	buffer  int
+}
+
+func (m *batch) nextNode() (int, error) {
+	if m.node >= len(m.nodes) {
+		return 0, fmt.Errorf("arrow record batch has too few field nodes")
+	}
+	n := m.nodes[m.node][0]
+	m.node++
+	return int(n), nil
+}
+
+func (m *batch) nextBuffer() ([]byte, error) {
+	if m.buffer >= len(m.buffers) {
+		return nil, fmt.Errorf("arrow record batch has too few buffers")
+	}
+	off, n := m.buffers[m.buffer][0], m.buffers[m.buffer][1]
+	m.buffer++
+	if off < 0 || n < 0 || off+n > int64(len(m.body)) {
+		return nil, fmt.Errorf("arrow buffer [%d:%d] is outside the body of %d bytes", off, off+n, len(m.body))
+	}
+	return m.body[off : off+n], nil
+}
+
+// bit is bit i of bitmap set, a missing (empty) validity bitmap is all set
+func bit(bitmap []byte, i int) bool {
+	if len(bitmap) == 0 {
+		return true
+	}
+	return i>>3 < len(bitmap) && bitmap[i>>3]&(1<<uint(i&7)) != 0
+}
+
+// array read the array of field f, its values (nil for nulls) by row
+func (m *batch) array(f *field) ([]interface{}, error) {
+	n, err := m.nextNode()
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]interface{}, n)
+	if f.typ == typeNull {
+		return vals, nil
+	}
+	validity, err := m.nextBuffer()
+	if err != nil {
+		return nil, err
+	}
+	switch f.typ {
+	case typeStruct:
+		children := make([][]interface{}, len(f.children))
+		for i, child := range f.children {
+			if children[i], err = m.array(child); err != nil {
+				return nil, err
+			}
+		}
+		for i := range vals {
+			if !bit(validity, i) {
+				continue
+			}
+			rec := make(map[string]interface{}, len(f.children))
+			for j, child := range f.children {
+				if i < len(children[j]) {
+					rec[child.name] = children[j][i]
+				}
+			}
+			vals[i] = rec
+		}
+		return vals, nil
+	case typeFixedSizeList:
+		items, err := m.array(f.children[0])
+		if err != nil {
+			return nil, err
+		}
+		for i := range vals {
+			if bit(validity, i) && (i+1)*f.width <= len(items) {
+				vals[i] = listValue(f, items[i*f.width:(i+1)*f.width])
+			}
+		}
+		return vals, nil
+	}
+	data, err := m.nextBuffer()
+	if err != nil {
+		return nil, err
+	}
+	switch f.typ {
+	case typeBinary, typeUtf8, typeLargeBinary, typeLargeUtf8, typeList, typeLargeList:
+		offsets := data
+		wide := f.typ == typeLargeBinary || f.typ == typeLargeUtf8 || f.typ == typeLargeList
+		offset := func(i int) int {
+			if wide {
+				return int(binary.LittleEndian.Uint64(offsets[i*8:]))
+			}
+			return int(binary.LittleEndian.Uint32(offsets[i*4:]))
+		}
+		width := 4
+		if wide {
+			width = 8
+		}
+		if n > 0 && len(offsets) < (n+1)*width {
+			return nil, fmt.Errorf("arrow field %q has %d offsets of %d rows", f.name, len(offsets)/width, n)
+		}
+		if f.typ == typeList || f.typ == typeLargeList {
+			items, err := m.array(f.children[0])
+			if err != nil {
+				return nil, err
+			}
+			for i := range vals {
+				if start, end := offset(i), offset(i+1); bit(validity, i) && start <= end && end <= len(items) {
+					vals[i] = listValue(f, items[start:end])
+				}
+			}
+			return vals, nil
+		}
+		if data, err = m.nextBuffer(); err != nil {
+			return nil, err
+		}
+		for i := range vals {
+			start, end := offset(i), offset(i+1)
+			if !bit(validity, i) || start > end || end > len(data) {
+				continue
+			}
+			if f.typ == typeUtf8 || f.typ == typeLargeUtf8 {
+				vals[i] = string(data[start:end])
+			} else {
+				vals[i] = append([]byte{}, data[start:end]...)
+			}
+		}
+		return vals, nil
+	case typeBool:
+		for i := range vals {
+			if bit(validity, i) {
+				vals[i] = bit(data, i)
+			}
+		}
+		return vals, nil
+	}
+	size := f.fixedSize()
+	if len(data) < n*size {
+		return nil, fmt.Errorf("arrow field %q has %d bytes of %d rows", f.name, len(data), n)
+	}
+	for i := range vals {
+		if bit(validity, i) {
+			vals[i] = f.fixedValue(data[i*size : (i+1)*size])
+		}
+	}
+	return vals, nil
+}
+
+// fixedSize bytes of the values of fixed width types
+func (f *field) fixedSize() int {
+	switch f.typ {
+	case typeInt, typeDecimal:
+		return f.width / 8
+	case typeFloatingPoint:
+		if f.width == 1 {
+			return 4
+		}
+		return 8
+	case typeDate:
+		if f.unit == 0 {
+			return 4
+		}
+		return 8
+	case typeTimestamp:
+		return 8
+	}
+	return f.width
+}
+
+// fixedValue the value of the bytes b of a fixed width type
+func (f *field) fixedValue(b []byte) interface{} {
+	switch f.typ {
+	case typeInt:
+		var u uint64
+		switch len(b) {
+		case 1:
+			if f.signed {
+				return int64(int8(b[0]))
+			}
+			u = uint64(b[0])
+		case 2:
+			if f.signed {
+				return int64(int16(binary.LittleEndian.Uint16(b)))
+			}
+			u = uint64(binary.LittleEndian.Uint16(b))
+		case 4:
+			if f.signed {
+				return int64(int32(binary.LittleEndian.Uint32(b)))
+			}
+			u = uint64(binary.LittleEndian.Uint32(b))
+		default:
+			u = binary.LittleEndian.Uint64(b)
+		}
+		return int64(u)
+	case typeFloatingPoint:
+		if len(b) == 4 {
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	case typeDecimal:
+		return decimal(b, f.scale)
+	case typeDate:
+		if f.unit == 0 {
+			return time.Unix(int64(int32(binary.LittleEndian.Uint32(b)))*86400, 0).UTC()
+		}
+		ms := int64(binary.LittleEndian.Uint64(b))
+		return time.Unix(ms/1e3, (ms%1e3)*1e6).UTC()
+	case typeTimestamp:
+		v := int64(binary.LittleEndian.Uint64(b))
+		switch f.unit {
+		case 0:
+			return time.Unix(v, 0).UTC()
+		case 1:
+			return time.Unix(v/1e3, (v%1e3)*1e6).UTC()
+		case 2:
+			return time.Unix(v/1e6, (v%1e6)*1e3).UTC()
+		}
+		return time.Unix(0, v).UTC()
+	}
+	return append([]byte{}, b...)
+}
+
+// listValue the value of the items of a list, []string of strings
+func listValue(f *field, items []interface{}) interface{} {
+	if f.valueType() != value.StringsType {
+		return append([]interface{}{}, items...)
+	}
+	strs := make([]string, len(items))
+	for i, item := range items {
+		strs[i], _ = item.(string)
+	}
+	return strs
+}
+
+// decimal the value of the little-endian two's complement unscaled bytes
+func decimal(b []byte, scale int) float64 {
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	unscaled := new(big.Int).SetBytes(be)
+	if len(be) > 0 && be[0]&0x80 != 0 {
+		unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(be)*8)))
+	}
+	f, _ := new(big.Rat).SetFrac(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)).Float64()
+	return f
+}
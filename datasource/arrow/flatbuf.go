@@ -0,0 +1,148 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// table a flatbuffers table of the ipc metadata, fields are read by their
+// index in the schema (Schema.fbs, Message.fbs) of the table
+type table struct {
+	buf []byte
+	pos int
+}
+
+// root the root table of a flatbuffer
+func root(buf []byte) (*table, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("arrow metadata of %d bytes is too short", len(buf))
+	}
+	t := &table{buf: buf, pos: int(binary.LittleEndian.Uint32(buf))}
+	if !t.valid(t.pos, 4) {
+		return nil, fmt.Errorf("invalid arrow metadata")
+	}
+	return t, nil
+}
+
+func (t *table) valid(pos, n int) bool { return pos >= 0 && pos+n <= len(t.buf) }
+
+// field the position of field i, 0 if it is not set (is the default)
+func (t *table) field(i int) int {
+	vtable := t.pos - int(int32(binary.LittleEndian.Uint32(t.buf[t.pos:])))
+	if !t.valid(vtable, 4) {
+		return 0
+	}
+	vsize := int(binary.LittleEndian.Uint16(t.buf[vtable:]))
+	at := 4 + 2*i
+	if at+2 > vsize || !t.valid(vtable+at, 2) {
+		return 0
+	}
+	off := int(binary.LittleEndian.Uint16(t.buf[vtable+at:]))
+	if off == 0 {
+		return 0
+	}
+	return t.pos + off
+}
+
+func (t *table) uint8(i int, def uint8) uint8 {
+	if p := t.field(i); p != 0 && t.valid(p, 1) {
+		return t.buf[p]
+	}
+	return def
+}
+
+func (t *table) bool(i int) bool { return t.uint8(i, 0) != 0 }
+
+func (t *table) int16(i int, def int16) int16 {
+	if p := t.field(i); p != 0 && t.valid(p, 2) {
+		return int16(binary.LittleEndian.Uint16(t.buf[p:]))
+	}
+	return def
+}
+
+func (t *table) int32(i int, def int32) int32 {
+	if p := t.field(i); p != 0 && t.valid(p, 4) {
+		return int32(binary.LittleEndian.Uint32(t.buf[p:]))
+	}
+	return def
+}
+
+func (t *table) int64(i int, def int64) int64 {
+	if p := t.field(i); p != 0 && t.valid(p, 8) {
+		return int64(binary.LittleEndian.Uint64(t.buf[p:]))
+	}
+	return def
+}
+
+// ref the position of the table, vector or string referenced by field i
+func (t *table) ref(i int) int {
+	p := t.field(i)
+	if p == 0 || !t.valid(p, 4) {
+		return 0
+	}
+	to := p + int(binary.LittleEndian.Uint32(t.buf[p:]))
+	if !t.valid(to, 4) {
+		return 0
+	}
+	return to
+}
+
+// table the table of field i, nil if not set
+func (t *table) table(i int) *table {
+	p := t.ref(i)
+	if p == 0 {
+		return nil
+	}
+	return &table{buf: t.buf, pos: p}
+}
+
+func (t *table) string(i int) string {
+	p := t.ref(i)
+	if p == 0 {
+		return ""
+	}
+	n := int(binary.LittleEndian.Uint32(t.buf[p:]))
+	if !t.valid(p+4, n) {
+		return ""
+	}
+	return string(t.buf[p+4 : p+4+n])
+}
+
+// tables the vector of tables of field i
+func (t *table) tables(i int) []*table {
+	p := t.ref(i)
+	if p == 0 {
+		return nil
+	}
+	n := int(binary.LittleEndian.Uint32(t.buf[p:]))
+	tables := make([]*table, 0, n)
+	for j := 0; j < n; j++ {
+		at := p + 4 + 4*j
+		if !t.valid(at, 4) {
+			break
+		}
+		tables = append(tables, &table{buf: t.buf, pos: at + int(binary.LittleEndian.Uint32(t.buf[at:]))})
+	}
+	return tables
+}
+
+// structs the vector of structs of size (of longs) of field i, ie the
+// FieldNode and Buffer vectors of a record batch
+func (t *table) structs(i int, size int) [][]int64 {
+	p := t.ref(i)
+	if p == 0 {
+		return nil
+	}
+	n := int(binary.LittleEndian.Uint32(t.buf[p:]))
+	if !t.valid(p+4, n*size*8) {
+		return nil
+	}
+	structs := make([][]int64, n)
+	for j := range structs {
+		structs[j] = make([]int64, size)
+		for k := range structs[j] {
+			structs[j][k] = int64(binary.LittleEndian.Uint64(t.buf[p+4+(j*size+k)*8:]))
+		}
+	}
+	return structs
+}
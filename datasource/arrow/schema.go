@@ -0,0 +1,148 @@
+package arrow
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/araddon/qlbridge/value"
+)
+
+// the types of the Type union of Schema.fbs
+const (
+	typeNull            = 1
+	typeInt             = 2
+	typeFloatingPoint   = 3
+	typeBinary          = 4
+	typeUtf8            = 5
+	typeBool            = 6
+	typeDecimal         = 7
+	typeDate            = 8
+	typeTimestamp       = 10
+	typeList            = 12
+	typeStruct          = 13
+	typeFixedSizeBinary = 15
+	typeFixedSizeList   = 16
+	typeLargeBinary     = 19
+	typeLargeUtf8       = 20
+	typeLargeList       = 21
+)
+
+// field a field of an arrow schema, or the child of a nested field
+type field struct {
+	name     string
+	typ      int
+	width    int // bits of ints, bytes of fixed size binary, items of fixed size lists
+	signed   bool
+	scale    int // of decimals
+	unit     int // of dates and timestamps
+	children []*field
+}
+
+// parseFields the fields of a Schema table
+func parseFields(tables []*table) ([]*field, error) {
+	fields := make([]*field, len(tables))
+	for i, ft := range tables {
+		f, err := parseField(ft)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = f
+	}
+	return fields, nil
+}
+
+// parseField a Field table: name, nullable, type_type, type, dictionary,
+// children
+func parseField(ft *table) (*field, error) {
+	f := &field{name: ft.string(0), typ: int(ft.uint8(2, 0))}
+	if ft.table(4) != nil {
+		return nil, fmt.Errorf("dictionary encoded arrow field %q is not supported", f.name)
+	}
+	tt := ft.table(3)
+	if tt == nil {
+		tt = &table{}
+	}
+	opt := func(read func()) {
+		if tt.buf != nil {
+			read()
+		}
+	}
+	switch f.typ {
+	case typeNull, typeBinary, typeUtf8, typeBool, typeLargeBinary, typeLargeUtf8,
+		typeList, typeLargeList, typeStruct:
+	case typeInt:
+		opt(func() { f.width, f.signed = int(tt.int32(0, 0)), tt.bool(1) })
+	case typeFloatingPoint:
+		opt(func() { f.width = int(tt.int16(0, 0)) })
+		if f.width == 0 {
+			return nil, fmt.Errorf("half float arrow field %q is not supported", f.name)
+		}
+	case typeDecimal:
+		f.width = 128
+		opt(func() { f.scale, f.width = int(tt.int32(1, 0)), int(tt.int32(2, 128)) })
+	case typeDate:
+		f.unit = 1
+		opt(func() { f.unit = int(tt.int16(0, 1)) })
+	case typeTimestamp:
+		opt(func() { f.unit = int(tt.int16(0, 0)) })
+	case typeFixedSizeBinary, typeFixedSizeList:
+		opt(func() { f.width = int(tt.int32(0, 0)) })
+	default:
+		return nil, fmt.Errorf("arrow field %q of type %d is not supported", f.name, f.typ)
+	}
+	children, err := parseFields(ft.tables(5))
+	if err != nil {
+		return nil, err
+	}
+	f.children = children
+	if (f.typ == typeList || f.typ == typeLargeList || f.typ == typeFixedSizeList) && len(children) != 1 {
+		return nil, fmt.Errorf("arrow list field %q has %d item fields", f.name, len(children))
+	}
+	return f, nil
+}
+
+// valueType the value type of a column of field f
+func (f *field) valueType() value.ValueType {
+	switch f.typ {
+	case typeNull:
+		return value.NilType
+	case typeInt:
+		return value.IntType
+	case typeFloatingPoint, typeDecimal:
+		return value.NumberType
+	case typeBool:
+		return value.BoolType
+	case typeUtf8, typeLargeUtf8:
+		return value.StringType
+	case typeBinary, typeLargeBinary, typeFixedSizeBinary:
+		return value.ByteSliceType
+	case typeDate, typeTimestamp:
+		return value.TimeType
+	case typeList, typeLargeList, typeFixedSizeList:
+		if item := f.children[0].typ; item == typeUtf8 || item == typeLargeUtf8 {
+			return value.StringsType
+		}
+	}
+	return value.JsonType
+}
+
+// column a column of a field, or of a field of a struct
+type column struct {
+	name string
+	path []string
+	typ  value.ValueType
+}
+
+// columns of fields, structs flattened into dotted columns, ie user.name
+func columns(fields []*field, prefix []string) []*column {
+	cols := make([]*column, 0, len(fields))
+	for _, f := range fields {
+		path := append(append([]string{}, prefix...), f.name)
+		if f.typ == typeStruct {
+			cols = append(cols, columns(f.children, path)...)
+			continue
+		}
+		cols = append(cols, &column{name: strings.Join(path, "."), path: path, typ: f.valueType()})
+	}
+	return cols
+}
@@ -0,0 +1,406 @@
+// Avro package implements Datasources of avro records, of object container
+// files and of schema registry framed messages.
+package avro
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/files"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Different Features of the Avro Data Sources
+	_ schema.Source            = (*AvroSource)(nil)
+	_ datasource.MeteredSource = (*AvroSource)(nil)
+	_ schema.ConnScanner       = (*AvroSource)(nil)
+	_ schema.ConnColumns       = (*AvroSource)(nil)
+	_ schema.IteratorErr       = (*AvroSource)(nil)
+	_ files.FileScanner        = (*AvroSource)(nil)
+	_ schema.Source            = (*MessageSource)(nil)
+	_ datasource.MeteredSource = (*MessageSource)(nil)
+	_ schema.ConnScanner       = (*MessageSource)(nil)
+	_ schema.ConnColumns       = (*MessageSource)(nil)
+
+	magic = []byte{'O', 'b', 'j', 1}
+
+	codecMu sync.RWMutex
+	codecs  = map[string]Codec{
+		"null": func(block []byte) ([]byte, error) { return block, nil },
+		"deflate": func(block []byte) ([]byte, error) {
+			return ioutil.ReadAll(flate.NewReader(bytes.NewReader(block)))
+		},
+	}
+)
+
+func init() {
+	files.RegisterFormat("avro", func(table string, r io.Reader, settings u.JsonHelper) (files.FileScanner, error) {
+		return NewAvroSource(table, r)
+	}, "avro")
+}
+
+// Codec decompress a block of a container file
+type Codec func(block []byte) ([]byte, error)
+
+// RegisterCodec register the Codec of the blocks of container files of
+// avro.codec name, ie snappy which has no decoder in the standard library
+func RegisterCodec(name string, c Codec) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	codecs[name] = c
+}
+
+// table the columns of a record schema
+type table struct {
+	name     string
+	rec      *avroType
+	tbl      *schema.Table
+	cols     []*column
+	colindex map[string]int
+}
+
+func newTable(name string, rec *avroType) (*table, error) {
+	rec = rec.nullable()
+	if rec.typ != "record" {
+		return nil, fmt.Errorf("avro schema of %q is a %s not a record", name, rec.typ)
+	}
+	m := &table{name: name, rec: rec, tbl: schema.NewTable(name), cols: columns(rec, nil, nil)}
+	names := make([]string, len(m.cols))
+	for i, col := range m.cols {
+		names[i] = col.name
+		m.tbl.AddField(schema.NewFieldBase(col.name, col.typ, 64, col.typ.String()))
+	}
+	m.tbl.SetColumns(names)
+	m.colindex = m.tbl.FieldPositions
+	return m, nil
+}
+
+// row the values of the columns of a decoded record
+func (m *table) row(rec map[string]interface{}) []driver.Value {
+	vals := make([]driver.Value, len(m.cols))
+	for i, col := range m.cols {
+		var v interface{} = rec
+		for _, name := range col.path {
+			fields, ok := v.(map[string]interface{})
+			if !ok {
+				v = nil
+				break
+			}
+			v = fields[name]
+		}
+		vals[i] = columnValue(v, col)
+	}
+	return vals
+}
+
+func columnValue(v interface{}, col *column) driver.Value {
+	if items, ok := v.([]interface{}); ok && col.typ.String() == "[]string" {
+		strs := make([]string, len(items))
+		for i, item := range items {
+			strs[i] = fmt.Sprint(item)
+		}
+		return strs
+	}
+	return v
+}
+
+// AvroSource a DataSource of the records of an avro object container file,
+// as a single table of the (record) schema of the file.
+//   - nested records are flattened into dotted columns, ie user.name
+//   - decimals are numbers, dates and timestamps times
+//   - blocks are null or deflate compressed, else see RegisterCodec
+//   - forward only single pass, not thread-safe, read only
+//
+// Files of the ".avro" extension are read by the files source.
+type AvroSource struct {
+	*table
+	r       *bufio.Reader
+	codec   Codec
+	sync    []byte
+	block   *decoder
+	left    int64 // records of the block not yet read
+	rowct   uint64
+	err     error
+	metrics datasource.Metrics
+}
+
+// NewAvroSource read the header of the container file of r, its schema
+func NewAvroSource(name string, r io.Reader) (*AvroSource, error) {
+	m := &AvroSource{r: bufio.NewReader(r)}
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(m.r, head); err != nil || !bytes.Equal(head, magic) {
+		return nil, fmt.Errorf("%q is not an avro container file", name)
+	}
+	meta := make(map[string][]byte)
+	err := m.readBlocks(func() error {
+		k, err := m.readBytes()
+		if err != nil {
+			return err
+		}
+		v, err := m.readBytes()
+		meta[string(k)] = v
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read avro header of %q: %v", name, err)
+	}
+	codecName := string(meta["avro.codec"])
+	if codecName == "" {
+		codecName = "null"
+	}
+	codecMu.RLock()
+	m.codec = codecs[codecName]
+	codecMu.RUnlock()
+	if m.codec == nil {
+		return nil, fmt.Errorf("avro codec %q of %q is not registered, see avro.RegisterCodec", codecName, name)
+	}
+	rec, err := parseSchema(string(meta["avro.schema"]))
+	if err != nil {
+		return nil, err
+	}
+	if m.table, err = newTable(name, rec); err != nil {
+		return nil, err
+	}
+	m.sync = make([]byte, 16)
+	if _, err := io.ReadFull(m.r, m.sync); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *AvroSource) readLong() (int64, error) { return binary.ReadVarint(m.r) }
+func (m *AvroSource) readBytes() ([]byte, error) {
+	n, err := m.readLong()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(m.r, b)
+	return b, err
+}
+
+// readBlocks read the blocks of a map (of the header) from the file
+func (m *AvroSource) readBlocks(item func() error) error {
+	for {
+		n, err := m.readLong()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		if n < 0 {
+			n = -n
+			if _, err := m.readLong(); err != nil {
+				return err
+			}
+		}
+		for i := int64(0); i < n; i++ {
+			if err := item(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// nextBlock read the next block of records, false at the end of the file
+func (m *AvroSource) nextBlock() bool {
+	count, err := m.readLong()
+	if err == io.EOF {
+		return false
+	} else if err != nil {
+		m.err = err
+		return false
+	}
+	data, err := m.readBytes()
+	if err != nil {
+		m.err = err
+		return false
+	}
+	sync := make([]byte, 16)
+	if _, err := io.ReadFull(m.r, sync); err != nil || !bytes.Equal(sync, m.sync) {
+		m.err = fmt.Errorf("avro block of %q is not followed by the sync marker", m.name)
+		return false
+	}
+	if data, err = m.codec(data); err != nil {
+		m.err = err
+		return false
+	}
+	m.block, m.left = &decoder{buf: data}, count
+	return true
+}
+
+func (m *AvroSource) Next() schema.Message {
+	for m.left == 0 {
+		if m.err != nil || !m.nextBlock() {
+			return nil
+		}
+	}
+	rec, err := m.block.value(m.rec)
+	if err != nil {
+		m.err = fmt.Errorf("could not decode avro record of %q: %v", m.name, err)
+		return nil
+	}
+	m.left--
+	m.rowct++
+	return datasource.NewSqlDriverMessageMap(m.rowct, m.row(rec.(map[string]interface{})), m.colindex)
+}
+
+func (m *AvroSource) Tables() []string                    { return []string{m.name} }
+func (m *AvroSource) Table(string) (*schema.Table, error) { return m.tbl, nil }
+func (m *AvroSource) Open(string) (schema.Conn, error)    { return m, nil }
+func (m *AvroSource) Columns() []string                   { return m.tbl.Columns() }
+func (m *AvroSource) Close() error                        { return nil }
+func (m *AvroSource) Metrics() *datasource.Metrics        { return &m.metrics }
+
+// Err the error reading the file, nil if all records were read
+func (m *AvroSource) Err() error { return m.err }
+
+// Registry the schemas of the ids of schema registry framed messages
+type Registry interface {
+	Schema(id int) (string, error)
+}
+
+// RegistryClient a Registry of a Confluent compatible schema registry
+// server, ie http://localhost:8081, caching the schemas it has read
+type RegistryClient struct {
+	url    string
+	client *http.Client
+	mu     sync.Mutex
+	cache  map[int]string
+}
+
+// NewRegistryClient a client of the schema registry server of url
+func NewRegistryClient(url string) *RegistryClient {
+	return &RegistryClient{url: strings.TrimRight(url, "/"), client: http.DefaultClient, cache: make(map[int]string)}
+}
+
+// Schema of id, GET /schemas/ids/{id}
+func (m *RegistryClient) Schema(id int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.cache[id]; ok {
+		return s, nil
+	}
+	resp, err := m.client.Get(fmt.Sprintf("%s/schemas/ids/%d", m.url, id))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("schema registry %s: no schema %d, status %d", m.url, id, resp.StatusCode)
+	}
+	var body struct {
+		Schema string `json:"schema"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	m.cache[id] = body.Schema
+	return body.Schema, nil
+}
+
+// MessageSource a DataSource of schema registry framed avro messages (a
+// zero byte, the big-endian 4 byte id of the schema they were written
+// with, then the record) as a table of the columns of a schema.  Records of
+// other (ie older or newer) schemas are read by their own schema, their
+// fields of the same name are the columns, others are null.  Messages that
+// are not framed records are skipped.
+type MessageSource struct {
+	*table
+	registry Registry
+	msgs     <-chan []byte
+	mu       sync.Mutex
+	schemas  map[int]*avroType // writer schemas by id
+	rowct    uint64
+	metrics  datasource.Metrics
+}
+
+// NewMessageSource a table of the messages of msgs (read until closed), of
+// the columns of the schema of id
+func NewMessageSource(name string, registry Registry, id int, msgs <-chan []byte) (*MessageSource, error) {
+	m := &MessageSource{registry: registry, msgs: msgs, schemas: make(map[int]*avroType)}
+	rec, err := m.schema(id)
+	if err != nil {
+		return nil, err
+	}
+	if m.table, err = newTable(name, rec); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// schema the parsed schema of id
+func (m *MessageSource) schema(id int) (*avroType, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t, ok := m.schemas[id]; ok {
+		return t, nil
+	}
+	s, err := m.registry.Schema(id)
+	if err != nil {
+		return nil, err
+	}
+	t, err := parseSchema(s)
+	if err != nil {
+		return nil, err
+	}
+	m.schemas[id] = t
+	return t, nil
+}
+
+// decode a framed message
+func (m *MessageSource) decode(msg []byte) (map[string]interface{}, error) {
+	if len(msg) < 5 || msg[0] != 0 {
+		return nil, fmt.Errorf("not a schema registry framed message")
+	}
+	t, err := m.schema(int(binary.BigEndian.Uint32(msg[1:5])))
+	if err != nil {
+		return nil, err
+	}
+	v, err := (&decoder{buf: msg[5:]}).value(t)
+	if err != nil {
+		return nil, err
+	}
+	rec, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("message is a %T not a record", v)
+	}
+	return rec, nil
+}
+
+func (m *MessageSource) Next() schema.Message {
+	for msg := range m.msgs {
+		rec, err := m.decode(msg)
+		if err != nil {
+			u.Warnf("skipping avro message of %q: %v", m.name, err)
+			continue
+		}
+		m.rowct++
+		return datasource.NewSqlDriverMessageMap(m.rowct, m.row(rec), m.colindex)
+	}
+	return nil
+}
+
+func (m *MessageSource) Tables() []string                    { return []string{m.name} }
+func (m *MessageSource) Table(string) (*schema.Table, error) { return m.tbl, nil }
+func (m *MessageSource) Open(string) (schema.Conn, error)    { return m, nil }
+func (m *MessageSource) Columns() []string                   { return m.tbl.Columns() }
+func (m *MessageSource) Close() error                        { return nil }
+func (m *MessageSource) Metrics() *datasource.Metrics        { return &m.metrics }
@@ -0,0 +1,220 @@
+package avro_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/avro"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// encoder of the avro binary encoding, of the values of the tests
+type encoder struct{ bytes.Buffer }
+
+func (m *encoder) long(v int64) *encoder {
+	b := make([]byte, binary.MaxVarintLen64)
+	m.Write(b[:binary.PutVarint(b, v)])
+	return m
+}
+func (m *encoder) bytes(b []byte) *encoder { m.long(int64(len(b))); m.Write(b); return m }
+func (m *encoder) str(s string) *encoder   { return m.bytes([]byte(s)) }
+
+const orderSchema = `{"type": "record", "name": "Order", "namespace": "shop", "fields": [
+	{"name": "id", "type": "long"},
+	{"name": "price", "type": {"type": "bytes", "logicalType": "decimal", "precision": 9, "scale": 2}},
+	{"name": "created", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+	{"name": "day", "type": {"type": "int", "logicalType": "date"}},
+	{"name": "note", "type": ["null", "string"]},
+	{"name": "user", "type": {"type": "record", "name": "User", "fields": [
+		{"name": "name", "type": "string"},
+		{"name": "tags", "type": {"type": "array", "items": "string"}}
+	]}}
+]}`
+
+// container an avro container file of a block of each of blocks
+func container(codec string, blocks ...[][]byte) []byte {
+	sync := []byte("0123456789abcdef")
+	f := &encoder{}
+	f.Write([]byte{'O', 'b', 'j', 1})
+	f.long(2).str("avro.schema").str(orderSchema).str("avro.codec").str(codec).long(0)
+	f.Write(sync)
+	for _, records := range blocks {
+		data := bytes.Join(records, nil)
+		if codec == "deflate" {
+			var buf bytes.Buffer
+			w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+			w.Write(data)
+			w.Close()
+			data = buf.Bytes()
+		}
+		f.long(int64(len(records))).bytes(data)
+		f.Write(sync)
+	}
+	return f.Bytes()
+}
+
+func order(id int64, price []byte, created time.Time, day int64, note string, name string, tags ...string) []byte {
+	r := &encoder{}
+	r.long(id).bytes(price).long(created.UnixNano() / 1e6).long(day)
+	if note == "" {
+		r.long(0)
+	} else {
+		r.long(1).str(note)
+	}
+	r.str(name)
+	if len(tags) > 0 {
+		r.long(int64(len(tags)))
+		for _, tag := range tags {
+			r.str(tag)
+		}
+	}
+	r.long(0)
+	return r.Bytes()
+}
+
+func query(t *testing.T, sch *schema.Schema, sql string, cols []string) [][]driver.Value {
+	ctx := plan.NewContext(sql)
+	ctx.Schema = sch
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	rows := exec.NewResultRows(ctx, cols)
+	job.RootTask.Add(rows)
+	assert.T(t, job.Setup() == nil)
+	go job.Run()
+	results := make([][]driver.Value, 0)
+	for {
+		dest := make([]driver.Value, len(cols))
+		if rows.Next(dest) != nil {
+			break
+		}
+		results = append(results, dest)
+	}
+	job.Close()
+	return results
+}
+
+func TestAvroSource(t *testing.T) {
+	created := time.Date(2016, 5, 4, 10, 30, 0, 0, time.UTC)
+	file := container("deflate",
+		[][]byte{
+			order(1, []byte{0x07, 0xcf}, created, 16925, "gift", "aaron", "vip", "new"),
+			order(2, []byte{0xff, 0x06}, created.Add(time.Hour), 16926, "", "bob"),
+		},
+		[][]byte{
+			order(3, []byte{0x27, 0x10}, created.Add(2*time.Hour), 16927, "", "carol", "vip"),
+		},
+	)
+	src, err := avro.NewAvroSource("orders", bytes.NewReader(file))
+	assert.Tf(t, err == nil, "no error %v", err)
+	tbl, _ := src.Table("orders")
+	assert.Equal(t, []string{"id", "price", "created", "day", "note", "user.name", "user.tags"}, tbl.Columns())
+	assert.Equal(t, "time", tbl.FieldMap["created"].Type.String())
+	assert.Equal(t, "number", tbl.FieldMap["price"].Type.String())
+
+	sch := datasource.RegisterSchemaSource("avroorders", "orders", src)
+	rows := query(t, sch, "SELECT id, price, created, day, note, user.name AS name, user.tags AS tags FROM orders WHERE price > 0",
+		[]string{"id", "price", "created", "day", "note", "name", "tags"})
+	assert.Tf(t, src.Err() == nil, "no error %v", src.Err())
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, []driver.Value{int64(1), 19.99, created, time.Date(2016, 5, 4, 0, 0, 0, 0, time.UTC),
+		"gift", "aaron", []string{"vip", "new"}}, rows[0])
+	assert.Equal(t, []driver.Value{int64(3), 100.0, created.Add(2 * time.Hour), time.Date(2016, 5, 6, 0, 0, 0, 0, time.UTC),
+		nil, "carol", []string{"vip"}}, rows[1])
+
+	// negative decimals, files of other codecs
+	src, err = avro.NewAvroSource("orders", bytes.NewReader(container("null", [][]byte{
+		order(2, []byte{0xff, 0x06}, created, 0, "", "bob"),
+	})))
+	assert.Tf(t, err == nil, "no error %v", err)
+	msg := src.Next()
+	assert.Equal(t, -2.5, msg.Body().(*datasource.SqlDriverMessageMap).Values()[1])
+	assert.Equal(t, nil, src.Next())
+
+	_, err = avro.NewAvroSource("orders", bytes.NewReader(container("snappy")))
+	assert.T(t, err != nil)
+	avro.RegisterCodec("snappy", func(block []byte) ([]byte, error) { return block, nil })
+	_, err = avro.NewAvroSource("orders", bytes.NewReader(container("snappy")))
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	// truncated files are an error of the scan
+	src, _ = avro.NewAvroSource("orders", bytes.NewReader(file[:len(file)-20]))
+	for msg := src.Next(); msg != nil; msg = src.Next() {
+	}
+	assert.T(t, src.Err() != nil)
+}
+
+type registry map[int]string
+
+func (m registry) Schema(id int) (string, error) {
+	s, ok := m[id]
+	if !ok {
+		return "", fmt.Errorf("no schema %d", id)
+	}
+	return s, nil
+}
+
+func framed(id uint32, record []byte) []byte {
+	msg := []byte{0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(msg[1:], id)
+	return append(msg, record...)
+}
+
+func TestMessageSource(t *testing.T) {
+	reg := registry{
+		1: `{"type": "record", "name": "User", "fields": [{"name": "id", "type": "long"}, {"name": "name", "type": "string"}]}`,
+		2: `{"type": "record", "name": "User", "fields": [{"name": "id", "type": "long"}, {"name": "name", "type": "string"},
+			{"name": "email", "type": ["null", "string"], "default": null}]}`,
+	}
+	msgs := make(chan []byte, 5)
+	msgs <- framed(1, (&encoder{}).long(1).str("aaron").Bytes())
+	msgs <- []byte("not avro")
+	msgs <- framed(9, (&encoder{}).long(9).Bytes())
+	msgs <- framed(2, (&encoder{}).long(2).str("bob").long(1).str("bob@email.com").Bytes())
+	msgs <- framed(2, (&encoder{}).long(3).str("carol").long(0).Bytes())
+	close(msgs)
+
+	src, err := avro.NewMessageSource("users", reg, 2, msgs)
+	assert.Tf(t, err == nil, "no error %v", err)
+	sch := datasource.RegisterSchemaSource("avrousers", "users", src)
+	rows := query(t, sch, "SELECT id, name, email FROM users", []string{"id", "name", "email"})
+	assert.Equal(t, [][]driver.Value{
+		{int64(1), "aaron", nil},
+		{int64(2), "bob", "bob@email.com"},
+		{int64(3), "carol", nil},
+	}, rows)
+
+	_, err = avro.NewMessageSource("users", reg, 5, msgs)
+	assert.T(t, err != nil)
+
+	gets := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gets++
+		if r.URL.Path != "/schemas/ids/1" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"schema": "{\"type\": \"string\"}"}`)
+	}))
+	defer ts.Close()
+	client := avro.NewRegistryClient(ts.URL + "/")
+	for i := 0; i < 2; i++ {
+		s, err := client.Schema(1)
+		assert.Tf(t, err == nil, "no error %v", err)
+		assert.Equal(t, `{"type": "string"}`, s)
+	}
+	assert.Equal(t, 1, gets)
+	_, err = client.Schema(2)
+	assert.T(t, err != nil)
+}
@@ -0,0 +1,193 @@
+package avro
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"time"
+)
+
+// decoder of the avro binary encoding of values
+type decoder struct {
+	buf []byte
+	pos int
+}
+
+func (m *decoder) long() (int64, error) {
+	v, n := binary.Varint(m.buf[m.pos:])
+	if n <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	m.pos += n
+	return v, nil
+}
+
+func (m *decoder) next(n int64) ([]byte, error) {
+	if n < 0 || int64(len(m.buf)-m.pos) < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := m.buf[m.pos : m.pos+int(n)]
+	m.pos += int(n)
+	return b, nil
+}
+
+func (m *decoder) bytes() ([]byte, error) {
+	n, err := m.long()
+	if err != nil {
+		return nil, err
+	}
+	return m.next(n)
+}
+
+// value decode a value of type t, records are map[string]interface{}
+func (m *decoder) value(t *avroType) (interface{}, error) {
+	switch t.typ {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := m.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case "int", "long":
+		v, err := m.long()
+		if err != nil {
+			return nil, err
+		}
+		return logicalLong(t, v), nil
+	case "float":
+		b, err := m.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), nil
+	case "double":
+		b, err := m.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case "bytes", "fixed":
+		var b []byte
+		var err error
+		if t.typ == "fixed" {
+			b, err = m.next(int64(t.size))
+		} else {
+			b, err = m.bytes()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if t.logical == "decimal" {
+			return decimal(b, t.scale), nil
+		}
+		return append([]byte{}, b...), nil
+	case "string":
+		b, err := m.bytes()
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case "enum":
+		i, err := m.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(t.symbols) {
+			return nil, fmt.Errorf("enum %s has no symbol %d", t.name, i)
+		}
+		return t.symbols[i], nil
+	case "union":
+		i, err := m.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(t.union) {
+			return nil, fmt.Errorf("union has no branch %d", i)
+		}
+		return m.value(t.union[i])
+	case "record":
+		rec := make(map[string]interface{}, len(t.fields))
+		for _, f := range t.fields {
+			v, err := m.value(f.typ)
+			if err != nil {
+				return nil, err
+			}
+			rec[f.name] = v
+		}
+		return rec, nil
+	case "array":
+		items := make([]interface{}, 0)
+		err := m.blocks(func() error {
+			v, err := m.value(t.items)
+			items = append(items, v)
+			return err
+		})
+		return items, err
+	case "map":
+		vals := make(map[string]interface{})
+		err := m.blocks(func() error {
+			k, err := m.bytes()
+			if err != nil {
+				return err
+			}
+			v, err := m.value(t.values)
+			vals[string(k)] = v
+			return err
+		})
+		return vals, err
+	}
+	return nil, fmt.Errorf("unsupported avro type %q", t.typ)
+}
+
+// blocks read the blocks of items of an array or map, each a count (negative
+// if followed by the size in bytes of the block) then the items, until an
+// empty block
+func (m *decoder) blocks(item func() error) error {
+	for {
+		n, err := m.long()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		if n < 0 {
+			n = -n
+			if _, err := m.long(); err != nil {
+				return err
+			}
+		}
+		for i := int64(0); i < n; i++ {
+			if err := item(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// logicalLong the value of an int or long of a logical type
+func logicalLong(t *avroType, v int64) interface{} {
+	switch t.logical {
+	case "date":
+		return time.Unix(v*86400, 0).UTC()
+	case "timestamp-millis", "local-timestamp-millis":
+		return time.Unix(v/1e3, (v%1e3)*1e6).UTC()
+	case "timestamp-micros", "local-timestamp-micros":
+		return time.Unix(v/1e6, (v%1e6)*1e3).UTC()
+	}
+	return v
+}
+
+// decimal the value of the big-endian two's complement unscaled bytes
+func decimal(b []byte, scale int) float64 {
+	unscaled := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	f, _ := new(big.Rat).SetFrac(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)).Float64()
+	return f
+}
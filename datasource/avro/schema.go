@@ -0,0 +1,218 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/araddon/qlbridge/value"
+)
+
+// avroType a parsed avro schema (or part of one)
+type avroType struct {
+	typ       string // null, boolean, int, long, float, double, bytes, string, record, enum, array, map, union, fixed
+	name      string
+	logical   string // decimal, date, timestamp-millis ...
+	precision int
+	scale     int
+	size      int // of fixed
+	fields    []*avroField
+	symbols   []string
+	items     *avroType // of arrays
+	values    *avroType // of maps
+	union     []*avroType
+}
+
+type avroField struct {
+	name string
+	typ  *avroType
+}
+
+// parseSchema parse the json avro schema
+func parseSchema(schema string) (*avroType, error) {
+	var raw interface{}
+	if err := json.Unmarshal([]byte(schema), &raw); err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %v", err)
+	}
+	return parseType(raw, make(map[string]*avroType), "")
+}
+
+var primitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true, "float": true,
+	"double": true, "bytes": true, "string": true,
+}
+
+// parseType of a schema, named the types defined so far by their full
+// names, of the namespace ns
+func parseType(raw interface{}, named map[string]*avroType, ns string) (*avroType, error) {
+	switch t := raw.(type) {
+	case string:
+		if primitives[t] {
+			return &avroType{typ: t}, nil
+		}
+		if nt, ok := named[t]; ok {
+			return nt, nil
+		}
+		if nt, ok := named[fullName(t, ns)]; ok {
+			return nt, nil
+		}
+		return nil, fmt.Errorf("unknown avro type %q", t)
+	case []interface{}:
+		at := &avroType{typ: "union"}
+		for _, branch := range t {
+			bt, err := parseType(branch, named, ns)
+			if err != nil {
+				return nil, err
+			}
+			at.union = append(at.union, bt)
+		}
+		return at, nil
+	case map[string]interface{}:
+		typ, _ := t["type"].(string)
+		at := &avroType{typ: typ}
+		at.logical, _ = t["logicalType"].(string)
+		if p, ok := t["precision"].(float64); ok {
+			at.precision = int(p)
+		}
+		if s, ok := t["scale"].(float64); ok {
+			at.scale = int(s)
+		}
+		if name, ok := t["name"].(string); ok {
+			if space, ok := t["namespace"].(string); ok && !strings.Contains(name, ".") {
+				ns = space
+			}
+			at.name = fullName(name, ns)
+			named[at.name] = at
+			named[name] = at
+		}
+		switch typ {
+		case "record", "error":
+			at.typ = "record"
+			fields, _ := t["fields"].([]interface{})
+			for _, f := range fields {
+				fm, ok := f.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("invalid field of record %s", at.name)
+				}
+				ft, err := parseType(fm["type"], named, ns)
+				if err != nil {
+					return nil, err
+				}
+				name, _ := fm["name"].(string)
+				at.fields = append(at.fields, &avroField{name: name, typ: ft})
+			}
+		case "enum":
+			symbols, _ := t["symbols"].([]interface{})
+			for _, s := range symbols {
+				sym, _ := s.(string)
+				at.symbols = append(at.symbols, sym)
+			}
+		case "array":
+			items, err := parseType(t["items"], named, ns)
+			if err != nil {
+				return nil, err
+			}
+			at.items = items
+		case "map":
+			values, err := parseType(t["values"], named, ns)
+			if err != nil {
+				return nil, err
+			}
+			at.values = values
+		case "fixed":
+			size, _ := t["size"].(float64)
+			at.size = int(size)
+		default:
+			if !primitives[typ] {
+				// {"type": "com.example.Named"}
+				return parseType(t["type"], named, ns)
+			}
+		}
+		return at, nil
+	}
+	return nil, fmt.Errorf("invalid avro schema type %v", raw)
+}
+
+func fullName(name, ns string) string {
+	if ns == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return ns + "." + name
+}
+
+// nullable the type of a union of null and one other type, else t
+func (t *avroType) nullable() *avroType {
+	if t.typ != "union" {
+		return t
+	}
+	var other *avroType
+	for _, branch := range t.union {
+		if branch.typ == "null" {
+			continue
+		}
+		if other != nil {
+			return t
+		}
+		other = branch
+	}
+	if other == nil {
+		return t
+	}
+	return other
+}
+
+// valueType the value type of a column of type t
+func (t *avroType) valueType() value.ValueType {
+	t = t.nullable()
+	switch t.logical {
+	case "decimal":
+		return value.NumberType
+	case "date", "timestamp-millis", "timestamp-micros", "local-timestamp-millis", "local-timestamp-micros":
+		return value.TimeType
+	}
+	switch t.typ {
+	case "boolean":
+		return value.BoolType
+	case "int", "long":
+		return value.IntType
+	case "float", "double":
+		return value.NumberType
+	case "string", "enum":
+		return value.StringType
+	case "bytes", "fixed":
+		return value.ByteSliceType
+	case "array":
+		if it := t.items.nullable(); it.typ == "string" || it.typ == "enum" {
+			return value.StringsType
+		}
+	}
+	return value.JsonType
+}
+
+// column a column of a record, a field of it or of its nested records
+type column struct {
+	name string
+	path []string
+	typ  value.ValueType
+}
+
+// columns of record t, nested records flattened into dotted columns, ie
+// user.name, parents the records t is nested in.  A recursive record (ie a
+// tree node of its child nodes) is a json column
+func columns(t *avroType, prefix []string, parents map[*avroType]bool) []*column {
+	if parents == nil {
+		parents = make(map[*avroType]bool)
+	}
+	parents[t] = true
+	defer delete(parents, t)
+	cols := make([]*column, 0, len(t.fields))
+	for _, f := range t.fields {
+		path := append(append([]string{}, prefix...), f.name)
+		if ft := f.typ.nullable(); ft.typ == "record" && !parents[ft] {
+			cols = append(cols, columns(ft, path, parents)...)
+			continue
+		}
+		cols = append(cols, &column{name: strings.Join(path, "."), path: path, typ: f.typ.valueType()})
+	}
+	return cols
+}
@@ -0,0 +1,335 @@
+// Cache package implements a Datasource caching the scans and key lookups
+// of another (slow, ie REST api) source, for a ttl per table.
+package cache
+
+import (
+	"container/list"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/schema"
+)
+
+const (
+	// SourceType the source type of cache sources in the config
+	SourceType = "cache"
+)
+
+var (
+	_ = u.EMPTY
+
+	// DefaultTTL of cached rows of tables of no ttl setting
+	DefaultTTL = time.Minute
+	// DefaultMaxRows cached of sources of no max_rows setting
+	DefaultMaxRows = 100000
+
+	// Different Features of this Cache Data Source
+	_ schema.Source            = (*CacheSource)(nil)
+	_ datasource.MeteredSource = (*CacheSource)(nil)
+	_ schema.SourceSetup       = (*CacheSource)(nil)
+	_ schema.SourceTableSchema = (*CacheSource)(nil)
+)
+
+// CacheStats the stats of the cache of a CacheSource
+type CacheStats struct {
+	Hits          int64 `json:"hits"`          // scans and lookups served from the cache
+	Misses        int64 `json:"misses"`        // scans and lookups of the source
+	Evictions     int64 `json:"evictions"`     // entries evicted for more than max rows
+	Invalidations int64 `json:"invalidations"` // of tables (and keys) by changes or Invalidate
+	Entries       int   `json:"entries"`       // scans and keys cached
+	Rows          int   `json:"rows"`          // cached
+}
+
+// CacheSource a DataSource caching the rows of the scans (of each where
+// pushed down) and key lookups of another source, of the settings of the
+// source config
+//
+//   "settings" : {
+//       "source"   : "github_api",
+//       "ttl"      : "5m",
+//       "max_rows" : 100000,
+//       "tables"   : {"issues": {"ttl": "30s"}, "repos": {"ttl": "1h"}}
+//   }
+//
+// Rows are cached for the ttl of their table, a ttl of 0 caches none.  The
+// least recently used scans and keys are evicted for more than max_rows
+// rows.  The cached rows of a table are invalidated by the changes of it if
+// the source is a schema.SourceChanges, else see Invalidate.  Only scans
+// read to the end are cached, and the capabilities of the source the cache
+// keeps (wheres, keys, order) are those of the cache, so to the planner it
+// is the source.
+type CacheSource struct {
+	ds      schema.Source
+	ttl     time.Duration
+	ttls    map[string]time.Duration
+	maxRows int
+	sub     schema.Subscription
+
+	mu      sync.Mutex
+	lru     *list.List // of *entry, most recently used first
+	entries map[string]*list.Element
+	rows    int
+	gens    map[string]uint64 // of tables, bumped by invalidations
+	gen     uint64            // bumped by invalidations of all tables
+	stats   CacheStats
+	metrics datasource.Metrics
+}
+
+// entry the rows of a scan (or key) of a table
+type entry struct {
+	key     string
+	table   string
+	msgs    []schema.Message
+	expires time.Time
+}
+
+// NewCacheSource a CacheSource of the rows of ds, of the settings of a
+// source config, of the registered source of the "source" setting if ds
+// is nil
+func NewCacheSource(ds schema.Source, settings u.JsonHelper) (*CacheSource, error) {
+	m := &CacheSource{ds: ds}
+	if err := m.load(settings); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Setup find the "source" to cache of the source config, and its "ttl",
+// "max_rows" and per table "tables" ttls, of a CacheSource{} registered
+// without settings
+func (m *CacheSource) Setup(ss *schema.SchemaSource) error {
+	return datasource.SetupSettings(ss, m.lru != nil, m.load)
+}
+
+func (m *CacheSource) load(settings u.JsonHelper) error {
+	if m.ds == nil {
+		name := settings.String("source")
+		if name == "" {
+			return fmt.Errorf("cache source requires a source in settings")
+		}
+		if m.ds = datasource.DataSourcesRegistry().Get(name); m.ds == nil {
+			return fmt.Errorf("could not find source %q to cache", name)
+		}
+	}
+	var err error
+	if m.ttl, err = parseTTL(settings, DefaultTTL); err != nil {
+		return err
+	}
+	m.ttls = make(map[string]time.Duration)
+	tables := settings.Helper("tables")
+	for table := range tables {
+		if m.ttls[strings.ToLower(table)], err = parseTTL(tables.Helper(table), m.ttl); err != nil {
+			return fmt.Errorf("table %q: %v", table, err)
+		}
+	}
+	m.maxRows = DefaultMaxRows
+	if max, ok := settings.IntSafe("max_rows"); ok && max > 0 {
+		m.maxRows = max
+	}
+	m.lru = list.New()
+	m.entries = make(map[string]*list.Element)
+	m.gens = make(map[string]uint64)
+
+	if sc, ok := m.ds.(schema.SourceChanges); ok {
+		sub, err := sc.Subscribe("")
+		if err != nil {
+			return fmt.Errorf("could not subscribe to the changes of the cached source: %v", err)
+		}
+		m.sub = sub
+		go m.watch(sub)
+	}
+	return nil
+}
+
+// parseTTL the "ttl" duration of settings, def if not set
+func parseTTL(settings u.JsonHelper, def time.Duration) (time.Duration, error) {
+	s := settings.String("ttl")
+	if s == "" {
+		return def, nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("invalid cache ttl %q", s)
+	}
+	return ttl, nil
+}
+
+// watch invalidate the rows of the tables (and keys) of the changes of sub,
+// until it is closed
+func (m *CacheSource) watch(sub schema.Subscription) {
+	for change := range sub.Changes() {
+		if change.Key != nil {
+			m.InvalidateKey(change.Table, change.Key)
+		} else {
+			m.Invalidate(change.Table)
+		}
+	}
+	if err := sub.Err(); err != nil {
+		// changes were missed
+		u.Warnf("changes of cached source stopped, invalidating all: %v", err)
+		m.Invalidate("")
+	}
+}
+
+func (m *CacheSource) Tables() []string { return m.ds.Tables() }
+
+func (m *CacheSource) Table(table string) (*schema.Table, error) {
+	if sts, ok := m.ds.(schema.SourceTableSchema); ok {
+		return sts.Table(table)
+	}
+	return nil, schema.ErrNotFound
+}
+
+func (m *CacheSource) Open(table string) (schema.Conn, error) {
+	conn, err := m.ds.Open(table)
+	if err != nil {
+		return nil, err
+	}
+	return &cacheConn{src: m, table: strings.ToLower(table), conn: conn}, nil
+}
+
+// Close stop watching the changes of the cached source, dropping the cache.
+// The cached source is not closed.
+func (m *CacheSource) Close() error {
+	if m.sub != nil {
+		m.sub.Close()
+	}
+	m.Invalidate("")
+	return nil
+}
+
+func (m *CacheSource) Metrics() *datasource.Metrics { return &m.metrics }
+
+// Stats of the cache
+func (m *CacheSource) Stats() CacheStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.stats
+	stats.Entries, stats.Rows = len(m.entries), m.rows
+	return stats
+}
+
+// Invalidate the cached rows of table, "" for all tables
+func (m *CacheSource) Invalidate(table string) {
+	table = strings.ToLower(table)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.Invalidations++
+	if table == "" {
+		m.gen++
+	} else {
+		m.gens[table]++
+	}
+	m.removeWhere(func(e *entry) bool { return table == "" || e.table == table })
+}
+
+// InvalidateKey the cached row of key of table, and the scans of table
+func (m *CacheSource) InvalidateKey(table string, key driver.Value) {
+	table = strings.ToLower(table)
+	k := keyOf(table, key)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.Invalidations++
+	m.gens[table]++
+	m.removeWhere(func(e *entry) bool {
+		return e.table == table && (e.key == k || strings.HasPrefix(e.key, scanPrefix(table)))
+	})
+}
+
+func (m *CacheSource) removeWhere(matches func(e *entry) bool) {
+	for el := m.lru.Front(); el != nil; {
+		next := el.Next()
+		if e := el.Value.(*entry); matches(e) {
+			m.remove(el)
+		}
+		el = next
+	}
+}
+
+func (m *CacheSource) remove(el *list.Element) {
+	e := m.lru.Remove(el).(*entry)
+	delete(m.entries, e.key)
+	m.rows -= size(e)
+}
+
+// generation of table, entries read at one are not cached once it changes
+func (m *CacheSource) generation(table string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.gen + m.gens[table]
+}
+
+// ttlOf the ttl of the rows of table
+func (m *CacheSource) ttlOf(table string) time.Duration {
+	if ttl, ok := m.ttls[table]; ok {
+		return ttl
+	}
+	return m.ttl
+}
+
+// get the cached rows of key, counting a hit or miss
+func (m *CacheSource) get(key string) ([]schema.Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if ok && time.Now().After(el.Value.(*entry).expires) {
+		m.remove(el)
+		ok = false
+	}
+	if !ok {
+		m.stats.Misses++
+		return nil, false
+	}
+	m.stats.Hits++
+	m.lru.MoveToFront(el)
+	return el.Value.(*entry).msgs, true
+}
+
+// put the rows of key of table read at generation gen, unless the table
+// was invalidated since
+func (m *CacheSource) put(table, key string, msgs []schema.Message, gen uint64) {
+	ttl := m.ttlOf(table)
+	if ttl <= 0 {
+		return
+	}
+	e := &entry{key: key, table: table, msgs: msgs, expires: time.Now().Add(ttl)}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.gen+m.gens[table] != gen || size(e) > m.maxRows {
+		return
+	}
+	if el, ok := m.entries[key]; ok {
+		m.remove(el)
+	}
+	m.entries[key] = m.lru.PushFront(e)
+	m.rows += size(e)
+	for m.rows > m.maxRows {
+		m.remove(m.lru.Back())
+		m.stats.Evictions++
+	}
+}
+
+// size the rows of an entry, a key not found is one
+func size(e *entry) int {
+	if len(e.msgs) == 0 {
+		return 1
+	}
+	return len(e.msgs)
+}
+
+// scanPrefix of the cache keys of the scans of table
+func scanPrefix(table string) string { return table + "\x00scan\x00" }
+
+// scanKey the cache key of the scan of table of a where, "" for none
+func scanKey(table, where string) string { return scanPrefix(table) + where }
+
+// keyOf the cache key of the row of key of table
+func keyOf(table string, key driver.Value) string {
+	return fmt.Sprintf("%s\x00key\x00%T:%v", table, key, key)
+}
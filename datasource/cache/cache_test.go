@@ -0,0 +1,187 @@
+package cache_test
+
+import (
+	"database/sql/driver"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	u "github.com/araddon/gou"
+	"github.com/bmizerany/assert"
+	"golang.org/x/net/context"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/cache"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// slowSource a membtree source counting the scans and lookups of it
+type slowSource struct {
+	*membtree.StaticDataSource
+	scans, gets int32
+}
+
+func (m *slowSource) Open(table string) (schema.Conn, error) {
+	return &slowConn{StaticDataSource: m.StaticDataSource, src: m}, nil
+}
+
+type slowConn struct {
+	*membtree.StaticDataSource
+	src     *slowSource
+	started bool
+}
+
+func (m *slowConn) Next() schema.Message {
+	if !m.started {
+		m.started = true
+		atomic.AddInt32(&m.src.scans, 1)
+	}
+	return m.StaticDataSource.Next()
+}
+
+func (m *slowConn) Get(key driver.Value) (schema.Message, error) {
+	atomic.AddInt32(&m.src.gets, 1)
+	return m.StaticDataSource.Get(key)
+}
+
+func (m *slowConn) MultiGet(keys []driver.Value) ([]schema.Message, error) {
+	atomic.AddInt32(&m.src.gets, 1)
+	return m.StaticDataSource.MultiGet(keys)
+}
+
+func newSlowSource() *slowSource {
+	return &slowSource{StaticDataSource: membtree.NewStaticDataSource("users", 0, [][]driver.Value{
+		{int64(1), "ann"},
+		{int64(2), "bob"},
+		{int64(3), "cat"},
+	}, []string{"id", "name"})}
+}
+
+func scan(t *testing.T, src schema.Source) []string {
+	conn, err := src.Open("users")
+	assert.Tf(t, err == nil, "no error %v", err)
+	defer conn.Close()
+	names := make([]string, 0)
+	for msg := conn.(schema.ConnScanner).Next(); msg != nil; msg = conn.(schema.ConnScanner).Next() {
+		name, _ := msg.Body().(*datasource.SqlDriverMessageMap).Get("name")
+		names = append(names, name.ToString())
+	}
+	return names
+}
+
+func query(t *testing.T, sch *schema.Schema, sql string) [][]driver.Value {
+	ctx := plan.NewContext(sql)
+	ctx.Schema = sch
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	cols := []string{"id", "name"}
+	out := exec.NewResultRows(ctx, cols)
+	job.RootTask.Add(out)
+	assert.T(t, job.Setup() == nil)
+	go job.Run()
+	rows := make([][]driver.Value, 0)
+	for {
+		dest := make([]driver.Value, len(cols))
+		if out.Next(dest) != nil {
+			break
+		}
+		rows = append(rows, dest)
+	}
+	job.Close()
+	return rows
+}
+
+func TestCacheSource(t *testing.T) {
+	slow := newSlowSource()
+	datasource.Register("cache_slow", slow)
+	src, err := cache.NewCacheSource(nil, u.JsonHelper{"source": "cache_slow", "ttl": "1h"})
+	assert.Tf(t, err == nil, "no error %v", err)
+	sch := datasource.RegisterSchemaSource("cache_users", "cache_users", src)
+
+	rows := query(t, sch, `SELECT id, name FROM users WHERE id > 1`)
+	assert.Equal(t, [][]driver.Value{{int64(2), "bob"}, {int64(3), "cat"}}, rows)
+	rows = query(t, sch, `SELECT id, name FROM users WHERE id > 1`)
+	assert.Equal(t, [][]driver.Value{{int64(2), "bob"}, {int64(3), "cat"}}, rows)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&slow.scans))
+	assert.Equal(t, int64(1), src.Stats().Hits)
+
+	// lookups, of those not found too
+	conn, _ := src.Open("users")
+	seeker := conn.(schema.ConnSeeker)
+	for i := 0; i < 2; i++ {
+		msg, err := seeker.Get(int64(2))
+		assert.Tf(t, err == nil, "no error %v", err)
+		assert.Equal(t, []driver.Value{int64(2), "bob"}, msg.Body().(*datasource.SqlDriverMessageMap).Values())
+		_, err = seeker.Get(int64(9))
+		assert.Equal(t, schema.ErrNotFound, err)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&slow.gets))
+	// of the keys not cached only
+	msgs, err := seeker.MultiGet([]driver.Value{int64(2), int64(3)})
+	assert.Tf(t, err == nil && len(msgs) == 2, "no error %v", err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&slow.gets))
+	_, err = seeker.MultiGet([]driver.Value{int64(1), int64(9)})
+	assert.Equal(t, schema.ErrNotFound, err)
+	conn.Close()
+
+	// a change of a row invalidates it, and the scans of its table
+	_, err = slow.Put(context.Background(), nil, []driver.Value{int64(2), "bea"})
+	assert.Tf(t, err == nil, "no error %v", err)
+	for i := 0; i < 100 && src.Stats().Invalidations == 0; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	rows = query(t, sch, `SELECT id, name FROM users WHERE id > 1`)
+	assert.Equal(t, [][]driver.Value{{int64(2), "bea"}, {int64(3), "cat"}}, rows)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&slow.scans))
+	conn, _ = src.Open("users")
+	msg, _ := conn.(schema.ConnSeeker).Get(int64(2))
+	assert.Equal(t, []driver.Value{int64(2), "bea"}, msg.Body().(*datasource.SqlDriverMessageMap).Values())
+	conn.Close()
+
+	src.Invalidate("users")
+	assert.Equal(t, 0, src.Stats().Entries)
+	src.Close()
+}
+
+func TestCacheLimits(t *testing.T) {
+	tests := []struct {
+		settings u.JsonHelper
+		wait     time.Duration
+		scans    int32
+	}{
+		{u.JsonHelper{"ttl": "1h"}, 0, 1},
+		{u.JsonHelper{"ttl": "10ms"}, 30 * time.Millisecond, 2},
+		// of the ttl of the table
+		{u.JsonHelper{"ttl": "1h", "tables": map[string]interface{}{"users": map[string]interface{}{"ttl": "0s"}}}, 0, 2},
+		// more rows than cached
+		{u.JsonHelper{"ttl": "1h", "max_rows": 2}, 0, 2},
+	}
+	for _, tt := range tests {
+		slow := newSlowSource()
+		src, err := cache.NewCacheSource(slow, tt.settings)
+		assert.Tf(t, err == nil, "no error %v", err)
+		assert.Equal(t, []string{"ann", "bob", "cat"}, scan(t, src))
+		time.Sleep(tt.wait)
+		assert.Equal(t, []string{"ann", "bob", "cat"}, scan(t, src))
+		assert.Equalf(t, tt.scans, atomic.LoadInt32(&slow.scans), "scans of %v", tt.settings)
+		src.Close()
+	}
+
+	// least recently used keys are evicted
+	slow := newSlowSource()
+	src, _ := cache.NewCacheSource(slow, u.JsonHelper{"max_rows": 2})
+	conn, _ := src.Open("users")
+	for _, key := range []int64{1, 2, 1, 3, 1, 2} {
+		conn.(schema.ConnSeeker).Get(key)
+	}
+	assert.Equal(t, int32(4), atomic.LoadInt32(&slow.gets))
+	assert.Equal(t, int64(2), src.Stats().Evictions)
+
+	_, err := cache.NewCacheSource(nil, u.JsonHelper{"source": "cache_missing"})
+	assert.T(t, err != nil)
+	_, err = cache.NewCacheSource(slow, u.JsonHelper{"ttl": "soon"})
+	assert.T(t, err != nil)
+}
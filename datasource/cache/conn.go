@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/expr/translate"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	// Different Features of this Cache Data Source Conn
+	_ schema.ConnScanner        = (*cacheConn)(nil)
+	_ schema.ConnColumns        = (*cacheConn)(nil)
+	_ schema.ConnSeeker         = (*cacheConn)(nil)
+	_ schema.IteratorErr        = (*cacheConn)(nil)
+	_ schema.SourceCapabilities = (*cacheConn)(nil)
+	_ translate.Translator      = (*cacheConn)(nil)
+)
+
+// cacheConn a conn of the cached source, whose scan (and lookups) are
+// served from the cache if cached, else recorded into it
+type cacheConn struct {
+	src    *CacheSource
+	table  string
+	conn   schema.Conn // of the cached source
+	where  string      // of the scan, the cache key of its rows
+	native bool
+
+	started bool
+	cached  []schema.Message // rows of the scan served from the cache
+	hit     bool
+	pos     int
+	scanner schema.ConnScanner
+	rec     []schema.Message // rows of the scan read, to cache at its end
+	gen     uint64
+	err     error
+}
+
+// Capabilities those of the conn of the cached source the cache keeps, the
+// rows of its scans and keys are cached as is
+func (m *cacheConn) Capabilities() *schema.Capabilities {
+	caps := plan.CapabilitiesOf(m.conn)
+	return &schema.Capabilities{
+		Scan:      caps.Scan,
+		Filter:    caps.Filter,
+		FilterOps: caps.FilterOps,
+		SortedBy:  caps.SortedBy,
+		Seek:      caps.Seek,
+		KeyColumn: caps.KeyColumn,
+	}
+}
+
+func (m *cacheConn) Columns() []string {
+	if cc, ok := m.conn.(schema.ConnColumns); ok {
+		return cc.Columns()
+	}
+	if tbl, err := m.src.Table(m.table); err == nil {
+		return tbl.Columns()
+	}
+	return nil
+}
+
+// Translate the where by the conn of the cached source, scans of different
+// wheres are cached apart
+func (m *cacheConn) Translate(node expr.Node) (interface{}, error) {
+	t, ok := m.conn.(translate.Translator)
+	if !ok {
+		return nil, fmt.Errorf("cached source of %q does not translate wheres", m.table)
+	}
+	native, err := t.Translate(node)
+	if err != nil {
+		return nil, err
+	}
+	m.where, m.native = node.String(), true
+	return native, nil
+}
+
+// SetNative the native where translated by the planner
+func (m *cacheConn) SetNative(native interface{}) {
+	if sn, ok := m.conn.(interface {
+		SetNative(native interface{})
+	}); ok {
+		sn.SetNative(native)
+	}
+	if !m.native {
+		// not translated by this conn
+		m.where, m.native = fmt.Sprintf("%#v", native), true
+	}
+}
+
+// Next the next row of the cached scan, else of the scan of the source
+func (m *cacheConn) Next() schema.Message {
+	if !m.started {
+		m.started = true
+		key := scanKey(m.table, m.where)
+		if m.cached, m.hit = m.src.get(key); !m.hit {
+			scanner, ok := m.conn.(schema.ConnScanner)
+			if !ok {
+				m.err = fmt.Errorf("cached source of %q can not be scanned", m.table)
+				return nil
+			}
+			m.scanner = scanner
+			m.gen = m.src.generation(m.table)
+			m.rec = make([]schema.Message, 0)
+		}
+	}
+	if m.hit {
+		if m.pos >= len(m.cached) {
+			return nil
+		}
+		m.pos++
+		return m.cached[m.pos-1]
+	}
+	if m.scanner == nil {
+		return nil
+	}
+	msg := m.scanner.Next()
+	if msg == nil {
+		if ie, ok := m.scanner.(schema.IteratorErr); ok && ie.Err() != nil {
+			m.err = ie.Err()
+		} else if m.rec != nil {
+			m.src.put(m.table, scanKey(m.table, m.where), m.rec, m.gen)
+		}
+		m.scanner, m.rec = nil, nil
+		return nil
+	}
+	if m.rec != nil {
+		if m.rec = append(m.rec, msg); len(m.rec) > m.src.maxRows {
+			// too many rows to cache
+			m.rec = nil
+		}
+	}
+	return msg
+}
+
+func (m *cacheConn) Err() error { return m.err }
+
+func (m *cacheConn) seeker() (schema.ConnSeeker, error) {
+	seeker, ok := m.conn.(schema.ConnSeeker)
+	if !ok {
+		return nil, schema.ErrNotImplemented
+	}
+	return seeker, nil
+}
+
+func (m *cacheConn) CanSeek(stmt *rel.SqlSelect) bool {
+	seeker, err := m.seeker()
+	return err == nil && seeker.CanSeek(stmt)
+}
+
+// Get the row of key, of the cache if cached (or known not found)
+func (m *cacheConn) Get(key driver.Value) (schema.Message, error) {
+	k := keyOf(m.table, key)
+	if msgs, ok := m.src.get(k); ok {
+		if len(msgs) == 0 {
+			return nil, schema.ErrNotFound
+		}
+		return msgs[0], nil
+	}
+	seeker, err := m.seeker()
+	if err != nil {
+		return nil, err
+	}
+	gen := m.src.generation(m.table)
+	msg, err := seeker.Get(key)
+	switch {
+	case err == schema.ErrNotFound:
+		m.src.put(m.table, k, nil, gen)
+	case err == nil:
+		m.src.put(m.table, k, []schema.Message{msg}, gen)
+	}
+	return msg, err
+}
+
+// MultiGet the rows of keys, those not cached of one MultiGet of the source,
+// schema.ErrNotFound if any is not found
+func (m *cacheConn) MultiGet(keys []driver.Value) ([]schema.Message, error) {
+	msgs := make([]schema.Message, len(keys))
+	missed := make([]int, 0)
+	for i, key := range keys {
+		if cached, ok := m.src.get(keyOf(m.table, key)); !ok {
+			missed = append(missed, i)
+		} else if len(cached) > 0 {
+			msgs[i] = cached[0]
+		}
+	}
+	if len(missed) > 0 {
+		seeker, err := m.seeker()
+		if err != nil {
+			return nil, err
+		}
+		gen := m.src.generation(m.table)
+		missedKeys := make([]driver.Value, len(missed))
+		for j, i := range missed {
+			missedKeys[j] = keys[i]
+		}
+		found, err := seeker.MultiGet(missedKeys)
+		switch {
+		case err == nil && len(found) == len(missed):
+			for j, i := range missed {
+				msgs[i] = found[j]
+				m.src.put(m.table, keyOf(m.table, keys[i]), []schema.Message{found[j]}, gen)
+			}
+		case err == nil || err == schema.ErrNotFound:
+			// some are not found, which ones of a key at a time
+			for _, i := range missed {
+				if msgs[i], err = m.Get(keys[i]); err != nil && err != schema.ErrNotFound {
+					return nil, err
+				}
+			}
+		default:
+			return nil, err
+		}
+	}
+	for _, msg := range msgs {
+		if msg == nil {
+			return nil, schema.ErrNotFound
+		}
+	}
+	return msgs, nil
+}
+
+func (m *cacheConn) Close() error {
+	return m.conn.Close()
+}
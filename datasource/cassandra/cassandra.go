@@ -0,0 +1,505 @@
+// Cassandra package implements a Datasource of the tables of a Cassandra
+// (CQL) keyspace.
+package cassandra
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/expr/translate"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Different Features of this Cassandra Data Source
+	_ schema.Source            = (*CassandraSource)(nil)
+	_ datasource.MeteredSource = (*CassandraSource)(nil)
+	_ schema.SourceSetup       = (*CassandraSource)(nil)
+	_ schema.SourceTableSchema = (*CassandraSource)(nil)
+	_ schema.ConnScanner       = (*cqlConn)(nil)
+	_ schema.ConnColumns       = (*cqlConn)(nil)
+	_ schema.ConnSorted        = (*cqlConn)(nil)
+	_ schema.IteratorErr       = (*cqlConn)(nil)
+	_ translate.Translator     = (*cqlConn)(nil)
+	_ plan.SourceLimiter       = (*cqlConn)(nil)
+
+	sessionMu   sync.Mutex
+	openSession SessionOpener
+)
+
+// Session a CQL session (ie of gocql) running queries of ? placeholder args
+type Session interface {
+	Query(cql string, args ...interface{}) (driver.Rows, error)
+	Close() error
+}
+
+// SessionOpener open the Session of the settings of a source config (hosts,
+// keyspace, credentials ..)
+type SessionOpener func(settings u.JsonHelper) (Session, error)
+
+// RegisterSession register the SessionOpener of sources registered by type
+// (see Setup).  The cassandra driver is not a dependency of qlbridge, so a
+// program using this source wraps the session of its driver.
+func RegisterSession(open SessionOpener) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	openSession = open
+}
+
+// CassandraSource a DataSource of the tables of a keyspace, their schema
+// read from the system_schema tables.  Created with NewCassandraSource, or
+// registered as a type whose source config settings are read on Setup
+// (with a registered SessionOpener):
+//
+//   "settings" : {
+//       "keyspace" : "metrics",
+//       "hosts"    : ["10.0.0.1", "10.0.0.2"]
+//   }
+//
+// A where restricting all partition key columns to values (= or IN) is sent
+// to cassandra, rows of a single partition are in clustering order, so an
+// ORDER BY of the clustering columns is not sorted again.  The LIMIT is sent
+// too if the where was sent whole.
+type CassandraSource struct {
+	mu       sync.Mutex
+	session  Session
+	keyspace string
+	names    []string
+	tables   map[string]*cqlTable
+	metrics  datasource.Metrics
+}
+
+// cqlTable the columns and keys of a table
+type cqlTable struct {
+	name       string
+	tbl        *schema.Table
+	cols       []string
+	partition  []string
+	clustering []string
+	desc       []bool // clustering columns in descending order
+}
+
+// column a row of system_schema.columns
+type column struct {
+	name, kind, order, typ string
+	position               int
+}
+
+// columns in the order of a table, partition key then clustering columns
+// by position, then the rest by name
+type columns []column
+
+func (m columns) Len() int      { return len(m) }
+func (m columns) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m columns) Less(i, j int) bool {
+	ri, rj := m[i].rank(), m[j].rank()
+	if ri != rj {
+		return ri < rj
+	}
+	if ri < 2 {
+		return m[i].position < m[j].position
+	}
+	return m[i].name < m[j].name
+}
+
+func (m column) rank() int {
+	switch m.kind {
+	case "partition_key":
+		return 0
+	case "clustering":
+		return 1
+	}
+	return 2
+}
+
+// cqlQuery the where of a scan, of the partition key columns
+type cqlQuery struct {
+	where    string
+	args     []interface{}
+	complete bool // the where was translated whole
+	single   bool // a single partition
+}
+
+// cqlConn the scan of a table
+type cqlConn struct {
+	src      *CassandraSource
+	t        *cqlTable
+	query    *cqlQuery
+	limit    int
+	rows     driver.Rows
+	colindex map[string]int
+	rowct    uint64
+	err      error
+}
+
+// NewCassandraSource a CassandraSource of the tables of keyspace
+func NewCassandraSource(keyspace string, session Session) (*CassandraSource, error) {
+	m := &CassandraSource{}
+	if err := m.load(keyspace, session); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Setup open the session of the source config, for a CassandraSource{}
+// registered without one
+func (m *CassandraSource) Setup(ss *schema.SchemaSource) error {
+	m.mu.Lock()
+	loaded := m.session != nil
+	m.mu.Unlock()
+	if loaded || ss == nil || ss.Conf == nil {
+		return nil
+	}
+	sessionMu.Lock()
+	open := openSession
+	sessionMu.Unlock()
+	if open == nil {
+		return fmt.Errorf("no cassandra session registered, see cassandra.RegisterSession")
+	}
+	session, err := open(ss.Conf.Settings)
+	if err != nil {
+		return err
+	}
+	return m.load(ss.Conf.Settings.String("keyspace"), session)
+}
+
+// load the tables of keyspace from system_schema
+func (m *CassandraSource) load(keyspace string, session Session) error {
+	if keyspace == "" {
+		return fmt.Errorf("cassandra source requires a keyspace")
+	}
+	rows, err := session.Query(`SELECT table_name, column_name, kind, position, clustering_order, type `+
+		`FROM system_schema.columns WHERE keyspace_name = ?`, keyspace)
+	if err != nil {
+		return fmt.Errorf("could not read schema of %q: %v", keyspace, err)
+	}
+	defer rows.Close()
+
+	byTable := make(map[string]columns)
+	row := make([]driver.Value, 6)
+	for {
+		if err := rows.Next(row); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		col := column{name: str(row[1]), kind: str(row[2]), order: str(row[4]), typ: str(row[5])}
+		switch pos := row[3].(type) {
+		case int64:
+			col.position = int(pos)
+		case int:
+			col.position = pos
+		}
+		table := strings.ToLower(str(row[0]))
+		byTable[table] = append(byTable[table], col)
+	}
+
+	tables := make(map[string]*cqlTable, len(byTable))
+	names := make([]string, 0, len(byTable))
+	for name, cols := range byTable {
+		sort.Sort(cols)
+		t := &cqlTable{name: name, tbl: schema.NewTable(name)}
+		for _, col := range cols {
+			t.cols = append(t.cols, col.name)
+			t.tbl.AddField(schema.NewFieldBase(col.name, cqlType(col.typ), 64, col.typ))
+			switch col.kind {
+			case "partition_key":
+				t.partition = append(t.partition, col.name)
+			case "clustering":
+				t.clustering = append(t.clustering, col.name)
+				t.desc = append(t.desc, strings.EqualFold(col.order, "desc"))
+			}
+		}
+		t.tbl.SetColumns(t.cols)
+		tables[name] = t
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.session, m.keyspace = session, keyspace
+	m.names, m.tables = names, tables
+	return nil
+}
+
+func str(v driver.Value) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	}
+	return ""
+}
+
+// cqlType the value type of a cql type
+func cqlType(typ string) value.ValueType {
+	typ = strings.ToLower(typ)
+	switch typ {
+	case "int", "bigint", "smallint", "tinyint", "varint", "counter":
+		return value.IntType
+	case "float", "double", "decimal":
+		return value.NumberType
+	case "boolean":
+		return value.BoolType
+	case "timestamp", "date":
+		return value.TimeType
+	case "blob":
+		return value.ByteSliceType
+	}
+	if strings.HasPrefix(typ, "list<") || strings.HasPrefix(typ, "set<") {
+		return value.SliceValueType
+	}
+	if strings.HasPrefix(typ, "map<") {
+		return value.MapValueType
+	}
+	return value.StringType
+}
+
+func (m *CassandraSource) Tables() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.names
+}
+
+func (m *CassandraSource) Table(table string) (*schema.Table, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t, ok := m.tables[strings.ToLower(table)]; ok {
+		return t.tbl, nil
+	}
+	return nil, schema.ErrNotFound
+}
+
+// Open a scan of table
+func (m *CassandraSource) Open(table string) (schema.Conn, error) {
+	m.mu.Lock()
+	t, ok := m.tables[strings.ToLower(table)]
+	m.mu.Unlock()
+	if !ok {
+		return nil, schema.ErrNotFound
+	}
+	return &cqlConn{src: m, t: t}, nil
+}
+
+func (m *CassandraSource) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.session != nil {
+		return m.session.Close()
+	}
+	return nil
+}
+
+func (m *CassandraSource) Metrics() *datasource.Metrics { return &m.metrics }
+
+// Translate the restrictions of the partition key columns AND-ed in the
+// where (col = value, col IN (values)) into the where of a cql query, all
+// partition key columns must be restricted.  The rest of the where is not
+// sent.  The query is kept by the conn, to know if it reads a single
+// partition (see SortedBy).
+func (m *cqlConn) Translate(node expr.Node) (interface{}, error) {
+	conds := make(map[string]string)
+	args := make(map[string][]interface{})
+	q := &cqlQuery{complete: true, single: true}
+	for _, conj := range conjuncts(node, nil) {
+		col, cond, vals := m.partitionCond(conj)
+		if col == "" || conds[col] != "" {
+			q.complete = false
+			continue
+		}
+		conds[col], args[col] = cond, vals
+		if len(vals) != 1 || strings.Contains(cond, " IN ") {
+			q.single = false
+		}
+	}
+	parts := make([]string, 0, len(m.t.partition))
+	for _, col := range m.t.partition {
+		if conds[col] == "" {
+			return nil, fmt.Errorf("partition key %q of %q not restricted in %s", col, m.t.name, node)
+		}
+		parts = append(parts, conds[col])
+		q.args = append(q.args, args[col]...)
+	}
+	q.where = strings.Join(parts, " AND ")
+	m.query = q
+	return q, nil
+}
+
+// conjuncts the expressions AND-ed in node
+func conjuncts(node expr.Node, out []expr.Node) []expr.Node {
+	if bn, ok := node.(*expr.BinaryNode); ok && bn.Operator.T == lex.TokenLogicAnd && len(bn.Args) == 2 {
+		out = conjuncts(bn.Args[0], out)
+		return conjuncts(bn.Args[1], out)
+	}
+	return append(out, node)
+}
+
+// partitionCond the cql condition of node if it restricts a partition key
+// column to values, empty column if not
+func (m *cqlConn) partitionCond(node expr.Node) (string, string, []interface{}) {
+	bn, ok := node.(*expr.BinaryNode)
+	if !ok || len(bn.Args) != 2 {
+		return "", "", nil
+	}
+	in, ok := bn.Args[0].(*expr.IdentityNode)
+	lit := bn.Args[1]
+	if !ok && bn.Operator.T != lex.TokenIN {
+		in, ok = bn.Args[1].(*expr.IdentityNode)
+		lit = bn.Args[0]
+	}
+	if !ok {
+		return "", "", nil
+	}
+	_, name, _ := in.LeftRight()
+	col := ""
+	for _, pk := range m.t.partition {
+		if strings.EqualFold(pk, name) {
+			col = pk
+		}
+	}
+	if col == "" {
+		return "", "", nil
+	}
+	switch bn.Operator.T {
+	case lex.TokenEqual, lex.TokenEqualEqual:
+		if val, ok := literal(lit); ok {
+			return col, quote(col) + " = ?", []interface{}{val}
+		}
+	case lex.TokenIN:
+		arr, ok := lit.(*expr.ArrayNode)
+		if !ok || len(arr.Args) == 0 {
+			return "", "", nil
+		}
+		vals := make([]interface{}, 0, len(arr.Args))
+		for _, arg := range arr.Args {
+			val, ok := literal(arg)
+			if !ok {
+				return "", "", nil
+			}
+			vals = append(vals, val)
+		}
+		marks := strings.TrimSuffix(strings.Repeat("?, ", len(vals)), ", ")
+		return col, quote(col) + " IN (" + marks + ")", vals
+	}
+	return "", "", nil
+}
+
+func literal(node expr.Node) (interface{}, bool) {
+	switch n := node.(type) {
+	case *expr.StringNode:
+		return n.Text, true
+	case *expr.NumberNode:
+		if n.IsInt {
+			return n.Int64, true
+		}
+		return n.Float64, true
+	}
+	return nil, false
+}
+
+func quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// SetNative the query translated by the planner (see Translate)
+func (m *cqlConn) SetNative(native interface{}) {
+	if q, ok := native.(*cqlQuery); ok {
+		m.query = q
+	}
+}
+
+// SortedBy the clustering columns (up to the first descending one) when the
+// scan reads a single partition, rows across partitions are not sorted
+func (m *cqlConn) SortedBy() []string {
+	if m.query == nil || !m.query.single {
+		return nil
+	}
+	cols := make([]string, 0, len(m.t.clustering))
+	for i, col := range m.t.clustering {
+		if m.t.desc[i] {
+			break
+		}
+		cols = append(cols, col)
+	}
+	return cols
+}
+
+// PushLimit the limit is sent with the query if it has no where, or all of
+// it was translated
+func (m *cqlConn) PushLimit(s *plan.Source, limit int) bool {
+	hasWhere := s.Stmt.Source != nil && s.Stmt.Source.Where != nil
+	if hasWhere && (m.query == nil || !m.query.complete) {
+		return false
+	}
+	m.limit = limit
+	return true
+}
+
+// Columns of the table
+func (m *cqlConn) Columns() []string { return m.t.cols }
+
+// cql the query of the scan
+func (m *cqlConn) cql() (string, []interface{}) {
+	cols := make([]string, len(m.t.cols))
+	for i, col := range m.t.cols {
+		cols[i] = quote(col)
+	}
+	cql := fmt.Sprintf("SELECT %s FROM %s.%s", strings.Join(cols, ", "), quote(m.src.keyspace), quote(m.t.name))
+	var args []interface{}
+	if m.query != nil {
+		cql += " WHERE " + m.query.where
+		args = m.query.args
+	}
+	if m.limit > 0 {
+		cql += fmt.Sprintf(" LIMIT %d", m.limit)
+	}
+	return cql, args
+}
+
+func (m *cqlConn) Next() schema.Message {
+	if m.rows == nil && m.err == nil {
+		cql, args := m.cql()
+		m.rows, m.err = m.src.session.Query(cql, args...)
+		m.colindex = make(map[string]int, len(m.t.cols))
+		for i, col := range m.t.cols {
+			m.colindex[col] = i
+		}
+	}
+	if m.err != nil {
+		return nil
+	}
+	row := make([]driver.Value, len(m.t.cols))
+	if err := m.rows.Next(row); err != nil {
+		if err != io.EOF {
+			m.err = err
+		}
+		return nil
+	}
+	m.rowct++
+	return datasource.NewSqlDriverMessageMap(m.rowct, row, m.colindex)
+}
+
+// Err the error of the query, nil if all rows were read
+func (m *cqlConn) Err() error { return m.err }
+
+func (m *cqlConn) Close() error {
+	if m.rows != nil {
+		return m.rows.Close()
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package cassandra_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/cassandra"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+)
+
+// rows of a query
+type rows struct {
+	cols []string
+	vals [][]driver.Value
+}
+
+func (m *rows) Columns() []string { return m.cols }
+func (m *rows) Close() error      { return nil }
+func (m *rows) Next(dest []driver.Value) error {
+	if len(m.vals) == 0 {
+		return io.EOF
+	}
+	copy(dest, m.vals[0])
+	m.vals = m.vals[1:]
+	return nil
+}
+
+// session of a keyspace of an events table, partitioned by user and
+// clustered by ts, recording the queries it is sent.  Rows are only
+// filtered by the user_id args.
+type session struct {
+	queries []string
+}
+
+var events = [][]driver.Value{
+	{"a", int64(1), "open"},
+	{"a", int64(2), "click"},
+	{"a", int64(3), "close"},
+	{"b", int64(1), "open"},
+}
+
+func (m *session) Query(cql string, args ...interface{}) (driver.Rows, error) {
+	if strings.Contains(cql, "system_schema") {
+		return &rows{vals: [][]driver.Value{
+			{"events", "kind", "regular", int64(-1), "none", "text"},
+			{"events", "ts", "clustering", int64(0), "asc", "bigint"},
+			{"events", "user_id", "partition_key", int64(0), "none", "text"},
+		}}, nil
+	}
+	m.queries = append(m.queries, fmt.Sprintf("%s %v", cql, args))
+	out := &rows{}
+	for _, row := range events {
+		match := len(args) == 0
+		for _, arg := range args {
+			match = match || row[0] == arg
+		}
+		if match {
+			out.vals = append(out.vals, row)
+		}
+	}
+	return out, nil
+}
+func (m *session) Close() error { return nil }
+
+func TestCassandraSource(t *testing.T) {
+	sess := &session{}
+	src, err := cassandra.NewCassandraSource("ks", sess)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"events"}, src.Tables())
+	tbl, err := src.Table("events")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"user_id", "ts", "kind"}, tbl.Columns())
+	datasource.RegisterSchemaSource("cassandra", "cassandra", src)
+
+	tests := []struct {
+		sql    string
+		cql    string
+		rows   string
+		sorted bool // order by was not sorted in-process
+	}{
+		{`SELECT kind FROM events WHERE user_id = "a" ORDER BY ts LIMIT 2`,
+			`SELECT "user_id", "ts", "kind" FROM "ks"."events" WHERE "user_id" = ? LIMIT 2 [a]`,
+			"open,click", true},
+		// the limit is not sent with a where not sent whole
+		{`SELECT kind FROM events WHERE user_id = "a" AND kind != "open" LIMIT 1`,
+			`SELECT "user_id", "ts", "kind" FROM "ks"."events" WHERE "user_id" = ? [a]`,
+			"click", false},
+		// many partitions are not in clustering order
+		{`SELECT kind FROM events WHERE user_id IN ("b", "a") ORDER BY ts DESC`,
+			`SELECT "user_id", "ts", "kind" FROM "ks"."events" WHERE "user_id" IN (?, ?) [b a]`,
+			"close,click,open,open", false},
+		{`SELECT kind FROM events WHERE kind = "open"`,
+			`SELECT "user_id", "ts", "kind" FROM "ks"."events" []`,
+			"open,open", false},
+		{`SELECT kind FROM events LIMIT 1`,
+			`SELECT "user_id", "ts", "kind" FROM "ks"."events" LIMIT 1 []`,
+			"open", false},
+	}
+	for _, tt := range tests {
+		sess.queries = nil
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("cassandra")
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		out := exec.NewResultRows(ctx, []string{"kind"})
+		job.RootTask.Add(out)
+		assert.T(t, job.Setup() == nil)
+		go job.Run()
+		kinds := make([]string, 0)
+		dest := make([]driver.Value, 1)
+		for out.Next(dest) == nil {
+			kinds = append(kinds, dest[0].(string))
+		}
+		job.Close()
+		assert.Equalf(t, tt.rows, strings.Join(kinds, ","), "sql %s", tt.sql)
+		assert.Equalf(t, []string{tt.cql}, sess.queries, "sql %s", tt.sql)
+		assert.Equalf(t, tt.sorted, ctx.Metrics.Applied("sorted-source"), "sql %s", tt.sql)
+	}
+}
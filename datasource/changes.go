@@ -0,0 +1,222 @@
+package datasource
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	_ schema.Source            = (*ChangeStreamSource)(nil)
+	_ MeteredSource            = (*ChangeStreamSource)(nil)
+	_ schema.SourceTableSchema = (*ChangeStreamSource)(nil)
+	_ schema.ConnScanner       = (*changeConn)(nil)
+	_ schema.ConnColumns       = (*changeConn)(nil)
+	_ schema.IteratorErr       = (*changeConn)(nil)
+
+	// ChangeBufferSize changes buffered per subscriber, a subscriber more
+	// changes behind than this is closed with ErrSlowSubscriber
+	ChangeBufferSize = 1024
+
+	// ErrSlowSubscriber the error of a subscription closed for falling
+	// behind the changes
+	ErrSlowSubscriber = fmt.Errorf("subscriber could not keep up with changes")
+
+	// ChangeOpColumn, ChangeTimeColumn the columns of the operation and time
+	// of the changes of a ChangeStreamSource, after the columns of the table
+	ChangeOpColumn   = "change_op"
+	ChangeTimeColumn = "change_time"
+)
+
+// ChangeFeed fans out the changes of the tables of a source to their
+// subscribers, for sources implementing schema.SourceChanges.  Publishing
+// never blocks on a subscriber.  Thread-safe.
+//
+//   func (m *MySource) Subscribe(table string) (schema.Subscription, error) {
+//       return m.feed.Subscribe(table), nil
+//   }
+//   // on each write
+//   m.feed.Publish(&schema.Change{Op: schema.ChangeInsert, Table: "users", Row: row})
+type ChangeFeed struct {
+	mu   sync.Mutex
+	subs map[*changeSub]struct{}
+}
+
+// NewChangeFeed a feed of no subscribers
+func NewChangeFeed() *ChangeFeed {
+	return &ChangeFeed{subs: make(map[*changeSub]struct{})}
+}
+
+// Subscribe to the changes of table, "" for all tables
+func (m *ChangeFeed) Subscribe(table string) schema.Subscription {
+	sub := &changeSub{feed: m, table: strings.ToLower(table), ch: make(chan *schema.Change, ChangeBufferSize)}
+	m.mu.Lock()
+	m.subs[sub] = struct{}{}
+	m.mu.Unlock()
+	return sub
+}
+
+// Subscribers the number of open subscriptions
+func (m *ChangeFeed) Subscribers() int {
+	if m == nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.subs)
+}
+
+// Publish change to the subscribers of its table, a nil feed has none
+func (m *ChangeFeed) Publish(change *schema.Change) {
+	if m == nil {
+		return
+	}
+	table := strings.ToLower(change.Table)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for sub := range m.subs {
+		if sub.table != "" && sub.table != table {
+			continue
+		}
+		select {
+		case sub.ch <- change:
+		default:
+			u.Warnf("closing subscriber of %q, %d changes behind", table, len(sub.ch))
+			sub.closeUnlocked(ErrSlowSubscriber)
+		}
+	}
+}
+
+// changeSub a subscription of a ChangeFeed
+type changeSub struct {
+	feed   *ChangeFeed
+	table  string
+	ch     chan *schema.Change
+	err    error
+	closed bool
+}
+
+func (m *changeSub) Changes() <-chan *schema.Change { return m.ch }
+
+func (m *changeSub) Err() error {
+	m.feed.mu.Lock()
+	defer m.feed.mu.Unlock()
+	return m.err
+}
+
+func (m *changeSub) Close() error {
+	m.feed.mu.Lock()
+	defer m.feed.mu.Unlock()
+	m.closeUnlocked(nil)
+	return nil
+}
+
+func (m *changeSub) closeUnlocked(err error) {
+	if m.closed {
+		return
+	}
+	m.closed, m.err = true, err
+	delete(m.feed.subs, m)
+	close(m.ch)
+}
+
+// ChangeStreamSource the changes of the tables of a schema.SourceChanges
+// as streaming tables, for continuous queries (see exec.ContinuousQuery) of
+// the rows as they are written instead of snapshots of the tables.  A row
+// per change, of the columns of the table (the row after an insert or
+// update, before a delete) and then change_op (insert, update, delete) and
+// change_time.  A scan blocks for the next change until it is closed.
+//
+//   stream, _ := datasource.NewChangeStreamSource(users)
+//   datasource.RegisterSchemaSource("live", "live", stream)
+//   // SELECT id, name, change_op FROM users WHERE change_op = "delete"
+type ChangeStreamSource struct {
+	src     schema.Source
+	cdc     schema.SourceChanges
+	metrics Metrics
+}
+
+// changeConn a subscription to the changes of a table, as rows
+type changeConn struct {
+	sub      schema.Subscription
+	tbl      *schema.Table
+	ncols    int
+	colindex map[string]int
+	ct       uint64
+}
+
+// NewChangeStreamSource the changes of the tables of src, which must
+// implement schema.SourceChanges
+func NewChangeStreamSource(src schema.Source) (*ChangeStreamSource, error) {
+	cdc, ok := src.(schema.SourceChanges)
+	if !ok {
+		return nil, fmt.Errorf("%T does not publish its changes (schema.SourceChanges)", src)
+	}
+	return &ChangeStreamSource{src: src, cdc: cdc}, nil
+}
+
+func (m *ChangeStreamSource) Tables() []string  { return m.src.Tables() }
+func (m *ChangeStreamSource) Close() error      { return nil }
+func (m *ChangeStreamSource) Metrics() *Metrics { return &m.metrics }
+
+// Table the columns of table, and the change columns
+func (m *ChangeStreamSource) Table(table string) (*schema.Table, error) {
+	sts, ok := m.src.(schema.SourceTableSchema)
+	if !ok {
+		return nil, fmt.Errorf("%T has no table schemas", m.src)
+	}
+	tbl, err := sts.Table(table)
+	if err != nil {
+		return nil, err
+	}
+	changes := schema.NewTable(tbl.Name)
+	for _, col := range tbl.Columns() {
+		typ := value.UnknownType
+		if f, ok := tbl.FieldMap[col]; ok {
+			typ = f.Type
+		}
+		changes.AddField(schema.NewFieldBase(col, typ, 64, typ.String()))
+	}
+	changes.AddField(schema.NewFieldBase(ChangeOpColumn, value.StringType, 16, "string"))
+	changes.AddField(schema.NewFieldBase(ChangeTimeColumn, value.TimeType, 32, "time"))
+	changes.SetColumns(append(tbl.Columns(), ChangeOpColumn, ChangeTimeColumn))
+	return changes, nil
+}
+
+// Open subscribe to the changes of table, the scan of them blocks for the
+// next change until the conn is closed
+func (m *ChangeStreamSource) Open(table string) (schema.Conn, error) {
+	tbl, err := m.Table(table)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := m.cdc.Subscribe(table)
+	if err != nil {
+		return nil, err
+	}
+	return &changeConn{sub: sub, tbl: tbl, ncols: len(tbl.Columns()) - 2, colindex: tbl.FieldPositions}, nil
+}
+
+func (m *changeConn) Columns() []string { return m.tbl.Columns() }
+func (m *changeConn) Close() error      { return m.sub.Close() }
+
+// Err the error that closed the subscription, ie it fell behind
+func (m *changeConn) Err() error { return m.sub.Err() }
+
+func (m *changeConn) Next() schema.Message {
+	change, ok := <-m.sub.Changes()
+	if !ok {
+		return nil
+	}
+	row := make([]driver.Value, m.ncols+2)
+	copy(row, change.Row)
+	row[m.ncols], row[m.ncols+1] = change.Op.String(), change.Ts
+	m.ct++
+	return NewSqlDriverMessageMap(m.ct, row, m.colindex)
+}
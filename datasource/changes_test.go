@@ -0,0 +1,37 @@
+package datasource_test
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/schema"
+)
+
+func TestChangeFeed(t *testing.T) {
+	feed := datasource.NewChangeFeed()
+	users, all := feed.Subscribe("Users"), feed.Subscribe("")
+	assert.Equal(t, 2, feed.Subscribers())
+	feed.Publish(&schema.Change{Op: schema.ChangeInsert, Table: "users", Row: []driver.Value{int64(1)}})
+	feed.Publish(&schema.Change{Op: schema.ChangeDelete, Table: "orders", Row: []driver.Value{int64(2)}})
+	assert.Equal(t, 1, len(users.Changes()))
+	assert.Equal(t, 2, len(all.Changes()))
+	assert.Equal(t, "insert", (<-users.Changes()).Op.String())
+
+	// closed subscriptions get no more changes
+	users.Close()
+	_, open := <-users.Changes()
+	assert.Equal(t, false, open)
+	assert.Equal(t, nil, users.Err())
+
+	// a subscriber that falls behind is closed
+	for i := 0; i < datasource.ChangeBufferSize; i++ {
+		feed.Publish(&schema.Change{Op: schema.ChangeUpdate, Table: "orders"})
+	}
+	assert.Equal(t, datasource.ErrSlowSubscriber, all.Err())
+	assert.Equal(t, 0, feed.Subscribers())
+	var none *datasource.ChangeFeed
+	none.Publish(&schema.Change{})
+}
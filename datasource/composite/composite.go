@@ -0,0 +1,401 @@
+// Composite package implements a Datasource presenting the tables of other
+// sources as one table, ie the recent rows of a table in memory and the
+// older rows of it in files, queried as a single table.
+package composite
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/expr/translate"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+const (
+	// SourceType the source type of composite sources in the config
+	SourceType = "composite"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Different Features of this Composite Data Source
+	_ schema.Source            = (*CompositeSource)(nil)
+	_ datasource.MeteredSource = (*CompositeSource)(nil)
+	_ schema.SourceSetup       = (*CompositeSource)(nil)
+	_ schema.SourceTableSchema = (*CompositeSource)(nil)
+	_ schema.ConnScanner       = (*compositeConn)(nil)
+	_ schema.ConnColumns       = (*compositeConn)(nil)
+	_ schema.IteratorErr       = (*compositeConn)(nil)
+	_ translate.Translator     = (*compositeConn)(nil)
+)
+
+// Part a table of another source holding some of the rows of a composite
+// table, those its where is true for (all if it has none).  The where
+// routes the queries of the composite table to the parts that may have
+// rows of them, of comparisons of columns to literals AND-ed together.
+//
+//   ts >= "now-7d"
+//   ts < "now-7d" AND region = "us"
+type Part struct {
+	Source string        `json:"source"` // name of the registered source
+	Table  string        `json:"table"`  // of the source, defaults to that of the composite table
+	Where  string        `json:"where"`  // the rows of the part
+	DS     schema.Source `json:"-"`      // the source, instead of the registered source of name Source
+
+	where expr.Node
+}
+
+// CompositeSource a DataSource of tables each of the tables (parts) of other
+// sources, of the settings of the source config
+//
+//   "settings" : {
+//       "tables" : {
+//           "events" : [
+//               {"source": "events_hot",  "table": "events", "where": "ts >= \"now-7d\""},
+//               {"source": "events_cold", "table": "events", "where": "ts < \"now-7d\""}
+//           ]
+//       }
+//   }
+//
+// The sources of the parts are registered (see datasource.Register) before
+// the schema of the composite source is loaded.  The columns of a table are
+// the columns of all of its parts, of the type of the first part of each,
+// rows of parts without a column are null for it.  Scans read the parts in
+// order, skipping parts whose where can't be true for that of the query.
+type CompositeSource struct {
+	mu      sync.Mutex
+	tables  map[string]*compositeTable
+	names   []string
+	metrics datasource.Metrics
+}
+
+// compositeTable a table of parts, its schema merged from theirs
+type compositeTable struct {
+	name  string
+	parts []*Part
+	mu    sync.Mutex
+	tbl   *schema.Table
+}
+
+// compositeConn the scan of the parts of a table in order
+type compositeConn struct {
+	t     *compositeTable
+	tbl   *schema.Table
+	parts []*Part   // routed to, not pruned by the where
+	where expr.Node // of the query, pushed down to the parts
+	i     int
+	cur   schema.ConnScanner
+	ct    uint64
+	err   error
+}
+
+// routed the native where of a composite table (see Translate)
+type routed struct {
+	parts []*Part
+	where expr.Node
+}
+
+// NewCompositeSource a CompositeSource of the settings of a source config
+func NewCompositeSource(settings u.JsonHelper) (*CompositeSource, error) {
+	m := &CompositeSource{}
+	if err := m.load(settings); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Setup read the parts of each of the "tables" of the source config, of a
+// CompositeSource{} registered without settings
+func (m *CompositeSource) Setup(ss *schema.SchemaSource) error {
+	m.mu.Lock()
+	loaded := m.tables != nil
+	m.mu.Unlock()
+	return datasource.SetupSettings(ss, loaded, m.load)
+}
+
+func (m *CompositeSource) load(settings u.JsonHelper) error {
+	tables := make(map[string][]*Part)
+	if raw, ok := settings["tables"]; ok {
+		// re-decode the generic json of the settings into the parts
+		by, err := json.Marshal(raw)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(by, &tables); err != nil {
+			return fmt.Errorf("invalid composite tables: %v", err)
+		}
+	}
+	if len(tables) == 0 {
+		return fmt.Errorf("composite source requires tables in settings")
+	}
+	for name, parts := range tables {
+		if err := m.AddTable(name, parts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddTable add (or replace) the table of name of these parts, in the order
+// they are scanned
+func (m *CompositeSource) AddTable(name string, parts ...*Part) error {
+	name = strings.ToLower(name)
+	if name == "" || len(parts) == 0 {
+		return fmt.Errorf("composite table requires a name and parts")
+	}
+	for i, p := range parts {
+		if p.Table == "" {
+			p.Table = name
+		}
+		if p.DS == nil {
+			if p.DS = datasource.DataSourcesRegistry().Get(p.Source); p.DS == nil {
+				return fmt.Errorf("could not find source %q of part %d of composite table %q", p.Source, i, name)
+			}
+		}
+		if p.Where != "" {
+			where, err := expr.ParseExpression(p.Where)
+			if err != nil {
+				return fmt.Errorf("invalid where %q of part %d of composite table %q: %v", p.Where, i, name, err)
+			}
+			p.where = where.Root
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tables == nil {
+		m.tables = make(map[string]*compositeTable)
+	}
+	if _, exists := m.tables[name]; !exists {
+		m.names = append(m.names, name)
+		sort.Strings(m.names)
+	}
+	m.tables[name] = &compositeTable{name: name, parts: parts}
+	return nil
+}
+
+func (m *CompositeSource) Tables() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.names...)
+}
+
+func (m *CompositeSource) table(name string) (*compositeTable, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tables[strings.ToLower(name)]
+	if !ok {
+		return nil, schema.ErrNotFound
+	}
+	return t, nil
+}
+
+// Table the schema of a table, merged from those of its parts
+func (m *CompositeSource) Table(name string) (*schema.Table, error) {
+	t, err := m.table(name)
+	if err != nil {
+		return nil, err
+	}
+	return t.schema()
+}
+
+func (m *CompositeSource) Open(name string) (schema.Conn, error) {
+	t, err := m.table(name)
+	if err != nil {
+		return nil, err
+	}
+	tbl, err := t.schema()
+	if err != nil {
+		return nil, err
+	}
+	return &compositeConn{t: t, tbl: tbl, parts: t.parts}, nil
+}
+
+func (m *CompositeSource) Close() error { return nil }
+
+func (m *CompositeSource) Metrics() *datasource.Metrics { return &m.metrics }
+
+// schema the table of the columns of all the parts, of the type of the
+// first part of each
+func (m *compositeTable) schema() (*schema.Table, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tbl != nil {
+		return m.tbl, nil
+	}
+	tbl := schema.NewTable(m.name)
+	cols := make([]string, 0)
+	for _, p := range m.parts {
+		ptbl, err := p.schema()
+		if err != nil {
+			return nil, err
+		}
+		for _, col := range ptbl.Columns() {
+			fld, ok := ptbl.FieldMap[col]
+			if !ok {
+				// a table of columns but no fields (ie membtree)
+				fld = schema.NewFieldBase(col, value.UnknownType, 255, "")
+			}
+			if existing, ok := tbl.FieldMap[col]; ok {
+				if existing.Type != fld.Type {
+					u.Warnf("column %q of composite table %q is %s in %q, not %s", col, m.name,
+						fld.Type, p.Table, existing.Type)
+				}
+				continue
+			}
+			f := *fld
+			tbl.AddField(&f)
+			cols = append(cols, col)
+		}
+	}
+	tbl.SetColumns(cols)
+	m.tbl = tbl
+	return tbl, nil
+}
+
+// schema the table of the part in its source
+func (m *Part) schema() (*schema.Table, error) {
+	if sts, ok := m.DS.(schema.SourceTableSchema); ok {
+		tbl, err := sts.Table(m.Table)
+		if err != nil {
+			return nil, fmt.Errorf("could not get table %q of part: %v", m.Table, err)
+		}
+		return tbl, nil
+	}
+	return nil, fmt.Errorf("source of part %q has no table schema", m.Table)
+}
+
+func (m *compositeConn) Columns() []string { return m.tbl.Columns() }
+
+// Translate route the where of the query to the parts whose wheres it may
+// be true with, so the others are not scanned.  The where is still
+// evaluated on the rows.
+func (m *compositeConn) Translate(node expr.Node) (interface{}, error) {
+	r := &routed{parts: m.t.route(node), where: node}
+	m.SetNative(r)
+	return r, nil
+}
+
+// SetNative the parts routed to by the planner (see Translate)
+func (m *compositeConn) SetNative(native interface{}) {
+	if r, ok := native.(*routed); ok {
+		m.parts, m.where = r.parts, r.where
+	}
+}
+
+// Next the next row of the parts, of the columns of the composite table
+func (m *compositeConn) Next() schema.Message {
+	for {
+		if m.err != nil {
+			return nil
+		}
+		if m.cur == nil {
+			if m.i >= len(m.parts) {
+				return nil
+			}
+			if m.err = m.open(m.parts[m.i]); m.err != nil {
+				return nil
+			}
+		}
+		msg := m.cur.Next()
+		if msg == nil {
+			if ie, ok := m.cur.(schema.IteratorErr); ok && ie.Err() != nil {
+				m.err = fmt.Errorf("scan of part %q of %q failed: %v", m.parts[m.i].Table, m.t.name, ie.Err())
+			}
+			m.cur.Close()
+			m.cur = nil
+			m.i++
+			continue
+		}
+		var ctx expr.ContextReader
+		switch body := msg.Body().(type) {
+		case expr.ContextReader:
+			ctx = body
+		case []driver.Value:
+			cols := m.parts[m.i].columns(m.cur)
+			if len(body) > len(cols) {
+				body = body[:len(cols)]
+			}
+			ctx = datasource.NewSqlDriverMessageMapVals(msg.Id(), body, cols[:len(body)])
+		default:
+			u.Warnf("unhandled row %T of part %q of %q", body, m.parts[m.i].Table, m.t.name)
+			continue
+		}
+		m.ct++
+		return datasource.NewSqlDriverMessageMapCtx(m.ct, ctx, m.tbl.FieldPositions)
+	}
+}
+
+// open the scan of part p, the where of the query pushed down to it if it
+// translates wheres and has all the columns of the where
+func (m *compositeConn) open(p *Part) error {
+	conn, err := p.DS.Open(p.Table)
+	if err != nil {
+		return fmt.Errorf("could not open part %q of %q: %v", p.Table, m.t.name, err)
+	}
+	scanner, ok := conn.(schema.ConnScanner)
+	if !ok {
+		conn.Close()
+		return fmt.Errorf("part %q of %q can not be scanned", p.Table, m.t.name)
+	}
+	if m.where != nil {
+		m.pushdown(p, conn)
+	}
+	m.cur = scanner
+	return nil
+}
+
+func (m *compositeConn) pushdown(p *Part, conn schema.Conn) {
+	t, ok := conn.(translate.Translator)
+	if !ok {
+		return
+	}
+	sn, ok := conn.(interface {
+		SetNative(native interface{})
+	})
+	if !ok {
+		return
+	}
+	ptbl, err := p.schema()
+	if err != nil {
+		return
+	}
+	for _, col := range identities(m.where, nil) {
+		if !ptbl.HasField(col) {
+			return
+		}
+	}
+	if native, err := t.Translate(m.where); err == nil {
+		sn.SetNative(native)
+	}
+}
+
+// columns of the rows of the part, of its conn if it has them
+func (m *Part) columns(conn schema.Conn) []string {
+	if cc, ok := conn.(schema.ConnColumns); ok {
+		return cc.Columns()
+	}
+	if tbl, err := m.schema(); err == nil {
+		return tbl.Columns()
+	}
+	return nil
+}
+
+func (m *compositeConn) Err() error { return m.err }
+
+func (m *compositeConn) Close() error {
+	if m.cur != nil {
+		m.cur.Close()
+		m.cur = nil
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package composite_test
+
+import (
+	"database/sql/driver"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	u "github.com/araddon/gou"
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/composite"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	openedMu sync.Mutex
+	opened   []string
+)
+
+// openedSource a membtree source recording it was opened
+type openedSource struct {
+	*membtree.StaticDataSource
+	name string
+}
+
+func (m *openedSource) Open(table string) (schema.Conn, error) {
+	openedMu.Lock()
+	opened = append(opened, m.name)
+	openedMu.Unlock()
+	return m.StaticDataSource.Open(table)
+}
+
+func day(d int) time.Time { return time.Date(2016, 5, d, 0, 0, 0, 0, time.UTC) }
+
+func TestCompositeSource(t *testing.T) {
+	hot := membtree.NewStaticDataSource("events", 0, [][]driver.Value{
+		{int64(3), day(2), 30.0, "late"},
+		{int64(4), day(3), 40.0, "later"},
+	}, []string{"id", "ts", "amount", "note"})
+	cold := membtree.NewStaticDataSource("events_archive", 0, [][]driver.Value{
+		{int64(1), day(1).AddDate(0, -1, 0), 10.0},
+		{int64(2), day(1).AddDate(0, 0, -1), 20.0},
+	}, []string{"id", "ts", "amount"})
+	datasource.Register("composite_hot", &openedSource{hot, "hot"})
+	datasource.Register("composite_cold", &openedSource{cold, "cold"})
+
+	src, err := composite.NewCompositeSource(u.JsonHelper{"tables": map[string]interface{}{
+		"events": []interface{}{
+			map[string]interface{}{"source": "composite_hot", "where": `ts >= "2016-05-01"`},
+			map[string]interface{}{"source": "composite_cold", "table": "events_archive", "where": `ts < "2016-05-01"`},
+		},
+	}})
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"events"}, src.Tables())
+	tbl, err := src.Table("events")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"id", "ts", "amount", "note"}, tbl.Columns())
+	sch := datasource.RegisterSchemaSource("composite_events", "composite_events", src)
+
+	tests := []struct {
+		sql    string
+		rows   [][]driver.Value
+		opened []string
+	}{
+		{`SELECT id, amount, note FROM events`, [][]driver.Value{
+			{int64(1), 10.0, nil},
+			{int64(2), 20.0, nil},
+			{int64(3), 30.0, "late"},
+			{int64(4), 40.0, "later"},
+		}, []string{"cold", "hot"}},
+		{`SELECT id, amount, note FROM events WHERE ts >= "2016-05-03"`, [][]driver.Value{
+			{int64(4), 40.0, "later"},
+		}, []string{"hot"}},
+		{`SELECT id, amount, note FROM events WHERE ts BETWEEN "2016-03-31" AND "2016-04-20"`, [][]driver.Value{
+			{int64(1), 10.0, nil},
+		}, []string{"cold"}},
+		{`SELECT id, amount, note FROM events WHERE ts < "2016-05-01" AND 15 < amount`, [][]driver.Value{
+			{int64(2), 20.0, nil},
+		}, []string{"cold"}},
+		// not of the routing column, all parts are scanned
+		{`SELECT id, amount, note FROM events WHERE amount > 25`, [][]driver.Value{
+			{int64(3), 30.0, "late"},
+			{int64(4), 40.0, "later"},
+		}, []string{"cold", "hot"}},
+	}
+	for _, tt := range tests {
+		openedMu.Lock()
+		opened = nil
+		openedMu.Unlock()
+
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema = sch
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		cols := []string{"id", "amount", "note"}
+		out := exec.NewResultRows(ctx, cols)
+		job.RootTask.Add(out)
+		assert.T(t, job.Setup() == nil)
+		go job.Run()
+		rows := make([][]driver.Value, 0)
+		for {
+			dest := make([]driver.Value, len(cols))
+			if out.Next(dest) != nil {
+				break
+			}
+			rows = append(rows, dest)
+		}
+		job.Close()
+		sort.Slice(rows, func(i, j int) bool { return rows[i][0].(int64) < rows[j][0].(int64) })
+		assert.Equalf(t, tt.rows, rows, "rows of %s", tt.sql)
+		openedMu.Lock()
+		sort.Strings(opened)
+		assert.Equalf(t, tt.opened, opened, "parts opened by %s", tt.sql)
+		openedMu.Unlock()
+	}
+
+	// parts of unknown sources, invalid wheres
+	_, err = composite.NewCompositeSource(u.JsonHelper{"tables": map[string]interface{}{
+		"events": []interface{}{map[string]interface{}{"source": "composite_missing"}}}})
+	assert.T(t, err != nil)
+	_, err = composite.NewCompositeSource(u.JsonHelper{"tables": map[string]interface{}{
+		"events": []interface{}{map[string]interface{}{"source": "composite_hot", "where": "ts >="}}}})
+	assert.T(t, err != nil)
+	_, err = composite.NewCompositeSource(u.JsonHelper{})
+	assert.T(t, err != nil)
+}
@@ -0,0 +1,259 @@
+package composite
+
+import (
+	"strings"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/value"
+	"github.com/araddon/qlbridge/vm"
+)
+
+// bound the lower or upper bound of an interval of a column
+type bound struct {
+	val  value.Value
+	incl bool
+}
+
+// interval the values of a column a where may be true for, of nil bounds
+// for unbounded
+type interval struct {
+	lo, hi *bound
+}
+
+// route the parts of the table the where may be true for rows of, in order
+func (m *compositeTable) route(where expr.Node) []*Part {
+	q := make(map[string]*interval)
+	columnRanges(where, q)
+	if len(q) == 0 {
+		return m.parts
+	}
+	parts := make([]*Part, 0, len(m.parts))
+	for _, p := range m.parts {
+		if p.where != nil {
+			pr := make(map[string]*interval)
+			columnRanges(p.where, pr)
+			if disjoint(q, pr) {
+				continue
+			}
+		}
+		parts = append(parts, p)
+	}
+	return parts
+}
+
+// disjoint are there no values of a column in both ranges a and b
+func disjoint(a, b map[string]*interval) bool {
+	for col, ai := range a {
+		if bi, ok := b[col]; ok && ai.disjoint(bi) {
+			return true
+		}
+	}
+	return false
+}
+
+// columnRanges narrow the intervals of the columns of the comparisons of
+// columns to literals of the conjuncts of node
+//
+//   ts >= "2016-05-01" AND ts < "now-1d"   ts ["2016-05-01", "now-1d")
+//   id BETWEEN 5 AND 10                    id [5, 10]
+func columnRanges(node expr.Node, ranges map[string]*interval) {
+	switch n := node.(type) {
+	case *expr.BinaryNode:
+		if n.Operator.T == lex.TokenLogicAnd {
+			columnRanges(n.Args[0], ranges)
+			columnRanges(n.Args[1], ranges)
+			return
+		}
+		if len(n.Args) != 2 {
+			return
+		}
+		op := n.Operator.T
+		col, ok := columnOf(n.Args[0])
+		v, vok := literal(n.Args[1])
+		if !ok || !vok {
+			// 5 < id
+			if col, ok = columnOf(n.Args[1]); !ok {
+				return
+			}
+			if v, vok = literal(n.Args[0]); !vok {
+				return
+			}
+			op = flip(op)
+		}
+		in := rangeOf(ranges, col)
+		switch op {
+		case lex.TokenEqual, lex.TokenEqualEqual:
+			in.narrowLo(v, true)
+			in.narrowHi(v, true)
+		case lex.TokenGT, lex.TokenGE:
+			in.narrowLo(v, op == lex.TokenGE)
+		case lex.TokenLT, lex.TokenLE:
+			in.narrowHi(v, op == lex.TokenLE)
+		}
+	case *expr.TriNode:
+		if n.Operator.T != lex.TokenBetween || len(n.Args) != 3 {
+			return
+		}
+		col, ok := columnOf(n.Args[0])
+		lo, lok := literal(n.Args[1])
+		hi, hok := literal(n.Args[2])
+		if !ok || !lok || !hok {
+			return
+		}
+		in := rangeOf(ranges, col)
+		in.narrowLo(lo, true)
+		in.narrowHi(hi, true)
+	}
+}
+
+func rangeOf(ranges map[string]*interval, col string) *interval {
+	in, ok := ranges[col]
+	if !ok {
+		in = &interval{}
+		ranges[col] = in
+	}
+	return in
+}
+
+// flip the operator of a comparison of swapped arguments
+func flip(op lex.TokenType) lex.TokenType {
+	switch op {
+	case lex.TokenGT:
+		return lex.TokenLT
+	case lex.TokenGE:
+		return lex.TokenLE
+	case lex.TokenLT:
+		return lex.TokenGT
+	case lex.TokenLE:
+		return lex.TokenGE
+	}
+	return op
+}
+
+// columnOf the lower cased column name (without table) of an identity
+func columnOf(node expr.Node) (string, bool) {
+	in, ok := node.(*expr.IdentityNode)
+	if !ok {
+		return "", false
+	}
+	// not in.LeftRight(), it memoizes onto the node evaluated concurrently
+	_, col, _ := expr.LeftRight(in.Text)
+	return strings.ToLower(col), true
+}
+
+// literal the value of a node of no identities (ie "now-7d", 5)
+func literal(node expr.Node) (value.Value, bool) {
+	if _, isIdent := node.(*expr.IdentityNode); isIdent || len(identities(node, nil)) > 0 {
+		return nil, false
+	}
+	v, ok := vm.Eval(datasource.NewContextSimple(), node)
+	if !ok || v == nil || v.Nil() {
+		return nil, false
+	}
+	return v, true
+}
+
+// identities the lower cased column names of all identities of node
+func identities(node expr.Node, cols []string) []string {
+	switch n := node.(type) {
+	case *expr.IdentityNode:
+		col, _ := columnOf(n)
+		cols = append(cols, col)
+	case *expr.BinaryNode:
+		for _, arg := range n.Args {
+			cols = identities(arg, cols)
+		}
+	case *expr.TriNode:
+		for _, arg := range n.Args {
+			cols = identities(arg, cols)
+		}
+	case *expr.FuncNode:
+		for _, arg := range n.Args {
+			cols = identities(arg, cols)
+		}
+	case *expr.UnaryNode:
+		cols = identities(n.Arg, cols)
+	}
+	return cols
+}
+
+// narrowLo raise the lower bound to v, if higher
+func (m *interval) narrowLo(v value.Value, incl bool) {
+	if m.lo != nil {
+		c, ok := compare(v, m.lo.val)
+		if !ok || c < 0 || (c == 0 && incl) {
+			return
+		}
+	}
+	m.lo = &bound{val: v, incl: incl}
+}
+
+// narrowHi lower the upper bound to v, if lower
+func (m *interval) narrowHi(v value.Value, incl bool) {
+	if m.hi != nil {
+		c, ok := compare(v, m.hi.val)
+		if !ok || c > 0 || (c == 0 && incl) {
+			return
+		}
+	}
+	m.hi = &bound{val: v, incl: incl}
+}
+
+// disjoint are there no values in both intervals, false if the bounds
+// can't be compared
+func (m *interval) disjoint(o *interval) bool {
+	return below(m.hi, o.lo) || below(o.hi, m.lo)
+}
+
+// below is every value of the upper bound hi below those of the lower
+// bound lo
+func below(hi, lo *bound) bool {
+	if hi == nil || lo == nil {
+		return false
+	}
+	c, ok := compare(hi.val, lo.val)
+	if !ok {
+		return false
+	}
+	return c < 0 || (c == 0 && !(hi.incl && lo.incl))
+}
+
+// compare a to b, as numbers if either is one, else as times if both are
+// (or are strings of times, or date math), else as strings
+func compare(a, b value.Value) (int, bool) {
+	_, anum := a.(value.NumericValue)
+	_, bnum := b.(value.NumericValue)
+	if anum || bnum {
+		af, aok := value.ValueToFloat64(a)
+		bf, bok := value.ValueToFloat64(b)
+		if !aok || !bok {
+			return 0, false
+		}
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		}
+		return 0, true
+	}
+	if at, ok := value.ValueToTime(a); ok {
+		if bt, ok := value.ValueToTime(b); ok {
+			switch {
+			case at.Before(bt):
+				return -1, true
+			case at.After(bt):
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	as, aok := a.(value.StringValue)
+	bs, bok := b.(value.StringValue)
+	if !aok || !bok {
+		return 0, false
+	}
+	return strings.Compare(as.Val(), bs.Val()), true
+}
@@ -0,0 +1,73 @@
+package datasource
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Decompressor open a reader of the decompressed contents of r
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+type compression struct {
+	name  string
+	magic []byte
+	open  Decompressor
+}
+
+var (
+	compressMu sync.RWMutex
+	// compressions known by the magic bytes their files start with, zstd
+	// has no decoder in the standard library so must be registered
+	compressions = []*compression{
+		{name: "gzip", magic: []byte{'\x1F', '\x8B'}, open: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		}},
+		{name: "zstd", magic: []byte{'\x28', '\xB5', '\x2F', '\xFD'}},
+	}
+)
+
+// RegisterDecompressor register the Decompressor for files starting with
+// magic, replacing any of the same name, ie
+//
+//   datasource.RegisterDecompressor("zstd", []byte{0x28, 0xB5, 0x2F, 0xFD},
+//       func(r io.Reader) (io.ReadCloser, error) {
+//           d, err := zstd.NewReader(r)
+//           return d.IOReadCloser(), err
+//       })
+func RegisterDecompressor(name string, magic []byte, open Decompressor) {
+	compressMu.Lock()
+	defer compressMu.Unlock()
+	for _, c := range compressions {
+		if c.name == name {
+			c.magic, c.open = magic, open
+			return
+		}
+	}
+	compressions = append(compressions, &compression{name: name, magic: magic, open: open})
+}
+
+// decompress buf if it starts with the magic bytes of a known compression,
+// the returned closer (if not nil) closes the decompressor.
+func decompress(buf *bufio.Reader) (io.Reader, io.Closer, error) {
+	compressMu.RLock()
+	defer compressMu.RUnlock()
+	for _, c := range compressions {
+		head, err := buf.Peek(len(c.magic))
+		if err != nil || !bytes.Equal(head, c.magic) {
+			continue
+		}
+		if c.open == nil {
+			return nil, nil, fmt.Errorf("%s compressed file but no decompressor registered, see RegisterDecompressor", c.name)
+		}
+		rc, err := c.open(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		return rc, rc, nil
+	}
+	return buf, nil, nil
+}
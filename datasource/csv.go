@@ -2,14 +2,17 @@ package datasource
 
 import (
 	"bufio"
-	"bytes"
-	"compress/gzip"
 	"database/sql/driver"
 	"encoding/csv"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/araddon/dateparse"
 	u "github.com/araddon/gou"
 
 	"github.com/araddon/qlbridge/expr"
@@ -19,71 +22,161 @@ import (
 
 var (
 	_ schema.Source      = (*CsvDataSource)(nil)
+	_ MeteredSource      = (*CsvDataSource)(nil)
 	_ schema.Conn        = (*CsvDataSource)(nil)
 	_ schema.ConnScanner = (*CsvDataSource)(nil)
+	_ schema.SourceSetup = (*CsvDataSource)(nil)
+
+	// CsvSampleRows rows sampled to infer the column types of csv files
+	// opened by path (Open) or NewCsvSource unless set in the source settings
+	CsvSampleRows = 100
 )
 
+// CsvOptions how a csv file is read
+type CsvOptions struct {
+	// Delimiter of fields, comma if zero (tab for .tsv files opened by path)
+	Delimiter rune
+	// Columns of a file without a header row, if empty the first row is
+	// the header
+	Columns []string
+	// NullTokens field values read as null, ie "NULL", "\\N"
+	NullTokens []string
+	// SampleRows rows read to infer the type of each column (int, number,
+	// bool, time or string), if zero all columns are strings
+	SampleRows int
+	// Types of columns by name, over-riding inference
+	Types map[string]value.ValueType
+}
+
+// CsvOptionsFromSettings the CsvOptions of the Settings of a source config
+//
+//   "settings" : {
+//       "delimiter"   : "\t",              // or "tab", "|", ";"
+//       "columns"     : ["id","name"],     // file has no header row
+//       "null"        : ["", "NULL"],
+//       "sample_rows" : 1000,              // 0 to read all columns as strings
+//       "types"       : {"zip":"string", "amount":"number"}
+//   }
+func CsvOptionsFromSettings(settings u.JsonHelper) (CsvOptions, error) {
+	opts := CsvOptions{SampleRows: CsvSampleRows}
+	if settings == nil {
+		return opts, nil
+	}
+	switch delim := settings.String("delimiter"); delim {
+	case "":
+	case "tab", "\\t", "\t":
+		opts.Delimiter = '\t'
+	default:
+		r, size := utf8.DecodeRuneInString(delim)
+		if size != len(delim) || r == '"' || r == '\r' || r == '\n' {
+			return opts, fmt.Errorf("invalid csv delimiter %q", delim)
+		}
+		opts.Delimiter = r
+	}
+	opts.Columns = settings.Strings("columns")
+	if nulls := settings.Strings("null"); len(nulls) > 0 {
+		opts.NullTokens = nulls
+	} else if null, ok := settings["null"].(string); ok {
+		opts.NullTokens = []string{null}
+	}
+	if n, ok := settings.IntSafe("sample_rows"); ok {
+		opts.SampleRows = n
+	}
+	if types := settings.Helper("types"); len(types) > 0 {
+		opts.Types = make(map[string]value.ValueType, len(types))
+		for col := range types {
+			name := types.String(col)
+			vt := value.ValueFromString(name)
+			if name == "float" {
+				vt = value.NumberType
+			}
+			switch vt {
+			case value.IntType, value.NumberType, value.BoolType, value.TimeType, value.StringType:
+			default:
+				return opts, fmt.Errorf("unsupported csv column type %q for %q", name, col)
+			}
+			opts.Types[strings.ToLower(col)] = vt
+		}
+	}
+	return opts, nil
+}
+
 // Csv DataSource, implements qlbridge schema DataSource, SourceConn, Scanner
+//
 //   to allow csv files to be full featured databases.
 //   - very, very naive scanner, forward only single pass
 //   - can open a file with .Open()
-//   - assumes comma delimited
+//   - comma delimited unless set in CsvOptions
+//   - column types inferred from the first CsvOptions.SampleRows rows, a value
+//     of a later row that does not parse as the type of its column ends the
+//     scan with an error (see Err)
 //   - not thread-safe
 //   - does not implement write operations
 type CsvDataSource struct {
-	table    string
-	tbl      *schema.Table
-	exit     <-chan bool
-	csvr     *csv.Reader
-	gz       *gzip.Reader
-	rc       io.ReadCloser
-	rowct    uint64
-	headers  []string
-	colindex map[string]int
-	indexCol int
-	filter   expr.Node
+	table      string
+	tbl        *schema.Table
+	exit       <-chan bool
+	csvr       *csv.Reader
+	dc         io.Closer
+	rc         io.ReadCloser
+	rowct      uint64
+	headers    []string
+	colindex   map[string]int
+	indexCol   int
+	filter     expr.Node
+	opts       CsvOptions
+	configured bool // opts from the Setup of a source config
+	nulls      map[string]bool
+	types      []value.ValueType
+	sample     [][]string // sampled rows not yet read
+	metrics    Metrics
+	err        error
 }
 
 // NewCsvSource reader assumes we are getting first row as headers
 // - optionally may be gzipped
+// - column types inferred from the first CsvSampleRows rows
 func NewCsvSource(table string, indexCol int, ior io.Reader, exit <-chan bool) (*CsvDataSource, error) {
+	return NewCsvSourceOptions(table, indexCol, ior, exit, CsvOptions{SampleRows: CsvSampleRows})
+}
+
+// NewCsvSourceOptions a csv reader of ior read as described by opts
+// - optionally compressed, gzip or a registered Decompressor
+// - quoted fields may span lines
+func NewCsvSourceOptions(table string, indexCol int, ior io.Reader, exit <-chan bool, opts CsvOptions) (*CsvDataSource, error) {
 
-	m := CsvDataSource{table: table, indexCol: indexCol}
+	m := CsvDataSource{table: table, indexCol: indexCol, exit: exit, opts: opts}
 	if rc, ok := ior.(io.ReadCloser); ok {
 		m.rc = rc
 	}
 
-	buf := bufio.NewReader(ior)
-
-	first2, err := buf.Peek(2)
+	r, dc, err := decompress(bufio.NewReader(ior))
 	if err != nil {
-		u.Errorf("Error opening bufio.peek for csv reader %v", err)
+		u.Errorf("Could not open reader? %v", err)
 		return nil, err
 	}
+	m.dc = dc
+	m.csvr = csv.NewReader(r)
 
-	// TODO:  move this compression to the file-reader not here
-	if err == nil && len(first2) == 2 && bytes.Equal(first2, []byte{'\x1F', '\x8B'}) {
-		gr, err := gzip.NewReader(buf)
+	m.csvr.TrailingComma = true // allow empty fields
+	if opts.Delimiter != 0 {
+		m.csvr.Comma = opts.Delimiter
+	}
+	if len(opts.NullTokens) > 0 {
+		m.nulls = make(map[string]bool, len(opts.NullTokens))
+		for _, tok := range opts.NullTokens {
+			m.nulls[tok] = true
+		}
+	}
+	headers := opts.Columns
+	if len(headers) == 0 {
+		headers, err = m.csvr.Read()
 		if err != nil {
-			u.Errorf("Could not open reader? %v", err)
+			u.Warnf("err csv %v", err)
 			return nil, err
 		}
-		m.gz = gr
-		m.csvr = csv.NewReader(gr)
 	} else {
-		m.csvr = csv.NewReader(buf)
-	}
-
-	m.csvr.TrailingComma = true // allow empty fields
-	// if flagCsvDelimiter == "|" {
-	// 	m.csvr.Comma = '|'
-	// } else if flagCsvDelimiter == "\t" || flagCsvDelimiter == "t" {
-	// 	m.csvr.Comma = '\t'
-	// }
-	headers, err := m.csvr.Read()
-	if err != nil {
-		u.Warnf("err csv %v", err)
-		return nil, err
+		headers = append([]string(nil), headers...)
 	}
 	//u.Debugf("headers: %v", headers)
 	m.headers = headers
@@ -93,11 +186,42 @@ func NewCsvSource(table string, indexCol int, ior io.Reader, exit <-chan bool) (
 		m.colindex[key] = i
 		m.headers[i] = key
 	}
+	m.types = make([]value.ValueType, len(m.headers))
+	if opts.SampleRows > 0 {
+		for len(m.sample) < opts.SampleRows {
+			row := m.readRow()
+			if row == nil {
+				break
+			}
+			m.sample = append(m.sample, row)
+		}
+		m.inferTypes()
+	}
+	for i, col := range m.headers {
+		if vt, ok := opts.Types[col]; ok {
+			m.types[i] = vt
+		} else if m.types[i] == value.UnknownType || m.types[i] == value.NilType {
+			m.types[i] = value.StringType
+		}
+	}
 	m.loadTable()
 	//u.Infof("csv headers: %v colIndex: %v", headers, m.colindex)
 	return &m, nil
 }
 
+// Setup read the CsvOptions of the source config Settings
+func (m *CsvDataSource) Setup(ss *schema.SchemaSource) error {
+	if ss == nil || ss.Conf == nil {
+		return nil
+	}
+	opts, err := CsvOptionsFromSettings(ss.Conf.Settings)
+	if err != nil {
+		return err
+	}
+	m.opts, m.configured = opts, true
+	return nil
+}
+
 func (m *CsvDataSource) Tables() []string                { return []string{m.table} }
 func (m *CsvDataSource) Columns() []string               { return m.headers }
 func (m *CsvDataSource) CreateIterator() schema.Iterator { return m }
@@ -112,7 +236,7 @@ func (m *CsvDataSource) loadTable() error {
 	columns := m.Columns()
 	for i, _ := range columns {
 		columns[i] = strings.ToLower(columns[i])
-		tbl.AddField(schema.NewFieldBase(columns[i], value.StringType, 64, "string"))
+		tbl.AddField(schema.NewFieldBase(columns[i], m.types[i], 64, m.types[i].String()))
 	}
 	tbl.SetColumns(columns)
 	m.tbl = tbl
@@ -126,8 +250,15 @@ func (m *CsvDataSource) Open(connInfo string) (schema.Conn, error) {
 	if err != nil {
 		return nil, err
 	}
+	opts := m.opts
+	if !m.configured {
+		opts.SampleRows = CsvSampleRows
+	}
+	if opts.Delimiter == 0 && strings.EqualFold(filepath.Ext(connInfo), ".tsv") {
+		opts.Delimiter = '\t'
+	}
 	exit := make(<-chan bool, 1)
-	return NewCsvSource(connInfo, 0, f, exit)
+	return NewCsvSourceOptions(connInfo, 0, f, exit, opts)
 }
 
 func (m *CsvDataSource) Close() error {
@@ -136,8 +267,8 @@ func (m *CsvDataSource) Close() error {
 			u.Errorf("close error: %v", r)
 		}
 	}()
-	if m.gz != nil {
-		m.gz.Close()
+	if m.dc != nil {
+		m.dc.Close()
 	}
 	if m.rc != nil {
 		m.rc.Close()
@@ -145,6 +276,8 @@ func (m *CsvDataSource) Close() error {
 	return nil
 }
 
+func (m *CsvDataSource) Metrics() *Metrics { return &m.metrics }
+
 func (m *CsvDataSource) MesgChan() <-chan schema.Message {
 	iter := m.CreateIterator()
 	return SourceIterChannel(iter, m.exit)
@@ -155,27 +288,132 @@ func (m *CsvDataSource) Next() schema.Message {
 	case <-m.exit:
 		return nil
 	default:
-		for {
-			row, err := m.csvr.Read()
-
-			if err != nil {
-				if err == io.EOF {
-					return nil
-				}
-				u.Warnf("could not read row? %v", err)
-				continue
+	}
+	if m.err != nil {
+		return nil
+	}
+	var row []string
+	if len(m.sample) > 0 {
+		row = m.sample[0]
+		m.sample = m.sample[1:]
+	} else if row = m.readRow(); row == nil {
+		return nil
+	}
+	m.rowct++
+	vals := make([]driver.Value, len(row))
+	for i, val := range row {
+		if m.nulls[val] {
+			continue
+		}
+		v, ok := csvValue(val, m.types[i])
+		if !ok {
+			m.err = fmt.Errorf("csv %q row %d: %q of column %q is not %s", m.table, m.rowct, val, m.headers[i], m.types[i])
+			u.Warnf("%v", m.err)
+			return nil
+		}
+		vals[i] = v
+	}
+	//u.Debugf("headers: %#v \n\trows:  %#v", m.headers, row)
+	return NewSqlDriverMessageMap(m.rowct, vals, m.colindex)
+}
+
+// Err the error of a row that could not be read as the column types, nil if
+// all were read
+func (m *CsvDataSource) Err() error { return m.err }
+
+// readRow the next row with a field per column, nil at the end
+func (m *CsvDataSource) readRow() []string {
+	for {
+		row, err := m.csvr.Read()
+
+		if err != nil {
+			if err == io.EOF {
+				return nil
 			}
-			m.rowct++
-			if len(row) != len(m.headers) {
-				u.Warnf("headers/cols dont match, dropping expected:%d got:%d   vals=", len(m.headers), len(row), row)
+			u.Warnf("could not read row? %v", err)
+			continue
+		}
+		if len(row) != len(m.headers) {
+			u.Warnf("headers/cols dont match, dropping expected:%d got:%d   vals=%v", len(m.headers), len(row), row)
+			continue
+		}
+		return row
+	}
+}
+
+// inferTypes the type of each column from the sampled rows, the narrowest
+// type all of its (non null) values parse as
+func (m *CsvDataSource) inferTypes() {
+	for _, row := range m.sample {
+		for i, val := range row {
+			if val == "" || m.nulls[val] || m.types[i] == value.StringType {
 				continue
 			}
-			vals := make([]driver.Value, len(row))
-			for i, val := range row {
-				vals[i] = val
-			}
-			//u.Debugf("headers: %#v \n\trows:  %#v", m.headers, row)
-			return NewSqlDriverMessageMap(m.rowct, vals, m.colindex)
+			m.types[i] = mergeCsvType(m.types[i], csvType(val))
 		}
 	}
 }
+
+// csvType the narrowest type val parses as, numbers of leading zeros (ie zip
+// codes, ids) are strings as the zeros would be lost
+func csvType(val string) value.ValueType {
+	if leadingZero(val) {
+		return value.StringType
+	} else if _, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return value.IntType
+	} else if _, err := strconv.ParseFloat(val, 64); err == nil {
+		return value.NumberType
+	} else if _, err := strconv.ParseBool(val); err == nil && len(val) > 1 {
+		return value.BoolType
+	} else if _, err := dateparse.ParseAny(val); err == nil {
+		return value.TimeType
+	}
+	return value.StringType
+}
+
+// leadingZero is val a number of a leading zero, ie 007 but not 0 or 0.5
+func leadingZero(val string) bool {
+	val = strings.TrimLeft(val, "+-")
+	return len(val) > 1 && val[0] == '0' && val[1] >= '0' && val[1] <= '9'
+}
+
+// mergeCsvType the type of a column with values of types a and b, ints
+// widen to numbers and other mixed types to strings
+func mergeCsvType(a, b value.ValueType) value.ValueType {
+	switch {
+	case a == value.UnknownType || a == value.NilType || a == b:
+		return b
+	case (a == value.IntType && b == value.NumberType) || (a == value.NumberType && b == value.IntType):
+		return value.NumberType
+	}
+	return value.StringType
+}
+
+// csvValue val as a value of type vt, false if it does not parse.  Empty
+// values are null.
+func csvValue(val string, vt value.ValueType) (driver.Value, bool) {
+	if val == "" && vt != value.StringType {
+		return nil, true
+	}
+	switch vt {
+	case value.IntType:
+		if iv, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return iv, true
+		}
+	case value.NumberType:
+		if fv, err := strconv.ParseFloat(val, 64); err == nil {
+			return fv, true
+		}
+	case value.BoolType:
+		if bv, err := strconv.ParseBool(val); err == nil {
+			return bv, true
+		}
+	case value.TimeType:
+		if tv, err := dateparse.ParseAny(val); err == nil {
+			return tv, true
+		}
+	default:
+		return val, true
+	}
+	return nil, false
+}
@@ -1,9 +1,15 @@
 package datasource_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"database/sql/driver"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
 
 	u "github.com/araddon/gou"
 	"github.com/bmizerany/assert"
@@ -11,6 +17,7 @@ import (
 	"github.com/araddon/qlbridge/datasource"
 	"github.com/araddon/qlbridge/schema"
 	"github.com/araddon/qlbridge/testutil"
+	"github.com/araddon/qlbridge/value"
 )
 
 var (
@@ -55,3 +62,116 @@ func TestCsvDataSource(t *testing.T) {
 	}
 	assert.Tf(t, iterCt == 3, "should have 3 rows: %v", iterCt)
 }
+
+func TestCsvOptions(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("id,name\n1,aaron\n"))
+	w.Close()
+
+	tests := []struct {
+		data     string
+		settings u.JsonHelper
+		cols     []string
+		rows     [][]driver.Value
+	}{
+		{"id\tname\n1\taaron\n", u.JsonHelper{"delimiter": "tab"},
+			[]string{"id", "name"}, [][]driver.Value{{int64(1), "aaron"}}},
+		{"id|name\n1|aaron\n", u.JsonHelper{"delimiter": "|"},
+			[]string{"id", "name"}, [][]driver.Value{{int64(1), "aaron"}}},
+		{"id,notes\n1,\"two\nlines\"\n2,one\n", nil,
+			[]string{"id", "notes"}, [][]driver.Value{{int64(1), "two\nlines"}, {int64(2), "one"}}},
+		{"1,NULL\n2,\n", u.JsonHelper{"columns": []string{"id", "Name"}, "null": []string{"NULL", ""}},
+			[]string{"id", "name"}, [][]driver.Value{{int64(1), nil}, {int64(2), nil}}},
+		{"1,\\N\n", u.JsonHelper{"columns": []string{"id", "name"}, "null": "\\N"},
+			[]string{"id", "name"}, [][]driver.Value{{int64(1), nil}}},
+		{gz.String(), nil,
+			[]string{"id", "name"}, [][]driver.Value{{int64(1), "aaron"}}},
+	}
+	for _, tt := range tests {
+		opts, err := datasource.CsvOptionsFromSettings(tt.settings)
+		assert.Tf(t, err == nil, "should not have error: %v", err)
+		csvIn, err := datasource.NewCsvSourceOptions("t", 0, strings.NewReader(tt.data), make(<-chan bool, 1), opts)
+		assert.Tf(t, err == nil, "should not have error: %v", err)
+		assert.Equal(t, tt.cols, csvIn.Columns())
+		rows := make([][]driver.Value, 0)
+		for msg := csvIn.Next(); msg != nil; msg = csvIn.Next() {
+			rows = append(rows, msg.(*datasource.SqlDriverMessageMap).Values())
+		}
+		assert.Equalf(t, tt.rows, rows, "rows of %q", tt.data)
+	}
+
+	_, err := datasource.CsvOptionsFromSettings(u.JsonHelper{"delimiter": "ab"})
+	assert.T(t, err != nil)
+
+	// zstd is only read once a decompressor is registered
+	zstd := "\x28\xB5\x2F\xFDid,name\n1,aaron\n"
+	_, err = datasource.NewCsvSource("t", 0, strings.NewReader(zstd), make(<-chan bool, 1))
+	assert.T(t, err != nil)
+	datasource.RegisterDecompressor("zstd", []byte("\x28\xB5\x2F\xFD"), func(r io.Reader) (io.ReadCloser, error) {
+		// not really zstd, strips the magic
+		r.Read(make([]byte, 4))
+		return ioutil.NopCloser(r), nil
+	})
+	defer datasource.RegisterDecompressor("zstd", []byte("\x28\xB5\x2F\xFD"), nil)
+	csvIn, err := datasource.NewCsvSource("t", 0, strings.NewReader(zstd), make(<-chan bool, 1))
+	assert.Tf(t, err == nil, "should not have error: %v", err)
+	assert.Equal(t, []string{"id", "name"}, csvIn.Columns())
+}
+
+func TestCsvTypeInference(t *testing.T) {
+	data := `id,amount,active,created,zip,notes
+1,1.5,true,2012-10-17T17:29:39Z,02134,a
+2,3,FALSE,2009-12-11,94110,
+3,n/a,true,2012-10-17T17:29:39Z,10001,c`
+	created, _ := time.Parse(time.RFC3339, "2012-10-17T17:29:39Z")
+
+	tests := []struct {
+		settings u.JsonHelper
+		types    []value.ValueType
+		row      []driver.Value
+		err      bool
+	}{
+		// read after the sample, n/a is not a number so ends the scan
+		{u.JsonHelper{"sample_rows": 2, "types": map[string]interface{}{"zip": "string"}},
+			[]value.ValueType{value.IntType, value.NumberType, value.BoolType, value.TimeType, value.StringType, value.StringType},
+			[]driver.Value{int64(2), float64(3), false, time.Date(2009, 12, 11, 0, 0, 0, 0, time.UTC), "94110", ""}, true},
+		// zips of a leading zero are strings
+		{u.JsonHelper{"types": map[string]interface{}{"id": "float"}},
+			[]value.ValueType{value.NumberType, value.StringType, value.BoolType, value.TimeType, value.StringType, value.StringType},
+			[]driver.Value{float64(3), "n/a", true, created, "10001", "c"}, false},
+		{u.JsonHelper{"sample_rows": 0},
+			[]value.ValueType{value.StringType, value.StringType, value.StringType, value.StringType, value.StringType, value.StringType},
+			[]driver.Value{"3", "n/a", "true", "2012-10-17T17:29:39Z", "10001", "c"}, false},
+	}
+	for _, tt := range tests {
+		opts, err := datasource.CsvOptionsFromSettings(tt.settings)
+		assert.Tf(t, err == nil, "should not have error: %v", err)
+		csvIn, err := datasource.NewCsvSourceOptions("t", 0, strings.NewReader(data), make(<-chan bool, 1), opts)
+		assert.Tf(t, err == nil, "should not have error: %v", err)
+		tbl, _ := csvIn.Table("t")
+		types := make([]value.ValueType, 0)
+		for _, f := range tbl.Fields {
+			types = append(types, f.Type)
+		}
+		assert.Equalf(t, tt.types, types, "types for %v", tt.settings)
+		var last []driver.Value
+		for msg := csvIn.Next(); msg != nil; msg = csvIn.Next() {
+			last = msg.(*datasource.SqlDriverMessageMap).Values()
+		}
+		assert.Equalf(t, tt.row, last, "row for %v", tt.settings)
+		assert.Equalf(t, tt.err, csvIn.Err() != nil, "err for %v: %v", tt.settings, csvIn.Err())
+	}
+
+	// true and false of any case ParseBool does not read are strings
+	csvIn, err := datasource.NewCsvSourceOptions("t", 0, strings.NewReader("a,b,c\ntrue,tRuE,0\nFalse,true,007\n"), make(<-chan bool, 1),
+		datasource.CsvOptions{SampleRows: datasource.CsvSampleRows})
+	assert.Tf(t, err == nil, "should not have error: %v", err)
+	tbl, _ := csvIn.Table("t")
+	assert.Equal(t, value.BoolType, tbl.Fields[0].Type)
+	assert.Equal(t, value.StringType, tbl.Fields[1].Type)
+	assert.Equal(t, value.StringType, tbl.Fields[2].Type)
+
+	_, err = datasource.CsvOptionsFromSettings(u.JsonHelper{"types": map[string]interface{}{"id": "[]string"}})
+	assert.T(t, err != nil)
+}
@@ -0,0 +1,457 @@
+// Datagen package implements a Datasource of synthetic rows generated from
+// a description of its tables (row count, column types, distributions),
+// for benchmarks and load tests of more rows than the mockcsv data sets.
+package datagen
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/araddon/dateparse"
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+const (
+	// SourceType the source type of generated sources in the config
+	SourceType = "datagen"
+)
+
+var (
+	_ = u.EMPTY
+
+	// PartitionRows rows per partition of a generated table, so large tables
+	// are generated in parallel
+	PartitionRows int64 = 1000000
+
+	// the range of time columns without from and to, fixed so generated
+	// rows don't change with when they are generated
+	defaultFrom = time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	defaultTo   = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Different Features of this Generator Data Source
+	_ schema.Source              = (*GenSource)(nil)
+	_ datasource.MeteredSource   = (*GenSource)(nil)
+	_ schema.SourceSetup         = (*GenSource)(nil)
+	_ schema.SourceTableSchema   = (*GenSource)(nil)
+	_ schema.SourcePartitionable = (*genConn)(nil)
+	_ schema.ConnScanner         = (*genConn)(nil)
+	_ schema.ConnColumns         = (*genConn)(nil)
+)
+
+// TableSpec the description of a generated table
+type TableSpec struct {
+	Name    string        `json:"name"`
+	Rows    int64         `json:"rows"`
+	Columns []*ColumnSpec `json:"columns"`
+}
+
+// ColumnSpec the description of the values of a generated column.  The
+// distribution picks a point of the range of the column, of
+//
+//   uniform   (default) every value equally likely
+//   normal    values around the middle of the range more likely
+//   skewed    values at the start of the range far more likely
+//   sequence  values in order of the rows, ie ids, event times
+//
+// of cardinality distinct values if set (ie the countries of 50).
+type ColumnSpec struct {
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`         // int (default), float, string, bool, time
+	Distribution string   `json:"distribution"` // uniform, normal, skewed, sequence
+	Min          float64  `json:"min"`          // of int and float columns
+	Max          float64  `json:"max"`          // defaults to rows - 1 for int, 1 for float
+	From         string   `json:"from"`         // of time columns
+	To           string   `json:"to"`
+	Cardinality  int64    `json:"cardinality"` // distinct values, 0 for as many as the range has
+	Values       []string `json:"values"`      // of string columns, else name_n
+	NullRate     float64  `json:"null_rate"`   // fraction of values that are null
+
+	from, to time.Time
+}
+
+// GenSource a DataSource of generated tables, of the settings of the source
+// config
+//
+//   "settings" : {
+//       "seed"   : 42,
+//       "tables" : [
+//           {"name": "users", "rows": 5000000, "columns": [
+//               {"name": "user_id", "distribution": "sequence"},
+//               {"name": "age", "min": 18, "max": 90, "distribution": "normal"},
+//               {"name": "country", "type": "string", "cardinality": 50, "distribution": "skewed"},
+//               {"name": "score", "type": "float", "null_rate": 0.1},
+//               {"name": "created", "type": "time", "from": "2016-01-01", "to": "2017-01-01"}
+//           ]}
+//       ]
+//   }
+//
+// Rows are generated as they are scanned, never stored, the values of a row
+// depend only on the seed, table and row number so every scan (and every
+// partition of it, scanned in parallel) of a table returns the same rows.
+type GenSource struct {
+	mu      sync.Mutex
+	seed    uint64
+	tables  map[string]*genTable
+	names   []string
+	metrics datasource.Metrics
+}
+
+// genTable a generated table, the seed of its rows
+type genTable struct {
+	spec *TableSpec
+	tbl  *schema.Table
+	seed uint64
+}
+
+// genConn the scan of the rows [row, end) of a generated table
+type genConn struct {
+	t        *genTable
+	row, end int64
+}
+
+// NewGenSource a GenSource of the settings of a source config
+func NewGenSource(settings u.JsonHelper) (*GenSource, error) {
+	m := &GenSource{}
+	if err := m.load(settings); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewGenSourceTables a GenSource of these tables
+func NewGenSourceTables(seed int64, tables ...*TableSpec) (*GenSource, error) {
+	m := &GenSource{}
+	if err := m.loadTables(uint64(seed), tables); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Setup generate the tables of the "tables" specs of the source config, of
+// its "seed", of a GenSource{} registered without settings
+func (m *GenSource) Setup(ss *schema.SchemaSource) error {
+	m.mu.Lock()
+	loaded := m.tables != nil
+	m.mu.Unlock()
+	return datasource.SetupSettings(ss, loaded, m.load)
+}
+
+func (m *GenSource) load(settings u.JsonHelper) error {
+	seed := settings.Int64("seed")
+	tables := make([]*TableSpec, 0)
+	if raw, ok := settings["tables"]; ok {
+		// re-decode the generic json of the settings into the specs
+		by, err := json.Marshal(raw)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(by, &tables); err != nil {
+			return fmt.Errorf("invalid datagen tables: %v", err)
+		}
+	}
+	return m.loadTables(uint64(seed), tables)
+}
+
+func (m *GenSource) loadTables(seed uint64, specs []*TableSpec) error {
+	if len(specs) == 0 {
+		return fmt.Errorf("datagen source requires tables in settings")
+	}
+	tables := make(map[string]*genTable, len(specs))
+	names := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		t, err := newGenTable(seed, spec)
+		if err != nil {
+			return err
+		}
+		tables[spec.Name] = t
+		names = append(names, spec.Name)
+	}
+	sort.Strings(names)
+	m.mu.Lock()
+	m.seed, m.tables, m.names = seed, tables, names
+	m.mu.Unlock()
+	return nil
+}
+
+func newGenTable(seed uint64, spec *TableSpec) (*genTable, error) {
+	if spec.Name == "" || len(spec.Columns) == 0 {
+		return nil, fmt.Errorf("datagen table requires a name and columns")
+	}
+	if spec.Rows < 0 {
+		return nil, fmt.Errorf("invalid rows %d of datagen table %q", spec.Rows, spec.Name)
+	}
+	tbl := schema.NewTable(spec.Name)
+	cols := make([]string, len(spec.Columns))
+	for i, col := range spec.Columns {
+		if err := col.load(spec); err != nil {
+			return nil, fmt.Errorf("column %q of datagen table %q: %v", col.Name, spec.Name, err)
+		}
+		typ := col.valueType()
+		tbl.AddField(schema.NewFieldBase(col.Name, typ, 255, typ.String()))
+		cols[i] = col.Name
+	}
+	tbl.SetColumns(cols)
+	h := fnv.New64a()
+	h.Write([]byte(spec.Name))
+	return &genTable{spec: spec, tbl: tbl, seed: seed ^ h.Sum64()}, nil
+}
+
+// load check the spec of the column, filling in the defaults
+func (m *ColumnSpec) load(t *TableSpec) error {
+	if m.Name == "" {
+		return fmt.Errorf("requires a name")
+	}
+	m.Type = strings.ToLower(m.Type)
+	m.Distribution = strings.ToLower(m.Distribution)
+	switch m.Type {
+	case "":
+		m.Type = "int"
+		fallthrough
+	case "int":
+		if m.Min == 0 && m.Max == 0 && t.Rows > 1 {
+			m.Max = float64(t.Rows - 1)
+		}
+	case "float":
+		if m.Min == 0 && m.Max == 0 {
+			m.Max = 1
+		}
+	case "time":
+		m.from, m.to = defaultFrom, defaultTo
+		for _, tt := range []struct {
+			s string
+			t *time.Time
+		}{{m.From, &m.from}, {m.To, &m.to}} {
+			if tt.s == "" {
+				continue
+			}
+			parsed, err := dateparse.ParseAny(tt.s)
+			if err != nil {
+				return fmt.Errorf("invalid time %q: %v", tt.s, err)
+			}
+			*tt.t = parsed
+		}
+		if m.to.Before(m.from) {
+			return fmt.Errorf("to %v is before from %v", m.to, m.from)
+		}
+	case "string", "bool":
+	default:
+		return fmt.Errorf("unknown type %q", m.Type)
+	}
+	if m.Max < m.Min {
+		return fmt.Errorf("max %v is less than min %v", m.Max, m.Min)
+	}
+	switch m.Distribution {
+	case "":
+		m.Distribution = "uniform"
+	case "uniform", "normal", "skewed", "sequence":
+	default:
+		return fmt.Errorf("unknown distribution %q", m.Distribution)
+	}
+	if m.Type == "string" && m.Cardinality == 0 {
+		m.Cardinality = int64(len(m.Values))
+	}
+	if m.Cardinality < 0 || m.NullRate < 0 || m.NullRate > 1 {
+		return fmt.Errorf("invalid cardinality %d or null_rate %v", m.Cardinality, m.NullRate)
+	}
+	return nil
+}
+
+func (m *ColumnSpec) valueType() value.ValueType {
+	switch m.Type {
+	case "float":
+		return value.NumberType
+	case "string":
+		return value.StringType
+	case "bool":
+		return value.BoolType
+	case "time":
+		return value.TimeType
+	}
+	return value.IntType
+}
+
+// generate the value of the column of row (of rows), of the random numbers
+// of r
+func (m *ColumnSpec) generate(row, rows int64, r *rowRand) driver.Value {
+	if m.NullRate > 0 && r.float() < m.NullRate {
+		return nil
+	}
+	// the point in [0, 1) of the range of the column
+	var f float64
+	switch m.Distribution {
+	case "sequence":
+		switch {
+		case m.Cardinality > 0:
+			f = float64(row%m.Cardinality) / float64(m.Cardinality)
+		case rows > 0:
+			f = float64(row) / float64(rows)
+		}
+		if m.Type == "int" && m.Cardinality == 0 {
+			return int64(m.Min) + row
+		}
+	case "normal":
+		// box-muller, 3 standard deviations either side of the middle
+		z := math.Sqrt(-2*math.Log(1-r.float())) * math.Cos(2*math.Pi*r.float())
+		f = math.Min(math.Max(0.5+z/6, 0), math.Nextafter(1, 0))
+	case "skewed":
+		f = math.Pow(r.float(), 4)
+	default:
+		f = r.float()
+	}
+	if m.Cardinality > 0 && m.Distribution != "sequence" {
+		f = math.Floor(f*float64(m.Cardinality)) / float64(m.Cardinality)
+	}
+
+	switch m.Type {
+	case "float":
+		return m.Min + f*(m.Max-m.Min)
+	case "string":
+		if m.Cardinality == 0 {
+			// unique per row
+			return m.Name + "_" + strconv.FormatInt(row, 10)
+		}
+		k := int64(f * float64(m.Cardinality))
+		if len(m.Values) > 0 {
+			return m.Values[k%int64(len(m.Values))]
+		}
+		return m.Name + "_" + strconv.FormatInt(k, 10)
+	case "bool":
+		return f >= 0.5
+	case "time":
+		return m.from.Add(time.Duration(f * float64(m.to.Sub(m.from))))
+	}
+	n := int64(m.Min + math.Floor(f*(m.Max-m.Min+1)))
+	if n > int64(m.Max) {
+		n = int64(m.Max)
+	}
+	return n
+}
+
+// values the generated values of row of the table
+func (m *genTable) values(row int64) []driver.Value {
+	vals := make([]driver.Value, len(m.spec.Columns))
+	for i, col := range m.spec.Columns {
+		r := rowRand{state: m.seed ^ uint64(row)*0x9e3779b97f4a7c15 ^ uint64(i+1)*0xbf58476d1ce4e5b9}
+		vals[i] = col.generate(row, m.spec.Rows, &r)
+	}
+	return vals
+}
+
+// rowRand splitmix64 random numbers, cheap enough to seed per value so the
+// values of a row don't depend on the rows before it
+type rowRand struct {
+	state uint64
+}
+
+func (m *rowRand) next() uint64 {
+	m.state += 0x9e3779b97f4a7c15
+	z := m.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// float a random number in [0, 1)
+func (m *rowRand) float() float64 {
+	return float64(m.next()>>11) / (1 << 53)
+}
+
+func (m *GenSource) Tables() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.names
+}
+
+func (m *GenSource) table(table string) (*genTable, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tables[table]
+	if !ok {
+		return nil, schema.ErrNotFound
+	}
+	return t, nil
+}
+
+// Table the schema of a generated table, of the types of its columns
+func (m *GenSource) Table(table string) (*schema.Table, error) {
+	t, err := m.table(table)
+	if err != nil {
+		return nil, err
+	}
+	return t.tbl, nil
+}
+
+// Open a scan of the rows of a generated table
+func (m *GenSource) Open(table string) (schema.Conn, error) {
+	t, err := m.table(table)
+	if err != nil {
+		return nil, err
+	}
+	return &genConn{t: t, end: t.spec.Rows}, nil
+}
+
+func (m *GenSource) Close() error { return nil }
+
+func (m *GenSource) Metrics() *datasource.Metrics { return &m.metrics }
+
+func (m *genConn) Columns() []string { return m.t.tbl.Columns() }
+
+func (m *genConn) Next() schema.Message {
+	if m.row >= m.end {
+		return nil
+	}
+	row := m.row
+	m.row++
+	return datasource.NewSqlDriverMessageMap(uint64(row), m.t.values(row), m.t.tbl.FieldPositions)
+}
+
+func (m *genConn) Close() error { return nil }
+
+// Partitions ranges of PartitionRows rows of the table, Left the first row
+// of the range and Right the first of the next
+func (m *genConn) Partitions() []*schema.Partition {
+	if PartitionRows <= 0 || m.t.spec.Rows <= PartitionRows {
+		return nil
+	}
+	parts := make([]*schema.Partition, 0, m.t.spec.Rows/PartitionRows+1)
+	for start := int64(0); start < m.t.spec.Rows; start += PartitionRows {
+		end := start + PartitionRows
+		if end > m.t.spec.Rows {
+			end = m.t.spec.Rows
+		}
+		parts = append(parts, &schema.Partition{
+			Id:    fmt.Sprintf("%s-%d", m.t.spec.Name, len(parts)),
+			Left:  strconv.FormatInt(start, 10),
+			Right: strconv.FormatInt(end, 10),
+		})
+	}
+	return parts
+}
+
+// PartitionSource a scan of the rows of the range of partition p
+func (m *genConn) PartitionSource(p *schema.Partition) (schema.Conn, error) {
+	start, err := strconv.ParseInt(p.Left, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid left %q of partition %s: %v", p.Left, p.Id, err)
+	}
+	end, err := strconv.ParseInt(p.Right, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid right %q of partition %s: %v", p.Right, p.Id, err)
+	}
+	if end > m.t.spec.Rows {
+		end = m.t.spec.Rows
+	}
+	return &genConn{t: m.t, row: start, end: end}, nil
+}
@@ -0,0 +1,150 @@
+package datagen_test
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	u "github.com/araddon/gou"
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/datagen"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/expr/builtins"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+func init() {
+	builtins.LoadAllBuiltins()
+}
+
+var settings = u.JsonHelper{
+	"seed": 42,
+	"tables": []interface{}{
+		map[string]interface{}{"name": "gen_users", "rows": 1000, "columns": []interface{}{
+			map[string]interface{}{"name": "user_id", "distribution": "sequence"},
+			map[string]interface{}{"name": "age", "min": 18, "max": 90, "distribution": "normal"},
+			map[string]interface{}{"name": "country", "type": "string", "values": []string{"us", "de", "jp"}, "distribution": "skewed"},
+			map[string]interface{}{"name": "score", "type": "float", "null_rate": 0.5},
+			map[string]interface{}{"name": "active", "type": "bool"},
+			map[string]interface{}{"name": "created", "type": "time", "from": "2016-05-01", "to": "2016-06-01"},
+		}},
+	},
+}
+
+func scan(t *testing.T, conn schema.Conn) [][]driver.Value {
+	rows := make([][]driver.Value, 0)
+	scanner := conn.(schema.ConnScanner)
+	for msg := scanner.Next(); msg != nil; msg = scanner.Next() {
+		rows = append(rows, msg.Body().(*datasource.SqlDriverMessageMap).Values())
+	}
+	return rows
+}
+
+func TestGenSource(t *testing.T) {
+	src, err := datagen.NewGenSource(settings)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"gen_users"}, src.Tables())
+	tbl, _ := src.Table("gen_users")
+	assert.Equal(t, []string{"user_id", "age", "country", "score", "active", "created"}, tbl.Columns())
+	_, err = src.Open("nope")
+	assert.Equal(t, schema.ErrNotFound, err)
+
+	conn, _ := src.Open("gen_users")
+	rows := scan(t, conn)
+	assert.Equal(t, 1000, len(rows))
+
+	// the same rows every scan, of the seed
+	conn, _ = src.Open("gen_users")
+	assert.Equal(t, rows, scan(t, conn))
+	other, _ := datagen.NewGenSource(u.JsonHelper{"seed": 7, "tables": settings["tables"]})
+	conn, _ = other.Open("gen_users")
+	assert.NotEqual(t, rows, scan(t, conn))
+
+	countries := make(map[driver.Value]int)
+	nulls, middle := 0, 0
+	from := time.Date(2016, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i, row := range rows {
+		assert.Equal(t, int64(i), row[0])
+		age := row[1].(int64)
+		assert.Tf(t, age >= 18 && age <= 90, "age in range %v", age)
+		if age >= 42 && age <= 66 {
+			middle++
+		}
+		countries[row[2]]++
+		if row[3] == nil {
+			nulls++
+		}
+		_, isBool := row[4].(bool)
+		assert.T(t, isBool)
+		created := row[5].(time.Time)
+		assert.Tf(t, !created.Before(from) && created.Before(from.AddDate(0, 1, 0)), "created in range %v", created)
+	}
+	assert.Tf(t, middle > 700, "normal ages around the middle %d", middle)
+	assert.Equal(t, 3, len(countries))
+	assert.Tf(t, countries["us"] > countries["jp"]*2, "skewed to the first value %v", countries)
+	assert.Tf(t, nulls > 400 && nulls < 600, "half null %d", nulls)
+
+	// partitions scan the same rows
+	defer func(rows int64) { datagen.PartitionRows = rows }(datagen.PartitionRows)
+	datagen.PartitionRows = 300
+	conn, _ = src.Open("gen_users")
+	parts := conn.(schema.SourcePartitionable).Partitions()
+	assert.Equal(t, 4, len(parts))
+	part, err := conn.(schema.SourcePartitionable).PartitionSource(parts[3])
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, rows[900:], scan(t, part))
+
+	_, err = datagen.NewGenSource(u.JsonHelper{"tables": []interface{}{
+		map[string]interface{}{"name": "bad", "rows": 1, "columns": []interface{}{
+			map[string]interface{}{"name": "x", "distribution": "bimodal"},
+		}},
+	}})
+	assert.T(t, err != nil)
+}
+
+func TestGenSourceQuery(t *testing.T) {
+	src, err := datagen.NewGenSourceTables(1, &datagen.TableSpec{Name: "gen_events", Rows: 10000, Columns: []*datagen.ColumnSpec{
+		{Name: "event_id", Distribution: "sequence"},
+		{Name: "kind", Type: "string", Cardinality: 4},
+	}})
+	assert.Tf(t, err == nil, "no error %v", err)
+	sch := datasource.RegisterSchemaSource("datagen", "datagen", src)
+
+	sql := `SELECT kind, count(*) AS ct FROM gen_events GROUP BY kind`
+	ctx := plan.NewContext(sql)
+	ctx.Schema = sch
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	cols := []string{"kind", "ct"}
+	out := exec.NewResultRows(ctx, cols)
+	job.RootTask.Add(out)
+	assert.T(t, job.Setup() == nil)
+	go job.Run()
+	total := int64(0)
+	kinds := 0
+	for {
+		dest := make([]driver.Value, len(cols))
+		if out.Next(dest) != nil {
+			break
+		}
+		kinds++
+		total += dest[1].(int64)
+	}
+	job.Close()
+	assert.Equal(t, 4, kinds)
+	assert.Equal(t, int64(10000), total)
+}
+
+func BenchmarkGenScan(b *testing.B) {
+	src, _ := datagen.NewGenSource(settings)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn, _ := src.Open("gen_users")
+		scanner := conn.(schema.ConnScanner)
+		for msg := scanner.Next(); msg != nil; msg = scanner.Next() {
+		}
+	}
+}
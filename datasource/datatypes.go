@@ -86,6 +86,8 @@ func (m *TimeValue) Scan(src interface{}) error {
 		} else {
 			return err
 		}
+	case time.Time:
+		*m = TimeValue(val)
 	case nil:
 		return nil
 	default:
@@ -0,0 +1,449 @@
+package files
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	u "github.com/araddon/gou"
+	"golang.org/x/net/context"
+)
+
+const (
+	// ChunkSize of the plaintext of the chunks of encrypted envelopes
+	ChunkSize = 64 * 1024
+
+	envelopeMagic = "qlbridge.enc/v1\n"
+	// sizes of the nonces of the data key and chunks, and gcm tags
+	keyNonceSize = 12
+	prefixSize   = 7
+	tagSize      = 16
+	dataKeySize  = 32
+)
+
+var (
+	_ KeyProvider = (StaticKeys)(nil)
+	_ KeyProvider = (*envKeys)(nil)
+	_ KeyProvider = (*fileKeys)(nil)
+
+	decryptMu sync.RWMutex
+	// encryptions known by the magic bytes their files start with, age has
+	// no decrypter in the standard library so must be registered
+	decrypters = []*decrypter{
+		{name: "aes-gcm", magic: []byte(envelopeMagic), open: openEnvelope},
+		{name: "age", magic: []byte("age-encryption.org/v1\n")},
+	}
+
+	keyProviderMu sync.RWMutex
+	keyProviders  = map[string]KeyProviderOpener{
+		"static": func(settings u.JsonHelper) (KeyProvider, error) {
+			keys := make(StaticKeys)
+			for id, key := range keysOf(settings) {
+				keys[id] = []byte(key)
+			}
+			return keys, nil
+		},
+		"env": func(settings u.JsonHelper) (KeyProvider, error) {
+			return &envKeys{vars: keysOf(settings)}, nil
+		},
+		"file": func(settings u.JsonHelper) (KeyProvider, error) {
+			return &fileKeys{paths: keysOf(settings)}, nil
+		},
+	}
+)
+
+// KeyProvider the keys of encrypted files by id, the id of the key of an
+// envelope is in its header so keys may be rotated.  Keys of the settings
+// are text, base64 for the AES keys of envelopes, an identity for age.
+type KeyProvider interface {
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// KeyProviderOpener open the KeyProvider of the "encryption" settings of a
+// source config
+type KeyProviderOpener func(settings u.JsonHelper) (KeyProvider, error)
+
+// Decrypter open a reader of the decrypted contents of r, of the keys of
+// keys
+type Decrypter func(ctx context.Context, r io.Reader, keys KeyProvider) (io.Reader, error)
+
+type decrypter struct {
+	name  string
+	magic []byte
+	open  Decrypter
+}
+
+// RegisterKeyProvider register the KeyProviderOpener of the "provider" of
+// the encryption settings, ie a kms.  "static" (the keys of the settings),
+// "env" (keys of environment variables) and "file" (keys of files) are
+// built in.
+func RegisterKeyProvider(name string, open KeyProviderOpener) {
+	keyProviderMu.Lock()
+	defer keyProviderMu.Unlock()
+	keyProviders[strings.ToLower(name)] = open
+}
+
+// RegisterDecrypter register the Decrypter for files starting with magic,
+// replacing any of the same name, ie
+//
+//   files.RegisterDecrypter("age", []byte("age-encryption.org/v1\n"),
+//       func(ctx context.Context, r io.Reader, keys files.KeyProvider) (io.Reader, error) {
+//           key, err := keys.Key(ctx, "age")
+//           if err != nil {
+//               return nil, err
+//           }
+//           ids, err := age.ParseIdentities(bytes.NewReader(key))
+//           if err != nil {
+//               return nil, err
+//           }
+//           return age.Decrypt(r, ids...)
+//       })
+func RegisterDecrypter(name string, magic []byte, open Decrypter) {
+	decryptMu.Lock()
+	defer decryptMu.Unlock()
+	for _, d := range decrypters {
+		if d.name == name {
+			d.magic, d.open = magic, open
+			return
+		}
+	}
+	decrypters = append(decrypters, &decrypter{name: name, magic: magic, open: open})
+}
+
+// encryption the encryption settings of a source
+//
+//   "encryption" : {
+//       "provider" : "env",                    // static, env, file or registered
+//       "keys"     : {"2016-05" : "LOGS_KEY"},  // of ids, their keys (or vars, paths)
+//       "required" : true                      // fail on files not encrypted
+//   }
+type encryption struct {
+	keys     KeyProvider
+	required bool
+}
+
+func encryptionOf(settings u.JsonHelper) (*encryption, error) {
+	enc := settings.Helper("encryption")
+	if enc == nil {
+		return nil, nil
+	}
+	name := strings.ToLower(enc.String("provider"))
+	if name == "" {
+		name = "static"
+	}
+	keyProviderMu.RLock()
+	open, ok := keyProviders[name]
+	keyProviderMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no key provider registered for %q, see files.RegisterKeyProvider", name)
+	}
+	keys, err := open(enc)
+	if err != nil {
+		return nil, err
+	}
+	m := &encryption{keys: keys}
+	m.required, _ = enc.BoolSafe("required")
+	return m, nil
+}
+
+// decrypt r if it starts with the magic bytes of a known encryption
+func (m *encryption) decrypt(ctx context.Context, r io.Reader) (io.Reader, error) {
+	buf := bufio.NewReader(r)
+	decryptMu.RLock()
+	defer decryptMu.RUnlock()
+	for _, d := range decrypters {
+		head, err := buf.Peek(len(d.magic))
+		if err != nil || !bytes.Equal(head, d.magic) {
+			continue
+		}
+		if d.open == nil {
+			return nil, fmt.Errorf("%s encrypted file but no decrypter registered, see files.RegisterDecrypter", d.name)
+		}
+		if m == nil {
+			return nil, fmt.Errorf("%s encrypted file but no encryption keys in settings", d.name)
+		}
+		return d.open(ctx, buf, m.keys)
+	}
+	if m != nil && m.required {
+		return nil, fmt.Errorf("file is not encrypted, encryption is required")
+	}
+	return buf, nil
+}
+
+// keysOf the "keys" of settings by id
+func keysOf(settings u.JsonHelper) map[string]string {
+	keys := make(map[string]string)
+	if kh := settings.Helper("keys"); kh != nil {
+		for id := range kh {
+			keys[id] = kh.String(id)
+		}
+	}
+	return keys
+}
+
+// StaticKeys a KeyProvider of keys by id
+type StaticKeys map[string][]byte
+
+func (m StaticKeys) Key(ctx context.Context, id string) ([]byte, error) {
+	if key, ok := m[id]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("no encryption key %q", id)
+}
+
+// envKeys keys of environment variables by id
+type envKeys struct {
+	vars map[string]string
+}
+
+func (m *envKeys) Key(ctx context.Context, id string) ([]byte, error) {
+	if name, ok := m.vars[id]; ok {
+		if key := os.Getenv(name); key != "" {
+			return []byte(key), nil
+		}
+		return nil, fmt.Errorf("no encryption key %q in $%s", id, name)
+	}
+	return nil, fmt.Errorf("no encryption key %q", id)
+}
+
+// fileKeys keys of files by id, read each time (so may be rotated)
+type fileKeys struct {
+	paths map[string]string
+}
+
+func (m *fileKeys) Key(ctx context.Context, id string) ([]byte, error) {
+	if path, ok := m.paths[id]; ok {
+		return ioutil.ReadFile(path)
+	}
+	return nil, fmt.Errorf("no encryption key %q", id)
+}
+
+// keyCipher the AES-GCM cipher of a key of a KeyProvider, base64 of 16, 24
+// or 32 bytes or else those bytes raw (base64 first, as that of 16 bytes
+// is 24 long)
+func keyCipher(key []byte) (cipher.AEAD, error) {
+	if raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(key))); err == nil && validKeySize(len(raw)) {
+		key = raw
+	} else if !validKeySize(len(key)) {
+		return nil, fmt.Errorf("encryption key must be 16, 24 or 32 bytes, or base64 of them")
+	}
+	return aesGCM(key)
+}
+
+func aesGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func validKeySize(n int) bool { return n == 16 || n == 24 || n == 32 }
+
+// header of an envelope, the data key of its contents encrypted with the
+// key of id
+//
+//   magic | len(id) | id | nonce | sealed data key | nonce prefix | chunks ..
+func envelopeHeader(id string) []byte {
+	return append(append([]byte(envelopeMagic), byte(len(id))), id...)
+}
+
+// chunkNonce the nonce of chunk n of an envelope, of the last chunk flagged
+// so a truncated envelope fails
+func chunkNonce(prefix []byte, n uint32, last bool) []byte {
+	nonce := make([]byte, prefixSize+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[prefixSize:], n)
+	if last {
+		nonce[prefixSize+4] = 1
+	}
+	return nonce
+}
+
+// envelopeWriter encrypt the chunks of the contents of an envelope
+type envelopeWriter struct {
+	w      io.Writer
+	gcm    cipher.AEAD
+	prefix []byte
+	n      uint32
+	buf    []byte
+	out    []byte
+	closed bool
+}
+
+// NewEncryptWriter a writer of an encrypted envelope (AES-GCM, of a data
+// key of each file sealed with the key of id of keys) of the contents
+// written to it, the envelope is finished on Close, w is not closed.
+// Envelopes are decrypted by file sources of the key in their settings.
+func NewEncryptWriter(ctx context.Context, w io.Writer, keys KeyProvider, id string) (io.WriteCloser, error) {
+	if len(id) > 255 {
+		return nil, fmt.Errorf("encryption key id %q longer than 255", id)
+	}
+	key, err := keys.Key(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := keyCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	random := make([]byte, keyNonceSize+dataKeySize+prefixSize)
+	if _, err := io.ReadFull(rand.Reader, random); err != nil {
+		return nil, err
+	}
+	nonce, dataKey, prefix := random[:keyNonceSize], random[keyNonceSize:keyNonceSize+dataKeySize], random[keyNonceSize+dataKeySize:]
+	header := envelopeHeader(id)
+	sealed := kek.Seal(nil, nonce, dataKey, header)
+	gcm, err := aesGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range [][]byte{header, nonce, sealed, prefix} {
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+	}
+	return &envelopeWriter{w: w, gcm: gcm, prefix: prefix, buf: make([]byte, 0, ChunkSize)}, nil
+}
+
+func (m *envelopeWriter) Write(p []byte) (int, error) {
+	if m.closed {
+		return 0, fmt.Errorf("write of closed envelope")
+	}
+	written := 0
+	for len(p) > 0 {
+		if len(m.buf) == ChunkSize {
+			// a chunk is only sealed once more follows, the last may be full
+			if err := m.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := ChunkSize - len(m.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		m.buf = append(m.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (m *envelopeWriter) seal(last bool) error {
+	m.out = m.gcm.Seal(m.out[:0], chunkNonce(m.prefix, m.n, last), m.buf, nil)
+	m.buf = m.buf[:0]
+	m.n++
+	_, err := m.w.Write(m.out)
+	return err
+}
+
+// Close seal the last chunk of the envelope
+func (m *envelopeWriter) Close() error {
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	return m.seal(true)
+}
+
+// envelopeReader decrypt the chunks of an envelope
+type envelopeReader struct {
+	r     *bufio.Reader
+	gcm   cipher.AEAD
+	pre   []byte
+	n     uint32
+	chunk []byte
+	buf   []byte
+	plain []byte // decrypted, not yet read
+	done  bool
+	err   error
+}
+
+// openEnvelope the Decrypter of envelopes (see NewEncryptWriter)
+func openEnvelope(ctx context.Context, r io.Reader, keys KeyProvider) (io.Reader, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	head := make([]byte, len(envelopeMagic)+1)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, fmt.Errorf("could not read envelope header: %v", err)
+	}
+	rest := make([]byte, int(head[len(head)-1])+keyNonceSize+dataKeySize+tagSize+prefixSize)
+	if _, err := io.ReadFull(br, rest); err != nil {
+		return nil, fmt.Errorf("could not read envelope header: %v", err)
+	}
+	id := string(rest[:head[len(head)-1]])
+	rest = rest[len(id):]
+	key, err := keys.Key(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := keyCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	sealed := rest[keyNonceSize : keyNonceSize+dataKeySize+tagSize]
+	dataKey, err := kek.Open(nil, rest[:keyNonceSize], sealed, envelopeHeader(id))
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt data key of envelope with key %q: %v", id, err)
+	}
+	gcm, err := aesGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &envelopeReader{r: br, gcm: gcm, pre: rest[len(rest)-prefixSize:],
+		chunk: make([]byte, ChunkSize+tagSize), buf: make([]byte, 0, ChunkSize)}, nil
+}
+
+func (m *envelopeReader) Read(p []byte) (int, error) {
+	for len(m.plain) == 0 {
+		if m.err != nil {
+			return 0, m.err
+		}
+		if m.done {
+			return 0, io.EOF
+		}
+		m.err = m.open()
+	}
+	n := copy(p, m.plain)
+	m.plain = m.plain[n:]
+	return n, nil
+}
+
+// open the next chunk, the last if short or at the end of the envelope
+func (m *envelopeReader) open() error {
+	n, err := io.ReadFull(m.r, m.chunk)
+	last := false
+	switch err {
+	case nil:
+		if _, err := m.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	case io.ErrUnexpectedEOF:
+		last = true
+	case io.EOF:
+		return fmt.Errorf("encrypted envelope truncated")
+	default:
+		return err
+	}
+	plain, err := m.gcm.Open(m.buf[:0], chunkNonce(m.pre, m.n, last), m.chunk[:n], nil)
+	if err != nil {
+		return fmt.Errorf("could not decrypt chunk %d of envelope, corrupt or truncated: %v", m.n, err)
+	}
+	m.plain, m.done = plain, last
+	m.n++
+	return nil
+}
@@ -0,0 +1,107 @@
+package files
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	formatMu sync.RWMutex
+	formats  = map[string]FileReader{
+		"csv":  csvReader,
+		"tsv":  tsvReader,
+		"json": jsonLinesReader,
+	}
+	// extensions of the file names of formats
+	formatExts = map[string]string{
+		"csv":    "csv",
+		"tsv":    "tsv",
+		"json":   "json",
+		"jsonl":  "json",
+		"ndjson": "json",
+	}
+	// extensions of compressed and encrypted files, read by the file readers
+	compressExts = map[string]bool{"gz": true, "zst": true, "enc": true, "age": true}
+)
+
+// FileScanner the rows of a file and its schema
+type FileScanner interface {
+	schema.ConnScanner
+	Table(table string) (*schema.Table, error)
+}
+
+// FileReader read the rows of a file in a format, with the settings of the
+// source config
+type FileReader func(table string, r io.Reader, settings u.JsonHelper) (FileScanner, error)
+
+// RegisterFormat register the FileReader of format, read for files of the
+// given extensions (ie "parquet") unless a format is set in the settings
+func RegisterFormat(format string, read FileReader, exts ...string) {
+	formatMu.Lock()
+	defer formatMu.Unlock()
+	formats[format] = read
+	for _, ext := range exts {
+		formatExts[strings.ToLower(ext)] = format
+	}
+}
+
+// formatOf the format of file name, from its extensions
+func formatOf(name string) string {
+	base := strings.ToLower(path.Base(name))
+	parts := strings.Split(base, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		if compressExts[parts[i]] {
+			continue
+		}
+		formatMu.RLock()
+		format := formatExts[parts[i]]
+		formatMu.RUnlock()
+		return format
+	}
+	return ""
+}
+
+// fileReader the reader of format
+func fileReader(format string) (FileReader, error) {
+	formatMu.RLock()
+	defer formatMu.RUnlock()
+	if read, ok := formats[format]; ok {
+		return read, nil
+	}
+	return nil, fmt.Errorf("unknown file format %q", format)
+}
+
+func csvReader(table string, r io.Reader, settings u.JsonHelper) (FileScanner, error) {
+	opts, err := datasource.CsvOptionsFromSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+	return datasource.NewCsvSourceOptions(table, 0, r, make(<-chan bool, 1), opts)
+}
+
+func tsvReader(table string, r io.Reader, settings u.JsonHelper) (FileScanner, error) {
+	opts, err := datasource.CsvOptionsFromSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Delimiter == 0 {
+		opts.Delimiter = '\t'
+	}
+	return datasource.NewCsvSourceOptions(table, 0, r, make(<-chan bool, 1), opts)
+}
+
+func jsonLinesReader(table string, r io.Reader, settings u.JsonHelper) (FileScanner, error) {
+	opts, err := datasource.JsonOptionsFromSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+	return datasource.NewJsonLinesSourceOptions(table, r, make(<-chan bool, 1), opts)
+}
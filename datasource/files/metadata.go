@@ -0,0 +1,192 @@
+package files
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/expr/translate"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+	"github.com/araddon/qlbridge/vm"
+)
+
+const (
+	// the columns of the file of each row, added to the columns of each
+	// table unless "file_columns" is false in the settings
+	FileColumn     = "_file"     // name of the file in the store
+	ModifiedColumn = "_modified" // when the file was last modified
+	SizeColumn     = "_size"     // of the file in bytes
+)
+
+var (
+	_ translate.Translator = (*fileConn)(nil)
+
+	// FileColumns the columns of the file of each row, in order
+	FileColumns = []string{FileColumn, ModifiedColumn, SizeColumn}
+)
+
+// fileFilter the conjuncts of a where of only the file columns, files they
+// are false for are not read
+type fileFilter struct {
+	nodes []expr.Node
+}
+
+// addFileColumns add the file columns to the schema of a table
+func addFileColumns(tbl *schema.Table) {
+	cols := tbl.Columns()
+	for i, col := range FileColumns {
+		typ := []value.ValueType{value.StringType, value.TimeType, value.IntType}[i]
+		tbl.AddField(schema.NewFieldBase(col, typ, 255, typ.String()))
+	}
+	tbl.SetColumns(append(cols[:len(cols):len(cols)], FileColumns...))
+}
+
+// withFileColumns the row of msg of the values of the file columns of the
+// file being read
+func (m *fileConn) withFileColumns(msg schema.Message) schema.Message {
+	dm, ok := msg.(*datasource.SqlDriverMessageMap)
+	if !ok {
+		return msg
+	}
+	tbl, err := m.src.Table(m.table)
+	if err != nil {
+		return msg
+	}
+	cols := tbl.Columns()
+	vals := make([]driver.Value, len(cols))
+	n := len(cols) - len(FileColumns)
+	data := dm.Values()
+	if len(data) > n {
+		data = data[:n]
+	}
+	copy(vals, data)
+	vals[n], vals[n+1], vals[n+2] = m.obj.Name, m.obj.Updated, m.obj.Size
+	return datasource.NewSqlDriverMessageMap(dm.IdVal, vals, tbl.FieldPositions)
+}
+
+// Translate the conjuncts of the where of only the file columns into the
+// filter of the files read, so files of other paths (ie dates) are pruned
+// without reading them.  Also prunes the partitions of this scan.  The
+// where is still evaluated on the rows.
+func (m *fileConn) Translate(node expr.Node) (interface{}, error) {
+	if !m.src.fileColumns {
+		return nil, fmt.Errorf("no file columns of %q", m.table)
+	}
+	f := &fileFilter{}
+	f.conjuncts(node)
+	if len(f.nodes) == 0 {
+		return nil, fmt.Errorf("no file column filters in %s", node)
+	}
+	m.filter = f
+	return f, nil
+}
+
+// SetNative the file filter pushed down by the planner (see Translate)
+func (m *fileConn) SetNative(native interface{}) {
+	if f, ok := native.(*fileFilter); ok {
+		m.filter = f
+	}
+}
+
+// conjuncts add the parts of node AND-ed together of only file columns
+func (m *fileFilter) conjuncts(node expr.Node) {
+	if bn, ok := node.(*expr.BinaryNode); ok && bn.Operator.T == lex.TokenLogicAnd {
+		m.conjuncts(bn.Args[0])
+		m.conjuncts(bn.Args[1])
+		return
+	}
+	idents := expr.FindAllIdentityField(node)
+	if len(idents) == 0 {
+		return
+	}
+	for _, ident := range idents {
+		if !isFileColumn(ident) {
+			return
+		}
+	}
+	m.nodes = append(m.nodes, node)
+}
+
+// matches may obj have rows the filter is true for
+func (m *fileFilter) matches(obj Object) bool {
+	if m == nil {
+		return true
+	}
+	ctx := datasource.NewContextSimpleNative(map[string]interface{}{
+		FileColumn:     obj.Name,
+		ModifiedColumn: obj.Updated,
+		SizeColumn:     obj.Size,
+	})
+	for _, node := range m.nodes {
+		v, ok := vm.Eval(ctx, node)
+		if !ok {
+			// can't tell, read it
+			continue
+		}
+		if bv, isBool := v.(value.BoolValue); isBool && !bv.Val() {
+			return false
+		}
+	}
+	return true
+}
+
+// matching the objects the filter may be true for
+func (m *fileFilter) matching(objs []Object) []Object {
+	if m == nil {
+		return objs
+	}
+	matched := make([]Object, 0, len(objs))
+	for _, obj := range objs {
+		if m.matches(obj) {
+			matched = append(matched, obj)
+		}
+	}
+	return matched
+}
+
+func isFileColumn(name string) bool {
+	for _, col := range FileColumns {
+		if name == col {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob does name match the glob of path.Match patterns, where a **
+// segment matches any number of directories
+//
+//   logs/**/*.csv   logs/a.csv, logs/2016/05/a.csv
+func matchGlob(glob, name string) bool {
+	if !strings.Contains(glob, "**") {
+		ok, _ := path.Match(glob, name)
+		return ok
+	}
+	return matchSegments(strings.Split(glob, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(globs, names []string) bool {
+	for len(globs) > 0 {
+		if globs[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				if matchSegments(globs[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(globs[0], names[0]); !ok {
+			return false
+		}
+		globs, names = globs[1:], names[1:]
+	}
+	return len(names) == 0
+}
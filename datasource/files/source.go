@@ -0,0 +1,415 @@
+package files
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	u "github.com/araddon/gou"
+	"golang.org/x/net/context"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	// Different Features of this File Data Source
+	_ schema.Source              = (*FileSource)(nil)
+	_ datasource.MeteredSource   = (*FileSource)(nil)
+	_ schema.SourceSetup         = (*FileSource)(nil)
+	_ schema.SourceTableSchema   = (*FileSource)(nil)
+	_ schema.ConnScanner         = (*fileConn)(nil)
+	_ schema.ConnColumns         = (*fileConn)(nil)
+	_ schema.IteratorErr         = (*fileConn)(nil)
+	_ schema.SourcePartitionable = (*fileConn)(nil)
+)
+
+// FileSource a DataSource of the files of an object store, each table the
+// files under a prefix of a bucket.  The files of a table are its
+// partitions, scanned in parallel by partitioned scans, and read by the
+// reader of their format (csv, tsv, json-lines, or registered with
+// RegisterFormat), the schema of a table is that of its first file.
+// Created with NewFileSource, or registered as a type whose source config
+// settings are read on Setup:
+//
+//   datasource.Register("files", &files.FileSource{})
+//
+//   "sources" : [{
+//       "name" : "logs",
+//       "type" : "files",
+//       "settings" : {
+//           "path"   : "s3://my-bucket/logs/",   // or gs://, az://, a local dir
+//                                               // (cloud stores see RegisterStore)
+//           "format" : "csv",                   // else from file extensions
+//           "tables" : {
+//               "clicks" : "clicks/**/*.csv.gz",
+//               "orders" : {"glob" : "orders/*.json", "explode" : ["items"]}
+//           },
+//           "access_key" : "...",               // credentials, for the Store
+//           "encryption" : {"provider" : "env", "keys" : {"k1" : "LOGS_KEY"}}
+//       }
+//   }]
+//
+// Tables are a glob, or a conf of a "glob" and settings of the table over
+// those of the source, ie the format and its options (see
+// datasource.JsonOptionsFromSettings, datasource.CsvOptionsFromSettings).
+// A file matched by the globs of several tables is of the first of them by
+// table name.
+// Without "tables" each directory directly under the prefix is a table, as
+// is each file there (named for the file, without extensions).  Table globs
+// are those of path.Match, and ** for any number of directories.
+//
+// Files encrypted (envelopes of NewEncryptWriter, or of a registered
+// Decrypter ie age) are decrypted as read with the keys of the encryption
+// settings, which may require all files be encrypted (see encryption).
+//
+// Rows have the columns of the file they were read from (see FileColumns)
+// unless "file_columns" is false, a where of them prunes the files read
+//
+//   SELECT * FROM clicks WHERE _file LIKE "clicks/2016-05-%" AND _size > 0
+type FileSource struct {
+	mu          sync.Mutex
+	store       Store
+	prefix      string
+	settings    u.JsonHelper
+	tableConf   map[string]u.JsonHelper // settings of tables of their own
+	fileColumns bool                    // add the file columns to the rows
+	encryption  *encryption
+	names       []string
+	tables      map[string][]Object
+	schemas     map[string]*schema.Table
+	metrics     datasource.Metrics
+}
+
+// fileConn the scan of the files of a table, one after another
+type fileConn struct {
+	src     *FileSource
+	table   string
+	objects []Object
+	obj     Object // being read
+	filter  *fileFilter
+	cur     FileScanner
+	rc      io.ReadCloser
+	err     error
+}
+
+// NewFileSource a FileSource of the settings of a source config, which must
+// have its "path"
+func NewFileSource(settings u.JsonHelper) (*FileSource, error) {
+	m := &FileSource{}
+	if err := m.load(settings); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Setup open the store of the "path" setting of the source config, and
+// the files of its "tables" settings, of a FileSource{} registered without
+// settings
+func (m *FileSource) Setup(ss *schema.SchemaSource) error {
+	m.mu.Lock()
+	loaded := m.store != nil
+	m.mu.Unlock()
+	return datasource.SetupSettings(ss, loaded, m.load)
+}
+
+// load the files of the store of settings by table
+func (m *FileSource) load(settings u.JsonHelper) error {
+	p := settings.String("path")
+	if p == "" {
+		return fmt.Errorf("files source requires a path setting")
+	}
+	store, prefix, err := OpenStore(p, settings)
+	if err != nil {
+		return err
+	}
+	list, err := store.List(context.Background(), prefix)
+	if err != nil {
+		return fmt.Errorf("could not list %q: %v", p, err)
+	}
+	sort.Sort(objects(list))
+	globs := make(globTables, 0)
+	tableSettings := make(map[string]u.JsonHelper)
+	if tables := settings.Helper("tables"); tables != nil {
+		for name := range tables {
+			gt := globTable{table: strings.ToLower(name), glob: tables.String(name)}
+			if th := tables.Helper(name); th != nil {
+				gt.glob = th.String("glob")
+				tableSettings[gt.table] = tableSettingsOf(settings, th)
+			}
+			if gt.glob == "" {
+				return fmt.Errorf("table %q of files source requires a glob", name)
+			}
+			globs = append(globs, gt)
+		}
+		sort.Sort(globs)
+	}
+	enc, err := encryptionOf(settings)
+	if err != nil {
+		return err
+	}
+	tables := make(map[string][]Object)
+	for _, obj := range list {
+		rel := strings.TrimLeft(strings.TrimPrefix(obj.Name, prefix), "/")
+		if table := tableOf(rel, globs); table != "" {
+			tables[table] = append(tables[table], obj)
+		}
+	}
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store, m.prefix, m.settings = store, prefix, settings
+	m.tableConf = tableSettings
+	m.fileColumns = true
+	if fileColumns, ok := settings.BoolSafe("file_columns"); ok {
+		m.fileColumns = fileColumns
+	}
+	m.encryption = enc
+	m.names, m.tables = names, tables
+	m.schemas = make(map[string]*schema.Table)
+	return nil
+}
+
+// tableSettingsOf the settings of a table of the source settings, those of
+// the table conf over the others
+func tableSettingsOf(settings, conf u.JsonHelper) u.JsonHelper {
+	merged := make(u.JsonHelper, len(settings)+len(conf))
+	for k, v := range settings {
+		if k != "tables" {
+			merged[k] = v
+		}
+	}
+	for k, v := range conf {
+		if k != "glob" {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// settingsOf the settings of the files of table
+func (m *FileSource) settingsOf(table string) u.JsonHelper {
+	if settings, ok := m.tableConf[table]; ok {
+		return settings
+	}
+	return m.settings
+}
+
+// globTable the glob of the files of a table
+type globTable struct {
+	table, glob string
+}
+
+// globTables sorted by table name
+type globTables []globTable
+
+func (m globTables) Len() int           { return len(m) }
+func (m globTables) Less(i, j int) bool { return m[i].table < m[j].table }
+func (m globTables) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+
+// tableOf the table of the file of name (relative to the prefix), the first
+// by table name of those whose glob it matches, empty if none
+func tableOf(name string, globs globTables) string {
+	if len(globs) > 0 {
+		for _, gt := range globs {
+			if matchGlob(gt.glob, name) {
+				return gt.table
+			}
+		}
+		return ""
+	}
+	if i := strings.Index(name, "/"); i > 0 {
+		return strings.ToLower(name[:i])
+	}
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return strings.ToLower(name)
+}
+
+func (m *FileSource) Tables() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.names
+}
+
+// Table the schema of table, read from the first of its files
+func (m *FileSource) Table(table string) (*schema.Table, error) {
+	table = strings.ToLower(table)
+	m.mu.Lock()
+	tbl, ok := m.schemas[table]
+	objs := m.tables[table]
+	m.mu.Unlock()
+	if ok {
+		return tbl, nil
+	}
+	if len(objs) == 0 {
+		return nil, schema.ErrNotFound
+	}
+	fs, rc, err := m.openFile(table, objs[0])
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	if tbl, err = fs.Table(table); err != nil {
+		return nil, err
+	}
+	if m.fileColumns {
+		addFileColumns(tbl)
+	}
+	m.mu.Lock()
+	m.schemas[table] = tbl
+	m.mu.Unlock()
+	return tbl, nil
+}
+
+// Open a scan of the files of table
+func (m *FileSource) Open(table string) (schema.Conn, error) {
+	table = strings.ToLower(table)
+	m.mu.Lock()
+	objs, ok := m.tables[table]
+	m.mu.Unlock()
+	if !ok {
+		return nil, schema.ErrNotFound
+	}
+	return &fileConn{src: m, table: table, objects: objs}, nil
+}
+
+func (m *FileSource) Close() error { return nil }
+
+func (m *FileSource) Metrics() *datasource.Metrics { return &m.metrics }
+
+func (m *FileSource) objectsOf(table string) []Object {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tables[table]
+}
+
+// openFile a reader of the rows of obj
+func (m *FileSource) openFile(table string, obj Object) (FileScanner, io.ReadCloser, error) {
+	settings := m.settingsOf(table)
+	format := strings.ToLower(settings.String("format"))
+	if format == "" {
+		format = formatOf(obj.Name)
+	}
+	read, err := fileReader(format)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read %q: %v", obj.Name, err)
+	}
+	f, err := m.store.Open(context.Background(), obj.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	rc := &countedFile{CountingReader: datasource.CountingReader{Reader: f}, rc: f, table: table, metrics: &m.metrics}
+	r, err := m.encryption.decrypt(context.Background(), rc)
+	if err != nil {
+		rc.Close()
+		return nil, nil, fmt.Errorf("could not read %q: %v", obj.Name, err)
+	}
+	fs, err := read(table, r, settings)
+	if err != nil {
+		rc.Close()
+		return nil, nil, fmt.Errorf("could not read %q: %v", obj.Name, err)
+	}
+	return fs, rc, nil
+}
+
+// countedFile a file of the store, its bytes read recorded in the metrics of
+// the source once closed
+type countedFile struct {
+	datasource.CountingReader
+	rc      io.ReadCloser
+	table   string
+	metrics *datasource.Metrics
+}
+
+func (m *countedFile) Close() error {
+	m.metrics.Read(m.table, 0, m.N)
+	return m.rc.Close()
+}
+
+func (m *fileConn) Next() schema.Message {
+	for m.err == nil {
+		if m.cur == nil {
+			if len(m.objects) == 0 {
+				return nil
+			}
+			obj := m.objects[0]
+			m.objects = m.objects[1:]
+			if !m.filter.matches(obj) {
+				continue
+			}
+			m.obj = obj
+			m.cur, m.rc, m.err = m.src.openFile(m.table, obj)
+			continue
+		}
+		if msg := m.cur.Next(); msg != nil {
+			if m.src.fileColumns {
+				return m.withFileColumns(msg)
+			}
+			return msg
+		}
+		if ie, ok := m.cur.(schema.IteratorErr); ok {
+			m.err = ie.Err()
+		}
+		m.closeFile()
+	}
+	return nil
+}
+
+// Columns of the table, those of its first file
+func (m *fileConn) Columns() []string {
+	tbl, err := m.src.Table(m.table)
+	if err != nil {
+		u.Warnf("could not read columns of %q: %v", m.table, err)
+		return nil
+	}
+	return tbl.Columns()
+}
+
+// Err the error reading the files, nil if all were read
+func (m *fileConn) Err() error { return m.err }
+
+// Partitions a partition per file of the table, of the files not pruned by
+// the where (see Translate)
+func (m *fileConn) Partitions() []*schema.Partition {
+	objs := m.filter.matching(m.src.objectsOf(m.table))
+	parts := make([]*schema.Partition, len(objs))
+	for i, obj := range objs {
+		parts[i] = &schema.Partition{Id: obj.Name, Left: obj.Name, Right: obj.Name}
+	}
+	return parts
+}
+
+// PartitionSource a scan of the file of partition p
+func (m *fileConn) PartitionSource(p *schema.Partition) (schema.Conn, error) {
+	for _, obj := range m.src.objectsOf(m.table) {
+		if obj.Name == p.Id {
+			return &fileConn{src: m.src, table: m.table, objects: []Object{obj}}, nil
+		}
+	}
+	return nil, fmt.Errorf("no file %q in table %q", p.Id, m.table)
+}
+
+func (m *fileConn) closeFile() {
+	if m.cur != nil {
+		m.cur.Close()
+		m.cur = nil
+	}
+	if m.rc != nil {
+		m.rc.Close()
+		m.rc = nil
+	}
+}
+
+func (m *fileConn) Close() error {
+	m.closeFile()
+	return nil
+}
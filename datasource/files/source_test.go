@@ -0,0 +1,336 @@
+package files_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql/driver"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	u "github.com/araddon/gou"
+	"github.com/bmizerany/assert"
+	"golang.org/x/net/context"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/files"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// memStore a bucket of objects in memory, as a cloud store would be
+type memStore map[string]string
+
+func (m memStore) List(ctx context.Context, prefix string) ([]files.Object, error) {
+	list := make([]files.Object, 0)
+	for name, data := range m {
+		if strings.HasPrefix(name, prefix) {
+			list = append(list, files.Object{Name: name, Size: int64(len(data))})
+		}
+	}
+	return list, nil
+}
+func (m memStore) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader(m[name])), nil
+}
+
+func gzipped(data string) string {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(data))
+	w.Close()
+	return buf.String()
+}
+
+func scan(t *testing.T, conn schema.Conn) []string {
+	rows := make([]string, 0)
+	scanner := conn.(schema.ConnScanner)
+	for msg := scanner.Next(); msg != nil; msg = scanner.Next() {
+		dm := msg.(*datasource.SqlDriverMessageMap)
+		vals := dm.Values()
+		if _, hasFile := dm.ColIndex[files.FileColumn]; hasFile {
+			// of the file columns, see TestFileColumns
+			vals = vals[:len(vals)-len(files.FileColumns)]
+		}
+		rows = append(rows, fmt.Sprintf("%v", vals))
+	}
+	assert.Tf(t, scanner.(schema.IteratorErr).Err() == nil, "no error %v", scanner.(schema.IteratorErr).Err())
+	sort.Strings(rows)
+	return rows
+}
+
+func TestFileSource(t *testing.T) {
+	bucket := memStore{
+		"logs/clicks/2016-01.csv":    "id,url\n1,/a\n2,/b\n",
+		"logs/clicks/2016-02.csv.gz": gzipped("id,url\n3,/c\n"),
+		"logs/users.json":            `{"id":1,"name":"aaron"}` + "\n",
+		"other/x.csv":                "id\n1\n",
+		"orders/1":                   `{"id":1,"items":[{"sku":"a"},{"sku":"b"}]}` + "\n",
+	}
+	var creds u.JsonHelper
+	files.RegisterStore("mem", func(name string, settings u.JsonHelper) (files.Store, error) {
+		creds = settings
+		return bucket, nil
+	})
+
+	src, err := files.NewFileSource(u.JsonHelper{"path": "mem://bucket/logs/", "secret": "abc"})
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, "abc", creds.String("secret"))
+	assert.Equal(t, []string{"clicks", "users"}, src.Tables())
+
+	tbl, err := src.Table("clicks")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"id", "url", "_file", "_modified", "_size"}, tbl.Columns())
+	tbl, err = src.Table("users")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"id", "name", "_file", "_modified", "_size"}, tbl.Columns())
+
+	// the files of a table are scanned one after another, or as partitions
+	conn, err := src.Open("clicks")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"[1 /a]", "[2 /b]", "[3 /c]"}, scan(t, conn))
+	parts := conn.(schema.SourcePartitionable).Partitions()
+	assert.Equal(t, 2, len(parts))
+	part, err := conn.(schema.SourcePartitionable).PartitionSource(parts[1])
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"[3 /c]"}, scan(t, part))
+
+	// tables of globs, and the format set for all files but those of tables
+	// of their own settings
+	src, err = files.NewFileSource(u.JsonHelper{"path": "mem://bucket/", "format": "csv",
+		"tables": map[string]interface{}{"early": "logs/clicks/*-01.csv", "other": "other/*",
+			"orders": map[string]interface{}{"glob": "orders/*", "format": "json", "explode": "items"}}})
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"early", "orders", "other"}, src.Tables())
+	conn, _ = src.Open("early")
+	assert.Equal(t, []string{"[1 /a]", "[2 /b]"}, scan(t, conn))
+	conn, _ = src.Open("orders")
+	assert.Equal(t, []string{"[1 a]", "[1 b]"}, scan(t, conn))
+
+	// a file matched by the globs of several tables is of the first by name
+	for i := 0; i < 10; i++ {
+		src, err = files.NewFileSource(u.JsonHelper{"path": "mem://bucket/logs/",
+			"tables": map[string]interface{}{"b": "clicks/*", "a": "clicks/*.csv", "c": "*"}})
+		assert.Tf(t, err == nil, "no error %v", err)
+		assert.Equal(t, []string{"a", "b", "c"}, src.Tables())
+		conn, _ = src.Open("a")
+		assert.Equal(t, []string{"[1 /a]", "[2 /b]"}, scan(t, conn))
+		conn, _ = src.Open("b")
+		assert.Equal(t, []string{"[3 /c]"}, scan(t, conn))
+	}
+
+	_, err = files.NewFileSource(u.JsonHelper{"path": "mem://bucket/",
+		"tables": map[string]interface{}{"orders": map[string]interface{}{"format": "json"}}})
+	assert.T(t, err != nil)
+
+	_, err = files.NewFileSource(u.JsonHelper{"path": "gs://bucket/logs/"})
+	assert.T(t, err != nil)
+}
+
+func TestLocalStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qlbridge-files")
+	assert.T(t, err == nil)
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "events"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "events", "a.tsv"), []byte("id\tkind\n1\topen\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "events", "b.jsonl"), []byte(`{"id":2,"kind":"close"}`+"\n"), 0644)
+
+	src, err := files.NewFileSource(u.JsonHelper{"path": dir})
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"events"}, src.Tables())
+	conn, err := src.Open("events")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"[1 open]", "[2 close]"}, scan(t, conn))
+}
+
+// openedStore a memStore recording the files opened
+type openedStore struct {
+	memStore
+	mu     sync.Mutex
+	opened []string
+}
+
+func (m *openedStore) List(ctx context.Context, prefix string) ([]files.Object, error) {
+	list, _ := m.memStore.List(ctx, prefix)
+	for i := range list {
+		list[i].Updated = time.Date(2016, 5, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return list, nil
+}
+func (m *openedStore) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	m.opened = append(m.opened, name)
+	m.mu.Unlock()
+	return m.memStore.Open(ctx, name)
+}
+
+func TestFileColumns(t *testing.T) {
+	bucket := &openedStore{memStore: memStore{
+		"logs/2016/04/30/a.csv": "id,url\n1,/a\n",
+		"logs/2016/05/01/b.csv": "id,url\n2,/b\n3,/c\n",
+		"logs/2016/05/02/c.csv": "id,url\n4,/d\n",
+		"logs/c.csv":            "id,url\n5,/e\n",
+		"logs/readme.txt":       "not a table",
+	}}
+	files.RegisterStore("opened", func(name string, settings u.JsonHelper) (files.Store, error) {
+		return bucket, nil
+	})
+	src, err := files.NewFileSource(u.JsonHelper{"path": "opened://bucket/",
+		"tables": map[string]interface{}{"clicks": "logs/**/*.csv"}})
+	assert.Tf(t, err == nil, "no error %v", err)
+	sch := datasource.RegisterSchemaSource("file_columns", "file_columns", src)
+
+	tests := []struct {
+		sql    string
+		rows   [][]driver.Value
+		opened []string
+	}{
+		{`SELECT id, _file, _size FROM clicks WHERE _file LIKE "logs/2016/05/%/%" AND id != 3`, [][]driver.Value{
+			{int64(2), "logs/2016/05/01/b.csv", int64(17)},
+			{int64(4), "logs/2016/05/02/c.csv", int64(12)},
+		}, []string{"logs/2016/05/01/b.csv", "logs/2016/05/02/c.csv"}},
+		{`SELECT id, _file, _size FROM clicks WHERE _file = "logs/c.csv"`, [][]driver.Value{
+			{int64(5), "logs/c.csv", int64(12)},
+		}, []string{"logs/c.csv"}},
+		// the where of the rows, all files are read
+		{`SELECT id, _file, _size FROM clicks WHERE id = 1`, [][]driver.Value{
+			{int64(1), "logs/2016/04/30/a.csv", int64(12)},
+		}, []string{"logs/2016/04/30/a.csv", "logs/2016/05/01/b.csv", "logs/2016/05/02/c.csv", "logs/c.csv"}},
+	}
+	for _, tt := range tests {
+		bucket.mu.Lock()
+		bucket.opened = nil
+		bucket.mu.Unlock()
+
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema = sch
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		cols := []string{"id", "_file", "_size"}
+		out := exec.NewResultRows(ctx, cols)
+		job.RootTask.Add(out)
+		assert.T(t, job.Setup() == nil)
+		go job.Run()
+		rows := make([][]driver.Value, 0)
+		for {
+			dest := make([]driver.Value, len(cols))
+			if out.Next(dest) != nil {
+				break
+			}
+			rows = append(rows, dest)
+		}
+		job.Close()
+		sort.Slice(rows, func(i, j int) bool { return rows[i][0].(int64) < rows[j][0].(int64) })
+		assert.Equalf(t, tt.rows, rows, "rows of %s", tt.sql)
+		bucket.mu.Lock()
+		sort.Strings(bucket.opened)
+		assert.Equalf(t, tt.opened, bucket.opened, "files opened by %s", tt.sql)
+		bucket.mu.Unlock()
+	}
+
+	// without the file columns
+	src, err = files.NewFileSource(u.JsonHelper{"path": "opened://bucket/", "file_columns": false,
+		"tables": map[string]interface{}{"clicks": "logs/**/*.csv"}})
+	assert.Tf(t, err == nil, "no error %v", err)
+	tbl, _ := src.Table("clicks")
+	assert.Equal(t, []string{"id", "url"}, tbl.Columns())
+}
+
+func encrypted(t *testing.T, keys files.KeyProvider, id, data string) string {
+	var buf bytes.Buffer
+	w, err := files.NewEncryptWriter(context.Background(), &buf, keys, id)
+	assert.Tf(t, err == nil, "no error %v", err)
+	w.Write([]byte(data))
+	assert.T(t, w.Close() == nil)
+	return buf.String()
+}
+
+func TestEncryptedFiles(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	keys := files.StaticKeys{"k1": []byte(key), "k2": bytes.Repeat([]byte{9}, 16)}
+	// of more than a chunk
+	big := "id,url\n" + strings.Repeat("1,/a\n", files.ChunkSize/5+10)
+	bucket := memStore{
+		"secure/clicks/a.csv.enc":    encrypted(t, keys, "k1", "id,url\n1,/a\n2,/b\n"),
+		"secure/clicks/b.csv.gz.enc": encrypted(t, keys, "k2", gzipped("id,url\n3,/c\n")),
+		"secure/empty/a.csv.enc":     encrypted(t, keys, "k1", ""),
+		"secure/big/a.csv.enc":       encrypted(t, keys, "k1", big),
+		"plain/clicks/a.csv":         "id,url\n1,/a\n",
+		"aged/clicks/a.csv.age":      "age-encryption.org/v1\n-> X25519 ...",
+	}
+	files.RegisterStore("secure", func(name string, settings u.JsonHelper) (files.Store, error) {
+		return bucket, nil
+	})
+	os.Setenv("QLBRIDGE_TEST_KEY", key)
+	defer os.Unsetenv("QLBRIDGE_TEST_KEY")
+	settings := func(path string, encryption map[string]interface{}) u.JsonHelper {
+		return u.JsonHelper{"path": "secure://bucket/" + path, "file_columns": false,
+			"encryption": encryption}
+	}
+	static := map[string]interface{}{"required": true,
+		"keys": map[string]interface{}{"k1": key, "k2": base64.StdEncoding.EncodeToString(keys["k2"])}}
+
+	src, err := files.NewFileSource(settings("secure/", static))
+	assert.Tf(t, err == nil, "no error %v", err)
+	tbl, err := src.Table("clicks")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"id", "url"}, tbl.Columns())
+	conn, _ := src.Open("clicks")
+	assert.Equal(t, []string{"[1 /a]", "[2 /b]", "[3 /c]"}, scan(t, conn))
+	conn, _ = src.Open("big")
+	n := 0
+	for msg := conn.(schema.ConnScanner).Next(); msg != nil; msg = conn.(schema.ConnScanner).Next() {
+		n++
+	}
+	assert.Tf(t, conn.(schema.IteratorErr).Err() == nil, "no error %v", conn.(schema.IteratorErr).Err())
+	assert.Equal(t, files.ChunkSize/5+10, n)
+
+	// keys of the environment
+	src, err = files.NewFileSource(settings("secure/clicks/", map[string]interface{}{"provider": "env",
+		"keys": map[string]interface{}{"k1": "QLBRIDGE_TEST_KEY"}}))
+	assert.Tf(t, err == nil, "no error %v", err)
+	conn, _ = src.Open("a")
+	assert.Equal(t, []string{"[1 /a]", "[2 /b]"}, scan(t, conn))
+
+	// files that can not be read
+	tests := []struct {
+		path       string
+		table      string
+		encryption map[string]interface{}
+	}{
+		// a plain file when encryption is required
+		{"plain/", "clicks", static},
+		// no decrypter registered
+		{"aged/", "clicks", static},
+		// no keys
+		{"secure/clicks/", "a", nil},
+		// the wrong key
+		{"secure/clicks/", "a", map[string]interface{}{"keys": map[string]interface{}{"k1": base64.StdEncoding.EncodeToString(keys["k2"])}}},
+	}
+	for _, tt := range tests {
+		src, err = files.NewFileSource(settings(tt.path, tt.encryption))
+		assert.Tf(t, err == nil, "no error %v", err)
+		_, err = src.Table(tt.table)
+		assert.Tf(t, err != nil, "could not read %s of %v", tt.path, tt.encryption)
+	}
+	// truncated, of the last chunk
+	data := bucket["secure/big/a.csv.enc"]
+	bucket["secure/big/a.csv.enc"] = data[:len(data)-100]
+	src, _ = files.NewFileSource(settings("secure/big/", static))
+	conn, _ = src.Open("a")
+	for msg := conn.(schema.ConnScanner).Next(); msg != nil; msg = conn.(schema.ConnScanner).Next() {
+	}
+	assert.T(t, conn.(schema.IteratorErr).Err() != nil)
+
+	_, err = files.NewFileSource(settings("secure/", map[string]interface{}{"provider": "vault"}))
+	assert.T(t, err != nil)
+}
@@ -0,0 +1,135 @@
+// Files package implements a Datasource of files (csv, json-lines) in an
+// object store (S3, GCS, Azure blob) or local directory.
+//
+// Only the Store of a local directory is built in.  The Stores of S3, GCS
+// and Azure blob are left to the program using this package, which
+// implements Store on the client library of its cloud and registers it
+// with RegisterStore.
+package files
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+	"golang.org/x/net/context"
+)
+
+var (
+	_ = u.EMPTY
+
+	_ Store = (*localStore)(nil)
+
+	storeMu sync.RWMutex
+	stores  = map[string]StoreOpener{
+		"file": func(bucket string, settings u.JsonHelper) (Store, error) {
+			return NewLocalStore(bucket), nil
+		},
+	}
+)
+
+// Store an object store of files by name, names are slash separated paths
+// within a bucket.
+type Store interface {
+	// List the objects whose names start with prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// Open a reader of the contents of the object of name
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// Object a file in a Store
+type Object struct {
+	Name    string
+	Size    int64
+	Updated time.Time
+}
+
+type objects []Object
+
+func (m objects) Len() int           { return len(m) }
+func (m objects) Less(i, j int) bool { return m[i].Name < m[j].Name }
+func (m objects) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+
+// StoreOpener open the Store of a bucket, with the settings of the source
+// config (credentials, region, endpoint ..)
+type StoreOpener func(bucket string, settings u.JsonHelper) (Store, error)
+
+// RegisterStore register the StoreOpener for paths of scheme, ie "s3" for
+// s3://bucket/prefix.  The client libraries of the cloud stores are not
+// dependencies of qlbridge, so the store of each is registered by the
+// program using it, "file" (a local directory) is built in.
+func RegisterStore(scheme string, open StoreOpener) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	stores[strings.ToLower(scheme)] = open
+}
+
+// OpenStore open the Store of path (scheme://bucket/prefix, or a local
+// directory), returns the prefix of path within the store.
+func OpenStore(path string, settings u.JsonHelper) (Store, string, error) {
+	scheme, bucket, prefix := "file", path, ""
+	if pu, err := url.Parse(path); err == nil && len(pu.Scheme) > 1 {
+		scheme, bucket, prefix = strings.ToLower(pu.Scheme), pu.Host, strings.TrimPrefix(pu.Path, "/")
+		if scheme == "file" {
+			bucket, prefix = pu.Path, ""
+		}
+	}
+	storeMu.RLock()
+	open, ok := stores[scheme]
+	storeMu.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("no store registered for %q, see files.RegisterStore", scheme)
+	}
+	store, err := open(bucket, settings)
+	if err != nil {
+		return nil, "", err
+	}
+	return store, prefix, nil
+}
+
+// localStore the files of a local directory
+type localStore struct {
+	dir string
+}
+
+// NewLocalStore a Store of the files under dir
+func NewLocalStore(dir string) Store {
+	return &localStore{dir: dir}
+}
+
+func (m *localStore) List(ctx context.Context, prefix string) ([]Object, error) {
+	list := make(objects, 0)
+	err := filepath.Walk(m.dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(m.dir, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		if strings.HasPrefix(name, prefix) {
+			list = append(list, Object{Name: name, Size: fi.Size(), Updated: fi.ModTime()})
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(list)
+	return list, nil
+}
+
+func (m *localStore) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(m.dir, filepath.FromSlash(name)))
+}
@@ -0,0 +1,195 @@
+package datasource
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	// HealthCheckTimeout how long a health check may take before the source
+	// is marked unhealthy
+	HealthCheckTimeout = 5 * time.Second
+
+	// healthMu guards the health of the sources of the registry
+	healthMu sync.Mutex
+)
+
+func init() {
+	plan.SourceHealthErr = registry.healthErr
+}
+
+// SourceHealth the status of the health checks of a source that implements
+// schema.HealthChecker
+type SourceHealth struct {
+	Source    string        `json:"source"`
+	Healthy   bool          `json:"healthy"`
+	Latency   time.Duration `json:"latency"`    // of the last check
+	LastError string        `json:"last_error"` // of the last failed check
+	LastCheck time.Time     `json:"last_check"`
+	Failures  int           `json:"failures"` // consecutive failed checks
+	ds        schema.Source
+	err       error
+}
+
+// CheckHealth check the health of the registered sources that implement
+// schema.HealthChecker now, in parallel, returning their health (see
+// Health).  Queries of sources found unhealthy fail when planned, until a
+// later check finds them healthy.
+func (m *Registry) CheckHealth() []SourceHealth {
+	registryMu.RLock()
+	checkers := make(map[string]schema.HealthChecker)
+	sources := make(map[string]schema.Source)
+	for name, src := range m.sources {
+		if checker, ok := src.(schema.HealthChecker); ok {
+			checkers[name], sources[name] = checker, src
+		}
+	}
+	registryMu.RUnlock()
+
+	var wg sync.WaitGroup
+	results := make(map[string]*SourceHealth, len(checkers))
+	var mu sync.Mutex
+	for name, checker := range checkers {
+		wg.Add(1)
+		go func(name string, checker schema.HealthChecker) {
+			defer wg.Done()
+			start := time.Now()
+			err := checkHealth(checker)
+			mu.Lock()
+			results[name] = &SourceHealth{Source: name, Latency: time.Since(start), LastCheck: start, err: err}
+			mu.Unlock()
+		}(name, checker)
+	}
+	wg.Wait()
+
+	healthMu.Lock()
+	prev := m.health
+	m.health = make(map[string]*SourceHealth, len(results))
+	for name, h := range results {
+		h.ds = sources[name]
+		h.Healthy = h.err == nil
+		if old, ok := prev[name]; ok {
+			h.LastError, h.Failures = old.LastError, old.Failures
+		}
+		if h.err != nil {
+			h.LastError = h.err.Error()
+			h.Failures++
+			if h.Failures == 1 {
+				u.Warnf("source %q is unhealthy: %v", name, h.err)
+			}
+		} else {
+			if h.Failures > 0 {
+				u.Infof("source %q is healthy again", name)
+			}
+			h.Failures = 0
+		}
+		m.health[name] = h
+	}
+	healthMu.Unlock()
+	return m.Health()
+}
+
+// checkHealth the error of the health check of checker, an error if it
+// takes longer than HealthCheckTimeout
+func checkHealth(checker schema.HealthChecker) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.CheckHealth()
+	}()
+	timer := time.NewTimer(HealthCheckTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("health check timed out after %v", HealthCheckTimeout)
+	}
+}
+
+// Health the health of the sources of the last check, sorted by source
+// name.  Empty if the sources have not been checked.
+func (m *Registry) Health() []SourceHealth {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	health := make([]SourceHealth, 0, len(m.health))
+	for _, h := range m.health {
+		health = append(health, *h)
+	}
+	sort.Sort(healthBySource(health))
+	return health
+}
+
+// StartHealthChecks check the health of the sources every interval in the
+// background, until StopHealthChecks
+func (m *Registry) StartHealthChecks(interval time.Duration) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	if m.stopHealth != nil {
+		close(m.stopHealth)
+	}
+	stop := make(chan struct{})
+	m.stopHealth = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		m.CheckHealth()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.CheckHealth()
+			}
+		}
+	}()
+}
+
+// StopHealthChecks stop the background health checks
+func (m *Registry) StopHealthChecks() {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	if m.stopHealth != nil {
+		close(m.stopHealth)
+		m.stopHealth = nil
+	}
+}
+
+// healthErr the error of the last check of ds, if it is unhealthy
+func (m *Registry) healthErr(ds schema.Source) error {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	for _, h := range m.health {
+		if h.err != nil && h.ds == ds {
+			return h.err
+		}
+	}
+	return nil
+}
+
+// RowsForHealth the rows of the _health table, of the health of the sources
+func RowsForHealth(ctx *plan.Context) [][]driver.Value {
+	health := registry.Health()
+	rows := make([][]driver.Value, len(health))
+	for i, h := range health {
+		var lastError driver.Value
+		if h.LastError != "" {
+			lastError = h.LastError
+		}
+		rows[i] = []driver.Value{h.Source, h.Healthy, h.Latency.Nanoseconds() / int64(time.Millisecond), lastError, h.LastCheck, int64(h.Failures)}
+	}
+	return rows
+}
+
+type healthBySource []SourceHealth
+
+func (m healthBySource) Len() int           { return len(m) }
+func (m healthBySource) Less(i, j int) bool { return m[i].Source < m[j].Source }
+func (m healthBySource) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
@@ -0,0 +1,443 @@
+package datasource
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	_ schema.Source      = (*JsonLinesDataSource)(nil)
+	_ MeteredSource      = (*JsonLinesDataSource)(nil)
+	_ schema.Conn        = (*JsonLinesDataSource)(nil)
+	_ schema.ConnScanner = (*JsonLinesDataSource)(nil)
+	_ schema.IteratorErr = (*JsonLinesDataSource)(nil)
+
+	// JsonLinesSampleCount records read to infer the columns and types of
+	// a json-lines source
+	JsonLinesSampleCount = 100
+)
+
+// JsonOptions how the json documents of a json-lines source are flattened
+// into rows
+type JsonOptions struct {
+	// MaxDepth of the nested objects flattened into columns, objects nested
+	// deeper are the json text of their column, 0 for all
+	MaxDepth int
+	// Separator of the names of nested fields in columns, "." if empty
+	Separator string
+	// Explode the arrays of these columns into a row per item, each with the
+	// other columns of the document (as UNNEST would), items that are
+	// objects flattened into the columns of the path.  In order, so a path
+	// of the items of an earlier path is exploded too, ie "items.tags".
+	Explode []string
+	// ExplodeOuter keep the documents of an empty or missing array exploded,
+	// as a row of a null item, else they are dropped
+	ExplodeOuter bool
+	// SampleRows rows read to infer the columns, JsonLinesSampleCount if 0
+	SampleRows int
+}
+
+// JsonOptionsFromSettings the JsonOptions of the Settings of a source config
+// (or of a table of one)
+//
+//   "settings" : {
+//       "max_depth"     : 2,                  // deeper objects are json text
+//       "separator"     : "_",                // user_name, not user.name
+//       "explode"       : ["items"],          // a row per item of items
+//       "explode_outer" : true,               // and of orders with no items
+//       "sample_rows"   : 1000
+//   }
+func JsonOptionsFromSettings(settings u.JsonHelper) (JsonOptions, error) {
+	opts := JsonOptions{}
+	if settings == nil {
+		return opts, nil
+	}
+	opts.MaxDepth, _ = settings.IntSafe("max_depth")
+	opts.SampleRows, _ = settings.IntSafe("sample_rows")
+	if opts.MaxDepth < 0 || opts.SampleRows < 0 {
+		return opts, fmt.Errorf("invalid negative json max_depth or sample_rows")
+	}
+	opts.Separator = settings.String("separator")
+	opts.Explode = settings.Strings("explode")
+	if path, ok := settings["explode"].(string); ok && path != "" {
+		opts.Explode = []string{path}
+	}
+	for i, path := range opts.Explode {
+		opts.Explode[i] = strings.ToLower(path)
+	}
+	opts.ExplodeOuter, _ = settings.BoolSafe("explode_outer")
+	return opts, nil
+}
+
+// JsonLinesDataSource a DataSource of newline delimited json (ndjson), one
+// object per line, as a single table.
+//   - the columns and their types are inferred from the first
+//     JsonLinesSampleCount rows, fields first seen after are ignored
+//   - nested objects are flattened into dotted columns, ie
+//     {"user":{"name":"bob"}} is column user.name, to JsonOptions.MaxDepth
+//   - arrays are values, []string if all strings, or exploded into rows of
+//     their items (see JsonOptions.Explode)
+//   - forward only single pass, not thread-safe, read only
+//   - lines that are not json objects are skipped
+type JsonLinesDataSource struct {
+	table    string
+	tbl      *schema.Table
+	exit     <-chan bool
+	r        *bufio.Reader
+	dc       io.Closer
+	rc       io.ReadCloser
+	rowct    uint64
+	opts     JsonOptions
+	sample   []map[string]interface{} // sampled rows not yet read
+	pending  []map[string]interface{} // rows of the last document not yet read
+	headers  []string
+	types    []value.ValueType
+	colindex map[string]int
+	err      error
+	metrics  Metrics
+}
+
+// NewJsonLinesSource read newline delimited json from ior, optionally
+// compressed (gzip or a registered Decompressor), sampling its first records for the schema.
+func NewJsonLinesSource(table string, ior io.Reader, exit <-chan bool) (*JsonLinesDataSource, error) {
+	return NewJsonLinesSourceOptions(table, ior, exit, JsonOptions{})
+}
+
+// NewJsonLinesSourceOptions read newline delimited json from ior, its
+// documents flattened into rows as described by opts
+func NewJsonLinesSourceOptions(table string, ior io.Reader, exit <-chan bool, opts JsonOptions) (*JsonLinesDataSource, error) {
+
+	if opts.Separator == "" {
+		opts.Separator = "."
+	}
+	if opts.SampleRows == 0 {
+		opts.SampleRows = JsonLinesSampleCount
+	}
+	m := JsonLinesDataSource{table: table, exit: exit, opts: opts}
+	if rc, ok := ior.(io.ReadCloser); ok {
+		m.rc = rc
+	}
+
+	r, dc, err := decompress(bufio.NewReader(ior))
+	if err != nil {
+		u.Errorf("Could not open reader? %v", err)
+		return nil, err
+	}
+	m.dc = dc
+	m.r = bufio.NewReader(r)
+
+	m.sample = make([]map[string]interface{}, 0, opts.SampleRows)
+	for len(m.sample) < opts.SampleRows {
+		rows, err := m.readRows()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		m.sample = append(m.sample, rows...)
+	}
+	m.inferColumns()
+	m.loadTable()
+	return &m, nil
+}
+
+func (m *JsonLinesDataSource) Tables() []string                { return []string{m.table} }
+func (m *JsonLinesDataSource) Columns() []string               { return m.headers }
+func (m *JsonLinesDataSource) CreateIterator() schema.Iterator { return m }
+func (m *JsonLinesDataSource) Table(tableName string) (*schema.Table, error) {
+	if m.tbl != nil {
+		return m.tbl, nil
+	}
+	return nil, schema.ErrNotFound
+}
+
+// Open a json-lines file at path connInfo, or stdin
+func (m *JsonLinesDataSource) Open(connInfo string) (schema.Conn, error) {
+	if connInfo == "stdio" || connInfo == "stdin" {
+		connInfo = "/dev/stdin"
+	}
+	f, err := os.Open(connInfo)
+	if err != nil {
+		return nil, err
+	}
+	exit := make(<-chan bool, 1)
+	return NewJsonLinesSource(connInfo, f, exit)
+}
+
+func (m *JsonLinesDataSource) Close() error {
+	if m.dc != nil {
+		m.dc.Close()
+	}
+	if m.rc != nil {
+		return m.rc.Close()
+	}
+	return nil
+}
+
+func (m *JsonLinesDataSource) Metrics() *Metrics { return &m.metrics }
+
+func (m *JsonLinesDataSource) MesgChan() <-chan schema.Message {
+	iter := m.CreateIterator()
+	return SourceIterChannel(iter, m.exit)
+}
+
+func (m *JsonLinesDataSource) Next() schema.Message {
+	select {
+	case <-m.exit:
+		return nil
+	default:
+	}
+	var rec map[string]interface{}
+	if len(m.sample) > 0 {
+		rec = m.sample[0]
+		m.sample = m.sample[1:]
+	} else {
+		if len(m.pending) == 0 {
+			rows, err := m.readRows()
+			if err != nil {
+				if err != io.EOF {
+					u.Warnf("could not read json-lines %q: %v", m.table, err)
+					m.err = err
+				}
+				return nil
+			}
+			m.pending = rows
+		}
+		rec = m.pending[0]
+		m.pending = m.pending[1:]
+	}
+	m.rowct++
+	vals := make([]driver.Value, len(m.headers))
+	for i, col := range m.headers {
+		vals[i] = jsonLinesValue(rec[col], m.types[i])
+	}
+	return NewSqlDriverMessageMap(m.rowct, vals, m.colindex)
+}
+
+// Err the error reading the rows, nil if all were read
+func (m *JsonLinesDataSource) Err() error { return m.err }
+
+// readRecord the next json object, flattened, skipping blank and invalid
+// lines.  io.EOF at the end.
+func (m *JsonLinesDataSource) readRecord() (map[string]interface{}, error) {
+	for {
+		line, err := m.r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var obj map[string]interface{}
+			dec := json.NewDecoder(bytes.NewReader(line))
+			dec.UseNumber()
+			if jerr := dec.Decode(&obj); jerr != nil || obj == nil {
+				u.Warnf("dropping json-lines row that is not an object %q: %v", line, jerr)
+			} else {
+				rec := make(map[string]interface{}, len(obj))
+				m.flatten(rec, "", obj, 1)
+				return rec, nil
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readRows the rows of the next document, of the arrays exploded, skipping
+// documents of no rows.  io.EOF at the end.
+func (m *JsonLinesDataSource) readRows() ([]map[string]interface{}, error) {
+	for {
+		rec, err := m.readRecord()
+		if err != nil {
+			return nil, err
+		}
+		if rows := m.explode(rec); len(rows) > 0 {
+			return rows, nil
+		}
+	}
+}
+
+// flatten the nested objects of obj, at depth, into keys of rec joined by
+// the separator
+func (m *JsonLinesDataSource) flatten(rec map[string]interface{}, prefix string, obj map[string]interface{}, depth int) {
+	for k, v := range obj {
+		k = prefix + strings.ToLower(k)
+		if nested, ok := v.(map[string]interface{}); ok && (m.opts.MaxDepth == 0 || depth < m.opts.MaxDepth) {
+			m.flatten(rec, k+m.opts.Separator, nested, depth+1)
+			continue
+		}
+		rec[k] = v
+	}
+}
+
+// explode the arrays of the explode paths of rec into a row per item
+func (m *JsonLinesDataSource) explode(rec map[string]interface{}) []map[string]interface{} {
+	rows := []map[string]interface{}{rec}
+	for _, path := range m.opts.Explode {
+		exploded := make([]map[string]interface{}, 0, len(rows))
+		for _, row := range rows {
+			items, isArray := row[path].([]interface{})
+			if !isArray {
+				if row[path] != nil || m.opts.ExplodeOuter {
+					// a single value is an item of its own
+					exploded = append(exploded, row)
+				}
+				continue
+			}
+			if len(items) == 0 && m.opts.ExplodeOuter {
+				items = []interface{}{nil}
+			}
+			for _, item := range items {
+				child := make(map[string]interface{}, len(row))
+				for k, v := range row {
+					if k != path {
+						child[k] = v
+					}
+				}
+				if obj, ok := item.(map[string]interface{}); ok {
+					m.flatten(child, path+m.opts.Separator, obj, strings.Count(path, m.opts.Separator)+2)
+				} else if item != nil {
+					child[path] = item
+				}
+				exploded = append(exploded, child)
+			}
+		}
+		rows = exploded
+	}
+	return rows
+}
+
+// inferColumns the columns of the sampled records in the order first seen
+// (the keys of a record sorted), and the type of each
+func (m *JsonLinesDataSource) inferColumns() {
+	m.colindex = make(map[string]int)
+	for _, rec := range m.sample {
+		keys := make([]string, 0, len(rec))
+		for k := range rec {
+			if _, ok := m.colindex[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			m.colindex[k] = len(m.headers)
+			m.headers = append(m.headers, k)
+			m.types = append(m.types, value.UnknownType)
+		}
+		for k, v := range rec {
+			i := m.colindex[k]
+			m.types[i] = mergeJsonType(m.types[i], jsonType(v))
+		}
+	}
+	for i, vt := range m.types {
+		if vt == value.UnknownType {
+			// only ever null
+			m.types[i] = value.StringType
+		}
+	}
+}
+
+func (m *JsonLinesDataSource) loadTable() {
+	tbl := schema.NewTable(strings.ToLower(m.table))
+	for i, col := range m.headers {
+		tbl.AddField(schema.NewFieldBase(col, m.types[i], 64, "json"))
+	}
+	tbl.SetColumns(m.headers)
+	m.tbl = tbl
+}
+
+// jsonType the value type of a decoded json value
+func jsonType(v interface{}) value.ValueType {
+	switch val := v.(type) {
+	case nil:
+		return value.UnknownType
+	case bool:
+		return value.BoolType
+	case string:
+		return value.StringType
+	case json.Number:
+		if _, err := val.Int64(); err == nil {
+			return value.IntType
+		}
+		return value.NumberType
+	case map[string]interface{}:
+		// nested deeper than flattened, its json
+		return value.StringType
+	case []interface{}:
+		for _, item := range val {
+			if _, ok := item.(string); !ok {
+				return value.SliceValueType
+			}
+		}
+		return value.StringsType
+	}
+	return value.UnknownType
+}
+
+// mergeJsonType the type of a column with values of types a and b, ints
+// widen to numbers and other mixed types to strings
+func mergeJsonType(a, b value.ValueType) value.ValueType {
+	switch {
+	case a == b || b == value.UnknownType:
+		return a
+	case a == value.UnknownType:
+		return b
+	case (a == value.IntType && b == value.NumberType) || (a == value.NumberType && b == value.IntType):
+		return value.NumberType
+	case (a == value.StringsType && b == value.SliceValueType) || (a == value.SliceValueType && b == value.StringsType):
+		return value.SliceValueType
+	}
+	return value.StringType
+}
+
+// jsonLinesValue the driver value of a decoded json value for a column of
+// type vt
+func jsonLinesValue(v interface{}, vt value.ValueType) driver.Value {
+	if v == nil {
+		return nil
+	}
+	switch vt {
+	case value.IntType:
+		if n, ok := v.(json.Number); ok {
+			if iv, err := n.Int64(); err == nil {
+				return iv
+			}
+		}
+	case value.NumberType:
+		if n, ok := v.(json.Number); ok {
+			if fv, err := n.Float64(); err == nil {
+				return fv
+			}
+		}
+	case value.StringsType:
+		if items, ok := v.([]interface{}); ok {
+			strs := make([]string, len(items))
+			for i, item := range items {
+				strs[i], _ = jsonLinesValue(item, value.StringType).(string)
+			}
+			return strs
+		}
+	case value.SliceValueType:
+		return v
+	case value.BoolType:
+		return v
+	}
+	// a value of another type than the column is read as a string
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	}
+	by, _ := json.Marshal(v)
+	return string(by)
+}
@@ -0,0 +1,121 @@
+package datasource_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	u "github.com/araddon/gou"
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/value"
+)
+
+var jsonLinesData = `{"id":1,"user":{"name":"aaron","age":31},"score":1.5,"tags":["a","b"]}
+{"id":2,"user":{"name":"bob"},"score":3,"active":true}
+
+not json
+{"id":3,"user":{"name":"carol","age":40},"score":null,"tags":[1,"x"],"late":"ignored"}`
+
+func TestJsonLinesDataSource(t *testing.T) {
+	datasource.JsonLinesSampleCount = 2
+	defer func() { datasource.JsonLinesSampleCount = 100 }()
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(jsonLinesData))
+	w.Close()
+
+	for _, r := range []*strings.Reader{strings.NewReader(jsonLinesData), strings.NewReader(gz.String())} {
+		src, err := datasource.NewJsonLinesSource("users", r, make(<-chan bool, 1))
+		assert.Tf(t, err == nil, "should not have error: %v", err)
+		assert.Equal(t, []string{"id", "score", "tags", "user.age", "user.name", "active"}, src.Columns())
+		tbl, err := src.Table("users")
+		assert.T(t, err == nil)
+		types := make([]value.ValueType, 0)
+		for _, f := range tbl.Fields {
+			types = append(types, f.Type)
+		}
+		assert.Equal(t, []value.ValueType{value.IntType, value.NumberType, value.StringsType,
+			value.IntType, value.StringType, value.BoolType}, types)
+
+		rows := make([][]driver.Value, 0)
+		for msg := src.Next(); msg != nil; msg = src.Next() {
+			rows = append(rows, msg.(*datasource.SqlDriverMessageMap).Values())
+		}
+		assert.Equal(t, 3, len(rows))
+		assert.Equal(t, []driver.Value{int64(1), 1.5, []string{"a", "b"}, int64(31), "aaron", nil}, rows[0])
+		assert.Equal(t, []driver.Value{int64(2), float64(3), nil, nil, "bob", true}, rows[1])
+		// read after the sample, a value not of the columns type is a string
+		assert.Equal(t, []driver.Value{int64(3), nil, []string{"1", "x"}, int64(40), "carol", nil}, rows[2])
+	}
+}
+
+var ordersData = `{"id":1,"customer":{"name":"ann","address":{"city":"sf"}},"items":[{"sku":"a","qty":2},{"sku":"b","qty":1}],"tags":["x","y"]}
+{"id":2,"customer":{"name":"bob"},"items":[]}`
+
+func TestJsonOptions(t *testing.T) {
+	tests := []struct {
+		opts datasource.JsonOptions
+		cols []string
+		rows [][]driver.Value
+	}{
+		{datasource.JsonOptions{Explode: []string{"items"}},
+			[]string{"customer.address.city", "customer.name", "id", "items.qty", "items.sku", "tags"},
+			[][]driver.Value{
+				{"sf", "ann", int64(1), int64(2), "a", []string{"x", "y"}},
+				{"sf", "ann", int64(1), int64(1), "b", []string{"x", "y"}},
+			}},
+		// documents of no items kept
+		{datasource.JsonOptions{Explode: []string{"items"}, ExplodeOuter: true},
+			[]string{"customer.address.city", "customer.name", "id", "items.qty", "items.sku", "tags"},
+			[][]driver.Value{
+				{"sf", "ann", int64(1), int64(2), "a", []string{"x", "y"}},
+				{"sf", "ann", int64(1), int64(1), "b", []string{"x", "y"}},
+				{nil, "bob", int64(2), nil, nil, nil},
+			}},
+		// the tags of each item, and scalars exploded into the column
+		{datasource.JsonOptions{Explode: []string{"items", "tags"}, Separator: "_"},
+			[]string{"customer_address_city", "customer_name", "id", "items_qty", "items_sku", "tags"},
+			[][]driver.Value{
+				{"sf", "ann", int64(1), int64(2), "a", "x"},
+				{"sf", "ann", int64(1), int64(2), "a", "y"},
+				{"sf", "ann", int64(1), int64(1), "b", "x"},
+				{"sf", "ann", int64(1), int64(1), "b", "y"},
+			}},
+		// objects deeper than the max depth are their json
+		{datasource.JsonOptions{MaxDepth: 1, Explode: []string{"items"}},
+			[]string{"customer", "id", "items.qty", "items.sku", "tags"},
+			[][]driver.Value{
+				{`{"address":{"city":"sf"},"name":"ann"}`, int64(1), int64(2), "a", []string{"x", "y"}},
+				{`{"address":{"city":"sf"},"name":"ann"}`, int64(1), int64(1), "b", []string{"x", "y"}},
+			}},
+		{datasource.JsonOptions{MaxDepth: 2, Explode: []string{"items"}, ExplodeOuter: true},
+			[]string{"customer.address", "customer.name", "id", "items.qty", "items.sku", "tags"},
+			[][]driver.Value{
+				{`{"city":"sf"}`, "ann", int64(1), int64(2), "a", []string{"x", "y"}},
+				{`{"city":"sf"}`, "ann", int64(1), int64(1), "b", []string{"x", "y"}},
+				{nil, "bob", int64(2), nil, nil, nil},
+			}},
+	}
+	for _, tt := range tests {
+		src, err := datasource.NewJsonLinesSourceOptions("orders", strings.NewReader(ordersData), make(<-chan bool, 1), tt.opts)
+		assert.Tf(t, err == nil, "should not have error: %v", err)
+		assert.Equalf(t, tt.cols, src.Columns(), "columns of %+v", tt.opts)
+		rows := make([][]driver.Value, 0)
+		for msg := src.Next(); msg != nil; msg = src.Next() {
+			rows = append(rows, msg.(*datasource.SqlDriverMessageMap).Values())
+		}
+		assert.Equalf(t, tt.rows, rows, "rows of %+v", tt.opts)
+	}
+
+	opts, err := datasource.JsonOptionsFromSettings(u.JsonHelper{"explode": "Items", "explode_outer": true,
+		"max_depth": 2, "separator": "_"})
+	assert.Tf(t, err == nil, "should not have error: %v", err)
+	assert.Equal(t, datasource.JsonOptions{MaxDepth: 2, Separator: "_", Explode: []string{"items"}, ExplodeOuter: true}, opts)
+	_, err = datasource.JsonOptionsFromSettings(u.JsonHelper{"max_depth": -1})
+	assert.T(t, err != nil)
+}
@@ -5,12 +5,13 @@ import (
 
 	u "github.com/araddon/gou"
 
-	"github.com/araddon/qlbridge/expr"
-	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/plan"
 	"github.com/araddon/qlbridge/schema"
 )
 
 var (
+	_ = u.EMPTY
+
 	_ schema.Key = (*KeyInt)(nil)
 	_ schema.Key = (*KeyInt64)(nil)
 	_ schema.Key = (*KeyCol)(nil)
@@ -24,10 +25,8 @@ type (
 	KeyInt64 struct {
 		Id int64
 	}
-	KeyCol struct {
-		Name string
-		Val  driver.Value
-	}
+	// KeyCol is a column = value key, see schema.KeyCol
+	KeyCol = schema.KeyCol
 )
 
 func NewKeyInt(key int) KeyInt      { return KeyInt{key} }
@@ -38,40 +37,11 @@ func (m *KeyInt) Key() driver.Value { return driver.Value(m.Id) }
 func NewKeyInt64(key int64) KeyInt64  { return KeyInt64{key} }
 func (m *KeyInt64) Key() driver.Value { return driver.Value(m.Id) }
 
-func NewKeyCol(name string, val driver.Value) KeyCol { return KeyCol{name, val} }
-func (m KeyCol) Key() driver.Value                   { return m.Val }
+func NewKeyCol(name string, val driver.Value) KeyCol { return schema.NewKeyCol(name, val) }
 
 // Given a Where expression, lets try to create a key which
 //  requires form    `idenity = "value"`
 //
 func KeyFromWhere(wh interface{}) schema.Key {
-	switch n := wh.(type) {
-	case *rel.SqlWhere:
-		return KeyFromWhere(n.Expr)
-	case *expr.BinaryNode:
-		if len(n.Args) != 2 {
-			u.Warnf("need more args? %#v", n.Args)
-			return nil
-		}
-		in, ok := n.Args[0].(*expr.IdentityNode)
-		if !ok {
-			u.Warnf("not identity? %T", n.Args[0])
-			return nil
-		}
-		// This only allows for    identity = value
-		// NOT:      identity = expr(identity, arg)
-		//
-		switch valT := n.Args[1].(type) {
-		case *expr.NumberNode:
-			return NewKeyCol(in.Text, valT.Float64)
-		case *expr.StringNode:
-			return NewKeyCol(in.Text, valT.Text)
-		//case *expr.FuncNode:
-		default:
-			u.Warnf("not supported arg? %#v", valT)
-		}
-	default:
-		u.Warnf("not supported node type? %#v", n)
-	}
-	return nil
+	return plan.KeyFromWhere(wh)
 }
@@ -5,6 +5,8 @@ package membtree
 import (
 	"database/sql/driver"
 	"fmt"
+	"sync"
+	"time"
 
 	u "github.com/araddon/gou"
 	"github.com/dchest/siphash"
@@ -13,6 +15,7 @@ import (
 
 	"github.com/araddon/qlbridge/datasource"
 	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/expr/translate"
 	"github.com/araddon/qlbridge/plan"
 	"github.com/araddon/qlbridge/rel"
 	"github.com/araddon/qlbridge/schema"
@@ -28,14 +31,20 @@ var (
 	_ = u.EMPTY
 
 	// Different Features of this Static Data Source
-	_ schema.Source            = (*StaticDataSource)(nil)
-	_ schema.SourceTableSchema = (*StaticDataSource)(nil)
-	_ schema.Conn              = (*StaticDataSource)(nil)
-	_ schema.ConnColumns       = (*StaticDataSource)(nil)
-	_ schema.ConnScanner       = (*StaticDataSource)(nil)
-	_ schema.ConnSeeker        = (*StaticDataSource)(nil)
-	_ schema.ConnUpsert        = (*StaticDataSource)(nil)
-	_ schema.ConnDeletion      = (*StaticDataSource)(nil)
+	_ schema.Source              = (*StaticDataSource)(nil)
+	_ datasource.MeteredSource   = (*StaticDataSource)(nil)
+	_ schema.SourceTableSchema   = (*StaticDataSource)(nil)
+	_ schema.SourceChanges       = (*StaticDataSource)(nil)
+	_ schema.SourcePartitionable = (*StaticDataSource)(nil)
+	_ schema.Conn                = (*StaticDataSource)(nil)
+	_ schema.ConnColumns         = (*StaticDataSource)(nil)
+	_ schema.ConnScanner         = (*StaticDataSource)(nil)
+	_ schema.ConnSeeker          = (*StaticDataSource)(nil)
+	_ schema.ConnKeyed           = (*StaticDataSource)(nil)
+	_ schema.ConnUpsert          = (*StaticDataSource)(nil)
+	_ schema.ConnDeletion        = (*StaticDataSource)(nil)
+	_ schema.ConnPatchWhere      = (*StaticDataSource)(nil)
+	_ translate.Translator       = (*StaticDataSource)(nil)
 )
 
 type Key struct {
@@ -114,17 +123,29 @@ func makeId(dv driver.Value) uint64 {
 //
 // Features
 // - only a single column may (and must) be identified as the "Indexed" column
-// - NOT threadsafe
+// - secondary indexes of other columns (see AddIndex)
+// - publishes its puts and deletes to subscribers (see Subscribe)
+// - rows may expire a ttl after they are written (see SetTTL)
+// - NOT threadsafe, other than for the background expiry of rows
 // - each StaticDataSource = a single Table
 //
 type StaticDataSource struct {
-	exit     <-chan bool
-	name     string
-	tbl      *schema.Table
-	indexCol int        // Which column position is indexed?  ie primary key
-	cursor   btree.Item // cursor position for paging
-	bt       *btree.BTree
-	max      int
+	mu         sync.Mutex
+	exit       <-chan bool
+	name       string
+	tbl        *schema.Table
+	indexCol   int        // Which column position is indexed?  ie primary key
+	cursor     btree.Item // cursor position for paging
+	bt         *btree.BTree
+	max        int
+	indexes    map[string]*index // secondary indexes by column
+	seek       *indexSeek        // index seek of the next scan
+	seekIds    []uint64          // row ids of the seek not yet read
+	changes    *datasource.ChangeFeed
+	ttl        time.Duration // rows expire ttl after written, 0 for never
+	expiresIdx int           // position of the expires_at column, 0 if none
+	stopExpiry chan struct{}
+	metrics    datasource.Metrics
 }
 
 func NewStaticDataSource(name string, indexedCol int, data [][]driver.Value, cols []string) *StaticDataSource {
@@ -155,24 +176,70 @@ func NewStaticData(name string) *StaticDataSource {
 
 func (m *StaticDataSource) Open(connInfo string) (schema.Conn, error) { return m, nil }
 func (m *StaticDataSource) Table(table string) (*schema.Table, error) { return m.tbl, nil }
-func (m *StaticDataSource) Close() error                              { return nil }
 func (m *StaticDataSource) CreateIterator() schema.Iterator           { return m }
 func (m *StaticDataSource) Tables() []string                          { return []string{m.name} }
 func (m *StaticDataSource) Columns() []string                         { return m.tbl.Columns() }
-func (m *StaticDataSource) Length() int                               { return m.bt.Len() }
-func (m *StaticDataSource) SetColumns(cols []string)                  { m.tbl.SetColumns(cols) }
+
+func (m *StaticDataSource) Length() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bt.Len()
+}
+
+// SetColumns the columns of the table, and expires_at if it has a ttl
+func (m *StaticDataSource) SetColumns(cols []string) {
+	if m.expiresIdx > 0 {
+		m.expiresIdx = len(cols)
+		cols = append(cols[:len(cols):len(cols)], ExpiresAtColumn)
+	}
+	m.tbl.SetColumns(cols)
+}
+
+// Subscribe to the puts and deletes of the rows of the table
+func (m *StaticDataSource) Subscribe(table string) (schema.Subscription, error) {
+	if m.changes == nil {
+		m.changes = datasource.NewChangeFeed()
+	}
+	return m.changes.Subscribe(table), nil
+}
+
+// publish the change of row to subscribers
+func (m *StaticDataSource) publish(op schema.ChangeOp, row, old []driver.Value) {
+	if m.changes.Subscribers() == 0 {
+		return
+	}
+	var key driver.Value
+	if m.indexCol >= 0 && m.indexCol < len(row) {
+		key = row[m.indexCol]
+	}
+	m.changes.Publish(&schema.Change{Op: op, Table: m.name, Key: key, Row: row, Old: old, Ts: time.Now()})
+}
 
 func (m *StaticDataSource) MesgChan() <-chan schema.Message {
 	iter := m.CreateIterator()
 	return datasource.SourceIterChannel(iter, m.exit)
 }
 
+func (m *StaticDataSource) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cursor, m.seek, m.seekIds = nil, nil, nil
+	return nil
+}
+
+func (m *StaticDataSource) Metrics() *datasource.Metrics { return &m.metrics }
+
 func (m *StaticDataSource) Next() schema.Message {
 	//u.Infof("Next()")
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	select {
 	case <-m.exit:
 		return nil
 	default:
+		if m.seek != nil {
+			return m.nextSeek()
+		}
 		for {
 			var item btree.Item
 
@@ -208,6 +275,9 @@ func (m *StaticDataSource) Next() schema.Message {
 			}
 			m.cursor = item
 			msg := item.(*DriverItem)
+			if m.expired(msg) {
+				continue
+			}
 			//u.Infof("return item btreeP:%p itemP:%p cursorP:%p  %v %v", m, item, m.cursor, msg.Id(), msg.Values())
 			//u.Debugf("return? %T  %v", item, item.(*DriverItem).SqlDriverMessageMap)
 			return msg.SqlDriverMessageMap.Copy()
@@ -216,23 +286,36 @@ func (m *StaticDataSource) Next() schema.Message {
 	}
 }
 
+// nextSeek the next row of the index seek
+func (m *StaticDataSource) nextSeek() schema.Message {
+	if m.seekIds == nil {
+		m.seekIds = m.seekRows(m.seek)
+	}
+	for len(m.seekIds) > 0 {
+		item := m.bt.Get(NewKey(m.seekIds[0]))
+		m.seekIds = m.seekIds[1:]
+		if item != nil && !m.expired(item.(*DriverItem)) {
+			return item.(*DriverItem).SqlDriverMessageMap.Copy()
+		}
+	}
+	m.seek, m.seekIds = nil, nil
+	return nil
+}
+
 // interface for Upsert.Put()
 func (m *StaticDataSource) Put(ctx context.Context, key schema.Key, row interface{}) (schema.Key, error) {
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	//u.Infof("%p Put(),  row:%#v", m, row)
 	switch rowVals := row.(type) {
 	case []driver.Value:
-		if len(rowVals) != len(m.Columns()) {
+		if !m.rowLenOk(len(rowVals)) {
 			u.Warnf("wrong column ct")
 			return nil, fmt.Errorf("Wrong number of columns, got %v expected %v", len(rowVals), len(m.Columns()))
 		}
 		id := makeId(rowVals[m.indexCol])
-		sdm := datasource.NewSqlDriverMessageMap(id, rowVals, m.tbl.FieldPositions)
-		item := DriverItem{sdm}
-		itemResult := m.bt.ReplaceOrInsert(&item)
-		if itemResult != nil {
-			//u.Errorf("could not insert? %#v", itemResult)
-		}
+		m.putRow(id, rowVals)
 		//u.Debugf("%p  PUT: id:%v IdVal:%v  Id():%v vals:%#v", m, id, sdm.IdVal, sdm.Id(), rowVals)
 		return NewKey(id), nil
 	case map[string]driver.Value:
@@ -266,7 +349,7 @@ func (m *StaticDataSource) Put(ctx context.Context, key schema.Key, row interfac
 			id = makeId(row[m.indexCol])
 		} else {
 			id = makeId(key)
-			sdm, _ := m.Get(key)
+			sdm, _ := m.get(key)
 			//u.Debugf("sdm: %#v  err%v", sdm, err)
 			if sdm != nil {
 				if dmval, ok := sdm.Body().(*datasource.SqlDriverMessageMap); ok {
@@ -280,9 +363,7 @@ func (m *StaticDataSource) Put(ctx context.Context, key schema.Key, row interfac
 		}
 		//u.Debugf("PUT: %#v", row)
 		//u.Infof("PUT: %v  key:%v  row:%v", id, key, row)
-		sdm := datasource.NewSqlDriverMessageMap(id, row, m.tbl.FieldPositions)
-		item := DriverItem{sdm}
-		m.bt.ReplaceOrInsert(&item)
+		m.putRow(id, row)
 		return NewKey(id), nil
 	default:
 		u.Warnf("not implemented %T", row)
@@ -291,8 +372,46 @@ func (m *StaticDataSource) Put(ctx context.Context, key schema.Key, row interfac
 	return nil, nil
 }
 
+// putRow insert (or replace) the row of id, and its index entries
+func (m *StaticDataSource) putRow(id uint64, row []driver.Value) {
+	row = m.withExpiry(row)
+	item := &DriverItem{datasource.NewSqlDriverMessageMap(id, row, m.tbl.FieldPositions)}
+	if replaced := m.bt.ReplaceOrInsert(item); replaced != nil {
+		m.indexPut(item, replaced.(*DriverItem))
+		m.publish(schema.ChangeUpdate, row, replaced.(*DriverItem).Values())
+	} else {
+		m.indexPut(item, nil)
+		m.publish(schema.ChangeInsert, row, nil)
+	}
+}
+
 func (m *StaticDataSource) PutMulti(ctx context.Context, keys []schema.Key, src interface{}) ([]schema.Key, error) {
-	return nil, fmt.Errorf("not implemented")
+	rows, ok := src.([][]driver.Value)
+	if !ok {
+		return nil, fmt.Errorf("Expected [][]driver.Value but got %T", src)
+	}
+	if len(keys) > 0 && len(keys) != len(rows) {
+		return nil, fmt.Errorf("Expected a key per row, got %d keys for %d rows", len(keys), len(rows))
+	}
+	// check all rows before putting any
+	for _, row := range rows {
+		if !m.rowLenOk(len(row)) {
+			return nil, fmt.Errorf("Wrong number of columns, got %v expected %v", len(row), len(m.Columns()))
+		}
+	}
+	out := make([]schema.Key, len(rows))
+	for i, row := range rows {
+		var key schema.Key
+		if len(keys) > 0 {
+			key = keys[i]
+		}
+		k, err := m.Put(ctx, key, row)
+		if err != nil {
+			return out[:i], err
+		}
+		out[i] = k
+	}
+	return out, nil
 }
 
 // interface for Seeker
@@ -301,18 +420,35 @@ func (m *StaticDataSource) CanSeek(sql *rel.SqlSelect) bool {
 }
 
 func (m *StaticDataSource) Get(key driver.Value) (schema.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.get(key)
+}
+
+func (m *StaticDataSource) get(key driver.Value) (schema.Message, error) {
 	item := m.bt.Get(NewKey(makeId(key)))
-	if item != nil {
+	if item != nil && !m.expired(item.(*DriverItem)) {
 		return item.(*DriverItem).SqlDriverMessageMap, nil
 	}
 	return nil, schema.ErrNotFound // Should not found be an error?
 }
 
+// KeyColumn the indexed column, ie rows are Get by its value
+func (m *StaticDataSource) KeyColumn() string {
+	cols := m.tbl.Columns()
+	if m.indexCol < 0 || m.indexCol >= len(cols) {
+		return ""
+	}
+	return cols[m.indexCol]
+}
+
 func (m *StaticDataSource) MultiGet(keys []driver.Value) ([]schema.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	rows := make([]schema.Message, len(keys))
 	for i, key := range keys {
 		item := m.bt.Get(NewKey(makeId(key)))
-		if item == nil {
+		if item == nil || m.expired(item.(*DriverItem)) {
 			return nil, schema.ErrNotFound
 		}
 		rows[i] = item.(*DriverItem).SqlDriverMessageMap
@@ -322,11 +458,19 @@ func (m *StaticDataSource) MultiGet(keys []driver.Value) ([]schema.Message, erro
 
 // Interface for Deletion
 func (m *StaticDataSource) Delete(key driver.Value) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.delete(key)
+}
+
+func (m *StaticDataSource) delete(key driver.Value) (int, error) {
 	item := m.bt.Delete(NewKey(makeId(key)))
 	if item == nil {
 		//u.Warnf("could not delete: %v", key)
 		return 0, schema.ErrNotFound
 	}
+	m.indexDelete(item.(*DriverItem))
+	m.publish(schema.ChangeDelete, item.(*DriverItem).Values(), nil)
 	return 1, nil
 }
 
@@ -337,49 +481,91 @@ func (m *StaticDataSource) DeleteExpression(p interface{}, where expr.Node) (int
 	if !ok {
 		return 0, plan.ErrNoPlan
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	evaluator := vm.Evaluator(where)
-	deletedKeys := make([]*Key, 0)
-	m.bt.Ascend(func(a btree.Item) bool {
-		di, ok := a.(*DriverItem)
-		if !ok {
-			u.Warnf("wat?  %T   %#v", a, a)
-			return false
+	deletedCt := 0
+	for _, di := range m.matching(where) {
+		if ct, err := m.delete(NewKey(di.IdVal)); err != nil {
+			u.Errorf("Could not delete key: %v", di.IdVal)
+		} else {
+			deletedCt += ct
+		}
+	}
+	return deletedCt, nil
+}
+
+// PatchWhere update the columns of patch (map[string]driver.Value) of the
+// rows of the where, see DeleteExpression
+func (m *StaticDataSource) PatchWhere(ctx context.Context, where expr.Node, patch interface{}) (int64, error) {
+	vals, ok := patch.(map[string]driver.Value)
+	if !ok {
+		return 0, fmt.Errorf("Expected map[string]driver.Value but got %T", patch)
+	}
+	for col := range vals {
+		if _, ok := m.tbl.FieldPositions[col]; !ok {
+			return 0, fmt.Errorf("Found column in patch that doesn't exist in cols: %v", col)
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := m.matching(where)
+	for _, di := range items {
+		row := make([]driver.Value, len(di.Values()))
+		copy(row, di.Values())
+		for col, val := range vals {
+			row[m.tbl.FieldPositions[col]] = val
+		}
+		id := di.IdVal
+		if _, patchesKey := vals[m.KeyColumn()]; patchesKey {
+			// the row moves to the id of its new key
+			m.delete(NewKey(id))
+			id = makeId(row[m.indexCol])
 		}
-		msgCtx := di.SqlDriverMessageMap
-		whereValue, ok := evaluator(msgCtx)
+		m.putRow(id, row)
+	}
+	return int64(len(items)), nil
+}
+
+// matching the rows the where (evaluated by the vm) is true for, of the
+// rows of an index seek of the where if there is one, else of all rows.
+// All rows if there is no where.
+func (m *StaticDataSource) matching(where expr.Node) []*DriverItem {
+	items := make([]*DriverItem, 0)
+	var evaluator vm.EvaluatorFunc
+	if where != nil {
+		evaluator = vm.Evaluator(where)
+	}
+	match := func(di *DriverItem) {
+		if m.expired(di) {
+			return
+		}
+		if evaluator == nil {
+			items = append(items, di)
+			return
+		}
+		whereValue, ok := evaluator(di.SqlDriverMessageMap)
 		if !ok {
-			u.Debugf("could not evaluate where: %v", msgCtx.Values())
-			//return deletedCt, fmt.Errorf("Could not evaluate where clause")
-			return true
+			u.Debugf("could not evaluate where: %v", di.Values())
+			return
 		}
-		switch whereVal := whereValue.(type) {
-		case value.BoolValue:
-			if whereVal.Val() == false {
-				//this means do NOT delete
-			} else {
-				// Delete!
-				indexVal := msgCtx.Values()[m.indexCol]
-				deletedKeys = append(deletedKeys, NewKey(makeId(indexVal)))
-			}
-		case nil:
-			// ??
-		default:
-			if whereVal.Nil() {
-				// Doesn't match, so don't delete
-			} else {
-				u.Warnf("unknown type? %T", whereVal)
+		if whereVal, isBool := whereValue.(value.BoolValue); isBool && whereVal.Val() {
+			items = append(items, di)
+		}
+	}
+	if where != nil && len(m.indexes) > 0 {
+		if seek := m.indexSeekOf(where, ""); seek != nil {
+			for _, id := range m.seekRows(seek) {
+				if item := m.bt.Get(NewKey(id)); item != nil {
+					match(item.(*DriverItem))
+				}
 			}
+			return items
 		}
+	}
+	m.bt.Ascend(func(a btree.Item) bool {
+		match(a.(*DriverItem))
 		return true
 	})
-
-	for _, deleteKey := range deletedKeys {
-		if ct, err := m.Delete(deleteKey); err != nil {
-			u.Errorf("Could not delete key: %v", deleteKey)
-		} else if ct != 1 {
-			u.Errorf("delete should have removed 1 key %v", deleteKey)
-		}
-	}
-	return len(deletedKeys), nil
+	return items
 }
@@ -3,6 +3,7 @@ package membtree
 import (
 	"database/sql/driver"
 	"flag"
+	"sync"
 	"testing"
 	"time"
 
@@ -113,3 +114,101 @@ func TestStaticDataSource(t *testing.T) {
 	assert.Equal(t, []string{"root", "admin"}, vals2[4], "Roles should match updated vals")
 	assert.Equal(t, created, vals2[3], "created date should match updated vals")
 }
+
+func TestStaticTTL(t *testing.T) {
+	defer func(interval time.Duration) { ExpiryInterval = interval }(ExpiryInterval)
+	ExpiryInterval = time.Hour
+
+	events := NewStaticDataSource("ttl_events", 0, [][]driver.Value{{int64(1), "signup"}}, []string{"id", "event"})
+	events.SetTTL(time.Hour)
+	assert.Equal(t, []string{"id", "event", "expires_at"}, events.Columns())
+	assert.Equal(t, time.Hour, events.TTL())
+	_, err := events.Put(nil, nil, []driver.Value{int64(2), "logon"})
+	assert.Tf(t, err == nil, "%v", err)
+	for _, id := range []int64{1, 2} {
+		row, err := events.Get(id)
+		assert.Tf(t, err == nil, "%v", err)
+		expires, ok := row.Body().(*datasource.SqlDriverMessageMap).Values()[2].(time.Time)
+		assert.Tf(t, ok && expires.After(time.Now().Add(59*time.Minute)), "expires in an hour %v", expires)
+	}
+
+	// expired rows are not read, before they are deleted
+	sub, _ := events.Subscribe("")
+	events.SetTTL(time.Millisecond)
+	events.Put(nil, nil, []driver.Value{int64(3), "logoff", nil})
+	time.Sleep(5 * time.Millisecond)
+	_, err = events.Get(int64(3))
+	assert.Equal(t, schema.ErrNotFound, err)
+	ct := 0
+	for msg := events.Next(); msg != nil; msg = events.Next() {
+		ct++
+	}
+	assert.Equal(t, 2, ct)
+	assert.Equal(t, 3, events.Length())
+
+	// those put before the ttl changed expire an hour after their put
+	assert.Equal(t, 1, events.Expire())
+	assert.Equal(t, 2, events.Length())
+	<-sub.Changes() // the insert
+	change := <-sub.Changes()
+	assert.Equal(t, schema.ChangeDelete, change.Op)
+	assert.Equal(t, int64(3), change.Key)
+
+	// expired rows are deleted in the background
+	ExpiryInterval = time.Millisecond
+	events.PatchWhere(nil, nil, map[string]driver.Value{"event": "seen"})
+	events.SetTTL(time.Millisecond)
+	for i := 0; i < 100 && events.Length() > 0; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, 0, events.Length())
+	events.SetTTL(0)
+	events.Put(nil, nil, []driver.Value{int64(4), "signup"})
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, 0, events.Expire())
+	assert.Equal(t, 1, events.Length())
+}
+
+func TestStaticPartitions(t *testing.T) {
+	data := make([][]driver.Value, 10)
+	for i := range data {
+		data[i] = []driver.Value{int64(i), "user"}
+	}
+	users := NewStaticDataSource("part_users", 0, data, []string{"id", "name"})
+	assert.Equal(t, 0, len(users.Partitions()))
+
+	tbl, _ := users.Table("part_users")
+	tbl.PartitionCt = 3
+	parts := users.Partitions()
+	assert.Equal(t, 3, len(parts))
+	assert.Equal(t, "0", parts[0].Left)
+	assert.Equal(t, parts[1].Left, parts[0].Right)
+	assert.Equal(t, "", parts[2].Right)
+
+	// the partitions scan every row once, concurrently
+	ids := make(chan int64, 10)
+	var wg sync.WaitGroup
+	for _, part := range parts {
+		conn, err := users.PartitionSource(part)
+		assert.Tf(t, err == nil, "%v", err)
+		wg.Add(1)
+		go func(scanner schema.ConnScanner) {
+			defer wg.Done()
+			for msg := scanner.Next(); msg != nil; msg = scanner.Next() {
+				ids <- msg.Body().(*datasource.SqlDriverMessageMap).Values()[0].(int64)
+			}
+			scanner.Close()
+		}(conn.(schema.ConnScanner))
+	}
+	wg.Wait()
+	close(ids)
+	seen := make(map[int64]bool)
+	for id := range ids {
+		assert.Tf(t, !seen[id], "scanned once %v", id)
+		seen[id] = true
+	}
+	assert.Equal(t, 10, len(seen))
+
+	_, err := users.PartitionSource(&schema.Partition{Id: "bad", Left: "x"})
+	assert.T(t, err != nil)
+}
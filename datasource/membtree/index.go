@@ -0,0 +1,386 @@
+package membtree
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/btree"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// index a secondary index of a column, a btree of the value of the
+// column of each row and the row id (its primary key)
+type index struct {
+	col int
+	bt  *btree.BTree
+}
+
+// indexItem an entry of an index, ordered by value then row id
+type indexItem struct {
+	val driver.Value
+	id  uint64
+}
+
+func (m *indexItem) Less(than btree.Item) bool {
+	it := than.(*indexItem)
+	if c := compareValues(m.val, it.val); c != 0 {
+		return c < 0
+	}
+	return m.id < it.id
+}
+
+// indexSeek the rows of an index seek, pushed down as the native form of
+// the where (see Translate)
+type indexSeek struct {
+	col    string
+	eq     []driver.Value // equal to any of, else in the range
+	lo, hi driver.Value   // bounds of the range, nil if none
+	loIncl bool
+	hiIncl bool
+}
+
+// AddIndex add a secondary index of column col, of the rows already in the
+// source and those put after.  Equality (=, IN) and range (<, <=, >, >=,
+// BETWEEN) predicates of the column in the where of a select seek the
+// index instead of scanning all rows.
+func (m *StaticDataSource) AddIndex(col string) error {
+	pos, ok := m.tbl.FieldPositions[col]
+	if !ok {
+		return fmt.Errorf("no column %q to index in %q", col, m.name)
+	}
+	if m.indexes == nil {
+		m.indexes = make(map[string]*index)
+	}
+	if _, exists := m.indexes[col]; exists {
+		return nil
+	}
+	idx := &index{col: pos, bt: btree.New(32)}
+	m.bt.Ascend(func(a btree.Item) bool {
+		idx.put(a.(*DriverItem))
+		return true
+	})
+	m.indexes[col] = idx
+	m.tbl.Indexes = append(m.tbl.Indexes, &schema.Index{Name: col, Fields: []string{col}})
+	return nil
+}
+
+func (m *index) put(item *DriverItem) {
+	m.bt.ReplaceOrInsert(&indexItem{indexKey(item.Values()[m.col]), item.IdVal})
+}
+
+func (m *index) delete(item *DriverItem) {
+	m.bt.Delete(&indexItem{indexKey(item.Values()[m.col]), item.IdVal})
+}
+
+// indexPut index the row item, replacing the entries of the row it replaced
+func (m *StaticDataSource) indexPut(item, replaced *DriverItem) {
+	for _, idx := range m.indexes {
+		if replaced != nil {
+			idx.delete(replaced)
+		}
+		idx.put(item)
+	}
+}
+
+// indexDelete remove the entries of the deleted row item
+func (m *StaticDataSource) indexDelete(item *DriverItem) {
+	for _, idx := range m.indexes {
+		idx.delete(item)
+	}
+}
+
+// Translate the where into a seek of a secondary index, of the first
+// predicate of an indexed column of the where (or of its AND-ed
+// expressions).  The rows of the seek are a superset of those of the
+// where, which is still evaluated.
+func (m *StaticDataSource) Translate(node expr.Node) (interface{}, error) {
+	if seek := m.indexSeekOf(node, ""); seek != nil {
+		return seek, nil
+	}
+	return nil, fmt.Errorf("no indexed column predicate in %s", node)
+}
+
+// TranslateIndex the where into a seek of the index of a USE_INDEX hint
+// (named for its column), of the first predicate of its column.
+func (m *StaticDataSource) TranslateIndex(node expr.Node, index string) (interface{}, error) {
+	if seek := m.indexSeekOf(node, index); seek != nil {
+		return seek, nil
+	}
+	return nil, fmt.Errorf("no predicate of index %q in %s", index, node)
+}
+
+// indexSeekOf the seek of the first predicate of an indexed column of node,
+// of only the index named index if not ""
+func (m *StaticDataSource) indexSeekOf(node expr.Node, index string) *indexSeek {
+	switch n := node.(type) {
+	case *expr.BinaryNode:
+		if len(n.Args) != 2 {
+			return nil
+		}
+		if n.Operator.T == lex.TokenLogicAnd {
+			if seek := m.indexSeekOf(n.Args[0], index); seek != nil {
+				return seek
+			}
+			return m.indexSeekOf(n.Args[1], index)
+		}
+		if n.Operator.T == lex.TokenIN {
+			col := m.indexedColumn(n.Args[0], index)
+			arr, ok := n.Args[1].(*expr.ArrayNode)
+			if col == "" || !ok {
+				return nil
+			}
+			seek := &indexSeek{col: col}
+			for _, arg := range arr.Args {
+				val, ok := literal(arg)
+				if !ok {
+					return nil
+				}
+				seek.eq = append(seek.eq, indexKey(val))
+			}
+			return seek
+		}
+		op := n.Operator.T
+		colNode, litNode := n.Args[0], n.Args[1]
+		if m.indexedColumn(colNode, index) == "" {
+			colNode, litNode = litNode, colNode
+			op = flipped[op]
+		}
+		col := m.indexedColumn(colNode, index)
+		val, ok := literal(litNode)
+		if col == "" || !ok || val == nil {
+			return nil
+		}
+		if op == lex.TokenEqual || op == lex.TokenEqualEqual {
+			return &indexSeek{col: col, eq: []driver.Value{indexKey(val)}}
+		}
+		if val, ok = rangeBound(val); !ok {
+			return nil
+		}
+		switch op {
+		case lex.TokenGT, lex.TokenGE:
+			return &indexSeek{col: col, lo: val, loIncl: op == lex.TokenGE}
+		case lex.TokenLT, lex.TokenLE:
+			return &indexSeek{col: col, hi: val, hiIncl: op == lex.TokenLE}
+		}
+	case *expr.TriNode:
+		if n.Operator.T != lex.TokenBetween || len(n.Args) != 3 {
+			return nil
+		}
+		col := m.indexedColumn(n.Args[0], index)
+		lo, lok := literal(n.Args[1])
+		hi, hok := literal(n.Args[2])
+		if col == "" || !lok || !hok {
+			return nil
+		}
+		if lo, lok = rangeBound(lo); !lok {
+			return nil
+		}
+		if hi, hok = rangeBound(hi); !hok {
+			return nil
+		}
+		return &indexSeek{col: col, lo: lo, hi: hi, loIncl: true, hiIncl: true}
+	}
+	return nil
+}
+
+// flipped the operator of a comparison of reversed args, ie 3 < x to x > 3
+var flipped = map[lex.TokenType]lex.TokenType{
+	lex.TokenEqual:      lex.TokenEqual,
+	lex.TokenEqualEqual: lex.TokenEqualEqual,
+	lex.TokenGT:         lex.TokenLT,
+	lex.TokenGE:         lex.TokenLE,
+	lex.TokenLT:         lex.TokenGT,
+	lex.TokenLE:         lex.TokenGE,
+}
+
+// indexedColumn the column of an identity node, if it is indexed (by the
+// index named index if not "")
+func (m *StaticDataSource) indexedColumn(node expr.Node, index string) string {
+	in, ok := node.(*expr.IdentityNode)
+	if !ok {
+		return ""
+	}
+	_, name, _ := in.LeftRight()
+	for col := range m.indexes {
+		if strings.EqualFold(col, name) && (index == "" || strings.EqualFold(col, index)) {
+			return col
+		}
+	}
+	return ""
+}
+
+// literal the value of a literal node
+func literal(node expr.Node) (driver.Value, bool) {
+	switch n := node.(type) {
+	case *expr.StringNode:
+		return n.Text, true
+	case *expr.NumberNode:
+		if n.IsInt {
+			return n.Int64, true
+		}
+		return n.Float64, true
+	case *expr.ValueNode:
+		if n.Value == nil {
+			return nil, false
+		}
+		return n.Value.Value(), true
+	}
+	return nil, false
+}
+
+// rangeBound the index key of a bound of a range, not of strings of numbers
+// which the vm compares as strings with strings but as numbers with numbers
+func rangeBound(val driver.Value) (driver.Value, bool) {
+	key := indexKey(val)
+	if _, isString := val.(string); key == nil || (isString && key != val) {
+		return nil, false
+	}
+	return key, true
+}
+
+// SetNative the index seek pushed down by the planner (see Translate), the
+// next scan reads only its rows
+func (m *StaticDataSource) SetNative(native interface{}) {
+	if seek, ok := native.(*indexSeek); ok {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.seek = seek
+		m.seekIds = nil
+	}
+}
+
+// seekRows the row ids of the index seek, in index order
+func (m *StaticDataSource) seekRows(seek *indexSeek) []uint64 {
+	idx := m.indexes[seek.col]
+	if idx == nil {
+		return nil
+	}
+	ids := make([]uint64, 0)
+	if len(seek.eq) > 0 {
+		for _, val := range seek.eq {
+			idx.bt.AscendGreaterOrEqual(&indexItem{val: val}, func(a btree.Item) bool {
+				it := a.(*indexItem)
+				if compareValues(it.val, val) != 0 {
+					return false
+				}
+				ids = append(ids, it.id)
+				return true
+			})
+		}
+		return ids
+	}
+	visit := func(a btree.Item) bool {
+		it := a.(*indexItem)
+		if it.val == nil {
+			// nulls are not in any range
+			return true
+		}
+		if seek.lo != nil {
+			if c := compareValues(it.val, seek.lo); c < 0 || (c == 0 && !seek.loIncl) {
+				return true
+			}
+		}
+		if seek.hi != nil {
+			if c := compareValues(it.val, seek.hi); c > 0 || (c == 0 && !seek.hiIncl) {
+				return false
+			}
+		}
+		ids = append(ids, it.id)
+		return true
+	}
+	if seek.lo != nil {
+		idx.bt.AscendGreaterOrEqual(&indexItem{val: seek.lo}, visit)
+	} else {
+		idx.bt.Ascend(visit)
+	}
+	return ids
+}
+
+// indexKey the value of a column as it is indexed.  Numbers (and strings
+// of numbers, as the vm compares them with numbers) are float64, other
+// strings and bytes strings.
+func indexKey(v driver.Value) driver.Value {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint32:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	case []byte:
+		return indexKey(string(n))
+	case string:
+		if f, err := strconv.ParseFloat(n, 64); err == nil {
+			return f
+		}
+	}
+	return v
+}
+
+// compareValues order of index keys, nulls first then by type (bools,
+// numbers, strings, times) then value
+func compareValues(a, b driver.Value) int {
+	ra, rb := valueRank(a), valueRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+	switch ra {
+	case 1:
+		ab, bb := a.(bool), b.(bool)
+		if ab == bb {
+			return 0
+		} else if !ab {
+			return -1
+		}
+		return 1
+	case 2:
+		af, bf := a.(float64), b.(float64)
+		if af < bf {
+			return -1
+		} else if af > bf {
+			return 1
+		}
+		return 0
+	case 3:
+		return strings.Compare(a.(string), b.(string))
+	case 4:
+		at, bt := a.(time.Time), b.(time.Time)
+		if at.Before(bt) {
+			return -1
+		} else if at.After(bt) {
+			return 1
+		}
+		return 0
+	case 5:
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+	return 0
+}
+
+func valueRank(v driver.Value) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case float64:
+		return 2
+	case string:
+		return 3
+	case time.Time:
+		return 4
+	}
+	return 5
+}
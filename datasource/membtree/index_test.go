@@ -0,0 +1,213 @@
+package membtree_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+func seekIds(t *testing.T, src *membtree.StaticDataSource, where string) string {
+	node, err := expr.ParseExpression(where)
+	assert.Tf(t, err == nil, "no error %v", err)
+	native, err := src.Translate(node.Root)
+	if err != nil {
+		return "scan"
+	}
+	src.SetNative(native)
+	ids := make([]string, 0)
+	for msg := src.Next(); msg != nil; msg = src.Next() {
+		ids = append(ids, fmt.Sprintf("%v", msg.(*datasource.SqlDriverMessageMap).Values()[0]))
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestSecondaryIndex(t *testing.T) {
+	src := membtree.NewStaticDataSource("people", 0, [][]driver.Value{
+		{int64(1), "aaron", "sf", int64(30)},
+		{int64(2), "bob", "nyc", int64(25)},
+		{int64(3), "carol", "sf", int64(41)},
+		{int64(4), "dan", nil, "35"},
+	}, []string{"id", "name", "city", "age"})
+	assert.T(t, src.AddIndex("city") == nil)
+	assert.T(t, src.AddIndex("age") == nil)
+	assert.T(t, src.AddIndex("nope") != nil)
+
+	tests := []struct {
+		where string
+		ids   string
+	}{
+		{`city = "sf"`, "1,3"},
+		{`"nyc" = city`, "2"},
+		{`city IN ("nyc", "la", "sf")`, "2,1,3"},
+		{`age > 30`, "4,3"},
+		{`30 >= age`, "2,1"},
+		{`age BETWEEN 25 AND 35`, "2,1,4"},
+		{`age = "35"`, "4"},
+		{`name = "bob" AND city = "nyc"`, "2"},
+		// strings of numbers compare as strings with strings
+		{`age < "4"`, "scan"},
+		{`name = "bob" OR city = "nyc"`, "scan"},
+		{`name = "bob"`, "scan"},
+	}
+	for _, tt := range tests {
+		assert.Equalf(t, tt.ids, seekIds(t, src, tt.where), "where %s", tt.where)
+	}
+
+	// the indexes follow puts and deletes
+	src.Put(nil, nil, []driver.Value{int64(3), "carol", "la", int64(41)})
+	src.Put(nil, nil, []driver.Value{int64(5), "eve", "sf", int64(22)})
+	_, err := src.Delete(int64(1))
+	assert.T(t, err == nil)
+	assert.Equal(t, "5", seekIds(t, src, `city = "sf"`))
+	assert.Equal(t, "3", seekIds(t, src, `city = "la"`))
+	assert.Equal(t, "5,2", seekIds(t, src, `age < 30`))
+
+	// selects seek the index, rows of the seek still filtered by the where
+	people := datasource.RegisterSchemaSource("people", "people", src)
+	names, ctx := selectNames(t, people, `SELECT name FROM people WHERE city = "sf" AND age > 20`)
+	assert.Equal(t, "eve", names)
+	accepted, _ := ctx.Metrics.PushdownCounts()
+	assert.Equal(t, 1, accepted)
+
+	// rows are in the order of the index seeked, age unless hinted city
+	names, _ = selectNames(t, people, `SELECT name FROM people WHERE age > 20 AND city IN ("la", "sf")`)
+	assert.Equal(t, "eve,carol", names)
+	names, ctx = selectNames(t, people, `/*+ USE_INDEX(people, city) */ SELECT name FROM people WHERE age > 20 AND city IN ("la", "sf")`)
+	assert.Equal(t, "carol,eve", names)
+	assert.Equal(t, 0, len(ctx.Warnings))
+
+	// an index that can not seek the where, or no such index, is ignored
+	names, ctx = selectNames(t, people, `/*+ USE_INDEX(people, city) */ SELECT name FROM people WHERE age > 20`)
+	assert.Equal(t, "eve,bob,dan,carol", names)
+	assert.Equal(t, 1, len(ctx.Warnings))
+	names, ctx = selectNames(t, people, `/*+ USE_INDEX(people, nope) */ SELECT name FROM people WHERE age > 20`)
+	assert.Equal(t, "eve,bob,dan,carol", names)
+	assert.Equal(t, []string{`index "nope" not found on "people", USE_INDEX hint ignored`}, ctx.Warnings)
+
+	// BETWEEN is inclusive seeked or evaluated in-process
+	names, ctx = selectNames(t, people, `SELECT name FROM people WHERE age BETWEEN 25 AND 41`)
+	assert.Equal(t, "bob,carol", names)
+	accepted, _ = ctx.Metrics.PushdownCounts()
+	assert.Equal(t, 1, accepted)
+	names, ctx = selectNames(t, people, `/*+ NO_PUSHDOWN */ SELECT name FROM people WHERE age BETWEEN 25 AND 41`)
+	assert.Equal(t, "bob,carol", names)
+	accepted, _ = ctx.Metrics.PushdownCounts()
+	assert.Equal(t, 0, accepted)
+}
+
+// seekOnly a source that seeks its indexes of USE_INDEX hints but does not
+// filter (or translate) other wheres
+type seekOnly struct {
+	*membtree.StaticDataSource
+}
+
+func (m *seekOnly) Open(table string) (schema.Conn, error) { return m, nil }
+func (m *seekOnly) Capabilities() *schema.Capabilities     { return &schema.Capabilities{Scan: true} }
+
+func TestUseIndexHint(t *testing.T) {
+	src := membtree.NewStaticDataSource("seekers", 0, [][]driver.Value{
+		{int64(1), "aaron", "sf", int64(30)},
+		{int64(2), "bob", "nyc", int64(25)},
+		{int64(3), "carol", "sf", int64(41)},
+	}, []string{"id", "name", "city", "age"})
+	assert.T(t, src.AddIndex("city") == nil)
+	seekers := datasource.RegisterSchemaSource("seekers", "seekers", &seekOnly{src})
+
+	names, ctx := selectNames(t, seekers, `/*+ USE_INDEX(seekers, city) */ SELECT name FROM seekers WHERE city = "sf" AND age > 35`)
+	assert.Equal(t, "carol", names)
+	assert.Equal(t, 0, len(ctx.Warnings))
+	assert.Equal(t, []string{"use-index"}, rulesOf(ctx, "use-index"))
+	accepted, _ := ctx.Metrics.PushdownCounts()
+	assert.Equal(t, 1, accepted)
+
+	// not applied, the hint is reported ignored
+	for _, sql := range []string{
+		`/*+ NO_PUSHDOWN USE_INDEX(seekers, city) */ SELECT name FROM seekers WHERE city = "sf" AND age > 35`,
+		`/*+ USE_INDEX(seekers, city) */ SELECT name FROM seekers WHERE age > 35`,
+	} {
+		names, ctx = selectNames(t, seekers, sql)
+		assert.Equalf(t, "carol", names, "names of %s", sql)
+		assert.Equalf(t, 1, len(ctx.Warnings), "warnings of %s %v", sql, ctx.Warnings)
+		assert.Equalf(t, 0, len(rulesOf(ctx, "use-index")), "rules of %s", sql)
+	}
+}
+
+// rulesOf the rules of name the planner applied
+func rulesOf(ctx *plan.Context, name string) []string {
+	rules := make([]string, 0)
+	if ctx.Metrics != nil {
+		for _, rule := range ctx.Metrics.Rules {
+			if rule == name {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	return rules
+}
+
+// selectNames the names of the rows of the select, in the order returned
+func selectNames(t *testing.T, s *schema.Schema, sql string) (string, *plan.Context) {
+	ctx := plan.NewContext(sql)
+	ctx.Schema = s
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	rows := exec.NewResultRows(ctx, []string{"name"})
+	job.RootTask.Add(rows)
+	assert.T(t, job.Setup() == nil)
+	go job.Run()
+	names := make([]string, 0)
+	dest := make([]driver.Value, 1)
+	for rows.Next(dest) == nil {
+		names = append(names, dest[0].(string))
+	}
+	job.Close()
+	return strings.Join(names, ","), ctx
+}
+
+func TestMutateWhere(t *testing.T) {
+	src := membtree.NewStaticDataSource("accounts", 0, [][]driver.Value{
+		{int64(1), "aaron", "sf", int64(30)},
+		{int64(2), "bob", "nyc", int64(25)},
+		{int64(3), "carol", "sf", int64(41)},
+		{int64(4), "dan", "la", int64(35)},
+	}, []string{"id", "name", "city", "age"})
+	assert.T(t, src.AddIndex("city") == nil)
+	datasource.RegisterSchemaSource("accounts", "accounts", src)
+
+	tests := []struct {
+		sql      string
+		affected int64
+		ids      string // of the rows left in sf
+	}{
+		{`UPDATE accounts SET city = "sf" WHERE age > 30 AND name != "carol"`, 1, "1,3,4"},
+		{`UPDATE accounts SET id = 7 WHERE name = "aaron"`, 1, "3,4,7"},
+		{`DELETE FROM accounts WHERE city = "sf" AND age < 40`, 2, "3"},
+		{`UPDATE accounts SET age = 1 WHERE name = "nobody"`, 0, "3"},
+		{`DELETE FROM accounts`, 2, ""},
+	}
+	for _, tt := range tests {
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("accounts")
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+		err = job.Run()
+		assert.Tf(t, err == nil, "no error %v", err)
+		job.Close()
+		assert.Equalf(t, tt.affected, msgs[0].Body().([]driver.Value)[1], "sql %s", tt.sql)
+		assert.Equalf(t, tt.ids, seekIds(t, src, `city = "sf"`), "sql %s", tt.sql)
+	}
+	assert.Equal(t, 0, src.Length())
+}
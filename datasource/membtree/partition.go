@@ -0,0 +1,131 @@
+package membtree
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/google/btree"
+
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	// PartitionSize rows per partition of a table without a PartitionCt,
+	// tables of fewer rows are not partitioned
+	PartitionSize = 100000
+
+	// Different Features of the partition scans
+	_ schema.ConnScanner = (*partitionConn)(nil)
+	_ schema.ConnColumns = (*partitionConn)(nil)
+)
+
+// Partitions split the table into ranges of row ids (the btree keys) of
+// about the same number of rows, the table PartitionCt of them if set, else
+// one per PartitionSize rows.  Left is the first row id of the partition,
+// Right the first row id of the next ("" for the last), so partitions may be
+// sent to (and scanned by) other nodes.
+func (m *StaticDataSource) Partitions() []*schema.Partition {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rows := m.bt.Len()
+	ct := m.tbl.PartitionCt
+	if ct <= 0 && PartitionSize > 0 {
+		ct = rows / PartitionSize
+	}
+	if ct > rows {
+		ct = rows
+	}
+	if ct < 2 {
+		return nil
+	}
+	starts := make([]uint64, 0, ct)
+	i := 0
+	m.bt.Ascend(func(a btree.Item) bool {
+		if i*ct/rows >= len(starts) {
+			starts = append(starts, a.(*DriverItem).IdVal)
+		}
+		i++
+		return true
+	})
+	parts := make([]*schema.Partition, len(starts))
+	for i, start := range starts {
+		parts[i] = &schema.Partition{Id: fmt.Sprintf("%s-%d", m.name, i), Left: strconv.FormatUint(start, 10)}
+		if i+1 < len(starts) {
+			parts[i].Right = strconv.FormatUint(starts[i+1], 10)
+		}
+	}
+	return parts
+}
+
+// PartitionSource a scan of the rows of partition p, of its own cursor so
+// partitions may be scanned in parallel
+func (m *StaticDataSource) PartitionSource(p *schema.Partition) (schema.Conn, error) {
+	conn := &partitionConn{src: m}
+	if p.Left != "" {
+		left, err := strconv.ParseUint(p.Left, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid left %q of partition %s: %v", p.Left, p.Id, err)
+		}
+		conn.left = NewKey(left)
+	}
+	if p.Right != "" {
+		right, err := strconv.ParseUint(p.Right, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid right %q of partition %s: %v", p.Right, p.Id, err)
+		}
+		conn.right = NewKey(right)
+	}
+	return conn, nil
+}
+
+// partitionConn a scan of the rows of ids in [left, right) of the btree
+type partitionConn struct {
+	src         *StaticDataSource
+	left, right *Key // nil for unbounded
+	cursor      btree.Item
+	done        bool
+}
+
+func (m *partitionConn) Columns() []string { return m.src.Columns() }
+
+func (m *partitionConn) Next() schema.Message {
+	if m.done {
+		return nil
+	}
+	m.src.mu.Lock()
+	defer m.src.mu.Unlock()
+	var item *DriverItem
+	iter := func(a btree.Item) bool {
+		if a == m.cursor {
+			return true
+		}
+		if m.right != nil && !a.Less(m.right) {
+			return false
+		}
+		if m.src.expired(a.(*DriverItem)) {
+			m.cursor = a
+			return true
+		}
+		item = a.(*DriverItem)
+		return false
+	}
+	switch {
+	case m.cursor != nil:
+		m.src.bt.AscendGreaterOrEqual(m.cursor, iter)
+	case m.left != nil:
+		m.src.bt.AscendGreaterOrEqual(m.left, iter)
+	default:
+		m.src.bt.Ascend(iter)
+	}
+	if item == nil {
+		m.done = true
+		return nil
+	}
+	m.cursor = item
+	return item.SqlDriverMessageMap.Copy()
+}
+
+func (m *partitionConn) Close() error {
+	m.cursor, m.done = nil, true
+	return nil
+}
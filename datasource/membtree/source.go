@@ -0,0 +1,116 @@
+package membtree
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	// Different Features of the in-memory btree Source of many tables
+	_ schema.Source            = (*Source)(nil)
+	_ schema.SourceTableSchema = (*Source)(nil)
+	_ schema.TableCreator      = (*Source)(nil)
+	_ datasource.MeteredSource = (*Source)(nil)
+)
+
+// Source a DataSource of many in-memory btree tables, each a
+// StaticDataSource, created by CREATE TABLE (see CreateTable) or added.
+// The rows of a table are keyed on the first column of its primary key,
+// else on its first column.
+type Source struct {
+	mu      sync.RWMutex
+	tables  map[string]*StaticDataSource
+	names   []string
+	metrics datasource.Metrics
+}
+
+// NewSource an in-memory btree Source of no tables
+func NewSource() *Source {
+	return &Source{tables: make(map[string]*StaticDataSource)}
+}
+
+// AddTable add (or replace) the table of ds
+func (m *Source) AddTable(ds *StaticDataSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tables == nil {
+		m.tables = make(map[string]*StaticDataSource)
+	}
+	name := strings.ToLower(ds.name)
+	if _, ok := m.tables[name]; !ok {
+		m.names = append(m.names, name)
+		sort.Strings(m.names)
+	}
+	m.tables[name] = ds
+}
+
+// CreateTable create an empty table of the fields of tbl, a no-op if it
+// exists
+func (m *Source) CreateTable(tbl *schema.Table) error {
+	if len(tbl.Fields) == 0 {
+		return fmt.Errorf("table %q has no columns to create", tbl.Name)
+	}
+	if _, err := m.source(tbl.Name); err == nil {
+		return nil
+	}
+	cols := make([]string, len(tbl.Fields))
+	for i, f := range tbl.Fields {
+		cols[i] = f.Name
+	}
+	keyCol := 0
+	if pk := tbl.PrimaryKey(); len(pk) > 0 {
+		pos, ok := tbl.FieldPositions[pk[0]]
+		if !ok {
+			return fmt.Errorf("primary key %q is not a column of %q", pk[0], tbl.Name)
+		}
+		keyCol = pos
+	}
+	ds := NewStaticDataSource(tbl.Name, keyCol, nil, cols)
+	for _, f := range tbl.Fields {
+		ds.tbl.AddField(f)
+	}
+	ds.tbl.Indexes = tbl.Indexes
+	m.AddTable(ds)
+	return nil
+}
+
+func (m *Source) source(table string) (*StaticDataSource, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ds, ok := m.tables[strings.ToLower(table)]
+	if !ok {
+		return nil, schema.ErrNotFound
+	}
+	return ds, nil
+}
+
+func (m *Source) Tables() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.names...)
+}
+
+func (m *Source) Table(table string) (*schema.Table, error) {
+	ds, err := m.source(table)
+	if err != nil {
+		return nil, err
+	}
+	return ds.tbl, nil
+}
+
+func (m *Source) Open(table string) (schema.Conn, error) {
+	ds, err := m.source(table)
+	if err != nil {
+		return nil, err
+	}
+	return ds.Open(table)
+}
+
+func (m *Source) Close() error { return nil }
+
+func (m *Source) Metrics() *datasource.Metrics { return &m.metrics }
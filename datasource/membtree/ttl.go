@@ -0,0 +1,137 @@
+package membtree
+
+import (
+	"database/sql/driver"
+	"time"
+
+	"github.com/google/btree"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	// ExpiresAtColumn the column of when a row of a table with a ttl expires
+	ExpiresAtColumn = "expires_at"
+
+	// ExpiryInterval how often expired rows are deleted in the background
+	ExpiryInterval = time.Second
+)
+
+// SetTTL expire the rows of the table ttl after they were last written, ie
+// to serve it as a cache of recent events.  Adds the expires_at column of
+// when each row expires, set on each put (or patch) of the row, it need not
+// be in the rows put.  Expired rows are not read, and are deleted (and
+// published to subscribers as deletes) in the background every
+// ExpiryInterval.  A ttl of 0 stops expiring rows.
+//
+//   events := membtree.NewStaticDataSource("events", 0, nil, []string{"id", "name"})
+//   events.SetTTL(time.Minute)
+//   // SELECT id, name, expires_at FROM events
+func (m *StaticDataSource) SetTTL(ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ttl = ttl
+	if ttl > 0 && m.expiresIdx == 0 {
+		cols := m.tbl.Columns()
+		m.expiresIdx = len(cols)
+		m.tbl.SetColumns(append(cols[:len(cols):len(cols)], ExpiresAtColumn))
+		m.tbl.AddField(schema.NewFieldBase(ExpiresAtColumn, value.TimeType, 32, "time"))
+		// the rows already put expire ttl from now
+		items := make([]*DriverItem, 0, m.bt.Len())
+		m.bt.Ascend(func(a btree.Item) bool {
+			items = append(items, a.(*DriverItem))
+			return true
+		})
+		for _, di := range items {
+			row := m.withExpiry(di.Values())
+			m.bt.ReplaceOrInsert(&DriverItem{datasource.NewSqlDriverMessageMap(di.IdVal, row, m.tbl.FieldPositions)})
+		}
+	}
+	if m.stopExpiry != nil {
+		close(m.stopExpiry)
+		m.stopExpiry = nil
+	}
+	if ttl > 0 {
+		m.stopExpiry = make(chan struct{})
+		go m.expireEvery(ExpiryInterval, m.stopExpiry)
+	}
+}
+
+// TTL how long after they are written rows expire, 0 if they do not
+func (m *StaticDataSource) TTL() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ttl
+}
+
+// Expire delete the rows that have expired, returns how many
+func (m *StaticDataSource) Expire() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ttl <= 0 {
+		return 0
+	}
+	now := time.Now()
+	expired := make([]*DriverItem, 0)
+	m.bt.Ascend(func(a btree.Item) bool {
+		if di := a.(*DriverItem); m.expiredAt(di, now) {
+			expired = append(expired, di)
+		}
+		return true
+	})
+	for _, di := range expired {
+		m.delete(NewKey(di.IdVal))
+	}
+	return len(expired)
+}
+
+func (m *StaticDataSource) expireEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.Expire()
+		}
+	}
+}
+
+// withExpiry the row of a put, with its expires_at set if the table has a
+// ttl.  The row may leave out expires_at.
+func (m *StaticDataSource) withExpiry(row []driver.Value) []driver.Value {
+	if m.expiresIdx == 0 {
+		return row
+	}
+	out := make([]driver.Value, m.expiresIdx+1)
+	copy(out, row)
+	if m.ttl > 0 {
+		out[m.expiresIdx] = time.Now().Add(m.ttl)
+	} else {
+		out[m.expiresIdx] = nil
+	}
+	return out
+}
+
+// rowLenOk if a row put of n values is of the columns of the table, with
+// or without expires_at
+func (m *StaticDataSource) rowLenOk(n int) bool {
+	return n == len(m.tbl.Columns()) || (m.expiresIdx > 0 && n == m.expiresIdx)
+}
+
+// expired if the row has expired, and is not to be read
+func (m *StaticDataSource) expired(di *DriverItem) bool {
+	return m.ttl > 0 && m.expiredAt(di, time.Now())
+}
+
+func (m *StaticDataSource) expiredAt(di *DriverItem, now time.Time) bool {
+	vals := di.Values()
+	if m.expiresIdx == 0 || m.expiresIdx >= len(vals) {
+		return false
+	}
+	expires, ok := vals[m.expiresIdx].(time.Time)
+	return ok && !expires.After(now)
+}
@@ -27,6 +27,7 @@ var (
 
 	// Different Features of this Static Data Source
 	_ schema.Source            = (*MemDb)(nil)
+	_ datasource.MeteredSource = (*MemDb)(nil)
 	_ schema.SourceTableSchema = (*MemDb)(nil)
 
 	// Connection
@@ -36,6 +37,10 @@ var (
 	_ schema.ConnUpsert   = (*dbConn)(nil)
 	_ schema.ConnDeletion = (*dbConn)(nil)
 	_ schema.ConnSeeker   = (*dbConn)(nil)
+
+	// Transactions
+	_ schema.ConnTransaction = (*dbConn)(nil)
+	_ schema.Transaction     = (*dbTx)(nil)
 )
 
 // MemDb implements qlbridge `Source` to allow in-memory native go data
@@ -52,12 +57,20 @@ type MemDb struct {
 	primaryIndex   string
 	db             *memdb.MemDB
 	max            int
+	metrics        datasource.Metrics
 }
 type dbConn struct {
 	md     *MemDb
 	db     *memdb.MemDB
 	txn    *memdb.Txn
 	result memdb.ResultIterator
+	wtxn   *memdb.Txn // write txn of a transaction, nil to commit each write
+}
+
+// dbTx a transaction of writes of one write txn of the memdb, there is only
+// one writer of a memdb at once so other writes wait for it to end.
+type dbTx struct {
+	*dbConn
 }
 
 // NewMemDbData creates a MemDb with given indexes, columns, and values
@@ -123,6 +136,8 @@ func (m *MemDb) Table(table string) (*schema.Table, error) { return m.tbl, nil }
 // Close this source
 func (m *MemDb) Close() error { return nil }
 
+func (m *MemDb) Metrics() *datasource.Metrics { return &m.metrics }
+
 // Tables list, should be single table
 func (m *MemDb) Tables() []string { return []string{m.tbl.Name} }
 
@@ -213,13 +228,13 @@ func (m *dbConn) Put(ctx context.Context, key schema.Key, row interface{}) (sche
 	//u.Infof("%p Put(),  row:%#v", m, row)
 	switch rowVals := row.(type) {
 	case []driver.Value:
-		txn := m.db.Txn(true)
+		txn := m.writeTxn()
 		key, err := m.putValues(txn, rowVals)
 		if err != nil {
-			txn.Abort()
+			m.abort(txn)
 			return nil, err
 		}
-		txn.Commit()
+		m.commit(txn)
 		return key, nil
 		/*
 			case map[string]driver.Value:
@@ -291,20 +306,19 @@ func (m *dbConn) putValues(txn *memdb.Txn, row []driver.Value) (schema.Key, erro
 }
 
 func (m *dbConn) PutMulti(ctx context.Context, keys []schema.Key, objs interface{}) ([]schema.Key, error) {
-	txn := m.db.Txn(true)
-
 	switch rows := objs.(type) {
 	case [][]driver.Value:
+		txn := m.writeTxn()
 		keys := make([]schema.Key, 0, len(rows))
 		for _, row := range rows {
 			key, err := m.putValues(txn, row)
 			if err != nil {
-				txn.Abort()
+				m.abort(txn)
 				return nil, err
 			}
 			keys = append(keys, key)
 		}
-		txn.Commit()
+		m.commit(txn)
 		return keys, nil
 	}
 	return nil, fmt.Errorf("unrecognized put object type: %T", objs)
@@ -341,14 +355,14 @@ func (m *dbConn) MultiGet(keys []driver.Value) ([]schema.Message, error) {
 
 // Interface for Deletion
 func (m *dbConn) Delete(key driver.Value) (int, error) {
-	txn := m.db.Txn(true)
+	txn := m.writeTxn()
 	err := txn.Delete(m.md.tbl.Name, key)
 	if err != nil {
-		txn.Abort()
+		m.abort(txn)
 		u.Warnf("could not delete: %v  err=%v", key, err)
 		return 0, err
 	}
-	txn.Commit()
+	m.commit(txn)
 	return 1, nil
 }
 
@@ -362,10 +376,10 @@ func (m *dbConn) DeleteExpression(p interface{}, where expr.Node) (int, error) {
 
 	evaluator := vm.Evaluator(where)
 	var deletedKeys []schema.Key
-	txn := m.db.Txn(true)
+	txn := m.writeTxn()
 	iter, err := txn.Get(m.md.tbl.Name, m.md.primaryIndex)
 	if err != nil {
-		txn.Abort()
+		m.abort(txn)
 		u.Errorf("could not get values %v", err)
 		return 0, err
 	}
@@ -411,9 +425,59 @@ deleteLoop:
 		}
 	}
 	if err != nil {
-		txn.Abort()
+		m.abort(txn)
 		return 0, err
 	}
-	txn.Commit()
+	m.commit(txn)
 	return len(deletedKeys), nil
 }
+
+// writeTxn the txn of a write, of the transaction if this is one
+func (m *dbConn) writeTxn() *memdb.Txn {
+	if m.wtxn != nil {
+		return m.wtxn
+	}
+	return m.db.Txn(true)
+}
+
+// commit the txn of a write, writes of a transaction wait for its Commit
+func (m *dbConn) commit(txn *memdb.Txn) {
+	if txn != m.wtxn {
+		txn.Commit()
+	}
+}
+
+// abort the txn of a failed write, a failed write of a transaction leaves
+// it to the caller to roll back
+func (m *dbConn) abort(txn *memdb.Txn) {
+	if txn != m.wtxn {
+		txn.Abort()
+	}
+}
+
+// Begin a transaction, Interface for ConnTransaction
+func (m *dbConn) Begin() (schema.Transaction, error) {
+	tx := newDbConn(m.md)
+	tx.wtxn = m.db.Txn(true)
+	return &dbTx{tx}, nil
+}
+
+// Commit the writes of the transaction
+func (m *dbTx) Commit() error {
+	if m.wtxn == nil {
+		return fmt.Errorf("transaction already ended")
+	}
+	m.wtxn.Commit()
+	m.wtxn = nil
+	return nil
+}
+
+// Rollback discard the writes of the transaction
+func (m *dbTx) Rollback() error {
+	if m.wtxn == nil {
+		return fmt.Errorf("transaction already ended")
+	}
+	m.wtxn.Abort()
+	m.wtxn = nil
+	return nil
+}
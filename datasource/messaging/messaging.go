@@ -0,0 +1,477 @@
+// Messaging package implements a streaming Datasource of the messages of
+// subscriptions of a message broker (NATS, Google Pub/Sub), acknowledged
+// once the rows of them are written downstream.
+package messaging
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/araddon/dateparse"
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+const (
+	// SourceType the source type of messaging sources in the config
+	SourceType = "messaging"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Columns the columns of every table, before the columns of the data
+	Columns = []string{"message_id", "subject", "published", "data"}
+
+	brokerMu sync.RWMutex
+	brokers  = make(map[string]BrokerOpener)
+
+	// Different Features of this Messaging Data Source
+	_ schema.Source            = (*MessageSource)(nil)
+	_ datasource.MeteredSource = (*MessageSource)(nil)
+	_ schema.SourceSetup       = (*MessageSource)(nil)
+	_ schema.SourceTableSchema = (*MessageSource)(nil)
+	_ schema.ConnScanner       = (*messageConn)(nil)
+	_ schema.ConnColumns       = (*messageConn)(nil)
+	_ schema.IteratorErr       = (*messageConn)(nil)
+	_ schema.ConnAcker         = (*messageConn)(nil)
+)
+
+// Broker a message broker the subscriptions of tables are made to
+type Broker interface {
+	// Subscribe to the messages of subject (the subscription of Pub/Sub),
+	// with the settings of the table
+	Subscribe(subject string, settings u.JsonHelper) (Subscriber, error)
+	Close() error
+}
+
+// Subscriber the messages of a subscription, received in order until it
+// is closed.  Messages not acked are redelivered by the broker, those
+// nacked as soon as it can.
+type Subscriber interface {
+	// Receive the next message, blocks until one arrives, nil once closed
+	Receive() (*Message, error)
+	Ack(msgs []*Message) error
+	Nack(msgs []*Message) error
+	Close() error
+}
+
+// Message a message of a Subscriber
+type Message struct {
+	Id         string
+	Subject    string
+	Data       []byte
+	Attributes map[string]string // headers of NATS
+	Published  time.Time
+	AckId      string // the handle of the broker to ack it by
+}
+
+// BrokerOpener open the Broker of a url (scheme://host...), with the
+// settings of the source config (credentials ..)
+type BrokerOpener func(u *url.URL, settings u.JsonHelper) (Broker, error)
+
+// RegisterBroker register the BrokerOpener of urls of scheme, ie "nats".
+// NATS and Pub/Sub are built in.
+func RegisterBroker(scheme string, open BrokerOpener) {
+	brokerMu.Lock()
+	defer brokerMu.Unlock()
+	brokers[strings.ToLower(scheme)] = open
+}
+
+// OpenBroker open the Broker of the url of the settings
+func OpenBroker(addr string, settings u.JsonHelper) (Broker, error) {
+	bu, err := url.Parse(addr)
+	if err != nil || bu.Scheme == "" {
+		return nil, fmt.Errorf("invalid broker url %q", addr)
+	}
+	brokerMu.RLock()
+	open, ok := brokers[strings.ToLower(bu.Scheme)]
+	brokerMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no broker registered for %q", bu.Scheme)
+	}
+	return open(bu, settings)
+}
+
+// MessageSource a DataSource of the subscriptions of a Broker, a streaming
+// table per subscription whose scans block for the next message until they
+// are closed.
+//
+//   "settings" : {
+//       "url"    : "nats://localhost:4222",   // or pubsub://project
+//       "token"  : "...",
+//       "tables" : {
+//           "orders" : {"subject" : "orders.created", "queue" : "pipeline",
+//                       "columns" : ["order_id int", "amount float", "sku"]},
+//           "events" : "events.>"
+//       }
+//   }
+//
+// The rows of a table are (message_id, subject, published, data) and then
+// the columns of its data, a json object, of the type of each (int, float,
+// bool, time, string the default).  The messages of the rows of an
+// INSERT ... SELECT are acked once the rows are written (and those its
+// where filtered out, once rows after them are written), nacked if the write
+// fails, so a pipeline of
+//
+//   INSERT INTO orders_db SELECT order_id, amount FROM orders
+//
+// writes each message at least once.  Messages of other queries are acked
+// as they are read.
+type MessageSource struct {
+	mu      sync.Mutex
+	broker  Broker
+	names   []string
+	tables  map[string]*topic
+	metrics datasource.Metrics
+}
+
+// topic the config of a table
+type topic struct {
+	subject  string
+	settings u.JsonHelper
+	tbl      *schema.Table
+	cols     []string // of the data
+	types    []value.ValueType
+}
+
+// messageConn a subscription of a table, as rows
+type messageConn struct {
+	t       *topic
+	sub     Subscriber
+	mu      sync.Mutex
+	pending []*Message // delivered, not yet acked, in order of ids
+	ids     []uint64
+	ct      uint64
+	err     error
+	closing bool // closed once the pending messages are acked or nacked
+	once    sync.Once
+}
+
+// NewMessageSource a MessageSource of the settings of a source config
+func NewMessageSource(settings u.JsonHelper) (*MessageSource, error) {
+	m := &MessageSource{}
+	if err := m.load(settings); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewBrokerSource a MessageSource of the subscriptions of broker, with
+// the tables of the settings of a source config
+func NewBrokerSource(broker Broker, settings u.JsonHelper) (*MessageSource, error) {
+	m := &MessageSource{broker: broker}
+	if err := m.loadTables(settings); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Setup open the broker of the "url" of the source config and the topics
+// of its "tables", of a MessageSource{} registered without settings
+func (m *MessageSource) Setup(ss *schema.SchemaSource) error {
+	m.mu.Lock()
+	loaded := m.broker != nil
+	m.mu.Unlock()
+	return datasource.SetupSettings(ss, loaded, m.load)
+}
+
+func (m *MessageSource) load(settings u.JsonHelper) error {
+	addr := settings.String("url")
+	if addr == "" {
+		return fmt.Errorf("messaging source requires a url in settings")
+	}
+	broker, err := OpenBroker(addr, settings)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.broker = broker
+	m.mu.Unlock()
+	if err := m.loadTables(settings); err != nil {
+		broker.Close()
+		return err
+	}
+	return nil
+}
+
+func (m *MessageSource) loadTables(settings u.JsonHelper) error {
+	tables := settings.Helper("tables")
+	if len(tables) == 0 {
+		return fmt.Errorf("messaging source requires tables in settings")
+	}
+	topics := make(map[string]*topic, len(tables))
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		t := &topic{subject: tables.String(name), settings: u.JsonHelper{}}
+		if th := tables.Helper(name); th != nil {
+			t.subject, t.settings = th.String("subject"), th
+		}
+		if t.subject == "" {
+			return fmt.Errorf("table %q requires a subject", name)
+		}
+		for _, def := range t.settings.Strings("columns") {
+			// name and type, ie "amount float"
+			fields := strings.Fields(def)
+			if len(fields) == 0 || len(fields) > 2 {
+				return fmt.Errorf("invalid column %q of table %q", def, name)
+			}
+			typ, err := columnType(strings.Join(fields[1:], ""))
+			if err != nil {
+				return fmt.Errorf("column %q of table %q: %v", fields[0], name, err)
+			}
+			t.cols, t.types = append(t.cols, fields[0]), append(t.types, typ)
+		}
+		t.tbl = newTable(strings.ToLower(name), t)
+		topics[t.tbl.Name] = t
+		names = append(names, t.tbl.Name)
+	}
+	sort.Strings(names)
+	m.mu.Lock()
+	m.names, m.tables = names, topics
+	m.mu.Unlock()
+	return nil
+}
+
+func columnType(name string) (value.ValueType, error) {
+	switch strings.ToLower(name) {
+	case "", "string":
+		return value.StringType, nil
+	case "int":
+		return value.IntType, nil
+	case "float", "number":
+		return value.NumberType, nil
+	case "bool":
+		return value.BoolType, nil
+	case "time":
+		return value.TimeType, nil
+	}
+	return value.UnknownType, fmt.Errorf("unsupported type %q", name)
+}
+
+func newTable(name string, t *topic) *schema.Table {
+	tbl := schema.NewTable(name)
+	types := []value.ValueType{value.StringType, value.StringType, value.TimeType, value.StringType}
+	for i, col := range Columns {
+		tbl.AddField(schema.NewFieldBase(col, types[i], 255, types[i].String()))
+	}
+	for i, col := range t.cols {
+		tbl.AddField(schema.NewFieldBase(col, t.types[i], 255, t.types[i].String()))
+	}
+	tbl.SetColumns(append(append([]string(nil), Columns...), t.cols...))
+	return tbl
+}
+
+func (m *MessageSource) Tables() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.names
+}
+
+func (m *MessageSource) topic(table string) (*topic, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tables[strings.ToLower(table)]
+	if !ok {
+		return nil, schema.ErrNotFound
+	}
+	return t, nil
+}
+
+func (m *MessageSource) Table(table string) (*schema.Table, error) {
+	t, err := m.topic(table)
+	if err != nil {
+		return nil, err
+	}
+	return t.tbl, nil
+}
+
+// Open subscribe to the subject of table, the scan of its messages blocks
+// for the next message until the conn is closed
+func (m *MessageSource) Open(table string) (schema.Conn, error) {
+	t, err := m.topic(table)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := m.broker.Subscribe(t.subject, t.settings)
+	if err != nil {
+		return nil, fmt.Errorf("could not subscribe to %q: %v", t.subject, err)
+	}
+	return &messageConn{t: t, sub: sub}, nil
+}
+
+func (m *MessageSource) Close() error {
+	m.mu.Lock()
+	broker := m.broker
+	m.mu.Unlock()
+	if broker == nil {
+		return nil
+	}
+	return broker.Close()
+}
+
+func (m *MessageSource) Metrics() *datasource.Metrics { return &m.metrics }
+
+func (m *messageConn) Columns() []string { return m.t.tbl.Columns() }
+
+func (m *messageConn) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+func (m *messageConn) Next() schema.Message {
+	msg, err := m.sub.Receive()
+	if err != nil || msg == nil {
+		m.mu.Lock()
+		m.err = err
+		m.mu.Unlock()
+		return nil
+	}
+	m.mu.Lock()
+	m.ct++
+	id := m.ct
+	m.pending = append(m.pending, msg)
+	m.ids = append(m.ids, id)
+	m.mu.Unlock()
+	return datasource.NewSqlDriverMessageMap(id, m.t.row(msg), m.t.tbl.FieldPositions)
+}
+
+// row the values of the columns of msg
+func (m *topic) row(msg *Message) []driver.Value {
+	row := make([]driver.Value, len(Columns)+len(m.cols))
+	row[0], row[1], row[3] = msg.Id, msg.Subject, string(msg.Data)
+	if !msg.Published.IsZero() {
+		row[2] = msg.Published
+	}
+	if len(m.cols) == 0 {
+		return row
+	}
+	var data map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(msg.Data))
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return row
+	}
+	for i, col := range m.cols {
+		row[len(Columns)+i] = dataValue(lookup(data, col), m.types[i])
+	}
+	return row
+}
+
+// lookup the value of the (dotted) path of a nested object of data
+func lookup(data map[string]interface{}, path string) interface{} {
+	if v, ok := data[path]; ok {
+		return v
+	}
+	parts := strings.SplitN(path, ".", 2)
+	if len(parts) == 2 {
+		if nested, ok := data[parts[0]].(map[string]interface{}); ok {
+			return lookup(nested, parts[1])
+		}
+	}
+	return nil
+}
+
+// dataValue the json value v as a value of typ, nil if it is not one
+func dataValue(v interface{}, typ value.ValueType) driver.Value {
+	if v == nil {
+		return nil
+	}
+	s := fmt.Sprintf("%v", v)
+	switch typ {
+	case value.IntType:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return int64(f)
+		}
+		return nil
+	case value.NumberType:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+		return nil
+	case value.BoolType:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+		return nil
+	case value.TimeType:
+		if t, err := dateparse.ParseAny(s); err == nil {
+			return t
+		}
+		return nil
+	}
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		by, _ := json.Marshal(v)
+		return string(by)
+	}
+	return s
+}
+
+// Ack the messages of the rows up to id
+func (m *messageConn) Ack(id uint64) error {
+	m.mu.Lock()
+	n := sort.Search(len(m.ids), func(i int) bool { return m.ids[i] > id })
+	msgs := m.pending[:n:n]
+	m.pending, m.ids = m.pending[n:], m.ids[n:]
+	m.mu.Unlock()
+	var err error
+	if len(msgs) > 0 {
+		err = m.sub.Ack(msgs)
+	}
+	return m.closeIfDone(err)
+}
+
+// Nack the messages delivered and not acked
+func (m *messageConn) Nack() error {
+	m.mu.Lock()
+	msgs := m.pending
+	m.pending, m.ids = nil, nil
+	m.mu.Unlock()
+	var err error
+	if len(msgs) > 0 {
+		err = m.sub.Nack(msgs)
+	}
+	return m.closeIfDone(err)
+}
+
+// Close end the subscription, once the messages delivered are acked (or
+// nacked) as the scan ends before the writes of its rows do
+func (m *messageConn) Close() error {
+	m.mu.Lock()
+	m.closing = true
+	m.mu.Unlock()
+	return m.closeIfDone(nil)
+}
+
+// closeIfDone close the subscription of a closed conn of no pending
+// messages, returning err or the error of closing it
+func (m *messageConn) closeIfDone(err error) error {
+	m.mu.Lock()
+	done := m.closing && len(m.pending) == 0
+	m.mu.Unlock()
+	if !done {
+		return err
+	}
+	m.once.Do(func() {
+		if cerr := m.sub.Close(); err == nil {
+			err = cerr
+		}
+	})
+	return err
+}
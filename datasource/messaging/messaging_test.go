@@ -0,0 +1,358 @@
+package messaging_test
+
+import (
+	"bufio"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	u "github.com/araddon/gou"
+	"github.com/bmizerany/assert"
+	"golang.org/x/net/context"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/datasource/messaging"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// fakeBroker a broker of the messages sent on msgs, recording the ids of
+// the messages acked and nacked
+type fakeBroker struct {
+	mu     sync.Mutex
+	msgs   chan *messaging.Message
+	acked  []string
+	nacked []string
+}
+
+type fakeSub struct {
+	b    *fakeBroker
+	msgs chan *messaging.Message
+}
+
+func (m *fakeBroker) Subscribe(subject string, settings u.JsonHelper) (messaging.Subscriber, error) {
+	return &fakeSub{b: m, msgs: m.msgs}, nil
+}
+func (m *fakeBroker) Close() error { return nil }
+
+// send messages of data, and end the subscription
+func (m *fakeBroker) send(data ...string) {
+	m.mu.Lock()
+	m.msgs = make(chan *messaging.Message, len(data))
+	m.acked, m.nacked = nil, nil
+	for i, d := range data {
+		m.msgs <- &messaging.Message{Id: fmt.Sprintf("m%d", i+1), Subject: "orders", Data: []byte(d)}
+	}
+	close(m.msgs)
+	m.mu.Unlock()
+}
+
+func (m *fakeSub) Receive() (*messaging.Message, error) {
+	msg, ok := <-m.msgs
+	if !ok {
+		return nil, nil
+	}
+	return msg, nil
+}
+func (m *fakeSub) Ack(msgs []*messaging.Message) error {
+	m.b.mu.Lock()
+	defer m.b.mu.Unlock()
+	for _, msg := range msgs {
+		m.b.acked = append(m.b.acked, msg.Id)
+	}
+	return nil
+}
+func (m *fakeSub) Nack(msgs []*messaging.Message) error {
+	m.b.mu.Lock()
+	defer m.b.mu.Unlock()
+	for _, msg := range msgs {
+		m.b.nacked = append(m.b.nacked, msg.Id)
+	}
+	return nil
+}
+func (m *fakeSub) Close() error { return nil }
+
+// pipeline the orders of a broker, and a sink table they are written to
+type pipeline struct {
+	*messaging.MessageSource
+	sink *sink
+}
+
+// sink a table whose writes fail if fail is set
+type sink struct {
+	*membtree.StaticDataSource
+	fail bool
+}
+
+func (m *sink) Put(ctx context.Context, key schema.Key, row interface{}) (schema.Key, error) {
+	if m.fail {
+		return nil, fmt.Errorf("sink is down")
+	}
+	return m.StaticDataSource.Put(ctx, key, row)
+}
+func (m *sink) PutMulti(ctx context.Context, keys []schema.Key, src interface{}) ([]schema.Key, error) {
+	if m.fail {
+		return nil, fmt.Errorf("sink is down")
+	}
+	return m.StaticDataSource.PutMulti(ctx, keys, src)
+}
+
+func (m *pipeline) Tables() []string { return append(m.MessageSource.Tables(), "sink") }
+func (m *pipeline) Table(table string) (*schema.Table, error) {
+	if table == "sink" {
+		return m.sink.Table(table)
+	}
+	return m.MessageSource.Table(table)
+}
+func (m *pipeline) Open(table string) (schema.Conn, error) {
+	if table == "sink" {
+		return m.sink, nil
+	}
+	return m.MessageSource.Open(table)
+}
+
+func TestMessageSource(t *testing.T) {
+	broker := &fakeBroker{}
+	src, err := messaging.NewBrokerSource(broker, u.JsonHelper{"tables": map[string]interface{}{
+		"orders": map[string]interface{}{"subject": "orders",
+			"columns": []interface{}{"order_id int", "amount float", "customer.name"}},
+		"events": "events.>",
+	}})
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"events", "orders"}, src.Tables())
+	tbl, _ := src.Table("orders")
+	assert.Equal(t, []string{"message_id", "subject", "published", "data", "order_id", "amount", "customer.name"}, tbl.Columns())
+
+	p := &pipeline{MessageSource: src, sink: &sink{StaticDataSource: membtree.NewStaticDataSource("sink", 0, nil, []string{"order_id", "amount"})}}
+	sch := datasource.RegisterSchemaSource("orders_pipeline", "orders_pipeline", p)
+	run := func(sql string) error {
+		ctx := plan.NewContext(sql)
+		ctx.Schema = sch
+		ctx.WriteBatchSize = 2
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		defer job.Close()
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+		return job.Run()
+	}
+
+	// messages are acked once written, or filtered out
+	broker.send(`{"order_id": 1, "amount": 5.5}`, `{"order_id": 2, "amount": 0}`,
+		`{"order_id": 3, "amount": 7}`, `{"order_id": 4, "amount": 9, "customer": {"name": "bob"}}`, `not json`)
+	err = run(`INSERT INTO sink (order_id, amount) SELECT order_id, amount FROM orders WHERE amount > 1`)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, 3, p.sink.Length())
+	assert.Equal(t, []string{"m1", "m2", "m3", "m4", "m5"}, broker.acked)
+	assert.Equal(t, 0, len(broker.nacked))
+
+	// messages of failed writes are redelivered
+	p.sink.fail = true
+	broker.send(`{"order_id": 5, "amount": 3}`, `{"order_id": 6, "amount": 4}`)
+	err = run(`INSERT INTO sink (order_id, amount) SELECT order_id, amount FROM orders`)
+	assert.T(t, err != nil)
+	assert.Equal(t, 0, len(broker.acked))
+	assert.Equal(t, []string{"m1", "m2"}, broker.nacked)
+	p.sink.fail = false
+
+	// messages of rows cut by a LIMIT are not acked
+	broker.send(`{"order_id": 8, "amount": 3}`, `{"order_id": 9, "amount": 4}`, `{"order_id": 10, "amount": 5}`)
+	err = run(`INSERT INTO sink (order_id, amount) SELECT order_id, amount FROM orders LIMIT 1`)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"m1"}, broker.acked)
+	for _, id := range broker.nacked {
+		assert.NotEqual(t, "m1", id)
+	}
+
+	// rows of a group by are not those of messages, acked as they are read
+	broker.send(`{"order_id": 11, "amount": 3}`, `{"order_id": 11, "amount": 4}`)
+	err = run(`INSERT INTO sink (order_id, amount) SELECT order_id, sum(amount) FROM orders GROUP BY order_id`)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"m1", "m2"}, broker.acked)
+	assert.Equal(t, 0, len(broker.nacked))
+
+	// messages of selects are acked as they are read
+	broker.send(`{"order_id": 7, "amount": 1, "customer": {"name": "ann"}}`)
+	ctx := plan.NewContext(`SELECT message_id, order_id, amount, customer.name FROM orders`)
+	ctx.Schema = sch
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	msgs := make([]schema.Message, 0)
+	job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+	assert.T(t, job.Setup() == nil)
+	assert.T(t, job.Run() == nil)
+	job.Close()
+	assert.Equal(t, 1, len(msgs))
+	assert.Equal(t, []driver.Value{"m1", int64(7), float64(1), "ann"}, msgs[0].(*datasource.SqlDriverMessageMap).Values())
+	assert.Equal(t, []string{"m1"}, broker.acked)
+
+	_, err = messaging.NewMessageSource(u.JsonHelper{"url": "kafka://broker", "tables": map[string]interface{}{"t": "t"}})
+	assert.T(t, err != nil)
+	_, err = messaging.NewBrokerSource(broker, u.JsonHelper{"tables": map[string]interface{}{"t": map[string]interface{}{"subject": "t", "columns": []interface{}{"a decimal"}}}})
+	assert.T(t, err != nil)
+}
+
+// natsServer a fake NATS server, sending msgs once subscribed and
+// recording the publishes of the client
+type natsServer struct {
+	ln   net.Listener
+	msgs string
+	pubs chan string
+}
+
+func newNatsServer(t *testing.T, msgs string) *natsServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Tf(t, err == nil, "no error %v", err)
+	m := &natsServer{ln: ln, msgs: msgs, pubs: make(chan string, 10)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"headers\":true}\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(line, "SUB "):
+				if line != "SUB orders.* workers 1" {
+					fmt.Fprintf(conn, "-ERR 'unexpected %s'\r\n", line)
+				}
+			case line == "PING":
+				fmt.Fprintf(conn, "PONG\r\n%s", m.msgs)
+			case strings.HasPrefix(line, "PUB "):
+				body, _ := r.ReadString('\n')
+				m.pubs <- strings.Fields(line)[1] + " " + strings.TrimSpace(body)
+			}
+		}
+	}()
+	return m
+}
+
+func TestNats(t *testing.T) {
+	ack := "$JS.ACK.ORDERS.workers.1.7.3.1462104000000000000.2"
+	hdr := "NATS/1.0\r\nNats-Msg-Id: order-8\r\n\r\n"
+	msgs := fmt.Sprintf("MSG orders.created 1 %s 15\r\n{\"order_id\": 7}\r\n", ack) +
+		"PING\r\n" +
+		fmt.Sprintf("HMSG orders.paid 1 %d %d\r\n%s{\"order_id\": 8}\r\n", len(hdr), len(hdr)+15, hdr)
+	srv := newNatsServer(t, msgs)
+	defer srv.ln.Close()
+
+	src, err := messaging.NewMessageSource(u.JsonHelper{"url": "nats://" + srv.ln.Addr().String(),
+		"tables": map[string]interface{}{"orders": map[string]interface{}{
+			"subject": "orders.*", "queue": "workers", "columns": []interface{}{"order_id int"}}}})
+	assert.Tf(t, err == nil, "no error %v", err)
+	conn, err := src.Open("orders")
+	assert.Tf(t, err == nil, "no error %v", err)
+	scanner := conn.(schema.ConnScanner)
+
+	vals := scanner.Next().Body().(*datasource.SqlDriverMessageMap).Values()
+	assert.Equal(t, []driver.Value{"7", "orders.created", time.Unix(1462104000, 0), `{"order_id": 7}`, int64(7)}, vals)
+	// the PING of the server is answered while waiting
+	vals = scanner.Next().Body().(*datasource.SqlDriverMessageMap).Values()
+	assert.Equal(t, []driver.Value{"order-8", "orders.paid", nil, `{"order_id": 8}`, int64(8)}, vals)
+
+	assert.T(t, conn.(schema.ConnAcker).Ack(1) == nil)
+	assert.Equal(t, ack+" +ACK", <-srv.pubs)
+	// the core nats message has nothing to nack
+	assert.T(t, conn.Close() == nil)
+	select {
+	case pub := <-srv.pubs:
+		t.Errorf("unexpected publish %s", pub)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// errors of the subscription are returned by Open
+	srv = newNatsServer(t, "")
+	defer srv.ln.Close()
+	src, err = messaging.NewMessageSource(u.JsonHelper{"url": "nats://" + srv.ln.Addr().String(),
+		"tables": map[string]interface{}{"orders": "orders.>"}})
+	assert.Tf(t, err == nil, "no error %v", err)
+	_, err = src.Open("orders")
+	assert.T(t, err != nil)
+}
+
+func TestPubSub(t *testing.T) {
+	var mu sync.Mutex
+	calls := make([]string, 0)
+	pulls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer abc" {
+			http.Error(w, `{"error": "unauthenticated"}`, 401)
+			return
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, ":pull"):
+			pulls++
+			if pulls > 1 {
+				w.Write([]byte(`{}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"receivedMessages": []interface{}{
+				map[string]interface{}{"ackId": "a1", "message": map[string]interface{}{"data": "eyJ4IjogMX0=",
+					"messageId": "101", "publishTime": "2016-05-01T12:00:00Z", "attributes": map[string]string{"k": "v"}}},
+				map[string]interface{}{"ackId": "a2", "message": map[string]interface{}{"data": "eyJ4IjogMn0=",
+					"messageId": "102", "publishTime": "2016-05-01T12:00:01Z"}},
+			}})
+		default:
+			calls = append(calls, r.URL.Path+" "+strings.TrimSpace(string(body)))
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	src, err := messaging.NewMessageSource(u.JsonHelper{"url": "pubsub://proj", "endpoint": srv.URL, "token": "abc",
+		"tables": map[string]interface{}{"clicks": map[string]interface{}{"subject": "clicks-sub", "poll": "5ms",
+			"columns": []interface{}{"x int"}}}})
+	assert.Tf(t, err == nil, "no error %v", err)
+	conn, err := src.Open("clicks")
+	assert.Tf(t, err == nil, "no error %v", err)
+	scanner := conn.(schema.ConnScanner)
+	vals := scanner.Next().Body().(*datasource.SqlDriverMessageMap).Values()
+	assert.Equal(t, []driver.Value{"101", "projects/proj/subscriptions/clicks-sub",
+		time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC), `{"x": 1}`, int64(1)}, vals)
+	assert.T(t, scanner.Next() != nil)
+
+	// closed before the writes of the rows of its messages are, ends once
+	// they are acked or nacked
+	assert.T(t, conn.(schema.ConnAcker).Ack(1) == nil)
+	assert.T(t, conn.Close() == nil)
+	mu.Lock()
+	assert.Equal(t, 1, len(calls))
+	mu.Unlock()
+	assert.T(t, conn.(schema.ConnAcker).Nack() == nil)
+	mu.Lock()
+	assert.Equal(t, []string{
+		`/v1/projects/proj/subscriptions/clicks-sub:acknowledge {"ackIds":["a1"]}`,
+		`/v1/projects/proj/subscriptions/clicks-sub:modifyAckDeadline {"ackDeadlineSeconds":0,"ackIds":["a2"]}`,
+	}, calls)
+	mu.Unlock()
+
+	// the scan of an empty subscription blocks until closed
+	conn, _ = src.Open("clicks")
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		conn.Close()
+	}()
+	assert.T(t, conn.(schema.ConnScanner).Next() == nil)
+	assert.T(t, conn.(schema.IteratorErr).Err() == nil)
+}
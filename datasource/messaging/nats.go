@@ -0,0 +1,284 @@
+package messaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+)
+
+var (
+	_ Broker     = (*natsBroker)(nil)
+	_ Subscriber = (*natsSub)(nil)
+
+	// NatsDialTimeout of connecting to a NATS server
+	NatsDialTimeout = 10 * time.Second
+)
+
+func init() {
+	RegisterBroker("nats", func(bu *url.URL, settings u.JsonHelper) (Broker, error) {
+		return newNatsBroker(bu, settings), nil
+	})
+}
+
+// natsBroker a NATS server, nats://[user:pass@]host:port, each subscription
+// of its own connection
+type natsBroker struct {
+	addr    string
+	connect map[string]interface{} // the CONNECT options
+}
+
+func newNatsBroker(bu *url.URL, settings u.JsonHelper) *natsBroker {
+	addr := bu.Host
+	if bu.Port() == "" {
+		addr = net.JoinHostPort(bu.Hostname(), "4222")
+	}
+	connect := map[string]interface{}{"verbose": false, "pedantic": false, "name": "qlbridge",
+		"lang": "go", "version": "1.0", "protocol": 1, "headers": true}
+	if bu.User != nil {
+		connect["user"] = bu.User.Username()
+		connect["pass"], _ = bu.User.Password()
+	}
+	if token := settings.String("token"); token != "" {
+		connect["auth_token"] = token
+	}
+	return &natsBroker{addr: addr, connect: connect}
+}
+
+func (m *natsBroker) Close() error { return nil }
+
+// Subscribe to subject, in the queue group of the "queue" setting if set.
+// Messages of JetStream (of a reply subject of $JS.ACK) are acked and
+// nacked, those of core NATS are not redelivered.
+func (m *natsBroker) Subscribe(subject string, settings u.JsonHelper) (Subscriber, error) {
+	conn, err := net.DialTimeout("tcp", m.addr, NatsDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	s := &natsSub{conn: conn, r: bufio.NewReader(conn), subject: subject}
+	if err := s.handshake(m.connect, settings.String("queue")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// natsSub a subscription of a connection to a NATS server
+type natsSub struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	wmu     sync.Mutex
+	subject string
+	ct      uint64
+	mu      sync.Mutex
+	closed  bool
+}
+
+// handshake read the INFO of the server, CONNECT, SUB and wait for the
+// PONG of a PING so the errors of the subscription are returned
+func (m *natsSub) handshake(connect map[string]interface{}, queue string) error {
+	m.conn.SetDeadline(time.Now().Add(NatsDialTimeout))
+	defer m.conn.SetDeadline(time.Time{})
+	line, err := m.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO") {
+		return fmt.Errorf("unexpected nats greeting %q", line)
+	}
+	opts, _ := json.Marshal(connect)
+	sub := m.subject
+	if queue != "" {
+		sub += " " + queue
+	}
+	if err := m.write(fmt.Sprintf("CONNECT %s\r\nSUB %s 1\r\nPING\r\n", opts, sub)); err != nil {
+		return err
+	}
+	for {
+		line, err := m.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.Trim(strings.TrimSpace(line[4:]), "'"))
+		case line == "PONG":
+			return nil
+		}
+	}
+}
+
+func (m *natsSub) readLine() (string, error) {
+	line, err := m.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (m *natsSub) write(s string) error {
+	m.wmu.Lock()
+	defer m.wmu.Unlock()
+	_, err := io.WriteString(m.conn, s)
+	return err
+}
+
+func (m *natsSub) isClosed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+// Receive the next MSG (or HMSG, of headers) of the subscription, answering
+// the PINGs of the server until it arrives
+func (m *natsSub) Receive() (*Message, error) {
+	for {
+		line, err := m.readLine()
+		if err != nil {
+			if m.isClosed() {
+				return nil, nil
+			}
+			return nil, err
+		}
+		op := line
+		if i := strings.IndexByte(line, ' '); i > 0 {
+			op = line[:i]
+		}
+		switch strings.ToUpper(op) {
+		case "MSG", "HMSG":
+			return m.readMsg(strings.ToUpper(op) == "HMSG", strings.Fields(line)[1:])
+		case "PING":
+			if err := m.write("PONG\r\n"); err != nil {
+				return nil, err
+			}
+		case "-ERR":
+			return nil, fmt.Errorf("nats: %s", strings.Trim(strings.TrimSpace(line[4:]), "'"))
+		}
+	}
+}
+
+// readMsg the payload of the MSG of args
+//
+//   MSG <subject> <sid> [reply-to] <#bytes>
+//   HMSG <subject> <sid> [reply-to] <#header bytes> <#total bytes>
+func (m *natsSub) readMsg(headers bool, args []string) (*Message, error) {
+	min := 3
+	if headers {
+		min = 4
+	}
+	if len(args) < min || len(args) > min+1 {
+		return nil, fmt.Errorf("invalid nats message %v", args)
+	}
+	msg := &Message{Subject: args[0]}
+	if len(args) == min+1 {
+		msg.AckId = args[2]
+	}
+	total, err := strconv.Atoi(args[len(args)-1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid nats message size %v", args)
+	}
+	hdrLen := 0
+	if headers {
+		if hdrLen, err = strconv.Atoi(args[len(args)-2]); err != nil || hdrLen > total {
+			return nil, fmt.Errorf("invalid nats header size %v", args)
+		}
+	}
+	buf := make([]byte, total+2)
+	if _, err := io.ReadFull(m.r, buf); err != nil {
+		return nil, err
+	}
+	msg.Data = buf[hdrLen:total]
+	if headers {
+		msg.Attributes = parseNatsHeaders(string(buf[:hdrLen]))
+		msg.Id = msg.Attributes["Nats-Msg-Id"]
+	}
+	m.ct++
+	if seq, ts, ok := jetStreamMeta(msg.AckId); ok {
+		msg.Published = ts
+		if msg.Id == "" {
+			msg.Id = seq
+		}
+	}
+	if msg.Id == "" {
+		msg.Id = strconv.FormatUint(m.ct, 10)
+	}
+	return msg, nil
+}
+
+// parseNatsHeaders the headers of a NATS/1.0 header block
+func parseNatsHeaders(block string) map[string]string {
+	headers := make(map[string]string)
+	for i, line := range strings.Split(block, "\r\n") {
+		if i == 0 || line == "" {
+			continue
+		}
+		if colon := strings.IndexByte(line, ':'); colon > 0 {
+			headers[strings.TrimSpace(line[:colon])] = strings.TrimSpace(line[colon+1:])
+		}
+	}
+	return headers
+}
+
+// jetStreamMeta the stream sequence and time of a JetStream ack subject
+//
+//   $JS.ACK.<stream>.<consumer>.<delivered>.<stream seq>.<consumer seq>.<time>.<pending>
+func jetStreamMeta(reply string) (string, time.Time, bool) {
+	if !strings.HasPrefix(reply, "$JS.ACK.") {
+		return "", time.Time{}, false
+	}
+	parts := strings.Split(reply, ".")
+	switch {
+	case len(parts) == 9:
+		parts = parts[2:]
+	case len(parts) >= 11:
+		// of a domain, the domain and account hash before the stream
+		parts = parts[4:11]
+	default:
+		return "", time.Time{}, false
+	}
+	nanos, err := strconv.ParseInt(parts[5], 10, 64)
+	if err != nil {
+		return parts[3], time.Time{}, true
+	}
+	return parts[3], time.Unix(0, nanos), true
+}
+
+func (m *natsSub) reply(msgs []*Message, body string) error {
+	var buf bytes.Buffer
+	for _, msg := range msgs {
+		if strings.HasPrefix(msg.AckId, "$JS.ACK.") {
+			fmt.Fprintf(&buf, "PUB %s %d\r\n%s\r\n", msg.AckId, len(body), body)
+		}
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	return m.write(buf.String())
+}
+
+// Ack the JetStream messages of msgs
+func (m *natsSub) Ack(msgs []*Message) error { return m.reply(msgs, "+ACK") }
+
+// Nack the JetStream messages of msgs, redelivered now
+func (m *natsSub) Nack(msgs []*Message) error { return m.reply(msgs, "-NAK") }
+
+func (m *natsSub) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.mu.Unlock()
+	m.write("UNSUB 1\r\n")
+	return m.conn.Close()
+}
@@ -0,0 +1,221 @@
+package messaging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+	"golang.org/x/net/context"
+)
+
+var (
+	_ Broker     = (*pubsubBroker)(nil)
+	_ Subscriber = (*pubsubSub)(nil)
+
+	// PubSubEndpoint of the Google Pub/Sub api, over-ride with the
+	// "endpoint" setting (ie of the emulator)
+	PubSubEndpoint = "https://pubsub.googleapis.com"
+)
+
+func init() {
+	RegisterBroker("pubsub", func(bu *url.URL, settings u.JsonHelper) (Broker, error) {
+		return newPubSubBroker(bu, settings)
+	})
+}
+
+// pubsubBroker the Google Pub/Sub api of a project, pubsub://project
+type pubsubBroker struct {
+	client   *http.Client
+	endpoint string
+	project  string
+	headers  map[string]string
+}
+
+func newPubSubBroker(bu *url.URL, settings u.JsonHelper) (*pubsubBroker, error) {
+	if bu.Host == "" {
+		return nil, fmt.Errorf("pubsub url requires a project, pubsub://project")
+	}
+	m := &pubsubBroker{client: &http.Client{Timeout: 90 * time.Second}, endpoint: PubSubEndpoint,
+		project: bu.Host, headers: make(map[string]string)}
+	if endpoint := settings.String("endpoint"); endpoint != "" {
+		m.endpoint = endpoint
+	}
+	m.endpoint = strings.TrimRight(m.endpoint, "/")
+	if token := settings.String("token"); token != "" {
+		m.headers["Authorization"] = "Bearer " + token
+	}
+	hh := settings.Helper("headers")
+	for k := range hh {
+		m.headers[k] = hh.String(k)
+	}
+	return m, nil
+}
+
+func (m *pubsubBroker) Close() error { return nil }
+
+// Subscribe pull the messages of the subscription of subject (of this
+// project unless a full projects/p/subscriptions/s name), max_messages at
+// a time, waiting poll ("1s") between pulls of none
+func (m *pubsubBroker) Subscribe(subject string, settings u.JsonHelper) (Subscriber, error) {
+	name := subject
+	if !strings.HasPrefix(name, "projects/") {
+		name = fmt.Sprintf("projects/%s/subscriptions/%s", m.project, subject)
+	}
+	s := &pubsubSub{broker: m, name: name, max: 100, poll: time.Second}
+	if max, ok := settings.IntSafe("max_messages"); ok && max > 0 {
+		s.max = max
+	}
+	if poll := settings.String("poll"); poll != "" {
+		d, err := time.ParseDuration(poll)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid pubsub poll %q", poll)
+		}
+		s.poll = d
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s, nil
+}
+
+// pubsubSub a subscription of Pub/Sub, the messages of the last pull
+type pubsubSub struct {
+	broker *pubsubBroker
+	name   string
+	max    int
+	poll   time.Duration
+	ctx    context.Context
+	cancel func()
+	mu     sync.Mutex
+	msgs   []*Message
+}
+
+// post body to the method of the subscription (pull, acknowledge ...), the
+// response into resp
+func (m *pubsubSub) post(method string, body, resp interface{}) error {
+	by, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/v1/%s:%s", m.broker.endpoint, m.name, method), bytes.NewReader(by))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(m.ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range m.broker.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := m.broker.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("pubsub %s %s: %s %s", method, m.name, res.Status, bytes.TrimSpace(data))
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.Unmarshal(data, resp)
+}
+
+// pull the next messages of the subscription
+func (m *pubsubSub) pull() ([]*Message, error) {
+	var resp struct {
+		ReceivedMessages []struct {
+			AckId   string `json:"ackId"`
+			Message struct {
+				Data        []byte            `json:"data"`
+				Attributes  map[string]string `json:"attributes"`
+				MessageId   string            `json:"messageId"`
+				PublishTime time.Time         `json:"publishTime"`
+			} `json:"message"`
+		} `json:"receivedMessages"`
+	}
+	if err := m.post("pull", map[string]interface{}{"maxMessages": m.max}, &resp); err != nil {
+		return nil, err
+	}
+	msgs := make([]*Message, len(resp.ReceivedMessages))
+	for i, rm := range resp.ReceivedMessages {
+		msgs[i] = &Message{Id: rm.Message.MessageId, Subject: m.name, Data: rm.Message.Data,
+			Attributes: rm.Message.Attributes, Published: rm.Message.PublishTime, AckId: rm.AckId}
+	}
+	return msgs, nil
+}
+
+// Receive the next message of the last pull, pulling until there are some
+func (m *pubsubSub) Receive() (*Message, error) {
+	for {
+		m.mu.Lock()
+		if len(m.msgs) > 0 {
+			msg := m.msgs[0]
+			m.msgs = m.msgs[1:]
+			m.mu.Unlock()
+			return msg, nil
+		}
+		m.mu.Unlock()
+		if m.ctx.Err() != nil {
+			return nil, nil
+		}
+		msgs, err := m.pull()
+		if err != nil {
+			if m.ctx.Err() != nil {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if len(msgs) == 0 {
+			select {
+			case <-m.ctx.Done():
+				return nil, nil
+			case <-time.After(m.poll):
+			}
+			continue
+		}
+		m.mu.Lock()
+		m.msgs = msgs
+		m.mu.Unlock()
+	}
+}
+
+func ackIds(msgs []*Message) []string {
+	ids := make([]string, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg.AckId
+	}
+	return ids
+}
+
+// Ack msgs, so they are not redelivered
+func (m *pubsubSub) Ack(msgs []*Message) error {
+	return m.post("acknowledge", map[string]interface{}{"ackIds": ackIds(msgs)}, nil)
+}
+
+// Nack msgs, by a deadline of now so they are redelivered now
+func (m *pubsubSub) Nack(msgs []*Message) error {
+	return m.post("modifyAckDeadline", map[string]interface{}{"ackIds": ackIds(msgs), "ackDeadlineSeconds": 0}, nil)
+}
+
+// Close stop pulling, nacking the messages pulled and not received
+func (m *pubsubSub) Close() error {
+	m.mu.Lock()
+	unread := m.msgs
+	m.msgs = nil
+	m.mu.Unlock()
+	var err error
+	if len(unread) > 0 {
+		err = m.Nack(unread)
+	}
+	m.cancel()
+	return err
+}
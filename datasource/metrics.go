@@ -0,0 +1,171 @@
+package datasource
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	// LatencyBuckets the upper bounds (seconds) of the buckets of the latency
+	// histograms of the requests of sources
+	LatencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+)
+
+// MeteredSource a Source recording the metrics of the use of its tables,
+// aggregated by the registry (see Registry.Metrics).  The scans and lookups
+// of sources are recorded by the exec engine, the bytes read by sources
+// that know them.
+type MeteredSource interface {
+	Metrics() *Metrics
+}
+
+// TableMetrics the metrics of the requests (scans and key lookups) of a
+// table of a source
+type TableMetrics struct {
+	Source   string    `json:"source"`
+	Table    string    `json:"table"`
+	Requests int64     `json:"requests"`
+	Rows     int64     `json:"rows"`   // scanned or looked up
+	Bytes    int64     `json:"bytes"`  // read of the backend, 0 if not known
+	Errors   int64     `json:"errors"` // requests failed
+	Latency  Histogram `json:"latency"`
+}
+
+// Histogram a histogram of latencies, of the observations in each bucket of
+// Buckets (upper bounds, in seconds), the last of Counts those over all
+// bounds
+type Histogram struct {
+	Buckets []float64 `json:"buckets"`
+	Counts  []int64   `json:"counts"`
+	Sum     float64   `json:"sum"` // seconds
+	Count   int64     `json:"count"`
+}
+
+// Observe latency d
+func (m *Histogram) Observe(d time.Duration) {
+	if m.Counts == nil {
+		m.Buckets = LatencyBuckets
+		m.Counts = make([]int64, len(m.Buckets)+1)
+	}
+	s := d.Seconds()
+	m.Counts[sort.SearchFloat64s(m.Buckets, s)]++
+	m.Sum += s
+	m.Count++
+}
+
+// Cumulative the counts of observations of at most each bound, the last of
+// all observations (ie the buckets of Prometheus histograms)
+func (m *Histogram) Cumulative() []int64 {
+	cum := make([]int64, len(m.Counts))
+	var n int64
+	for i, c := range m.Counts {
+		n += c
+		cum[i] = n
+	}
+	return cum
+}
+
+// Metrics the metrics of the tables of a source, the zero value records
+// none yet.  Thread-safe.
+type Metrics struct {
+	mu     sync.Mutex
+	tables map[string]*TableMetrics
+}
+
+func (m *Metrics) table(table string) *TableMetrics {
+	if m.tables == nil {
+		m.tables = make(map[string]*TableMetrics)
+	}
+	tm, ok := m.tables[table]
+	if !ok {
+		tm = &TableMetrics{Table: table}
+		m.tables[table] = tm
+	}
+	return tm
+}
+
+// Request record a request of table that took d, failed if err
+func (m *Metrics) Request(table string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tm := m.table(table)
+	tm.Requests++
+	if err != nil {
+		tm.Errors++
+	}
+	tm.Latency.Observe(d)
+}
+
+// Read record rows and bytes read of table
+func (m *Metrics) Read(table string, rows, bytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tm := m.table(table)
+	tm.Rows += rows
+	tm.Bytes += bytes
+}
+
+// Tables the metrics of the tables, sorted by table
+func (m *Metrics) Tables() []TableMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tables := make([]TableMetrics, 0, len(m.tables))
+	for _, tm := range m.tables {
+		t := *tm
+		t.Latency.Counts = append([]int64(nil), tm.Latency.Counts...)
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Table < tables[j].Table })
+	return tables
+}
+
+// Metrics the metrics of the tables of the registered sources that record
+// them (see MeteredSource), sorted by source and table
+func (m *Registry) Metrics() []TableMetrics {
+	registryMu.RLock()
+	metered := make(map[string]MeteredSource)
+	for name, src := range m.sources {
+		if ms, ok := src.(MeteredSource); ok {
+			metered[name] = ms
+		}
+	}
+	registryMu.RUnlock()
+	names := make([]string, 0, len(metered))
+	for name := range metered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	all := make([]TableMetrics, 0)
+	for _, name := range names {
+		for _, tm := range metered[name].Metrics().Tables() {
+			tm.Source = name
+			all = append(all, tm)
+		}
+	}
+	return all
+}
+
+// CountingReader a reader counting the bytes read of it, ie for the bytes
+// read of the metrics of a source
+type CountingReader struct {
+	io.Reader
+	N int64
+}
+
+func (m *CountingReader) Read(p []byte) (int, error) {
+	n, err := m.Reader.Read(p)
+	m.N += int64(n)
+	return n, err
+}
+
+// MetricsOf the metrics of source ds, nil if it records none
+func MetricsOf(ds schema.Source) *Metrics {
+	if ms, ok := ds.(MeteredSource); ok {
+		return ms.Metrics()
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package datasource_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+)
+
+func TestMetrics(t *testing.T) {
+	var m datasource.Metrics
+	m.Request("users", 2*time.Millisecond, nil)
+	m.Request("users", 3*time.Second, fmt.Errorf("timeout"))
+	m.Request("orders", time.Minute, nil)
+	m.Read("users", 10, 1024)
+
+	tables := m.Tables()
+	assert.Equal(t, 2, len(tables))
+	assert.Equal(t, "orders", tables[0].Table)
+	users := tables[1]
+	assert.Equal(t, int64(2), users.Requests)
+	assert.Equal(t, int64(1), users.Errors)
+	assert.Equal(t, int64(10), users.Rows)
+	assert.Equal(t, int64(1024), users.Bytes)
+	assert.Equal(t, int64(2), users.Latency.Count)
+	assert.Tf(t, users.Latency.Sum > 3, "sum %v", users.Latency.Sum)
+	// in the buckets of .005 and 5 seconds
+	cum := users.Latency.Cumulative()
+	assert.Equal(t, int64(0), cum[0])
+	assert.Equal(t, int64(1), cum[1])
+	assert.Equal(t, int64(2), cum[10])
+	// over all bounds
+	orders := tables[0].Latency
+	assert.Equal(t, int64(1), orders.Counts[len(orders.Counts)-1])
+
+	// the tables returned are copies
+	users.Latency.Counts[0] = 5
+	assert.Equal(t, int64(0), m.Tables()[1].Latency.Counts[0])
+}
@@ -5,6 +5,7 @@ package mockcsv
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	u "github.com/araddon/gou"
 
@@ -20,10 +21,12 @@ const (
 var (
 	// Enforce Features of this MockCsv Data Source
 	// - the rest are implemented in the static in-memory btree
-	_ schema.Source       = (*MockCsvSource)(nil)
-	_ schema.Conn         = (*MockCsvTable)(nil)
-	_ schema.ConnUpsert   = (*MockCsvTable)(nil)
-	_ schema.ConnDeletion = (*MockCsvTable)(nil)
+	_ schema.Source            = (*MockCsvSource)(nil)
+	_ datasource.MeteredSource = (*MockCsvSource)(nil)
+	_ schema.Conn              = (*MockCsvTable)(nil)
+	_ schema.ConnUpsert        = (*MockCsvTable)(nil)
+	_ schema.ConnDeletion      = (*MockCsvTable)(nil)
+	_ schema.ConnPatchWhere    = (*MockCsvTable)(nil)
 
 	// Schema  ~= global mock
 	//    -> SourceSchema  = "mockcsv"
@@ -44,6 +47,13 @@ func LoadTable(schemaName, name, csvRaw string) {
 	MockSchema.RefreshSchema()
 }
 
+// SetTableTTL expire the rows of table ttl after they are written, see
+// membtree.StaticDataSource.SetTTL
+func SetTableTTL(name string, ttl time.Duration) {
+	MockCsvGlobal.SetTTL(name, ttl)
+	MockSchema.RefreshSchema()
+}
+
 // MockCsvSource DataSource for testing
 //  - creates an in memory b-tree per "table"
 //  - not thread safe
@@ -51,6 +61,8 @@ type MockCsvSource struct {
 	tablenamelist []string
 	tables        map[string]*membtree.StaticDataSource
 	raw           map[string]string
+	ttls          map[string]time.Duration
+	metrics       datasource.Metrics
 }
 
 // MockCsvTable converts the static csv-source into a schema.Conn source
@@ -63,6 +75,7 @@ func NewMockSource() *MockCsvSource {
 		tablenamelist: make([]string, 0),
 		raw:           make(map[string]string),
 		tables:        make(map[string]*membtree.StaticDataSource),
+		ttls:          make(map[string]time.Duration),
 	}
 }
 
@@ -111,6 +124,9 @@ func (m *MockCsvSource) loadTable(tableName string) error {
 	ds := membtree.NewStaticData(tableName)
 	u.Infof("loaded columns %v", csvSource.Columns())
 	ds.SetColumns(csvSource.Columns())
+	if ttl, ok := m.ttls[tableName]; ok {
+		ds.SetTTL(ttl)
+	}
 	//u.Infof("set index col for %v: %v -- %v", tableName, 0, csvSource.Columns()[0])
 	m.tables[tableName] = ds
 
@@ -139,8 +155,9 @@ func (m *MockCsvSource) loadTable(tableName string) error {
 	return datasource.IntrospectTable(tbl, iter)
 }
 
-func (m *MockCsvSource) Close() error     { return nil }
-func (m *MockCsvSource) Tables() []string { return m.tablenamelist }
+func (m *MockCsvSource) Close() error                 { return nil }
+func (m *MockCsvSource) Metrics() *datasource.Metrics { return &m.metrics }
+func (m *MockCsvSource) Tables() []string             { return m.tablenamelist }
 func (m *MockCsvSource) CreateTable(tableName, csvRaw string) {
 	if _, exists := m.raw[tableName]; !exists {
 		m.tablenamelist = append(m.tablenamelist, tableName)
@@ -148,3 +165,13 @@ func (m *MockCsvSource) CreateTable(tableName, csvRaw string) {
 	m.raw[tableName] = csvRaw
 	m.loadTable(tableName)
 }
+
+// SetTTL expire the rows of table ttl after they are written, 0 for never,
+// of the table now and when (re)loaded.  See membtree.StaticDataSource.SetTTL
+func (m *MockCsvSource) SetTTL(tableName string, ttl time.Duration) {
+	tableName = strings.ToLower(tableName)
+	m.ttls[tableName] = ttl
+	if ds, ok := m.tables[tableName]; ok {
+		ds.SetTTL(ttl)
+	}
+}
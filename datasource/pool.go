@@ -0,0 +1,283 @@
+package datasource
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+)
+
+var (
+	// DefaultMaxIdle idle conns kept by a ConnPool of a PoolConfig without
+	// MaxIdle
+	DefaultMaxIdle = 2
+
+	// ErrPoolClosed the error of getting a conn of a closed pool
+	ErrPoolClosed = fmt.Errorf("connection pool is closed")
+	// ErrPoolTimeout the error of waiting longer than MaxWait for a conn
+	ErrPoolTimeout = fmt.Errorf("timed out waiting for a connection of the pool")
+	// ErrPoolExhausted the error of getting a conn when MaxWaiting are
+	// already waiting for one
+	ErrPoolExhausted = fmt.Errorf("too many waiting for a connection of the pool")
+)
+
+// PoolConfig the limits of a ConnPool
+type PoolConfig struct {
+	MaxOpen     int           // conns open (in use or idle) at once, 0 for no limit
+	MaxIdle     int           // idle conns kept for reuse, 0 for DefaultMaxIdle, < 0 for none
+	MaxLifetime time.Duration // conns are closed this long after opened, 0 for never
+	MaxWait     time.Duration // how long Get waits for a conn when MaxOpen are open, 0 for no limit
+	MaxWaiting  int           // Gets waiting at once, more fail with ErrPoolExhausted, 0 for no limit
+}
+
+// PoolConfigFromSettings the pool config of the "pool" settings of a source
+// config, of durations as strings.
+//
+//   "settings" : {
+//       "pool" : {"max_open": 10, "max_idle": 2, "max_lifetime": "1h", "max_wait": "5s", "max_waiting": 100}
+//   }
+func PoolConfigFromSettings(settings u.JsonHelper) (PoolConfig, error) {
+	conf := PoolConfig{}
+	pool := settings.Helper("pool")
+	if pool == nil {
+		return conf, nil
+	}
+	conf.MaxOpen, _ = pool.IntSafe("max_open")
+	conf.MaxIdle, _ = pool.IntSafe("max_idle")
+	conf.MaxWaiting, _ = pool.IntSafe("max_waiting")
+	for key, dur := range map[string]*time.Duration{"max_lifetime": &conf.MaxLifetime, "max_wait": &conf.MaxWait} {
+		if s := pool.String(key); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return conf, fmt.Errorf("invalid pool %s %q: %v", key, s, err)
+			}
+			*dur = d
+		}
+	}
+	return conf, nil
+}
+
+// PoolStats the stats of a ConnPool
+type PoolStats struct {
+	MaxOpen        int           `json:"max_open"`        // limit of open conns, 0 for none
+	Open           int           `json:"open"`            // conns open, in use and idle
+	InUse          int           `json:"in_use"`          // conns gotten, not yet put back
+	Idle           int           `json:"idle"`            // conns kept for reuse
+	Waiting        int           `json:"waiting"`         // Gets waiting for a conn now
+	WaitCount      int64         `json:"wait_count"`      // Gets that waited for a conn
+	WaitDuration   time.Duration `json:"wait_duration"`   // total time waited for conns
+	Timeouts       int64         `json:"timeouts"`        // Gets that gave up waiting
+	IdleClosed     int64         `json:"idle_closed"`     // conns closed for more than MaxIdle idle
+	LifetimeClosed int64         `json:"lifetime_closed"` // conns closed for being older than MaxLifetime
+}
+
+// PooledSource a Source pooling its connections to its backend (see
+// ConnPool), with the stats of its pool.  See Registry.PoolStats
+type PooledSource interface {
+	PoolStats() PoolStats
+}
+
+// ConnPool a pool of connections to the backend of a source (ie the tcp
+// connections of the conns of its Open), so concurrent queries don't open
+// more than the backend allows.  Conns are gotten, and put back when the
+// conn of the Open is closed.  Gets of more than MaxOpen conns wait, in a
+// queue, for one to be put back.  Conns must be comparable (ie pointers).
+// Thread-safe.
+type ConnPool struct {
+	conf PoolConfig
+	dial func() (io.Closer, error)
+
+	mu      sync.Mutex
+	opened  map[io.Closer]time.Time // open conns, when they were opened
+	idle    []io.Closer
+	open    int // open conns, and conns being dialed
+	waiters []chan poolGrant
+	closed  bool
+	stats   PoolStats
+}
+
+// poolGrant a conn for a waiter, or nil for it to dial one
+type poolGrant struct {
+	conn io.Closer
+	err  error
+}
+
+// NewConnPool a pool of conns of dial
+func NewConnPool(conf PoolConfig, dial func() (io.Closer, error)) *ConnPool {
+	if conf.MaxIdle == 0 {
+		conf.MaxIdle = DefaultMaxIdle
+	}
+	return &ConnPool{conf: conf, dial: dial, opened: make(map[io.Closer]time.Time)}
+}
+
+// Get an idle conn, else a new conn, else (of MaxOpen open) wait for one
+// to be put back
+func (m *ConnPool) Get() (io.Closer, error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	now := time.Now()
+	for len(m.idle) > 0 {
+		conn := m.idle[len(m.idle)-1]
+		m.idle = m.idle[:len(m.idle)-1]
+		if m.expired(conn, now) {
+			m.stats.LifetimeClosed++
+			m.closeConn(conn)
+			continue
+		}
+		m.mu.Unlock()
+		return conn, nil
+	}
+	if m.conf.MaxOpen <= 0 || m.open < m.conf.MaxOpen {
+		m.open++
+		m.mu.Unlock()
+		return m.dialConn()
+	}
+	if m.conf.MaxWaiting > 0 && len(m.waiters) >= m.conf.MaxWaiting {
+		m.mu.Unlock()
+		return nil, ErrPoolExhausted
+	}
+	wait := make(chan poolGrant, 1)
+	m.waiters = append(m.waiters, wait)
+	m.stats.WaitCount++
+	m.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if m.conf.MaxWait > 0 {
+		timer := time.NewTimer(m.conf.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var grant poolGrant
+	select {
+	case grant = <-wait:
+	case <-timeout:
+		m.mu.Lock()
+		for i, w := range m.waiters {
+			if w == wait {
+				m.waiters = append(m.waiters[:i], m.waiters[i+1:]...)
+				m.stats.Timeouts++
+				m.stats.WaitDuration += time.Since(now)
+				m.mu.Unlock()
+				return nil, ErrPoolTimeout
+			}
+		}
+		m.mu.Unlock()
+		// granted as it timed out
+		grant = <-wait
+	}
+	m.mu.Lock()
+	m.stats.WaitDuration += time.Since(now)
+	m.mu.Unlock()
+	if grant.err != nil {
+		return nil, grant.err
+	}
+	if grant.conn == nil {
+		return m.dialConn()
+	}
+	return grant.conn, nil
+}
+
+// dialConn a new conn, its slot of the open conns already taken
+func (m *ConnPool) dialConn() (io.Closer, error) {
+	conn, err := m.dial()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.open--
+		m.grantSlot()
+		return nil, err
+	}
+	m.opened[conn] = time.Now()
+	return conn, nil
+}
+
+// Put conn back, for reuse (or to be closed, if over MaxIdle or
+// MaxLifetime).  A conn of err (broken) is closed.
+func (m *ConnPool) Put(conn io.Closer, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.opened[conn]; !ok {
+		u.Warnf("put of a conn not of the pool %T", conn)
+		return
+	}
+	switch {
+	case m.closed || err != nil:
+		m.closeConn(conn)
+	case m.expired(conn, time.Now()):
+		m.stats.LifetimeClosed++
+		m.closeConn(conn)
+	case len(m.waiters) > 0:
+		wait := m.waiters[0]
+		m.waiters = m.waiters[1:]
+		wait <- poolGrant{conn: conn}
+	case len(m.idle) < m.conf.MaxIdle:
+		m.idle = append(m.idle, conn)
+	default:
+		m.stats.IdleClosed++
+		m.closeConn(conn)
+	}
+}
+
+// Stats of the conns of the pool
+func (m *ConnPool) Stats() PoolStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.stats
+	stats.MaxOpen = m.conf.MaxOpen
+	stats.Open = len(m.opened)
+	stats.Idle = len(m.idle)
+	stats.InUse = stats.Open - stats.Idle
+	stats.Waiting = len(m.waiters)
+	return stats
+}
+
+// Close the idle conns, and conns in use as they are put back.  Waiting
+// Gets fail with ErrPoolClosed.
+func (m *ConnPool) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	for _, conn := range m.idle {
+		m.closeConn(conn)
+	}
+	m.idle = nil
+	for _, wait := range m.waiters {
+		wait <- poolGrant{err: ErrPoolClosed}
+	}
+	m.waiters = nil
+	return nil
+}
+
+func (m *ConnPool) expired(conn io.Closer, now time.Time) bool {
+	return m.conf.MaxLifetime > 0 && now.Sub(m.opened[conn]) >= m.conf.MaxLifetime
+}
+
+// closeConn close conn, freeing its slot of the open conns
+func (m *ConnPool) closeConn(conn io.Closer) {
+	if err := conn.Close(); err != nil {
+		u.Debugf("could not close pooled conn: %v", err)
+	}
+	delete(m.opened, conn)
+	m.open--
+	m.grantSlot()
+}
+
+// grantSlot let the first waiter dial a conn, of a slot of the open conns
+// just freed
+func (m *ConnPool) grantSlot() {
+	if len(m.waiters) == 0 || m.closed {
+		return
+	}
+	wait := m.waiters[0]
+	m.waiters = m.waiters[1:]
+	m.open++
+	wait <- poolGrant{}
+}
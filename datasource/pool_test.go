@@ -0,0 +1,98 @@
+package datasource_test
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	u "github.com/araddon/gou"
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+)
+
+type poolConn struct {
+	mu     sync.Mutex
+	closed bool
+}
+
+func (m *poolConn) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
+
+func TestConnPool(t *testing.T) {
+	dials := 0
+	pool := datasource.NewConnPool(datasource.PoolConfig{MaxOpen: 2, MaxIdle: 1, MaxWait: 20 * time.Millisecond, MaxWaiting: 1},
+		func() (io.Closer, error) {
+			dials++
+			if dials == 3 {
+				return nil, fmt.Errorf("refused")
+			}
+			return &poolConn{}, nil
+		})
+
+	c1, err := pool.Get()
+	assert.Tf(t, err == nil, "no error %v", err)
+	c2, _ := pool.Get()
+	assert.Equal(t, 2, dials)
+
+	// of MaxOpen open, a get waits for a put, else times out
+	_, err = pool.Get()
+	assert.Equal(t, datasource.ErrPoolTimeout, err)
+	got := make(chan io.Closer)
+	go func() {
+		c, _ := pool.Get()
+		got <- c
+	}()
+	for pool.Stats().Waiting == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	_, err = pool.Get()
+	assert.Equal(t, datasource.ErrPoolExhausted, err)
+	pool.Put(c1, nil)
+	assert.T(t, <-got == c1)
+	stats := pool.Stats()
+	assert.Equal(t, datasource.PoolStats{MaxOpen: 2, Open: 2, InUse: 2, WaitCount: 2, WaitDuration: stats.WaitDuration, Timeouts: 1}, stats)
+
+	// over MaxIdle are closed, broken conns are closed
+	pool.Put(c1, nil)
+	pool.Put(c2, nil)
+	assert.Equal(t, true, c2.(*poolConn).closed)
+	c, _ := pool.Get()
+	assert.T(t, c == c1)
+	pool.Put(c, fmt.Errorf("broken pipe"))
+	assert.Equal(t, true, c1.(*poolConn).closed)
+	_, err = pool.Get()
+	assert.Equal(t, "refused", err.Error())
+	stats = pool.Stats()
+	assert.Equal(t, 0, stats.Open)
+	assert.Equal(t, int64(1), stats.IdleClosed)
+
+	// idle conns older than MaxLifetime are not reused
+	pool = datasource.NewConnPool(datasource.PoolConfig{MaxLifetime: 5 * time.Millisecond}, func() (io.Closer, error) {
+		return &poolConn{}, nil
+	})
+	c1, _ = pool.Get()
+	pool.Put(c1, nil)
+	time.Sleep(10 * time.Millisecond)
+	c2, _ = pool.Get()
+	assert.T(t, c1 != c2)
+	assert.Equal(t, int64(1), pool.Stats().LifetimeClosed)
+
+	pool.Close()
+	pool.Put(c2, nil)
+	assert.Equal(t, true, c2.(*poolConn).closed)
+	_, err = pool.Get()
+	assert.Equal(t, datasource.ErrPoolClosed, err)
+
+	conf, err := datasource.PoolConfigFromSettings(u.JsonHelper{"pool": map[string]interface{}{"max_open": 10, "max_wait": "5s"}})
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, datasource.PoolConfig{MaxOpen: 10, MaxWait: 5 * time.Second}, conf)
+	_, err = datasource.PoolConfigFromSettings(u.JsonHelper{"pool": map[string]interface{}{"max_lifetime": "forever"}})
+	assert.T(t, err != nil)
+}
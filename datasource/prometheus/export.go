@@ -0,0 +1,82 @@
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/araddon/qlbridge/datasource"
+)
+
+// MetricsPrefix the prefix of the names of the metrics of sources exported
+var MetricsPrefix = "qlbridge_source_"
+
+// MetricsHandler a http.Handler of the metrics of the tables of the sources
+// of the registry (see datasource.MeteredSource), in the text format scraped
+// by Prometheus, ie
+//
+//   http.Handle("/metrics/sources", prometheus.MetricsHandler())
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := WriteMetrics(w, datasource.DataSourcesRegistry().Metrics()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// WriteMetrics write the metrics of tables in the Prometheus text format,
+// labeled by source and table
+//
+//   qlbridge_source_requests_total{source="users",table="accounts"} 12
+//   qlbridge_source_request_duration_seconds_bucket{source="users",table="accounts",le="0.005"} 9
+func WriteMetrics(w io.Writer, metrics []datasource.TableMetrics) error {
+	bw := bufio.NewWriter(w)
+	counters := []struct {
+		name, help string
+		value      func(tm *datasource.TableMetrics) int64
+	}{
+		{"requests_total", "Scans and key lookups of the table.", func(tm *datasource.TableMetrics) int64 { return tm.Requests }},
+		{"rows_total", "Rows scanned or looked up of the table.", func(tm *datasource.TableMetrics) int64 { return tm.Rows }},
+		{"bytes_total", "Bytes read of the backend of the table.", func(tm *datasource.TableMetrics) int64 { return tm.Bytes }},
+		{"errors_total", "Requests of the table that failed.", func(tm *datasource.TableMetrics) int64 { return tm.Errors }},
+	}
+	for _, c := range counters {
+		name := MetricsPrefix + c.name
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", name, c.help, name)
+		for i := range metrics {
+			fmt.Fprintf(bw, "%s{%s} %d\n", name, labels(&metrics[i]), c.value(&metrics[i]))
+		}
+	}
+	name := MetricsPrefix + "request_duration_seconds"
+	fmt.Fprintf(bw, "# HELP %s Latency of the requests of the table.\n# TYPE %s histogram\n", name, name)
+	for i := range metrics {
+		tm := &metrics[i]
+		lbls := labels(tm)
+		h := tm.Latency
+		if h.Counts == nil {
+			h = datasource.Histogram{Buckets: datasource.LatencyBuckets, Counts: make([]int64, len(datasource.LatencyBuckets)+1)}
+		}
+		for j, n := range h.Cumulative() {
+			le := "+Inf"
+			if j < len(h.Buckets) {
+				le = strconv.FormatFloat(h.Buckets[j], 'g', -1, 64)
+			}
+			fmt.Fprintf(bw, "%s_bucket{%s,le=%q} %d\n", name, lbls, le, n)
+		}
+		fmt.Fprintf(bw, "%s_sum{%s} %s\n", name, lbls, strconv.FormatFloat(h.Sum, 'g', -1, 64))
+		fmt.Fprintf(bw, "%s_count{%s} %d\n", name, lbls, h.Count)
+	}
+	return bw.Flush()
+}
+
+func labels(tm *datasource.TableMetrics) string {
+	return fmt.Sprintf(`source="%s",table="%s"`, escapeLabel(tm.Source), escapeLabel(tm.Table))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,422 @@
+// Prometheus package implements a Datasource of the metrics of a Prometheus
+// server, each table the samples of a metric.
+package prometheus
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/expr/translate"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Different Features of this Prometheus Data Source
+	_ schema.Source            = (*PrometheusSource)(nil)
+	_ datasource.MeteredSource = (*PrometheusSource)(nil)
+	_ schema.SourceSetup       = (*PrometheusSource)(nil)
+	_ schema.SourceTableSchema = (*PrometheusSource)(nil)
+	_ schema.ConnScanner       = (*promConn)(nil)
+	_ schema.ConnColumns       = (*promConn)(nil)
+	_ schema.IteratorErr       = (*promConn)(nil)
+	_ translate.Translator     = (*promConn)(nil)
+)
+
+const (
+	// the columns of each table, besides its labels
+	MetricColumn    = "metric"
+	TimestampColumn = "timestamp"
+	ValueColumn     = "value"
+)
+
+// PrometheusSource a DataSource of the http api of a Prometheus server, a
+// table per metric of columns (metric, labels..., timestamp, value), a row
+// per sample of a series over the range up to now.
+//
+//   "settings" : {
+//       "url"     : "http://localhost:9090",
+//       "metrics" : ["up", "http_requests_total"],  // else all metrics
+//       "range"   : "1h",                           // of samples up to now
+//       "step"    : "1m",
+//       "headers" : {"Authorization" : "Bearer ..."},
+//       "timeout" : 30                              // seconds per request
+//   }
+//
+// The label columns of a table are the labels of its series over the range.
+// Equalities of labels to literals AND-ed in a where are sent as the label
+// matchers of the query, ie
+//   SELECT * FROM http_requests_total WHERE job = "api" AND code = "500"
+//     =>  http_requests_total{code="500",job="api"}
+// the where is still evaluated on the rows.  Joins with other sources are
+// evaluated by qlbridge, ie dashboards of metrics by the accounts of a db.
+type PrometheusSource struct {
+	mu      sync.Mutex
+	client  *http.Client
+	url     string
+	headers map[string]string
+	rng     time.Duration
+	step    time.Duration
+	names   []string
+	schemas map[string]*schema.Table
+	metrics datasource.Metrics
+}
+
+// promConn the scan of the samples of a metric, queried on the first Next
+// so the where pushed down is known
+type promConn struct {
+	src     *PrometheusSource
+	metric  string
+	tbl     *schema.Table
+	pushed  map[string]string // label matchers, by label
+	rows    [][]driver.Value
+	cursor  int
+	started bool
+	err     error
+}
+
+// NewPrometheusSource a PrometheusSource of the settings of a source config
+func NewPrometheusSource(settings u.JsonHelper) (*PrometheusSource, error) {
+	m := &PrometheusSource{}
+	if err := m.load(settings); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Setup read the server "url", default "range" and "step" and the "metrics"
+// tables of the source config, of a PrometheusSource{} registered without
+// settings
+func (m *PrometheusSource) Setup(ss *schema.SchemaSource) error {
+	m.mu.Lock()
+	loaded := m.client != nil
+	m.mu.Unlock()
+	return datasource.SetupSettings(ss, loaded, m.load)
+}
+
+func (m *PrometheusSource) load(settings u.JsonHelper) error {
+	addr := strings.TrimRight(settings.String("url"), "/")
+	if addr == "" {
+		return fmt.Errorf("prometheus source requires a url in settings")
+	}
+	rng, step := time.Hour, time.Minute
+	for name, d := range map[string]*time.Duration{"range": &rng, "step": &step} {
+		if s := settings.String(name); s != "" {
+			parsed, err := time.ParseDuration(s)
+			if err != nil || parsed <= 0 {
+				return fmt.Errorf("invalid prometheus %s %q", name, s)
+			}
+			*d = parsed
+		}
+	}
+	headers := make(map[string]string)
+	hh := settings.Helper("headers")
+	for k := range hh {
+		headers[k] = hh.String(k)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	if secs, ok := settings.IntSafe("timeout"); ok && secs > 0 {
+		client.Timeout = time.Duration(secs) * time.Second
+	}
+
+	m.mu.Lock()
+	m.client, m.url, m.headers = client, addr, headers
+	m.rng, m.step = rng, step
+	m.schemas = make(map[string]*schema.Table)
+	m.mu.Unlock()
+
+	names := settings.Strings("metrics")
+	if len(names) == 0 {
+		var all []string
+		if err := m.get("", "/api/v1/label/__name__/values", nil, &all); err != nil {
+			return fmt.Errorf("could not read prometheus metrics: %v", err)
+		}
+		names = all
+	}
+	sort.Strings(names)
+	m.mu.Lock()
+	m.names = names
+	m.mu.Unlock()
+	return nil
+}
+
+// get the data of the api response of path with params q into data, its
+// bytes read of table (if any)
+func (m *PrometheusSource) get(table, path string, q url.Values, data interface{}) error {
+	target := m.url + path
+	if len(q) > 0 {
+		target += "?" + q.Encode()
+	}
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range m.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	cr := &datasource.CountingReader{Reader: resp.Body}
+	if table != "" {
+		defer func() { m.metrics.Read(table, 0, cr.N) }()
+	}
+	var body struct {
+		Status string          `json:"status"`
+		Error  string          `json:"error"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(cr).Decode(&body); err != nil {
+		return fmt.Errorf("GET %s: %s, %v", path, resp.Status, err)
+	}
+	if body.Status != "success" {
+		return fmt.Errorf("GET %s: %s %s", path, resp.Status, body.Error)
+	}
+	return json.Unmarshal(body.Data, data)
+}
+
+// window the start, end and step params of the range up to now
+func (m *PrometheusSource) window(q url.Values) url.Values {
+	end := time.Now()
+	q.Set("start", formatTime(end.Add(-m.rng)))
+	q.Set("end", formatTime(end))
+	return q
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', 3, 64)
+}
+
+func (m *PrometheusSource) Tables() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.names
+}
+
+func (m *PrometheusSource) hasTable(table string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, name := range m.names {
+		if name == table {
+			return true
+		}
+	}
+	return false
+}
+
+// Table the schema of a metric, of the labels of its series
+func (m *PrometheusSource) Table(table string) (*schema.Table, error) {
+	m.mu.Lock()
+	tbl, ok := m.schemas[table]
+	m.mu.Unlock()
+	if ok {
+		return tbl, nil
+	}
+	if !m.hasTable(table) {
+		return nil, schema.ErrNotFound
+	}
+	var series []map[string]string
+	q := m.window(url.Values{"match[]": {table}})
+	if err := m.get(table, "/api/v1/series", q, &series); err != nil {
+		return nil, fmt.Errorf("could not read series of %q: %v", table, err)
+	}
+	seen := make(map[string]bool)
+	labels := make([]string, 0)
+	for _, s := range series {
+		for label := range s {
+			if !seen[label] && label != "__name__" && !isColumn(label) {
+				seen[label] = true
+				labels = append(labels, label)
+			}
+		}
+	}
+	sort.Strings(labels)
+
+	tbl = schema.NewTable(table)
+	cols := append(append([]string{MetricColumn}, labels...), TimestampColumn, ValueColumn)
+	for _, col := range cols {
+		typ := value.StringType
+		switch col {
+		case TimestampColumn:
+			typ = value.TimeType
+		case ValueColumn:
+			typ = value.NumberType
+		}
+		tbl.AddField(schema.NewFieldBase(col, typ, 255, typ.String()))
+	}
+	tbl.SetColumns(cols)
+	m.mu.Lock()
+	m.schemas[table] = tbl
+	m.mu.Unlock()
+	return tbl, nil
+}
+
+// isColumn is name one of the columns besides the labels
+func isColumn(name string) bool {
+	return name == MetricColumn || name == TimestampColumn || name == ValueColumn
+}
+
+// Open a scan of the samples of metric table
+func (m *PrometheusSource) Open(table string) (schema.Conn, error) {
+	tbl, err := m.Table(table)
+	if err != nil {
+		return nil, err
+	}
+	return &promConn{src: m, metric: table, tbl: tbl}, nil
+}
+
+func (m *PrometheusSource) Close() error { return nil }
+
+func (m *PrometheusSource) Metrics() *datasource.Metrics { return &m.metrics }
+
+// Translate the equalities of labels to literals AND-ed in the where into
+// the label matchers of the query.  The rest of the where is not sent.
+func (m *promConn) Translate(node expr.Node) (interface{}, error) {
+	eq := make(map[string]string)
+	m.equalities(node, eq)
+	if len(eq) == 0 {
+		return nil, fmt.Errorf("no label equalities to match in %s", node)
+	}
+	return eq, nil
+}
+
+// equalities   label = "literal" [AND ...]
+func (m *promConn) equalities(node expr.Node, eq map[string]string) {
+	bn, ok := node.(*expr.BinaryNode)
+	if !ok || len(bn.Args) != 2 {
+		return
+	}
+	switch bn.Operator.T {
+	case lex.TokenLogicAnd:
+		m.equalities(bn.Args[0], eq)
+		m.equalities(bn.Args[1], eq)
+	case lex.TokenEqual, lex.TokenEqualEqual:
+		col, lit := bn.Args[0], bn.Args[1]
+		if _, isIdent := col.(*expr.IdentityNode); !isIdent {
+			col, lit = lit, col
+		}
+		in, isIdent := col.(*expr.IdentityNode)
+		sn, isString := lit.(*expr.StringNode)
+		if !isIdent || !isString {
+			return
+		}
+		label := in.Text
+		if _, right, hasLeft := expr.LeftRight(label); hasLeft {
+			label = right
+		}
+		if _, isLabel := m.tbl.FieldMap[label]; isLabel && !isColumn(label) {
+			eq[label] = sn.Text
+		}
+	}
+}
+
+// SetNative the label matchers pushed down by the planner (see Translate)
+func (m *promConn) SetNative(native interface{}) {
+	if eq, ok := native.(map[string]string); ok {
+		m.pushed = eq
+	}
+}
+
+// selector the metric and label matchers of the query
+func (m *promConn) selector() string {
+	if len(m.pushed) == 0 {
+		return m.metric
+	}
+	labels := make([]string, 0, len(m.pushed))
+	for label := range m.pushed {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	matchers := make([]string, len(labels))
+	for i, label := range labels {
+		matchers[i] = label + "=" + strconv.Quote(m.pushed[label])
+	}
+	return m.metric + "{" + strings.Join(matchers, ",") + "}"
+}
+
+// query the samples of the series of the selector, the rows by series
+func (m *promConn) query() error {
+	var data struct {
+		Result []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][]interface{}   `json:"values"`
+		} `json:"result"`
+	}
+	q := m.src.window(url.Values{"query": {m.selector()}})
+	q.Set("step", strconv.FormatFloat(m.src.step.Seconds(), 'f', -1, 64))
+	if err := m.src.get(m.metric, "/api/v1/query_range", q, &data); err != nil {
+		return fmt.Errorf("could not query %q: %v", m.metric, err)
+	}
+	cols := m.tbl.Columns()
+	for _, series := range data.Result {
+		for _, sample := range series.Values {
+			if len(sample) != 2 {
+				continue
+			}
+			ts, _ := sample[0].(float64)
+			sv, _ := sample[1].(string)
+			f, err := strconv.ParseFloat(sv, 64)
+			if err != nil {
+				f = math.NaN()
+			}
+			row := make([]driver.Value, len(cols))
+			for i, col := range cols {
+				switch col {
+				case MetricColumn:
+					row[i] = m.metric
+				case TimestampColumn:
+					sec, frac := math.Modf(ts)
+					row[i] = time.Unix(int64(sec), int64(math.Round(frac*1e3))*1e6).UTC()
+				case ValueColumn:
+					row[i] = f
+				default:
+					if v, ok := series.Metric[col]; ok {
+						row[i] = v
+					}
+				}
+			}
+			m.rows = append(m.rows, row)
+		}
+	}
+	return nil
+}
+
+// Columns of the table
+func (m *promConn) Columns() []string { return m.tbl.Columns() }
+
+func (m *promConn) Next() schema.Message {
+	if !m.started {
+		m.started = true
+		if m.err = m.query(); m.err != nil {
+			return nil
+		}
+	}
+	if m.cursor >= len(m.rows) {
+		return nil
+	}
+	m.cursor++
+	return datasource.NewSqlDriverMessageMap(uint64(m.cursor), m.rows[m.cursor-1], m.tbl.FieldPositions)
+}
+
+// Err the error querying the samples, nil if all were read
+func (m *promConn) Err() error { return m.err }
+
+func (m *promConn) Close() error { return nil }
@@ -0,0 +1,184 @@
+package prometheus_test
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	u "github.com/araddon/gou"
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/prometheus"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+)
+
+var series = []map[string]string{
+	{"__name__": "http_requests_total", "job": "api", "instance": "a:80", "code": "200"},
+	{"__name__": "http_requests_total", "job": "api", "instance": "a:80", "code": "500"},
+	{"__name__": "http_requests_total", "job": "web", "instance": "b:80", "code": "500"},
+	{"__name__": "up", "job": "api", "instance": "a:80"},
+}
+
+// api serves the series above, recording the queries of query_range
+type api struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (m *api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var data interface{}
+	switch r.URL.Path {
+	case "/api/v1/label/__name__/values":
+		data = []string{"up", "http_requests_total"}
+	case "/api/v1/series":
+		matched := make([]map[string]string, 0)
+		for _, s := range series {
+			if s["__name__"] == q.Get("match[]") {
+				matched = append(matched, s)
+			}
+		}
+		data = matched
+	case "/api/v1/query_range":
+		query := q.Get("query")
+		if q.Get("start") == "" || q.Get("end") == "" || q.Get("step") != "30" {
+			json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": "bad range"})
+			return
+		}
+		m.mu.Lock()
+		m.queries = append(m.queries, query)
+		m.mu.Unlock()
+		result := make([]map[string]interface{}, 0)
+		for i, s := range series {
+			if !strings.HasPrefix(query, s["__name__"]) {
+				continue
+			}
+			matches := true
+			for label, val := range s {
+				if strings.Contains(query, label+"=") && !strings.Contains(query, label+`="`+val+`"`) {
+					matches = false
+				}
+			}
+			if matches {
+				result = append(result, map[string]interface{}{"metric": s, "values": []interface{}{
+					[]interface{}{1462357800, "1"},
+					[]interface{}{1462357860.5, "2.5"},
+				}})
+				if i == 2 {
+					result[len(result)-1]["values"] = []interface{}{[]interface{}{1462357800, "7"}}
+				}
+			}
+		}
+		data = map[string]interface{}{"resultType": "matrix", "result": result}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": data})
+}
+
+func TestPrometheusSource(t *testing.T) {
+	srv := &api{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	src, err := prometheus.NewPrometheusSource(u.JsonHelper{"url": ts.URL, "range": "2h", "step": "30s"})
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"http_requests_total", "up"}, src.Tables())
+	tbl, err := src.Table("http_requests_total")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"metric", "code", "instance", "job", "timestamp", "value"}, tbl.Columns())
+	_, err = src.Table("nope")
+	assert.T(t, err != nil)
+
+	sch := datasource.RegisterSchemaSource("prometheus", "prometheus", src)
+	t0 := time.Unix(1462357800, 0).UTC()
+	tests := []struct {
+		sql   string
+		cols  []string
+		query string
+		rows  [][]driver.Value
+	}{
+		{`SELECT instance, code, timestamp, value FROM http_requests_total WHERE job = "api" AND code = "500"`,
+			[]string{"instance", "code", "timestamp", "value"}, `http_requests_total{code="500",job="api"}`, [][]driver.Value{
+				{"a:80", "500", t0, 1.0},
+				{"a:80", "500", t0.Add(60*time.Second + 500*time.Millisecond), 2.5},
+			}},
+		// only the label equalities are sent, the rest of the where is evaluated
+		{`SELECT job, code, timestamp, value FROM http_requests_total WHERE code = "500" AND value > 2`,
+			[]string{"job", "code", "timestamp", "value"}, `http_requests_total{code="500"}`, [][]driver.Value{
+				{"api", "500", t0.Add(60*time.Second + 500*time.Millisecond), 2.5},
+				{"web", "500", t0, 7.0},
+			}},
+		{`SELECT metric, job, timestamp, value FROM up WHERE value > 1 OR job = "web"`,
+			[]string{"metric", "job", "timestamp", "value"}, `up`, [][]driver.Value{
+				{"up", "api", t0.Add(60*time.Second + 500*time.Millisecond), 2.5},
+			}},
+	}
+	for _, tt := range tests {
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema = sch
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		out := exec.NewResultRows(ctx, tt.cols)
+		job.RootTask.Add(out)
+		assert.T(t, job.Setup() == nil)
+		go job.Run()
+		rows := make([][]driver.Value, 0)
+		for {
+			dest := make([]driver.Value, 4)
+			if out.Next(dest) != nil {
+				break
+			}
+			rows = append(rows, dest)
+		}
+		job.Close()
+		assert.Equalf(t, tt.rows, rows, "sql %s", tt.sql)
+		srv.mu.Lock()
+		assert.Equalf(t, tt.query, srv.queries[len(srv.queries)-1], "sql %s", tt.sql)
+		srv.mu.Unlock()
+	}
+
+	_, err = prometheus.NewPrometheusSource(u.JsonHelper{"url": ts.URL, "range": "soon"})
+	assert.T(t, err != nil)
+	_, err = prometheus.NewPrometheusSource(u.JsonHelper{"url": ts.URL + "/nope"})
+	assert.T(t, err != nil)
+}
+
+func TestWriteMetrics(t *testing.T) {
+	var m datasource.Metrics
+	m.Request("accounts", 2*time.Millisecond, nil)
+	m.Read("accounts", 3, 100)
+	tables := m.Tables()
+	tables[0].Source = `db"1`
+
+	var buf strings.Builder
+	assert.T(t, prometheus.WriteMetrics(&buf, tables) == nil)
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE qlbridge_source_requests_total counter",
+		`qlbridge_source_requests_total{source="db\"1",table="accounts"} 1`,
+		`qlbridge_source_rows_total{source="db\"1",table="accounts"} 3`,
+		`qlbridge_source_bytes_total{source="db\"1",table="accounts"} 100`,
+		`qlbridge_source_errors_total{source="db\"1",table="accounts"} 0`,
+		"# TYPE qlbridge_source_request_duration_seconds histogram",
+		`qlbridge_source_request_duration_seconds_bucket{source="db\"1",table="accounts",le="0.001"} 0`,
+		`qlbridge_source_request_duration_seconds_bucket{source="db\"1",table="accounts",le="0.005"} 1`,
+		`qlbridge_source_request_duration_seconds_bucket{source="db\"1",table="accounts",le="+Inf"} 1`,
+		`qlbridge_source_request_duration_seconds_count{source="db\"1",table="accounts"} 1`,
+	} {
+		assert.Tf(t, strings.Contains(out, line+"\n"), "%s in\n%s", line, out)
+	}
+
+	w := httptest.NewRecorder()
+	prometheus.MetricsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.T(t, strings.Contains(w.Body.String(), "# TYPE qlbridge_source_rows_total counter"))
+}
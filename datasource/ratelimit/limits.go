@@ -0,0 +1,270 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/plan"
+)
+
+var (
+	// DefaultQuotaPeriod of row quotas of no quota_period
+	DefaultQuotaPeriod = 24 * time.Hour
+
+	// ErrRateLimited the error of a request (or row) of a source over its
+	// rate or concurrency limits, when failing fast or after waiting longer
+	// than MaxWait.  Transient, see plan.IsTransient.
+	ErrRateLimited = plan.Transient(fmt.Errorf("rate limit of source exceeded"))
+	// ErrQuotaExceeded the error of reading more rows of a source than its
+	// quota of the period
+	ErrQuotaExceeded = fmt.Errorf("row quota of source exceeded")
+	// ErrScanTooLarge the error of a scan reading more than MaxScanRows
+	ErrScanTooLarge = fmt.Errorf("scan read more rows than the limit of the source")
+)
+
+// Limits the limits of the use of a source.  Requests are the scans and key
+// lookups of it, rows those they read.
+type Limits struct {
+	QPS           float64       // requests per second, 0 for no limit
+	Burst         int           // requests at once over QPS, 0 for QPS (at least 1)
+	MaxConcurrent int           // scans and lookups at once, 0 for no limit
+	RowsPerSecond float64       // rows read per second, 0 for no limit
+	RowQuota      int64         // rows read per QuotaPeriod, 0 for no limit
+	QuotaPeriod   time.Duration // of the RowQuota, 0 for DefaultQuotaPeriod
+	MaxScanRows   int64         // rows read of one scan, 0 for no limit
+	MaxWait       time.Duration // how long to wait for a limit, 0 for as long as it takes
+	FailFast      bool          // fail instead of waiting for a limit
+}
+
+// LimitsFromSettings the limits of the settings of a source config, of
+// durations as strings
+//
+//   "settings" : {
+//       "qps" : 10, "burst" : 20, "max_concurrent" : 4,
+//       "rows_per_second" : 5000, "row_quota" : 1000000, "quota_period" : "24h",
+//       "max_scan_rows" : 100000, "max_wait" : "5s", "fail_fast" : false
+//   }
+func LimitsFromSettings(settings u.JsonHelper) (Limits, error) {
+	l := Limits{}
+	l.QPS = settings.Float64("qps")
+	l.Burst, _ = settings.IntSafe("burst")
+	l.MaxConcurrent, _ = settings.IntSafe("max_concurrent")
+	l.RowsPerSecond = settings.Float64("rows_per_second")
+	l.RowQuota = settings.Int64("row_quota")
+	l.MaxScanRows = settings.Int64("max_scan_rows")
+	l.FailFast, _ = settings.BoolSafe("fail_fast")
+	for key, dur := range map[string]*time.Duration{"quota_period": &l.QuotaPeriod, "max_wait": &l.MaxWait} {
+		if s := settings.String(key); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil || d < 0 {
+				return l, fmt.Errorf("invalid %s %q", key, s)
+			}
+			*dur = d
+		}
+	}
+	if l.QPS < 0 || l.RowsPerSecond < 0 || l.Burst < 0 || l.MaxConcurrent < 0 || l.RowQuota < 0 || l.MaxScanRows < 0 {
+		return l, fmt.Errorf("invalid negative rate limit %+v", l)
+	}
+	return l, nil
+}
+
+// LimitStats the stats of the limits of a RateLimitedSource
+type LimitStats struct {
+	Requests     int64         `json:"requests"`      // scans and lookups
+	Rows         int64         `json:"rows"`          // read
+	InFlight     int           `json:"in_flight"`     // scans and lookups running now
+	Waits        int64         `json:"waits"`         // requests (and rows) that waited for a limit
+	WaitDuration time.Duration `json:"wait_duration"` // total time waited
+	Rejected     int64         `json:"rejected"`      // requests (and rows) failed for a limit
+	QuotaUsed    int64         `json:"quota_used"`    // rows read of the quota this period
+}
+
+// bucket a token bucket of rate tokens per second, of at most burst
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, burst int) *bucket {
+	if rate <= 0 {
+		return nil
+	}
+	b := float64(burst)
+	if b <= 0 {
+		b = math.Max(rate, 1)
+	}
+	return &bucket{rate: rate, burst: b, tokens: b, last: time.Now()}
+}
+
+// reserve n tokens, the time to wait until they are available, taking them
+// only if take (ie the wait is acceptable)
+func (m *bucket) reserve(now time.Time, n float64, take func(wait time.Duration) bool) (time.Duration, bool) {
+	m.tokens = math.Min(m.burst, m.tokens+now.Sub(m.last).Seconds()*m.rate)
+	m.last = now
+	var wait time.Duration
+	if m.tokens < n {
+		wait = time.Duration((n - m.tokens) / m.rate * float64(time.Second))
+	}
+	if !take(wait) {
+		return wait, false
+	}
+	m.tokens -= n
+	return wait, true
+}
+
+// limiter the limits of a source, shared by all its conns
+type limiter struct {
+	limits Limits
+	slots  chan struct{} // of MaxConcurrent
+
+	mu          sync.Mutex
+	requests    *bucket
+	rows        *bucket
+	periodStart time.Time
+	stats       LimitStats
+}
+
+func newLimiter(l Limits) *limiter {
+	m := &limiter{limits: l, requests: newBucket(l.QPS, l.Burst), rows: newBucket(l.RowsPerSecond, 0),
+		periodStart: time.Now()}
+	if m.limits.QuotaPeriod <= 0 {
+		m.limits.QuotaPeriod = DefaultQuotaPeriod
+	}
+	if l.MaxConcurrent > 0 {
+		m.slots = make(chan struct{}, l.MaxConcurrent)
+	}
+	return m
+}
+
+// acceptable may a limit of wait be waited for
+func (m *limiter) acceptable(wait time.Duration) bool {
+	if wait <= 0 {
+		return true
+	}
+	return !m.limits.FailFast && (m.limits.MaxWait <= 0 || wait <= m.limits.MaxWait)
+}
+
+// take n tokens of b, waiting for them if acceptable
+func (m *limiter) take(b *bucket, n float64) error {
+	if b == nil {
+		return nil
+	}
+	m.mu.Lock()
+	wait, ok := b.reserve(time.Now(), n, m.acceptable)
+	if !ok {
+		m.stats.Rejected++
+		m.mu.Unlock()
+		return ErrRateLimited
+	}
+	if wait > 0 {
+		m.stats.Waits++
+		m.stats.WaitDuration += wait
+	}
+	m.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return nil
+}
+
+// begin a request, of a slot of the concurrent requests and a token of
+// the requests per second, end it once done
+func (m *limiter) begin() error {
+	if m.slots != nil {
+		if err := m.acquire(); err != nil {
+			return err
+		}
+	}
+	if err := m.take(m.requests, 1); err != nil {
+		m.release()
+		return err
+	}
+	m.mu.Lock()
+	m.stats.Requests++
+	m.stats.InFlight++
+	m.mu.Unlock()
+	return nil
+}
+
+// acquire a slot of the concurrent requests
+func (m *limiter) acquire() error {
+	select {
+	case m.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if !m.acceptable(time.Nanosecond) {
+		m.rejected()
+		return ErrRateLimited
+	}
+	var timeout <-chan time.Time
+	if m.limits.MaxWait > 0 {
+		timer := time.NewTimer(m.limits.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	start := time.Now()
+	select {
+	case m.slots <- struct{}{}:
+		m.mu.Lock()
+		m.stats.Waits++
+		m.stats.WaitDuration += time.Since(start)
+		m.mu.Unlock()
+		return nil
+	case <-timeout:
+		m.rejected()
+		return ErrRateLimited
+	}
+}
+
+func (m *limiter) rejected() {
+	m.mu.Lock()
+	m.stats.Rejected++
+	m.mu.Unlock()
+}
+
+func (m *limiter) release() {
+	if m.slots != nil {
+		<-m.slots
+	}
+}
+
+// end a request begun
+func (m *limiter) end() {
+	m.mu.Lock()
+	m.stats.InFlight--
+	m.mu.Unlock()
+	m.release()
+}
+
+// read n rows, of the quota of the period and rows per second
+func (m *limiter) read(n int64) error {
+	if n <= 0 {
+		return nil
+	}
+	m.mu.Lock()
+	if now := time.Now(); now.Sub(m.periodStart) >= m.limits.QuotaPeriod {
+		m.periodStart, m.stats.QuotaUsed = now, 0
+	}
+	if m.limits.RowQuota > 0 && m.stats.QuotaUsed+n > m.limits.RowQuota {
+		m.stats.Rejected++
+		m.mu.Unlock()
+		return ErrQuotaExceeded
+	}
+	m.stats.QuotaUsed += n
+	m.stats.Rows += n
+	m.mu.Unlock()
+	return m.take(m.rows, float64(n))
+}
+
+func (m *limiter) statsNow() LimitStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
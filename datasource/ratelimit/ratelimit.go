@@ -0,0 +1,280 @@
+// Ratelimit package implements a Datasource limiting the use of another
+// source (ie an api billed or throttled by request) to rates, concurrency
+// and quotas of rows, waiting for (or failing fast on) its limits.
+package ratelimit
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/expr/translate"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+)
+
+const (
+	// SourceType the source type of rate limited sources in the config
+	SourceType = "ratelimit"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Different Features of this Rate Limited Data Source
+	_ schema.Source             = (*RateLimitedSource)(nil)
+	_ datasource.MeteredSource  = (*RateLimitedSource)(nil)
+	_ schema.SourceSetup        = (*RateLimitedSource)(nil)
+	_ schema.SourceTableSchema  = (*RateLimitedSource)(nil)
+	_ schema.ConnScanner        = (*limitedConn)(nil)
+	_ schema.ConnColumns        = (*limitedConn)(nil)
+	_ schema.ConnSeeker         = (*limitedConn)(nil)
+	_ schema.IteratorErr        = (*limitedConn)(nil)
+	_ schema.SourceCapabilities = (*limitedConn)(nil)
+	_ translate.Translator      = (*limitedConn)(nil)
+)
+
+// RateLimitedSource a DataSource limiting the scans, key lookups and rows
+// read of another source to Limits, of the settings of the source config
+// (see LimitsFromSettings) and the "source" setting, the name of the
+// registered source limited.
+//
+//   "settings" : {
+//       "source" : "github_api",
+//       "qps" : 5, "max_concurrent" : 2, "row_quota" : 1000000, "max_wait" : "10s"
+//   }
+//
+// The limits are of all queries of the source.  A scan waits for its limits
+// (up to max_wait) or, if fail_fast, fails with ErrRateLimited, a scan over
+// the row quota fails with ErrQuotaExceeded.  The capabilities of the
+// source the limits keep (wheres, keys, order) are those of this source.
+type RateLimitedSource struct {
+	ds      schema.Source
+	limiter *limiter
+	metrics datasource.Metrics
+}
+
+// limitedConn a conn of the limited source, its scan a request of the
+// limits from its first row until its last (or it is closed)
+type limitedConn struct {
+	src     *RateLimitedSource
+	table   string
+	conn    schema.Conn
+	started bool
+	begun   bool // a request of the limits until done
+	done    bool
+	rows    int64
+	err     error
+}
+
+// NewRateLimitedSource a RateLimitedSource of ds, of the settings of a
+// source config, of the registered source of the "source" setting if ds
+// is nil
+func NewRateLimitedSource(ds schema.Source, settings u.JsonHelper) (*RateLimitedSource, error) {
+	m := &RateLimitedSource{ds: ds}
+	if err := m.load(settings); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewRateLimitedSourceLimits a RateLimitedSource of ds of limits
+func NewRateLimitedSourceLimits(ds schema.Source, limits Limits) *RateLimitedSource {
+	return &RateLimitedSource{ds: ds, limiter: newLimiter(limits)}
+}
+
+// Setup find the "source" to limit of the source config, and its limits
+// (see LimitsFromSettings), of a RateLimitedSource{} registered without
+// settings
+func (m *RateLimitedSource) Setup(ss *schema.SchemaSource) error {
+	return datasource.SetupSettings(ss, m.limiter != nil, m.load)
+}
+
+func (m *RateLimitedSource) load(settings u.JsonHelper) error {
+	if m.ds == nil {
+		name := settings.String("source")
+		if name == "" {
+			return fmt.Errorf("ratelimit source requires a source in settings")
+		}
+		if m.ds = datasource.DataSourcesRegistry().Get(name); m.ds == nil {
+			return fmt.Errorf("could not find source %q to limit", name)
+		}
+	}
+	limits, err := LimitsFromSettings(settings)
+	if err != nil {
+		return err
+	}
+	m.limiter = newLimiter(limits)
+	return nil
+}
+
+// Stats of the limits of the source
+func (m *RateLimitedSource) Stats() LimitStats { return m.limiter.statsNow() }
+
+func (m *RateLimitedSource) Tables() []string { return m.ds.Tables() }
+
+func (m *RateLimitedSource) Table(table string) (*schema.Table, error) {
+	if sts, ok := m.ds.(schema.SourceTableSchema); ok {
+		return sts.Table(table)
+	}
+	return nil, schema.ErrNotFound
+}
+
+// Open a conn of the limited source, which is not a request of the limits
+// until scanned
+func (m *RateLimitedSource) Open(table string) (schema.Conn, error) {
+	conn, err := m.ds.Open(table)
+	if err != nil {
+		return nil, err
+	}
+	return &limitedConn{src: m, table: table, conn: conn}, nil
+}
+
+// Close the limits, the limited source is not closed
+func (m *RateLimitedSource) Close() error { return nil }
+
+func (m *RateLimitedSource) Metrics() *datasource.Metrics { return &m.metrics }
+
+// Capabilities those of the conn of the limited source the limits keep
+func (m *limitedConn) Capabilities() *schema.Capabilities {
+	caps := plan.CapabilitiesOf(m.conn)
+	return &schema.Capabilities{
+		Scan:      caps.Scan,
+		Filter:    caps.Filter,
+		FilterOps: caps.FilterOps,
+		SortedBy:  caps.SortedBy,
+		Seek:      caps.Seek,
+		KeyColumn: caps.KeyColumn,
+	}
+}
+
+func (m *limitedConn) Columns() []string {
+	if cc, ok := m.conn.(schema.ConnColumns); ok {
+		return cc.Columns()
+	}
+	if tbl, err := m.src.Table(m.table); err == nil {
+		return tbl.Columns()
+	}
+	return nil
+}
+
+func (m *limitedConn) Translate(node expr.Node) (interface{}, error) {
+	t, ok := m.conn.(translate.Translator)
+	if !ok {
+		return nil, fmt.Errorf("limited source of %q does not translate wheres", m.table)
+	}
+	return t.Translate(node)
+}
+
+func (m *limitedConn) SetNative(native interface{}) {
+	if sn, ok := m.conn.(interface {
+		SetNative(native interface{})
+	}); ok {
+		sn.SetNative(native)
+	}
+}
+
+// Next the next row of the scan of the limited source, nil once a limit
+// fails it (see Err)
+func (m *limitedConn) Next() schema.Message {
+	if m.done {
+		return nil
+	}
+	if !m.started {
+		m.started = true
+		if _, ok := m.conn.(schema.ConnScanner); !ok {
+			return m.fail(fmt.Errorf("limited source of %q can not be scanned", m.table))
+		}
+		if err := m.src.limiter.begin(); err != nil {
+			return m.fail(err)
+		}
+		m.begun = true
+	}
+	msg := m.conn.(schema.ConnScanner).Next()
+	if msg == nil {
+		if ie, ok := m.conn.(schema.IteratorErr); ok {
+			m.err = ie.Err()
+		}
+		return m.fail(m.err)
+	}
+	if m.rows++; m.src.limiter.limits.MaxScanRows > 0 && m.rows > m.src.limiter.limits.MaxScanRows {
+		return m.fail(ErrScanTooLarge)
+	}
+	if err := m.src.limiter.read(1); err != nil {
+		return m.fail(err)
+	}
+	return msg
+}
+
+// fail end the scan with err, nil for at its end
+func (m *limitedConn) fail(err error) schema.Message {
+	ended := m.begun && !m.done
+	m.done, m.err = true, err
+	if ended {
+		m.src.limiter.end()
+	}
+	return nil
+}
+
+func (m *limitedConn) Err() error { return m.err }
+
+func (m *limitedConn) seeker() (schema.ConnSeeker, error) {
+	seeker, ok := m.conn.(schema.ConnSeeker)
+	if !ok {
+		return nil, schema.ErrNotImplemented
+	}
+	return seeker, nil
+}
+
+func (m *limitedConn) CanSeek(stmt *rel.SqlSelect) bool {
+	seeker, err := m.seeker()
+	return err == nil && seeker.CanSeek(stmt)
+}
+
+// Get the row of key, a request of the limits
+func (m *limitedConn) Get(key driver.Value) (schema.Message, error) {
+	seeker, err := m.seeker()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.src.limiter.begin(); err != nil {
+		return nil, err
+	}
+	defer m.src.limiter.end()
+	msg, err := seeker.Get(key)
+	if err == nil {
+		if err := m.src.limiter.read(1); err != nil {
+			return nil, err
+		}
+	}
+	return msg, err
+}
+
+// MultiGet the rows of keys, a request of the limits
+func (m *limitedConn) MultiGet(keys []driver.Value) ([]schema.Message, error) {
+	seeker, err := m.seeker()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.src.limiter.begin(); err != nil {
+		return nil, err
+	}
+	defer m.src.limiter.end()
+	msgs, err := seeker.MultiGet(keys)
+	if err == nil {
+		if err := m.src.limiter.read(int64(len(msgs))); err != nil {
+			return nil, err
+		}
+	}
+	return msgs, err
+}
+
+// Close the conn of the limited source, ending its scan
+func (m *limitedConn) Close() error {
+	m.fail(m.err)
+	return m.conn.Close()
+}
@@ -0,0 +1,146 @@
+package ratelimit_test
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	u "github.com/araddon/gou"
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/datasource/ratelimit"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+func newUsers() *membtree.StaticDataSource {
+	return membtree.NewStaticDataSource("users", 0, [][]driver.Value{
+		{int64(1), "ann"},
+		{int64(2), "bob"},
+		{int64(3), "cat"},
+	}, []string{"id", "name"})
+}
+
+// scan the rows of users, and the error that stopped the scan
+func scan(t *testing.T, src schema.Source) (int, error) {
+	conn, err := src.Open("users")
+	assert.Tf(t, err == nil, "no error %v", err)
+	defer conn.Close()
+	scanner := conn.(schema.ConnScanner)
+	n := 0
+	for msg := scanner.Next(); msg != nil; msg = scanner.Next() {
+		n++
+	}
+	return n, conn.(schema.IteratorErr).Err()
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		settings u.JsonHelper
+		scans    int   // scans of 3 rows
+		rows     int   // read of the last scan
+		err      error // of the last scan
+	}{
+		{u.JsonHelper{"qps": 1, "fail_fast": true}, 2, 0, ratelimit.ErrRateLimited},
+		// waits longer than max wait
+		{u.JsonHelper{"qps": 1, "max_wait": "10ms"}, 2, 0, ratelimit.ErrRateLimited},
+		{u.JsonHelper{"row_quota": 4}, 2, 1, ratelimit.ErrQuotaExceeded},
+		{u.JsonHelper{"max_scan_rows": 2}, 1, 2, ratelimit.ErrScanTooLarge},
+		{u.JsonHelper{"rows_per_second": 2, "fail_fast": true}, 1, 2, ratelimit.ErrRateLimited},
+	}
+	for _, tt := range tests {
+		src, err := ratelimit.NewRateLimitedSource(newUsers(), tt.settings)
+		assert.Tf(t, err == nil, "no error %v", err)
+		var n int
+		for i := 0; i < tt.scans; i++ {
+			n, err = scan(t, src)
+			if i < tt.scans-1 {
+				assert.Tf(t, n == 3 && err == nil, "scan %d of %v: %d %v", i, tt.settings, n, err)
+			}
+		}
+		assert.Equalf(t, tt.rows, n, "rows of %v", tt.settings)
+		assert.Equalf(t, tt.err, err, "error of %v", tt.settings)
+		assert.Equal(t, 0, src.Stats().InFlight)
+	}
+	assert.T(t, plan.IsTransient(ratelimit.ErrRateLimited))
+	assert.T(t, !plan.IsTransient(ratelimit.ErrQuotaExceeded))
+
+	// waits for the rate, of no burst
+	src, _ := ratelimit.NewRateLimitedSource(newUsers(), u.JsonHelper{"qps": 20, "burst": 1})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		n, err := scan(t, src)
+		assert.Tf(t, n == 3 && err == nil, "scan %d: %d %v", i, n, err)
+	}
+	assert.Tf(t, time.Since(start) >= 90*time.Millisecond, "waited %v", time.Since(start))
+	stats := src.Stats()
+	assert.Equal(t, int64(3), stats.Requests)
+	assert.Equal(t, int64(9), stats.Rows)
+	assert.Equal(t, int64(2), stats.Waits)
+
+	_, err := ratelimit.LimitsFromSettings(u.JsonHelper{"max_wait": "soon"})
+	assert.T(t, err != nil)
+	_, err = ratelimit.LimitsFromSettings(u.JsonHelper{"qps": -1.0})
+	assert.T(t, err != nil)
+	_, err = ratelimit.NewRateLimitedSource(nil, u.JsonHelper{"source": "ratelimit_missing"})
+	assert.T(t, err != nil)
+}
+
+func TestConcurrency(t *testing.T) {
+	src := ratelimit.NewRateLimitedSourceLimits(newUsers(), ratelimit.Limits{MaxConcurrent: 1, FailFast: true})
+	first, _ := src.Open("users")
+	assert.T(t, first.(schema.ConnScanner).Next() != nil)
+	assert.Equal(t, 1, src.Stats().InFlight)
+
+	// scans and lookups wait for the first scan
+	n, err := scan(t, src)
+	assert.Tf(t, n == 0 && err == ratelimit.ErrRateLimited, "%d %v", n, err)
+	second, _ := src.Open("users")
+	_, err = second.(schema.ConnSeeker).Get(int64(1))
+	assert.Equal(t, ratelimit.ErrRateLimited, err)
+	second.Close()
+
+	first.Close()
+	assert.Equal(t, 0, src.Stats().InFlight)
+	second, _ = src.Open("users")
+	msg, err := second.(schema.ConnSeeker).Get(int64(1))
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []driver.Value{int64(1), "ann"}, msg.Body().(*datasource.SqlDriverMessageMap).Values())
+	second.Close()
+
+	// waiting for a scan to end
+	src = ratelimit.NewRateLimitedSourceLimits(newUsers(), ratelimit.Limits{MaxConcurrent: 1, MaxWait: time.Second})
+	first, _ = src.Open("users")
+	first.(schema.ConnScanner).Next()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		for first.(schema.ConnScanner).Next() != nil {
+		}
+	}()
+	second, _ = src.Open("users")
+	_, err = second.(schema.ConnSeeker).Get(int64(2))
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, int64(1), src.Stats().Waits)
+	second.Close()
+	first.Close()
+}
+
+func TestQueryLimited(t *testing.T) {
+	src, err := ratelimit.NewRateLimitedSource(newUsers(), u.JsonHelper{"max_scan_rows": 2})
+	assert.Tf(t, err == nil, "no error %v", err)
+	sch := datasource.RegisterSchemaSource("ratelimit_users", "ratelimit_users", src)
+
+	ctx := plan.NewContext(`SELECT id, name FROM users WHERE id > 0`)
+	ctx.Schema = sch
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	msgs := make([]schema.Message, 0)
+	job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+	assert.T(t, job.Setup() == nil)
+	err = job.Run()
+	job.Close()
+	assert.Tf(t, err != nil, "scan of too many rows fails the query")
+}
@@ -0,0 +1,620 @@
+// Redis package implements a Datasource of the keys of a Redis server,
+// each table the strings, hashes, sorted sets or streams of a key pattern.
+package redis
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/expr/translate"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Different Features of this Redis Data Source
+	_ schema.Source            = (*RedisSource)(nil)
+	_ datasource.MeteredSource = (*RedisSource)(nil)
+	_ schema.SourceSetup       = (*RedisSource)(nil)
+	_ schema.SourceTableSchema = (*RedisSource)(nil)
+	_ datasource.PooledSource  = (*RedisSource)(nil)
+	_ schema.HealthChecker     = (*RedisSource)(nil)
+	_ schema.ConnScanner       = (*redisConn)(nil)
+	_ schema.ConnColumns       = (*redisConn)(nil)
+	_ schema.IteratorErr       = (*redisConn)(nil)
+	_ translate.Translator     = (*redisConn)(nil)
+
+	// SampleCount entries read to find the fields (columns) of hash and
+	// stream tables
+	SampleCount = 100
+)
+
+const (
+	// KeyColumn the column of the key of each row
+	KeyColumn = "key"
+)
+
+// RedisSource a DataSource of the keys of a Redis server, a table per key
+// pattern (and type).  Created with NewRedisSource, or registered as a type
+// whose source config settings are read on Setup:
+//
+//   "settings" : {
+//       "address"  : "localhost:6379",
+//       "password" : "",
+//       "db"       : 0,
+//       "pool"     : {"max_open": 10, "max_idle": 2, "max_wait": "5s"},
+//       "tables" : {
+//           "sessions" : {"pattern" : "session:*", "type" : "string"},
+//           "users"    : {"pattern" : "user:*",    "type" : "hash"},
+//           "scores"   : {"pattern" : "board:*",   "type" : "zset"},
+//           "events"   : {"pattern" : "events:*",  "type" : "stream"}
+//       }
+//   }
+//
+// Each table has the key column, then by type:
+//   string   value                       a row per key
+//   hash     the fields of the hashes    a row per key
+//   zset     member, score               a row per member
+//   stream   id, the fields of entries   a row per entry
+// the fields of hashes and streams from the first SampleCount entries.
+//
+// A where of key equalities (key = "user:1", key IN (..)) reads just those
+// keys (GET, HGETALL ..), else the keys of the pattern are SCAN'd.  Keys
+// not of the table type are skipped.
+//
+// The connections of scans are pooled, of the limits of the pool settings
+// (see datasource.PoolConfigFromSettings).
+type RedisSource struct {
+	mu        sync.Mutex
+	address   string
+	password  string
+	db        int
+	timeout   time.Duration
+	scanCount int
+	names     []string
+	tables    map[string]*keyspace
+	schemas   map[string]*schema.Table
+	pool      *datasource.ConnPool
+	metrics   datasource.Metrics
+}
+
+// keyspace the keys of a table
+type keyspace struct {
+	name    string
+	pattern string
+	kind    string
+	match   *regexp.Regexp
+}
+
+// entry a hash, string, member of a sorted set or entry of a stream, its
+// fields as name, value pairs
+type entry struct {
+	id     string
+	fields []string
+}
+
+// redisConn the scan of the keys of a table
+type redisConn struct {
+	src      *RedisSource
+	ks       *keyspace
+	cl       *client
+	keys     []string // keys pushed down, else scanned
+	pushed   bool
+	cursor   string
+	scanned  bool
+	pending  []string
+	entries  []entry
+	key      string
+	cols     []string
+	types    []value.ValueType
+	colindex map[string]int
+	rowct    uint64
+	err      error
+}
+
+// NewRedisSource a RedisSource of the settings of a source config
+func NewRedisSource(settings u.JsonHelper) (*RedisSource, error) {
+	m := &RedisSource{}
+	if err := m.load(settings); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Setup connect to the "address" (and "password", "db") of the source config
+// and read the keyspaces of its "tables" settings, of a RedisSource{}
+// registered without settings
+func (m *RedisSource) Setup(ss *schema.SchemaSource) error {
+	m.mu.Lock()
+	loaded := m.tables != nil
+	m.mu.Unlock()
+	return datasource.SetupSettings(ss, loaded, m.load)
+}
+
+func (m *RedisSource) load(settings u.JsonHelper) error {
+	tables := settings.Helper("tables")
+	if len(tables) == 0 {
+		return fmt.Errorf("redis source requires tables in settings")
+	}
+	kss := make(map[string]*keyspace, len(tables))
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		conf := tables.Helper(name)
+		ks := &keyspace{
+			name:    strings.ToLower(name),
+			pattern: conf.String("pattern"),
+			kind:    strings.ToLower(conf.String("type")),
+		}
+		if ks.pattern == "" {
+			return fmt.Errorf("redis table %q requires a pattern", name)
+		}
+		switch ks.kind {
+		case "string", "hash", "zset", "stream":
+		default:
+			return fmt.Errorf("redis table %q has unknown type %q", name, ks.kind)
+		}
+		ks.match = globRegexp(ks.pattern)
+		kss[ks.name] = ks
+		names = append(names, ks.name)
+	}
+	sort.Strings(names)
+
+	address := settings.String("address")
+	if address == "" {
+		address = "localhost:6379"
+	}
+	timeout := 10 * time.Second
+	if secs, ok := settings.IntSafe("timeout"); ok && secs > 0 {
+		timeout = time.Duration(secs) * time.Second
+	}
+	scanCount := 100
+	if n, ok := settings.IntSafe("scan_count"); ok && n > 0 {
+		scanCount = n
+	}
+	poolConf, err := datasource.PoolConfigFromSettings(settings)
+	if err != nil {
+		return err
+	}
+	password, db := settings.String("password"), settings.Int("db")
+	pool := datasource.NewConnPool(poolConf, func() (io.Closer, error) {
+		return dial(address, password, db, timeout)
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pool != nil {
+		m.pool.Close()
+	}
+	m.address, m.password, m.db = address, password, db
+	m.timeout, m.scanCount = timeout, scanCount
+	m.names, m.tables = names, kss
+	m.schemas = make(map[string]*schema.Table)
+	m.pool = pool
+	return nil
+}
+
+// globRegexp the regexp of a redis key pattern, * and ? wildcards
+func globRegexp(pattern string) *regexp.Regexp {
+	re := regexp.QuoteMeta(pattern)
+	re = strings.Replace(re, `\*`, ".*", -1)
+	re = strings.Replace(re, `\?`, ".", -1)
+	return regexp.MustCompile("^" + re + "$")
+}
+
+// dial get a client of the pool
+func (m *RedisSource) dial() (*client, error) {
+	m.mu.Lock()
+	pool := m.pool
+	m.mu.Unlock()
+	if pool == nil {
+		return nil, fmt.Errorf("redis source has no settings")
+	}
+	cl, err := pool.Get()
+	if err != nil {
+		return nil, err
+	}
+	return cl.(*client), nil
+}
+
+// release cl back to the pool, closed if err broke it (not an error reply)
+func (m *RedisSource) release(cl *client, err error) {
+	if _, isReply := err.(redisError); isReply {
+		err = nil
+	}
+	m.mu.Lock()
+	pool := m.pool
+	m.mu.Unlock()
+	pool.Put(cl, err)
+}
+
+// CheckHealth PING the server
+func (m *RedisSource) CheckHealth() error {
+	cl, err := m.dial()
+	if err != nil {
+		return err
+	}
+	_, err = cl.do("PING")
+	m.release(cl, err)
+	return err
+}
+
+// PoolStats the stats of the pool of connections
+func (m *RedisSource) PoolStats() datasource.PoolStats {
+	m.mu.Lock()
+	pool := m.pool
+	m.mu.Unlock()
+	if pool == nil {
+		return datasource.PoolStats{}
+	}
+	return pool.Stats()
+}
+
+func (m *RedisSource) Tables() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.names
+}
+
+// Table the schema of table, the fields of hashes and streams sampled from
+// their first entries
+func (m *RedisSource) Table(table string) (*schema.Table, error) {
+	table = strings.ToLower(table)
+	m.mu.Lock()
+	tbl, ok := m.schemas[table]
+	ks := m.tables[table]
+	m.mu.Unlock()
+	if ok {
+		return tbl, nil
+	}
+	if ks == nil {
+		return nil, schema.ErrNotFound
+	}
+
+	cols := []string{KeyColumn}
+	types := []value.ValueType{value.StringType}
+	switch ks.kind {
+	case "string":
+		cols, types = append(cols, "value"), append(types, value.StringType)
+	case "zset":
+		cols, types = append(cols, "member", "score"), append(types, value.StringType, value.NumberType)
+	case "stream":
+		cols, types = append(cols, "id"), append(types, value.StringType)
+		fallthrough
+	case "hash":
+		fields, err := m.sampleFields(ks)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range fields {
+			cols, types = append(cols, field), append(types, value.StringType)
+		}
+	}
+
+	tbl = schema.NewTable(table)
+	for i, col := range cols {
+		tbl.AddField(schema.NewFieldBase(col, types[i], 64, "redis"))
+	}
+	tbl.SetColumns(cols)
+	m.mu.Lock()
+	m.schemas[table] = tbl
+	m.mu.Unlock()
+	return tbl, nil
+}
+
+// sampleFields the field names of the first SampleCount entries of ks
+func (m *RedisSource) sampleFields(ks *keyspace) ([]string, error) {
+	cl, err := m.dial()
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{src: m, ks: ks, cl: cl}
+	defer conn.Close()
+	seen := make(map[string]bool)
+	fields := make([]string, 0)
+	for n := 0; n < SampleCount; n++ {
+		e, ok := conn.nextEntry()
+		if !ok {
+			break
+		}
+		for i := 0; i+1 < len(e.fields); i += 2 {
+			field := strings.ToLower(e.fields[i])
+			if !seen[field] {
+				seen[field] = true
+				fields = append(fields, field)
+			}
+		}
+	}
+	if conn.err != nil {
+		return nil, conn.err
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// Open a scan of the keys of table
+func (m *RedisSource) Open(table string) (schema.Conn, error) {
+	m.mu.Lock()
+	ks, ok := m.tables[strings.ToLower(table)]
+	m.mu.Unlock()
+	if !ok {
+		return nil, schema.ErrNotFound
+	}
+	return &redisConn{src: m, ks: ks}, nil
+}
+
+// Close the pool of connections
+func (m *RedisSource) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pool != nil {
+		return m.pool.Close()
+	}
+	return nil
+}
+
+func (m *RedisSource) Metrics() *datasource.Metrics { return &m.metrics }
+
+// Translate the key equalities of the where (AND-ed key = "a", key IN
+// ("a","b")) into the keys to read.  Other expressions are not translated,
+// the where is still evaluated on the rows.
+func (m *redisConn) Translate(node expr.Node) (interface{}, error) {
+	keys := make([]string, 0)
+	keyEqualities(node, &keys)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no key equalities in %s", node)
+	}
+	return keys, nil
+}
+
+func keyEqualities(node expr.Node, keys *[]string) {
+	bn, ok := node.(*expr.BinaryNode)
+	if !ok || len(bn.Args) != 2 {
+		return
+	}
+	switch bn.Operator.T {
+	case lex.TokenLogicAnd:
+		// either side, the keys of one are a superset of the rows of both
+		before := len(*keys)
+		keyEqualities(bn.Args[0], keys)
+		if len(*keys) > before {
+			return
+		}
+		keyEqualities(bn.Args[1], keys)
+	case lex.TokenEqual, lex.TokenEqualEqual:
+		col, lit := bn.Args[0], bn.Args[1]
+		if _, isIdent := col.(*expr.IdentityNode); !isIdent {
+			col, lit = lit, col
+		}
+		if sn, ok := lit.(*expr.StringNode); ok && isKey(col) {
+			*keys = append(*keys, sn.Text)
+		}
+	case lex.TokenIN:
+		arr, ok := bn.Args[1].(*expr.ArrayNode)
+		if !ok || !isKey(bn.Args[0]) {
+			return
+		}
+		in := make([]string, 0, len(arr.Args))
+		for _, arg := range arr.Args {
+			sn, ok := arg.(*expr.StringNode)
+			if !ok {
+				return
+			}
+			in = append(in, sn.Text)
+		}
+		*keys = append(*keys, in...)
+	}
+}
+
+// isKey whether node is the key column
+func isKey(node expr.Node) bool {
+	in, ok := node.(*expr.IdentityNode)
+	if !ok {
+		return false
+	}
+	name := in.Text
+	if _, right, hasLeft := expr.LeftRight(name); hasLeft {
+		name = right
+	}
+	return strings.ToLower(name) == KeyColumn
+}
+
+// SetNative the keys pushed down by the planner (see Translate)
+func (m *redisConn) SetNative(native interface{}) {
+	if keys, ok := native.([]string); ok {
+		m.keys, m.pushed = keys, true
+	}
+}
+
+// Columns of the table
+func (m *redisConn) Columns() []string {
+	tbl, err := m.src.Table(m.ks.name)
+	if err != nil {
+		u.Warnf("could not read columns of %q: %v", m.ks.name, err)
+		return nil
+	}
+	return tbl.Columns()
+}
+
+func (m *redisConn) Next() schema.Message {
+	if m.cl == nil && m.err == nil {
+		m.err = m.start()
+	}
+	if m.err != nil {
+		return nil
+	}
+	e, ok := m.nextEntry()
+	if !ok {
+		return nil
+	}
+	row := make([]driver.Value, len(m.cols))
+	row[0] = m.key
+	if m.ks.kind == "zset" || m.ks.kind == "stream" {
+		row[1] = e.id
+	}
+	for i := 0; i+1 < len(e.fields); i += 2 {
+		idx, ok := m.colindex[strings.ToLower(e.fields[i])]
+		if !ok {
+			continue
+		}
+		row[idx] = e.fields[i+1]
+		if m.types[idx] == value.NumberType {
+			if f, err := strconv.ParseFloat(e.fields[i+1], 64); err == nil {
+				row[idx] = f
+			}
+		}
+	}
+	m.rowct++
+	return datasource.NewSqlDriverMessageMap(m.rowct, row, m.colindex)
+}
+
+// start the scan, connecting to the server
+func (m *redisConn) start() error {
+	tbl, err := m.src.Table(m.ks.name)
+	if err != nil {
+		return err
+	}
+	m.cols = tbl.Columns()
+	m.colindex = make(map[string]int, len(m.cols))
+	m.types = make([]value.ValueType, len(m.cols))
+	for i, col := range m.cols {
+		m.colindex[col] = i
+		m.types[i] = tbl.Fields[i].Type
+	}
+	m.cl, err = m.src.dial()
+	return err
+}
+
+// nextEntry the next entry of the keys of the table, false at the end or
+// on error
+func (m *redisConn) nextEntry() (entry, bool) {
+	for m.err == nil {
+		if len(m.entries) > 0 {
+			e := m.entries[0]
+			m.entries = m.entries[1:]
+			return e, true
+		}
+		key, ok := m.nextKey()
+		if !ok {
+			break
+		}
+		m.key = key
+		m.entries, m.err = m.read(key)
+	}
+	return entry{}, false
+}
+
+// nextKey the next key pushed down, or of the pattern by SCAN
+func (m *redisConn) nextKey() (string, bool) {
+	if m.pushed {
+		for len(m.keys) > 0 {
+			key := m.keys[0]
+			m.keys = m.keys[1:]
+			if m.ks.match.MatchString(key) {
+				return key, true
+			}
+		}
+		return "", false
+	}
+	for len(m.pending) == 0 {
+		if m.scanned {
+			return "", false
+		}
+		if m.cursor == "" {
+			m.cursor = "0"
+		}
+		reply, err := m.cl.do("SCAN", m.cursor, "MATCH", m.ks.pattern, "COUNT", strconv.Itoa(m.src.scanCount))
+		if err != nil {
+			m.err = err
+			return "", false
+		}
+		page, _ := reply.([]interface{})
+		if len(page) != 2 {
+			m.err = fmt.Errorf("invalid SCAN reply %v", reply)
+			return "", false
+		}
+		m.cursor, _ = page[0].(string)
+		m.scanned = m.cursor == "0"
+		m.pending = strs(page[1])
+	}
+	key := m.pending[0]
+	m.pending = m.pending[1:]
+	return key, true
+}
+
+// read the entries of key, none if it does not exist or is not of the
+// table type
+func (m *redisConn) read(key string) ([]entry, error) {
+	var reply interface{}
+	var err error
+	switch m.ks.kind {
+	case "string":
+		reply, err = m.cl.do("GET", key)
+	case "hash":
+		reply, err = m.cl.do("HGETALL", key)
+	case "zset":
+		reply, err = m.cl.do("ZRANGE", key, "0", "-1", "WITHSCORES")
+	case "stream":
+		reply, err = m.cl.do("XRANGE", key, "-", "+")
+	}
+	if rerr, ok := err.(redisError); ok && strings.HasPrefix(string(rerr), "WRONGTYPE") {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	switch m.ks.kind {
+	case "string":
+		if s, ok := reply.(string); ok {
+			return []entry{{fields: []string{"value", s}}}, nil
+		}
+	case "hash":
+		if fields := strs(reply); len(fields) > 0 {
+			return []entry{{fields: fields}}, nil
+		}
+	case "zset":
+		pairs := strs(reply)
+		entries := make([]entry, 0, len(pairs)/2)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			entries = append(entries, entry{id: pairs[i], fields: []string{"score", pairs[i+1]}})
+		}
+		return entries, nil
+	case "stream":
+		items, _ := reply.([]interface{})
+		entries := make([]entry, 0, len(items))
+		for _, item := range items {
+			parts, _ := item.([]interface{})
+			if len(parts) != 2 {
+				continue
+			}
+			id, _ := parts[0].(string)
+			entries = append(entries, entry{id: id, fields: strs(parts[1])})
+		}
+		return entries, nil
+	}
+	return nil, nil
+}
+
+// Err the error reading the keys, nil if all were read
+func (m *redisConn) Err() error { return m.err }
+
+// Close the scan, its client back to the pool
+func (m *redisConn) Close() error {
+	if m.cl != nil {
+		m.src.release(m.cl, m.err)
+		m.cl = nil
+	}
+	return nil
+}
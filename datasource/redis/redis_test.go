@@ -0,0 +1,223 @@
+package redis_test
+
+import (
+	"bufio"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	u "github.com/araddon/gou"
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/redis"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// server a redis server of a few keys, recording the commands it is sent
+type server struct {
+	mu       sync.Mutex
+	ln       net.Listener
+	commands []string
+	keys     map[string][]string // type, then the values of the key
+}
+
+func newServer(t *testing.T) *server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.T(t, err == nil)
+	m := &server{ln: ln, keys: map[string][]string{
+		"session:1": {"string", "aaron"},
+		"session:2": {"string", "bob"},
+		"user:1":    {"hash", "name", "aaron", "city", "sf"},
+		"user:2":    {"hash", "name", "bob", "age", "40"},
+		"user:3":    {"string", "not a hash"},
+		"board:1":   {"zset", "aaron", "10", "bob", "7.5"},
+		"events:a":  {"stream", "1-0", "kind", "open", "2-0", "kind", "close"},
+	}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(conn)
+		}
+	}()
+	return m
+}
+
+func (m *server) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			line, _ = r.ReadString('\n')
+			size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			buf := make([]byte, size+2)
+			io.ReadFull(r, buf)
+			args[i] = string(buf[:size])
+		}
+		m.mu.Lock()
+		m.commands = append(m.commands, strings.Join(args, " "))
+		reply := m.reply(args)
+		m.mu.Unlock()
+		io.WriteString(conn, reply)
+	}
+}
+
+func bulks(vals []string) string {
+	out := fmt.Sprintf("*%d\r\n", len(vals))
+	for _, v := range vals {
+		out += fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	}
+	return out
+}
+
+func (m *server) reply(args []string) string {
+	var val []string
+	if len(args) > 1 {
+		val = m.keys[args[1]]
+	}
+	kinds := map[string]string{"GET": "string", "HGETALL": "hash", "ZRANGE": "zset", "XRANGE": "stream"}
+	if kind, ok := kinds[args[0]]; ok && val != nil && val[0] != kind {
+		return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+	}
+	switch args[0] {
+	case "AUTH", "SELECT":
+		return "+OK\r\n"
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		if val == nil {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(val[1]), val[1])
+	case "HGETALL", "ZRANGE":
+		if val == nil {
+			return "*0\r\n"
+		}
+		return bulks(val[1:])
+	case "XRANGE":
+		out := fmt.Sprintf("*%d\r\n", (len(val)-1)/3)
+		for i := 1; i+2 < len(val); i += 3 {
+			out += "*2\r\n" + bulks(val[i:i+1])[4:] + bulks(val[i+1:i+3])
+		}
+		return out
+	case "SCAN":
+		// a page of COUNT keys of the pattern, the cursor the offset
+		cursor, _ := strconv.Atoi(args[1])
+		count, _ := strconv.Atoi(args[5])
+		re := regexp.MustCompile("^" + strings.Replace(regexp.QuoteMeta(args[3]), `\*`, ".*", -1) + "$")
+		keys := make([]string, 0)
+		for key := range m.keys {
+			if re.MatchString(key) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		end, next := cursor+count, strconv.Itoa(cursor+count)
+		if end >= len(keys) {
+			end, next = len(keys), "0"
+		}
+		return "*2\r\n" + fmt.Sprintf("$%d\r\n%s\r\n", len(next), next) + bulks(keys[cursor:end])
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func scan(t *testing.T, conn schema.Conn) []string {
+	rows := make([]string, 0)
+	scanner := conn.(schema.ConnScanner)
+	for msg := scanner.Next(); msg != nil; msg = scanner.Next() {
+		rows = append(rows, fmt.Sprintf("%v", msg.(*datasource.SqlDriverMessageMap).Values()))
+	}
+	assert.Tf(t, scanner.(schema.IteratorErr).Err() == nil, "no error %v", scanner.(schema.IteratorErr).Err())
+	return rows
+}
+
+func TestRedisSource(t *testing.T) {
+	srv := newServer(t)
+	defer srv.ln.Close()
+
+	src, err := redis.NewRedisSource(u.JsonHelper{
+		"address":    srv.ln.Addr().String(),
+		"password":   "secret",
+		"scan_count": 1,
+		"pool":       map[string]interface{}{"max_open": 1},
+		"tables": map[string]interface{}{
+			"sessions": map[string]interface{}{"pattern": "session:*", "type": "string"},
+			"users":    map[string]interface{}{"pattern": "user:*", "type": "hash"},
+			"scores":   map[string]interface{}{"pattern": "board:*", "type": "zset"},
+			"events":   map[string]interface{}{"pattern": "events:*", "type": "stream"},
+		},
+	})
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"events", "scores", "sessions", "users"}, src.Tables())
+
+	tests := []struct {
+		table string
+		cols  []string
+		rows  []string
+	}{
+		{"sessions", []string{"key", "value"}, []string{"[session:1 aaron]", "[session:2 bob]"}},
+		{"users", []string{"key", "age", "city", "name"}, []string{"[user:1 <nil> sf aaron]", "[user:2 40 <nil> bob]"}},
+		{"scores", []string{"key", "member", "score"}, []string{"[board:1 aaron 10]", "[board:1 bob 7.5]"}},
+		{"events", []string{"key", "id", "kind"}, []string{"[events:a 1-0 open]", "[events:a 2-0 close]"}},
+	}
+	for _, tt := range tests {
+		tbl, err := src.Table(tt.table)
+		assert.Tf(t, err == nil, "no error %v", err)
+		assert.Equalf(t, tt.cols, tbl.Columns(), "table %s", tt.table)
+		conn, err := src.Open(tt.table)
+		assert.Tf(t, err == nil, "no error %v", err)
+		assert.Equalf(t, tt.rows, scan(t, conn), "table %s", tt.table)
+		conn.Close()
+	}
+
+	// key equalities are read directly, without a scan
+	ctx := plan.NewContext(`SELECT name FROM users WHERE key IN ("user:2", "user:9", "other") AND name != "x"`)
+	ctx.Schema = datasource.RegisterSchemaSource("redis", "redis", src)
+	srv.mu.Lock()
+	srv.commands = nil
+	srv.mu.Unlock()
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	rows := exec.NewResultRows(ctx, []string{"name"})
+	job.RootTask.Add(rows)
+	assert.T(t, job.Setup() == nil)
+	go job.Run()
+	names := make([]string, 0)
+	dest := make([]driver.Value, 1)
+	for rows.Next(dest) == nil {
+		names = append(names, dest[0].(string))
+	}
+	job.Close()
+	assert.Equal(t, []string{"bob"}, names)
+	srv.mu.Lock()
+	// the client of the scans before is reused, already authenticated
+	assert.Equal(t, []string{"HGETALL user:2", "HGETALL user:9"}, srv.commands)
+	srv.mu.Unlock()
+	assert.Equal(t, nil, src.CheckHealth())
+	stats := datasource.DataSourcesRegistry().PoolStats()["redis"]
+	assert.Equal(t, 1, stats.Open)
+	assert.Equal(t, 1, stats.Idle)
+	assert.Equal(t, 0, stats.InUse)
+
+	_, err = redis.NewRedisSource(u.JsonHelper{"tables": map[string]interface{}{
+		"x": map[string]interface{}{"pattern": "x:*", "type": "list"}}})
+	assert.T(t, err != nil)
+}
@@ -0,0 +1,130 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// redisError an error reply of the server, ie WRONGTYPE
+type redisError string
+
+func (m redisError) Error() string { return string(m) }
+
+// client a connection speaking the redis protocol (RESP), replies are
+// string (simple and bulk strings), int64, []interface{}, nil or a
+// redisError
+type client struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	w       *bufio.Writer
+	timeout time.Duration
+}
+
+// dial a client of the server at address, authenticated and on db
+func dial(address, password string, db int, timeout time.Duration) (*client, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	m := &client{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn), timeout: timeout}
+	if password != "" {
+		if _, err := m.do("AUTH", password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if db != 0 {
+		if _, err := m.do("SELECT", strconv.Itoa(db)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// do send the command of args, returning its reply
+func (m *client) do(args ...string) (interface{}, error) {
+	if m.timeout > 0 {
+		m.conn.SetDeadline(time.Now().Add(m.timeout))
+	}
+	fmt.Fprintf(m.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(m.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := m.w.Flush(); err != nil {
+		return nil, err
+	}
+	reply, err := m.read()
+	if err != nil {
+		return nil, err
+	}
+	if rerr, ok := reply.(redisError); ok {
+		return nil, rerr
+	}
+	return reply, nil
+}
+
+// read a reply
+func (m *client) read() (interface{}, error) {
+	line, err := m.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("invalid redis reply %q", line)
+	}
+	kind, line := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return redisError(line), nil
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(m.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := m.read()
+			if err != nil {
+				return nil, err
+			}
+			if rerr, ok := item.(redisError); ok {
+				return nil, rerr
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("invalid redis reply %q", kind)
+}
+
+func (m *client) Close() error { return m.conn.Close() }
+
+// strings the string items of an array reply
+func strs(reply interface{}) []string {
+	items, _ := reply.([]interface{})
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		s, _ := item.(string)
+		out = append(out, s)
+	}
+	return out
+}
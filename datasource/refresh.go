@@ -0,0 +1,69 @@
+package datasource
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/araddon/qlbridge/schema"
+)
+
+// schemaRefreshMu guards the background refresh of the tables of the
+// registry
+var schemaRefreshMu sync.Mutex
+
+// RefreshSchemas reload the tables of the schemas of the registry that are
+// stale by their refresh intervals (see schema.Schema.RefreshStale).
+// Returns the tables refreshed as "schema.table".
+func (m *Registry) RefreshSchemas() []string {
+	registryMu.RLock()
+	schemas := make([]*schema.Schema, 0, len(m.schemas))
+	for _, s := range m.schemas {
+		schemas = append(schemas, s)
+	}
+	registryMu.RUnlock()
+
+	refreshed := make([]string, 0)
+	for _, s := range schemas {
+		for _, name := range s.RefreshStale() {
+			refreshed = append(refreshed, s.Name+"."+name)
+		}
+	}
+	sort.Strings(refreshed)
+	return refreshed
+}
+
+// StartSchemaRefresh check for stale tables every interval in the
+// background, refreshing each of its source as often as its refresh
+// interval, until StopSchemaRefresh
+func (m *Registry) StartSchemaRefresh(interval time.Duration) {
+	schemaRefreshMu.Lock()
+	defer schemaRefreshMu.Unlock()
+	if m.stopRefresh != nil {
+		close(m.stopRefresh)
+	}
+	stop := make(chan struct{})
+	m.stopRefresh = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.RefreshSchemas()
+			}
+		}
+	}()
+}
+
+// StopSchemaRefresh stop the background refresh of tables
+func (m *Registry) StopSchemaRefresh() {
+	schemaRefreshMu.Lock()
+	defer schemaRefreshMu.Unlock()
+	if m.stopRefresh != nil {
+		close(m.stopRefresh)
+		m.stopRefresh = nil
+	}
+}
@@ -0,0 +1,248 @@
+package datasource
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	// SourceDrainWait how long sources removed (or replaced) by a config
+	// applied to the registry are kept open for the queries already running
+	// against them, before they are closed.
+	SourceDrainWait = 10 * time.Second
+
+	// applyMu serializes Registry.Apply
+	applyMu sync.Mutex
+)
+
+// RegistryConfig the sources and schemas of the registry, as applied at
+// runtime by Registry.Apply.
+//
+//   {
+//     "sources": [{"name": "orders_db", "type": "mysql", "settings": {...}}],
+//     "schemas": [{"name": "orders", "sources": ["orders_db"]}]
+//   }
+type RegistryConfig struct {
+	Sources []*schema.ConfigSource `json:"sources"`
+	Schemas []*schema.ConfigSchema `json:"schemas"`
+}
+
+// RegistryChanges the report of the changes of applying a RegistryConfig,
+// the names of the sources and schemas added, removed, and changed.
+type RegistryChanges struct {
+	SourcesAdded   []string `json:"sources_added"`
+	SourcesRemoved []string `json:"sources_removed"`
+	SourcesChanged []string `json:"sources_changed"`
+	SchemasAdded   []string `json:"schemas_added"`
+	SchemasRemoved []string `json:"schemas_removed"`
+	SchemasChanged []string `json:"schemas_changed"`
+}
+
+// Empty if the config applied changed nothing
+func (m *RegistryChanges) Empty() bool {
+	return len(m.SourcesAdded)+len(m.SourcesRemoved)+len(m.SourcesChanged)+
+		len(m.SchemasAdded)+len(m.SchemasRemoved)+len(m.SchemasChanged) == 0
+}
+
+func (m *RegistryChanges) String() string {
+	return fmt.Sprintf("sources added=%v removed=%v changed=%v schemas added=%v removed=%v changed=%v",
+		m.SourcesAdded, m.SourcesRemoved, m.SourcesChanged, m.SchemasAdded, m.SchemasRemoved, m.SchemasChanged)
+}
+
+// configuredSource a source of an applied config
+type configuredSource struct {
+	conf *schema.ConfigSource
+	ds   schema.Source
+	own  bool // created for this config by a schema.SourceFactory, so closed on its removal
+	reg  bool // registered by its name by the config, so unregistered on its removal
+}
+
+// Apply update the sources and schemas of the registry at runtime to those
+// of conf, instead of restarting the process to change them, returning a
+// report of the changes.  Relative to the previous config applied:
+//  - new sources are created (by their type in the registry, see
+//    schema.SourceFactory) and registered by name
+//  - removed, and changed, sources are unregistered, and closed after
+//    SourceDrainWait
+//  - schemas of new or changed sources (or list of sources) are loaded,
+//    and then replace the schemas of the registry at once.  Queries already
+//    planned against the old schema finish against it.
+// The config is validated, and all new schemas loaded, before changing any,
+// an error leaves the registry as it was.
+func (m *Registry) Apply(conf *RegistryConfig) (*RegistryChanges, error) {
+	applyMu.Lock()
+	defer applyMu.Unlock()
+
+	registryMu.RLock()
+	prev := m.configured
+	registryMu.RUnlock()
+
+	changes := &RegistryChanges{}
+	sources := make(map[string]*configuredSource, len(conf.Sources))
+	created := make([]*configuredSource, 0)
+	abort := func(err error) (*RegistryChanges, error) {
+		for _, cs := range created {
+			cs.ds.Close()
+		}
+		return nil, err
+	}
+	for _, sc := range conf.Sources {
+		name := strings.ToLower(sc.Name)
+		if name == "" {
+			return abort(fmt.Errorf("datasource: source config of type %q has no name", sc.SourceType))
+		}
+		if _, dupe := sources[name]; dupe {
+			return abort(fmt.Errorf("datasource: source %q configured twice", name))
+		}
+		if old, ok := prev[name]; ok && reflect.DeepEqual(*old.conf, *sc) {
+			sources[name] = old
+			continue
+		}
+		registryMu.RLock()
+		registered, isRegistered := m.sources[name]
+		sourceType := m.Get(sc.SourceType)
+		registryMu.RUnlock()
+		old, wasConfigured := prev[name]
+		if isRegistered && !wasConfigured && registered != sourceType {
+			return abort(fmt.Errorf("datasource: source %q is already registered", name))
+		}
+		if sourceType == nil {
+			return abort(fmt.Errorf("datasource: unknown source type %q of source %q (forgotten import?)", sc.SourceType, name))
+		}
+		c := *sc
+		cs := &configuredSource{conf: &c, ds: sourceType, reg: !isRegistered || (wasConfigured && old.reg)}
+		if factory, ok := sourceType.(schema.SourceFactory); ok {
+			ds, err := factory.NewSource(cs.conf)
+			if err != nil {
+				return abort(fmt.Errorf("datasource: could not create source %q: %v", name, err))
+			}
+			cs.ds, cs.own = ds, true
+			created = append(created, cs)
+		}
+		sources[name] = cs
+		if wasConfigured {
+			changes.SourcesChanged = append(changes.SourcesChanged, name)
+		} else {
+			changes.SourcesAdded = append(changes.SourcesAdded, name)
+		}
+	}
+	for name := range prev {
+		if _, ok := sources[name]; !ok {
+			changes.SourcesRemoved = append(changes.SourcesRemoved, name)
+		}
+	}
+
+	registryMu.RLock()
+	prevSchemas := m.configuredSchemas
+	registryMu.RUnlock()
+	schemaConfs := make(map[string]*schema.ConfigSchema, len(conf.Schemas))
+	schemas := make(map[string]*schema.Schema)
+	for _, sc := range conf.Schemas {
+		name := strings.ToLower(sc.Name)
+		if _, dupe := schemaConfs[name]; dupe {
+			return abort(fmt.Errorf("datasource: schema %q configured twice", name))
+		}
+		schemaConfs[name] = sc
+		changed := false
+		for _, sourceName := range sc.Sources {
+			cs, ok := sources[strings.ToLower(sourceName)]
+			if !ok {
+				return abort(fmt.Errorf("datasource: schema %q has unknown source %q", name, sourceName))
+			}
+			if old, ok := prev[strings.ToLower(sourceName)]; !ok || old != cs {
+				changed = true
+			}
+		}
+		ic, err := schema.ParseIdentifierCase(sc.Case)
+		if err != nil {
+			return abort(fmt.Errorf("datasource: schema %q: %v", name, err))
+		}
+		old, existed := prevSchemas[name]
+		if existed && !changed && reflect.DeepEqual(old.Sources, sc.Sources) && old.Case == sc.Case &&
+			reflect.DeepEqual(old.Aliases, sc.Aliases) {
+			continue
+		}
+		s := schema.NewSchema(name)
+		s.SetIdentifierCase(ic)
+		registryMu.RLock()
+		s.SetStore(m.store)
+		registryMu.RUnlock()
+		for _, sourceName := range sc.Sources {
+			cs := sources[strings.ToLower(sourceName)]
+			ss := schema.NewSchemaSource(strings.ToLower(sourceName), cs.conf.SourceType)
+			ss.Conf = cs.conf
+			ss.Partitions = cs.conf.Partitions
+			ss.DS = cs.ds
+			ss.Alias = sc.Aliases[sourceName]
+			s.AddSourceSchema(ss)
+			if err := loadSchema(ss); err != nil {
+				return abort(fmt.Errorf("datasource: could not load source %q of schema %q: %v", sourceName, name, err))
+			}
+		}
+		schemas[name] = s
+		if existed {
+			changes.SchemasChanged = append(changes.SchemasChanged, name)
+		} else {
+			changes.SchemasAdded = append(changes.SchemasAdded, name)
+		}
+	}
+	for name := range prevSchemas {
+		if _, ok := schemaConfs[name]; !ok {
+			changes.SchemasRemoved = append(changes.SchemasRemoved, name)
+		}
+	}
+
+	// swap in the new sources and schemas at once
+	registryMu.Lock()
+	closing := make([]*configuredSource, 0)
+	for name, old := range prev {
+		if cs, ok := sources[name]; ok && cs == old {
+			continue
+		}
+		if old.reg {
+			delete(m.sources, name)
+		}
+		if old.own {
+			closing = append(closing, old)
+		}
+	}
+	for name, cs := range sources {
+		if cs.reg {
+			m.sources[name] = cs.ds
+		}
+	}
+	for _, name := range changes.SchemasRemoved {
+		delete(m.schemas, name)
+	}
+	for name, s := range schemas {
+		m.schemas[name] = s
+	}
+	m.configured, m.configuredSchemas = sources, schemaConfs
+	m.tables = nil
+	registryMu.Unlock()
+
+	for _, cs := range closing {
+		cs := cs
+		time.AfterFunc(SourceDrainWait, func() {
+			if err := cs.ds.Close(); err != nil {
+				u.Warnf("could not close source %q: %v", cs.conf.Name, err)
+			}
+		})
+	}
+	for _, names := range [][]string{changes.SourcesAdded, changes.SourcesRemoved, changes.SourcesChanged,
+		changes.SchemasAdded, changes.SchemasRemoved, changes.SchemasChanged} {
+		sort.Strings(names)
+	}
+	if !changes.Empty() {
+		u.Infof("applied datasource config: %s", changes)
+	}
+	return changes, nil
+}
@@ -0,0 +1,154 @@
+package datasource_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	u "github.com/araddon/gou"
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// reloadSource a source type creating a source per config, of the tables
+// of its "tables" setting
+type reloadSource struct {
+	tables []string
+	closed chan bool
+	asked  int // tables asked for
+}
+
+func (m *reloadSource) NewSource(conf *schema.ConfigSource) (schema.Source, error) {
+	return &reloadSource{tables: strings.Split(conf.Settings.String("tables"), ","), closed: make(chan bool, 1)}, nil
+}
+func (m *reloadSource) Tables() []string                   { return m.tables }
+func (m *reloadSource) Open(_ string) (schema.Conn, error) { return nil, schema.ErrNotImplemented }
+func (m *reloadSource) Close() error {
+	m.closed <- true
+	return nil
+}
+func (m *reloadSource) Table(table string) (*schema.Table, error) {
+	m.asked++
+	tbl := schema.NewTable(table)
+	tbl.SetColumns([]string{"id"})
+	return tbl, nil
+}
+
+func TestRegistryApply(t *testing.T) {
+	defer func(wait time.Duration) { datasource.SourceDrainWait = wait }(datasource.SourceDrainWait)
+	datasource.SourceDrainWait = 0
+	datasource.Register("reloadtest", &reloadSource{})
+	reg := datasource.DataSourcesRegistry()
+
+	source := func(name, tables string) *schema.ConfigSource {
+		return &schema.ConfigSource{Name: name, SourceType: "reloadtest", Settings: u.JsonHelper{"tables": tables}}
+	}
+	conf := &datasource.RegistryConfig{
+		Sources: []*schema.ConfigSource{source("reload_s1", "a"), source("reload_s2", "b")},
+		Schemas: []*schema.ConfigSchema{
+			{Name: "reload_one", Sources: []string{"reload_s1"}},
+			{Name: "reload_two", Sources: []string{"reload_s1", "reload_s2"}},
+		},
+	}
+	changes, err := reg.Apply(conf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"reload_s1", "reload_s2"}, changes.SourcesAdded)
+	assert.Equal(t, []string{"reload_one", "reload_two"}, changes.SchemasAdded)
+	one, _ := reg.Schema("reload_one")
+	two, _ := reg.Schema("reload_two")
+	assert.Equal(t, []string{"a", "b"}, two.Tables())
+	s2 := reg.Get("reload_s2").(*reloadSource)
+
+	changes, err = reg.Apply(conf)
+	assert.Tf(t, err == nil && changes.Empty(), "no changes %v %v", err, changes)
+
+	// a changed source is replaced, and the old one closed, in the schemas of it
+	conf.Sources[1] = source("reload_s2", "b,c")
+	changes, err = reg.Apply(conf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"reload_s2"}, changes.SourcesChanged)
+	assert.Equal(t, []string{"reload_two"}, changes.SchemasChanged)
+	assert.Equal(t, 0, len(changes.SchemasAdded)+len(changes.SourcesAdded))
+	<-s2.closed
+	sch, _ := reg.Schema("reload_one")
+	assert.T(t, sch == one)
+	sch, _ = reg.Schema("reload_two")
+	assert.T(t, sch != two)
+	assert.Equal(t, []string{"a", "b", "c"}, sch.Tables())
+
+	// an invalid config changes nothing
+	for _, bad := range []*datasource.RegistryConfig{
+		{Sources: []*schema.ConfigSource{{Name: "reload_s3", SourceType: "nope"}}},
+		{Schemas: []*schema.ConfigSchema{{Name: "reload_one", Sources: []string{"reload_s3"}}}},
+		{Sources: []*schema.ConfigSource{source("reload_s1", "a"), source("reload_s1", "b")}},
+		{Sources: []*schema.ConfigSource{{Name: "reloadtest", SourceType: "reloadtest"}, source("mockcsv", "")}},
+	} {
+		_, err = reg.Apply(bad)
+		assert.T(t, err != nil)
+	}
+	sch, _ = reg.Schema("reload_two")
+	assert.Equal(t, []string{"a", "b", "c"}, sch.Tables())
+
+	s1 := reg.Get("reload_s1").(*reloadSource)
+	changes, err = reg.Apply(&datasource.RegistryConfig{})
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"reload_s1", "reload_s2"}, changes.SourcesRemoved)
+	assert.Equal(t, []string{"reload_one", "reload_two"}, changes.SchemasRemoved)
+	<-s1.closed
+	assert.T(t, reg.Get("reload_s1") == nil)
+}
+
+func TestRegistrySchemaStore(t *testing.T) {
+	defer func(wait time.Duration) { datasource.SourceDrainWait = wait }(datasource.SourceDrainWait)
+	datasource.SourceDrainWait = 0
+	datasource.Register("storetest", &reloadSource{})
+	dir, err := ioutil.TempDir("", "qlbridge-store")
+	assert.T(t, err == nil)
+	defer os.RemoveAll(dir)
+	store, err := schema.NewFileStore(dir)
+	assert.Tf(t, err == nil, "no error %v", err)
+	reg := datasource.DataSourcesRegistry()
+	reg.SetSchemaStore(store)
+	defer reg.SetSchemaStore(nil)
+
+	source := func(name, tables, gen string) *schema.ConfigSource {
+		return &schema.ConfigSource{Name: name, SourceType: "storetest",
+			Settings: u.JsonHelper{"tables": tables, "gen": gen}}
+	}
+	conf := &datasource.RegistryConfig{
+		Sources: []*schema.ConfigSource{source("store_s1", "sa", "1"), source("store_s2", "sb", "1")},
+		Schemas: []*schema.ConfigSchema{{Name: "store_two", Sources: []string{"store_s1", "store_s2"}}},
+	}
+	_, err = reg.Apply(conf)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	// each source is saved, not only the last loaded
+	def, err := store.Load("store_two")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, 2, len(def.Sources))
+	for _, name := range []string{"store_s1", "store_s2"} {
+		sd := def.Source(name)
+		assert.Tf(t, sd != nil && len(sd.Tables) == 1, "saved source %s %+v", name, sd)
+	}
+
+	// restarted (new sources of the same names), tables are of the store
+	conf.Sources = []*schema.ConfigSource{source("store_s1", "sa", "2"), source("store_s2", "sb", "2")}
+	_, err = reg.Apply(conf)
+	assert.Tf(t, err == nil, "no error %v", err)
+	sch, _ := reg.Schema("store_two")
+	assert.Equal(t, []string{"sa", "sb"}, sch.Tables())
+	for _, tableName := range []string{"sa", "sb"} {
+		_, err := sch.Table(tableName)
+		assert.Tf(t, err == nil, "no error %v", err)
+	}
+	for _, name := range []string{"store_s1", "store_s2"} {
+		assert.Equalf(t, 0, reg.Get(name).(*reloadSource).asked, "tables asked of %s", name)
+	}
+
+	_, err = reg.Apply(&datasource.RegistryConfig{})
+	assert.Tf(t, err == nil, "no error %v", err)
+}
@@ -0,0 +1,577 @@
+// Rest package implements a Datasource of HTTP REST api endpoints, each
+// table the json rows of an endpoint.
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/expr/translate"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Different Features of this Rest Data Source
+	_ schema.Source            = (*RestSource)(nil)
+	_ datasource.MeteredSource = (*RestSource)(nil)
+	_ schema.SourceSetup       = (*RestSource)(nil)
+	_ schema.SourceTableSchema = (*RestSource)(nil)
+	_ schema.ConnScanner       = (*restConn)(nil)
+	_ schema.ConnColumns       = (*restConn)(nil)
+	_ schema.IteratorErr       = (*restConn)(nil)
+	_ translate.Translator     = (*restConn)(nil)
+)
+
+// RestSource a DataSource of HTTP json api endpoints, a table per endpoint.
+// The rows of a table are the objects found at the json path of each
+// response, paged through by page number, offset or cursor, and read as
+// json-lines (nested objects are dotted columns, the schema inferred from
+// the first rows), flattened by the json options of the table (see
+// datasource.JsonOptionsFromSettings).
+//
+//   "settings" : {
+//       "headers"    : {"Authorization" : "Bearer ..."},
+//       "rate_limit" : 5,                      // requests per second, all tables
+//       "timeout"    : 30,                     // seconds per request
+//       "tables" : {
+//           "users" : {
+//               "url"    : "https://api.example.com/users",
+//               "params" : {"status" : "{status}", "key" : "abc"},
+//               "path"   : "data.items",       // json path of the rows
+//               "explode" : ["roles"],         // a row per role of a user
+//               "paging" : {"type" : "page", "param" : "page", "start" : 1,
+//                           "size_param" : "per_page", "size" : 100}
+//           }
+//       }
+//   }
+//
+// A param templated on a column, {status} above, is sent with the value of
+// an equality on the column pushed down by a query, ie
+//   SELECT * FROM users WHERE status = "active"   =>  ?status=active
+// and left out otherwise.  The where is still evaluated on the rows.
+//
+// Paging types:
+//   page    param = start, start+1 ... until a page has fewer than size rows
+//   offset  param = 0, n ... the number of rows read so far, same end
+//   cursor  param = the value at cursor_path of the last response, until empty
+type RestSource struct {
+	mu      sync.Mutex
+	client  *http.Client
+	headers map[string]string
+	limiter *rateLimiter
+	names   []string
+	tables  map[string]*endpoint
+	schemas map[string]*schema.Table
+	metrics datasource.Metrics
+}
+
+// endpoint the config of a table
+type endpoint struct {
+	name       string
+	url        string
+	params     map[string]string
+	path       string
+	paging     string
+	param      string
+	start      int
+	sizeParam  string
+	size       int
+	cursorPath string
+	maxPages   int
+	json       datasource.JsonOptions // flattening of the rows
+}
+
+// restConn the scan of the rows of an endpoint, fetched by a goroutine that
+// writes them as json-lines read by a datasource.JsonLinesDataSource
+type restConn struct {
+	src     *RestSource
+	ep      *endpoint
+	pushed  map[string]string // values of equalities pushed down, by column
+	rows    *datasource.JsonLinesDataSource
+	pr      *io.PipeReader
+	done    chan struct{}
+	started bool
+	err     error
+	once    sync.Once
+}
+
+// NewRestSource a RestSource of the settings of a source config
+func NewRestSource(settings u.JsonHelper) (*RestSource, error) {
+	m := &RestSource{}
+	if err := m.load(settings); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Setup read the endpoints of the "tables" settings (and the shared "headers"
+// and "timeout") of the source config, of a RestSource{} registered without
+// settings
+func (m *RestSource) Setup(ss *schema.SchemaSource) error {
+	m.mu.Lock()
+	loaded := m.tables != nil
+	m.mu.Unlock()
+	return datasource.SetupSettings(ss, loaded, m.load)
+}
+
+func (m *RestSource) load(settings u.JsonHelper) error {
+	tables := settings.Helper("tables")
+	if len(tables) == 0 {
+		return fmt.Errorf("rest source requires tables in settings")
+	}
+	eps := make(map[string]*endpoint, len(tables))
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		conf := tables.Helper(name)
+		ep := &endpoint{
+			name:   strings.ToLower(name),
+			url:    conf.String("url"),
+			params: make(map[string]string),
+			path:   conf.String("path"),
+		}
+		if ep.url == "" {
+			return fmt.Errorf("rest table %q requires a url", name)
+		}
+		opts, err := datasource.JsonOptionsFromSettings(conf)
+		if err != nil {
+			return fmt.Errorf("rest table %q: %v", name, err)
+		}
+		ep.json = opts
+		params := conf.Helper("params")
+		for k := range params {
+			ep.params[k] = params.String(k)
+		}
+		if paging := conf.Helper("paging"); paging != nil {
+			ep.paging = strings.ToLower(paging.String("type"))
+			ep.param = paging.String("param")
+			ep.start = 1
+			if n, ok := paging.IntSafe("start"); ok {
+				ep.start = n
+			}
+			ep.sizeParam = paging.String("size_param")
+			ep.size = paging.Int("size")
+			ep.cursorPath = paging.String("cursor_path")
+			ep.maxPages = paging.Int("max_pages")
+			switch ep.paging {
+			case "page", "offset", "cursor":
+				if ep.param == "" {
+					return fmt.Errorf("rest table %q paging requires a param", name)
+				}
+			default:
+				return fmt.Errorf("rest table %q has unknown paging type %q", name, ep.paging)
+			}
+			if ep.paging == "cursor" && ep.cursorPath == "" {
+				return fmt.Errorf("rest table %q cursor paging requires a cursor_path", name)
+			}
+		}
+		eps[ep.name] = ep
+		names = append(names, ep.name)
+	}
+	sort.Strings(names)
+
+	headers := make(map[string]string)
+	hh := settings.Helper("headers")
+	for k := range hh {
+		headers[k] = hh.String(k)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	if secs, ok := settings.IntSafe("timeout"); ok && secs > 0 {
+		client.Timeout = time.Duration(secs) * time.Second
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.client, m.headers = client, headers
+	m.limiter = newRateLimiter(settings.Float64("rate_limit"))
+	m.names, m.tables = names, eps
+	m.schemas = make(map[string]*schema.Table)
+	return nil
+}
+
+func (m *RestSource) Tables() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.names
+}
+
+// Table the schema of table, from the first rows of its endpoint
+func (m *RestSource) Table(table string) (*schema.Table, error) {
+	table = strings.ToLower(table)
+	m.mu.Lock()
+	tbl, ok := m.schemas[table]
+	m.mu.Unlock()
+	if ok {
+		return tbl, nil
+	}
+	conn, err := m.Open(table)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rc := conn.(*restConn)
+	if err := rc.start(); err != nil {
+		return nil, err
+	}
+	if tbl, err = rc.rows.Table(table); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.schemas[table] = tbl
+	m.mu.Unlock()
+	return tbl, nil
+}
+
+// Open a scan of the rows of the endpoint of table, fetched on the first
+// Next so the where pushed down is known
+func (m *RestSource) Open(table string) (schema.Conn, error) {
+	m.mu.Lock()
+	ep, ok := m.tables[strings.ToLower(table)]
+	m.mu.Unlock()
+	if !ok {
+		return nil, schema.ErrNotFound
+	}
+	return &restConn{src: m, ep: ep, done: make(chan struct{})}, nil
+}
+
+func (m *RestSource) Close() error { return nil }
+
+func (m *RestSource) Metrics() *datasource.Metrics { return &m.metrics }
+
+// Translate the equalities of columns to literals AND-ed in the where into
+// the values of templated params.  The rest of the where is not sent.
+func (m *restConn) Translate(node expr.Node) (interface{}, error) {
+	eq := make(map[string]string)
+	equalities(node, eq)
+	if len(eq) == 0 {
+		return nil, fmt.Errorf("no equalities to send as params in %s", node)
+	}
+	return eq, nil
+}
+
+// equalities   col = literal [AND ...]
+func equalities(node expr.Node, eq map[string]string) {
+	bn, ok := node.(*expr.BinaryNode)
+	if !ok || len(bn.Args) != 2 {
+		return
+	}
+	switch bn.Operator.T {
+	case lex.TokenLogicAnd:
+		equalities(bn.Args[0], eq)
+		equalities(bn.Args[1], eq)
+	case lex.TokenEqual, lex.TokenEqualEqual:
+		col, lit := bn.Args[0], bn.Args[1]
+		if _, isIdent := col.(*expr.IdentityNode); !isIdent {
+			col, lit = lit, col
+		}
+		in, isIdent := col.(*expr.IdentityNode)
+		if !isIdent || in.IsBooleanIdentity() {
+			return
+		}
+		var val string
+		switch lt := lit.(type) {
+		case *expr.StringNode:
+			val = lt.Text
+		case *expr.NumberNode:
+			val = lt.Text
+		default:
+			return
+		}
+		// user.name is a nested column or name qualified by its table
+		eq[strings.ToLower(in.Text)] = val
+		if _, right, hasLeft := expr.LeftRight(in.Text); hasLeft {
+			if _, exists := eq[strings.ToLower(right)]; !exists {
+				eq[strings.ToLower(right)] = val
+			}
+		}
+	}
+}
+
+// SetNative the equalities pushed down by the planner (see Translate)
+func (m *restConn) SetNative(native interface{}) {
+	if eq, ok := native.(map[string]string); ok {
+		m.pushed = eq
+	}
+}
+
+// Columns of the table
+func (m *restConn) Columns() []string {
+	tbl, err := m.src.Table(m.ep.name)
+	if err != nil {
+		u.Warnf("could not read columns of %q: %v", m.ep.name, err)
+		return nil
+	}
+	return tbl.Columns()
+}
+
+func (m *restConn) Next() schema.Message {
+	if !m.started {
+		if m.err = m.start(); m.err != nil {
+			return nil
+		}
+	}
+	if m.rows == nil {
+		return nil
+	}
+	return m.rows.Next()
+}
+
+// Err the error fetching the rows, nil if all were read
+func (m *restConn) Err() error {
+	if m.err == nil && m.rows != nil {
+		return m.rows.Err()
+	}
+	return m.err
+}
+
+func (m *restConn) Close() error {
+	m.once.Do(func() {
+		close(m.done)
+		if m.pr != nil {
+			m.pr.Close()
+		}
+	})
+	return nil
+}
+
+// start fetching the pages of rows
+func (m *restConn) start() error {
+	m.started = true
+	pr, pw := io.Pipe()
+	m.pr = pr
+	go m.fetch(pw)
+	rows, err := datasource.NewJsonLinesSourceOptions(m.ep.name, pr, make(<-chan bool, 1), m.ep.json)
+	if err != nil {
+		return err
+	}
+	m.rows = rows
+	return nil
+}
+
+// fetch the pages of the endpoint, writing each row as a line of json to
+// w until the last page or the conn is closed
+func (m *restConn) fetch(w *io.PipeWriter) {
+	ep := m.ep
+	offset, page := 0, ep.start
+	cursor := ""
+	for pages := 0; ep.maxPages <= 0 || pages < ep.maxPages; pages++ {
+		q := m.query()
+		switch ep.paging {
+		case "page":
+			q.Set(ep.param, strconv.Itoa(page))
+		case "offset":
+			q.Set(ep.param, strconv.Itoa(offset))
+		case "cursor":
+			if pages > 0 {
+				q.Set(ep.param, cursor)
+			}
+		}
+		if ep.sizeParam != "" && ep.size > 0 {
+			q.Set(ep.sizeParam, strconv.Itoa(ep.size))
+		}
+		doc, err := m.get(q)
+		if err != nil {
+			w.CloseWithError(err)
+			return
+		}
+		rows := rowsAt(doc, ep.path)
+		for _, row := range rows {
+			line, err := json.Marshal(row)
+			if err != nil {
+				w.CloseWithError(err)
+				return
+			}
+			if _, err := w.Write(append(line, '\n')); err != nil {
+				// closed
+				return
+			}
+		}
+		if !m.more(rows, doc, &page, &offset, &cursor) {
+			break
+		}
+	}
+	w.Close()
+}
+
+// more whether there is another page after the rows of doc, advancing the
+// page, offset or cursor to it
+func (m *restConn) more(rows []interface{}, doc interface{}, page, offset *int, cursor *string) bool {
+	switch m.ep.paging {
+	case "page", "offset":
+		if len(rows) == 0 || (m.ep.size > 0 && len(rows) < m.ep.size) {
+			return false
+		}
+		*page++
+		*offset += len(rows)
+		return true
+	case "cursor":
+		*cursor = valueString(valueAt(doc, m.ep.cursorPath))
+		return *cursor != "" && len(rows) > 0
+	}
+	return false
+}
+
+// query the params of the endpoint, templated params with the values pushed
+// down
+func (m *restConn) query() url.Values {
+	q := url.Values{}
+	for k, tmpl := range m.ep.params {
+		val, ok := expand(tmpl, m.pushed)
+		if ok {
+			q.Set(k, val)
+		}
+	}
+	return q
+}
+
+// expand the {column} templates of tmpl, false if a column has no value
+func expand(tmpl string, vals map[string]string) (string, bool) {
+	out := ""
+	for {
+		i := strings.Index(tmpl, "{")
+		if i < 0 {
+			return out + tmpl, true
+		}
+		j := strings.Index(tmpl[i:], "}")
+		if j < 0 {
+			return out + tmpl, true
+		}
+		val, ok := vals[strings.ToLower(tmpl[i+1:i+j])]
+		if !ok {
+			return "", false
+		}
+		out += tmpl[:i] + val
+		tmpl = tmpl[i+j+1:]
+	}
+}
+
+// get the json document of the endpoint with params q
+func (m *restConn) get(q url.Values) (interface{}, error) {
+	if !m.src.limiter.wait(m.done) {
+		return nil, fmt.Errorf("closed")
+	}
+	target := m.ep.url
+	if len(q) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + q.Encode()
+	}
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range m.src.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := m.src.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", m.ep.url, resp.Status)
+	}
+	cr := &datasource.CountingReader{Reader: resp.Body}
+	defer func() { m.src.metrics.Read(m.ep.name, 0, cr.N) }()
+	var doc interface{}
+	dec := json.NewDecoder(cr)
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("could not read json of %s: %v", m.ep.url, err)
+	}
+	return doc, nil
+}
+
+// valueAt the value at the dotted path of doc, nil if none
+func valueAt(doc interface{}, path string) interface{} {
+	if path == "" {
+		return doc
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		doc = obj[key]
+	}
+	return doc
+}
+
+// rowsAt the objects at path of doc, an array of them or a single one
+func rowsAt(doc interface{}, path string) []interface{} {
+	switch val := valueAt(doc, path).(type) {
+	case []interface{}:
+		return val
+	case map[string]interface{}:
+		return []interface{}{val}
+	}
+	return nil
+}
+
+func valueString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	}
+	return ""
+}
+
+// rateLimiter spaces requests at least interval apart
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newRateLimiter a limiter of perSecond requests, none if zero
+func newRateLimiter(perSecond float64) *rateLimiter {
+	m := &rateLimiter{}
+	if perSecond > 0 {
+		m.interval = time.Duration(float64(time.Second) / perSecond)
+	}
+	return m
+}
+
+// wait for the next request, false if stop is closed first
+func (m *rateLimiter) wait(stop <-chan struct{}) bool {
+	if m.interval <= 0 {
+		return true
+	}
+	m.mu.Lock()
+	now := time.Now()
+	at := m.next
+	if at.Before(now) {
+		at = now
+	}
+	m.next = at.Add(m.interval)
+	m.mu.Unlock()
+	if d := at.Sub(now); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-stop:
+			return false
+		}
+	}
+	return true
+}
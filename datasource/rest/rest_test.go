@@ -0,0 +1,176 @@
+package rest_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	u "github.com/araddon/gou"
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/rest"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// api serves 5 users by page, offset and cursor, recording the queries
+type api struct {
+	mu      sync.Mutex
+	queries []string
+	times   []time.Time
+}
+
+var apiUsers = []string{"aaron", "bob", "carol", "dan", "eve"}
+
+func (m *api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	m.queries = append(m.queries, r.URL.Path+"?"+r.URL.RawQuery)
+	m.times = append(m.times, time.Now())
+	m.mu.Unlock()
+	q := r.URL.Query()
+	if r.Header.Get("X-Key") != "secret" {
+		http.Error(w, "denied", http.StatusUnauthorized)
+		return
+	}
+	size, _ := strconv.Atoi(q.Get("per_page"))
+	if size == 0 {
+		size = len(apiUsers)
+	}
+	start := 0
+	switch r.URL.Path {
+	case "/page":
+		page, _ := strconv.Atoi(q.Get("page"))
+		start = (page - 1) * size
+	case "/offset":
+		start, _ = strconv.Atoi(q.Get("offset"))
+	case "/cursor":
+		start, _ = strconv.Atoi(q.Get("after"))
+	}
+	rows := ""
+	for i := start; i < start+size && i < len(apiUsers); i++ {
+		if q.Get("status") != "" && q.Get("status") != status(i) {
+			continue
+		}
+		if rows != "" {
+			rows += ","
+		}
+		rows += fmt.Sprintf(`{"id":%d,"name":%q,"profile":{"status":%q}}`, i+1, apiUsers[i], status(i))
+	}
+	next := ""
+	if start+size < len(apiUsers) {
+		next = strconv.Itoa(start + size)
+	}
+	fmt.Fprintf(w, `{"data":{"users":[%s]},"next":%q}`, rows, next)
+}
+
+func status(i int) string {
+	if i%2 == 0 {
+		return "active"
+	}
+	return "idle"
+}
+
+func scan(t *testing.T, conn schema.Conn) []string {
+	rows := make([]string, 0)
+	scanner := conn.(schema.ConnScanner)
+	for msg := scanner.Next(); msg != nil; msg = scanner.Next() {
+		rows = append(rows, fmt.Sprintf("%v", msg.(*datasource.SqlDriverMessageMap).Values()))
+	}
+	assert.Tf(t, scanner.(schema.IteratorErr).Err() == nil, "no error %v", scanner.(schema.IteratorErr).Err())
+	return rows
+}
+
+func table(url string, paging map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"url": url, "path": "data.users", "paging": paging,
+		"params": map[string]interface{}{"status": "{profile.status}"}}
+}
+
+func TestRestSource(t *testing.T) {
+	srv := &api{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	src, err := rest.NewRestSource(u.JsonHelper{
+		"headers": map[string]interface{}{"X-Key": "secret"},
+		"tables": map[string]interface{}{
+			"paged":    table(ts.URL+"/page", map[string]interface{}{"type": "page", "param": "page", "size_param": "per_page", "size": 2}),
+			"offsets":  table(ts.URL+"/offset", map[string]interface{}{"type": "offset", "param": "offset", "size_param": "per_page", "size": 2}),
+			"cursored": table(ts.URL+"/cursor", map[string]interface{}{"type": "cursor", "param": "after", "cursor_path": "next", "size_param": "per_page", "size": 3}),
+			"single":   table(ts.URL+"/all", nil),
+		},
+	})
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"cursored", "offsets", "paged", "single"}, src.Tables())
+
+	tbl, err := src.Table("paged")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"id", "name", "profile.status"}, tbl.Columns())
+
+	all := []string{"[1 aaron active]", "[2 bob idle]", "[3 carol active]", "[4 dan idle]", "[5 eve active]"}
+	for _, name := range src.Tables() {
+		conn, err := src.Open(name)
+		assert.Tf(t, err == nil, "no error %v", err)
+		assert.Equalf(t, all, scan(t, conn), "table %s", name)
+		conn.Close()
+	}
+
+	// equalities of the where are sent as the templated params
+	ctx := plan.NewContext(`SELECT name FROM single WHERE profile.status = "idle" AND id > 0`)
+	ctx.Schema = datasource.RegisterSchemaSource("rest", "rest", src)
+	srv.queries = nil
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	rows := exec.NewResultRows(ctx, []string{"name"})
+	job.RootTask.Add(rows)
+	assert.T(t, job.Setup() == nil)
+	go job.Run()
+	names := make([]string, 0)
+	dest := make([]driver.Value, 1)
+	for rows.Next(dest) == nil {
+		names = append(names, dest[0].(string))
+	}
+	job.Close()
+	assert.Equal(t, []string{"bob", "dan"}, names)
+	assert.Equal(t, []string{"/all?status=idle"}, srv.queries)
+
+	// errors of the api are errors of the scan
+	bad, _ := rest.NewRestSource(u.JsonHelper{"tables": map[string]interface{}{
+		"single": map[string]interface{}{"url": ts.URL + "/all"}}})
+	conn, _ := bad.Open("single")
+	assert.T(t, conn.(schema.ConnScanner).Next() == nil)
+	assert.T(t, conn.(schema.IteratorErr).Err() != nil)
+
+	_, err = rest.NewRestSource(u.JsonHelper{"tables": map[string]interface{}{
+		"x": map[string]interface{}{"url": ts.URL, "paging": map[string]interface{}{"type": "links"}}}})
+	assert.T(t, err != nil)
+}
+
+func TestRestRateLimit(t *testing.T) {
+	srv := &api{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	src, err := rest.NewRestSource(u.JsonHelper{
+		"headers":    map[string]interface{}{"X-Key": "secret"},
+		"rate_limit": 20,
+		"tables": map[string]interface{}{
+			"paged": table(ts.URL+"/page", map[string]interface{}{"type": "page", "param": "page", "size_param": "per_page", "size": 1}),
+		},
+	})
+	assert.Tf(t, err == nil, "no error %v", err)
+	conn, _ := src.Open("paged")
+	assert.Equal(t, 5, len(scan(t, conn)))
+	// a page per user, and an empty one
+	assert.Equal(t, 6, len(srv.times))
+	for i := 1; i < len(srv.times); i++ {
+		gap := srv.times[i].Sub(srv.times[i-1])
+		assert.Tf(t, gap >= 40*time.Millisecond, "requests should be spaced by the rate limit got %v", gap)
+	}
+}
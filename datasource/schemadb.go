@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"database/sql/driver"
 	"fmt"
+	"strings"
 
 	u "github.com/araddon/gou"
 
+	"github.com/araddon/qlbridge/expr"
 	"github.com/araddon/qlbridge/plan"
 	"github.com/araddon/qlbridge/schema"
 	"github.com/araddon/qlbridge/value"
@@ -26,13 +28,14 @@ var (
 
 	// Different Features of this Static Schema Data Source
 	_ schema.Source      = (*SchemaDb)(nil)
+	_ MeteredSource      = (*SchemaDb)(nil)
 	_ schema.Conn        = (*SchemaSource)(nil)
 	_ schema.ConnColumns = (*SchemaSource)(nil)
 	_ schema.ConnScanner = (*SchemaSource)(nil)
 
 	// normal tables
 	defaultSchemaTables = []string{"tables", "databases", "columns", "global_variables", "session_variables",
-		"functions", "procedures", "engines", "status", "indexes"}
+		"functions", "procedures", "engines", "session_status", "global_status", "processlist", "grants", "indexes", "explain", "_health", "funcs"}
 	DialectWriterCols = []string{"mysql"}
 	DialectWriters    = []schema.DialectWriter{&mysqlWriter{}}
 )
@@ -46,14 +49,16 @@ type (
 		is       *schema.Schema
 		tbls     []string
 		tableMap map[string]*schema.Table
+		versions map[string]uint64 // of the tables described, by name
+		metrics  Metrics
 	}
 	SchemaSource struct {
-		db      *SchemaDb
-		tbl     *schema.Table
-		ctx     *plan.Context
-		session bool
-		cursor  int
-		rows    [][]driver.Value
+		db     *SchemaDb
+		tbl    *schema.Table
+		ctx    *plan.Context
+		rowsFn func(ctx *plan.Context) [][]driver.Value // rows built from the request context
+		cursor int
+		rows   [][]driver.Value
 	}
 )
 
@@ -62,11 +67,13 @@ func NewSchemaDb(s *schema.Schema) *SchemaDb {
 		s:        s,
 		tbls:     defaultSchemaTables,
 		tableMap: make(map[string]*schema.Table),
+		versions: make(map[string]uint64),
 	}
 	return &m
 }
-func (m *SchemaDb) Close() error     { return nil }
-func (m *SchemaDb) Tables() []string { return m.tbls }
+func (m *SchemaDb) Close() error      { return nil }
+func (m *SchemaDb) Metrics() *Metrics { return &m.metrics }
+func (m *SchemaDb) Tables() []string  { return m.tbls }
 func (m *SchemaDb) Table(table string) (*schema.Table, error) {
 
 	//u.Debugf("Table(%q)", table)
@@ -75,14 +82,26 @@ func (m *SchemaDb) Table(table string) (*schema.Table, error) {
 		return m.tableForTables()
 	case "databases":
 		return m.tableForDatabases()
-	case "session_variables", "global_variables":
+	case "session_variables", "global_variables", "session_status", "global_status":
 		return m.tableForVariables(table)
+	case "processlist":
+		return m.tableForProcessList()
+	case "grants":
+		return m.tableForGrants()
 	case "procedures", "functions":
 		return m.tableForProcedures(table)
 	case "engines":
 		return m.tableForEngines()
 	case "indexes", "keys":
 		return m.tableForIndexes()
+	case "explain":
+		return m.tableForExplain()
+	case "_health":
+		return m.tableForHealth()
+	case "funcs":
+		return m.tableForFuncs()
+	case "columns":
+		return m.tableForColumns()
 	default:
 		//u.Debugf("Table(%q)", table)
 		return m.tableForTable(table)
@@ -97,8 +116,24 @@ func (m *SchemaDb) Open(schemaObjectName string) (schema.Conn, error) {
 	if err == nil && tbl != nil {
 
 		switch schemaObjectName {
-		case "session_variables", "global_variables":
-			return &SchemaSource{db: m, tbl: tbl, session: true}, nil
+		case "session_variables":
+			return &SchemaSource{db: m, tbl: tbl, rowsFn: RowsForSession}, nil
+		case "global_variables":
+			return &SchemaSource{db: m, tbl: tbl, rowsFn: RowsForGlobal}, nil
+		case "session_status", "global_status":
+			return &SchemaSource{db: m, tbl: tbl, rowsFn: RowsForStatus}, nil
+		case "processlist":
+			return &SchemaSource{db: m, tbl: tbl, rowsFn: RowsForProcessList}, nil
+		case "grants":
+			return &SchemaSource{db: m, tbl: tbl, rowsFn: RowsForGrants}, nil
+		case "explain":
+			return &SchemaSource{db: m, tbl: tbl, rowsFn: RowsForExplain}, nil
+		case "_health":
+			return &SchemaSource{db: m, tbl: tbl, rowsFn: RowsForHealth}, nil
+		case "funcs":
+			return &SchemaSource{db: m, tbl: tbl, rowsFn: RowsForFuncs}, nil
+		case "columns":
+			return &SchemaSource{db: m, tbl: tbl, rowsFn: m.rowsForColumns}, nil
 		case "engines", "procedures", "functions", "indexes":
 			return &SchemaSource{db: m, tbl: tbl, rows: nil}, nil
 		default:
@@ -111,8 +146,8 @@ func (m *SchemaDb) Open(schemaObjectName string) (schema.Conn, error) {
 
 func (m *SchemaSource) SetContext(ctx *plan.Context) {
 	m.ctx = ctx
-	if m.session {
-		m.rows = RowsForSession(ctx)
+	if m.rowsFn != nil {
+		m.rows = m.rowsFn(ctx)
 	}
 }
 
@@ -157,12 +192,6 @@ func (m *SchemaDb) tableForTable(table string) (*schema.Table, error) {
 		return nil, err
 	}
 
-	tbl, hasTable := m.tableMap[table]
-	//u.Debugf("s:%p infoschema:%p creating schema table for %q", m.s, m.is, table)
-	if hasTable {
-		//u.Infof("found existing table %q", table)
-		return tbl, nil
-	}
 	srcTbl, err := m.s.Table(table)
 	if err != nil {
 		u.Errorf("no table? err=%v for=%s", err, table)
@@ -171,6 +200,12 @@ func (m *SchemaDb) tableForTable(table string) (*schema.Table, error) {
 	if srcTbl == nil {
 		return nil, schema.ErrNotFound
 	}
+	tbl, hasTable := m.tableMap[table]
+	//u.Debugf("s:%p infoschema:%p creating schema table for %q", m.s, m.is, table)
+	if hasTable && m.versions[table] == srcTbl.Version() {
+		//u.Infof("found existing table %q", table)
+		return tbl, nil
+	}
 	if len(srcTbl.Columns()) > 0 && len(srcTbl.Fields) == 0 {
 		// I really don't like where/how this gets called
 		//    needs to be in schema somewhere?
@@ -196,6 +231,7 @@ func (m *SchemaDb) tableForTable(table string) (*schema.Table, error) {
 
 	ss.AddTable(t)
 	m.tableMap[table] = t
+	m.versions[table] = srcTbl.Version()
 	return t, nil
 }
 
@@ -282,6 +318,130 @@ func (m *SchemaDb) tableForVariables(table string) (*schema.Table, error) {
 	return t, nil
 }
 
+func (m *SchemaDb) tableForProcessList() (*schema.Table, error) {
+
+	ss, err := m.is.SchemaSource("schema")
+	if err != nil {
+		return nil, err
+	}
+
+	t := schema.NewTable("processlist")
+	t.AddField(schema.NewFieldBase("Id", value.IntType, 64, "bigint"))
+	t.AddField(schema.NewFieldBase("User", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("Host", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("db", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("Command", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("Time", value.IntType, 64, "int"))
+	t.AddField(schema.NewFieldBase("State", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("Info", value.StringType, 255, "string"))
+	t.SetColumns(schema.ProcessListColumns)
+	ss.AddTable(t)
+	return t, nil
+}
+
+// tableForHealth the _health table of the health of the sources, see
+// Registry.CheckHealth
+func (m *SchemaDb) tableForHealth() (*schema.Table, error) {
+
+	ss, err := m.is.SchemaSource("schema")
+	if err != nil {
+		return nil, err
+	}
+
+	t := schema.NewTable("_health")
+	t.AddField(schema.NewFieldBase("source", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("healthy", value.BoolType, 1, "bool"))
+	t.AddField(schema.NewFieldBase("latency_ms", value.IntType, 64, "bigint"))
+	t.AddField(schema.NewFieldBase("last_error", value.StringType, 255, "string"))
+	t.AddField(schema.NewFieldBase("last_check", value.TimeType, 32, "datetime"))
+	t.AddField(schema.NewFieldBase("failures", value.IntType, 64, "int"))
+	t.SetColumns(schema.HealthColumns)
+	ss.AddTable(t)
+	return t, nil
+}
+
+// tableForFuncs the funcs table of the registered functions for
+// SHOW FUNCTIONS
+func (m *SchemaDb) tableForFuncs() (*schema.Table, error) {
+
+	ss, err := m.is.SchemaSource("schema")
+	if err != nil {
+		return nil, err
+	}
+
+	t := schema.NewTable("funcs")
+	t.AddField(schema.NewFieldBase("Name", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("Category", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("Signature", value.StringType, 255, "string"))
+	t.AddField(schema.NewFieldBase("Type", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("Returns", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("Description", value.StringType, 255, "string"))
+	t.SetColumns(schema.FuncsColumns)
+	ss.AddTable(t)
+	return t, nil
+}
+
+// tableForColumns the information_schema columns table, of the columns of
+// the tables of the schema and their defaults, nullability and lengths
+func (m *SchemaDb) tableForColumns() (*schema.Table, error) {
+
+	ss, err := m.is.SchemaSource("schema")
+	if err != nil {
+		return nil, err
+	}
+
+	t := schema.NewTable("columns")
+	t.AddField(schema.NewFieldBase("TABLE_SCHEMA", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("TABLE_NAME", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("COLUMN_NAME", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("ORDINAL_POSITION", value.IntType, 64, "bigint"))
+	t.AddField(schema.NewFieldBase("COLUMN_DEFAULT", value.StringType, 255, "string"))
+	t.AddField(schema.NewFieldBase("IS_NULLABLE", value.StringType, 3, "string"))
+	t.AddField(schema.NewFieldBase("DATA_TYPE", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("CHARACTER_MAXIMUM_LENGTH", value.IntType, 64, "bigint"))
+	t.AddField(schema.NewFieldBase("COLUMN_KEY", value.StringType, 3, "string"))
+	t.AddField(schema.NewFieldBase("EXTRA", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("GENERATION_EXPRESSION", value.StringType, 255, "string"))
+	t.SetColumns(schema.ColumnsColumns)
+	ss.AddTable(t)
+	return t, nil
+}
+
+func (m *SchemaDb) rowsForColumns(ctx *plan.Context) [][]driver.Value {
+	return m.s.ColumnsRows()
+}
+
+func (m *SchemaDb) tableForGrants() (*schema.Table, error) {
+
+	ss, err := m.is.SchemaSource("schema")
+	if err != nil {
+		return nil, err
+	}
+
+	t := schema.NewTable("grants")
+	t.AddField(schema.NewFieldBase("Grants", value.StringType, 255, "string"))
+	t.SetColumns(schema.GrantsColumns)
+	ss.AddTable(t)
+	return t, nil
+}
+
+func (m *SchemaDb) tableForExplain() (*schema.Table, error) {
+
+	ss, err := m.is.SchemaSource("schema")
+	if err != nil {
+		return nil, err
+	}
+
+	t := schema.NewTable("explain")
+	t.AddField(schema.NewFieldBase("id", value.IntType, 64, "int"))
+	t.AddField(schema.NewFieldBase("parent_id", value.IntType, 64, "int"))
+	t.AddField(schema.NewFieldBase("task", value.StringType, 64, "string"))
+	t.AddField(schema.NewFieldBase("detail", value.StringType, 255, "string"))
+	t.SetColumns(schema.ExplainColumns)
+	ss.AddTable(t)
+	return t, nil
+}
+
 func (m *SchemaDb) tableForTables() (*schema.Table, error) {
 
 	ss, err := m.is.SchemaSource("schema")
@@ -301,10 +461,13 @@ func (m *SchemaDb) tableForTables() (*schema.Table, error) {
 	t.SetColumns(cols)
 
 	ss.AddTable(t)
-	rows := make([][]driver.Value, len(m.s.Tables()))
+	rows := make([][]driver.Value, len(m.s.Tables()), len(m.s.Tables())+len(m.s.Views()))
 	for i, tableName := range m.s.Tables() {
 		rows[i] = []driver.Value{tableName, "BASE TABLE"}
 		tbl, err := m.s.Table(tableName)
+		if tbl != nil {
+			rows[i][0] = m.s.IdentifierCase().Name(tbl)
+		}
 		if tbl != nil && len(tbl.Columns()) > 0 && len(tbl.Fields) == 0 {
 			// I really don't like where this is, needs to be in schema somewhere
 			m.inspect(tbl.Name)
@@ -321,6 +484,13 @@ func (m *SchemaDb) tableForTables() (*schema.Table, error) {
 		}
 
 	}
+	for _, v := range m.s.Views() {
+		row := []driver.Value{v.Name, "VIEW"}
+		for range DialectWriters {
+			row = append(row, fmt.Sprintf("CREATE VIEW %s AS %s", expr.IdentityMaybeQuote('`', v.Name), v.Stmt))
+		}
+		rows = append(rows, row)
+	}
 	//u.Debugf("set rows: %v for tables: %v", rows, m.s.Tables())
 	t.SetRows(rows)
 	return t, nil
@@ -428,25 +598,49 @@ func mysqlWriteField(w *bytes.Buffer, fld *schema.Field) {
 	deflen := fld.Length
 	switch fld.Type {
 	case value.BoolType:
-		fmt.Fprint(w, "tinyint(1) DEFAULT NULL")
+		fmt.Fprint(w, "tinyint(1)")
 	case value.IntType:
-		fmt.Fprint(w, "bigint DEFAULT NULL")
+		fmt.Fprint(w, "bigint")
 	case value.StringType:
 		if deflen == 0 {
 			deflen = 255
 		}
-		fmt.Fprintf(w, "varchar(%d) DEFAULT NULL", deflen)
+		fmt.Fprintf(w, "varchar(%d)", deflen)
 	case value.NumberType:
-		fmt.Fprint(w, "float DEFAULT NULL")
+		fmt.Fprint(w, "float")
 	case value.TimeType:
-		fmt.Fprint(w, "datetime DEFAULT NULL")
+		fmt.Fprint(w, "datetime")
+	default:
+		fmt.Fprint(w, "text")
+	}
+	switch {
+	case fld.DefaultValue != nil && fld.NoNulls:
+		fmt.Fprintf(w, " NOT NULL DEFAULT %s", mysqlDefault(fld.DefaultValue))
+	case fld.DefaultValue != nil:
+		fmt.Fprintf(w, " DEFAULT %s", mysqlDefault(fld.DefaultValue))
+	case fld.NoNulls:
+		fmt.Fprint(w, " NOT NULL")
 	default:
-		fmt.Fprint(w, "text DEFAULT NULL")
+		fmt.Fprint(w, " DEFAULT NULL")
 	}
 	if len(fld.Description) > 0 {
 		fmt.Fprintf(w, " COMMENT %q", fld.Description)
 	}
 }
+
+// mysqlDefault the DEFAULT of a column of default v, strings quoted
+func mysqlDefault(v driver.Value) string {
+	switch vt := v.(type) {
+	case string:
+		return "'" + strings.Replace(vt, "'", "''", -1) + "'"
+	case bool:
+		if vt {
+			return "1"
+		}
+		return "0"
+	}
+	return fmt.Sprint(v)
+}
 func MysqlValueString(t value.ValueType) string {
 	switch t {
 	case value.NilType:
@@ -106,6 +106,51 @@ func TestSchemaShowStatements(t *testing.T) {
 			{"max_allowed_packet", int64(datasource.MaxAllowedPacket)},
 		},
 	)
+	testutil.TestSelect(t, `show variables like 'max_allowed_packet';`,
+		[][]driver.Value{
+			{"max_allowed_packet", int64(datasource.MaxAllowedPacket)},
+		},
+	)
+
+	// STATUS
+	testutil.TestSelect(t, `show status like 'Ssl%';`,
+		[][]driver.Value{
+			{"Ssl_cipher", ""},
+			{"Ssl_version", ""},
+		},
+	)
+	testutil.TestSelect(t, `show global status like 'Ssl_cipher';`,
+		[][]driver.Value{
+			{"Ssl_cipher", ""},
+		},
+	)
+	testutil.TestSelectErr(t, `show slave status;`, nil)
+
+	// GRANTS
+	testutil.TestSelect(t, `show grants;`,
+		[][]driver.Value{
+			{"GRANT ALL PRIVILEGES ON *.* TO ''@'%'"},
+		},
+	)
+	testutil.TestSelectErr(t, `show grants for 'bob'@'localhost';`, nil)
+
+	// FUNCTIONS
+	testutil.TestSelect(t, `show functions like 'array_j%';`,
+		[][]driver.Value{
+			{"array_join", "array", "array_join(array, sep [, null_as])", "scalar", "string", "the elements of an array joined by a separator"},
+		},
+	)
+	testutil.TestSelect(t, `show functions where Category = "window" AND Name LIKE "%rank";`,
+		[][]driver.Value{
+			{"dense_rank", "window", "dense_rank()", "window", "int", "the rank of the row in its partition, without gaps after ties"},
+			{"rank", "window", "rank()", "window", "int", "the rank of the row in its partition, of gaps after ties"},
+		},
+	)
+	testutil.TestSelect(t, `show functions like 'sum';`,
+		[][]driver.Value{
+			{"sum", "aggregate", "sum(value)", "aggregate", "number", "the sum of the values"},
+		},
+	)
 
 	// DESCRIBE
 	testutil.TestSelect(t, `describe users;`,
@@ -119,3 +164,16 @@ func TestSchemaShowStatements(t *testing.T) {
 	)
 
 }
+
+func TestSchemaExplain(t *testing.T) {
+	testutil.TestSelect(t, `/*+ BOGUS */ explain select user_id from users where user_id = "abc";`,
+		[][]driver.Value{
+			{int64(1), int64(0), "select", `SELECT user_id FROM users WHERE user_id = "abc"`},
+			{int64(2), int64(1), "source", "users conn=*mockcsv.MockCsvTable"},
+			{int64(3), int64(2), "where", `user_id = "abc"`},
+			{int64(4), int64(1), "where", `user_id = "abc"`},
+			{int64(5), int64(1), "projection", "user_id final"},
+			{int64(0), int64(0), "warning", "unknown hint BOGUS ignored"},
+		},
+	)
+}
@@ -2,6 +2,10 @@ package datasource
 
 import (
 	"database/sql/driver"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/araddon/qlbridge/expr"
@@ -17,12 +21,105 @@ const (
 // http://dev.mysql.com/doc/refman/5.6/en/server-system-variables.html
 var mysqlGlobalVars *ContextSimple = NewMySqlGlobalVars()
 
+// process start, for Uptime status
+var startTime = time.Now()
+
+// RowsForSession the session variables for SHOW [SESSION] VARIABLES, session
+// values take precedence over globals.
 func RowsForSession(ctx *plan.Context) [][]driver.Value {
+	if ctx.Session == nil {
+		return RowsForGlobal(ctx)
+	}
+	return variableRows(ctx.Session.Row())
+}
+
+// RowsForGlobal the global variables for SHOW GLOBAL VARIABLES
+func RowsForGlobal(ctx *plan.Context) [][]driver.Value {
+	return variableRows(mysqlGlobalVars.Row())
+}
+
+// variableRows normalize  @@session.tx_isolation, @@tx_isolation => tx_isolation
+// into sorted, de-duplicated Variable_name, Value rows.
+func variableRows(vars map[string]value.Value) [][]driver.Value {
+	names := make([]string, 0, len(vars))
+	vals := make(map[string]value.Value, len(vars))
+	for k, v := range vars {
+		name := strings.TrimPrefix(k, "@@")
+		isSession := strings.HasPrefix(name, "session.")
+		name = strings.TrimPrefix(strings.TrimPrefix(name, "session."), "global.")
+		if _, exists := vals[name]; !exists {
+			names = append(names, name)
+		} else if !isSession {
+			continue
+		}
+		vals[name] = v
+	}
+	sort.Strings(names)
+	rows := make([][]driver.Value, len(names))
+	for i, name := range names {
+		rows[i] = []driver.Value{name, vals[name].Value()}
+	}
+	return rows
+}
+
+// RowsForStatus the engine runtime status for SHOW STATUS
+func RowsForStatus(ctx *plan.Context) [][]driver.Value {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return [][]driver.Value{
+		{"Memory_used", int64(mem.Alloc)},
+		{"Ssl_cipher", ""},
+		{"Ssl_version", ""},
+		{"Threads_running", int64(runtime.NumGoroutine())},
+		{"Uptime", int64(time.Since(startTime) / time.Second)},
+	}
+}
 
-	ses := ctx.Session.Row()
-	rows := make([][]driver.Value, 0, len(ses))
-	for k, v := range ses {
-		rows = append(rows, []driver.Value{k, v.Value()})
+// RowsForProcessList for SHOW [FULL] PROCESSLIST, we don't track other
+// connections so this is only the current request.
+func RowsForProcessList(ctx *plan.Context) [][]driver.Value {
+	return [][]driver.Value{
+		{int64(ctx.ToPB().Id), ctx.User(), "", ctx.SchemaName, "Query", int64(0), "executing", ctx.Raw},
+	}
+}
+
+// RowsForGrants for SHOW GRANTS, there is no access control so the user
+// has all privileges.
+func RowsForGrants(ctx *plan.Context) [][]driver.Value {
+	return [][]driver.Value{
+		{fmt.Sprintf("GRANT ALL PRIVILEGES ON *.* TO '%s'@'%%'", ctx.User())},
+	}
+}
+
+// RowsForFuncs the registered functions for SHOW FUNCTIONS, of their type
+// scalar, aggregate or window
+func RowsForFuncs(ctx *plan.Context) [][]driver.Value {
+	infos := expr.FuncsInfo()
+	rows := make([][]driver.Value, len(infos))
+	for i, info := range infos {
+		kind := "scalar"
+		switch {
+		case info.Aggregate:
+			kind = "aggregate"
+		case info.Window:
+			kind = "window"
+		}
+		rows[i] = []driver.Value{info.Name, info.Category, info.Name + info.Signature, kind, info.Returns, info.Doc}
+	}
+	return rows
+}
+
+// RowsForExplain the plan steps of the statement being explained,
+// followed by any planner warnings (such as ignored hints).
+func RowsForExplain(ctx *plan.Context) [][]driver.Value {
+
+	steps := plan.ExplainTask(ctx.Explain)
+	rows := make([][]driver.Value, 0, len(steps)+len(ctx.Warnings))
+	for _, step := range steps {
+		rows = append(rows, []driver.Value{int64(step.Id), int64(step.ParentId), step.Task, step.Detail})
+	}
+	for _, warning := range ctx.Warnings {
+		rows = append(rows, []driver.Value{int64(0), int64(0), "warning", warning})
 	}
 	return rows
 }
@@ -56,7 +153,6 @@ func NewMySqlGlobalVars() *ContextSimple {
 	ctx.Data["@@lower_case_table_names"] = value.NewIntValue(0)
 	ctx.Data["max_allowed_packet"] = value.NewIntValue(MaxAllowedPacket)
 	ctx.Data["@@max_allowed_packet"] = value.NewIntValue(MaxAllowedPacket)
-	ctx.Data["@@max_allowed_packets"] = value.NewIntValue(MaxAllowedPacket)
 	ctx.Data["@@net_buffer_length"] = value.NewIntValue(16384)
 	ctx.Data["@@net_write_timeout"] = value.NewIntValue(600)
 	ctx.Data["@@query_cache_size"] = value.NewIntValue(1048576)
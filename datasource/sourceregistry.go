@@ -93,6 +93,18 @@ type Registry struct {
 	// We need to be able to flatten all tables across all sources into single keyspace
 	//tableSources map[string]schema.DataSource
 	tables []string
+	// the sources and schemas of the last config applied, see Apply
+	configured        map[string]*configuredSource
+	configuredSchemas map[string]*schema.ConfigSchema
+	// the health of the sources of the last check, see CheckHealth
+	health     map[string]*SourceHealth
+	stopHealth chan struct{}
+	// stops the background refresh of stats, see StartStatsRefresh
+	stopStats chan struct{}
+	// stops the background refresh of tables, see StartSchemaRefresh
+	stopRefresh chan struct{}
+	// the store schemas are saved to and loaded of, see SetSchemaStore
+	store schema.Store
 }
 
 func newRegistry() *Registry {
@@ -157,9 +169,24 @@ func (m *Registry) SchemaAdd(s *schema.Schema) {
 	if ok {
 		return
 	}
+	if m.store != nil && s.Store() == nil {
+		s.SetStore(m.store)
+	}
 	m.schemas[s.Name] = s
 }
 
+// SetSchemaStore the Store the schemas created after are saved to once
+// loaded, and their tables loaded of on the next start (instead of asking
+// their sources for them), ie
+//
+//   store, err := schema.NewFileStore("/var/lib/qlbridge/schema")
+//   datasource.DataSourcesRegistry().SetSchemaStore(store)
+func (m *Registry) SetSchemaStore(store schema.Store) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	m.store = store
+}
+
 // Add a new SourceSchema to a schema which will be created if it doesn't exist
 func (m *Registry) SourceSchemaAdd(schemaName string, ss *schema.SchemaSource) error {
 
@@ -174,6 +201,20 @@ func (m *Registry) SourceSchemaAdd(schemaName string, ss *schema.SchemaSource) e
 	return loadSchema(ss)
 }
 
+// PoolStats the stats of the connection pools of the registered sources
+// that pool their connections (see PooledSource), by source name
+func (m *Registry) PoolStats() map[string]PoolStats {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	stats := make(map[string]PoolStats)
+	for name, src := range m.sources {
+		if pooled, ok := src.(PooledSource); ok {
+			stats[name] = pooled.PoolStats()
+		}
+	}
+	return stats
+}
+
 // Tables - Get all tables from this registry
 func (m *Registry) Tables() []string {
 	if len(m.tables) == 0 {
@@ -273,12 +314,24 @@ func createSchema(sourceName string) (*schema.Schema, bool) {
 
 	ss.DS = ds
 	s := schema.NewSchema(sourceName)
+	s.SetStore(registry.store)
 	s.AddSourceSchema(ss)
 	loadSchema(ss)
 
 	return s, true
 }
 
+// SetupSettings load the settings of the config of ss for the Setup of a
+// source registered without them (ie a FileSource{} instead of one of
+// NewFileSource), a no-op if the source already loaded its settings or ss
+// has no config.
+func SetupSettings(ss *schema.SchemaSource, loaded bool, load func(settings u.JsonHelper) error) error {
+	if loaded || ss == nil || ss.Conf == nil {
+		return nil
+	}
+	return load(ss.Conf.Settings)
+}
+
 func loadSchema(ss *schema.SchemaSource) error {
 
 	if ss.DS == nil {
@@ -294,6 +347,20 @@ func loadSchema(ss *schema.SchemaSource) error {
 	}
 
 	s := ss.Schema()
+	if store := s.Store(); store != nil {
+		def, err := store.Load(s.Name)
+		if err == nil {
+			if sd := def.Source(ss.Name); sd != nil {
+				ss.LoadDef(sd)
+			}
+			if err := s.LoadViews(def); err != nil {
+				u.Warnf("%v", err)
+			}
+		} else if err != schema.ErrNotFound {
+			u.Warnf("could not load schema %q of store: %v", s.Name, err)
+		}
+	}
+
 	infoSchema := s.InfoSchema
 	var infoSchemaSource *schema.SchemaSource
 	var err error
@@ -312,7 +379,7 @@ func loadSchema(ss *schema.SchemaSource) error {
 		infoSchema.InfoSchema = infoSchema
 		infoSchema.AddSourceSchema(infoSchemaSource)
 	} else {
-		infoSchemaSource, err = infoSchema.Source("schema")
+		infoSchemaSource, err = infoSchema.SchemaSource("schema")
 	}
 
 	if err != nil {
@@ -334,6 +401,9 @@ func loadSchema(ss *schema.SchemaSource) error {
 	s.InfoSchema = infoSchema
 
 	s.RefreshSchema()
+	if err := s.Save(); err != nil {
+		u.Warnf("could not save schema %q: %v", s.Name, err)
+	}
 
 	//u.Debugf("s:%p ss:%p infoschema:%p  name:%s", s, ss, infoSchema, s.Name)
 
@@ -0,0 +1,133 @@
+package sqldb
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/araddon/qlbridge/expr/translate"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	// Postgres  $1 args, 'literal', "identity"
+	Postgres = &Dialect{
+		Name:          "postgres",
+		LiteralQuote:  '\'',
+		IdentityQuote: '"',
+		Placeholder:   func(i int) string { return "$" + strconv.Itoa(i) },
+		Funcs:         []string{"lower", "upper", "length", "coalesce", "abs", "round", "now"},
+	}
+	// MySQL  ? args, "literal", `identity`
+	MySQL = &Dialect{
+		Name:          "mysql",
+		LiteralQuote:  '"',
+		IdentityQuote: '`',
+		Funcs:         []string{"lower", "upper", "length", "coalesce", "abs", "round", "now"},
+	}
+	// MSSQL  @p1 args, 'literal', "identity", TOP n
+	MSSQL = &Dialect{
+		Name:          "mssql",
+		LiteralQuote:  '\'',
+		IdentityQuote: '"',
+		Placeholder:   func(i int) string { return "@p" + strconv.Itoa(i) },
+		Top:           true,
+		Funcs:         []string{"lower", "upper", "len", "coalesce", "abs", "round"},
+	}
+
+	dialectMu sync.RWMutex
+	// dialects by the name of their database/sql driver
+	dialects = map[string]*Dialect{
+		"postgres":  Postgres,
+		"pgx":       Postgres,
+		"mysql":     MySQL,
+		"sqlserver": MSSQL,
+		"mssql":     MSSQL,
+	}
+	// standard sql types of columns of value types
+	sqlTypes = map[value.ValueType]string{
+		value.IntType:       "BIGINT",
+		value.NumberType:    "DOUBLE PRECISION",
+		value.BoolType:      "BOOLEAN",
+		value.TimeType:      "TIMESTAMP",
+		value.ByteSliceType: "VARBINARY(8000)",
+		value.StringType:    "VARCHAR(1024)",
+	}
+)
+
+// Dialect the sql a database speaks, its quoting, args and functions
+type Dialect struct {
+	Name          string
+	LiteralQuote  byte
+	IdentityQuote byte
+	// Placeholder of the i'th (from 1) arg of a statement, ? if nil
+	Placeholder func(i int) string
+	// Top limit with SELECT TOP n instead of LIMIT n
+	Top bool
+	// Funcs the functions of the database a where may be sent with
+	Funcs []string
+	// ColumnsQuery the table name, column name and type of each column of
+	// the tables of a schema, in column order.  Its arg (if any) is the
+	// schema name.  Defaults to information_schema.columns.
+	ColumnsQuery string
+	// Types the sql types of the columns of tables created (see
+	// CreateTable), of their value type.  Defaults to standard sql types.
+	Types map[value.ValueType]string
+}
+
+// RegisterDialect register the Dialect of the database/sql driver of name
+func RegisterDialect(driver string, d *Dialect) {
+	dialectMu.Lock()
+	defer dialectMu.Unlock()
+	dialects[strings.ToLower(driver)] = d
+}
+
+// DialectOf the Dialect of a database/sql driver, nil if not known
+func DialectOf(driver string) *Dialect {
+	dialectMu.RLock()
+	defer dialectMu.RUnlock()
+	return dialects[strings.ToLower(driver)]
+}
+
+// arg the placeholder of the i'th arg
+func (m *Dialect) arg(i int) string {
+	if m.Placeholder == nil {
+		return "?"
+	}
+	return m.Placeholder(i)
+}
+
+// columnsQuery the query of the columns of schema, and its args
+func (m *Dialect) columnsQuery(schemaName string) (string, []interface{}) {
+	if m.ColumnsQuery != "" {
+		if strings.Contains(m.ColumnsQuery, m.arg(1)) {
+			return m.ColumnsQuery, []interface{}{schemaName}
+		}
+		return m.ColumnsQuery, nil
+	}
+	return "SELECT table_name, column_name, data_type FROM information_schema.columns " +
+		"WHERE table_schema = " + m.arg(1) + " ORDER BY table_name, ordinal_position", []interface{}{schemaName}
+}
+
+// sqlType the sql type of columns of value type vt, strings if unknown
+func (m *Dialect) sqlType(vt value.ValueType) string {
+	types := m.Types
+	if types == nil {
+		types = sqlTypes
+	}
+	if typ, ok := types[vt]; ok {
+		return typ
+	}
+	return types[value.StringType]
+}
+
+// translator of wheres into the dialect
+func (m *Dialect) translator() translate.Translator {
+	return translate.NewSqlTranslator(m.LiteralQuote, m.IdentityQuote, m.Funcs...)
+}
+
+// identity quoted always, names sent are those of the database
+func (m *Dialect) identity(name string) string {
+	q := string(m.IdentityQuote)
+	return q + strings.Replace(name, q, q+q, -1) + q
+}
@@ -0,0 +1,234 @@
+package sqldb_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/sqldb"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/expr/builtins"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+// testDB a database/sql driver of a users table, recording the statements
+// it is sent.  Selects return rows, as the database would for the statement.
+type testDB struct {
+	mu         sync.Mutex
+	statements []string
+	rows       [][]driver.Value
+	columns    [][]driver.Value // table, column and type of each column
+	created    [][]driver.Value // columns of the table of CREATE TABLE
+}
+
+var db = &testDB{columns: [][]driver.Value{
+	{"users", "id", "integer"},
+	{"users", "name", "character varying"},
+	{"users", "age", "smallint"},
+}}
+
+func init() {
+	builtins.LoadAllBuiltins()
+	sql.Register("sqldbtest", db)
+	sql.Register("sqlite3", db)
+}
+
+func (m *testDB) record(query string, args []driver.Value) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(args) > 0 {
+		query = fmt.Sprintf("%s %v", query, args)
+	}
+	m.statements = append(m.statements, query)
+}
+
+func (m *testDB) Open(name string) (driver.Conn, error) { return m, nil }
+func (m *testDB) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{query: query}, nil
+}
+func (m *testDB) Close() error              { return nil }
+func (m *testDB) Begin() (driver.Tx, error) { return nil, fmt.Errorf("no transactions") }
+
+type stmt struct {
+	query string
+}
+
+func (m *stmt) Close() error  { return nil }
+func (m *stmt) NumInput() int { return -1 }
+func (m *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	db.record(m.query, args)
+	if strings.HasPrefix(m.query, "CREATE TABLE") {
+		db.mu.Lock()
+		db.columns = append(db.columns, db.created...)
+		db.mu.Unlock()
+	}
+	return driver.RowsAffected(2), nil
+}
+func (m *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if strings.Contains(m.query, "information_schema") || strings.Contains(m.query, "sqlite_master") {
+		return &rows{cols: []string{"table_name", "column_name", "data_type"}, vals: db.columns}, nil
+	}
+	db.mu.Unlock()
+	db.record(m.query, args)
+	db.mu.Lock()
+	return &rows{cols: []string{"id", "name", "age"}, vals: db.rows}, nil
+}
+
+type rows struct {
+	cols []string
+	vals [][]driver.Value
+}
+
+func (m *rows) Columns() []string { return m.cols }
+func (m *rows) Close() error      { return nil }
+func (m *rows) Next(dest []driver.Value) error {
+	if len(m.vals) == 0 {
+		return io.EOF
+	}
+	copy(dest, m.vals[0])
+	m.vals = m.vals[1:]
+	return nil
+}
+
+func TestSqlSource(t *testing.T) {
+	conn, err := sql.Open("sqldbtest", "")
+	assert.Tf(t, err == nil, "no error %v", err)
+	src, err := sqldb.NewSqlSource(conn, sqldb.Postgres, "public")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"users"}, src.Tables())
+	tbl, err := src.Table("users")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"id", "name", "age"}, tbl.Columns())
+	datasource.RegisterSchemaSource("sqldb", "sqldb", src)
+
+	tests := []struct {
+		sql       string
+		statement string
+		rows      [][]driver.Value // returned by the database
+		names     string
+	}{
+		{`SELECT name FROM users WHERE age > 30 ORDER BY name DESC LIMIT 2`,
+			`SELECT "id", "name", "age" FROM "public"."users" WHERE age > 30 ORDER BY "name" DESC LIMIT 2`,
+			[][]driver.Value{{int64(1), []byte("carol"), int64(40)}, {int64(2), []byte("bob"), int64(35)}},
+			"carol,bob"},
+		// the where is evaluated in-process, before the limit
+		{`SELECT name FROM users WHERE tolower(name) = "bob" LIMIT 1`,
+			`SELECT "id", "name", "age" FROM "public"."users"`,
+			[][]driver.Value{{int64(1), []byte("carol"), int64(40)}, {int64(2), []byte("bob"), int64(35)}},
+			"bob"},
+		{`SELECT u.name AS name FROM users AS u WHERE u.age < 30 ORDER BY u.name`,
+			`SELECT "id", "name", "age" FROM "public"."users" AS "u" WHERE u.age < 30 ORDER BY "name"`,
+			[][]driver.Value{{int64(3), []byte("aaron"), int64(20)}},
+			"aaron"},
+	}
+	for _, tt := range tests {
+		db.mu.Lock()
+		db.statements, db.rows = nil, tt.rows
+		db.mu.Unlock()
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("sqldb")
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		out := exec.NewResultRows(ctx, []string{"name"})
+		job.RootTask.Add(out)
+		assert.T(t, job.Setup() == nil)
+		go job.Run()
+		names := make([]string, 0)
+		dest := make([]driver.Value, 1)
+		for out.Next(dest) == nil {
+			names = append(names, dest[0].(string))
+		}
+		job.Close()
+		assert.Equalf(t, tt.names, strings.Join(names, ","), "sql %s", tt.sql)
+		assert.Equalf(t, []string{tt.statement}, db.statements, "sql %s", tt.sql)
+	}
+
+	writes := []struct {
+		sql       string
+		statement string
+	}{
+		{`INSERT INTO users (id, name) VALUES (4, "dan"), (5, "eve")`,
+			`INSERT INTO "public"."users" ("id", "name") VALUES ($1, $2), ($3, $4) [4 dan 5 eve]`},
+		{`UPDATE users SET age = 41 WHERE name = "carol"`,
+			`UPDATE "public"."users" SET "age" = $1 WHERE name = 'carol' [41]`},
+		{`DELETE FROM users WHERE age < 21`,
+			`DELETE FROM "public"."users" WHERE age < 21`},
+	}
+	for _, tt := range writes {
+		db.mu.Lock()
+		db.statements = nil
+		db.mu.Unlock()
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("sqldb")
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+		err = job.Run()
+		assert.Tf(t, err == nil, "no error %v", err)
+		job.Close()
+		assert.Equalf(t, []string{tt.statement}, db.statements, "sql %s", tt.sql)
+	}
+}
+
+func TestSqliteSource(t *testing.T) {
+	src, err := sqldb.NewSqliteSource("test.db")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"users"}, src.Tables())
+
+	archive := schema.NewTable("archive")
+	archive.AddField(schema.NewFieldBase("id", value.IntType, 64, "int"))
+	archive.AddField(schema.NewFieldBase("name", value.StringType, 64, "string"))
+	archive.AddField(schema.NewFieldBase("active", value.BoolType, 1, "bool"))
+	db.mu.Lock()
+	db.statements = nil
+	db.created = [][]driver.Value{
+		{"archive", "id", "INTEGER"},
+		{"archive", "name", "TEXT"},
+		{"archive", "active", "BOOLEAN"},
+	}
+	db.mu.Unlock()
+	assert.T(t, src.CreateTable(archive) == nil)
+	assert.Equal(t, []string{
+		`CREATE TABLE IF NOT EXISTS "archive" ("id" INTEGER, "name" TEXT, "active" BOOLEAN)`,
+	}, db.statements)
+	assert.Equal(t, []string{"archive", "users"}, src.Tables())
+	tbl, err := src.Table("archive")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"id", "name", "active"}, tbl.Columns())
+	assert.Equal(t, value.BoolType, tbl.Fields[2].Type)
+
+	// the rows of the select are kept in the archive table
+	db.mu.Lock()
+	db.statements = nil
+	db.rows = [][]driver.Value{{int64(1), []byte("carol"), int64(40)}, {int64(2), []byte("bob"), int64(35)}}
+	db.mu.Unlock()
+	ctx := plan.NewContext(`INSERT INTO archive (id, name) SELECT id, name FROM users WHERE age > 30`)
+	ctx.Schema = datasource.RegisterSchemaSource("sqlite", "sqlite", src)
+	ctx.WriteBatchSize = 10
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	msgs := make([]schema.Message, 0)
+	job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+	assert.T(t, job.Setup() == nil)
+	err = job.Run()
+	assert.Tf(t, err == nil, "no error %v", err)
+	job.Close()
+	assert.Equal(t, []string{
+		`SELECT "id", "name", "age" FROM "users" WHERE age > 30`,
+		`INSERT INTO "archive" ("id", "name") VALUES (?, ?), (?, ?) [1 carol 2 bob]`,
+	}, db.statements)
+	assert.Equal(t, int64(2), msgs[0].Body().([]driver.Value)[1])
+}
@@ -0,0 +1,58 @@
+package sqldb
+
+import (
+	"database/sql"
+
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	// SqliteDriver the database/sql driver NewSqliteSource opens files with,
+	// imported by the program (ie github.com/mattn/go-sqlite3)
+	SqliteDriver = "sqlite3"
+
+	// Sqlite  ? args, 'literal', "identity", of a database file without
+	// schemas, its tables in sqlite_master
+	Sqlite = &Dialect{
+		Name:          "sqlite",
+		LiteralQuote:  '\'',
+		IdentityQuote: '"',
+		Funcs:         []string{"lower", "upper", "length", "coalesce", "abs", "round"},
+		ColumnsQuery: "SELECT m.name, p.name, p.type FROM sqlite_master AS m " +
+			"JOIN pragma_table_info(m.name) AS p " +
+			"WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%' ORDER BY m.name, p.cid",
+		Types: map[value.ValueType]string{
+			value.IntType:       "INTEGER",
+			value.NumberType:    "REAL",
+			value.BoolType:      "BOOLEAN",
+			value.TimeType:      "DATETIME",
+			value.ByteSliceType: "BLOB",
+			value.StringType:    "TEXT",
+		},
+	}
+)
+
+func init() {
+	RegisterDialect("sqlite3", Sqlite)
+	RegisterDialect("sqlite", Sqlite)
+}
+
+// NewSqliteSource a SqlSource of the tables of the SQLite database file,
+// created if it does not exist.  As a source its scans are pushed down
+// as of any other database, and as a sink (see CreateTable) rows of
+// INSERT INTO ... SELECT are kept in the file.  The file is the dsn of the
+// driver, so may have its options, ie WAL so selects of the file being
+// inserted into from do not block the inserts.
+//
+//   src, _ := sqldb.NewSqliteSource("/var/lib/app/events.db?_journal_mode=WAL")
+//   src.CreateTable(eventsTable)
+//   datasource.RegisterSchemaSource("local", "local", src)
+//
+//   INSERT INTO events (id, kind) SELECT id, kind FROM events_csv
+func NewSqliteSource(file string) (*SqlSource, error) {
+	db, err := sql.Open(SqliteDriver, file)
+	if err != nil {
+		return nil, err
+	}
+	return NewSqlSource(db, Sqlite, "")
+}
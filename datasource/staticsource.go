@@ -11,6 +11,7 @@ var _ = u.EMPTY
 var (
 	// Enforce datasource feature interfaces
 	_ schema.Source      = (*StaticSource)(nil)
+	_ MeteredSource      = (*StaticSource)(nil)
 	_ schema.ConnScanner = (*StaticSource)(nil)
 	_ schema.Conn        = (*StaticSource)(nil)
 	_ schema.ConnColumns = (*StaticSource)(nil)
@@ -18,11 +19,12 @@ var (
 
 // A static, non-thread safe, single-table data source
 type StaticSource struct {
-	table  string
-	cols   []string
-	cursor int
-	vals   []schema.Message
-	exit   <-chan bool
+	table   string
+	cols    []string
+	cursor  int
+	vals    []schema.Message
+	exit    <-chan bool
+	metrics Metrics
 }
 
 func NewStaticSource(name string, cols []string, msgs []schema.Message) *StaticSource {
@@ -36,6 +38,7 @@ func NewStaticSource(name string, cols []string, msgs []schema.Message) *StaticS
 func (m *StaticSource) Tables() []string                   { return []string{m.table} }
 func (m *StaticSource) Open(_ string) (schema.Conn, error) { return m, nil }
 func (m *StaticSource) Close() error                       { return nil }
+func (m *StaticSource) Metrics() *Metrics                  { return &m.metrics }
 func (m *StaticSource) Columns() []string                  { return m.cols }
 func (m *StaticSource) CreateIterator() schema.Iterator    { return m }
 func (m *StaticSource) MesgChan() <-chan schema.Message {
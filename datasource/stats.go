@@ -0,0 +1,161 @@
+package datasource
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	// StatsMaxAge how old the stats of a table may get before the background
+	// refresh (see Registry.StartStatsRefresh) analyzes it again
+	StatsMaxAge = time.Hour
+
+	// statsRefreshMu guards the background stats refresh of the registry
+	statsRefreshMu sync.Mutex
+)
+
+// AnalyzeTable compute the ColumnStats of the columns of tbl of a scan of
+// all of its rows, and set them on its fields (see ANALYZE TABLE).
+func AnalyzeTable(tbl *schema.Table) error {
+	if tbl.SchemaSource == nil || tbl.SchemaSource.DS == nil {
+		return fmt.Errorf("table %q has no source to analyze", tbl.Name)
+	}
+	conn, err := tbl.SchemaSource.DS.Open(tbl.Name)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	scanner, ok := conn.(schema.ConnScanner)
+	if !ok {
+		return fmt.Errorf("source of table %q does not scan, can not analyze", tbl.Name)
+	}
+	return AnalyzeTableIter(tbl, scanner)
+}
+
+// AnalyzeTableIter compute the ColumnStats of the columns of tbl of the
+// rows of iter, and set them on its fields, added for columns without one
+func AnalyzeTableIter(tbl *schema.Table, iter schema.Iterator) error {
+	cols := tbl.Columns()
+	if len(cols) == 0 {
+		return fmt.Errorf("table %q has no columns to analyze", tbl.Name)
+	}
+	b := schema.NewStatsBuilder(cols)
+	for {
+		msg := iter.Next()
+		if msg == nil {
+			break
+		}
+		switch mt := msg.Body().(type) {
+		case []driver.Value:
+			b.Add(mt)
+		case *SqlDriverMessageMap:
+			b.Add(mt.Vals)
+		default:
+			return fmt.Errorf("can not analyze rows of %T of table %q", mt, tbl.Name)
+		}
+	}
+	stats := b.Stats()
+	for _, col := range cols {
+		// a field for the stats of the columns of sources that only know
+		// their names, of the type of their values
+		if _, ok := tbl.FieldMap[col]; !ok {
+			vt := value.UnknownType
+			if cs := stats[col]; cs.Min != nil {
+				vt = value.NewValue(cs.Min).Type()
+			}
+			tbl.AddFieldType(col, vt)
+		}
+	}
+	tbl.SetStats(stats)
+	return nil
+}
+
+// RefreshStats analyze the tables of the schemas of the registry whose
+// stats are older than maxAge (or missing), see RefreshSchemaStats.
+// Returns the tables analyzed as "schema.table".
+func (m *Registry) RefreshStats(maxAge time.Duration) []string {
+	registryMu.RLock()
+	schemas := make([]*schema.Schema, 0, len(m.schemas))
+	for _, s := range m.schemas {
+		schemas = append(schemas, s)
+	}
+	registryMu.RUnlock()
+
+	analyzed := make([]string, 0)
+	for _, s := range schemas {
+		for _, name := range RefreshSchemaStats(s, maxAge) {
+			analyzed = append(analyzed, s.Name+"."+name)
+		}
+	}
+	sort.Strings(analyzed)
+	return analyzed
+}
+
+// RefreshSchemaStats analyze the tables of s whose stats are older than
+// maxAge (or missing), and save s to its store if any were.  Returns the
+// names of the tables analyzed.
+func RefreshSchemaStats(s *schema.Schema, maxAge time.Duration) []string {
+	analyzed := make([]string, 0)
+	for _, name := range s.Tables() {
+		tbl, err := s.Table(name)
+		if err != nil || tbl == nil || tbl.SchemaSource == nil {
+			continue
+		}
+		if at := tbl.Analyzed(); !at.IsZero() && time.Since(at) < maxAge {
+			continue
+		}
+		if err := AnalyzeTable(tbl); err != nil {
+			u.Warnf("could not analyze %s.%s: %v", s.Name, name, err)
+			continue
+		}
+		analyzed = append(analyzed, name)
+	}
+	if len(analyzed) > 0 {
+		if err := s.Save(); err != nil {
+			u.Warnf("could not save stats of schema %q: %v", s.Name, err)
+		}
+	}
+	return analyzed
+}
+
+// StartStatsRefresh analyze the tables with stats older than StatsMaxAge
+// every interval in the background, until StopStatsRefresh
+func (m *Registry) StartStatsRefresh(interval time.Duration) {
+	statsRefreshMu.Lock()
+	defer statsRefreshMu.Unlock()
+	if m.stopStats != nil {
+		close(m.stopStats)
+	}
+	stop := make(chan struct{})
+	m.stopStats = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.RefreshStats(StatsMaxAge)
+			}
+		}
+	}()
+}
+
+// StopStatsRefresh stop the background refresh of stats
+func (m *Registry) StopStatsRefresh() {
+	statsRefreshMu.Lock()
+	defer statsRefreshMu.Unlock()
+	if m.stopStats != nil {
+		close(m.stopStats)
+		m.stopStats = nil
+	}
+}
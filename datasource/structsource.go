@@ -0,0 +1,304 @@
+package datasource
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	_ schema.Source            = (*StructSource)(nil)
+	_ MeteredSource            = (*StructSource)(nil)
+	_ schema.SourceTableSchema = (*StructSource)(nil)
+	_ schema.Conn              = (*structConn)(nil)
+	_ schema.ConnScanner       = (*structConn)(nil)
+	_ schema.ConnColumns       = (*structConn)(nil)
+
+	// StructTag the tag of the column name (and options) of struct fields
+	StructTag = "db"
+
+	timeType = reflect.TypeOf(time.Time{})
+
+	// the column types of the options of struct tags
+	structTagTypes = map[string]value.ValueType{
+		"string": value.StringType,
+		"int":    value.IntType,
+		"number": value.NumberType,
+		"bool":   value.BoolType,
+		"time":   value.TimeType,
+		"json":   value.JsonType,
+	}
+)
+
+// StructSource a DataSource of go structs as a single table, of a slice
+// ([]T, []*T, or *[]T read as it is when scanned) or a channel (read once
+// until closed) of them.  A column per exported field, named by its tag:
+//
+//   type User struct {
+//       Id      int64     `db:"id"`
+//       Name    string                     // column Name
+//       Created time.Time `db:"created"`
+//       Secret  string    `db:"-"`          // not a column
+//       Zip     int       `db:"zip,string"` // a string column
+//       Address                            // embedded, its fields are columns
+//   }
+//
+//   src, _ := datasource.NewStructSource("users", users)
+//   datasource.RegisterSchemaSource("app", "app", src)
+//
+// Options of a tag set the type of the column (string, int, number, bool,
+// time, json), else it is of the field kind.  Structs, maps and slices of
+// other than strings and bytes are json.
+type StructSource struct {
+	table   string
+	tbl     *schema.Table
+	fields  []*structField
+	rows    reflect.Value // the slice, or pointer to it
+	ch      reflect.Value // or the channel
+	metrics Metrics
+}
+
+// structField a column of a struct field
+type structField struct {
+	name  string
+	index []int
+	typ   value.ValueType
+}
+
+// structConn a scan of the rows of a StructSource
+type structConn struct {
+	src      *StructSource
+	cursor   int
+	colindex map[string]int
+}
+
+// NewStructSource a source of table of the structs of rows, a slice,
+// pointer to a slice, or channel of structs (or pointers to them)
+func NewStructSource(table string, rows interface{}) (*StructSource, error) {
+	m := &StructSource{table: table}
+	rv := reflect.ValueOf(rows)
+	elem := rv.Type()
+	switch {
+	case rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Slice:
+		m.rows = rv
+		elem = rv.Elem().Type().Elem()
+	case rv.Kind() == reflect.Slice:
+		m.rows = rv
+		elem = elem.Elem()
+	case rv.Kind() == reflect.Chan:
+		m.ch = rv
+		elem = elem.Elem()
+	default:
+		return nil, fmt.Errorf("struct source %q requires a slice or channel of structs, not %T", table, rows)
+	}
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("struct source %q requires a slice or channel of structs, not of %v", table, elem)
+	}
+	fields, err := structFields(elem, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("struct source %q has no exported fields of %v", table, elem)
+	}
+	m.fields = fields
+	m.tbl = schema.NewTable(table)
+	cols := make([]string, len(fields))
+	for i, f := range fields {
+		cols[i] = f.name
+		m.tbl.AddField(schema.NewFieldBase(f.name, f.typ, 64, f.typ.String()))
+	}
+	m.tbl.SetColumns(cols)
+	return m, nil
+}
+
+// structFields the columns of the exported fields of struct type t, and
+// of its embedded structs
+func structFields(t reflect.Type, index []int) ([]*structField, error) {
+	fields := make([]*structField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fi := append(append([]int{}, index...), i)
+		tag := sf.Tag.Get(StructTag)
+		if tag == "-" {
+			continue
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && ft.Kind() == reflect.Struct && tag == "" {
+			embedded, err := structFields(ft, fi)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, embedded...)
+			continue
+		}
+		if sf.PkgPath != "" {
+			// unexported
+			continue
+		}
+		name, opt := sf.Name, ""
+		if tag != "" {
+			parts := strings.SplitN(tag, ",", 2)
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			if len(parts) > 1 {
+				opt = strings.TrimSpace(parts[1])
+			}
+		}
+		typ := kindType(ft)
+		if opt != "" {
+			optType, ok := structTagTypes[opt]
+			if !ok {
+				return nil, fmt.Errorf("unknown type %q of field %s", opt, sf.Name)
+			}
+			typ = optType
+		}
+		fields = append(fields, &structField{name: name, index: fi, typ: typ})
+	}
+	return fields, nil
+}
+
+// kindType the value type of a field of go type t
+func kindType(t reflect.Type) value.ValueType {
+	if t == timeType {
+		return value.TimeType
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.IntType
+	case reflect.Float32, reflect.Float64:
+		return value.NumberType
+	case reflect.Bool:
+		return value.BoolType
+	case reflect.String:
+		return value.StringType
+	case reflect.Slice:
+		switch t.Elem().Kind() {
+		case reflect.Uint8:
+			return value.ByteSliceType
+		case reflect.String:
+			return value.StringsType
+		}
+	}
+	return value.JsonType
+}
+
+func (m *StructSource) Tables() []string  { return []string{m.table} }
+func (m *StructSource) Close() error      { return nil }
+func (m *StructSource) Metrics() *Metrics { return &m.metrics }
+
+func (m *StructSource) Table(table string) (*schema.Table, error) {
+	if !strings.EqualFold(table, m.table) {
+		return nil, schema.ErrNotFound
+	}
+	return m.tbl, nil
+}
+
+// Open a scan of the rows, from the first of a slice
+func (m *StructSource) Open(table string) (schema.Conn, error) {
+	if !strings.EqualFold(table, m.table) {
+		return nil, schema.ErrNotFound
+	}
+	return &structConn{src: m, colindex: m.tbl.FieldPositions}, nil
+}
+
+func (m *structConn) Columns() []string { return m.src.tbl.Columns() }
+func (m *structConn) Close() error      { return nil }
+
+func (m *structConn) Next() schema.Message {
+	var row reflect.Value
+	if m.src.ch.IsValid() {
+		recv, ok := m.src.ch.Recv()
+		if !ok {
+			return nil
+		}
+		row = recv
+	} else {
+		rows := m.src.rows
+		if rows.Kind() == reflect.Ptr {
+			rows = rows.Elem()
+		}
+		if m.cursor >= rows.Len() {
+			return nil
+		}
+		row = rows.Index(m.cursor)
+	}
+	m.cursor++
+	for row.Kind() == reflect.Ptr {
+		if row.IsNil() {
+			// a nil row is skipped
+			return m.Next()
+		}
+		row = row.Elem()
+	}
+	vals := make([]driver.Value, len(m.src.fields))
+	for i, f := range m.src.fields {
+		vals[i] = fieldValue(row, f)
+	}
+	return NewSqlDriverMessageMap(uint64(m.cursor), vals, m.colindex)
+}
+
+// fieldValue the value of field f of struct row, as of its column type
+func fieldValue(row reflect.Value, f *structField) driver.Value {
+	fv := row
+	for _, i := range f.index {
+		for fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				return nil
+			}
+			fv = fv.Elem()
+		}
+		fv = fv.Field(i)
+	}
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	if (fv.Kind() == reflect.Slice || fv.Kind() == reflect.Map) && fv.IsNil() {
+		return nil
+	}
+	v := fv.Interface()
+	var ok bool
+	switch f.typ {
+	case value.StringType:
+		if s, isString := v.(string); isString {
+			return s
+		}
+		return fmt.Sprint(v)
+	case value.IntType:
+		v, ok = value.ToInt64(fv)
+	case value.NumberType:
+		v, ok = value.ToFloat64(fv)
+	case value.BoolType:
+		v, ok = value.ToBool(fv)
+	case value.TimeType:
+		if _, ok = v.(time.Time); !ok {
+			v, ok = value.ValueToTime(value.NewValue(v))
+		}
+	default:
+		// bytes, strings and json as they are
+		return v
+	}
+	if !ok {
+		u.Debugf("could not convert %v to %s", fv.Interface(), f.typ)
+		return nil
+	}
+	return v
+}
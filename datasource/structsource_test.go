@@ -0,0 +1,113 @@
+package datasource_test
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+type address struct {
+	City string `db:"city"`
+}
+
+type account struct {
+	Id      int64     `db:"id"`
+	Name    string    // column Name
+	Created time.Time `db:"created"`
+	Secret  string    `db:"-"`
+	Zip     int       `db:"zip,string"`
+	Score   *float64  `db:"score"`
+	Tags    []string  `db:"tags"`
+	private int
+	*address
+}
+
+func structRows(t *testing.T, conn schema.Conn) [][]driver.Value {
+	rows := make([][]driver.Value, 0)
+	scanner := conn.(schema.ConnScanner)
+	for msg := scanner.Next(); msg != nil; msg = scanner.Next() {
+		rows = append(rows, msg.(*datasource.SqlDriverMessageMap).Values())
+	}
+	return rows
+}
+
+func TestStructSource(t *testing.T) {
+	created := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	score := 1.5
+	accounts := []*account{
+		{Id: 1, Name: "aaron", Created: created, Secret: "x", Zip: 94107, Score: &score, Tags: []string{"a"}, address: &address{"sf"}},
+		nil,
+		{Id: 2, Name: "bob", Zip: 10001},
+	}
+	src, err := datasource.NewStructSource("accounts", &accounts)
+	assert.Tf(t, err == nil, "no error %v", err)
+	tbl, err := src.Table("accounts")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"id", "Name", "created", "zip", "score", "tags", "city"}, tbl.Columns())
+	types := make([]value.ValueType, 0)
+	for _, f := range tbl.Fields {
+		types = append(types, f.Type)
+	}
+	assert.Equal(t, []value.ValueType{value.IntType, value.StringType, value.TimeType,
+		value.StringType, value.NumberType, value.StringsType, value.StringType}, types)
+
+	conn, err := src.Open("accounts")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, [][]driver.Value{
+		{int64(1), "aaron", created, "94107", 1.5, []string{"a"}, "sf"},
+		{int64(2), "bob", time.Time{}, "10001", nil, nil, nil},
+	}, structRows(t, conn))
+
+	// rows of the slice appended after are read by later scans
+	accounts = append(accounts, &account{Id: 3, Name: "carol", address: &address{"sf"}})
+	ctx := plan.NewContext(`SELECT Name FROM accounts WHERE city = "sf"`)
+	ctx.Schema = datasource.RegisterSchemaSource("structs", "structs", src)
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	out := exec.NewResultRows(ctx, []string{"Name"})
+	job.RootTask.Add(out)
+	assert.T(t, job.Setup() == nil)
+	go job.Run()
+	names := make([]string, 0)
+	dest := make([]driver.Value, 1)
+	for out.Next(dest) == nil {
+		names = append(names, dest[0].(string))
+	}
+	job.Close()
+	assert.Equal(t, []string{"aaron", "carol"}, names)
+	// the scan, of all rows before the where, in the metrics of the source
+	var metrics []datasource.TableMetrics
+	for _, tm := range datasource.DataSourcesRegistry().Metrics() {
+		if tm.Source == "structs" {
+			metrics = append(metrics, tm)
+		}
+	}
+	assert.Equal(t, 1, len(metrics))
+	assert.Equal(t, int64(1), metrics[0].Requests)
+	assert.Equal(t, int64(3), metrics[0].Rows)
+	assert.Equal(t, int64(1), metrics[0].Latency.Count)
+
+	// a channel is read until closed
+	ch := make(chan account, 2)
+	ch <- account{Id: 4, Name: "dan"}
+	close(ch)
+	chsrc, err := datasource.NewStructSource("accounts", ch)
+	assert.Tf(t, err == nil, "no error %v", err)
+	conn, _ = chsrc.Open("accounts")
+	assert.Equal(t, 1, len(structRows(t, conn)))
+
+	_, err = datasource.NewStructSource("accounts", []int{1})
+	assert.T(t, err != nil)
+	_, err = datasource.NewStructSource("accounts", []struct {
+		A int `db:"a,blob"`
+	}{})
+	assert.T(t, err != nil)
+}
@@ -0,0 +1,250 @@
+package syslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// facility names by code, of RFC 5424
+	facilities = []string{"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+		"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+		"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7"}
+
+	// severity names by code, of RFC 5424
+	severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+	// priority of messages without one, user.notice
+	defaultPriority = 13
+)
+
+// Entry a parsed syslog message, or journald entry
+type Entry struct {
+	Timestamp      time.Time
+	Priority       int // facility * 8 + severity
+	Hostname       string
+	App            string // app-name, the tag of RFC 3164 messages
+	ProcId         string
+	MsgId          string
+	StructuredData string // of RFC 5424 messages, unparsed
+	Message        string
+	Remote         string // address of the sender, "" for journald
+}
+
+// Facility the name of the facility of the priority of m, ie "auth"
+func (m *Entry) Facility() string {
+	if f := m.Priority / 8; f >= 0 && f < len(facilities) {
+		return facilities[f]
+	}
+	return strconv.Itoa(m.Priority / 8)
+}
+
+// Severity the name of the severity of the priority of m, ie "err"
+func (m *Entry) Severity() string {
+	return severities[m.Priority%8]
+}
+
+// Parse a syslog message, of RFC 5424
+//
+//   <165>1 2016-05-01T12:00:00.003Z host app 1234 ID47 [ex@32473 a="1"] message
+//
+// or RFC 3164 (BSD)
+//
+//   <34>May  1 12:00:00 host su[1234]: message
+//
+// the time of RFC 3164 messages is in the current year (of now), in local
+// time.  Lines of neither are a message of the default priority received now.
+func Parse(line string, now time.Time) *Entry {
+	line = strings.TrimRight(line, "\r\n\x00")
+	e := &Entry{Priority: defaultPriority, Timestamp: now}
+	rest, ok := parsePriority(line, e)
+	if !ok {
+		e.Message = line
+		return e
+	}
+	if strings.HasPrefix(rest, "1 ") {
+		if parse5424(rest[2:], e) {
+			return e
+		}
+		*e = Entry{Priority: e.Priority, Timestamp: now}
+	}
+	parse3164(rest, now, e)
+	return e
+}
+
+// parsePriority the <PRI> of the start of line, the rest of line after it
+func parsePriority(line string, e *Entry) (string, bool) {
+	if !strings.HasPrefix(line, "<") {
+		return line, false
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return line, false
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return line, false
+	}
+	e.Priority = pri
+	return line[end+1:], true
+}
+
+// parse5424 the header, structured data and message after the version
+func parse5424(rest string, e *Entry) bool {
+	fields := make([]string, 5)
+	for i := range fields {
+		sp := strings.IndexByte(rest, ' ')
+		if sp < 0 {
+			return false
+		}
+		fields[i], rest = rest[:sp], rest[sp+1:]
+	}
+	if fields[0] != "-" {
+		ts, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return false
+		}
+		e.Timestamp = ts
+	}
+	e.Hostname, e.App, e.ProcId, e.MsgId = nilValue(fields[1]), nilValue(fields[2]), nilValue(fields[3]), nilValue(fields[4])
+
+	sd, msg, ok := structuredData(rest)
+	if !ok {
+		return false
+	}
+	e.StructuredData = sd
+	// a BOM marks a UTF-8 message
+	e.Message = strings.TrimPrefix(msg, "\xef\xbb\xbf")
+	return true
+}
+
+// structuredData split the structured data of the start of rest from the
+// message after it
+func structuredData(rest string) (string, string, bool) {
+	if rest == "-" || strings.HasPrefix(rest, "- ") {
+		if len(rest) > 2 {
+			return "", rest[2:], true
+		}
+		return "", "", true
+	}
+	if !strings.HasPrefix(rest, "[") {
+		return "", "", false
+	}
+	inValue, escaped := false, false
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && inValue:
+			escaped = true
+		case c == '"':
+			inValue = !inValue
+		case c == ']' && !inValue:
+			// elements follow each other with no space between
+			if i+1 < len(rest) && rest[i+1] == '[' {
+				continue
+			}
+			sd, msg := rest[:i+1], rest[i+1:]
+			return sd, strings.TrimPrefix(msg, " "), true
+		}
+	}
+	return "", "", false
+}
+
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// parse3164 the timestamp, hostname and tag[pid]: of a BSD message, the
+// whole of rest is the message if it has no timestamp
+func parse3164(rest string, now time.Time, e *Entry) {
+	e.Message = rest
+	if len(rest) < 16 || rest[15] != ' ' {
+		return
+	}
+	ts, err := time.ParseInLocation(time.Stamp, rest[:15], now.Location())
+	if err != nil {
+		return
+	}
+	year := now.Year()
+	// messages of late december received in january
+	if ts.Month() > now.Month()+1 {
+		year--
+	}
+	e.Timestamp = time.Date(year, ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), 0, now.Location())
+	rest = rest[16:]
+
+	if sp := strings.IndexByte(rest, ' '); sp > 0 {
+		e.Hostname, rest = rest[:sp], rest[sp+1:]
+	}
+	e.Message = rest
+	colon := strings.Index(rest, ": ")
+	if colon <= 0 || strings.ContainsAny(rest[:colon], " \t") {
+		return
+	}
+	tag := rest[:colon]
+	if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+		e.ProcId = tag[open+1 : len(tag)-1]
+		tag = tag[:open]
+	}
+	e.App, e.Message = tag, rest[colon+2:]
+}
+
+// ParseJournal a journald entry, of the json export of journalctl
+//
+//   journalctl --follow --output=json
+func ParseJournal(line []byte) (*Entry, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return nil, fmt.Errorf("invalid journal entry: %v", err)
+	}
+	field := func(name string) string {
+		switch v := fields[name].(type) {
+		case string:
+			return v
+		case []interface{}:
+			// binary fields are arrays of bytes
+			b := make([]byte, 0, len(v))
+			for _, c := range v {
+				if f, ok := c.(float64); ok {
+					b = append(b, byte(f))
+				}
+			}
+			return string(b)
+		}
+		return ""
+	}
+	e := &Entry{
+		Priority: defaultPriority,
+		Hostname: field("_HOSTNAME"),
+		App:      field("SYSLOG_IDENTIFIER"),
+		ProcId:   field("_PID"),
+		MsgId:    field("MESSAGE_ID"),
+		Message:  field("MESSAGE"),
+	}
+	if e.App == "" {
+		e.App = field("_COMM")
+	}
+	if usec, err := strconv.ParseInt(field("__REALTIME_TIMESTAMP"), 10, 64); err == nil {
+		e.Timestamp = time.Unix(usec/1e6, usec%1e6*1e3)
+	} else {
+		e.Timestamp = time.Now()
+	}
+	severity, err := strconv.Atoi(field("PRIORITY"))
+	if err != nil || severity < 0 || severity > 7 {
+		severity = defaultPriority % 8
+	}
+	facility, err := strconv.Atoi(field("SYSLOG_FACILITY"))
+	if err != nil || facility < 0 || facility >= len(facilities) {
+		facility = defaultPriority / 8
+	}
+	e.Priority = facility*8 + severity
+	return e, nil
+}
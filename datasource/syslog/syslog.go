@@ -0,0 +1,479 @@
+// Syslog package implements a streaming Datasource of the syslog messages
+// received by listeners (udp, tcp) and of journald entries, for continuous
+// queries (see exec.ContinuousQuery) and filters of the messages as they
+// arrive.
+package syslog
+
+import (
+	"bufio"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+const (
+	// SourceType the source type of syslog sources in the config
+	SourceType = "syslog"
+
+	// JournalTable the table of journald entries
+	JournalTable = "journald"
+)
+
+var (
+	_ = u.EMPTY
+
+	// MaxMessageSize the longest message read, longer messages of tcp are
+	// truncated
+	MaxMessageSize = 64 * 1024
+
+	// Columns the columns of the tables, of each message
+	Columns = []string{"timestamp", "priority", "facility", "severity", "hostname",
+		"app", "procid", "msgid", "structured_data", "message", "remote"}
+
+	// journalctl the default command of the journald entries
+	journalctl = []string{"journalctl", "--follow", "--output=json", "--lines=0"}
+
+	// Different Features of this Syslog Data Source
+	_ schema.Source            = (*SyslogSource)(nil)
+	_ datasource.MeteredSource = (*SyslogSource)(nil)
+	_ schema.SourceSetup       = (*SyslogSource)(nil)
+	_ schema.SourceTableSchema = (*SyslogSource)(nil)
+	_ schema.SourceChanges     = (*SyslogSource)(nil)
+	_ schema.ConnScanner       = (*syslogConn)(nil)
+	_ schema.ConnColumns       = (*syslogConn)(nil)
+	_ schema.IteratorErr       = (*syslogConn)(nil)
+)
+
+// SyslogSource a DataSource of the syslog messages received on its listen
+// addresses, as a streaming table (whose scans block for the next message
+// until closed), and of the entries of journald as the journald table.
+//
+//   "settings" : {
+//       "listen"     : ["udp://:514", "tcp://:601"],
+//       "table"      : "syslog",       // of the messages of the listeners
+//       "journald"   : true,           // the journald table
+//       "journalctl" : ["journalctl", "--follow", "--output=json", "--lines=0"]
+//   }
+//
+// Messages of RFC 5424 and RFC 3164 are parsed into the columns (timestamp,
+// priority, facility, severity, hostname, app, procid, msgid,
+// structured_data, message, remote), tcp messages are newline or octet
+// counting framed.  Messages are only sent to the scans open when they
+// arrive, so with no query running they are dropped, ie
+//
+//   SELECT hostname, app, message FROM syslog
+//   WHERE severity IN ("emerg", "alert", "crit", "err")
+type SyslogSource struct {
+	mu        sync.Mutex
+	table     string
+	names     []string
+	feed      *datasource.ChangeFeed
+	listeners []io.Closer
+	tcpConns  map[net.Conn]struct{}
+	journal   *exec.Cmd
+	subs      map[schema.Subscription]struct{} // of the open scans
+	closed    bool
+	ct        uint64
+	metrics   datasource.Metrics
+}
+
+// syslogConn a subscription to the messages of a table, as rows
+type syslogConn struct {
+	src *SyslogSource
+	sub schema.Subscription
+	tbl *schema.Table
+}
+
+// NewSyslogSource a SyslogSource of the settings of a source config,
+// listening on its addresses
+func NewSyslogSource(settings u.JsonHelper) (*SyslogSource, error) {
+	m := &SyslogSource{}
+	if err := m.load(settings); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Setup listen on the "listen" addresses (or read the journal if "journald")
+// of the source config into its "table", of a SyslogSource{} registered
+// without settings
+func (m *SyslogSource) Setup(ss *schema.SchemaSource) error {
+	m.mu.Lock()
+	loaded := m.feed != nil
+	m.mu.Unlock()
+	return datasource.SetupSettings(ss, loaded, m.load)
+}
+
+func (m *SyslogSource) load(settings u.JsonHelper) error {
+	table := strings.ToLower(settings.String("table"))
+	if table == "" {
+		table = "syslog"
+	}
+	addrs := settings.Strings("listen")
+	if addr := settings.String("listen"); addr != "" && len(addrs) == 0 {
+		addrs = []string{addr}
+	}
+	journald, _ := settings.BoolSafe("journald")
+	if len(addrs) == 0 && !journald {
+		return fmt.Errorf("syslog source requires listen addresses or journald in settings")
+	}
+
+	m.mu.Lock()
+	m.table = table
+	m.feed = datasource.NewChangeFeed()
+	m.tcpConns = make(map[net.Conn]struct{})
+	m.subs = make(map[schema.Subscription]struct{})
+	m.mu.Unlock()
+
+	for _, addr := range addrs {
+		if err := m.listen(addr); err != nil {
+			m.Close()
+			return err
+		}
+	}
+	names := make([]string, 0, 2)
+	if len(addrs) > 0 {
+		names = append(names, table)
+	}
+	if journald {
+		cmd := settings.Strings("journalctl")
+		if len(cmd) == 0 {
+			cmd = journalctl
+		}
+		if err := m.readJournal(cmd); err != nil {
+			m.Close()
+			return err
+		}
+		names = append(names, JournalTable)
+	}
+	m.mu.Lock()
+	m.names = names
+	m.mu.Unlock()
+	return nil
+}
+
+// listen for the messages of addr, of udp://host:port or tcp://host:port
+func (m *SyslogSource) listen(addr string) error {
+	network, hostport := "udp", addr
+	if i := strings.Index(addr, "://"); i > 0 {
+		network, hostport = addr[:i], addr[i+3:]
+	}
+	switch network {
+	case "udp", "udp4", "udp6":
+		pc, err := net.ListenPacket(network, hostport)
+		if err != nil {
+			return fmt.Errorf("could not listen on %s: %v", addr, err)
+		}
+		m.addListener(pc)
+		go m.readPackets(pc)
+	case "tcp", "tcp4", "tcp6":
+		l, err := net.Listen(network, hostport)
+		if err != nil {
+			return fmt.Errorf("could not listen on %s: %v", addr, err)
+		}
+		m.addListener(l)
+		go m.accept(l)
+	default:
+		return fmt.Errorf("unsupported syslog listen address %q", addr)
+	}
+	return nil
+}
+
+func (m *SyslogSource) addListener(l io.Closer) {
+	m.mu.Lock()
+	m.listeners = append(m.listeners, l)
+	m.mu.Unlock()
+}
+
+// Addrs the addresses listened on, ie of the ports of ":0" addresses
+func (m *SyslogSource) Addrs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	addrs := make([]string, 0, len(m.listeners))
+	for _, l := range m.listeners {
+		switch l := l.(type) {
+		case net.PacketConn:
+			addrs = append(addrs, l.LocalAddr().Network()+"://"+l.LocalAddr().String())
+		case net.Listener:
+			addrs = append(addrs, l.Addr().Network()+"://"+l.Addr().String())
+		}
+	}
+	return addrs
+}
+
+// readPackets a message per datagram, until the conn is closed
+func (m *SyslogSource) readPackets(pc net.PacketConn) {
+	buf := make([]byte, MaxMessageSize)
+	for {
+		n, from, err := pc.ReadFrom(buf)
+		if err != nil {
+			if !m.isClosed() {
+				u.Errorf("syslog read error: %v", err)
+			}
+			return
+		}
+		e := Parse(string(buf[:n]), time.Now())
+		e.Remote = from.String()
+		m.publish(m.table, e)
+	}
+}
+
+func (m *SyslogSource) accept(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if !m.isClosed() {
+				u.Errorf("syslog accept error: %v", err)
+			}
+			return
+		}
+		m.mu.Lock()
+		m.tcpConns[conn] = struct{}{}
+		m.mu.Unlock()
+		go m.readStream(conn)
+	}
+}
+
+// readStream the messages of a tcp conn, each of a line, or prefixed by
+// its length (octet counting of RFC 6587)
+func (m *SyslogSource) readStream(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		m.mu.Lock()
+		delete(m.tcpConns, conn)
+		m.mu.Unlock()
+	}()
+	remote := conn.RemoteAddr().String()
+	r := bufio.NewReaderSize(conn, 4096)
+	for {
+		msg, err := readFrame(r)
+		if len(msg) > 0 {
+			e := Parse(msg, time.Now())
+			e.Remote = remote
+			m.publish(m.table, e)
+		}
+		if err != nil {
+			if err != io.EOF && !m.isClosed() {
+				u.Warnf("syslog connection from %s: %v", remote, err)
+			}
+			return
+		}
+	}
+}
+
+// readFrame the next message of r
+func readFrame(r *bufio.Reader) (string, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return "", err
+	}
+	if first[0] < '0' || first[0] > '9' {
+		line, err := r.ReadString('\n')
+		if len(line) > MaxMessageSize {
+			line = line[:MaxMessageSize]
+		}
+		return line, err
+	}
+	count, err := r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("invalid message length %q", count)
+	}
+	size := n
+	if size > MaxMessageSize {
+		size = MaxMessageSize
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	if n > size {
+		if _, err := r.Discard(n - size); err != nil {
+			return string(buf), err
+		}
+	}
+	return string(buf), nil
+}
+
+// readJournal the entries of the json export of cmd, ie journalctl
+func (m *SyslogSource) readJournal(args []string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start %s: %v", args[0], err)
+	}
+	m.mu.Lock()
+	m.journal = cmd
+	m.mu.Unlock()
+	go func() {
+		scanner := bufio.NewScanner(out)
+		scanner.Buffer(make([]byte, 4096), MaxMessageSize)
+		for scanner.Scan() {
+			e, err := ParseJournal(scanner.Bytes())
+			if err != nil {
+				u.Warnf("%v", err)
+				continue
+			}
+			m.publish(JournalTable, e)
+		}
+		if err := cmd.Wait(); err != nil && !m.isClosed() {
+			u.Errorf("%s exited: %v", args[0], err)
+		}
+	}()
+	return nil
+}
+
+// publish e to the scans of table
+func (m *SyslogSource) publish(table string, e *Entry) {
+	m.feed.Publish(&schema.Change{Op: schema.ChangeInsert, Table: table, Row: e.row(), Ts: e.Timestamp})
+}
+
+func (e *Entry) row() []driver.Value {
+	return []driver.Value{e.Timestamp, int64(e.Priority), e.Facility(), e.Severity(), e.Hostname,
+		e.App, e.ProcId, e.MsgId, e.StructuredData, e.Message, e.Remote}
+}
+
+func (m *SyslogSource) isClosed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+func (m *SyslogSource) Tables() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.names
+}
+
+func (m *SyslogSource) hasTable(table string) bool {
+	for _, name := range m.Tables() {
+		if name == strings.ToLower(table) {
+			return true
+		}
+	}
+	return false
+}
+
+// Table the columns of the messages, the same for every table
+func (m *SyslogSource) Table(table string) (*schema.Table, error) {
+	if !m.hasTable(table) {
+		return nil, schema.ErrNotFound
+	}
+	tbl := schema.NewTable(strings.ToLower(table))
+	for i, col := range Columns {
+		typ := value.StringType
+		switch i {
+		case 0:
+			typ = value.TimeType
+		case 1:
+			typ = value.IntType
+		}
+		tbl.AddField(schema.NewFieldBase(col, typ, 255, typ.String()))
+	}
+	tbl.SetColumns(Columns)
+	return tbl, nil
+}
+
+// Subscribe to the messages of table, as changes of inserts of their rows
+func (m *SyslogSource) Subscribe(table string) (schema.Subscription, error) {
+	if !m.hasTable(table) {
+		return nil, schema.ErrNotFound
+	}
+	if m.isClosed() {
+		return nil, fmt.Errorf("syslog source is closed")
+	}
+	return m.feed.Subscribe(table), nil
+}
+
+// Open a scan of the messages of table as they arrive, it blocks for the
+// next message until the conn (or source) is closed
+func (m *SyslogSource) Open(table string) (schema.Conn, error) {
+	tbl, err := m.Table(table)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := m.Subscribe(table)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.subs[sub] = struct{}{}
+	m.mu.Unlock()
+	return &syslogConn{src: m, sub: sub, tbl: tbl}, nil
+}
+
+// Close stop listening, and end the open scans
+func (m *SyslogSource) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	listeners, journal := m.listeners, m.journal
+	conns := make([]net.Conn, 0, len(m.tcpConns))
+	for conn := range m.tcpConns {
+		conns = append(conns, conn)
+	}
+	subs := make([]schema.Subscription, 0, len(m.subs))
+	for sub := range m.subs {
+		subs = append(subs, sub)
+	}
+	m.mu.Unlock()
+
+	for _, l := range listeners {
+		l.Close()
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	if journal != nil && journal.Process != nil {
+		journal.Process.Kill()
+	}
+	for _, sub := range subs {
+		sub.Close()
+	}
+	return nil
+}
+
+func (m *SyslogSource) Metrics() *datasource.Metrics { return &m.metrics }
+
+func (m *syslogConn) Columns() []string { return m.tbl.Columns() }
+
+// Err the error that ended the scan, ie it fell behind the messages
+func (m *syslogConn) Err() error { return m.sub.Err() }
+
+func (m *syslogConn) Next() schema.Message {
+	change, ok := <-m.sub.Changes()
+	if !ok {
+		return nil
+	}
+	id := atomic.AddUint64(&m.src.ct, 1)
+	return datasource.NewSqlDriverMessageMap(id, change.Row, m.tbl.FieldPositions)
+}
+
+func (m *syslogConn) Close() error {
+	m.src.mu.Lock()
+	delete(m.src.subs, m.sub)
+	m.src.mu.Unlock()
+	return m.sub.Close()
+}
@@ -0,0 +1,145 @@
+package syslog_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	u "github.com/araddon/gou"
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/syslog"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2016, 5, 1, 13, 0, 0, 0, time.UTC)
+	tests := []struct {
+		line string
+		e    syslog.Entry
+		fac  string
+		sev  string
+	}{
+		{`<165>1 2016-05-01T12:00:00.003Z host.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventID="1011"][b@1 x="]\""] An application event`,
+			syslog.Entry{Timestamp: time.Date(2016, 5, 1, 12, 0, 0, 3e6, time.UTC), Priority: 165, Hostname: "host.example.com",
+				App: "evntslog", MsgId: "ID47", StructuredData: `[exampleSDID@32473 iut="3" eventID="1011"][b@1 x="]\""]`,
+				Message: "An application event"}, "local4", "notice"},
+		{"<34>1 2016-05-01T12:00:00Z mymachine su 42 - - \xef\xbb\xbf'su root' failed\n",
+			syslog.Entry{Timestamp: time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC), Priority: 34, Hostname: "mymachine",
+				App: "su", ProcId: "42", Message: "'su root' failed"}, "auth", "crit"},
+		{`<13>1 - - - - - -`, syslog.Entry{Timestamp: now, Priority: 13}, "user", "notice"},
+		{`<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick`,
+			syslog.Entry{Timestamp: time.Date(2015, 10, 11, 22, 14, 15, 0, time.UTC), Priority: 34, Hostname: "mymachine",
+				App: "su", ProcId: "230", Message: "'su root' failed for lonvick"}, "auth", "crit"},
+		{`<0>May  1 12:59:00 gw kernel: oops`,
+			syslog.Entry{Timestamp: time.Date(2016, 5, 1, 12, 59, 0, 0, time.UTC), Priority: 0, Hostname: "gw",
+				App: "kernel", Message: "oops"}, "kern", "emerg"},
+		{`<191>no header at all`, syslog.Entry{Timestamp: now, Priority: 191, Message: "no header at all"}, "local7", "debug"},
+		{`plain line`, syslog.Entry{Timestamp: now, Priority: 13, Message: "plain line"}, "user", "notice"},
+	}
+	for _, tt := range tests {
+		e := syslog.Parse(tt.line, now)
+		assert.Equalf(t, tt.e, *e, "parse of %q", tt.line)
+		assert.Equal(t, tt.fac, e.Facility())
+		assert.Equal(t, tt.sev, e.Severity())
+	}
+
+	e, err := syslog.ParseJournal([]byte(`{"__REALTIME_TIMESTAMP":"1462104000000123","PRIORITY":"3",` +
+		`"SYSLOG_FACILITY":"4","_HOSTNAME":"gw","SYSLOG_IDENTIFIER":"sshd","_PID":"77","MESSAGE":[104,105]}`))
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, syslog.Entry{Timestamp: time.Unix(1462104000, 123000), Priority: 35, Hostname: "gw",
+		App: "sshd", ProcId: "77", Message: "hi"}, *e)
+	_, err = syslog.ParseJournal([]byte(`not json`))
+	assert.T(t, err != nil)
+}
+
+func TestSyslogSource(t *testing.T) {
+	src, err := syslog.NewSyslogSource(u.JsonHelper{"listen": []interface{}{"udp://127.0.0.1:0", "tcp://127.0.0.1:0"},
+		"table": "logs", "journald": true, "journalctl": []interface{}{"cat", "/dev/null"}})
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"logs", "journald"}, src.Tables())
+	tbl, err := src.Table("logs")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, syslog.Columns, tbl.Columns())
+	addrs := src.Addrs()
+	assert.Equal(t, 2, len(addrs))
+
+	conn, err := src.Open("logs")
+	assert.Tf(t, err == nil, "no error %v", err)
+	scanner := conn.(schema.ConnScanner)
+	next := func() string {
+		msg := scanner.Next()
+		if msg == nil {
+			return "<nil>"
+		}
+		vals := msg.Body().(*datasource.SqlDriverMessageMap).Values()
+		return fmt.Sprintf("%v %v %v %v", vals[2], vals[3], vals[5], vals[9])
+	}
+
+	udp, err := net.Dial("udp", strings.TrimPrefix(addrs[0], "udp://"))
+	assert.Tf(t, err == nil, "no error %v", err)
+	defer udp.Close()
+	udp.Write([]byte(`<34>1 2016-05-01T12:00:00Z gw su 42 - - failed`))
+	assert.Equal(t, "auth crit su failed", next())
+
+	tcp, err := net.Dial("tcp", strings.TrimPrefix(addrs[1], "tcp://"))
+	assert.Tf(t, err == nil, "no error %v", err)
+	defer tcp.Close()
+	// newline and octet counted frames
+	msg := "<14>1 - gw app - - - second\nthird line"
+	fmt.Fprintf(tcp, "<11>May  1 12:00:00 gw cron[1]: first\n%d %s", len(msg), msg)
+	assert.Equal(t, "user err cron first", next())
+	assert.Equal(t, "user info app second\nthird line", next())
+
+	// closing the source ends the scans
+	go src.Close()
+	assert.Equal(t, "<nil>", next())
+	assert.T(t, conn.Close() == nil)
+	_, err = src.Open("logs")
+	assert.T(t, err != nil)
+
+	_, err = syslog.NewSyslogSource(u.JsonHelper{})
+	assert.T(t, err != nil)
+	_, err = syslog.NewSyslogSource(u.JsonHelper{"listen": "unix://nope"})
+	assert.T(t, err != nil)
+}
+
+func TestSyslogContinuousQuery(t *testing.T) {
+	src, err := syslog.NewSyslogSource(u.JsonHelper{"listen": "udp://127.0.0.1:0"})
+	assert.Tf(t, err == nil, "no error %v", err)
+	defer src.Close()
+	sch := datasource.RegisterSchemaSource("syslog_alerts", "syslog_alerts", src)
+
+	ctx := plan.NewContext(`SELECT hostname, app, message FROM syslog WHERE severity IN ("emerg", "alert", "crit", "err")`)
+	ctx.Schema = sch
+	q, err := exec.NewContinuousQuery(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	defer q.Close()
+
+	udp, err := net.Dial("udp", strings.TrimPrefix(src.Addrs()[0], "udp://"))
+	assert.Tf(t, err == nil, "no error %v", err)
+	defer udp.Close()
+	// sent until the query is subscribed, udp messages to no scans are dropped
+	done := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+				udp.Write([]byte(`<30>May  1 12:00:00 web nginx: started`))
+				udp.Write([]byte(`<27>May  1 12:00:01 db postgres[9]: disk full`))
+			}
+		}
+	}()
+	row := make([]driver.Value, 3)
+	assert.T(t, q.Next(row) == nil)
+	close(done)
+	assert.Equal(t, []driver.Value{"db", "postgres", "disk full"}, row)
+}
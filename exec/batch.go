@@ -0,0 +1,96 @@
+package exec
+
+import (
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner     = (*Unbatch)(nil)
+	_ schema.Message = (*RowBatch)(nil)
+)
+
+// RowBatch is a batch of rows sent between operators as a single message,
+// amortizing the channel send (and select) of each row across the batch.
+// Sources send batches of RowBatchSize rows to the operators that support
+// them (where, projection), evaluating each row of batch in a single call.
+//
+//   source -> [batch] -> where -> [batch] -> projection -> [batch] -> unbatch -> groupby
+//
+type RowBatch struct {
+	Rows []schema.Message
+}
+
+// NewRowBatch a batch with capacity for n rows
+func NewRowBatch(n int) *RowBatch {
+	return &RowBatch{Rows: make([]schema.Message, 0, n)}
+}
+
+// Id of the first row of batch
+func (m *RowBatch) Id() uint64 {
+	if len(m.Rows) == 0 {
+		return 0
+	}
+	return m.Rows[0].Id()
+}
+func (m *RowBatch) Body() interface{} { return m.Rows }
+func (m *RowBatch) Len() int          { return len(m.Rows) }
+
+// RowBatchSize number of rows per batch sent by sources, <= 1 for sending
+// single rows (no batches).
+func RowBatchSize(ctx *plan.Context) int {
+	if ctx == nil {
+		return 1
+	}
+	return ctx.RowBatchSize
+}
+
+// Unbatch splits batches back into single rows for the operators that read
+// one row at a time, other messages are passed on as is.
+type Unbatch struct {
+	*TaskBase
+}
+
+// NewUnbatch create a task to split batches into rows
+func NewUnbatch(ctx *plan.Context) *Unbatch {
+	return &Unbatch{TaskBase: NewTaskBaseNamed(ctx, "unbatch")}
+}
+
+func (m *Unbatch) Run() error {
+	defer m.Ctx.Recover()
+	defer close(m.msgOutCh)
+
+	outCh := m.MessageOut()
+	inCh := m.MessageIn()
+	for {
+		select {
+		case <-m.SigChan():
+			return nil
+		case msg, ok := <-inCh:
+			if !ok {
+				return nil
+			}
+			batch, isBatch := msg.(*RowBatch)
+			if !isBatch {
+				select {
+				case outCh <- msg:
+				case <-m.SigChan():
+					return nil
+				}
+				continue
+			}
+			for _, row := range batch.Rows {
+				select {
+				case outCh <- row:
+				case <-m.SigChan():
+					return nil
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package exec_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// scanSource opens a new scan of its rows for each query, so a query that
+// stops early (limit) does not leave a cursor part way through for the next
+type scanSource struct {
+	*membtree.StaticDataSource
+	rows [][]driver.Value
+}
+
+func (m *scanSource) Open(name string) (schema.Conn, error) {
+	return membtree.NewStaticDataSource("numbers", 0, m.rows, m.Columns()), nil
+}
+
+var scanSchema = func() *schema.Schema {
+	rows := make([][]driver.Value, 10000)
+	for i := range rows {
+		rows[i] = []driver.Value{fmt.Sprintf("%05d", i), int64(i), fmt.Sprintf("g%02d", i%100)}
+	}
+	src := &scanSource{StaticDataSource: membtree.NewStaticDataSource("numbers", 0, rows, []string{"id", "n", "g"}), rows: rows}
+	return datasource.RegisterSchemaSource("scans", "scans", src)
+}()
+
+func TestRowBatch(t *testing.T) {
+	tests := []struct {
+		sql     string
+		cols    []string
+		sorted  bool // rows are compared in order they are returned
+		unbatch bool // expect batches to be split for a per-row operator
+		count   bool // compare number of rows, which rows are returned is arbitrary
+	}{
+		{`SELECT n, g FROM numbers WHERE n < 250 AND g != "g07"`, []string{"n", "g"}, false, false, false},
+		{`SELECT n, g FROM numbers WHERE n > 9990`, []string{"n", "g"}, false, false, false},
+		{`SELECT n FROM numbers WHERE n = 12345`, []string{"n"}, false, false, false},
+		// limit reached part way through a batch
+		{`SELECT n FROM numbers LIMIT 37`, []string{"n"}, false, false, true},
+		{`SELECT n FROM numbers WHERE n < 100 LIMIT 32`, []string{"n"}, false, false, true},
+		{`SELECT g, count(*) AS ct FROM numbers WHERE n < 500 GROUP BY g`, []string{"g", "ct"}, false, true, false},
+		{`SELECT n FROM numbers WHERE n < 300 ORDER BY n DESC LIMIT 5`, []string{"n"}, true, true, false},
+		{`SELECT DISTINCT g FROM numbers WHERE n < 1000`, []string{"g"}, false, true, false},
+	}
+	run := func(sql string, cols []string, batchSize int) (string, bool) {
+		ctx := plan.NewContext(sql)
+		ctx.Schema = scanSchema
+		ctx.RowBatchSize = batchSize
+
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v for %s", err, sql)
+		rows := exec.NewResultRows(ctx, cols)
+		job.RootTask.Add(rows)
+		assert.T(t, job.Setup() == nil)
+		unbatch := findTask(job.RootTask, func(t exec.Task) bool {
+			_, ok := t.(*exec.Unbatch)
+			return ok
+		}) != nil
+		go job.Run()
+
+		got := make([]string, 0)
+		for {
+			dest := make([]driver.Value, len(cols))
+			if rows.Next(dest) != nil {
+				break
+			}
+			vals := make([]string, len(dest))
+			for i, v := range dest {
+				vals[i] = fmt.Sprintf("%v", v)
+			}
+			got = append(got, strings.Join(vals, ":"))
+		}
+		job.Close()
+		return strings.Join(got, ","), unbatch
+	}
+	for _, tt := range tests {
+		want, unbatch := run(tt.sql, tt.cols, 0)
+		assert.Tf(t, !unbatch, "no batches by default %s", tt.sql)
+		got, unbatch := run(tt.sql, tt.cols, 16)
+		assert.Equalf(t, tt.unbatch, unbatch, "unbatch for %s", tt.sql)
+		switch {
+		case tt.count:
+			want, got = fmt.Sprintf("%d rows", len(strings.Split(want, ","))), fmt.Sprintf("%d rows", len(strings.Split(got, ",")))
+		case !tt.sorted:
+			want, got = sortedRows(want), sortedRows(got)
+		}
+		assert.Equalf(t, want, got, "batched rows for %s", tt.sql)
+	}
+}
+
+func sortedRows(rows string) string {
+	vals := strings.Split(rows, ",")
+	sort.Strings(vals)
+	return strings.Join(vals, ",")
+}
+
+// ackConn a scan that records the id of the last row scanned, and the last
+// id acked
+type ackConn struct {
+	*membtree.StaticDataSource
+	mu      sync.Mutex
+	scanned uint64
+	acked   uint64
+}
+
+func (m *ackConn) Next() schema.Message {
+	msg := m.StaticDataSource.Next()
+	if msg != nil {
+		m.mu.Lock()
+		m.scanned = msg.Id()
+		m.mu.Unlock()
+	}
+	return msg
+}
+func (m *ackConn) Ack(id uint64) error {
+	m.mu.Lock()
+	m.acked = id
+	m.mu.Unlock()
+	return nil
+}
+func (m *ackConn) Nack() error { return nil }
+
+type ackSource struct {
+	*scanSource
+	conn *ackConn
+}
+
+func (m *ackSource) Open(name string) (schema.Conn, error) {
+	m.conn = &ackConn{StaticDataSource: membtree.NewStaticDataSource("acked", 0, m.rows, m.Columns())}
+	return m.conn, nil
+}
+
+func TestRowBatchAck(t *testing.T) {
+	vals := make([][]driver.Value, 1000)
+	for i := range vals {
+		vals[i] = []driver.Value{fmt.Sprintf("%05d", i), int64(i), fmt.Sprintf("g%02d", i%100)}
+	}
+	src := &ackSource{scanSource: &scanSource{
+		StaticDataSource: membtree.NewStaticDataSource("acked", 0, vals, []string{"id", "n", "g"}),
+		rows:             vals,
+	}}
+	acked := datasource.RegisterSchemaSource("acks", "acks", src)
+
+	// the where filters the rows of each batch, the last row scanned is
+	// acked not the last one left of the batch
+	sql := `SELECT n, g FROM acked WHERE n < 250 AND g != "g07"`
+	ctx := plan.NewContext(sql)
+	ctx.Schema = acked
+	ctx.RowBatchSize = 16
+
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	rows := exec.NewResultRows(ctx, []string{"n", "g"})
+	job.RootTask.Add(rows)
+	assert.T(t, job.Setup() == nil)
+	go job.Run()
+	ct := 0
+	for {
+		dest := make([]driver.Value, 2)
+		if rows.Next(dest) != nil {
+			break
+		}
+		ct++
+	}
+	job.Close()
+	assert.Equal(t, 247, ct)
+	src.conn.mu.Lock()
+	defer src.conn.mu.Unlock()
+	assert.NotEqual(t, uint64(0), src.conn.scanned)
+	assert.Equal(t, src.conn.scanned, src.conn.acked)
+}
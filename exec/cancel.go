@@ -0,0 +1,71 @@
+package exec
+
+import (
+	"time"
+
+	u "github.com/araddon/gou"
+	"golang.org/x/net/context"
+)
+
+var _ = u.EMPTY
+
+// quitTasks signal each task of the dag to quit.  Every operator watches
+// its SigChan while reading or sending rows, and the sources close their
+// connections once the dag shuts down.
+func quitTasks(t Task) {
+	for _, child := range t.Children() {
+		quitTasks(child)
+	}
+	if tr, ok := t.(TaskRunner); ok {
+		tr.Quit()
+	}
+}
+
+// watchCancel quit all tasks of the job when the Context of the query is
+// cancelled (client went away, Ctrl-C) or its Timeout passes.  Returns a
+// func to stop watching once the job is done, which returns the error of
+// the cancel, nil if the job was not cancelled.
+//
+//   ctx.Done()  ->
+//                   quitTasks(root) -> sources, joins ... return -> Close()
+//   Timeout     ->
+func (m *JobExecutor) watchCancel() func() error {
+	var done <-chan struct{}
+	var timeout <-chan time.Time
+	var timer *time.Timer
+	if m.Ctx != nil && m.Ctx.Context != nil {
+		done = m.Ctx.Context.Done()
+	}
+	if m.Ctx != nil && m.Ctx.Timeout > 0 {
+		timer = time.NewTimer(m.Ctx.Timeout)
+		timeout = timer.C
+	}
+	if done == nil && timeout == nil {
+		return func() error { return nil }
+	}
+
+	stopCh := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		var err error
+		select {
+		case <-done:
+			err = m.Ctx.Context.Err()
+		case <-timeout:
+			err = context.DeadlineExceeded
+		case <-stopCh:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			u.Debugf("cancel query: %v", err)
+			quitTasks(m.RootTask)
+		}
+		errCh <- err
+	}()
+	return func() error {
+		close(stopCh)
+		return <-errCh
+	}
+}
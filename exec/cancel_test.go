@@ -0,0 +1,99 @@
+package exec_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"golang.org/x/net/context"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// endlessSource a table whose scans never end, counting its open and
+// closed connections
+type endlessSource struct {
+	*membtree.StaticDataSource
+	opened int32
+	closed int32
+}
+
+type endlessConn struct {
+	*membtree.StaticDataSource
+	src *endlessSource
+	ct  int64
+}
+
+func (m *endlessSource) Open(name string) (schema.Conn, error) {
+	atomic.AddInt32(&m.opened, 1)
+	return &endlessConn{StaticDataSource: m.StaticDataSource, src: m}, nil
+}
+
+func (m *endlessConn) Next() schema.Message {
+	m.ct++
+	vals := []driver.Value{fmt.Sprintf("%d", m.ct), m.ct % 100}
+	return datasource.NewSqlDriverMessageMap(uint64(m.ct), vals, map[string]int{"id": 0, "n": 1})
+}
+
+func (m *endlessConn) Close() error {
+	atomic.AddInt32(&m.src.closed, 1)
+	return nil
+}
+
+func TestCancel(t *testing.T) {
+	src := &endlessSource{StaticDataSource: membtree.NewStaticDataSource("endless", 0, nil, []string{"id", "n"})}
+	endless := datasource.RegisterSchemaSource("endless", "endless", src)
+
+	tests := []struct {
+		sql     string
+		timeout bool // statement timeout, else context cancelled
+	}{
+		{`SELECT n FROM endless WHERE n > 5`, false},
+		{`SELECT n FROM endless WHERE n > 5`, true},
+		{`SELECT n, count(*) AS ct FROM endless GROUP BY n`, false},
+		{`SELECT n FROM endless ORDER BY n ASC`, true},
+		{`SELECT a.n FROM endless AS a INNER JOIN endless AS b ON a.id = b.id`, false},
+	}
+	for _, tt := range tests {
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema = endless
+		want := context.DeadlineExceeded
+		if tt.timeout {
+			ctx.Timeout = 50 * time.Millisecond
+		} else {
+			var cancel context.CancelFunc
+			ctx.Context, cancel = context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			want = context.Canceled
+		}
+
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+
+		errCh := make(chan error, 1)
+		start := time.Now()
+		go func() {
+			errCh <- job.Run()
+		}()
+		select {
+		case err = <-errCh:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("query was not stopped %s", tt.sql)
+		}
+		assert.Equalf(t, want, err, "error of %s", tt.sql)
+		assert.Tf(t, time.Since(start) < 2*time.Second, "stopped promptly %s", tt.sql)
+		job.Close()
+	}
+	assert.Tf(t, atomic.LoadInt32(&src.opened) > 0, "expected scans")
+	assert.Equalf(t, atomic.LoadInt32(&src.opened), atomic.LoadInt32(&src.closed), "connections closed")
+}
@@ -29,7 +29,7 @@ type Command struct {
 // NewCommand creates new command exec task
 func NewCommand(ctx *plan.Context, p *plan.Command) *Command {
 	m := &Command{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "command"),
 		p:        p,
 	}
 	return m
@@ -0,0 +1,82 @@
+package exec
+
+import (
+	"database/sql/driver"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/vm"
+)
+
+// computedScanner a scanner adding the values of the computed columns of
+// its table (see schema.Table.AddComputed) to the rows it scans, after the
+// columns of the source
+//
+//   first, last  ->  first, last, join(first, last, " ") AS full_name
+type computedScanner struct {
+	schema.ConnScanner
+	fields   []*schema.Field
+	width    int            // columns of the rows of the source
+	colIndex map[string]int // of the source rows, and the computed columns
+}
+
+// newComputedScanner a scanner of the computed columns of p added to the
+// rows of scanner, scanner if none
+func newComputedScanner(p *plan.Source, scanner schema.ConnScanner) schema.ConnScanner {
+	if len(p.Computed) == 0 {
+		return scanner
+	}
+	return &computedScanner{ConnScanner: scanner, fields: p.Computed, width: -1}
+}
+
+func (m *computedScanner) Next() schema.Message {
+	msg := m.ConnScanner.Next()
+	if msg == nil {
+		return nil
+	}
+	switch mt := msg.(type) {
+	case *datasource.SqlDriverMessageMap:
+		return m.compute(mt)
+	case *datasource.ContextSimple:
+		for _, f := range m.fields {
+			if v, ok := vm.Eval(mt, f.ComputedExpr()); ok {
+				mt.Data[f.Name] = v
+			}
+		}
+	default:
+		u.Warnf("can not compute columns of message %T", msg)
+	}
+	return msg
+}
+
+// compute the row of msg, with the values of the computed columns appended
+func (m *computedScanner) compute(msg *datasource.SqlDriverMessageMap) schema.Message {
+	if len(msg.Vals) != m.width {
+		m.width = len(msg.Vals)
+		m.colIndex = make(map[string]int, len(msg.ColIndex)+len(m.fields))
+		for name, idx := range msg.ColIndex {
+			m.colIndex[name] = idx
+		}
+		for i, f := range m.fields {
+			m.colIndex[f.Name] = m.width + i
+		}
+	}
+	row := make([]driver.Value, m.width+len(m.fields))
+	copy(row, msg.Vals)
+	out := msg.Copy()
+	out.Vals = row
+	out.ColIndex = m.colIndex
+	for i, f := range m.fields {
+		// computed columns may refer to those before them
+		if v, ok := vm.Eval(out, f.ComputedExpr()); ok && v != nil && !v.Nil() {
+			row[m.width+i] = v.Value()
+		}
+	}
+	return out
+}
+
+// Err the error of the scan of the source
+func (m *computedScanner) Err() error { return scanErr(m.ConnScanner) }
@@ -0,0 +1,83 @@
+package exec_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/value"
+)
+
+func TestExecComputedColumns(t *testing.T) {
+
+	mem := membtree.NewSource()
+	mem.AddTable(membtree.NewStaticDataSource("people", 0, [][]driver.Value{
+		{int64(1), "ada", "lovelace", int64(36)},
+		{int64(2), "alan", "turing", int64(41)},
+		{int64(3), "grace", "hopper", int64(29)},
+	}, []string{"id", "first", "last", "age"}))
+	s := datasource.RegisterSchemaSource("compmem", "compmem", mem)
+
+	tbl, err := s.Table("people")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	assert.Equal(t, nil, tbl.AddComputed("full_name", value.UnknownType, `join(first, last, " ")`))
+	assert.Equal(t, nil, tbl.AddComputed("decade", value.IntType, `age / 10`))
+	assert.Equal(t, nil, tbl.AddComputed("shout", value.UnknownType, `join(full_name, "!", "")`))
+	assert.NotEqual(t, nil, tbl.AddComputed("bad", value.UnknownType, `join(first, middle, " ")`))
+	assert.NotEqual(t, nil, tbl.AddComputed("age", value.IntType, `id + 1`))
+	assert.Equal(t, []string{"id", "first", "last", "age"}, tbl.Columns())
+	f, ok := tbl.Field("full_name")
+	assert.T(t, ok && f.IsComputed())
+	assert.Equal(t, value.StringType, f.Type)
+
+	sqlDb, err := sql.Open("qlbridge", "compmem")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer sqlDb.Close()
+
+	strs := func(q string) []string {
+		rows, err := sqlDb.Query(q)
+		assert.Tf(t, err == nil, "error: %v %s", err, q)
+		defer rows.Close()
+		vals := make([]string, 0)
+		for rows.Next() {
+			var val string
+			assert.T(t, rows.Scan(&val) == nil)
+			vals = append(vals, val)
+		}
+		return vals
+	}
+	assert.Equal(t, []string{"ada lovelace", "alan turing", "grace hopper"},
+		strs(`SELECT full_name FROM people`))
+	assert.Equal(t, []string{"alan turing!"}, strs(`SELECT shout FROM people WHERE decade >= 4`))
+	assert.Equal(t, []string{"grace"}, strs(`SELECT first FROM people WHERE full_name = "grace hopper"`))
+	assert.Equal(t, []string{"grace hopper", "alan turing", "ada lovelace"},
+		strs(`SELECT full_name FROM people ORDER BY full_name DESC`))
+
+	rows, err := sqlDb.Query(`SELECT id, full_name, decade FROM people WHERE id = 1`)
+	assert.Tf(t, err == nil, "error: %v", err)
+	assert.T(t, rows.Next())
+	var id, decade int64
+	var fullName string
+	assert.T(t, rows.Scan(&id, &fullName, &decade) == nil)
+	assert.Equal(t, "ada lovelace", fullName)
+	assert.Equal(t, int64(3), decade)
+	rows.Close()
+
+	// DESCRIBE shows them as generated
+	rows, err = sqlDb.Query(`DESCRIBE people`)
+	assert.Tf(t, err == nil, "error: %v", err)
+	extras := make(map[string]string)
+	for rows.Next() {
+		var field, typ, null, key, def, extra sql.NullString
+		err := rows.Scan(&field, &typ, &null, &key, &def, &extra)
+		assert.Tf(t, err == nil, "error: %v", err)
+		extras[field.String] = extra.String
+	}
+	rows.Close()
+	assert.Equal(t, "VIRTUAL GENERATED", extras["full_name"])
+	assert.Equal(t, "", extras["first"])
+}
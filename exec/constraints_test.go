@@ -0,0 +1,78 @@
+package exec_test
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+)
+
+func TestExecWriteConstraints(t *testing.T) {
+
+	datasource.RegisterSchemaSource("consmem", "consmem", membtree.NewSource())
+
+	sqlDb, err := sql.Open("qlbridge", "consmem")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer sqlDb.Close()
+
+	_, err = sqlDb.Exec(`CREATE TABLE members (
+		id bigint NOT NULL PRIMARY KEY,
+		name varchar(8) NOT NULL,
+		status varchar(16) DEFAULT 'new',
+		note varchar(32)
+	)`)
+	assert.Tf(t, err == nil, "error: %v", err)
+
+	tests := []struct {
+		sql string
+		err string // empty for none
+	}{
+		{`INSERT INTO members (id, name) VALUES (1, "bob")`, ""},
+		{`INSERT INTO members (id, name, status, note) VALUES (2, "alice", "old", NULL)`, ""},
+		{`INSERT INTO members (id) VALUES (3)`, "Error 1364 (HY000): Field 'name' doesn't have a default value"},
+		{`INSERT INTO members (id, name) VALUES (4, NULL)`, "Error 1048 (23000): Column 'name' cannot be null"},
+		{`INSERT INTO members (id, name) VALUES (5, "bartholomew")`, "Error 1406 (22001): Data too long for column 'name' at row 1"},
+		{`INSERT INTO members (id, name) VALUES (6, "ok"), (7, "much too long")`, "Error 1406 (22001): Data too long for column 'name' at row 2"},
+		{`UPDATE members SET name = NULL WHERE id = 1`, "Error 1048 (23000): Column 'name' cannot be null"},
+	}
+	for _, tt := range tests {
+		_, err := sqlDb.Exec(tt.sql)
+		if tt.err == "" {
+			assert.Tf(t, err == nil, "error: %v %s", err, tt.sql)
+			continue
+		}
+		assert.Tf(t, err != nil && strings.Contains(err.Error(), tt.err), "want %q got %v of %s", tt.err, err, tt.sql)
+	}
+
+	rows, err := sqlDb.Query(`SELECT id, status FROM members WHERE id < 3`)
+	assert.Tf(t, err == nil, "error: %v", err)
+	statuses := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var status string
+		assert.T(t, rows.Scan(&id, &status) == nil)
+		statuses[id] = status
+	}
+	rows.Close()
+	assert.Equal(t, map[int64]string{1: "new", 2: "old"}, statuses)
+
+	// the constraints of the columns in information_schema
+	rows, err = sqlDb.Query(`SELECT COLUMN_NAME, IS_NULLABLE, COLUMN_DEFAULT, CHARACTER_MAXIMUM_LENGTH, COLUMN_KEY
+		FROM schema.columns WHERE TABLE_NAME = "members"`)
+	assert.Tf(t, err == nil, "error: %v", err)
+	cols := make([]string, 0)
+	for rows.Next() {
+		var name, nullable string
+		var def, key sql.NullString
+		var maxLen sql.NullInt64
+		assert.T(t, rows.Scan(&name, &nullable, &def, &maxLen, &key) == nil)
+		cols = append(cols, fmt.Sprint(name, " ", nullable, " ", def.String, " ", key.String, " ", maxLen.Int64))
+	}
+	rows.Close()
+	assert.Equal(t, []string{"id NO  PRI 0", "name NO   8", "status YES new  16", "note YES   32"}, cols)
+}
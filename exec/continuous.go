@@ -0,0 +1,119 @@
+package exec
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+)
+
+// ContinuousQuery a SELECT left running against a streaming source (one
+// whose scan blocks for new rows, until its connection is closed), whose
+// result rows are read as they are produced.  Rows are filtered and
+// projected as they arrive, aggregates must be grouped by tumbling windows
+// of event time (see plan.TumbleWindow) which are emitted as each window
+// closes.  With a CheckpointStore on the Context the open windows are
+// checkpointed under CheckpointKey, so a query restarted with the same key
+// resumes them.
+//
+//   ctx.Checkpoints, ctx.CheckpointKey = store, "clicks-per-minute"
+//   q, err := exec.NewContinuousQuery(ctx)
+//   for {
+//       err := q.Next(row)   // blocks until the next window closes
+//   }
+//   q.Close()
+type ContinuousQuery struct {
+	job    *JobExecutor
+	rows   *ResultWriter
+	cols   []string
+	done   chan struct{} // closed when job finishes running
+	runErr error
+	once   sync.Once
+}
+
+// NewContinuousQuery start running the SELECT statement of ctx
+func NewContinuousQuery(ctx *plan.Context) (*ContinuousQuery, error) {
+	// checked before planning, so a rejected query opens no sources
+	stmt, err := rel.ParseSql(ctx.Raw)
+	if err != nil {
+		return nil, err
+	}
+	sqlSelect, ok := stmt.(*rel.SqlSelect)
+	if !ok {
+		return nil, fmt.Errorf("QLBridge: continuous query requires a select statement but got %T", stmt)
+	}
+	if err := continuousSelect(sqlSelect); err != nil {
+		return nil, err
+	}
+	job, err := BuildSqlJob(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := &ContinuousQuery{
+		job:  job,
+		cols: sqlSelect.Columns.AliasedFieldNames(),
+		done: make(chan struct{}),
+	}
+	m.rows = NewResultRows(ctx, m.cols)
+	job.RootTask.Add(m.rows)
+	if err := job.Setup(); err != nil {
+		job.Close()
+		return nil, err
+	}
+	go func() {
+		defer close(m.done)
+		m.runErr = job.Run()
+		if m.runErr != nil {
+			u.Errorf("error on continuous query: %v", m.runErr)
+		}
+	}()
+	return m, nil
+}
+
+// continuousSelect can stmt produce results before its input ends
+func continuousSelect(stmt *rel.SqlSelect) error {
+	if len(stmt.OrderBy) > 0 {
+		return fmt.Errorf("QLBridge: continuous query can not ORDER BY, it never has all rows to sort")
+	}
+	if stmt.IsAggQuery() && plan.WindowOf(stmt) == nil {
+		return fmt.Errorf("QLBridge: continuous query aggregates must GROUP BY a tumble(time, duration) or date_trunc(unit, time) window")
+	}
+	return nil
+}
+
+// Columns the names of the columns of the result rows
+func (m *ContinuousQuery) Columns() []string { return m.cols }
+
+// Next read the next result row into dest, blocking until there is one.
+// io.EOF once the stream has ended and all rows are read.
+func (m *ContinuousQuery) Next(dest []driver.Value) error {
+	err := m.rows.Next(dest)
+	if err != io.EOF {
+		return err
+	}
+	// the result writer only stops when the job is closed
+	m.Close()
+	if m.runErr != nil {
+		return m.runErr
+	}
+	return io.EOF
+}
+
+// Close stop the query and wait for it to finish, its open windows are
+// checkpointed.  Closing the source connection must unblock its scan.
+func (m *ContinuousQuery) Close() error {
+	var err error
+	m.once.Do(func() {
+		// every task quits before the source connection is closed, so the
+		// end of the rows is not mistaken for the end of the stream
+		quitTasks(m.job.RootTask)
+		err = m.job.Close()
+	})
+	<-m.done
+	return err
+}
@@ -0,0 +1,163 @@
+package exec_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// streamSource a table of clicks whose scans block for rows sent on rows,
+// until it is closed
+type streamSource struct {
+	*membtree.StaticDataSource
+	rows chan []driver.Value
+}
+
+type streamConn struct {
+	*membtree.StaticDataSource
+	rows   chan []driver.Value
+	closed chan bool
+	ct     uint64
+}
+
+func (m *streamSource) Open(name string) (schema.Conn, error) {
+	return &streamConn{StaticDataSource: m.StaticDataSource, rows: m.rows, closed: make(chan bool)}, nil
+}
+
+func (m *streamConn) Next() schema.Message {
+	select {
+	case vals, ok := <-m.rows:
+		if !ok {
+			return nil
+		}
+		m.ct++
+		return datasource.NewSqlDriverMessageMap(m.ct, vals, map[string]int{"ts": 0, "url": 1})
+	case <-m.closed:
+		return nil
+	}
+}
+
+func (m *streamConn) Close() error {
+	select {
+	case <-m.closed:
+	default:
+		close(m.closed)
+	}
+	return nil
+}
+
+func TestContinuousQuery(t *testing.T) {
+	src := &streamSource{StaticDataSource: membtree.NewStaticDataSource("clicks", 0, nil, []string{"ts", "url"})}
+	clicks := datasource.RegisterSchemaSource("clicks", "clicks", src)
+	store := plan.NewMemCheckpoints()
+	start := func(sql string) (*exec.ContinuousQuery, error) {
+		src.rows = make(chan []driver.Value, 10)
+		ctx := plan.NewContext(sql)
+		ctx.Schema = clicks
+		ctx.Checkpoints = store
+		ctx.CheckpointKey = "clicks-per-minute"
+		return exec.NewContinuousQuery(ctx)
+	}
+	send := func(ts int64, url string) {
+		src.rows <- []driver.Value{ts, url}
+	}
+	read := func(q *exec.ContinuousQuery, n int) string {
+		rows := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			row := make([]driver.Value, 3)
+			err := q.Next(row)
+			assert.Tf(t, err == nil, "no error %v", err)
+			rows = append(rows, fmt.Sprintf("%d:%v:%v", row[0].(time.Time).Unix(), row[1], row[2]))
+		}
+		sort.Strings(rows)
+		return strings.Join(rows, ",")
+	}
+
+	sql := `SELECT tumble(ts, "1m") AS minute, url, count(*) AS ct FROM clicks GROUP BY tumble(ts, "1m"), url`
+	q, err := start(sql)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"minute", "url", "ct"}, q.Columns())
+
+	// the first minute is emitted once a row of the next arrives, while
+	// the stream is still open
+	send(1, "/a")
+	send(20, "/b")
+	send(59, "/a")
+	send(61, "/a")
+	assert.Equal(t, "0:/a:2,0:/b:1", read(q, 2))
+
+	// late rows are dropped
+	send(30, "/b")
+	send(90, "/b")
+	send(125, "/a")
+	assert.Equal(t, "60:/a:1,60:/b:1", read(q, 2))
+	// the open minute is checkpointed on close
+	assert.T(t, q.Close() == nil)
+
+	// restarted, it resumes the open minute
+	q, err = start(sql)
+	assert.Tf(t, err == nil, "no error %v", err)
+	send(10, "/a")
+	send(130, "/a")
+	send(150, "/b")
+	close(src.rows)
+	assert.Equal(t, "120:/a:2,120:/b:1", read(q, 2))
+	assert.Equal(t, io.EOF, q.Next(make([]driver.Value, 3)))
+
+	// results are only produced as windows close
+	for _, sql := range []string{
+		`SELECT url, count(*) AS ct FROM clicks GROUP BY url`,
+		`SELECT url FROM clicks ORDER BY url`,
+	} {
+		_, err = start(sql)
+		assert.Tf(t, err != nil, "expected error for %s", sql)
+	}
+}
+
+func TestContinuousQueryChanges(t *testing.T) {
+	users := membtree.NewStaticDataSource("users", 0, [][]driver.Value{
+		{int64(1), "aaron", int64(30)},
+	}, []string{"id", "name", "age"})
+	_, err := datasource.NewChangeStreamSource(&datasource.StructSource{})
+	assert.T(t, err != nil)
+	stream, err := datasource.NewChangeStreamSource(users)
+	assert.Tf(t, err == nil, "no error %v", err)
+	tbl, err := stream.Table("users")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"id", "name", "age", "change_op", "change_time"}, tbl.Columns())
+
+	// a continuous query of the changes as they are written
+	ctx := plan.NewContext(`SELECT id, name, change_op FROM users WHERE age > 30`)
+	ctx.Schema = datasource.RegisterSchemaSource("liveusers", "liveusers", stream)
+	q, err := exec.NewContinuousQuery(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	users.Put(nil, nil, []driver.Value{int64(2), "bob", int64(40)})
+	users.Put(nil, nil, []driver.Value{int64(3), "carol", int64(20)})
+	users.Put(nil, nil, []driver.Value{int64(1), "aaron", int64(35)})
+	users.Delete(int64(2))
+	got := make([][]driver.Value, 0)
+	for len(got) < 3 {
+		row := make([]driver.Value, 3)
+		assert.T(t, q.Next(row) == nil)
+		got = append(got, row)
+	}
+	assert.Equal(t, [][]driver.Value{
+		{int64(2), "bob", "insert"},
+		{int64(1), "aaron", "update"},
+		{int64(2), "bob", "delete"},
+	}, got)
+	q.Close()
+}
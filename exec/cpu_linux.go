@@ -0,0 +1,18 @@
+package exec
+
+import (
+	"syscall"
+	"time"
+)
+
+// getrusage of calling thread only
+const rusageThread = 1
+
+// threadCPU cpu time (user + system) of the current thread
+func threadCPU() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(rusageThread, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
@@ -0,0 +1,8 @@
+// +build !linux
+
+package exec
+
+import "time"
+
+// threadCPU cpu time of the current thread, not measured on this platform
+func threadCPU() time.Duration { return 0 }
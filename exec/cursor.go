@@ -0,0 +1,256 @@
+package exec
+
+import (
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+)
+
+var (
+	_ = u.EMPTY
+
+	// ErrCursorNotFound the token is not of an open cursor, it was read to
+	// the end, closed, or expired
+	ErrCursorNotFound = fmt.Errorf("QLBridge: cursor not found or expired")
+)
+
+const (
+	// CursorIdleDefault how long a cursor is kept open without a page
+	// being read before it is closed
+	CursorIdleDefault = 5 * time.Minute
+)
+
+// CursorPage a page of the result rows of a cursor
+type CursorPage struct {
+	Columns []string
+	Rows    [][]driver.Value
+	// Next the token to read the page after this one with, empty once all
+	// rows have been read (the cursor is closed)
+	Next string
+}
+
+// Cursor a query whose result rows are read a page at a time, by separate
+// requests each passing the token of the page it wants.  The query runs in
+// the background between pages, only reading ahead as far as the buffers
+// between its operators, so the rows are never all held in memory nor a
+// connection kept open while reading them.
+type Cursor struct {
+	id       string
+	cols     []string
+	job      *JobExecutor
+	rows     *ResultWriter
+	done     chan struct{} // closed when job finishes running
+	runErr   error
+	mu       sync.Mutex
+	seq      int         // sequence of the next page
+	last     *CursorPage // page seq-1, returned again if its token is retried
+	lastUsed time.Time   // guarded by CursorStore
+	busy     int         // pages being read, guarded by CursorStore
+}
+
+func (m *Cursor) token(seq int) string {
+	return m.id + "." + strconv.Itoa(seq)
+}
+
+// fetch read the next page of up to n rows
+func (m *Cursor) fetch(seq, n int) (*CursorPage, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if seq == m.seq-1 && m.last != nil {
+		// the page was lost on the way to the client, send it again
+		return m.last, m.last.Next == "", nil
+	}
+	if seq != m.seq {
+		return nil, false, fmt.Errorf("QLBridge: cursor page %d is not the next page %d", seq, m.seq)
+	}
+	page := &CursorPage{Columns: m.cols, Rows: make([][]driver.Value, 0, n)}
+	eof := false
+	for len(page.Rows) < n {
+		row := make([]driver.Value, len(m.cols))
+		err := m.rows.Next(row)
+		if err == io.EOF {
+			eof = true
+			break
+		} else if err != nil {
+			return nil, true, err
+		}
+		page.Rows = append(page.Rows, row)
+	}
+	if eof {
+		// the result writer only stops when the job is closed
+		m.job.Close()
+		<-m.done
+		if m.runErr != nil {
+			return nil, true, m.runErr
+		}
+	} else {
+		page.Next = m.token(m.seq + 1)
+	}
+	m.seq++
+	m.last = page
+	return page, eof, nil
+}
+
+func (m *Cursor) close() {
+	m.job.Close()
+}
+
+// CursorStore the open cursors of a server by token.  Cursors not read for
+// longer than Idle are closed, stopping their query.
+//
+//   store := exec.NewCursorStore(time.Minute)
+//   page, err := store.Open(ctx, 1000)      // first page
+//   page, err = store.Fetch(page.Next, 1000) // until page.Next == ""
+type CursorStore struct {
+	Idle    time.Duration
+	mu      sync.Mutex
+	cursors map[string]*Cursor
+}
+
+// NewCursorStore a store of cursors closed after idle, <= 0 for
+// CursorIdleDefault
+func NewCursorStore(idle time.Duration) *CursorStore {
+	if idle <= 0 {
+		idle = CursorIdleDefault
+	}
+	return &CursorStore{Idle: idle, cursors: make(map[string]*Cursor)}
+}
+
+// Open run the SELECT statement of ctx and read its first page of up to
+// n rows, the query is left running for the pages after
+func (m *CursorStore) Open(ctx *plan.Context, n int) (*CursorPage, error) {
+	m.Expire()
+
+	job, err := BuildSqlJob(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sqlSelect, ok := job.Ctx.Stmt.(*rel.SqlSelect)
+	if !ok {
+		return nil, fmt.Errorf("QLBridge: cursor requires a select statement but got %T", job.Ctx.Stmt)
+	}
+	c := &Cursor{
+		id:       newCursorId(),
+		cols:     sqlSelect.Columns.AliasedFieldNames(),
+		job:      job,
+		done:     make(chan struct{}),
+		lastUsed: time.Now(),
+	}
+	c.rows = NewResultRows(ctx, c.cols)
+	job.RootTask.Add(c.rows)
+	if err := job.Setup(); err != nil {
+		job.Close()
+		return nil, err
+	}
+	go func() {
+		defer close(c.done)
+		c.runErr = job.Run()
+		if c.runErr != nil {
+			u.Errorf("error on cursor query: %v", c.runErr)
+		}
+	}()
+
+	m.mu.Lock()
+	m.cursors[c.id] = c
+	m.mu.Unlock()
+	return m.Fetch(c.token(0), n)
+}
+
+// Fetch the page of up to n rows of token.  Fetching the token of the last
+// page read again returns the same page, so a client may retry a request
+// whose response it did not get.
+func (m *CursorStore) Fetch(token string, n int) (*CursorPage, error) {
+	m.Expire()
+
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return nil, ErrCursorNotFound
+	}
+	seq, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, ErrCursorNotFound
+	}
+	m.mu.Lock()
+	c, ok := m.cursors[parts[0]]
+	if ok {
+		c.busy++
+	}
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrCursorNotFound
+	}
+	if n <= 0 {
+		n = 1
+	}
+	page, done, err := c.fetch(seq, n)
+	m.mu.Lock()
+	c.busy--
+	c.lastUsed = time.Now()
+	m.mu.Unlock()
+	if done {
+		// the last page is not kept, the cursor is gone once read
+		m.Close(token)
+	}
+	return page, err
+}
+
+// Close the cursor of token, stopping its query
+func (m *CursorStore) Close(token string) error {
+	id := strings.SplitN(token, ".", 2)[0]
+	m.mu.Lock()
+	c, ok := m.cursors[id]
+	delete(m.cursors, id)
+	m.mu.Unlock()
+	if !ok {
+		return ErrCursorNotFound
+	}
+	c.close()
+	return nil
+}
+
+// Expire close the cursors not read for longer than Idle, returns the
+// number closed.  Called on each Open and Fetch, or periodically by a
+// server.
+func (m *CursorStore) Expire() int {
+	cutoff := time.Now().Add(-m.Idle)
+	expired := make([]*Cursor, 0)
+	m.mu.Lock()
+	for id, c := range m.cursors {
+		if c.busy == 0 && c.lastUsed.Before(cutoff) {
+			delete(m.cursors, id)
+			expired = append(expired, c)
+		}
+	}
+	m.mu.Unlock()
+	for _, c := range expired {
+		u.Debugf("closing idle cursor %s", c.id)
+		c.close()
+	}
+	return len(expired)
+}
+
+// Len the number of open cursors
+func (m *CursorStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.cursors)
+}
+
+func newCursorId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatUint(plan.NextId(), 16)
+	}
+	return hex.EncodeToString(b)
+}
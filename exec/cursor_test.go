@@ -0,0 +1,74 @@
+package exec_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+)
+
+func TestCursor(t *testing.T) {
+	store := exec.NewCursorStore(time.Minute)
+	newCtx := func(sql string) *plan.Context {
+		ctx := plan.NewContext(sql)
+		ctx.Schema = scanSchema
+		return ctx
+	}
+
+	// page through all rows
+	page, err := store.Open(newCtx(`SELECT n FROM numbers WHERE n < 2500`), 1000)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"n"}, page.Columns)
+	seen := make(map[int64]bool)
+	pages := 0
+	for {
+		pages++
+		for _, row := range page.Rows {
+			seen[row[0].(int64)] = true
+		}
+		if page.Next == "" {
+			break
+		}
+		assert.Equal(t, 1, store.Len())
+		token := page.Next
+		page, err = store.Fetch(token, 1000)
+		assert.Tf(t, err == nil, "no error %v", err)
+
+		// a retried page is sent again
+		if page.Next != "" {
+			again, err := store.Fetch(token, 1000)
+			assert.Tf(t, err == nil, "no error %v", err)
+			assert.Equal(t, page, again)
+		}
+	}
+	assert.Equal(t, 3, pages)
+	assert.Equal(t, 2500, len(seen))
+	assert.Equal(t, 0, store.Len())
+
+	_, err = store.Fetch(page.Next, 10)
+	assert.Equal(t, exec.ErrCursorNotFound, err)
+	_, err = store.Fetch("not-a-token", 10)
+	assert.Equal(t, exec.ErrCursorNotFound, err)
+
+	// a query is stopped when its cursor is closed, or expires
+	page, err = store.Open(newCtx(`SELECT n FROM numbers`), 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, 10, len(page.Rows))
+	assert.T(t, store.Close(page.Next) == nil)
+	_, err = store.Fetch(page.Next, 10)
+	assert.Equal(t, exec.ErrCursorNotFound, err)
+
+	page, err = store.Open(newCtx(`SELECT n FROM numbers`), 10)
+	assert.Tf(t, err == nil, "no error %v", err)
+	store.Idle = time.Millisecond
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, 1, store.Expire())
+	_, err = store.Fetch(page.Next, 10)
+	assert.Equal(t, exec.ErrCursorNotFound, err)
+
+	_, err = store.Open(newCtx(`INSERT INTO numbers (id, n, g) VALUES ("x", 1, "g")`), 10)
+	assert.T(t, err != nil)
+}
@@ -0,0 +1,130 @@
+package exec
+
+import (
+	"database/sql/driver"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*Create)(nil)
+	_ TaskRunner = (*Analyze)(nil)
+)
+
+// Create is executeable task for CREATE TABLE, creating the table in its
+// source if it creates tables (see schema.TableCreator) and adding it to
+// the schema, which is saved to its store if any.
+type Create struct {
+	*TaskBase
+	p *plan.Create
+}
+
+// NewCreate creates new create table exec task
+func NewCreate(ctx *plan.Context, p *plan.Create) *Create {
+	m := &Create{
+		TaskBase: NewTaskBaseNamed(ctx, "create"),
+		p:        p,
+	}
+	return m
+}
+
+// Run Create
+func (m *Create) Run() error {
+	defer m.Ctx.Recover()
+	defer close(m.msgOutCh)
+
+	err := m.create()
+	vals := make([]driver.Value, 2)
+	if err != nil {
+		vals[0] = err.Error()
+		vals[1] = int64(-1)
+		m.msgOutCh <- &datasource.SqlDriverMessage{Vals: vals, IdVal: 1}
+		return err
+	}
+	vals[0] = int64(0)
+	vals[1] = int64(0)
+	m.msgOutCh <- &datasource.SqlDriverMessage{Vals: vals, IdVal: 1}
+	return nil
+}
+
+func (m *Create) create() error {
+	if m.p.Exists {
+		return nil
+	}
+	tbl, ss := m.p.Table, m.p.Source
+	if creator, ok := ss.DS.(schema.TableCreator); ok {
+		if err := creator.CreateTable(tbl); err != nil {
+			return err
+		}
+		// the table as the source knows it, ie with its context
+		if sts, ok := ss.DS.(schema.SourceTableSchema); ok {
+			if created, err := sts.Table(tbl.Name); err == nil && created != nil {
+				// sources that do not know of foreign keys keep those of the ddl
+				if len(created.Relations) == 0 {
+					for _, r := range tbl.Relations {
+						if err := created.AddRelation(r); err != nil {
+							u.Warnf("could not add relation %s: %v", r, err)
+						}
+					}
+				}
+				tbl = created
+			}
+		}
+	}
+	tbl.SchemaSource = ss
+	tbl.Schema = ss.Schema()
+	ss.AddTable(tbl)
+	if err := ss.Schema().Save(); err != nil {
+		u.Warnf("could not save schema %q after create of %q: %v", ss.Schema().Name, tbl.Name, err)
+	}
+	return nil
+}
+
+// Analyze is executeable task for ANALYZE TABLE, computing the stats of the
+// columns of the table of a scan of it, saved with the schema to its store
+// if any.
+type Analyze struct {
+	*TaskBase
+	p *plan.Analyze
+}
+
+// NewAnalyze creates new analyze table exec task
+func NewAnalyze(ctx *plan.Context, p *plan.Analyze) *Analyze {
+	m := &Analyze{
+		TaskBase: NewTaskBaseNamed(ctx, "analyze"),
+		p:        p,
+	}
+	return m
+}
+
+// Run Analyze
+func (m *Analyze) Run() error {
+	defer m.Ctx.Recover()
+	defer close(m.msgOutCh)
+
+	tbl := m.p.Table
+	err := datasource.AnalyzeTable(tbl)
+	vals := make([]driver.Value, 2)
+	if err != nil {
+		vals[0] = err.Error()
+		vals[1] = int64(-1)
+		m.msgOutCh <- &datasource.SqlDriverMessage{Vals: vals, IdVal: 1}
+		return err
+	}
+	if tbl.Schema != nil {
+		if err := tbl.Schema.Save(); err != nil {
+			u.Warnf("could not save schema %q after analyze of %q: %v", tbl.Schema.Name, tbl.Name, err)
+		}
+	}
+	vals[0] = int64(0)
+	vals[1] = int64(0)
+	m.msgOutCh <- &datasource.SqlDriverMessage{Vals: vals, IdVal: 1}
+	return nil
+}
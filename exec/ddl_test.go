@@ -0,0 +1,259 @@
+package exec_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+func TestExecCreateTable(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "qlbridge-ddl")
+	assert.T(t, err == nil)
+	defer os.RemoveAll(dir)
+	store, err := schema.NewFileStore(dir)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	src := membtree.NewSource()
+	s := datasource.RegisterSchemaSource("ddlmem", "ddlmem", src)
+	s.SetStore(store)
+
+	sqlDb, err := sql.Open("qlbridge", "ddlmem")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer sqlDb.Close()
+
+	_, err = sqlDb.Exec(`CREATE TABLE accounts (
+		id bigint NOT NULL PRIMARY KEY,
+		name varchar(64) DEFAULT 'anon',
+		balance decimal(10,2)
+	)`)
+	assert.Tf(t, err == nil, "error: %v", err)
+
+	// the table of the source, and of the schema
+	assert.Equal(t, []string{"accounts"}, src.Tables())
+	tbl, err := s.Table("accounts")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"id", "name", "balance"}, tbl.Columns())
+	assert.Equal(t, value.IntType, tbl.FieldMap["id"].Type)
+	assert.Equal(t, "anon", tbl.FieldMap["name"].DefaultValue)
+	assert.Equal(t, uint32(64), tbl.FieldMap["name"].Length)
+	assert.Equal(t, []string{"id"}, tbl.PrimaryKey())
+
+	// saved to the store of the schema
+	def, err := store.Load("ddlmem")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, "accounts", def.Source("ddlmem").Tables[0].Name)
+
+	result, err := sqlDb.Exec(`INSERT INTO accounts (id, name, balance) VALUES (1, "bob", 10.5), (2, "alice", 3.0)`)
+	assert.Tf(t, err == nil, "error: %v", err)
+	insertedCt, _ := result.RowsAffected()
+	assert.Equal(t, int64(2), insertedCt)
+
+	rows, err := sqlDb.Query(`SELECT name FROM accounts WHERE balance > 5`)
+	assert.Tf(t, err == nil, "error: %v", err)
+	names := make([]string, 0)
+	for rows.Next() {
+		var name string
+		assert.T(t, rows.Scan(&name) == nil)
+		names = append(names, name)
+	}
+	rows.Close()
+	assert.Equal(t, []string{"bob"}, names)
+
+	create := func(sql string) error {
+		ctx := plan.NewContext(sql)
+		ctx.DisableRecover = true
+		ctx.Schema = s
+		ctx.Session = datasource.NewMySqlSessionVars()
+		job, err := exec.BuildSqlJob(ctx)
+		if err != nil {
+			return err
+		}
+		defer job.Close()
+		if err := job.Setup(); err != nil {
+			return err
+		}
+		return job.Run()
+	}
+	assert.T(t, create(`CREATE TABLE IF NOT EXISTS accounts (id int)`) == nil)
+	assert.T(t, create(`CREATE TABLE accounts (id int)`) != nil)
+	assert.T(t, create(`CREATE TABLE bad (id whatever)`) != nil)
+	assert.T(t, create(`CREATE TABLE bad (id int, PRIMARY KEY (nope))`) != nil)
+	assert.T(t, create(`CREATE TABLE events (id int) WITH {"source":"nope"}`) != nil)
+	assert.Equal(t, []string{"accounts"}, src.Tables())
+}
+
+func TestExecAnalyzeTable(t *testing.T) {
+
+	src := membtree.NewSource()
+	users := make([][]driver.Value, 0)
+	for i := 0; i < 5; i++ {
+		users = append(users, []driver.Value{int64(i), fmt.Sprintf("user%d", i)})
+	}
+	orders := make([][]driver.Value, 0)
+	for i := 0; i < 200; i++ {
+		orders = append(orders, []driver.Value{int64(i), int64(i % 5), int64(i)})
+	}
+	src.AddTable(membtree.NewStaticDataSource("users", 1, users, []string{"user_id", "name"}))
+	src.AddTable(membtree.NewStaticDataSource("orders", 0, orders, []string{"order_id", "user_id", "qty"}))
+	s := datasource.RegisterSchemaSource("statsmem", "statsmem", src)
+
+	sqlDb, err := sql.Open("qlbridge", "statsmem")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer sqlDb.Close()
+
+	explain := func() string {
+		rows, err := sqlDb.Query(`EXPLAIN SELECT o.qty, u.name FROM users AS u INNER JOIN orders AS o ON u.user_id = o.user_id`)
+		assert.Tf(t, err == nil, "error: %v", err)
+		defer rows.Close()
+		details := make([]string, 0)
+		for rows.Next() {
+			var id, parent int64
+			var task, detail string
+			assert.T(t, rows.Scan(&id, &parent, &task, &detail) == nil)
+			details = append(details, task+" "+detail)
+		}
+		return strings.Join(details, "\n")
+	}
+	assert.T(t, !strings.Contains(explain(), "build=left"))
+
+	_, err = sqlDb.Exec(`ANALYZE TABLE orders`)
+	assert.Tf(t, err == nil, "error: %v", err)
+	tbl, err := s.Table("orders")
+	assert.Tf(t, err == nil, "no error %v", err)
+	rows, ok := tbl.RowCount()
+	assert.Tf(t, ok && rows == 200, "rows %d %v", rows, ok)
+	assert.Equal(t, int64(5), tbl.ColumnStats("user_id").Distinct)
+	assert.Equal(t, int64(199), tbl.ColumnStats("qty").Max)
+
+	// users not yet analyzed, but the background refresh does
+	assert.T(t, !strings.Contains(explain(), "build=left"))
+	assert.Equal(t, []string{"users"}, datasource.RefreshSchemaStats(s, time.Hour))
+	assert.Equal(t, []string{}, datasource.RefreshSchemaStats(s, time.Hour))
+	tbl, err = s.Table("users")
+	assert.Tf(t, err == nil, "no error %v", err)
+	rows, ok = tbl.RowCount()
+	assert.Tf(t, ok && rows == 5, "rows %d %v", rows, ok)
+
+	// the smaller side of the join is built
+	out := explain()
+	assert.Tf(t, strings.Contains(out, "build=left") && strings.Contains(out, "rows=5"), "%s", out)
+	joined, err := sqlDb.Query(`SELECT o.qty, u.name FROM users AS u INNER JOIN orders AS o ON u.user_id = o.user_id`)
+	assert.Tf(t, err == nil, "error: %v", err)
+	ct := 0
+	for joined.Next() {
+		var qty int64
+		var name string
+		assert.T(t, joined.Scan(&qty, &name) == nil)
+		assert.Equal(t, fmt.Sprintf("user%d", qty%5), name)
+		ct++
+	}
+	joined.Close()
+	assert.Equal(t, 200, ct)
+
+	_, err = sqlDb.Exec(`ANALYZE TABLE nope`)
+	assert.T(t, err != nil)
+}
+
+func TestExecForeignKeys(t *testing.T) {
+
+	src := membtree.NewSource()
+	s := datasource.RegisterSchemaSource("relmem", "relmem", src)
+
+	sqlDb, err := sql.Open("qlbridge", "relmem")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer sqlDb.Close()
+
+	for _, sql := range []string{
+		`CREATE TABLE users (user_id bigint PRIMARY KEY, name varchar(64))`,
+		`CREATE TABLE orders (order_id bigint PRIMARY KEY, user_id bigint NOT NULL REFERENCES users (user_id), qty int)`,
+		`INSERT INTO users (user_id, name) VALUES (1, "bob"), (2, "alice")`,
+		`INSERT INTO orders (order_id, user_id, qty) VALUES (10, 1, 5), (11, 2, 7), (12, 1, 9)`,
+	} {
+		_, err = sqlDb.Exec(sql)
+		assert.Tf(t, err == nil, "error: %v %s", err, sql)
+	}
+	tbl, err := s.Table("orders")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, 1, len(tbl.Relations))
+	assert.Equal(t, "orders.user_id = users.user_id", s.JoinSuggestions("users")[0].On)
+
+	_, err = sqlDb.Exec(`CREATE TABLE items (id int, order_id int REFERENCES nope (id))`)
+	assert.T(t, err != nil)
+	_, err = sqlDb.Exec(`CREATE TABLE items (id int, order_id int REFERENCES orders (nope))`)
+	assert.T(t, err != nil)
+
+	explain := func(sql string) ([]string, []string) {
+		ctx := plan.NewContext(sql)
+		ctx.DisableRecover = true
+		ctx.Schema = s
+		ctx.Session = datasource.NewMySqlSessionVars()
+		stmt, err := rel.ParseSql(sql)
+		assert.Tf(t, err == nil, "Must parse %s but got %v", sql, err)
+		pln, err := plan.WalkStmt(ctx, stmt, plan.NewPlanner(ctx))
+		assert.Tf(t, err == nil, "no error %v for %s", err, sql)
+		tasks := make([]string, 0)
+		for _, step := range plan.ExplainTask(pln) {
+			tasks = append(tasks, step.Task)
+		}
+		return tasks, ctx.Warnings
+	}
+	query := func(sql string) []int64 {
+		rows, err := sqlDb.Query(sql)
+		assert.Tf(t, err == nil, "error: %v", err)
+		defer rows.Close()
+		qtys := make([]int64, 0)
+		for rows.Next() {
+			var qty int64
+			assert.T(t, rows.Scan(&qty) == nil)
+			qtys = append(qtys, qty)
+		}
+		sort.Slice(qtys, func(i, j int) bool { return qtys[i] < qtys[j] })
+		return qtys
+	}
+
+	// users only contributes the join key, each order has exactly one user
+	tests := []struct {
+		sql        string
+		eliminated bool
+	}{
+		{`SELECT o.qty FROM orders AS o INNER JOIN users AS u ON o.user_id = u.user_id`, true},
+		{`SELECT o.qty FROM users AS u INNER JOIN orders AS o ON u.user_id = o.user_id WHERE o.qty > 6`, true},
+		{`SELECT o.qty FROM orders AS o INNER JOIN users AS u ON o.user_id = u.user_id WHERE u.name = "bob"`, false},
+		{`SELECT o.qty FROM orders AS o LEFT JOIN users AS u ON o.user_id = u.user_id`, false},
+		{`SELECT u.user_id FROM users AS u INNER JOIN orders AS o ON u.user_id = o.user_id`, false},
+	}
+	for _, tt := range tests {
+		tasks, _ := explain(tt.sql)
+		joined := false
+		for _, task := range tasks {
+			joined = joined || task == "join"
+		}
+		assert.Tf(t, joined != tt.eliminated, "eliminated=%v %v %s", tt.eliminated, tasks, tt.sql)
+	}
+	assert.Equal(t, []int64{5, 7, 9}, query(tests[0].sql))
+	assert.Equal(t, []int64{7, 9}, query(tests[1].sql))
+	assert.Equal(t, []int64{5, 9}, query(tests[2].sql))
+
+	// a join not on the relation of the tables
+	_, warnings := explain(`SELECT o.qty FROM orders AS o INNER JOIN users AS u ON o.order_id = u.user_id`)
+	assert.Tf(t, len(warnings) == 1 && strings.Contains(warnings[0], "orders(user_id) -> users(user_id)"), "%v", warnings)
+	_, warnings = explain(`SELECT o.qty, u.name FROM orders AS o INNER JOIN users AS u ON o.user_id = u.user_id`)
+	assert.Equal(t, 0, len(warnings))
+}
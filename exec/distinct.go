@@ -0,0 +1,389 @@
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql/driver"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/value"
+	"github.com/araddon/qlbridge/vm"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*Distinct)(nil)
+)
+
+// Distinct removes duplicate rows of a SELECT DISTINCT.  The key of a row is
+// the canonical encoding of its selected column values, each row with a key
+// not seen before is passed on, see dedupSet for spilling.
+type Distinct struct {
+	*TaskBase
+	p     *plan.Distinct
+	dedup *dedupSet
+}
+
+// NewDistinct create new distinct (dedup) exec task
+func NewDistinct(ctx *plan.Context, p *plan.Distinct) *Distinct {
+	return &Distinct{
+		TaskBase: NewTaskBaseNamed(ctx, "distinct"),
+		p:        p,
+	}
+}
+
+func (m *Distinct) Run() error {
+	defer m.Ctx.Recover()
+	defer close(m.msgOutCh)
+
+	outCh := m.MessageOut()
+	inCh := m.MessageIn()
+
+	// keyed on all values of rows that are already the selected columns
+	allCols := m.p.Stmt.IsAggQuery() || m.p.Stmt.Star
+	colIndex := m.p.Stmt.ColIndexes()
+
+	dedup := newDedupSet(AggMemory(m.Ctx), tempDir(m.Ctx), newMemReservation(m.TaskBase, "distinct"))
+	defer dedup.Close()
+	m.Lock()
+	m.dedup = dedup
+	m.Unlock()
+
+	emit := func(sdm *datasource.SqlDriverMessageMap) bool {
+		select {
+		case outCh <- sdm:
+			return true
+		case <-m.SigChan():
+			return false
+		}
+	}
+
+msgReadLoop:
+	for {
+		select {
+		case <-m.SigChan():
+			return nil
+		case msg, ok := <-inCh:
+			if !ok {
+				break msgReadLoop
+			}
+			var sdm *datasource.SqlDriverMessageMap
+			switch mt := msg.(type) {
+			case *datasource.SqlDriverMessageMap:
+				sdm = mt
+			default:
+				msgReader, isContextReader := msg.(expr.ContextReader)
+				if !isContextReader {
+					err := fmt.Errorf("To use Distinct must use SqlDriverMessageMap but got %T", msg)
+					u.Errorf("unrecognized msg %T", msg)
+					close(m.TaskBase.sigCh)
+					return err
+				}
+				sdm = datasource.NewSqlDriverMessageMapCtx(msg.Id(), msgReader, colIndex)
+			}
+
+			var key string
+			if allCols {
+				key = canonicalKey(sdm.Vals)
+			} else {
+				key = canonicalKey(distinctValues(m.p.Stmt.Columns, sdm))
+			}
+			isNew, err := dedup.Add(key, sdm)
+			if err != nil {
+				u.Errorf("could not spill distinct rows: %v", err)
+				return err
+			}
+			if isNew && !emit(sdm) {
+				return nil
+			}
+		}
+	}
+
+	if err := dedup.Emit(emit); err != nil {
+		u.Errorf("could not merge spilled distinct rows: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Spills number of times rows were spilled to disk, 0 if deduped in memory
+func (m *Distinct) Spills() int {
+	m.Lock()
+	defer m.Unlock()
+	if m.dedup == nil {
+		return 0
+	}
+	return int(atomic.LoadInt32(&m.dedup.spilled))
+}
+
+// distinctValues the values of the selected columns of row
+func distinctValues(cols rel.Columns, sdm *datasource.SqlDriverMessageMap) []driver.Value {
+	vals := make([]driver.Value, 0, len(cols))
+	for _, col := range cols {
+		if col.ParentIndex < 0 {
+			continue
+		}
+		var v value.Value
+		switch {
+		case col.Over != nil:
+			v, _ = sdm.Get(col.As)
+		case col.Expr != nil:
+			v, _ = vm.Eval(sdm, col.Expr)
+		}
+		if v == nil || v.Nil() {
+			vals = append(vals, nil)
+			continue
+		}
+		vals = append(vals, v.Value())
+	}
+	return vals
+}
+
+// dedupSet the keys seen so far of a stream of rows.  Keys are held in memory
+// until they exceed the memory budget or the memory limit of the query, then
+// the set is frozen:  rows with a
+// key in it are still duplicates, other rows are spilled to files hash
+// partitioned on key instead of being passed on.  Those rows are not in the
+// frozen set so at the end each partition is deduped on its own.  Rows past
+// the budget are thus emitted after, not in input order.
+//
+//   rows -> [ keys ] -> new row -> emit
+//                    -> full    -> partition-0 (file) -> dedup -> emit
+//                               -> partition-n (file) -> dedup -> emit
+type dedupSet struct {
+	budget   int64
+	dir      string
+	keys     map[string]struct{}
+	used     int64
+	res      *memReservation
+	parts    []*aggPartition
+	spilled  int32
+	colIndex map[string]int
+}
+
+func newDedupSet(budget int64, dir string, res *memReservation) *dedupSet {
+	return &dedupSet{
+		budget: budget,
+		dir:    dir,
+		keys:   make(map[string]struct{}),
+		res:    res,
+	}
+}
+
+// Add the key of a row, is it the first row with this key.  Once over
+// budget new rows are spilled and false returned, they are emitted by Emit.
+func (m *dedupSet) Add(key string, row *datasource.SqlDriverMessageMap) (bool, error) {
+	if _, seen := m.keys[key]; seen {
+		return false, nil
+	}
+	if m.parts == nil {
+		m.keys[key] = struct{}{}
+		size := int64(48 + len(key))
+		m.used += size
+		if err := m.res.Grow(size); err != nil || m.used > m.budget {
+			if err := m.createPartitions(); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	if m.colIndex == nil && row != nil {
+		m.colIndex = row.ColIndex
+	}
+	hasher := fnv.New32a()
+	hasher.Write([]byte(key))
+	part := m.parts[hasher.Sum32()%uint32(len(m.parts))]
+	srow := spillRow{Key: key}
+	if row != nil {
+		srow.Id, srow.Vals = row.IdVal, row.Vals
+	}
+	return false, part.enc.Encode(&srow)
+}
+
+func (m *dedupSet) createPartitions() error {
+	for i := 0; i < aggSpillPartitions; i++ {
+		f, err := ioutil.TempFile(m.dir, "qlbridge-distinct-")
+		if err != nil {
+			return err
+		}
+		w := bufio.NewWriter(f)
+		m.parts = append(m.parts, &aggPartition{f: f, w: w, enc: gob.NewEncoder(w)})
+	}
+	atomic.AddInt32(&m.spilled, 1)
+	return nil
+}
+
+// Emit the first row of each key that was spilled, stops if emit returns false
+func (m *dedupSet) Emit(emit func(*datasource.SqlDriverMessageMap) bool) error {
+	return m.eachSpilled(func(row *spillRow) bool {
+		return emit(datasource.NewSqlDriverMessageMap(row.Id, row.Vals, m.colIndex))
+	})
+}
+
+// Keys each distinct key of the set, those held in memory then those
+// spilled, stops if fn returns false
+func (m *dedupSet) Keys(fn func(key string) bool) error {
+	for key := range m.keys {
+		if !fn(key) {
+			return nil
+		}
+	}
+	return m.eachSpilled(func(row *spillRow) bool {
+		return fn(row.Key)
+	})
+}
+
+// eachSpilled the first spilled row of each key, stops if fn returns false
+func (m *dedupSet) eachSpilled(fn func(*spillRow) bool) error {
+	if m.parts == nil {
+		return nil
+	}
+	// spilled keys are not in the frozen set, it is not needed anymore
+	m.keys = nil
+	m.res.Release()
+	for _, part := range m.parts {
+		if err := part.w.Flush(); err != nil {
+			return err
+		}
+		if _, err := part.f.Seek(0, 0); err != nil {
+			return err
+		}
+		seen := make(map[string]struct{})
+		dec := gob.NewDecoder(bufio.NewReader(part.f))
+		for {
+			var row spillRow
+			if err := dec.Decode(&row); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return err
+			}
+			if _, ok := seen[row.Key]; ok {
+				continue
+			}
+			seen[row.Key] = struct{}{}
+			if !fn(&row) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// Close remove the spilled partitions
+func (m *dedupSet) Close() error {
+	m.res.Release()
+	for _, part := range m.parts {
+		part.f.Close()
+		os.Remove(part.f.Name())
+	}
+	m.parts = nil
+	return nil
+}
+
+// canonicalKey an encoding of values where equal values have equal keys
+// regardless of their go type, ie int64(1), int(1) and float64(1) are equal.
+// Each value is type tagged and strings are length prefixed so keys of
+// different values never collide.
+func canonicalKey(vals []driver.Value) string {
+	var buf bytes.Buffer
+	for _, v := range vals {
+		writeCanonical(&buf, v)
+	}
+	return buf.String()
+}
+
+func writeCanonical(buf *bytes.Buffer, v interface{}) {
+	switch vt := v.(type) {
+	case nil:
+		buf.WriteByte('N')
+	case bool:
+		if vt {
+			buf.WriteString("B1")
+		} else {
+			buf.WriteString("B0")
+		}
+	case int:
+		writeCanonicalInt(buf, int64(vt))
+	case int32:
+		writeCanonicalInt(buf, int64(vt))
+	case int64:
+		writeCanonicalInt(buf, vt)
+	case uint32:
+		writeCanonicalInt(buf, int64(vt))
+	case uint64:
+		if vt > math.MaxInt64 {
+			writeCanonicalFloat(buf, float64(vt))
+			return
+		}
+		writeCanonicalInt(buf, int64(vt))
+	case float32:
+		writeCanonicalFloat(buf, float64(vt))
+	case float64:
+		writeCanonicalFloat(buf, vt)
+	case string:
+		writeCanonicalString(buf, vt)
+	case []byte:
+		writeCanonicalString(buf, string(vt))
+	case time.Time:
+		buf.WriteByte('D')
+		buf.WriteString(strconv.FormatInt(vt.UnixNano(), 10))
+		buf.WriteByte(';')
+	case value.Value:
+		if vt.Nil() {
+			buf.WriteByte('N')
+			return
+		}
+		writeCanonical(buf, vt.Value())
+	default:
+		// maps and slices, json sorts map keys
+		by, err := json.Marshal(vt)
+		if err != nil {
+			by = []byte(fmt.Sprintf("%v", vt))
+		}
+		buf.WriteByte('J')
+		writeCanonicalString(buf, string(by))
+	}
+}
+
+func writeCanonicalInt(buf *bytes.Buffer, n int64) {
+	buf.WriteByte('I')
+	buf.WriteString(strconv.FormatInt(n, 10))
+	buf.WriteByte(';')
+}
+
+func writeCanonicalFloat(buf *bytes.Buffer, f float64) {
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < -math.MinInt64 {
+		// integral floats equal to the int
+		writeCanonicalInt(buf, int64(f))
+		return
+	}
+	buf.WriteByte('F')
+	buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	buf.WriteByte(';')
+}
+
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('S')
+	buf.WriteString(strconv.Itoa(len(s)))
+	buf.WriteByte(':')
+	buf.WriteString(s)
+}
@@ -0,0 +1,89 @@
+package exec_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+)
+
+// findTask the first task of a job matching
+func findTask(t exec.Task, match func(exec.Task) bool) exec.Task {
+	if match(t) {
+		return t
+	}
+	for _, child := range t.Children() {
+		if found := findTask(child, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func TestDistinct(t *testing.T) {
+	allGroups := make([]string, 100)
+	for i := range allGroups {
+		allGroups[i] = fmt.Sprintf("g%02d", i)
+	}
+	tests := []struct {
+		sql    string
+		memory int64
+		spills bool
+		sorted bool // compare rows in order they are returned
+		want   string
+	}{
+		{`SELECT DISTINCT g FROM numbers`, 0, false, false, strings.Join(allGroups, ",")},
+		// keys past the first few spilled and deduped per partition
+		{`SELECT DISTINCT g FROM numbers`, 512, true, false, strings.Join(allGroups, ",")},
+		{`SELECT DISTINCT g FROM numbers ORDER BY g DESC LIMIT 3`, 0, false, true, "g99,g98,g97"},
+		{`SELECT DISTINCT g FROM numbers WHERE n < 1000 ORDER BY g ASC LIMIT 2`, 512, true, true, "g00,g01"},
+		{`SELECT DISTINCT g, count(*) AS ct FROM numbers GROUP BY g ORDER BY g ASC LIMIT 2`, 0, false, true, "g00,g01"},
+	}
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("", "qlbridge-distinct")
+		assert.Tf(t, err == nil, "no error %v", err)
+		defer os.RemoveAll(dir)
+
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("counting")
+		ctx.AggMemory = tt.memory
+		ctx.TempDir = dir
+
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+		rows := exec.NewResultRows(ctx, []string{"g"})
+		job.RootTask.Add(rows)
+		assert.T(t, job.Setup() == nil)
+		go job.Run()
+
+		got := make([]string, 0)
+		dest := make([]driver.Value, 1)
+		for rows.Next(dest) == nil {
+			got = append(got, dest[0].(string))
+		}
+		if !tt.sorted {
+			sort.Strings(got)
+		}
+		assert.Equalf(t, tt.want, strings.Join(got, ","), "rows for %s", tt.sql)
+
+		d, _ := findTask(job.RootTask, func(t exec.Task) bool {
+			_, ok := t.(*exec.Distinct)
+			return ok
+		}).(*exec.Distinct)
+		assert.T(t, d != nil)
+		assert.Equalf(t, tt.spills, d.Spills() > 0, "spilled %d with memory %d", d.Spills(), tt.memory)
+		job.Close()
+
+		files, _ := ioutil.ReadDir(dir)
+		assert.Equalf(t, 0, len(files), "spilled partitions should be removed %v", files)
+	}
+}
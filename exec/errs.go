@@ -3,6 +3,7 @@ package exec
 import (
 	"database/sql/driver"
 	"strings"
+	"sync"
 )
 
 // Create a multiple error type
@@ -29,6 +30,29 @@ func (e errList) Error() string {
 	return strings.Join(a, "\n")
 }
 
+// firstErr keeps the first error of tasks run concurrently
+type firstErr struct {
+	mu  sync.Mutex
+	err error
+}
+
+// set the error, returns true if it is the first one
+func (e *firstErr) set(err error) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return false
+	}
+	e.err = err
+	return true
+}
+
+func (e *firstErr) error() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
 func params(args []driver.Value) []interface{} {
 	r := make([]interface{}, len(args))
 	for i, v := range args {
@@ -0,0 +1,144 @@
+package exec
+
+import (
+	"sync"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/plan"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*Exchange)(nil)
+)
+
+// Exchange distributes its input messages round-robin across N parallel
+//   fragments (sequential tasks), and merges their output into a single
+//   output channel.
+//
+//                 -> fragment 0 ->
+//   input  ->    -> fragment 1 ->    --> output
+//                 -> fragment n ->
+//
+type Exchange struct {
+	*TaskBase
+	fragments []*TaskSequential
+	closed    bool
+}
+
+// NewExchange create an exchange over given fragments
+func NewExchange(ctx *plan.Context, fragments []*TaskSequential) *Exchange {
+	return &Exchange{
+		TaskBase:  NewTaskBaseNamed(ctx, "exchange"),
+		fragments: fragments,
+	}
+}
+
+func (m *Exchange) Children() []Task {
+	tasks := make([]Task, len(m.fragments))
+	for i, frag := range m.fragments {
+		tasks[i] = frag
+	}
+	return tasks
+}
+
+func (m *Exchange) Setup(depth int) error {
+	m.setup = true
+	for _, frag := range m.fragments {
+		frag.MessageInSet(make(MessageChan, BufferSize(m.Ctx, "exchange")))
+		if err := frag.Setup(depth + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Exchange) Close() error {
+	m.Lock()
+	if m.closed {
+		m.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.Unlock()
+
+	errs := make(errList, 0)
+	for _, frag := range m.fragments {
+		if err := frag.Close(); err != nil {
+			errs.append(err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return m.TaskBase.Close()
+}
+
+func (m *Exchange) Run() error {
+	defer m.Ctx.Recover()
+	defer close(m.msgOutCh)
+
+	outCh := m.MessageOut()
+	inCh := m.MessageIn()
+
+	var runWg, mergeWg sync.WaitGroup
+	var runErr firstErr
+	for _, frag := range m.fragments {
+		runWg.Add(1)
+		go func(frag *TaskSequential) {
+			defer runWg.Done()
+			if err := frag.Run(); err != nil {
+				u.Errorf("fragment errored %v", err)
+				// a failed fragment would leave partial results, stop the others
+				if runErr.set(err) {
+					quitTasks(m)
+				}
+			}
+		}(frag)
+
+		mergeWg.Add(1)
+		go func(fragOut MessageChan) {
+			defer mergeWg.Done()
+			for msg := range fragOut {
+				select {
+				case outCh <- msg:
+				case <-m.SigChan():
+					// drain so the fragment can finish
+					for range fragOut {
+					}
+					return
+				}
+			}
+		}(frag.MessageOut())
+	}
+
+	// round robin distribute input across fragments
+	next := 0
+msgReadLoop:
+	for {
+		select {
+		case <-m.SigChan():
+			break msgReadLoop
+		case msg, ok := <-inCh:
+			if !ok {
+				break msgReadLoop
+			}
+			select {
+			case m.fragments[next].MessageIn() <- msg:
+			case <-m.SigChan():
+				break msgReadLoop
+			}
+			next = (next + 1) % len(m.fragments)
+		}
+	}
+	for _, frag := range m.fragments {
+		close(frag.MessageIn())
+	}
+
+	mergeWg.Wait()
+	runWg.Wait()
+	return runErr.error()
+}
@@ -70,6 +70,15 @@ type (
 	TaskPrinter interface {
 		PrintDag(depth int)
 	}
+	// BatchTask is a task that may be sent *RowBatch messages instead of
+	// single rows.  Tasks that don't implement it never see a batch, a
+	// TaskSequential puts an Unbatch in front of them.
+	BatchTask interface {
+		// Batched given its input is batches, are the messages it sends on
+		// batches.  Only called on tasks whose output is read by a batch
+		// aware task.
+		Batched(in bool) bool
+	}
 
 	// Executor defines standard Walk() pattern to create a executeable task dag from a plan dag
 	//
@@ -88,16 +97,23 @@ type (
 		WalkUpdate(p *plan.Update) (Task, error)
 		WalkDelete(p *plan.Delete) (Task, error)
 		WalkCommand(p *plan.Command) (Task, error)
+		WalkCreate(p *plan.Create) (Task, error)
+		WalkAnalyze(p *plan.Analyze) (Task, error)
 		WalkPreparedStatement(p *plan.PreparedStatement) (Task, error)
 
 		// Child Tasks
 		WalkSource(p *plan.Source) (Task, error)
 		WalkJoin(p *plan.JoinMerge) (Task, error)
 		WalkJoinKey(p *plan.JoinKey) (Task, error)
+		WalkExchange(p *plan.Exchange) (Task, error)
+		WalkSemiJoin(p *plan.SemiJoin) (Task, error)
 		WalkWhere(p *plan.Where) (Task, error)
 		WalkHaving(p *plan.Having) (Task, error)
 		WalkGroupBy(p *plan.GroupBy) (Task, error)
 		WalkOrder(p *plan.Order) (Task, error)
+		WalkWindow(p *plan.Window) (Task, error)
+		WalkDistinct(p *plan.Distinct) (Task, error)
+		WalkSetOperation(p *plan.SetOperation) (Task, error)
 		WalkProjection(p *plan.Projection) (Task, error)
 	}
 
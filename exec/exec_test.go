@@ -1,8 +1,12 @@
 package exec_test
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,9 +14,11 @@ import (
 	"github.com/bmizerany/assert"
 
 	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
 	"github.com/araddon/qlbridge/datasource/mockcsv"
 	td "github.com/araddon/qlbridge/datasource/mockcsvtestdata"
 	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
 	"github.com/araddon/qlbridge/schema"
 	"github.com/araddon/qlbridge/testutil"
 )
@@ -51,7 +57,7 @@ func TestStatements(t *testing.T) {
 
 	// Mixed *, literal, fields
 	testutil.TestSelect(t, "SELECT *, emaildomain(email), contains(email,\"aaron\"), 5 FROM users WHERE email = \"aaron@email.com\"",
-		[][]driver.Value{{"9Ip1aKbeZe2njCDM", "aaron@email.com", "fishing", "2012-10-17T17:29:39.738Z", "82",
+		[][]driver.Value{{"9Ip1aKbeZe2njCDM", "aaron@email.com", "fishing", time.Date(2012, 10, 17, 17, 29, 39, 738000000, time.UTC), int64(82),
 			"email.com", true, int64(5)}},
 	)
 
@@ -74,7 +80,10 @@ func TestStatements(t *testing.T) {
 
 	// Distinct keyword
 	testutil.TestSelect(t, "SELECT COUNT(DISTINCT(`users.email`)) AS cd FROM users",
-		[][]driver.Value{{int64(0)}},
+		[][]driver.Value{{int64(3)}},
+	)
+	testutil.TestSelect(t, "SELECT COUNT(DISTINCT `users.user_id`) AS cd FROM users",
+		[][]driver.Value{{int64(3)}},
 	)
 
 	testutil.TestSelect(t, "SELECT email FROM users ORDER BY email DESC",
@@ -83,16 +92,19 @@ func TestStatements(t *testing.T) {
 	testutil.TestSelect(t, "SELECT email FROM users ORDER BY email ASC",
 		[][]driver.Value{{"aaron@email.com"}, {"bob@email.com"}, {"not_an_email_2"}},
 	)
+	// csv columns of numbers compare and sort as numbers
+	testutil.TestSelect(t, "SELECT order_id FROM orders WHERE price BETWEEN 20 AND 30",
+		[][]driver.Value{{int64(1)}, {int64(3)}},
+	)
+	testutil.TestSelect(t, "SELECT order_id, price * item_count FROM orders ORDER BY price DESC LIMIT 1",
+		[][]driver.Value{{int64(2), float64(3075)}},
+	)
 
 	// This is an error because we have schema on this table, and this column
 	// doesn't exist.
 	testutil.TestSelectErr(t, "SELECT email, non_existent_field FROM users ORDER BY email ASC", nil)
 
 	return
-	// TODO: #56 DISTINCT inside count()
-	testutil.TestSelect(t, "SELECT COUNT(DISTINCT(`users.user_id`)) AS cd FROM users",
-		[][]driver.Value{{int64(3)}},
-	)
 
 	// TODO: #56 this doesn't work because ordering is non-deterministic coming out of group by currently
 	//  which technically don't think there is any sql expectation of ordering, but there is for this test harness
@@ -194,12 +206,27 @@ func TestExecSelectWhere(t *testing.T) {
 	//u.Debugf("row: %#v", row)
 	assert.Tf(t, len(row) == 5, "expects 5 cols but got %v", len(row))
 	assert.T(t, row[0] == "9Ip1aKbeZe2njCDM")
-	// I really don't like this float behavior?
-	assert.Tf(t, int(row[2].(float64)) == 164, "expected %v == 164  T:%T", row[2], row[2])
+	assert.Tf(t, row[2] == int64(164), "expected %v == 164  T:%T", row[2], row[2])
 	assert.Tf(t, row[3] == int64(5), "wanted 5 got %v  T:%T", row[3], row[3])
 	assert.T(t, row[4] == true)
 }
 
+func TestExecUnnest(t *testing.T) {
+	// a row per element, the other columns repeated
+	testutil.TestSelect(t, `SELECT user_id, unnest(split(email, "@")) AS part FROM users WHERE email = "aaron@email.com"`,
+		[][]driver.Value{{"9Ip1aKbeZe2njCDM", "aaron"}, {"9Ip1aKbeZe2njCDM", "email.com"}},
+	)
+	// unnest columns side by side, the shorter NULL past its end
+	testutil.TestSelect(t, `SELECT unnest(split(email, "a")) AS x, unnest(split(email, "@")) AS y FROM users WHERE email = "aaron@email.com"`,
+		[][]driver.Value{{"", "aaron"}, {"", "email.com"}, {"ron@em", nil}, {"il.com", nil}},
+	)
+	// rows of no elements (no interests) are dropped
+	testutil.TestSelect(t, `SELECT user_id, unnest(split(interests, "i")) AS part FROM users`,
+		[][]driver.Value{{"9Ip1aKbeZe2njCDM", "f"}, {"9Ip1aKbeZe2njCDM", "sh"}, {"9Ip1aKbeZe2njCDM", "ng"},
+			{"hT2impsOPUREcVPc", "sw"}, {"hT2impsOPUREcVPc", "mm"}, {"hT2impsOPUREcVPc", "ng"}},
+	)
+}
+
 func TestExecGroupBy(t *testing.T) {
 
 	sqlText := `
@@ -264,6 +291,53 @@ func TestExecGroupBy(t *testing.T) {
 	assert.Tf(t, int(row[0].(float64)) == 14, "expected avg(len(email))=14 but got %v", int(row[0].(float64)))
 }
 
+func TestExecParallelFragments(t *testing.T) {
+
+	runParallel := func(sqlText string, dop int) []schema.Message {
+		ctx := td.TestContext(sqlText)
+		ctx.Parallelism = dop
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+
+		msgs := make([]schema.Message, 0)
+		resultWriter := exec.NewResultBuffer(ctx, &msgs)
+		job.RootTask.Add(resultWriter)
+
+		err = job.Setup()
+		assert.T(t, err == nil)
+		err = job.Run()
+		time.Sleep(time.Millisecond * 10)
+		assert.Tf(t, err == nil, "no error %v", err)
+		return msgs
+	}
+
+	// where evaluated across 3 fragments
+	msgs := runParallel(`select user_id, email FROM users WHERE yy(reg_date) > 10`, 3)
+	assert.Tf(t, len(msgs) == 1, "should have filtered out 2 messages %v", len(msgs))
+	row := msgs[0].(*datasource.SqlDriverMessageMap).Values()
+	assert.Equal(t, "9Ip1aKbeZe2njCDM", row[0])
+
+	// partial aggregation in fragments, merged by final group by
+	msgs = runParallel(`select user_id, count(user_id), avg(price) FROM orders GROUP BY user_id`, 2)
+	assert.Tf(t, len(msgs) == 2, "should have grouped orders into 2 users %v", len(msgs))
+	row = nil
+	for _, msg := range msgs {
+		r := msg.(*datasource.SqlDriverMessageMap).Values()
+		if r[0] == "9Ip1aKbeZe2njCDM" {
+			row = r
+		}
+	}
+	assert.Tf(t, len(row) == 3, "expects 3 cols but got %v", row)
+	assert.Tf(t, row[1] == int64(2), "expected 2 orders for %v", row)
+	assert.Tf(t, int(row[2].(float64)) == 30, "expected avg=30 for price %v", row)
+
+	// query hint overrides the context default
+	msgs = runParallel(`/*+ PARALLEL(4) */ select count(*) FROM orders WHERE price > 30`, 0)
+	assert.Tf(t, len(msgs) == 1, "expected 1 row %v", len(msgs))
+	row = msgs[0].(*datasource.SqlDriverMessageMap).Values()
+	assert.Tf(t, row[0] == int64(1), "expected count 1 %v", row)
+}
+
 func TestExecHaving(t *testing.T) {
 	sqlText := `
 		select 
@@ -585,3 +659,283 @@ func testSubselect(t *testing.T) {
 	assert.Tf(t, err == nil, "no error %v", err)
 	assert.Tf(t, len(msgs) == 1, "should have filtered out 2 messages")
 }
+
+func TestExecInsertSelect(t *testing.T) {
+
+	// By "Loading" table we force it to exist in this non DDL mock store
+	mockcsv.LoadTable(mockcsv.MockSchemaName, "user_event4", "id,user_id,event,date\n1,abcabcabc,signup,\"2012-12-24T17:29:39.738Z\"")
+
+	sqlText := `
+		INSERT INTO user_event4 (id, user_id, event, date)
+		SELECT user_id, user_id, email, reg_date FROM users WHERE referral_count > 0
+	`
+	ctx := td.TestContext(sqlText)
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "%v", err)
+
+	msgs := make([]schema.Message, 0)
+	job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+	err = job.Setup()
+	assert.T(t, err == nil)
+	err = job.Run()
+	assert.Tf(t, err == nil, "%v", err)
+
+	db, err := datasource.OpenConn("mockcsv", "user_event4")
+	assert.Tf(t, err == nil, "%v", err)
+	dbTable, ok := db.(*mockcsv.MockCsvTable)
+	assert.Tf(t, ok, "Should be type MockCsvTable but was T %T", db)
+	assert.Tf(t, dbTable.Length() == 4, "Should have inserted 3 for 4 rows but was %v", dbTable.Length())
+
+	// column count must match
+	ctx = td.TestContext(`INSERT INTO user_event4 (id, user_id) SELECT user_id FROM users`)
+	_, err = exec.BuildSqlJob(ctx)
+	assert.T(t, err != nil, "should error on column count mismatch")
+}
+
+func TestExecInsertBatch(t *testing.T) {
+
+	mockcsv.LoadTable(mockcsv.MockSchemaName, "user_event5", "id,user_id,event,date\n1,abcabcabc,signup,\"2012-12-24T17:29:39.738Z\"")
+
+	tests := []struct {
+		sql      string
+		batch    int
+		affected int64
+		total    int // rows in table after
+	}{
+		// columns in other order than table, written in batches of 2
+		{`INSERT INTO user_event5 (event, id, user_id) VALUES ("logon", "a1", "u1"), ("click", "a2", "u1"), ("logon", "a3", "u2")`,
+			2, 3, 4},
+		// columns not given are nil
+		{`INSERT INTO user_event5 (id, event) VALUES ("a4", "logon")`, 0, 1, 5},
+		// a row at a time
+		{`INSERT INTO user_event5 (id, user_id, event, date) VALUES ("a5", "u3", "logon", now()), ("a6", "u3", "logon", now())`,
+			1, 2, 7},
+		{`INSERT INTO user_event5 (user_id, id) SELECT user_id, email FROM users`, 2, 3, 10},
+	}
+	for _, tt := range tests {
+		ctx := td.TestContext(tt.sql)
+		ctx.WriteBatchSize = tt.batch
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+		err = job.Run()
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+		assert.Equalf(t, 1, len(msgs), "result of %s", tt.sql)
+		vals := msgs[0].Body().([]driver.Value)
+		assert.Equalf(t, tt.affected, vals[1], "affected rows of %s", tt.sql)
+
+		db, err := datasource.OpenConn("mockcsv", "user_event5")
+		assert.Tf(t, err == nil, "%v", err)
+		assert.Equalf(t, tt.total, db.(*mockcsv.MockCsvTable).Length(), "rows after %s", tt.sql)
+	}
+
+	db, _ := datasource.OpenConn("mockcsv", "user_event5")
+	tbl := db.(*mockcsv.MockCsvTable)
+	for id, want := range map[string][]driver.Value{
+		"a2": {"a2", "u1", "click"},
+		"a4": {"a4", nil, "logon"},
+	} {
+		msg, err := tbl.Get(id)
+		assert.Tf(t, err == nil, "found %s %v", id, err)
+		got := msg.Body().(*datasource.SqlDriverMessageMap).Values()[:3]
+		assert.Equalf(t, want, got, "row %s", id)
+	}
+
+	ctx := td.TestContext(`INSERT INTO user_event5 (id, nope) VALUES ("a9", "x")`)
+	_, err := exec.BuildSqlJob(ctx)
+	assert.T(t, err != nil, "should error on unknown column")
+
+	// sources without PutMulti have each row of the batch Put
+	single := &singleSource{StaticDataSource: membtree.NewStaticDataSource("singles", 0, nil, []string{"id", "name"})}
+	ctx = plan.NewContext(`INSERT INTO singles (id, name) VALUES (1, "aaron"), (2, "bob"), (3, "carl")`)
+	ctx.Schema = datasource.RegisterSchemaSource("singles", "singles", single)
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.T(t, job.Setup() == nil)
+	err = job.Run()
+	assert.Tf(t, err == nil, "no error %v", err)
+	job.Close()
+	assert.Equal(t, 3, single.Length())
+	assert.Equal(t, 1, single.multi)
+}
+
+// singleSource a table whose PutMulti is not implemented
+type singleSource struct {
+	*membtree.StaticDataSource
+	multi int // PutMulti calls
+}
+
+func (m *singleSource) Open(table string) (schema.Conn, error) { return m, nil }
+func (m *singleSource) PutMulti(ctx context.Context, keys []schema.Key, src interface{}) ([]schema.Key, error) {
+	m.multi++
+	return nil, schema.ErrNotImplemented
+}
+
+func TestExecSemiJoin(t *testing.T) {
+	tests := []struct {
+		sql  string
+		rows []string
+	}{
+		{`SELECT user_id FROM users WHERE user_id IN (SELECT user_id FROM orders)`,
+			[]string{"9Ip1aKbeZe2njCDM"}},
+		{`SELECT user_id FROM users WHERE user_id NOT IN (SELECT user_id FROM orders WHERE price > 30)`,
+			[]string{"hT2impsOPUREcVPc", "hT2impsabc345c"}},
+		// left hand side not projected
+		{`SELECT email FROM users WHERE user_id IN (SELECT user_id FROM orders)`,
+			[]string{"aaron@email.com"}},
+		// correlated
+		{`SELECT user_id FROM users AS u WHERE EXISTS (SELECT order_id FROM orders AS o WHERE o.user_id = u.user_id AND price > 30)`,
+			[]string{"9Ip1aKbeZe2njCDM"}},
+		{`SELECT user_id FROM users AS u WHERE NOT EXISTS (SELECT order_id FROM orders AS o WHERE u.user_id = o.user_id)`,
+			[]string{"hT2impsOPUREcVPc", "hT2impsabc345c"}},
+		// un-correlated
+		{`SELECT user_id FROM users WHERE EXISTS (SELECT order_id FROM orders WHERE price > 1000)`,
+			[]string{}},
+		{`SELECT user_id FROM users WHERE NOT EXISTS (SELECT order_id FROM orders WHERE price > 1000)`,
+			[]string{"9Ip1aKbeZe2njCDM", "hT2impsOPUREcVPc", "hT2impsabc345c"}},
+		// scalar sub-query of one row
+		{`SELECT user_id FROM users WHERE user_id = (SELECT user_id FROM orders WHERE price > 30)`,
+			[]string{"9Ip1aKbeZe2njCDM"}},
+		{`SELECT user_id FROM users WHERE user_id = (SELECT user_id FROM orders WHERE price > 1000)`,
+			[]string{}},
+	}
+	for _, tt := range tests {
+		ctx := td.TestContext(tt.sql)
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+
+		msgs := make([]schema.Message, 0)
+		resultWriter := exec.NewResultBuffer(ctx, &msgs)
+		job.RootTask.Add(resultWriter)
+
+		err = job.Setup()
+		assert.T(t, err == nil)
+		err = job.Run()
+		time.Sleep(time.Millisecond * 10)
+		assert.Tf(t, err == nil, "no error %v", err)
+
+		vals := make([]string, 0, len(msgs))
+		for _, msg := range msgs {
+			row := msg.(*datasource.SqlDriverMessageMap).Values()
+			vals = append(vals, row[0].(string))
+		}
+		sort.Strings(vals)
+		assert.Tf(t, strings.Join(vals, ",") == strings.Join(tt.rows, ","),
+			"%s wanted %v got %v", tt.sql, tt.rows, vals)
+	}
+
+	// a scalar sub-query of more than one row is an error, not an IN
+	sql := `SELECT user_id FROM users WHERE user_id = (SELECT user_id FROM orders)`
+	ctx := td.TestContext(sql)
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	msgs := make([]schema.Message, 0)
+	job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+	assert.T(t, job.Setup() == nil)
+	err = job.Run()
+	assert.Tf(t, err != nil && strings.Contains(err.Error(), "more than one row"), "expected error got %v", err)
+	assert.Equal(t, 0, len(msgs))
+}
+
+func TestExecTTL(t *testing.T) {
+
+	mockcsv.LoadTable(mockcsv.MockSchemaName, "ttl_event", "id,event\n1,signup")
+	mockcsv.SetTableTTL("ttl_event", time.Hour)
+
+	sqlDb, err := sql.Open("qlbridge", "mockcsv")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer sqlDb.Close()
+
+	result, err := sqlDb.Exec(`INSERT into ttl_event (id, event) VALUES ("2", "logon")`)
+	assert.Tf(t, err == nil, "error: %v", err)
+	insertedCt, _ := result.RowsAffected()
+	assert.Tf(t, insertedCt == 1, "should have inserted 1 but was %v", insertedCt)
+
+	rows, err := sqlDb.Query(`SELECT id, event, expires_at FROM ttl_event WHERE expires_at > "now+59m"`)
+	assert.Tf(t, err == nil, "error: %v", err)
+	defer rows.Close()
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id, event string
+		var expires time.Time
+		err = rows.Scan(&id, &event, &expires)
+		assert.Tf(t, err == nil, "no error: %v", err)
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	assert.Equal(t, []string{"1", "2"}, ids)
+}
+
+func TestExecIdentifierCase(t *testing.T) {
+	src := membtree.NewStaticDataSource("People", 0, [][]driver.Value{
+		{int64(1), "aaron"},
+		{int64(2), "bob"},
+	}, []string{"UserId", "Name"})
+	people := datasource.RegisterSchemaSource("case_people", "case_people", src)
+	people.SetIdentifierCase(schema.CaseInsensitive)
+
+	tests := []struct {
+		sql   string
+		names []string
+	}{
+		{`SELECT name FROM people`, []string{"aaron", "bob"}},
+		{`SELECT NAME FROM People WHERE userid = 1`, []string{"aaron"}},
+		{`SELECT Name FROM people WHERE UserId > 1 ORDER BY uSeRiD`, []string{"bob"}},
+		{`SELECT p.name FROM people AS p WHERE p.USERID = 2`, []string{"bob"}},
+		{`SELECT count(*) AS ct, name FROM people GROUP BY NAME ORDER BY name`, []string{"aaron", "bob"}},
+	}
+	for _, tt := range tests {
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema = people
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+		err = job.Run()
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+		job.Close()
+		names := make([]string, 0, len(msgs))
+		for _, msg := range msgs {
+			vals := msg.(*datasource.SqlDriverMessageMap).Values()
+			names = append(names, fmt.Sprintf("%v", vals[len(vals)-1]))
+		}
+		sort.Strings(names)
+		assert.Equalf(t, tt.names, names, "names of %s", tt.sql)
+	}
+
+	// joined, of each source its own names
+	src = membtree.NewStaticDataSource("users", 0, [][]driver.Value{
+		{int64(1), "aaron"},
+		{int64(2), "bob"},
+	}, []string{"UserId", "Name"})
+	orders := membtree.NewStaticDataSource("orders", 0, [][]driver.Value{
+		{int64(10), int64(2)},
+		{int64(11), int64(2)},
+	}, []string{"OrderId", "USER_ID"})
+	joined := datasource.RegisterSchemaSource("case_orders", "case_orders", &lookupSource{tables: map[string]*lookupTable{
+		"users":  {StaticDataSource: src},
+		"orders": {StaticDataSource: orders},
+	}})
+	joined.SetIdentifierCase(schema.CaseInsensitive)
+	sql := `SELECT o.orderid, u.name FROM orders AS o INNER JOIN users AS u ON o.user_id = u.userid WHERE u.NAME = "bob"`
+	ctx := plan.NewContext(sql)
+	ctx.Schema = joined
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v for %s", err, sql)
+	msgs := make([]schema.Message, 0)
+	job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+	assert.T(t, job.Setup() == nil)
+	err = job.Run()
+	assert.Tf(t, err == nil, "no error %v for %s", err, sql)
+	job.Close()
+	rows := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		rows = append(rows, fmt.Sprintf("%v", msg.(*datasource.SqlDriverMessageMap).Values()[:2]))
+	}
+	sort.Strings(rows)
+	assert.Equal(t, []string{"[10 bob]", "[11 bob]"}, rows)
+}
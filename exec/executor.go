@@ -8,6 +8,7 @@ import (
 	"github.com/araddon/qlbridge/datasource/membtree"
 	"github.com/araddon/qlbridge/plan"
 	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
 )
 
 var (
@@ -30,6 +31,7 @@ type JobExecutor struct {
 	Ctx      *plan.Context
 	distinct bool
 	children []Task
+	err      error // of Run, recorded on the span of the query
 }
 
 func NewExecutor(ctx *plan.Context, planner plan.Planner) *JobExecutor {
@@ -48,17 +50,29 @@ func BuildSqlJob(ctx *plan.Context) (*JobExecutor, error) {
 	}
 	taskRunner, ok := task.(TaskRunner)
 	if !ok {
-		return nil, fmt.Errorf("Expected TaskRunner but was %T", task)
+		err = fmt.Errorf("Expected TaskRunner but was %T", task)
+		ctx.EndTrace(err)
+		return nil, err
 	}
 	job.RootTask = taskRunner
 	return job, err
 }
 
 // Create Job made up of sub-tasks in DAG that is the
-//  plan for execution of this query/job
-func BuildSqlJobPlanned(planner plan.Planner, executor Executor, ctx *plan.Context) (Task, error) {
+//  plan for execution of this query/job.  If traced the span of the query
+//  is started, and ended on error or else by Close of the job.
+func BuildSqlJobPlanned(planner plan.Planner, executor Executor, ctx *plan.Context) (_ Task, err error) {
+
+	ctx.StartTrace()
+	defer func() {
+		if err != nil {
+			ctx.EndTrace(err)
+		}
+	}()
 
+	span := ctx.StartSpan("parse")
 	stmt, err := rel.ParseSql(ctx.Raw)
+	plan.EndSpan(span, err)
 	if err != nil {
 		u.Debugf("could not parse sql : %v", err)
 		return nil, err
@@ -68,7 +82,10 @@ func BuildSqlJobPlanned(planner plan.Planner, executor Executor, ctx *plan.Conte
 	}
 	ctx.Stmt = stmt
 
+	span = ctx.StartSpan("plan")
 	pln, err := plan.WalkStmt(ctx, stmt, planner)
+	ctx.TracePlan(span)
+	plan.EndSpan(span, err)
 
 	if err != nil {
 		return nil, err
@@ -120,6 +137,10 @@ func (m *JobExecutor) WalkPlan(p plan.Task) (Task, error) {
 		return m.Executor.WalkDelete(p)
 	case *plan.Command:
 		return m.Executor.WalkCommand(p)
+	case *plan.Create:
+		return m.Executor.WalkCreate(p)
+	case *plan.Analyze:
+		return m.Executor.WalkAnalyze(p)
 	}
 	panic(fmt.Sprintf("Not implemented for %T", p))
 }
@@ -136,6 +157,16 @@ func (m *JobExecutor) WalkUpsert(p *plan.Upsert) (Task, error) {
 }
 func (m *JobExecutor) WalkInsert(p *plan.Insert) (Task, error) {
 	root := m.NewTask(p)
+	if p.Select != nil {
+		// INSERT INTO ... SELECT  the select feeds the insert
+		sel, err := m.Executor.WalkSelect(p.Select)
+		if err != nil {
+			return nil, err
+		}
+		if err := root.Add(sel); err != nil {
+			return nil, err
+		}
+	}
 	return root, root.Add(NewInsert(m.Ctx, p))
 }
 func (m *JobExecutor) WalkUpdate(p *plan.Update) (Task, error) {
@@ -150,6 +181,14 @@ func (m *JobExecutor) WalkCommand(p *plan.Command) (Task, error) {
 	root := m.NewTask(p)
 	return root, root.Add(NewCommand(m.Ctx, p))
 }
+func (m *JobExecutor) WalkCreate(p *plan.Create) (Task, error) {
+	root := m.NewTask(p)
+	return root, root.Add(NewCreate(m.Ctx, p))
+}
+func (m *JobExecutor) WalkAnalyze(p *plan.Analyze) (Task, error) {
+	root := m.NewTask(p)
+	return root, root.Add(NewAnalyze(m.Ctx, p))
+}
 func (m *JobExecutor) WalkSource(p *plan.Source) (Task, error) {
 	if len(p.Static) > 0 {
 		static := membtree.NewStaticData("static")
@@ -165,7 +204,10 @@ func (m *JobExecutor) WalkSource(p *plan.Source) (Task, error) {
 			u.Warnf("no datasource")
 			return nil, fmt.Errorf("missing data source")
 		}
-		source, err := p.DataSource.Open(p.Stmt.SourceName())
+		name := p.Stmt.SourceName()
+		source, err := plan.OpenRetry(m.Ctx, nil, name, func() (schema.Conn, error) {
+			return p.DataSource.Open(name)
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -177,6 +219,9 @@ func (m *JobExecutor) WalkSource(p *plan.Source) (Task, error) {
 	if hasSourceExec {
 		return e.WalkExecSource(p)
 	}
+	if len(p.Partitions) > 0 {
+		return NewSourcePartitioned(m.Ctx, p)
+	}
 	return NewSource(m.Ctx, p)
 }
 func (m *JobExecutor) WalkSourceExec(p *plan.Source) (Task, error) {
@@ -186,7 +231,10 @@ func (m *JobExecutor) WalkSourceExec(p *plan.Source) (Task, error) {
 			u.Warnf("no datasource")
 			return nil, fmt.Errorf("missing data source")
 		}
-		source, err := p.DataSource.Open(p.Stmt.SourceName())
+		name := p.Stmt.SourceName()
+		source, err := plan.OpenRetry(m.Ctx, nil, name, func() (schema.Conn, error) {
+			return p.DataSource.Open(name)
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -207,15 +255,41 @@ func (m *JobExecutor) WalkHaving(p *plan.Having) (Task, error) {
 	return NewHaving(m.Ctx, p), nil
 }
 func (m *JobExecutor) WalkGroupBy(p *plan.GroupBy) (Task, error) {
+	if p.Final {
+		return NewGroupByFinal(m.Ctx, p), nil
+	}
+	if w := plan.WindowOf(p.Stmt); w != nil && !p.Partial {
+		return NewGroupByWindow(m.Ctx, p, w), nil
+	}
 	return NewGroupBy(m.Ctx, p), nil
 }
 func (m *JobExecutor) WalkOrder(p *plan.Order) (Task, error) {
 	return NewOrder(m.Ctx, p), nil
 }
+func (m *JobExecutor) WalkWindow(p *plan.Window) (Task, error) {
+	return NewWindow(m.Ctx, p), nil
+}
+func (m *JobExecutor) WalkDistinct(p *plan.Distinct) (Task, error) {
+	return NewDistinct(m.Ctx, p), nil
+}
 func (m *JobExecutor) WalkProjection(p *plan.Projection) (Task, error) {
 	return NewProjection(m.Ctx, p), nil
 }
 func (m *JobExecutor) WalkJoin(p *plan.JoinMerge) (Task, error) {
+	switch {
+	case len(p.Partitions) > 0:
+		jp, err := m.walkJoinColocated(p)
+		if err != nil {
+			return nil, err
+		}
+		return jp, nil
+	case p.Repartition > 1:
+		jp, err := m.walkJoinRepartition(p)
+		if err != nil {
+			return nil, err
+		}
+		return jp, nil
+	}
 	execTask := NewTaskParallel(m.Ctx)
 	//u.Debugf("join.Left: %#v    \nright:%#v", p.Left, p.Right)
 	l, err := m.WalkPlanAll(p.Left)
@@ -228,6 +302,17 @@ func (m *JobExecutor) WalkJoin(p *plan.JoinMerge) (Task, error) {
 		u.Errorf("whoops %T  %v", l, err)
 		return nil, err
 	}
+	if p.Algorithm == plan.JoinAlgorithmLookup {
+		// the right side is looked up by the join, not scanned
+		jl, err := NewJoinLookup(m.Ctx, l.(TaskRunner), p)
+		if err != nil {
+			return nil, err
+		}
+		if err = execTask.Add(jl); err != nil {
+			return nil, err
+		}
+		return execTask, nil
+	}
 	r, err := m.WalkPlanAll(p.Right)
 	if err != nil {
 		return nil, err
@@ -237,7 +322,7 @@ func (m *JobExecutor) WalkJoin(p *plan.JoinMerge) (Task, error) {
 		return nil, err
 	}
 
-	jm := NewJoinNaiveMerge(m.Ctx, l.(TaskRunner), r.(TaskRunner), p)
+	jm := newJoinTask(m.Ctx, l.(TaskRunner), r.(TaskRunner), p)
 	err = execTask.Add(jm)
 	if err != nil {
 		return nil, err
@@ -247,6 +332,61 @@ func (m *JobExecutor) WalkJoin(p *plan.JoinMerge) (Task, error) {
 func (m *JobExecutor) WalkJoinKey(p *plan.JoinKey) (Task, error) {
 	return NewJoinKey(m.Ctx, p), nil
 }
+func (m *JobExecutor) WalkExchange(p *plan.Exchange) (Task, error) {
+	fragments := make([]*TaskSequential, len(p.Fragments))
+	for i, frag := range p.Fragments {
+		fragments[i] = NewTaskSequential(m.Ctx)
+		if err := m.WalkChildren(frag, fragments[i]); err != nil {
+			return nil, err
+		}
+	}
+	return NewExchange(m.Ctx, fragments), nil
+}
+func (m *JobExecutor) WalkSemiJoin(p *plan.SemiJoin) (Task, error) {
+	sub, err := m.Executor.WalkSelect(p.Sub)
+	if err != nil {
+		return nil, err
+	}
+	subRunner, ok := sub.(TaskRunner)
+	if !ok {
+		return nil, fmt.Errorf("sub-query task must be a TaskRunner %T", sub)
+	}
+	return NewSemiJoin(m.Ctx, p, subRunner), nil
+}
+func (m *JobExecutor) WalkSetOperation(p *plan.SetOperation) (Task, error) {
+	left, err := m.walkSetInput(p.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := m.walkSetInput(p.Right)
+	if err != nil {
+		return nil, err
+	}
+	return NewSetOperation(m.Ctx, p, left, right), nil
+}
+
+// walkSetInput the task of an input of a set operation, a select or another
+// set operation
+func (m *JobExecutor) walkSetInput(p plan.Task) (TaskRunner, error) {
+	var t Task
+	var err error
+	switch pt := p.(type) {
+	case *plan.Select:
+		t, err = m.Executor.WalkSelect(pt)
+	case *plan.SetOperation:
+		t, err = m.Executor.WalkSetOperation(pt)
+	default:
+		return nil, fmt.Errorf("unsupported set operation input %T", p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	runner, ok := t.(TaskRunner)
+	if !ok {
+		return nil, fmt.Errorf("set operation input task must be a TaskRunner %T", t)
+	}
+	return runner, nil
+}
 func (m *JobExecutor) WalkPlanAll(p plan.Task) (Task, error) {
 	root, err := m.WalkPlanTask(p)
 	if err != nil {
@@ -281,12 +421,22 @@ func (m *JobExecutor) WalkPlanTask(p plan.Task) (Task, error) {
 		return m.Executor.WalkGroupBy(p)
 	case *plan.Order:
 		return m.Executor.WalkOrder(p)
+	case *plan.Window:
+		return m.Executor.WalkWindow(p)
+	case *plan.Distinct:
+		return m.Executor.WalkDistinct(p)
+	case *plan.SetOperation:
+		return m.Executor.WalkSetOperation(p)
 	case *plan.Projection:
 		return m.Executor.WalkProjection(p)
 	case *plan.JoinMerge:
 		return m.Executor.WalkJoin(p)
 	case *plan.JoinKey:
 		return m.Executor.WalkJoinKey(p)
+	case *plan.Exchange:
+		return m.Executor.WalkExchange(p)
+	case *plan.SemiJoin:
+		return m.Executor.WalkSemiJoin(p)
 	}
 	panic(fmt.Sprintf("Task plan-exec Not implemented for %T", p))
 }
@@ -343,18 +493,27 @@ func (m *JobExecutor) Setup() error {
 	return m.RootTask.Setup(0)
 }
 
-// Run this task
+// Run this task.  The job is stopped once the Context of the query is
+// cancelled or its Timeout passes, returning the error of the Context.
 func (m *JobExecutor) Run() error {
 	if m.Ctx != nil {
 		m.Ctx.DisableRecover = m.Ctx.DisableRecover
 	}
+	stop := m.watchCancel()
 	//u.Debugf("job run: %#v", m.RootTask)
-	return m.RootTask.Run()
+	err := m.RootTask.Run()
+	if cerr := stop(); cerr != nil {
+		err = cerr
+	}
+	m.err = err
+	return err
 }
 
-// Close the normal close of root task
+// Close the normal close of root task, ending the span of the query
 func (m *JobExecutor) Close() error {
-	return m.RootTask.Close()
+	err := m.RootTask.Close()
+	m.Ctx.EndTrace(m.err)
+	return err
 }
 
 // The drain is the last out channel, on last task
@@ -1,10 +1,10 @@
 package exec
 
 import (
-	"database/sql/driver"
 	"encoding/gob"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	u "github.com/araddon/gou"
@@ -13,6 +13,7 @@ import (
 	"github.com/araddon/qlbridge/expr"
 	"github.com/araddon/qlbridge/plan"
 	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
 	"github.com/araddon/qlbridge/value"
 	"github.com/araddon/qlbridge/vm"
 )
@@ -31,7 +32,8 @@ func init() {
 // Group by:   Sql Group By Operator
 //   creates a hashable key commposed of key = {each,value,of,column,in,groupby}
 //
-// A very stupid naive parallel groupby holds values in memory
+// A hash aggregate, holds the aggregate state of each group in memory
+// spilling partial aggregates to disk when over the AggMemory budget.
 //
 //   task   ->  groupby  -->
 //
@@ -39,11 +41,12 @@ type GroupBy struct {
 	*TaskBase
 	closed bool
 	p      *plan.GroupBy
+	gb     *aggTable
 }
 
 func NewGroupBy(ctx *plan.Context, p *plan.GroupBy) *GroupBy {
 	m := &GroupBy{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "groupby"),
 		p:        p,
 	}
 	return m
@@ -61,7 +64,7 @@ type GroupByFinal struct {
 
 func NewGroupByFinal(ctx *plan.Context, p *plan.GroupBy) *GroupByFinal {
 	m := &GroupByFinal{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "groupby"),
 		p:        p,
 		complete: make(chan bool),
 	}
@@ -72,21 +75,23 @@ func (m *GroupBy) Run() error {
 	defer m.Ctx.Recover()
 	defer close(m.msgOutCh)
 
-	outCh := m.MessageOut()
 	inCh := m.MessageIn()
 
 	columns := m.p.Stmt.Columns
 	colIndex := m.p.Stmt.ColIndexes()
 
-	aggs, err := buildAggs(m.p)
-	if err != nil {
+	if _, err := buildAggs(m.p); err != nil {
 		u.Warnf("Group By statement not supported? %v", err)
 		return err
 	}
 
-	// are are going to hold entire row in memory while we are calculating
-	//  so obviously not scalable.
-	gb := make(map[string][]*datasource.SqlDriverMessageMap)
+	// hash aggregate, only the aggregate state of each group is held in
+	// memory, spilling to disk past the AggMemory budget
+	gb := newAggTable(m.p, AggMemory(m.Ctx), tempDir(m.Ctx), newMemReservation(m.TaskBase, "groupby"))
+	defer gb.Close()
+	m.Lock()
+	m.gb = gb
+	m.Unlock()
 
 msgReadLoop:
 	for {
@@ -100,109 +105,144 @@ msgReadLoop:
 				//u.Debugf("NICE, got closed channel shutdown")
 				break msgReadLoop
 			} else {
-				var sdm *datasource.SqlDriverMessageMap
-
-				switch mt := msg.(type) {
-				case *datasource.SqlDriverMessageMap:
-					sdm = mt
-				default:
-
-					msgReader, isContextReader := msg.(expr.ContextReader)
-					if !isContextReader {
-						err := fmt.Errorf("To use Join must use SqlDriverMessageMap but got %T", msg)
-						u.Errorf("unrecognized msg %T", msg)
-						close(m.TaskBase.sigCh)
-						return err
-					}
-
-					sdm = datasource.NewSqlDriverMessageMapCtx(msg.Id(), msgReader, colIndex)
+				sdm, err := aggMessage(msg, colIndex)
+				if err != nil {
+					u.Errorf("unrecognized msg %T", msg)
+					close(m.TaskBase.sigCh)
+					return err
 				}
-
-				// We are going to use VM Engine to create a value for each statement in group by
-				//  then join each value together to create a unique key.
-				keys := make([]string, len(m.p.Stmt.GroupBy))
-				for i, col := range m.p.Stmt.GroupBy {
-					if col.Expr != nil {
-						if key, ok := vm.Eval(sdm, col.Expr); ok {
-							//u.Debugf("msgtype:%T  key:%q for-expr:%s", sdm, key, col.Expr)
-							keys[i] = key.ToString()
-						} else {
-							// Is this an error?
-							//u.Warnf("no key?  %s for %+v", col.Expr, sdm)
-						}
-					} else {
-						u.Warnf("no col.expr? %#v", col)
-					}
+				aggs, err := gb.group(groupKey(m.p, sdm))
+				if err != nil {
+					return err
+				}
+				aggregateRow(aggs, columns, sdm)
+				if err := gb.checkSpill(); err != nil {
+					u.Errorf("could not spill groups: %v", err)
+					return err
 				}
-				key := strings.Join(keys, ",")
-				//u.Infof("found key:%s for %+v", key, sdm)
-				gb[key] = append(gb[key], sdm)
 			}
 		}
 	}
 
-	i := uint64(0)
-	for key, v := range gb {
-		//u.Debugf("got %s:%v msgs", k, len(v))
+	return emitAggRows(m.TaskBase, gb, colIndex)
+}
 
-		for _, mm := range v {
-			for i, col := range columns {
-				//u.Debugf("col: idx:%v sidx: %v pidx:%v key:%v   %s", col.Index, col.SourceIndex, col.ParentIndex, col.Key(), col.Expr)
+// aggMessage the row of msg to aggregate
+func aggMessage(msg schema.Message, colIndex map[string]int) (*datasource.SqlDriverMessageMap, error) {
+	switch mt := msg.(type) {
+	case *datasource.SqlDriverMessageMap:
+		return mt, nil
+	default:
+		msgReader, isContextReader := msg.(expr.ContextReader)
+		if !isContextReader {
+			return nil, fmt.Errorf("To use Join must use SqlDriverMessageMap but got %T", msg)
+		}
+		return datasource.NewSqlDriverMessageMapCtx(msg.Id(), msgReader, colIndex), nil
+	}
+}
 
-				if col.Expr == nil {
-					u.Warnf("wat?   nil col expr? %#v", col)
-				} else {
-					v, ok := vm.Eval(mm, col.Expr)
-					//u.Infof("mt: %T  mm %#v", mm, mm)
-					if !ok || v == nil {
-						//u.Debugf("evaled nil? key=%v  val=%v expr:%s", col.Key(), v, col.Expr.String())
-						//u.Infof("mt: %T  mm %#v", mm, mm)
-						aggs[i].Do(value.NewNilValue())
-					} else {
-						//u.Debugf("evaled: key=%v  val=%v", col.Key(), v.Value())
-						aggs[i].Do(v)
-					}
-				}
+// groupKey the key of the group of row, the value of each group by
+// expression joined together
+func groupKey(p *plan.GroupBy, sdm *datasource.SqlDriverMessageMap) string {
+	// We are going to use VM Engine to create a value for each statement in group by
+	//  then join each value together to create a unique key.
+	keys := make([]string, len(p.Stmt.GroupBy))
+	for i, col := range p.Stmt.GroupBy {
+		if col.Expr != nil {
+			if key, ok := vm.Eval(sdm, col.Expr); ok {
+				//u.Debugf("msgtype:%T  key:%q for-expr:%s", sdm, key, col.Expr)
+				keys[i] = key.ToString()
+			} else {
+				// Is this an error?
+				//u.Warnf("no key?  %s for %+v", col.Expr, sdm)
 			}
+		} else {
+			u.Warnf("no col.expr? %#v", col)
 		}
+	}
+	return strings.Join(keys, ",")
+}
 
-		row := make([]driver.Value, len(columns))
-		for i, agg := range aggs {
-			row[i] = driver.Value(agg.Result())
-			agg.Reset()
-			//u.Debugf("agg result: %#v  %v", row[i], row[i])
+// aggregateRow evaluate each column for row into the aggregators of its group
+func aggregateRow(aggs []Aggregator, columns rel.Columns, sdm *datasource.SqlDriverMessageMap) {
+	for i, col := range columns {
+		//u.Debugf("col: idx:%v sidx: %v pidx:%v key:%v   %s", col.Index, col.SourceIndex, col.ParentIndex, col.Key(), col.Expr)
+		if col.Expr == nil {
+			u.Warnf("wat?   nil col expr? %#v", col)
+			continue
 		}
-
-		if m.p.Partial {
-			// Partial results, append key at end?  shouldn't be able to be fit in message itself?
-			row = append(row, key)
-			//u.Debugf("GroupBy output row? key:%s %#v", key, row)
+		var v value.Value
+		var ok bool
+		if aa, isArgAgg := aggs[i].(argAggregator); isArgAgg {
+			v, ok = aggArgValue(sdm, aa.arg())
+		} else {
+			v, ok = vm.Eval(sdm, col.Expr)
+		}
+		if !ok || v == nil {
+			//u.Debugf("evaled nil? key=%v  val=%v expr:%s", col.Key(), v, col.Expr.String())
+			aggs[i].Do(value.NewNilValue())
+		} else {
+			aggs[i].Do(v)
 		}
-		//u.Debugf("row: %v  cols:%v", row, colIndex)
-		outCh <- datasource.NewSqlDriverMessageMap(i, row, colIndex)
-		i++
 	}
+}
 
+// argAggregator an aggregate given the value of its argument of each row,
+// rather than of its column expression, as distinct counts and user defined
+// aggregates are.
+//
+//   count(DISTINCT user_id)
+//   approx_count_distinct(user_id)
+type argAggregator interface {
+	Aggregator
+	arg() expr.Node
+}
+
+// aggArg the argument of the aggregate func of column
+func aggArg(col *rel.Column) expr.Node {
+	if fn, ok := col.Expr.(*expr.FuncNode); ok && len(fn.Args) == 1 {
+		return fn.Args[0]
+	}
 	return nil
 }
 
+// aggArgValue the value of the argument of an aggregate for row, identities
+// looked up by name as the vm does the arguments of a function (the
+// qualified `users`.`email` of a rewritten column is not in row)
+func aggArgValue(sdm *datasource.SqlDriverMessageMap, arg expr.Node) (value.Value, bool) {
+	if in, ok := arg.(*expr.IdentityNode); ok && !in.IsBooleanIdentity() {
+		return sdm.Get(in.Text)
+	}
+	return vm.Eval(sdm, arg)
+}
+
+// Spills number of times the groups were spilled to disk, 0 if aggregated
+// in memory
+func (m *GroupBy) Spills() int {
+	m.Lock()
+	defer m.Unlock()
+	if m.gb == nil {
+		return 0
+	}
+	return int(atomic.LoadInt32(&m.gb.spilled))
+}
+
 func (m *GroupByFinal) Run() error {
 	defer m.Ctx.Recover()
 	defer close(m.msgOutCh)
 
-	outCh := m.MessageOut()
 	inCh := m.MessageIn()
 
 	columns := m.p.Stmt.Columns
 	colIndex := m.p.Stmt.ColIndexes()
 
 	m.p.Partial = false
-	aggs, err := buildAggs(m.p)
-	if err != nil {
+	if _, err := buildAggs(m.p); err != nil {
 		return err
 	}
 
-	gb := make(map[string][][]driver.Value)
+	gb := newAggTable(m.p, AggMemory(m.Ctx), tempDir(m.Ctx), newMemReservation(m.TaskBase, "groupby"))
+	defer gb.Close()
 
 msgReadLoop:
 	for {
@@ -226,9 +266,16 @@ msgReadLoop:
 					if !ok {
 						u.Warnf("expected key?  %#v", mt.Vals)
 					}
-					vals := mt.Vals[0 : len(mt.Vals)-1]
 					//u.Infof("found key:%s for %#v", key, mt.Vals)
-					gb[key] = append(gb[key], vals)
+					aggs, err := gb.group(key)
+					if err != nil {
+						return err
+					}
+					mergePartial(aggs, mt.Vals[0:len(mt.Vals)-1])
+					if err := gb.checkSpill(); err != nil {
+						u.Errorf("could not spill groups: %v", err)
+						return err
+					}
 				default:
 					err := fmt.Errorf("To use Join must use SqlDriverMessageMap but got %T", msg)
 					u.Errorf("unrecognized msg %T", msg)
@@ -239,46 +286,8 @@ msgReadLoop:
 		}
 	}
 
-	i := uint64(0)
-	for _, vals := range gb {
-		//u.Debugf("got %s:%v msgs", key, vals)
-
-		for _, dv := range vals {
-			for i, col := range columns {
-				//u.Debugf("col: idx:%v sidx: %v pidx:%v key:%v   %s", col.Index, col.SourceIndex, col.ParentIndex, col.Key(), col.Expr)
-				if i-1 >= len(dv) {
-					u.Errorf("what??? %v  dv: %d   %#v", i, len(dv), dv)
-				}
-				if col.Expr == nil {
-					u.Warnf("wat?   nil col expr? %#v", col)
-				} else {
-					v := dv[i]
-					switch vt := v.(type) {
-					case *AggPartial:
-						//u.Debugf("evaled: key=%v  val=%v", col.Key(), v.Value())
-						aggs[i].Merge(vt)
-					case AggPartial:
-						aggs[i].Merge(&vt)
-					case int64:
-						aggs[i].Merge(&AggPartial{Ct: vt})
-					case string:
-						aggs[i] = &groupByFunc{vt}
-					default:
-						u.Warnf("unhandled type: %#v", v)
-					}
-				}
-			}
-		}
-
-		row := make([]driver.Value, len(columns))
-		for i, agg := range aggs {
-			row[i] = driver.Value(agg.Result())
-			agg.Reset()
-			//u.Debugf("agg result: %#v  %v", row[i], row[i])
-		}
-		//u.Debugf("GroupBy output row? %v", row)
-		outCh <- datasource.NewSqlDriverMessageMap(i, row, colIndex)
-		i++
+	if err := emitAggRows(m.TaskBase, gb, colIndex); err != nil {
+		return err
 	}
 
 	m.isComplete = true
@@ -321,8 +330,11 @@ func (m *GroupByFinal) Close() error {
 }
 
 type AggPartial struct {
-	Ct int64
-	N  float64
+	Ct    int64
+	N     float64
+	Keys  []string       // distinct keys of count(DISTINCT)
+	Hll   []byte         // sketch registers of approx_count_distinct
+	State AggregateState // of a user defined aggregate, see RegisterAggregate
 }
 
 type AggFunc func(v value.Value)
@@ -369,8 +381,8 @@ func (m *sum) Result() interface{} {
 		return m.n
 	}
 	return &AggPartial{
-		m.ct,
-		m.n,
+		Ct: m.ct,
+		N:  m.n,
 	}
 }
 func (m *sum) Reset() { m.n = 0 }
@@ -402,8 +414,8 @@ func (m *avg) Result() interface{} {
 		return m.n / float64(m.ct)
 	}
 	return &AggPartial{
-		m.ct,
-		m.n,
+		Ct: m.ct,
+		N:  m.n,
 	}
 }
 func (m *avg) Reset() { m.n = 0; m.ct = 0 }
@@ -460,15 +472,35 @@ colLoop:
 		case *expr.FuncNode:
 
 			// TODO:  extract to a UDF Registry Similar to builtins
-			switch strings.ToLower(n.Name) {
+			name := strings.ToLower(n.Name)
+			switch {
+			case n.Distinct && name != "count":
+				return nil, fmt.Errorf("Not implemented groupby for DISTINCT of function: %s", col.Expr)
+			case (n.Distinct || name == "approx_count_distinct") && len(n.Args) != 1:
+				return nil, fmt.Errorf("Distinct count must have one argument: %s", col.Expr)
+			}
+			switch name {
 			case "avg":
 				aggs[colIdx] = NewAvg(col, p.Partial)
 			case "count":
-				aggs[colIdx] = NewCount(col)
+				if n.Distinct {
+					aggs[colIdx] = NewCountDistinct(col, p.Partial)
+				} else {
+					aggs[colIdx] = NewCount(col)
+				}
+			case "approx_count_distinct":
+				aggs[colIdx] = NewApproxCountDistinct(col, p.Partial)
 			case "sum":
 				aggs[colIdx] = NewSum(col, p.Partial)
 			default:
-				return nil, fmt.Errorf("Not implemented groupby for function: %s", col.Expr)
+				newState, ok := userAggregate(name)
+				if !ok {
+					return nil, fmt.Errorf("Not implemented groupby for function: %s", col.Expr)
+				}
+				if len(n.Args) != 1 {
+					return nil, fmt.Errorf("Aggregate must have one argument: %s", col.Expr)
+				}
+				aggs[colIdx] = NewUserAggregate(col, newState, p.Partial)
 			}
 		case *expr.BinaryNode:
 			// expression logic?
@@ -0,0 +1,140 @@
+package exec
+
+import (
+	"database/sql/driver"
+	"os"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	_ argAggregator = (*countDistinct)(nil)
+	_ argAggregator = (*approxCountDistinct)(nil)
+)
+
+// distinctKey the key of a value, equal for equal values of any type
+func distinctKey(v value.Value) string {
+	return canonicalKey([]driver.Value{v})
+}
+
+// countDistinct the exact count of distinct values, deduped by a dedupSet
+// which spills to disk past its budget.  Its partial is the distinct keys
+// themselves, so partials of the same value from different partitions are
+// not counted twice.
+type countDistinct struct {
+	partial bool
+	expr    expr.Node
+	seen    *dedupSet
+	n       int64 // new keys, not counting those spilled
+}
+
+func (m *countDistinct) arg() expr.Node { return m.expr }
+func (m *countDistinct) Do(v value.Value) {
+	if v == nil || v.Nil() {
+		return
+	}
+	m.add(distinctKey(v))
+}
+func (m *countDistinct) add(key string) {
+	if m.seen == nil {
+		res := &memReservation{account: plan.NewMemoryAccount(0), name: "count-distinct"}
+		m.seen = newDedupSet(AggMemoryDefault, os.TempDir(), res)
+	}
+	isNew, err := m.seen.Add(key, nil)
+	if err != nil {
+		u.Errorf("could not spill distinct values: %v", err)
+		return
+	}
+	if isNew {
+		m.n++
+	}
+}
+
+// Result the count, or partial of keys.  The keys are read once, spilled
+// keys are removed after.
+func (m *countDistinct) Result() interface{} {
+	if m.partial {
+		return m.partialResult()
+	}
+	n := m.n
+	if m.seen != nil {
+		err := m.seen.eachSpilled(func(*spillRow) bool {
+			n++
+			return true
+		})
+		if err != nil {
+			u.Errorf("could not read spilled distinct values: %v", err)
+		}
+	}
+	m.Reset()
+	return n
+}
+func (m *countDistinct) partialResult() *AggPartial {
+	keys := make([]string, 0, m.n)
+	if m.seen != nil {
+		err := m.seen.Keys(func(key string) bool {
+			keys = append(keys, key)
+			return true
+		})
+		if err != nil {
+			u.Errorf("could not read spilled distinct values: %v", err)
+		}
+	}
+	m.Reset()
+	return &AggPartial{Ct: int64(len(keys)), Keys: keys}
+}
+func (m *countDistinct) Reset() {
+	if m.seen != nil {
+		m.seen.Close()
+	}
+	m.seen = nil
+	m.n = 0
+}
+func (m *countDistinct) Merge(a *AggPartial) {
+	for _, key := range a.Keys {
+		m.add(key)
+	}
+}
+func NewCountDistinct(col *rel.Column, partial bool) Aggregator {
+	return &countDistinct{partial: partial, expr: aggArg(col)}
+}
+
+// approxCountDistinct the estimated count of distinct values of a
+// hyperLogLog sketch, of fixed memory however many values.  Its partial is
+// the registers of the sketch.
+type approxCountDistinct struct {
+	partial bool
+	expr    expr.Node
+	sketch  *hyperLogLog
+}
+
+func (m *approxCountDistinct) arg() expr.Node { return m.expr }
+func (m *approxCountDistinct) Do(v value.Value) {
+	if v == nil || v.Nil() {
+		return
+	}
+	m.sketch.Add(distinctKey(v))
+}
+func (m *approxCountDistinct) Result() interface{} {
+	if m.partial {
+		return m.partialResult()
+	}
+	return m.sketch.Count()
+}
+func (m *approxCountDistinct) partialResult() *AggPartial {
+	registers := make([]byte, len(m.sketch.registers))
+	copy(registers, m.sketch.registers)
+	return &AggPartial{Hll: registers}
+}
+func (m *approxCountDistinct) Reset() { m.sketch = newHyperLogLog() }
+func (m *approxCountDistinct) Merge(a *AggPartial) {
+	m.sketch.Merge(a.Hll)
+}
+func NewApproxCountDistinct(col *rel.Column, partial bool) Aggregator {
+	return &approxCountDistinct{partial: partial, expr: aggArg(col), sketch: newHyperLogLog()}
+}
@@ -0,0 +1,275 @@
+package exec
+
+import (
+	"bufio"
+	"database/sql/driver"
+	"encoding/gob"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/plan"
+)
+
+var _ = u.EMPTY
+
+const (
+	// AggMemoryDefault bytes of group state a group by holds in memory before
+	// spilling partial aggregates to disk.  Over-ride with plan.Context AggMemory.
+	AggMemoryDefault int64 = 64 * 1024 * 1024
+
+	// aggSpillPartitions number of files spilled groups are hash partitioned
+	// into, each partition is merged on its own so must fit in memory
+	aggSpillPartitions = 16
+)
+
+// AggMemory the memory budget for a group by from context, or default
+func AggMemory(ctx *plan.Context) int64 {
+	if ctx != nil && ctx.AggMemory > 0 {
+		return ctx.AggMemory
+	}
+	return AggMemoryDefault
+}
+
+// aggTable a hash aggregate, the aggregate state of each group by key.  Only
+// the state (count, sum) of a group is held, not its rows.  When the groups
+// exceed the memory budget their partial aggregates are spilled to files
+// hash partitioned on group key and the table is emptied.  On emit each
+// partition is read back and merged, as all partials of a group are in the
+// same partition.
+//
+//   rows -> [ groups ] -> spill -> partition-0 (file) -> merge -> emit
+//                              -> partition-n (file) -> merge -> emit
+type aggTable struct {
+	p       *plan.GroupBy
+	budget  int64
+	dir     string
+	groups  map[string][]Aggregator
+	used    int64
+	res     *memReservation
+	full    bool // over the memory limit of query
+	parts   []*aggPartition
+	spilled int32
+}
+
+type aggPartition struct {
+	f   *os.File
+	w   *bufio.Writer
+	enc *gob.Encoder
+}
+
+func newAggTable(p *plan.GroupBy, budget int64, dir string, res *memReservation) *aggTable {
+	return &aggTable{
+		p:      p,
+		budget: budget,
+		dir:    dir,
+		groups: make(map[string][]Aggregator),
+		res:    res,
+	}
+}
+
+// group the aggregators of the group for key, created if new
+func (m *aggTable) group(key string) ([]Aggregator, error) {
+	if aggs, ok := m.groups[key]; ok {
+		return aggs, nil
+	}
+	aggs, err := buildAggs(m.p)
+	if err != nil {
+		return nil, err
+	}
+	m.groups[key] = aggs
+	size := int64(96 + len(key) + 48*len(aggs))
+	m.used += size
+	if err := m.res.Grow(size); err != nil {
+		m.full = true
+	}
+	return aggs, nil
+}
+
+// checkSpill spill the groups if over memory budget, or memory limit of query
+func (m *aggTable) checkSpill() error {
+	if m.used <= m.budget && !m.full {
+		return nil
+	}
+	return m.spill()
+}
+
+func (m *aggTable) spill() error {
+	if m.parts == nil {
+		for i := 0; i < aggSpillPartitions; i++ {
+			f, err := ioutil.TempFile(m.dir, "qlbridge-agg-")
+			if err != nil {
+				return err
+			}
+			w := bufio.NewWriter(f)
+			m.parts = append(m.parts, &aggPartition{f: f, w: w, enc: gob.NewEncoder(w)})
+		}
+	}
+	hasher := fnv.New32a()
+	for key, aggs := range m.groups {
+		hasher.Reset()
+		hasher.Write([]byte(key))
+		part := m.parts[hasher.Sum32()%uint32(len(m.parts))]
+		row := spillRow{Key: key, Vals: partialValues(aggs)}
+		if err := part.enc.Encode(&row); err != nil {
+			return err
+		}
+	}
+	atomic.AddInt32(&m.spilled, 1)
+	m.groups = make(map[string][]Aggregator)
+	m.used = 0
+	m.full = false
+	m.res.Release()
+	return nil
+}
+
+// Emit a row per group, stops if emit returns false
+func (m *aggTable) Emit(emit func(row []driver.Value) bool) error {
+	if m.parts == nil {
+		m.emitGroups(emit)
+		return nil
+	}
+	// everything goes to the partitions so each group is merged in one place
+	if err := m.spill(); err != nil {
+		return err
+	}
+	for _, part := range m.parts {
+		if err := part.w.Flush(); err != nil {
+			return err
+		}
+		if _, err := part.f.Seek(0, 0); err != nil {
+			return err
+		}
+		dec := gob.NewDecoder(bufio.NewReader(part.f))
+		for {
+			var row spillRow
+			if err := dec.Decode(&row); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return err
+			}
+			aggs, err := m.group(row.Key)
+			if err != nil {
+				return err
+			}
+			mergePartial(aggs, row.Vals)
+		}
+		if !m.emitGroups(emit) {
+			return nil
+		}
+		// the groups of a partition must be merged in memory, over the
+		// limit or not
+		m.groups = make(map[string][]Aggregator)
+		m.used = 0
+		m.full = false
+		m.res.Release()
+	}
+	return nil
+}
+
+// emitAggRows send a message per group of the table to output of task
+func emitAggRows(m *TaskBase, gb *aggTable, colIndex map[string]int) error {
+	outCh := m.MessageOut()
+	i := uint64(0)
+	err := gb.Emit(func(row []driver.Value) bool {
+		//u.Debugf("row: %v  cols:%v", row, colIndex)
+		select {
+		case outCh <- datasource.NewSqlDriverMessageMap(i, row, colIndex):
+		case <-m.SigChan():
+			return false
+		}
+		i++
+		return true
+	})
+	if err != nil {
+		u.Errorf("could not merge spilled groups: %v", err)
+	}
+	return err
+}
+
+func (m *aggTable) emitGroups(emit func(row []driver.Value) bool) bool {
+	for key, aggs := range m.groups {
+		row := make([]driver.Value, len(aggs))
+		for i, agg := range aggs {
+			row[i] = driver.Value(agg.Result())
+		}
+		if m.p.Partial {
+			// Partial results, key at end to be merged by final groupby
+			row = append(row, key)
+		}
+		if !emit(row) {
+			return false
+		}
+	}
+	return true
+}
+
+// Close remove the spilled partitions
+func (m *aggTable) Close() error {
+	m.res.Release()
+	for _, part := range m.parts {
+		part.f.Close()
+		os.Remove(part.f.Name())
+	}
+	m.parts = nil
+	return nil
+}
+
+// partialValues the partial (mergeable) form of each aggregate of a group
+func partialValues(aggs []Aggregator) []driver.Value {
+	vals := make([]driver.Value, len(aggs))
+	for i, agg := range aggs {
+		switch at := agg.(type) {
+		case *sum:
+			vals[i] = &AggPartial{Ct: at.ct, N: at.n}
+		case *avg:
+			vals[i] = &AggPartial{Ct: at.ct, N: at.n}
+		case *count:
+			vals[i] = at.n
+		case *countDistinct:
+			vals[i] = at.partialResult()
+		case *approxCountDistinct:
+			vals[i] = at.partialResult()
+		case *userAgg:
+			vals[i] = at.partialResult()
+		case *groupByFunc:
+			vals[i] = at.last
+		default:
+			vals[i] = agg.Result()
+		}
+	}
+	return vals
+}
+
+// mergePartial merge partial aggregate values of a group into its aggregators
+func mergePartial(aggs []Aggregator, dv []driver.Value) {
+	for i := range aggs {
+		if i >= len(dv) {
+			u.Errorf("what??? %v  dv: %d   %#v", i, len(dv), dv)
+			return
+		}
+		if gbf, isGroupBy := aggs[i].(*groupByFunc); isGroupBy {
+			// group by column values pass through as is
+			gbf.last = dv[i]
+			continue
+		}
+		switch vt := dv[i].(type) {
+		case *AggPartial:
+			aggs[i].Merge(vt)
+		case AggPartial:
+			aggs[i].Merge(&vt)
+		case int64:
+			aggs[i].Merge(&AggPartial{Ct: vt})
+		case string:
+			aggs[i] = &groupByFunc{vt}
+		default:
+			u.Warnf("unhandled type: %#v", dv[i])
+		}
+	}
+}
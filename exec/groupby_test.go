@@ -0,0 +1,242 @@
+package exec_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+var _ plan.SourcePartialAggregator = (*partialSource)(nil)
+
+// findGroupBy the (non final) groupby task of a job
+func findGroupBy(t exec.Task) *exec.GroupBy {
+	if gb, ok := t.(*exec.GroupBy); ok {
+		return gb
+	}
+	for _, child := range t.Children() {
+		if gb := findGroupBy(child); gb != nil {
+			return gb
+		}
+	}
+	return nil
+}
+
+func TestGroupBySpill(t *testing.T) {
+	tests := []struct {
+		memory int64
+		spills bool
+	}{
+		{0, false},   // default budget fits in memory
+		{2048, true}, // groups spilled many times, merged from partitions
+	}
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("", "qlbridge-groupby")
+		assert.Tf(t, err == nil, "no error %v", err)
+		defer os.RemoveAll(dir)
+
+		ctx := plan.NewContext(`SELECT g, count(*) AS ct, sum(n) AS total, avg(n) AS a FROM numbers GROUP BY g`)
+		ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("counting")
+		ctx.AggMemory = tt.memory
+		ctx.TempDir = dir
+
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		rows := exec.NewResultRows(ctx, []string{"g", "ct", "total", "a"})
+		job.RootTask.Add(rows)
+		assert.T(t, job.Setup() == nil)
+		go job.Run()
+
+		dest := make([]driver.Value, 4)
+		read := 0
+		for ; rows.Next(dest) == nil; read++ {
+			var g int
+			fmt.Sscanf(dest[0].(string), "g%d", &g)
+			total := float64(100*g + 495000)
+			assert.Equalf(t, int64(100), dest[1], "count of %v", dest[0])
+			assert.Equalf(t, total, dest[2], "sum of %v", dest[0])
+			assert.Equalf(t, total/100, dest[3], "avg of %v", dest[0])
+		}
+		assert.Equal(t, 100, read)
+
+		gb := findGroupBy(job.RootTask)
+		assert.T(t, gb != nil)
+		assert.Equalf(t, tt.spills, gb.Spills() > 0, "spilled %d with memory %d", gb.Spills(), tt.memory)
+		job.Close()
+
+		files, _ := ioutil.ReadDir(dir)
+		assert.Equalf(t, 0, len(files), "spilled partitions should be removed %v", files)
+	}
+}
+
+// partialSource computes partial aggregates itself, as a sharded store that
+// aggregates per shard would.  Its rows are the per shard partial counts.
+type partialSource struct {
+	*membtree.StaticDataSource
+	accepted bool
+}
+
+func (m *partialSource) Open(name string) (schema.Conn, error) { return m, nil }
+func (m *partialSource) Next() schema.Message {
+	msg := m.StaticDataSource.Next()
+	if msg == nil {
+		return nil
+	}
+	// strip the id the btree is keyed on
+	sdm := msg.(*datasource.SqlDriverMessageMap)
+	return datasource.NewSqlDriverMessageMap(sdm.Id(), sdm.Vals[1:], nil)
+}
+func (m *partialSource) WalkPartialAggregate(s *plan.Source, gb *plan.GroupBy) (bool, error) {
+	m.accepted = gb.Partial
+	return m.accepted, nil
+}
+
+var partials = func() *partialSource {
+	// g, count(*), sum(n), group key;  two shards have a partial of "a"
+	rows := [][]driver.Value{
+		{"a", int64(2), &exec.AggPartial{Ct: 2, N: 10}, "a"},
+		{"b", int64(1), &exec.AggPartial{Ct: 1, N: 7}, "b"},
+		{"a", int64(3), &exec.AggPartial{Ct: 3, N: 5}, "a"},
+	}
+	for i, row := range rows {
+		rows[i] = append([]driver.Value{int64(i)}, row...)
+	}
+	src := &partialSource{StaticDataSource: membtree.NewStaticDataSource("shards", 0, rows, []string{"id", "g", "ct", "n", "key"})}
+	datasource.RegisterSchemaSource("partials", "partials", src)
+	return src
+}()
+
+func TestPartialAggregatePushdown(t *testing.T) {
+	sql := `SELECT g, count(*) AS ct, sum(n) AS total FROM shards GROUP BY g`
+	ctx := plan.NewContext(sql)
+	ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("partials")
+
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.T(t, partials.accepted)
+	assert.T(t, ctx.Metrics.Applied("partial-aggregate"))
+	assert.T(t, findGroupBy(job.RootTask) == nil)
+
+	rows := exec.NewResultRows(ctx, []string{"g", "ct", "total"})
+	job.RootTask.Add(rows)
+	assert.T(t, job.Setup() == nil)
+	go job.Run()
+	defer job.Close()
+
+	dest := make([]driver.Value, 3)
+	got := make([]string, 0)
+	for rows.Next(dest) == nil {
+		got = append(got, fmt.Sprintf("%v:%v:%v", dest[0], dest[1], dest[2]))
+	}
+	sort.Strings(got)
+	assert.Equal(t, "a:5:15,b:1:7", strings.Join(got, ","))
+}
+
+func TestCountDistinct(t *testing.T) {
+	for _, memory := range []int64{0, 2048} {
+		// spilled groups merge the partial distinct keys, and sketches
+		ctx := plan.NewContext(`SELECT g, count(DISTINCT n) AS d, count(DISTINCT g) AS gs, approx_count_distinct(n) AS a FROM numbers GROUP BY g`)
+		ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("counting")
+		ctx.AggMemory = memory
+
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		rows := exec.NewResultRows(ctx, []string{"g", "d", "gs", "a"})
+		job.RootTask.Add(rows)
+		assert.T(t, job.Setup() == nil)
+		go job.Run()
+
+		dest := make([]driver.Value, 4)
+		read := 0
+		for ; rows.Next(dest) == nil; read++ {
+			assert.Equalf(t, int64(100), dest[1], "distinct n of %v", dest[0])
+			assert.Equalf(t, int64(1), dest[2], "distinct g of %v", dest[0])
+			approx := dest[3].(int64)
+			assert.Tf(t, approx >= 90 && approx <= 110, "approx distinct n of %v was %d", dest[0], approx)
+		}
+		assert.Equal(t, 100, read)
+		job.Close()
+	}
+
+	ctx := plan.NewContext(`SELECT count(DISTINCT g) AS d, approx_count_distinct(n) AS a FROM numbers`)
+	ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("counting")
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	rows := exec.NewResultRows(ctx, []string{"d", "a"})
+	job.RootTask.Add(rows)
+	assert.T(t, job.Setup() == nil)
+	go job.Run()
+	defer job.Close()
+
+	dest := make([]driver.Value, 2)
+	assert.T(t, rows.Next(dest) == nil)
+	assert.Equal(t, int64(100), dest[0])
+	approx := dest[1].(int64)
+	assert.Tf(t, approx > 9700 && approx < 10300, "approx distinct of 10000 was %d", approx)
+}
+
+// bitOr a user defined aggregate, the bitwise or of integers
+type bitOr struct {
+	Bits int64
+}
+
+func (m *bitOr) Update(v value.Value) {
+	if n, ok := value.ValueToInt64(v); ok {
+		m.Bits |= n
+	}
+}
+func (m *bitOr) Merge(other exec.AggregateState) { m.Bits |= other.(*bitOr).Bits }
+func (m *bitOr) Result() value.Value             { return value.NewIntValue(m.Bits) }
+
+func TestUserAggregate(t *testing.T) {
+	exec.RegisterAggregate("bit_or", func() exec.AggregateState { return &bitOr{} })
+
+	tests := []struct {
+		memory      int64
+		parallelism int
+	}{
+		{0, 0},
+		{2048, 0}, // states spilled and merged
+		{0, 3},    // partial states of fragments merged by the final group by
+	}
+	for _, tt := range tests {
+		ctx := plan.NewContext(`SELECT g, bit_or(n) AS b, count(*) AS ct FROM numbers GROUP BY g`)
+		ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("counting")
+		ctx.AggMemory = tt.memory
+		ctx.Parallelism = tt.parallelism
+
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		rows := exec.NewResultRows(ctx, []string{"g", "b", "ct"})
+		job.RootTask.Add(rows)
+		assert.T(t, job.Setup() == nil)
+		go job.Run()
+
+		dest := make([]driver.Value, 3)
+		read := 0
+		for ; rows.Next(dest) == nil; read++ {
+			var g int64
+			fmt.Sscanf(dest[0].(string), "g%d", &g)
+			bits := int64(0)
+			for n := g; n < 10000; n += 100 {
+				bits |= n
+			}
+			assert.Equalf(t, bits, dest[1], "bit_or of %v", dest[0])
+			assert.Equalf(t, int64(100), dest[2], "count of %v", dest[0])
+		}
+		assert.Equal(t, 100, read)
+		job.Close()
+	}
+}
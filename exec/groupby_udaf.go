@@ -0,0 +1,99 @@
+package exec
+
+import (
+	"encoding/gob"
+	"strings"
+	"sync"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	_ argAggregator = (*userAgg)(nil)
+
+	udafMu sync.RWMutex
+	udafs  = make(map[string]func() AggregateState)
+)
+
+// AggregateState the state of a user defined aggregate for one group, see
+// RegisterAggregate.  States of a group aggregated apart (partial aggregates
+// of partitions, shards, or spilled to disk) are merged, so a state is gob
+// encoded and must have exported fields.
+type AggregateState interface {
+	// Update the state with the value of the argument of a row, NULL values
+	// are skipped
+	Update(v value.Value)
+	// Merge another state of the same aggregate and group into this one
+	Merge(other AggregateState)
+	// Result the value of the aggregate, nil for NULL
+	Result() value.Value
+}
+
+// RegisterAggregate a user defined aggregate function of one argument, of
+// name usable in GROUP BY queries, partial aggregation and merges of
+// partials.  newState makes the empty state of a group.
+//
+//   exec.RegisterAggregate("bit_or", func() exec.AggregateState { return &BitOr{} })
+//
+//   SELECT domain, bit_or(flags) FROM users GROUP BY domain
+//
+func RegisterAggregate(name string, newState func() AggregateState) {
+	name = strings.ToLower(name)
+	gob.Register(newState())
+	udafMu.Lock()
+	udafs[name] = newState
+	udafMu.Unlock()
+	// the value of a row is its argument, the state aggregates it
+	expr.AggFuncAdd(name, func(ctx expr.EvalContext, v value.Value) (value.Value, bool) {
+		return v, true
+	})
+}
+
+// userAggregate the state maker of a registered aggregate of name
+func userAggregate(name string) (func() AggregateState, bool) {
+	udafMu.RLock()
+	defer udafMu.RUnlock()
+	newState, ok := udafs[name]
+	return newState, ok
+}
+
+// userAgg the Aggregator of a user defined aggregate, its partial is the
+// state itself
+type userAgg struct {
+	partial  bool
+	expr     expr.Node
+	newState func() AggregateState
+	state    AggregateState
+}
+
+func (m *userAgg) arg() expr.Node { return m.expr }
+func (m *userAgg) Do(v value.Value) {
+	if v == nil || v.Nil() {
+		return
+	}
+	m.state.Update(v)
+}
+func (m *userAgg) Result() interface{} {
+	if m.partial {
+		return m.partialResult()
+	}
+	v := m.state.Result()
+	if v == nil || v.Nil() {
+		return nil
+	}
+	return v.Value()
+}
+func (m *userAgg) partialResult() *AggPartial {
+	return &AggPartial{State: m.state}
+}
+func (m *userAgg) Reset() { m.state = m.newState() }
+func (m *userAgg) Merge(a *AggPartial) {
+	if a.State != nil {
+		m.state.Merge(a.State)
+	}
+}
+func NewUserAggregate(col *rel.Column, newState func() AggregateState, partial bool) Aggregator {
+	return &userAgg{partial: partial, expr: aggArg(col), newState: newState, state: newState()}
+}
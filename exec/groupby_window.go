@@ -0,0 +1,289 @@
+package exec
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/gob"
+	"math"
+	"sort"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/value"
+	"github.com/araddon/qlbridge/vm"
+)
+
+var (
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*GroupByWindow)(nil)
+)
+
+// GroupByWindow a group by over tumbling windows of event time (see
+// plan.TumbleWindow), for queries over a stream that never ends.  The
+// groups of each open window are held in memory, once a row arrives whose
+// time is past the end of a window (plus WindowLateness) the window is
+// closed, its groups emitted and dropped.  Rows of a closed window that
+// arrive after are dropped.  The windows still open are emitted when the
+// input ends.
+//
+//   rows  ->  [ window-0 groups ]  -- closed -->  emit
+//             [ window-1 groups ]
+//
+// If the Context is Checkpointing the open windows are saved each time
+// windows are emitted (and when stopped), and restored when the query is
+// restarted.  Rows of windows already emitted are dropped as late, so a
+// source that replays from an earlier position does not emit them twice.
+type GroupByWindow struct {
+	*TaskBase
+	p         *plan.GroupBy
+	w         *plan.TumbleWindow
+	windows   map[int64]*aggWindow // by window start, unix nanos
+	watermark int64                // latest event time seen, unix nanos
+	emitted   int64                // windows starting before are closed
+	dropped   int64
+	res       *memReservation
+	id        uint64
+}
+
+// aggWindow the groups of one window
+type aggWindow struct {
+	groups map[string][]Aggregator
+	used   int64
+}
+
+type windowStarts []int64
+
+func (m windowStarts) Len() int           { return len(m) }
+func (m windowStarts) Less(i, j int) bool { return m[i] < m[j] }
+func (m windowStarts) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+
+// windowCheckpoint the state of a GroupByWindow, the partial aggregates of
+// the groups of each open window
+type windowCheckpoint struct {
+	Watermark int64
+	Emitted   int64
+	Windows   map[int64][]spillRow
+}
+
+// NewGroupByWindow a group by over the tumbling windows w
+func NewGroupByWindow(ctx *plan.Context, p *plan.GroupBy, w *plan.TumbleWindow) *GroupByWindow {
+	return &GroupByWindow{
+		TaskBase: NewTaskBaseNamed(ctx, "groupby"),
+		p:        p,
+		w:        w,
+		windows:  make(map[int64]*aggWindow),
+		emitted:  math.MinInt64,
+	}
+}
+
+// Dropped number of rows dropped, as they were late for their window or had
+// no event time
+func (m *GroupByWindow) Dropped() int64 {
+	m.Lock()
+	defer m.Unlock()
+	return m.dropped
+}
+
+func (m *GroupByWindow) Run() error {
+	defer m.Ctx.Recover()
+	defer close(m.msgOutCh)
+
+	if _, err := buildAggs(m.p); err != nil {
+		u.Warnf("Group By statement not supported? %v", err)
+		return err
+	}
+	m.res = newMemReservation(m.TaskBase, "groupby")
+	defer m.res.Release()
+	if err := m.restore(); err != nil {
+		return err
+	}
+
+	inCh := m.MessageIn()
+	columns := m.p.Stmt.Columns
+	colIndex := m.p.Stmt.ColIndexes()
+	lateness := m.Ctx.WindowLateness.Nanoseconds()
+
+	for {
+		select {
+		case <-m.SigChan():
+			return m.checkpoint()
+		case msg, ok := <-inCh:
+			if !ok {
+				select {
+				case <-m.SigChan():
+					// stopped, not the end of the stream
+					return m.checkpoint()
+				default:
+				}
+				// end of the stream, the open windows are complete
+				if m.emit(math.MaxInt64) {
+					return m.checkpoint()
+				}
+				return nil
+			}
+			sdm, err := aggMessage(msg, colIndex)
+			if err != nil {
+				return err
+			}
+			ts, ok := m.eventTime(sdm)
+			start := ts.Truncate(m.w.Size).UnixNano()
+			if !ok || start < m.emitted {
+				m.Lock()
+				m.dropped++
+				m.Unlock()
+				continue
+			}
+			aggs, err := m.group(start, groupKey(m.p, sdm))
+			if err != nil {
+				return err
+			}
+			aggregateRow(aggs, columns, sdm)
+
+			if ts.UnixNano() <= m.watermark {
+				continue
+			}
+			m.watermark = ts.UnixNano()
+			// windows ending (plus lateness) at or before the watermark
+			cut := m.watermark - m.w.Size.Nanoseconds() - lateness + 1
+			if cut <= m.emitted {
+				continue
+			}
+			n := len(m.windows)
+			if !m.emit(cut) {
+				return nil
+			}
+			if len(m.windows) < n {
+				if err := m.checkpoint(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// eventTime the time of row, integers are unix seconds
+func (m *GroupByWindow) eventTime(sdm *datasource.SqlDriverMessageMap) (time.Time, bool) {
+	v, ok := vm.Eval(sdm, m.w.Time)
+	if !ok || v == nil || v.Nil() {
+		return time.Time{}, false
+	}
+	if iv, isInt := v.(value.IntValue); isInt {
+		return time.Unix(iv.Val(), 0), true
+	}
+	return value.ValueToTime(v)
+}
+
+// group the aggregators of the group for key of the window, created if new
+func (m *GroupByWindow) group(start int64, key string) ([]Aggregator, error) {
+	w, ok := m.windows[start]
+	if !ok {
+		w = &aggWindow{groups: make(map[string][]Aggregator)}
+		m.windows[start] = w
+	}
+	if aggs, ok := w.groups[key]; ok {
+		return aggs, nil
+	}
+	aggs, err := buildAggs(m.p)
+	if err != nil {
+		return nil, err
+	}
+	w.groups[key] = aggs
+	size := int64(96 + len(key) + 48*len(aggs))
+	w.used += size
+	return aggs, m.res.Grow(size)
+}
+
+// emit the groups of the windows starting before cut in order of time, and
+// drop them.  Returns false on quit.
+func (m *GroupByWindow) emit(cut int64) bool {
+	starts := make(windowStarts, 0, len(m.windows))
+	for start := range m.windows {
+		if start < cut {
+			starts = append(starts, start)
+		}
+	}
+	sort.Sort(starts)
+	colIndex := m.p.Stmt.ColIndexes()
+	for _, start := range starts {
+		for _, aggs := range m.windows[start].groups {
+			row := make([]driver.Value, len(aggs))
+			for i, agg := range aggs {
+				row[i] = driver.Value(agg.Result())
+			}
+			select {
+			case m.msgOutCh <- datasource.NewSqlDriverMessageMap(m.id, row, colIndex):
+				m.id++
+			case <-m.SigChan():
+				return false
+			}
+		}
+		delete(m.windows, start)
+	}
+	if cut > m.emitted {
+		m.emitted = cut
+	}
+	// only the open windows are held
+	m.res.Release()
+	for _, w := range m.windows {
+		m.res.Grow(w.used)
+	}
+	return true
+}
+
+// checkpoint save the open windows, if checkpointing
+func (m *GroupByWindow) checkpoint() error {
+	if !m.Ctx.Checkpointing() {
+		return nil
+	}
+	state := windowCheckpoint{
+		Watermark: m.watermark,
+		Emitted:   m.emitted,
+		Windows:   make(map[int64][]spillRow, len(m.windows)),
+	}
+	for start, w := range m.windows {
+		rows := make([]spillRow, 0, len(w.groups))
+		for key, aggs := range w.groups {
+			rows = append(rows, spillRow{Key: key, Vals: partialValues(aggs)})
+		}
+		state.Windows[start] = rows
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&state); err != nil {
+		return err
+	}
+	return m.Ctx.Checkpoints.Save(m.checkpointKey(), buf.Bytes())
+}
+
+// restore the open windows of the last checkpoint, if any
+func (m *GroupByWindow) restore() error {
+	if !m.Ctx.Checkpointing() {
+		return nil
+	}
+	raw, err := m.Ctx.Checkpoints.Load(m.checkpointKey())
+	if err != nil || raw == nil {
+		return err
+	}
+	var state windowCheckpoint
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&state); err != nil {
+		return err
+	}
+	m.watermark, m.emitted = state.Watermark, state.Emitted
+	for start, rows := range state.Windows {
+		for _, row := range rows {
+			aggs, err := m.group(start, row.Key)
+			if err != nil {
+				return err
+			}
+			mergePartial(aggs, row.Vals)
+		}
+	}
+	u.Debugf("restored %d windows of %q", len(state.Windows), m.Ctx.CheckpointKey)
+	return nil
+}
+
+func (m *GroupByWindow) checkpointKey() string {
+	return m.Ctx.CheckpointKey + ".groupby"
+}
@@ -0,0 +1,99 @@
+package exec_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+)
+
+// checkedSource a source of the health of err
+type checkedSource struct {
+	*membtree.StaticDataSource
+	mu  sync.Mutex
+	err error
+}
+
+func (m *checkedSource) CheckHealth() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+func TestSourceHealth(t *testing.T) {
+	src := &checkedSource{StaticDataSource: membtree.NewStaticDataSource("checked", 0,
+		[][]driver.Value{{int64(1)}}, []string{"id"})}
+	sch := datasource.RegisterSchemaSource("checked", "checked", src)
+	reg := datasource.DataSourcesRegistry()
+
+	query := func(sql string, cols []string) ([][]driver.Value, error) {
+		ctx := plan.NewContext(sql)
+		ctx.Schema = sch
+		job, err := exec.BuildSqlJob(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out := exec.NewResultRows(ctx, cols)
+		job.RootTask.Add(out)
+		assert.T(t, job.Setup() == nil)
+		go job.Run()
+		rows := make([][]driver.Value, 0)
+		for {
+			dest := make([]driver.Value, len(cols))
+			if out.Next(dest) != nil {
+				break
+			}
+			rows = append(rows, dest)
+		}
+		job.Close()
+		return rows, nil
+	}
+	health := func() [][]driver.Value {
+		rows, err := query(`SELECT source, healthy, last_error, failures FROM schema._health WHERE source = "checked"`,
+			[]string{"source", "healthy", "last_error", "failures"})
+		assert.Tf(t, err == nil, "no error %v", err)
+		return rows
+	}
+
+	reg.CheckHealth()
+	assert.Equal(t, [][]driver.Value{{"checked", true, nil, int64(0)}}, health())
+	rows, err := query(`SELECT id FROM checked`, []string{"id"})
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, 1, len(rows))
+
+	// queries of an unhealthy source fail fast
+	src.mu.Lock()
+	src.err = fmt.Errorf("connection refused")
+	src.mu.Unlock()
+	reg.CheckHealth()
+	reg.CheckHealth()
+	assert.Equal(t, [][]driver.Value{{"checked", false, "connection refused", int64(2)}}, health())
+	_, err = query(`SELECT id FROM checked`, []string{"id"})
+	assert.Tf(t, err != nil && strings.Contains(err.Error(), "unhealthy"), "unhealthy %v", err)
+
+	// until checked healthy again, in the background
+	src.mu.Lock()
+	src.err = nil
+	src.mu.Unlock()
+	reg.StartHealthChecks(time.Millisecond)
+	for i := 0; i < 100; i++ {
+		if _, err = query(`SELECT id FROM checked`, []string{"id"}); err == nil {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	reg.StopHealthChecks()
+	assert.Tf(t, err == nil, "no error %v", err)
+	h := health()[0]
+	assert.Equal(t, true, h[1])
+	assert.Equal(t, "connection refused", h[2])
+}
@@ -0,0 +1,82 @@
+package exec
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+const (
+	// hllPrecision bits of the hash that pick a register, 2^12 registers
+	// of a byte each for a standard error of about 1.6%
+	hllPrecision = 12
+	hllRegisters = 1 << hllPrecision
+)
+
+// hyperLogLog a sketch of the number of distinct keys added, of fixed size
+// however many there are.  Each key is hashed, the first bits of the hash
+// pick a register which holds the most leading zeros seen in the rest.
+// Sketches merge by taking the max of each register, so partial sketches
+// (of partitions, shards) merge to the sketch of all keys.
+type hyperLogLog struct {
+	registers []byte
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{registers: make([]byte, hllRegisters)}
+}
+
+// Add a key to the sketch
+func (m *hyperLogLog) Add(key string) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	x := hllMix(h.Sum64())
+	idx := x >> (64 - hllPrecision)
+	// the sentinel bit bounds the leading zeros of the remaining bits
+	w := x<<hllPrecision | 1<<(hllPrecision-1)
+	if rho := byte(bits.LeadingZeros64(w) + 1); rho > m.registers[idx] {
+		m.registers[idx] = rho
+	}
+}
+
+// Merge the registers of another sketch into this, registers of a different
+// size are ignored
+func (m *hyperLogLog) Merge(registers []byte) {
+	if len(registers) != len(m.registers) {
+		return
+	}
+	for i, r := range registers {
+		if r > m.registers[i] {
+			m.registers[i] = r
+		}
+	}
+}
+
+// Count the estimated number of distinct keys added
+func (m *hyperLogLog) Count() int64 {
+	n := float64(len(m.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range m.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	est := 0.7213 / (1 + 1.079/n) * n * n / sum
+	if est <= 2.5*n && zeros > 0 {
+		// small cardinalities, linear counting of the empty registers
+		est = n * math.Log(n/float64(zeros))
+	}
+	return int64(est + 0.5)
+}
+
+// hllMix the murmur3 64 bit finalizer, so the bits of short similar keys
+// are spread across the whole hash
+func hllMix(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -9,9 +9,11 @@ import (
 	u "github.com/araddon/gou"
 
 	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
 	"github.com/araddon/qlbridge/plan"
 	"github.com/araddon/qlbridge/rel"
 	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
 	"github.com/araddon/qlbridge/vm"
 )
 
@@ -44,7 +46,7 @@ type JoinKey struct {
 //
 func NewJoinKey(ctx *plan.Context, p *plan.JoinKey) *JoinKey {
 	m := &JoinKey{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "joinkey"),
 		colIndex: make(map[string]int),
 		p:        p,
 	}
@@ -72,23 +74,14 @@ func (m *JoinKey) Run() error {
 			}
 
 			//u.Infof("In joinkey msg %#v", msg)
-		msgTypeSwitch:
 			switch mt := msg.(type) {
 			case *datasource.SqlDriverMessageMap:
-				vals := make([]string, len(joinNodes))
-				for i, node := range joinNodes {
-					joinVal, ok := vm.Eval(mt, node)
-					//u.Debugf("evaluating: ok?%v T:%T result=%v node '%v'", ok, joinVal, joinVal.ToString(), node.String())
-					if !ok {
-						u.Errorf("could not evaluate: %T %#v   %v", joinVal, joinVal, msg)
-						break msgTypeSwitch
-					}
-					vals[i] = joinVal.ToString()
+				mt.SetKeyHashed(joinKey(mt, joinNodes))
+				select {
+				case outCh <- mt:
+				case <-m.SigChan():
+					return nil
 				}
-				//u.Infof("joinkey: %v row:%v", vals, mt)
-				key := strings.Join(vals, string(byte(0)))
-				mt.SetKeyHashed(key)
-				outCh <- mt
 			default:
 				return fmt.Errorf("To use JoinKey must use SqlDriverMessageMap but got %T", msg)
 			}
@@ -98,6 +91,24 @@ func (m *JoinKey) Run() error {
 	return nil
 }
 
+// joinKey the key of row for the join expressions, empty if any of them
+// evaluates to NULL.  Keys of values start with a \x01 so the key of an
+// empty string value is not that of NULL.
+func joinKey(row expr.EvalContext, joinNodes []expr.Node) string {
+	vals := make([]string, len(joinNodes))
+	for i, node := range joinNodes {
+		joinVal, ok := vm.Eval(row, node)
+		//u.Debugf("evaluating: ok?%v T:%T result=%v node '%v'", ok, joinVal, joinVal.ToString(), node.String())
+		if !ok || joinVal == nil || joinVal.Type() == value.NilType {
+			// NULL keys never match, but are still forwarded
+			// as outer joins keep the row
+			return ""
+		}
+		vals[i] = joinVal.ToString()
+	}
+	return "\x01" + strings.Join(vals, string(byte(0)))
+}
+
 // Scans 2 source tasks for rows, evaluate keys, use for join
 //
 type JoinMerge struct {
@@ -131,7 +142,7 @@ type JoinMerge struct {
 func NewJoinNaiveMerge(ctx *plan.Context, l, r TaskRunner, p *plan.JoinMerge) *JoinMerge {
 
 	m := &JoinMerge{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "join"),
 		colIndex: p.ColIndex,
 	}
 
@@ -177,15 +188,13 @@ func (m *JoinMerge) Run() error {
 					case *datasource.SqlDriverMessageMap:
 						key := mt.Key()
 						if key == "" {
-							fatalErr = fmt.Errorf(`To use Join msgs must have keys but got "" for %+v`, mt)
-							u.Errorf("no key? %#v  %v", mt, fatalErr)
-							close(m.TaskBase.sigCh)
-							return
+							// NULL join key, never matches
+							continue
 						}
 						lh[key] = append(lh[key], mt)
 					default:
 						fatalErr = fmt.Errorf("To use Join must use SqlDriverMessageMap but got %T", msg)
-						u.Errorf("unrecognized msg %T", msg)
+						u.Errorf("unrecognized msg %v", fatalErr)
 						close(m.TaskBase.sigCh)
 						return
 					}
@@ -215,15 +224,13 @@ func (m *JoinMerge) Run() error {
 					case *datasource.SqlDriverMessageMap:
 						key := mt.Key()
 						if key == "" {
-							fatalErr = fmt.Errorf(`To use Join msgs must have keys but got "" for %+v`, mt)
-							u.Errorf("no key? %#v  %v", mt, fatalErr)
-							close(m.TaskBase.sigCh)
-							return
+							// NULL join key, never matches
+							continue
 						}
 						rh[key] = append(rh[key], mt)
 					default:
 						fatalErr = fmt.Errorf("To use Join must use SqlDriverMessageMap but got %T", msg)
-						u.Errorf("unrecognized msg %T", msg)
+						u.Errorf("unrecognized msg %v", fatalErr)
 						close(m.TaskBase.sigCh)
 						return
 					}
@@ -238,38 +245,30 @@ func (m *JoinMerge) Run() error {
 	for keyLeft, valLeft := range lh {
 		//u.Debugf("compare:  key:%v  left:%#v  right:%#v  rh: %#v", keyLeft, valLeft, rh[keyLeft], rh)
 		if valRight, ok := rh[keyLeft]; ok {
-			//u.Debugf("found match?\n\t%d left=%#v\n\t%d right=%#v", len(valLeft), valLeft, len(valRight), valRight)
-			msgs := m.mergeValueMessages(valLeft, valRight)
-			//u.Debugf("msgsct: %v   msgs:%#v", len(msgs), msgs)
-			for _, msg := range msgs {
-				//outCh <- datasource.NewUrlValuesMsg(i, msg)
-				//u.Debugf("i:%d   msg:%#v", i, msg)
-				msg.IdVal = i
-				i++
-				outCh <- msg
+			// emit merged rows as they are created rather than building the
+			// cross product of all rows for this key first
+			for _, lm := range valLeft {
+				for _, rm := range valRight {
+					msg := m.mergeValueMessage(lm, rm)
+					msg.IdVal = i
+					i++
+					select {
+					case outCh <- msg:
+					case <-m.SigChan():
+						return nil
+					}
+				}
 			}
 		}
 	}
 	return nil
 }
 
-func (m *JoinMerge) mergeValueMessages(lmsgs, rmsgs []*datasource.SqlDriverMessageMap) []*datasource.SqlDriverMessageMap {
-	// m.leftStmt.Columns, m.rightStmt.Columns, nil
-	//func mergeValuesMsgs(lmsgs, rmsgs []datasource.Message, lcols, rcols []*rel.Column, cols map[string]*rel.Column) []*datasource.SqlDriverMessageMap {
-	out := make([]*datasource.SqlDriverMessageMap, 0)
-	//u.Infof("merge values: %v:%v", len(lcols), len(rcols))
-	for _, lm := range lmsgs {
-		//u.Warnf("nice SqlDriverMessageMap: %#v", lmt)
-		for _, rm := range rmsgs {
-			vals := make([]driver.Value, len(m.colIndex))
-			vals = m.valIndexing(vals, lm.Values(), m.leftStmt.Source.Columns)
-			vals = m.valIndexing(vals, rm.Values(), m.rightStmt.Source.Columns)
-			newMsg := datasource.NewSqlDriverMessageMap(0, vals, m.colIndex)
-			//u.Infof("out: %+v", newMsg)
-			out = append(out, newMsg)
-		}
-	}
-	return out
+func (m *JoinMerge) mergeValueMessage(lm, rm *datasource.SqlDriverMessageMap) *datasource.SqlDriverMessageMap {
+	vals := make([]driver.Value, len(m.colIndex))
+	vals = m.valIndexing(vals, lm.Values(), m.leftStmt.Source.Columns)
+	vals = m.valIndexing(vals, rm.Values(), m.rightStmt.Source.Columns)
+	return datasource.NewSqlDriverMessageMap(0, vals, m.colIndex)
 }
 
 func (m *JoinMerge) valIndexing(valOut, valSource []driver.Value, cols []*rel.Column) []driver.Value {
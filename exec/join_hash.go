@@ -0,0 +1,530 @@
+package exec
+
+import (
+	"bufio"
+	"database/sql/driver"
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*JoinHash)(nil)
+)
+
+// JoinHash a build/probe hash join.  The build side is read entirely into a
+// hash table of join key, then rows of the probe side are streamed through
+// it emitting joined rows as they are found so only the build side is held
+// in memory.
+//
+//   build  (right)  ->  hash-table
+//                            |
+//   probe  (left)   ->     probe    -->  output
+//
+// The right side is built, as is typical of a large table joined to a small
+// lookup, except for RIGHT JOIN which builds the left and probes with the
+// right, and inner joins of a left side estimated smaller by the stats of
+// its table (plan.JoinMerge BuildLeft).  Outer joins emit preserved rows
+// that have no match with NULL values for the other side, for OUTER JOIN
+// (both sides preserved) the un-matched build rows are emitted after the
+// probe completes.
+//
+// If the build side turns out larger than JoinMemory (or the memory limit
+// of the query) the join adapts:  it reads the probe side, and if that fits
+// flips the sides to build the probe side and probe with the rows of the
+// build side.  If neither fits both sides are spilled to temp files hash
+// partitioned on join key, and each partition is joined on its own.  The
+// adaption is recorded with plan.Context Adapted.
+//
+//   build  -> over memory -> read probe -> fits      -> flip, build probe side
+//                                       -> over memory -> spill:
+//   build partition-0 (file) -> hash-table <- probe partition-0 (file)
+//   build partition-n (file) -> hash-table <- probe partition-n (file)
+type JoinHash struct {
+	*TaskBase
+	leftStmt      *rel.SqlSource
+	rightStmt     *rel.SqlSource
+	ltask         TaskRunner
+	rtask         TaskRunner
+	colIndex      map[string]int
+	buildLeft     bool // build hash table of left side, probe with right
+	preserveProbe bool // emit probe rows without match
+	preserveBuild bool // emit build rows without match
+	id            uint64
+}
+
+const (
+	// JoinMemoryDefault bytes of build side rows a hash join holds in memory
+	// before it adapts.  Over-ride with plan.Context JoinMemory.
+	JoinMemoryDefault int64 = 64 * 1024 * 1024
+
+	// joinSpillPartitions number of files each side of a spilled hash join
+	// is partitioned into, the build side of each partition must fit in
+	// memory
+	joinSpillPartitions = 16
+)
+
+// JoinMemory the memory budget for the build side of a hash join from
+// context, or default
+func JoinMemory(ctx *plan.Context) int64 {
+	if ctx != nil && ctx.JoinMemory > 0 {
+		return ctx.JoinMemory
+	}
+	return JoinMemoryDefault
+}
+
+// NewJoinHash create a hash join of left and right tasks.
+func NewJoinHash(ctx *plan.Context, l, r TaskRunner, p *plan.JoinMerge) *JoinHash {
+	m := &JoinHash{
+		TaskBase:  NewTaskBaseNamed(ctx, "join"),
+		leftStmt:  p.LeftFrom,
+		rightStmt: p.RightFrom,
+		ltask:     l,
+		rtask:     r,
+		colIndex:  p.ColIndex,
+	}
+	preserveLeft, preserveRight := p.Preserved()
+	if preserveRight && !preserveLeft {
+		m.buildLeft = true
+		m.preserveProbe = true
+	} else if p.BuildLeft && !preserveLeft && !preserveRight {
+		m.buildLeft = true
+	} else {
+		m.preserveProbe = preserveLeft
+		m.preserveBuild = preserveRight
+	}
+	return m
+}
+
+// newJoinTask create the executor task for the algorithm chosen by planner
+func newJoinTask(ctx *plan.Context, l, r TaskRunner, p *plan.JoinMerge) TaskRunner {
+	switch p.Algorithm {
+	case plan.JoinAlgorithmHash:
+		return NewJoinHash(ctx, l, r, p)
+	case plan.JoinAlgorithmMerge:
+		return NewJoinSortMerge(ctx, l, r, p)
+	}
+	return NewJoinNaiveMerge(ctx, l, r, p)
+}
+
+// joinTable the rows of one side of a hash join by join key
+type joinTable struct {
+	rows     map[string][]*datasource.SqlDriverMessageMap
+	nullKeys []*datasource.SqlDriverMessageMap
+	used     int64
+}
+
+func newJoinTable() *joinTable {
+	return &joinTable{rows: make(map[string][]*datasource.SqlDriverMessageMap)}
+}
+
+// each row of table, stops if fn returns false
+func (m *joinTable) each(fn func(row *datasource.SqlDriverMessageMap) bool) bool {
+	for _, rows := range m.rows {
+		for _, row := range rows {
+			if !fn(row) {
+				return false
+			}
+		}
+	}
+	for _, row := range m.nullKeys {
+		if !fn(row) {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	readDone = iota // input closed, all rows read
+	readFull        // over memory, rows left on input
+	readQuit        // task shut down
+)
+
+func (m *JoinHash) Run() error {
+	defer m.Ctx.Recover()
+	defer close(m.msgOutCh)
+
+	buildIn, probeIn := m.rtask.MessageOut(), m.ltask.MessageOut()
+	if m.buildLeft {
+		buildIn, probeIn = probeIn, buildIn
+	}
+
+	res := newMemReservation(m.TaskBase, "join")
+	defer res.Release()
+
+	budget := JoinMemory(m.Ctx)
+	build := newJoinTable()
+	state, err := m.read(buildIn, build, m.preserveBuild, res, budget)
+	if err != nil || state == readQuit {
+		return err
+	}
+	if state == readDone {
+		return m.probe(build, probeIn)
+	}
+
+	// the build side is larger than expected, build the probe side instead
+	// if it is smaller
+	probe := newJoinTable()
+	state, err = m.read(probeIn, probe, m.preserveProbe, res, budget)
+	if err != nil || state == readQuit {
+		return err
+	}
+	if state == readDone {
+		m.Ctx.Adapted(m.metric, "join", "flip")
+		m.buildLeft = !m.buildLeft
+		m.preserveProbe, m.preserveBuild = m.preserveBuild, m.preserveProbe
+		return m.probe(probe, m.chain(build, buildIn))
+	}
+
+	m.Ctx.Adapted(m.metric, "join", "spill")
+	return m.spill(build, buildIn, probe, probeIn, res)
+}
+
+// read rows of in into table until in is closed or the table is over budget
+// (or the memory limit of query).  Rows with NULL key are only kept if
+// keepNull, as they never match.
+func (m *JoinHash) read(in MessageChan, table *joinTable, keepNull bool, res *memReservation, budget int64) (int, error) {
+	for {
+		select {
+		case <-m.SigChan():
+			return readQuit, nil
+		case msg, ok := <-in:
+			if !ok {
+				return readDone, nil
+			}
+			mt, isMap := msg.(*datasource.SqlDriverMessageMap)
+			if !isMap {
+				return readQuit, fmt.Errorf("To use Join must use SqlDriverMessageMap but got %T", msg)
+			}
+			key := mt.Key()
+			if key == "" && !keepNull {
+				continue
+			}
+			if key == "" {
+				table.nullKeys = append(table.nullKeys, mt)
+			} else {
+				table.rows[key] = append(table.rows[key], mt)
+			}
+			size := rowSize(mt.Vals)
+			table.used += size
+			if err := res.Grow(size); err != nil || table.used > budget {
+				return readFull, nil
+			}
+		}
+	}
+}
+
+// chain a channel of the rows of table followed by the rest of in, for
+// the rows of a flipped build side to probe with
+func (m *JoinHash) chain(table *joinTable, in MessageChan) MessageChan {
+	out := make(MessageChan, cap(in))
+	go func() {
+		defer close(out)
+		ok := table.each(func(row *datasource.SqlDriverMessageMap) bool {
+			select {
+			case out <- row:
+				return true
+			case <-m.SigChan():
+				return false
+			}
+		})
+		if !ok {
+			return
+		}
+		for msg := range in {
+			select {
+			case out <- msg:
+			case <-m.SigChan():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// probe the build table with each row of in, then emit the build rows that
+// never matched if the build side is preserved
+func (m *JoinHash) probe(table *joinTable, in MessageChan) error {
+	var matched map[string]bool
+	if m.preserveBuild {
+		matched = make(map[string]bool)
+	}
+	for {
+		select {
+		case <-m.SigChan():
+			return nil
+		case msg, ok := <-in:
+			if !ok {
+				m.emitUnmatched(table, matched)
+				return nil
+			}
+			probe, isMap := msg.(*datasource.SqlDriverMessageMap)
+			if !isMap {
+				return fmt.Errorf("To use Join must use SqlDriverMessageMap but got %T", msg)
+			}
+			if !m.probeRow(table, probe, matched) {
+				return nil
+			}
+		}
+	}
+}
+
+// probeRow emit the joined rows of probe, returns false on quit
+func (m *JoinHash) probeRow(table *joinTable, probe *datasource.SqlDriverMessageMap, matched map[string]bool) bool {
+	key := probe.Key()
+	rows := table.rows[key]
+	if key == "" || len(rows) == 0 {
+		return !m.preserveProbe || m.emit(probe, nil)
+	}
+	if matched != nil {
+		matched[key] = true
+	}
+	for _, row := range rows {
+		if !m.emit(probe, row) {
+			return false
+		}
+	}
+	return true
+}
+
+// emitUnmatched emit build rows that were never matched, if preserved
+func (m *JoinHash) emitUnmatched(table *joinTable, matched map[string]bool) bool {
+	if !m.preserveBuild {
+		return true
+	}
+	for key, rows := range table.rows {
+		if matched[key] {
+			continue
+		}
+		for _, row := range rows {
+			if !m.emit(nil, row) {
+				return false
+			}
+		}
+	}
+	for _, row := range table.nullKeys {
+		if !m.emit(nil, row) {
+			return false
+		}
+	}
+	return true
+}
+
+// emit the joined row of probe and build, returns false on quit
+func (m *JoinHash) emit(probe, build *datasource.SqlDriverMessageMap) bool {
+	msg := m.merge(probe, build)
+	msg.IdVal = m.id
+	m.id++
+	select {
+	case m.msgOutCh <- msg:
+		return true
+	case <-m.SigChan():
+		return false
+	}
+}
+
+// spill both sides to files hash partitioned on join key, then join each
+// partition in memory.  Preserved rows with NULL key never match so they
+// are emitted as they are read.
+func (m *JoinHash) spill(build *joinTable, buildIn MessageChan, probe *joinTable, probeIn MessageChan, res *memReservation) error {
+	dir := tempDir(m.Ctx)
+	buildParts, err := newJoinPartitions(dir)
+	defer buildParts.Close()
+	if err != nil {
+		return err
+	}
+	probeParts, err := newJoinPartitions(dir)
+	defer probeParts.Close()
+	if err != nil {
+		return err
+	}
+	ok, err := m.spillSide(build, buildIn, buildParts, false)
+	if err != nil || !ok {
+		return err
+	}
+	ok, err = m.spillSide(probe, probeIn, probeParts, true)
+	if err != nil || !ok {
+		return err
+	}
+	build, probe = nil, nil
+	res.Release()
+
+	for i := range buildParts.parts {
+		table := newJoinTable()
+		err = buildParts.read(i, func(row *datasource.SqlDriverMessageMap) error {
+			table.rows[row.Key()] = append(table.rows[row.Key()], row)
+			return res.Grow(rowSize(row.Vals))
+		})
+		if err != nil {
+			return err
+		}
+		var matched map[string]bool
+		if m.preserveBuild {
+			matched = make(map[string]bool)
+		}
+		quit := fmt.Errorf("quit")
+		err = probeParts.read(i, func(row *datasource.SqlDriverMessageMap) error {
+			if !m.probeRow(table, row, matched) {
+				return quit
+			}
+			return nil
+		})
+		if err == quit {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !m.emitUnmatched(table, matched) {
+			return nil
+		}
+		res.Release()
+	}
+	return nil
+}
+
+// spillSide write the rows of table then the rest of in to partitions,
+// returns false on quit
+func (m *JoinHash) spillSide(table *joinTable, in MessageChan, parts *joinPartitions, isProbe bool) (bool, error) {
+	var err error
+	add := func(row *datasource.SqlDriverMessageMap) bool {
+		if row.Key() != "" {
+			err = parts.add(row)
+			return err == nil
+		}
+		if isProbe {
+			return !m.preserveProbe || m.emit(row, nil)
+		}
+		return !m.preserveBuild || m.emit(nil, row)
+	}
+	if !table.each(add) {
+		return false, err
+	}
+	for {
+		select {
+		case <-m.SigChan():
+			return false, nil
+		case msg, ok := <-in:
+			if !ok {
+				return true, nil
+			}
+			mt, isMap := msg.(*datasource.SqlDriverMessageMap)
+			if !isMap {
+				return false, fmt.Errorf("To use Join must use SqlDriverMessageMap but got %T", msg)
+			}
+			if !add(mt) {
+				return false, err
+			}
+		}
+	}
+}
+
+// joinPartitions the rows of one side of a spilled hash join, in files hash
+// partitioned on join key so matching rows of both sides are in the same
+// partition
+type joinPartitions struct {
+	parts    []*aggPartition
+	colIndex map[string]int
+}
+
+func newJoinPartitions(dir string) (*joinPartitions, error) {
+	m := &joinPartitions{}
+	for i := 0; i < joinSpillPartitions; i++ {
+		f, err := ioutil.TempFile(dir, "qlbridge-join-")
+		if err != nil {
+			return m, err
+		}
+		w := bufio.NewWriter(f)
+		m.parts = append(m.parts, &aggPartition{f: f, w: w, enc: gob.NewEncoder(w)})
+	}
+	return m, nil
+}
+
+func (m *joinPartitions) add(row *datasource.SqlDriverMessageMap) error {
+	if m.colIndex == nil {
+		m.colIndex = row.ColIndex
+	}
+	hasher := fnv.New32a()
+	hasher.Write([]byte(row.Key()))
+	part := m.parts[hasher.Sum32()%uint32(len(m.parts))]
+	return part.enc.Encode(&spillRow{Id: row.IdVal, Key: row.Key(), Vals: row.Vals})
+}
+
+// read the rows of partition i
+func (m *joinPartitions) read(i int, fn func(row *datasource.SqlDriverMessageMap) error) error {
+	part := m.parts[i]
+	if err := part.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := part.f.Seek(0, 0); err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(bufio.NewReader(part.f))
+	for {
+		var row spillRow
+		if err := dec.Decode(&row); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		sdm := datasource.NewSqlDriverMessageMap(row.Id, row.Vals, m.colIndex)
+		sdm.SetKey(row.Key)
+		if err := fn(sdm); err != nil {
+			return err
+		}
+	}
+}
+
+// Close remove the partition files
+func (m *joinPartitions) Close() error {
+	for _, part := range m.parts {
+		part.f.Close()
+		os.Remove(part.f.Name())
+	}
+	m.parts = nil
+	return nil
+}
+
+// merge create the joined row, either side may be nil for outer joins
+func (m *JoinHash) merge(probe, build *datasource.SqlDriverMessageMap) *datasource.SqlDriverMessageMap {
+	left, right := probe, build
+	if m.buildLeft {
+		left, right = build, probe
+	}
+	vals := make([]driver.Value, len(m.colIndex))
+	if left != nil {
+		vals = joinValues(vals, left.Values(), m.leftStmt.Source.Columns)
+	}
+	if right != nil {
+		vals = joinValues(vals, right.Values(), m.rightStmt.Source.Columns)
+	}
+	return datasource.NewSqlDriverMessageMap(0, vals, m.colIndex)
+}
+
+// joinValues copy the values of one side of a join into the joined row
+func joinValues(valOut, valSource []driver.Value, cols []*rel.Column) []driver.Value {
+	for _, col := range cols {
+		if col.ParentIndex < 0 || col.ParentIndex >= len(valOut) {
+			continue
+		}
+		if col.Index < 0 || col.Index >= len(valSource) {
+			u.Errorf("source index out of range? idx:%v of %d  col=%v", col.Index, len(valSource), col)
+			continue
+		}
+		valOut[col.ParentIndex] = valSource[col.Index]
+	}
+	return valOut
+}
@@ -0,0 +1,159 @@
+package exec_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/mockcsv"
+	td "github.com/araddon/qlbridge/datasource/mockcsvtestdata"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+)
+
+func TestExecJoinHash(t *testing.T) {
+	tests := []struct {
+		join    string
+		explain string
+		rows    string
+	}{
+		{"INNER JOIN", "hash",
+			"9Ip1aKbeZe2njCDM:1,9Ip1aKbeZe2njCDM:2"},
+		{"LEFT JOIN", "hash left outer",
+			"9Ip1aKbeZe2njCDM:1,9Ip1aKbeZe2njCDM:2,hT2impsOPUREcVPc:<nil>,hT2impsabc345c:<nil>"},
+		{"RIGHT OUTER JOIN", "hash right outer",
+			"9Ip1aKbeZe2njCDM:1,9Ip1aKbeZe2njCDM:2,<nil>:3"},
+		{"OUTER JOIN", "hash full outer",
+			"9Ip1aKbeZe2njCDM:1,9Ip1aKbeZe2njCDM:2,<nil>:3,hT2impsOPUREcVPc:<nil>,hT2impsabc345c:<nil>"},
+	}
+	for _, tt := range tests {
+		sql := fmt.Sprintf(`SELECT u.user_id, o.order_id FROM users AS u %s orders AS o ON u.user_id = o.user_id`, tt.join)
+		ctx := td.TestContext(sql)
+
+		stmt, err := rel.ParseSql(sql)
+		assert.Tf(t, err == nil, "Must parse %s but got %v", sql, err)
+		pln, err := plan.WalkStmt(ctx, stmt, plan.NewPlanner(ctx))
+		assert.Tf(t, err == nil, "no error %v for %s", err, sql)
+		for _, step := range plan.ExplainTask(pln) {
+			if step.Task == "join" {
+				assert.Equalf(t, tt.explain, step.Detail, "join plan for %s", sql)
+			}
+		}
+
+		ctx = td.TestContext(sql)
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+		err = job.Run()
+		assert.Tf(t, err == nil, "no error %v", err)
+
+		rows := make([]string, 0, len(msgs))
+		for _, msg := range msgs {
+			vals := msg.(*datasource.SqlDriverMessageMap).Values()
+			rows = append(rows, fmt.Sprintf("%v:%v", vals[0], vals[1]))
+		}
+		sort.Strings(rows)
+		assert.Equalf(t, tt.rows, strings.Join(rows, ","), "rows for %s", sql)
+	}
+}
+
+func TestExecJoinHashAdaptive(t *testing.T) {
+	big := []string{"id,k,v"}
+	for i := 0; i < 200; i++ {
+		big = append(big, fmt.Sprintf("%d,k%d,v%d", i, i%50, i))
+	}
+	small := []string{"id,k,w"}
+	for i := 0; i < 10; i++ {
+		small = append(small, fmt.Sprintf("%d,k%d,w%d", i, i*7, i))
+	}
+	mockcsv.LoadTable(mockcsv.MockSchemaName, "join_big", strings.Join(big, "\n"))
+	mockcsv.LoadTable(mockcsv.MockSchemaName, "join_small", strings.Join(small, "\n"))
+
+	joinRows := func(sql string, joinMemory int64) (string, []string) {
+		ctx := td.TestContext(sql)
+		ctx.JoinMemory = joinMemory
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+		err = job.Run()
+		assert.Tf(t, err == nil, "no error %v", err)
+		rows := make([]string, 0, len(msgs))
+		for _, msg := range msgs {
+			vals := msg.(*datasource.SqlDriverMessageMap).Values()
+			rows = append(rows, fmt.Sprintf("%v:%v", vals[0], vals[1]))
+		}
+		sort.Strings(rows)
+		return strings.Join(rows, ","), ctx.Adaptions()
+	}
+
+	// the first scan of a newly loaded mock table may miss rows, scan each
+	// once before comparing
+	joinRows("SELECT * FROM join_big", 0)
+	joinRows("SELECT * FROM join_small", 0)
+
+	tests := []struct {
+		join       string
+		joinMemory int64
+		adapted    string
+	}{
+		// big is the build side (right), small fits so is built instead
+		{"INNER JOIN", 2000, "join:flip"},
+		{"LEFT JOIN", 2000, "join:flip"},
+		{"OUTER JOIN", 2000, "join:flip"},
+		// small is the build side of right join
+		{"RIGHT JOIN", 2000, ""},
+		{"INNER JOIN", 1, "join:spill"},
+		{"LEFT JOIN", 1, "join:spill"},
+		{"RIGHT JOIN", 1, "join:spill"},
+		{"OUTER JOIN", 1, "join:spill"},
+	}
+	for _, tt := range tests {
+		sql := fmt.Sprintf(`SELECT s.w, b.v FROM join_small AS s %s join_big AS b ON s.k = b.k`, tt.join)
+		want, adapted := joinRows(sql, 0)
+		assert.Equalf(t, 0, len(adapted), "not adapted %s", sql)
+		rows, adapted := joinRows(sql, tt.joinMemory)
+		assert.Equalf(t, want, rows, "rows for %s memory %d", sql, tt.joinMemory)
+		assert.Equalf(t, tt.adapted, strings.Join(adapted, ","), "adapted %s memory %d", sql, tt.joinMemory)
+	}
+}
+
+func TestExecJoinEmptyKey(t *testing.T) {
+	mockcsv.LoadTable(mockcsv.MockSchemaName, "empty_left", "id,k\n1,\"\"\n2,a\n3,b")
+	mockcsv.LoadTable(mockcsv.MockSchemaName, "empty_right", "rid,k\n10,\"\"\n11,a")
+
+	// empty strings are keys as any other, not NULL
+	for _, hint := range []string{"", "/*+ MERGE_JOIN */ "} {
+		for join, want := range map[string]string{
+			"INNER JOIN": "1:10,2:11",
+			"LEFT JOIN":  "1:10,2:11,3:<nil>",
+		} {
+			sql := fmt.Sprintf(`%sSELECT l.id, r.rid FROM empty_left AS l %s empty_right AS r ON l.k = r.k`, hint, join)
+			ctx := td.TestContext(sql)
+			job, err := exec.BuildSqlJob(ctx)
+			assert.Tf(t, err == nil, "no error %v", err)
+			msgs := make([]schema.Message, 0)
+			job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+			assert.T(t, job.Setup() == nil)
+			err = job.Run()
+			assert.Tf(t, err == nil, "no error %v", err)
+
+			rows := make([]string, 0, len(msgs))
+			for _, msg := range msgs {
+				vals := msg.(*datasource.SqlDriverMessageMap).Values()
+				rows = append(rows, fmt.Sprintf("%v:%v", vals[0], vals[1]))
+			}
+			sort.Strings(rows)
+			assert.Equalf(t, want, strings.Join(rows, ","), "rows for %s", sql)
+		}
+	}
+}
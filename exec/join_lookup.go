@@ -0,0 +1,340 @@
+package exec
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+	"github.com/araddon/qlbridge/vm"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*JoinLookup)(nil)
+)
+
+const (
+	// JoinLookupBatchDefault left rows whose keys are looked up at once
+	// (MultiGet) by a lookup join.  Over-ride with plan.Context RowBatchSize.
+	JoinLookupBatchDefault = 256
+)
+
+// JoinLookup a nested loop join that looks up the rows of the right side
+// by key instead of scanning it, for a right source that is a key-value
+// store (schema.ConnSeeker) keyed on the join column.  The rows of the left
+// side are read in batches, the distinct keys of each batch fetched with
+// one MultiGet, and the fetched rows filtered by the where of the right
+// source and projected as if they had been scanned.  Only the rows that
+// match are ever read, so a selective join of a large right table does not
+// pay for a scan of it.
+//
+//   left  ->  batch of keys  ->  MultiGet(keys)  ->  join  -->  output
+//                                      |
+//                              right (key-value)
+//
+// Only inner and left outer joins are looked up, as the right rows without
+// a match are never read.
+type JoinLookup struct {
+	*TaskBase
+	leftStmt     *rel.SqlSource
+	rightStmt    *rel.SqlSource
+	ltask        TaskRunner
+	conn         schema.Conn
+	seeker       schema.ConnSeeker
+	metrics      *datasource.Metrics // of the right source, nil if it records none
+	colIndex     map[string]int
+	leftNode     expr.Node   // left join expression, the key looked up
+	rightNodes   []expr.Node // right join expressions, key of fetched rows
+	where        func(msg schema.Message) (bool, bool)
+	project      func(ctx *plan.Context, msg schema.Message) schema.Message
+	preserveLeft bool
+	batchSize    int
+	id           uint64
+	closeOnce    sync.Once
+}
+
+// NewJoinLookup create a lookup join of the left task to the right source
+// of p, which is looked up instead of being run as a task.
+func NewJoinLookup(ctx *plan.Context, l TaskRunner, p *plan.JoinMerge) (*JoinLookup, error) {
+	right, ok := p.Right.(*plan.Source)
+	if !ok {
+		return nil, fmt.Errorf("lookup join requires a source on the right but got %T", p.Right)
+	}
+	seeker, ok := right.Conn.(schema.ConnSeeker)
+	if !ok {
+		return nil, fmt.Errorf("lookup join requires a schema.ConnSeeker for %q but got %T", right.Stmt.SourceName(), right.Conn)
+	}
+	leftNodes := p.LeftFrom.JoinNodes()
+	if len(leftNodes) != 1 {
+		return nil, fmt.Errorf("lookup join requires a single join key but got %d", len(leftNodes))
+	}
+	m := &JoinLookup{
+		TaskBase:   NewTaskBaseNamed(ctx, "join"),
+		leftStmt:   p.LeftFrom,
+		rightStmt:  p.RightFrom,
+		ltask:      l,
+		conn:       right.Conn,
+		seeker:     seeker,
+		metrics:    datasource.MetricsOf(right.DataSource),
+		colIndex:   p.ColIndex,
+		leftNode:   leftNodes[0],
+		rightNodes: right.Stmt.JoinNodes(),
+		batchSize:  JoinLookupBatchDefault,
+	}
+	m.preserveLeft, _ = p.Preserved()
+	if n := RowBatchSize(ctx); n > 1 {
+		m.batchSize = n
+	}
+	// the where and projection of the right source are evaluated on the
+	// fetched rows, as they would have been on scanned rows
+	for _, t := range right.Children() {
+		switch pt := t.(type) {
+		case *plan.Where:
+			m.where = whereEvaluator(pt.Stmt.Where.Expr, pt.Stmt.UnAliasedColumns())
+		case *plan.Projection:
+			m.project = (&Projection{p: pt}).projector(false)
+		}
+	}
+	return m, nil
+}
+
+// Close the right source connection
+func (m *JoinLookup) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		err = m.conn.Close()
+	})
+	if err != nil {
+		return err
+	}
+	return m.TaskBase.Close()
+}
+
+func (m *JoinLookup) Run() error {
+	defer m.Ctx.Recover()
+	defer close(m.msgOutCh)
+
+	in := m.ltask.MessageOut()
+	for {
+		batch, more, err := m.readBatch(in)
+		if err != nil {
+			return err
+		}
+		if len(batch) > 0 {
+			found, err := m.lookup(batch)
+			if err != nil {
+				return err
+			}
+			for _, row := range batch {
+				if !m.joinRow(row, found[row.Key()]) {
+					return nil
+				}
+			}
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// readBatch read up to batchSize rows of in, waiting for the first but not
+// the rest.  more is false once in is closed or the task quit.
+func (m *JoinLookup) readBatch(in MessageChan) ([]*datasource.SqlDriverMessageMap, bool, error) {
+	batch := make([]*datasource.SqlDriverMessageMap, 0, m.batchSize)
+	for len(batch) < m.batchSize {
+		var msg schema.Message
+		var ok bool
+		if len(batch) == 0 {
+			select {
+			case <-m.SigChan():
+				return nil, false, nil
+			case msg, ok = <-in:
+			}
+		} else {
+			select {
+			case <-m.SigChan():
+				return nil, false, nil
+			case msg, ok = <-in:
+			default:
+				return batch, true, nil
+			}
+		}
+		if !ok {
+			return batch, false, nil
+		}
+		mt, isMap := msg.(*datasource.SqlDriverMessageMap)
+		if !isMap {
+			return nil, false, fmt.Errorf("To use Join must use SqlDriverMessageMap but got %T", msg)
+		}
+		batch = append(batch, mt)
+	}
+	return batch, true, nil
+}
+
+// lookup the right rows matching the distinct keys of batch, by join key
+func (m *JoinLookup) lookup(batch []*datasource.SqlDriverMessageMap) (map[string][]*datasource.SqlDriverMessageMap, error) {
+	seen := make(map[string]bool, len(batch))
+	keys := make([]driver.Value, 0, len(batch))
+	for _, row := range batch {
+		key := row.Key()
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		val, ok := vm.Eval(row, m.leftNode)
+		if !ok || val == nil || val.Type() == value.NilType {
+			continue
+		}
+		keys = append(keys, lookupKeys(val)...)
+	}
+	msgs, err := m.get(keys)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string][]*datasource.SqlDriverMessageMap, len(msgs))
+	got := make(map[uint64]bool, len(msgs))
+	for _, msg := range msgs {
+		// a row may be found by more than one of the keys of a value
+		if msg == nil || got[msg.Id()] {
+			continue
+		}
+		got[msg.Id()] = true
+		row, err := m.inner(msg)
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			continue
+		}
+		found[row.Key()] = append(found[row.Key()], row)
+	}
+	return found, nil
+}
+
+// lookupKeys the keys to look up the right rows of a join value by.  Rows
+// match on the string of their join keys as for a hash join, but sources
+// key them by their type (ie 7 and "7" are different keys of a membtree),
+// so a value is looked up as an int (if it is one) and as a string.
+func lookupKeys(val value.Value) []driver.Value {
+	keys := make([]driver.Value, 0, 2)
+	s := val.ToString()
+	if iv, err := strconv.ParseInt(s, 10, 64); err == nil {
+		keys = append(keys, iv)
+	}
+	keys = append(keys, s)
+	switch v := val.Value().(type) {
+	case int64, string, float64:
+	default:
+		keys = append(keys, v)
+	}
+	return keys
+}
+
+// get the rows of keys, those not found are left out.  Sources whose
+// MultiGet fails if any key is missing are read a key at a time.
+func (m *JoinLookup) get(keys []driver.Value) ([]schema.Message, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if m.metrics == nil {
+		return m.fetch(keys)
+	}
+	start := time.Now()
+	msgs, err := m.fetch(keys)
+	name := m.rightStmt.SourceName()
+	m.metrics.Request(name, time.Since(start), err)
+	m.metrics.Read(name, int64(len(msgs)), 0)
+	return msgs, err
+}
+
+func (m *JoinLookup) fetch(keys []driver.Value) ([]schema.Message, error) {
+	if len(keys) > 1 {
+		msgs, err := m.seeker.MultiGet(keys)
+		if err == nil {
+			return msgs, nil
+		} else if err != schema.ErrNotFound {
+			return nil, err
+		}
+	}
+	msgs := make([]schema.Message, 0, len(keys))
+	for _, key := range keys {
+		msg, err := m.seeker.Get(key)
+		if err == schema.ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// inner the row of the right side for a fetched row, with its join key, nil
+// if filtered out by the where of the right source
+func (m *JoinLookup) inner(msg schema.Message) (*datasource.SqlDriverMessageMap, error) {
+	mt, isMap := msg.(*datasource.SqlDriverMessageMap)
+	if !isMap {
+		return nil, fmt.Errorf("To use Join must use SqlDriverMessageMap but got %T", msg)
+	}
+	if m.where != nil {
+		if keep, _ := m.where(mt); !keep {
+			return nil, nil
+		}
+	}
+	var row *datasource.SqlDriverMessageMap
+	if m.project == nil {
+		// rows are the sources own, never modified
+		row = mt.Copy()
+	} else if row, isMap = m.project(m.Ctx, mt).(*datasource.SqlDriverMessageMap); !isMap {
+		return nil, fmt.Errorf("could not project lookup row %T", msg)
+	}
+	key := joinKey(row, m.rightNodes)
+	if key == "" {
+		return nil, nil
+	}
+	row.SetKeyHashed(key)
+	return row, nil
+}
+
+// joinRow emit the joined rows of left and its matches, returns false on quit
+func (m *JoinLookup) joinRow(left *datasource.SqlDriverMessageMap, matches []*datasource.SqlDriverMessageMap) bool {
+	if len(matches) == 0 {
+		return !m.preserveLeft || m.emit(left, nil)
+	}
+	for _, right := range matches {
+		if !m.emit(left, right) {
+			return false
+		}
+	}
+	return true
+}
+
+// emit the joined row of left and right, right is nil for left rows without
+// a match
+func (m *JoinLookup) emit(left, right *datasource.SqlDriverMessageMap) bool {
+	vals := make([]driver.Value, len(m.colIndex))
+	vals = joinValues(vals, left.Values(), m.leftStmt.Source.Columns)
+	if right != nil {
+		vals = joinValues(vals, right.Values(), m.rightStmt.Source.Columns)
+	}
+	msg := datasource.NewSqlDriverMessageMap(m.id, vals, m.colIndex)
+	m.id++
+	select {
+	case m.msgOutCh <- msg:
+		return true
+	case <-m.SigChan():
+		return false
+	}
+}
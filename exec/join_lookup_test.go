@@ -0,0 +1,178 @@
+package exec_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	_ schema.Source     = (*lookupSource)(nil)
+	_ schema.ConnSeeker = (*lookupTable)(nil)
+)
+
+// lookupSource tables count the rows scanned and the Get/MultiGet calls made
+type lookupSource struct {
+	tables map[string]*lookupTable
+}
+
+type lookupTable struct {
+	*membtree.StaticDataSource
+	scanned int
+	lookups int
+}
+
+func (m *lookupSource) Tables() []string { return []string{"users", "orders", "refs"} }
+func (m *lookupSource) Open(name string) (schema.Conn, error) {
+	if t, ok := m.tables[name]; ok {
+		return t, nil
+	}
+	return nil, schema.ErrNotFound
+}
+func (m *lookupSource) Table(name string) (*schema.Table, error) {
+	if t, ok := m.tables[name]; ok {
+		return t.StaticDataSource.Table(name)
+	}
+	return nil, schema.ErrNotFound
+}
+func (m *lookupSource) Close() error { return nil }
+
+func (m *lookupTable) Next() schema.Message {
+	msg := m.StaticDataSource.Next()
+	if msg != nil {
+		m.scanned++
+	}
+	return msg
+}
+func (m *lookupTable) Get(key driver.Value) (schema.Message, error) {
+	m.lookups++
+	return m.StaticDataSource.Get(key)
+}
+func (m *lookupTable) MultiGet(keys []driver.Value) ([]schema.Message, error) {
+	m.lookups++
+	return m.StaticDataSource.MultiGet(keys)
+}
+
+func TestExecJoinLookup(t *testing.T) {
+	users := make([][]driver.Value, 0)
+	for i := 1; i <= 500; i++ {
+		city := "oslo"
+		if i%2 == 0 {
+			city = "paris"
+		}
+		users = append(users, []driver.Value{int64(i), fmt.Sprintf("user%d", i), city})
+	}
+	orders := make([][]driver.Value, 0)
+	for i := 1; i <= 20; i++ {
+		// the orders of user 999 have no user
+		userId := int64(i * 7)
+		if i%5 == 0 {
+			userId = 999
+		}
+		orders = append(orders, []driver.Value{int64(i), userId})
+	}
+	// user ids of other types than the int keys of users
+	refs := [][]driver.Value{
+		{int64(1), "7"}, {int64(2), float64(14)}, {int64(3), "x"}, {int64(4), float64(2.5)},
+	}
+	src := &lookupSource{tables: map[string]*lookupTable{
+		"users":  {StaticDataSource: membtree.NewStaticDataSource("users", 0, users, []string{"user_id", "name", "city"})},
+		"orders": {StaticDataSource: membtree.NewStaticDataSource("orders", 0, orders, []string{"order_id", "user_id"})},
+		"refs":   {StaticDataSource: membtree.NewStaticDataSource("refs", 0, refs, []string{"ref_id", "user_id"})},
+	}}
+	lookupSchema := datasource.RegisterSchemaSource("lookup", "lookup", src)
+
+	newCtx := func(sql string) *plan.Context {
+		ctx := plan.NewContext(sql)
+		ctx.DisableRecover = true
+		ctx.Schema = lookupSchema
+		ctx.Session = datasource.NewMySqlSessionVars()
+		return ctx
+	}
+	explain := func(sql string) (string, []string) {
+		ctx := newCtx(sql)
+		stmt, err := rel.ParseSql(sql)
+		assert.Tf(t, err == nil, "Must parse %s but got %v", sql, err)
+		pln, err := plan.WalkStmt(ctx, stmt, plan.NewPlanner(ctx))
+		assert.Tf(t, err == nil, "no error %v for %s", err, sql)
+		for _, step := range plan.ExplainTask(pln) {
+			if step.Task == "join" {
+				return step.Detail, ctx.Warnings
+			}
+		}
+		return "", ctx.Warnings
+	}
+	run := func(sql string) []string {
+		ctx := newCtx(sql)
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v for %s", err, sql)
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+		err = job.Run()
+		assert.Tf(t, err == nil, "no error %v for %s", err, sql)
+		job.Close()
+		rows := make([]string, 0, len(msgs))
+		for _, msg := range msgs {
+			rows = append(rows, fmt.Sprintf("%v", msg.(*datasource.SqlDriverMessageMap).Values()))
+		}
+		sort.Strings(rows)
+		return rows
+	}
+
+	tests := []struct {
+		sql     string
+		explain string
+		rows    int
+		lookup  bool
+	}{
+		{`SELECT o.order_id, u.name FROM orders AS o INNER JOIN users AS u ON o.user_id = u.user_id`,
+			"lookup", 16, true},
+		{`SELECT o.order_id, u.name FROM orders AS o LEFT JOIN users AS u ON o.user_id = u.user_id`,
+			"lookup left outer", 20, true},
+		{`SELECT o.order_id, u.name FROM orders AS o INNER JOIN users AS u ON o.user_id = u.user_id WHERE u.city = "paris"`,
+			"lookup", 8, true},
+		// keys of other types match as they do for a hash join
+		{`SELECT r.ref_id, u.name FROM refs AS r INNER JOIN users AS u ON r.user_id = u.user_id`,
+			"lookup", 2, true},
+		// the right rows without a match are never read, so right joins scan
+		{`SELECT o.order_id, u.name FROM orders AS o RIGHT JOIN users AS u ON o.user_id = u.user_id`,
+			"hash right outer", 500, false},
+		// not joined on the key of users
+		{`SELECT o.order_id, u.name FROM orders AS o INNER JOIN users AS u ON o.user_id = u.name`,
+			"hash", 0, false},
+	}
+	for _, tt := range tests {
+		detail, _ := explain(tt.sql)
+		assert.Equalf(t, tt.explain, detail, "join plan for %s", tt.sql)
+
+		users := src.tables["users"]
+		users.scanned, users.lookups = 0, 0
+		rows := run(tt.sql)
+		assert.Equalf(t, tt.rows, len(rows), "rows for %s", tt.sql)
+		if tt.lookup {
+			assert.Equalf(t, 0, users.scanned, "users scanned for %s", tt.sql)
+			assert.Tf(t, users.lookups > 0, "users looked up for %s", tt.sql)
+		}
+
+		// same rows as a hash join
+		hashRows := run(`/*+ HASH_JOIN */ ` + tt.sql)
+		assert.Equalf(t, strings.Join(hashRows, ","), strings.Join(rows, ","), "rows for %s", tt.sql)
+	}
+
+	// a lookup join is only hinted for a source keyed on the join key
+	detail, warnings := explain(`/*+ LOOKUP_JOIN */ SELECT u.name, o.order_id FROM users AS u INNER JOIN orders AS o ON u.user_id = o.user_id`)
+	assert.Equal(t, "hash", detail)
+	assert.Equal(t, 1, len(warnings))
+}
@@ -0,0 +1,217 @@
+package exec
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/value"
+	"github.com/araddon/qlbridge/vm"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*JoinSortMerge)(nil)
+)
+
+// JoinSortMerge a merge join of two inputs that are sorted (ascending) on
+// the join key, either by the source or by an order task the planner added.
+// Both inputs are read in step, only the rows of the right side that share
+// the current key are held in memory so large inputs may be joined.
+//
+//   source1 (sorted)  ->
+//                        \
+//                          --  merge  -->
+//                        /
+//   source2 (sorted)  ->
+//
+// Rows with a NULL join key never match, for outer joins preserved rows
+// without a match are emitted with NULL values for the other side.
+type JoinSortMerge struct {
+	*TaskBase
+	leftStmt      *rel.SqlSource
+	rightStmt     *rel.SqlSource
+	ltask         TaskRunner
+	rtask         TaskRunner
+	colIndex      map[string]int
+	preserveLeft  bool
+	preserveRight bool
+	stopped       bool
+}
+
+type joinRow struct {
+	msg *datasource.SqlDriverMessageMap
+	key []value.Value // nil for NULL key
+}
+
+// NewJoinSortMerge create a merge join of left and right sorted tasks.
+func NewJoinSortMerge(ctx *plan.Context, l, r TaskRunner, p *plan.JoinMerge) *JoinSortMerge {
+	m := &JoinSortMerge{
+		TaskBase:  NewTaskBaseNamed(ctx, "join"),
+		leftStmt:  p.LeftFrom,
+		rightStmt: p.RightFrom,
+		ltask:     l,
+		rtask:     r,
+		colIndex:  p.ColIndex,
+	}
+	m.preserveLeft, m.preserveRight = p.Preserved()
+	return m
+}
+
+func (m *JoinSortMerge) Run() error {
+	defer m.Ctx.Recover()
+	defer close(m.msgOutCh)
+
+	lin, rin := m.ltask.MessageOut(), m.rtask.MessageOut()
+	lnodes, rnodes := m.leftStmt.JoinNodes(), m.rightStmt.JoinNodes()
+
+	outCh := m.MessageOut()
+	i := uint64(0)
+	emit := func(left, right *joinRow) bool {
+		msg := m.merge(left, right)
+		msg.IdVal = i
+		i++
+		select {
+		case outCh <- msg:
+			return true
+		case <-m.SigChan():
+			m.stopped = true
+			return false
+		}
+	}
+
+	// nextRight the next right row that has a key, right rows with NULL
+	// key are emitted for right outer joins
+	nextRight := func() (*joinRow, error) {
+		for {
+			r, err := m.recv(rin, rnodes)
+			if r == nil || err != nil {
+				return nil, err
+			}
+			if r.key != nil {
+				return r, nil
+			}
+			if m.preserveRight && !emit(nil, r) {
+				return nil, nil
+			}
+		}
+	}
+
+	rpeek, err := nextRight()
+	if err != nil {
+		return err
+	}
+
+	// group is the right rows sharing the key of the current left row
+	var group []*joinRow
+	for {
+		l, err := m.recv(lin, lnodes)
+		if err != nil {
+			return err
+		}
+		if l == nil {
+			break
+		}
+		if l.key == nil {
+			if m.preserveLeft && !emit(l, nil) {
+				return nil
+			}
+			continue
+		}
+		if len(group) == 0 || compareKeys(group[0].key, l.key) != 0 {
+			group = group[:0]
+			for rpeek != nil && compareKeys(rpeek.key, l.key) < 0 {
+				if m.preserveRight && !emit(nil, rpeek) {
+					return nil
+				}
+				if rpeek, err = nextRight(); err != nil {
+					return err
+				}
+			}
+			for rpeek != nil && compareKeys(rpeek.key, l.key) == 0 {
+				group = append(group, rpeek)
+				if rpeek, err = nextRight(); err != nil {
+					return err
+				}
+			}
+		}
+		if len(group) == 0 {
+			if m.preserveLeft && !emit(l, nil) {
+				return nil
+			}
+			continue
+		}
+		for _, r := range group {
+			if !emit(l, r) {
+				return nil
+			}
+		}
+	}
+
+	// drain the right side, rows past the last left key never match
+	for rpeek != nil && !m.stopped {
+		if m.preserveRight && !emit(nil, rpeek) {
+			return nil
+		}
+		if rpeek, err = nextRight(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recv read the next row and evaluate its join key, nil on closed input or quit
+func (m *JoinSortMerge) recv(in MessageChan, nodes []expr.Node) (*joinRow, error) {
+	select {
+	case <-m.SigChan():
+		m.stopped = true
+		return nil, nil
+	case msg, ok := <-in:
+		if !ok {
+			return nil, nil
+		}
+		mt, isMap := msg.(*datasource.SqlDriverMessageMap)
+		if !isMap {
+			return nil, fmt.Errorf("To use Join must use SqlDriverMessageMap but got %T", msg)
+		}
+		row := &joinRow{msg: mt, key: make([]value.Value, len(nodes))}
+		for i, node := range nodes {
+			joinVal, ok := vm.Eval(mt, node)
+			if !ok || joinVal == nil || joinVal.Type() == value.NilType {
+				row.key = nil
+				break
+			}
+			row.key[i] = joinVal
+		}
+		return row, nil
+	}
+}
+
+// merge create the joined row, either side may be nil for outer joins
+func (m *JoinSortMerge) merge(left, right *joinRow) *datasource.SqlDriverMessageMap {
+	vals := make([]driver.Value, len(m.colIndex))
+	if left != nil {
+		vals = joinValues(vals, left.msg.Values(), m.leftStmt.Source.Columns)
+	}
+	if right != nil {
+		vals = joinValues(vals, right.msg.Values(), m.rightStmt.Source.Columns)
+	}
+	return datasource.NewSqlDriverMessageMap(0, vals, m.colIndex)
+}
+
+// compareKeys compare composite join keys in the sort order of compareValues
+func compareKeys(a, b []value.Value) int {
+	for i := range a {
+		if c := compareValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
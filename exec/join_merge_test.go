@@ -0,0 +1,136 @@
+package exec_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	td "github.com/araddon/qlbridge/datasource/mockcsvtestdata"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	_ schema.Source     = (*sortedSource)(nil)
+	_ schema.ConnSorted = (*sortedTable)(nil)
+)
+
+// sortedSource tables are btrees keyed on an int column, so scans return
+// rows sorted by that key
+type sortedSource struct {
+	tables map[string]*sortedTable
+}
+
+type sortedTable struct {
+	*membtree.StaticDataSource
+	key string
+}
+
+func (m *sortedSource) Tables() []string {
+	names := make([]string, 0, len(m.tables))
+	for name := range m.tables {
+		names = append(names, name)
+	}
+	return names
+}
+func (m *sortedSource) Open(name string) (schema.Conn, error) {
+	if t, ok := m.tables[name]; ok {
+		return t, nil
+	}
+	return nil, schema.ErrNotFound
+}
+func (m *sortedSource) Table(name string) (*schema.Table, error) {
+	if t, ok := m.tables[name]; ok {
+		return t.StaticDataSource.Table(name)
+	}
+	return nil, schema.ErrNotFound
+}
+func (m *sortedSource) Close() error { return nil }
+
+func (m *sortedTable) SortedBy() []string { return []string{m.key} }
+
+var sortedSchema = func() *schema.Schema {
+	userCols := []string{"user_id", "name"}
+	users := [][]driver.Value{{9, "nancy"}, {1, "alice"}, {10, "zed"}, {2, "bob"}}
+	profileCols := []string{"user_id", "city"}
+	profiles := [][]driver.Value{{10, "paris"}, {2, "oslo"}, {7, "rome"}, {1, "lima"}}
+	src := &sortedSource{tables: map[string]*sortedTable{
+		"users":    {membtree.NewStaticDataSource("users", 0, users, userCols), "user_id"},
+		"profiles": {membtree.NewStaticDataSource("profiles", 0, profiles, profileCols), "user_id"},
+	}}
+	return datasource.RegisterSchemaSource("sorted", "sorted", src)
+}()
+
+func TestExecJoinSortMerge(t *testing.T) {
+	tests := []struct {
+		sql     string
+		sorted  bool
+		explain string
+		orders  int // sort tasks added for inputs
+		rows    string
+	}{
+		{`SELECT u.user_id, p.city FROM users AS u INNER JOIN profiles AS p ON u.user_id = p.user_id`,
+			true, "merge", 0,
+			"10:paris,1:lima,2:oslo"},
+		{`SELECT u.user_id, p.city FROM users AS u OUTER JOIN profiles AS p ON u.user_id = p.user_id`,
+			true, "merge full outer", 0,
+			"10:paris,1:lima,2:oslo,9:<nil>,<nil>:rome"},
+		{`/*+ MERGE_JOIN */ SELECT u.user_id, o.order_id FROM users AS u INNER JOIN orders AS o ON u.user_id = o.user_id`,
+			false, "merge", 2,
+			"9Ip1aKbeZe2njCDM:1,9Ip1aKbeZe2njCDM:2"},
+		{`/*+ MERGE_JOIN */ SELECT u.user_id, o.order_id FROM users AS u LEFT JOIN orders AS o ON u.user_id = o.user_id`,
+			false, "merge left outer", 2,
+			"9Ip1aKbeZe2njCDM:1,9Ip1aKbeZe2njCDM:2,hT2impsOPUREcVPc:<nil>,hT2impsabc345c:<nil>"},
+		{`/*+ MERGE_JOIN */ SELECT u.user_id, o.order_id FROM users AS u RIGHT JOIN orders AS o ON u.user_id = o.user_id`,
+			false, "merge right outer", 2,
+			"9Ip1aKbeZe2njCDM:1,9Ip1aKbeZe2njCDM:2,<nil>:3"},
+	}
+	for _, tt := range tests {
+		ctx := td.TestContext(tt.sql)
+		if tt.sorted {
+			ctx = plan.NewContext(tt.sql)
+			ctx.DisableRecover = true
+			ctx.Schema = sortedSchema
+			ctx.Session = datasource.NewMySqlSessionVars()
+		}
+
+		stmt, err := rel.ParseSql(tt.sql)
+		assert.Tf(t, err == nil, "Must parse %s but got %v", tt.sql, err)
+		pln, err := plan.WalkStmt(ctx, stmt, plan.NewPlanner(ctx))
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+		orders := 0
+		for _, step := range plan.ExplainTask(pln) {
+			switch step.Task {
+			case "join":
+				assert.Equalf(t, tt.explain, step.Detail, "join plan for %s", tt.sql)
+			case "order":
+				orders++
+			}
+		}
+		assert.Equalf(t, tt.orders, orders, "sorted inputs for %s", tt.sql)
+
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+		err = job.Run()
+		assert.Tf(t, err == nil, "no error %v", err)
+
+		rows := make([]string, 0, len(msgs))
+		for _, msg := range msgs {
+			vals := msg.(*datasource.SqlDriverMessageMap).Values()
+			rows = append(rows, fmt.Sprintf("%v:%v", vals[0], vals[1]))
+		}
+		sort.Strings(rows)
+		assert.Equalf(t, tt.rows, strings.Join(rows, ","), "rows for %s", tt.sql)
+	}
+}
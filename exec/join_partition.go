@@ -0,0 +1,211 @@
+package exec
+
+import (
+	"fmt"
+	"sync"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*JoinPartitioned)(nil)
+)
+
+// JoinPartitioned runs one join per partition and merges their output.
+//
+// Colocated inputs are already partitioned on the join key so each join
+// reads its own partition of left and right:
+//
+//   left p0, right p0  ->  join p0  ->
+//                                       --> merge
+//   left p1, right p1  ->  join p1  ->
+//
+// Otherwise the inputs are repartitioned by hash of their join key (the
+// message Id set by JoinKey) so matching rows always meet in the same join:
+//
+//   left   -> hash-route ->  join p0  ->
+//                                         --> merge
+//   right  -> hash-route ->  join p1  ->
+//
+type JoinPartitioned struct {
+	*TaskBase
+	inputs  []TaskRunner  // repartitioned inputs (left, right)
+	buckets [][]*TaskBase // per input, per partition output of the hash-route
+	joins   []TaskRunner  // join per partition
+	closed  bool
+}
+
+// walkJoinColocated one join per partition of colocated left/right sources
+func (m *JobExecutor) walkJoinColocated(p *plan.JoinMerge) (*JoinPartitioned, error) {
+	left, lok := p.Left.(*plan.Source)
+	right, rok := p.Right.(*plan.Source)
+	if !lok || !rok {
+		return nil, fmt.Errorf("colocated join requires source inputs but got %T, %T", p.Left, p.Right)
+	}
+	jp := &JoinPartitioned{TaskBase: NewTaskBaseNamed(m.Ctx, "join")}
+	for _, part := range p.Partitions {
+		l, err := m.walkSourcePartition(left, part)
+		if err != nil {
+			return nil, err
+		}
+		r, err := m.walkSourcePartition(right, part)
+		if err != nil {
+			return nil, err
+		}
+		joinTask := NewTaskParallel(m.Ctx)
+		for _, t := range []Task{l, r, newJoinTask(m.Ctx, l.(TaskRunner), r.(TaskRunner), p)} {
+			if err := joinTask.Add(t); err != nil {
+				return nil, err
+			}
+		}
+		jp.joins = append(jp.joins, joinTask)
+	}
+	return jp, nil
+}
+
+// walkSourcePartition the exec tasks for a single partition of source
+func (m *JobExecutor) walkSourcePartition(src *plan.Source, part *schema.Partition) (Task, error) {
+	conn, err := openPartition(m.Ctx, src, part)
+	if err != nil {
+		return nil, err
+	}
+	partSrc := *src
+	partSrc.Conn = conn
+	return m.WalkPlanAll(&partSrc)
+}
+
+// walkJoinRepartition hash-partition left and right into n joins
+func (m *JobExecutor) walkJoinRepartition(p *plan.JoinMerge) (*JoinPartitioned, error) {
+	jp := &JoinPartitioned{TaskBase: NewTaskBaseNamed(m.Ctx, "join")}
+	for _, in := range []plan.Task{p.Left, p.Right} {
+		t, err := m.WalkPlanAll(in)
+		if err != nil {
+			return nil, err
+		}
+		jp.inputs = append(jp.inputs, t.(TaskRunner))
+		buckets := make([]*TaskBase, p.Repartition)
+		for i := range buckets {
+			buckets[i] = NewTaskBaseNamed(m.Ctx, "join")
+		}
+		jp.buckets = append(jp.buckets, buckets)
+	}
+	for i := 0; i < p.Repartition; i++ {
+		jp.joins = append(jp.joins, newJoinTask(m.Ctx, jp.buckets[0][i], jp.buckets[1][i], p))
+	}
+	return jp, nil
+}
+
+func (m *JoinPartitioned) Children() []Task {
+	tasks := make([]Task, 0, len(m.inputs)+len(m.joins))
+	for _, t := range m.inputs {
+		tasks = append(tasks, t)
+	}
+	for _, t := range m.joins {
+		tasks = append(tasks, t)
+	}
+	return tasks
+}
+
+func (m *JoinPartitioned) Setup(depth int) error {
+	m.setup = true
+	for _, t := range m.Children() {
+		registerMetric(t.(TaskRunner), depth+1)
+		if err := t.(TaskRunner).Setup(depth + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *JoinPartitioned) Close() error {
+	m.Lock()
+	if m.closed {
+		m.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.Unlock()
+
+	errs := make(errList, 0)
+	for _, t := range m.Children() {
+		if err := t.Close(); err != nil {
+			errs.append(err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return m.TaskBase.Close()
+}
+
+func (m *JoinPartitioned) Run() error {
+	defer m.Ctx.Recover()
+	defer close(m.msgOutCh)
+
+	outCh := m.MessageOut()
+
+	var runWg, mergeWg sync.WaitGroup
+	var runErr firstErr
+	run := func(t TaskRunner) {
+		runWg.Add(1)
+		go func() {
+			defer runWg.Done()
+			if err := runTask(t); err != nil {
+				u.Errorf("partition join task errored %v", err)
+				// a failed partition would leave partial results, stop the others
+				if runErr.set(err) {
+					quitTasks(m)
+				}
+			}
+		}()
+	}
+
+	for _, join := range m.joins {
+		run(join)
+		mergeWg.Add(1)
+		go func(joinOut MessageChan) {
+			defer mergeWg.Done()
+			for msg := range joinOut {
+				select {
+				case outCh <- msg:
+				case <-m.SigChan():
+					// drain so the join can finish
+					for range joinOut {
+					}
+					return
+				}
+			}
+		}(join.MessageOut())
+	}
+	for i, in := range m.inputs {
+		run(in)
+		go m.route(in.MessageOut(), m.buckets[i])
+	}
+
+	mergeWg.Wait()
+	runWg.Wait()
+	return runErr.error()
+}
+
+// route send each message to the partition for the hash of its join key
+func (m *JoinPartitioned) route(in MessageChan, buckets []*TaskBase) {
+	defer func() {
+		for _, b := range buckets {
+			close(b.msgOutCh)
+		}
+	}()
+	n := uint64(len(buckets))
+	for msg := range in {
+		select {
+		case buckets[msg.Id()%n].msgOutCh <- msg:
+		case <-m.SigChan():
+			for range in {
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package exec_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	_ schema.Source              = (*shardedSource)(nil)
+	_ schema.SourcePartitionable = (*shardedTable)(nil)
+
+	shardParts = []*schema.Partition{
+		{Id: "0", Left: "", Right: "m"},
+		{Id: "1", Left: "m", Right: ""},
+	}
+)
+
+// shardedSource tables are split by range of a key column into partitions,
+// each of which is a separate in-memory btree
+type shardedSource struct {
+	tables map[string]*shardedTable
+}
+
+// shardedTable scans all rows, or a single partition via PartitionSource
+type shardedTable struct {
+	*membtree.StaticDataSource
+	key    string
+	shards []*membtree.StaticDataSource
+	broken string // id of a partition whose scan fails
+}
+
+// brokenShard a partition whose scan fails after its rows are read
+type brokenShard struct {
+	*membtree.StaticDataSource
+}
+
+func (m *brokenShard) Err() error { return fmt.Errorf("partition scan failed") }
+
+func newShardedTable(name string, keyCol int, cols []string, rows [][]driver.Value) *shardedTable {
+	t := &shardedTable{
+		StaticDataSource: membtree.NewStaticDataSource(name, 0, rows, cols),
+		key:              cols[keyCol],
+	}
+	for _, part := range shardParts {
+		partRows := make([][]driver.Value, 0)
+		for _, row := range rows {
+			key := row[keyCol].(string)
+			if key >= part.Left && (part.Right == "" || key < part.Right) {
+				partRows = append(partRows, row)
+			}
+		}
+		t.shards = append(t.shards, membtree.NewStaticDataSource(name, 0, partRows, cols))
+	}
+	return t
+}
+
+func (m *shardedSource) Tables() []string {
+	names := make([]string, 0, len(m.tables))
+	for name := range m.tables {
+		names = append(names, name)
+	}
+	return names
+}
+func (m *shardedSource) Open(name string) (schema.Conn, error) {
+	if t, ok := m.tables[name]; ok {
+		return t, nil
+	}
+	return nil, schema.ErrNotFound
+}
+func (m *shardedSource) Table(name string) (*schema.Table, error) {
+	t, ok := m.tables[name]
+	if !ok {
+		return nil, schema.ErrNotFound
+	}
+	tbl, _ := t.StaticDataSource.Table(name)
+	tbl.Partition = &schema.TablePartition{Table: name, Keys: []string{t.key}, Partitions: shardParts}
+	return tbl, nil
+}
+func (m *shardedSource) Close() error { return nil }
+
+func (m *shardedTable) Partitions() []*schema.Partition { return shardParts }
+func (m *shardedTable) PartitionSource(p *schema.Partition) (schema.Conn, error) {
+	for i, part := range shardParts {
+		if part.Id == p.Id && part.Id == m.broken {
+			return &brokenShard{m.shards[i]}, nil
+		} else if part.Id == p.Id {
+			return m.shards[i], nil
+		}
+	}
+	return nil, schema.ErrNotFound
+}
+
+var shardedSchema = func() *schema.Schema {
+	userCols := []string{"user_id", "name"}
+	users := [][]driver.Value{{"a1", "alice"}, {"b2", "bob"}, {"n3", "nancy"}, {"z4", "zed"}}
+	orderCols := []string{"order_id", "user_id", "price"}
+	orders := [][]driver.Value{{"o1", "a1", "10"}, {"o2", "n3", "20"}, {"o3", "n3", "30"}, {"o4", "q9", "5"}}
+	src := &shardedSource{tables: map[string]*shardedTable{
+		"users": newShardedTable("users", 0, userCols, users),
+		// partitioned on user_id, same as users
+		"orders": newShardedTable("orders", 1, orderCols, orders),
+		// partitioned on order_id
+		"orders_by_id": newShardedTable("orders_by_id", 0, orderCols, orders),
+		"orders_broken": func() *shardedTable {
+			t := newShardedTable("orders_broken", 1, orderCols, orders)
+			t.broken = "1"
+			return t
+		}(),
+	}}
+	return datasource.RegisterSchemaSource("sharded", "sharded", src)
+}()
+
+func TestExecJoinPartitioned(t *testing.T) {
+	tests := []struct {
+		sql     string
+		explain string
+	}{
+		{`SELECT u.user_id, o.order_id FROM users AS u INNER JOIN orders AS o ON u.user_id = o.user_id`,
+			"hash colocated partitions=2"},
+		{`SELECT u.user_id, o.order_id FROM users AS u INNER JOIN orders_by_id AS o ON u.user_id = o.user_id`,
+			"hash repartition=2"},
+	}
+	for _, tt := range tests {
+		ctx := plan.NewContext(tt.sql)
+		ctx.DisableRecover = true
+		ctx.Schema = shardedSchema
+		ctx.Session = datasource.NewMySqlSessionVars()
+
+		stmt, err := rel.ParseSql(tt.sql)
+		assert.Tf(t, err == nil, "Must parse %s but got %v", tt.sql, err)
+		pln, err := plan.WalkStmt(ctx, stmt, plan.NewPlanner(ctx))
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+		found := false
+		for _, step := range plan.ExplainTask(pln) {
+			if step.Task == "join" {
+				found = true
+				assert.Equalf(t, tt.explain, step.Detail, "join plan for %s", tt.sql)
+			}
+		}
+		assert.Tf(t, found, "expected join in plan for %s", tt.sql)
+
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+		err = job.Run()
+		assert.Tf(t, err == nil, "no error %v", err)
+
+		rows := make([]string, 0, len(msgs))
+		for _, msg := range msgs {
+			vals := msg.(*datasource.SqlDriverMessageMap).Values()
+			rows = append(rows, fmt.Sprintf("%v:%v", vals[0], vals[1]))
+		}
+		sort.Strings(rows)
+		assert.Equalf(t, "a1:o1,n3:o2,n3:o3", strings.Join(rows, ","), "rows for %s", tt.sql)
+	}
+}
+
+func TestExecJoinPartitionedError(t *testing.T) {
+	sql := `SELECT u.user_id, o.order_id FROM users AS u INNER JOIN orders_broken AS o ON u.user_id = o.user_id`
+	ctx := plan.NewContext(sql)
+	ctx.DisableRecover = true
+	ctx.Schema = shardedSchema
+	ctx.Session = datasource.NewMySqlSessionVars()
+
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	msgs := make([]schema.Message, 0)
+	job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+	assert.T(t, job.Setup() == nil)
+	err = job.Run()
+	assert.Tf(t, err != nil, "expected the error of the failed partition")
+	assert.Tf(t, strings.Contains(err.Error(), "partition scan failed"), "got %v", err)
+}
@@ -0,0 +1,40 @@
+package exec
+
+import (
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/plan"
+)
+
+var _ = u.EMPTY
+
+// memReservation the memory held by a single operator, reserved against the
+// MemoryAccount of its query.  Only used by the goroutine of the operator.
+type memReservation struct {
+	account *plan.MemoryAccount
+	metric  *plan.OperatorMetric // of the operator, nil if not analyzed
+	name    string
+	used    int64
+}
+
+func newMemReservation(task *TaskBase, name string) *memReservation {
+	return &memReservation{account: task.Ctx.Memory(), metric: task.metric, name: name}
+}
+
+// Grow reserve n more bytes, a plan.MemoryQuotaError if over query's limit
+func (m *memReservation) Grow(n int64) error {
+	if err := m.account.Reserve(m.name, n); err != nil {
+		return err
+	}
+	m.used += n
+	if m.metric != nil {
+		m.metric.Memory(m.used)
+	}
+	return nil
+}
+
+// Release all memory held by operator
+func (m *memReservation) Release() {
+	m.account.Release(m.used)
+	m.used = 0
+}
@@ -0,0 +1,81 @@
+package exec_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qlbridge-memory")
+	assert.Tf(t, err == nil, "no error %v", err)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		sql   string
+		limit int64
+		rows  int
+		quota bool // fails with memory quota exceeded, instead of spilling
+	}{
+		// spill to disk, before reaching their own budget
+		{`SELECT n FROM numbers WHERE n < 2000 ORDER BY n ASC`, 4096, 2000, false},
+		{`SELECT g, count(*) AS ct FROM numbers GROUP BY g`, 4096, 100, false},
+		{`SELECT DISTINCT g FROM numbers`, 2048, 100, false},
+		// can not spill
+		{`SELECT n, row_number() OVER (PARTITION BY g ORDER BY n) AS rn FROM numbers`, 4096, 0, true},
+		{`SELECT g FROM numbers INTERSECT SELECT g FROM numbers WHERE n < 5000`, 1024, 0, true},
+		// no limit
+		{`SELECT n, row_number() OVER (PARTITION BY g ORDER BY n) AS rn FROM numbers WHERE n < 300`, 0, 300, false},
+	}
+	for _, tt := range tests {
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema = scanSchema
+		ctx.MemoryLimit = tt.limit
+		ctx.TempDir = dir
+
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+		err = job.Run()
+		job.Close()
+
+		if tt.quota {
+			assert.Tf(t, plan.IsMemoryQuotaExceeded(err), "expected quota error for %s got %v", tt.sql, err)
+		} else {
+			assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+			assert.Equalf(t, tt.rows, len(msgs), "rows of %s", tt.sql)
+		}
+		assert.Equalf(t, int64(0), ctx.Memory().Used(), "memory released after %s", tt.sql)
+		assert.Tf(t, ctx.Memory().Peak() > 0, "memory reserved by %s", tt.sql)
+		if tt.limit > 0 {
+			assert.Tf(t, ctx.Memory().Peak() <= tt.limit, "peak %d over limit for %s", ctx.Memory().Peak(), tt.sql)
+		}
+
+		if order := findOrder(job.RootTask); order != nil && !tt.quota {
+			assert.Tf(t, order.Runs() > 0, "expected order by to spill")
+		}
+		if gb, ok := findTask(job.RootTask, func(t exec.Task) bool {
+			_, ok := t.(*exec.GroupBy)
+			return ok
+		}).(*exec.GroupBy); ok {
+			assert.Tf(t, gb.Spills() > 0, "expected group by to spill")
+		}
+		if d, ok := findTask(job.RootTask, func(t exec.Task) bool {
+			_, ok := t.(*exec.Distinct)
+			return ok
+		}).(*exec.Distinct); ok {
+			assert.Tf(t, d.Spills() > 0, "expected distinct to spill")
+		}
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Equalf(t, 0, len(files), "spilled files should be removed %v", files)
+}
@@ -0,0 +1,181 @@
+package exec
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/plan"
+)
+
+var _ = u.EMPTY
+
+// metered a task with execution metrics, all tasks built on TaskBase
+type metered interface {
+	taskBase() *TaskBase
+}
+
+func (m *TaskBase) taskBase() *TaskBase { return m }
+
+// Metric the execution metrics of this task, nil unless the query is
+// analyzed (plan.Context Analyze)
+func (m *TaskBase) Metric() *plan.OperatorMetric { return m.metric }
+
+// registerMetric add the metrics of task to the context of an analyzed
+// query.  Sequences and parallel tasks are not operators, their tasks are.
+func registerMetric(task TaskRunner, depth int) {
+	switch task.(type) {
+	case *TaskSequential, *TaskParallel:
+		return
+	}
+	mt, ok := task.(metered)
+	if !ok {
+		return
+	}
+	tb := mt.taskBase()
+	if tb.Ctx == nil || !(tb.Ctx.Analyze || tb.Ctx.Tracing()) || tb.metric != nil {
+		return
+	}
+	name := tb.Name
+	if name == "" {
+		name = strings.ToLower(strings.TrimPrefix(fmt.Sprintf("%T", task), "*exec."))
+	}
+	tb.metric = tb.Ctx.OperatorMetric(name, depth)
+}
+
+// meteredTask the task of the operator of task, for a sequence its first
+// (or last) task as that is the one reading (or writing) its channel
+func meteredTask(task TaskRunner, last bool) *TaskBase {
+	switch tt := task.(type) {
+	case *TaskSequential:
+		if len(tt.runners) == 0 {
+			return nil
+		}
+		if last {
+			return meteredTask(tt.runners[len(tt.runners)-1], last)
+		}
+		return meteredTask(tt.runners[0], last)
+	case *TaskParallel:
+		return nil
+	case metered:
+		return tt.taskBase()
+	}
+	return nil
+}
+
+// taskMetric the metric of task, see meteredTask
+func taskMetric(task TaskRunner, last bool) *plan.OperatorMetric {
+	if tb := meteredTask(task, last); tb != nil {
+		return tb.metric
+	}
+	return nil
+}
+
+// connect the output of up to the input of down.  If they are metered the
+// rows are counted as they pass through a channel in between.
+//
+//   up -> out -> [ count ] -> in -> down
+func connect(up, down TaskRunner) {
+	out, in := taskMetric(up, true), taskMetric(down, false)
+	if out == nil && in == nil {
+		down.MessageInSet(up.MessageOut())
+		return
+	}
+	src := up.MessageOut()
+	dst := make(MessageChan, cap(src))
+	down.MessageInSet(dst)
+	done := make(chan struct{})
+	if tb := meteredTask(up, true); tb != nil {
+		tb.outDone = done
+	}
+	go countRows(src, dst, done, down.SigChan(), out, in)
+}
+
+func countRows(src, dst MessageChan, done chan struct{}, sigCh SigChan, out, in *plan.OperatorMetric) {
+	defer close(done)
+	defer close(dst)
+	for msg := range src {
+		var ct int64
+		switch mt := msg.(type) {
+		case nil:
+			// limit reached
+		case *RowBatch:
+			ct = int64(mt.Len())
+		default:
+			ct = 1
+		}
+		// counted before sending, so they are counted once down is done
+		if out != nil {
+			out.AddRows(0, ct)
+		}
+		if in != nil {
+			in.AddRows(ct, 0)
+		}
+		select {
+		case dst <- msg:
+		case <-sigCh:
+			if in != nil {
+				in.AddRows(-ct, 0)
+			}
+			return
+		}
+	}
+}
+
+// runTask run task, timing it if metered.  The goroutine is locked to its
+// thread while running so the cpu time of the thread is that of the task.
+// If traced the task is a span, ended once its rows have been counted.
+func runTask(task TaskRunner) error {
+	var tb *TaskBase
+	switch task.(type) {
+	case *TaskSequential, *TaskParallel:
+	default:
+		tb = meteredTask(task, false)
+	}
+	if tb == nil || tb.metric == nil {
+		return task.Run()
+	}
+	metric := tb.metric
+	span := tb.Ctx.StartSpan("exec." + metric.Name)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	start, cpu := time.Now(), threadCPU()
+	err := task.Run()
+	metric.Wall = time.Since(start)
+	metric.CPU = threadCPU() - cpu
+	if tb.Ctx.Tracing() {
+		if tb.outDone != nil {
+			// rows still buffered on the way out are not yet counted
+			select {
+			case <-tb.outDone:
+			case <-tb.SigChan():
+			}
+		}
+		traceOperator(span, task, metric)
+	}
+	plan.EndSpan(span, err)
+	return err
+}
+
+// traced a task with attributes of its own on its span, beyond its metrics
+type traced interface {
+	trace(span plan.Span)
+}
+
+// traceOperator set the metrics of an operator on its span
+func traceOperator(span plan.Span, task TaskRunner, metric *plan.OperatorMetric) {
+	span.SetAttribute("rows_in", atomic.LoadInt64(&metric.RowsIn))
+	span.SetAttribute("rows_out", atomic.LoadInt64(&metric.RowsOut))
+	span.SetAttribute("cpu", metric.CPU)
+	span.SetAttribute("peak_memory", atomic.LoadInt64(&metric.PeakMemory))
+	if len(metric.Adaptions) > 0 {
+		span.SetAttribute("adapted", metric.Adaptions)
+	}
+	if t, ok := task.(traced); ok {
+		t.trace(span)
+	}
+}
@@ -0,0 +1,62 @@
+package exec_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+func TestOperatorMetrics(t *testing.T) {
+	tests := []struct {
+		sql     string
+		analyze bool
+		batch   int
+		rows    map[string][2]int64 // operator -> rows in, out
+		memory  string              // operator expected to hold memory
+	}{
+		{`SELECT g, count(*) AS ct FROM numbers WHERE n < 500 GROUP BY g`, true, 0,
+			map[string][2]int64{"source": {0, 10000}, "where": {10000, 500}, "groupby": {500, 100}}, "groupby"},
+		// batches count their rows
+		{`SELECT g, count(*) AS ct FROM numbers WHERE n < 500 GROUP BY g`, true, 16,
+			map[string][2]int64{"source": {0, 10000}, "where": {10000, 500}, "groupby": {500, 100}}, "groupby"},
+		{`SELECT n FROM numbers WHERE n >= 9000 ORDER BY n ASC`, true, 0,
+			map[string][2]int64{"where": {10000, 1000}, "order": {1000, 1000}}, "order"},
+		{`SELECT n FROM numbers WHERE n >= 9000`, false, 0, nil, ""},
+	}
+	for _, tt := range tests {
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema = scanSchema
+		ctx.Analyze = tt.analyze
+		ctx.RowBatchSize = tt.batch
+
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+		assert.Tf(t, job.Run() == nil, "no error for %s", tt.sql)
+		job.Close()
+
+		if !tt.analyze {
+			assert.Equalf(t, 0, len(ctx.Operators), "no metrics unless analyzed %s", tt.sql)
+			continue
+		}
+		for name, rows := range tt.rows {
+			op := ctx.Operators.Operator(name)
+			assert.Tf(t, op != nil, "expected %s operator in %s", name, ctx.Operators)
+			assert.Equalf(t, rows, [2]int64{op.RowsIn, op.RowsOut}, "rows of %s for %s", name, tt.sql)
+			assert.Tf(t, op.Wall > 0, "wall time of %s", name)
+		}
+		assert.Tf(t, ctx.Operators.Operator(tt.memory).PeakMemory > 0, "peak memory of %s", tt.memory)
+		assert.Equalf(t, len(msgs), int(ctx.Operators.Operator("result").RowsIn), "rows to result")
+
+		out := ctx.Operators.String()
+		assert.Equalf(t, len(ctx.Operators), len(strings.Split(out, "\n")), "a line per operator %s", out)
+		assert.Tf(t, strings.HasPrefix(strings.TrimSpace(out), "source rows_in=0 rows_out=10000 "), "explain analyze %s", out)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	u "github.com/araddon/gou"
 
 	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
 	"github.com/araddon/qlbridge/plan"
 	"github.com/araddon/qlbridge/rel"
 	"github.com/araddon/qlbridge/schema"
@@ -17,20 +18,40 @@ var (
 	_ = u.EMPTY
 
 	_ TaskRunner = (*Upsert)(nil)
+	_ BatchTask  = (*Upsert)(nil)
 	_ TaskRunner = (*DeletionTask)(nil)
 	_ TaskRunner = (*DeletionScanner)(nil)
 )
 
+const (
+	// DefaultWriteBatchSize rows written to a source at once by an insert,
+	// over-ride with plan.Context WriteBatchSize.
+	DefaultWriteBatchSize = 100
+)
+
 type (
-	// Upsert task for insert, update, upsert
+	// Upsert task for insert, update, upsert.  Rows are written to the
+	// source in batches of WriteBatchSize with PutMulti, or with Put for
+	// sources whose PutMulti is not implemented.
 	Upsert struct {
 		*TaskBase
-		closed  bool
-		insert  *rel.SqlInsert
-		update  *rel.SqlUpdate
-		upsert  *rel.SqlUpsert
-		db      schema.ConnUpsert
-		dbpatch schema.ConnPatchWhere
+		closed    bool
+		insert    *rel.SqlInsert
+		update    *rel.SqlUpdate
+		upsert    *rel.SqlUpsert
+		db        schema.ConnUpsert
+		dbpatch   schema.ConnPatchWhere
+		key       schema.Key
+		positions []int // position in table row of each value, nil if in order
+		width     int
+		tbl       *schema.Table    // of the defaults and constraints of its columns, nil if none
+		given     []bool           // columns of the table row given by the insert, nil if all
+		rowCt     int              // rows put, of constraint errors
+		pending   [][]driver.Value // rows not yet written
+		single    bool             // source has no PutMulti, Put each row
+		pendingId uint64           // of the message of the last pending row
+		writtenId uint64           // of the message of the last row written
+		written   int64
 	}
 	// Delete task for sources that natively support delete
 	DeletionTask struct {
@@ -50,33 +71,66 @@ type (
 // An insert to write to data source
 func NewInsert(ctx *plan.Context, p *plan.Insert) *Upsert {
 	m := &Upsert{
-		TaskBase: NewTaskBase(ctx),
-		db:       p.Source,
-		insert:   p.Stmt,
+		TaskBase:  NewTaskBaseNamed(ctx, "mutation"),
+		db:        p.Source,
+		insert:    p.Stmt,
+		positions: p.Positions,
+		width:     p.Width,
+		tbl:       p.Table,
 	}
+	m.given = givenColumns(p.Positions, p.Width)
 	return m
 }
 func NewUpdate(ctx *plan.Context, p *plan.Update) *Upsert {
 	m := &Upsert{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "mutation"),
 		db:       p.Source,
+		dbpatch:  p.Patch,
+		key:      p.Key,
 		update:   p.Stmt,
+		tbl:      p.Table,
 	}
 	return m
 }
 func NewUpsert(ctx *plan.Context, p *plan.Upsert) *Upsert {
 	m := &Upsert{
-		TaskBase: NewTaskBase(ctx),
-		db:       p.Source,
-		upsert:   p.Stmt,
+		TaskBase:  NewTaskBaseNamed(ctx, "mutation"),
+		db:        p.Source,
+		upsert:    p.Stmt,
+		positions: p.Positions,
+		width:     p.Width,
+		tbl:       p.Table,
 	}
+	m.given = givenColumns(p.Positions, p.Width)
 	return m
 }
 
+// givenColumns the columns of a table row of width given by the values of
+// positions, nil if they are all given in order
+func givenColumns(positions []int, width int) []bool {
+	if positions == nil {
+		return nil
+	}
+	given := make([]bool, width)
+	for _, pos := range positions {
+		given[pos] = true
+	}
+	return given
+}
+
+// WriteBatchSize rows an insert writes to its source at once, 1 for each
+// row on its own
+func WriteBatchSize(ctx *plan.Context) int {
+	if ctx == nil || ctx.WriteBatchSize <= 0 {
+		return DefaultWriteBatchSize
+	}
+	return ctx.WriteBatchSize
+}
+
 // An inserter to write to data source
 func NewDelete(ctx *plan.Context, p *plan.Delete) *DeletionTask {
 	m := &DeletionTask{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "mutation"),
 		db:       p.Source,
 		sql:      p.Stmt,
 		p:        p,
@@ -85,10 +139,15 @@ func NewDelete(ctx *plan.Context, p *plan.Delete) *DeletionTask {
 }
 
 func (m *Upsert) Close() error {
+	m.Lock()
 	if m.closed {
+		m.Unlock()
 		return nil
 	}
 	m.closed = true
+	m.Unlock()
+	// messages of rows not written, ie the insert was cancelled
+	m.nack()
 	if closer, ok := m.db.(schema.Source); ok {
 		if err := closer.Close(); err != nil {
 			return err
@@ -104,6 +163,8 @@ func (m *Upsert) Run() error {
 	var err error
 	var affectedCt int64
 	switch {
+	case m.insert != nil && m.insert.Select != nil:
+		affectedCt, err = m.insertSelected()
 	case m.insert != nil:
 		affectedCt, err = m.insertRows(m.insert.Rows)
 	case m.upsert != nil && len(m.upsert.Rows) > 0:
@@ -155,12 +216,18 @@ func (m *Upsert) updateValues() (int64, error) {
 			valmap[key] = valcol.Value.Value()
 		}
 		//u.Debugf("key:%v col: %v   vals:%v", key, valcol, valmap[key])
+		if m.tbl != nil {
+			if f, ok := m.tbl.Field(key); ok {
+				if err := f.CheckValue(valmap[key], 1); err != nil {
+					return 0, err
+				}
+			}
+		}
 	}
 
 	// if our backend source supports Where-Patches, ie update multiple
-	dbpatch, ok := m.db.(schema.ConnPatchWhere)
-	if ok {
-		updated, err := dbpatch.PatchWhere(m.Ctx, m.update.Where.Expr, valmap)
+	if m.dbpatch != nil {
+		updated, err := m.dbpatch.PatchWhere(m.Ctx, m.update.Where.Expr, valmap)
 		u.Infof("patch: %v %v", updated, err)
 		if err != nil {
 			return updated, err
@@ -169,12 +236,10 @@ func (m *Upsert) updateValues() (int64, error) {
 	}
 
 	// TODO:   If it does not implement Where Patch then we need to do a poly fill
-	//      Do we have to recognize if the Where is on a primary key?
 	// - for sources/queries that can't do partial updates we need to do a read first
 
-	// Create a key from Where
-	key := datasource.KeyFromWhere(m.update.Where)
-	if _, err := m.db.Put(m.Ctx, key, valmap); err != nil {
+	// The planner extracted key from Where
+	if _, err := m.db.Put(m.Ctx, m.key, valmap); err != nil {
 		u.Errorf("Could not put values: %v", err)
 		return 0, err
 	}
@@ -182,13 +247,10 @@ func (m *Upsert) updateValues() (int64, error) {
 }
 
 func (m *Upsert) insertRows(rows [][]*rel.ValueColumn) (int64, error) {
-	for i, row := range rows {
+	for _, row := range rows {
 		select {
 		case <-m.SigChan():
-			if i == 0 {
-				return 0, nil
-			}
-			return int64(i) - 1, nil
+			return m.written, nil
 		default:
 			vals := make([]driver.Value, len(row))
 			for x, val := range row {
@@ -196,21 +258,161 @@ func (m *Upsert) insertRows(rows [][]*rel.ValueColumn) (int64, error) {
 					exprVal, ok := vm.Eval(nil, val.Expr)
 					if !ok {
 						u.Errorf("Could not evaluate: %v", val.Expr)
-						return 0, fmt.Errorf("Could not evaluate expression: %v", val.Expr)
+						return m.written, fmt.Errorf("Could not evaluate expression: %v", val.Expr)
 					}
 					vals[x] = exprVal.Value()
 				} else {
 					vals[x] = val.Value.Value()
 				}
 			}
+			if err := m.put(vals); err != nil {
+				return m.written, err
+			}
+		}
+	}
+	return m.written, m.flush()
+}
+
+// insertSelected insert the rows of INSERT INTO ... SELECT which are
+// the messages (or batches of them) from the select task ahead of us,
+// written as they stream in
+func (m *Upsert) insertSelected() (int64, error) {
+	inCh := m.MessageIn()
+	for {
+		select {
+		case <-m.SigChan():
+			return m.written, nil
+		case msg, ok := <-inCh:
+			// nil once a LIMIT is reached
+			if !ok || msg == nil {
+				if err := m.flush(); err != nil {
+					return m.written, err
+				}
+				// messages after those written or filtered out, ie cut by a
+				// LIMIT, are redelivered
+				m.ack(m.ackedThrough())
+				m.nack()
+				return m.written, nil
+			}
+			msgs := []schema.Message{msg}
+			if batch, isBatch := msg.(*RowBatch); isBatch {
+				msgs = batch.Rows
+			}
+			for _, msg := range msgs {
+				var vals []driver.Value
+				switch mt := msg.(type) {
+				case *datasource.SqlDriverMessageMap:
+					vals = mt.Values()
+				case *datasource.SqlDriverMessage:
+					vals = mt.Vals
+				default:
+					return m.written, fmt.Errorf("unsupported message type for insert %T", msg)
+				}
+				m.pendingId = msg.Id()
+				if err := m.put(vals); err != nil {
+					return m.written, err
+				}
+			}
+		}
+	}
+}
+
+// Batched implements BatchTask, the rows of batches are inserted
+func (m *Upsert) Batched(in bool) bool { return false }
+
+// put a row, written once there are WriteBatchSize rows pending
+func (m *Upsert) put(vals []driver.Value) error {
+	if m.positions != nil {
+		row := make([]driver.Value, m.width)
+		for i, pos := range m.positions {
+			if i < len(vals) {
+				row[pos] = vals[i]
+			}
+		}
+		vals = row
+	}
+	if m.tbl != nil {
+		m.rowCt++
+		if err := m.tbl.WriteRow(vals, m.given, m.rowCt); err != nil {
+			m.nack()
+			return err
+		}
+	}
+	if m.single || WriteBatchSize(m.Ctx) <= 1 {
+		if _, err := m.db.Put(m.Ctx, nil, vals); err != nil {
+			u.Errorf("Could not put values: fordb T:%T  %v", m.db, err)
+			m.nack()
+			return err
+		}
+		m.written++
+		m.writtenId = m.pendingId
+		m.ack(m.writtenId)
+		return nil
+	}
+	m.pending = append(m.pending, vals)
+	if len(m.pending) >= WriteBatchSize(m.Ctx) {
+		return m.flush()
+	}
+	return nil
+}
 
-			if _, err := m.db.Put(m.Ctx, nil, vals); err != nil {
-				u.Errorf("Could not put values: fordb T:%T  %v", m.db, err)
-				return 0, err
+// flush write the pending rows to source
+func (m *Upsert) flush() error {
+	if len(m.pending) == 0 {
+		return nil
+	}
+	_, err := m.db.PutMulti(m.Ctx, nil, m.pending)
+	if err == schema.ErrNotImplemented {
+		// no batch writes, Put each row of this and all later batches
+		m.single = true
+		for _, row := range m.pending {
+			if _, err = m.db.Put(m.Ctx, nil, row); err != nil {
+				break
 			}
 		}
 	}
-	return int64(len(rows)), nil
+	if err != nil {
+		u.Errorf("Could not put values: fordb T:%T  %v", m.db, err)
+		m.nack()
+		return err
+	}
+	m.written += int64(len(m.pending))
+	m.pending = m.pending[:0]
+	m.writtenId = m.pendingId
+	m.ack(m.writtenId)
+	return nil
+}
+
+// ackedThrough the id of the last message of the rows written, or of
+// those filtered out after them if every row kept by the where was written
+func (m *Upsert) ackedThrough() uint64 {
+	kept, dropped := m.Ctx.FilteredIds()
+	if dropped > m.writtenId && kept <= m.writtenId {
+		return dropped
+	}
+	return m.writtenId
+}
+
+// ack the messages of the rows up to id of the scans of messages (see
+// schema.ConnAcker) of an insert of a select, once the rows are written
+func (m *Upsert) ack(id uint64) {
+	if id == 0 {
+		return
+	}
+	for _, acker := range m.Ctx.Ackers() {
+		if err := acker.Ack(id); err != nil {
+			u.Warnf("could not ack written messages: %v", err)
+		}
+	}
+}
+
+// nack the messages not written, to be redelivered
+func (m *Upsert) nack() {
+	for _, acker := range m.Ctx.Ackers() {
+		if err := acker.Nack(); err != nil {
+			u.Warnf("could not nack messages: %v", err)
+		}
+	}
 }
 
 func (m *DeletionTask) Close() error {
@@ -234,7 +436,11 @@ func (m *DeletionTask) Run() error {
 	defer close(m.msgOutCh)
 
 	vals := make([]driver.Value, 2)
-	deletedCt, err := m.db.DeleteExpression(m.p, m.sql.Where.Expr)
+	var where expr.Node
+	if m.sql.Where != nil {
+		where = m.sql.Where.Expr
+	}
+	deletedCt, err := m.db.DeleteExpression(m.p, where)
 	if err != nil {
 		u.Errorf("Could not delete values: %v", err)
 		vals[0] = err.Error()
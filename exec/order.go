@@ -2,7 +2,8 @@ package exec
 
 import (
 	"fmt"
-	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	u "github.com/araddon/gou"
@@ -10,6 +11,7 @@ import (
 	"github.com/araddon/qlbridge/datasource"
 	"github.com/araddon/qlbridge/expr"
 	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/value"
 	"github.com/araddon/qlbridge/vm"
 )
 
@@ -17,6 +19,7 @@ import (
 type Order struct {
 	*TaskBase
 	p          *plan.Order
+	sorter     orderSorter
 	complete   chan bool
 	closed     bool
 	isComplete bool
@@ -25,7 +28,7 @@ type Order struct {
 // NewORder create new order by exec task
 func NewOrder(ctx *plan.Context, p *plan.Order) *Order {
 	o := &Order{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "order"),
 		p:        p,
 		complete: make(chan bool),
 	}
@@ -64,11 +67,20 @@ func (m *Order) Run() error {
 	inCh := m.MessageIn()
 
 	colIndex := m.p.Stmt.ColIndexes()
-	orderCt := len(m.p.Stmt.OrderBy)
 
-	// are are going to hold entire row in memory while we are calculating
-	//  so obviously not scalable.
-	sl := NewOrderMessages(m.p)
+	// with a limit only the top rows are kept, else rows are held in memory
+	// up to the SortMemory budget, past that sorted runs are spilled to disk
+	// and merged when emitting
+	var sorter orderSorter
+	if m.p.Limit > 0 {
+		sorter = newOrderTopN(m.p)
+	} else {
+		sorter = newOrderSpill(m.p, SortMemory(m.Ctx), tempDir(m.Ctx), newMemReservation(m.TaskBase, "order"))
+	}
+	defer sorter.Close()
+	m.Lock()
+	m.sorter = sorter
+	m.Unlock()
 
 msgReadLoop:
 	for {
@@ -100,34 +112,26 @@ msgReadLoop:
 					sdm = datasource.NewSqlDriverMessageMapCtx(msg.Id(), msgReader, colIndex)
 				}
 
-				// We are going to use VM Engine to create a value for each statement in group by
-				//  then join each value together to create a unique key.
-				keys := make([]string, orderCt)
-				for i, col := range m.p.Stmt.OrderBy {
-					if col.Expr != nil {
-						if key, ok := vm.Eval(sdm, col.Expr); ok {
-							//u.Debugf("msgtype:%T  key:%q for-expr:%s", sdm, key, col.Expr)
-							keys[i] = key.ToString()
-						} else {
-							// Is this an error?
-							//u.Warnf("no key?  %s for %+v", col.Expr, sdm)
-						}
-					} else {
-						//u.Warnf("no col.expr? %#v", col)
-					}
-				}
-
 				//u.Infof("found key:%s for %+v", key, sdm)
-				sl.l = append(sl.l, &msgkey{keys, sdm})
+				if err := sorter.Add(&msgkey{orderKeys(m.p, sdm), sdm}); err != nil {
+					u.Errorf("could not spill sort run: %v", err)
+					return err
+				}
 			}
 		}
 	}
 
-	sort.Sort(sl)
-
-	for _, m := range sl.l {
-		//u.Debugf("got %s:%v msgs", key, vals)
-		outCh <- m.msg
+	err := sorter.Emit(func(mk *msgkey) bool {
+		select {
+		case outCh <- mk.msg:
+			return true
+		case <-m.SigChan():
+			return false
+		}
+	})
+	if err != nil {
+		u.Errorf("could not merge sort runs: %v", err)
+		return err
 	}
 
 	m.isComplete = true
@@ -136,8 +140,45 @@ msgReadLoop:
 	return nil
 }
 
+// Runs number of sorted runs spilled to disk, 0 if sorted in memory
+func (m *Order) Runs() int {
+	m.Lock()
+	defer m.Unlock()
+	spill, ok := m.sorter.(*orderSpill)
+	if !ok {
+		return 0
+	}
+	return int(atomic.LoadInt32(&spill.spilled))
+}
+
+// orderKeys use VM Engine to create a value for each expression in order by
+func orderKeys(p *plan.Order, sdm *datasource.SqlDriverMessageMap) []value.Value {
+	keys := make([]value.Value, len(p.Stmt.OrderBy))
+	for i, col := range p.Stmt.OrderBy {
+		if col.Expr != nil {
+			if key, ok := vm.Eval(sdm, col.Expr); ok {
+				//u.Debugf("msgtype:%T  key:%q for-expr:%s", sdm, key, col.Expr)
+				keys[i] = key
+			} else {
+				// Is this an error?
+				//u.Warnf("no key?  %s for %+v", col.Expr, sdm)
+			}
+		} else {
+			//u.Warnf("no col.expr? %#v", col)
+		}
+	}
+	return keys
+}
+
+// orderSorter sorts the rows of an order by
+type orderSorter interface {
+	Add(mk *msgkey) error
+	Emit(emit func(*msgkey) bool) error
+	Close() error
+}
+
 type msgkey struct {
-	keys []string
+	keys []value.Value
 	msg  *datasource.SqlDriverMessageMap
 }
 type OrderMessages struct {
@@ -164,20 +205,49 @@ func (m *OrderMessages) Len() int {
 	return len(m.l)
 }
 func (m *OrderMessages) Less(i, j int) bool {
-	for ki, key := range m.l[i].keys {
-		if key < m.l[j].keys[ki] {
-			if m.invert[ki] {
-				return false
-			}
-			return true
-		} else {
-			if m.invert[ki] {
-				return true
-			}
+	return m.less(m.l[i], m.l[j])
+}
+func (m *OrderMessages) less(a, b *msgkey) bool {
+	for ki, key := range a.keys {
+		c := compareValues(key, b.keys[ki])
+		if c == 0 {
+			continue
 		}
+		if m.invert[ki] {
+			return c > 0
+		}
+		return c < 0
 	}
 	return false
 }
 func (m *OrderMessages) Swap(i, j int) {
 	m.l[i], m.l[j] = m.l[j], m.l[i]
 }
+
+// compareValues compare two values for sorting, -1, 0, 1 for less, equal,
+// greater.  Numeric (int, number, time) values compare numerically, others
+// by their string value, nil (un-evaluable) values sort first.
+func compareValues(a, b value.Value) int {
+	aNil, bNil := a == nil || a.Nil(), b == nil || b.Nil()
+	switch {
+	case aNil && bNil:
+		return 0
+	case aNil:
+		return -1
+	case bNil:
+		return 1
+	}
+	an, aNum := a.(value.NumericValue)
+	bn, bNum := b.(value.NumericValue)
+	if aNum && bNum {
+		af, bf := an.Float(), bn.Float()
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a.ToString(), b.ToString())
+}
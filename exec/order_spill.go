@@ -0,0 +1,260 @@
+package exec
+
+import (
+	"bufio"
+	"container/heap"
+	"database/sql/driver"
+	"encoding/gob"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/plan"
+)
+
+var _ = u.EMPTY
+
+const (
+	// SortMemoryDefault bytes of rows an order by holds in memory before
+	// spilling sorted runs to disk.  Over-ride with plan.Context SortMemory.
+	SortMemoryDefault int64 = 64 * 1024 * 1024
+)
+
+func init() {
+	// non-basic value types that may be in a row, so they can be spilled
+	gob.Register(time.Time{})
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// SortMemory the memory budget for an order by from context, or default
+func SortMemory(ctx *plan.Context) int64 {
+	if ctx != nil && ctx.SortMemory > 0 {
+		return ctx.SortMemory
+	}
+	return SortMemoryDefault
+}
+
+// tempDir directory for spill files from context, or os default
+func tempDir(ctx *plan.Context) string {
+	if ctx != nil && ctx.TempDir != "" {
+		return ctx.TempDir
+	}
+	return os.TempDir()
+}
+
+// orderSpill an external merge sort.  Rows are sorted in memory until they
+// exceed the memory budget, then that sorted run is written to a temp file
+// and memory is re-used for the next run.  When emitting, the runs (and rows
+// still in memory) are k-way merged reading one row at a time from each.
+//
+//   rows -> [ sort ] -> run-1 (file)  \
+//        -> [ sort ] -> run-2 (file)   -- merge -->
+//        -> [ sort ] -> (in memory)   /
+type orderSpill struct {
+	p        *plan.Order
+	budget   int64
+	dir      string
+	mem      *OrderMessages
+	used     int64
+	res      *memReservation
+	runs     []string // temp file names of sorted runs
+	spilled  int32
+	colIndex map[string]int
+	files    []*os.File
+}
+
+// spillRow the serialized form of a row in a sorted run
+type spillRow struct {
+	Id   uint64
+	Key  string
+	Vals []driver.Value
+}
+
+func newOrderSpill(p *plan.Order, budget int64, dir string, res *memReservation) *orderSpill {
+	return &orderSpill{
+		p:      p,
+		budget: budget,
+		dir:    dir,
+		mem:    NewOrderMessages(p),
+		res:    res,
+	}
+}
+
+// Add a row, spilling the rows in memory to a sorted run if over budget or
+// the memory limit of query
+func (m *orderSpill) Add(mk *msgkey) error {
+	if m.colIndex == nil {
+		m.colIndex = mk.msg.ColIndex
+	}
+	m.mem.l = append(m.mem.l, mk)
+	size := rowSize(mk.msg.Vals)
+	m.used += size
+	if err := m.res.Grow(size); err != nil || m.used > m.budget {
+		return m.spill()
+	}
+	return nil
+}
+
+func (m *orderSpill) spill() error {
+	sort.Sort(m.mem)
+	f, err := ioutil.TempFile(m.dir, "qlbridge-sort-")
+	if err != nil {
+		return err
+	}
+	m.runs = append(m.runs, f.Name())
+	w := bufio.NewWriter(f)
+	enc := gob.NewEncoder(w)
+	for _, mk := range m.mem.l {
+		row := spillRow{Id: mk.msg.IdVal, Key: mk.msg.Key(), Vals: mk.msg.Vals}
+		if err = enc.Encode(&row); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	atomic.AddInt32(&m.spilled, 1)
+	m.mem.l = make([]*msgkey, 0)
+	m.used = 0
+	m.res.Release()
+	return nil
+}
+
+// Emit all rows in sorted order, stops if emit returns false
+func (m *orderSpill) Emit(emit func(*msgkey) bool) error {
+	sort.Sort(m.mem)
+	if len(m.runs) == 0 {
+		for _, mk := range m.mem.l {
+			if !emit(mk) {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	h := &runHeap{sl: m.mem}
+	if len(m.mem.l) > 0 {
+		h.runs = append(h.runs, &sortRun{mem: m.mem.l})
+	}
+	for _, name := range m.runs {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		m.files = append(m.files, f)
+		h.runs = append(h.runs, &sortRun{dec: gob.NewDecoder(bufio.NewReader(f))})
+	}
+	for _, run := range h.runs {
+		if err := m.next(run); err != nil {
+			return err
+		}
+	}
+	heap.Init(h)
+	for h.Len() > 0 {
+		run := h.runs[0]
+		if run.cur == nil {
+			heap.Pop(h)
+			continue
+		}
+		if !emit(run.cur) {
+			return nil
+		}
+		if err := m.next(run); err != nil {
+			return err
+		}
+		heap.Fix(h, 0)
+	}
+	return nil
+}
+
+// next advance run to its next row, cur is nil when exhausted
+func (m *orderSpill) next(run *sortRun) error {
+	if run.dec == nil {
+		run.cur = nil
+		if len(run.mem) > 0 {
+			run.cur, run.mem = run.mem[0], run.mem[1:]
+		}
+		return nil
+	}
+	var row spillRow
+	if err := run.dec.Decode(&row); err != nil {
+		run.cur = nil
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	sdm := datasource.NewSqlDriverMessageMap(row.Id, row.Vals, m.colIndex)
+	sdm.SetKey(row.Key)
+	run.cur = &msgkey{orderKeys(m.p, sdm), sdm}
+	return nil
+}
+
+// Close remove the spilled runs
+func (m *orderSpill) Close() error {
+	m.res.Release()
+	for _, f := range m.files {
+		f.Close()
+	}
+	for _, name := range m.runs {
+		os.Remove(name)
+	}
+	m.files, m.runs = nil, nil
+	return nil
+}
+
+type sortRun struct {
+	dec *gob.Decoder // nil for the run still in memory
+	mem []*msgkey
+	cur *msgkey
+}
+
+// runHeap of sorted runs ordered by their current row, exhausted runs first
+// so they are popped
+type runHeap struct {
+	sl   *OrderMessages
+	runs []*sortRun
+}
+
+func (m *runHeap) Len() int      { return len(m.runs) }
+func (m *runHeap) Swap(i, j int) { m.runs[i], m.runs[j] = m.runs[j], m.runs[i] }
+func (m *runHeap) Less(i, j int) bool {
+	a, b := m.runs[i].cur, m.runs[j].cur
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+	return m.sl.less(a, b)
+}
+func (m *runHeap) Push(x interface{}) { m.runs = append(m.runs, x.(*sortRun)) }
+func (m *runHeap) Pop() interface{} {
+	run := m.runs[len(m.runs)-1]
+	m.runs = m.runs[:len(m.runs)-1]
+	return run
+}
+
+// rowSize approximate bytes of memory held by a row
+func rowSize(vals []driver.Value) int64 {
+	size := int64(64) // message, slice and key overhead
+	for _, v := range vals {
+		size += 16
+		switch vt := v.(type) {
+		case string:
+			size += int64(len(vt))
+		case []byte:
+			size += int64(len(vt))
+		}
+	}
+	return size
+}
@@ -0,0 +1,119 @@
+package exec_test
+
+import (
+	"database/sql/driver"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+)
+
+// findOrder the order task of a job
+func findOrder(t exec.Task) *exec.Order {
+	if o, ok := t.(*exec.Order); ok {
+		return o
+	}
+	for _, child := range t.Children() {
+		if o := findOrder(child); o != nil {
+			return o
+		}
+	}
+	return nil
+}
+
+func TestOrderSpill(t *testing.T) {
+	tests := []struct {
+		memory int64
+		spills bool
+	}{
+		{0, false},         // default budget fits in memory
+		{64 * 1024, true},  // many runs
+		{512 * 1024, true}, // a few runs and rows left in memory
+	}
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("", "qlbridge-order")
+		assert.Tf(t, err == nil, "no error %v", err)
+		defer os.RemoveAll(dir)
+
+		ctx := plan.NewContext(`SELECT id, n FROM numbers ORDER BY n DESC`)
+		ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("counting")
+		ctx.SortMemory = tt.memory
+		ctx.TempDir = dir
+
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+		rows := exec.NewResultRows(ctx, []string{"id", "n"})
+		job.RootTask.Add(rows)
+		assert.T(t, job.Setup() == nil)
+		go job.Run()
+
+		dest := make([]driver.Value, 2)
+		read := 0
+		for ; rows.Next(dest) == nil; read++ {
+			assert.Equalf(t, int64(9999-read), dest[1], "row %d out of order", read)
+		}
+		assert.Equal(t, 10000, read)
+
+		order := findOrder(job.RootTask)
+		assert.T(t, order != nil)
+		assert.Equalf(t, tt.spills, order.Runs() > 0, "spilled %d runs with memory %d", order.Runs(), tt.memory)
+		job.Close()
+
+		files, _ := ioutil.ReadDir(dir)
+		assert.Equalf(t, 0, len(files), "spilled runs should be removed %v", files)
+	}
+}
+
+func TestOrderTopN(t *testing.T) {
+	tests := []struct {
+		sql     string
+		explain string
+		want    []int64
+	}{
+		{`SELECT id, n FROM numbers ORDER BY n DESC LIMIT 5`, "n DESC top 5",
+			[]int64{9999, 9998, 9997, 9996, 9995}},
+		{`SELECT id, n FROM numbers ORDER BY g ASC, n DESC LIMIT 4`, "g ASC, n DESC top 4",
+			[]int64{9900, 9800, 9700, 9600}},
+		{`SELECT id, n FROM numbers ORDER BY g DESC, n ASC LIMIT 3`, "g DESC, n ASC top 3",
+			[]int64{99, 199, 299}},
+	}
+	for _, tt := range tests {
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("counting")
+		stmt, err := rel.ParseSql(tt.sql)
+		assert.Tf(t, err == nil, "Must parse %s but got %v", tt.sql, err)
+		pln, err := plan.WalkStmt(ctx, stmt, plan.NewPlanner(ctx))
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+		assert.T(t, ctx.Metrics.Applied("topn"))
+		for _, step := range plan.ExplainTask(pln) {
+			if step.Task == "order" {
+				assert.Equalf(t, tt.explain, step.Detail, "order plan for %s", tt.sql)
+			}
+		}
+
+		ctx = plan.NewContext(tt.sql)
+		ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("counting")
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v", err)
+
+		rows := exec.NewResultRows(ctx, []string{"id", "n"})
+		job.RootTask.Add(rows)
+		assert.T(t, job.Setup() == nil)
+		go job.Run()
+
+		got := make([]int64, 0)
+		dest := make([]driver.Value, 2)
+		for rows.Next(dest) == nil {
+			got = append(got, dest[1].(int64))
+		}
+		assert.Equalf(t, tt.want, got, "rows for %s", tt.sql)
+		assert.Equal(t, 0, findOrder(job.RootTask).Runs())
+		job.Close()
+	}
+}
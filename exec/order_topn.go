@@ -0,0 +1,95 @@
+package exec
+
+import (
+	"container/heap"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/plan"
+)
+
+var _ = u.EMPTY
+
+// orderTopN keeps only the first n rows of an order by with a limit.  Rows
+// are held in a bounded heap with the last (worst) of the kept rows on top,
+// a new row replaces it if it sorts before it, so memory is n rows and each
+// row is O(log n) instead of sorting all rows.
+//
+//   rows -> [ heap of n ] -> emit
+type orderTopN struct {
+	n    int
+	sl   *OrderMessages
+	rows []*topRow
+	seq  uint64
+}
+
+// topRow a kept row, seq is arrival order so ties keep first rows
+type topRow struct {
+	mk  *msgkey
+	seq uint64
+}
+
+func newOrderTopN(p *plan.Order) *orderTopN {
+	return &orderTopN{
+		n:    p.Limit,
+		sl:   NewOrderMessages(p),
+		rows: make([]*topRow, 0, p.Limit),
+	}
+}
+
+// before does row a sort before b
+func (m *orderTopN) before(a, b *topRow) bool {
+	if m.sl.less(a.mk, b.mk) {
+		return true
+	}
+	if m.sl.less(b.mk, a.mk) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
+// Add a row, kept if among the first n rows so far
+func (m *orderTopN) Add(mk *msgkey) error {
+	row := &topRow{mk: mk, seq: m.seq}
+	m.seq++
+	if len(m.rows) < m.n {
+		heap.Push(m, row)
+		return nil
+	}
+	if m.before(row, m.rows[0]) {
+		m.rows[0] = row
+		heap.Fix(m, 0)
+	}
+	return nil
+}
+
+// Emit the kept rows in sorted order, stops if emit returns false
+func (m *orderTopN) Emit(emit func(*msgkey) bool) error {
+	// popping the heap gives rows worst first
+	rows := make([]*topRow, len(m.rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		rows[i] = heap.Pop(m).(*topRow)
+	}
+	for _, row := range rows {
+		if !emit(row.mk) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *orderTopN) Close() error {
+	m.rows = nil
+	return nil
+}
+
+// heap.Interface, a max heap so the row to evict is on top
+func (m *orderTopN) Len() int           { return len(m.rows) }
+func (m *orderTopN) Swap(i, j int)      { m.rows[i], m.rows[j] = m.rows[j], m.rows[i] }
+func (m *orderTopN) Less(i, j int) bool { return m.before(m.rows[j], m.rows[i]) }
+func (m *orderTopN) Push(x interface{}) { m.rows = append(m.rows, x.(*topRow)) }
+func (m *orderTopN) Pop() interface{} {
+	row := m.rows[len(m.rows)-1]
+	m.rows = m.rows[:len(m.rows)-1]
+	return row
+}
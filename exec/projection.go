@@ -3,22 +3,31 @@ package exec
 import (
 	"database/sql/driver"
 	"math"
+	"strings"
 
 	u "github.com/araddon/gou"
 
 	"github.com/araddon/qlbridge/datasource"
 	"github.com/araddon/qlbridge/expr"
 	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
 	"github.com/araddon/qlbridge/schema"
 	"github.com/araddon/qlbridge/value"
 	"github.com/araddon/qlbridge/vm"
 )
 
+var (
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*Projection)(nil)
+	_ BatchTask  = (*Projection)(nil)
+)
+
 // Projection Execution Task
 type Projection struct {
 	*TaskBase
-	closed bool
-	p      *plan.Projection
+	closed  bool
+	batches bool // projects batches, not limit only
+	p       *plan.Projection
 }
 
 // In Process projections are used when mapping multiple sources together
@@ -36,7 +45,8 @@ func NewProjection(ctx *plan.Context, p *plan.Projection) *Projection {
 //  even if they will not be used in Final projection
 func NewProjectionInProcess(ctx *plan.Context, p *plan.Projection) *Projection {
 	s := &Projection{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "projection"),
+		batches:  true,
 		p:        p,
 	}
 	s.Handler = s.projectionEvaluator(p.Final)
@@ -46,7 +56,8 @@ func NewProjectionInProcess(ctx *plan.Context, p *plan.Projection) *Projection {
 // Final Projections project final select columns for result-writing
 func NewProjectionFinal(ctx *plan.Context, p *plan.Projection) *Projection {
 	s := &Projection{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "projection"),
+		batches:  true,
 		p:        p,
 	}
 	s.Handler = s.projectionEvaluator(p.Final)
@@ -56,7 +67,7 @@ func NewProjectionFinal(ctx *plan.Context, p *plan.Projection) *Projection {
 // NewProjectionLimit Only provides counting/limit projection
 func NewProjectionLimit(ctx *plan.Context, p *plan.Projection) *Projection {
 	s := &Projection{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "projection"),
 		p:        p,
 	}
 	s.Handler = s.limitEvaluator()
@@ -109,21 +120,19 @@ func (m *Projection) CloseFinal() error {
 	return m.TaskBase.Close()
 }
 
+// Batched implements BatchTask, each row of a batch is projected into a
+// batch of projected rows.
+func (m *Projection) Batched(in bool) bool { return in && m.batches }
+
 // Create handler function for evaluation (ie, field selection from tuples)
 func (m *Projection) projectionEvaluator(isFinal bool) MessageHandler {
 
 	out := m.MessageOut()
-	columns := m.p.Stmt.Columns
-	colIndex := m.p.Stmt.ColIndexes()
 	limit := m.p.Stmt.Limit
 	if limit == 0 {
 		limit = math.MaxInt32
 	}
-	colCt := len(columns)
-	// If we have a projection, use that as col count
-	if m.p.Proj != nil {
-		colCt = len(m.p.Proj.Columns)
-	}
+	project := m.rowProjector(isFinal)
 
 	rowCt := 0
 	return func(ctx *plan.Context, msg schema.Message) bool {
@@ -135,8 +144,88 @@ func (m *Projection) projectionEvaluator(isFinal bool) MessageHandler {
 		default:
 		}
 
+		if batch, isBatch := msg.(*RowBatch); isBatch {
+			rows := NewRowBatch(batch.Len())
+			limited := false
+		batchRows:
+			for _, row := range batch.Rows {
+				for _, outMsg := range unnestRows(project(ctx, row)) {
+					if rowCt >= limit {
+						limited = true
+						break batchRows
+					}
+					rowCt++
+					rows.Rows = append(rows.Rows, outMsg)
+				}
+			}
+			if rows.Len() > 0 {
+				select {
+				case out <- rows:
+				case <-m.SigChan():
+					return false
+				}
+			}
+			if limited {
+				out <- nil // limit reached, shutdown downstream
+				m.Quit()
+				return false
+			}
+			return true
+		}
+
+		for _, outMsg := range unnestRows(project(ctx, msg)) {
+			if rowCt >= limit {
+				//u.Debugf("%p Projection reaching Limit!!! rowct:%v  limit:%v", m, rowCt, limit)
+				out <- nil // Sending nil message is a message to downstream to shutdown
+				m.Quit()   // should close rest of dag as well
+				return false
+			}
+			rowCt++
+
+			//u.Debugf("row:%d  completed projection for: %p %#v", rowCt, out, outMsg)
+			select {
+			case out <- outMsg:
+			case <-m.SigChan():
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// projector the function projecting a row of the input into the columns
+// of the projection
+func (m *Projection) projector(isFinal bool) func(ctx *plan.Context, msg schema.Message) schema.Message {
+	project := m.rowProjector(isFinal)
+	return func(ctx *plan.Context, msg schema.Message) schema.Message {
+		outMsg, _ := project(ctx, msg)
+		return outMsg
+	}
+}
+
+// rowProjector the projector, also returning the positions of unnest()
+// columns in the projected row to expand, of the final projection
+func (m *Projection) rowProjector(isFinal bool) func(ctx *plan.Context, msg schema.Message) (schema.Message, []int) {
+
+	columns := m.p.Stmt.Columns
+	colIndex := m.p.Stmt.ColIndexes()
+	colCt := len(columns)
+	// If we have a projection, use that as col count
+	if m.p.Proj != nil {
+		colCt = len(m.p.Proj.Columns)
+	}
+	unnest := make([]bool, len(columns))
+	if m.p.Final {
+		for i, col := range columns {
+			unnest[i] = isUnnest(col)
+		}
+	}
+
+	return func(ctx *plan.Context, msg schema.Message) (schema.Message, []int) {
+
 		//u.Infof("got projection message: %T %#v", msg, msg.Body())
 		var outMsg schema.Message
+		var unnested []int
 		switch mt := msg.(type) {
 		case *datasource.SqlDriverMessageMap:
 			// use our custom write context for example purposes
@@ -147,7 +236,7 @@ func (m *Projection) projectionEvaluator(isFinal bool) MessageHandler {
 			}, mt.Ts())
 			//u.Debugf("about to project: %#v", mt)
 			colIdx := -1
-			for _, col := range columns {
+			for ci, col := range columns {
 				colIdx += 1
 				//u.Debugf("%d  colidx:%v sidx: %v pidx:%v key:%q Expr:%v", colIdx, col.Index, col.SourceIndex, col.ParentIndex, col.Key(), col.Expr)
 
@@ -199,6 +288,11 @@ func (m *Projection) projectionEvaluator(isFinal bool) MessageHandler {
 
 				} else if col.Expr == nil {
 					u.Warnf("wat?   nil col expr? %#v", col)
+				} else if col.Over != nil {
+					// window functions were evaluated by window task
+					if v, ok := mt.Get(col.As); ok && v != nil {
+						row[colIdx] = v.Value()
+					}
 				} else {
 					v, ok := vm.Eval(rdr, col.Expr)
 					if !ok {
@@ -218,11 +312,14 @@ func (m *Projection) projectionEvaluator(isFinal bool) MessageHandler {
 						//writeContext.Put(col, mt, v)
 						row[colIdx] = v.Value()
 					}
+					if unnest[ci] {
+						unnested = append(unnested, colIdx)
+					}
 				}
 			}
 			//u.Infof("row: %#v", row)
 			//u.Infof("row cols: %v", colIndex)
-			outMsg = datasource.NewSqlDriverMessageMap(0, row, colIndex)
+			outMsg = datasource.NewSqlDriverMessageMap(msg.Id(), row, colIndex)
 
 		case expr.ContextReader:
 			//u.Warnf("nice, got context reader? %T", mt)
@@ -265,6 +362,10 @@ func (m *Projection) projectionEvaluator(isFinal bool) MessageHandler {
 					}
 				} else if col.Expr == nil {
 					u.Warnf("wat?   nil col expr? %#v", col)
+				} else if col.Over != nil {
+					if v, ok := mt.Get(col.As); ok && v != nil {
+						row[i+colIdx] = v.Value()
+					}
 				} else {
 					v, ok := vm.Eval(mt, col.Expr)
 					if !ok {
@@ -280,32 +381,88 @@ func (m *Projection) projectionEvaluator(isFinal bool) MessageHandler {
 						//writeContext.Put(col, mt, v)
 						row[i+colIdx] = v.Value()
 					}
+					if unnest[i] {
+						unnested = append(unnested, i+colIdx)
+					}
 				}
 			}
 			//u.Infof("row: %#v cols:%#v", row, colIndex)
 			//u.Infof("row cols: %v", colIndex)
-			outMsg = datasource.NewSqlDriverMessageMap(0, row, colIndex)
+			outMsg = datasource.NewSqlDriverMessageMap(msg.Id(), row, colIndex)
 
 		default:
 			u.Errorf("could not project msg:  %T", msg)
 		}
+		return outMsg, unnested
+	}
+}
 
-		if rowCt >= limit {
-			//u.Debugf("%p Projection reaching Limit!!! rowct:%v  limit:%v", m, rowCt, limit)
-			out <- nil // Sending nil message is a message to downstream to shutdown
-			m.Quit()   // should close rest of dag as well
-			return false
+// isUnnest is a column unnest(arr), of a row per element of arr
+func isUnnest(col *rel.Column) bool {
+	fn, ok := col.Expr.(*expr.FuncNode)
+	return ok && strings.ToLower(fn.Name) == "unnest"
+}
+
+// unnestRows the rows of a projected row of unnest() columns at positions
+// unnested, a row per element of the longest of their arrays (shorter ones
+// NULL past their end) and the other columns repeated.  A row of empty or
+// NULL arrays has none, as postgres.
+func unnestRows(msg schema.Message, unnested []int) []schema.Message {
+	mt, ok := msg.(*datasource.SqlDriverMessageMap)
+	if !ok || len(unnested) == 0 {
+		return []schema.Message{msg}
+	}
+	arrays := make([][]driver.Value, len(unnested))
+	n := 0
+	for i, pos := range unnested {
+		arrays[i] = unnestValues(mt.Vals[pos])
+		if len(arrays[i]) > n {
+			n = len(arrays[i])
 		}
-		rowCt++
+	}
+	msgs := make([]schema.Message, n)
+	for ri := range msgs {
+		row := make([]driver.Value, len(mt.Vals))
+		copy(row, mt.Vals)
+		for i, pos := range unnested {
+			row[pos] = nil
+			if ri < len(arrays[i]) {
+				row[pos] = arrays[i][ri]
+			}
+		}
+		msgs[ri] = datasource.NewSqlDriverMessageMap(mt.Id(), row, mt.ColIndex)
+	}
+	return msgs
+}
 
-		//u.Debugf("row:%d  completed projection for: %p %#v", rowCt, out, outMsg)
-		select {
-		case out <- outMsg:
-			return true
-		case <-m.SigChan():
-			return false
+// unnestValues the elements of a projected array value, a value not an
+// array is its only element
+func unnestValues(v driver.Value) []driver.Value {
+	switch vt := v.(type) {
+	case nil:
+		return nil
+	case []value.Value:
+		vals := make([]driver.Value, len(vt))
+		for i, ev := range vt {
+			if ev != nil {
+				vals[i] = ev.Value()
+			}
+		}
+		return vals
+	case []string:
+		vals := make([]driver.Value, len(vt))
+		for i, s := range vt {
+			vals[i] = s
+		}
+		return vals
+	case []interface{}:
+		vals := make([]driver.Value, len(vt))
+		for i, ev := range vt {
+			vals[i] = ev
 		}
+		return vals
 	}
+	return []driver.Value{v}
 }
 
 // Limit only evaluator
@@ -0,0 +1,67 @@
+package exec_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/schema"
+)
+
+func TestExecQualifiedTables(t *testing.T) {
+
+	// two sources of a users table, the first added owns the unqualified
+	pg := membtree.NewSource()
+	pg.AddTable(membtree.NewStaticDataSource("users", 0, [][]driver.Value{
+		{int64(1), "pg-alice"},
+	}, []string{"user_id", "name"}))
+	es := membtree.NewSource()
+	es.AddTable(membtree.NewStaticDataSource("users", 0, [][]driver.Value{
+		{int64(1), "es-alice"},
+		{int64(2), "es-bob"},
+	}, []string{"user_id", "name"}))
+	s := datasource.RegisterSchemaSource("qualpg", "qualpg", pg)
+	ss := schema.NewSchemaSource("quales", "membtree")
+	ss.DS = es
+	ss.Alias = "es"
+	s.AddSourceSchema(ss)
+	s.RefreshSchema()
+
+	sqlDb, err := sql.Open("qlbridge", "qualpg")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer sqlDb.Close()
+
+	names := func(q string) []string {
+		rows, err := sqlDb.Query(q)
+		assert.Tf(t, err == nil, "error: %v %s", err, q)
+		defer rows.Close()
+		names := make([]string, 0)
+		for rows.Next() {
+			var name string
+			assert.T(t, rows.Scan(&name) == nil)
+			names = append(names, name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"pg-alice"}, names(`SELECT name FROM users`))
+	assert.Equal(t, []string{"es-alice", "es-bob"}, names(`SELECT name FROM es.users`))
+	assert.Equal(t, []string{"es-alice", "es-bob"}, names(`SELECT name FROM quales.users`))
+	assert.Equal(t, []string{"pg-alice"}, names(`SELECT name FROM qualpg.users`))
+	assert.Equal(t, []string{"es-bob"}, names(`SELECT u.name FROM es.users AS u WHERE u.user_id = 2`))
+
+	sources := s.TableSources("users")
+	assert.Equal(t, 2, len(sources))
+	assert.Equal(t, "qualpg", sources[0].Name)
+	assert.Equal(t, ss, s.SourceOf("es"))
+	assert.T(t, s.SourceOf("nope") == nil)
+
+	// without its first source, the table of the name is of the next
+	assert.T(t, s.RemoveSourceSchema("qualpg") == nil)
+	tbl, err := s.Table("users")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, ss, tbl.SchemaSource)
+}
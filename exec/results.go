@@ -3,6 +3,8 @@ package exec
 import (
 	"database/sql/driver"
 	"io"
+	"reflect"
+	"time"
 
 	u "github.com/araddon/gou"
 
@@ -10,6 +12,7 @@ import (
 	"github.com/araddon/qlbridge/expr"
 	"github.com/araddon/qlbridge/plan"
 	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
 )
 
 const (
@@ -20,13 +23,47 @@ var (
 	_ = u.EMPTY
 
 	// ensure our resultwriter implements database/sql/driver `driver.Rows`
-	_ driver.Rows = (*ResultWriter)(nil)
+	_ driver.Rows                           = (*ResultWriter)(nil)
+	_ driver.RowsColumnTypeScanType         = (*ResultWriter)(nil)
+	_ driver.RowsColumnTypeDatabaseTypeName = (*ResultWriter)(nil)
 
 	// Ensure that we implement the Task Runner interface
 	// required for usage as tasks in Executor
 	_ TaskRunner = (*ResultExecWriter)(nil)
 	_ TaskRunner = (*ResultWriter)(nil)
 	_ TaskRunner = (*ResultBuffer)(nil)
+	_ BatchTask  = (*ResultWriter)(nil)
+	_ BatchTask  = (*ResultBuffer)(nil)
+
+	// go types of the values of rows of columns of value types, see msgToRow
+	scanTypes = map[value.ValueType]reflect.Type{
+		value.IntType:       reflect.TypeOf(int64(0)),
+		value.NumberType:    reflect.TypeOf(float64(0)),
+		value.BoolType:      reflect.TypeOf(false),
+		value.StringType:    reflect.TypeOf(""),
+		value.TimeType:      reflect.TypeOf(time.Time{}),
+		value.ByteSliceType: reflect.TypeOf([]byte(nil)),
+	}
+	scanTypeUnknown = reflect.TypeOf((*interface{})(nil)).Elem()
+
+	// (mysql) database type names of columns of value types
+	databaseTypeNames = map[value.ValueType]string{
+		value.IntType:        "BIGINT",
+		value.NumberType:     "DOUBLE",
+		value.BoolType:       "BOOL",
+		value.StringType:     "VARCHAR",
+		value.TimeType:       "DATETIME",
+		value.ByteSliceType:  "BLOB",
+		value.StringsType:    "JSON",
+		value.MapValueType:   "JSON",
+		value.MapIntType:     "JSON",
+		value.MapStringType:  "JSON",
+		value.MapNumberType:  "JSON",
+		value.MapBoolType:    "JSON",
+		value.MapTimeType:    "JSON",
+		value.SliceValueType: "JSON",
+		value.JsonType:       "JSON",
+	}
 )
 
 type ResultExecWriter struct {
@@ -38,8 +75,10 @@ type ResultExecWriter struct {
 }
 type ResultWriter struct {
 	*TaskBase
-	closed bool
-	cols   []string
+	closed  bool
+	cols    []string
+	types   []value.ValueType // of cols, nil if not known
+	pending []schema.Message  // rows of a batch not yet read by Next
 }
 type ResultBuffer struct {
 	*TaskBase
@@ -49,7 +88,7 @@ type ResultBuffer struct {
 
 func NewResultExecWriter(ctx *plan.Context) *ResultExecWriter {
 	m := &ResultExecWriter{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "result"),
 	}
 	m.Handler = func(ctx *plan.Context, msg schema.Message) bool {
 		switch mt := msg.(type) {
@@ -75,7 +114,7 @@ func NewResultExecWriter(ctx *plan.Context) *ResultExecWriter {
 
 func NewResultWriter(ctx *plan.Context) *ResultWriter {
 	m := &ResultWriter{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "result"),
 	}
 	m.Handler = resultWrite(m)
 	return m
@@ -92,9 +131,13 @@ func NewResultRows(ctx *plan.Context, cols []string) *ResultWriter {
 
 func NewResultBuffer(ctx *plan.Context, writeTo *[]schema.Message) *ResultBuffer {
 	m := &ResultBuffer{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "result"),
 	}
 	m.Handler = func(ctx *plan.Context, msg schema.Message) bool {
+		if batch, isBatch := msg.(*RowBatch); isBatch {
+			*writeTo = append(*writeTo, batch.Rows...)
+			return true
+		}
 		*writeTo = append(*writeTo, msg)
 		//u.Infof("write to msgs: %v", len(*writeTo))
 		return true
@@ -119,8 +162,9 @@ func (m *ResultExecWriter) Close() error {
 }
 func (m *ResultWriter) Copy() *ResultWriter { return NewResultWriter(m.Ctx) }
 func (m *ResultWriter) Close() error {
-	u.Debugf("%p ResultWriter.Close()???? already closed?%v", m, m.closed)
+	// closed by both the rows and the job of the sql driver
 	m.Lock()
+	u.Debugf("%p ResultWriter.Close()???? already closed?%v", m, m.closed)
 	if m.closed {
 		m.Unlock()
 		return nil
@@ -142,16 +186,33 @@ func (m *ResultBuffer) Close() error {
 	return m.TaskBase.Close()
 }
 
+// Batched implements BatchTask, rows of batches are read one at a time by Next
+func (m *ResultWriter) Batched(in bool) bool { return false }
+
+// Batched implements BatchTask, rows of batches are buffered
+func (m *ResultBuffer) Batched(in bool) bool { return false }
+
 // Note, this is implementation of the sql/driver Rows() Next() interface
 func (m *ResultWriter) Next(dest []driver.Value) error {
 	//u.Debugf("resultwriter.Next()")
+	if len(m.pending) > 0 {
+		msg := m.pending[0]
+		m.pending = m.pending[1:]
+		return msgToRow(msg, m.cols, dest)
+	}
 	select {
 	case <-m.SigChan():
+		if err := m.cancelled(); err != nil {
+			return err
+		}
 		return ErrShuttingDown
 	case err := <-m.ErrChan():
 		return err
 	case msg, ok := <-m.MessageIn():
 		if !ok {
+			if err := m.cancelled(); err != nil {
+				return err
+			}
 			return io.EOF
 		}
 		if msg == nil {
@@ -159,11 +220,25 @@ func (m *ResultWriter) Next(dest []driver.Value) error {
 			return io.EOF
 			//return fmt.Errorf("Nil message error?")
 		}
+		if batch, isBatch := msg.(*RowBatch); isBatch {
+			// batches are never empty
+			m.pending = batch.Rows
+			return m.Next(dest)
+		}
 		//u.Infof("got msg: T:%T   v:%#v", msg, msg)
 		return msgToRow(msg, m.cols, dest)
 	}
 }
 
+// cancelled the error of the Context of the query if it was cancelled, the
+// rows end early as the job quits
+func (m *ResultWriter) cancelled() error {
+	if m.Ctx != nil && m.Ctx.Context != nil {
+		return m.Ctx.Context.Err()
+	}
+	return nil
+}
+
 // For ResultWriter, since we are are not paging through messages
 //  using this mesage channel, instead using Next() as defined by sql/driver
 //  we don't read the input channel, just watch stop channels
@@ -189,6 +264,28 @@ func (m *ResultWriter) Columns() []string {
 	return m.cols
 }
 
+func (m *ResultWriter) columnType(index int) value.ValueType {
+	if index < len(m.types) {
+		return m.types[index]
+	}
+	return value.UnknownType
+}
+
+// ColumnTypeScanType implements driver.RowsColumnTypeScanType, the go type
+// of the values of the column, interface{} if not known
+func (m *ResultWriter) ColumnTypeScanType(index int) reflect.Type {
+	if st, ok := scanTypes[m.columnType(index)]; ok {
+		return st
+	}
+	return scanTypeUnknown
+}
+
+// ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName,
+// empty if the type of the column is not known
+func (m *ResultWriter) ColumnTypeDatabaseTypeName(index int) string {
+	return databaseTypeNames[m.columnType(index)]
+}
+
 func resultWrite(m *ResultWriter) MessageHandler {
 	out := m.MessageOut()
 	return func(ctx *plan.Context, msg schema.Message) bool {
@@ -241,13 +338,18 @@ func msgToRow(msg schema.Message, cols []string, dest []driver.Value) error {
 	case *datasource.SqlDriverMessageMap:
 		for i, key := range cols {
 			//u.Debugf("key=%v mt = nil? %v", key, mt)
+			// dest is re-used across rows, so a NULL is set too, not left of
+			// the last row.  empty strings are Nil() but are not NULL.
 			if val, ok := mt.Get(key); ok && val != nil && !val.Nil() {
 				dest[i] = val.Value()
 				//u.Infof("key=%v   val=%v", key, val)
-			} else if val == nil {
-				u.Errorf("could not evaluate? %v  %#v", key, mt)
+			} else if sv, isStr := val.(value.StringValue); isStr {
+				dest[i] = sv.Val()
 			} else {
-				u.Warnf("missing value? %v %T %v", key, val.Value(), val.Value())
+				if val == nil {
+					u.Errorf("could not evaluate? %v  %#v", key, mt)
+				}
+				dest[i] = nil
 			}
 		}
 		//u.Debugf("got msg in row result writer: %#v", dest)
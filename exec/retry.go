@@ -0,0 +1,136 @@
+package exec
+
+import (
+	"fmt"
+	"sync"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	_ = u.EMPTY
+
+	_ schema.ConnScanner = (*retryScanner)(nil)
+	_ schema.IteratorErr = (*retryScanner)(nil)
+)
+
+type openFunc func() (schema.Conn, error)
+
+// scanErr the error that stopped a scan, nil if it read all rows
+func scanErr(it schema.Iterator) error {
+	if ie, ok := it.(schema.IteratorErr); ok {
+		return ie.Err()
+	}
+	return nil
+}
+
+// retryScanner a scanner that re-opens its source when a scan fails with a
+// transient error, and skips the rows it already read.  Assumes each scan
+// of the source returns its rows in the same order (files, btrees, sorted
+// queries).
+//
+//   Next() -> row 1 .. row n -> nil, Err() transient
+//          -> wait -> Close(), Open() -> skip n rows -> row n+1 ...
+type retryScanner struct {
+	ctx      *plan.Context
+	sigCh    SigChan
+	name     string
+	open     openFunc
+	read     int // rows returned
+	skip     int // rows of a re-opened scan left to skip
+	attempts int // failed attempts since the last row returned
+	err      error
+
+	mu     sync.Mutex
+	conn   schema.ConnScanner
+	closed bool
+}
+
+func newRetryScanner(ctx *plan.Context, sigCh SigChan, name string, conn schema.ConnScanner, open openFunc) *retryScanner {
+	return &retryScanner{ctx: ctx, sigCh: sigCh, name: name, conn: conn, open: open}
+}
+
+func (m *retryScanner) scanner() schema.ConnScanner {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.conn
+}
+
+func (m *retryScanner) Next() schema.Message {
+	if m.err != nil {
+		return nil
+	}
+	for {
+		conn := m.scanner()
+		msg := conn.Next()
+		if msg != nil {
+			if m.skip > 0 {
+				m.skip--
+				continue
+			}
+			m.read++
+			m.attempts = 0
+			return msg
+		}
+		err := scanErr(conn)
+		if err == nil {
+			if m.skip > 0 {
+				m.err = fmt.Errorf("%s returned %d rows fewer on retry than were read before", m.name, m.skip)
+			}
+			return nil
+		}
+		m.attempts++
+		policy := m.ctx.RetryPolicy()
+		if !policy.Retry(m.attempts, err) || !policy.Wait(m.ctx, m.sigCh, m.attempts) {
+			m.err = err
+			return nil
+		}
+		u.Warnf("retry %d scan of %s after %d rows, error: %v", m.attempts, m.name, m.read, err)
+		if err := m.reopen(); err != nil {
+			m.err = err
+			return nil
+		}
+	}
+}
+
+// reopen close the failed scan and open a new one
+func (m *retryScanner) reopen() error {
+	m.scanner().Close()
+	conn, err := plan.OpenRetry(m.ctx, m.sigCh, m.name, m.open)
+	if err != nil {
+		return err
+	}
+	scanner, ok := conn.(schema.ConnScanner)
+	if !ok {
+		conn.Close()
+		return fmt.Errorf("%T Must Implement Scanner for %s", conn, m.name)
+	}
+	if sourceContext, needsContext := conn.(RequiresContext); needsContext {
+		sourceContext.SetContext(m.ctx)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		conn.Close()
+		return fmt.Errorf("%s closed", m.name)
+	}
+	m.conn = scanner
+	m.skip = m.read
+	return nil
+}
+
+// Err the error that stopped the scan once retries ran out
+func (m *retryScanner) Err() error { return m.err }
+
+func (m *retryScanner) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	return m.conn.Close()
+}
@@ -0,0 +1,132 @@
+package exec_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// flakySource a table of 20 rows whose first openFails opens fail, and
+// whose scans fail part way with err until scanFails scans have failed
+type flakySource struct {
+	*membtree.StaticDataSource
+	err       error
+	openFails int
+	scanFails int
+	failAt    int
+	opened    int
+	closed    int
+}
+
+type flakyConn struct {
+	*membtree.StaticDataSource
+	src  *flakySource
+	fail bool
+	ct   int
+	err  error
+}
+
+func (m *flakySource) Open(name string) (schema.Conn, error) {
+	if m.openFails > 0 {
+		m.openFails--
+		return nil, m.err
+	}
+	m.opened++
+	fail := m.scanFails > 0
+	if fail {
+		m.scanFails--
+	}
+	return &flakyConn{StaticDataSource: m.StaticDataSource, src: m, fail: fail}, nil
+}
+
+func (m *flakyConn) Next() schema.Message {
+	if m.fail && m.ct == m.src.failAt {
+		m.err = m.src.err
+		return nil
+	}
+	if m.ct == 20 {
+		return nil
+	}
+	m.ct++
+	vals := []driver.Value{int64(m.ct)}
+	return datasource.NewSqlDriverMessageMap(uint64(m.ct), vals, map[string]int{"id": 0})
+}
+
+func (m *flakyConn) Err() error { return m.err }
+
+func (m *flakyConn) Close() error {
+	m.src.closed++
+	return nil
+}
+
+func TestRetry(t *testing.T) {
+	transient := plan.Transient(fmt.Errorf("connection reset"))
+	fatal := fmt.Errorf("bad credentials")
+	policy := &plan.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+
+	tests := []struct {
+		policy    *plan.RetryPolicy
+		err       error
+		openFails int
+		scanFails int
+		failAt    int
+		rows      int  // rows read
+		fails     bool // query fails with err
+	}{
+		{policy, transient, 2, 0, 0, 20, false},
+		{policy, transient, 0, 2, 5, 20, false},
+		{policy, transient, 1, 1, 0, 20, false},
+		{policy, transient, 0, 3, 5, 5, true},
+		{policy, transient, 3, 0, 0, 0, true},
+		{policy, fatal, 0, 1, 5, 5, true},
+		{nil, transient, 0, 1, 5, 5, true},
+		{&plan.RetryPolicy{MaxAttempts: 2, Retryable: func(err error) bool { return err == fatal }},
+			fatal, 0, 1, 12, 20, false},
+	}
+	for i, tt := range tests {
+		name := fmt.Sprintf("flaky%d", i)
+		src := &flakySource{
+			StaticDataSource: membtree.NewStaticDataSource(name, 0, nil, []string{"id"}),
+			err:              tt.err,
+			openFails:        tt.openFails,
+			scanFails:        tt.scanFails,
+			failAt:           tt.failAt,
+		}
+		sql := fmt.Sprintf("SELECT id FROM %s", name)
+		ctx := plan.NewContext(sql)
+		ctx.Schema = datasource.RegisterSchemaSource(name, name, src)
+		ctx.Retry = tt.policy
+
+		job, err := exec.BuildSqlJob(ctx)
+		if err != nil {
+			assert.Tf(t, tt.fails && tt.rows == 0, "no error %v for %d", err, i)
+			assert.Equalf(t, tt.err, err, "open error of %d", i)
+			continue
+		}
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+		err = job.Run()
+		job.Close()
+		if tt.fails {
+			assert.Equalf(t, tt.err, err, "error of %d", i)
+		} else {
+			assert.Tf(t, err == nil, "no error %v for %d", err, i)
+		}
+		assert.Equalf(t, tt.rows, len(msgs), "rows of %d", i)
+		for j, msg := range msgs {
+			id, _ := msg.Body().(*datasource.SqlDriverMessageMap).Get("id")
+			assert.Equalf(t, int64(j+1), id.Value(), "row %d of %d", j, i)
+		}
+		assert.Equalf(t, src.opened, src.closed, "connections closed of %d", i)
+	}
+}
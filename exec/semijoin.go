@@ -0,0 +1,197 @@
+package exec
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+	"github.com/araddon/qlbridge/vm"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*SemiJoin)(nil)
+)
+
+// SemiJoin filters its input to rows with a match in the results of a
+// sub-query (or for an anti-join rows without a match).  The sub-query is
+// run to completion first, building a set of its keys, then each input row
+// is emitted at most once, unlike a join which would need de-duplication.
+//
+//   sub-query  ->  keys
+//                    |
+//   input      ->  semijoin  -->  output
+//
+// NULL follows sql semantics:  a NULL left hand side never matches, and
+// NOT IN is never true if the sub-query returned any NULL.  For
+// un-correlated EXISTS the sub-query has no key, and all input rows pass
+// if it returned any rows.  The sub-query of an = is a scalar, an error if
+// it returns more than one row.
+type SemiJoin struct {
+	*TaskBase
+	p       *plan.SemiJoin
+	sub     TaskRunner
+	cols    map[string]*rel.Column
+	keys    map[string]struct{}
+	rowCt   int
+	hasNull bool
+	closed  bool
+}
+
+// NewSemiJoin create a semi-join of input against sub-query task
+func NewSemiJoin(ctx *plan.Context, p *plan.SemiJoin, sub TaskRunner) *SemiJoin {
+	return &SemiJoin{
+		TaskBase: NewTaskBaseNamed(ctx, "semijoin"),
+		p:        p,
+		sub:      sub,
+		cols:     p.Stmt.UnAliasedColumns(),
+		keys:     make(map[string]struct{}),
+	}
+}
+
+func (m *SemiJoin) Children() []Task { return []Task{m.sub} }
+
+func (m *SemiJoin) Setup(depth int) error {
+	m.setup = true
+	m.sub.MessageInSet(make(MessageChan, BufferSize(m.Ctx, "semijoin")))
+	return m.sub.Setup(depth + 1)
+}
+
+func (m *SemiJoin) Close() error {
+	m.Lock()
+	if m.closed {
+		m.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.Unlock()
+
+	if err := m.sub.Close(); err != nil {
+		return err
+	}
+	return m.TaskBase.Close()
+}
+
+func (m *SemiJoin) Run() error {
+	defer m.Ctx.Recover()
+	defer close(m.msgOutCh)
+
+	if err := m.loadKeys(); err != nil {
+		return err
+	}
+
+	outCh := m.MessageOut()
+	inCh := m.MessageIn()
+	for {
+		select {
+		case <-m.SigChan():
+			return nil
+		case msg, ok := <-inCh:
+			if !ok {
+				return nil
+			}
+			if !m.match(msg) {
+				continue
+			}
+			select {
+			case outCh <- msg:
+			case <-m.SigChan():
+				return nil
+			}
+		}
+	}
+}
+
+// loadKeys run the sub-query collecting the set of its keys
+func (m *SemiJoin) loadKeys() error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- m.sub.Run()
+	}()
+	close(m.sub.MessageIn())
+
+	var err error
+	for msg := range m.sub.MessageOut() {
+		m.rowCt++
+		if m.p.Single && m.rowCt > 1 && err == nil {
+			err = fmt.Errorf("sub-query of = returned more than one row: %s", m.p.Sub.Stmt)
+		}
+		if m.p.Rhs == nil || err != nil {
+			continue
+		}
+		vals := messageValues(msg)
+		if len(vals) == 0 || vals[0] == nil {
+			m.hasNull = true
+			continue
+		}
+		v := value.NewValue(vals[0])
+		if v == nil || v.Nil() {
+			m.hasNull = true
+			continue
+		}
+		m.keys[v.ToString()] = struct{}{}
+	}
+	if subErr := <-errCh; subErr != nil {
+		return subErr
+	}
+	return err
+}
+
+// match should this input row be emitted
+func (m *SemiJoin) match(msg schema.Message) bool {
+	if m.p.Lhs == nil {
+		// un-correlated EXISTS
+		return (m.rowCt > 0) != m.p.Anti
+	}
+	found := false
+	reader, ok := messageReader(msg, m.cols)
+	if !ok {
+		u.Errorf("could not convert to message reader: %T", msg)
+		return false
+	}
+	v, ok := vm.Eval(reader, m.p.Lhs)
+	if !ok || v == nil || v.Nil() {
+		if m.p.In {
+			// NULL [NOT] IN (...) is NULL, never true
+			return false
+		}
+	} else {
+		if m.p.In && m.p.Anti && m.hasNull {
+			// x NOT IN (..., NULL) is NULL when x is not found, never true
+			return false
+		}
+		_, found = m.keys[v.ToString()]
+	}
+	return found != m.p.Anti
+}
+
+func messageReader(msg schema.Message, cols map[string]*rel.Column) (expr.ContextReader, bool) {
+	switch mt := msg.(type) {
+	case *datasource.SqlDriverMessage:
+		return datasource.NewValueContextWrapper(mt, cols), true
+	case expr.ContextReader:
+		return mt, true
+	}
+	return nil, false
+}
+
+func messageValues(msg schema.Message) []driver.Value {
+	switch mt := msg.(type) {
+	case *datasource.SqlDriverMessage:
+		return mt.Vals
+	case *datasource.SqlDriverMessageMap:
+		return mt.Values()
+	default:
+		u.Warnf("un-handled sub-query message type %T", msg)
+	}
+	return nil
+}
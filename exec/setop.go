@@ -0,0 +1,281 @@
+package exec
+
+import (
+	"database/sql/driver"
+	"sync/atomic"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*SetOperation)(nil)
+)
+
+// SetOperation combines the rows of two inputs, each a select or another set
+// operation, by UNION, INTERSECT or EXCEPT.
+//
+//   UNION ALL   left rows, then right rows
+//   UNION       rows with a key not seen before, see dedupSet for spilling
+//   INTERSECT   left rows with a key in right
+//   EXCEPT      left rows with a key not in right
+//
+//   left   ->
+//               setop  -->  output
+//   right  ->
+//
+// For INTERSECT and EXCEPT the right input is read first into a count of
+// rows per key held in memory.  With ALL a left row is emitted as many
+// times as it is in left, at most (INTERSECT) or less (EXCEPT) the times it
+// is in right.  The counts are held against the memory limit of the query,
+// a plan.MemoryQuotaError if over it.  Rows are keyed on the canonical encoding of their values
+// after conversion to the unified column types, so int 1 equals float 1.0.
+type SetOperation struct {
+	*TaskBase
+	p        *plan.SetOperation
+	left     TaskRunner
+	right    TaskRunner
+	colIndex map[string]int
+	dedup    *dedupSet
+	closed   bool
+}
+
+// NewSetOperation create a set operation of left and right input tasks
+func NewSetOperation(ctx *plan.Context, p *plan.SetOperation, left, right TaskRunner) *SetOperation {
+	colIndex := make(map[string]int, len(p.Columns))
+	for i, name := range p.Columns {
+		colIndex[name] = i
+	}
+	return &SetOperation{
+		TaskBase: NewTaskBaseNamed(ctx, "setop"),
+		p:        p,
+		left:     left,
+		right:    right,
+		colIndex: colIndex,
+	}
+}
+
+func (m *SetOperation) Children() []Task { return []Task{m.left, m.right} }
+
+func (m *SetOperation) Setup(depth int) error {
+	m.setup = true
+	for _, in := range []TaskRunner{m.left, m.right} {
+		in.MessageInSet(make(MessageChan, BufferSize(m.Ctx, "setop")))
+		if err := in.Setup(depth + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *SetOperation) Close() error {
+	m.Lock()
+	if m.closed {
+		m.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.Unlock()
+
+	if err := m.left.Close(); err != nil {
+		return err
+	}
+	if err := m.right.Close(); err != nil {
+		return err
+	}
+	return m.TaskBase.Close()
+}
+
+// Spills number of times UNION rows were spilled to disk, 0 if in memory
+func (m *SetOperation) Spills() int {
+	m.Lock()
+	defer m.Unlock()
+	if m.dedup == nil {
+		return 0
+	}
+	return int(atomic.LoadInt32(&m.dedup.spilled))
+}
+
+func (m *SetOperation) Run() error {
+	defer m.Ctx.Recover()
+	defer close(m.msgOutCh)
+
+	outCh := m.MessageOut()
+	emit := func(sdm *datasource.SqlDriverMessageMap) bool {
+		select {
+		case outCh <- sdm:
+			return true
+		case <-m.SigChan():
+			return false
+		}
+	}
+
+	var err error
+	switch m.p.Op {
+	case lex.TokenUnion:
+		err = m.union(emit)
+	case lex.TokenIntersect, lex.TokenExcept:
+		err = m.match(emit)
+	default:
+		u.Errorf("unsupported set operation %s", m.p.Op)
+		return nil
+	}
+	if err != nil {
+		u.Errorf("could not run %s: %v", m.p.Op, err)
+		return err
+	}
+	return nil
+}
+
+func (m *SetOperation) union(emit func(*datasource.SqlDriverMessageMap) bool) error {
+	if m.p.All {
+		for _, in := range []TaskRunner{m.left, m.right} {
+			if done, err := m.read(in, emit); !done || err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	dedup := newDedupSet(AggMemory(m.Ctx), tempDir(m.Ctx), newMemReservation(m.TaskBase, "union"))
+	defer dedup.Close()
+	m.Lock()
+	m.dedup = dedup
+	m.Unlock()
+
+	var err error
+	add := func(sdm *datasource.SqlDriverMessageMap) bool {
+		isNew, addErr := dedup.Add(canonicalKey(sdm.Vals), sdm)
+		if addErr != nil {
+			err = addErr
+			return false
+		}
+		return !isNew || emit(sdm)
+	}
+	for _, in := range []TaskRunner{m.left, m.right} {
+		done, readErr := m.read(in, add)
+		if err != nil {
+			return err
+		}
+		if !done || readErr != nil {
+			return readErr
+		}
+	}
+	return dedup.Emit(emit)
+}
+
+func (m *SetOperation) match(emit func(*datasource.SqlDriverMessageMap) bool) error {
+	res := newMemReservation(m.TaskBase, "setop")
+	defer res.Release()
+
+	counts := make(map[string]int)
+	var quotaErr error
+	done, err := m.read(m.right, func(sdm *datasource.SqlDriverMessageMap) bool {
+		key := canonicalKey(sdm.Vals)
+		if _, ok := counts[key]; !ok {
+			if quotaErr = res.Grow(int64(56 + len(key))); quotaErr != nil {
+				return false
+			}
+		}
+		counts[key]++
+		return true
+	})
+	if quotaErr != nil {
+		return quotaErr
+	}
+	if !done || err != nil {
+		return err
+	}
+
+	_, err = m.read(m.left, func(sdm *datasource.SqlDriverMessageMap) bool {
+		key := canonicalKey(sdm.Vals)
+		ct, inRight := counts[key]
+		if m.p.Op == lex.TokenIntersect {
+			if ct == 0 {
+				return true
+			}
+			if m.p.All {
+				counts[key] = ct - 1
+			} else {
+				// emitted once
+				counts[key] = 0
+			}
+			return emit(sdm)
+		}
+		switch {
+		case m.p.All && ct > 0:
+			counts[key] = ct - 1
+			return true
+		case !m.p.All && inRight:
+			return true
+		case !m.p.All:
+			// later duplicates are not emitted
+			counts[key] = 0
+		}
+		return emit(sdm)
+	})
+	return err
+}
+
+// read the rows of input until it is done, or fn returns false.  Values are
+// converted to the unified column types and named by the set operation
+// columns.  Inputs are started when read, not both at once, so the two
+// selects of a table are not scanning it at the same time.  Returns false
+// if it quit before input was done.
+func (m *SetOperation) read(in TaskRunner, fn func(*datasource.SqlDriverMessageMap) bool) (bool, error) {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- in.Run()
+	}()
+	// it has no input of its own
+	close(in.MessageIn())
+
+	outCh := in.MessageOut()
+	for {
+		select {
+		case <-m.SigChan():
+			// quit, input is shut down by Close
+			return false, nil
+		case msg, ok := <-outCh:
+			if !ok {
+				return true, <-errCh
+			}
+			if msg == nil {
+				// limit of the input was reached
+				continue
+			}
+			inVals := messageValues(msg)
+			vals := make([]driver.Value, len(inVals))
+			for i, v := range inVals {
+				if i < len(m.p.Types) {
+					v = unifyValue(m.p.Types[i], v)
+				}
+				vals[i] = v
+			}
+			if !fn(datasource.NewSqlDriverMessageMap(msg.Id(), vals, m.colIndex)) {
+				return false, nil
+			}
+		}
+	}
+}
+
+// unifyValue convert v to the column type vt
+func unifyValue(vt value.ValueType, v driver.Value) driver.Value {
+	if v == nil {
+		return nil
+	}
+	switch vt {
+	case value.NumberType:
+		if f, ok := value.ValueToFloat64(value.NewValue(v)); ok {
+			return f
+		}
+	}
+	return v
+}
@@ -0,0 +1,158 @@
+package exec_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/mockcsv"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+)
+
+func TestSetOperation(t *testing.T) {
+	tests := []struct {
+		sql    string
+		cols   []string
+		sorted bool   // compare rows in order they are returned
+		ops    string // set operations of plan
+		want   string
+	}{
+		{`SELECT n, g FROM numbers WHERE n < 3 UNION ALL SELECT n, g FROM numbers WHERE n < 2`,
+			[]string{"n", "g"}, false, "union", "0:g00,0:g00,1:g01,1:g01,2:g02"},
+		{`SELECT g FROM numbers WHERE n < 3 UNION SELECT g FROM numbers WHERE n < 5`,
+			[]string{"g"}, false, "union", "g00,g01,g02,g03,g04"},
+		// named by the first select
+		{`SELECT g AS grp FROM numbers WHERE n < 3 UNION SELECT g FROM numbers WHERE n < 5 ORDER BY grp DESC LIMIT 2`,
+			[]string{"grp"}, true, "union", "g04,g03"},
+		{`SELECT g FROM numbers WHERE n < 300 INTERSECT SELECT g FROM numbers WHERE n > 97 AND n < 102`,
+			[]string{"g"}, false, "intersect", "g00,g01,g98,g99"},
+		{`SELECT g FROM numbers WHERE n < 300 INTERSECT ALL SELECT g FROM numbers WHERE n < 3 OR n = 101`,
+			[]string{"g"}, false, "intersect", "g00,g01,g01,g02"},
+		{`SELECT g FROM numbers WHERE n < 5 EXCEPT SELECT g FROM numbers WHERE n > 2 AND n < 100`,
+			[]string{"g"}, false, "except", "g00,g01,g02"},
+		{`SELECT g FROM numbers WHERE n < 3 OR n = 101 OR n = 102 EXCEPT ALL SELECT g FROM numbers WHERE n = 1`,
+			[]string{"g"}, false, "except", "g00,g01,g02,g02"},
+		// INTERSECT binds tighter:  a UNION (b INTERSECT c)
+		{`SELECT g FROM numbers WHERE n < 2 UNION SELECT g FROM numbers WHERE n < 5 INTERSECT SELECT g FROM numbers WHERE n = 4`,
+			[]string{"g"}, false, "union,intersect", "g00,g01,g04"},
+		{`SELECT g FROM numbers WHERE n < 2 EXCEPT SELECT g FROM numbers WHERE n = 1 UNION ALL SELECT g FROM numbers WHERE n = 1`,
+			[]string{"g"}, false, "union,except", "g00,g01"},
+	}
+	for _, tt := range tests {
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("counting")
+
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+		ops := make([]string, 0)
+		for _, step := range plan.ExplainTask(ctx.Projection.P) {
+			switch step.Task {
+			case "union", "intersect", "except":
+				ops = append(ops, step.Task)
+			}
+		}
+		assert.Equalf(t, tt.ops, strings.Join(ops, ","), "plan of %s", tt.sql)
+
+		rows := exec.NewResultRows(ctx, tt.cols)
+		job.RootTask.Add(rows)
+		assert.T(t, job.Setup() == nil)
+		go job.Run()
+
+		got := make([]string, 0)
+		for {
+			dest := make([]driver.Value, len(tt.cols))
+			if rows.Next(dest) != nil {
+				break
+			}
+			vals := make([]string, len(dest))
+			for i, v := range dest {
+				vals[i] = fmt.Sprintf("%v", v)
+			}
+			got = append(got, strings.Join(vals, ":"))
+		}
+		job.Close()
+		if !tt.sorted {
+			sort.Strings(got)
+		}
+		assert.Equalf(t, tt.want, strings.Join(got, ","), "rows for %s", tt.sql)
+	}
+}
+
+func TestSetOperationTypes(t *testing.T) {
+	mockcsv.LoadTable(mockcsv.MockSchemaName, "setop_ints", "id,v\n1,1\n2,2\n3,3")
+	mockcsv.LoadTable(mockcsv.MockSchemaName, "setop_floats", "id,v\n1,1.5\n2,2.0")
+
+	// ints are widened to floats, and 2 equals 2.0
+	sql := `SELECT v FROM setop_ints UNION SELECT v FROM setop_floats`
+	ctx := plan.NewContext(sql)
+	ctx.Schema, _ = datasource.DataSourcesRegistry().Schema(mockcsv.MockSchemaName)
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v for %s", err, sql)
+	rows := exec.NewResultRows(ctx, []string{"v"})
+	job.RootTask.Add(rows)
+	assert.T(t, job.Setup() == nil)
+	go job.Run()
+
+	got := make([]string, 0)
+	dest := make([]driver.Value, 1)
+	for rows.Next(dest) == nil {
+		got = append(got, fmt.Sprintf("%T(%v)", dest[0], dest[0]))
+	}
+	job.Close()
+	sort.Strings(got)
+	assert.Equal(t, "float64(1),float64(1.5),float64(2),float64(3)", strings.Join(got, ","))
+
+	sql = `SELECT id, v FROM setop_ints UNION SELECT v FROM setop_floats`
+	ctx = plan.NewContext(sql)
+	ctx.Schema, _ = datasource.DataSourcesRegistry().Schema(mockcsv.MockSchemaName)
+	_, err = exec.BuildSqlJob(ctx)
+	assert.Tf(t, err != nil, "column count mismatch must error %s", sql)
+}
+
+func TestSetOperationSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qlbridge-setop")
+	assert.Tf(t, err == nil, "no error %v", err)
+	defer os.RemoveAll(dir)
+
+	sql := `SELECT g FROM numbers UNION SELECT g FROM numbers WHERE n < 10`
+	ctx := plan.NewContext(sql)
+	ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("counting")
+	ctx.AggMemory = 512
+	ctx.TempDir = dir
+
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v for %s", err, sql)
+	rows := exec.NewResultRows(ctx, []string{"g"})
+	job.RootTask.Add(rows)
+	assert.T(t, job.Setup() == nil)
+	go job.Run()
+
+	seen := make(map[string]int)
+	dest := make([]driver.Value, 1)
+	for rows.Next(dest) == nil {
+		seen[dest[0].(string)]++
+	}
+	assert.Equal(t, 100, len(seen))
+	for g, ct := range seen {
+		assert.Equalf(t, 1, ct, "%s emitted once", g)
+	}
+
+	so, _ := findTask(job.RootTask, func(t exec.Task) bool {
+		_, ok := t.(*exec.SetOperation)
+		return ok
+	}).(*exec.SetOperation)
+	assert.T(t, so != nil)
+	assert.Tf(t, so.Spills() > 0, "expected union to spill")
+	job.Close()
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Equalf(t, 0, len(files), "spilled partitions should be removed %v", files)
+}
@@ -2,10 +2,13 @@ package exec
 
 import (
 	"fmt"
+	"time"
 
 	u "github.com/araddon/gou"
 
+	"github.com/araddon/qlbridge/datasource"
 	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
 	"github.com/araddon/qlbridge/schema"
 )
 
@@ -15,6 +18,7 @@ var (
 	// Ensure that we implement the Task Runner interface
 	// to ensure this can run in exec engine
 	_ TaskRunner = (*Source)(nil)
+	_ BatchTask  = (*Source)(nil)
 )
 
 // Source data sources requires context
@@ -22,6 +26,12 @@ type RequiresContext interface {
 	SetContext(ctx *plan.Context)
 }
 
+// RequiresNative data sources that filter their scan by the where pushed
+// down to them, translated to their native form (see plan.Source Native)
+type RequiresNative interface {
+	SetNative(native interface{})
+}
+
 // Scan a data source for rows, feed into runner.  The source scanner being
 //   a source is iter.Next() messages instead of sending them on input channel
 //
@@ -39,6 +49,9 @@ type Source struct {
 	Scanner    schema.ConnScanner
 	ExecSource ExecutorSource
 	JoinKey    KeyEvaluator
+	batchSize  int              // rows per batch sent, <= 1 for single rows
+	acker      schema.ConnAcker // acks messages as they are sent, nil if not
+	rows       int64            // scanned, for the metrics of the source
 	closed     bool
 }
 
@@ -58,12 +71,15 @@ func NewSource(ctx *plan.Context, p *plan.Source) (*Source, error) {
 	if sourceContext, needsContext := p.Conn.(RequiresContext); needsContext {
 		sourceContext.SetContext(ctx)
 	}
+	if sourceNative, needsNative := p.Conn.(RequiresNative); needsNative && p.Native != nil {
+		sourceNative.SetNative(p.Native)
+	}
 
 	if !hasScanner {
 		e, hasSourceExec := p.Conn.(ExecutorSource)
 		if hasSourceExec {
 			s := &Source{
-				TaskBase:   NewTaskBase(ctx),
+				TaskBase:   NewTaskBaseNamed(ctx, "source"),
 				ExecSource: e,
 				p:          p,
 			}
@@ -74,17 +90,44 @@ func NewSource(ctx *plan.Context, p *plan.Source) (*Source, error) {
 	}
 	//u.Debugf("NewSource: hasScanner? %T", scanner)
 	s := &Source{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "source"),
 		Scanner:  scanner,
 		p:        p,
 	}
+	if acker, isAcker := scanner.(schema.ConnAcker); isAcker {
+		if insert, isInsert := ctx.Stmt.(*rel.SqlInsert); isInsert && ackedOnWrite(insert.Select) {
+			// acked by the insert once the rows are written
+			ctx.AddAcker(acker)
+		} else {
+			// nothing writes the rows, or not the rows of the messages, acked
+			// once sent on
+			s.acker = acker
+		}
+	}
+	if ctx.RetryPolicy() != nil && p.DataSource != nil {
+		name := p.Stmt.SourceName()
+		s.Scanner = newRetryScanner(ctx, s.SigChan(), name, scanner, func() (schema.Conn, error) {
+			return p.DataSource.Open(name)
+		})
+	}
+	s.Scanner = newComputedScanner(p, s.Scanner)
 	return s, nil
 }
 
+// ackedOnWrite is the select of an insert a scan of one source whose rows
+// are written in the order of, and with the ids of, its messages.  Grouped,
+// joined, sorted or distinct rows are not.
+func ackedOnWrite(sel *rel.SqlSelect) bool {
+	if sel == nil || len(sel.From) != 1 || sel.From[0].SubQuery != nil || len(sel.SetOps) > 0 {
+		return false
+	}
+	return !sel.IsAggQuery() && !sel.IsWindowQuery() && !sel.Distinct && len(sel.OrderBy) == 0
+}
+
 // A scanner to read from sub-query data source (join, sub-query, static)
 func NewSourceScanner(ctx *plan.Context, p *plan.Source, scanner schema.ConnScanner) *Source {
 	s := &Source{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "source"),
 		Scanner:  scanner,
 		p:        p,
 	}
@@ -93,6 +136,33 @@ func NewSourceScanner(ctx *plan.Context, p *plan.Source, scanner schema.ConnScan
 
 func (m *Source) Copy() *Source { return &Source{} }
 
+// trace the source name and work pushed down to it on the span of source
+func (m *Source) trace(span plan.Span) {
+	if m.p == nil || m.p.Stmt == nil {
+		return
+	}
+	name := m.p.Stmt.SourceName()
+	span.SetAttribute("source", name)
+	if m.Ctx.Metrics == nil {
+		return
+	}
+	for _, pd := range m.Ctx.Metrics.Pushdowns {
+		if pd.Source == name {
+			span.SetAttribute("pushdown."+pd.Kind, pd.Accepted)
+		}
+	}
+}
+
+// Batched implements BatchTask, scanned rows are sent in batches of
+// RowBatchSize rows if its reader takes them.
+func (m *Source) Batched(in bool) bool {
+	if m.Scanner == nil {
+		return false
+	}
+	m.batchSize = RowBatchSize(m.Ctx)
+	return m.batchSize > 1
+}
+
 func (m *Source) closeSource() error {
 	m.Lock()
 	defer m.Unlock()
@@ -100,6 +170,12 @@ func (m *Source) closeSource() error {
 		return nil
 	}
 	m.closed = true
+	if m.acker != nil {
+		// messages scanned and never sent on, ie of a LIMIT
+		if err := m.acker.Nack(); err != nil {
+			u.Warnf("could not nack messages of %s: %v", m.p.Stmt.SourceName(), err)
+		}
+	}
 	if m.Scanner != nil {
 		if closer, ok := m.Scanner.(schema.Conn); ok {
 			if err := closer.Close(); err != nil {
@@ -118,7 +194,7 @@ func (m *Source) Close() error {
 	return m.TaskBase.Close()
 }
 
-func (m *Source) Run() error {
+func (m *Source) Run() (err error) {
 	defer m.Ctx.Recover()
 	defer close(m.msgOutCh)
 
@@ -126,11 +202,17 @@ func (m *Source) Run() error {
 		u.Warnf("no datasource configured?")
 		return fmt.Errorf("No datasource found")
 	}
+	defer m.meter(time.Now(), &err)
 
 	//u.Debugf("scanner: %T %#v", m.Scanner, m.Scanner)
 	sigChan := m.SigChan()
 
+	if m.batchSize > 1 {
+		return m.runBatches()
+	}
+
 	for item := m.Scanner.Next(); item != nil; item = m.Scanner.Next() {
+		m.rows++
 
 		//u.Infof("In source Scanner iter %#v", item)
 		select {
@@ -138,10 +220,90 @@ func (m *Source) Run() error {
 			//u.Debugf("exec/source SigChan shutdown")
 			return nil
 		case m.msgOutCh <- item:
-			// continue
+			m.ack(item)
 		}
 
 	}
 	//u.Debugf("leaving source scanner due to nil item")
-	return nil
+	return scanErr(m.Scanner)
+}
+
+// runBatches scan the rows, sending them in batches of batchSize, or in
+// the batches of the scanner if it reads batches
+func (m *Source) runBatches() error {
+	sigChan := m.SigChan()
+	if bs, ok := m.Scanner.(schema.ConnBatchScanner); ok {
+		for rows := bs.NextBatch(); rows != nil; rows = bs.NextBatch() {
+			if len(rows) == 0 {
+				continue
+			}
+			m.rows += int64(len(rows))
+			last := m.lastToAck(rows)
+			select {
+			case <-sigChan:
+				return nil
+			case m.msgOutCh <- &RowBatch{Rows: rows}:
+				m.ack(last)
+			}
+		}
+		return scanErr(m.Scanner)
+	}
+	batch := NewRowBatch(m.batchSize)
+	for item := m.Scanner.Next(); item != nil; item = m.Scanner.Next() {
+		m.rows++
+		batch.Rows = append(batch.Rows, item)
+		if batch.Len() < m.batchSize {
+			continue
+		}
+		last := m.lastToAck(batch.Rows)
+		select {
+		case <-sigChan:
+			return nil
+		case m.msgOutCh <- batch:
+			m.ack(last)
+			batch = NewRowBatch(m.batchSize)
+		}
+	}
+	if batch.Len() > 0 {
+		last := m.lastToAck(batch.Rows)
+		select {
+		case <-sigChan:
+		case m.msgOutCh <- batch:
+			m.ack(last)
+		}
+	}
+	return scanErr(m.Scanner)
+}
+
+// lastToAck the last of the rows of a batch, read before the batch is sent
+// as the receiver owns (and filters in place) its rows once sent.  nil if
+// the scan is not acked.
+func (m *Source) lastToAck(rows []schema.Message) schema.Message {
+	if m.acker == nil {
+		return nil
+	}
+	return rows[len(rows)-1]
+}
+
+// ack the messages up to that of msg, of a scan acked as it is sent on
+func (m *Source) ack(msg schema.Message) {
+	if m.acker == nil || msg == nil {
+		return
+	}
+	if err := m.acker.Ack(msg.Id()); err != nil {
+		u.Warnf("could not ack messages of %s: %v", m.p.Stmt.SourceName(), err)
+	}
+}
+
+// meter record the scan begun at start, of the rows scanned, in the metrics
+// of the source if it records them (see datasource.MeteredSource)
+func (m *Source) meter(start time.Time, err *error) {
+	if m.p == nil || m.p.Stmt == nil {
+		return
+	}
+	if metrics := datasource.MetricsOf(m.p.DataSource); metrics != nil {
+		name := m.p.Stmt.SourceName()
+		metrics.Request(name, time.Since(start), *err)
+		metrics.Read(name, m.rows, 0)
+	}
 }
@@ -0,0 +1,145 @@
+package exec
+
+import (
+	"fmt"
+	"sync"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*SourcePartitioned)(nil)
+)
+
+// SourcePartitioned scans the partitions of a partitionable source in
+// parallel.  A pool of ScanWorkers workers each take the next partition not
+// yet scanned, open a connection to it and send its rows to the shared
+// output, so rows of different partitions are interleaved.
+//
+//   partitions -> worker 0 (p0, p2 ..) ->
+//              -> worker 1 (p1, p3 ..) ->   --> output
+//              -> worker n             ->
+//
+type SourcePartitioned struct {
+	*TaskBase
+	p *plan.Source
+}
+
+// NewSourcePartitioned a parallel scan of the partitions of source
+func NewSourcePartitioned(ctx *plan.Context, p *plan.Source) (*SourcePartitioned, error) {
+	_, partitionable := p.Conn.(schema.SourcePartitionable)
+	for _, part := range p.Partitions {
+		if part.Source == "" && !partitionable {
+			return nil, fmt.Errorf("%T does not implement schema.SourcePartitionable", p.Conn)
+		}
+	}
+	return &SourcePartitioned{
+		TaskBase: NewTaskBaseNamed(ctx, "source"),
+		p:        p,
+	}, nil
+}
+
+func (m *SourcePartitioned) Run() error {
+	defer m.Ctx.Recover()
+	defer close(m.msgOutCh)
+
+	partCh := make(chan *schema.Partition, len(m.p.Partitions))
+	for _, part := range m.p.Partitions {
+		partCh <- part
+	}
+	close(partCh)
+
+	workers := m.p.ScanWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := make(errList, 0)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer m.Ctx.Recover()
+			defer wg.Done()
+			for part := range partCh {
+				done, err := m.scan(part)
+				if err != nil {
+					u.Errorf("could not scan partition %s of %s: %v", part.Id, m.p.Stmt.SourceName(), err)
+					mu.Lock()
+					errs.append(err)
+					mu.Unlock()
+				}
+				if !done {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return errs.error()
+}
+
+// scan the rows of a single partition, returns false if the task was shut
+// down before it finished
+func (m *SourcePartitioned) scan(part *schema.Partition) (bool, error) {
+	name := fmt.Sprintf("%s partition %s", m.p.Stmt.SourceName(), part.Id)
+	open := func() (schema.Conn, error) {
+		return openPartition(m.Ctx, m.p, part)
+	}
+	conn, err := plan.OpenRetry(m.Ctx, m.SigChan(), name, open)
+	if err != nil {
+		return true, err
+	}
+	scanner, ok := conn.(schema.ConnScanner)
+	if !ok {
+		conn.Close()
+		return true, fmt.Errorf("%T Must Implement Scanner for partition %s", conn, part.Id)
+	}
+	if sourceContext, needsContext := conn.(RequiresContext); needsContext {
+		sourceContext.SetContext(m.Ctx)
+	}
+	if m.Ctx.RetryPolicy() != nil {
+		// re-opens the partition on a transient error
+		scanner = newRetryScanner(m.Ctx, m.SigChan(), name, scanner, open)
+	}
+	scanner = newComputedScanner(m.p, scanner)
+	defer scanner.Close()
+
+	sigChan := m.SigChan()
+	for item := scanner.Next(); item != nil; item = scanner.Next() {
+		select {
+		case <-sigChan:
+			return false, nil
+		case m.msgOutCh <- item:
+		}
+	}
+	return true, scanErr(scanner)
+}
+
+// openPartition a connection to the rows of part of the source of p, of the
+// source the partition names if any (its table of the same name there), else
+// of the partitionable connection of p
+func openPartition(ctx *plan.Context, p *plan.Source, part *schema.Partition) (schema.Conn, error) {
+	if part.Source != "" {
+		if ctx.Schema == nil || p.Tbl == nil {
+			return nil, fmt.Errorf("no schema to find source %q of partition %s", part.Source, part.Id)
+		}
+		ss, err := ctx.Schema.SchemaSource(part.Source)
+		if err != nil {
+			return nil, err
+		}
+		return ss.DS.Open(p.Tbl.Name)
+	}
+	partitionable, ok := p.Conn.(schema.SourcePartitionable)
+	if !ok {
+		return nil, fmt.Errorf("%T does not implement schema.SourcePartitionable", p.Conn)
+	}
+	return partitionable.PartitionSource(part)
+}
@@ -0,0 +1,236 @@
+package exec_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// barrierTable partitions whose scans each wait until all partitions have
+// started scanning, so rows are only returned if they are scanned in parallel
+type barrierTable struct {
+	*membtree.StaticDataSource
+	parts   []*schema.Partition
+	shards  []*membtree.StaticDataSource
+	started *sync.WaitGroup
+}
+
+type barrierShard struct {
+	*membtree.StaticDataSource
+	started *sync.WaitGroup
+	waited  bool
+}
+
+func (m *barrierTable) Open(name string) (schema.Conn, error) { return m, nil }
+func (m *barrierTable) Partitions() []*schema.Partition       { return m.parts }
+func (m *barrierTable) PartitionSource(p *schema.Partition) (schema.Conn, error) {
+	for i, part := range m.parts {
+		if part.Id == p.Id {
+			return &barrierShard{StaticDataSource: m.shards[i], started: m.started}, nil
+		}
+	}
+	return nil, schema.ErrNotFound
+}
+
+func (m *barrierShard) Next() schema.Message {
+	if !m.waited {
+		m.waited = true
+		m.started.Done()
+		done := make(chan bool)
+		go func() {
+			m.started.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			return nil
+		}
+	}
+	return m.StaticDataSource.Next()
+}
+
+func newBarrierTable(parts int) *barrierTable {
+	t := &barrierTable{started: &sync.WaitGroup{}}
+	cols := []string{"id", "part"}
+	all := make([][]driver.Value, 0)
+	for i := 0; i < parts; i++ {
+		t.parts = append(t.parts, &schema.Partition{Id: fmt.Sprintf("%d", i)})
+		rows := make([][]driver.Value, 0)
+		for j := 0; j < 10; j++ {
+			rows = append(rows, []driver.Value{fmt.Sprintf("%d-%d", i, j), int64(i)})
+		}
+		all = append(all, rows...)
+		t.shards = append(t.shards, membtree.NewStaticDataSource("parts", 0, rows, cols))
+	}
+	t.StaticDataSource = membtree.NewStaticDataSource("parts", 0, all, cols)
+	t.started.Add(parts)
+	return t
+}
+
+func TestSourcePartitioned(t *testing.T) {
+	tests := []struct {
+		sql     string
+		workers int
+		explain string
+	}{
+		{`SELECT user_id, name FROM users WHERE name != "bob"`, 2,
+			"users conn=*exec_test.shardedTable partitions=2 workers=2"},
+		{`SELECT user_id, name FROM users WHERE name != "bob"`, 1,
+			"users conn=*exec_test.shardedTable partitions=2 workers=1"},
+		// never more workers than partitions
+		{`SELECT user_id, name FROM users WHERE name != "bob"`, 8,
+			"users conn=*exec_test.shardedTable partitions=2 workers=2"},
+		{`/*+ MAX_PARALLELISM(1) */ SELECT user_id, name FROM users WHERE name != "bob"`, 8,
+			"users conn=*exec_test.shardedTable partitions=2 workers=1"},
+	}
+	for _, tt := range tests {
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema = shardedSchema
+		ctx.ScanWorkers = tt.workers
+
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+		source := ""
+		for _, step := range plan.ExplainTask(ctx.Projection.P) {
+			if step.Task == "source" {
+				source = step.Detail
+			}
+		}
+		assert.Equalf(t, tt.explain, source, "source plan for %s", tt.sql)
+
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+		err = job.Run()
+		assert.Tf(t, err == nil, "no error %v", err)
+
+		rows := make([]string, 0, len(msgs))
+		for _, msg := range msgs {
+			vals := msg.(*datasource.SqlDriverMessageMap).Values()
+			rows = append(rows, fmt.Sprintf("%v:%v", vals[0], vals[1]))
+		}
+		sort.Strings(rows)
+		assert.Equalf(t, "a1:alice,n3:nancy,z4:zed", strings.Join(rows, ","), "rows for %s", tt.sql)
+	}
+}
+
+func TestSourcePartitionedParallel(t *testing.T) {
+	tbl := newBarrierTable(4)
+	sch := datasource.RegisterSchemaSource("barrier", "barrier", tbl)
+
+	// each partition waits for all 4 to start, only possible with 4 workers
+	sql := `SELECT id, part FROM parts`
+	ctx := plan.NewContext(sql)
+	ctx.Schema = sch
+	ctx.ScanWorkers = 4
+
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v for %s", err, sql)
+	assert.T(t, ctx.Projection.P.From[0].ScanWorkers == 4)
+	msgs := make([]schema.Message, 0)
+	job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+	assert.T(t, job.Setup() == nil)
+	assert.T(t, job.Run() == nil)
+	assert.Equal(t, 40, len(msgs))
+
+	parts := make(map[string]int)
+	for _, msg := range msgs {
+		vals := msg.(*datasource.SqlDriverMessageMap).Values()
+		parts[fmt.Sprintf("%v", vals[1])]++
+	}
+	assert.Equal(t, 4, len(parts))
+	for part, ct := range parts {
+		assert.Equalf(t, 10, ct, "rows of partition %s", part)
+	}
+}
+
+func TestSourcePartitionPruning(t *testing.T) {
+
+	// events hash partitioned on id over two sources, of the same table
+	// (of all rows) in the first
+	cols := []string{"id", "name"}
+	tp := &schema.TablePartition{Keys: []string{"id"}, Method: schema.PartitionHash, Buckets: 2,
+		Partitions: []*schema.Partition{{Id: "0", Source: "pruneshard0"}, {Id: "1", Source: "pruneshard1"}}}
+	all := make([][]driver.Value, 0)
+	shards := [][][]driver.Value{{}, {}}
+	for i := int64(1); i <= 6; i++ {
+		row := []driver.Value{i, fmt.Sprintf("event%d", i)}
+		all = append(all, row)
+		shards[tp.Bucket(i)] = append(shards[tp.Bucket(i)], row)
+	}
+	primary := membtree.NewSource()
+	primary.AddTable(membtree.NewStaticDataSource("events", 0, all, cols))
+	sch := datasource.RegisterSchemaSource("prunemem", "prunemem", primary)
+	for i, rows := range shards {
+		shard := membtree.NewSource()
+		shard.AddTable(membtree.NewStaticDataSource("events", 0, rows, cols))
+		ss := schema.NewSchemaSource(fmt.Sprintf("pruneshard%d", i), "membtree")
+		ss.DS = shard
+		sch.AddSourceSchema(ss)
+	}
+	sch.RefreshSchema()
+	tbl, err := sch.Table("events")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.T(t, tbl.SetPartition(tp) == nil)
+
+	tests := []struct {
+		schema  *schema.Schema
+		sql     string
+		rows    string
+		explain string
+	}{
+		{sch, `SELECT id, name FROM events`, "1:event1,2:event2,3:event3,4:event4,5:event5,6:event6",
+			"partitions=2 workers=2"},
+		{sch, `SELECT id, name FROM events WHERE id = 4`, "4:event4", "partitions=1 workers=1"},
+		{sch, `SELECT id, name FROM events WHERE id IN (2, 4) AND name != "x"`, "2:event2,4:event4",
+			fmt.Sprintf("partitions=%d", len(map[int]bool{tp.Bucket(int64(2)): true, tp.Bucket(int64(4)): true}))},
+		// ranges of a hash are in any partition
+		{sch, `SELECT id, name FROM events WHERE id > 4`, "5:event5,6:event6", "partitions=2 workers=2"},
+		// range partitions
+		{shardedSchema, `SELECT user_id, name FROM users WHERE user_id = "n3"`, "n3:nancy", "partitions=1 workers=1"},
+		{shardedSchema, `SELECT user_id, name FROM users WHERE "c" > user_id`, "a1:alice,b2:bob", "partitions=1 workers=1"},
+		{shardedSchema, `SELECT user_id, name FROM users WHERE user_id IN ("b2", "z4")`, "b2:bob,z4:zed", "partitions=2 workers=2"},
+	}
+	for _, tt := range tests {
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema = tt.schema
+		ctx.ScanWorkers = 2
+
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+		source := ""
+		for _, step := range plan.ExplainTask(ctx.Projection.P) {
+			if step.Task == "source" {
+				source = step.Detail
+			}
+		}
+		assert.Tf(t, strings.Contains(source, tt.explain), "want %q in %q for %s", tt.explain, source, tt.sql)
+
+		msgs := make([]schema.Message, 0)
+		job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+		assert.T(t, job.Setup() == nil)
+		err = job.Run()
+		assert.Tf(t, err == nil, "no error %v", err)
+
+		rows := make([]string, 0, len(msgs))
+		for _, msg := range msgs {
+			vals := msg.(*datasource.SqlDriverMessageMap).Values()
+			rows = append(rows, fmt.Sprintf("%v:%v", vals[0], vals[1]))
+		}
+		sort.Strings(rows)
+		assert.Equalf(t, tt.rows, strings.Join(rows, ","), "rows for %s", tt.sql)
+	}
+}
@@ -2,20 +2,18 @@ package exec
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"database/sql/driver"
-	"errors"
 	"fmt"
 	"io"
-	"strconv"
+	"reflect"
 	"strings"
 	"sync"
-	"time"
 
 	u "github.com/araddon/gou"
 
 	"github.com/araddon/qlbridge/datasource"
-	"github.com/araddon/qlbridge/expr"
 	"github.com/araddon/qlbridge/plan"
 	"github.com/araddon/qlbridge/rel"
 	"github.com/araddon/qlbridge/schema"
@@ -30,7 +28,20 @@ var (
 	_ driver.Result  = (*qlbResult)(nil)
 	_ driver.Rows    = (*qlbRows)(nil)
 	_ driver.Stmt    = (*qlbStmt)(nil)
-	//_ driver.Tx      = (*driverConn)(nil)
+	_ driver.Tx      = (*qlbTx)(nil)
+
+	// cancelled with the context of the caller
+	_ driver.ExecerContext      = (*qlbConn)(nil)
+	_ driver.QueryerContext     = (*qlbConn)(nil)
+	_ driver.ConnPrepareContext = (*qlbConn)(nil)
+	_ driver.ConnBeginTx        = (*qlbConn)(nil)
+	_ driver.StmtExecContext    = (*qlbStmt)(nil)
+	_ driver.StmtQueryContext   = (*qlbStmt)(nil)
+
+	// statements of more than one result set, column types
+	_ driver.RowsNextResultSet              = (*qlbRows)(nil)
+	_ driver.RowsColumnTypeScanType         = (*qlbRows)(nil)
+	_ driver.RowsColumnTypeDatabaseTypeName = (*qlbRows)(nil)
 
 	// Create an instance of our driver
 	qlbd          = &qlbdriver{}
@@ -109,6 +120,7 @@ type qlbConn struct {
 	parallel bool   // Do we Run In Background Mode?  Default = true
 	connInfo string //
 	schema   *schema.Schema
+	tx       *plan.Tx // the transaction of Begin, nil if none
 }
 
 // Exec may return ErrSkip.
@@ -116,21 +128,62 @@ type qlbConn struct {
 // Execer implementation. To be used for queries that do not return any rows
 // such as Create Index, Insert, Upset, Delete etc
 func (m *qlbConn) Exec(query string, args []driver.Value) (driver.Result, error) {
-	stmt := &qlbStmt{conn: m, query: query}
-	return stmt.Exec(args)
+	return m.ExecContext(context.Background(), query, namedArgs(args))
 }
 
 // Queryer implementation
 // Query may return ErrSkip
 //
 func (m *qlbConn) Query(query string, args []driver.Value) (driver.Rows, error) {
-	stmt := &qlbStmt{conn: m, query: query}
-	return stmt.Query(args)
+	return m.QueryContext(context.Background(), query, namedArgs(args))
+}
+
+// ExecContext implementation, the statement is stopped if ctx is cancelled
+func (m *qlbConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	stmt, err := m.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.(*qlbStmt).ExecContext(ctx, args)
+}
+
+// QueryContext implementation, the query is stopped if ctx is cancelled
+// such as the client going away
+func (m *qlbConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	stmt, err := m.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.(*qlbStmt).QueryContext(ctx, args)
 }
 
 // Prepare returns a prepared statement, bound to this connection.
 func (m *qlbConn) Prepare(query string) (driver.Stmt, error) {
-	return nil, expr.ErrNotImplemented
+	return m.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext a prepared statement of the query, whose statements are
+// parsed once here so syntax errors are returned by Prepare.  The args of
+// each Exec/Query are bound into the placeholders (?, $1 or :name) of the
+// statements as literals.
+func (m *qlbConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	q, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]bool, len(q.stmts))
+	for i, stmt := range q.stmts {
+		// placeholders are parsed as 0, a literal wherever a value may be
+		sqlStmt, err := rel.ParseSql(strings.Join(stmt.parts, "0"))
+		if err != nil {
+			return nil, err
+		}
+		switch sqlStmt.(type) {
+		case *rel.SqlSelect, *rel.SqlShow, *rel.SqlDescribe:
+			rows[i] = true
+		}
+	}
+	return &qlbStmt{conn: m, query: query, q: q, rows: rows}, nil
 }
 
 // Close invalidates and potentially stops any current
@@ -142,22 +195,58 @@ func (m *qlbConn) Prepare(query string) (driver.Stmt, error) {
 // idle connections, it shouldn't be necessary for drivers to
 // do their own connection caching.
 func (m *qlbConn) Close() error {
-	//u.Debugf("sqlbConn.Close() do we need to do anything here?")
+	if m.tx != nil {
+		tx := m.tx
+		m.tx = nil
+		return tx.Rollback()
+	}
 	return nil
 }
 
 // Begin starts and returns a new transaction.
 func (m *qlbConn) Begin() (driver.Tx, error) {
-	return nil, expr.ErrNotImplemented
+	return m.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx a transaction of the writes of the statements of this connection
+// until Commit or Rollback.  Writes of each table are of a transaction of
+// its source so sources of tables written must be transactional (see
+// schema.ConnTransaction).  Reads see committed rows, not those written by
+// the transaction.
+func (m *qlbConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if m.tx != nil {
+		return nil, fmt.Errorf("a transaction is already in progress")
+	}
+	switch sql.IsolationLevel(opts.Isolation) {
+	case sql.LevelDefault, sql.LevelReadCommitted:
+	default:
+		return nil, fmt.Errorf("isolation level %v is not supported", sql.IsolationLevel(opts.Isolation))
+	}
+	m.tx = plan.NewTx()
+	return &qlbTx{conn: m, tx: m.tx}, nil
 }
 
 // sql.Tx Transaction Interface implementation.
-type qlbTx struct{}
+type qlbTx struct {
+	conn *qlbConn
+	tx   *plan.Tx
+}
 
-func (conn *qlbTx) Commit() error {
-	return expr.ErrNotImplemented
+func (m *qlbTx) Commit() error {
+	m.end()
+	return m.tx.Commit()
+}
+func (m *qlbTx) Rollback() error {
+	m.end()
+	return m.tx.Rollback()
+}
+
+// end the transaction of the connection, its statements are no longer of it
+func (m *qlbTx) end() {
+	if m.conn.tx == m.tx {
+		m.conn.tx = nil
+	}
 }
-func (conn *qlbTx) Rollback() error { return expr.ErrNotImplemented }
 
 // driver.Stmt Interface implementation.
 //
@@ -165,8 +254,10 @@ func (conn *qlbTx) Rollback() error { return expr.ErrNotImplemented }
 // used by multiple goroutines concurrently.
 //
 type qlbStmt struct {
-	job   *JobExecutor
+	job   *JobExecutor // of the last statement run
 	query string
+	q     *sqlQuery
+	rows  []bool // does each statement of q return rows?
 	conn  *qlbConn
 }
 
@@ -190,63 +281,93 @@ func (m *qlbStmt) Close() error {
 // NumInput may also return -1, if the driver doesn't know
 // its number of placeholders. In that case, the sql package
 // will not sanity check Exec or Query argument counts.
-func (m *qlbStmt) NumInput() int { return 0 }
+func (m *qlbStmt) NumInput() int { return m.q.numInput }
 
 // Exec executes a query that doesn't return rows, such
 // as an INSERT, UPDATE, DELETE
 func (m *qlbStmt) Exec(args []driver.Value) (driver.Result, error) {
-	var err error
-	if len(args) > 0 {
-		m.query, err = queryArgsConvert(m.query, args)
+	return m.ExecContext(context.Background(), namedArgs(args))
+}
+
+// ExecContext run each statement of the query, the result is the rows
+// affected by all of them and the last insert id of the last to have one
+func (m *qlbStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	sqls, err := m.q.bind(args)
+	if err != nil {
+		return nil, err
+	}
+	result := &qlbResult{}
+	for _, sqlText := range sqls {
+		job, err := m.newJob(ctx, sqlText)
 		if err != nil {
 			return nil, err
 		}
+		r, err := m.runExec(job)
+		if err != nil {
+			return nil, err
+		}
+		result.affected += r.affected
+		if r.lastId != 0 {
+			result.lastId = r.lastId
+		}
+	}
+	return result, nil
+}
+
+// Query executes a query that may return rows, such as a SELECT
+func (m *qlbStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return m.QueryContext(context.Background(), namedArgs(args))
+}
+
+// QueryContext run the statements of the query, each SELECT (or SHOW,
+// DESCRIBE) is a result set run as the rows of the one before it are read,
+// others are run on the way to the next result set.
+func (m *qlbStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	sqls, err := m.q.bind(args)
+	if err != nil {
+		return nil, err
+	}
+	rows := &qlbRows{stmt: m, ctx: ctx, sqls: sqls, rows: m.rows}
+	if err := rows.NextResultSet(); err != nil && err != io.EOF {
+		return nil, err
 	}
+	return rows, nil
+}
 
-	// Create a Job, which is Dag of Tasks that Run()
-	ctx := plan.NewContext(m.query)
-	ctx.Schema = m.conn.schema
-	job, err := BuildSqlJob(ctx)
+// newJob a Job, which is Dag of Tasks that Run(), of a statement
+func (m *qlbStmt) newJob(ctx context.Context, sqlText string) (*JobExecutor, error) {
+	pctx := plan.NewContext(sqlText)
+	pctx.Context = ctx
+	pctx.Schema = m.conn.schema
+	pctx.Tx = m.conn.tx
+	job, err := BuildSqlJob(pctx)
 	if err != nil {
 		return nil, err
 	}
 	m.job = job
+	return job, nil
+}
 
-	resultWriter := NewResultExecWriter(ctx)
+// runExec run the job of a statement that doesn't return rows
+func (m *qlbStmt) runExec(job *JobExecutor) (*qlbResult, error) {
+	resultWriter := NewResultExecWriter(job.Ctx)
 	job.RootTask.Add(resultWriter)
 
 	job.Setup()
 	//u.Infof("in qlbdriver.Exec about to run")
-	err = job.Run()
+	err := job.Run()
 	//u.Debugf("After qlb driver.Run() in Exec()")
 	if err != nil {
 		u.Errorf("error on Query.Run(): %v", err)
-		//resultWriter.ErrChan() <- err
-		//job.Close()
+		// ie a write rejected by the constraints of its table
+		return nil, err
 	}
-	return resultWriter.Result(), nil
+	return &qlbResult{lastId: resultWriter.lastInsertId, affected: resultWriter.rowsAffected}, nil
 }
 
-// Query executes a query that may return rows, such as a SELECT
-func (m *qlbStmt) Query(args []driver.Value) (driver.Rows, error) {
-	var err error
-	if len(args) > 0 {
-		m.query, err = queryArgsConvert(m.query, args)
-		if err != nil {
-			return nil, err
-		}
-	}
-	//u.Debugf("query: %v", m.query)
-
-	// Create a Job, which is Dag of Tasks that Run()
-	ctx := plan.NewContext(m.query)
-	ctx.Schema = m.conn.schema
-	job, err := BuildSqlJob(ctx)
-	if err != nil {
-		u.Warnf("return error? %v", err)
-		return nil, err
-	}
-	m.job = job
+// runQuery start the job of a select, whose rows are read by Next of the
+// result writer as it runs
+func (m *qlbStmt) runQuery(job *JobExecutor) (*ResultWriter, error) {
 
 	// The only type of stmt that makes sense for Query is SELECT
 	//  and we need list of columns that requires casing
@@ -258,22 +379,19 @@ func (m *qlbStmt) Query(args []driver.Value) (driver.Rows, error) {
 
 	// Prepare a result writer, we manually append this task to end
 	// of job?
-	resultWriter := NewResultRows(ctx, sqlSelect.Columns.AliasedFieldNames())
+	resultWriter := NewResultRows(job.Ctx, sqlSelect.Columns.AliasedFieldNames())
+	resultWriter.types = plan.ColumnTypes(job.Ctx, sqlSelect)
 
 	job.RootTask.Add(resultWriter)
 
 	job.Setup()
 
-	// TODO:   this can't run in parallel-buffered mode?
-	// how to open in go-routine and still be able to send error to rows?
+	// the job runs until the rows are closed, its errors (and the error
+	// of a cancelled context) are read by Next
 	go func() {
 		//u.Debugf("Start Job.Run")
-		err = job.Run()
-		//u.Debugf("After job.Run()")
-		if err != nil {
+		if err := job.Run(); err != nil {
 			u.Errorf("error on Query.Run(): %v", err)
-			//resultWriter.ErrChan() <- err
-			//job.Close()
 		}
 		job.Close()
 		//u.Debugf("exiting Background Query")
@@ -292,22 +410,44 @@ func (m *qlbStmt) Query(args []driver.Value) (driver.Rows, error) {
 // column index.  If the type of a specific column isn't known
 // or shouldn't be handled specially, DefaultValueConverter
 // can be returned.
-func (conn *qlbStmt) ColumnConverter(idx int) driver.ValueConverter { return nil }
+func (conn *qlbStmt) ColumnConverter(idx int) driver.ValueConverter {
+	return driver.DefaultParameterConverter
+}
 
 // driver.Rows Interface implementation.
 //
-// Rows is an iterator over an executed query's results.
+// Rows is an iterator over an executed query's results, the rows of each
+// statement of the query that returns rows are a result set.
 //
-type qlbRows struct{}
+type qlbRows struct {
+	stmt *qlbStmt
+	ctx  context.Context
+	sqls []string // statements not yet run
+	rows []bool   // does each of sqls return rows?
+	cur  *ResultWriter
+}
 
 // Columns returns the names of the columns. The number of
 // columns of the result is inferred from the length of the
 // slice.  If a particular column name isn't known, an empty
 // string should be returned for that entry.
-func (conn *qlbRows) Columns() []string { return nil }
+func (m *qlbRows) Columns() []string {
+	if m.cur == nil {
+		return nil
+	}
+	return m.cur.Columns()
+}
 
-// Close closes the rows iterator.
-func (conn *qlbRows) Close() error { return expr.ErrNotImplemented }
+// Close closes the rows iterator, statements of result sets not yet read
+// are not run.
+func (m *qlbRows) Close() error {
+	if m.cur == nil {
+		return nil
+	}
+	cur := m.cur
+	m.cur = nil
+	return cur.Close()
+}
 
 // Next is called to populate the next row of data into
 // the provided slice. The provided slice will be the same
@@ -318,7 +458,56 @@ func (conn *qlbRows) Close() error { return expr.ErrNotImplemented }
 // All string values must be converted to []byte.
 //
 // Next should return io.EOF when there are no more rows.
-func (conn *qlbRows) Next(dest []driver.Value) error { return expr.ErrNotImplemented }
+func (m *qlbRows) Next(dest []driver.Value) error {
+	if m.cur == nil {
+		return io.EOF
+	}
+	return m.cur.Next(dest)
+}
+
+// HasNextResultSet is there a statement after this result set
+func (m *qlbRows) HasNextResultSet() bool { return len(m.sqls) > 0 }
+
+// NextResultSet run statements up to and including the next that returns
+// rows, io.EOF if none do.
+func (m *qlbRows) NextResultSet() error {
+	if err := m.Close(); err != nil {
+		return err
+	}
+	for len(m.sqls) > 0 {
+		sqlText, rows := m.sqls[0], m.rows[0]
+		m.sqls, m.rows = m.sqls[1:], m.rows[1:]
+		job, err := m.stmt.newJob(m.ctx, sqlText)
+		if err != nil {
+			return err
+		}
+		if !rows {
+			if _, err := m.stmt.runExec(job); err != nil {
+				return err
+			}
+			continue
+		}
+		m.cur, err = m.stmt.runQuery(job)
+		return err
+	}
+	return io.EOF
+}
+
+// ColumnTypeScanType the go type of the values of the column
+func (m *qlbRows) ColumnTypeScanType(index int) reflect.Type {
+	if m.cur == nil {
+		return scanTypeUnknown
+	}
+	return m.cur.ColumnTypeScanType(index)
+}
+
+// ColumnTypeDatabaseTypeName the database type of the column
+func (m *qlbRows) ColumnTypeDatabaseTypeName(index int) string {
+	if m.cur == nil {
+		return ""
+	}
+	return m.cur.ColumnTypeDatabaseTypeName(index)
+}
 
 // driver.Result Interface implementation.
 //
@@ -339,99 +528,6 @@ func (r *qlbResult) LastInsertId() (int64, error) { return r.lastId, r.err }
 // query.
 func (r *qlbResult) RowsAffected() (int64, error) { return r.affected, r.err }
 
-func join(a []string) string {
-	n := 0
-	for _, s := range a {
-		n += len(s)
-	}
-	b := make([]byte, n)
-	n = 0
-	for _, s := range a {
-		n += copy(b[n:], s)
-	}
-	return string(b)
-}
-
-func queryArgsConvert(query string, args []driver.Value) (string, error) {
-	if len(args) == 0 {
-		return query, nil
-	}
-	// a tiny, tiny, tiny bit of string sanitization
-	if strings.ContainsAny(query, `'"`) {
-		return "", nil
-	}
-	q := make([]string, 2*len(args)+1)
-	n := 0
-	for _, a := range args {
-		i := strings.IndexRune(query, '?')
-		if i == -1 {
-			return "", errors.New("number of parameters doesn't match number of placeholders")
-		}
-		var s string
-		switch v := a.(type) {
-		case nil:
-			s = "NULL"
-		case string:
-			s = "'" + escapeString(v) + "'"
-		case []byte:
-			s = "'" + escapeString(string(v)) + "'"
-		case int64:
-			s = strconv.FormatInt(v, 10)
-		case time.Time:
-			s = "'" + v.Format(MysqlTimeFormat) + "'"
-		case bool:
-			if v {
-				s = "1"
-			} else {
-				s = "0"
-			}
-		case float64:
-			s = strconv.FormatFloat(v, 'e', 12, 64)
-		default:
-			panic(fmt.Sprintf("%v (%T) can't be handled by godrv"))
-		}
-		q[n] = query[:i]
-		q[n+1] = s
-		query = query[i+1:]
-		n += 2
-	}
-	q[n] = query
-	return join(q), nil
-}
-
-func escapeString(txt string) string {
-	var (
-		esc string
-		buf bytes.Buffer
-	)
-	last := 0
-	for ii, bb := range txt {
-		switch bb {
-		case 0:
-			esc = `\0`
-		case '\n':
-			esc = `\n`
-		case '\r':
-			esc = `\r`
-		case '\\':
-			esc = `\\`
-		case '\'':
-			esc = `\'`
-		case '"':
-			esc = `\"`
-		case '\032':
-			esc = `\Z`
-		default:
-			continue
-		}
-		io.WriteString(&buf, txt[last:ii])
-		io.WriteString(&buf, esc)
-		last = ii + 1
-	}
-	io.WriteString(&buf, txt[last:])
-	return buf.String()
-}
-
 func escapeQuotes(txt string) string {
 	var buf bytes.Buffer
 	last := 0
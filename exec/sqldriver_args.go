@@ -0,0 +1,280 @@
+package exec
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// sqlParam a placeholder of a statement, ? and $1 are positional (of the
+// ordinal of their arg), :name named (of the sql.Named arg of name)
+type sqlParam struct {
+	ordinal int
+	name    string
+}
+
+// sqlTemplate a statement of a query split at its placeholders, the args
+// are bound into it as literals per Exec/Query.
+type sqlTemplate struct {
+	parts  []string // text around the params, len(params)+1
+	params []sqlParam
+}
+
+// sqlQuery the statements of a query of the driver, a query may have more
+// than one statement separated by ; each of which is a result set.
+type sqlQuery struct {
+	stmts    []*sqlTemplate
+	numInput int  // number of args of the placeholders
+	named    bool // are the placeholders :name ?
+}
+
+// parseQuery split a query into its statements and placeholders, skipping
+// those in quoted strings, identities and comments
+//
+//   SELECT * FROM users WHERE id = ? AND email = ?;   -- positional
+//   SELECT * FROM users WHERE id = $2 OR ref = $1;    -- positional of ordinal
+//   SELECT * FROM users WHERE id = :id;               -- named, sql.Named("id", 1)
+//
+func parseQuery(query string) (*sqlQuery, error) {
+	q := &sqlQuery{}
+	names := make(map[string]bool)
+	positional := false
+	nextOrdinal := 1
+	cur := &sqlTemplate{}
+	start := 0
+	// the last token was a quoted string or identity, a : after it is
+	// the : of json ({"a": 1}) not a placeholder
+	afterLiteral := false
+
+	addStmt := func(end int) {
+		cur.parts = append(cur.parts, query[start:end])
+		if len(cur.params) > 0 || !isBlankSql(strings.Join(cur.parts, "")) {
+			q.stmts = append(q.stmts, cur)
+		}
+		cur = &sqlTemplate{}
+	}
+	addParam := func(end, next int, p sqlParam) {
+		cur.parts = append(cur.parts, query[start:end])
+		cur.params = append(cur.params, p)
+		start = next
+	}
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end, err := quotedEnd(query, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			afterLiteral = true
+			continue
+		case c == '-' && strings.HasPrefix(query[i:], "--"), c == '/' && strings.HasPrefix(query[i:], "//"):
+			if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(query) - 1
+			}
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment in %q", query)
+			}
+			i += end + 3
+		case c == ';':
+			addStmt(i)
+			start = i + 1
+		case c == '?':
+			positional = true
+			addParam(i, i+1, sqlParam{ordinal: nextOrdinal})
+			nextOrdinal++
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			end := i + 1
+			for end < len(query) && isDigit(query[end]) {
+				end++
+			}
+			ordinal, err := strconv.Atoi(query[i+1 : end])
+			if err != nil || ordinal < 1 {
+				return nil, fmt.Errorf("invalid placeholder %q", query[i:end])
+			}
+			positional = true
+			addParam(i, end, sqlParam{ordinal: ordinal})
+			i = end - 1
+		case c == ':' && !afterLiteral && i+1 < len(query) && isNameStart(query[i+1]) &&
+			(i == 0 || query[i-1] != ':'):
+			end := i + 1
+			for end < len(query) && isNamePart(query[end]) {
+				end++
+			}
+			name := query[i+1 : end]
+			names[name] = true
+			addParam(i, end, sqlParam{name: name})
+			i = end - 1
+		}
+		if !unicode.IsSpace(rune(c)) {
+			afterLiteral = false
+		}
+	}
+	addStmt(len(query))
+
+	if positional && len(names) > 0 {
+		return nil, fmt.Errorf("can not mix positional and named placeholders in %q", query)
+	}
+	q.named = len(names) > 0
+	for _, stmt := range q.stmts {
+		for _, p := range stmt.params {
+			if p.ordinal > q.numInput {
+				q.numInput = p.ordinal
+			}
+		}
+	}
+	if q.named {
+		q.numInput = len(names)
+	}
+	return q, nil
+}
+
+// quotedEnd the position of the quote ending the quoted string (or
+// identity) starting at start, quotes are escaped by \ or doubled
+func quotedEnd(query string, start int) (int, error) {
+	quote := query[start]
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated quoted string in %q", query)
+}
+
+func isBlankSql(sql string) bool {
+	for _, r := range sql {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(c byte) bool     { return c >= '0' && c <= '9' }
+func isNameStart(c byte) bool { return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isNamePart(c byte) bool  { return isNameStart(c) || isDigit(c) }
+
+// bind the args into the statements of the query, the sql of each
+func (m *sqlQuery) bind(args []driver.NamedValue) ([]string, error) {
+	if m.named {
+		for _, arg := range args {
+			if arg.Name == "" {
+				return nil, fmt.Errorf("query has named placeholders but arg %d is not a sql.Named arg", arg.Ordinal)
+			}
+		}
+	} else {
+		for _, arg := range args {
+			if arg.Name != "" {
+				return nil, fmt.Errorf("named arg %q but query has no named placeholders", arg.Name)
+			}
+		}
+	}
+	if len(args) != m.numInput {
+		return nil, fmt.Errorf("query has %d placeholders but got %d args", m.numInput, len(args))
+	}
+	sqls := make([]string, len(m.stmts))
+	for i, stmt := range m.stmts {
+		var buf bytes.Buffer
+		for pi, p := range stmt.params {
+			buf.WriteString(stmt.parts[pi])
+			arg, ok := m.arg(p, args)
+			if !ok {
+				return nil, fmt.Errorf("no arg for placeholder %s", p)
+			}
+			lit, err := sqlLiteral(arg.Value)
+			if err != nil {
+				return nil, fmt.Errorf("placeholder %s: %v", p, err)
+			}
+			buf.WriteString(lit)
+		}
+		buf.WriteString(stmt.parts[len(stmt.params)])
+		sqls[i] = buf.String()
+	}
+	return sqls, nil
+}
+
+// arg the arg of the placeholder
+func (m *sqlQuery) arg(p sqlParam, args []driver.NamedValue) (driver.NamedValue, bool) {
+	for _, arg := range args {
+		if p.name != "" && arg.Name == p.name || p.name == "" && arg.Ordinal == p.ordinal {
+			return arg, true
+		}
+	}
+	return driver.NamedValue{}, false
+}
+
+func (m sqlParam) String() string {
+	if m.name != "" {
+		return ":" + m.name
+	}
+	return "$" + strconv.Itoa(m.ordinal)
+}
+
+// namedArgs the args of positional values
+func namedArgs(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+// sqlLiteral the sql literal of a value.  Strings are not unescaped by the
+// lexer so are quoted by whichever quote mark they don't have.
+func sqlLiteral(v driver.Value) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return quoteString(v)
+	case []byte:
+		return quoteString(string(v))
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("can not bind %v", v)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	case time.Time:
+		return "'" + v.Format(MysqlTimeFormat) + "'", nil
+	}
+	return "", fmt.Errorf("can not bind %v (%T)", v, v)
+}
+
+func quoteString(s string) (string, error) {
+	switch {
+	case strings.IndexByte(s, 0) >= 0:
+		return "", fmt.Errorf("can not bind a string with a NUL byte")
+	case strings.HasSuffix(s, `\`):
+		return "", fmt.Errorf("can not bind a string ending in \\: %q", s)
+	case !strings.Contains(s, "'"):
+		return "'" + s + "'", nil
+	case !strings.Contains(s, `"`):
+		return `"` + s + `"`, nil
+	}
+	return "", fmt.Errorf("can not bind a string of both ' and \": %q", s)
+}
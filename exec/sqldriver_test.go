@@ -1,7 +1,11 @@
 package exec_test
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -9,6 +13,8 @@ import (
 	"github.com/bmizerany/assert"
 
 	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/datasource/memdb"
 	"github.com/araddon/qlbridge/exec"
 )
 
@@ -374,3 +380,208 @@ func TestSqlDbConnFailure(t *testing.T) {
 	assert.Tf(t, uo1.Price == 22.5, "? %#v", uo1)
 	rows2.Close()
 }
+
+func TestSqlDriverPrepared(t *testing.T) {
+	db, err := sql.Open("qlbridge", "mockcsv")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer db.Close()
+
+	// prepared once, run of each arg
+	stmt, err := db.Prepare(`SELECT email FROM users WHERE user_id = ? AND email != "x's"`)
+	assert.Tf(t, err == nil, "no error: %v", err)
+	for id, want := range map[string]string{
+		"9Ip1aKbeZe2njCDM": "aaron@email.com",
+		"hT2impsOPUREcVPc": "bob@email.com",
+	} {
+		var email string
+		err = stmt.QueryRow(id).Scan(&email)
+		assert.Tf(t, err == nil, "no error: %v", err)
+		assert.Equal(t, want, email)
+	}
+	_, err = stmt.Query()
+	assert.Tf(t, err != nil, "expected error of missing arg")
+	stmt.Close()
+
+	tests := []struct {
+		sql  string
+		args []interface{}
+		want []string
+	}{
+		{`SELECT user_id FROM users WHERE email = $2 OR email = $1`,
+			[]interface{}{"aaron@email.com", "bob@email.com"}, []string{"9Ip1aKbeZe2njCDM", "hT2impsOPUREcVPc"}},
+		{`SELECT user_id FROM users WHERE email = :email AND referral_count > :ct`,
+			[]interface{}{sql.Named("ct", 50), sql.Named("email", "aaron@email.com")}, []string{"9Ip1aKbeZe2njCDM"}},
+		// quotes of args, ? in strings and comments are not placeholders
+		{`SELECT user_id FROM users WHERE email = ? OR email = '?' -- or ?`,
+			[]interface{}{`o'brien "bob"@email.com`}, nil},
+		{`SELECT user_id FROM users WHERE email = ? OR interests = ?`,
+			[]interface{}{"o'brien@email.com", "swimming"}, []string{"hT2impsOPUREcVPc"}},
+	}
+	for _, tt := range tests {
+		rows, err := db.Query(tt.sql, tt.args...)
+		if tt.want == nil {
+			assert.Tf(t, err != nil, "expected error for %s", tt.sql)
+			continue
+		}
+		assert.Tf(t, err == nil, "no error: %v for %s", err, tt.sql)
+		var got []string
+		for rows.Next() {
+			var id string
+			assert.T(t, rows.Scan(&id) == nil)
+			got = append(got, id)
+		}
+		assert.Tf(t, rows.Err() == nil, "no error: %v", rows.Err())
+		rows.Close()
+		assert.Equalf(t, tt.want, got, "rows of %s", tt.sql)
+	}
+
+	for _, bad := range []string{
+		`SELECT user_id FROM users WHERE email = ? AND user_id = :id`,
+		`SELECT user_id FROM users WHERE email = 'unterminated`,
+		`SELECT user_id FROM WHERE`,
+	} {
+		_, err = db.Prepare(bad)
+		assert.Tf(t, err != nil, "expected error for %s", bad)
+	}
+}
+
+func TestSqlDriverResultSets(t *testing.T) {
+	db, err := sql.Open("qlbridge", "mockcsv")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT email FROM users WHERE user_id = ?;
+		SELECT item_id, price FROM orders WHERE user_id = ?;
+	`, "9Ip1aKbeZe2njCDM", "9Ip1aKbeZe2njCDM")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer rows.Close()
+
+	var emails []string
+	for rows.Next() {
+		var email string
+		assert.T(t, rows.Scan(&email) == nil)
+		emails = append(emails, email)
+	}
+	assert.Equal(t, []string{"aaron@email.com"}, emails)
+
+	assert.T(t, rows.NextResultSet())
+	cols, _ := rows.Columns()
+	assert.Equal(t, []string{"item_id", "price"}, cols)
+	var prices []float64
+	for rows.Next() {
+		var item string
+		var price float64
+		assert.T(t, rows.Scan(&item, &price) == nil)
+		prices = append(prices, price)
+	}
+	assert.Equal(t, []float64{22.5, 37.5}, prices)
+	assert.T(t, !rows.NextResultSet())
+	assert.Tf(t, rows.Err() == nil, "no error: %v", rows.Err())
+}
+
+func TestSqlDriverColumnTypes(t *testing.T) {
+	db, err := sql.Open("qlbridge", "mockcsv")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT user_id, referral_count * 2 AS refs, referral_count / 2 AS half,
+		yy(reg_date) AS yy, contains(email, "aaron") AS isaaron, "x" AS lit, 5 AS five
+		FROM users`)
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	assert.Tf(t, err == nil, "no error: %v", err)
+
+	wantNames := []string{"VARCHAR", "BIGINT", "DOUBLE", "BIGINT", "BOOL", "VARCHAR", "BIGINT"}
+	wantScan := []reflect.Type{reflect.TypeOf(""), reflect.TypeOf(int64(0)), reflect.TypeOf(float64(0)),
+		reflect.TypeOf(int64(0)), reflect.TypeOf(false), reflect.TypeOf(""), reflect.TypeOf(int64(0))}
+	assert.Equal(t, len(wantNames), len(types))
+	for i, ct := range types {
+		assert.Equalf(t, wantNames[i], ct.DatabaseTypeName(), "type of %s", ct.Name())
+		assert.Equalf(t, wantScan[i], ct.ScanType(), "scan type of %s", ct.Name())
+	}
+	for rows.Next() {
+	}
+	assert.Tf(t, rows.Err() == nil, "no error: %v", rows.Err())
+}
+
+func TestSqlDriverCancel(t *testing.T) {
+	src := &endlessSource{StaticDataSource: membtree.NewStaticDataSource("endlessdrv", 0, nil, []string{"id", "n"})}
+	datasource.RegisterSchemaSource("endlessdrv", "endlessdrv", src)
+
+	db, err := sql.Open("qlbridge", "endlessdrv")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rows, err := db.QueryContext(ctx, `SELECT n FROM endlessdrv WHERE n > 5`)
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer rows.Close()
+
+	ct := 0
+	start := time.Now()
+	for rows.Next() {
+		if ct++; ct == 10 {
+			cancel()
+		}
+		assert.Tf(t, time.Since(start) < 5*time.Second, "query was not stopped")
+	}
+	assert.Equal(t, context.Canceled, rows.Err())
+}
+
+func TestSqlDriverTransactions(t *testing.T) {
+	accounts, err := memdb.NewMemDbData("accounts", [][]driver.Value{{int64(1), "aaron"}}, []string{"id", "name"})
+	assert.Tf(t, err == nil, "no error: %v", err)
+	datasource.RegisterSchemaSource("txmemdb", "txmemdb", accounts)
+
+	db, err := sql.Open("qlbridge", "txmemdb")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer db.Close()
+
+	names := func() []string {
+		rows, err := db.Query(`SELECT name FROM accounts`)
+		assert.Tf(t, err == nil, "no error: %v", err)
+		defer rows.Close()
+		var names []string
+		for rows.Next() {
+			var name string
+			assert.T(t, rows.Scan(&name) == nil)
+			names = append(names, name)
+		}
+		return names
+	}
+
+	tx, err := db.Begin()
+	assert.Tf(t, err == nil, "no error: %v", err)
+	_, err = tx.Exec(`INSERT INTO accounts (id, name) VALUES (?, ?)`, 2, "bob")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	_, err = tx.Exec(`INSERT INTO accounts (id, name) VALUES (:id, :name)`, sql.Named("id", 3), sql.Named("name", "carol"))
+	assert.Tf(t, err == nil, "no error: %v", err)
+	// not visible until committed
+	assert.Equal(t, []string{"aaron"}, names())
+	assert.Tf(t, tx.Commit() == nil, "commit")
+	assert.Equal(t, []string{"aaron", "bob", "carol"}, names())
+	assert.Equal(t, sql.ErrTxDone, tx.Rollback())
+
+	tx, err = db.Begin()
+	assert.Tf(t, err == nil, "no error: %v", err)
+	_, err = tx.Exec(`INSERT INTO accounts (id, name) VALUES (?, ?)`, 4, "dan")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	assert.Tf(t, tx.Rollback() == nil, "rollback")
+	assert.Equal(t, []string{"aaron", "bob", "carol"}, names())
+
+	// writes of a transaction must be to transactional sources
+	csvDb, err := sql.Open("qlbridge", "mockcsv")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer csvDb.Close()
+	tx, err = csvDb.Begin()
+	assert.Tf(t, err == nil, "no error: %v", err)
+	_, err = tx.Exec(`INSERT INTO users (user_id, email) VALUES (?, ?)`, "abc", "abc@email.com")
+	assert.Tf(t, err != nil && strings.Contains(err.Error(), "transactions"), "expected error: %v", err)
+	assert.T(t, tx.Rollback() == nil)
+
+	_, err = db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	assert.Tf(t, err != nil, "expected error of isolation level")
+}
@@ -15,6 +15,9 @@ var (
 )
 
 const (
+	// ItemDefaultChannelSize size of the output channel of each task, the number
+	// of messages an operator may get ahead of the operator consuming them
+	// before it blocks.  Over-ride with plan.Context BufferSize(s).
 	ItemDefaultChannelSize = 50
 )
 
@@ -34,17 +37,44 @@ type TaskBase struct {
 	errCh    ErrChan
 	sigCh    SigChan // notify of quit/stop
 	errors   []error
+	metric   *plan.OperatorMetric // nil unless query is analyzed or traced
+	outDone  chan struct{}        // closed once output rows are counted
 }
 
 func NewTaskBase(ctx *plan.Context) *TaskBase {
+	return NewTaskBaseNamed(ctx, "")
+}
+
+// NewTaskBaseNamed task base for an operator of given name (source, where,
+// join, ...) whose output channel is sized per the context buffer config.
+func NewTaskBaseNamed(ctx *plan.Context, name string) *TaskBase {
 	return &TaskBase{
 		// All Tasks Get output channels by default, but NOT input
-		msgOutCh: make(MessageChan, ItemDefaultChannelSize),
+		msgOutCh: make(MessageChan, BufferSize(ctx, name)),
 		sigCh:    make(SigChan, 1),
 		errCh:    make(ErrChan, 10),
 		errors:   make([]error, 0),
 		Ctx:      ctx,
+		Name:     name,
+	}
+}
+
+// BufferSize the size of output channel for named operator, operators
+// block when it is full so this bounds the rows in flight between them.
+//
+//   ctx.BufferSize = 10                       // all operators
+//   ctx.BufferSizes = map[string]int{"source": 1000}  // read-ahead of scans
+func BufferSize(ctx *plan.Context, name string) int {
+	if ctx == nil {
+		return ItemDefaultChannelSize
+	}
+	if size, ok := ctx.BufferSizes[name]; ok && size > 0 {
+		return size
+	}
+	if ctx.BufferSize > 0 {
+		return ctx.BufferSize
 	}
+	return ItemDefaultChannelSize
 }
 
 func (m *TaskBase) Children() []Task { return nil }
@@ -65,15 +95,18 @@ func (m *TaskBase) MessageOutSet(ch MessageChan) { m.msgOutCh = ch }
 func (m *TaskBase) ErrChan() ErrChan             { return m.errCh }
 func (m *TaskBase) SigChan() SigChan             { return m.sigCh }
 func (m *TaskBase) Quit() {
-	if m.hasquit {
+	m.Lock()
+	if m.hasquit || m.closed {
+		m.Unlock()
 		return
 	}
+	m.hasquit = true
+	m.Unlock()
 	defer func() {
 		if r := recover(); r != nil {
 			u.Errorf("Error on closing sigchannel %v", r)
 		}
 	}()
-	m.hasquit = true
 	close(m.sigCh)
 }
 func (m *TaskBase) Close() error {
@@ -89,9 +122,12 @@ func (m *TaskBase) Close() error {
 		return nil
 	}
 	m.closed = true
+	hasquit := m.hasquit
 	m.Unlock()
 	//u.Debugf("%p finished Close()", m)
-	close(m.sigCh)
+	if !hasquit {
+		close(m.sigCh)
+	}
 	return nil
 }
 func (m *TaskBase) CloseFinal() error { return nil }
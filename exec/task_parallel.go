@@ -31,7 +31,7 @@ type TaskParallel struct {
 
 func NewTaskParallel(ctx *plan.Context) *TaskParallel {
 	return &TaskParallel{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "parallel"),
 		runners:  make([]TaskRunner, 0),
 		tasks:    make([]Task, 0),
 	}
@@ -80,6 +80,7 @@ func (m *TaskParallel) Setup(depth int) error {
 	}
 	for i := 0; i < len(m.runners); i++ {
 		//u.Debugf("%d  Setup: %T", depth, m.runners[i])
+		registerMetric(m.runners[i], depth+1)
 		if err := m.runners[i].Setup(depth + 1); err != nil {
 			return err
 		}
@@ -127,6 +128,7 @@ func (m *TaskParallel) Run() error {
 	}
 
 	var wg sync.WaitGroup
+	var runErr firstErr
 
 	// start tasks in reverse order, so that by time
 	// source starts up all downstreams have started
@@ -135,9 +137,9 @@ func (m *TaskParallel) Run() error {
 		go func(taskId int) {
 			task := m.runners[taskId]
 			//u.Infof("starting task %d-%d %T in:%p  out:%p", m.depth, taskId, task, task.MessageIn(), task.MessageOut())
-			if err := task.Run(); err != nil {
+			if err := runTask(task); err != nil {
 				u.Errorf("%T.Run() errored %v", task, err)
-				// TODO:  what do we do with this error?   send to error channel?
+				runErr.set(err)
 			}
 			//u.Debugf("exiting taskId: %v %T", taskId, task)
 			wg.Done()
@@ -146,5 +148,5 @@ func (m *TaskParallel) Run() error {
 
 	wg.Wait()
 
-	return nil
+	return runErr.error()
 }
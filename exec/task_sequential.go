@@ -13,19 +13,22 @@ var (
 	_ = u.EMPTY
 
 	// Ensure that we implement the plan.Tasks
-	_ Task = (*TaskSequential)(nil)
+	_ Task      = (*TaskSequential)(nil)
+	_ BatchTask = (*TaskSequential)(nil)
 )
 
 type TaskSequential struct {
 	*TaskBase
-	closed  bool
-	tasks   []Task
-	runners []TaskRunner
+	closed   bool
+	tasks    []Task
+	runners  []TaskRunner
+	batchIn  bool // input is batches
+	batchOut bool // may send batches, its reader is batch aware
 }
 
 func NewTaskSequential(ctx *plan.Context) *TaskSequential {
 	st := &TaskSequential{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "sequential"),
 		tasks:    make([]Task, 0),
 		runners:  make([]TaskRunner, 0),
 	}
@@ -77,19 +80,58 @@ func (m *TaskSequential) Close() error {
 	return m.TaskBase.Close()
 }
 
+// Batched implements BatchTask, the sequence is batch aware as it puts
+// Unbatch in front of its tasks that aren't
+func (m *TaskSequential) Batched(in bool) bool {
+	m.batchIn, m.batchOut = in, true
+	return m.batches(false)
+}
+
+// batches is the output of the tasks batches.  With shim the tasks are
+// re-written with an Unbatch in front of each task that doesn't take batches
+// but would get them, and at the end if the reader of this sequence doesn't.
+func (m *TaskSequential) batches(shim bool) bool {
+	batched := m.batchIn
+	runners := make([]TaskRunner, 0, len(m.runners))
+	for _, tr := range m.runners {
+		if bt, ok := tr.(BatchTask); ok {
+			batched = bt.Batched(batched)
+		} else if batched {
+			runners = append(runners, NewUnbatch(m.Ctx))
+			batched = false
+		}
+		runners = append(runners, tr)
+	}
+	if !shim {
+		return batched
+	}
+	if batched && !m.batchOut {
+		runners = append(runners, NewUnbatch(m.Ctx))
+		batched = false
+	}
+	m.runners = runners
+	m.tasks = make([]Task, len(runners))
+	for i, tr := range runners {
+		m.tasks[i] = tr
+	}
+	return batched
+}
+
 func (m *TaskSequential) Setup(depth int) error {
 	// We don't need to setup the First(source) Input channel
 	m.depth = depth
 	m.setup = true
+	m.batches(true)
 	for i := 0; i < len(m.runners); i++ {
 		//u.Debugf("%d i:%d  Setup: %T p:%p", depth, i, m.runners[i], m.runners[i])
+		registerMetric(m.runners[i], depth+1)
 		if err := m.runners[i].Setup(depth + 1); err != nil {
 			return err
 		}
 	}
 	//u.Infof("%d  TaskSequential Setup  tasks len=%d", depth, len(m.tasks))
 	for i := 1; i < len(m.runners); i++ {
-		m.runners[i].MessageInSet(m.runners[i-1].MessageOut())
+		connect(m.runners[i-1], m.runners[i])
 		//u.Infof("%d-%d setup msgin: %T  %p", depth, i, m.runners[i], m.runners[i].MessageIn())
 	}
 	if depth > 0 {
@@ -148,7 +190,7 @@ func (m *TaskSequential) Run() (err error) {
 		go func(taskId int) {
 			task := m.runners[taskId]
 			//u.Infof("starting task %d-%d %T in:%p  out:%p", m.depth, taskId, task, task.MessageIn(), task.MessageOut())
-			if taskErr := task.Run(); taskErr != nil {
+			if taskErr := runTask(task); taskErr != nil {
 				u.Errorf("%T.Run() errored %v", task, taskErr)
 				// TODO:  what do we do with this error?   send to error channel?
 				err = taskErr
@@ -0,0 +1,127 @@
+package exec_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// countingSource counts the rows read from it
+type countingSource struct {
+	*membtree.StaticDataSource
+	scanned int64
+}
+
+func (m *countingSource) Open(name string) (schema.Conn, error) { return m, nil }
+func (m *countingSource) Next() schema.Message {
+	msg := m.StaticDataSource.Next()
+	if msg != nil {
+		atomic.AddInt64(&m.scanned, 1)
+	}
+	return msg
+}
+
+var numbers = func() *countingSource {
+	rows := make([][]driver.Value, 10000)
+	for i := range rows {
+		rows[i] = []driver.Value{fmt.Sprintf("%05d", i), int64(i), fmt.Sprintf("g%02d", i%100)}
+	}
+	src := &countingSource{StaticDataSource: membtree.NewStaticDataSource("numbers", 0, rows, []string{"id", "n", "g"})}
+	datasource.RegisterSchemaSource("counting", "counting", src)
+	return src
+}()
+
+func TestBufferSize(t *testing.T) {
+	ctx := plan.NewContext("")
+	assert.Equal(t, exec.ItemDefaultChannelSize, exec.BufferSize(ctx, "where"))
+	ctx.BufferSize = 10
+	ctx.BufferSizes = map[string]int{"source": 100}
+	assert.Equal(t, 10, exec.BufferSize(ctx, "where"))
+	assert.Equal(t, 100, exec.BufferSize(ctx, "source"))
+	assert.Equal(t, 100, cap(exec.NewTaskBaseNamed(ctx, "source").MessageOut()))
+}
+
+func TestStreamingBackpressure(t *testing.T) {
+	ctx := plan.NewContext(`SELECT id, n FROM numbers WHERE n >= 0`)
+	ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("counting")
+	ctx.BufferSize = 4
+	atomic.StoreInt64(&numbers.scanned, 0)
+
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	rows := exec.NewResultRows(ctx, []string{"id", "n"})
+	job.RootTask.Add(rows)
+	assert.T(t, job.Setup() == nil)
+	go job.Run()
+	defer job.Close()
+
+	dest := make([]driver.Value, 2)
+	assert.T(t, rows.Next(dest) == nil)
+
+	// the consumer has read one row, the scan may only get as far ahead of
+	// it as the buffers between operators allow
+	time.Sleep(50 * time.Millisecond)
+	scanned := atomic.LoadInt64(&numbers.scanned)
+	assert.Tf(t, scanned > 1 && scanned < 40, "expected scan to block on full buffers got %d", scanned)
+
+	read := 1
+	for ; rows.Next(dest) == nil; read++ {
+	}
+	assert.Equal(t, 10000, read)
+}
+
+// failingTask reads a single message then errors
+type failingTask struct {
+	*exec.TaskBase
+}
+
+func (m *failingTask) Run() error {
+	defer close(m.MessageOut())
+	<-m.MessageIn()
+	return fmt.Errorf("fragment failed")
+}
+
+func TestExchangeFragmentError(t *testing.T) {
+	ctx := plan.NewContext("")
+	ctx.DisableRecover = true
+
+	passthru := exec.NewTaskBase(ctx)
+	passthru.Handler = exec.MakeHandler(passthru)
+	ok := exec.NewTaskSequential(ctx)
+	ok.Add(passthru)
+	failing := exec.NewTaskSequential(ctx)
+	failing.Add(&failingTask{exec.NewTaskBase(ctx)})
+
+	ex := exec.NewExchange(ctx, []*exec.TaskSequential{ok, failing})
+	in := make(exec.MessageChan)
+	ex.MessageInSet(in)
+	assert.T(t, ex.Setup(0) == nil)
+	go func() {
+		defer close(in)
+		for i := 0; i < 1000; i++ {
+			select {
+			case in <- datasource.NewSqlDriverMessageMap(uint64(i), []driver.Value{int64(i)}, nil):
+			case <-ex.SigChan():
+				return
+			}
+		}
+	}()
+	go func() {
+		for range ex.MessageOut() {
+		}
+	}()
+
+	err := ex.Run()
+	assert.Tf(t, err != nil, "expected the fragment error")
+	assert.Equal(t, "fragment failed", err.Error())
+}
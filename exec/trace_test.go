@@ -0,0 +1,154 @@
+package exec_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"golang.org/x/net/context"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+type traceKey struct{}
+type spanKey struct{}
+
+// testTracer records the spans started, the request they belong to and
+// their parent span
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+type testSpan struct {
+	name    string
+	request interface{}
+	parent  interface{}
+	attrs   map[string]interface{}
+	err     error
+	ended   bool
+}
+
+func (m *testTracer) StartSpan(ctx context.Context, name string) (context.Context, plan.Span) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	span := &testSpan{name: name, request: ctx.Value(traceKey{}), parent: ctx.Value(spanKey{}), attrs: make(map[string]interface{})}
+	m.spans = append(m.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (m *testTracer) span(name string) *testSpan {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, span := range m.spans {
+		if span.name == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func (m *testSpan) SetAttribute(key string, value interface{}) { m.attrs[key] = value }
+func (m *testSpan) RecordError(err error)                      { m.err = err }
+func (m *testSpan) End()                                       { m.ended = true }
+
+// tracedSource records the span of the Context given to its scans
+type tracedSource struct {
+	*scanSource
+	mu    sync.Mutex
+	spans []interface{}
+}
+
+type tracedConn struct {
+	*membtree.StaticDataSource
+	source *tracedSource
+}
+
+func (m *tracedSource) Open(name string) (schema.Conn, error) {
+	conn, err := m.scanSource.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{conn.(*membtree.StaticDataSource), m}, nil
+}
+
+func (m *tracedConn) SetContext(ctx *plan.Context) {
+	m.source.mu.Lock()
+	defer m.source.mu.Unlock()
+	m.source.spans = append(m.source.spans, ctx.Context.Value(spanKey{}))
+}
+
+func TestTrace(t *testing.T) {
+	ss, err := scanSchema.Source("numbers")
+	assert.Tf(t, err == nil, "no error %v", err)
+	src := &tracedSource{scanSource: ss.DS.(*scanSource)}
+	traced := datasource.RegisterSchemaSource("traced", "traced", src)
+
+	sql := `SELECT g, count(*) AS ct FROM numbers WHERE n < 500 GROUP BY g`
+	tracer := &testTracer{}
+	ctx := plan.NewContext(sql)
+	ctx.Schema = traced
+	ctx.Context = context.WithValue(context.Background(), traceKey{}, "request-1")
+	ctx.Tracer = tracer
+
+	job, err := exec.BuildSqlJob(ctx)
+	assert.Tf(t, err == nil, "no error %v", err)
+	msgs := make([]schema.Message, 0)
+	job.RootTask.Add(exec.NewResultBuffer(ctx, &msgs))
+	assert.T(t, job.Setup() == nil)
+	assert.T(t, job.Run() == nil)
+	job.Close()
+	assert.Equal(t, 100, len(msgs))
+
+	tests := []struct {
+		span    string
+		rowsIn  int64
+		rowsOut int64
+	}{
+		{"exec.source", 0, 10000},
+		{"exec.where", 10000, 500},
+		{"exec.groupby", 500, 100},
+	}
+	for _, tt := range tests {
+		found := false
+		for _, span := range tracer.spans {
+			if span.name == tt.span && span.attrs["rows_in"] == tt.rowsIn && span.attrs["rows_out"] == tt.rowsOut {
+				found = true
+			}
+		}
+		assert.Tf(t, found, "expected span %s rows in %d out %d", tt.span, tt.rowsIn, tt.rowsOut)
+	}
+	assert.Equal(t, "numbers", tracer.span("exec.source").attrs["source"])
+	for _, name := range []string{"query", "parse", "plan"} {
+		assert.Tf(t, tracer.span(name) != nil, "expected span %s", name)
+	}
+	// parse, plan and exec spans are children of the query span, as are
+	// the calls of the source
+	query := tracer.span("query")
+	assert.Equal(t, nil, query.parent)
+	for _, span := range tracer.spans {
+		assert.Tf(t, span.ended, "span %s ended", span.name)
+		assert.Equalf(t, "request-1", span.request, "span %s of request", span.name)
+		assert.Equalf(t, nil, span.err, "span %s error", span.name)
+		if span != query {
+			assert.Tf(t, span.parent == query, "span %s child of query", span.name)
+		}
+	}
+	assert.Equal(t, 1, len(src.spans))
+	assert.Tf(t, src.spans[0] == query, "source given context of query span")
+
+	// parse errors are recorded on the parse span
+	tracer = &testTracer{}
+	ctx = plan.NewContext(`SELEKT n FROM numbers`)
+	ctx.Schema = scanSchema
+	ctx.Tracer = tracer
+	_, err = exec.BuildSqlJob(ctx)
+	assert.T(t, err != nil)
+	assert.Equal(t, err, tracer.span("parse").err)
+	assert.Equal(t, err, tracer.span("query").err)
+	assert.T(t, tracer.span("query").ended)
+}
@@ -0,0 +1,61 @@
+package exec_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+)
+
+func TestExecViews(t *testing.T) {
+
+	src := membtree.NewSource()
+	src.AddTable(membtree.NewStaticDataSource("orders", 0, [][]driver.Value{
+		{int64(1), "bob", int64(5)},
+		{int64(2), "alice", int64(20)},
+		{int64(3), "bob", int64(30)},
+	}, []string{"order_id", "user", "qty"}))
+	s := datasource.RegisterSchemaSource("viewdb", "viewdb", src)
+
+	for name, sql := range map[string]string{
+		"big_orders": `SELECT order_id, user, qty FROM orders WHERE qty > 10`,
+		"bob_orders": `SELECT order_id, qty FROM big_orders WHERE user = "bob"`,
+	} {
+		stmt, err := rel.ParseSqlSelect(sql)
+		assert.Tf(t, err == nil, "err=%v", err)
+		s.AddView(schema.NewView(name, stmt))
+	}
+
+	sqlDb, err := sql.Open("qlbridge", "viewdb")
+	assert.Tf(t, err == nil, "no error: %v", err)
+	defer sqlDb.Close()
+
+	rows, err := sqlDb.Query(`show full tables`)
+	assert.Tf(t, err == nil, "error: %v", err)
+	tables := make([][]string, 0)
+	for rows.Next() {
+		var name, typ string
+		assert.T(t, rows.Scan(&name, &typ) == nil)
+		tables = append(tables, []string{name, typ})
+	}
+	rows.Close()
+	assert.Equal(t, [][]string{{"orders", "BASE TABLE"}, {"big_orders", "VIEW"}, {"bob_orders", "VIEW"}}, tables)
+
+	// views of views expanded
+	rows, err = sqlDb.Query(`SELECT order_id FROM bob_orders`)
+	assert.Tf(t, err == nil, "error: %v", err)
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		assert.T(t, rows.Scan(&id) == nil)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	assert.Equal(t, []int64{3}, ids)
+}
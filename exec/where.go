@@ -30,7 +30,7 @@ func NewWhere(ctx *plan.Context, p *plan.Where) *Where {
 
 func NewWhereFinal(ctx *plan.Context, p *plan.Where) *Where {
 	s := &Where{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "where"),
 		sel:      p.Stmt,
 		filter:   p.Stmt.Where.Expr,
 	}
@@ -71,7 +71,7 @@ func NewWhereFinal(ctx *plan.Context, p *plan.Where) *Where {
 //  filters vs final differ bc the Final does final column aliasing
 func NewWhereFilter(ctx *plan.Context, sql *rel.SqlSelect) *Where {
 	s := &Where{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "where"),
 		filter:   sql.Where.Expr,
 	}
 	cols := sql.UnAliasedColumns()
@@ -84,18 +84,68 @@ func NewHaving(ctx *plan.Context, p *plan.Having) *Where {
 	// cols map[string]*rel.Column, filter expr.Node
 	// NewHavingFilter(m.Ctx, sp.Stmt.UnAliasedColumns(), sp.Stmt.Having)
 	s := &Where{
-		TaskBase: NewTaskBase(ctx),
+		TaskBase: NewTaskBaseNamed(ctx, "having"),
 		filter:   p.Stmt.Having,
 	}
 	s.Handler = whereFilter(p.Stmt.Having, s, p.Stmt.UnAliasedColumns())
 	return s
 }
 
+// Batched implements BatchTask, a batch is filtered into a batch of the
+// rows that match.
+func (m *Where) Batched(in bool) bool { return in }
+
 func whereFilter(filter expr.Node, task TaskRunner, cols map[string]*rel.Column) MessageHandler {
 	out := task.MessageOut()
+	matches := whereEvaluator(filter, cols)
+	send := func(msg schema.Message) bool {
+		//u.Debugf("about to send from where to forward: %#v", msg)
+		select {
+		case out <- msg:
+			return true
+		case <-task.SigChan():
+			return false
+		}
+	}
+	return func(ctx *plan.Context, msg schema.Message) bool {
+		// the messages of an insert of a select are acked once written, or
+		// filtered out here
+		_, acked := ctx.Stmt.(*rel.SqlInsert)
+		if batch, isBatch := msg.(*RowBatch); isBatch {
+			// rows are filtered in place, batch is ours once received
+			kept := batch.Rows[:0]
+			for _, row := range batch.Rows {
+				keep, _ := matches(row)
+				if keep {
+					kept = append(kept, row)
+				}
+				if acked {
+					ctx.Filtered(row.Id(), keep)
+				}
+			}
+			if len(kept) == 0 {
+				return true
+			}
+			batch.Rows = kept
+			return send(batch)
+		}
+		keep, ok := matches(msg)
+		if acked {
+			ctx.Filtered(msg.Id(), keep)
+		}
+		if !keep {
+			return ok
+		}
+		return send(msg)
+	}
+}
+
+// whereEvaluator evaluates filter for a row, is it kept, and false ok if
+// the filter could not be evaluated.
+func whereEvaluator(filter expr.Node, cols map[string]*rel.Column) func(msg schema.Message) (bool, bool) {
 	evaluator := vm.Evaluator(filter)
 	//u.Debugf("prepare filter %s", filter)
-	return func(ctx *plan.Context, msg schema.Message) bool {
+	return func(msg schema.Message) (bool, bool) {
 
 		var filterValue value.Value
 		var ok bool
@@ -124,28 +174,21 @@ func whereFilter(filter expr.Node, task TaskRunner, cols map[string]*rel.Column)
 		//u.Infof("evaluating: ok?%v  result=%v filter expr: '%s'", ok, filterValue.ToString(), filter.String())
 		if !ok {
 			u.Debugf("could not evaluate: %T %#v", msg, msg)
-			return false
+			return false, false
 		}
 		switch valTyped := filterValue.(type) {
 		case value.BoolValue:
 			if valTyped.Val() == false {
 				//u.Debugf("Filtering out: T:%T   v:%#v", valTyped, valTyped)
-				return true
+				return false, true
 			}
 		case nil:
-			return false
+			return false, false
 		default:
 			if valTyped.Nil() {
-				return false
+				return false, false
 			}
 		}
-
-		//u.Debugf("about to send from where to forward: %#v", msg)
-		select {
-		case out <- msg:
-			return true
-		case <-task.SigChan():
-			return false
-		}
+		return true, true
 	}
 }
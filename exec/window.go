@@ -0,0 +1,438 @@
+package exec
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/value"
+	"github.com/araddon/qlbridge/vm"
+)
+
+var (
+	_ = u.EMPTY
+
+	// Ensure that we implement the Task Runner interface
+	_ TaskRunner = (*Window)(nil)
+)
+
+// Window evaluates the window function columns of a select.  All input rows
+// are held, then for each distinct window the rows are partitioned on the
+// PARTITION BY keys, sorted within partition on the ORDER BY keys, and each
+// window function evaluated over the frame of each row.  Rows are emitted
+// (partitioned and sorted by the first window) with the window function
+// values added as columns named by the column's As.
+//
+//   rows -> [ partition ] -> [ sort ] -> [ row_number, rank, lag, sum ... ] ->
+//
+// Supported functions are row_number, rank, dense_rank, lag, lead, and
+// first_value, last_value and the aggregates sum, count, avg over the
+// frame.  The default frame is the start of the
+// partition to the current row (and its peers) if there is an ORDER BY, else
+// the whole partition.  The held rows count against the memory limit of the
+// query, a plan.MemoryQuotaError if over it.
+type Window struct {
+	*TaskBase
+	p        *plan.Window
+	complete chan bool
+	closed   bool
+}
+
+// winRow a row being windowed, and its order by keys for current window
+type winRow struct {
+	idx  int
+	msg  *datasource.SqlDriverMessageMap
+	keys []value.Value
+}
+
+// winPartition rows of a window partition, sorted on order by keys
+type winPartition struct {
+	rows   []*winRow
+	invert []bool
+}
+
+// NewWindow create new window function exec task
+func NewWindow(ctx *plan.Context, p *plan.Window) *Window {
+	return &Window{
+		TaskBase: NewTaskBaseNamed(ctx, "window"),
+		p:        p,
+		complete: make(chan bool),
+	}
+}
+
+func (m *Window) Close() error {
+	m.Lock()
+	if m.closed {
+		m.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.Unlock()
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	select {
+	case <-ticker.C:
+		u.Warnf("window timeout???? ")
+	case <-m.complete:
+	}
+
+	return m.TaskBase.Close()
+}
+
+func (m *Window) Run() error {
+	defer m.Ctx.Recover()
+	defer close(m.msgOutCh)
+	defer close(m.complete)
+
+	outCh := m.MessageOut()
+	inCh := m.MessageIn()
+
+	stmtIndex := m.p.Stmt.ColIndexes()
+	rows := make([]*winRow, 0)
+	res := newMemReservation(m.TaskBase, "window")
+	defer res.Release()
+
+msgReadLoop:
+	for {
+		select {
+		case <-m.SigChan():
+			return nil
+		case msg, ok := <-inCh:
+			if !ok {
+				break msgReadLoop
+			}
+			var sdm *datasource.SqlDriverMessageMap
+			switch mt := msg.(type) {
+			case *datasource.SqlDriverMessageMap:
+				sdm = mt
+			default:
+				msgReader, isContextReader := msg.(expr.ContextReader)
+				if !isContextReader {
+					err := fmt.Errorf("To use Window must use SqlDriverMessageMap but got %T", msg)
+					u.Errorf("unrecognized msg %T", msg)
+					close(m.TaskBase.sigCh)
+					return err
+				}
+				sdm = datasource.NewSqlDriverMessageMapCtx(msg.Id(), msgReader, stmtIndex)
+			}
+			if err := res.Grow(rowSize(sdm.Vals)); err != nil {
+				u.Errorf("could not hold window rows: %v", err)
+				close(m.TaskBase.sigCh)
+				return err
+			}
+			rows = append(rows, &winRow{idx: len(rows), msg: sdm})
+		}
+	}
+
+	cols := make([]*rel.Column, 0)
+	for _, col := range m.p.Stmt.Columns {
+		if col.Over != nil {
+			cols = append(cols, col)
+		}
+	}
+
+	// columns that share a window are evaluated on the same partitions
+	results := make([][]driver.Value, len(rows))
+	for i := range results {
+		results[i] = make([]driver.Value, len(cols))
+	}
+	var order [][]*winRow
+	done := make(map[int]bool)
+	for ci, col := range cols {
+		if done[ci] {
+			continue
+		}
+		parts := windowPartitions(col.Over, rows)
+		if order == nil {
+			order = parts
+		}
+		for cj := ci; cj < len(cols); cj++ {
+			if done[cj] || !cols[cj].Over.Equal(col.Over) {
+				continue
+			}
+			done[cj] = true
+			for _, part := range parts {
+				if err := evalWindow(cols[cj], part, results, cj); err != nil {
+					u.Errorf("could not evaluate window function %s: %v", cols[cj], err)
+					close(m.TaskBase.sigCh)
+					return err
+				}
+			}
+		}
+	}
+
+	var colIndex map[string]int
+	for _, part := range order {
+		for _, row := range part {
+			if colIndex == nil {
+				colIndex = make(map[string]int, len(row.msg.ColIndex)+len(cols))
+				for k, v := range row.msg.ColIndex {
+					colIndex[k] = v
+				}
+				for i, col := range cols {
+					colIndex[col.As] = len(row.msg.Vals) + i
+				}
+			}
+			vals := make([]driver.Value, len(row.msg.Vals), len(row.msg.Vals)+len(cols))
+			copy(vals, row.msg.Vals)
+			vals = append(vals, results[row.idx]...)
+			select {
+			case outCh <- datasource.NewSqlDriverMessageMap(row.msg.IdVal, vals, colIndex):
+			case <-m.SigChan():
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// windowPartitions the rows partitioned on partition by keys, in order of
+// first row of each partition, each sorted on order by keys
+func windowPartitions(w *rel.Window, rows []*winRow) [][]*winRow {
+	invert := make([]bool, len(w.OrderBy))
+	for i, col := range w.OrderBy {
+		invert[i] = strings.ToLower(col.Order) == "desc"
+	}
+	parts := make([][]*winRow, 0)
+	partIdx := make(map[string]int)
+	for _, r := range rows {
+		row := &winRow{idx: r.idx, msg: r.msg, keys: make([]value.Value, len(w.OrderBy))}
+		for i, col := range w.OrderBy {
+			if v, ok := vm.Eval(row.msg, col.Expr); ok {
+				row.keys[i] = v
+			}
+		}
+		key := partitionKey(w, row.msg)
+		i, ok := partIdx[key]
+		if !ok {
+			i = len(parts)
+			partIdx[key] = i
+			parts = append(parts, nil)
+		}
+		parts[i] = append(parts[i], row)
+	}
+	for _, part := range parts {
+		sort.Stable(&winPartition{rows: part, invert: invert})
+	}
+	return parts
+}
+
+func partitionKey(w *rel.Window, msg *datasource.SqlDriverMessageMap) string {
+	if len(w.PartitionBy) == 0 {
+		return ""
+	}
+	keys := make([]string, len(w.PartitionBy))
+	for i, n := range w.PartitionBy {
+		v, ok := vm.Eval(msg, n)
+		if !ok || v == nil || v.Nil() {
+			keys[i] = "\x00"
+			continue
+		}
+		keys[i] = v.ToString()
+	}
+	return strings.Join(keys, "\x01")
+}
+
+func (m *winPartition) Len() int      { return len(m.rows) }
+func (m *winPartition) Swap(i, j int) { m.rows[i], m.rows[j] = m.rows[j], m.rows[i] }
+func (m *winPartition) Less(i, j int) bool {
+	a, b := m.rows[i], m.rows[j]
+	for ki, key := range a.keys {
+		c := compareValues(key, b.keys[ki])
+		if c == 0 {
+			continue
+		}
+		if m.invert[ki] {
+			return c > 0
+		}
+		return c < 0
+	}
+	return false
+}
+
+// peers rows with equal order by keys
+func peers(a, b *winRow) bool {
+	for i, key := range a.keys {
+		if compareValues(key, b.keys[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// evalWindow the window function of col over a sorted partition, results are
+// written to results[row.idx][ci]
+func evalWindow(col *rel.Column, part []*winRow, results [][]driver.Value, ci int) error {
+	fn, ok := col.Expr.(*expr.FuncNode)
+	if !ok {
+		return fmt.Errorf("window column must be a function but got %s", col.Expr)
+	}
+	switch name := strings.ToLower(fn.Name); name {
+	case "row_number":
+		for i, row := range part {
+			results[row.idx][ci] = int64(i + 1)
+		}
+	case "rank":
+		rank := 1
+		for i, row := range part {
+			if i > 0 && !peers(part[i-1], row) {
+				rank = i + 1
+			}
+			results[row.idx][ci] = int64(rank)
+		}
+	case "dense_rank":
+		rank := 1
+		for i, row := range part {
+			if i > 0 && !peers(part[i-1], row) {
+				rank++
+			}
+			results[row.idx][ci] = int64(rank)
+		}
+	case "lag", "lead":
+		if len(fn.Args) == 0 {
+			return fmt.Errorf("%s requires an expression", name)
+		}
+		for i, row := range part {
+			offset := 1
+			if len(fn.Args) > 1 {
+				if v, ok := vm.Eval(row.msg, fn.Args[1]); ok {
+					if iv, ok := value.ValueToInt64(v); ok {
+						offset = int(iv)
+					}
+				}
+			}
+			if name == "lag" {
+				offset = -offset
+			}
+			var v value.Value
+			if j := i + offset; j >= 0 && j < len(part) {
+				v, _ = vm.Eval(part[j].msg, fn.Args[0])
+			} else if len(fn.Args) > 2 {
+				v, _ = vm.Eval(row.msg, fn.Args[2])
+			}
+			if v != nil && !v.Nil() {
+				results[row.idx][ci] = v.Value()
+			}
+		}
+	case "first_value", "last_value":
+		if len(fn.Args) != 1 {
+			return fmt.Errorf("%s requires one expression", name)
+		}
+		for i, row := range part {
+			lo, hi := windowFrame(col.Over, part, i)
+			if hi < lo {
+				continue
+			}
+			j := lo
+			if name == "last_value" {
+				j = hi
+			}
+			if v, ok := vm.Eval(part[j].msg, fn.Args[0]); ok && v != nil && !v.Nil() {
+				results[row.idx][ci] = v.Value()
+			}
+		}
+	case "sum", "count", "avg":
+		return evalWindowAgg(name, fn, col.Over, part, results, ci)
+	default:
+		return fmt.Errorf("unsupported window function %s", fn.Name)
+	}
+	return nil
+}
+
+// evalWindowAgg an aggregate over the frame of each row.  Frames are a
+// contiguous range of the partition so running totals of count and sum give
+// the aggregate of any frame.
+func evalWindowAgg(name string, fn *expr.FuncNode, w *rel.Window, part []*winRow, results [][]driver.Value, ci int) error {
+	if len(fn.Args) != 1 {
+		return fmt.Errorf("%s requires one expression", name)
+	}
+	countAll := fn.Args[0].String() == "*"
+	cts := make([]int64, len(part)+1)
+	sums := make([]float64, len(part)+1)
+	for i, row := range part {
+		cts[i+1], sums[i+1] = cts[i], sums[i]
+		if countAll {
+			cts[i+1]++
+			continue
+		}
+		v, ok := vm.Eval(row.msg, fn.Args[0])
+		if !ok || v == nil || v.Nil() {
+			continue
+		}
+		cts[i+1]++
+		if fv, ok := value.ValueToFloat64(v); ok {
+			sums[i+1] += fv
+		}
+	}
+	for i, row := range part {
+		lo, hi := windowFrame(w, part, i)
+		ct, sum := cts[hi+1]-cts[lo], sums[hi+1]-sums[lo]
+		switch name {
+		case "count":
+			results[row.idx][ci] = ct
+		case "sum":
+			if ct > 0 {
+				results[row.idx][ci] = sum
+			}
+		case "avg":
+			if ct > 0 {
+				results[row.idx][ci] = sum / float64(ct)
+			}
+		}
+	}
+	return nil
+}
+
+// windowFrame the first and last row (inclusive) of frame for row i, hi < lo
+// for an empty frame
+func windowFrame(w *rel.Window, part []*winRow, i int) (int, int) {
+	frame := w.Frame
+	if frame == nil {
+		if len(w.OrderBy) == 0 {
+			return 0, len(part) - 1
+		}
+		frame = &rel.WindowFrame{Range: true, Start: rel.WindowBound{Unbounded: true, Offset: -1}}
+	}
+	var lo, hi int
+	switch {
+	case frame.Start.Unbounded:
+		lo = 0
+	case frame.Range:
+		// current row, from its first peer
+		for lo = i; lo > 0 && peers(part[lo-1], part[i]); lo-- {
+		}
+	default:
+		lo = i + frame.Start.Offset
+	}
+	switch {
+	case frame.End.Unbounded:
+		hi = len(part) - 1
+	case frame.Range:
+		// current row, through its last peer
+		for hi = i; hi < len(part)-1 && peers(part[hi+1], part[i]); hi++ {
+		}
+	default:
+		hi = i + frame.End.Offset
+	}
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > len(part)-1 {
+		hi = len(part) - 1
+	}
+	if lo > hi {
+		return 0, -1
+	}
+	return lo, hi
+}
@@ -0,0 +1,128 @@
+package exec_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/exec"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+)
+
+func TestWindow(t *testing.T) {
+	// n < 300 has 3 rows in each group g, n = i, i+100, i+200.  want is the
+	// window value of those rows of group i
+	tests := []struct {
+		col  string
+		want func(i int64) string
+	}{
+		{`row_number() OVER (PARTITION BY g ORDER BY n)`,
+			func(i int64) string { return "1,2,3" }},
+		{`row_number() OVER (PARTITION BY g ORDER BY n DESC)`,
+			func(i int64) string { return "3,2,1" }},
+		// all rows of a group are peers
+		{`rank() OVER (PARTITION BY g ORDER BY g)`,
+			func(i int64) string { return "1,1,1" }},
+		{`rank() OVER (ORDER BY g)`,
+			func(i int64) string { r := 3*i + 1; return fmt.Sprintf("%d,%d,%d", r, r, r) }},
+		{`dense_rank() OVER (ORDER BY g)`,
+			func(i int64) string { r := i + 1; return fmt.Sprintf("%d,%d,%d", r, r, r) }},
+		{`dense_rank() OVER (PARTITION BY g ORDER BY n DESC)`,
+			func(i int64) string { return "3,2,1" }},
+		{`lag(n) OVER (PARTITION BY g ORDER BY n)`,
+			func(i int64) string { return fmt.Sprintf("<nil>,%d,%d", i, i+100) }},
+		{`lag(n, 2, 0) OVER (PARTITION BY g ORDER BY n)`,
+			func(i int64) string { return fmt.Sprintf("0,0,%d", i) }},
+		{`lead(n) OVER (PARTITION BY g ORDER BY n)`,
+			func(i int64) string { return fmt.Sprintf("%d,%d,<nil>", i+100, i+200) }},
+		// running sum, the default frame
+		{`sum(n) OVER (PARTITION BY g ORDER BY n)`,
+			func(i int64) string { return fmt.Sprintf("%d,%d,%d", i, 2*i+100, 3*i+300) }},
+		// peers of current row are in the default RANGE frame
+		{`count(*) OVER (PARTITION BY g ORDER BY g)`,
+			func(i int64) string { return "3,3,3" }},
+		{`sum(n) OVER (PARTITION BY g)`,
+			func(i int64) string { s := 3*i + 300; return fmt.Sprintf("%d,%d,%d", s, s, s) }},
+		{`sum(n) OVER (PARTITION BY g ORDER BY n ROWS BETWEEN 1 PRECEDING AND CURRENT ROW)`,
+			func(i int64) string { return fmt.Sprintf("%d,%d,%d", i, 2*i+100, 2*i+300) }},
+		{`avg(n) OVER (PARTITION BY g ORDER BY n ROWS BETWEEN CURRENT ROW AND UNBOUNDED FOLLOWING)`,
+			func(i int64) string { return fmt.Sprintf("%d,%d,%d", i+100, i+150, i+200) }},
+		{`count(n) OVER (PARTITION BY g ORDER BY n ROWS BETWEEN 2 FOLLOWING AND 3 FOLLOWING)`,
+			func(i int64) string { return "1,0,0" }},
+		{`first_value(n) OVER (PARTITION BY g ORDER BY n DESC)`,
+			func(i int64) string { return fmt.Sprintf("%d,%d,%d", i+200, i+200, i+200) }},
+		// the default frame ends at the current row
+		{`last_value(n) OVER (PARTITION BY g ORDER BY n)`,
+			func(i int64) string { return fmt.Sprintf("%d,%d,%d", i, i+100, i+200) }},
+		{`last_value(n) OVER (PARTITION BY g ORDER BY n ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING)`,
+			func(i int64) string { return fmt.Sprintf("%d,%d,%d", i+200, i+200, i+200) }},
+		{`first_value(n) OVER (PARTITION BY g ORDER BY n ROWS BETWEEN 1 FOLLOWING AND 1 FOLLOWING)`,
+			func(i int64) string { return fmt.Sprintf("%d,%d,<nil>", i+100, i+200) }},
+	}
+	for _, tt := range tests {
+		sql := fmt.Sprintf(`SELECT n, %s AS w FROM numbers WHERE n < 300`, tt.col)
+		ctx := plan.NewContext(sql)
+		ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("counting")
+
+		job, err := exec.BuildSqlJob(ctx)
+		assert.Tf(t, err == nil, "no error %v for %s", err, sql)
+		rows := exec.NewResultRows(ctx, []string{"n", "w"})
+		job.RootTask.Add(rows)
+		assert.T(t, job.Setup() == nil)
+		go job.Run()
+
+		got := make(map[int64]string)
+		for {
+			// nil window values are not written to dest, so fresh each row
+			dest := make([]driver.Value, 2)
+			if rows.Next(dest) != nil {
+				break
+			}
+			got[dest[0].(int64)] = fmt.Sprintf("%v", dest[1])
+		}
+		job.Close()
+		assert.Equalf(t, 300, len(got), "rows for %s", tt.col)
+		for i := int64(0); i < 100; i++ {
+			row := fmt.Sprintf("%s,%s,%s", got[i], got[i+100], got[i+200])
+			assert.Equalf(t, tt.want(i), row, "%s for group g%02d", tt.col, i)
+		}
+	}
+}
+
+func TestWindowPlan(t *testing.T) {
+	tests := []struct {
+		sql    string
+		window string
+		err    bool
+	}{
+		{`SELECT g, row_number() OVER (PARTITION BY g ORDER BY n DESC ROWS UNBOUNDED PRECEDING) AS rn FROM numbers`,
+			"row_number() OVER (PARTITION BY g ORDER BY n DESC ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) AS rn", false},
+		{`SELECT sum(n) OVER (ORDER BY n RANGE BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) FROM numbers`,
+			"sum(n) OVER (ORDER BY n RANGE BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING)", false},
+		{`SELECT g, count(*), rank() OVER (ORDER BY g) FROM numbers GROUP BY g`, "", true},
+	}
+	for _, tt := range tests {
+		ctx := plan.NewContext(tt.sql)
+		ctx.Schema, _ = datasource.DataSourcesRegistry().Schema("counting")
+		stmt, err := rel.ParseSql(tt.sql)
+		assert.Tf(t, err == nil, "Must parse %s but got %v", tt.sql, err)
+		ctx.Stmt = stmt
+		pln, err := plan.WalkStmt(ctx, stmt, plan.NewPlanner(ctx))
+		if tt.err {
+			assert.Tf(t, err != nil, "expected error for %s", tt.sql)
+			continue
+		}
+		assert.Tf(t, err == nil, "no error %v for %s", err, tt.sql)
+		window := ""
+		for _, step := range plan.ExplainTask(pln) {
+			if step.Task == "window" {
+				window = step.Detail
+			}
+		}
+		assert.Equalf(t, tt.window, window, "window plan for %s", tt.sql)
+	}
+}
@@ -0,0 +1,199 @@
+package builtins
+
+import (
+	"strings"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// Arrays are SliceValue or StringsValue (of url values, split), functions
+// returning arrays keep StringsValue of StringsValue input.
+
+// arrayArg the elements of an array argument, not ok if not an array
+func arrayArg(v value.Value) ([]value.Value, bool) {
+	switch vt := v.(type) {
+	case value.StringsValue:
+		// its SliceValue() is nil of an empty array
+		vals := make([]value.Value, len(vt.Val()))
+		for i, s := range vt.Val() {
+			vals[i] = value.NewStringValue(s)
+		}
+		return vals, true
+	case value.Slice:
+		return vt.SliceValue(), true
+	}
+	return nil, false
+}
+
+// arrayOf an array of vals, a StringsValue if like is
+func arrayOf(like value.Value, vals []value.Value) value.Value {
+	if _, ok := like.(value.StringsValue); ok {
+		strs := make([]string, len(vals))
+		for i, v := range vals {
+			strs[i] = v.ToString()
+		}
+		return value.NewStringsValue(strs)
+	}
+	return value.NewSliceValues(vals)
+}
+
+// array_contains:  does an array have an element equal to a value, NULL
+// if not an array
+//
+//   array_contains(["a","b"], "b")   => true
+//   array_contains(tags, "z")        => false
+//
+func ArrayContainsFunc(ctx expr.EvalContext, arr, item value.Value) (value.BoolValue, bool) {
+	vals, ok := arrayArg(arr)
+	if !ok || isNull(item) {
+		return value.BoolValueFalse, false
+	}
+	for _, v := range vals {
+		if eq, err := value.Equal(v, item); err == nil && eq {
+			return value.BoolValueTrue, true
+		}
+	}
+	return value.BoolValueFalse, true
+}
+
+// array_length:  the number of elements of an array, NULL if not an array
+//
+//   array_length(["a","b"])   => 2
+//
+func ArrayLengthFunc(ctx expr.EvalContext, arr value.Value) (value.IntValue, bool) {
+	vals, ok := arrayArg(arr)
+	if !ok {
+		return value.NewIntNil(), false
+	}
+	return value.NewIntValue(int64(len(vals))), true
+}
+
+// array_join:  the elements of an array joined by a separator, NULL elements
+// skipped unless a replacement for them is given
+//
+//   array_join(["a","b"], "-")              => "a-b"
+//   array_join(["a",null_field], "-", "?")  => "a-?"
+//
+func ArrayJoinFunc(ctx expr.EvalContext, args ...value.Value) (value.StringValue, bool) {
+	if len(args) < 2 || len(args) > 3 {
+		return value.EmptyStringValue, false
+	}
+	vals, ok := arrayArg(args[0])
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	sep, ok := stringArg(args[1])
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	nullAs, replaceNull := "", false
+	if len(args) == 3 {
+		nullAs, replaceNull = stringArg(args[2])
+	}
+	parts := make([]string, 0, len(vals))
+	for _, v := range vals {
+		if isNull(v) {
+			if replaceNull {
+				parts = append(parts, nullAs)
+			}
+			continue
+		}
+		parts = append(parts, v.ToString())
+	}
+	return value.NewStringValue(strings.Join(parts, sep)), true
+}
+
+// array_distinct:  the elements of an array without repeats, in the order
+// of their first occurrence
+//
+//   array_distinct(["b","a","b"])   => ["b","a"]
+//
+func ArrayDistinctFunc(ctx expr.EvalContext, arr value.Value) (value.Value, bool) {
+	vals, ok := arrayArg(arr)
+	if !ok {
+		return nil, false
+	}
+	out := make([]value.Value, 0, len(vals))
+	seen := make(map[string]bool, len(vals))
+	for _, v := range vals {
+		key := "\x00"
+		if !isNull(v) {
+			key = v.Type().String() + ":" + v.ToString()
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, v)
+	}
+	return arrayOf(arr, out), true
+}
+
+// slice:  length elements of an array from start, 1 based and from the end
+// if negative.  Past the end of the array is shortened, NULL of start 0.
+//
+//   slice(["a","b","c","d"], 2, 2)    => ["b","c"]
+//   slice(["a","b","c","d"], -2, 5)   => ["c","d"]
+//
+func SliceFunc(ctx expr.EvalContext, arr, startV, lengthV value.Value) (value.Value, bool) {
+	vals, ok := arrayArg(arr)
+	if !ok {
+		return nil, false
+	}
+	start, ok := value.ValueToInt(startV)
+	if !ok || start == 0 {
+		return nil, false
+	}
+	length, ok := value.ValueToInt(lengthV)
+	if !ok || length < 0 {
+		return nil, false
+	}
+	if start > 0 {
+		start--
+	} else {
+		start += len(vals)
+	}
+	if start < 0 || start >= len(vals) {
+		return arrayOf(arr, nil), true
+	}
+	end := start + length
+	if end > len(vals) {
+		end = len(vals)
+	}
+	return arrayOf(arr, vals[start:end]), true
+}
+
+// flatten:  the elements of an array of arrays in one array, elements not
+// arrays are kept as they are
+//
+//   flatten([["a","b"],["c"]])   => ["a","b","c"]
+//
+func FlattenFunc(ctx expr.EvalContext, arr value.Value) (value.Value, bool) {
+	vals, ok := arrayArg(arr)
+	if !ok {
+		return nil, false
+	}
+	out := make([]value.Value, 0, len(vals))
+	for _, v := range vals {
+		if inner, ok := arrayArg(v); ok {
+			out = append(out, inner...)
+			continue
+		}
+		out = append(out, v)
+	}
+	return arrayOf(arr, out), true
+}
+
+// unnest:  an array as it is.  As a column of a select it expands each row
+// to a row per element, see exec.Projection.
+//
+//   SELECT user_id, unnest(split(interests, ",")) AS interest FROM users
+//
+func UnnestFunc(ctx expr.EvalContext, arr value.Value) (value.Value, bool) {
+	vals, ok := arrayArg(arr)
+	if !ok {
+		return nil, false
+	}
+	return arrayOf(arr, vals), true
+}
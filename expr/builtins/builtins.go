@@ -12,7 +12,6 @@ import (
 	"math"
 	"net/mail"
 	"net/url"
-	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,7 +20,6 @@ import (
 	"github.com/araddon/dateparse"
 	u "github.com/araddon/gou"
 	"github.com/leekchan/timeutil"
-	"github.com/lytics/datemath"
 	"github.com/mb0/glob"
 	"github.com/pborman/uuid"
 
@@ -45,6 +43,16 @@ func LoadAllBuiltins() {
 		expr.AggFuncAdd("count", CountFunc)
 		expr.AggFuncAdd("avg", AvgFunc)
 		expr.AggFuncAdd("sum", SumFunc)
+		expr.AggFuncAdd("approx_count_distinct", ApproxCountDistinctFunc)
+
+		// window functions, evaluated by the window operator
+		expr.WindowFuncAdd("row_number", RowNumberFunc)
+		expr.WindowFuncAdd("rank", RankFunc)
+		expr.WindowFuncAdd("dense_rank", DenseRankFunc)
+		expr.WindowFuncAdd("lag", LagFunc)
+		expr.WindowFuncAdd("lead", LeadFunc)
+		expr.WindowFuncAdd("first_value", FirstValueFunc)
+		expr.WindowFuncAdd("last_value", LastValueFunc)
 
 		// logical
 		expr.FuncAdd("gt", Gt)
@@ -70,23 +78,89 @@ func LoadAllBuiltins() {
 		expr.FuncAdd("todate", ToDate)
 		expr.FuncAdd("seconds", TimeSeconds)
 		expr.FuncAdd("maptime", MapTime)
+		expr.FuncAdd("tumble", Tumble)
+		expr.FuncAdd("timebucket", Tumble)
+		expr.FuncAdd("date_trunc", DateTrunc)
 
 		// String Functions
 		expr.FuncAdd("contains", ContainsFunc)
 		expr.FuncAdd("tolower", Lower)
 		expr.FuncAdd("toint", ToInt)
 		expr.FuncAdd("tonumber", ToNumber)
+		expr.FuncAdd("tofloat", ToNumber)
+		expr.FuncAdd("tobool", ToBoolFunc)
+		expr.FuncAdd("tostring", ToStringFunc)
 		expr.FuncAdd("uuid", UuidGenerate)
+		expr.FuncAdd("uuid_v7", UuidV7Generate)
+		expr.FuncAdd("is_uuid", IsUuidFunc)
+		expr.FuncAdd("uuid_to_bin", UuidToBinFunc)
+		expr.FuncAdd("bin_to_uuid", BinToUuidFunc)
 		expr.FuncAdd("split", SplitFunc)
 		expr.FuncAdd("replace", Replace)
 		expr.FuncAdd("join", JoinFunc)
 		expr.FuncAdd("hassuffix", HasSuffix)
 		expr.FuncAdd("hasprefix", HasPrefix)
+		expr.FuncAdd("lower", Lower)
+		expr.FuncAdd("lcase", Lower)
+		expr.FuncAdd("upper", Upper)
+		expr.FuncAdd("ucase", Upper)
+		expr.FuncAdd("toupper", Upper)
+		expr.FuncAdd("substr", SubstrFunc)
+		expr.FuncAdd("substring", SubstrFunc)
+		expr.FuncAdd("left", LeftFunc)
+		expr.FuncAdd("right", RightFunc)
+		expr.FuncAdd("trim", TrimFunc)
+		expr.FuncAdd("ltrim", LtrimFunc)
+		expr.FuncAdd("rtrim", RtrimFunc)
+		expr.FuncAdd("lpad", LpadFunc)
+		expr.FuncAdd("rpad", RpadFunc)
+		expr.FuncAdd("reverse", ReverseFunc)
+		expr.FuncAdd("repeat", RepeatFunc)
+		expr.FuncAdd("instr", InstrFunc)
+		expr.FuncAdd("locate", LocateFunc)
+		expr.FuncAdd("position", LocateFunc)
+		expr.FuncAdd("format", FormatFunc)
+
+		// regular expressions
+		expr.FuncAdd("regexp_match", RegexpMatchFunc)
+		expr.FuncAdd("regexp_like", RegexpMatchFunc)
+		expr.FuncAdd("regexp_extract", RegexpExtractFunc)
+		expr.FuncAdd("regexp_replace", RegexpReplaceFunc)
+
+		// json
+		expr.FuncAdd("json_extract", JsonExtractFunc)
+		expr.FuncAdd("json_unquote", JsonUnquoteFunc)
+		expr.FuncAdd("json_set", JsonSetFunc)
+		expr.FuncAdd("json_length", JsonLengthFunc)
+		expr.FuncAdd("json_array_length", JsonArrayLengthFunc)
+		expr.FuncAdd("json_keys", JsonKeysFunc)
 
 		// array, string
 		expr.FuncAdd("len", LengthFunc)
 		expr.FuncAdd("array.index", ArrayIndex)
 		expr.FuncAdd("array.slice", ArraySlice)
+		expr.FuncAdd("array_contains", ArrayContainsFunc)
+		expr.FuncAdd("array_length", ArrayLengthFunc)
+		expr.FuncAdd("array_join", ArrayJoinFunc)
+		expr.FuncAdd("array_distinct", ArrayDistinctFunc)
+		expr.FuncAdd("slice", SliceFunc)
+		expr.FuncAdd("flatten", FlattenFunc)
+		expr.FuncAdd("unnest", UnnestFunc)
+
+		// geo
+		expr.FuncAdd("haversine", HaversineFunc)
+		expr.FuncAdd("st_contains", StContainsFunc)
+		expr.FuncAdd("in_bbox", InBboxFunc)
+		expr.FuncAdd("geohash_encode", GeohashEncodeFunc)
+		expr.FuncAdd("geohash_decode", GeohashDecodeFunc)
+
+		// conditional
+		expr.FuncAdd("coalesce", CoalesceFunc)
+		expr.FuncAdd("ifnull", IfNullFunc)
+		expr.FuncAdd("nullif", NullIfFunc)
+		expr.FuncAdd("if", IfFunc)
+		expr.FuncAdd("greatest", GreatestFunc)
+		expr.FuncAdd("least", LeastFunc)
 
 		// selection
 		expr.FuncAdd("oneof", OneOfFunc)
@@ -94,6 +168,11 @@ func LoadAllBuiltins() {
 		expr.FuncAdd("mapkeys", MapKeys)
 		expr.FuncAdd("mapvalues", MapValues)
 		expr.FuncAdd("mapinvert", MapInvert)
+		expr.FuncAdd("map_keys", MapKeysFunc)
+		expr.FuncAdd("map_values", MapValuesFunc)
+		expr.FuncAdd("map_get", MapGetFunc)
+		expr.FuncAdd("has_key", HasKeyFunc)
+		expr.FuncAdd("map_filter", MapFilterFunc)
 		expr.FuncAdd("any", AnyFunc)
 		expr.FuncAdd("all", AllFunc)
 		expr.FuncAdd("filter", FilterFunc)
@@ -111,17 +190,51 @@ func LoadAllBuiltins() {
 		expr.FuncAdd("urlmain", UrlMain)
 		expr.FuncAdd("urlminusqs", UrlMinusQs)
 		expr.FuncAdd("urldecode", UrlDecode)
+		expr.FuncAdd("urlencode", UrlEncode)
+		expr.FuncAdd("urlhost", UrlHostFunc)
+		expr.FuncAdd("urlpath", UrlPathFunc)
+		expr.FuncAdd("urlparam", UrlParamFunc)
+		expr.FuncAdd("urldecodequery", UrlDecodeQueryFunc)
+		expr.FuncAdd("useragent", UserAgentFunc)
 		expr.FuncAdd("extract", TimeExtractFunc)
 
+		// ip addresses
+		expr.FuncAdd("inet_aton", InetAtonFunc)
+		expr.FuncAdd("inet_ntoa", InetNtoaFunc)
+		expr.FuncAdd("ip_in_cidr", IpInCidrFunc)
+		expr.FuncAdd("is_private_ip", IsPrivateIpFunc)
+		expr.FuncAdd("ip_normalize", IpNormalizeFunc)
+
+		// encoding
+		expr.FuncAdd("to_base64", ToBase64Func)
+		expr.FuncAdd("from_base64", FromBase64Func)
+		expr.FuncAdd("hex", HexFunc)
+		expr.FuncAdd("unhex", UnhexFunc)
+		expr.FuncAdd("quote", QuoteFunc)
+		expr.FuncAdd("escape", EscapeFunc)
+
 		// Hashing functions
 		expr.FuncAdd("hash.md5", HashMd5Func)
 		expr.FuncAdd("hash.sha1", HashSha1Func)
 		expr.FuncAdd("hash.sha256", HashSha256Func)
 		expr.FuncAdd("hash.sha512", HashSha512Func)
+		expr.FuncAdd("md5", HashMd5Func)
+		expr.FuncAdd("sha1", HashSha1Func)
+		expr.FuncAdd("sha256", HashSha256Func)
+		expr.FuncAdd("sha512", HashSha512Func)
+		expr.FuncAdd("hash", Fnv64Func)
+		expr.FuncAdd("fnv64", Fnv64Func)
+		expr.FuncAdd("crc32", Crc32Func)
+		expr.FuncAdd("murmur3", Murmur3Func)
+		expr.FuncAdd("hmac_sha256", HmacSha256Func)
+		expr.FuncAdd("tokenize", TokenizeFunc)
 
 		// MySQL Builtins
 		expr.FuncAdd("cast", CastFunc)
-		expr.FuncAdd("char_length", LengthFunc)
+		expr.FuncAdd("char_length", CharLengthFunc)
+		expr.FuncAdd("character_length", CharLengthFunc)
+
+		describeBuiltins()
 	})
 }
 
@@ -235,6 +348,86 @@ func CountFunc(ctx expr.EvalContext, val value.Value) (value.IntValue, bool) {
 	return value.NewIntValue(1), true
 }
 
+// approx_count_distinct:  aggregate, the estimated number of distinct non
+// null values, within a couple of percent, of a fixed size sketch per group
+// where count(DISTINCT x) holds every value.  Of a single row it is 1.
+//
+//      approx_count_distinct(user_id)   => 10023
+//
+func ApproxCountDistinctFunc(ctx expr.EvalContext, val value.Value) (value.IntValue, bool) {
+	if val.Err() || val.Nil() {
+		return value.NewIntValue(0), false
+	}
+	return value.NewIntValue(1), true
+}
+
+// row_number:  window function, the 1 based position of row in its window
+// partition.  Only has a value when used with OVER, the window operator
+// computes it across the rows of a partition.
+//
+//   row_number() OVER (PARTITION BY user_id ORDER BY created) => 1, 2, 3 ...
+//
+func RowNumberFunc(ctx expr.EvalContext) (value.IntValue, bool) {
+	return value.NewIntValue(0), false
+}
+
+// rank:  window function, the 1 based position of row in its window
+// partition where rows with equal order by keys share a rank and leave gaps
+//
+//   rank() OVER (ORDER BY score DESC) => 1, 2, 2, 4 ...
+//
+func RankFunc(ctx expr.EvalContext) (value.IntValue, bool) {
+	return value.NewIntValue(0), false
+}
+
+// dense_rank:  window function, as rank but without gaps after rows of equal
+// order by keys
+//
+//   dense_rank() OVER (ORDER BY score DESC) => 1, 2, 2, 3 ...
+//
+func DenseRankFunc(ctx expr.EvalContext) (value.IntValue, bool) {
+	return value.NewIntValue(0), false
+}
+
+// lag:  window function, value of expression from the row offset (default 1)
+// rows before this one in its window partition, or default (nil) if none
+//
+//   lag(price) OVER (ORDER BY day)         => price of previous day
+//   lag(price, 7, 0) OVER (ORDER BY day)   => price a week ago, or 0
+//
+func LagFunc(ctx expr.EvalContext, val value.Value, args ...value.Value) (value.Value, bool) {
+	return value.NilValueVal, false
+}
+
+// lead:  window function, value of expression from the row offset (default 1)
+// rows after this one in its window partition, or default (nil) if none
+//
+//   lead(price) OVER (ORDER BY day)   => price of next day
+//
+func LeadFunc(ctx expr.EvalContext, val value.Value, args ...value.Value) (value.Value, bool) {
+	return value.NilValueVal, false
+}
+
+// first_value:  window function, value of expression from the first row of
+// the window frame of this row
+//
+//   first_value(price) OVER (PARTITION BY item ORDER BY day)   => opening price
+//
+func FirstValueFunc(ctx expr.EvalContext, val value.Value) (value.Value, bool) {
+	return value.NilValueVal, false
+}
+
+// last_value:  window function, value of expression from the last row of the
+// window frame of this row.  The default frame ends at the current row (and
+// its peers) so use a frame to the end of the partition for its last row.
+//
+//   last_value(price) OVER (PARTITION BY item ORDER BY day
+//       ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING)   => closing price
+//
+func LastValueFunc(ctx expr.EvalContext, val value.Value) (value.Value, bool) {
+	return value.NilValueVal, false
+}
+
 // Sqrt
 //
 //      sqrt(4)            =>  2, true
@@ -689,7 +882,7 @@ func MapInvert(ctx expr.EvalContext, items ...value.Value) (value.Value, bool) {
 	return value.NewMapStringValue(mv), true
 }
 
-// uuid generates a uuid
+// uuid generates a (version 4, random) uuid
 //
 func UuidGenerate(ctx expr.EvalContext) (value.StringValue, bool) {
 	return value.NewStringValue(uuid.New()), true
@@ -1090,44 +1283,6 @@ func CastFunc(ctx expr.EvalContext, items ...value.Value) (value.Value, bool) {
 	return val, true
 }
 
-// Convert to Integer:   Best attempt at converting to integer
-//
-//   toint("5")          => 5, true
-//   toint("5.75")       => 5, true
-//   toint("5,555")      => 5555, true
-//   toint("$5")         => 5, true
-//   toint("5,555.00")   => 5555, true
-//
-func ToInt(ctx expr.EvalContext, item value.Value) (value.IntValue, bool) {
-
-	switch itemT := item.(type) {
-	case value.TimeValue:
-		iv := itemT.Val().UnixNano() / 1e6 // Milliseconds
-		return value.NewIntValue(iv), true
-	}
-	iv, ok := value.ToInt64(reflect.ValueOf(item.Value()))
-	if !ok {
-		return value.NewIntValue(0), false
-	}
-	return value.NewIntValue(iv), true
-}
-
-// Convert to Number:   Best attempt at converting to integer
-//
-//   tonumber("5") => 5.0
-//   tonumber("5.75") => 5.75
-//   tonumber("5,555") => 5555
-//   tonumber("$5") => 5.00
-//   tonumber("5,555.00") => 5555
-//
-func ToNumber(ctx expr.EvalContext, item value.Value) (value.NumberValue, bool) {
-	fv, ok := value.ToFloat64(reflect.ValueOf(item.Value()))
-	if !ok {
-		return value.NewNumberNil(), false
-	}
-	return value.NewNumberValue(fv), true
-}
-
 // Get current time of Message (message time stamp) or else choose current
 //   server time if none is available in message context
 //
@@ -1294,67 +1449,77 @@ func HourOfDay(ctx expr.EvalContext, items ...value.Value) (value.IntValue, bool
 	return value.NewIntValue(0), false
 }
 
-// totimestamp:   convert to date, then to unix Seconds
+// tumble:  the start of the tumbling window of a duration the time falls
+//   in, to group by windows of event time.  Integers are unix seconds.
+//   Also timebucket.
 //
-func ToTimestamp(ctx expr.EvalContext, item value.Value) (value.IntValue, bool) {
-
-	dateStr, ok := value.ToString(item.Rv())
+//   tumble("2016-01-02T15:04:45Z", "1m")  =>  2016-01-02T15:04:00Z, true
+//   timebucket(ts, "5m")
+//
+func Tumble(ctx expr.EvalContext, items ...value.Value) (value.TimeValue, bool) {
+	if len(items) != 2 {
+		return value.TimeZeroValue, false
+	}
+	t, ok := timeArg(items[0])
 	if !ok {
-		return value.NewIntValue(0), false
+		return value.TimeZeroValue, false
 	}
-	if t, err := dateparse.ParseAny(dateStr); err == nil {
-		//u.Infof("v=%v   %v  unix=%v", item, item.Rv(), t.Unix())
-		return value.NewIntValue(int64(t.Unix())), true
+	sizeStr, ok := value.ToString(items[1].Rv())
+	if !ok {
+		return value.TimeZeroValue, false
 	}
-
-	return value.NewIntValue(0), false
+	size, err := time.ParseDuration(sizeStr)
+	if err != nil || size <= 0 {
+		return value.TimeZeroValue, false
+	}
+	return value.NewTimeValue(t.In(time.UTC).Truncate(size)), true
 }
 
-// todate:   convert to Date
-//
-//   todate("now-3m")  uses lytics/datemath
-//
-//   todate(field)  uses araddon/dateparse util to recognize formats
+// date_trunc:  a time truncated (in UTC) to the start of its second,
+//   minute, hour, day, week (of monday), month, quarter or year
 //
-//   todate("01/02/2006", field )  uses golang date parse rules
-//      first parameter is the layout/format
+//   date_trunc("hour", "2016-01-02T15:04:45Z")   =>  2016-01-02T15:00:00Z, true
+//   date_trunc("month", "2016-01-02T15:04:45Z")  =>  2016-01-01T00:00:00Z, true
 //
-//
-func ToDate(ctx expr.EvalContext, items ...value.Value) (value.TimeValue, bool) {
-
-	if len(items) == 1 {
-		dateStr, ok := value.ToString(items[0].Rv())
-		if !ok {
-			return value.TimeZeroValue, false
-		}
-		//u.Infof("v=%v   %v  ", dateStr, items[0].Rv())
-		if len(dateStr) > 3 && strings.ToLower(dateStr[:3]) == "now" {
-			// Is date math
-			if t, err := datemath.Eval(dateStr[3:]); err == nil {
-				return value.NewTimeValue(t), true
-			}
-		} else {
-			if t, err := dateparse.ParseAny(dateStr); err == nil {
-				return value.NewTimeValue(t), true
-			}
-		}
-
-	} else if len(items) == 2 {
-		dateStr, ok := value.ToString(items[1].Rv())
-		if !ok {
-			return value.TimeZeroValue, false
-		}
-		formatStr, ok := value.ToString(items[0].Rv())
-		if !ok {
-			return value.TimeZeroValue, false
-		}
-		//u.Infof("hello  layout=%v  time=%v", formatStr, dateStr)
-		if t, err := time.Parse(formatStr, dateStr); err == nil {
-			return value.NewTimeValue(t), true
-		}
+func DateTrunc(ctx expr.EvalContext, unitv, item value.Value) (value.TimeValue, bool) {
+	unit, ok := value.ValueToString(unitv)
+	if !ok {
+		return value.TimeZeroValue, false
 	}
+	t, ok := timeArg(item)
+	if !ok {
+		return value.TimeZeroValue, false
+	}
+	t = t.In(time.UTC)
+	switch strings.ToLower(unit) {
+	case "second":
+		t = t.Truncate(time.Second)
+	case "minute":
+		t = t.Truncate(time.Minute)
+	case "hour":
+		t = t.Truncate(time.Hour)
+	case "day":
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case "week":
+		t = time.Date(t.Year(), t.Month(), t.Day()-(int(t.Weekday())+6)%7, 0, 0, 0, 0, time.UTC)
+	case "month":
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case "quarter":
+		t = time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	case "year":
+		t = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return value.TimeZeroValue, false
+	}
+	return value.NewTimeValue(t), true
+}
 
-	return value.TimeZeroValue, false
+// timeArg the time of a function argument, of integers unix seconds
+func timeArg(v value.Value) (time.Time, bool) {
+	if iv, ok := v.(value.IntValue); ok {
+		return time.Unix(iv.Val(), 0), true
+	}
+	return value.ValueToTime(v)
 }
 
 // MapTime()    Create a map[string]time of each key
@@ -1646,6 +1811,8 @@ func UrlDecode(ctx expr.EvalContext, item value.Value) (value.StringValue, bool)
 	switch itemT := item.(type) {
 	case value.StringValue:
 		val = itemT.Val()
+	case value.ByteSliceValue:
+		val = string(itemT.Val())
 	case value.StringsValue:
 		if len(itemT.Val()) == 0 {
 			return value.EmptyStringValue, false
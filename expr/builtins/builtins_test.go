@@ -1,6 +1,7 @@
 package builtins
 
 import (
+	"fmt"
 	"net/url"
 	"sort"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"github.com/bmizerany/assert"
 
 	"github.com/araddon/qlbridge/datasource"
+	"github.com/araddon/qlbridge/expr"
 	"github.com/araddon/qlbridge/value"
 	"github.com/araddon/qlbridge/vm"
 )
@@ -20,6 +22,11 @@ var _ = u.EMPTY
 
 func init() {
 	LoadAllBuiltins()
+	RegisterKeyring("pii", StaticKeyring([]byte("secret")))
+	RegisterKeyring("pii2", StaticKeyring([]byte("another secret")))
+	RegisterKeyring("broken", KeyringFunc(func() ([]byte, error) {
+		return nil, fmt.Errorf("vault unavailable")
+	}))
 	u.SetupLogging("debug")
 	u.SetColorOutput()
 }
@@ -46,6 +53,7 @@ var (
 		"score_amount": {"22"},
 		"tag_name":     {"bob"},
 		"tags":         {"a", "b", "c", "d"},
+		"payload":      {`{"user": {"name": "bob", "first name": "Bob"}, "tags": ["a", "b"], "n": 5, "f": 1.5, "ok": true}`},
 	}, ts)
 	float3pt1 = float64(3.1)
 )
@@ -206,6 +214,45 @@ var builtinTests = []testBuiltins{
 
 	{`split("apples,oranges",",")`, value.NewStringsValue([]string{"apples", "oranges"})},
 
+	{`toint(haversine(51.5074, -0.1278, 48.8566, 2.3522))`, value.NewIntValue(343)},
+	{`toint(haversine("51.5074,-0.1278", [48.8566, 2.3522], "mi"))`, value.NewIntValue(213)},
+	{`toint(haversine("POINT(-0.1278 51.5074)", "48.8566,2.3522", "m") / 1000)`, value.NewIntValue(343)},
+	{`haversine("0,0", "0,0")`, value.NewNumberValue(0)},
+	{`haversine("0,0", "0,0", "parsecs")`, value.ErrValue},
+	{`haversine("91,0", "0,0")`, nil},
+	{`haversine(not_a_field, "0,0")`, nil},
+	{`st_contains("POLYGON((0 0, 10 0, 10 10, 0 10, 0 0))", "5,5")`, value.BoolValueTrue},
+	{`st_contains("5,5", "0,0;0,10;10,10;10,0")`, value.BoolValueTrue},
+	{`st_contains([0, 0, 0, 10, 10, 5], "5,9")`, value.BoolValueFalse},
+	{`st_contains([0, 0, 0, 10, 10, 5], "5,4")`, value.BoolValueTrue},
+	{`st_contains("5,5", "0,0;0,10")`, nil},
+	{`in_bbox("48.8566,2.3522", 48.8, 2.2, 48.9, 2.5)`, value.BoolValueTrue},
+	{`in_bbox("48.8566,2.6", 48.8, 2.2, 48.9, 2.5)`, value.BoolValueFalse},
+	{`in_bbox("0,-179.5", -1, 179, 1, -179)`, value.BoolValueTrue},
+	{`geohash_encode(57.64911, 10.40744, 11)`, value.NewStringValue("u4pruydqqvj")},
+	{`geohash_encode("42.6,-5.6", 5)`, value.NewStringValue("ezs42")},
+	{`geohash_encode("42.6,-5.6", 13)`, nil},
+	{`array_join(geohash_decode("ezs42"), ",")`, value.NewStringValue("42.60498046875,-5.60302734375")},
+	{`geohash_encode(geohash_decode("u4pruydqqvj"), 11)`, value.NewStringValue("u4pruydqqvj")},
+	{`geohash_decode("ezs4a")`, nil},
+
+	{`array_contains(tags, "c")`, value.BoolValueTrue},
+	{`array_contains(tags, "z")`, value.BoolValueFalse},
+	{`array_contains([1, 2], 2)`, value.BoolValueTrue},
+	{`array_contains(not_a_field, "c")`, nil},
+	{`array_length(tags)`, value.NewIntValue(4)},
+	{`array_length(event)`, nil},
+	{`array_join(tags, "-")`, value.NewStringValue("a-b-c-d")},
+	{`array_join([1, 2], "+", "?")`, value.NewStringValue("1+2")},
+	{`array_join(array_distinct(["b","a","b","c","a"]), ",")`, value.NewStringValue("b,a,c")},
+	{`array_distinct(split("a,b,a", ","))`, value.NewStringsValue([]string{"a", "b"})},
+	{`array_join(slice(tags, 2, 2), ",")`, value.NewStringValue("b,c")},
+	{`array_join(slice(tags, -2, 5), ",")`, value.NewStringValue("c,d")},
+	{`array_length(slice(tags, 9, 2))`, value.NewIntValue(0)},
+	{`slice(tags, 0, 2)`, nil},
+	{`array_join(unnest(tags), "")`, value.NewStringValue("abcd")},
+	{`unnest(event)`, nil},
+
 	{`replace("M20:30","M")`, value.NewStringValue("20:30")},
 	{`replace("/search/for+stuff","/search/")`, value.NewStringValue("for+stuff")},
 	{`replace("M20:30","M","")`, value.NewStringValue("20:30")},
@@ -218,6 +265,113 @@ var builtinTests = []testBuiltins{
 	{`len("abc") >= 2`, value.BoolValueTrue},
 	{`CHAR_LENGTH("abc") `, value.NewIntValue(3)},
 	{`CHAR_LENGTH(CAST("abc" AS CHAR))`, value.NewIntValue(3)},
+	{`char_length("héllo")`, value.NewIntValue(5)},
+	{`char_length(not_a_field)`, nil},
+
+	{`upper("Apple")`, value.NewStringValue("APPLE")},
+	{`lower("Apple")`, value.NewStringValue("apple")},
+	{`ucase(event)`, value.NewStringValue("HELLO")},
+
+	{`substr("Quadratically", 5)`, value.NewStringValue("ratically")},
+	{`substring("Quadratically", 5, 6)`, value.NewStringValue("ratica")},
+	{`substr("Sakila", -3)`, value.NewStringValue("ila")},
+	{`substr("Sakila", -5, 3)`, value.NewStringValue("aki")},
+	{`substr("héllo", 2, 3)`, value.NewStringValue("éll")},
+	{`eq(substr("Sakila", 0),"")`, value.BoolValueTrue},
+	{`substr(not_a_field, 2)`, nil},
+
+	{`left("foobarbar", 5)`, value.NewStringValue("fooba")},
+	{`left("foo", 5)`, value.NewStringValue("foo")},
+	{`right("foobarbar", 4)`, value.NewStringValue("rbar")},
+
+	{`trim("  bar   ")`, value.NewStringValue("bar")},
+	{`trim("xxxbarxxx", "x")`, value.NewStringValue("bar")},
+	{`trim("xyzbarxyz", "xyz")`, value.NewStringValue("bar")},
+	{`ltrim("  barbar ")`, value.NewStringValue("barbar ")},
+	{`rtrim(" barbar   ")`, value.NewStringValue(" barbar")},
+
+	{`lpad("hi", 4, "??")`, value.NewStringValue("??hi")},
+	{`lpad("hi", 5, "ab")`, value.NewStringValue("abahi")},
+	{`lpad("hi", 1, "??")`, value.NewStringValue("h")},
+	{`rpad("hi", 5, "?")`, value.NewStringValue("hi???")},
+	{`rpad("hi", 5, "")`, nil},
+	{`lpad("a", 9223372036854775807, "x")`, nil},
+	{`rpad("a", 4194305, "x")`, nil},
+
+	{`reverse("abc")`, value.NewStringValue("cba")},
+	{`reverse("héllo")`, value.NewStringValue("olléh")},
+	{`repeat("ab", 3)`, value.NewStringValue("ababab")},
+	{`eq(repeat("ab", 0),"")`, value.BoolValueTrue},
+	{`repeat("ab", 9223372036854775807)`, nil},
+	{`repeat("ab", 2097153)`, nil},
+	{`len(repeat("ab", 2097152))`, value.NewIntValue(4194304)},
+
+	{`instr("foobarbar", "bar")`, value.NewIntValue(4)},
+	{`instr("xbar", "foobar")`, value.NewIntValue(0)},
+	{`locate("bar", "foobarbar")`, value.NewIntValue(4)},
+	{`locate("bar", "foobarbar", 5)`, value.NewIntValue(7)},
+	{`position("l", "héllo")`, value.NewIntValue(3)},
+
+	{`format(12332.123456, 4)`, value.NewStringValue("12,332.1235")},
+	{`format(12332.2, 0)`, value.NewStringValue("12,332")},
+	{`format(-1234567.891, 2)`, value.NewStringValue("-1,234,567.89")},
+	{`format(123, 2)`, value.NewStringValue("123.00")},
+	{`format(2.5, 0)`, value.NewStringValue("3")},
+	{`format(-0.5, 0)`, value.NewStringValue("-1")},
+	{`format(1, 1000000000)`, value.NewStringValue("1." + strings.Repeat("0", 30))},
+	{`format(0.125, 2)`, value.NewStringValue("0.13")},
+	{`format(-1234.5, 0)`, value.NewStringValue("-1,235")},
+	{`format(1e20, 2)`, value.NewStringValue("100,000,000,000,000,000,000.00")},
+	{`format("abc", 2)`, nil},
+
+	{`regexp_match("apple pie", "^app")`, value.BoolValueTrue},
+	{`regexp_match("apple pie", "[0-9]")`, value.BoolValueFalse},
+	{`regexp_like(email, "@email[.]com$")`, value.BoolValueTrue},
+	{`regexp_match("apple", "(")`, value.ErrValue},
+	{`regexp_match(not_a_field, "a")`, nil},
+	{`regexp_extract("order-1234-x", "[0-9]+")`, value.NewStringValue("1234")},
+	{`regexp_extract("bob@gmail.com", "@([a-z]+)[.]com", 1)`, value.NewStringValue("gmail")},
+	{`regexp_extract("bob@gmail.com", "@([a-z]+)", 2)`, value.ErrValue},
+	{`regexp_extract("no digits", "[0-9]+")`, nil},
+	{`regexp_extract("apple", "[a-")`, value.ErrValue},
+	{`regexp_replace("a1b22c", "[0-9]+", "#")`, value.NewStringValue("a#b#c")},
+	{`regexp_replace("John Smith", "([a-zA-Z]+) ([a-zA-Z]+)", "$2, $1")`, value.NewStringValue("Smith, John")},
+	{`regexp_replace("apple", "*", "")`, value.ErrValue},
+
+	{`json_extract(payload, "$.user.name")`, value.NewStringValue("bob")},
+	{`json_extract(payload, '$.user."first name"')`, value.NewStringValue("Bob")},
+	{`json_extract(payload, "$.tags[1]")`, value.NewStringValue("b")},
+	{`json_extract(payload, "$.n")`, value.NewIntValue(5)},
+	{`json_extract(payload, "$.f")`, value.NewNumberValue(1.5)},
+	{`json_extract(payload, "$.ok")`, value.BoolValueTrue},
+	{`json_extract(payload, "$.tags[2]")`, nil},
+	{`json_extract(payload, "$.nope.name")`, nil},
+	{`json_extract(payload, "user.name")`, value.ErrValue},
+	{`json_extract(payload, "$.tags[x]")`, value.ErrValue},
+	{`json_extract("not json", "$.a")`, nil},
+	{`json_unquote(json_extract(payload, "$.user"))`, value.NewStringValue(`{"first name":"Bob","name":"bob"}`)},
+	{`json_unquote(json_extract(payload, "$.n", "$.tags[0]"))`, value.NewStringValue(`[5,"a"]`)},
+	{`payload->"$.user.name"`, value.NewStringValue("bob")},
+	{`payload->"$.n" > 3`, value.BoolValueTrue},
+	{`payload->>"$.tags"`, value.NewStringValue(`["a","b"]`)},
+	{`payload->>'$.n'`, value.NewStringValue("5")},
+	{`json_unquote('"bob"')`, value.NewStringValue("bob")},
+
+	{`json_unquote(json_set('{"a": 1}', "$.a", 2, "$.b", "x"))`, value.NewStringValue(`{"a":2,"b":"x"}`)},
+	{`json_unquote(json_set('{"a": [1]}', "$.a[1]", 2, "$.c.d", 3))`, value.NewStringValue(`{"a":[1,2]}`)},
+	{`json_extract(json_set(payload, "$.user.name", "alice"), "$.user.name")`, value.NewStringValue("alice")},
+	{`json_set(payload, "$.a")`, value.ErrValue},
+
+	{`json_length(payload)`, value.NewIntValue(5)},
+	{`json_length(payload, "$.user")`, value.NewIntValue(2)},
+	{`json_length(payload, "$.n")`, value.NewIntValue(1)},
+	{`json_array_length(payload, "$.tags")`, value.NewIntValue(2)},
+	{`json_array_length('[1, 2, 3]')`, value.NewIntValue(3)},
+	{`json_array_length(payload)`, nil},
+
+	{`json_keys(payload)`, value.NewStringsValue([]string{"f", "n", "ok", "tags", "user"})},
+	{`json_keys(payload, "$.user")`, value.NewStringsValue([]string{"first name", "name"})},
+	{`json_keys(payload, "$.tags")`, nil},
 
 	/*
 		hashing functions
@@ -225,6 +379,18 @@ var builtinTests = []testBuiltins{
 	{`hash.md5("hello")`, value.NewStringValue("5d41402abc4b2a76b9719d911017c592")},
 	{`hash.sha1("hello")`, value.NewStringValue("aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d")},
 	{`hash.sha256("hello")`, value.NewStringValue("2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")},
+	{`md5("hello")`, value.NewStringValue("5d41402abc4b2a76b9719d911017c592")},
+	{`sha1("hello")`, value.NewStringValue("aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d")},
+	{`sha256("hello")`, value.NewStringValue("2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")},
+	{`fnv64("hello")`, value.NewIntValue(2607821981565500683)},
+	{`hash(42)`, value.NewIntValue(571532774284038691)},
+	{`hash("42")`, value.NewIntValue(571532774284038691)},
+	{`hash(not_a_field)`, nil},
+	{`hash(42) % 100 < 95`, value.BoolValueTrue},
+	{`crc32("MySQL")`, value.NewIntValue(3259397556)},
+	{`murmur3("hello")`, value.NewIntValue(613153351)},
+	{`murmur3("The quick brown fox jumps over the lazy dog")`, value.NewIntValue(776992547)},
+	{`murmur3("hello", 42)`, value.NewIntValue(3806057185)},
 
 	/*
 		Special Type Functions:  Email, url's
@@ -246,6 +412,76 @@ var builtinTests = []testBuiltins{
 	{`urldecode("hello world")`, value.NewStringValue("hello world")},
 	{`urldecode("2Live_Reg")`, value.NewStringValue("2Live_Reg")},
 	{`urldecode("https%3A%2F%2Fwww.google.com%2Fsearch%3Fq%3Dgolang")`, value.NewStringValue("https://www.google.com/search?q=golang")},
+	{`urldecode(unhex("612532306225323663"))`, value.NewStringValue("a b&c")},
+	{`urlencode("a b&c")`, value.NewStringValue("a+b%26c")},
+	{`urlencode("https://www.google.com/search?q=golang")`, value.NewStringValue("https%3A%2F%2Fwww.google.com%2Fsearch%3Fq%3Dgolang")},
+	{`urlhost("https://WWW.Google.com:8080/Search?q=Go")`, value.NewStringValue("www.google.com")},
+	{`urlhost("www.Google.com/?q=golang")`, value.NewStringValue("www.google.com")},
+	{`urlhost("/search?q=golang")`, nil},
+	{`urlpath("https://www.google.com/Blog/my%20post?x=1")`, value.NewStringValue("/Blog/my post")},
+	{`urlpath("www.google.com/Search")`, value.NewStringValue("/Search")},
+	{`urlpath("/a/B?c=d")`, value.NewStringValue("/a/B")},
+	{`urlparam("http://www.site.com/?utm_source=Google&q=a%20b", "utm_source")`, value.NewStringValue("Google")},
+	{`urlparam("http://www.site.com/?utm_source=Google&q=a%20b", "q")`, value.NewStringValue("a b")},
+	{`urlparam("/page?id=1&id=2", "id")`, value.NewStringValue("1")},
+	{`urlparam("http://www.site.com/?q=1", "Q")`, nil},
+	{`urlparam(not_a_field, "q")`, nil},
+	{`map_get(urldecodequery("http://site.com/?a=1&b=x%20y&a=2"), "b")`, value.NewStringValue("x y")},
+	{`array_join(map_get(urldecodequery("http://site.com/?a=1&b=x%20y&a=2"), "a"), ",")`, value.NewStringValue("1,2")},
+	{`array_join(map_keys(urldecodequery("?b=2&a=1")), ",")`, value.NewStringValue("a,b")},
+	{`map_keys(urldecodequery("http://site.com/"))`, value.NewStringsValue([]string{})},
+
+	{`useragent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36", "browser")`, value.NewStringValue("Chrome")},
+	{`useragent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36", "browser_version")`, value.NewStringValue("91.0.4472.124")},
+	{`useragent("Mozilla/5.0 (Windows NT 6.1; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36 Edg/91.0.864.59", "browser")`, value.NewStringValue("Edge")},
+	{`useragent("Mozilla/5.0 (Windows NT 6.1; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36 Edg/91.0.864.59", "OS")`, value.NewStringValue("Windows")},
+	{`useragent("Mozilla/5.0 (Windows NT 6.1; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36 Edg/91.0.864.59", "os_version")`, value.NewStringValue("7")},
+	{`useragent("Mozilla/5.0 (iPhone; CPU iPhone OS 14_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Mobile/15E148 Safari/604.1", "browser")`, value.NewStringValue("Safari")},
+	{`useragent("Mozilla/5.0 (iPhone; CPU iPhone OS 14_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Mobile/15E148 Safari/604.1", "os")`, value.NewStringValue("iOS")},
+	{`useragent("Mozilla/5.0 (iPhone; CPU iPhone OS 14_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Mobile/15E148 Safari/604.1", "os_version")`, value.NewStringValue("14.6")},
+	{`useragent("Mozilla/5.0 (iPhone; CPU iPhone OS 14_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Mobile/15E148 Safari/604.1", "device")`, value.NewStringValue("mobile")},
+	{`useragent("Mozilla/5.0 (Linux; Android 11; SM-T870) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.120 Safari/537.36", "device")`, value.NewStringValue("tablet")},
+	{`useragent("Mozilla/5.0 (Linux; Android 11; SM-T870) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.120 Safari/537.36", "os")`, value.NewStringValue("Android")},
+	{`useragent("Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:89.0) Gecko/20100101 Firefox/89.0", "browser")`, value.NewStringValue("Firefox")},
+	{`useragent("Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:89.0) Gecko/20100101 Firefox/89.0", "os")`, value.NewStringValue("Mac OS X")},
+	{`useragent("Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:89.0) Gecko/20100101 Firefox/89.0", "device")`, value.NewStringValue("desktop")},
+	{`useragent("Mozilla/5.0 (Windows NT 6.3; Trident/7.0; rv:11.0) like Gecko", "browser")`, value.NewStringValue("Internet Explorer")},
+	{`useragent("Mozilla/5.0 (Windows NT 6.3; Trident/7.0; rv:11.0) like Gecko", "browser_version")`, value.NewStringValue("11.0")},
+	{`useragent("Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "browser")`, value.NewStringValue("Googlebot")},
+	{`useragent("Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "device")`, value.NewStringValue("bot")},
+	{`useragent("curl/7.64.1", "browser")`, value.NewStringValue("Other")},
+	{`useragent("curl/7.64.1", "os_version")`, nil},
+	{`useragent("curl/7.64.1", "color")`, value.ErrValue},
+	{`useragent(not_a_field, "os")`, nil},
+
+	{`hmac_sha256("key", "The quick brown fox jumps over the lazy dog")`, value.NewStringValue("f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8")},
+	{`hmac_sha256("", "")`, value.NewStringValue("b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad")},
+	{`hmac_sha256("key", not_a_field)`, nil},
+	{`tokenize("bob@example.com", "pii")`, value.NewStringValue("dlt@pggfhjx.fap")},
+	{`tokenize("555-0100", "PII")`, value.NewStringValue("362-4748")},
+	{`tokenize("Bob Smith", "pii")`, value.NewStringValue("Ipg Vcexo")},
+	{`tokenize(email, "pii") == tokenize(email, "pii")`, value.BoolValueTrue},
+	{`tokenize(email, "pii") == tokenize(email, "pii2")`, value.BoolValueFalse},
+	{`len(tokenize(email, "pii")) == len(email)`, value.BoolValueTrue},
+	{`tokenize("", "pii")`, value.NewStringValue("")},
+	{`tokenize(not_a_field, "pii")`, nil},
+	{`tokenize("bob", "nope")`, value.ErrValue},
+	{`tokenize("bob", "broken")`, value.ErrValue},
+
+	{`to_base64("abc")`, value.NewStringValue("YWJj")},
+	{`from_base64("YWJj")`, value.NewByteSliceValue([]byte("abc"))},
+	{`from_base64("YWJjZA")`, value.NewByteSliceValue([]byte("abcd"))},
+	{`from_base64("not base64!")`, nil},
+	{`to_base64(from_base64("AAH/"))`, value.NewStringValue("AAH/")},
+	{`hex(255)`, value.NewStringValue("FF")},
+	{`hex(-1)`, value.NewStringValue("FFFFFFFFFFFFFFFF")},
+	{`hex("abc")`, value.NewStringValue("616263")},
+	{`unhex("616263")`, value.NewByteSliceValue([]byte("abc"))},
+	{`unhex("zz")`, nil},
+	{`hex(unhex("00FF10"))`, value.NewStringValue("00FF10")},
+	{`quote("Don't!")`, value.NewStringValue(`'Don\'t!'`)},
+	{`quote(not_a_field)`, value.NewStringValue("NULL")},
+	{`escape("Don't!")`, value.NewStringValue(`Don\'t!`)},
 
 	{`domain("https://www.Google.com/search?q=golang")`, value.NewStringValue("google.com")},
 	{`domains("https://www.Google.com/search?q=golang")`, value.NewStringsValue([]string{"google.com"})},
@@ -265,6 +501,60 @@ var builtinTests = []testBuiltins{
 	{`urlminusqs("http://www.Google.com/search?q1=golang","q1")`, value.NewStringValue("http://www.Google.com/search")},
 	{`urlmain("http://www.Google.com/search?q1=golang&q2=github")`, value.NewStringValue("www.Google.com/search")},
 
+	{`inet_aton("10.0.5.9")`, value.NewIntValue(167773449)},
+	{`inet_aton("2001:db8::1")`, nil},
+	{`inet_aton("not an ip")`, nil},
+	{`inet_ntoa(167773449)`, value.NewStringValue("10.0.5.9")},
+	{`inet_ntoa(inet_aton("255.255.255.255"))`, value.NewStringValue("255.255.255.255")},
+	{`inet_ntoa(4294967296)`, nil},
+	{`ip_in_cidr("10.1.2.3", "10.0.0.0/8")`, value.BoolValueTrue},
+	{`ip_in_cidr("11.1.2.3", "10.0.0.0/8")`, value.BoolValueFalse},
+	{`ip_in_cidr("2001:db8::1", "2001:db8::/32")`, value.BoolValueTrue},
+	{`ip_in_cidr("10.1.2.3", "2001:db8::/32")`, value.BoolValueFalse},
+	{`ip_in_cidr("10.1.2.3", "10.0.0.0/33")`, value.ErrValue},
+	{`ip_in_cidr(not_a_field, "10.0.0.0/8")`, nil},
+	{`is_private_ip("192.168.1.20")`, value.BoolValueTrue},
+	{`is_private_ip("172.31.0.1")`, value.BoolValueTrue},
+	{`is_private_ip("172.32.0.1")`, value.BoolValueFalse},
+	{`is_private_ip("fd12:3456::1")`, value.BoolValueTrue},
+	{`is_private_ip("8.8.8.8")`, value.BoolValueFalse},
+	{`ip_normalize("2001:0DB8:0000:0000:0000:0000:0000:0001")`, value.NewStringValue("2001:db8::1")},
+	{`ip_normalize("::ffff:10.0.0.1")`, value.NewStringValue("10.0.0.1")},
+	{`ip_normalize("bogus")`, nil},
+
+	{`is_uuid(uuid())`, value.BoolValueTrue},
+	{`is_uuid(uuid_v7())`, value.BoolValueTrue},
+	{`substr(uuid_v7(), 15, 1)`, value.NewStringValue("7")},
+	{`is_uuid("6ccd780c-baba-1026-9564-5b8c656024db")`, value.BoolValueTrue},
+	{`is_uuid("{6ccd780c-baba-1026-9564-5b8c656024db}")`, value.BoolValueTrue},
+	{`is_uuid("6ccd780cbaba102695645b8c656024db")`, value.BoolValueTrue},
+	{`is_uuid("6ccd780c-baba-1026-9564-5b8c6560")`, value.BoolValueFalse},
+	{`is_uuid("6ccd780c_baba_1026_9564_5b8c656024db")`, value.BoolValueFalse},
+	{`hex(uuid_to_bin("6ccd780c-baba-1026-9564-5b8c656024db"))`, value.NewStringValue("6CCD780CBABA102695645B8C656024DB")},
+	{`hex(uuid_to_bin("6ccd780c-baba-1026-9564-5b8c656024db", true))`, value.NewStringValue("1026BABA6CCD780C95645B8C656024DB")},
+	{`uuid_to_bin("nope")`, nil},
+	{`bin_to_uuid(uuid_to_bin("6CCD780C-BABA-1026-9564-5B8C656024DB"))`, value.NewStringValue("6ccd780c-baba-1026-9564-5b8c656024db")},
+	{`bin_to_uuid(uuid_to_bin("6ccd780c-baba-1026-9564-5b8c656024db", 1), 1)`, value.NewStringValue("6ccd780c-baba-1026-9564-5b8c656024db")},
+	{`bin_to_uuid(unhex("00ff"))`, nil},
+
+	{`coalesce(not_a_field, event, "x")`, value.NewStringValue("hello")},
+	{`coalesce(not_a_field, NULL)`, nil},
+	{`coalesce("a", regexp_match("x", "("))`, value.NewStringValue("a")},
+	{`coalesce(not_a_field, regexp_match("x", "("), "a")`, value.ErrValue},
+	{`ifnull(not_a_field, 0)`, value.NewIntValue(0)},
+	{`ifnull(event, "x")`, value.NewStringValue("hello")},
+	{`nullif(event, "hello")`, nil},
+	{`nullif(event, "x")`, value.NewStringValue("hello")},
+	{`if(event == "hello", "yes", "no")`, value.NewStringValue("yes")},
+	{`if(0, "yes", "no")`, value.NewStringValue("no")},
+	{`if(false, "yes")`, nil},
+	{`if(true, "a", regexp_match("x", "("))`, value.NewStringValue("a")},
+	{`greatest(2, 10, 5)`, value.NewIntValue(10)},
+	{`greatest(1.5, 1)`, value.NewNumberValue(1.5)},
+	{`greatest("b", "a", "c")`, value.NewStringValue("c")},
+	{`least(2, 10, 5)`, value.NewIntValue(2)},
+	{`least(2, not_a_field)`, nil},
+
 	/*
 		Casting and type-coercion functions
 	*/
@@ -280,6 +570,32 @@ var builtinTests = []testBuiltins{
 	{`todate("4/7/14")`, value.NewTimeValue(ts2)},
 	{`todate("Apr 7, 2014 4:58:55 PM")`, value.NewTimeValue(ts)},
 	{`todate("Apr 7, 2014 4:58:55 PM") < todate("now-3m")`, value.NewBoolValue(true)},
+	{`todate("07/04/2014", "02/01/2006")`, value.NewTimeValue(ts2)},
+	{`todate("2014-04-07", "%d/%m/%Y", "%Y-%m-%d")`, value.NewTimeValue(ts2)},
+	{`todate("07.04.2014 16:58:55", "%d.%m.%Y %H:%M:%S")`, value.NewTimeValue(ts)},
+	{`todate("7 avril 2014", "2 January 2006", "fr")`, value.NewTimeValue(ts2)},
+	{`todate("Montag, 7. April 2014", "Monday, 2. January 2006", "de-DE")`, value.NewTimeValue(ts2)},
+	{`todate("7 abr 2014", "2 Jan 2006", "es")`, value.NewTimeValue(ts2)},
+	{`todate("7 avril 2014", "fr")`, value.NewTimeValue(ts2)},
+	{`todate("2014-04-07", "%d/%m/%Y")`, value.ErrValue},
+	{`todate("2014-04-07", "%Q")`, value.ErrValue},
+	{`todate("not a date")`, value.ErrValue},
+	{`todate(not_a_field, "%Y")`, nil},
+
+	{`tumble("Apr 7, 2014 4:58:55 PM", "1m")`, value.NewTimeValue(time.Date(2014, 4, 7, 16, 58, 0, 0, time.UTC))},
+	{`tumble("Apr 7, 2014 4:58:55 PM", "24h")`, value.NewTimeValue(ts2)},
+	{`tumble("Apr 7, 2014 4:58:55 PM", "fortnight")`, value.ErrValue},
+	{`timebucket("Apr 7, 2014 4:58:55 PM", "5m")`, value.NewTimeValue(time.Date(2014, 4, 7, 16, 55, 0, 0, time.UTC))},
+
+	{`date_trunc("second", "2016-01-02T15:04:45.123Z")`, value.NewTimeValue(time.Date(2016, 1, 2, 15, 4, 45, 0, time.UTC))},
+	{`date_trunc("minute", "Apr 7, 2014 4:58:55 PM")`, value.NewTimeValue(time.Date(2014, 4, 7, 16, 58, 0, 0, time.UTC))},
+	{`date_trunc("hour", "Apr 7, 2014 4:58:55 PM")`, value.NewTimeValue(time.Date(2014, 4, 7, 16, 0, 0, 0, time.UTC))},
+	{`date_trunc("day", "Apr 7, 2014 4:58:55 PM")`, value.NewTimeValue(ts2)},
+	{`date_trunc("week", "2016-01-03T15:04:45Z")`, value.NewTimeValue(time.Date(2015, 12, 28, 0, 0, 0, 0, time.UTC))},
+	{`date_trunc("MONTH", "Apr 7, 2014 4:58:55 PM")`, value.NewTimeValue(time.Date(2014, 4, 1, 0, 0, 0, 0, time.UTC))},
+	{`date_trunc("quarter", "2016-08-02T15:04:45Z")`, value.NewTimeValue(time.Date(2016, 7, 1, 0, 0, 0, 0, time.UTC))},
+	{`date_trunc("year", 1451747085)`, value.NewTimeValue(time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC))},
+	{`date_trunc("fortnight", "Apr 7, 2014 4:58:55 PM")`, value.ErrValue},
 
 	{`toint("5")`, value.NewIntValue(5)},
 	{`toint("hello")`, value.ErrValue},
@@ -298,6 +614,38 @@ var builtinTests = []testBuiltins{
 	{`tonumber("5,555.00")`, value.NewNumberValue(float64(5555.00))},
 	{`tonumber("€ 5,555.00")`, value.NewNumberValue(float64(5555.00))},
 
+	{`toint("9007199254740993")`, value.NewIntValue(9007199254740993)},
+	{`toint("5.555,00", "de")`, value.NewIntValue(5555)},
+	{`toint("5,555.00", "de")`, value.ErrValue},
+	{`toint("5", "xx")`, value.ErrValue},
+	{`toint(true)`, value.NewIntValue(1)},
+	{`toint(not_a_field)`, nil},
+	{`tofloat("5 555,25", "fr")`, value.NewNumberValue(5555.25)},
+	{`tofloat("1'234.5", "de-CH")`, value.ErrValue},
+	{`tofloat("1'234.5", "ch")`, value.NewNumberValue(1234.5)},
+	{`tofloat("€1.234,50", "es_ES")`, value.NewNumberValue(1234.5)},
+	{`tofloat(score_amount)`, value.NewNumberValue(22)},
+	{`tofloat("abc")`, value.ErrValue},
+
+	{`tobool("Yes")`, value.BoolValueTrue},
+	{`tobool("off")`, value.BoolValueFalse},
+	{`tobool(1)`, value.BoolValueTrue},
+	{`tobool(0.0)`, value.BoolValueFalse},
+	{`tobool(2)`, value.ErrValue},
+	{`tobool("maybe")`, value.ErrValue},
+	{`tobool(not_a_field)`, nil},
+
+	{`tostring(5)`, value.NewStringValue("5")},
+	{`tostring(3.14159, "%.2f")`, value.NewStringValue("3.14")},
+	{`tostring(42, "%05d")`, value.NewStringValue("00042")},
+	{`tostring(42, "%q")`, value.NewStringValue(`'*'`)},
+	{`tostring(3.5, "%d")`, value.ErrValue},
+	{`tostring(todate("2014-04-07"), "%Y/%m/%d")`, value.NewStringValue("2014/04/07")},
+	{`tostring(todate("2014-04-07"), "Jan 2")`, value.NewStringValue("Apr 7")},
+	{`tostring(todate("2014-04-07"))`, value.NewStringValue("2014-04-07T00:00:00Z")},
+	{`tostring(event, "%d")`, value.ErrValue},
+	{`tostring(not_a_field)`, nil},
+
 	/*
 		Date functions
 	*/
@@ -326,6 +674,9 @@ var builtinTests = []testBuiltins{
 	{`hourofweek("Apr 7, 2014 4:58:55 PM")`, value.NewIntValue(40)},
 
 	{`totimestamp("Apr 7, 2014 4:58:55 PM")`, value.NewIntValue(1396889935)},
+	{`totimestamp("07.04.2014", "%d.%m.%Y")`, value.NewIntValue(1396828800)},
+	{`totimestamp("7 mei 2014", "2 January 2006", "nl")`, value.NewIntValue(1399420800)},
+	{`totimestamp("7/4/2014", "%Y-%m-%d")`, value.ErrValue},
 
 	{`extract(reg_date, "%B")`, value.NewStringValue("October")},
 	{`extract(reg_date, "%d")`, value.NewStringValue("13")},
@@ -445,3 +796,70 @@ func TestBuiltins(t *testing.T) {
 
 	}
 }
+
+// nativeContext fields of arrays and maps of go types, as of json events
+var nativeContext = datasource.NewContextSimpleNative(map[string]interface{}{
+	"nested": []interface{}{[]string{"a", "b"}, []interface{}{"c"}, "d"},
+	"labels": map[string]interface{}{"env": "prod", "team": "core", "team_lead": "bob"},
+	"counts": map[string]int64{"x": 1, "y": 2},
+})
+
+func TestBuiltinsDocumented(t *testing.T) {
+	for _, info := range expr.FuncsInfo() {
+		assert.Tf(t, info.Category != "" && info.Doc != "", "undocumented builtin %q", info.Name)
+		assert.Tf(t, strings.HasPrefix(info.Signature, "("), "signature of %q: %q", info.Name, info.Signature)
+	}
+	info, ok := expr.FuncInfoGet("LAG")
+	assert.T(t, ok)
+	assert.Equal(t, "window", info.Category)
+	assert.T(t, info.Window && !info.Aggregate)
+	info, ok = expr.FuncInfoGet("count")
+	assert.T(t, ok)
+	assert.T(t, info.Aggregate && !info.Window)
+	info, ok = expr.FuncInfoGet("array_join")
+	assert.T(t, ok)
+	assert.Equal(t, "(array, sep [, null_as])", info.Signature)
+	assert.Equal(t, "string", info.Returns)
+	_, ok = expr.FuncInfoGet("not_a_func")
+	assert.T(t, !ok)
+}
+
+func TestNativeBuiltins(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		val  string // nil of ""
+	}{
+		{`array_join(flatten(nested), ",")`, "a,b,c,d"},
+		{`array_length(flatten(nested))`, "4"},
+		{`array_contains(flatten(nested), "c")`, "true"},
+
+		{`array_join(map_keys(labels), ",")`, "env,team,team_lead"},
+		{`array_join(map_keys(counts), ",")`, "x,y"},
+		{`map_keys(event)`, ""},
+		{`array_join(map_values(labels), ",")`, "prod,core,bob"},
+		{`array_join(map_values(counts), "+")`, "1+2"},
+		{`map_get(labels, "env")`, "prod"},
+		{`map_get(counts, "y") + 1`, "3"},
+		{`map_get(labels, "region")`, ""},
+		{`map_get(labels, "region", "none")`, "none"},
+		{`map_get(not_a_field, "env", "none")`, "none"},
+		{`has_key(labels, "team")`, "true"},
+		{`has_key(labels, "region")`, "false"},
+		{`has_key(not_a_field, "team")`, "false"},
+		{`array_join(map_keys(map_filter(labels, "team")), ",")`, "team,team_lead"},
+		{`array_join(map_keys(map_filter(labels, "env", "*lead")), ",")`, "env,team_lead"},
+		{`array_length(map_keys(map_filter(labels, "nope")))`, "0"},
+	} {
+		exprVm, err := vm.NewVm(tc.expr)
+		assert.Tf(t, err == nil, "parse err: %v on %s", err, tc.expr)
+		writeContext := datasource.NewContextSimple()
+		err = exprVm.Execute(writeContext, nativeContext)
+		val, ok := writeContext.Get("")
+		if tc.val == "" {
+			assert.Tf(t, err != nil || !ok, "expected nil got %v on %s", val, tc.expr)
+			continue
+		}
+		assert.Tf(t, err == nil, "eval err: %v on %s", err, tc.expr)
+		assert.Tf(t, ok && val.ToString() == tc.val, "expected %v got %v on %s", tc.val, val, tc.expr)
+	}
+}
@@ -0,0 +1,178 @@
+package builtins
+
+import (
+	"strings"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// isNull is a value SQL NULL, ie missing or nil, not an empty string
+func isNull(v value.Value) bool {
+	switch vt := v.(type) {
+	case nil, value.NilValue:
+		return true
+	case value.NumberValue, value.IntValue:
+		return vt.Nil()
+	}
+	return false
+}
+
+// coalesce:  the first of its arguments not NULL, evaluating none after
+//
+//   coalesce(nickname, first_name, "anonymous")
+//
+func CoalesceFunc(ctx expr.EvalContext, args ...expr.LazyArg) (value.Value, bool) {
+	for _, arg := range args {
+		if v := arg(); !isNull(v) {
+			// errors too, rather than evaluating on past them
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// ifnull:  the first argument, or the second (only evaluated then) if it
+// is NULL
+//
+//   ifnull(discount, 0)
+//
+func IfNullFunc(ctx expr.EvalContext, args ...expr.LazyArg) (value.Value, bool) {
+	if len(args) != 2 {
+		return value.NewErrorValue("ifnull: expects (value, default)"), true
+	}
+	return CoalesceFunc(ctx, args...)
+}
+
+// nullif:  NULL if its two arguments are equal, else the first
+//
+//   nullif(status, "")   => NULL of an empty status
+//
+func NullIfFunc(ctx expr.EvalContext, a, b value.Value) (value.Value, bool) {
+	if isNull(a) {
+		return nil, false
+	}
+	if !isNull(b) {
+		if eq, err := value.Equal(a, b); err == nil && eq {
+			return nil, false
+		}
+	}
+	return a, true
+}
+
+// if:  the second argument if the first is true, else the third (or NULL),
+// evaluating only the branch taken
+//
+//   if(ct > 10, "many", "few")
+//
+func IfFunc(ctx expr.EvalContext, args ...expr.LazyArg) (value.Value, bool) {
+	if len(args) < 2 || len(args) > 3 {
+		return value.NewErrorValue("if: expects (condition, then [, else])"), true
+	}
+	cond := args[0]()
+	if cond != nil && cond.Err() {
+		return cond, true
+	}
+	if truthy(cond) {
+		return nullable(args[1]())
+	}
+	if len(args) == 3 {
+		return nullable(args[2]())
+	}
+	return nil, false
+}
+
+// nullable a value, not ok if NULL
+func nullable(v value.Value) (value.Value, bool) {
+	if isNull(v) {
+		return nil, false
+	}
+	return v, true
+}
+
+// truthy is a condition true, as mysql of numbers not zero
+func truthy(v value.Value) bool {
+	switch vt := v.(type) {
+	case value.BoolValue:
+		return vt.Val()
+	case value.StringValue:
+		b, ok := value.ToBool(vt.Rv())
+		return ok && b
+	}
+	if isNull(v) {
+		return false
+	}
+	f, ok := value.ValueToFloat64(v)
+	return ok && f != 0
+}
+
+// greatest:  the largest of its arguments, NULL if any is.  Compared as
+// numbers if all are, times if all are, else as strings.
+//
+//   greatest(2, 10, 5)        => 10
+//   greatest("b", "a", "c")   => "c"
+//
+func GreatestFunc(ctx expr.EvalContext, vals ...value.Value) (value.Value, bool) {
+	return extremeOf(vals, 1)
+}
+
+// least:  the smallest of its arguments, NULL if any is, see greatest
+//
+//   least(2, 10, 5)   => 2
+//
+func LeastFunc(ctx expr.EvalContext, vals ...value.Value) (value.Value, bool) {
+	return extremeOf(vals, -1)
+}
+
+// extremeOf the value of vals comparing sign (1 greatest, -1 least) to
+// the others
+func extremeOf(vals []value.Value, sign int) (value.Value, bool) {
+	if len(vals) == 0 {
+		return nil, false
+	}
+	numbers, times := true, true
+	for _, v := range vals {
+		if isNull(v) {
+			return nil, false
+		}
+		if v.Err() {
+			return v, true
+		}
+		switch v.(type) {
+		case value.NumericValue:
+			times = false
+		case value.TimeValue:
+			numbers = false
+		default:
+			numbers, times = false, false
+		}
+	}
+	best := vals[0]
+	for _, v := range vals[1:] {
+		var cmp int
+		switch {
+		case numbers:
+			a, b := v.(value.NumericValue).Float(), best.(value.NumericValue).Float()
+			switch {
+			case a > b:
+				cmp = 1
+			case a < b:
+				cmp = -1
+			}
+		case times:
+			a, b := v.(value.TimeValue).Val(), best.(value.TimeValue).Val()
+			switch {
+			case a.After(b):
+				cmp = 1
+			case a.Before(b):
+				cmp = -1
+			}
+		default:
+			cmp = strings.Compare(v.ToString(), best.ToString())
+		}
+		if cmp == sign {
+			best = v
+		}
+	}
+	return best, true
+}
@@ -0,0 +1,494 @@
+package builtins
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/araddon/dateparse"
+	"github.com/lytics/datemath"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// The conversion functions are NULL of NULL, and an ErrorValue of a value
+// that does not convert, rather than the NULL of implicit coercion, so
+// careful pipelines fail on bad input instead of dropping it.  Numbers and
+// dates of other locales convert of an optional locale argument ("de",
+// "fr-FR", ...).
+
+// numberLocale the separators of numbers of a locale
+type numberLocale struct {
+	group   string
+	decimal string
+}
+
+// dateLocale the names of months and days of a locale, lower case of
+// their english names
+type dateLocale map[string]string
+
+var (
+	numberLocales = map[string]numberLocale{
+		"en": {",", "."},
+		"de": {".", ","},
+		"es": {".", ","},
+		"it": {".", ","},
+		"nl": {".", ","},
+		"pt": {".", ","},
+		"fr": {" ", ","},
+		"ch": {"'", "."},
+	}
+	dateLocales = map[string]dateLocale{
+		"en": {},
+		"de": newDateLocale(
+			"januar februar märz april mai juni juli august september oktober november dezember",
+			"jan feb mär apr mai jun jul aug sep okt nov dez",
+			"sonntag montag dienstag mittwoch donnerstag freitag samstag",
+			"so mo di mi do fr sa"),
+		"es": newDateLocale(
+			"enero febrero marzo abril mayo junio julio agosto septiembre octubre noviembre diciembre",
+			"ene feb mar abr may jun jul ago sep oct nov dic",
+			"domingo lunes martes miércoles jueves viernes sábado",
+			"dom lun mar mié jue vie sáb"),
+		"fr": newDateLocale(
+			"janvier février mars avril mai juin juillet août septembre octobre novembre décembre",
+			"janv févr mars avr mai juin juil août sept oct nov déc",
+			"dimanche lundi mardi mercredi jeudi vendredi samedi",
+			"dim lun mar mer jeu ven sam"),
+		"it": newDateLocale(
+			"gennaio febbraio marzo aprile maggio giugno luglio agosto settembre ottobre novembre dicembre",
+			"gen feb mar apr mag giu lug ago set ott nov dic",
+			"domenica lunedì martedì mercoledì giovedì venerdì sabato",
+			"dom lun mar mer gio ven sab"),
+		"nl": newDateLocale(
+			"januari februari maart april mei juni juli augustus september oktober november december",
+			"jan feb mrt apr mei jun jul aug sep okt nov dec",
+			"zondag maandag dinsdag woensdag donderdag vrijdag zaterdag",
+			"zo ma di wo do vr za"),
+		"pt": newDateLocale(
+			"janeiro fevereiro março abril maio junho julho agosto setembro outubro novembro dezembro",
+			"jan fev mar abr mai jun jul ago set out nov dez",
+			"domingo segunda-feira terça-feira quarta-feira quinta-feira sexta-feira sábado",
+			"dom seg ter qua qui sex sáb"),
+	}
+
+	// strftimeLayouts the go layouts of strftime directives, for layouts of
+	// % directives of todate and tostring
+	strftimeLayouts = map[byte]string{
+		'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2", 'j': "002",
+		'H': "15", 'I': "03", 'M': "04", 'S': "05", 'f': "000000", 'p': "PM",
+		'b': "Jan", 'h': "Jan", 'B': "January", 'a': "Mon", 'A': "Monday",
+		'z': "-0700", 'Z': "MST", '%': "%",
+	}
+)
+
+// newDateLocale the names of a locale of its (space separated) months,
+// abbreviated months, days of week from sunday and abbreviated days
+func newDateLocale(months, shortMonths, days, shortDays string) dateLocale {
+	names := make(dateLocale)
+	add := func(local, english []string) {
+		for i, name := range local {
+			if _, exists := names[name]; !exists {
+				names[name] = english[i]
+			}
+		}
+	}
+	// full names first, so of a name both full and abbreviated (mars, mai)
+	// the full english name is kept
+	add(strings.Fields(months), strings.Fields("January February March April May June July August September October November December"))
+	add(strings.Fields(days), strings.Fields("Sunday Monday Tuesday Wednesday Thursday Friday Saturday"))
+	add(strings.Fields(shortMonths), strings.Fields("Jan Feb Mar Apr May Jun Jul Aug Sep Oct Nov Dec"))
+	add(strings.Fields(shortDays), strings.Fields("Sun Mon Tue Wed Thu Fri Sat"))
+	return names
+}
+
+// localeName the language of a locale, "fr" of "fr-FR" or "fr_FR"
+func localeName(locale string) string {
+	locale = strings.ToLower(locale)
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		locale = locale[:i]
+	}
+	return locale
+}
+
+// parseLocaleNumber a number of the separators of locale, of an optional
+// currency symbol
+func parseLocaleNumber(s, locale string) (float64, error) {
+	loc, ok := numberLocales[localeName(locale)]
+	if !ok {
+		return 0, fmt.Errorf("unknown locale %q", locale)
+	}
+	n := strings.TrimFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.Is(unicode.Sc, r)
+	})
+	if i := strings.Index(n, loc.decimal); i >= 0 && strings.Contains(n[i+1:], loc.group) {
+		// separators of another locale, "5,555.00" of "de"
+		return 0, fmt.Errorf("could not convert %q as a number of locale %q", s, locale)
+	}
+	if loc.group == " " {
+		n = strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "").Replace(n)
+	} else {
+		n = strings.Replace(n, loc.group, "", -1)
+	}
+	n = strings.Replace(n, loc.decimal, ".", 1)
+	f, err := strconv.ParseFloat(n, 64)
+	if err != nil {
+		return 0, fmt.Errorf("could not convert %q as a number of locale %q", s, locale)
+	}
+	return f, nil
+}
+
+// toFloat the float of a value, of locale if not empty
+func toFloat(v value.Value, locale string) (float64, error) {
+	switch vt := v.(type) {
+	case value.BoolValue:
+		if vt.Val() {
+			return 1, nil
+		}
+		return 0, nil
+	case value.NumericValue:
+		return vt.Float(), nil
+	}
+	if locale != "" {
+		s, ok := stringArg(v)
+		if !ok {
+			return 0, fmt.Errorf("could not convert %v to a number", v.Value())
+		}
+		return parseLocaleNumber(s, locale)
+	}
+	f, ok := value.ToFloat64(reflect.ValueOf(v.Value()))
+	if !ok || math.IsNaN(f) {
+		return 0, fmt.Errorf("could not convert %q to a number", v.ToString())
+	}
+	return f, nil
+}
+
+// optionalArg the string of an optional argument at i, empty if none
+func optionalArg(args []value.Value, i int) (string, bool) {
+	if len(args) <= i {
+		return "", true
+	}
+	return stringArg(args[i])
+}
+
+// toint:  an integer of a value, of the separators of an optional locale.
+//   Numbers are truncated, times are unix milliseconds.
+//
+//   toint("5")                => 5
+//   toint("5.75")             => 5
+//   toint("$5,555.00")        => 5555
+//   toint("5.555,00", "de")   => 5555
+//   toint("hello")            => error
+//
+func ToInt(ctx expr.EvalContext, args ...value.Value) (value.Value, bool) {
+	if len(args) < 1 || len(args) > 2 {
+		return value.NewErrorValue("toint: expects (value [, locale])"), true
+	}
+	if isNull(args[0]) {
+		return nil, false
+	}
+	switch vt := args[0].(type) {
+	case value.TimeValue:
+		return value.NewIntValue(vt.Val().UnixNano() / 1e6), true
+	case value.IntValue:
+		return vt, true
+	}
+	locale, ok := optionalArg(args, 1)
+	if !ok {
+		return value.NewErrorValue("toint: locale must be a string"), true
+	}
+	if s, ok := args[0].(value.StringValue); ok && locale == "" {
+		// exactly, of integers past the precision of a float
+		if iv, err := strconv.ParseInt(strings.TrimSpace(s.Val()), 10, 64); err == nil {
+			return value.NewIntValue(iv), true
+		}
+	}
+	f, err := toFloat(args[0], locale)
+	if err != nil {
+		return value.NewErrorValuef("toint: %v", err), true
+	}
+	if math.IsInf(f, 0) || f > math.MaxInt64 || f < math.MinInt64 {
+		return value.NewErrorValuef("toint: %v out of range", args[0].ToString()), true
+	}
+	return value.NewIntValue(int64(f)), true
+}
+
+// tofloat:  a number of a value, of the separators of an optional locale.
+//   Also tonumber.
+//
+//   tofloat("5.75")             => 5.75
+//   tofloat("€ 5,555.00")       => 5555
+//   tofloat("5 555,25", "fr")   => 5555.25
+//
+func ToNumber(ctx expr.EvalContext, args ...value.Value) (value.Value, bool) {
+	if len(args) < 1 || len(args) > 2 {
+		return value.NewErrorValue("tofloat: expects (value [, locale])"), true
+	}
+	if isNull(args[0]) {
+		return nil, false
+	}
+	locale, ok := optionalArg(args, 1)
+	if !ok {
+		return value.NewErrorValue("tofloat: locale must be a string"), true
+	}
+	f, err := toFloat(args[0], locale)
+	if err != nil {
+		return value.NewErrorValuef("tofloat: %v", err), true
+	}
+	return value.NewNumberValue(f), true
+}
+
+// tobool:  a boolean of a value, of true/false, yes/no, on/off, t/f, y/n
+//   or 1/0 (of any case)
+//
+//   tobool("Yes")   => true
+//   tobool(0)       => false
+//   tobool("maybe") => error
+//
+func ToBoolFunc(ctx expr.EvalContext, item value.Value) (value.Value, bool) {
+	if isNull(item) {
+		return nil, false
+	}
+	switch vt := item.(type) {
+	case value.BoolValue:
+		return vt, true
+	case value.NumericValue:
+		switch vt.Float() {
+		case 0:
+			return value.BoolValueFalse, true
+		case 1:
+			return value.BoolValueTrue, true
+		}
+		return value.NewErrorValuef("tobool: could not convert %v, not 0 or 1", item.Value()), true
+	}
+	s, ok := stringArg(item)
+	if ok {
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "true", "t", "yes", "y", "on", "1":
+			return value.BoolValueTrue, true
+		case "false", "f", "no", "n", "off", "0":
+			return value.BoolValueFalse, true
+		}
+	}
+	return value.NewErrorValuef("tobool: could not convert %q", item.ToString()), true
+}
+
+// tostring:  the string of a value, of an optional format.  Times format
+//   of a go layout or strftime directives (default RFC3339), numbers of a
+//   printf verb.
+//
+//   tostring(5)                                 => "5"
+//   tostring(todate("2014-04-07"), "%Y/%m/%d")  => "2014/04/07"
+//   tostring(todate("2014-04-07"), "Jan 2")     => "Apr 7"
+//   tostring(3.14159, "%.2f")                   => "3.14"
+//
+func ToStringFunc(ctx expr.EvalContext, args ...value.Value) (value.Value, bool) {
+	if len(args) < 1 || len(args) > 2 {
+		return value.NewErrorValue("tostring: expects (value [, format])"), true
+	}
+	item := args[0]
+	if isNull(item) {
+		return nil, false
+	}
+	format, ok := optionalArg(args, 1)
+	if !ok {
+		return value.NewErrorValue("tostring: format must be a string"), true
+	}
+	switch vt := item.(type) {
+	case value.TimeValue:
+		if format == "" {
+			return value.NewStringValue(vt.Val().Format(time.RFC3339Nano)), true
+		}
+		layout, err := goLayout(format)
+		if err != nil {
+			return value.NewErrorValuef("tostring: %v", err), true
+		}
+		return value.NewStringValue(vt.Val().Format(layout)), true
+	case value.IntValue, value.NumberValue:
+		if format == "" {
+			return value.NewStringValue(item.ToString()), true
+		}
+		s := fmt.Sprintf(format, item.Value())
+		if strings.Contains(s, "%!") {
+			return value.NewErrorValuef("tostring: bad format %q of %v", format, item.Value()), true
+		}
+		return value.NewStringValue(s), true
+	}
+	if format != "" {
+		return value.NewErrorValuef("tostring: no format of %s values", item.Type()), true
+	}
+	switch vt := item.(type) {
+	case value.StringValue:
+		return vt, true
+	case value.ByteSliceValue:
+		return value.NewStringValue(string(vt.Val())), true
+	}
+	return value.NewStringValue(item.ToString()), true
+}
+
+// goLayout the go layout of a layout of strftime % directives, or the
+// layout itself of none
+func goLayout(layout string) (string, error) {
+	if !strings.Contains(layout, "%") {
+		return layout, nil
+	}
+	var buf strings.Builder
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' {
+			buf.WriteByte(layout[i])
+			continue
+		}
+		i++
+		if i == len(layout) {
+			return "", fmt.Errorf("layout %q ends in %%", layout)
+		}
+		l, ok := strftimeLayouts[layout[i]]
+		if !ok {
+			return "", fmt.Errorf("unsupported directive %%%c of layout %q", layout[i], layout)
+		}
+		buf.WriteString(l)
+	}
+	return buf.String(), nil
+}
+
+// englishDate the date of month and day names of a locale in english
+func englishDate(s string, names dateLocale) string {
+	if len(names) == 0 {
+		return s
+	}
+	var buf strings.Builder
+	word := make([]rune, 0, 16)
+	flush := func() {
+		if len(word) > 0 {
+			w := string(word)
+			if en, ok := names[strings.ToLower(w)]; ok {
+				w = en
+			}
+			buf.WriteString(w)
+			word = word[:0]
+		}
+	}
+	for _, r := range s {
+		if unicode.IsLetter(r) || (r == '-' && len(word) > 0) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		buf.WriteRune(r)
+	}
+	flush()
+	return buf.String()
+}
+
+// parseTime the time of the arguments of todate and totimestamp, a value
+// of optional layouts (tried in order) then an optional locale.  Not ok of
+// a NULL value.
+func parseTime(args []value.Value) (time.Time, bool, error) {
+	if len(args) == 0 {
+		return time.Time{}, false, fmt.Errorf("expects (value [, layout...] [, locale])")
+	}
+	if isNull(args[0]) {
+		return time.Time{}, false, nil
+	}
+	switch vt := args[0].(type) {
+	case value.TimeValue:
+		return vt.Val(), true, nil
+	case value.IntValue:
+		// unix seconds
+		return time.Unix(vt.Val(), 0).In(time.UTC), true, nil
+	}
+	dateStr, ok := stringArg(args[0])
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("could not convert %v to a date", args[0].Value())
+	}
+	layouts := make([]string, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		layout, ok := stringArg(arg)
+		if !ok {
+			return time.Time{}, false, fmt.Errorf("layout must be a string, not %v", arg.Value())
+		}
+		layouts = append(layouts, layout)
+	}
+	var names dateLocale
+	if n := len(layouts); n > 0 {
+		if loc, ok := dateLocales[localeName(layouts[n-1])]; ok {
+			names, layouts = loc, layouts[:n-1]
+		}
+	}
+	dateStr = englishDate(dateStr, names)
+
+	if len(layouts) == 0 {
+		if len(dateStr) > 3 && strings.ToLower(dateStr[:3]) == "now" {
+			// Is date math
+			t, err := datemath.Eval(dateStr[3:])
+			if err != nil {
+				return time.Time{}, false, fmt.Errorf("could not evaluate date math %q", dateStr)
+			}
+			return t, true, nil
+		}
+		t, err := dateparse.ParseAny(dateStr)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("could not parse %q as a date", dateStr)
+		}
+		return t, true, nil
+	}
+	for _, layout := range layouts {
+		l, err := goLayout(layout)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		if t, err := time.Parse(l, dateStr); err == nil {
+			return t, true, nil
+		}
+	}
+	if len(args) == 2 && names == nil {
+		// todate(layout, value) of before layouts followed the value
+		if t, err := time.Parse(dateStr, layouts[0]); err == nil {
+			return t, true, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("could not parse %q of layouts %q", dateStr, layouts)
+}
+
+// todate:  a time of a value, of the first of the layouts (go layouts or
+//   strftime directives) it parses of, of month and day names of an
+//   optional last locale argument.  Without a layout araddon/dateparse
+//   recognizes the format, and "now-3m" is date math of lytics/datemath.
+//
+//   todate("now-3m")
+//   todate(field)
+//   todate("07/04/2014", "02/01/2006")              => 2014-04-07
+//   todate("2014-04-07", "%d/%m/%Y", "%Y-%m-%d")    => 2014-04-07
+//   todate("7 avril 2014", "2 January 2006", "fr")  => 2014-04-07
+//
+func ToDate(ctx expr.EvalContext, args ...value.Value) (value.Value, bool) {
+	t, ok, err := parseTime(args)
+	if err != nil {
+		return value.NewErrorValuef("todate: %v", err), true
+	}
+	if !ok {
+		return nil, false
+	}
+	return value.NewTimeValue(t), true
+}
+
+// totimestamp:  the unix seconds of a time, of the arguments of todate
+//
+//   totimestamp("Apr 7, 2014 4:58:55 PM")               => 1396889935
+//   totimestamp("07.04.2014", "%d.%m.%Y")               => 1396828800
+//
+func ToTimestamp(ctx expr.EvalContext, args ...value.Value) (value.Value, bool) {
+	t, ok, err := parseTime(args)
+	if err != nil {
+		return value.NewErrorValuef("totimestamp: %v", err), true
+	}
+	if !ok {
+		return nil, false
+	}
+	return value.NewIntValue(t.Unix()), true
+}
@@ -0,0 +1,233 @@
+package builtins
+
+import (
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/expr"
+)
+
+// builtinDoc the signature and description of a builtin
+type builtinDoc struct {
+	name      string
+	signature string
+	doc       string
+}
+
+// builtinDocs the documentation of the builtins by category, for
+// SHOW FUNCTIONS and expr.FuncsInfo.  The doc comments of the funcs have
+// the details and examples.
+var builtinDocs = map[string][]builtinDoc{
+	"math": {
+		{"sqrt", "(x)", "the square root of a number"},
+		{"pow", "(x, y)", "x raised to the power of y"},
+	},
+	"aggregate": {
+		{"count", "(value)", "the number of rows of non NULL values"},
+		{"avg", "(value)", "the average of the values"},
+		{"sum", "(value)", "the sum of the values"},
+		{"approx_count_distinct", "(value)", "the estimated number of distinct non NULL values, of a fixed size sketch"},
+	},
+	"window": {
+		{"row_number", "()", "the 1 based position of the row in its window partition"},
+		{"rank", "()", "the rank of the row in its partition, of gaps after ties"},
+		{"dense_rank", "()", "the rank of the row in its partition, without gaps after ties"},
+		{"lag", "(value [, offset [, default]])", "the value of the row offset (default 1) rows before this one"},
+		{"lead", "(value [, offset [, default]])", "the value of the row offset (default 1) rows after this one"},
+		{"first_value", "(value)", "the value of the first row of the window frame"},
+		{"last_value", "(value)", "the value of the last row of the window frame"},
+	},
+	"logical": {
+		{"gt", "(a, b)", "is a greater than b, as numbers"},
+		{"ge", "(a, b)", "is a greater than or equal to b, as numbers"},
+		{"ne", "(a, b)", "are a and b not equal"},
+		{"le", "(a, b)", "is a less than or equal to b, as numbers"},
+		{"lt", "(a, b)", "is a less than b, as numbers"},
+		{"not", "(value)", "the negation of a boolean"},
+		{"eq", "(a, b)", "are a and b equal"},
+		{"exists", "(value)", "does the field exist and is it non NULL"},
+		{"map", "(key, value)", "a map of one key and value"},
+	},
+	"date": {
+		{"now", "()", "the time of the message, else the current time"},
+		{"yy", "([time])", "the 2 digit year of a time"},
+		{"yymm", "([time])", "the 4 digit year and month of a time"},
+		{"mm", "([time])", "the month of a time, 1 to 12"},
+		{"monthofyear", "([time])", "the month of a time, 1 to 12.  Also mm."},
+		{"dayofweek", "([time])", "the day of the week of a time, 0 (sunday) to 6"},
+		{"hourofday", "([time])", "the hour of the day of a time, 0 to 23"},
+		{"hourofweek", "([time])", "the hour of the week of a time, 0 to 167"},
+		{"totimestamp", "(value [, layout...] [, locale])", "the unix seconds of a time, parsed as todate"},
+		{"todate", "(value [, layout...] [, locale])", "a time of a value, of go layouts or strftime directives and locale month names"},
+		{"seconds", "(value)", "the seconds of a duration or time of day"},
+		{"maptime", "(key [, time])", "a map of the key to a time, the message time if none given"},
+		{"tumble", "(time, duration)", "the start of the tumbling window of a duration the time is in"},
+		{"timebucket", "(time, duration)", "the start of the tumbling window of a duration the time is in.  Also tumble."},
+		{"date_trunc", "(unit, time)", "a time truncated to the start of its minute, hour, day, week, month, quarter or year"},
+		{"extract", "(time, format)", "the parts of a time of strftime directives"},
+	},
+	"string": {
+		{"contains", "(str, substr)", "does a string contain a substring"},
+		{"tolower", "(str)", "a string in lower case"},
+		{"lower", "(str)", "a string in lower case"},
+		{"lcase", "(str)", "a string in lower case"},
+		{"upper", "(str)", "a string in upper case"},
+		{"ucase", "(str)", "a string in upper case"},
+		{"toupper", "(str)", "a string in upper case"},
+		{"split", "(str, sep)", "an array of a string split by a separator"},
+		{"replace", "(str, old [, new])", "a string of every old replaced by new (default empty)"},
+		{"join", "(values..., sep)", "the values (or array) joined by a separator"},
+		{"hassuffix", "(str, suffix)", "does a string end with a suffix"},
+		{"hasprefix", "(str, prefix)", "does a string begin with a prefix"},
+		{"substr", "(str, pos [, length])", "the characters of a string from pos (1 based, negative from the end)"},
+		{"substring", "(str, pos [, length])", "the characters of a string from pos.  Also substr."},
+		{"left", "(str, n)", "the first n characters of a string"},
+		{"right", "(str, n)", "the last n characters of a string"},
+		{"trim", "(str [, remove])", "a string of leading and trailing spaces (or remove) removed"},
+		{"ltrim", "(str [, remove])", "a string of leading spaces (or remove) removed"},
+		{"rtrim", "(str [, remove])", "a string of trailing spaces (or remove) removed"},
+		{"lpad", "(str, n, pad)", "a string left padded by pad to n characters"},
+		{"rpad", "(str, n, pad)", "a string right padded by pad to n characters"},
+		{"reverse", "(str)", "the characters of a string in reverse order"},
+		{"repeat", "(str, count)", "a string repeated count times"},
+		{"instr", "(str, substr)", "the 1 based position of substr in a string, 0 if not found"},
+		{"locate", "(substr, str [, pos])", "the 1 based position of substr in a string from pos, 0 if not found"},
+		{"position", "(substr, str)", "the 1 based position of substr in a string.  Also locate."},
+		{"format", "(number, decimals)", "a number rounded to decimals, of thousands separated by commas"},
+		{"char_length", "(str)", "the number of characters of a string"},
+		{"character_length", "(str)", "the number of characters of a string.  Also char_length."},
+	},
+	"conversion": {
+		{"toint", "(value [, locale])", "an integer of a value, of the number separators of a locale"},
+		{"tonumber", "(value [, locale])", "a number of a value, of the number separators of a locale"},
+		{"tofloat", "(value [, locale])", "a number of a value.  Also tonumber."},
+		{"tobool", "(value)", "a boolean of true/false, yes/no, on/off, t/f, y/n or 1/0"},
+		{"tostring", "(value [, format])", "the string of a value, of a time layout or number format"},
+		{"cast", "(value AS type)", "a value converted to char, string, int or float"},
+	},
+	"uuid": {
+		{"uuid", "()", "a random (version 4) uuid"},
+		{"uuid_v7", "()", "a time ordered (version 7) uuid"},
+		{"is_uuid", "(str)", "is a string a uuid"},
+		{"uuid_to_bin", "(uuid [, swap])", "the 16 bytes of a uuid"},
+		{"bin_to_uuid", "(bytes [, swap])", "the uuid of 16 bytes"},
+	},
+	"regexp": {
+		{"regexp_match", "(str, pattern)", "does a string match a regular expression"},
+		{"regexp_like", "(str, pattern)", "does a string match a regular expression.  Also regexp_match."},
+		{"regexp_extract", "(str, pattern [, group])", "the first match of a regular expression, or of its capture group"},
+		{"regexp_replace", "(str, pattern, replacement)", "a string of every match of a regular expression replaced"},
+	},
+	"json": {
+		{"json_extract", "(json, path...)", "the part of a json document at a path"},
+		{"json_unquote", "(json)", "the text of a json value, strings unquoted"},
+		{"json_set", "(json, path, value...)", "a json document of the values at paths set"},
+		{"json_length", "(json [, path])", "the number of members or elements of a json document"},
+		{"json_array_length", "(json [, path])", "the number of elements of a json array"},
+		{"json_keys", "(json [, path])", "the sorted member names of a json object"},
+	},
+	"array": {
+		{"len", "(value)", "the length of an array, map or string"},
+		{"array.index", "(array, pos)", "the element of an array at a (0 based) position"},
+		{"array.slice", "(array, start [, end])", "the elements of an array from start to end (0 based)"},
+		{"array_contains", "(array, value)", "does an array have an element equal to a value"},
+		{"array_length", "(array)", "the number of elements of an array"},
+		{"array_join", "(array, sep [, null_as])", "the elements of an array joined by a separator"},
+		{"array_distinct", "(array)", "the elements of an array without repeats"},
+		{"slice", "(array, start, length)", "length elements of an array from start (1 based, negative from the end)"},
+		{"flatten", "(array)", "the elements of an array of arrays in one array"},
+		{"unnest", "(array)", "as a select column, a row per element of the array"},
+	},
+	"geo": {
+		{"haversine", "(lat1, lon1, lat2, lon2 | p1, p2 [, unit])", "the great circle distance of two points in km, m or mi"},
+		{"st_contains", "(polygon, point)", "is a point inside a polygon"},
+		{"in_bbox", "(point, min_lat, min_lon, max_lat, max_lon)", "is a point inside a bounding box"},
+		{"geohash_encode", "(lat, lon | point [, precision])", "the geohash of a point"},
+		{"geohash_decode", "(geohash)", "the center [lat, lon] of a geohash cell"},
+	},
+	"conditional": {
+		{"coalesce", "(values...)", "the first argument not NULL"},
+		{"ifnull", "(value, default)", "the value, or the default if it is NULL"},
+		{"nullif", "(a, b)", "NULL if a equals b, else a"},
+		{"if", "(cond, then [, else])", "then if cond is true else else, evaluating only the branch taken"},
+		{"greatest", "(values...)", "the largest argument"},
+		{"least", "(values...)", "the smallest argument"},
+		{"oneof", "(values...)", "the first argument not NULL"},
+		{"any", "(values...)", "are any of the arguments truthy"},
+		{"all", "(values...)", "are all of the arguments truthy"},
+	},
+	"map": {
+		{"match", "(prefix...)", "a map of the fields of prefixes, of the prefix removed from keys"},
+		{"mapkeys", "(maps...)", "the distinct keys of maps"},
+		{"mapvalues", "(maps...)", "the distinct values of maps"},
+		{"mapinvert", "(map)", "a map of the keys and values swapped"},
+		{"map_keys", "(map)", "the sorted keys of a map"},
+		{"map_values", "(map)", "the values of a map in the order of its sorted keys"},
+		{"map_get", "(map, key [, default])", "the value of a key of a map, or the default"},
+		{"has_key", "(map, key)", "does a map have a key"},
+		{"map_filter", "(map, patterns...)", "the entries of a map of keys matching a prefix or wildcard"},
+		{"filter", "(value, patterns...)", "a map or array of the keys or values matching a pattern removed"},
+	},
+	"url": {
+		{"email", "(str)", "the address of an email"},
+		{"emaildomain", "(str)", "the domain of an email address"},
+		{"emailname", "(str)", "the name of an email address"},
+		{"domain", "(url)", "the domain of a url"},
+		{"domains", "(urls...)", "the domains of urls"},
+		{"host", "(url)", "the lower case host of a url"},
+		{"hosts", "(urls...)", "the hosts of urls"},
+		{"path", "(url)", "the lower case path of a url"},
+		{"qs", "(url, name)", "the value of a query parameter of a (lower cased) url"},
+		{"urlmain", "(url)", "a url without its scheme and query"},
+		{"urlminusqs", "(url, name)", "a url of a query parameter removed"},
+		{"urldecode", "(str)", "a query unescaped string"},
+		{"urlencode", "(str)", "a query escaped string"},
+		{"urlhost", "(url)", "the lower case host of a url, without the port"},
+		{"urlpath", "(url)", "the decoded path of a url"},
+		{"urlparam", "(url, name)", "the decoded value of a query parameter of a url"},
+		{"urldecodequery", "(url)", "a map of the decoded query parameters of a url"},
+		{"useragent", "(ua, field)", "the browser, browser_version, os, os_version or device of a user-agent"},
+	},
+	"ip": {
+		{"inet_aton", "(ip)", "the integer of an ipv4 address"},
+		{"inet_ntoa", "(int)", "the ipv4 address of an integer"},
+		{"ip_in_cidr", "(ip, cidr)", "is an ip address in the network of a cidr"},
+		{"is_private_ip", "(ip)", "is an ip address of a private network"},
+		{"ip_normalize", "(ip)", "the canonical text of an ip address"},
+	},
+	"encoding": {
+		{"to_base64", "(str)", "the base64 encoding of a string or bytes"},
+		{"from_base64", "(str)", "the bytes of a base64 string"},
+		{"hex", "(value)", "the upper case hexadecimal of an integer or string"},
+		{"unhex", "(str)", "the bytes of a hexadecimal string"},
+		{"quote", "(str)", "a string quoted as a mysql string literal"},
+		{"escape", "(str)", "a string escaped as a mysql string literal, without quotes"},
+	},
+	"hash": {
+		{"hash.md5", "(value)", "the hex md5 of a value"},
+		{"hash.sha1", "(value)", "the hex sha1 of a value"},
+		{"hash.sha256", "(value)", "the hex sha256 of a value"},
+		{"hash.sha512", "(value)", "the hex sha512 of a value"},
+		{"md5", "(value)", "the hex md5 of a value"},
+		{"sha1", "(value)", "the hex sha1 of a value"},
+		{"sha256", "(value)", "the hex sha256 of a value"},
+		{"sha512", "(value)", "the hex sha512 of a value"},
+		{"hash", "(value)", "the non negative 64 bit fnv-1a hash of a value.  Also fnv64."},
+		{"fnv64", "(value)", "the non negative 64 bit fnv-1a hash of a value"},
+		{"crc32", "(value)", "the crc32 checksum of a value"},
+		{"murmur3", "(value [, seed])", "the 32 bit murmur3 hash of a value"},
+		{"hmac_sha256", "(key, value)", "the hex hmac-sha256 of a value"},
+		{"tokenize", "(value, keyring)", "a deterministic, format preserving pseudonym of a value"},
+	},
+}
+
+// describeBuiltins document the builtins
+func describeBuiltins() {
+	for category, docs := range builtinDocs {
+		for _, d := range docs {
+			doc := expr.FuncDoc{Category: category, Signature: d.signature, Doc: d.doc}
+			if !expr.FuncDescribe(d.name, doc) {
+				u.Warnf("no builtin %q to describe", d.name)
+			}
+		}
+	}
+}
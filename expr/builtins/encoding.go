@@ -0,0 +1,139 @@
+package builtins
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// bytesArg the bytes of a function argument, of byte slices as is, else
+// of the string of it
+func bytesArg(v value.Value) ([]byte, bool) {
+	if bv, ok := v.(value.ByteSliceValue); ok {
+		return bv.Val(), true
+	}
+	val, ok := stringArg(v)
+	if !ok {
+		return nil, false
+	}
+	return []byte(val), true
+}
+
+// to_base64:  the base64 encoding of a string or bytes
+//
+//   to_base64("abc")   => "YWJj"
+//
+func ToBase64Func(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
+	by, ok := bytesArg(item)
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	return value.NewStringValue(base64.StdEncoding.EncodeToString(by)), true
+}
+
+// from_base64:  the bytes of a base64 string (padded or not), NULL if not
+// base64
+//
+//   from_base64("YWJj")   => abc
+//
+func FromBase64Func(ctx expr.EvalContext, item value.Value) (value.ByteSliceValue, bool) {
+	val, ok := stringArg(item)
+	if !ok {
+		return value.NewByteSliceValue(nil), false
+	}
+	by, err := base64.StdEncoding.DecodeString(val)
+	if err != nil {
+		if by, err = base64.RawStdEncoding.DecodeString(val); err != nil {
+			return value.NewByteSliceValue(nil), false
+		}
+	}
+	return value.NewByteSliceValue(by), true
+}
+
+// hex:  the hexadecimal of an integer, or of the bytes of a string, as
+// mysql upper case
+//
+//   hex(255)     => "FF"
+//   hex("abc")   => "616263"
+//
+func HexFunc(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
+	switch vt := item.(type) {
+	case value.IntValue:
+		return value.NewStringValue(strings.ToUpper(strconv.FormatUint(uint64(vt.Val()), 16))), true
+	case value.NumberValue:
+		if vt.Nil() {
+			return value.EmptyStringValue, false
+		}
+		return value.NewStringValue(strings.ToUpper(strconv.FormatUint(uint64(vt.Int()), 16))), true
+	}
+	by, ok := bytesArg(item)
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	return value.NewStringValue(strings.ToUpper(hex.EncodeToString(by))), true
+}
+
+// unhex:  the bytes of a hexadecimal string, NULL if not hexadecimal
+//
+//   unhex("616263")   => abc
+//
+func UnhexFunc(ctx expr.EvalContext, item value.Value) (value.ByteSliceValue, bool) {
+	val, ok := stringArg(item)
+	if !ok {
+		return value.NewByteSliceValue(nil), false
+	}
+	if len(val)%2 == 1 {
+		val = "0" + val
+	}
+	by, err := hex.DecodeString(val)
+	if err != nil {
+		return value.NewByteSliceValue(nil), false
+	}
+	return value.NewByteSliceValue(by), true
+}
+
+// urlencode:  a string query escaped, of spaces +
+//
+//   urlencode("a b&c")   => "a+b%26c"
+//
+func UrlEncode(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
+	by, ok := bytesArg(item)
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	return value.NewStringValue(url.QueryEscape(string(by))), true
+}
+
+// sqlEscaper the escapes of mysql quote()
+var sqlEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\x00", `\0`, "\x1a", `\Z`)
+
+// quote:  a string quoted as a mysql string literal, of its backslashes,
+// single quotes, NUL and Control+Z escaped.  NULL is the word NULL.
+//
+//   quote("Don't!")   => 'Don\'t!'
+//   quote(NULL)       => NULL
+//
+func QuoteFunc(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
+	by, ok := bytesArg(item)
+	if !ok {
+		return value.NewStringValue("NULL"), true
+	}
+	return value.NewStringValue("'" + sqlEscaper.Replace(string(by)) + "'"), true
+}
+
+// escape:  a string escaped as quote does, without the quotes
+//
+//   escape("Don't!")   => Don\'t!
+//
+func EscapeFunc(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
+	by, ok := bytesArg(item)
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	return value.NewStringValue(sqlEscaper.Replace(string(by))), true
+}
@@ -0,0 +1,431 @@
+package builtins
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// Points are the values of location events as they come:  an array of
+// [lat, lon], a string "lat,lon" or WKT "POINT(lon lat)", or a map of
+// lat/lon (latitude/longitude, lng) keys.  Polygons are an array of points,
+// a flat array [lat1, lon1, lat2, lon2, ...], a string "lat,lon;lat,lon;..."
+// or WKT "POLYGON((lon lat, ...))" of its outer ring.
+
+const (
+	earthRadiusKm = 6371.0088
+	geohashBase32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+// geoPoint a latitude and longitude in degrees
+type geoPoint struct {
+	lat, lon float64
+}
+
+func (p geoPoint) valid() bool {
+	return p.lat >= -90 && p.lat <= 90 && p.lon >= -180 && p.lon <= 180
+}
+
+// floatArg the float of a numeric value or string of a number
+func floatArg(v value.Value) (float64, bool) {
+	if isNull(v) || v.Err() {
+		return 0, false
+	}
+	if nv, ok := v.(value.NumericValue); ok {
+		f := nv.Float()
+		return f, !math.IsNaN(f)
+	}
+	s, ok := stringArg(v)
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return f, err == nil
+}
+
+// parsePointText a point of "lat,lon" or "POINT(lon lat)"
+func parsePointText(s string) (geoPoint, bool) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(strings.ToUpper(s), "POINT") {
+		coords, ok := wktCoords(s[len("POINT"):])
+		if !ok || len(coords) != 1 {
+			return geoPoint{}, false
+		}
+		return coords[0], coords[0].valid()
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return geoPoint{}, false
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return geoPoint{}, false
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return geoPoint{}, false
+	}
+	p := geoPoint{lat, lon}
+	return p, p.valid()
+}
+
+// wktCoords the "lon lat" points of the text of a WKT geometry past its
+// name, of its first ring
+func wktCoords(s string) ([]geoPoint, bool) {
+	s = strings.TrimSpace(s)
+	for strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	if i := strings.Index(s, ")"); i >= 0 {
+		// holes are ignored
+		s = s[:i]
+	}
+	var pts []geoPoint
+	for _, pair := range strings.Split(s, ",") {
+		xy := strings.Fields(pair)
+		if len(xy) != 2 {
+			return nil, false
+		}
+		lon, err := strconv.ParseFloat(xy[0], 64)
+		if err != nil {
+			return nil, false
+		}
+		lat, err := strconv.ParseFloat(xy[1], 64)
+		if err != nil {
+			return nil, false
+		}
+		pts = append(pts, geoPoint{lat, lon})
+	}
+	return pts, len(pts) > 0
+}
+
+// pointArg the point of a value, see Points above
+func pointArg(v value.Value) (geoPoint, bool) {
+	if isNull(v) || v.Err() {
+		return geoPoint{}, false
+	}
+	if mv, ok := mapArg(v); ok {
+		lat, latOk := mapFloat(mv, "lat", "latitude")
+		lon, lonOk := mapFloat(mv, "lon", "lng", "longitude")
+		p := geoPoint{lat, lon}
+		return p, latOk && lonOk && p.valid()
+	}
+	if vals, ok := arrayArg(v); ok {
+		if len(vals) != 2 {
+			return geoPoint{}, false
+		}
+		lat, latOk := floatArg(vals[0])
+		lon, lonOk := floatArg(vals[1])
+		p := geoPoint{lat, lon}
+		return p, latOk && lonOk && p.valid()
+	}
+	s, ok := stringArg(v)
+	if !ok {
+		return geoPoint{}, false
+	}
+	return parsePointText(s)
+}
+
+// mapFloat the float of the first of keys a map has
+func mapFloat(mv value.MapValue, keys ...string) (float64, bool) {
+	for _, key := range keys {
+		if v, ok := mv.Get(key); ok {
+			return floatArg(v)
+		}
+	}
+	return 0, false
+}
+
+// polygonArg the points of a polygon value of at least 3 points, see
+// Polygons above
+func polygonArg(v value.Value) ([]geoPoint, bool) {
+	if isNull(v) || v.Err() {
+		return nil, false
+	}
+	var pts []geoPoint
+	if vals, ok := arrayArg(v); ok {
+		for _, pv := range vals {
+			p, ok := pointArg(pv)
+			if !ok {
+				pts = nil
+				break
+			}
+			pts = append(pts, p)
+		}
+		if pts == nil && len(vals)%2 == 0 {
+			// flat lat, lon pairs
+			for i := 0; i < len(vals); i += 2 {
+				lat, latOk := floatArg(vals[i])
+				lon, lonOk := floatArg(vals[i+1])
+				p := geoPoint{lat, lon}
+				if !latOk || !lonOk || !p.valid() {
+					return nil, false
+				}
+				pts = append(pts, p)
+			}
+		}
+	} else if s, ok := stringArg(v); ok {
+		s = strings.TrimSpace(s)
+		if strings.HasPrefix(strings.ToUpper(s), "POLYGON") {
+			if pts, ok = wktCoords(s[len("POLYGON"):]); !ok {
+				return nil, false
+			}
+		} else {
+			for _, ps := range strings.Split(s, ";") {
+				p, ok := parsePointText(ps)
+				if !ok {
+					return nil, false
+				}
+				pts = append(pts, p)
+			}
+		}
+	}
+	if n := len(pts); n > 1 && pts[0] == pts[n-1] {
+		// closed rings repeat the first point
+		pts = pts[:n-1]
+	}
+	return pts, len(pts) >= 3
+}
+
+// haversineKm the great circle distance of two points
+func haversineKm(a, b geoPoint) float64 {
+	rad := math.Pi / 180
+	dLat := (b.lat - a.lat) * rad
+	dLon := (b.lon - a.lon) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(a.lat*rad)*math.Cos(b.lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// haversine:  the great circle distance of two points in km, or of an
+//   optional unit "m", "km" or "mi".  Points are of four lat, lon numbers
+//   or of two point values.
+//
+//   haversine(51.5074, -0.1278, 48.8566, 2.3522)   => 343.5...
+//   haversine(location, "48.8566,2.3522", "mi")
+//
+func HaversineFunc(ctx expr.EvalContext, args ...value.Value) (value.Value, bool) {
+	var a, b geoPoint
+	unit := "km"
+	switch len(args) {
+	case 2, 3:
+		var aOk, bOk bool
+		a, aOk = pointArg(args[0])
+		b, bOk = pointArg(args[1])
+		if !aOk || !bOk {
+			return nil, false
+		}
+		if len(args) == 3 {
+			var ok bool
+			if unit, ok = stringArg(args[2]); !ok {
+				return nil, false
+			}
+		}
+	case 4:
+		var coords [4]float64
+		for i, arg := range args {
+			f, ok := floatArg(arg)
+			if !ok {
+				return nil, false
+			}
+			coords[i] = f
+		}
+		a, b = geoPoint{coords[0], coords[1]}, geoPoint{coords[2], coords[3]}
+		if !a.valid() || !b.valid() {
+			return nil, false
+		}
+	default:
+		return value.NewErrorValue("haversine: expects (point, point [, unit]) or (lat1, lon1, lat2, lon2)"), true
+	}
+	km := haversineKm(a, b)
+	switch strings.ToLower(unit) {
+	case "km":
+		return value.NewNumberValue(km), true
+	case "m":
+		return value.NewNumberValue(km * 1000), true
+	case "mi":
+		return value.NewNumberValue(km / 1.609344), true
+	}
+	return value.NewErrorValuef("haversine: unknown unit %q", unit), true
+}
+
+// polygonContains is p inside the polygon, of ray casting on lon, lat as
+// planar coordinates (so for polygons not crossing the antimeridian)
+func polygonContains(poly []geoPoint, p geoPoint) bool {
+	inside := false
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		a, b := poly[i], poly[j]
+		if (a.lat > p.lat) != (b.lat > p.lat) &&
+			p.lon < (b.lon-a.lon)*(p.lat-a.lat)/(b.lat-a.lat)+a.lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// st_contains:  is a point inside a polygon, of the arguments in either
+//   order
+//
+//   st_contains("POLYGON((0 0, 10 0, 10 10, 0 10, 0 0))", "5,5")   => true
+//   st_contains(location, geofence)
+//
+func StContainsFunc(ctx expr.EvalContext, a, b value.Value) (value.BoolValue, bool) {
+	poly, ok := polygonArg(a)
+	pv := b
+	if !ok {
+		poly, ok = polygonArg(b)
+		pv = a
+	}
+	if !ok {
+		return value.BoolValueFalse, false
+	}
+	p, ok := pointArg(pv)
+	if !ok {
+		return value.BoolValueFalse, false
+	}
+	return value.NewBoolValue(polygonContains(poly, p)), true
+}
+
+// in_bbox:  is a point inside the bounding box of its south west and north
+//   east corners, inclusive.  A box of min_lon > max_lon crosses the
+//   antimeridian.
+//
+//   in_bbox(location, 48.8, 2.2, 48.9, 2.5)   => true of a point in paris
+//
+func InBboxFunc(ctx expr.EvalContext, args ...value.Value) (value.BoolValue, bool) {
+	if len(args) != 5 {
+		return value.BoolValueFalse, false
+	}
+	p, ok := pointArg(args[0])
+	if !ok {
+		return value.BoolValueFalse, false
+	}
+	var box [4]float64
+	for i, arg := range args[1:] {
+		f, ok := floatArg(arg)
+		if !ok {
+			return value.BoolValueFalse, false
+		}
+		box[i] = f
+	}
+	minLat, minLon, maxLat, maxLon := box[0], box[1], box[2], box[3]
+	if p.lat < minLat || p.lat > maxLat {
+		return value.BoolValueFalse, true
+	}
+	if minLon <= maxLon {
+		return value.NewBoolValue(p.lon >= minLon && p.lon <= maxLon), true
+	}
+	return value.NewBoolValue(p.lon >= minLon || p.lon <= maxLon), true
+}
+
+// geohash_encode:  the geohash of a point of precision characters (default
+//   12, at most 12), of a point value or lat, lon numbers
+//
+//   geohash_encode(57.64911, 10.40744, 11)   => "u4pruydqqvj"
+//   geohash_encode(location, 5)
+//
+func GeohashEncodeFunc(ctx expr.EvalContext, args ...value.Value) (value.StringValue, bool) {
+	if len(args) < 1 || len(args) > 3 {
+		return value.EmptyStringValue, false
+	}
+	var p geoPoint
+	var ok bool
+	rest := args[1:]
+	if _, isNum := args[0].(value.NumericValue); isNum && len(args) >= 2 {
+		lat, latOk := floatArg(args[0])
+		lon, lonOk := floatArg(args[1])
+		p, ok, rest = geoPoint{lat, lon}, latOk && lonOk, args[2:]
+		ok = ok && p.valid()
+	} else {
+		p, ok = pointArg(args[0])
+	}
+	if !ok || len(rest) > 1 {
+		return value.EmptyStringValue, false
+	}
+	precision := 12
+	if len(rest) == 1 {
+		if precision, ok = intArg(rest[0]); !ok || precision < 1 || precision > 12 {
+			return value.EmptyStringValue, false
+		}
+	}
+	return value.NewStringValue(geohashEncode(p, precision)), true
+}
+
+func geohashEncode(p geoPoint, precision int) string {
+	latLo, latHi, lonLo, lonHi := -90.0, 90.0, -180.0, 180.0
+	hash := make([]byte, 0, precision)
+	even := true // even bits are of longitude
+	bit, ch := 0, 0
+	for len(hash) < precision {
+		if even {
+			mid := (lonLo + lonHi) / 2
+			if p.lon >= mid {
+				ch = ch<<1 | 1
+				lonLo = mid
+			} else {
+				ch <<= 1
+				lonHi = mid
+			}
+		} else {
+			mid := (latLo + latHi) / 2
+			if p.lat >= mid {
+				ch = ch<<1 | 1
+				latLo = mid
+			} else {
+				ch <<= 1
+				latHi = mid
+			}
+		}
+		even = !even
+		if bit++; bit == 5 {
+			hash = append(hash, geohashBase32[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return string(hash)
+}
+
+// geohash_decode:  the center [lat, lon] of the cell of a geohash, NULL of
+//   an invalid geohash
+//
+//   geohash_decode("ezs42")   => [42.60498046875, -5.60302734375]
+//
+func GeohashDecodeFunc(ctx expr.EvalContext, item value.Value) (value.SliceValue, bool) {
+	hash, ok := stringArg(item)
+	if !ok || hash == "" {
+		return value.NewSliceValues(nil), false
+	}
+	latLo, latHi, lonLo, lonHi := -90.0, 90.0, -180.0, 180.0
+	even := true
+	for _, c := range strings.ToLower(hash) {
+		idx := strings.IndexRune(geohashBase32, c)
+		if idx < 0 {
+			return value.NewSliceValues(nil), false
+		}
+		for mask := 16; mask > 0; mask >>= 1 {
+			if even {
+				mid := (lonLo + lonHi) / 2
+				if idx&mask != 0 {
+					lonLo = mid
+				} else {
+					lonHi = mid
+				}
+			} else {
+				mid := (latLo + latHi) / 2
+				if idx&mask != 0 {
+					latLo = mid
+				} else {
+					latHi = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return value.NewSliceValues([]value.Value{
+		value.NewNumberValue((latLo + latHi) / 2),
+		value.NewNumberValue((lonLo + lonHi) / 2),
+	}), true
+}
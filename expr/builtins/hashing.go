@@ -0,0 +1,120 @@
+package builtins
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"hash/fnv"
+	"math"
+	"math/bits"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// The non-cryptographic hashes of values, as non-negative integers so they
+// bucket and sample by modulo:
+//
+//   hash(user_id) % 100 < 5      a consistent 5% sample of users
+
+// hashArg the bytes of a value hashed, its string form so the int 42 and
+// the string "42" hash alike
+func hashArg(v value.Value) ([]byte, bool) {
+	val, ok := stringArg(v)
+	if !ok {
+		return nil, false
+	}
+	return []byte(val), true
+}
+
+// fnv64:  the 64 bit fnv-1a hash of a value, of its sign bit cleared.
+// Also hash.
+//
+//   fnv64("hello")   => 2607821981565500683
+//
+func Fnv64Func(ctx expr.EvalContext, arg value.Value) (value.IntValue, bool) {
+	by, ok := hashArg(arg)
+	if !ok {
+		return value.NewIntNil(), false
+	}
+	h := fnv.New64a()
+	h.Write(by)
+	return value.NewIntValue(int64(h.Sum64() & math.MaxInt64)), true
+}
+
+// crc32:  the crc32 (ieee) checksum of a value, as mysql
+//
+//   crc32("MySQL")   => 3259397556
+//
+func Crc32Func(ctx expr.EvalContext, arg value.Value) (value.IntValue, bool) {
+	by, ok := hashArg(arg)
+	if !ok {
+		return value.NewIntNil(), false
+	}
+	return value.NewIntValue(int64(crc32.ChecksumIEEE(by))), true
+}
+
+// murmur3:  the 32 bit murmur3 (x86) hash of a value, of an optional seed
+//
+//   murmur3("hello")      => 613153351
+//   murmur3("hello", 42)  => 3806057185
+//
+func Murmur3Func(ctx expr.EvalContext, vals ...value.Value) (value.IntValue, bool) {
+	if len(vals) < 1 || len(vals) > 2 {
+		return value.NewIntNil(), false
+	}
+	by, ok := hashArg(vals[0])
+	if !ok {
+		return value.NewIntNil(), false
+	}
+	var seed uint32
+	if len(vals) == 2 {
+		s, ok := value.ValueToInt64(vals[1])
+		if !ok {
+			return value.NewIntNil(), false
+		}
+		seed = uint32(s)
+	}
+	return value.NewIntValue(int64(murmur3Sum32(by, seed))), true
+}
+
+// murmur3Sum32 the MurmurHash3_x86_32 of data
+func murmur3Sum32(data []byte, seed uint32) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	h := seed
+	n := len(data) / 4
+	for i := 0; i < n; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+	var k uint32
+	tail := data[n*4:]
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
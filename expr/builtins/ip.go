@@ -0,0 +1,121 @@
+package builtins
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// privateNets the private address ranges of rfc 1918 (ipv4) and 4193 (ipv6)
+var privateNets = parseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7")
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// ipArg the ip address of a function argument, of ipv4 or ipv6 text
+func ipArg(v value.Value) (net.IP, bool) {
+	val, ok := stringArg(v)
+	if !ok {
+		return nil, false
+	}
+	ip := net.ParseIP(strings.TrimSpace(val))
+	return ip, ip != nil
+}
+
+// inet_aton:  the integer of an ipv4 address, NULL if not one
+//
+//   inet_aton("10.0.5.9")   => 167773449
+//
+func InetAtonFunc(ctx expr.EvalContext, item value.Value) (value.IntValue, bool) {
+	ip, ok := ipArg(item)
+	if !ok {
+		return value.NewIntNil(), false
+	}
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return value.NewIntNil(), false
+	}
+	return value.NewIntValue(int64(binary.BigEndian.Uint32(ip4))), true
+}
+
+// inet_ntoa:  the ipv4 address of an integer, NULL if out of range
+//
+//   inet_ntoa(167773449)   => "10.0.5.9"
+//
+func InetNtoaFunc(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
+	n, ok := value.ValueToInt64(item)
+	if !ok || n < 0 || n > 0xffffffff {
+		return value.EmptyStringValue, false
+	}
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, uint32(n))
+	return value.NewStringValue(ip.String()), true
+}
+
+// ip_in_cidr:  is an ip address (v4 or v6) in the network of a cidr, NULL
+// if not an address, an error if not a cidr
+//
+//   ip_in_cidr("10.1.2.3", "10.0.0.0/8")         => true
+//   ip_in_cidr("2001:db8::1", "2001:db8::/32")   => true
+//
+func IpInCidrFunc(ctx expr.EvalContext, item, cidrv value.Value) (value.Value, bool) {
+	cidr, ok := stringArg(cidrv)
+	if !ok {
+		return nil, false
+	}
+	_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return value.NewErrorValuef("ip_in_cidr: invalid cidr %q", cidr), true
+	}
+	ip, ok := ipArg(item)
+	if !ok {
+		return nil, false
+	}
+	return value.NewBoolValue(network.Contains(ip)), true
+}
+
+// is_private_ip:  is an ip address of a private network, 10/8, 172.16/12,
+// 192.168/16 or fc00::/7
+//
+//   is_private_ip("192.168.1.20")   => true
+//   is_private_ip("8.8.8.8")        => false
+//
+func IsPrivateIpFunc(ctx expr.EvalContext, item value.Value) (value.BoolValue, bool) {
+	ip, ok := ipArg(item)
+	if !ok {
+		return value.BoolValueFalse, false
+	}
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return value.BoolValueTrue, true
+		}
+	}
+	return value.BoolValueFalse, true
+}
+
+// ip_normalize:  the canonical text of an ip address, ipv6 lower case and
+// of its longest run of zeros compressed, ipv4 (also ipv4-mapped ipv6)
+// dotted, so addresses written differently compare equal
+//
+//   ip_normalize("2001:0DB8:0000:0000:0000:0000:0000:0001")   => "2001:db8::1"
+//   ip_normalize("::ffff:10.0.0.1")                           => "10.0.0.1"
+//
+func IpNormalizeFunc(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
+	ip, ok := ipArg(item)
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	return value.NewStringValue(ip.String()), true
+}
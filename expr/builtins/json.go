@@ -0,0 +1,409 @@
+package builtins
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// The json functions of mysql, of documents of json (JsonValue) or string
+// columns and paths of the form
+//
+//   $                  the document
+//   $.user.name        member name of member user
+//   $."first name"     member of a quoted name
+//   $.tags[0]          first element of array tags
+//
+// A path matching nothing, or a document that is not json, is NULL (not
+// ok), an invalid path is an error.  Unlike mysql, json_extract (and ->)
+// returns the scalar it matches, ie the string (not a quoted json string)
+// or number so comparisons of it work, and the json of objects and arrays.
+
+// jsonStep a member name, or array index, of a json path
+type jsonStep struct {
+	key   string
+	index int
+}
+
+// parseJsonPath the steps of a json path
+func parseJsonPath(path string) ([]jsonStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid json path %q: must start with $", path)
+	}
+	steps := make([]jsonStep, 0)
+	for p := path[1:]; p != ""; {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			var key string
+			if strings.HasPrefix(p, `"`) {
+				end := strings.Index(p[1:], `"`)
+				if end < 0 {
+					return nil, fmt.Errorf("invalid json path %q: unterminated quoted member", path)
+				}
+				key, p = p[1:end+1], p[end+2:]
+			} else {
+				end := strings.IndexAny(p, ".[")
+				if end < 0 {
+					end = len(p)
+				}
+				key, p = p[:end], p[end:]
+			}
+			if key == "" || key == "*" {
+				return nil, fmt.Errorf("invalid json path %q: member name expected", path)
+			}
+			steps = append(steps, jsonStep{key: key, index: -1})
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid json path %q: unterminated [", path)
+			}
+			idx, err := strconv.Atoi(strings.TrimSpace(p[1:end]))
+			if err != nil || idx < 0 {
+				return nil, fmt.Errorf("invalid json path %q: array index expected", path)
+			}
+			steps = append(steps, jsonStep{index: idx})
+			p = p[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid json path %q: unexpected %q", path, p[:1])
+		}
+	}
+	return steps, nil
+}
+
+// jsonDoc the decoded json document of a function argument, numbers as
+// json.Number so integers stay so
+func jsonDoc(v value.Value) (interface{}, bool) {
+	var raw []byte
+	switch vt := v.(type) {
+	case nil, value.NilValue:
+		return nil, false
+	case value.JsonValue:
+		raw = []byte(vt.ToString())
+	case value.StringValue:
+		raw = []byte(vt.Val())
+	case value.ByteSliceValue:
+		raw = vt.Val()
+	default:
+		if v.Err() {
+			return nil, false
+		}
+		var err error
+		if raw, err = json.Marshal(v.Value()); err != nil {
+			return nil, false
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, false
+	}
+	return doc, true
+}
+
+// jsonLookup the part of a json document at the steps of a path
+func jsonLookup(doc interface{}, steps []jsonStep) (interface{}, bool) {
+	for _, step := range steps {
+		switch dt := doc.(type) {
+		case map[string]interface{}:
+			if step.index >= 0 {
+				return nil, false
+			}
+			v, ok := dt[step.key]
+			if !ok {
+				return nil, false
+			}
+			doc = v
+		case []interface{}:
+			if step.index < 0 || step.index >= len(dt) {
+				return nil, false
+			}
+			doc = dt[step.index]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// jsonValue the value of a part of a json document
+func jsonValue(part interface{}) (value.Value, bool) {
+	switch pt := part.(type) {
+	case nil:
+		return nil, false
+	case string:
+		return value.NewStringValue(pt), true
+	case bool:
+		return value.NewBoolValue(pt), true
+	case json.Number:
+		if iv, err := pt.Int64(); err == nil {
+			return value.NewIntValue(iv), true
+		}
+		fv, err := pt.Float64()
+		if err != nil {
+			return nil, false
+		}
+		return value.NewNumberValue(fv), true
+	}
+	by, err := json.Marshal(part)
+	if err != nil {
+		return nil, false
+	}
+	return value.NewJsonValue(by), true
+}
+
+// jsonArgs the document and the steps of the path of a json function, an
+// ErrorValue of an invalid path
+func jsonArgs(name string, docv, pathv value.Value) (interface{}, []jsonStep, value.Value, bool) {
+	doc, ok := jsonDoc(docv)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	if pathv == nil {
+		return doc, nil, nil, true
+	}
+	steps, errv, ok := jsonPathArg(name, pathv)
+	return doc, steps, errv, ok
+}
+
+// jsonPathArg the steps of a json path argument
+func jsonPathArg(name string, pathv value.Value) ([]jsonStep, value.Value, bool) {
+	path, ok := value.ValueToString(pathv)
+	if !ok {
+		return nil, nil, false
+	}
+	steps, err := parseJsonPath(path)
+	if err != nil {
+		return nil, value.NewErrorValuef("%s: %v", name, err), true
+	}
+	return steps, nil, true
+}
+
+// json_extract:  the part of a json document at a path, or the json array
+// of those of many paths.  Also the -> operator.
+//
+//   json_extract(payload, "$.user.name")    => "bob"
+//   payload->"$.tags[0]"                    => "a"
+//   json_extract(payload, "$.n", "$.m")     => [5, 6]
+//
+func JsonExtractFunc(ctx expr.EvalContext, vals ...value.Value) (value.Value, bool) {
+	if len(vals) < 2 {
+		return value.NewErrorValue("json_extract: expects (json, path [, path ...])"), true
+	}
+	if len(vals) == 2 {
+		doc, steps, errv, ok := jsonArgs("json_extract", vals[0], vals[1])
+		if !ok || errv != nil {
+			return errv, ok
+		}
+		part, ok := jsonLookup(doc, steps)
+		if !ok {
+			return nil, false
+		}
+		return jsonValue(part)
+	}
+	doc, ok := jsonDoc(vals[0])
+	if !ok {
+		return nil, false
+	}
+	parts := make([]interface{}, 0, len(vals)-1)
+	for _, pathv := range vals[1:] {
+		steps, errv, ok := jsonPathArg("json_extract", pathv)
+		if !ok || errv != nil {
+			return errv, ok
+		}
+		if part, ok := jsonLookup(doc, steps); ok {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return nil, false
+	}
+	return jsonValue(parts)
+}
+
+// json_unquote:  the text of a json value, of json strings unquoted.  Of
+// json_extract, the ->> operator.
+//
+//   json_unquote('"bob"')           => bob
+//   payload->>"$.user"              => {"name":"bob"}
+//
+func JsonUnquoteFunc(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
+	switch vt := item.(type) {
+	case nil, value.NilValue:
+		return value.EmptyStringValue, false
+	case value.StringValue:
+		val := vt.Val()
+		if len(val) >= 2 && strings.HasPrefix(val, `"`) && strings.HasSuffix(val, `"`) {
+			if s, err := strconv.Unquote(val); err == nil {
+				return value.NewStringValue(s), true
+			}
+		}
+		return vt, true
+	case value.JsonValue:
+		var s string
+		if err := json.Unmarshal([]byte(vt.ToString()), &s); err == nil {
+			return value.NewStringValue(s), true
+		}
+	}
+	if item.Err() {
+		return value.EmptyStringValue, false
+	}
+	return value.NewStringValue(item.ToString()), true
+}
+
+// json_set:  a json document of the values at paths set, replacing those
+// there or adding them (to objects, or appended to arrays) if not
+//
+//   json_set('{"a": 1}', "$.a", 2, "$.b", "x")   => {"a":2,"b":"x"}
+//
+func JsonSetFunc(ctx expr.EvalContext, vals ...value.Value) (value.Value, bool) {
+	if len(vals) < 3 || len(vals)%2 == 0 {
+		return value.NewErrorValue("json_set: expects (json, path, value [, path, value ...])"), true
+	}
+	doc, ok := jsonDoc(vals[0])
+	if !ok {
+		return nil, false
+	}
+	for i := 1; i < len(vals); i += 2 {
+		steps, errv, ok := jsonPathArg("json_set", vals[i])
+		if !ok || errv != nil {
+			return errv, ok
+		}
+		doc = jsonSet(doc, steps, jsonOf(vals[i+1]))
+	}
+	by, err := json.Marshal(doc)
+	if err != nil {
+		return value.NewErrorValuef("json_set: %v", err), true
+	}
+	return value.NewJsonValue(by), true
+}
+
+// jsonSet the document of v set at the steps of a path, of the parents of
+// the last step existing
+func jsonSet(doc interface{}, steps []jsonStep, v interface{}) interface{} {
+	if len(steps) == 0 {
+		return v
+	}
+	step, rest := steps[0], steps[1:]
+	switch dt := doc.(type) {
+	case map[string]interface{}:
+		if step.index >= 0 {
+			return doc
+		}
+		child, ok := dt[step.key]
+		if !ok && len(rest) > 0 {
+			return doc
+		}
+		dt[step.key] = jsonSet(child, rest, v)
+	case []interface{}:
+		switch {
+		case step.index < 0:
+		case step.index < len(dt):
+			dt[step.index] = jsonSet(dt[step.index], rest, v)
+		case len(rest) == 0:
+			return append(dt, v)
+		}
+	}
+	return doc
+}
+
+// jsonOf the json of a value set into a document
+func jsonOf(v value.Value) interface{} {
+	switch vt := v.(type) {
+	case nil, value.NilValue:
+		return nil
+	case value.JsonValue:
+		if doc, ok := jsonDoc(vt); ok {
+			return doc
+		}
+	}
+	return v.Value()
+}
+
+// json_length:  the length of a json document (or its part at a path),
+// members of an object, elements of an array, 1 of scalars
+//
+//   json_length('{"a": 1, "b": [1, 2, 3]}')           => 2
+//   json_length('{"a": 1, "b": [1, 2, 3]}', "$.b")    => 3
+//
+func JsonLengthFunc(ctx expr.EvalContext, vals ...value.Value) (value.Value, bool) {
+	return jsonLength("json_length", vals, false)
+}
+
+// json_array_length:  the number of elements of a json array, NULL if not
+// an array
+//
+//   json_array_length('[1, 2, 3]')                => 3
+//   json_array_length(payload, "$.tags")          => 2
+//
+func JsonArrayLengthFunc(ctx expr.EvalContext, vals ...value.Value) (value.Value, bool) {
+	return jsonLength("json_array_length", vals, true)
+}
+
+func jsonLength(name string, vals []value.Value, arrays bool) (value.Value, bool) {
+	if len(vals) < 1 || len(vals) > 2 {
+		return value.NewErrorValuef("%s: expects (json [, path])", name), true
+	}
+	var pathv value.Value
+	if len(vals) == 2 {
+		pathv = vals[1]
+	}
+	doc, steps, errv, ok := jsonArgs(name, vals[0], pathv)
+	if !ok || errv != nil {
+		return errv, ok
+	}
+	part, ok := jsonLookup(doc, steps)
+	if !ok {
+		return nil, false
+	}
+	switch pt := part.(type) {
+	case []interface{}:
+		return value.NewIntValue(int64(len(pt))), true
+	case map[string]interface{}:
+		if !arrays {
+			return value.NewIntValue(int64(len(pt))), true
+		}
+	default:
+		if !arrays && part != nil {
+			return value.NewIntValue(1), true
+		}
+	}
+	return nil, false
+}
+
+// json_keys:  the member names of a json object (or its part at a path),
+// sorted, NULL if not an object
+//
+//   json_keys('{"b": 1, "a": 2}')   => ["a", "b"]
+//
+func JsonKeysFunc(ctx expr.EvalContext, vals ...value.Value) (value.Value, bool) {
+	if len(vals) < 1 || len(vals) > 2 {
+		return value.NewErrorValue("json_keys: expects (json [, path])"), true
+	}
+	var pathv value.Value
+	if len(vals) == 2 {
+		pathv = vals[1]
+	}
+	doc, steps, errv, ok := jsonArgs("json_keys", vals[0], pathv)
+	if !ok || errv != nil {
+		return errv, ok
+	}
+	part, _ := jsonLookup(doc, steps)
+	obj, ok := part.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return value.NewStringsValue(keys), true
+}
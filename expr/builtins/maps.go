@@ -0,0 +1,132 @@
+package builtins
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mb0/glob"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// mapArg the map of an argument of any map type, not ok if not a map
+func mapArg(v value.Value) (value.MapValue, bool) {
+	if mv, ok := v.(value.Map); ok {
+		return mv.MapValue(), true
+	}
+	return value.EmptyMapValue, false
+}
+
+// sortedKeys the keys of a map in order, so results of them are stable
+func sortedKeys(m map[string]value.Value) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// map_keys:  the keys of a map, sorted.  NULL if not a map.
+//
+//   given labels = {"env":"prod","team":"core"}
+//
+//   map_keys(labels)   => ["env","team"]
+//
+func MapKeysFunc(ctx expr.EvalContext, item value.Value) (value.StringsValue, bool) {
+	mv, ok := mapArg(item)
+	if !ok {
+		return value.NewStringsValue(nil), false
+	}
+	return value.NewStringsValue(sortedKeys(mv.Val())), true
+}
+
+// map_values:  the values of a map in the order of their sorted keys, of
+// their own types and repeats kept (unlike mapvalues)
+//
+//   map_values(labels)   => ["prod","core"]
+//
+func MapValuesFunc(ctx expr.EvalContext, item value.Value) (value.SliceValue, bool) {
+	mv, ok := mapArg(item)
+	if !ok {
+		return value.NewSliceValues(nil), false
+	}
+	vals := make([]value.Value, 0, mv.Len())
+	for _, k := range sortedKeys(mv.Val()) {
+		vals = append(vals, mv.Val()[k])
+	}
+	return value.NewSliceValues(vals), true
+}
+
+// map_get:  the value of a key of a map, or the default (NULL if none
+// given) if the map has no such key
+//
+//   map_get(labels, "env")              => "prod"
+//   map_get(labels, "region", "none")   => "none"
+//
+func MapGetFunc(ctx expr.EvalContext, args ...value.Value) (value.Value, bool) {
+	if len(args) < 2 || len(args) > 3 {
+		return value.NewErrorValue("map_get: expects (map, key [, default])"), true
+	}
+	if key, ok := stringArg(args[1]); ok {
+		if mv, ok := mapArg(args[0]); ok {
+			if v, ok := mv.Get(key); ok && !isNull(v) {
+				return v, true
+			}
+		}
+	}
+	if len(args) == 3 {
+		return nullable(args[2])
+	}
+	return nil, false
+}
+
+// has_key:  does a map have a key, false if not a map
+//
+//   has_key(labels, "team")   => true
+//
+func HasKeyFunc(ctx expr.EvalContext, item, keyItem value.Value) (value.BoolValue, bool) {
+	key, ok := stringArg(keyItem)
+	if !ok {
+		return value.BoolValueFalse, false
+	}
+	mv, ok := mapArg(item)
+	if !ok {
+		return value.BoolValueFalse, true
+	}
+	_, ok = mv.Get(key)
+	return value.NewBoolValue(ok), true
+}
+
+// map_filter:  the entries of a map of keys matching any of the patterns,
+// the inverse of filter.  Patterns are key prefixes, or wildcards of * (or %).
+//
+//   given labels = {"env":"prod","team":"core","team_lead":"bob"}
+//
+//   map_filter(labels, "team")          => {"team":"core","team_lead":"bob"}
+//   map_filter(labels, "env", "*lead")  => {"env":"prod","team_lead":"bob"}
+//
+func MapFilterFunc(ctx expr.EvalContext, item value.Value, patterns ...value.Value) (value.MapValue, bool) {
+	mv, ok := mapArg(item)
+	if !ok {
+		return value.EmptyMapValue, false
+	}
+	filters := FiltersFromArgs(patterns)
+	out := make(map[string]interface{})
+	for key, v := range mv.Val() {
+		for _, filter := range filters {
+			var match bool
+			if strings.Contains(filter, "*") {
+				match, _ = glob.Match(filter, key)
+			} else {
+				match = strings.HasPrefix(key, filter)
+			}
+			if match && v != nil {
+				out[key] = v.Value()
+				break
+			}
+		}
+	}
+	return value.NewMapValue(out), true
+}
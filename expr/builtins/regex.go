@@ -0,0 +1,122 @@
+package builtins
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// maxCachedPatterns the most patterns kept compiled, the cache is emptied
+// when full so patterns of column values can't grow it without bound.
+const maxCachedPatterns = 1000
+
+var (
+	patternMu    sync.RWMutex
+	patternCache = make(map[string]*compiledPattern)
+)
+
+// compiledPattern a pattern compiled, or the error of compiling it, so
+// an invalid pattern of every row is not compiled again for each
+type compiledPattern struct {
+	re  *regexp.Regexp
+	err error
+}
+
+// compilePattern the compiled regexp of a pattern, from the cache
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	patternMu.RLock()
+	cp, ok := patternCache[pattern]
+	patternMu.RUnlock()
+	if ok {
+		return cp.re, cp.err
+	}
+	re, err := regexp.Compile(pattern)
+	patternMu.Lock()
+	if len(patternCache) >= maxCachedPatterns {
+		patternCache = make(map[string]*compiledPattern)
+	}
+	patternCache[pattern] = &compiledPattern{re: re, err: err}
+	patternMu.Unlock()
+	return re, err
+}
+
+// regexArgs the string and compiled pattern of the first two arguments of
+// a regexp function, an ErrorValue of an invalid pattern
+func regexArgs(name string, item, patternv value.Value) (string, *regexp.Regexp, value.Value, bool) {
+	val, ok := stringArg(item)
+	pattern, pok := stringArg(patternv)
+	if !ok || !pok {
+		return "", nil, nil, false
+	}
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return "", nil, value.NewErrorValuef("%s: invalid pattern %q: %v", name, pattern, err), true
+	}
+	return val, re, nil, true
+}
+
+// regexp_match:  does a string match a regular expression (of go's
+// regexp syntax), anywhere in it unless anchored.  Also regexp_like.
+//
+//   regexp_match("apple pie", "^app")    => true
+//   regexp_match("apple pie", "[0-9]")   => false
+//   regexp_match("apple", "(")           => error, invalid pattern
+//
+func RegexpMatchFunc(ctx expr.EvalContext, item, pattern value.Value) (value.Value, bool) {
+	val, re, errv, ok := regexArgs("regexp_match", item, pattern)
+	if !ok || errv != nil {
+		return errv, ok
+	}
+	return value.NewBoolValue(re.MatchString(val)), true
+}
+
+// regexp_extract:  the first match of a regular expression in a string,
+// or of its optional capture group (0 the whole match).  Not ok (NULL)
+// if it does not match.
+//
+//   regexp_extract("order-1234-x", "[0-9]+")                  => "1234"
+//   regexp_extract("bob@gmail.com", "@([a-z]+)\\.com", 1)     => "gmail"
+//
+func RegexpExtractFunc(ctx expr.EvalContext, vals ...value.Value) (value.Value, bool) {
+	if len(vals) < 2 || len(vals) > 3 {
+		return value.NewErrorValue("regexp_extract: expects (string, pattern [, group])"), true
+	}
+	val, re, errv, ok := regexArgs("regexp_extract", vals[0], vals[1])
+	if !ok || errv != nil {
+		return errv, ok
+	}
+	group := 0
+	if len(vals) == 3 {
+		if group, ok = intArg(vals[2]); !ok {
+			return nil, false
+		}
+		if group < 0 || group > re.NumSubexp() {
+			return value.NewErrorValuef("regexp_extract: no group %d of pattern %q", group, re.String()), true
+		}
+	}
+	match := re.FindStringSubmatchIndex(val)
+	if match == nil || match[2*group] < 0 {
+		return nil, false
+	}
+	return value.NewStringValue(val[match[2*group]:match[2*group+1]]), true
+}
+
+// regexp_replace:  a string of every match of a regular expression
+// replaced, of $1 (or ${name}) in the replacement its capture groups
+//
+//   regexp_replace("a1b22c", "[0-9]+", "#")               => "a#b#c"
+//   regexp_replace("John Smith", "(\\w+) (\\w+)", "$2")   => "Smith"
+//
+func RegexpReplaceFunc(ctx expr.EvalContext, item, pattern, replacement value.Value) (value.Value, bool) {
+	val, re, errv, ok := regexArgs("regexp_replace", item, pattern)
+	if !ok || errv != nil {
+		return errv, ok
+	}
+	repl, ok := stringArg(replacement)
+	if !ok {
+		return nil, false
+	}
+	return value.NewStringValue(re.ReplaceAllString(val, repl)), true
+}
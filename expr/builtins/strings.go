@@ -0,0 +1,377 @@
+package builtins
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// The string functions of mysql, of its semantics: positions count
+// characters (not bytes) from 1, and a NULL argument is NULL (not ok).
+
+// MaxStringLength the longest string (in bytes) the string functions
+// build, longer are NULL as they are in mysql over its max_allowed_packet
+const MaxStringLength = 4194304
+
+// stringArg the string of a function argument, of an empty string ok
+// (unlike value.ValueToString) and of NULL not ok
+func stringArg(v value.Value) (string, bool) {
+	switch vt := v.(type) {
+	case nil, value.NilValue:
+		return "", false
+	case value.StringValue:
+		return vt.Val(), true
+	case value.ByteSliceValue:
+		return string(vt.Val()), true
+	}
+	if v.Err() || v.Nil() {
+		return "", false
+	}
+	return value.ToString(v.Rv())
+}
+
+// intArg the integer of a function argument
+func intArg(v value.Value) (int, bool) {
+	if v == nil {
+		return 0, false
+	}
+	return value.ValueToInt(v)
+}
+
+// Upper case a string
+//
+//   upper("Apple")  => "APPLE"
+//
+func Upper(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
+	val, ok := stringArg(item)
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	return value.NewStringValue(strings.ToUpper(val)), true
+}
+
+// char_length:  the number of characters of a string (len is of bytes)
+//
+//   char_length("héllo")   => 5
+//
+func CharLengthFunc(ctx expr.EvalContext, item value.Value) (value.IntValue, bool) {
+	val, ok := stringArg(item)
+	if !ok {
+		return value.NewIntNil(), false
+	}
+	return value.NewIntValue(int64(utf8.RuneCountInString(val))), true
+}
+
+// substr:  the characters of a string from pos (of 1, or negative from the
+// end) of an optional length
+//
+//   substr("Quadratically", 5)      => "ratically"
+//   substr("Quadratically", 5, 6)   => "ratica"
+//   substr("Sakila", -3)            => "ila"
+//   substr("Sakila", 0)             => ""
+//
+func SubstrFunc(ctx expr.EvalContext, vals ...value.Value) (value.StringValue, bool) {
+	if len(vals) < 2 || len(vals) > 3 {
+		return value.EmptyStringValue, false
+	}
+	val, ok := stringArg(vals[0])
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	pos, ok := intArg(vals[1])
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	runes := []rune(val)
+	if pos == 0 {
+		return value.EmptyStringValue, true
+	} else if pos > 0 {
+		pos--
+	} else {
+		pos += len(runes)
+	}
+	if pos < 0 || pos >= len(runes) {
+		return value.EmptyStringValue, true
+	}
+	end := len(runes)
+	if len(vals) == 3 {
+		n, ok := intArg(vals[2])
+		if !ok {
+			return value.EmptyStringValue, false
+		}
+		if n < 1 {
+			return value.EmptyStringValue, true
+		}
+		if pos+n < end {
+			end = pos + n
+		}
+	}
+	return value.NewStringValue(string(runes[pos:end])), true
+}
+
+// left:  the first n characters of a string
+//
+//   left("foobarbar", 5)   => "fooba"
+//
+func LeftFunc(ctx expr.EvalContext, item, nv value.Value) (value.StringValue, bool) {
+	val, ok := stringArg(item)
+	n, nok := intArg(nv)
+	if !ok || !nok {
+		return value.EmptyStringValue, false
+	}
+	runes := []rune(val)
+	if n < 0 {
+		n = 0
+	}
+	if n < len(runes) {
+		runes = runes[:n]
+	}
+	return value.NewStringValue(string(runes)), true
+}
+
+// right:  the last n characters of a string
+//
+//   right("foobarbar", 4)   => "rbar"
+//
+func RightFunc(ctx expr.EvalContext, item, nv value.Value) (value.StringValue, bool) {
+	val, ok := stringArg(item)
+	n, nok := intArg(nv)
+	if !ok || !nok {
+		return value.EmptyStringValue, false
+	}
+	runes := []rune(val)
+	if n < 0 {
+		n = 0
+	}
+	if n < len(runes) {
+		runes = runes[len(runes)-n:]
+	}
+	return value.NewStringValue(string(runes)), true
+}
+
+// trim:  a string of the leading and trailing spaces removed, or of an
+// optional string removed (as many times as it repeats)
+//
+//   trim("  bar   ")        => "bar"
+//   trim("xxxbarxxx", "x")  => "bar"
+//
+func TrimFunc(ctx expr.EvalContext, vals ...value.Value) (value.StringValue, bool) {
+	return trimArgs(vals, true, true)
+}
+
+// ltrim:  a string of the leading spaces (or optional string) removed
+//
+//   ltrim("  barbar")   => "barbar"
+//
+func LtrimFunc(ctx expr.EvalContext, vals ...value.Value) (value.StringValue, bool) {
+	return trimArgs(vals, true, false)
+}
+
+// rtrim:  a string of the trailing spaces (or optional string) removed
+//
+//   rtrim("barbar   ")   => "barbar"
+//
+func RtrimFunc(ctx expr.EvalContext, vals ...value.Value) (value.StringValue, bool) {
+	return trimArgs(vals, false, true)
+}
+
+func trimArgs(vals []value.Value, leading, trailing bool) (value.StringValue, bool) {
+	if len(vals) < 1 || len(vals) > 2 {
+		return value.EmptyStringValue, false
+	}
+	val, ok := stringArg(vals[0])
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	remove := " "
+	if len(vals) == 2 {
+		if remove, ok = stringArg(vals[1]); !ok {
+			return value.EmptyStringValue, false
+		}
+	}
+	if remove == "" {
+		return value.NewStringValue(val), true
+	}
+	for leading && strings.HasPrefix(val, remove) {
+		val = val[len(remove):]
+	}
+	for trailing && strings.HasSuffix(val, remove) {
+		val = val[:len(val)-len(remove)]
+	}
+	return value.NewStringValue(val), true
+}
+
+// lpad:  a string left-padded by pad to a length of n characters, or of
+// its first n characters if longer, NULL over MaxStringLength
+//
+//   lpad("hi", 4, "??")   => "??hi"
+//   lpad("hi", 1, "??")   => "h"
+//
+func LpadFunc(ctx expr.EvalContext, item, nv, padv value.Value) (value.StringValue, bool) {
+	return pad(item, nv, padv, true)
+}
+
+// rpad:  a string right-padded by pad to a length of n characters, or of
+// its first n characters if longer, NULL over MaxStringLength
+//
+//   rpad("hi", 5, "?")   => "hi???"
+//
+func RpadFunc(ctx expr.EvalContext, item, nv, padv value.Value) (value.StringValue, bool) {
+	return pad(item, nv, padv, false)
+}
+
+func pad(item, nv, padv value.Value, left bool) (value.StringValue, bool) {
+	val, ok := stringArg(item)
+	n, nok := intArg(nv)
+	padWith, pok := stringArg(padv)
+	if !ok || !nok || !pok || n < 0 {
+		return value.EmptyStringValue, false
+	}
+	runes := []rune(val)
+	if n <= len(runes) {
+		return value.NewStringValue(string(runes[:n])), true
+	}
+	if padWith == "" || n > MaxStringLength {
+		return value.EmptyStringValue, false
+	}
+	padRunes := []rune(strings.Repeat(padWith, (n-len(runes))/utf8.RuneCountInString(padWith)+1))
+	padRunes = padRunes[:n-len(runes)]
+	if left {
+		return value.NewStringValue(string(padRunes) + val), true
+	}
+	return value.NewStringValue(val + string(padRunes)), true
+}
+
+// reverse:  the characters of a string in reverse order
+//
+//   reverse("abc")   => "cba"
+//
+func ReverseFunc(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
+	val, ok := stringArg(item)
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	runes := []rune(val)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return value.NewStringValue(string(runes)), true
+}
+
+// repeat:  a string repeated count times, empty of a count less than 1,
+// NULL over MaxStringLength
+//
+//   repeat("ab", 3)   => "ababab"
+//
+func RepeatFunc(ctx expr.EvalContext, item, countv value.Value) (value.StringValue, bool) {
+	val, ok := stringArg(item)
+	count, cok := intArg(countv)
+	if !ok || !cok {
+		return value.EmptyStringValue, false
+	}
+	if count < 1 {
+		return value.EmptyStringValue, true
+	}
+	if len(val) > 0 && count > MaxStringLength/len(val) {
+		return value.EmptyStringValue, false
+	}
+	return value.NewStringValue(strings.Repeat(val, count)), true
+}
+
+// instr:  the position (of 1) of the first occurrence of substr in a
+// string, 0 if not found
+//
+//   instr("foobarbar", "bar")   => 4
+//   instr("xbar", "foobar")     => 0
+//
+func InstrFunc(ctx expr.EvalContext, item, substr value.Value) (value.IntValue, bool) {
+	return locate(substr, item, nil)
+}
+
+// locate:  the position of the first occurrence of substr in a string,
+// from an optional position, 0 if not found.  Also position(substr, str).
+//
+//   locate("bar", "foobarbar")      => 4
+//   locate("bar", "foobarbar", 5)   => 7
+//
+func LocateFunc(ctx expr.EvalContext, vals ...value.Value) (value.IntValue, bool) {
+	if len(vals) < 2 || len(vals) > 3 {
+		return value.NewIntNil(), false
+	}
+	var from value.Value
+	if len(vals) == 3 {
+		from = vals[2]
+	}
+	return locate(vals[0], vals[1], from)
+}
+
+func locate(substrv, item, fromv value.Value) (value.IntValue, bool) {
+	substr, sok := stringArg(substrv)
+	val, ok := stringArg(item)
+	if !ok || !sok {
+		return value.NewIntNil(), false
+	}
+	from := 1
+	if fromv != nil {
+		if from, ok = intArg(fromv); !ok {
+			return value.NewIntNil(), false
+		}
+	}
+	runes := []rune(val)
+	if from < 1 || from > len(runes)+1 {
+		return value.NewIntValue(0), true
+	}
+	idx := strings.Index(string(runes[from-1:]), substr)
+	if idx < 0 {
+		return value.NewIntValue(0), true
+	}
+	pos := from + utf8.RuneCountInString(string(runes[from-1:])[:idx])
+	return value.NewIntValue(int64(pos)), true
+}
+
+// format:  a number rounded to decimals places, of thousands separated
+// by commas
+//
+//   format(12332.123456, 4)   => "12,332.1235"
+//   format(12332.2, 0)        => "12,332"
+//
+func FormatFunc(ctx expr.EvalContext, numv, decv value.Value) (value.StringValue, bool) {
+	f, ok := value.ValueToFloat64(numv)
+	dec, dok := intArg(decv)
+	if !ok || !dok || math.IsNaN(f) || math.IsInf(f, 0) {
+		return value.EmptyStringValue, false
+	}
+	if dec < 0 {
+		dec = 0
+	} else if dec > 30 {
+		// as mysql, of at most 30 decimals
+		dec = 30
+	}
+	a := math.Abs(f)
+	// FormatFloat rounds half to even, round half away from zero as mysql
+	// does, unless a is too large to have digits past the decimals
+	if p := math.Pow10(dec); a*p < 1<<53 {
+		a = math.Round(a*p) / p
+	}
+	s := strconv.FormatFloat(a, 'f', dec, 64)
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i:]
+	}
+	grouped := make([]byte, 0, len(whole)+len(whole)/3)
+	for i := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped = append(grouped, ',')
+		}
+		grouped = append(grouped, whole[i])
+	}
+	s = string(grouped) + frac
+	if f < 0 && strings.Trim(s, "0.,") != "" {
+		s = "-" + s
+	}
+	return value.NewStringValue(s), true
+}
@@ -0,0 +1,148 @@
+package builtins
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	keyringMu sync.RWMutex
+	keyrings  = make(map[string]Keyring)
+)
+
+// Keyring the secret key of tokenize, registered by name with RegisterKeyring
+// so keys are not written in queries (or logged with them).
+type Keyring interface {
+	// Key the secret key to tokenize with, called per value so keys may
+	// be rotated or fetched of a secrets manager
+	Key() ([]byte, error)
+}
+
+// KeyringFunc a func as a Keyring
+type KeyringFunc func() ([]byte, error)
+
+// Key the key of the func
+func (f KeyringFunc) Key() ([]byte, error) { return f() }
+
+// StaticKeyring a Keyring of a fixed key
+func StaticKeyring(key []byte) Keyring {
+	return KeyringFunc(func() ([]byte, error) { return key, nil })
+}
+
+// RegisterKeyring a keyring of name for tokenize, replacing any of the
+// same name
+//
+//   builtins.RegisterKeyring("pii", builtins.StaticKeyring(key))
+//
+//   SELECT tokenize(email, "pii") AS email FROM users
+//
+func RegisterKeyring(name string, kr Keyring) {
+	keyringMu.Lock()
+	keyrings[strings.ToLower(name)] = kr
+	keyringMu.Unlock()
+}
+
+// keyringKey the key of the registered keyring of name
+func keyringKey(name string) ([]byte, error) {
+	keyringMu.RLock()
+	kr, ok := keyrings[strings.ToLower(name)]
+	keyringMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no keyring %q", name)
+	}
+	key, err := kr.Key()
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("keyring %q has an empty key", name)
+	}
+	return key, nil
+}
+
+// hmac_sha256:  the hex hmac-sha256 of a value of a key
+//
+//   hmac_sha256("key", "The quick brown fox jumps over the lazy dog")
+//      => "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+//
+func HmacSha256Func(ctx expr.EvalContext, keyItem, item value.Value) (value.StringValue, bool) {
+	key, ok := stringArg(keyItem)
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	val, ok := stringArg(item)
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(val))
+	return value.NewStringValue(hex.EncodeToString(mac.Sum(nil))), true
+}
+
+// tokenize:  a deterministic pseudonym of a value of the key of a registered
+//   keyring, of the same format:  digits become digits, letters letters of
+//   the same case and other characters are kept, so emails, phone numbers
+//   and ids still look (and validate) alike.  Equal values of one key have
+//   equal tokens so tokenized columns still join and group; tokens are
+//   one way.
+//
+//   tokenize("bob@example.com", "pii")   => "dlt@pggfhjx.fap"
+//   tokenize("555-0100", "pii")          => "362-4748"
+//
+func TokenizeFunc(ctx expr.EvalContext, item, keyringItem value.Value) (value.Value, bool) {
+	val, ok := stringArg(item)
+	if !ok {
+		return nil, false
+	}
+	name, ok := stringArg(keyringItem)
+	if !ok {
+		return value.NewErrorValue("tokenize: keyring must be a string"), true
+	}
+	key, err := keyringKey(name)
+	if err != nil {
+		return value.NewErrorValue(fmt.Sprintf("tokenize: %v", err)), true
+	}
+	return value.NewStringValue(tokenize(key, val)), true
+}
+
+// tokenize val of a keystream of the hmac of val, hmac blocks of a counter
+func tokenize(key []byte, val string) string {
+	mac := hmac.New(sha256.New, key)
+	var stream []byte
+	var counter [8]byte
+	next := func() uint32 {
+		if len(stream) < 4 {
+			mac.Reset()
+			mac.Write([]byte(val))
+			mac.Write(counter[:])
+			stream = mac.Sum(nil)
+			binary.BigEndian.PutUint64(counter[:], binary.BigEndian.Uint64(counter[:])+1)
+		}
+		n := binary.BigEndian.Uint32(stream)
+		stream = stream[4:]
+		return n
+	}
+	out := make([]rune, 0, len(val))
+	for _, r := range val {
+		switch {
+		case unicode.IsDigit(r):
+			out = append(out, '0'+rune(next()%10))
+		case unicode.IsUpper(r):
+			out = append(out, 'A'+rune(next()%26))
+		case unicode.IsLetter(r):
+			out = append(out, 'a'+rune(next()%26))
+		default:
+			out = append(out, r)
+		}
+	}
+	return string(out)
+}
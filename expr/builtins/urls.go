@@ -0,0 +1,109 @@
+package builtins
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// Unlike host, path and qs these keep the case of paths and query values,
+// and take urls of no scheme ("www.site.com/a?b=c") and relative urls
+// ("/a?b=c").
+
+// parseUrlArg the url of a value, the first of an array
+func parseUrlArg(v value.Value) (*url.URL, bool) {
+	if vals, ok := arrayArg(v); ok {
+		if len(vals) == 0 {
+			return nil, false
+		}
+		v = vals[0]
+	}
+	s, ok := stringArg(v)
+	if !ok {
+		return nil, false
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, false
+	}
+	if !strings.Contains(s, "://") && !strings.HasPrefix(s, "/") && !strings.HasPrefix(s, "?") {
+		s = "http://" + s
+	}
+	up, err := url.Parse(s)
+	if err != nil {
+		return nil, false
+	}
+	return up, true
+}
+
+// urlhost:  the host of a url, lower case and without a port
+//
+//   urlhost("https://WWW.Site.com:8080/a")   => "www.site.com"
+//
+func UrlHostFunc(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
+	up, ok := parseUrlArg(item)
+	if !ok || up.Hostname() == "" {
+		return value.EmptyStringValue, false
+	}
+	return value.NewStringValue(strings.ToLower(up.Hostname())), true
+}
+
+// urlpath:  the (decoded) path of a url
+//
+//   urlpath("https://www.site.com/Blog/my%20post?x=1")   => "/Blog/my post"
+//
+func UrlPathFunc(ctx expr.EvalContext, item value.Value) (value.StringValue, bool) {
+	up, ok := parseUrlArg(item)
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	return value.NewStringValue(up.Path), true
+}
+
+// urlparam:  the (decoded) value of a query parameter of a url, the first
+//   of a repeated parameter.  NULL if the url has none of name.
+//
+//   urlparam("http://site.com/?utm_source=Google&q=a%20b", "q")   => "a b"
+//
+func UrlParamFunc(ctx expr.EvalContext, item, nameItem value.Value) (value.StringValue, bool) {
+	name, ok := stringArg(nameItem)
+	if !ok || name == "" {
+		return value.EmptyStringValue, false
+	}
+	up, ok := parseUrlArg(item)
+	if !ok {
+		return value.EmptyStringValue, false
+	}
+	vals, ok := up.Query()[name]
+	if !ok || len(vals) == 0 {
+		return value.EmptyStringValue, false
+	}
+	return value.NewStringValue(vals[0]), true
+}
+
+// urldecodequery:  the (decoded) query parameters of a url as a map, and
+//   an array of the values of repeated parameters
+//
+//   urldecodequery("http://site.com/?a=1&b=x%20y&a=2")   => {"a": ["1","2"], "b": "x y"}
+//
+func UrlDecodeQueryFunc(ctx expr.EvalContext, item value.Value) (value.MapValue, bool) {
+	up, ok := parseUrlArg(item)
+	if !ok {
+		return value.EmptyMapValue, false
+	}
+	query, err := url.ParseQuery(up.RawQuery)
+	if err != nil {
+		return value.EmptyMapValue, false
+	}
+	params := make(map[string]interface{}, len(query))
+	for name, vals := range query {
+		if len(vals) == 1 {
+			params[name] = vals[0]
+		} else {
+			params[name] = vals
+		}
+	}
+	return value.NewMapValue(params), true
+}
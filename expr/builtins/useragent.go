@@ -0,0 +1,136 @@
+package builtins
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// uaRule a browser or os of a user-agent token, the first rule of a
+// matching pattern wins so the more specific rules go first (chrome
+// user-agents also name safari, edge ones name chrome).
+type uaRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+var (
+	uaBotRe = regexp.MustCompile(`(?i)([a-z0-9_-]*(?:bot|crawler|spider|slurp))\b`)
+
+	uaBrowsers = []uaRule{
+		{"Edge", regexp.MustCompile(`\b(?:Edg|Edge|EdgA|EdgiOS)/([\d.]+)`)},
+		{"Opera", regexp.MustCompile(`\b(?:OPR|Opera)[/ ]([\d.]+)`)},
+		{"Samsung Internet", regexp.MustCompile(`\bSamsungBrowser/([\d.]+)`)},
+		{"Firefox", regexp.MustCompile(`\b(?:Firefox|FxiOS)/([\d.]+)`)},
+		{"Chrome", regexp.MustCompile(`\b(?:Chrome|CriOS)/([\d.]+)`)},
+		{"Safari", regexp.MustCompile(`\bVersion/([\d.]+).*\bSafari/`)},
+		{"Internet Explorer", regexp.MustCompile(`\bMSIE ([\d.]+)|\bTrident/.*\brv:([\d.]+)`)},
+	}
+
+	uaOses = []uaRule{
+		{"Windows", regexp.MustCompile(`\bWindows (?:NT )?([\d.]+)?`)},
+		{"iOS", regexp.MustCompile(`\b(?:iPhone|iPad|iPod)\b(?:.*? OS ([\d_]+))?`)},
+		{"Android", regexp.MustCompile(`\bAndroid ?([\d.]+)?`)},
+		{"Chrome OS", regexp.MustCompile(`\bCrOS\b`)},
+		{"Mac OS X", regexp.MustCompile(`\bMac OS X ?([\d_.]+)?`)},
+		{"Linux", regexp.MustCompile(`\bLinux\b`)},
+	}
+
+	// windowsVersions names of the nt versions
+	windowsVersions = map[string]string{
+		"10.0": "10",
+		"6.3":  "8.1",
+		"6.2":  "8",
+		"6.1":  "7",
+		"6.0":  "Vista",
+		"5.1":  "XP",
+	}
+)
+
+// uaMatch the name and version of the first matching rule
+func uaMatch(ua string, rules []uaRule) (string, string) {
+	for _, rule := range rules {
+		m := rule.re.FindStringSubmatch(ua)
+		if m == nil {
+			continue
+		}
+		version := ""
+		for _, part := range m[1:] {
+			if part != "" {
+				version = strings.Replace(part, "_", ".", -1)
+				break
+			}
+		}
+		return rule.name, version
+	}
+	return "Other", ""
+}
+
+// uaDevice the kind of device of a user-agent:  bot, tablet, mobile or desktop
+func uaDevice(ua string) string {
+	switch {
+	case uaBotRe.MatchString(ua):
+		return "bot"
+	case strings.Contains(ua, "iPad") || strings.Contains(ua, "Tablet") ||
+		(strings.Contains(ua, "Android") && !strings.Contains(ua, "Mobile")):
+		return "tablet"
+	case strings.Contains(ua, "Mobi") || strings.Contains(ua, "iPhone") ||
+		strings.Contains(ua, "iPod") || strings.Contains(ua, "Android"):
+		return "mobile"
+	}
+	return "desktop"
+}
+
+// useragent:  a field of a user-agent string, of
+//   browser, browser_version, os, os_version or device
+//   (bot, tablet, mobile, desktop).  Unrecognized browsers and os are "Other".
+//
+//   useragent("Mozilla/5.0 (iPhone; CPU iPhone OS 14_6 like Mac OS X) ... Version/14.1.1 Mobile/15E148 Safari/604.1", "browser")  => "Safari"
+//   useragent(ua, "os")          => "iOS"
+//   useragent(ua, "os_version")  => "14.6"
+//   useragent(ua, "device")      => "mobile"
+//
+func UserAgentFunc(ctx expr.EvalContext, item, fieldItem value.Value) (value.Value, bool) {
+	ua, ok := stringArg(item)
+	if !ok || strings.TrimSpace(ua) == "" {
+		return nil, false
+	}
+	field, ok := stringArg(fieldItem)
+	if !ok {
+		return nil, false
+	}
+	switch strings.ToLower(field) {
+	case "browser", "browser_version":
+		name, version := uaMatch(ua, uaBrowsers)
+		if m := uaBotRe.FindStringSubmatch(ua); m != nil {
+			name, version = m[1], ""
+		}
+		if strings.ToLower(field) == "browser" {
+			return value.NewStringValue(name), true
+		}
+		if version == "" {
+			return nil, false
+		}
+		return value.NewStringValue(version), true
+	case "os", "os_version":
+		name, version := uaMatch(ua, uaOses)
+		if name == "Windows" {
+			if named, ok := windowsVersions[version]; ok {
+				version = named
+			}
+		}
+		if strings.ToLower(field) == "os" {
+			return value.NewStringValue(name), true
+		}
+		if version == "" {
+			return nil, false
+		}
+		return value.NewStringValue(version), true
+	case "device":
+		return value.NewStringValue(uaDevice(ua)), true
+	}
+	return value.NewErrorValue(fmt.Sprintf("useragent: unknown field %q", field)), true
+}
@@ -0,0 +1,145 @@
+package builtins
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+// Uuids are the strings of them (as uuid() generates), or their 16 bytes
+// (ByteSliceValue) of uuid_to_bin, as mysql.
+
+// parseUuid the 16 bytes of a uuid of the text of it, of dashes or not and
+// optionally braced, as mysql is_uuid
+func parseUuid(s string) ([]byte, bool) {
+	if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+		s = s[1 : len(s)-1]
+	}
+	switch len(s) {
+	case 36:
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return nil, false
+		}
+		s = s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	case 32:
+	default:
+		return nil, false
+	}
+	by, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, false
+	}
+	return by, true
+}
+
+// formatUuid the text of the 16 bytes of a uuid
+func formatUuid(by []byte) string {
+	h := hex.EncodeToString(by)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// swapUuid the bytes of a uuid of its time-low and time-high parts swapped
+// (or swapped back), so (version 1) uuids of time order sort so as bytes
+func swapUuid(by []byte, unswap bool) []byte {
+	out := make([]byte, 0, 16)
+	if unswap {
+		out = append(out, by[4:8]...)
+		out = append(out, by[2:4]...)
+		out = append(out, by[0:2]...)
+	} else {
+		out = append(out, by[6:8]...)
+		out = append(out, by[4:6]...)
+		out = append(out, by[0:4]...)
+	}
+	return append(out, by[8:]...)
+}
+
+// uuid_v7:  generates a version 7 uuid, of the unix milliseconds now then
+// random bits, so uuids generated later sort after
+//
+//   uuid_v7()   => "0188e3f2-8d4a-7c3e-9a1b-2f4c6d8e0a1b"
+//
+func UuidV7Generate(ctx expr.EvalContext) (value.StringValue, bool) {
+	by := make([]byte, 16)
+	if _, err := rand.Read(by[6:]); err != nil {
+		return value.EmptyStringValue, false
+	}
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixNano()/int64(time.Millisecond)))
+	copy(by[:6], ms[2:])
+	by[6] = by[6]&0x0f | 0x70 // version 7
+	by[8] = by[8]&0x3f | 0x80 // rfc 4122 variant
+	return value.NewStringValue(formatUuid(by)), true
+}
+
+// is_uuid:  is a string a uuid, of dashes or not and optionally braced
+//
+//   is_uuid("6ccd780c-baba-1026-9564-5b8c656024db")   => true
+//   is_uuid("6ccd780c-baba-1026-9564-5b8c6560")       => false
+//
+func IsUuidFunc(ctx expr.EvalContext, item value.Value) (value.BoolValue, bool) {
+	val, ok := stringArg(item)
+	if !ok {
+		return value.BoolValueFalse, false
+	}
+	_, ok = parseUuid(val)
+	return value.NewBoolValue(ok), true
+}
+
+// uuid_to_bin:  the 16 bytes of a uuid, of its time parts swapped first if
+// the optional swap flag is true.  NULL if not a uuid.
+//
+//   uuid_to_bin("6ccd780c-baba-1026-9564-5b8c656024db")         => 6CCD780CBABA102695645B8C656024DB
+//   uuid_to_bin("6ccd780c-baba-1026-9564-5b8c656024db", true)   => 1026BABA6CCD780C95645B8C656024DB
+//
+func UuidToBinFunc(ctx expr.EvalContext, vals ...value.Value) (value.ByteSliceValue, bool) {
+	if len(vals) < 1 || len(vals) > 2 {
+		return value.NewByteSliceValue(nil), false
+	}
+	val, ok := stringArg(vals[0])
+	if !ok {
+		return value.NewByteSliceValue(nil), false
+	}
+	by, ok := parseUuid(val)
+	if !ok {
+		return value.NewByteSliceValue(nil), false
+	}
+	if len(vals) == 2 && uuidSwap(vals[1]) {
+		by = swapUuid(by, false)
+	}
+	return value.NewByteSliceValue(by), true
+}
+
+// bin_to_uuid:  the uuid of 16 bytes, of its time parts swapped back if
+// the optional swap flag is true.  NULL if not 16 bytes.
+//
+//   bin_to_uuid(uuid_to_bin(id, true), true)   => id
+//
+func BinToUuidFunc(ctx expr.EvalContext, vals ...value.Value) (value.StringValue, bool) {
+	if len(vals) < 1 || len(vals) > 2 {
+		return value.EmptyStringValue, false
+	}
+	by, ok := bytesArg(vals[0])
+	if !ok || len(by) != 16 {
+		return value.EmptyStringValue, false
+	}
+	if len(vals) == 2 && uuidSwap(vals[1]) {
+		by = swapUuid(by, true)
+	}
+	return value.NewStringValue(formatUuid(by)), true
+}
+
+// uuidSwap is the swap flag of uuid_to_bin and bin_to_uuid set, true or 1
+func uuidSwap(v value.Value) bool {
+	switch vt := v.(type) {
+	case value.BoolValue:
+		return vt.Val()
+	}
+	n, ok := value.ValueToInt64(v)
+	return ok && n != 0
+}
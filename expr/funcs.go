@@ -3,6 +3,7 @@ package expr
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 
@@ -19,6 +20,12 @@ var (
 	aggFuncs = make(map[string]Func)
 )
 
+// LazyArg an argument of a function evaluated when (and only if) the
+// function calls it, of NilValue if it does not evaluate (ie a missing field)
+type LazyArg func() value.Value
+
+var lazyArgType = reflect.TypeOf(LazyArg(nil))
+
 // FuncResolver is a function resolution service that allows
 //  local/namespaced function resolution
 type FuncResolver interface {
@@ -62,6 +69,13 @@ func (m *FuncRegistry) FuncGet(name string) (Func, bool) {
 //      func(ctx expr.ContextReader, value.Value, value.Value) (value.NumberValue, bool) {
 //          // function
 //      }
+//
+//  Functions of variadic LazyArg arguments evaluate only the arguments they
+//  ask for, ie if() of the branch taken:
+//
+//      func(ctx expr.EvalContext, args ...expr.LazyArg) (value.Value, bool) {
+//          // function
+//      }
 func FuncAdd(name string, fn interface{}) {
 	funcMu.Lock()
 	defer funcMu.Unlock()
@@ -81,6 +95,113 @@ func AggFuncAdd(name string, fn interface{}) {
 	aggFuncs[name] = fun
 }
 
+// WindowFuncAdd Adding window functions, which only have a value evaluated
+//  by the window operator over the rows of their OVER window
+func WindowFuncAdd(name string, fn interface{}) {
+	funcMu.Lock()
+	defer funcMu.Unlock()
+	name = strings.ToLower(name)
+	fun := makeFunc(name, fn)
+	fun.Window = true
+	funcs[name] = fun
+}
+
+// FuncDoc the documentation of a function, for SHOW FUNCTIONS and for
+//  ui's to offer autocomplete and help of
+type FuncDoc struct {
+	Category  string // ie string, math, date, aggregate
+	Signature string // the arguments, ie "(str, start [, length])"
+	Doc       string // short description
+}
+
+// FuncInfo the metadata of a registered function, see FuncsInfo
+type FuncInfo struct {
+	Name      string `json:"name"`
+	Category  string `json:"category"`
+	Signature string `json:"signature"`
+	Doc       string `json:"doc"`
+	Aggregate bool   `json:"aggregate"`
+	Window    bool   `json:"window"`
+	Returns   string `json:"returns"` // the value type returned, "value" of any
+}
+
+// FuncDescribe document a registered function, false if there is no
+//  function of name.  Document after adding, adding replaces the func.
+//
+//      expr.FuncAdd("email_is_valid", EmailIsValid)
+//      expr.FuncDescribe("email_is_valid", expr.FuncDoc{
+//          Category: "string", Signature: "(email)", Doc: "is the email valid"})
+func FuncDescribe(name string, doc FuncDoc) bool {
+	funcMu.Lock()
+	defer funcMu.Unlock()
+	name = strings.ToLower(name)
+	fun, ok := funcs[name]
+	if !ok {
+		return false
+	}
+	fun.FuncDoc = doc
+	funcs[name] = fun
+	if _, isAgg := aggFuncs[name]; isAgg {
+		aggFuncs[name] = fun
+	}
+	return true
+}
+
+// FuncsInfo the metadata of the global funcs sorted by name
+func FuncsInfo() []FuncInfo {
+	funcMu.Lock()
+	defer funcMu.Unlock()
+	infos := make([]FuncInfo, 0, len(funcs))
+	for _, fun := range funcs {
+		infos = append(infos, fun.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// FuncInfoGet the metadata of the global func of name
+func FuncInfoGet(name string) (FuncInfo, bool) {
+	funcMu.Lock()
+	defer funcMu.Unlock()
+	fun, ok := funcs[strings.ToLower(name)]
+	if !ok {
+		return FuncInfo{}, false
+	}
+	return fun.Info(), true
+}
+
+// Info the metadata of a func, of a signature of its go args if undocumented
+func (m *Func) Info() FuncInfo {
+	info := FuncInfo{
+		Name:      m.Name,
+		Category:  m.Category,
+		Signature: m.Signature,
+		Doc:       m.Doc,
+		Aggregate: m.Aggregate,
+		Window:    m.Window,
+		Returns:   m.ReturnValueType.String(),
+	}
+	if info.Signature == "" {
+		args := make([]string, len(m.Args))
+		for i := range args {
+			args[i] = fmt.Sprintf("arg%d", i+1)
+		}
+		if m.VariadicArgs && len(args) > 0 {
+			args[len(args)-1] = "args..."
+		}
+		info.Signature = "(" + strings.Join(args, ", ") + ")"
+	}
+	if info.Category == "" {
+		switch {
+		case m.Aggregate:
+			info.Category = "aggregate"
+		case m.Window:
+			info.Category = "window"
+		}
+	}
+	return info
+}
+
 // FuncsGet get the global func registry
 func FuncsGet() map[string]Func {
 	return funcs
@@ -122,6 +243,7 @@ func makeFunc(name string, fn interface{}) Func {
 	f.Args = make([]reflect.Value, methodNumArgs)
 	if funcType.IsVariadic() {
 		f.VariadicArgs = true
+		f.LazyArgs = funcType.In(funcType.NumIn()-1).Elem() == lazyArgType
 	}
 
 	return f
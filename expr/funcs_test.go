@@ -0,0 +1,51 @@
+package expr_test
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+func TestFuncInfo(t *testing.T) {
+	expr.FuncAdd("test_undocumented", func(ctx expr.EvalContext, a value.Value, rest ...value.Value) (value.StringValue, bool) {
+		return value.EmptyStringValue, false
+	})
+	info, ok := expr.FuncInfoGet("test_undocumented")
+	assert.T(t, ok)
+	assert.Equal(t, "(arg1, args...)", info.Signature)
+	assert.Equal(t, "", info.Category)
+	assert.Equal(t, "string", info.Returns)
+
+	ok = expr.FuncDescribe("Test_Undocumented", expr.FuncDoc{Category: "test", Signature: "(str, more...)", Doc: "nothing"})
+	assert.T(t, ok)
+	info, _ = expr.FuncInfoGet("test_undocumented")
+	assert.Equal(t, expr.FuncInfo{Name: "test_undocumented", Category: "test", Signature: "(str, more...)",
+		Doc: "nothing", Returns: "string"}, info)
+	assert.T(t, !expr.FuncDescribe("test_not_a_func", expr.FuncDoc{Doc: "nothing"}))
+
+	expr.AggFuncAdd("test_agg", func(ctx expr.EvalContext, v value.Value) (value.Value, bool) {
+		return v, true
+	})
+	expr.WindowFuncAdd("test_window", func(ctx expr.EvalContext) (value.IntValue, bool) {
+		return value.NewIntNil(), false
+	})
+	found := 0
+	for _, info := range expr.FuncsInfo() {
+		switch info.Name {
+		case "test_agg":
+			found++
+			assert.T(t, info.Aggregate && !info.Window)
+			assert.Equal(t, "aggregate", info.Category)
+			assert.Equal(t, "value", info.Returns)
+		case "test_window":
+			found++
+			assert.T(t, info.Window && !info.Aggregate)
+			assert.Equal(t, "()", info.Signature)
+		}
+	}
+	assert.Equal(t, 2, found)
+	assert.T(t, expr.IsAgg("test_agg"))
+}
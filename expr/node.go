@@ -127,9 +127,12 @@ type (
 	Func struct {
 		Name      string
 		Aggregate bool // is this aggregate func?
+		Window    bool // is this window func, of a value only with OVER?
+		FuncDoc        // documentation, see FuncDescribe
 		// The arguments we expect
 		Args            []reflect.Value
 		VariadicArgs    bool
+		LazyArgs        bool // variadic args are expr.LazyArg, evaluated on demand
 		Return          reflect.Value
 		ReturnValueType value.ValueType
 		// The actual Go Function
@@ -141,10 +144,11 @@ type (
 	//
 	// interfaces:   Node
 	FuncNode struct {
-		Name    string // Name of func
-		F       Func   // The actual function that this AST maps to
-		Missing bool
-		Args    []Node // Arguments are them-selves nodes
+		Name     string // Name of func
+		F        Func   // The actual function that this AST maps to
+		Missing  bool
+		Distinct bool   // aggregate of the distinct values of args, count(DISTINCT x)
+		Args     []Node // Arguments are them-selves nodes
 	}
 
 	// IdentityNode will look up a value out of a env bag
@@ -157,6 +161,8 @@ type (
 		escaped  string
 		left     string
 		right    string
+		line     int // of the statement it was parsed from, 0 if not parsed
+		col      int
 	}
 
 	// StringNode holds a value literal, quotes not included
@@ -366,6 +372,9 @@ func (m *FuncNode) String() string {
 func (m *FuncNode) WriteDialect(w DialectWriter) {
 	io.WriteString(w, m.Name)
 	io.WriteString(w, "(")
+	if m.Distinct {
+		io.WriteString(w, "DISTINCT ")
+	}
 	for i, arg := range m.Args {
 		if i > 0 {
 			io.WriteString(w, ", ")
@@ -412,6 +421,7 @@ func (f *FuncNode) Type() reflect.Value { return f.F.Return }
 func (m *FuncNode) ToPB() *NodePb {
 	n := &FuncNodePb{}
 	n.Name = m.Name
+	n.Distinct = m.Distinct
 	n.Args = make([]NodePb, len(m.Args))
 	for i, a := range m.Args {
 		//u.Debugf("Func ToPB: arg %T", a)
@@ -426,9 +436,10 @@ func (m *FuncNode) FromPB(n *NodePb) Node {
 		// Panic?
 	}
 	return &FuncNode{
-		Name: n.Fn.Name,
-		Args: NodesFromNodesPb(n.Fn.Args),
-		F:    fn,
+		Name:     n.Fn.Name,
+		Distinct: n.Fn.Distinct,
+		Args:     NodesFromNodesPb(n.Fn.Args),
+		F:        fn,
 	}
 }
 func (m *FuncNode) Equal(n Node) bool {
@@ -442,7 +453,7 @@ func (m *FuncNode) Equal(n Node) bool {
 		return false
 	}
 	if nt, ok := n.(*FuncNode); ok {
-		if m.Name != nt.Name {
+		if m.Name != nt.Name || m.Distinct != nt.Distinct {
 			return false
 		}
 		for i, arg := range nt.Args {
@@ -511,6 +522,10 @@ func (m *NumberNode) ToPB() *NodePb {
 	return &NodePb{Nn: n}
 }
 func (m *NumberNode) FromPB(n *NodePb) Node {
+	// IsInt/IsFloat are not serialized, re-derive them from the text
+	if nn, err := NewNumberStr(n.Nn.Text); err == nil {
+		return nn
+	}
 	return &NumberNode{
 		Text:    n.Nn.Text,
 		Float64: n.Nn.Fv,
@@ -693,6 +708,13 @@ func (m *ValueNode) Equal(n Node) bool {
 
 func NewIdentityNode(tok *lex.Token) *IdentityNode {
 	in := &IdentityNode{Text: tok.V, Quote: tok.Quote}
+	if tok.Line > 0 {
+		// the column of a token is of its end, before any closing quote
+		in.line, in.col = tok.Line, tok.Column-len(tok.V)+1
+		if tok.Quote != 0 {
+			in.col--
+		}
+	}
 	in.load()
 	return in
 }
@@ -732,6 +754,17 @@ func (m *IdentityNode) WriteDialect(w DialectWriter) {
 	}
 	w.WriteIdentity(m.Text)
 }
+
+// Rename the identity to name, keeping its quote and position (ie to the
+// name a source gives a column matched regardless of case)
+func (m *IdentityNode) Rename(name string) {
+	m.Text, m.original = name, ""
+	m.load()
+}
+
+// Position the 1 based line and column of the identity in the statement it
+// was parsed from, 0, 0 if it was not parsed (ie of a rewrite)
+func (m *IdentityNode) Position() (line, col int) { return m.line, m.col }
 func (m *IdentityNode) OriginalText() string {
 	if m.original != "" {
 		return m.original
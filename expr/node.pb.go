@@ -3,22 +3,24 @@
 // DO NOT EDIT!
 
 /*
-	Package expr is a generated protocol buffer package.
-
-	It is generated from these files:
-		node.proto
-
-	It has these top-level messages:
-		NodePb
-		BinaryNodePb
-		UnaryNodePb
-		FuncNodePb
-		TriNodePb
-		ArrayNodePb
-		StringNodePb
-		IdentityNodePb
-		NumberNodePb
-		ValueNodePb
+Package expr is a generated protocol buffer package.
+
+It is generated from these files:
+
+	node.proto
+
+It has these top-level messages:
+
+	NodePb
+	BinaryNodePb
+	UnaryNodePb
+	FuncNodePb
+	TriNodePb
+	ArrayNodePb
+	StringNodePb
+	IdentityNodePb
+	NumberNodePb
+	ValueNodePb
 */
 package expr
 
@@ -82,6 +84,7 @@ func (*UnaryNodePb) ProtoMessage()    {}
 type FuncNodePb struct {
 	Name             string   `protobuf:"bytes,1,req,name=name" json:"name"`
 	Args             []NodePb `protobuf:"bytes,2,rep,name=args" json:"args"`
+	Distinct         bool     `protobuf:"varint,3,opt,name=distinct" json:"distinct"`
 	XXX_unrecognized []byte   `json:"-"`
 }
 
@@ -397,6 +400,14 @@ func (m *FuncNodePb) MarshalTo(data []byte) (int, error) {
 			i += n
 		}
 	}
+	data[i] = 0x18
+	i++
+	if m.Distinct {
+		data[i] = 1
+	} else {
+		data[i] = 0
+	}
+	i++
 	if m.XXX_unrecognized != nil {
 		i += copy(data[i:], m.XXX_unrecognized)
 	}
@@ -741,6 +752,7 @@ func (m *FuncNodePb) Size() (n int) {
 			n += 1 + l + sovNode(uint64(l))
 		}
 	}
+	n += 2
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -1548,6 +1560,26 @@ func (m *FuncNodePb) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Distinct", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowNode
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Distinct = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipNode(data[iNdEx:])
@@ -16,6 +16,7 @@ var pbTests = []string{
 	`"xyz" BETWEEN todate("1/1/2015") AND 50`,
 	`name == "bob"`,
 	`name = 'bob'`,
+	`count(DISTINCT user_id)`,
 }
 
 func TestNodePb(t *testing.T) {
@@ -34,4 +35,26 @@ func TestNodePb(t *testing.T) {
 	}
 }
 
+func TestNumberNodePb(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		text    string
+		isInt   bool
+		isFloat bool
+	}{
+		{"1", true, true},
+		{"1.5", false, true},
+		{"-30", true, true},
+	}
+	for _, tt := range tests {
+		nn, err := expr.NewNumberStr(tt.text)
+		assert.Tf(t, err == nil, "%s: %v", tt.text, err)
+		n2 := nn.FromPB(nn.ToPB())
+		nn2, ok := n2.(*expr.NumberNode)
+		assert.Tf(t, ok, "%s: %T", tt.text, n2)
+		assert.Tf(t, nn2.IsInt == tt.isInt, "%s: IsInt %v", tt.text, nn2.IsInt)
+		assert.Tf(t, nn2.IsFloat == tt.isFloat, "%s: IsFloat %v", tt.text, nn2.IsFloat)
+	}
+}
+
 var _ = u.EMPTY
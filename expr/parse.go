@@ -525,6 +525,10 @@ func (t *Tree) v(depth int) Node {
 	case lex.TokenIdentity:
 		n := NewIdentityNode(&cur)
 		t.Next()
+		switch t.Cur().T {
+		case lex.TokenArrow, lex.TokenDoubleArrow:
+			return t.jsonPath(n)
+		}
 		return n
 	case lex.TokenNull:
 		t.Next()
@@ -595,6 +599,11 @@ func (t *Tree) Func(depth int, funcTok lex.Token) (fn *FuncNode) {
 	//t.Next() // step forward to hopefully left paren
 	t.expect(lex.TokenLeftParenthesis, "func")
 	t.Next() // Are we sure we consume?
+	if t.Cur().T == lex.TokenDistinct {
+		//   count(DISTINCT user_id)
+		fn.Distinct = true
+		t.Next()
+	}
 
 	switch {
 	// Ugh, we need a way of identifying which functions get this special
@@ -696,6 +705,41 @@ func (t *Tree) Func(depth int, funcTok lex.Token) (fn *FuncNode) {
 	}
 }
 
+// jsonPath the mysql json path operators of an identity, as the functions
+// they are sugar for
+//
+//   payload->"$.user.name"    =>  json_extract(payload, "$.user.name")
+//   payload->>"$.user.name"   =>  json_unquote(json_extract(payload, "$.user.name"))
+func (t *Tree) jsonPath(n Node) Node {
+	op := t.Next()
+	path := t.Next()
+	if path.T != lex.TokenValue {
+		t.unexpected(path, "json path")
+	}
+	n = t.funcOf("json_extract", n, NewStringNodeToken(path))
+	if op.T == lex.TokenDoubleArrow {
+		n = t.funcOf("json_unquote", n)
+	}
+	return n
+}
+
+// funcOf a FuncNode of the function of name of args
+func (t *Tree) funcOf(name string, args ...Node) *FuncNode {
+	funcImpl, ok := t.getFunction(name)
+	if !ok {
+		if t.runCheck {
+			t.errorf("non existent function %s", name)
+		}
+		funcImpl = Func{Name: name}
+	}
+	fn := NewFuncNode(name, funcImpl)
+	fn.Missing = !ok
+	for _, arg := range args {
+		fn.append(arg)
+	}
+	return fn
+}
+
 // get Function from Global
 func (t *Tree) getFunction(name string) (v Func, ok bool) {
 	if t.fr != nil {
@@ -120,6 +120,10 @@ var parseTests = []parseTest{
 	{"in ident", `1 IN ident`, noError, `1 IN ident`},
 	{"general parse test", "`tablename` LIKE \"%\"", noError, "tablename LIKE \"%\""},
 	{"general parse test", `"value" IN hosts(@@content_whitelist_domains)`, noError, "\"value\" IN hosts(`@@content_whitelist_domains`)"},
+	{"json path", `payload->"$.user.name" == "bob"`, noError, `json_extract(payload, "$.user.name") == "bob"`},
+	{"json path unquoted", `payload->>"$.tags[0]"`, noError, `json_unquote(json_extract(payload, "$.tags[0]"))`},
+	{"json path not a string", `payload->5`, hasError, ``},
+	{"distinct aggregate", `count(DISTINCT user_id)`, noError, `count(DISTINCT user_id)`},
 }
 
 func TestParseExpressions(t *testing.T) {
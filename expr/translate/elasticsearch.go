@@ -0,0 +1,168 @@
+package translate
+
+import (
+	"strings"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+)
+
+var (
+	// Ensure we implement translator
+	_ Translator = (*EsTranslator)(nil)
+)
+
+// EsTranslator translates expressions into elasticsearch query dsl filters
+//
+//   x > 5 AND y IN ("a","b")
+//      => {"bool": {"filter": [
+//            {"range": {"x": {"gt": 5}}},
+//            {"terms": {"y": ["a","b"]}}
+//         ]}}
+//
+// Filters are plain map[string]interface{}, json encode for the request body.
+type EsTranslator struct{}
+
+// NewEsTranslator create an elasticsearch query dsl translator
+func NewEsTranslator() *EsTranslator { return &EsTranslator{} }
+
+func (m *EsTranslator) Translate(node expr.Node) (interface{}, error) {
+	return m.walk(node)
+}
+
+func (m *EsTranslator) walk(node expr.Node) (map[string]interface{}, error) {
+	switch n := node.(type) {
+	case *expr.BinaryNode:
+		return m.walkBinary(n)
+	case *expr.UnaryNode:
+		if n.Operator.T != lex.TokenNegate {
+			return nil, unsupported(n, "unsupported unary operator")
+		}
+		q, err := m.walk(n.Arg)
+		if err != nil {
+			return nil, err
+		}
+		return esBool("must_not", q), nil
+	case *expr.TriNode:
+		if n.Operator.T != lex.TokenBetween {
+			return nil, unsupported(n, "unsupported operator")
+		}
+		f, ok := fieldName(n.Args[0])
+		lower, lok := literal(n.Args[1])
+		upper, uok := literal(n.Args[2])
+		if !ok || !lok || !uok {
+			return nil, unsupported(n, "BETWEEN requires field and literal bounds")
+		}
+		return esQuery("range", f, map[string]interface{}{"gte": lower, "lte": upper}), nil
+	case *expr.FuncNode:
+		if strings.EqualFold(n.Name, "exists") && len(n.Args) == 1 {
+			if f, ok := fieldName(n.Args[0]); ok {
+				return esExists(f), nil
+			}
+		}
+		return nil, unsupported(n, "unsupported function")
+	case *expr.IdentityNode:
+		// where is_active
+		if f, ok := fieldName(n); ok {
+			return esQuery("term", f, true), nil
+		}
+	}
+	return nil, unsupported(node, "unsupported node")
+}
+
+func (m *EsTranslator) walkBinary(n *expr.BinaryNode) (map[string]interface{}, error) {
+	switch op := n.Operator.T; {
+	case isAnd(op), isOr(op):
+		qs := make([]interface{}, 0, len(n.Args))
+		for _, arg := range n.Args {
+			q, err := m.walk(arg)
+			if err != nil {
+				return nil, err
+			}
+			qs = append(qs, q)
+		}
+		if isAnd(op) {
+			return map[string]interface{}{"bool": map[string]interface{}{"filter": qs}}, nil
+		}
+		return map[string]interface{}{"bool": map[string]interface{}{
+			"should":               qs,
+			"minimum_should_match": 1,
+		}}, nil
+	case op == lex.TokenIN:
+		f, vals, err := inArgs(n)
+		if err != nil {
+			return nil, err
+		}
+		return esQuery("terms", f, vals), nil
+	case op == lex.TokenLike:
+		f, pattern, err := likeArgs(n)
+		if err != nil {
+			return nil, err
+		}
+		return esQuery("wildcard", f, likeToWildcard(pattern)), nil
+	case op == lex.TokenContains:
+		f, s, err := likeArgs(n)
+		if err != nil {
+			return nil, err
+		}
+		return esQuery("wildcard", f, "*"+escapeWildcard(s)+"*"), nil
+	}
+
+	c, err := newComparison(n)
+	if err != nil {
+		return nil, err
+	}
+	switch c.op {
+	case lex.TokenEqual:
+		if c.val == nil {
+			return esBool("must_not", esExists(c.field)), nil
+		}
+		return esQuery("term", c.field, c.val), nil
+	case lex.TokenNE:
+		if c.val == nil {
+			return esExists(c.field), nil
+		}
+		return esBool("must_not", esQuery("term", c.field, c.val)), nil
+	case lex.TokenGT:
+		return esQuery("range", c.field, map[string]interface{}{"gt": c.val}), nil
+	case lex.TokenGE:
+		return esQuery("range", c.field, map[string]interface{}{"gte": c.val}), nil
+	case lex.TokenLT:
+		return esQuery("range", c.field, map[string]interface{}{"lt": c.val}), nil
+	case lex.TokenLE:
+		return esQuery("range", c.field, map[string]interface{}{"lte": c.val}), nil
+	}
+	return nil, unsupported(n, "unsupported operator")
+}
+
+func esQuery(kind, field string, val interface{}) map[string]interface{} {
+	return map[string]interface{}{kind: map[string]interface{}{field: val}}
+}
+
+func esExists(field string) map[string]interface{} {
+	return map[string]interface{}{"exists": map[string]interface{}{"field": field}}
+}
+
+func esBool(clause string, q map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"bool": map[string]interface{}{clause: []interface{}{q}}}
+}
+
+// likeToWildcard convert a sql LIKE pattern to an es wildcard pattern
+func likeToWildcard(pattern string) string {
+	var buf strings.Builder
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			buf.WriteByte('*')
+		case '_':
+			buf.WriteByte('?')
+		default:
+			buf.WriteString(escapeWildcard(string(r)))
+		}
+	}
+	return buf.String()
+}
+
+func escapeWildcard(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`).Replace(s)
+}
@@ -0,0 +1,149 @@
+package translate
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+)
+
+var (
+	// Ensure we implement translator
+	_ Translator = (*MongoTranslator)(nil)
+)
+
+// MongoTranslator translates expressions into mongo query filter documents
+//
+//   x > 5 AND y IN ("a","b")
+//      => {"$and": [{"x": {"$gt": 5}}, {"y": {"$in": ["a","b"]}}]}
+//
+// Documents are plain map[string]interface{} so they may be marshalled by
+// any bson (or json) encoder.
+type MongoTranslator struct{}
+
+// NewMongoTranslator create a mongo filter document translator
+func NewMongoTranslator() *MongoTranslator { return &MongoTranslator{} }
+
+func (m *MongoTranslator) Translate(node expr.Node) (interface{}, error) {
+	return m.walk(node)
+}
+
+func (m *MongoTranslator) walk(node expr.Node) (map[string]interface{}, error) {
+	switch n := node.(type) {
+	case *expr.BinaryNode:
+		return m.walkBinary(n)
+	case *expr.UnaryNode:
+		if n.Operator.T != lex.TokenNegate {
+			return nil, unsupported(n, "unsupported unary operator")
+		}
+		return m.walkNegate(n.Arg)
+	case *expr.TriNode:
+		if n.Operator.T != lex.TokenBetween {
+			return nil, unsupported(n, "unsupported operator")
+		}
+		return m.walkBetween(n)
+	case *expr.FuncNode:
+		return m.walkFunc(n)
+	case *expr.IdentityNode:
+		// where is_active
+		if f, ok := fieldName(n); ok {
+			return map[string]interface{}{f: true}, nil
+		}
+	}
+	return nil, unsupported(node, "unsupported node")
+}
+
+func (m *MongoTranslator) walkBinary(n *expr.BinaryNode) (map[string]interface{}, error) {
+	switch op := n.Operator.T; {
+	case isAnd(op), isOr(op):
+		docs := make([]interface{}, 0, len(n.Args))
+		for _, arg := range n.Args {
+			doc, err := m.walk(arg)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+		if isAnd(op) {
+			return map[string]interface{}{"$and": docs}, nil
+		}
+		return map[string]interface{}{"$or": docs}, nil
+	case op == lex.TokenIN:
+		f, vals, err := inArgs(n)
+		if err != nil {
+			return nil, err
+		}
+		return fieldOp(f, "$in", vals), nil
+	case op == lex.TokenLike:
+		f, pattern, err := likeArgs(n)
+		if err != nil {
+			return nil, err
+		}
+		return fieldOp(f, "$regex", likeToRegex(pattern)), nil
+	case op == lex.TokenContains:
+		f, s, err := likeArgs(n)
+		if err != nil {
+			return nil, err
+		}
+		return fieldOp(f, "$regex", regexp.QuoteMeta(s)), nil
+	}
+
+	c, err := newComparison(n)
+	if err != nil {
+		return nil, err
+	}
+	switch c.op {
+	case lex.TokenEqual:
+		return map[string]interface{}{c.field: c.val}, nil
+	case lex.TokenNE:
+		return fieldOp(c.field, "$ne", c.val), nil
+	case lex.TokenGT:
+		return fieldOp(c.field, "$gt", c.val), nil
+	case lex.TokenGE:
+		return fieldOp(c.field, "$gte", c.val), nil
+	case lex.TokenLT:
+		return fieldOp(c.field, "$lt", c.val), nil
+	case lex.TokenLE:
+		return fieldOp(c.field, "$lte", c.val), nil
+	}
+	return nil, unsupported(n, "unsupported operator")
+}
+
+func (m *MongoTranslator) walkNegate(node expr.Node) (map[string]interface{}, error) {
+	if bn, ok := node.(*expr.BinaryNode); ok && bn.Operator.T == lex.TokenIN {
+		f, vals, err := inArgs(bn)
+		if err != nil {
+			return nil, err
+		}
+		return fieldOp(f, "$nin", vals), nil
+	}
+	doc, err := m.walk(node)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"$nor": []interface{}{doc}}, nil
+}
+
+func (m *MongoTranslator) walkBetween(n *expr.TriNode) (map[string]interface{}, error) {
+	f, ok := fieldName(n.Args[0])
+	lower, lok := literal(n.Args[1])
+	upper, uok := literal(n.Args[2])
+	if !ok || !lok || !uok {
+		return nil, unsupported(n, "BETWEEN requires field and literal bounds")
+	}
+	return map[string]interface{}{f: map[string]interface{}{"$gte": lower, "$lte": upper}}, nil
+}
+
+func (m *MongoTranslator) walkFunc(n *expr.FuncNode) (map[string]interface{}, error) {
+	if strings.EqualFold(n.Name, "exists") && len(n.Args) == 1 {
+		if f, ok := fieldName(n.Args[0]); ok {
+			return fieldOp(f, "$exists", true), nil
+		}
+	}
+	return nil, unsupported(n, "unsupported function")
+}
+
+func fieldOp(field, op string, val interface{}) map[string]interface{} {
+	return map[string]interface{}{field: map[string]interface{}{op: val}}
+}
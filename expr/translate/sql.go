@@ -0,0 +1,199 @@
+package translate
+
+import (
+	"io"
+	"strings"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+)
+
+var (
+	// Ensure we implement translator
+	_ Translator = (*SqlTranslator)(nil)
+)
+
+// SqlTranslator translates expressions into a sql WHERE clause string for
+// sql databases, with the literal and identity escaping of the dialect.
+//
+//   x > 5 AND y IN ("a","b")  => x > 5 AND y IN ("a", "b")   mysql
+//                             => x > 5 AND y IN ('a', 'b')   postgres
+//
+// Identities are only quoted if required.  Comparison to NULL is written as IS [NOT] NULL.  Functions are only
+// translated if the database is known to have them, see Funcs.
+type SqlTranslator struct {
+	LiteralQuote  byte
+	IdentityQuote byte
+	// Funcs lower-cased names of functions native to the database
+	Funcs map[string]struct{}
+}
+
+// NewSqlTranslator create a sql where translator with given literal and
+// identity quote characters, and functions the database supports.
+func NewSqlTranslator(literalQuote, identityQuote byte, funcs ...string) *SqlTranslator {
+	m := &SqlTranslator{
+		LiteralQuote:  literalQuote,
+		IdentityQuote: identityQuote,
+		Funcs:         make(map[string]struct{}, len(funcs)),
+	}
+	for _, f := range funcs {
+		m.Funcs[strings.ToLower(f)] = struct{}{}
+	}
+	return m
+}
+
+func (m *SqlTranslator) Translate(node expr.Node) (interface{}, error) {
+	w := expr.NewDialectWriter(m.LiteralQuote, m.IdentityQuote)
+	if err := m.write(w, node, false); err != nil {
+		return nil, err
+	}
+	return w.String(), nil
+}
+
+func (m *SqlTranslator) write(w expr.DialectWriter, node expr.Node, nested bool) error {
+	switch n := node.(type) {
+	case *expr.IdentityNode:
+		if n.IsBooleanIdentity() {
+			io.WriteString(w, strings.ToUpper(n.Text))
+			return nil
+		}
+		n.WriteDialect(w)
+	case *expr.StringNode:
+		w.WriteLiteral(n.Text)
+	case *expr.NumberNode:
+		w.WriteNumber(n.Text)
+	case *expr.NullNode:
+		io.WriteString(w, "NULL")
+	case *expr.ValueNode:
+		v, ok := literal(n)
+		if !ok {
+			return unsupported(n, "unsupported value")
+		}
+		if v == nil {
+			io.WriteString(w, "NULL")
+			return nil
+		}
+		if s, isStr := v.(string); isStr {
+			w.WriteLiteral(s)
+			return nil
+		}
+		n.WriteDialect(w)
+	case *expr.ArrayNode:
+		io.WriteString(w, "(")
+		for i, arg := range n.Args {
+			if i > 0 {
+				io.WriteString(w, ", ")
+			}
+			if err := m.write(w, arg, true); err != nil {
+				return err
+			}
+		}
+		io.WriteString(w, ")")
+	case *expr.BinaryNode:
+		return m.writeBinary(w, n, nested)
+	case *expr.UnaryNode:
+		switch n.Operator.T {
+		case lex.TokenNegate:
+			io.WriteString(w, "NOT (")
+			if err := m.write(w, n.Arg, false); err != nil {
+				return err
+			}
+			io.WriteString(w, ")")
+		case lex.TokenMinus:
+			io.WriteString(w, "-")
+			return m.write(w, n.Arg, true)
+		default:
+			return unsupported(n, "unsupported unary operator")
+		}
+	case *expr.TriNode:
+		if n.Operator.T != lex.TokenBetween {
+			return unsupported(n, "unsupported operator")
+		}
+		if err := m.write(w, n.Args[0], true); err != nil {
+			return err
+		}
+		io.WriteString(w, " BETWEEN ")
+		if err := m.write(w, n.Args[1], true); err != nil {
+			return err
+		}
+		io.WriteString(w, " AND ")
+		return m.write(w, n.Args[2], true)
+	case *expr.FuncNode:
+		if _, ok := m.Funcs[strings.ToLower(n.Name)]; !ok {
+			return unsupported(n, "function not supported by database")
+		}
+		io.WriteString(w, n.Name)
+		io.WriteString(w, "(")
+		for i, arg := range n.Args {
+			if i > 0 {
+				io.WriteString(w, ", ")
+			}
+			if err := m.write(w, arg, false); err != nil {
+				return err
+			}
+		}
+		io.WriteString(w, ")")
+	default:
+		return unsupported(node, "unsupported node")
+	}
+	return nil
+}
+
+func (m *SqlTranslator) writeBinary(w expr.DialectWriter, n *expr.BinaryNode, nested bool) error {
+	var op string
+	switch t := n.Operator.T; {
+	case isAnd(t):
+		op = "AND"
+	case isOr(t):
+		op = "OR"
+	case t == lex.TokenEqual, t == lex.TokenEqualEqual:
+		if _, isNull := n.Args[1].(*expr.NullNode); isNull {
+			op = "IS"
+		} else {
+			op = "="
+		}
+	case t == lex.TokenNE:
+		if _, isNull := n.Args[1].(*expr.NullNode); isNull {
+			op = "IS NOT"
+		} else {
+			op = "!="
+		}
+	case t == lex.TokenGT, t == lex.TokenGE, t == lex.TokenLT, t == lex.TokenLE,
+		t == lex.TokenPlus, t == lex.TokenMinus, t == lex.TokenMultiply,
+		t == lex.TokenDivide, t == lex.TokenModulus:
+		op = n.Operator.V
+	case t == lex.TokenIN:
+		op = "IN"
+	case t == lex.TokenLike:
+		op = "LIKE"
+	default:
+		return unsupported(n, "unsupported operator")
+	}
+	if len(n.Args) != 2 {
+		return unsupported(n, "expected 2 args")
+	}
+	if nested {
+		io.WriteString(w, "(")
+	}
+	for i, arg := range n.Args {
+		if i > 0 {
+			io.WriteString(w, " ")
+			io.WriteString(w, op)
+			io.WriteString(w, " ")
+		}
+		// operands of AND/OR only need parens if they are the other one
+		argNested := true
+		if op == "AND" || op == "OR" {
+			bn, isBinary := arg.(*expr.BinaryNode)
+			argNested = isBinary && (isAnd(bn.Operator.T) || isOr(bn.Operator.T)) &&
+				isAnd(bn.Operator.T) != (op == "AND")
+		}
+		if err := m.write(w, arg, argNested); err != nil {
+			return err
+		}
+	}
+	if nested {
+		io.WriteString(w, ")")
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+// Package translate converts expressions pushed down to a data source into
+// the native query representation of that source (sql where clauses, mongo
+// filter documents, elasticsearch query dsl) so each source does not have
+// to hand-roll its own walk of the expression tree.
+//
+// Translators are registered per source type, the same name a source is
+// registered under in the datasource registry, and the planner looks up the
+// translator for the source of each FROM when planning the where clause.
+package translate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+)
+
+var (
+	_ = u.EMPTY
+
+	registryMu  sync.RWMutex
+	translators = make(map[string]Translator)
+)
+
+// Translator converts an expression into a source-native query.
+type Translator interface {
+	// Translate the expression, returning an *UnsupportedError if any
+	// part of it can not be expressed natively.  Translation is all or
+	// nothing, partial translations would change the meaning of OR/NOT.
+	Translate(node expr.Node) (interface{}, error)
+}
+
+// UnsupportedError the expression (or a part of it) has no native form
+// for this translator, the caller should evaluate it in-process instead.
+type UnsupportedError struct {
+	Node   expr.Node
+	Reason string
+}
+
+func (m *UnsupportedError) Error() string {
+	if m.Node == nil {
+		return fmt.Sprintf("translate: %s", m.Reason)
+	}
+	return fmt.Sprintf("translate: %s: %s", m.Reason, m.Node)
+}
+
+// IsUnsupported is this error an expression that could not be translated
+func IsUnsupported(err error) bool {
+	_, ok := err.(*UnsupportedError)
+	return ok
+}
+
+func unsupported(node expr.Node, reason string) error {
+	return &UnsupportedError{Node: node, Reason: reason}
+}
+
+// Register a translator for a source type, replacing any existing one.
+func Register(sourceType string, t Translator) {
+	if t == nil {
+		panic("translate: Register translator is nil")
+	}
+	registryMu.Lock()
+	translators[strings.ToLower(sourceType)] = t
+	registryMu.Unlock()
+}
+
+// Get the translator for a source type, nil if none is registered.
+func Get(sourceType string) Translator {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return translators[strings.ToLower(sourceType)]
+}
+
+// Translate the expression using the translator registered for source type.
+func Translate(sourceType string, node expr.Node) (interface{}, error) {
+	t := Get(sourceType)
+	if t == nil {
+		return nil, fmt.Errorf("translate: no translator registered for %q", sourceType)
+	}
+	return t.Translate(node)
+}
+
+// comparison a binary comparison of a field against a literal value
+type comparison struct {
+	field string
+	op    lex.TokenType
+	val   interface{}
+}
+
+// newComparison normalize   field op literal   or   literal op field
+// into field op literal, reversing the operator for the latter.
+func newComparison(n *expr.BinaryNode) (*comparison, error) {
+	if len(n.Args) != 2 {
+		return nil, unsupported(n, "expected 2 args")
+	}
+	op := n.Operator.T
+	f, ok := fieldName(n.Args[0])
+	val, isLit := literal(n.Args[1])
+	if !ok {
+		f, ok = fieldName(n.Args[1])
+		val, isLit = literal(n.Args[0])
+		switch op {
+		case lex.TokenGT:
+			op = lex.TokenLT
+		case lex.TokenGE:
+			op = lex.TokenLE
+		case lex.TokenLT:
+			op = lex.TokenGT
+		case lex.TokenLE:
+			op = lex.TokenGE
+		}
+	}
+	if !ok || !isLit {
+		return nil, unsupported(n, "requires field compared to literal")
+	}
+	if op == lex.TokenEqualEqual {
+		op = lex.TokenEqual
+	}
+	return &comparison{field: f, op: op, val: val}, nil
+}
+
+// fieldName the name of a column identity, bool identities are literals
+func fieldName(n expr.Node) (string, bool) {
+	in, ok := n.(*expr.IdentityNode)
+	if !ok || in.IsBooleanIdentity() {
+		return "", false
+	}
+	return in.Text, true
+}
+
+// literal the go value of a literal node
+func literal(n expr.Node) (interface{}, bool) {
+	switch nt := n.(type) {
+	case *expr.StringNode:
+		return nt.Text, true
+	case *expr.NumberNode:
+		if nt.IsInt {
+			return nt.Int64, true
+		}
+		return nt.Float64, true
+	case *expr.NullNode:
+		return nil, true
+	case *expr.ValueNode:
+		if nt.Value == nil || nt.Value.Nil() {
+			return nil, true
+		}
+		return nt.Value.Value(), true
+	case *expr.IdentityNode:
+		if nt.IsBooleanIdentity() {
+			return nt.Bool(), true
+		}
+	}
+	return nil, false
+}
+
+// literals the values of an IN (...) list
+func literals(n expr.Node) ([]interface{}, bool) {
+	an, ok := n.(*expr.ArrayNode)
+	if !ok {
+		return nil, false
+	}
+	vals := make([]interface{}, 0, len(an.Args))
+	for _, arg := range an.Args {
+		v, ok := literal(arg)
+		if !ok {
+			return nil, false
+		}
+		vals = append(vals, v)
+	}
+	return vals, true
+}
+
+// inArgs   field IN (literal, ...)
+func inArgs(n *expr.BinaryNode) (string, []interface{}, error) {
+	f, ok := fieldName(n.Args[0])
+	if !ok {
+		return "", nil, unsupported(n, "IN requires field on left")
+	}
+	vals, ok := literals(n.Args[1])
+	if !ok {
+		return "", nil, unsupported(n, "IN requires list of literals")
+	}
+	return f, vals, nil
+}
+
+// likeArgs   field LIKE "pattern"
+func likeArgs(n *expr.BinaryNode) (string, string, error) {
+	f, ok := fieldName(n.Args[0])
+	if !ok {
+		return "", "", unsupported(n, "requires field on left")
+	}
+	pattern, ok := n.Args[1].(*expr.StringNode)
+	if !ok {
+		return "", "", unsupported(n, "requires string pattern")
+	}
+	return f, pattern.Text, nil
+}
+
+// isAnd, isOr  logical operators are lexed as both AND and &&
+func isAnd(t lex.TokenType) bool { return t == lex.TokenLogicAnd || t == lex.TokenAnd }
+func isOr(t lex.TokenType) bool  { return t == lex.TokenLogicOr || t == lex.TokenOr }
+
+// likeToRegex convert a sql LIKE pattern to an anchored regular expression
+func likeToRegex(pattern string) string {
+	var buf strings.Builder
+	buf.WriteByte('^')
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			buf.WriteString(".*")
+		case '_':
+			buf.WriteByte('.')
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	buf.WriteByte('$')
+	return buf.String()
+}
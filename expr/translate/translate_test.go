@@ -0,0 +1,116 @@
+package translate_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/expr/builtins"
+	"github.com/araddon/qlbridge/expr/translate"
+)
+
+func init() {
+	builtins.LoadAllBuiltins()
+}
+
+type translateTest struct {
+	exprText string
+	sql      string // mysql dialect, empty if un-supported
+	mongo    string // json of filter doc, empty if un-supported
+	es       string // json of query dsl, empty if un-supported
+}
+
+var translateTests = []translateTest{
+	{`x > 5`,
+		"x > 5",
+		`{"x":{"$gt":5}}`,
+		`{"range":{"x":{"gt":5}}}`},
+	{`5 <= x`,
+		"5 <= x",
+		`{"x":{"$gte":5}}`,
+		`{"range":{"x":{"gte":5}}}`},
+	{`name = "bob" AND age != 2.5`,
+		"name = \"bob\" AND age != 2.5",
+		`{"$and":[{"name":"bob"},{"age":{"$ne":2.5}}]}`,
+		`{"bool":{"filter":[{"term":{"name":"bob"}},{"bool":{"must_not":[{"term":{"age":2.5}}]}}]}}`},
+	{`(a = 1 OR b = 2) AND c = true`,
+		"(a = 1 OR b = 2) AND c = TRUE",
+		`{"$and":[{"$or":[{"a":1},{"b":2}]},{"c":true}]}`,
+		`{"bool":{"filter":[{"bool":{"minimum_should_match":1,"should":[{"term":{"a":1}},{"term":{"b":2}}]}},{"term":{"c":true}}]}}`},
+	{`x IN ("a", "b")`,
+		"x IN (\"a\", \"b\")",
+		`{"x":{"$in":["a","b"]}}`,
+		`{"terms":{"x":["a","b"]}}`},
+	{`x NOT IN ("a", 2)`,
+		"NOT (x IN (\"a\", 2))",
+		`{"x":{"$nin":["a",2]}}`,
+		`{"bool":{"must_not":[{"terms":{"x":["a",2]}}]}}`},
+	{`email LIKE "a_%.com"`,
+		"email LIKE \"a_%.com\"",
+		`{"email":{"$regex":"^a..*\\.com$"}}`,
+		`{"wildcard":{"email":"a?*.com"}}`},
+	{`x BETWEEN 1 AND 5`,
+		"x BETWEEN 1 AND 5",
+		`{"x":{"$gte":1,"$lte":5}}`,
+		`{"range":{"x":{"gte":1,"lte":5}}}`},
+	{`x IS NOT NULL`,
+		"x IS NOT NULL",
+		`{"x":{"$ne":null}}`,
+		`{"exists":{"field":"x"}}`},
+	{`exists(x)`,
+		"",
+		`{"x":{"$exists":true}}`,
+		`{"exists":{"field":"x"}}`},
+	{`toint(x) > 5`, "", "", ""},
+	{`x > y`, "x > y", "", ""},
+	{"`first name` = \"bob\"", "`first name` = \"bob\"", `{"first name":"bob"}`, `{"term":{"first name":"bob"}}`},
+}
+
+func TestTranslate(t *testing.T) {
+	sqlT := translate.NewSqlTranslator('"', '`')
+	mongoT := translate.NewMongoTranslator()
+	esT := translate.NewEsTranslator()
+	for _, tt := range translateTests {
+		tree, err := expr.ParseExpression(tt.exprText)
+		assert.Tf(t, err == nil, "must parse %s: %v", tt.exprText, err)
+
+		out, err := sqlT.Translate(tree.Root)
+		checkTranslation(t, tt.exprText, tt.sql, out, err, false)
+		out, err = mongoT.Translate(tree.Root)
+		checkTranslation(t, tt.exprText, tt.mongo, out, err, true)
+		out, err = esT.Translate(tree.Root)
+		checkTranslation(t, tt.exprText, tt.es, out, err, true)
+	}
+}
+
+func checkTranslation(t *testing.T, exprText, expected string, out interface{}, err error, asJson bool) {
+	if expected == "" {
+		assert.Tf(t, translate.IsUnsupported(err), "expected unsupported for %s got %v", exprText, out)
+		return
+	}
+	assert.Tf(t, err == nil, "expected translation of %s got %v", exprText, err)
+	if asJson {
+		by, err := json.Marshal(out)
+		assert.Tf(t, err == nil, "json %v", err)
+		out = string(by)
+	}
+	assert.Equalf(t, expected, out, "translation of %s", exprText)
+}
+
+func TestTranslateRegistry(t *testing.T) {
+	assert.Equal(t, nil, translate.Get("not-registered"))
+	_, err := translate.Translate("not-registered", expr.NewIdentityNodeVal("x"))
+	assert.T(t, err != nil)
+
+	sqlT := translate.NewSqlTranslator('\'', '"', "tolower")
+	translate.Register("PgTest", sqlT)
+	assert.Equal(t, translate.Translator(sqlT), translate.Get("pgtest"))
+
+	tree, err := expr.ParseExpression(`tolower(name) = "bob"`)
+	assert.T(t, err == nil)
+	out, err := translate.Translate("pgtest", tree.Root)
+	assert.Tf(t, err == nil, "%v", err)
+	assert.Equal(t, `tolower(name) = 'bob'`, out)
+}
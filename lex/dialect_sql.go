@@ -18,11 +18,36 @@ var SqlSelect = []*Clause{
 	{Token: TokenOrderBy, Lexer: LexOrderByColumn, Optional: true, Name: "sqlSelect.orderby"},
 	{Token: TokenLimit, Lexer: LexLimit, Optional: true},
 	{Token: TokenOffset, Lexer: LexNumber, Optional: true},
+	{KeywordMatcher: setOperationMatch, Optional: true, Repeat: true, Clauses: setQuery, Name: "sqlSelect.setop"},
 	{Token: TokenWith, Lexer: LexJsonOrKeyValue, Optional: true},
 	{Token: TokenAlias, Lexer: LexIdentifier, Optional: true},
 	{Token: TokenEOF, Lexer: LexEndOfStatement, Optional: false},
 }
 
+// find the keyword of a set operation between selects
+//    SELECT ... UNION [ALL] SELECT ...
+func setOperationMatch(c *Clause, peekWord string, l *Lexer) bool {
+	switch peekWord {
+	case "union", "intersect", "except":
+		return true
+	}
+	return false
+}
+
+// the select following a set operation, an ORDER BY, LIMIT of the last
+// select applies to the whole set operation
+var setQuery = []*Clause{
+	{KeywordMatcher: setOperationMatch, Lexer: LexSetOperation, Name: "setQuery.op"},
+	{Token: TokenSelect, Lexer: LexSelectClause, Name: "setQuery.Select"},
+	{Token: TokenFrom, Lexer: LexTableReferenceFirst, Optional: true, Name: "setQuery.From"},
+	{Token: TokenWhere, Lexer: LexConditionalClause, Optional: true, Name: "setQuery.Where"},
+	{Token: TokenGroupBy, Lexer: LexColumns, Optional: true, Name: "setQuery.GroupBy"},
+	{Token: TokenHaving, Lexer: LexConditionalClause, Optional: true, Name: "setQuery.Having"},
+	{Token: TokenOrderBy, Lexer: LexOrderByColumn, Optional: true, Name: "setQuery.OrderBy"},
+	{Token: TokenLimit, Lexer: LexLimit, Optional: true, Name: "setQuery.Limit"},
+	{Token: TokenOffset, Lexer: LexNumber, Optional: true, Name: "setQuery.Offset"},
+}
+
 // find any keyword that starts a source
 //    FROM <name>
 //    FROM (select ...)
@@ -136,6 +161,17 @@ var SqlAlter = []*Clause{
 	{Token: TokenWith, Lexer: LexJson, Optional: true},
 }
 
+var SqlCreate = []*Clause{
+	{Token: TokenCreate, Lexer: LexEmpty},
+	{Token: TokenTable, Lexer: LexCreateTable},
+	{Token: TokenWith, Lexer: LexJsonOrKeyValue, Optional: true},
+}
+
+var SqlAnalyze = []*Clause{
+	{Token: TokenAnalyze, Lexer: LexEmpty},
+	{Token: TokenTable, Lexer: LexIdentifier},
+}
+
 var SqlDescribe = []*Clause{
 	{Token: TokenDescribe, Lexer: LexColumns},
 }
@@ -187,9 +223,10 @@ var SqlCommit = []*Clause{
 //
 // ddl
 //    ALTER
+//    CREATE TABLE
+//    ANALYZE TABLE
 //
 //  TODO:
-//      CREATE
 //      VIEW
 var SqlDialect *Dialect = &Dialect{
 	Statements: []*Clause{
@@ -200,6 +237,8 @@ var SqlDialect *Dialect = &Dialect{
 		&Clause{Token: TokenInsert, Clauses: SqlInsert},
 		&Clause{Token: TokenDelete, Clauses: SqlDelete},
 		&Clause{Token: TokenAlter, Clauses: SqlAlter},
+		&Clause{Token: TokenCreate, Clauses: SqlCreate},
+		&Clause{Token: TokenAnalyze, Clauses: SqlAnalyze},
 		&Clause{Token: TokenDescribe, Clauses: SqlDescribe},
 		&Clause{Token: TokenExplain, Clauses: SqlExplain},
 		&Clause{Token: TokenDesc, Clauses: SqlDescribeAlt},
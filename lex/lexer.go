@@ -612,9 +612,15 @@ func (l *Lexer) isNextKeyword(peekWord string) bool {
 		}
 		// TODO:  allow clauses to reserve keywords, or sub-clause
 		switch kwMaybe {
-		case "select", "insert", "delete", "update", "from", "inner", "outer":
+		case "select", "insert", "delete", "update", "from", "inner", "outer",
+			"union", "intersect", "except":
 			//u.Warnf("doing true: %v", kwMaybe)
 			return true
+		case "left", "right":
+			// LEFT/RIGHT are also functions, only keywords as part of a join
+			if l.isJoinWord(kwMaybe) {
+				return true
+			}
 		}
 		if !clause.Optional {
 			return false
@@ -625,6 +631,26 @@ func (l *Lexer) isNextKeyword(peekWord string) bool {
 	return false
 }
 
+// non-consuming check that word is followed by JOIN or OUTER, ie
+//  users AS u LEFT JOIN ...
+func (l *Lexer) isJoinWord(word string) bool {
+	rest := strings.TrimLeftFunc(l.input[l.pos:], unicode.IsSpace)
+	if len(rest) < len(word) {
+		return false
+	}
+	rest = strings.ToLower(strings.TrimLeftFunc(rest[len(word):], unicode.IsSpace))
+	return strings.HasPrefix(rest, "join") || strings.HasPrefix(rest, "outer")
+}
+
+// isOverClause is the next word OVER followed by the ( of a window spec
+func (l *Lexer) isOverClause() bool {
+	rest := strings.TrimLeftFunc(l.input[l.pos:], unicode.IsSpace)
+	if len(rest) < 4 {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimLeftFunc(rest[4:], unicode.IsSpace), "(")
+}
+
 // non-consuming isIdentity
 //  Identities are non-numeric string values that are not quoted
 func (l *Lexer) isIdentity() bool {
@@ -1297,6 +1323,12 @@ func LexListOfArgs(l *Lexer) StateFn {
 			l.Emit(TokenAs)
 			return LexListOfArgs
 		}
+		if peekWord == "distinct" && l.lastToken.T == TokenLeftParenthesis {
+			//   count(DISTINCT user_id)
+			l.ConsumeWord(peekWord)
+			l.Emit(TokenDistinct)
+			return lexDistinctArg
+		}
 		if l.isNextKeyword(peekWord) {
 			//u.Warnf("found keyword while looking for arg? %v", string(r))
 			return nil
@@ -1311,6 +1343,21 @@ func LexListOfArgs(l *Lexer) StateFn {
 	return nil
 }
 
+// lexDistinctArg the argument after DISTINCT of an aggregate, of parens or not
+//
+//   count(DISTINCT user_id)
+//   count(DISTINCT(user_id))
+func lexDistinctArg(l *Lexer) StateFn {
+	l.SkipWhiteSpaces()
+	if l.Peek() == '(' {
+		l.Next()
+		l.Emit(TokenLeftParenthesis)
+		// its right paren pops back to the args of the aggregate
+		l.Push("LexListOfArgs", LexListOfArgs)
+	}
+	return LexListOfArgs
+}
+
 // LexIdentifier scans and finds named things (tables, columns)
 //  and specifies them as TokenIdentity, uses LexIdentifierType
 //
@@ -1423,6 +1470,10 @@ func LexIdentifierOfType(forToken TokenType) StateFn {
 			allDigits := isDigit(firstChar)
 			for rune := l.Next(); IsIdentifierRune(rune); rune = l.Next() {
 				// iterate until we find non-identifer character
+				if rune == '-' && l.Peek() == '>' {
+					// json path   payload->"$.name"
+					break
+				}
 				if allDigits && !isDigit(rune) {
 					allDigits = false
 				}
@@ -1502,6 +1553,47 @@ func LexEndOfStatement(l *Lexer) StateFn {
 	return l.errorToken("Unexpected token:" + l.current())
 }
 
+// LexSetOperation the set operation between two selects and its optional
+// duplicate handling
+//
+//    <set_op> := ( UNION | INTERSECT | EXCEPT ) [ALL | DISTINCT]
+//
+func LexSetOperation(l *Lexer) StateFn {
+
+	l.SkipWhiteSpaces()
+
+	word := strings.ToLower(l.PeekWord())
+	switch word {
+	case "union":
+		l.ConsumeWord(word)
+		l.Emit(TokenUnion)
+	case "intersect":
+		l.ConsumeWord(word)
+		l.Emit(TokenIntersect)
+	case "except":
+		l.ConsumeWord(word)
+		l.Emit(TokenExcept)
+	default:
+		return l.errorToken("expected UNION, INTERSECT or EXCEPT but got: " + word)
+	}
+	return lexSetQuantifier
+}
+
+// the optional ALL | DISTINCT of a set operation
+func lexSetQuantifier(l *Lexer) StateFn {
+	l.SkipWhiteSpaces()
+	word := strings.ToLower(l.PeekWord())
+	switch word {
+	case "all":
+		l.ConsumeWord(word)
+		l.Emit(TokenAll)
+	case "distinct":
+		l.ConsumeWord(word)
+		l.Emit(TokenDistinct)
+	}
+	return nil
+}
+
 // Handle start of select statements, specifically looking for
 //    @@variables, *, or else we drop into <select_list>
 //
@@ -1639,6 +1731,11 @@ func LexSubQuery(l *Lexer) StateFn {
 		l.Push("LexConditionalClause", LexConditionalClause)
 		return LexTableReferences
 	default:
+		if l.isNextKeyword(word) {
+			// end of sub-query  ie   (SELECT ...) LIMIT 5, the tokens for
+			// the remaining clauses are the same so let statement lex them
+			return nil
+		}
 	}
 
 	l.Push("LexSubQuery", LexSubQuery)
@@ -1705,6 +1802,11 @@ func LexSelectList(l *Lexer) StateFn {
 		l.Push("LexSelectList", LexSelectList)
 		return LexIdentifier
 	case "if":
+		switch l.lastToken.T {
+		case TokenSelect, TokenDistinct, TokenComma:
+			// starts a column, so the if() function not a guard
+			return LexExpression
+		}
 		l.skipX(2)
 		l.Emit(TokenIf)
 		l.Push("LexSelectList", LexSelectList)
@@ -1713,6 +1815,109 @@ func LexSelectList(l *Lexer) StateFn {
 	return LexExpression
 }
 
+// LexOver the window spec of a window function column, OVER has already
+// been consumed
+//
+//     <select_col> :== <func> OVER '(' <window> ')' [AS <identifier>]
+//
+//     <window>      := [PARTITION BY <identity> [, <identity>]*]
+//                      [ORDER BY <identity> [ASC | DESC] [, ...]*]
+//                      [(ROWS | RANGE) <frame>]
+//     <frame>       := <bound> | BETWEEN <bound> AND <bound>
+//     <bound>       := UNBOUNDED (PRECEDING | FOLLOWING) | CURRENT ROW
+//                      | <integer> (PRECEDING | FOLLOWING)
+//
+// Examples:
+//
+//  row_number() OVER (PARTITION BY user_id ORDER BY created DESC)
+//  sum(amount) OVER (ORDER BY created ROWS BETWEEN 2 PRECEDING AND CURRENT ROW)
+//
+func LexOver(l *Lexer) StateFn {
+	l.SkipWhiteSpaces()
+	if l.IsEnd() {
+		return nil
+	}
+	switch r := l.Peek(); {
+	case r == '(':
+		l.Next()
+		l.Emit(TokenLeftParenthesis)
+		return LexOver
+	case r == ')':
+		l.Next()
+		l.Emit(TokenRightParenthesis)
+		return nil
+	case r == ',':
+		l.Next()
+		l.Emit(TokenComma)
+		return LexOver
+	case unicode.IsDigit(r):
+		l.Push("LexOver", LexOver)
+		return LexNumber
+	}
+
+	word := strings.ToLower(l.PeekWord())
+	switch word {
+	case "partition", "order", "current":
+		l.ConsumeWord(word)
+		for unicode.IsSpace(l.Peek()) {
+			l.Next()
+		}
+		next := strings.ToLower(l.PeekWord())
+		switch {
+		case word == "partition" && next == "by":
+			l.ConsumeWord(next)
+			l.Emit(TokenPartitionBy)
+		case word == "order" && next == "by":
+			l.ConsumeWord(next)
+			l.Emit(TokenOrderBy)
+		case word == "current" && next == "row":
+			l.ConsumeWord(next)
+			l.Emit(TokenCurrentRow)
+		default:
+			return l.errorf("unexpected %q after %s", next, word)
+		}
+		return LexOver
+	case "asc":
+		l.ConsumeWord(word)
+		l.Emit(TokenAsc)
+		return LexOver
+	case "desc":
+		l.ConsumeWord(word)
+		l.Emit(TokenDesc)
+		return LexOver
+	case "rows":
+		l.ConsumeWord(word)
+		l.Emit(TokenRows)
+		return LexOver
+	case "range":
+		l.ConsumeWord(word)
+		l.Emit(TokenRange)
+		return LexOver
+	case "between":
+		l.ConsumeWord(word)
+		l.Emit(TokenBetween)
+		return LexOver
+	case "and":
+		l.ConsumeWord(word)
+		l.Emit(TokenLogicAnd)
+		return LexOver
+	case "unbounded":
+		l.ConsumeWord(word)
+		l.Emit(TokenUnbounded)
+		return LexOver
+	case "preceding":
+		l.ConsumeWord(word)
+		l.Emit(TokenPreceding)
+		return LexOver
+	case "following":
+		l.ConsumeWord(word)
+		l.Emit(TokenFollowing)
+		return LexOver
+	}
+	l.Push("LexOver", LexOver)
+	return LexIdentifier
+}
+
 // Handle Source References ie [From table], [SubSelects], Joins
 //
 //    SELECT ...  FROM <sources>
@@ -2216,12 +2421,21 @@ func LexExpression(l *Lexer) StateFn {
 		foundLogical := false
 		foundOperator := false
 		switch r {
-		case '-': // comment?  or minus?
+		case '-': // comment?  or minus?  or json path ->
 			p := l.Peek()
 			if p == '-' {
 				l.backup()
 				l.Push("LexExpression", LexExpression)
 				return LexInlineComment
+			} else if p == '>' {
+				l.Next()
+				if l.Peek() == '>' {
+					l.Next()
+					l.Emit(TokenDoubleArrow)
+				} else {
+					l.Emit(TokenArrow)
+				}
+				foundOperator = true
 			} else {
 				l.Emit(TokenMinus)
 				return l.clauseState()
@@ -2386,6 +2600,14 @@ func LexExpression(l *Lexer) StateFn {
 		}
 		l.Emit(TokenExists)
 		return LexExpression
+	case "over":
+		// window of a function column, only when followed by its spec
+		if l.isOverClause() {
+			l.ConsumeWord(word)
+			l.Emit(TokenOver)
+			l.Push("LexExpression", l.clauseState())
+			return LexOver
+		}
 	case "is":
 		l.ConsumeWord(word)
 		l.Emit(TokenIs)
@@ -2777,6 +2999,185 @@ func LexDdlColumn(l *Lexer) StateFn {
 	return LexExpressionOrIdentity
 }
 
+// LexCreateTable the name and column definitions of a CREATE TABLE
+//
+//   CREATE TABLE [IF NOT EXISTS] <identity> '(' <create_def> [, <create_def>]* ')'
+//
+//   <create_def> := <identity> <data_type> ['(' <integer> [, <integer>] ')']
+//                       [NOT NULL | NULL] [DEFAULT <value>] [PRIMARY KEY]
+//                 | PRIMARY KEY '(' <identity> [, <identity>]* ')'
+//
+func LexCreateTable(l *Lexer) StateFn {
+
+	l.SkipWhiteSpaces()
+	if strings.ToLower(l.PeekWord()) == "if" {
+		l.ConsumeWord("if")
+		l.Emit(TokenIf)
+		return lexCreateIfNotExists
+	}
+	l.Push("lexCreateDefs", lexCreateDefs)
+	return LexIdentifier
+}
+
+// the NOT EXISTS of IF NOT EXISTS
+func lexCreateIfNotExists(l *Lexer) StateFn {
+	l.SkipWhiteSpaces()
+	switch strings.ToLower(l.PeekWord()) {
+	case "not":
+		l.ConsumeWord("not")
+		l.Emit(TokenNegate)
+		return lexCreateIfNotExists
+	case "exists":
+		l.ConsumeWord("exists")
+		l.Emit(TokenExists)
+		l.Push("lexCreateDefs", lexCreateDefs)
+		return LexIdentifier
+	}
+	return l.errorToken("expected IF NOT EXISTS but got " + l.PeekX(10))
+}
+
+// the '(' of the create definitions
+func lexCreateDefs(l *Lexer) StateFn {
+	l.SkipWhiteSpaces()
+	if l.Peek() != '(' {
+		return l.errorToken("expected ( of column definitions but got " + l.PeekX(10))
+	}
+	l.Next()
+	l.Emit(TokenLeftParenthesis)
+	return lexCreateDef
+}
+
+// a column definition, or PRIMARY KEY or FOREIGN KEY of columns
+func lexCreateDef(l *Lexer) StateFn {
+	l.SkipWhiteSpaces()
+	switch strings.ToLower(l.PeekX(len("primary key"))) {
+	case "primary key":
+		l.ConsumeWord("primary key")
+		l.Emit(TokenPrimaryKey)
+		l.Push("lexCreateDefEnd", lexCreateDefEnd)
+		return LexColumnNames
+	case "foreign key":
+		l.ConsumeWord("foreign key")
+		l.Emit(TokenForeignKey)
+		l.Push("lexCreateReferences", lexCreateReferences)
+		return LexColumnNames
+	}
+	l.Push("lexColumnDataType", lexColumnDataType)
+	return LexIdentifier
+}
+
+// the REFERENCES table (columns) of a FOREIGN KEY
+func lexCreateReferences(l *Lexer) StateFn {
+	l.SkipWhiteSpaces()
+	if strings.ToLower(l.PeekWord()) != "references" {
+		return l.errorToken("expected REFERENCES of foreign key but got " + l.PeekX(10))
+	}
+	l.ConsumeWord("references")
+	l.Emit(TokenReferences)
+	l.Push("lexCreateDefEnd", lexCreateDefEnd)
+	l.Push("LexColumnNames", LexColumnNames)
+	return LexIdentifier
+}
+
+// the end of a definition, the next or the ')' of all
+func lexCreateDefEnd(l *Lexer) StateFn {
+	l.SkipWhiteSpaces()
+	switch l.Next() {
+	case ',':
+		l.Emit(TokenComma)
+		return lexCreateDef
+	case ')':
+		l.Emit(TokenRightParenthesis)
+		return nil
+	}
+	l.backup()
+	return l.errorToken("expected , or ) of column definitions but got " + l.PeekX(10))
+}
+
+// the data type of a column definition, up to its size if any
+func lexColumnDataType(l *Lexer) StateFn {
+	l.SkipWhiteSpaces()
+	for {
+		r := l.Next()
+		if r == eof || !isIdentCh(r) {
+			l.backup()
+			break
+		}
+	}
+	if l.pos == l.start {
+		return l.errorToken("expected data type of column but got " + l.PeekX(10))
+	}
+	l.Emit(TokenDataType)
+	return lexColumnOptions
+}
+
+// the size of the data type, and the options of a column definition
+func lexColumnOptions(l *Lexer) StateFn {
+
+	l.SkipWhiteSpaces()
+	switch l.Peek() {
+	case '(':
+		// varchar(255), decimal(10,2)
+		l.Next()
+		l.Emit(TokenLeftParenthesis)
+		l.Push("lexColumnOptions", lexColumnOptions)
+		return lexColumnSize
+	case ',', ')':
+		return lexCreateDefEnd
+	}
+
+	word := strings.ToLower(l.PeekWord())
+	switch word {
+	case "not":
+		l.ConsumeWord(word)
+		l.Emit(TokenNegate)
+		return lexColumnOptions
+	case "null":
+		l.ConsumeWord(word)
+		l.Emit(TokenNull)
+		return lexColumnOptions
+	case "default":
+		l.ConsumeWord(word)
+		l.Emit(TokenDefault)
+		l.SkipWhiteSpaces()
+		if strings.ToLower(l.PeekWord()) == "null" {
+			return lexColumnOptions
+		}
+		l.Push("lexColumnOptions", lexColumnOptions)
+		return LexValue
+	case "primary":
+		if strings.ToLower(l.PeekX(len("primary key"))) == "primary key" {
+			l.ConsumeWord("primary key")
+			l.Emit(TokenPrimaryKey)
+			return lexColumnOptions
+		}
+	case "references":
+		l.ConsumeWord(word)
+		l.Emit(TokenReferences)
+		l.Push("lexColumnOptions", lexColumnOptions)
+		l.Push("LexColumnNames", LexColumnNames)
+		return LexIdentifier
+	}
+	return l.errorToken("unexpected in column definition: " + l.PeekX(10))
+}
+
+// the integer args of a data type up to its ')'
+func lexColumnSize(l *Lexer) StateFn {
+	l.SkipWhiteSpaces()
+	switch l.Peek() {
+	case ',':
+		l.Next()
+		l.Emit(TokenComma)
+		return lexColumnSize
+	case ')':
+		l.Next()
+		l.Emit(TokenRightParenthesis)
+		return nil
+	}
+	l.Push("lexColumnSize", lexColumnSize)
+	return LexNumber
+}
+
 // Lex either Json or Key/Value pairs
 //
 //    Must start with { or [ for json
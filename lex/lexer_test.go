@@ -665,6 +665,47 @@ func TestLexGroupBy(t *testing.T) {
 		})
 }
 
+func TestLexCreateForeignKey(t *testing.T) {
+	verifyTokens(t, `CREATE TABLE orders (id int, user_id int REFERENCES users (id), FOREIGN KEY (id) REFERENCES items (order_id))`,
+		[]Token{
+			tv(TokenCreate, "CREATE"),
+			tv(TokenTable, "TABLE"),
+			tv(TokenIdentity, "orders"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenIdentity, "id"),
+			tv(TokenDataType, "int"),
+			tv(TokenComma, ","),
+			tv(TokenIdentity, "user_id"),
+			tv(TokenDataType, "int"),
+			tv(TokenReferences, "REFERENCES"),
+			tv(TokenIdentity, "users"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenIdentity, "id"),
+			tv(TokenRightParenthesis, ")"),
+			tv(TokenComma, ","),
+			tv(TokenForeignKey, "FOREIGN KEY"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenIdentity, "id"),
+			tv(TokenRightParenthesis, ")"),
+			tv(TokenReferences, "REFERENCES"),
+			tv(TokenIdentity, "items"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenIdentity, "order_id"),
+			tv(TokenRightParenthesis, ")"),
+			tv(TokenRightParenthesis, ")"),
+		})
+}
+
+func TestLexAnalyze(t *testing.T) {
+	verifyTokens(t, `ANALYZE TABLE users;`,
+		[]Token{
+			tv(TokenAnalyze, "ANALYZE"),
+			tv(TokenTable, "TABLE"),
+			tv(TokenIdentity, "users"),
+			tv(TokenEOS, ";"),
+		})
+}
+
 func TestLexFrom(t *testing.T) {
 	verifyTokens(t, `SELECT x FROM github.user`,
 		[]Token{
@@ -790,6 +831,18 @@ func TestLexSelectExpressions(t *testing.T) {
 			tv(TokenIdentity, "Product"),
 		})
 
+	verifyTokens(t, `SELECT count(DISTINCT user_id) FROM Product`,
+		[]Token{
+			tv(TokenSelect, "SELECT"),
+			tv(TokenUdfExpr, "count"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenDistinct, "DISTINCT"),
+			tv(TokenIdentity, "user_id"),
+			tv(TokenRightParenthesis, ")"),
+			tv(TokenFrom, "FROM"),
+			tv(TokenIdentity, "Product"),
+		})
+
 	verifyTokens(t, `SELECT * FROM Product`,
 		[]Token{
 			tv(TokenSelect, "SELECT"),
@@ -835,6 +888,48 @@ func TestLexSelectExpressions(t *testing.T) {
 		})
 }
 
+func TestLexSelectWindow(t *testing.T) {
+
+	verifyTokens(t, `SELECT row_number() OVER (PARTITION BY g ORDER BY n DESC) AS rn,
+			sum(n) OVER (ORDER BY n ROWS BETWEEN 2 PRECEDING AND CURRENT ROW), over FROM nums`,
+		[]Token{
+			tv(TokenSelect, "SELECT"),
+			tv(TokenUdfExpr, "row_number"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenRightParenthesis, ")"),
+			tv(TokenOver, "OVER"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenPartitionBy, "PARTITION BY"),
+			tv(TokenIdentity, "g"),
+			tv(TokenOrderBy, "ORDER BY"),
+			tv(TokenIdentity, "n"),
+			tv(TokenDesc, "DESC"),
+			tv(TokenRightParenthesis, ")"),
+			tv(TokenAs, "AS"),
+			tv(TokenIdentity, "rn"),
+			tv(TokenComma, ","),
+			tv(TokenUdfExpr, "sum"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenIdentity, "n"),
+			tv(TokenRightParenthesis, ")"),
+			tv(TokenOver, "OVER"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenOrderBy, "ORDER BY"),
+			tv(TokenIdentity, "n"),
+			tv(TokenRows, "ROWS"),
+			tv(TokenBetween, "BETWEEN"),
+			tv(TokenInteger, "2"),
+			tv(TokenPreceding, "PRECEDING"),
+			tv(TokenLogicAnd, "AND"),
+			tv(TokenCurrentRow, "CURRENT ROW"),
+			tv(TokenRightParenthesis, ")"),
+			tv(TokenComma, ","),
+			tv(TokenIdentity, "over"),
+			tv(TokenFrom, "FROM"),
+			tv(TokenIdentity, "nums"),
+		})
+}
+
 func TestLexSelectIfGuard(t *testing.T) {
 
 	verifyTokens(t, `SELECT 
@@ -869,6 +964,30 @@ func TestLexSelectIfGuard(t *testing.T) {
 			tv(TokenFrom, "FROM"),
 			tv(TokenIdentity, "Product"),
 		})
+
+	// if() starting a column is the function, not a guard
+	verifyTokens(t, `SELECT name, if(ok, 1, 0) AS flag IF hey == 0 FROM nothing`,
+		[]Token{
+			tv(TokenSelect, "SELECT"),
+			tv(TokenIdentity, "name"),
+			tv(TokenComma, ","),
+			tv(TokenUdfExpr, "if"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenIdentity, "ok"),
+			tv(TokenComma, ","),
+			tv(TokenInteger, "1"),
+			tv(TokenComma, ","),
+			tv(TokenInteger, "0"),
+			tv(TokenRightParenthesis, ")"),
+			tv(TokenAs, "AS"),
+			tv(TokenIdentity, "flag"),
+			tv(TokenIf, "IF"),
+			tv(TokenIdentity, "hey"),
+			tv(TokenEqualEqual, "=="),
+			tv(TokenInteger, "0"),
+			tv(TokenFrom, "FROM"),
+			tv(TokenIdentity, "nothing"),
+		})
 }
 
 func TestLexSelectLogicalColumns(t *testing.T) {
@@ -1014,6 +1133,75 @@ func TestLexAlter(t *testing.T) {
 		})
 }
 
+func TestLexCreate(t *testing.T) {
+
+	verifyTokens(t, `CREATE TABLE IF NOT EXISTS users (
+			user_id bigint NOT NULL PRIMARY KEY,
+			name varchar(255) DEFAULT 'anon',
+			score decimal(10, 2) NULL
+		) WITH {"source":"mem"};`,
+		[]Token{
+			tv(TokenCreate, "CREATE"),
+			tv(TokenTable, "TABLE"),
+			tv(TokenIf, "IF"),
+			tv(TokenNegate, "NOT"),
+			tv(TokenExists, "EXISTS"),
+			tv(TokenIdentity, "users"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenIdentity, "user_id"),
+			tv(TokenDataType, "bigint"),
+			tv(TokenNegate, "NOT"),
+			tv(TokenNull, "NULL"),
+			tv(TokenPrimaryKey, "PRIMARY KEY"),
+			tv(TokenComma, ","),
+			tv(TokenIdentity, "name"),
+			tv(TokenDataType, "varchar"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenInteger, "255"),
+			tv(TokenRightParenthesis, ")"),
+			tv(TokenDefault, "DEFAULT"),
+			tv(TokenValue, "anon"),
+			tv(TokenComma, ","),
+			tv(TokenIdentity, "score"),
+			tv(TokenDataType, "decimal"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenInteger, "10"),
+			tv(TokenComma, ","),
+			tv(TokenInteger, "2"),
+			tv(TokenRightParenthesis, ")"),
+			tv(TokenNull, "NULL"),
+			tv(TokenRightParenthesis, ")"),
+			tv(TokenWith, "WITH"),
+			tv(TokenLeftBrace, "{"),
+			tv(TokenIdentity, "source"),
+			tv(TokenColon, ":"),
+			tv(TokenValue, "mem"),
+			tv(TokenRightBrace, "}"),
+			tv(TokenEOS, ";"),
+		})
+
+	verifyTokens(t, `CREATE TABLE orders (id int, user_id int, PRIMARY KEY (id, user_id))`,
+		[]Token{
+			tv(TokenCreate, "CREATE"),
+			tv(TokenTable, "TABLE"),
+			tv(TokenIdentity, "orders"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenIdentity, "id"),
+			tv(TokenDataType, "int"),
+			tv(TokenComma, ","),
+			tv(TokenIdentity, "user_id"),
+			tv(TokenDataType, "int"),
+			tv(TokenComma, ","),
+			tv(TokenPrimaryKey, "PRIMARY KEY"),
+			tv(TokenLeftParenthesis, "("),
+			tv(TokenIdentity, "id"),
+			tv(TokenComma, ","),
+			tv(TokenIdentity, "user_id"),
+			tv(TokenRightParenthesis, ")"),
+			tv(TokenRightParenthesis, ")"),
+		})
+}
+
 func TestLexUpdate(t *testing.T) {
 	/*
 			UPDATE [LOW_PRIORITY] [IGNORE] table_reference
@@ -38,26 +38,24 @@ func (t Token) String() string {
 }
 
 /*
-
-	// List of datatypes from MySql, implement them as tokens?   or leave as Identity during
-	// DDL create/alter statements?
-	BOOL	TINYINT
-	BOOLEAN	TINYINT
-	CHARACTER VARYING(M)	VARCHAR(M)
-	FIXED	DECIMAL
-	FLOAT4	FLOAT
-	FLOAT8	DOUBLE
-	INT1	TINYINT
-	INT2	SMALLINT
-	INT3	MEDIUMINT
-	INT4	INT
-	INT8	BIGINT
-	LONG VARBINARY	MEDIUMBLOB
-	LONG VARCHAR	MEDIUMTEXT
-	LONG	MEDIUMTEXT
-	MIDDLEINT	MEDIUMINT
-	NUMERIC	DECIMAL
-
+// List of datatypes from MySql, implement them as tokens?   or leave as Identity during
+// DDL create/alter statements?
+BOOL	TINYINT
+BOOLEAN	TINYINT
+CHARACTER VARYING(M)	VARCHAR(M)
+FIXED	DECIMAL
+FLOAT4	FLOAT
+FLOAT8	DOUBLE
+INT1	TINYINT
+INT2	SMALLINT
+INT3	MEDIUMINT
+INT4	INT
+INT8	BIGINT
+LONG VARBINARY	MEDIUMBLOB
+LONG VARCHAR	MEDIUMTEXT
+LONG	MEDIUMTEXT
+MIDDLEINT	MEDIUMINT
+NUMERIC	DECIMAL
 */
 const (
 	// List of all TokenTypes Note we do NOT use IOTA because it is evil
@@ -123,6 +121,8 @@ const (
 	TokenNull             TokenType = 88 // NULL
 	TokenContains         TokenType = 89 // CONTAINS
 	TokenIntersects       TokenType = 90 // INTERSECTS
+	TokenArrow            TokenType = 91 // ->  json path
+	TokenDoubleArrow      TokenType = 92 // ->> json path, unquoted
 
 	// ql top-level keywords, these first keywords determine parser
 	TokenPrepare   TokenType = 200
@@ -141,36 +141,50 @@ const (
 	TokenReplace   TokenType = 213 // Insert/Replace are interchangeable on insert statements
 	TokenRollback  TokenType = 214
 	TokenCommit    TokenType = 215
+	TokenAnalyze   TokenType = 216 // analyze table
 
 	// Other QL Keywords, These are clause-level keywords that mark seperation between clauses
-	TokenTable    TokenType = 301 // table
-	TokenFrom     TokenType = 302 // from
-	TokenWhere    TokenType = 303 // where
-	TokenHaving   TokenType = 304 // having
-	TokenGroupBy  TokenType = 305 // group by
-	TokenBy       TokenType = 306 // by
-	TokenAlias    TokenType = 307 // alias
-	TokenWith     TokenType = 308 // with
-	TokenValues   TokenType = 309 // values
-	TokenInto     TokenType = 310 // into
-	TokenLimit    TokenType = 311 // limit
-	TokenOrderBy  TokenType = 312 // order by
-	TokenInner    TokenType = 313 // inner , ie of join
-	TokenCross    TokenType = 314 // cross
-	TokenOuter    TokenType = 315 // outer
-	TokenLeft     TokenType = 316 // left
-	TokenRight    TokenType = 317 // right
-	TokenJoin     TokenType = 318 // Join
-	TokenOn       TokenType = 319 // on
-	TokenDistinct TokenType = 320 // DISTINCT
-	TokenAll      TokenType = 321 // all
-	TokenInclude  TokenType = 322 // INCLUDE
-	TokenExists   TokenType = 323 // EXISTS
-	TokenOffset   TokenType = 324 // OFFSET
-	TokenFull     TokenType = 325 // FULL
-	TokenGlobal   TokenType = 326 // GLOBAL
-	TokenSession  TokenType = 327 // SESSION
-	TokenTables   TokenType = 328 // TABLES
+	TokenTable     TokenType = 301 // table
+	TokenFrom      TokenType = 302 // from
+	TokenWhere     TokenType = 303 // where
+	TokenHaving    TokenType = 304 // having
+	TokenGroupBy   TokenType = 305 // group by
+	TokenBy        TokenType = 306 // by
+	TokenAlias     TokenType = 307 // alias
+	TokenWith      TokenType = 308 // with
+	TokenValues    TokenType = 309 // values
+	TokenInto      TokenType = 310 // into
+	TokenLimit     TokenType = 311 // limit
+	TokenOrderBy   TokenType = 312 // order by
+	TokenInner     TokenType = 313 // inner , ie of join
+	TokenCross     TokenType = 314 // cross
+	TokenOuter     TokenType = 315 // outer
+	TokenLeft      TokenType = 316 // left
+	TokenRight     TokenType = 317 // right
+	TokenJoin      TokenType = 318 // Join
+	TokenOn        TokenType = 319 // on
+	TokenDistinct  TokenType = 320 // DISTINCT
+	TokenAll       TokenType = 321 // all
+	TokenInclude   TokenType = 322 // INCLUDE
+	TokenExists    TokenType = 323 // EXISTS
+	TokenOffset    TokenType = 324 // OFFSET
+	TokenFull      TokenType = 325 // FULL
+	TokenGlobal    TokenType = 326 // GLOBAL
+	TokenSession   TokenType = 327 // SESSION
+	TokenTables    TokenType = 328 // TABLES
+	TokenUnion     TokenType = 329 // UNION
+	TokenIntersect TokenType = 330 // INTERSECT
+	TokenExcept    TokenType = 331 // EXCEPT
+
+	// window functions
+	TokenOver        TokenType = 340 // OVER
+	TokenPartitionBy TokenType = 341 // PARTITION BY
+	TokenRows        TokenType = 342 // ROWS
+	TokenRange       TokenType = 343 // RANGE
+	TokenUnbounded   TokenType = 344 // UNBOUNDED
+	TokenPreceding   TokenType = 345 // PRECEDING
+	TokenFollowing   TokenType = 346 // FOLLOWING
+	TokenCurrentRow  TokenType = 347 // CURRENT ROW
 
 	// ddl
 	TokenChange       TokenType = 400 // change
@@ -178,6 +192,10 @@ const (
 	TokenFirst        TokenType = 402 // first
 	TokenAfter        TokenType = 403 // after
 	TokenCharacterSet TokenType = 404 // character set
+	TokenPrimaryKey   TokenType = 405 // primary key
+	TokenDefault      TokenType = 406 // default
+	TokenForeignKey   TokenType = 407 // foreign key
+	TokenReferences   TokenType = 408 // references
 
 	// Other QL keywords
 	TokenSet  TokenType = 500 // set
@@ -249,33 +267,35 @@ var (
 		TokenRightBrace:   {Kw: "}", Description: "}"},
 
 		// Logic, Expressions, Operators etc
-		TokenMultiply:   {Kw: "*", Description: "Multiply"},
-		TokenMinus:      {Kw: "-", Description: "-"},
-		TokenPlus:       {Kw: "+", Description: "+"},
-		TokenPlusPlus:   {Kw: "++", Description: "++"},
-		TokenPlusEquals: {Kw: "+=", Description: "+="},
-		TokenDivide:     {Kw: "/", Description: "Divide /"},
-		TokenModulus:    {Kw: "%", Description: "Modulus %"},
-		TokenEqual:      {Kw: "=", Description: "Equal"},
-		TokenEqualEqual: {Kw: "==", Description: "=="},
-		TokenNE:         {Kw: "!=", Description: "NE"},
-		TokenGE:         {Kw: ">=", Description: "GE"},
-		TokenLE:         {Kw: "<=", Description: "LE"},
-		TokenGT:         {Kw: ">", Description: "GT"},
-		TokenLT:         {Kw: "<", Description: "LT"},
-		TokenIf:         {Kw: "if", Description: "IF"},
-		TokenAnd:        {Kw: "&&", Description: "&&"},
-		TokenOr:         {Kw: "||", Description: "||"},
-		TokenLogicOr:    {Kw: "or", Description: "Or"},
-		TokenLogicAnd:   {Kw: "and", Description: "And"},
-		TokenIN:         {Kw: "in", Description: "IN"},
-		TokenLike:       {Kw: "like", Description: "LIKE"},
-		TokenNegate:     {Kw: "not", Description: "NOT"},
-		TokenBetween:    {Kw: "between", Description: "between"},
-		TokenIs:         {Kw: "is", Description: "IS"},
-		TokenNull:       {Kw: "null", Description: "NULL"},
-		TokenContains:   {Kw: "contains", Description: "contains"},
-		TokenIntersects: {Kw: "intersects", Description: "intersects"},
+		TokenMultiply:    {Kw: "*", Description: "Multiply"},
+		TokenMinus:       {Kw: "-", Description: "-"},
+		TokenPlus:        {Kw: "+", Description: "+"},
+		TokenPlusPlus:    {Kw: "++", Description: "++"},
+		TokenPlusEquals:  {Kw: "+=", Description: "+="},
+		TokenDivide:      {Kw: "/", Description: "Divide /"},
+		TokenModulus:     {Kw: "%", Description: "Modulus %"},
+		TokenEqual:       {Kw: "=", Description: "Equal"},
+		TokenEqualEqual:  {Kw: "==", Description: "=="},
+		TokenNE:          {Kw: "!=", Description: "NE"},
+		TokenGE:          {Kw: ">=", Description: "GE"},
+		TokenLE:          {Kw: "<=", Description: "LE"},
+		TokenGT:          {Kw: ">", Description: "GT"},
+		TokenLT:          {Kw: "<", Description: "LT"},
+		TokenIf:          {Kw: "if", Description: "IF"},
+		TokenAnd:         {Kw: "&&", Description: "&&"},
+		TokenOr:          {Kw: "||", Description: "||"},
+		TokenLogicOr:     {Kw: "or", Description: "Or"},
+		TokenLogicAnd:    {Kw: "and", Description: "And"},
+		TokenIN:          {Kw: "in", Description: "IN"},
+		TokenLike:        {Kw: "like", Description: "LIKE"},
+		TokenNegate:      {Kw: "not", Description: "NOT"},
+		TokenBetween:     {Kw: "between", Description: "between"},
+		TokenIs:          {Kw: "is", Description: "IS"},
+		TokenNull:        {Kw: "null", Description: "NULL"},
+		TokenContains:    {Kw: "contains", Description: "contains"},
+		TokenIntersects:  {Kw: "intersects", Description: "intersects"},
+		TokenArrow:       {Kw: "->", Description: "->"},
+		TokenDoubleArrow: {Kw: "->>", Description: "->>"},
 
 		// Identity ish bools
 		TokenTrue:  {Kw: "true", Description: "True"},
@@ -305,6 +325,7 @@ var (
 		TokenReplace:   {Description: "replace"},
 		TokenRollback:  {Description: "rollback"},
 		TokenCommit:    {Description: "commit"},
+		TokenAnalyze:   {Description: "analyze"},
 
 		// Top Level ql clause keywords
 		TokenTable:   {Description: "table"},
@@ -315,27 +336,40 @@ var (
 		TokenHaving:  {Description: "having"},
 		TokenGroupBy: {Description: "group by"},
 		// Other Ql Keywords
-		TokenAlias:    {Description: "alias"},
-		TokenWith:     {Description: "with"},
-		TokenValues:   {Description: "values"},
-		TokenLimit:    {Description: "limit"},
-		TokenOrderBy:  {Description: "order by"},
-		TokenInner:    {Description: "inner"},
-		TokenCross:    {Description: "cross"},
-		TokenOuter:    {Description: "outer"},
-		TokenLeft:     {Description: "left"},
-		TokenRight:    {Description: "right"},
-		TokenJoin:     {Description: "join"},
-		TokenOn:       {Description: "on"},
-		TokenDistinct: {Description: "distinct"},
-		TokenAll:      {Description: "all"},
-		TokenInclude:  {Description: "include"},
-		TokenExists:   {Description: "exists"},
-		TokenOffset:   {Description: "offset"},
-		TokenFull:     {Description: "full"},
-		TokenGlobal:   {Description: "global"},
-		TokenSession:  {Description: "session"},
-		TokenTables:   {Description: "tables"},
+		TokenAlias:     {Description: "alias"},
+		TokenWith:      {Description: "with"},
+		TokenValues:    {Description: "values"},
+		TokenLimit:     {Description: "limit"},
+		TokenOrderBy:   {Description: "order by"},
+		TokenInner:     {Description: "inner"},
+		TokenCross:     {Description: "cross"},
+		TokenOuter:     {Description: "outer"},
+		TokenLeft:      {Description: "left"},
+		TokenRight:     {Description: "right"},
+		TokenJoin:      {Description: "join"},
+		TokenOn:        {Description: "on"},
+		TokenDistinct:  {Description: "distinct"},
+		TokenAll:       {Description: "all"},
+		TokenInclude:   {Description: "include"},
+		TokenExists:    {Description: "exists"},
+		TokenOffset:    {Description: "offset"},
+		TokenFull:      {Description: "full"},
+		TokenGlobal:    {Description: "global"},
+		TokenSession:   {Description: "session"},
+		TokenTables:    {Description: "tables"},
+		TokenUnion:     {Description: "union"},
+		TokenIntersect: {Description: "intersect"},
+		TokenExcept:    {Description: "except"},
+
+		// window function keywords
+		TokenOver:        {Description: "over"},
+		TokenPartitionBy: {Description: "partition by"},
+		TokenRows:        {Description: "rows"},
+		TokenRange:       {Description: "range"},
+		TokenUnbounded:   {Description: "unbounded"},
+		TokenPreceding:   {Description: "preceding"},
+		TokenFollowing:   {Description: "following"},
+		TokenCurrentRow:  {Description: "current row"},
 
 		// ddl keywords
 		TokenChange:       {Description: "change"},
@@ -343,6 +377,10 @@ var (
 		TokenAdd:          {Description: "add"},
 		TokenFirst:        {Description: "first"},
 		TokenAfter:        {Description: "after"},
+		TokenPrimaryKey:   {Description: "primary key"},
+		TokenDefault:      {Description: "default"},
+		TokenForeignKey:   {Description: "foreign key"},
+		TokenReferences:   {Description: "references"},
 
 		// QL Keywords, all lower-case
 		TokenSet:  {Description: "set"},
@@ -0,0 +1,98 @@
+package plan
+
+import (
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/expr/translate"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// CapabilitiesOf the capabilities of conn (or mutator), those it declares
+// if it is a schema.SourceCapabilities, else those of the interfaces it
+// implements.
+func CapabilitiesOf(conn interface{}) *schema.Capabilities {
+	if declared, ok := conn.(schema.SourceCapabilities); ok {
+		if caps := declared.Capabilities(); caps != nil {
+			return caps
+		}
+	}
+	caps := &schema.Capabilities{}
+	if conn == nil {
+		return caps
+	}
+	_, caps.Scan = conn.(schema.ConnScanner)
+	_, caps.Filter = conn.(translate.Translator)
+	_, caps.Projection = conn.(SourcePlanner)
+	_, caps.Limit = conn.(SourceLimiter)
+	_, caps.Order = conn.(SourceOrderer)
+	_, caps.Aggregates = conn.(SourcePartialAggregator)
+	_, caps.Seek = conn.(schema.ConnSeeker)
+	_, caps.Partitions = conn.(schema.SourcePartitionable)
+	_, caps.Writes = conn.(schema.ConnUpsert)
+	_, caps.PatchWhere = conn.(schema.ConnPatchWhere)
+	_, caps.Deletes = conn.(schema.ConnDeletion)
+	_, caps.Transactions = conn.(schema.ConnTransaction)
+	if sorted, ok := conn.(schema.ConnSorted); ok {
+		caps.SortedBy = sorted.SortedBy()
+	}
+	if keyed, ok := conn.(schema.ConnKeyed); ok {
+		caps.KeyColumn = keyed.KeyColumn()
+	}
+	return caps
+}
+
+// Capabilities of the connection of this source, of wheres translated by
+// the translator registered for its source type if it doesn't declare them
+func (m *Source) Capabilities() *schema.Capabilities {
+	caps := CapabilitiesOf(m.Conn)
+	if _, declared := m.Conn.(schema.SourceCapabilities); !declared && !caps.Filter &&
+		m.SchemaSource != nil && m.SchemaSource.Conf != nil {
+		caps.Filter = translate.Get(m.SchemaSource.Conf.SourceType) != nil
+	}
+	return caps
+}
+
+// unsupportedFilterOp the first operator (or function) of the where node
+// the source of caps does not evaluate, "" if it does all of them
+func unsupportedFilterOp(caps *schema.Capabilities, node expr.Node) string {
+	switch n := node.(type) {
+	case *expr.BinaryNode:
+		if !caps.FilterOp(n.Operator.V) {
+			return n.Operator.V
+		}
+		for _, arg := range n.Args {
+			if op := unsupportedFilterOp(caps, arg); op != "" {
+				return op
+			}
+		}
+	case *expr.TriNode:
+		if !caps.FilterOp(n.Operator.V) {
+			return n.Operator.V
+		}
+		for _, arg := range n.Args {
+			if op := unsupportedFilterOp(caps, arg); op != "" {
+				return op
+			}
+		}
+	case *expr.UnaryNode:
+		if !caps.FilterOp(n.Operator.V) {
+			return n.Operator.V
+		}
+		return unsupportedFilterOp(caps, n.Arg)
+	case *expr.FuncNode:
+		if !caps.FilterOp(n.Name) {
+			return n.Name
+		}
+		for _, arg := range n.Args {
+			if op := unsupportedFilterOp(caps, arg); op != "" {
+				return op
+			}
+		}
+	case *expr.ArrayNode:
+		for _, arg := range n.Args {
+			if op := unsupportedFilterOp(caps, arg); op != "" {
+				return op
+			}
+		}
+	}
+	return ""
+}
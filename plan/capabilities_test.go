@@ -0,0 +1,45 @@
+package plan_test
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/datasource/membtree"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// equalsOnly a source that only evaluates = of the wheres pushed down
+type equalsOnly struct {
+	*membtree.StaticDataSource
+}
+
+func (m *equalsOnly) Capabilities() *schema.Capabilities {
+	return &schema.Capabilities{Scan: true, Filter: true, FilterOps: []string{"="}}
+}
+
+func TestCapabilities(t *testing.T) {
+	users := membtree.NewStaticDataSource("caps_users", 0, [][]driver.Value{{int64(1), "aaron"}}, []string{"id", "name"})
+
+	caps := plan.CapabilitiesOf(users)
+	assert.Equal(t, true, caps.Scan)
+	assert.Equal(t, true, caps.Filter)
+	assert.Equal(t, true, caps.Seek)
+	assert.Equal(t, "id", caps.KeyColumn)
+	assert.Equal(t, true, caps.Partitions)
+	assert.Equal(t, true, caps.Writes && caps.PatchWhere && caps.Deletes)
+	assert.Equal(t, false, caps.Projection || caps.Limit || caps.Order || caps.Aggregates)
+	assert.Equal(t, true, caps.FilterOp("LIKE"))
+
+	// declared capabilities, not those of the interfaces
+	caps = plan.CapabilitiesOf(&equalsOnly{users})
+	assert.Equal(t, false, caps.Seek || caps.Writes)
+	assert.Equal(t, "", caps.KeyColumn)
+	assert.Equal(t, true, caps.FilterOp("="))
+	assert.Equal(t, true, caps.FilterOp("AND"))
+	assert.Equal(t, false, caps.FilterOp("like"))
+
+	assert.Equal(t, &schema.Capabilities{}, plan.CapabilitiesOf(nil))
+}
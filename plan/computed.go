@@ -0,0 +1,74 @@
+package plan
+
+import (
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// computedOf the computed columns of tbl (see schema.Table.AddComputed), all
+// of them in order if the statement of the source refers to any, as each may
+// refer to those before it
+func computedOf(tbl *schema.Table, from *rel.SqlSource) []*schema.Field {
+	if tbl == nil || from == nil || from.Source == nil {
+		return nil
+	}
+	fields := tbl.ComputedFields()
+	if len(fields) == 0 {
+		return nil
+	}
+	stmt := from.Source
+	nodes := make([]expr.Node, 0)
+	for _, cols := range []rel.Columns{stmt.Columns, stmt.GroupBy, stmt.OrderBy} {
+		for _, col := range cols {
+			if col.Star {
+				return fields
+			}
+			if col.SourceField != "" {
+				nodes = append(nodes, &expr.IdentityNode{Text: col.SourceField})
+			}
+			if col.Expr != nil {
+				nodes = append(nodes, col.Expr)
+			}
+		}
+	}
+	if stmt.Where != nil && stmt.Where.Expr != nil {
+		nodes = append(nodes, stmt.Where.Expr)
+	}
+	if stmt.Having != nil {
+		nodes = append(nodes, stmt.Having)
+	}
+	nodes = append(nodes, from.JoinNodes()...)
+	for _, node := range nodes {
+		for _, ident := range expr.FindAllIdentityField(node) {
+			_, col, _ := expr.LeftRight(ident)
+			if f, ok := tbl.Field(col); ok && f.IsComputed() {
+				return fields
+			}
+		}
+	}
+	return nil
+}
+
+// pushdownDisabled is pushdown to src disabled, by a NO_PUSHDOWN hint or
+// as it is queried of computed columns its source does not have
+func (m *PlannerDefault) pushdownDisabled(src *Source) bool {
+	return len(src.Computed) > 0 || m.Ctx.Hints.PushdownDisabled(src.Stmt)
+}
+
+// noPushdownReason why pushdown to src is disabled, see pushdownDisabled
+func noPushdownReason(src *Source) string {
+	if len(src.Computed) > 0 {
+		return "computed columns"
+	}
+	return "NO_PUSHDOWN hint"
+}
+
+// computedNames the names of the computed columns fields
+func computedNames(fields []*schema.Field) []string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
+	}
+	return names
+}
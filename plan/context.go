@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/rand"
 	"strings"
+	"sync"
 	"time"
 
 	u "github.com/araddon/gou"
@@ -48,13 +49,78 @@ type Context struct {
 	Session expr.ContextReadWriter // Session for this connection
 	Schema  *schema.Schema         // this schema for this connection
 	Funcs   expr.FuncResolver      // Local/Dialect specific functions
+	Tx      *Tx                    // transaction of the writes of this statement, nil for none
 
 	// From configuration
-	DisableRecover bool
+	DisableRecover       bool
+	UseMaterializedViews bool // allow planner to rewrite queries to use materialized views
+	Parallelism          int  // default degree of parallelism for where/aggregation, <= 1 is serial
+	ScanWorkers          int  // partitions of a partitioned source scanned at once, <= 0 for GOMAXPROCS
+	// Analyze collect execution metrics of each operator into Operators
+	Analyze bool
+	// Timeout of the statement, once past it the statement is stopped as
+	// if its Context was cancelled, <= 0 for none.
+	Timeout time.Duration
+	// BufferSize of the channel between each operator, bounds how far an operator
+	// may read ahead of its consumer, <= 0 for executor default.  BufferSizes
+	// over-ride per operator type ("source", "where", "join", "groupby" etc).
+	BufferSize  int
+	BufferSizes map[string]int
+	// RowBatchSize rows per batch sent from sources to the operators that
+	// take batches of rows instead of single rows, <= 1 for single rows.
+	RowBatchSize int
+	// SortMemory bytes of rows an ORDER BY holds in memory before spilling
+	// sorted runs to temp files in TempDir (default os.TempDir()), <= 0 for
+	// executor default.
+	SortMemory int64
+	TempDir    string
+	// AggMemory bytes of group state a GROUP BY (or keys a DISTINCT) holds in
+	// memory before spilling to temp files, <= 0 for executor default.
+	AggMemory int64
+	// JoinMemory bytes of rows the build side of a hash join holds in memory
+	// before the join adapts, building the other side instead if it is
+	// smaller, else spilling both sides to temp files, <= 0 for executor
+	// default.
+	JoinMemory int64
+	// WriteBatchSize rows an INSERT or UPSERT writes to its source at once
+	// (PutMulti), <= 0 for executor default, 1 to write (Put) each row.
+	WriteBatchSize int
+	// MemoryLimit bytes all operators of this query may hold in memory at
+	// once, past it operators spill or fail the query, <= 0 for no limit.
+	MemoryLimit int64
+	// Retry policy of the calls to open and scan sources that fail with a
+	// transient error, nil for no retries.
+	Retry *RetryPolicy
+	// Tracer of the spans of parse, plan and each exec operator of the
+	// query, nil to not trace.
+	Tracer Tracer
+	// Checkpoints store of the operator state of a continuous query, saved
+	// under CheckpointKey as its windows are emitted and restored when it
+	// is restarted, nil to not checkpoint.
+	Checkpoints   CheckpointStore
+	CheckpointKey string
+	// WindowLateness how long past the end of a tumbling window rows of it
+	// are still aggregated before the window is emitted, rows arriving
+	// later are dropped.
+	WindowLateness time.Duration
 
 	// Local State
 	Errors     []error
+	Hints      *Hints          // Optimizer hints for this statement
+	Warnings   []string        // Non-fatal planning warnings, shown in EXPLAIN output
+	Explain    Task            // For EXPLAIN statements, the plan being explained
+	Metrics    *PlanMetrics    // Planning timings and decisions
+	Operators  OperatorMetrics // Execution metrics per operator, if Analyze
+	adaptMu    sync.Mutex
+	adaptions  []string
+	ackMu      sync.Mutex
+	ackers     []schema.ConnAcker
+	keptId     uint64 // of the last message kept by the where of the ackers
+	droppedId  uint64 // of the last message filtered out by it
 	errRecover interface{}
+	memory     *MemoryAccount
+	memoryOnce sync.Once
+	traceSpan  Span // of the query, if tracing
 }
 
 // NewContext plan context
@@ -65,6 +131,78 @@ func NewContextFromPb(pb *ContextPb) *Context {
 	return &Context{id: pb.Id, fingerprint: pb.Fingerprint, SchemaName: pb.Schema}
 }
 
+// User the user for this connection from session variable @@user, empty
+// for anonymous
+func (m *Context) User() string {
+	if m.Session == nil {
+		return ""
+	}
+	if v, ok := m.Session.Get("@@user"); ok && v != nil {
+		return v.ToString()
+	}
+	return ""
+}
+
+// Memory the account of memory held by the operators of this query, limited
+// to MemoryLimit
+func (m *Context) Memory() *MemoryAccount {
+	if m == nil {
+		return NewMemoryAccount(0)
+	}
+	m.memoryOnce.Do(func() {
+		m.memory = NewMemoryAccount(m.MemoryLimit)
+	})
+	return m.memory
+}
+
+// AddAcker register the scan of messages acked once their rows are
+// written, by the writer of an INSERT ... SELECT
+func (m *Context) AddAcker(acker schema.ConnAcker) {
+	m.ackMu.Lock()
+	m.ackers = append(m.ackers, acker)
+	m.ackMu.Unlock()
+}
+
+// Ackers the scans of messages of this statement
+func (m *Context) Ackers() []schema.ConnAcker {
+	if m == nil {
+		return nil
+	}
+	m.ackMu.Lock()
+	defer m.ackMu.Unlock()
+	return m.ackers
+}
+
+// Filtered record the message of id kept, or filtered out, by the where
+// of the scans of the ackers
+func (m *Context) Filtered(id uint64, kept bool) {
+	m.ackMu.Lock()
+	defer m.ackMu.Unlock()
+	if len(m.ackers) == 0 {
+		return
+	}
+	if kept {
+		m.keptId = id
+	} else {
+		m.droppedId = id
+	}
+}
+
+// FilteredIds the ids of the last messages kept and filtered out by the
+// where of the scans of the ackers
+func (m *Context) FilteredIds() (kept, dropped uint64) {
+	m.ackMu.Lock()
+	defer m.ackMu.Unlock()
+	return m.keptId, m.droppedId
+}
+
+// Warnf records a non-fatal planning warning
+func (m *Context) Warnf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	u.Debugf("plan warning: %s", msg)
+	m.Warnings = append(m.Warnings, msg)
+}
+
 // called by go routines/tasks to ensure any recovery panics are captured
 func (m *Context) Recover() {
 	if m == nil {
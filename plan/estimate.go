@@ -0,0 +1,83 @@
+package plan
+
+import (
+	"database/sql/driver"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// EstimatedRows the rows this source is estimated to return, of the row
+// count of its table and the selectivity of the comparisons of columns to
+// literals of its where, by the stats of the table (see ANALYZE TABLE).
+// False if the table has not been analyzed.
+func (m *Source) EstimatedRows() (int64, bool) {
+	if m.Tbl == nil {
+		return 0, false
+	}
+	rows, ok := m.Tbl.RowCount()
+	if !ok {
+		return 0, false
+	}
+	sel := 1.0
+	if m.Stmt != nil && m.Stmt.Source != nil && m.Stmt.Source.Where != nil {
+		sel = selectivity(m.Tbl, m.Stmt.Source.Where.Expr)
+	}
+	return int64(float64(rows)*sel + 0.5), true
+}
+
+// selectivity estimated fraction of the rows of tbl matching node, of
+// the ANDs and ORs of comparisons of a column to a literal, 1 for others
+func selectivity(tbl *schema.Table, node expr.Node) float64 {
+	bn, ok := node.(*expr.BinaryNode)
+	if !ok || len(bn.Args) != 2 {
+		return 1
+	}
+	switch bn.Operator.T {
+	case lex.TokenLogicAnd:
+		return selectivity(tbl, bn.Args[0]) * selectivity(tbl, bn.Args[1])
+	case lex.TokenLogicOr:
+		l, r := selectivity(tbl, bn.Args[0]), selectivity(tbl, bn.Args[1])
+		return l + r - l*r
+	}
+	op := bn.Operator.T
+	in, ok := bn.Args[0].(*expr.IdentityNode)
+	lit := bn.Args[1]
+	if !ok {
+		// 5 < col is col > 5
+		if in, ok = bn.Args[1].(*expr.IdentityNode); !ok {
+			return 1
+		}
+		lit = bn.Args[0]
+		switch op {
+		case lex.TokenLT:
+			op = lex.TokenGT
+		case lex.TokenLE:
+			op = lex.TokenGE
+		case lex.TokenGT:
+			op = lex.TokenLT
+		case lex.TokenGE:
+			op = lex.TokenLE
+		}
+	}
+	v, ok := literalValue(lit)
+	if !ok {
+		return 1
+	}
+	_, col, _ := in.LeftRight()
+	return tbl.ColumnStats(col).Selectivity(op, v)
+}
+
+func literalValue(node expr.Node) (driver.Value, bool) {
+	switch n := node.(type) {
+	case *expr.NumberNode:
+		if n.IsInt {
+			return n.Int64, true
+		}
+		return n.Float64, true
+	case *expr.StringNode:
+		return n.Text, true
+	}
+	return nil, false
+}
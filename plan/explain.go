@@ -0,0 +1,191 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/araddon/qlbridge/rel"
+)
+
+// ExplainStep is a single row of EXPLAIN output, describing one task
+// of a plan dag.  ParentId is 0 for the root task.
+type ExplainStep struct {
+	Id       int
+	ParentId int
+	Task     string
+	Detail   string
+}
+
+// ExplainTask flattens a plan dag into depth-first ordered steps
+func ExplainTask(t Task) []*ExplainStep {
+	steps := make([]*ExplainStep, 0)
+	return explainTask(t, 0, steps)
+}
+
+func explainTask(t Task, parentId int, steps []*ExplainStep) []*ExplainStep {
+	if t == nil {
+		return steps
+	}
+	step := &ExplainStep{Id: len(steps) + 1, ParentId: parentId}
+	steps = append(steps, step)
+
+	switch tt := t.(type) {
+	case *Select:
+		step.Task = "select"
+		if tt.Stmt != nil {
+			step.Detail = tt.Stmt.String()
+		}
+	case *Source:
+		step.Task = "source"
+		if tt.Stmt != nil {
+			step.Detail = tt.Stmt.SourceName()
+		}
+		if len(tt.Static) > 0 {
+			step.Detail = "static"
+		} else if tt.Conn != nil {
+			if tt.Capabilities().Projection && len(tt.Children()) > 0 {
+				step.Detail += " pushdown"
+			}
+			step.Detail += fmt.Sprintf(" conn=%T", tt.Conn)
+		}
+		if tt.IndexHint != "" {
+			step.Detail += fmt.Sprintf(" index=%s", tt.IndexHint)
+		}
+		if len(tt.Partitions) > 0 {
+			step.Detail += fmt.Sprintf(" partitions=%d workers=%d", len(tt.Partitions), tt.ScanWorkers)
+		}
+		if rows, ok := tt.EstimatedRows(); ok {
+			step.Detail += fmt.Sprintf(" rows=%d", rows)
+		}
+	case *Where:
+		step.Task = "where"
+		step.Detail = whereDetail(tt.Stmt)
+	case *Having:
+		step.Task = "having"
+		if tt.Stmt != nil && tt.Stmt.Having != nil {
+			step.Detail = tt.Stmt.Having.String()
+		}
+	case *GroupBy:
+		step.Task = "groupby"
+		if tt.Stmt != nil {
+			step.Detail = tt.Stmt.GroupBy.String()
+		}
+		if tt.Partial {
+			step.Detail += " partial"
+		}
+		if tt.Final {
+			step.Detail += " final"
+		}
+	case *Window:
+		step.Task = "window"
+		if tt.Stmt != nil {
+			cols := make([]string, 0)
+			for _, col := range tt.Stmt.Columns {
+				if col.Over != nil {
+					cols = append(cols, col.String())
+				}
+			}
+			step.Detail = strings.Join(cols, ", ")
+		}
+	case *Distinct:
+		step.Task = "distinct"
+		if tt.Stmt != nil {
+			step.Detail = tt.Stmt.Columns.String()
+		}
+	case *SetOperation:
+		step.Task = strings.ToLower(tt.Op.String())
+		step.Detail = "distinct"
+		if tt.All {
+			step.Detail = "all"
+		}
+		steps = explainTask(tt.Left, step.Id, steps)
+		steps = explainTask(tt.Right, step.Id, steps)
+	case *Order:
+		step.Task = "order"
+		if tt.Stmt != nil {
+			step.Detail = tt.Stmt.OrderBy.String()
+		}
+		if tt.Limit > 0 {
+			step.Detail += fmt.Sprintf(" top %d", tt.Limit)
+		}
+	case *Projection:
+		step.Task = "projection"
+		if tt.Proj != nil {
+			cols := make([]string, len(tt.Proj.Columns))
+			for i, col := range tt.Proj.Columns {
+				cols[i] = col.As
+			}
+			step.Detail = strings.Join(cols, ", ")
+		}
+		if tt.Final {
+			step.Detail += " final"
+		}
+	case *JoinMerge:
+		step.Task = "join"
+		step.Detail = tt.Algorithm
+		if step.Detail == "" {
+			step.Detail = JoinAlgorithmHash
+		}
+		switch left, right := tt.Preserved(); {
+		case left && right:
+			step.Detail += " full outer"
+		case left:
+			step.Detail += " left outer"
+		case right:
+			step.Detail += " right outer"
+		}
+		if tt.BuildLeft {
+			step.Detail += " build=left"
+		}
+		switch {
+		case len(tt.Partitions) > 0:
+			step.Detail += fmt.Sprintf(" colocated partitions=%d", len(tt.Partitions))
+		case tt.Repartition > 0:
+			step.Detail += fmt.Sprintf(" repartition=%d", tt.Repartition)
+		}
+		steps = explainTask(tt.Left, step.Id, steps)
+		steps = explainTask(tt.Right, step.Id, steps)
+	case *JoinKey:
+		step.Task = "joinkey"
+	case *Exchange:
+		step.Task = "exchange"
+		step.Detail = fmt.Sprintf("fragments=%d", len(tt.Fragments))
+		for _, frag := range tt.Fragments {
+			steps = explainTask(frag, step.Id, steps)
+		}
+	case *SemiJoin:
+		step.Task = "semijoin"
+		if tt.Anti {
+			step.Task = "antijoin"
+		}
+		switch {
+		case tt.Lhs != nil && tt.Rhs != nil:
+			step.Detail = fmt.Sprintf("%s = %s", tt.Lhs, tt.Rhs)
+		default:
+			step.Detail = "exists"
+		}
+		steps = explainTask(tt.Sub, step.Id, steps)
+	case *Fragment:
+		step.Task = "fragment"
+		step.Detail = fmt.Sprintf("%d", tt.Id)
+	case *Insert, *Upsert, *Update, *Delete, *Command, *Into:
+		step.Task = strings.ToLower(strings.TrimPrefix(fmt.Sprintf("%T", t), "*plan."))
+	default:
+		step.Task = fmt.Sprintf("%T", t)
+	}
+
+	for _, child := range t.Children() {
+		steps = explainTask(child, step.Id, steps)
+	}
+	return steps
+}
+
+func whereDetail(stmt *rel.SqlSelect) string {
+	if stmt == nil || stmt.Where == nil {
+		return ""
+	}
+	if stmt.Where.Expr != nil {
+		return stmt.Where.Expr.String()
+	}
+	return stmt.Where.String()
+}
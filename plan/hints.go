@@ -0,0 +1,170 @@
+package plan
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/rel"
+)
+
+var _ = u.EMPTY
+
+const (
+	// JoinAlgorithmHash build a hash table of one side of join and probe with other
+	JoinAlgorithmHash = "hash"
+	// JoinAlgorithmMerge merge two inputs sorted on the join key
+	JoinAlgorithmMerge = "merge"
+	// JoinAlgorithmLookup look up the rows of the right side by key for
+	// each batch of left rows, instead of scanning it
+	JoinAlgorithmLookup = "lookup"
+)
+
+// Hints are optimizer hints parsed from a leading statement comment
+//
+//    /*+ NO_PUSHDOWN(users) HASH_JOIN USE_INDEX(orders, idx_user) MAX_PARALLELISM(4) */
+//    SELECT ...
+//
+//  - NO_PUSHDOWN(source, ...)    do not let source(s) plan their own execution, run
+//                                where/projection in-process.  NO_PUSHDOWN() with no
+//                                args applies to all sources.
+//  - HASH_JOIN, MERGE_JOIN,      force the join algorithm, MERGE_JOIN sorts
+//    LOOKUP_JOIN                 inputs that are not already sorted on join key,
+//                                LOOKUP_JOIN requires a right side keyed on join key
+//  - USE_INDEX(table, index)     force a specific index for a table (alias FORCE_INDEX),
+//                                of sources that seek indexes (SourceIndexTranslator)
+//  - PARALLEL(n)                 run with degree of parallelism n
+//  - MAX_PARALLELISM(n)          cap the degree of parallelism
+//
+// Hints that are unknown or invalid are ignored and reported as warnings
+// on the plan Context (shown in EXPLAIN output).
+type Hints struct {
+	NoPushdown     map[string]bool   // source names/aliases that must not push down, "*" for all
+	JoinAlgorithm  string            // forced join algorithm, empty for planner choice
+	Indexes        map[string]string // table -> forced index name
+	Parallelism    int               // requested degree of parallelism, 0 for default
+	MaxParallelism int               // 0 for no cap
+}
+
+// ParseHints parse the raw hint text (inside of /*+ */) into Hints, returning
+// a list of warnings for hints that were not understood.
+func ParseHints(raw string) (*Hints, []string) {
+	h := &Hints{
+		NoPushdown: make(map[string]bool),
+		Indexes:    make(map[string]string),
+	}
+	var warnings []string
+	warnf := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+	for _, hint := range splitHints(raw) {
+		name, args := hint.name, hint.args
+		switch name {
+		case "no_pushdown":
+			if len(args) == 0 {
+				h.NoPushdown["*"] = true
+			}
+			for _, arg := range args {
+				h.NoPushdown[strings.ToLower(arg)] = true
+			}
+		case "hash_join":
+			h.JoinAlgorithm = JoinAlgorithmHash
+		case "merge_join":
+			h.JoinAlgorithm = JoinAlgorithmMerge
+		case "lookup_join":
+			h.JoinAlgorithm = JoinAlgorithmLookup
+		case "use_index", "force_index":
+			if len(args) != 2 {
+				warnf("hint %s expects (table, index) got %v", strings.ToUpper(name), args)
+				continue
+			}
+			h.Indexes[strings.ToLower(args[0])] = args[1]
+		case "max_parallelism", "parallel":
+			if len(args) != 1 {
+				warnf("hint %s expects (n) got %v", strings.ToUpper(name), args)
+				continue
+			}
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				warnf("hint %s expects a positive integer got %q", strings.ToUpper(name), args[0])
+				continue
+			}
+			if name == "parallel" {
+				h.Parallelism = n
+			} else {
+				h.MaxParallelism = n
+			}
+		default:
+			warnf("unknown hint %s ignored", hint.raw)
+		}
+	}
+	return h, warnings
+}
+
+// PushdownDisabled is source pushdown disabled by hint for this source
+func (m *Hints) PushdownDisabled(from *rel.SqlSource) bool {
+	if m == nil || from == nil {
+		return false
+	}
+	if m.NoPushdown["*"] {
+		return true
+	}
+	return m.NoPushdown[strings.ToLower(from.Name)] || (from.Alias != "" && m.NoPushdown[strings.ToLower(from.Alias)])
+}
+
+// Index the forced index for this source, empty if none
+func (m *Hints) Index(from *rel.SqlSource) string {
+	if m == nil || from == nil {
+		return ""
+	}
+	if idx, ok := m.Indexes[strings.ToLower(from.Name)]; ok {
+		return idx
+	}
+	if from.Alias != "" {
+		return m.Indexes[strings.ToLower(from.Alias)]
+	}
+	return ""
+}
+
+type hintToken struct {
+	raw  string
+	name string
+	args []string
+}
+
+// splitHints splits   "NO_PUSHDOWN(a, b) HASH_JOIN"  into individual hints
+func splitHints(raw string) []hintToken {
+	hints := make([]hintToken, 0)
+	raw = strings.TrimSpace(raw)
+	for len(raw) > 0 {
+		end := strings.IndexAny(raw, " \t\n(")
+		if end < 0 {
+			hints = append(hints, hintToken{raw: raw, name: strings.ToLower(raw)})
+			break
+		}
+		name := raw[:end]
+		if raw[end] != '(' {
+			hints = append(hints, hintToken{raw: name, name: strings.ToLower(name)})
+			raw = strings.TrimSpace(raw[end:])
+			continue
+		}
+		closeIdx := strings.Index(raw, ")")
+		if closeIdx < 0 {
+			// un-terminated args, treat rest as the hint
+			hints = append(hints, hintToken{raw: raw, name: strings.ToLower(name)})
+			break
+		}
+		ht := hintToken{raw: raw[:closeIdx+1], name: strings.ToLower(name)}
+		for _, arg := range strings.Split(raw[end+1:closeIdx], ",") {
+			arg = strings.Trim(strings.TrimSpace(arg), "`'\"")
+			if arg != "" {
+				ht.args = append(ht.args, arg)
+			}
+		}
+		hints = append(hints, ht)
+		raw = strings.TrimSpace(raw[closeIdx+1:])
+	}
+	return hints
+}
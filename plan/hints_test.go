@@ -0,0 +1,58 @@
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+)
+
+func TestParseHints(t *testing.T) {
+
+	h, warnings := plan.ParseHints(`NO_PUSHDOWN(users, o) HASH_JOIN USE_INDEX(orders, idx_user) MAX_PARALLELISM(4)`)
+	assert.Equal(t, 0, len(warnings))
+	assert.Equal(t, plan.JoinAlgorithmHash, h.JoinAlgorithm)
+	assert.Equal(t, 4, h.MaxParallelism)
+	assert.Equal(t, true, h.PushdownDisabled(&rel.SqlSource{Name: "users"}))
+	assert.Equal(t, true, h.PushdownDisabled(&rel.SqlSource{Name: "orders", Alias: "o"}))
+	assert.Equal(t, false, h.PushdownDisabled(&rel.SqlSource{Name: "orders"}))
+	assert.Equal(t, "idx_user", h.Index(&rel.SqlSource{Name: "ORDERS"}))
+	assert.Equal(t, "", h.Index(&rel.SqlSource{Name: "users"}))
+
+	h, warnings = plan.ParseHints(`PARALLEL(3) MERGE_JOIN`)
+	assert.Equal(t, 0, len(warnings))
+	assert.Equal(t, 3, h.Parallelism)
+	assert.Equal(t, plan.JoinAlgorithmMerge, h.JoinAlgorithm)
+	assert.Equal(t, 0, h.MaxParallelism)
+
+	h, warnings = plan.ParseHints(`LOOKUP_JOIN`)
+	assert.Equal(t, 0, len(warnings))
+	assert.Equal(t, plan.JoinAlgorithmLookup, h.JoinAlgorithm)
+
+	h, warnings = plan.ParseHints(`NO_PUSHDOWN()`)
+	assert.Equal(t, 0, len(warnings))
+	assert.Equal(t, true, h.PushdownDisabled(&rel.SqlSource{Name: "anything"}))
+
+	h, warnings = plan.ParseHints(`BOGUS(1) PARALLEL(zero) USE_INDEX(orders)`)
+	assert.Equal(t, 3, len(warnings))
+	assert.Equal(t, "unknown hint BOGUS(1) ignored", warnings[0])
+	assert.Equal(t, 0, h.MaxParallelism)
+
+	// nil hints are safe to use
+	var none *plan.Hints
+	assert.Equal(t, false, none.PushdownDisabled(&rel.SqlSource{Name: "users"}))
+	assert.Equal(t, "", none.Index(&rel.SqlSource{Name: "users"}))
+}
+
+func TestHintsParsedFromStatement(t *testing.T) {
+	stmt, err := rel.ParseSqlSelect(`/*+ HASH_JOIN MAX_PARALLELISM(2) */ SELECT user_id FROM users`)
+	assert.Tf(t, err == nil, "must parse %v", err)
+	assert.Equal(t, "HASH_JOIN MAX_PARALLELISM(2)", stmt.Hints)
+
+	// a plain comment is not a hint
+	stmt, err = rel.ParseSqlSelect(`/* just a comment */ SELECT user_id FROM users`)
+	assert.Tf(t, err == nil, "must parse %v", err)
+	assert.Equal(t, "", stmt.Hints)
+}
@@ -0,0 +1,107 @@
+package plan
+
+import (
+	"strings"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/expr"
+)
+
+var _ = u.EMPTY
+
+// planJoinPartitions decide how the rows of two partitioned sources are
+// distributed to the join.
+//
+// Colocated, both inputs are partitioned on their join keys by the same
+// ranges, and each can open a connection per partition, so partition i of
+// left only needs to be joined with partition i of right:
+//
+//   left p0   ->
+//                 join p0  ->
+//   right p0  ->               --> merge
+//   left p1   ->
+//                 join p1  ->
+//   right p1  ->
+//
+// Otherwise if either input is partitioned, both are repartitioned (hash of
+// join key) into the larger partition count and joined per hash partition.
+func (m *PlannerDefault) planJoinPartitions(jm *JoinMerge, left, right *Source) {
+	lp, rp := sourcePartitions(left), sourcePartitions(right)
+	if lp == 0 && rp == 0 {
+		return
+	}
+	if colocated(left, right) {
+		jm.Partitions = left.Tbl.Partition.Partitions
+		m.Ctx.RuleApplied("colocated-join")
+		return
+	}
+	if n := lp; rp > n {
+		jm.Repartition = rp
+	} else {
+		jm.Repartition = n
+	}
+	m.Ctx.RuleApplied("repartition-join")
+}
+
+// sourcePartitions number of partitions of the table for this source
+func sourcePartitions(s *Source) int {
+	if s == nil || s.Tbl == nil {
+		return 0
+	}
+	if s.Tbl.Partition != nil && len(s.Tbl.Partition.Partitions) > 0 {
+		return len(s.Tbl.Partition.Partitions)
+	}
+	return s.Tbl.PartitionCt
+}
+
+// colocated are both sources partitioned on their join keys by the same ranges
+// (or hash buckets)
+func colocated(left, right *Source) bool {
+	for _, s := range []*Source{left, right} {
+		if s.Tbl == nil || s.Tbl.Partition == nil || len(s.Tbl.Partition.Partitions) == 0 {
+			return false
+		}
+		if !s.Capabilities().Partitions && !sourcedPartitions(s) {
+			return false
+		}
+		if !sameKeys(s.Tbl.Partition.Keys, s.Stmt.JoinNodes()) {
+			return false
+		}
+	}
+	ltp, rtp := left.Tbl.Partition, right.Tbl.Partition
+	if ltp.Hashed() != rtp.Hashed() || ltp.Buckets != rtp.Buckets {
+		return false
+	}
+	lparts, rparts := ltp.Partitions, rtp.Partitions
+	if len(lparts) != len(rparts) {
+		return false
+	}
+	for i, lp := range lparts {
+		if lp.Left != rparts[i].Left || lp.Right != rparts[i].Right {
+			return false
+		}
+	}
+	return true
+}
+
+// sameKeys are the join key nodes exactly the partition key columns, in order
+func sameKeys(keys []string, joinNodes []expr.Node) bool {
+	if len(keys) == 0 || len(keys) != len(joinNodes) {
+		return false
+	}
+	for i, node := range joinNodes {
+		in, ok := node.(*expr.IdentityNode)
+		if !ok {
+			return false
+		}
+		name := in.Text
+		if _, right, hasLeft := in.LeftRight(); hasLeft {
+			name = right
+		}
+		if !strings.EqualFold(name, keys[i]) {
+			return false
+		}
+	}
+	return true
+}
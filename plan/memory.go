@@ -0,0 +1,75 @@
+package plan
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// MemoryQuotaError an operator needed more memory than is left of the
+// MemoryLimit of its query, and could not spill to disk instead
+type MemoryQuotaError struct {
+	Operator string
+	Limit    int64 // MemoryLimit of query
+	Used     int64 // bytes in use by query
+	Request  int64 // bytes requested
+}
+
+func (m *MemoryQuotaError) Error() string {
+	return fmt.Sprintf("memory quota exceeded: %s needs %d more bytes, %d of %d bytes in use",
+		m.Operator, m.Request, m.Used, m.Limit)
+}
+
+// IsMemoryQuotaExceeded is err a MemoryQuotaError
+func IsMemoryQuotaExceeded(err error) bool {
+	_, ok := err.(*MemoryQuotaError)
+	return ok
+}
+
+// MemoryAccount the bytes of memory held by all operators of a query.
+// Operators reserve memory for the rows and state they hold before holding
+// them, and release it when they let go.  A reservation past the limit
+// fails, operators that can spill (order by, group by, distinct) spill to
+// disk and the others fail the query with a MemoryQuotaError, instead of
+// one query taking all memory of the process.
+//
+//   order by   -> Reserve()  -> ok
+//   hash join  -> Reserve()  -> ok
+//   group by   -> Reserve()  -> over limit -> spill, Release()
+//   window     -> Reserve()  -> over limit -> MemoryQuotaError
+type MemoryAccount struct {
+	limit int64
+	used  int64
+	peak  int64
+}
+
+// NewMemoryAccount an account of memory up to limit bytes, <= 0 for no limit
+func NewMemoryAccount(limit int64) *MemoryAccount {
+	return &MemoryAccount{limit: limit}
+}
+
+// Reserve n bytes for operator, fails with a MemoryQuotaError if over limit
+func (m *MemoryAccount) Reserve(operator string, n int64) error {
+	used := atomic.AddInt64(&m.used, n)
+	if m.limit > 0 && used > m.limit {
+		atomic.AddInt64(&m.used, -n)
+		return &MemoryQuotaError{Operator: operator, Limit: m.limit, Used: used - n, Request: n}
+	}
+	for {
+		peak := atomic.LoadInt64(&m.peak)
+		if used <= peak || atomic.CompareAndSwapInt64(&m.peak, peak, used) {
+			return nil
+		}
+	}
+}
+
+// Release n bytes reserved earlier
+func (m *MemoryAccount) Release(n int64) { atomic.AddInt64(&m.used, -n) }
+
+// Used bytes currently reserved
+func (m *MemoryAccount) Used() int64 { return atomic.LoadInt64(&m.used) }
+
+// Peak the most bytes reserved at once
+func (m *MemoryAccount) Peak() int64 { return atomic.LoadInt64(&m.peak) }
+
+// Limit bytes that may be reserved, <= 0 for no limit
+func (m *MemoryAccount) Limit() int64 { return m.limit }
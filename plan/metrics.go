@@ -0,0 +1,251 @@
+package plan
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// PlanMetrics are the timings and decisions made while planning a statement,
+// to find out why planning was slow or why a plan is not the one expected.
+//
+//   ctx := plan.NewContext(sql)
+//   p, err := plan.WalkStmt(ctx, stmt, plan.NewPlanner(ctx))
+//   ctx.Metrics.Phase("sources").Time
+//
+// Phases of sub-queries are accumulated into the phase of the same name, and
+// their time is also included in the outer phase they were planned in.
+type PlanMetrics struct {
+	Total     time.Duration     // total time planning the statement
+	Phases    []*PhaseMetric    // in the order first started
+	Rules     []string          // rewrite rules applied, in order
+	Pushdowns []*PushdownMetric // pushdown decisions per source
+}
+
+// PhaseMetric time spent in a planning phase
+type PhaseMetric struct {
+	Name  string
+	Time  time.Duration
+	Count int // number of times this phase was run (sub-queries, unions)
+}
+
+// PushdownMetric decision to push (or not) work down to a source
+type PushdownMetric struct {
+	Source   string // source (table) name
+	Kind     string // planner:  source planned itself,  translate:  where translated to native query, aggregate:  partial group by, limit:  source stops after limit rows, order:  source sorts rows
+	Accepted bool
+	Reason   string // why rejected (or accepted despite hints)
+}
+
+// Phase find metric for phase of given name, nil if it was never run
+func (m *PlanMetrics) Phase(name string) *PhaseMetric {
+	if m == nil {
+		return nil
+	}
+	for _, p := range m.Phases {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Applied was the named rewrite rule applied
+func (m *PlanMetrics) Applied(rule string) bool {
+	if m == nil {
+		return false
+	}
+	for _, r := range m.Rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// PushdownCounts number of accepted and rejected pushdowns
+func (m *PlanMetrics) PushdownCounts() (accepted, rejected int) {
+	if m == nil {
+		return 0, 0
+	}
+	for _, pd := range m.Pushdowns {
+		if pd.Accepted {
+			accepted++
+		} else {
+			rejected++
+		}
+	}
+	return accepted, rejected
+}
+
+func (m *PlanMetrics) String() string {
+	if m == nil {
+		return "<nil>"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "total=%v", m.Total)
+	for _, p := range m.Phases {
+		fmt.Fprintf(&buf, " %s=%v", p.Name, p.Time)
+	}
+	fmt.Fprintf(&buf, " rules=%v", m.Rules)
+	accepted, rejected := m.PushdownCounts()
+	fmt.Fprintf(&buf, " pushdowns accepted=%d rejected=%d", accepted, rejected)
+	return buf.String()
+}
+
+func (m *Context) metrics() *PlanMetrics {
+	if m.Metrics == nil {
+		m.Metrics = &PlanMetrics{}
+	}
+	return m.Metrics
+}
+
+// StartPhase start timing a planning phase, call the returned func to end
+// it.  Ending more than once is a no-op so it is safe to also defer it.
+func (m *Context) StartPhase(name string) func() {
+	pm := m.metrics()
+	phase := pm.Phase(name)
+	if phase == nil {
+		phase = &PhaseMetric{Name: name}
+		pm.Phases = append(pm.Phases, phase)
+	}
+	phase.Count++
+	start := time.Now()
+	done := false
+	return func() {
+		if done {
+			return
+		}
+		done = true
+		phase.Time += time.Since(start)
+	}
+}
+
+// RuleApplied record that the planner applied a rewrite rule
+func (m *Context) RuleApplied(rule string) {
+	pm := m.metrics()
+	pm.Rules = append(pm.Rules, rule)
+}
+
+// Pushdown record a decision to push work down to a source (or not)
+func (m *Context) Pushdown(source, kind string, accepted bool, reason string) {
+	pm := m.metrics()
+	pm.Pushdowns = append(pm.Pushdowns, &PushdownMetric{
+		Source:   source,
+		Kind:     kind,
+		Accepted: accepted,
+		Reason:   reason,
+	})
+}
+
+// OperatorMetric execution metrics of one operator (source, where, join
+// ...) of a query, collected if Context.Analyze is set.  Rows are counted
+// as they pass between operators, CPU is the time the operator's own
+// goroutine was on cpu (not measured on all platforms).
+type OperatorMetric struct {
+	Name       string
+	Depth      int // depth of the operator in the task dag
+	RowsIn     int64
+	RowsOut    int64
+	Wall       time.Duration // from start of operator until it was done
+	CPU        time.Duration
+	PeakMemory int64    // most bytes reserved at once, see MemoryAccount
+	Adaptions  []string // changes of strategy while running, see Context.Adapted
+}
+
+// AddRows count rows in to, or out of the operator
+func (m *OperatorMetric) AddRows(in, out int64) {
+	if in != 0 {
+		atomic.AddInt64(&m.RowsIn, in)
+	}
+	if out != 0 {
+		atomic.AddInt64(&m.RowsOut, out)
+	}
+}
+
+// Memory record bytes held by the operator, keeping the peak
+func (m *OperatorMetric) Memory(used int64) {
+	for {
+		peak := atomic.LoadInt64(&m.PeakMemory)
+		if used <= peak || atomic.CompareAndSwapInt64(&m.PeakMemory, peak, used) {
+			return
+		}
+	}
+}
+
+func (m *OperatorMetric) String() string {
+	s := fmt.Sprintf("%s rows_in=%d rows_out=%d time=%v cpu=%v peak_mem=%d", m.Name,
+		atomic.LoadInt64(&m.RowsIn), atomic.LoadInt64(&m.RowsOut), m.Wall, m.CPU,
+		atomic.LoadInt64(&m.PeakMemory))
+	if len(m.Adaptions) > 0 {
+		s += fmt.Sprintf(" adapted=%s", strings.Join(m.Adaptions, ","))
+	}
+	return s
+}
+
+// OperatorMetrics the metrics of the operators of a query, in dag order
+type OperatorMetrics []*OperatorMetric
+
+// Operator find the first metric of operator of given name, nil if none
+func (m OperatorMetrics) Operator(name string) *OperatorMetric {
+	for _, op := range m {
+		if op.Name == name {
+			return op
+		}
+	}
+	return nil
+}
+
+// String the metrics as EXPLAIN ANALYZE, one line per operator indented
+// by its depth
+//
+//   source rows_in=0 rows_out=10000 time=3.1ms cpu=2.4ms peak_mem=0
+//   where rows_in=10000 rows_out=250 time=3.2ms cpu=1.1ms peak_mem=0
+//     ...
+func (m OperatorMetrics) String() string {
+	var buf bytes.Buffer
+	minDepth := -1
+	for _, op := range m {
+		if minDepth < 0 || op.Depth < minDepth {
+			minDepth = op.Depth
+		}
+	}
+	for i, op := range m {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(strings.Repeat("  ", op.Depth-minDepth))
+		buf.WriteString(op.String())
+	}
+	return buf.String()
+}
+
+// OperatorMetric register the metrics of an operator at depth of the dag
+func (m *Context) OperatorMetric(name string, depth int) *OperatorMetric {
+	op := &OperatorMetric{Name: name, Depth: depth}
+	m.Operators = append(m.Operators, op)
+	return op
+}
+
+// Adapted record that an operator changed its strategy while running, ie a
+// hash join whose build side was far larger than expected flipping its
+// sides or spilling to disk.  Recorded in the metric of the operator (nil
+// if not analyzed) as well as the Adaptions of the query.
+func (m *Context) Adapted(op *OperatorMetric, name, what string) {
+	m.adaptMu.Lock()
+	defer m.adaptMu.Unlock()
+	m.adaptions = append(m.adaptions, name+":"+what)
+	if op != nil {
+		op.Adaptions = append(op.Adaptions, what)
+	}
+}
+
+// Adaptions the changes of strategy made by operators of the query while
+// running, as "operator:what" ie "join:spill"
+func (m *Context) Adaptions() []string {
+	m.adaptMu.Lock()
+	defer m.adaptMu.Unlock()
+	return append([]string(nil), m.adaptions...)
+}
@@ -0,0 +1,53 @@
+package plan_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	td "github.com/araddon/qlbridge/datasource/mockcsvtestdata"
+	"github.com/araddon/qlbridge/expr/translate"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+)
+
+func planMetrics(t *testing.T, q string) *plan.PlanMetrics {
+	ctx := td.TestContext(q)
+	stmt, err := rel.ParseSql(q)
+	assert.Tf(t, err == nil, "Must parse %s but got %v", q, err)
+	_, err = plan.WalkStmt(ctx, stmt, plan.NewPlanner(ctx))
+	assert.Tf(t, err == nil, "no error for %s but got %v", q, err)
+	assert.Tf(t, ctx.Metrics != nil, "expected metrics for %s", q)
+	return ctx.Metrics
+}
+
+func TestPlanMetrics(t *testing.T) {
+	td.LoadTestDataOnce()
+	translate.Register("mockcsv", translate.NewSqlTranslator('\'', '"'))
+
+	pm := planMetrics(t, `SELECT user_id FROM users WHERE referral_count > 2 AND true ORDER BY user_id`)
+	assert.T(t, pm.Total > 0)
+	for _, name := range []string{"rewrite", "resolve", "sources", "where", "aggregate", "order", "projection"} {
+		phase := pm.Phase(name)
+		assert.Tf(t, phase != nil, "expected phase %s in %v", name, pm)
+		assert.Equalf(t, 1, phase.Count, "phase %s", name)
+	}
+	assert.Equal(t, []string{"simplify"}, pm.Rules)
+	accepted, rejected := pm.PushdownCounts()
+	assert.Equal(t, 1, accepted)
+	assert.Equal(t, 0, rejected)
+	assert.Equal(t, "users", pm.Pushdowns[0].Source)
+	assert.Equal(t, "translate", pm.Pushdowns[0].Kind)
+
+	// sub-query phases accumulate, and un-translatable wheres are rejected
+	pm = planMetrics(t, `SELECT user_id FROM users AS u WHERE NOT EXISTS (SELECT order_id FROM orders AS o WHERE o.user_id = u.user_id AND toint(price) > 30)`)
+	assert.Equal(t, 2, pm.Phase("sources").Count)
+	assert.T(t, pm.Applied("decorrelate"))
+	assert.T(t, pm.Applied("anti-join"))
+	accepted, rejected = pm.PushdownCounts()
+	assert.Equal(t, 0, accepted)
+	assert.Equal(t, 1, rejected)
+	assert.Equal(t, "orders", pm.Pushdowns[0].Source)
+	assert.T(t, strings.Contains(pm.String(), "pushdowns accepted=0 rejected=1"), pm.String())
+}
@@ -0,0 +1,186 @@
+package plan
+
+import (
+	"runtime"
+	"strings"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/rel"
+)
+
+var _ = u.EMPTY
+
+// parallelism the degree of parallelism (number of fragments) to use when
+// evaluating where/aggregation for given source.  In order of precedence
+//
+//  - MAX_PARALLELISM(n) hint caps whatever is chosen below
+//  - PARALLEL(n) hint for this query
+//  - source config "parallelism"
+//  - Context.Parallelism default for this query
+//
+// Anything <= 1 means run serially.
+func (m *PlannerDefault) parallelism(p *Source) int {
+	dop := m.Ctx.Parallelism
+	if p.SchemaSource != nil && p.SchemaSource.Conf != nil && p.SchemaSource.Conf.Parallelism > 0 {
+		dop = p.SchemaSource.Conf.Parallelism
+	}
+	if h := m.Ctx.Hints; h != nil {
+		if h.Parallelism > 0 {
+			dop = h.Parallelism
+		}
+		if h.MaxParallelism > 0 && dop > h.MaxParallelism {
+			dop = h.MaxParallelism
+		}
+	}
+	return dop
+}
+
+// walkFragments splits the where and (partial) aggregation of a single source
+// select into dop parallel fragments, adding the exchange that merges them
+// to the select.  Returns false if this query can't be run in parallel.
+//
+//                   -> where -> groupby(partial) ->
+//   source  ->  exchange                              ->  groupby(final) -> ...
+//                   -> where -> groupby(partial) ->
+//
+func (m *PlannerDefault) walkFragments(p *Select, src *Source, dop int) bool {
+
+	if !m.canFragment(p.Stmt, src) {
+		return false
+	}
+	hasWhere := p.Stmt.Where != nil && p.Stmt.Where.Expr != nil
+	isAgg := p.Stmt.IsAggQuery()
+	if !hasWhere && !isAgg {
+		// nothing to parallelize
+		return false
+	}
+
+	// the where is evaluated in the fragments instead of on the source
+	kept := src.tasks[:0]
+	for _, t := range src.tasks {
+		if _, isWhere := t.(*Where); !isWhere {
+			kept = append(kept, t)
+		}
+	}
+	src.tasks = kept
+
+	exchange := NewExchange(dop)
+	for _, frag := range exchange.Fragments {
+		if hasWhere {
+			frag.Add(NewWhere(p.Stmt))
+		}
+		if isAgg {
+			frag.Add(NewGroupByPartial(p.Stmt))
+		}
+	}
+	p.Add(src)
+	p.Add(exchange)
+	return true
+}
+
+// planPartitionedScan scan each partition of a partitionable source on its
+// own connection, ScanWorkers partitions at a time, instead of a single scan
+// of the whole table.  Rows of partitions are merged in no particular order.
+//
+//   partition 0  ->
+//   partition 1  ->   source  ->  where ...
+//   partition n  ->
+//
+// Partitions of the table the where of the source can not match are not
+// scanned, see prunePartitions.  The number of workers in order of
+// precedence
+//
+//  - MAX_PARALLELISM(n) hint caps whatever is chosen below
+//  - Context.ScanWorkers
+//  - GOMAXPROCS
+//
+// and never more than the number of partitions.
+func (m *PlannerDefault) planPartitionedScan(src *Source) {
+	if len(src.Static) > 0 || src.Conn == nil || src.IsSchemaQuery() || src.IndexHint != "" {
+		return
+	}
+	caps := src.Capabilities()
+	if caps.Projection && !m.pushdownDisabled(src) {
+		// source did its own planning
+		return
+	}
+	all := partitionsOf(src)
+	parts := prunePartitions(src, all)
+	if len(parts) < len(all) {
+		// a single partition left is still scanned on its own
+		m.Ctx.RuleApplied("partition-pruning")
+		if len(parts) == 0 {
+			return
+		}
+	} else if len(parts) < 2 {
+		return
+	}
+	workers := m.Ctx.ScanWorkers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if h := m.Ctx.Hints; h != nil && h.MaxParallelism > 0 && workers > h.MaxParallelism {
+		workers = h.MaxParallelism
+	}
+	if workers > len(parts) {
+		workers = len(parts)
+	}
+	src.Partitions = parts
+	src.ScanWorkers = workers
+	m.Ctx.RuleApplied("partitioned-scan")
+}
+
+// canFragment is this a single source, in-process planned select whose
+// aggregates (if any) can be partially computed and merged.
+func (m *PlannerDefault) canFragment(stmt *rel.SqlSelect, src *Source) bool {
+	if len(src.Static) > 0 || src.Conn == nil || src.IsSchemaQuery() {
+		return false
+	}
+	caps := src.Capabilities()
+	if !caps.Scan {
+		return false
+	}
+	if caps.Projection && !m.pushdownDisabled(src) {
+		// source did its own planning
+		return false
+	}
+	if stmt.Where != nil && stmt.Where.Source != nil {
+		return false
+	}
+	if stmt.IsAggQuery() {
+		return canMergeAggs(stmt)
+	}
+	return true
+}
+
+// canMergeAggs only group-by columns and the aggregate functions that know
+// how to merge partial results are supported.
+func canMergeAggs(stmt *rel.SqlSelect) bool {
+	if WindowOf(stmt) != nil {
+		// windows are emitted as they close, by a single group by
+		return false
+	}
+colLoop:
+	for _, col := range stmt.Columns {
+		for _, gb := range stmt.GroupBy {
+			if gb.As == col.As || (col.Expr != nil && col.Expr.Equal(gb.Expr)) {
+				continue colLoop
+			}
+		}
+		fn, ok := col.Expr.(*expr.FuncNode)
+		if !ok {
+			return false
+		}
+		switch strings.ToLower(fn.Name) {
+		case "count", "sum", "avg", "approx_count_distinct":
+		default:
+			// user defined aggregates (exec.RegisterAggregate) merge states
+			if !expr.IsAgg(strings.ToLower(fn.Name)) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	td "github.com/araddon/qlbridge/datasource/mockcsvtestdata"
+	"github.com/araddon/qlbridge/plan"
+)
+
+type parallelTest struct {
+	q         string
+	dop       int
+	fragments int // expected fragments, 0 for serial plan
+}
+
+var parallelTests = []parallelTest{
+	{`SELECT user_id FROM users WHERE user_id = "abc"`, 3, 3},
+	{`SELECT user_id, count(*) FROM orders GROUP BY user_id`, 2, 2},
+	{`/*+ MAX_PARALLELISM(2) */ SELECT user_id FROM users WHERE user_id = "abc"`, 8, 2},
+	{`/*+ PARALLEL(4) */ SELECT user_id FROM users WHERE user_id = "abc"`, 0, 4},
+	// nothing to parallelize
+	{`SELECT user_id FROM users`, 4, 0},
+	// aggregate that can't merge partials
+	{`SELECT max(price) FROM orders`, 4, 0},
+	// serial by default
+	{`SELECT user_id FROM users WHERE user_id = "abc"`, 0, 0},
+}
+
+func TestParallelFragments(t *testing.T) {
+	td.LoadTestDataOnce()
+	for _, pt := range parallelTests {
+		ctx := td.TestContext(pt.q)
+		ctx.Parallelism = pt.dop
+		p := selectPlan(t, ctx)
+		assert.T(t, p != nil)
+
+		var exchange *plan.Exchange
+		for _, task := range p.Children() {
+			if ex, ok := task.(*plan.Exchange); ok {
+				exchange = ex
+			}
+		}
+		if pt.fragments == 0 {
+			assert.Tf(t, exchange == nil, "expected serial plan for %s", pt.q)
+			continue
+		}
+		assert.Tf(t, exchange != nil, "expected exchange for %s", pt.q)
+		assert.Equalf(t, pt.fragments, len(exchange.Fragments), "fragments for %s", pt.q)
+	}
+}
@@ -0,0 +1,183 @@
+package plan
+
+import (
+	"database/sql/driver"
+	"strings"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// partitionsOf the partitions of src to scan each on its own connection, of
+// its table if each partition of it names the source holding it (see
+// schema.Partition.Source), else of its connection if partitionable
+func partitionsOf(src *Source) []*schema.Partition {
+	if sourcedPartitions(src) {
+		return src.Tbl.Partition.Partitions
+	}
+	if partitionable, ok := src.Conn.(schema.SourcePartitionable); ok && src.Capabilities().Partitions {
+		return partitionable.Partitions()
+	}
+	return nil
+}
+
+// sourcedPartitions does each partition of the table of src name its source
+func sourcedPartitions(src *Source) bool {
+	if src.Tbl == nil || src.Tbl.Partition == nil || len(src.Tbl.Partition.Partitions) == 0 {
+		return false
+	}
+	for _, part := range src.Tbl.Partition.Partitions {
+		if part.Source == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// prunePartitions the partitions of parts that may hold rows of the where
+// of src, of the partitioning of its table on a single key.
+//
+//   WHERE user_id = 42        hash:   the bucket of 42
+//   WHERE day >= "2016-03"    range:  those of a Right above "2016-03"
+//
+// Partitions of parts the table does not list are kept.
+func prunePartitions(src *Source, parts []*schema.Partition) []*schema.Partition {
+	if src.Tbl == nil || src.Tbl.Partition == nil || src.Stmt == nil || src.Stmt.Source == nil {
+		return parts
+	}
+	tp := src.Tbl.Partition
+	where := src.Stmt.Source.Where
+	if len(tp.Keys) != 1 || len(tp.Partitions) == 0 || where == nil || where.Expr == nil {
+		return parts
+	}
+	bucket := make(map[string]int, len(tp.Partitions))
+	for i, part := range tp.Partitions {
+		bucket[part.Id] = i
+	}
+	kept := make([]*schema.Partition, 0, len(parts))
+	conds := andTerms(where.Expr)
+	for _, part := range parts {
+		i, listed := bucket[part.Id]
+		if !listed || mayHold(tp, tp.Partitions[i], i, conds) {
+			kept = append(kept, part)
+		}
+	}
+	return kept
+}
+
+// mayHold may the i'th partition part of tp hold rows of all of conds
+func mayHold(tp *schema.TablePartition, part *schema.Partition, i int, conds []expr.Node) bool {
+	for _, cond := range conds {
+		op, vals, ok := keyCondition(cond, tp.Keys[0])
+		if !ok {
+			continue
+		}
+		held := false
+		for _, v := range vals {
+			if holds(tp, part, i, op, v) {
+				held = true
+				break
+			}
+		}
+		if !held {
+			return false
+		}
+	}
+	return true
+}
+
+// holds may the i'th partition part of tp hold a key of op to v
+func holds(tp *schema.TablePartition, part *schema.Partition, i int, op lex.TokenType, v driver.Value) bool {
+	if op == lex.TokenIN {
+		op = lex.TokenEqual
+	}
+	if !tp.Hashed() {
+		return part.MayHold(op, v)
+	}
+	switch op {
+	case lex.TokenEqual, lex.TokenEqualEqual:
+		return tp.Bucket(v) == i
+	}
+	// ranges of a hash are in any bucket
+	return true
+}
+
+// keyCondition the operator and literals of cond if it compares key to them,
+// of the key on the left
+//
+//   key = 5            =   [5]
+//   10 < key           >   [10]
+//   key IN ("a", "b")  IN  ["a", "b"]
+func keyCondition(cond expr.Node, key string) (lex.TokenType, []driver.Value, bool) {
+	bn, ok := cond.(*expr.BinaryNode)
+	if !ok || len(bn.Args) != 2 {
+		return 0, nil, false
+	}
+	op := bn.Operator.T
+	rhs := bn.Args[1]
+	if !isKey(bn.Args[0], key) {
+		if !isKey(rhs, key) || op == lex.TokenIN {
+			return 0, nil, false
+		}
+		rhs = bn.Args[0]
+		switch op {
+		case lex.TokenLT:
+			op = lex.TokenGT
+		case lex.TokenLE:
+			op = lex.TokenGE
+		case lex.TokenGT:
+			op = lex.TokenLT
+		case lex.TokenGE:
+			op = lex.TokenLE
+		}
+	}
+	switch op {
+	case lex.TokenEqual, lex.TokenEqualEqual, lex.TokenLT, lex.TokenLE, lex.TokenGT, lex.TokenGE:
+		if v, ok := literalOf(rhs); ok {
+			return op, []driver.Value{v}, true
+		}
+	case lex.TokenIN:
+		arr, ok := rhs.(*expr.ArrayNode)
+		if !ok {
+			return 0, nil, false
+		}
+		vals := make([]driver.Value, 0, len(arr.Args))
+		for _, arg := range arr.Args {
+			v, ok := literalOf(arg)
+			if !ok {
+				return 0, nil, false
+			}
+			vals = append(vals, v)
+		}
+		return op, vals, true
+	}
+	return 0, nil, false
+}
+
+// isKey is node the identity of the column key, qualified or not
+func isKey(node expr.Node, key string) bool {
+	in, ok := node.(*expr.IdentityNode)
+	if !ok {
+		return false
+	}
+	name := in.Text
+	if _, right, hasLeft := in.LeftRight(); hasLeft {
+		name = right
+	}
+	return strings.EqualFold(name, key)
+}
+
+// literalOf the value of a string or number literal
+func literalOf(node expr.Node) (driver.Value, bool) {
+	switch nt := node.(type) {
+	case *expr.StringNode:
+		return nt.Text, true
+	case *expr.NumberNode:
+		if nt.IsInt {
+			return nt.Int64, true
+		}
+		return nt.Float64, true
+	}
+	return nil, false
+}
@@ -8,12 +8,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	u "github.com/araddon/gou"
 	"github.com/golang/protobuf/proto"
 
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
 	"github.com/araddon/qlbridge/rel"
 	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
 )
 
 var (
@@ -23,6 +27,16 @@ var (
 	ErrNoDataSource   = fmt.Errorf("QLBridge.plan:  No datasource found")
 	ErrNoPlan         = fmt.Errorf("No Plan")
 
+	// TopNLimit largest LIMIT (plus OFFSET) of an ORDER BY that keeps only
+	// its top rows in a bounded heap rather than sorting all rows
+	TopNLimit = 10000
+
+	// SourceHealthErr the error of the health check of an unhealthy source,
+	// nil if healthy (or not checked).  Queries of unhealthy sources fail
+	// planning fast instead of waiting on the source to time out.  Set by
+	// the datasource registry, see schema.HealthChecker
+	SourceHealthErr func(ds schema.Source) error
+
 	// Force Plans to implement Task
 	_ Task = (*PreparedStatement)(nil)
 	_ Task = (*Select)(nil)
@@ -31,6 +45,7 @@ var (
 	_ Task = (*Update)(nil)
 	_ Task = (*Delete)(nil)
 	_ Task = (*Command)(nil)
+	_ Task = (*Create)(nil)
 	_ Task = (*Projection)(nil)
 	_ Task = (*Source)(nil)
 	_ Task = (*Into)(nil)
@@ -38,8 +53,14 @@ var (
 	_ Task = (*Having)(nil)
 	_ Task = (*GroupBy)(nil)
 	_ Task = (*Order)(nil)
+	_ Task = (*Window)(nil)
+	_ Task = (*Distinct)(nil)
+	_ Task = (*SetOperation)(nil)
 	_ Task = (*JoinMerge)(nil)
 	_ Task = (*JoinKey)(nil)
+	_ Task = (*Exchange)(nil)
+	_ Task = (*Fragment)(nil)
+	_ Task = (*SemiJoin)(nil)
 
 	// Force any plan that participates in a Select to implement Proto
 	//  which allows us to serialize and distribute to multiple nodes.
@@ -92,6 +113,8 @@ type (
 		WalkUpdate(p *Update) error
 		WalkDelete(p *Delete) error
 		WalkCommand(p *Command) error
+		WalkCreate(p *Create) error
+		WalkAnalyze(p *Analyze) error
 		WalkInto(p *Into) error
 
 		WalkSourceSelect(p *Source) error
@@ -106,6 +129,41 @@ type (
 		// given our request statement, turn that into a plan.Task.
 		WalkSourceSelect(pl Planner, s *Source) (Task, error)
 	}
+
+	// SourcePartialAggregator sources that can compute the partial aggregates
+	//  of a group by themselves (ie, per shard) so only the final merge of the
+	//  partials is run in-process.  If accepted, the source returns a row per
+	//  group of the partial values of each column (see GroupBy Partial)
+	//  followed by the group key.
+	SourcePartialAggregator interface {
+		WalkPartialAggregate(s *Source, gb *GroupBy) (bool, error)
+	}
+
+	// SourceLimiter sources that can stop their scan after the first rows,
+	//  offered the LIMIT (plus OFFSET) of a select of a single source when
+	//  only its where is evaluated before the limit.  A source should only
+	//  accept if it evaluates all of the where itself (see translate), as the
+	//  in-process where would otherwise drop rows after the source limited.
+	SourceLimiter interface {
+		PushLimit(s *Source, limit int) bool
+	}
+
+	// SourceOrderer sources that can return the rows of their scan in the
+	//  order of an ORDER BY of their columns, offered the ORDER BY of a select
+	//  of a single source when nothing but its where (which keeps the order)
+	//  is evaluated before the order.  Returns whether accepted, then the
+	//  rows are not sorted again.
+	SourceOrderer interface {
+		PushOrder(s *Source, order rel.Columns) bool
+	}
+
+	// SourceIndexTranslator sources that translate wheres into seeks of
+	//  their indexes (see translate), of the index of a USE_INDEX hint
+	//  instead of the one they would choose.  Errors if the where has no
+	//  predicate the index can seek.
+	SourceIndexTranslator interface {
+		TranslateIndex(node expr.Node, index string) (interface{}, error)
+	}
 )
 
 type (
@@ -128,18 +186,28 @@ type (
 	}
 	Insert struct {
 		*PlanBase
-		Stmt   *rel.SqlInsert
-		Source schema.ConnUpsert
+		Stmt      *rel.SqlInsert
+		Source    schema.ConnUpsert
+		Select    *Select       // INSERT INTO ... SELECT, the rows to insert
+		Positions []int         // position in table row of each value, nil if in table column order
+		Width     int           // columns of table row, if Positions
+		Table     *schema.Table // table written, if it has defaults or constraints of its columns
 	}
 	Upsert struct {
 		*PlanBase
-		Stmt   *rel.SqlUpsert
-		Source schema.ConnUpsert
+		Stmt      *rel.SqlUpsert
+		Source    schema.ConnUpsert
+		Positions []int         // position in table row of each value, nil if in table column order
+		Width     int           // columns of table row, if Positions
+		Table     *schema.Table // table written, if it has defaults or constraints of its columns
 	}
 	Update struct {
 		*PlanBase
 		Stmt   *rel.SqlUpdate
 		Source schema.ConnUpsert
+		Patch  schema.ConnPatchWhere // if the source can update by where expression
+		Key    schema.Key            // key from WHERE for sources that can't patch
+		Table  *schema.Table         // table written, if it has defaults or constraints of its columns
 	}
 	Delete struct {
 		*PlanBase
@@ -151,6 +219,22 @@ type (
 		Ctx  *Context
 		Stmt *rel.SqlCommand
 	}
+	// Create CREATE TABLE of the Table of its columns, in Source
+	Create struct {
+		*PlanBase
+		Ctx    *Context
+		Stmt   *rel.SqlCreate
+		Table  *schema.Table
+		Source *schema.SchemaSource
+		Exists bool // exists and IF NOT EXISTS, nothing to create
+	}
+	// Analyze ANALYZE TABLE, computing the stats of the columns of Table
+	Analyze struct {
+		*PlanBase
+		Ctx   *Context
+		Stmt  *rel.SqlAnalyze
+		Table *schema.Table
+	}
 
 	// Projection holds original query for column info and schema/field types
 	Projection struct {
@@ -182,6 +266,17 @@ type (
 		Tbl          *schema.Table        // Table schema for this From
 		Static       []driver.Value       // this is static data source
 		Cols         []string
+		IndexHint    string // Forced index for this source from USE_INDEX hint
+		// Native the where pushed down to this source translated to the source's
+		// own query form (sql string, filter document), see expr/translate
+		Native interface{}
+		// Partitions of a schema.SourcePartitionable source scanned in parallel
+		// by ScanWorkers workers instead of a single scan of the whole table.
+		Partitions  []*schema.Partition
+		ScanWorkers int
+		// Computed the computed columns of Tbl the scan of this source adds
+		// to its rows, as its statement refers to them
+		Computed []*schema.Field
 	}
 	// Select INTO table
 	Into struct {
@@ -191,13 +286,42 @@ type (
 	GroupBy struct {
 		*PlanBase
 		Stmt    *rel.SqlSelect
-		Partial bool
+		Partial bool // emit partial aggregates to be merged by a Final groupby
+		Final   bool // merge partial aggregates from parallel fragments
 	}
 	// Order By clause
 	Order struct {
+		*PlanBase
+		Stmt  *rel.SqlSelect
+		Limit int // > 0 only the first Limit rows are needed, a top-n
+	}
+	// Window evaluates the window function columns of a select, each row
+	// is passed on with its window function values added
+	Window struct {
 		*PlanBase
 		Stmt *rel.SqlSelect
 	}
+	// Distinct removes duplicate rows of a SELECT DISTINCT, rows are
+	// duplicates if the values of the selected columns are equal
+	Distinct struct {
+		*PlanBase
+		Stmt *rel.SqlSelect
+	}
+	// SetOperation combines the rows of its two inputs, planned selects or
+	// other set operations, by UNION, INTERSECT or EXCEPT.  Without ALL
+	// duplicate rows are removed.  Rows are aligned on position, and named
+	// by the Columns of the first select.
+	SetOperation struct {
+		*PlanBase
+		Op      lex.TokenType // TokenUnion, TokenIntersect, TokenExcept
+		All     bool
+		Left    Task
+		Right   Task
+		Columns []string
+		// Types column types values are converted to so equal values of
+		// different input types are equal, UnknownType to leave as is
+		Types []value.ValueType
+	}
 	// Where, pre-aggregation filter
 	Where struct {
 		*PlanBase
@@ -217,16 +341,59 @@ type (
 		LeftFrom  *rel.SqlSource
 		RightFrom *rel.SqlSource
 		ColIndex  map[string]int
+		Algorithm string // Join algorithm, empty for executor default
+		// BuildLeft build the hash table of a hash join of the left input,
+		// estimated smaller than the right (see Source.EstimatedRows)
+		BuildLeft bool
+
+		// Partitions both inputs are partitioned on the join key by this same
+		// scheme, so each partition is joined independently (colocated).
+		Partitions []*schema.Partition
+		// Repartition hash-partition both inputs on the join key into this many
+		// partitions before joining, 0 for a single join of all rows.
+		Repartition int
 	}
 	JoinKey struct {
 		*PlanBase
 		Source *Source
 	}
+	// Exchange splits its input across N parallel fragments, and merges
+	// their output back into a single stream
+	Exchange struct {
+		*PlanBase
+		Fragments []*Fragment
+	}
+	// Fragment is one of N identical sequential pipelines of tasks (where,
+	// partial groupby) run in parallel under an Exchange
+	Fragment struct {
+		*PlanBase
+		Id int
+	}
+	// SemiJoin filters its input to the rows that have (or for Anti, do not
+	// have) a match in a sub-query, for WHERE [NOT] IN (SELECT ...) and
+	// WHERE [NOT] EXISTS (SELECT ...).  Unlike a join, each input row is
+	// emitted at most once, and no columns of the sub-query are projected.
+	SemiJoin struct {
+		*PlanBase
+		Stmt   *rel.SqlSelect // outer statement
+		Anti   bool           // NOT IN, NOT EXISTS
+		In     bool           // IN semantics for NULL, vs EXISTS
+		Single bool           // = sub-query, of at most one row
+		Lhs    expr.Node      // outer key, nil for un-correlated EXISTS
+		Rhs    expr.Node      // sub-query key, the single column it selects
+		Sub    *Select        // the planned sub-query
+	}
 )
 
 // Walk given statement for given Planner to produce a query plan
 //  which is a plan.Task and children, ie a DAG of tasks
 func WalkStmt(ctx *Context, stmt rel.SqlStatement, planner Planner) (Task, error) {
+	if ctx != nil {
+		start := time.Now()
+		defer func() {
+			ctx.metrics().Total = time.Since(start)
+		}()
+	}
 	var p Task
 	base := NewPlanBase(false)
 	switch st := stmt.(type) {
@@ -250,6 +417,17 @@ func WalkStmt(ctx *Context, stmt rel.SqlStatement, planner Planner) (Task, error
 		ctx.Stmt = sel
 		p = &Select{Stmt: sel, PlanBase: base, Ctx: ctx}
 	case *rel.SqlDescribe:
+		if st.Stmt != nil {
+			// EXPLAIN SELECT ...   plan the statement, the plan is
+			// read back out of context by the explain info-schema table
+			ctx.Stmt = st.Stmt
+			explained, err := WalkStmt(ctx, st.Stmt, planner)
+			if err != nil {
+				return nil, err
+			}
+			ctx.Explain = explained
+			ctx.Projection = nil
+		}
 		sel, err := RewriteDescribeAsSelect(st, ctx)
 		if err != nil {
 			return nil, err
@@ -258,6 +436,10 @@ func WalkStmt(ctx *Context, stmt rel.SqlStatement, planner Planner) (Task, error
 		p = &Select{Stmt: sel, PlanBase: base}
 	case *rel.SqlCommand:
 		p = &Command{Stmt: st, PlanBase: base, Ctx: ctx}
+	case *rel.SqlCreate:
+		p = &Create{Stmt: st, PlanBase: base, Ctx: ctx}
+	case *rel.SqlAnalyze:
+		p = &Analyze{Stmt: st, PlanBase: base, Ctx: ctx}
 	default:
 		panic(fmt.Sprintf("Not implemented for %T", stmt))
 	}
@@ -358,6 +540,8 @@ func (m *Upsert) Walk(p Planner) error            { return p.WalkUpsert(m) }
 func (m *Update) Walk(p Planner) error            { return p.WalkUpdate(m) }
 func (m *Delete) Walk(p Planner) error            { return p.WalkDelete(m) }
 func (m *Command) Walk(p Planner) error           { return p.WalkCommand(m) }
+func (m *Create) Walk(p Planner) error            { return p.WalkCreate(m) }
+func (m *Analyze) Walk(p Planner) error           { return p.WalkAnalyze(m) }
 func (m *Source) Walk(p Planner) error            { return p.WalkSourceSelect(m) }
 
 func (m *Select) Marshal() ([]byte, error) {
@@ -591,7 +775,10 @@ func (m *Source) LoadConn() error {
 			return nil
 		}
 	}
-	source, err := m.DataSource.Open(m.Stmt.SourceName())
+	name := m.Stmt.SourceName()
+	source, err := OpenRetry(m.ctx, nil, name, func() (schema.Conn, error) {
+		return m.DataSource.Open(name)
+	})
 	if err != nil {
 		u.Debugf("no source? %T for source %q", m.DataSource, m.Stmt.SourceName())
 		return err
@@ -683,7 +870,8 @@ func (m *Source) load() error {
 	if m.Stmt == nil {
 		return nil
 	}
-	fromName := strings.ToLower(m.Stmt.SourceName())
+	// matched to the tables of the schema by its IdentifierCase
+	fromName := qualifiedName(m.Stmt)
 	if m.ctx == nil {
 		return fmt.Errorf("missing context in Source")
 	}
@@ -701,6 +889,11 @@ func (m *Source) load() error {
 		return fmt.Errorf("Could not find source for %v", m.Stmt.SourceName())
 	}
 	m.SchemaSource = ss
+	if SourceHealthErr != nil && ss.DS != nil {
+		if err := SourceHealthErr(ss.DS); err != nil {
+			return fmt.Errorf("source %q of %q is unhealthy: %v", ss.Name, fromName, err)
+		}
+	}
 	// Create a context-datasource
 	m.DataSource = ss.DS
 
@@ -715,6 +908,7 @@ func (m *Source) load() error {
 		return fmt.Errorf("No table found for %q", fromName)
 	}
 	m.Tbl = tbl
+	m.Computed = computedOf(tbl, m.Stmt)
 
 	return projectionForSourcePlan(m)
 }
@@ -816,9 +1010,61 @@ func NewHaving(stmt *rel.SqlSelect) *Having {
 func NewGroupBy(stmt *rel.SqlSelect) *GroupBy {
 	return &GroupBy{Stmt: stmt, PlanBase: NewPlanBase(false)}
 }
+func NewGroupByPartial(stmt *rel.SqlSelect) *GroupBy {
+	return &GroupBy{Stmt: stmt, Partial: true, PlanBase: NewPlanBase(false)}
+}
+func NewGroupByFinal(stmt *rel.SqlSelect) *GroupBy {
+	return &GroupBy{Stmt: stmt, Final: true, PlanBase: NewPlanBase(false)}
+}
+
+// An exchange of n parallel fragments
+//
+//                 -> fragment 0 ->
+//   source  ->   -> fragment 1 ->    -->
+//                 -> fragment n ->
+//
+func NewExchange(n int) *Exchange {
+	m := &Exchange{
+		PlanBase:  NewPlanBase(false),
+		Fragments: make([]*Fragment, n),
+	}
+	m.SetParallel()
+	for i := range m.Fragments {
+		m.Fragments[i] = &Fragment{Id: i, PlanBase: NewPlanBase(false)}
+	}
+	return m
+}
+
+func NewSetOperation(op *rel.SqlSetOp, left, right Task) *SetOperation {
+	return &SetOperation{
+		PlanBase: NewPlanBase(false),
+		Op:       op.Op,
+		All:      op.All,
+		Left:     left,
+		Right:    right,
+	}
+}
+
+// NewSemiJoin create a semi-join (or anti-join) of outer statement
+// against the planned sub-query.
+func NewSemiJoin(stmt *rel.SqlSelect, sub *Select, lhs, rhs expr.Node) *SemiJoin {
+	return &SemiJoin{
+		PlanBase: NewPlanBase(false),
+		Stmt:     stmt,
+		Sub:      sub,
+		Lhs:      lhs,
+		Rhs:      rhs,
+	}
+}
 func NewOrder(stmt *rel.SqlSelect) *Order {
 	return &Order{Stmt: stmt, PlanBase: NewPlanBase(false)}
 }
+func NewWindow(stmt *rel.SqlSelect) *Window {
+	return &Window{Stmt: stmt, PlanBase: NewPlanBase(false)}
+}
+func NewDistinct(stmt *rel.SqlSelect) *Distinct {
+	return &Distinct{Stmt: stmt, PlanBase: NewPlanBase(false)}
+}
 
 func (m *Into) Equal(t Task) bool {
 	if m == nil && t == nil {
@@ -975,7 +1221,7 @@ func (m *Order) Equal(t Task) bool {
 	if !m.PlanBase.EqualBase(s.PlanBase) {
 		return false
 	}
-	return true
+	return m.Limit == s.Limit
 }
 func OrderFromPB(pb *PlanPb) *Order {
 	m := Order{
@@ -985,6 +1231,48 @@ func OrderFromPB(pb *PlanPb) *Order {
 	return &m
 }
 
+func (m *Window) Equal(t Task) bool {
+	if m == nil && t == nil {
+		return true
+	}
+	if m == nil && t != nil {
+		return false
+	}
+	if m != nil && t == nil {
+		return false
+	}
+	s, ok := t.(*Window)
+	if !ok {
+		return false
+	}
+
+	if !m.PlanBase.EqualBase(s.PlanBase) {
+		return false
+	}
+	return m.Stmt.Equal(s.Stmt)
+}
+
+func (m *Distinct) Equal(t Task) bool {
+	if m == nil && t == nil {
+		return true
+	}
+	if m == nil && t != nil {
+		return false
+	}
+	if m != nil && t == nil {
+		return false
+	}
+	s, ok := t.(*Distinct)
+	if !ok {
+		return false
+	}
+
+	if !m.PlanBase.EqualBase(s.PlanBase) {
+		return false
+	}
+	return m.Stmt.Equal(s.Stmt)
+}
+
 func (m *JoinMerge) Equal(t Task) bool {
 	if m == nil && t == nil {
 		return true
@@ -1003,8 +1291,148 @@ func (m *JoinMerge) Equal(t Task) bool {
 	if !m.PlanBase.EqualBase(s.PlanBase) {
 		return false
 	}
+	if m.Repartition != s.Repartition || len(m.Partitions) != len(s.Partitions) {
+		return false
+	}
+	if m.Algorithm != s.Algorithm || m.BuildLeft != s.BuildLeft {
+		return false
+	}
+	return true
+}
+
+// Preserved which sides of an outer join keep their rows that have no match:
+// LEFT [OUTER] JOIN the left, RIGHT [OUTER] JOIN the right and OUTER JOIN both.
+func (m *JoinMerge) Preserved() (left, right bool) {
+	if m.RightFrom == nil {
+		return false, false
+	}
+	switch m.RightFrom.LeftOrRight {
+	case lex.TokenLeft:
+		return true, false
+	case lex.TokenRight:
+		return false, true
+	}
+	if m.RightFrom.JoinType == lex.TokenOuter {
+		return true, true
+	}
+	return false, false
+}
+func (m *Exchange) Equal(t Task) bool {
+	if m == nil && t == nil {
+		return true
+	}
+	if m == nil && t != nil {
+		return false
+	}
+	if m != nil && t == nil {
+		return false
+	}
+	s, ok := t.(*Exchange)
+	if !ok {
+		return false
+	}
+
+	if !m.PlanBase.EqualBase(s.PlanBase) {
+		return false
+	}
+	if len(m.Fragments) != len(s.Fragments) {
+		return false
+	}
 	return true
 }
+func (m *Fragment) Equal(t Task) bool {
+	if m == nil && t == nil {
+		return true
+	}
+	if m == nil && t != nil {
+		return false
+	}
+	if m != nil && t == nil {
+		return false
+	}
+	s, ok := t.(*Fragment)
+	if !ok {
+		return false
+	}
+
+	if !m.PlanBase.EqualBase(s.PlanBase) {
+		return false
+	}
+	return m.Id == s.Id
+}
+func (m *SemiJoin) Equal(t Task) bool {
+	if m == nil && t == nil {
+		return true
+	}
+	if m == nil && t != nil {
+		return false
+	}
+	if m != nil && t == nil {
+		return false
+	}
+	s, ok := t.(*SemiJoin)
+	if !ok {
+		return false
+	}
+
+	if !m.PlanBase.EqualBase(s.PlanBase) {
+		return false
+	}
+	if m.Anti != s.Anti || m.In != s.In || m.Single != s.Single {
+		return false
+	}
+	if (m.Lhs == nil) != (s.Lhs == nil) || m.Lhs != nil && !m.Lhs.Equal(s.Lhs) {
+		return false
+	}
+	if (m.Rhs == nil) != (s.Rhs == nil) || m.Rhs != nil && !m.Rhs.Equal(s.Rhs) {
+		return false
+	}
+	if !m.Stmt.Equal(s.Stmt) {
+		return false
+	}
+	return m.Sub.Equal(s.Sub)
+}
+func (m *SetOperation) Equal(t Task) bool {
+	if m == nil && t == nil {
+		return true
+	}
+	if m == nil && t != nil {
+		return false
+	}
+	if m != nil && t == nil {
+		return false
+	}
+	s, ok := t.(*SetOperation)
+	if !ok {
+		return false
+	}
+
+	if !m.PlanBase.EqualBase(s.PlanBase) {
+		return false
+	}
+	if m.Op != s.Op || m.All != s.All {
+		return false
+	}
+	if len(m.Columns) != len(s.Columns) || len(m.Types) != len(s.Types) {
+		return false
+	}
+	for i, col := range m.Columns {
+		if col != s.Columns[i] {
+			return false
+		}
+	}
+	for i, vt := range m.Types {
+		if vt != s.Types[i] {
+			return false
+		}
+	}
+	left, ok := m.Left.(SelectTask)
+	if !ok || !left.Equal(s.Left) {
+		return false
+	}
+	right, ok := m.Right.(SelectTask)
+	return ok && right.Equal(s.Right)
+}
 func (m *JoinKey) Equal(t Task) bool {
 	if m == nil && t == nil {
 		return true
@@ -0,0 +1,173 @@
+package plan
+
+import (
+	"fmt"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	// DataTypes the value types of the data types of the columns of
+	// CREATE TABLE, by lower cased name
+	DataTypes = map[string]value.ValueType{
+		"int":        value.IntType,
+		"integer":    value.IntType,
+		"tinyint":    value.IntType,
+		"smallint":   value.IntType,
+		"mediumint":  value.IntType,
+		"bigint":     value.IntType,
+		"float":      value.NumberType,
+		"double":     value.NumberType,
+		"real":       value.NumberType,
+		"decimal":    value.NumberType,
+		"numeric":    value.NumberType,
+		"bool":       value.BoolType,
+		"boolean":    value.BoolType,
+		"char":       value.StringType,
+		"varchar":    value.StringType,
+		"text":       value.StringType,
+		"tinytext":   value.StringType,
+		"mediumtext": value.StringType,
+		"longtext":   value.StringType,
+		"string":     value.StringType,
+		"date":       value.TimeType,
+		"datetime":   value.TimeType,
+		"timestamp":  value.TimeType,
+		"time":       value.TimeType,
+		"binary":     value.ByteSliceType,
+		"varbinary":  value.ByteSliceType,
+		"blob":       value.ByteSliceType,
+		"bytes":      value.ByteSliceType,
+		"json":       value.JsonType,
+	}
+)
+
+// WalkCreate the Table of the columns of a CREATE TABLE, and the source of
+// the schema it is created in: that of the "source" of its WITH, else the
+// only source of the schema, else its only source that creates tables
+// (see schema.TableCreator).  Its foreign keys must reference tables of the
+// schema.
+//
+//   CREATE TABLE users (id bigint PRIMARY KEY, name varchar(255)) WITH {"source":"mem"}
+func (m *PlannerDefault) WalkCreate(p *Create) error {
+	u.Debugf("VisitCreate %+v", p.Stmt)
+	if m.Ctx.Schema == nil {
+		return ErrNoDataSource
+	}
+	if _, err := m.Ctx.Schema.Source(p.Stmt.Identity); err == nil {
+		if !p.Stmt.IfNotExists {
+			return fmt.Errorf("table %q already exists", p.Stmt.Identity)
+		}
+		p.Exists = true
+		return nil
+	}
+	tbl, err := TableOfCreate(p.Stmt)
+	if err != nil {
+		return err
+	}
+	for _, r := range tbl.Relations {
+		ref := tbl
+		if r.RefTable != tbl.Name {
+			if ref, err = m.Ctx.Schema.Table(r.RefTable); err != nil {
+				return fmt.Errorf("foreign key of %q references unknown table %q", tbl.Name, r.RefTable)
+			}
+		}
+		for _, col := range r.RefColumns {
+			if len(ref.Fields) > 0 && !ref.HasField(col) {
+				return fmt.Errorf("foreign key of %q references unknown column %q of %q", tbl.Name, col, r.RefTable)
+			}
+		}
+	}
+	ss, err := createSource(m.Ctx.Schema, p.Stmt.With.String("source"))
+	if err != nil {
+		return err
+	}
+	p.Table, p.Source = tbl, ss
+	return nil
+}
+
+// WalkAnalyze the Table of an ANALYZE TABLE
+func (m *PlannerDefault) WalkAnalyze(p *Analyze) error {
+	u.Debugf("VisitAnalyze %+v", p.Stmt)
+	if m.Ctx.Schema == nil {
+		return ErrNoDataSource
+	}
+	tbl, err := m.Ctx.Schema.Table(p.Stmt.Identity)
+	if err != nil {
+		return fmt.Errorf("could not find table %q to analyze: %v", p.Stmt.Identity, err)
+	}
+	p.Table = tbl
+	return nil
+}
+
+// TableOfCreate the Table of the column definitions of stmt
+func TableOfCreate(stmt *rel.SqlCreate) (*schema.Table, error) {
+	tbl := schema.NewTable(stmt.Identity)
+	cols := make([]string, 0, len(stmt.Cols))
+	for _, col := range stmt.Cols {
+		vt, ok := DataTypes[col.DataType]
+		if !ok {
+			return nil, fmt.Errorf("unsupported data type %q of column %q", col.DataType, col.Name)
+		}
+		if tbl.HasField(col.Name) {
+			return nil, fmt.Errorf("duplicate column %q", col.Name)
+		}
+		size := 0
+		if len(col.Size) > 0 {
+			size = col.Size[0]
+		}
+		f := schema.NewFieldBase(col.Name, vt, size, "")
+		f.NoNulls = col.NoNulls
+		if col.Default != nil {
+			f.DefaultValue = col.Default.Value()
+		}
+		tbl.AddField(f)
+		cols = append(cols, col.Name)
+	}
+	tbl.SetColumns(cols)
+	if len(stmt.PrimaryKey) > 0 {
+		for _, name := range stmt.PrimaryKey {
+			f, ok := tbl.FieldMap[name]
+			if !ok {
+				return nil, fmt.Errorf("primary key %q is not a column of %q", name, stmt.Identity)
+			}
+			f.NoNulls, f.Indexed = true, true
+		}
+		tbl.Indexes = append(tbl.Indexes, &schema.Index{Name: "primary", Fields: stmt.PrimaryKey, PrimaryKey: true})
+	}
+	for _, fk := range stmt.ForeignKeys {
+		r := &schema.Relation{Columns: fk.Columns, RefTable: fk.RefTable, RefColumns: fk.RefColumns}
+		if err := tbl.AddRelation(r); err != nil {
+			return nil, err
+		}
+	}
+	return tbl, nil
+}
+
+// createSource the source of s to create a table in, of name if given
+func createSource(s *schema.Schema, name string) (*schema.SchemaSource, error) {
+	if name != "" {
+		return s.SchemaSource(name)
+	}
+	sources := s.SchemaSources()
+	if len(sources) == 1 {
+		return sources[0], nil
+	}
+	var creator *schema.SchemaSource
+	for _, ss := range sources {
+		if _, ok := ss.DS.(schema.TableCreator); ok {
+			if creator != nil {
+				return nil, fmt.Errorf("schema %q has many sources to create tables in, choose one WITH {\"source\":\"name\"}", s.Name)
+			}
+			creator = ss
+		}
+	}
+	if creator == nil {
+		return nil, fmt.Errorf("schema %q has no source to create tables in", s.Name)
+	}
+	return creator, nil
+}
@@ -2,9 +2,13 @@ package plan
 
 import (
 	"fmt"
+	"strings"
 
 	u "github.com/araddon/gou"
 
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/rel"
 	"github.com/araddon/qlbridge/schema"
 )
 
@@ -17,7 +21,18 @@ func (m *PlannerDefault) WalkInto(p *Into) error {
 	return ErrNotImplemented
 }
 
-func upsertSource(ctx *Context, table string) (schema.ConnUpsert, error) {
+// mutationConn open the connection for the source that owns table,
+// preferring a Mutator connection specific to this request if
+// the source supports it.  Callers assert the mutation interface they need.
+func mutationConn(ctx *Context, table string) (interface{}, error) {
+
+	if ctx.Schema == nil {
+		return nil, ErrNoDataSource
+	}
+	// `schema`.`table`  we only write to tables in the current schema
+	if _, right, hasLeft := expr.LeftRight(table); hasLeft {
+		table = right
+	}
 
 	conn, err := ctx.Schema.Open(table)
 	if err != nil {
@@ -25,24 +40,91 @@ func upsertSource(ctx *Context, table string) (schema.ConnUpsert, error) {
 		return nil, err
 	}
 
+	// writes of a transaction are of the transaction of the table
+	if ctx.Tx != nil {
+		return ctx.Tx.mutator(table, conn)
+	}
+
 	mutatorSource, hasMutator := conn.(schema.ConnMutation)
 	if hasMutator {
 		mutator, err := mutatorSource.CreateMutator(ctx)
 		if err != nil {
 			u.Warnf("%p could not create mutator for %q err=%v", ctx.Schema, table, err)
-			//return nil, err
 		} else {
 			return mutator, nil
 		}
 	}
+	return conn, nil
+}
 
+func upsertSource(ctx *Context, table string) (schema.ConnUpsert, error) {
+	conn, err := mutationConn(ctx, table)
+	if err != nil {
+		return nil, err
+	}
 	upsertDs, isUpsert := conn.(schema.ConnUpsert)
-	if !isUpsert {
+	if !isUpsert || !CapabilitiesOf(conn).Writes {
 		return nil, fmt.Errorf("%T does not implement required schema.Upsert for upserts", conn)
 	}
 	return upsertDs, nil
 }
 
+// columnPositions the position in a row of table of each column named by an
+// insert, so values are put in table column order.  Nil if they already are,
+// or the table is not known to the schema, then values are put as given.
+//
+//   INSERT INTO t (event, id) ...    table t (id, user_id, event)
+//   positions = [2, 0]  width = 3
+func columnPositions(ctx *Context, table string, cols rel.Columns) ([]int, int, error) {
+	if len(cols) == 0 || ctx.Schema == nil {
+		return nil, 0, nil
+	}
+	if _, right, hasLeft := expr.LeftRight(table); hasLeft {
+		table = right
+	}
+	tbl, err := ctx.Schema.Table(table)
+	if err != nil || tbl == nil || len(tbl.Columns()) == 0 {
+		return nil, 0, nil
+	}
+	tableCols := tbl.Columns()
+	positions := make([]int, len(cols))
+	inOrder := len(cols) == len(tableCols)
+	for i, col := range cols {
+		pos, ok := tbl.FieldPositions[col.As]
+		if !ok {
+			pos, ok = tbl.FieldPositions[strings.ToLower(col.As)]
+		}
+		if !ok {
+			return nil, 0, fmt.Errorf("insert into %q unknown column %q", table, col.As)
+		}
+		positions[i] = pos
+		if pos != i {
+			inOrder = false
+		}
+	}
+	if inOrder {
+		return nil, 0, nil
+	}
+	return positions, len(tableCols), nil
+}
+
+// constrainedTable the table written by a mutation of table, if it has
+// defaults or constraints of its columns (see schema.Table.WriteRow), nil
+// if not or not known to the schema
+func constrainedTable(ctx *Context, table string) *schema.Table {
+	if ctx.Schema == nil {
+		return nil
+	}
+	if _, right, hasLeft := expr.LeftRight(table); hasLeft {
+		table = right
+	}
+	tbl, err := ctx.Schema.Table(table)
+	if err != nil || tbl == nil || !tbl.HasConstraints() {
+		return nil
+	}
+	return tbl
+}
+
 func (m *PlannerDefault) WalkInsert(p *Insert) error {
 	u.Debugf("VisitInsert %s", p.Stmt)
 	src, err := upsertSource(m.Ctx, p.Stmt.Table)
@@ -50,6 +132,30 @@ func (m *PlannerDefault) WalkInsert(p *Insert) error {
 		return err
 	}
 	p.Source = src
+	p.Positions, p.Width, err = columnPositions(m.Ctx, p.Stmt.Table, p.Stmt.Columns)
+	if err != nil {
+		return err
+	}
+	p.Table = constrainedTable(m.Ctx, p.Stmt.Table)
+
+	if p.Stmt.Select == nil {
+		return nil
+	}
+
+	// INSERT INTO table SELECT ...   plan the select whose output are the rows
+	sel := &Select{Stmt: p.Stmt.Select, PlanBase: NewPlanBase(false), Ctx: m.Ctx}
+	if err := m.Planner.WalkSelect(sel); err != nil {
+		return err
+	}
+	if len(p.Stmt.Columns) > 0 && m.Ctx.Projection != nil && m.Ctx.Projection.Proj != nil {
+		if ct := len(m.Ctx.Projection.Proj.Columns); ct != len(p.Stmt.Columns) {
+			return fmt.Errorf("insert into %q has %d columns but select has %d", p.Stmt.Table, len(p.Stmt.Columns), ct)
+		}
+	}
+	// the projection is of the insert result, not the select
+	m.Ctx.Projection = nil
+	p.Select = sel
+	p.Add(sel)
 	return nil
 }
 
@@ -60,6 +166,21 @@ func (m *PlannerDefault) WalkUpdate(p *Update) error {
 		return err
 	}
 	p.Source = src
+	p.Table = constrainedTable(m.Ctx, p.Stmt.Table)
+
+	// if our backend source supports Where-Patches, ie update multiple
+	if patch, ok := src.(schema.ConnPatchWhere); ok && CapabilitiesOf(src).PatchWhere {
+		p.Patch = patch
+		return nil
+	}
+
+	// Otherwise we can only update a single row identified by key
+	if p.Stmt.Where != nil {
+		p.Key = KeyFromWhere(p.Stmt.Where)
+	}
+	if p.Key == nil {
+		return fmt.Errorf("update of %q requires WHERE of form key = value for %T", p.Stmt.Table, src)
+	}
 	return nil
 }
 
@@ -70,33 +191,64 @@ func (m *PlannerDefault) WalkUpsert(p *Upsert) error {
 		return err
 	}
 	p.Source = src
-	return nil
+	p.Positions, p.Width, err = columnPositions(m.Ctx, p.Stmt.Table, p.Stmt.Columns)
+	p.Table = constrainedTable(m.Ctx, p.Stmt.Table)
+	return err
 }
 
 func (m *PlannerDefault) WalkDelete(p *Delete) error {
 	u.Debugf("VisitDelete %+v", p.Stmt)
-	conn, err := m.Ctx.Schema.Open(p.Stmt.Table)
+	conn, err := mutationConn(m.Ctx, p.Stmt.Table)
 	if err != nil {
-		u.Warnf("%p no schema for %q err=%v", m.Ctx.Schema, p.Stmt.Table, err)
 		return err
 	}
-
-	mutatorSource, hasMutator := conn.(schema.ConnMutation)
-	if hasMutator {
-		mutator, err := mutatorSource.CreateMutator(m.Ctx)
-		if err != nil {
-			u.Warnf("%p could not create mutator for %q err=%v", m.Ctx.Schema, p.Stmt.Table, err)
-			//return nil, err
-		} else {
-			p.Source = mutator
-			return nil
-		}
-	}
-
 	deleteDs, isDelete := conn.(schema.ConnDeletion)
-	if !isDelete {
+	if !isDelete || !CapabilitiesOf(conn).Deletes {
 		return fmt.Errorf("%T does not implement required schema.Deletion for deletions", conn)
 	}
 	p.Source = deleteDs
 	return nil
 }
+
+// KeyFromWhere Given a Where expression, lets try to create a key which
+//  requires form    `idenity = "value"`
+//
+func KeyFromWhere(wh interface{}) schema.Key {
+	switch n := wh.(type) {
+	case *rel.SqlWhere:
+		if n == nil {
+			return nil
+		}
+		return KeyFromWhere(n.Expr)
+	case *expr.BinaryNode:
+		switch n.Operator.T {
+		case lex.TokenEqual, lex.TokenEqualEqual:
+		default:
+			return nil
+		}
+		if len(n.Args) != 2 {
+			u.Warnf("need more args? %#v", n.Args)
+			return nil
+		}
+		in, ok := n.Args[0].(*expr.IdentityNode)
+		if !ok {
+			u.Warnf("not identity? %T", n.Args[0])
+			return nil
+		}
+		// This only allows for    identity = value
+		// NOT:      identity = expr(identity, arg)
+		//
+		switch valT := n.Args[1].(type) {
+		case *expr.NumberNode:
+			return schema.NewKeyCol(in.Text, valT.Float64)
+		case *expr.StringNode:
+			return schema.NewKeyCol(in.Text, valT.Text)
+		//case *expr.FuncNode:
+		default:
+			u.Warnf("not supported arg? %#v", valT)
+		}
+	default:
+		u.Warnf("not supported node type? %#v", n)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	td "github.com/araddon/qlbridge/datasource/mockcsvtestdata"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+)
+
+type mutateTest struct {
+	q     string
+	key   schema.Key // expected update key
+	isErr bool
+}
+
+var mutateTests = []mutateTest{
+	// the source patches the rows of the where, no key needed
+	{`UPDATE users SET email = "x" WHERE user_id = "abc"`, nil, false},
+	{"UPDATE `mockcsv`.`users` SET email = \"x\" WHERE referral_count = 12", nil, false},
+	{`UPDATE users SET email = "x" WHERE referral_count > 12`, nil, false},
+	{`UPDATE users SET email = "x"`, nil, false},
+	{`UPDATE not_a_table SET email = "x"`, nil, true},
+	{`INSERT INTO users (user_id, email) VALUES ("abc", "x")`, nil, false},
+	{`INSERT INTO users (user_id, email) SELECT user_id, email FROM users`, nil, false},
+	{`DELETE FROM users WHERE user_id = "abc"`, nil, false},
+	{`DELETE FROM not_a_table WHERE user_id = "abc"`, nil, true},
+}
+
+func TestKeyFromWhere(t *testing.T) {
+	tests := []struct {
+		where string
+		key   schema.Key
+	}{
+		{`SELECT a FROM t WHERE user_id = "abc"`, schema.NewKeyCol("user_id", "abc")},
+		{`SELECT a FROM t WHERE referral_count = 12`, schema.NewKeyCol("referral_count", float64(12))},
+		// only of the form identity = value
+		{`SELECT a FROM t WHERE referral_count > 12`, nil},
+	}
+	for _, tt := range tests {
+		stmt, err := rel.ParseSqlSelect(tt.where)
+		assert.Tf(t, err == nil, "Must parse %s but got %v", tt.where, err)
+		assert.Equalf(t, tt.key, plan.KeyFromWhere(stmt.Where), "where %s", tt.where)
+	}
+}
+
+func TestMutationPlans(t *testing.T) {
+	td.LoadTestDataOnce()
+	for _, mt := range mutateTests {
+		ctx := td.TestContext(mt.q)
+		stmt, err := rel.ParseSql(mt.q)
+		assert.Tf(t, err == nil, "Must parse %s but got %v", mt.q, err)
+		p, err := plan.WalkStmt(ctx, stmt, plan.NewPlanner(ctx))
+		if mt.isErr {
+			assert.Tf(t, err != nil, "expected error for %s", mt.q)
+			continue
+		}
+		assert.Tf(t, err == nil, "expected no error for %s but got %v", mt.q, err)
+		switch pt := p.(type) {
+		case *plan.Update:
+			assert.Tf(t, pt.Source != nil, "must have source for %s", mt.q)
+			assert.Tf(t, pt.Patch != nil, "must patch by where for %s", mt.q)
+			assert.Equal(t, mt.key, pt.Key)
+		case *plan.Insert:
+			assert.Tf(t, pt.Source != nil, "must have source for %s", mt.q)
+			if pt.Stmt.Select != nil {
+				assert.Tf(t, pt.Select != nil, "must plan select for %s", mt.q)
+				assert.Equal(t, 1, len(pt.Children()))
+				assert.Tf(t, ctx.Projection == nil, "insert must not have select projection")
+			}
+		case *plan.Delete:
+			assert.Tf(t, pt.Source != nil, "must have source for %s", mt.q)
+		default:
+			t.Fatalf("unexpected plan %T for %s", p, mt.q)
+		}
+	}
+}
@@ -2,9 +2,13 @@ package plan
 
 import (
 	"fmt"
+	"strings"
 
 	u "github.com/araddon/gou"
 
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/expr/translate"
+	"github.com/araddon/qlbridge/rel"
 	"github.com/araddon/qlbridge/schema"
 )
 
@@ -18,6 +22,42 @@ func (m *PlannerDefault) WalkSelect(p *Select) error {
 	//u.Debugf("VisitSelect ctx:%p  %+v", p.Ctx, p.Stmt)
 
 	needsFinalProject := true
+	fragmented := false // where and partial aggregation run in parallel fragments
+
+	if len(p.Stmt.SetOps) > 0 {
+		return m.walkSetOperation(p)
+	}
+
+	phase := m.Ctx.StartPhase("rewrite")
+	defer func() { phase() }()
+
+	if p.Stmt.Hints != "" && m.Ctx.Hints == nil {
+		hints, warnings := ParseHints(p.Stmt.Hints)
+		m.Ctx.Hints = hints
+		m.Ctx.Warnings = append(m.Ctx.Warnings, warnings...)
+	}
+
+	if err := m.rewriteViews(p); err != nil {
+		return err
+	}
+
+	// Remove redundant predicates before they get pushed down to sources
+	before := simplifiable(p.Stmt)
+	SimplifyStatement(p.Stmt)
+	if simplifiable(p.Stmt) != before {
+		m.Ctx.RuleApplied("simplify")
+	}
+
+	phase()
+	phase = m.Ctx.StartPhase("resolve")
+	if err := ResolveColumns(m.Ctx, p.Stmt); err != nil {
+		return err
+	}
+	m.validateJoins(p.Stmt)
+	m.eliminateJoin(p.Stmt)
+
+	phase()
+	phase = m.Ctx.StartPhase("sources")
 
 	if len(p.Stmt.From) == 0 {
 
@@ -33,7 +73,6 @@ func (m *PlannerDefault) WalkSelect(p *Select) error {
 			return err
 		}
 		p.From = append(p.From, srcPlan)
-		p.Add(srcPlan)
 
 		err = m.Planner.WalkSourceSelect(srcPlan)
 		if err != nil {
@@ -42,9 +81,21 @@ func (m *PlannerDefault) WalkSelect(p *Select) error {
 		}
 
 		if srcPlan.Complete {
+			p.Add(srcPlan)
+			phase()
+			phase = m.Ctx.StartPhase("projection")
 			goto finalProjection
 		}
 
+		m.planPartitionedScan(srcPlan)
+
+		if dop := m.parallelism(srcPlan); dop > 1 && m.walkFragments(p, srcPlan, dop) {
+			fragmented = true
+			m.Ctx.RuleApplied("parallel-fragments")
+		} else {
+			p.Add(srcPlan)
+		}
+
 	} else {
 
 		var prevSource *Source
@@ -70,6 +121,14 @@ func (m *PlannerDefault) WalkSelect(p *Select) error {
 				from.Seekable = true
 				// fold this source into previous
 				curMergeTask := NewJoinMerge(prevTask, srcPlan, prevSource.Stmt, srcPlan.Stmt)
+				curMergeTask.Algorithm = m.joinAlgorithm(i, curMergeTask, prevSource, srcPlan)
+				if i == 1 && curMergeTask.Algorithm == JoinAlgorithmHash {
+					m.joinBuildSide(curMergeTask, prevSource, srcPlan)
+				}
+				if i == 1 && curMergeTask.Algorithm != JoinAlgorithmLookup {
+					// only the first join has two sources (vs a join) as inputs
+					m.planJoinPartitions(curMergeTask, prevSource, srcPlan)
+				}
 				prevTask = curMergeTask
 			} else {
 				prevTask = srcPlan
@@ -81,12 +140,15 @@ func (m *PlannerDefault) WalkSelect(p *Select) error {
 
 	}
 
-	if p.Stmt.Where != nil {
+	phase()
+	phase = m.Ctx.StartPhase("where")
+	if p.Stmt.Where != nil && !fragmented {
 		switch {
 		case p.Stmt.Where.Source != nil:
 			// SELECT id from article WHERE id in (select article_id from comments where comment_ct > 50);
-			u.Warnf("Found un-supported subquery: %#v", p.Stmt.Where)
-			return ErrNotImplemented
+			if err := m.walkSemiJoin(p); err != nil {
+				return err
+			}
 		case p.Stmt.Where.Expr != nil:
 			p.Add(NewWhere(p.Stmt))
 		default:
@@ -95,9 +157,19 @@ func (m *PlannerDefault) WalkSelect(p *Select) error {
 		}
 	}
 
+	phase()
+	phase = m.Ctx.StartPhase("aggregate")
 	if p.Stmt.IsAggQuery() {
 		//u.Debugf("Adding aggregate/group by? %#v", m.Planner)
-		p.Add(NewGroupBy(p.Stmt))
+		pushed, err := m.pushPartialAggregate(p, fragmented)
+		if err != nil {
+			return err
+		}
+		if fragmented || pushed {
+			p.Add(NewGroupByFinal(p.Stmt))
+		} else {
+			p.Add(NewGroupBy(p.Stmt))
+		}
 		needsFinalProject = false
 	}
 
@@ -105,10 +177,24 @@ func (m *PlannerDefault) WalkSelect(p *Select) error {
 		p.Add(NewHaving(p.Stmt))
 	}
 
-	if len(p.Stmt.OrderBy) > 0 {
-		p.Add(NewOrder(p.Stmt))
+	if p.Stmt.IsWindowQuery() {
+		if p.Stmt.IsAggQuery() {
+			return fmt.Errorf("window functions are not supported with aggregates or GROUP BY")
+		}
+		p.Add(NewWindow(p.Stmt))
+	}
+
+	if p.Stmt.Distinct {
+		// before order and limit, so they apply to the distinct rows
+		p.Add(NewDistinct(p.Stmt))
 	}
 
+	phase()
+	phase = m.Ctx.StartPhase("order")
+	m.pushLimit(p, fragmented, m.addOrder(p, fragmented))
+
+	phase()
+	phase = m.Ctx.StartPhase("projection")
 	if needsFinalProject {
 		err := m.WalkProjectionFinal(p)
 		if err != nil {
@@ -131,6 +217,109 @@ finalProjection:
 	return nil
 }
 
+// addOrder the ORDER BY of the select, if any and the rows are not already
+// in that order, returns whether an Order was added
+func (m *PlannerDefault) addOrder(p *Select, fragmented bool) bool {
+	if len(p.Stmt.OrderBy) == 0 {
+		return false
+	}
+	if !fragmented && m.sourceOrdered(p) {
+		return false
+	}
+	order := NewOrder(p.Stmt)
+	if n := p.Stmt.Limit + p.Stmt.Offset; p.Stmt.Limit > 0 && n <= TopNLimit {
+		// a small limit only needs the top rows kept, not a full sort
+		order.Limit = n
+		m.Ctx.RuleApplied("topn")
+	}
+	p.Add(order)
+	return true
+}
+
+// sourceOrdered do the rows of the single source of the select come in the
+// order of its ORDER BY, with nothing in between that re-orders them.
+// Either the source scans in that order already (see schema.ConnSorted),
+// or sorts them itself (see SourceOrderer).
+func (m *PlannerDefault) sourceOrdered(p *Select) bool {
+	if len(p.From) != 1 || p.Stmt.IsAggQuery() || p.Stmt.IsWindowQuery() || p.Stmt.Distinct {
+		return false
+	}
+	src := p.From[0]
+	if len(src.Partitions) > 0 || !orderBySourceColumns(p.Stmt) {
+		return false
+	}
+	caps := src.Capabilities()
+	if len(caps.SortedBy) > 0 && sortedBy(p.Stmt.OrderBy, caps.SortedBy) {
+		m.Ctx.RuleApplied("sorted-source")
+		return true
+	}
+	orderer, ok := src.Conn.(SourceOrderer)
+	if !ok || !caps.Order || m.pushdownDisabled(src) {
+		return false
+	}
+	if orderer.PushOrder(src, p.Stmt.OrderBy) {
+		m.Ctx.Pushdown(src.Stmt.SourceName(), "order", true, "")
+		return true
+	}
+	m.Ctx.Pushdown(src.Stmt.SourceName(), "order", false, "source declined")
+	return false
+}
+
+// orderBySourceColumns is the ORDER BY all columns of the source, not
+// expressions or aliases of other columns
+func orderBySourceColumns(stmt *rel.SqlSelect) bool {
+	for _, col := range stmt.OrderBy {
+		in, ok := col.Expr.(*expr.IdentityNode)
+		if !ok {
+			return false
+		}
+		_, name, _ := in.LeftRight()
+		for _, c := range stmt.Columns {
+			if strings.EqualFold(c.As, name) && !strings.EqualFold(c.SourceField, name) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sortedBy is the ORDER BY ascending columns of the sort order cols
+func sortedBy(order rel.Columns, cols []string) bool {
+	if len(order) > len(cols) {
+		return false
+	}
+	for i, col := range order {
+		_, name, _ := col.Expr.(*expr.IdentityNode).LeftRight()
+		if strings.EqualFold(col.Order, "DESC") || !strings.EqualFold(name, cols[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// pushLimit offer the LIMIT of a select of a single source to the source, if
+// only its where is evaluated before the limit
+func (m *PlannerDefault) pushLimit(p *Select, fragmented, ordered bool) {
+	if p.Stmt.Limit <= 0 || fragmented || ordered || len(p.From) != 1 {
+		return
+	}
+	stmt := p.Stmt
+	if stmt.IsAggQuery() || stmt.IsWindowQuery() || stmt.Distinct || stmt.Having != nil ||
+		(stmt.Where != nil && stmt.Where.Source != nil) {
+		return
+	}
+	src := p.From[0]
+	limiter, ok := src.Conn.(SourceLimiter)
+	if !ok || !src.Capabilities().Limit || len(src.Partitions) > 0 || m.pushdownDisabled(src) {
+		return
+	}
+	if limiter.PushLimit(src, stmt.Limit+stmt.Offset) {
+		m.Ctx.Pushdown(src.Stmt.SourceName(), "limit", true, "")
+	} else {
+		m.Ctx.Pushdown(src.Stmt.SourceName(), "limit", false, "source declined")
+	}
+}
+
 func (m *PlannerDefault) WalkProjectionFinal(p *Select) error {
 	// Add a Final Projection to choose the columns for results
 	proj, err := NewProjectionFinal(m.Ctx, p)
@@ -147,6 +336,251 @@ func (m *PlannerDefault) WalkProjectionFinal(p *Select) error {
 	return nil
 }
 
+// pushPartialAggregate let a single source that can compute partial
+// aggregates do so, leaving only the final merge of partials in-process.
+func (m *PlannerDefault) pushPartialAggregate(p *Select, fragmented bool) (bool, error) {
+	if fragmented || len(p.From) != 1 || !canMergeAggs(p.Stmt) {
+		return false, nil
+	}
+	src := p.From[0]
+	aggSource, ok := src.Conn.(SourcePartialAggregator)
+	if !ok || !src.Capabilities().Aggregates {
+		return false, nil
+	}
+	name := src.Stmt.SourceName()
+	switch {
+	case m.pushdownDisabled(src):
+		m.Ctx.Pushdown(name, "aggregate", false, noPushdownReason(src))
+		return false, nil
+	case p.Stmt.Where != nil:
+		// the where is evaluated in-process, after the source
+		m.Ctx.Pushdown(name, "aggregate", false, "where evaluated in-process")
+		return false, nil
+	}
+	accepted, err := aggSource.WalkPartialAggregate(src, NewGroupByPartial(p.Stmt))
+	if err != nil {
+		return false, err
+	}
+	m.Ctx.Pushdown(name, "aggregate", accepted, "")
+	if accepted {
+		m.Ctx.RuleApplied("partial-aggregate")
+	}
+	return accepted, nil
+}
+
+// joinAlgorithm choose the algorithm to join this source to the sources
+// before it, equality joins use a build/probe hash join unless hinted, or
+// a merge join if both inputs are already sorted on the join key, or a
+// lookup join if the right side can be looked up by the join key.
+func (m *PlannerDefault) joinAlgorithm(i int, jm *JoinMerge, left, right *Source) string {
+	if len(right.Stmt.JoinNodes()) == 0 {
+		return ""
+	}
+	algorithm := JoinAlgorithmHash
+	if m.Ctx.Hints != nil && m.Ctx.Hints.JoinAlgorithm != "" {
+		algorithm = m.Ctx.Hints.JoinAlgorithm
+	} else if i == 1 && sortedOnJoin(left) && sortedOnJoin(right) {
+		algorithm = JoinAlgorithmMerge
+	} else if keyedOnJoin(jm, right) {
+		algorithm = JoinAlgorithmLookup
+	}
+	switch algorithm {
+	case JoinAlgorithmLookup:
+		if !keyedOnJoin(jm, right) {
+			m.Ctx.Warnf("lookup join of %q requires a source keyed on the join key, using hash join", right.Stmt.SourceName())
+			return JoinAlgorithmHash
+		}
+		m.Ctx.RuleApplied("lookup-join")
+	case JoinAlgorithmMerge:
+		if i != 1 {
+			// the output of a join is not sorted (or keyed) for the next one
+			m.Ctx.Warnf("merge join of %q requires sorted inputs, using hash join", right.Stmt.SourceName())
+			return JoinAlgorithmHash
+		}
+		m.Ctx.RuleApplied("merge-join")
+		for _, src := range []*Source{left, right} {
+			if !sortedOnJoin(src) {
+				src.Add(NewOrder(joinSortStmt(src.Stmt)))
+				m.Ctx.RuleApplied("sort-join-input")
+			}
+		}
+	}
+	return algorithm
+}
+
+// joinBuildSide build the hash table of an inner hash join of the left
+// source instead of the right if the stats of their tables estimate it
+// returns fewer rows.
+func (m *PlannerDefault) joinBuildSide(jm *JoinMerge, left, right *Source) {
+	if preserveLeft, preserveRight := jm.Preserved(); preserveLeft || preserveRight {
+		return
+	}
+	lrows, ok := left.EstimatedRows()
+	if !ok {
+		return
+	}
+	rrows, ok := right.EstimatedRows()
+	if !ok || lrows >= rrows {
+		return
+	}
+	jm.BuildLeft = true
+	m.Ctx.RuleApplied("build-smaller-side")
+}
+
+// keyedOnJoin can the rows of the right source of an inner or left join be
+// looked up by the join key, ie the source is a key-value store keyed on
+// the single join column, and its where (if any) is evaluated in-process.
+func keyedOnJoin(jm *JoinMerge, right *Source) bool {
+	if _, preserveRight := jm.Preserved(); preserveRight {
+		return false
+	}
+	caps := right.Capabilities()
+	if caps.Projection {
+		return false
+	}
+	seeker, ok := right.Conn.(schema.ConnSeeker)
+	if !ok || !caps.Seek {
+		return false
+	}
+	nodes := right.Stmt.JoinNodes()
+	if len(nodes) != 1 || len(jm.LeftFrom.JoinNodes()) != 1 {
+		return false
+	}
+	in, ok := nodes[0].(*expr.IdentityNode)
+	if !ok {
+		return false
+	}
+	_, col, _ := in.LeftRight()
+	if caps.KeyColumn == "" || !strings.EqualFold(col, caps.KeyColumn) {
+		return false
+	}
+	if src := right.Stmt.Source; src == nil || (src.Where != nil && src.Where.Expr == nil) {
+		return false
+	}
+	return seeker.CanSeek(right.Stmt.Source)
+}
+
+// sortedOnJoin does the source connection return rows already sorted on the
+// join key columns, in order.
+func sortedOnJoin(p *Source) bool {
+	cols := p.Capabilities().SortedBy
+	nodes := p.Stmt.JoinNodes()
+	if len(nodes) == 0 || len(nodes) > len(cols) {
+		return false
+	}
+	for i, node := range nodes {
+		in, ok := node.(*expr.IdentityNode)
+		if !ok {
+			return false
+		}
+		_, col, _ := in.LeftRight()
+		if !strings.EqualFold(col, cols[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// joinSortStmt a statement ordering by the join key of this source, used to
+// sort a merge join input
+func joinSortStmt(from *rel.SqlSource) *rel.SqlSelect {
+	stmt := &rel.SqlSelect{}
+	for _, node := range from.JoinNodes() {
+		stmt.OrderBy = append(stmt.OrderBy, &rel.Column{Expr: node, Order: "ASC"})
+	}
+	return stmt
+}
+
+// translateWhere translate the where pushed down to this source into the
+// native query form of the source, by the source connection itself if it is
+// a translate.Translator, else (or if it could not) the translator
+// registered for the source type.  Un-translatable wheres are left for the
+// source (or in-process) to evaluate.
+func (m *PlannerDefault) translateWhere(p *Source) {
+	if p.Stmt.Source == nil {
+		return
+	}
+	where := p.Stmt.Source.Where
+	if where == nil || where.Expr == nil {
+		if p.IndexHint != "" {
+			m.Ctx.Warnf("%q has no where to seek index %q of, USE_INDEX hint ignored", p.Stmt.SourceName(), p.IndexHint)
+			p.IndexHint = ""
+		}
+		return
+	}
+	if p.IndexHint != "" {
+		// the hinted index is seeked by the source itself, of the where of
+		// any operators or translators
+		native, err := p.Conn.(SourceIndexTranslator).TranslateIndex(where.Expr, p.IndexHint)
+		if err == nil {
+			p.Native = native
+			m.Ctx.RuleApplied("use-index")
+			m.Ctx.Pushdown(p.Stmt.SourceName(), "translate", true, "")
+			return
+		}
+		m.Ctx.Warnf("index %q can not seek the where of %q, USE_INDEX hint ignored: %v", p.IndexHint, p.Stmt.SourceName(), err)
+		p.IndexHint = ""
+	}
+	caps := p.Capabilities()
+	if !caps.Filter {
+		return
+	}
+	if op := unsupportedFilterOp(caps, where.Expr); op != "" {
+		m.Ctx.Pushdown(p.Stmt.SourceName(), "translate", false, fmt.Sprintf("operator %s not supported", op))
+		return
+	}
+	translators := make([]translate.Translator, 0, 2)
+	if t, ok := p.Conn.(translate.Translator); ok {
+		translators = append(translators, t)
+	}
+	if p.SchemaSource != nil && p.SchemaSource.Conf != nil {
+		if t := translate.Get(p.SchemaSource.Conf.SourceType); t != nil {
+			translators = append(translators, t)
+		}
+	}
+	if len(translators) == 0 {
+		return
+	}
+	var native interface{}
+	var err error
+	for _, t := range translators {
+		if native, err = t.Translate(where.Expr); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		u.Debugf("could not translate where for %q: %v", p.Stmt.SourceName(), err)
+		m.Ctx.Pushdown(p.Stmt.SourceName(), "translate", false, err.Error())
+		return
+	}
+	p.Native = native
+	m.Ctx.Pushdown(p.Stmt.SourceName(), "translate", true, "")
+}
+
+// simplifiable the parts of the statement SimplifyStatement may rewrite
+func simplifiable(stmt *rel.SqlSelect) string {
+	s := ""
+	if stmt.Where != nil {
+		s = stmt.Where.String()
+	}
+	if stmt.Having != nil {
+		s += " HAVING " + stmt.Having.String()
+	}
+	return s
+}
+
+func hasIndex(tbl *schema.Table, name string) bool {
+	if tbl == nil {
+		return false
+	}
+	for _, idx := range tbl.Indexes {
+		if strings.EqualFold(idx.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
 // Build Column Name to Position index for given *source* (from) used to interpret
 // positional []driver.Value args, mutate the *from* itself to hold this map
 func buildColIndex(colSchema schema.ConnColumns, p *Source) error {
@@ -154,7 +588,107 @@ func buildColIndex(colSchema schema.ConnColumns, p *Source) error {
 		u.Errorf("Could not build Column-Index bc no source %#v", p)
 		return nil
 	}
-	return p.Stmt.BuildColIndex(colSchema.Columns())
+	cols := colSchema.Columns()
+	if len(p.Computed) > 0 {
+		// added to the rows of the source as scanned, after its columns
+		cols = append(cols[:len(cols):len(cols)], computedNames(p.Computed)...)
+	}
+	if p.ctx != nil && p.ctx.Schema != nil && p.ctx.Schema.IdentifierCase() != schema.CaseExact {
+		caseColumns(p.Stmt, cols)
+	}
+	return p.Stmt.BuildColIndex(cols)
+}
+
+// caseColumns rename the identities of the columns of the source of from
+// not named as by the source, but matching its cols regardless of case,
+// to their names of the source, as its rows are keyed by them.
+func caseColumns(from *rel.SqlSource, cols []string) {
+	names := make(map[string]string, len(cols))
+	for _, col := range cols {
+		if _, ok := names[strings.ToLower(col)]; !ok {
+			names[strings.ToLower(col)] = col
+		}
+	}
+	exact := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		exact[col] = true
+	}
+	rename := func(name string) string {
+		if exact[name] {
+			return name
+		}
+		if col, ok := names[strings.ToLower(name)]; ok {
+			return col
+		}
+		return name
+	}
+	var walk func(node expr.Node, qualified bool)
+	walk = func(node expr.Node, qualified bool) {
+		switch n := node.(type) {
+		case *expr.IdentityNode:
+			if n.Quote == '\'' || n.Quote == '"' || n.IsBooleanIdentity() {
+				return
+			}
+			if left, right, ok := n.LeftRight(); ok {
+				if strings.EqualFold(left, from.Alias) || strings.EqualFold(left, from.Name) {
+					if col := rename(right); col != right {
+						n.Rename(left + "." + col)
+					}
+				}
+			} else if !qualified {
+				if col := rename(n.Text); col != n.Text {
+					n.Rename(col)
+				}
+			}
+		case *expr.BinaryNode:
+			for _, arg := range n.Args {
+				walk(arg, qualified)
+			}
+		case *expr.TriNode:
+			for _, arg := range n.Args {
+				walk(arg, qualified)
+			}
+		case *expr.UnaryNode:
+			walk(n.Arg, qualified)
+		case *expr.FuncNode:
+			for _, arg := range n.Args {
+				walk(arg, qualified)
+			}
+		case *expr.ArrayNode:
+			for _, arg := range n.Args {
+				walk(arg, qualified)
+			}
+		}
+	}
+	// of a join only the identities qualified by this source are its own
+	if from.JoinExpr != nil {
+		walk(from.JoinExpr, true)
+	}
+	sel := from.Source
+	if sel == nil {
+		return
+	}
+	for _, col := range sel.Columns {
+		if col.Expr != nil {
+			walk(col.Expr, false)
+		}
+		if col.SourceField != "" {
+			col.SourceField = rename(col.SourceField)
+		}
+	}
+	if sel.Where != nil && sel.Where.Expr != nil {
+		walk(sel.Where.Expr, false)
+	}
+	for _, cols := range []rel.Columns{sel.GroupBy, sel.OrderBy} {
+		for _, col := range cols {
+			if col.Expr != nil {
+				walk(col.Expr, false)
+			}
+		}
+	}
+	if sel.Having != nil {
+		walk(sel.Having, false)
+	}
 }
 
 // SourceSelect is a single source select
@@ -195,7 +729,44 @@ func (m *PlannerDefault) WalkSourceSelect(p *Source) error {
 		}
 	}
 
-	if sourcePlanner, hasSourcePlanner := p.Conn.(SourcePlanner); hasSourcePlanner {
+	if idx := m.Ctx.Hints.Index(p.Stmt); idx != "" {
+		_, seeksIndex := p.Conn.(SourceIndexTranslator)
+		switch {
+		case !hasIndex(p.Tbl, idx):
+			m.Ctx.Warnf("index %q not found on %q, USE_INDEX hint ignored", idx, p.Stmt.SourceName())
+		case !seeksIndex:
+			m.Ctx.Warnf("%q can not be told which index to seek, USE_INDEX hint ignored", p.Stmt.SourceName())
+		default:
+			p.IndexHint = idx
+		}
+	}
+
+	if !m.pushdownDisabled(p) {
+		m.translateWhere(p)
+	} else if p.IndexHint != "" {
+		m.Ctx.Warnf("pushdown to %q disabled by NO_PUSHDOWN, USE_INDEX hint ignored", p.Stmt.SourceName())
+		p.IndexHint = ""
+	}
+
+	sourcePlanner, hasSourcePlanner := p.Conn.(SourcePlanner)
+	hasSourcePlanner = hasSourcePlanner && p.Capabilities().Projection
+	if hasSourcePlanner && m.pushdownDisabled(p) {
+		hasSourcePlanner = false
+		if _, ok := p.Conn.(schema.ConnColumns); !ok && len(p.Computed) > 0 {
+			return fmt.Errorf("source %q plans its own queries, it can not compute the columns %s", p.Stmt.SourceName(), computedNames(p.Computed))
+		} else if !ok {
+			// Without columns we can't run this source in-process
+			m.Ctx.Warnf("source %q requires pushdown, NO_PUSHDOWN hint ignored", p.Stmt.SourceName())
+			m.Ctx.Pushdown(p.Stmt.SourceName(), "planner", true, "source requires pushdown, NO_PUSHDOWN hint ignored")
+			hasSourcePlanner = true
+		} else {
+			m.Ctx.Pushdown(p.Stmt.SourceName(), "planner", false, noPushdownReason(p))
+		}
+	} else if hasSourcePlanner {
+		m.Ctx.Pushdown(p.Stmt.SourceName(), "planner", true, "")
+	}
+
+	if hasSourcePlanner {
 		// Can do our own planning
 		t, err := sourcePlanner.WalkSourceSelect(m.Planner, p)
 		if err != nil {
@@ -219,6 +790,8 @@ func (m *PlannerDefault) WalkSourceSelect(p *Source) error {
 			switch {
 			case p.Stmt.Source.Where.Expr != nil:
 				p.Add(NewWhere(p.Stmt.Source))
+			case p.Stmt.Source.Where.Source != nil:
+				// sub-query, evaluated by a SemiJoin after the source
 			default:
 				u.Warnf("Found un-supported where type: %#v", p.Stmt.Source)
 				return fmt.Errorf("Unsupported Where clause:  %q", p.Stmt)
@@ -95,8 +95,7 @@ func (m *Projection) loadFinal(ctx *Context, isFinal bool) error {
 
 	for _, from := range m.Stmt.From {
 
-		fromName := strings.ToLower(from.SourceName())
-		tbl, err := ctx.Schema.Table(fromName)
+		tbl, err := ctx.Schema.Table(qualifiedName(from))
 		if err != nil {
 			u.Errorf("could not get table: %v", err)
 			return err
@@ -114,7 +113,7 @@ func (m *Projection) loadFinal(ctx *Context, isFinal bool) error {
 						m.Proj.AddColumnShort(f.Name, f.Type)
 					}
 				} else {
-					if schemaCol, ok := tbl.FieldMap[col.SourceField]; ok {
+					if schemaCol, ok := tbl.Field(col.SourceField); ok {
 						if isFinal {
 							if col.InFinalProjection() {
 								//u.Debugf("in plan final %s", col.As)
@@ -165,7 +164,7 @@ func projectionForSourcePlan(plan *Source) error {
 			} else {
 				plan.Proj.AddColumn(col, value.StringType)
 			}
-		} else if schemaCol, ok := plan.Tbl.FieldMap[col.SourceField]; ok {
+		} else if schemaCol, ok := plan.Tbl.Field(col.SourceField); ok {
 			if plan.Final {
 				if col.InFinalProjection() {
 					//u.Infof("col add %v for %s", schemaCol.Type.String(), col)
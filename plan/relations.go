@@ -0,0 +1,271 @@
+package plan
+
+import (
+	"strings"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+)
+
+// joinSide a source of a join, and the table of it
+type joinSide struct {
+	from  *rel.SqlSource
+	alias string
+	tbl   *schema.Table
+}
+
+// validateJoins warn of joins of tables with relations (see
+// schema.Relation) on columns that match none of them, ie a join of orders
+// to users on orders.id instead of orders.user_id.
+func (m *PlannerDefault) validateJoins(stmt *rel.SqlSelect) {
+	sides := m.joinSides(stmt)
+	if sides == nil {
+		return
+	}
+	for i := 1; i < len(sides); i++ {
+		pairs := joinPairs(sides[i].from.JoinExpr)
+		for _, left := range sides[:i] {
+			lcols, rcols := pairsOf(pairs, left.alias, sides[i].alias)
+			if len(lcols) == 0 {
+				continue
+			}
+			rels := m.Ctx.Schema.RelationsBetween(left.tbl.Name, sides[i].tbl.Name)
+			if len(rels) == 0 || joinsOnRelation(rels, left.tbl.Name, lcols, rcols) != nil {
+				continue
+			}
+			names := make([]string, len(rels))
+			for j, r := range rels {
+				names[j] = r.String()
+			}
+			m.Ctx.Warnf("join of %s and %s on %s matches none of their relations: %s",
+				left.tbl.Name, sides[i].tbl.Name, sides[i].from.JoinExpr, strings.Join(names, "; "))
+		}
+	}
+}
+
+// eliminateJoin remove the table of an inner join of two tables that only
+// contributes its join columns, if a foreign key of NOT NULL columns of the
+// other table references its primary key: each row of the other table
+// joins exactly one row of it, so the join changes nothing.  Foreign keys
+// are trusted to hold, logical relations are not.
+//
+//   SELECT o.qty FROM orders AS o INNER JOIN users AS u ON o.user_id = u.user_id
+//   =>  SELECT o.qty FROM orders AS o
+func (m *PlannerDefault) eliminateJoin(stmt *rel.SqlSelect) bool {
+	if len(stmt.From) != 2 || stmt.Star || (stmt.Where != nil && stmt.Where.Source != nil) {
+		return false
+	}
+	sides := m.joinSides(stmt)
+	if sides == nil {
+		return false
+	}
+	join := sides[1].from
+	if join.LeftOrRight != 0 || join.JoinType == lex.TokenOuter || join.JoinType == lex.TokenCross {
+		return false
+	}
+	pairs := joinPairs(join.JoinExpr)
+	for p, parent := range sides {
+		child := sides[1-p]
+		ccols, pcols := pairsOf(pairs, child.alias, parent.alias)
+		if len(ccols) == 0 || len(ccols) != len(pairs) {
+			continue
+		}
+		r := joinsOnRelation(child.tbl.Relations, child.tbl.Name, ccols, pcols)
+		if r == nil || r.Logical || r.RefTable != parent.tbl.Name {
+			continue
+		}
+		if !notNull(child.tbl, ccols) || !sameColumns(parent.tbl.PrimaryKey(), pcols) {
+			continue
+		}
+		if referencesSide(stmt, parent) {
+			continue
+		}
+		child.from.JoinExpr = nil
+		child.from.JoinType = 0
+		child.from.LeftOrRight = 0
+		child.from.Op = 0
+		stmt.From = []*rel.SqlSource{child.from}
+		m.Ctx.RuleApplied("join-elimination")
+		return true
+	}
+	return false
+}
+
+// joinSides the sources of the joins of stmt and their tables, nil if any
+// is not a table of the schema
+func (m *PlannerDefault) joinSides(stmt *rel.SqlSelect) []*joinSide {
+	if m.Ctx.Schema == nil || len(stmt.From) < 2 {
+		return nil
+	}
+	sides := make([]*joinSide, len(stmt.From))
+	for i, from := range stmt.From {
+		if from.SubQuery != nil || (i > 0 && from.JoinExpr == nil) {
+			return nil
+		}
+		tbl, err := m.Ctx.Schema.Table(qualifiedName(from))
+		if err != nil || tbl == nil {
+			return nil
+		}
+		alias := from.Alias
+		if alias == "" {
+			alias = from.SourceName()
+		}
+		sides[i] = &joinSide{from: from, alias: strings.ToLower(alias), tbl: tbl}
+	}
+	return sides
+}
+
+// joinPairs the identities of the equalities of the ANDs of a join
+func joinPairs(node expr.Node) [][2]*expr.IdentityNode {
+	bn, ok := node.(*expr.BinaryNode)
+	if !ok || len(bn.Args) != 2 {
+		return nil
+	}
+	switch bn.Operator.T {
+	case lex.TokenLogicAnd:
+		return append(joinPairs(bn.Args[0]), joinPairs(bn.Args[1])...)
+	case lex.TokenEqual, lex.TokenEqualEqual:
+		l, lok := bn.Args[0].(*expr.IdentityNode)
+		r, rok := bn.Args[1].(*expr.IdentityNode)
+		if lok && rok {
+			return [][2]*expr.IdentityNode{{l, r}}
+		}
+	}
+	return nil
+}
+
+// pairsOf the columns of the pairs of an identity qualified by a, and one
+// by b, in order of pairs
+func pairsOf(pairs [][2]*expr.IdentityNode, a, b string) (acols, bcols []string) {
+	for _, pair := range pairs {
+		l, lcol, _ := pair[0].LeftRight()
+		r, rcol, _ := pair[1].LeftRight()
+		l, r = strings.ToLower(l), strings.ToLower(r)
+		switch {
+		case l == a && r == b:
+			acols, bcols = append(acols, lcol), append(bcols, rcol)
+		case l == b && r == a:
+			acols, bcols = append(acols, rcol), append(bcols, lcol)
+		}
+	}
+	return acols, bcols
+}
+
+// joinsOnRelation the relation of rels joined by cols of table to refCols,
+// or refCols to cols of table
+func joinsOnRelation(rels []*schema.Relation, table string, cols, refCols []string) *schema.Relation {
+	for _, r := range rels {
+		if r.Table == table && r.Joins(cols, refCols) {
+			return r
+		}
+		if r.Table != table && r.Joins(refCols, cols) {
+			return r
+		}
+	}
+	return nil
+}
+
+func notNull(tbl *schema.Table, cols []string) bool {
+	for _, col := range cols {
+		f, ok := tbl.FieldMap[col]
+		if !ok || !f.NoNulls {
+			return false
+		}
+	}
+	return true
+}
+
+func sameColumns(a, b []string) bool {
+	if len(a) == 0 || len(a) != len(b) {
+		return false
+	}
+	for _, col := range a {
+		found := false
+		for _, other := range b {
+			if strings.EqualFold(col, other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// referencesSide does stmt (other than the join) refer to a column of side,
+// qualified by its alias, or unqualified and maybe of side
+func referencesSide(stmt *rel.SqlSelect, side *joinSide) bool {
+	nodes := make([]expr.Node, 0)
+	for _, cols := range []rel.Columns{stmt.Columns, stmt.GroupBy, stmt.OrderBy} {
+		for _, col := range cols {
+			if col.Star {
+				return true
+			}
+			if col.Expr != nil {
+				nodes = append(nodes, col.Expr)
+			}
+			if col.Guard != nil {
+				nodes = append(nodes, col.Guard)
+			}
+		}
+	}
+	if stmt.Where != nil && stmt.Where.Expr != nil {
+		nodes = append(nodes, stmt.Where.Expr)
+	}
+	if stmt.Having != nil {
+		nodes = append(nodes, stmt.Having)
+	}
+	for _, node := range nodes {
+		if refersTo(node, side) {
+			return true
+		}
+	}
+	return false
+}
+
+func refersTo(node expr.Node, side *joinSide) bool {
+	switch n := node.(type) {
+	case *expr.IdentityNode:
+		left, col, hasLeft := n.LeftRight()
+		if hasLeft {
+			return strings.ToLower(left) == side.alias
+		}
+		return len(side.tbl.Fields) == 0 || side.tbl.HasField(col)
+	case *expr.BinaryNode:
+		for _, arg := range n.Args {
+			if refersTo(arg, side) {
+				return true
+			}
+		}
+	case *expr.TriNode:
+		for _, arg := range n.Args {
+			if refersTo(arg, side) {
+				return true
+			}
+		}
+	case *expr.FuncNode:
+		for _, arg := range n.Args {
+			if refersTo(arg, side) {
+				return true
+			}
+		}
+	case *expr.ArrayNode:
+		for _, arg := range n.Args {
+			if refersTo(arg, side) {
+				return true
+			}
+		}
+	case *expr.UnaryNode:
+		return refersTo(n.Arg, side)
+	case *expr.StringNode, *expr.NumberNode, *expr.NullNode, *expr.ValueNode:
+		return false
+	default:
+		// a node we do not look into may refer to it
+		return true
+	}
+	return false
+}
@@ -0,0 +1,451 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+var _ = u.EMPTY
+
+// ColumnError is a plan-time error for an identifier that could not be
+// resolved against (or is the wrong type for) the schema of the sources
+// in a statement.
+type ColumnError struct {
+	Column     string // the identifier as written
+	Table      string // table(s) it was resolved against
+	Suggestion string // closest matching column, if any
+	Reason     string // type error, empty for unknown columns
+	Pos        int    // byte offset of identifier in statement, -1 if unknown
+	Line       int    // 1 based line of Pos
+	Col        int    // 1 based column of Pos
+}
+
+func (m *ColumnError) Error() string {
+	var msg string
+	if m.Reason != "" {
+		msg = fmt.Sprintf("%s for column '%s' in table '%s'", m.Reason, m.Column, m.Table)
+	} else {
+		msg = fmt.Sprintf("unknown column '%s' in table '%s'", m.Column, m.Table)
+		if m.Suggestion != "" {
+			msg += fmt.Sprintf(", did you mean '%s'?", m.Suggestion)
+		}
+	}
+	if m.Pos >= 0 {
+		msg += fmt.Sprintf(" at line %d column %d", m.Line, m.Col)
+	}
+	return msg
+}
+
+// resolveSource a table that identifiers may be resolved against
+type resolveSource struct {
+	from *rel.SqlSource
+	tbl  *schema.Table
+}
+
+// columnResolver resolves identifiers of a select statement against the
+// schema of its sources.
+type columnResolver struct {
+	stmt    *rel.SqlSelect
+	sources []*resolveSource
+	aliases map[string]bool
+	ic      schema.IdentifierCase // how names match, of the schema
+}
+
+// ResolveColumns checks that every identifier in the SELECT, WHERE, GROUP BY,
+// HAVING and ORDER BY clauses of the statement exists in the schema of the
+// source it refers to, returning a *ColumnError for the first one that does
+// not.  Also checks that arithmetic is not applied to non-numeric columns.
+//
+// Sources without a known schema (no fields, sub-queries, schema queries) are
+// not validated, nor are identifiers qualified by something other than a
+// source name or alias as they may be paths into nested data.
+func ResolveColumns(ctx *Context, stmt *rel.SqlSelect) error {
+	if ctx == nil || ctx.Schema == nil || stmt == nil || len(stmt.From) == 0 {
+		return nil
+	}
+	r := &columnResolver{stmt: stmt, aliases: make(map[string]bool), ic: ctx.Schema.IdentifierCase()}
+	for _, from := range stmt.From {
+		if from.SubQuery != nil || isSchemaSource(from) {
+			return nil
+		}
+		tbl, err := ctx.Schema.Table(qualifiedName(from))
+		if err != nil || tbl == nil || len(tbl.Fields) == 0 {
+			// unknown schema, it is an error elsewhere if the table doesn't exist
+			return nil
+		}
+		r.sources = append(r.sources, &resolveSource{from: from, tbl: tbl})
+	}
+	for _, col := range stmt.Columns {
+		if col.As != "" {
+			r.aliases[strings.ToLower(col.As)] = true
+		}
+	}
+
+	for _, col := range stmt.Columns {
+		if col.Star || col.Expr == nil {
+			continue
+		}
+		if err := r.resolve(col.Expr, false); err != nil {
+			return err
+		}
+	}
+	if stmt.Where != nil {
+		for _, node := range []expr.Node{stmt.Where.Expr, stmt.Where.Lhs} {
+			if node == nil {
+				continue
+			}
+			if err := r.resolve(node, false); err != nil {
+				return err
+			}
+		}
+	}
+	for _, cols := range []rel.Columns{stmt.GroupBy, stmt.OrderBy} {
+		for _, col := range cols {
+			if col.Expr == nil {
+				continue
+			}
+			if err := r.resolve(col.Expr, true); err != nil {
+				return err
+			}
+		}
+	}
+	if stmt.Having != nil {
+		return r.resolve(stmt.Having, true)
+	}
+	return nil
+}
+
+// ColumnTypes the type of the values of each column of a select, of the
+// schema of the columns of its sources, return types of functions and
+// literals.  UnknownType for columns (and *) whose type is not known.
+func ColumnTypes(ctx *Context, stmt *rel.SqlSelect) []value.ValueType {
+	types := make([]value.ValueType, len(stmt.Columns))
+	r := &columnResolver{stmt: stmt, aliases: make(map[string]bool)}
+	if ctx != nil && ctx.Schema != nil {
+		r.ic = ctx.Schema.IdentifierCase()
+		for _, from := range stmt.From {
+			if from.SubQuery != nil {
+				continue
+			}
+			if tbl, err := ctx.Schema.Table(qualifiedName(from)); err == nil && tbl != nil {
+				r.sources = append(r.sources, &resolveSource{from: from, tbl: tbl})
+			}
+		}
+	}
+	for i, col := range stmt.Columns {
+		types[i] = value.UnknownType
+		if !col.Star && col.Expr != nil {
+			types[i] = r.valueType(col.Expr)
+		}
+	}
+	return types
+}
+
+// valueType the type of the values of node, UnknownType if not known
+func (m *columnResolver) valueType(node expr.Node) value.ValueType {
+	switch n := node.(type) {
+	case *expr.IdentityNode:
+		if n.IsBooleanIdentity() {
+			return value.BoolType
+		}
+		if f, _ := m.field(n, false); f != nil {
+			return f.Type
+		}
+	case *expr.StringNode:
+		return value.StringType
+	case *expr.NumberNode:
+		if n.IsInt {
+			return value.IntType
+		}
+		return value.NumberType
+	case *expr.ValueNode:
+		if n.Value != nil {
+			return n.Value.Type()
+		}
+	case *expr.FuncNode:
+		return n.F.ReturnValueType
+	case *expr.BinaryNode:
+		switch n.Operator.T {
+		case lex.TokenPlus, lex.TokenMinus, lex.TokenMultiply, lex.TokenModulus:
+			for _, arg := range n.Args {
+				if m.valueType(arg) != value.IntType {
+					return value.NumberType
+				}
+			}
+			return value.IntType
+		case lex.TokenDivide:
+			return value.NumberType
+		}
+		return expr.ValueTypeFromNode(n)
+	case *expr.UnaryNode:
+		if n.Operator.T == lex.TokenNegate {
+			return value.BoolType
+		}
+		return m.valueType(n.Arg)
+	}
+	return value.UnknownType
+}
+
+func isSchemaSource(from *rel.SqlSource) bool {
+	switch strings.ToLower(from.Schema) {
+	case "context", "schema":
+		return true
+	}
+	return false
+}
+
+// qualifiedName the name of the table of from, qualified as written (ie
+// es.users) so the schema finds it of the source of the qualifier, if one
+// of its sources is (see schema.Schema.SourceOf)
+func qualifiedName(from *rel.SqlSource) string {
+	if from.Schema == "" || from.SubQuery != nil || isSchemaSource(from) {
+		return from.SourceName()
+	}
+	return from.Schema + "." + from.SourceName()
+}
+
+// resolve walk the expression checking identities, allowAlias for clauses
+// evaluated after projection that may refer to select column aliases.
+func (m *columnResolver) resolve(node expr.Node, allowAlias bool) error {
+	switch n := node.(type) {
+	case *expr.IdentityNode:
+		_, err := m.field(n, allowAlias)
+		return err
+	case *expr.BinaryNode:
+		for _, arg := range n.Args {
+			if err := m.resolve(arg, allowAlias); err != nil {
+				return err
+			}
+		}
+		return m.checkArithmetic(n, allowAlias)
+	case *expr.FuncNode:
+		for _, arg := range n.Args {
+			if err := m.resolve(arg, allowAlias); err != nil {
+				return err
+			}
+		}
+	case *expr.TriNode:
+		for _, arg := range n.Args {
+			if err := m.resolve(arg, allowAlias); err != nil {
+				return err
+			}
+		}
+	case *expr.ArrayNode:
+		for _, arg := range n.Args {
+			if err := m.resolve(arg, allowAlias); err != nil {
+				return err
+			}
+		}
+	case *expr.UnaryNode:
+		return m.resolve(n.Arg, allowAlias)
+	}
+	return nil
+}
+
+// field find the schema field for an identity, nil if it is not a column
+// we can validate (alias, nested path, keyword).
+func (m *columnResolver) field(n *expr.IdentityNode, allowAlias bool) (*schema.Field, error) {
+	if n.Quote == '\'' || n.Quote == '"' || n.IsBooleanIdentity() {
+		return nil, nil
+	}
+	name := n.Text
+	if name == "*" || strings.HasPrefix(name, "@") || strings.ToLower(name) == "null" {
+		return nil, nil
+	}
+	if allowAlias && m.aliases[strings.ToLower(name)] {
+		return nil, nil
+	}
+
+	sources := m.sources
+	if left, right, ok := n.LeftRight(); ok {
+		src := m.source(left)
+		if src == nil {
+			// not a source qualifier, possibly a path into nested data
+			return nil, nil
+		}
+		sources = []*resolveSource{src}
+		name = right
+	}
+	for _, src := range sources {
+		if f, ok := src.tbl.Field(name); ok {
+			return f, nil
+		}
+	}
+	return nil, m.unknown(n, name, sources)
+}
+
+// source find the source with given name or alias
+func (m *columnResolver) source(name string) *resolveSource {
+	for _, src := range m.sources {
+		if m.ic.Equal(src.from.Alias, name) || m.ic.Equal(src.from.Name, name) {
+			return src
+		}
+	}
+	return nil
+}
+
+func (m *columnResolver) unknown(n *expr.IdentityNode, name string, sources []*resolveSource) error {
+	tables := make([]string, len(sources))
+	suggestion, best := "", -1
+	for i, src := range sources {
+		tables[i] = src.tbl.NameOriginal
+		if tables[i] == "" {
+			tables[i] = src.tbl.Name
+		}
+		for _, f := range src.tbl.Fields {
+			d := editDistance(strings.ToLower(name), strings.ToLower(f.Name))
+			if d <= maxSuggestDistance(name) && (best < 0 || d < best) {
+				suggestion, best = f.Name, d
+			}
+		}
+	}
+	err := &ColumnError{
+		Column:     name,
+		Table:      strings.Join(tables, ", "),
+		Suggestion: suggestion,
+	}
+	m.position(err, n)
+	return err
+}
+
+// checkArithmetic  x + y etc only make sense on numeric (or string-ly typed
+// as sources such as csv are untyped) columns.
+func (m *columnResolver) checkArithmetic(n *expr.BinaryNode, allowAlias bool) error {
+	switch n.Operator.T {
+	case lex.TokenPlus, lex.TokenMinus, lex.TokenMultiply, lex.TokenDivide, lex.TokenModulus:
+	default:
+		return nil
+	}
+	for _, arg := range n.Args {
+		in, ok := arg.(*expr.IdentityNode)
+		if !ok {
+			continue
+		}
+		f, err := m.field(in, allowAlias)
+		if err != nil || f == nil {
+			continue
+		}
+		switch f.Type {
+		case value.BoolType, value.MapValueType, value.MapStringType, value.MapIntType,
+			value.MapNumberType, value.MapBoolType, value.MapTimeType,
+			value.SliceValueType, value.StringsType:
+			cerr := &ColumnError{
+				Column: in.Text,
+				Table:  fieldTable(f, m.sources),
+				Reason: fmt.Sprintf("invalid operator '%s' on type %s", n.Operator.V, f.Type),
+			}
+			m.position(cerr, in)
+			return cerr
+		}
+	}
+	return nil
+}
+
+func fieldTable(f *schema.Field, sources []*resolveSource) string {
+	for _, src := range sources {
+		if sf, _ := src.tbl.Field(f.Name); sf == f {
+			return src.tbl.Name
+		}
+	}
+	return ""
+}
+
+// position of the identity in the raw statement, of where it was parsed.
+// Identities of rewrites have no position so are the first whole-word
+// occurrence of it.
+func (m *columnResolver) position(err *ColumnError, n *expr.IdentityNode) {
+	err.Pos = -1
+	raw := m.stmt.Raw
+	word := n.OriginalText()
+	if raw == "" || word == "" {
+		return
+	}
+	if line, col := n.Position(); line > 0 {
+		idx := lineOffset(raw, line) + col - 1
+		if idx >= 0 && idx+len(word) <= len(raw) && strings.EqualFold(raw[idx:idx+len(word)], word) {
+			err.Pos, err.Line, err.Col = idx, line, col
+			return
+		}
+	}
+	lowerRaw, lowerWord := strings.ToLower(raw), strings.ToLower(word)
+	for start := 0; start < len(raw); {
+		idx := strings.Index(lowerRaw[start:], lowerWord)
+		if idx < 0 {
+			return
+		}
+		idx += start
+		end := idx + len(word)
+		if (idx == 0 || !isIdentByte(raw[idx-1])) && (end == len(raw) || !isIdentByte(raw[end])) {
+			err.Pos = idx
+			err.Line = strings.Count(raw[:idx], "\n") + 1
+			err.Col = idx - strings.LastIndex(raw[:idx], "\n")
+			return
+		}
+		start = idx + 1
+	}
+}
+
+// lineOffset the offset of the start of the 1 based line of raw, -1 if
+// raw has fewer lines
+func lineOffset(raw string, line int) int {
+	offset := 0
+	for ; line > 1; line-- {
+		nl := strings.IndexByte(raw[offset:], '\n')
+		if nl < 0 {
+			return -1
+		}
+		offset += nl + 1
+	}
+	return offset
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b == '.' || b == '`' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// maxSuggestDistance how many edits away a column may be to still be suggested
+func maxSuggestDistance(name string) int {
+	if d := len(name) / 3; d > 2 {
+		return d
+	}
+	return 2
+}
+
+// editDistance levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,70 @@
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	td "github.com/araddon/qlbridge/datasource/mockcsvtestdata"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+)
+
+type resolveTest struct {
+	q   string
+	err string // expected error, empty for none
+}
+
+var resolveTests = []resolveTest{
+	{`SELECT user_id, email FROM users WHERE referral_count > 2`, ""},
+	{`SELECT u.user_id, o.price FROM users AS u INNER JOIN orders AS o ON u.user_id = o.user_id`, ""},
+	{`SELECT user_id, count(*) AS ct FROM orders GROUP BY user_id ORDER BY ct`, ""},
+	{`SELECT USER_ID FROM users`, ""},
+	// not a source qualifier, may be nested data
+	{`SELECT user_id FROM users WHERE json.field = "x"`, ""},
+	{`SELECT usr_id FROM users`,
+		"unknown column 'usr_id' in table 'users', did you mean 'user_id'? at line 1 column 8"},
+	{"SELECT user_id\nFROM users\nWHERE emial = \"x\"",
+		"unknown column 'emial' in table 'users', did you mean 'email'? at line 3 column 7"},
+	{`SELECT user_id FROM users GROUP BY nothing_like_it`,
+		"unknown column 'nothing_like_it' in table 'users' at line 1 column 36"},
+	{`SELECT u.price FROM users AS u INNER JOIN orders AS o ON u.user_id = o.user_id`,
+		"unknown column 'price' in table 'users' at line 1 column 8"},
+	// the position of the failing identity, not of an alias or string of its name
+	{`SELECT email AS emial FROM users WHERE emial = "x"`,
+		"unknown column 'emial' in table 'users', did you mean 'email'? at line 1 column 40"},
+	{"SELECT user_id FROM users\nWHERE email = \"emial\" OR emial = \"x\"",
+		"unknown column 'emial' in table 'users', did you mean 'email'? at line 2 column 26"},
+}
+
+func TestResolveColumns(t *testing.T) {
+	td.LoadTestDataOnce()
+	for _, rt := range resolveTests {
+		ctx := td.TestContext(rt.q)
+		stmt, err := rel.ParseSqlSelect(rt.q)
+		assert.Tf(t, err == nil, "Must parse %s but got %v", rt.q, err)
+		err = plan.ResolveColumns(ctx, stmt)
+		if rt.err == "" {
+			assert.Tf(t, err == nil, "expected no error for %s but got %v", rt.q, err)
+			continue
+		}
+		assert.Tf(t, err != nil, "expected error for %s", rt.q)
+		_, isColErr := err.(*plan.ColumnError)
+		assert.Tf(t, isColErr, "expected *plan.ColumnError got %T", err)
+		assert.Equal(t, rt.err, err.Error())
+	}
+}
+
+func TestResolveColumnsExactCase(t *testing.T) {
+	td.LoadTestDataOnce()
+	q := `SELECT USER_ID FROM users`
+	ctx := td.TestContext(q)
+	ctx.Schema.SetIdentifierCase(schema.CaseExact)
+	defer ctx.Schema.SetIdentifierCase(schema.CaseFold)
+	stmt, err := rel.ParseSqlSelect(q)
+	assert.Tf(t, err == nil, "Must parse %s but got %v", q, err)
+	err = plan.ResolveColumns(ctx, stmt)
+	assert.Tf(t, err != nil, "expected error for %s", q)
+	assert.Equal(t, "unknown column 'USER_ID' in table 'users', did you mean 'user_id'? at line 1 column 8", err.Error())
+}
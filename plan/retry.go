@@ -0,0 +1,144 @@
+package plan
+
+import (
+	"io"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/schema"
+)
+
+// RetryPolicy how scan operators retry the calls to a source that failed
+// with a transient error (a backend restarting, a dropped connection), so a
+// blip on a backend does not fail a long running query.  Opening the source
+// is retried as is, a scan that failed part way is re-opened and skips the
+// rows it already read.
+//
+//   Open()  -> err (transient) -> wait Backoff   -> Open() -> ok
+//   Next()  -> err (transient) -> wait Backoff*2 -> Open(), skip read rows -> Next()
+//   Next()  -> err (not transient, or MaxAttempts) -> query fails with err
+type RetryPolicy struct {
+	// MaxAttempts of each call including the first, <= 1 for no retries
+	MaxAttempts int
+	// Backoff the wait before the first retry, doubled for each retry after
+	Backoff time.Duration
+	// MaxBackoff the most to wait between retries, <= 0 for no cap
+	MaxBackoff time.Duration
+	// Retryable classify which errors are transient, nil for IsTransient
+	Retryable func(error) bool
+}
+
+// Retry should a call that has failed attempts times, the last with err,
+// be tried again
+func (m *RetryPolicy) Retry(attempts int, err error) bool {
+	if m == nil || err == nil || attempts >= m.MaxAttempts {
+		return false
+	}
+	if m.Retryable != nil {
+		return m.Retryable(err)
+	}
+	return IsTransient(err)
+}
+
+// Delay the wait before retrying a call that has failed attempts times
+func (m *RetryPolicy) Delay(attempts int) time.Duration {
+	if m == nil || m.Backoff <= 0 || attempts < 1 {
+		return 0
+	}
+	d := m.Backoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if m.MaxBackoff > 0 && d >= m.MaxBackoff {
+			break
+		}
+	}
+	if m.MaxBackoff > 0 && d > m.MaxBackoff {
+		d = m.MaxBackoff
+	}
+	return d
+}
+
+// Wait the Delay before retrying a call that has failed attempts times,
+// returns false if the query is cancelled or quit closed first
+func (m *RetryPolicy) Wait(ctx *Context, quit <-chan bool, attempts int) bool {
+	var done <-chan struct{}
+	if ctx != nil && ctx.Context != nil {
+		done = ctx.Context.Done()
+	}
+	timer := time.NewTimer(m.Delay(attempts))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-quit:
+		return false
+	case <-done:
+		return false
+	}
+}
+
+// RetryPolicy the Retry policy of this query, nil if calls are not retried
+func (m *Context) RetryPolicy() *RetryPolicy {
+	if m == nil || m.Retry == nil || m.Retry.MaxAttempts <= 1 {
+		return nil
+	}
+	return m.Retry
+}
+
+// OpenRetry open a connection to a source, retrying transient errors per
+// the Retry policy of ctx until quit (optional) is closed
+func OpenRetry(ctx *Context, quit <-chan bool, name string, open func() (schema.Conn, error)) (schema.Conn, error) {
+	policy := ctx.RetryPolicy()
+	for attempts := 1; ; attempts++ {
+		conn, err := open()
+		if err == nil {
+			return conn, nil
+		}
+		if !policy.Retry(attempts, err) {
+			return nil, err
+		}
+		u.Warnf("retry %d open of %s after error: %v", attempts, name, err)
+		if !policy.Wait(ctx, quit, attempts) {
+			return nil, err
+		}
+	}
+}
+
+type transientError struct {
+	error
+}
+
+func (m *transientError) Temporary() bool { return true }
+
+// Transient mark err as transient, for sources to tell the default
+// classifier the call may succeed if retried
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err}
+}
+
+// IsTransient the default classifier of a RetryPolicy: errors marked
+// Transient, network errors that are temporary or timed out, and a
+// connection closed part way through a response.
+func IsTransient(err error) bool {
+	switch et := err.(type) {
+	case interface {
+		Temporary() bool
+	}:
+		if et.Temporary() {
+			return true
+		}
+	}
+	switch et := err.(type) {
+	case interface {
+		Timeout() bool
+	}:
+		if et.Timeout() {
+			return true
+		}
+	}
+	return err == io.ErrUnexpectedEOF
+}
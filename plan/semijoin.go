@@ -0,0 +1,167 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/rel"
+)
+
+var _ = u.EMPTY
+
+// walkSemiJoin plan a where that is a sub-query as a semi-join (or anti-join
+// when negated) of the outer rows against the sub-query results.
+//
+//   WHERE id IN (SELECT article_id FROM comments)
+//   WHERE id NOT IN (SELECT article_id FROM comments)
+//   WHERE EXISTS (SELECT 1 FROM comments AS c WHERE c.article_id = a.id)
+//   WHERE NOT EXISTS (SELECT 1 FROM comments AS c WHERE c.article_id = a.id)
+//   WHERE id = (SELECT max(article_id) FROM comments)
+//
+// The sub-query of = is a scalar, an error if it returns more than one row.
+// A correlated EXISTS is de-correlated by pulling the equality between the
+// inner and outer columns out of the sub-query where, so the sub-query is
+// run once and selects the inner key.
+func (m *PlannerDefault) walkSemiJoin(p *Select) error {
+
+	where := p.Stmt.Where
+	sub := where.Source.Copy()
+
+	var lhs, rhs expr.Node
+	in := false
+	switch where.Op {
+	case lex.TokenIN, lex.TokenEqual:
+		if len(sub.Columns) != 1 || sub.Columns[0].Star || sub.Columns[0].Expr == nil {
+			return fmt.Errorf("sub-query must select exactly one column: %s", where.Source)
+		}
+		if where.Lhs == nil {
+			return fmt.Errorf("missing left hand side of sub-query: %s", where)
+		}
+		in = true
+		lhs = unqualify(p.Stmt, where.Lhs)
+		rhs = sub.Columns[0].Expr
+	case lex.TokenExists:
+		lhs, rhs = decorrelate(p.Stmt, sub)
+		if lhs != nil {
+			m.Ctx.RuleApplied("decorrelate")
+		} else if sub.Limit == 0 {
+			// un-correlated, we only need to know if there are any rows
+			sub.Limit = 1
+			m.Ctx.RuleApplied("exists-limit")
+		}
+	default:
+		u.Warnf("Found un-supported subquery: %#v", where)
+		return fmt.Errorf("unsupported sub-query where %s: %v", where, ErrNotImplemented)
+	}
+
+	// The sub-query is planned with its own final projection, which must not
+	// replace the projection of the outer statement
+	proj := m.Ctx.Projection
+	subPlan := &Select{Stmt: sub, PlanBase: NewPlanBase(false), Ctx: m.Ctx}
+	if err := m.Planner.WalkSelect(subPlan); err != nil {
+		return err
+	}
+	m.Ctx.Projection = proj
+
+	sj := NewSemiJoin(p.Stmt, subPlan, lhs, rhs)
+	sj.Anti = where.Negate
+	sj.In = in
+	sj.Single = where.Op == lex.TokenEqual
+	p.Add(sj)
+	if sj.Anti {
+		m.Ctx.RuleApplied("anti-join")
+	} else {
+		m.Ctx.RuleApplied("semi-join")
+	}
+	return nil
+}
+
+// decorrelate find a conjunct of form  inner.col = outer.col  in the
+// sub-query where, remove it and make the sub-query select inner.col.
+// Returns nil keys if the sub-query is not correlated this way.
+func decorrelate(outer, sub *rel.SqlSelect) (outerKey, innerKey expr.Node) {
+	if sub.Where == nil || sub.Where.Expr == nil {
+		return nil, nil
+	}
+	conjuncts := andTerms(sub.Where.Expr)
+	for i, c := range conjuncts {
+		bn, ok := c.(*expr.BinaryNode)
+		if !ok || len(bn.Args) != 2 {
+			continue
+		}
+		switch bn.Operator.T {
+		case lex.TokenEqual, lex.TokenEqualEqual:
+		default:
+			continue
+		}
+		l, lok := bn.Args[0].(*expr.IdentityNode)
+		r, rok := bn.Args[1].(*expr.IdentityNode)
+		if !lok || !rok {
+			continue
+		}
+		lOuter, rOuter := isOuterRef(outer, sub, l), isOuterRef(outer, sub, r)
+		if lOuter == rOuter {
+			continue
+		}
+		if lOuter {
+			l, r = r, l
+		}
+
+		var rest expr.Node
+		for ci, c := range conjuncts {
+			if ci != i {
+				rest = andNodes(rest, c)
+			}
+		}
+		if rest == nil {
+			sub.Where = nil
+		} else {
+			sub.Where.Expr = rest
+		}
+
+		name := l.Text
+		if _, right, hasLeft := l.LeftRight(); hasLeft && len(sub.From) == 1 {
+			name = right
+		}
+		innerKey = expr.NewIdentityNodeVal(name)
+		sub.Columns = rel.Columns{rel.NewColumn(name)}
+		return unqualify(outer, r), innerKey
+	}
+	return nil, nil
+}
+
+// isOuterRef is this identity qualified by a source of the outer statement
+// (and not shadowed by a source of the sub-query)
+func isOuterRef(outer, sub *rel.SqlSelect, n *expr.IdentityNode) bool {
+	left, _, hasLeft := n.LeftRight()
+	if !hasLeft {
+		return false
+	}
+	return hasSource(outer, left) && !hasSource(sub, left)
+}
+
+func hasSource(stmt *rel.SqlSelect, name string) bool {
+	for _, from := range stmt.From {
+		if strings.EqualFold(from.Alias, name) || strings.EqualFold(from.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// unqualify rows of a single source are keyed by column name only so
+// `a.id` must be evaluated as `id`
+func unqualify(stmt *rel.SqlSelect, node expr.Node) expr.Node {
+	in, ok := node.(*expr.IdentityNode)
+	if !ok || len(stmt.From) != 1 {
+		return node
+	}
+	if left, right, hasLeft := in.LeftRight(); hasLeft && hasSource(stmt, left) {
+		return expr.NewIdentityNodeVal(right)
+	}
+	return node
+}
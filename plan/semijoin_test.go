@@ -0,0 +1,62 @@
+package plan_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	td "github.com/araddon/qlbridge/datasource/mockcsvtestdata"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+)
+
+type semiJoinTest struct {
+	q       string
+	explain string // task:detail of the semi-join step
+	sub     string // the sub-query as planned
+}
+
+var semiJoinTests = []semiJoinTest{
+	{`SELECT user_id FROM users WHERE user_id IN (SELECT user_id FROM orders)`,
+		"semijoin:user_id = user_id", "SELECT user_id FROM orders"},
+	{`SELECT user_id FROM users AS u WHERE u.user_id NOT IN (SELECT user_id FROM orders)`,
+		"antijoin:user_id = user_id", "SELECT user_id FROM orders"},
+	{`SELECT user_id FROM users AS u WHERE EXISTS (SELECT order_id FROM orders AS o WHERE o.user_id = u.user_id AND price > 30)`,
+		"semijoin:user_id = user_id", "SELECT user_id FROM orders AS o WHERE price > 30"},
+	{`SELECT user_id FROM users AS u WHERE NOT EXISTS (SELECT order_id FROM orders AS o WHERE u.user_id = o.user_id)`,
+		"antijoin:user_id = user_id", "SELECT user_id FROM orders AS o"},
+	{`SELECT user_id FROM users WHERE EXISTS (SELECT order_id FROM orders WHERE price > 30)`,
+		"semijoin:exists", "SELECT order_id FROM orders WHERE price > 30 LIMIT 1"},
+}
+
+func TestSemiJoinPlans(t *testing.T) {
+	td.LoadTestDataOnce()
+	for _, st := range semiJoinTests {
+		ctx := td.TestContext(st.q)
+		stmt, err := rel.ParseSql(st.q)
+		assert.Tf(t, err == nil, "Must parse %s but got %v", st.q, err)
+		pln, err := plan.WalkStmt(ctx, stmt, plan.NewPlanner(ctx))
+		assert.Tf(t, err == nil, "no error for %s but got %v", st.q, err)
+
+		var sj *plan.SemiJoin
+		for _, task := range pln.Children() {
+			if s, ok := task.(*plan.SemiJoin); ok {
+				sj = s
+			}
+		}
+		assert.Tf(t, sj != nil, "expected semi-join for %s", st.q)
+		steps := plan.ExplainTask(sj)
+		assert.Equal(t, st.explain, fmt.Sprintf("%s:%s", steps[0].Task, steps[0].Detail))
+		assert.Equal(t, st.sub, sj.Sub.Stmt.String())
+	}
+
+	// IN requires a single column sub-query
+	q := `SELECT user_id FROM users WHERE user_id IN (SELECT user_id, price FROM orders)`
+	ctx := td.TestContext(q)
+	stmt, err := rel.ParseSql(q)
+	assert.Tf(t, err == nil, "Must parse %s but got %v", q, err)
+	_, err = plan.WalkStmt(ctx, stmt, plan.NewPlanner(ctx))
+	assert.Tf(t, err != nil && strings.Contains(err.Error(), "exactly one column"), "expected error got %v", err)
+}
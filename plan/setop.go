@@ -0,0 +1,119 @@
+package plan
+
+import (
+	"fmt"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/value"
+)
+
+var _ = u.EMPTY
+
+// walkSetOperation plan a UNION, INTERSECT or EXCEPT.  Each select is planned
+// on its own, with its own final projection, and their rows combined by
+// SetOperation tasks.  INTERSECT binds tighter than UNION and EXCEPT which
+// are evaluated left to right:
+//
+//   a UNION b INTERSECT c EXCEPT d   =>  (a UNION (b INTERSECT c)) EXCEPT d
+//
+// The ORDER BY, LIMIT of the statement apply to the combined rows, which are
+// named by the columns of the first select.
+func (m *PlannerDefault) walkSetOperation(p *Select) error {
+
+	sels := p.Stmt.SetOpSelects()
+	inputs := make([]Task, len(sels))
+	projs := make([]*Projection, len(sels))
+	for i, stmt := range sels {
+		// each select sets its own final projection
+		m.Ctx.Projection = nil
+		sub := &Select{Stmt: stmt, PlanBase: NewPlanBase(false), Ctx: m.Ctx}
+		if err := m.Planner.WalkSelect(sub); err != nil {
+			return err
+		}
+		if m.Ctx.Projection == nil || m.Ctx.Projection.Proj == nil {
+			return fmt.Errorf("could not project select %d of set operation: %s", i+1, stmt)
+		}
+		inputs[i], projs[i] = sub, m.Ctx.Projection
+	}
+
+	first := projs[0].Proj.Columns
+	for i, proj := range projs[1:] {
+		if len(proj.Proj.Columns) != len(first) {
+			return fmt.Errorf("each select of %s must have the same number of columns, %d != %d",
+				p.Stmt.SetOps[i].Op, len(first), len(proj.Proj.Columns))
+		}
+	}
+	names := make([]string, len(first))
+	types := make([]value.ValueType, len(first))
+	for i, col := range first {
+		names[i] = col.As
+		types[i] = unifySetOpType(projs, i)
+		if types[i] != value.UnknownType {
+			col.Type = types[i]
+		}
+	}
+
+	// terms are joined by UNION, EXCEPT, each term a chain of INTERSECT
+	terms := make([]Task, 0, len(inputs))
+	termOps := make([]*rel.SqlSetOp, 0, len(inputs))
+	cur := inputs[0]
+	for i, op := range p.Stmt.SetOps {
+		if op.Op == lex.TokenIntersect {
+			cur = newSetOperation(op, cur, inputs[i+1], names, types)
+			continue
+		}
+		terms = append(terms, cur)
+		termOps = append(termOps, op)
+		cur = inputs[i+1]
+	}
+	terms = append(terms, cur)
+	root := terms[0]
+	for i, op := range termOps {
+		root = newSetOperation(op, root, terms[i+1], names, types)
+	}
+	p.Add(root)
+	m.Ctx.RuleApplied("set-operation")
+
+	m.addOrder(p, false)
+
+	// The final projection selects the combined columns by name, and limits
+	out := &rel.SqlSelect{Limit: p.Stmt.Limit}
+	for i, name := range names {
+		out.Columns = append(out.Columns, &rel.Column{As: name, Expr: expr.NewIdentityNodeVal(name), Index: i, ParentIndex: i})
+	}
+	proj := &Projection{PlanBase: NewPlanBase(false), Final: true, P: p, Stmt: out, Proj: projs[0].Proj}
+	p.Add(proj)
+	m.Ctx.Projection = proj
+	return nil
+}
+
+func newSetOperation(op *rel.SqlSetOp, left, right Task, names []string, types []value.ValueType) *SetOperation {
+	so := NewSetOperation(op, left, right)
+	so.Columns = names
+	so.Types = types
+	return so
+}
+
+// unifySetOpType the type column i of each select is converted to.  Numbers
+// are widened so an int of one select equals the same float of another,
+// other columns are left as is.
+func unifySetOpType(projs []*Projection, i int) value.ValueType {
+	widen := false
+	for _, proj := range projs {
+		switch proj.Proj.Columns[i].Type {
+		case value.IntType:
+		case value.NumberType:
+			widen = true
+		default:
+			return value.UnknownType
+		}
+	}
+	if widen {
+		return value.NumberType
+	}
+	return value.UnknownType
+}
@@ -0,0 +1,371 @@
+package plan
+
+import (
+	"strconv"
+	"strings"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/rel"
+)
+
+var _ = u.EMPTY
+
+// SimplifyStatement runs the expression simplifier over the WHERE and HAVING
+// clauses of a select statement.  Clauses that simplify to an always-true
+// literal are removed entirely.
+func SimplifyStatement(stmt *rel.SqlSelect) {
+	if stmt == nil {
+		return
+	}
+	if stmt.Where != nil && stmt.Where.Expr != nil {
+		stmt.Where.Expr = SimplifyExpr(stmt.Where.Expr)
+		if isBoolLiteral(stmt.Where.Expr, true) {
+			stmt.Where = nil
+		}
+	}
+	if stmt.Having != nil {
+		stmt.Having = SimplifyExpr(stmt.Having)
+		if isBoolLiteral(stmt.Having, true) {
+			stmt.Having = nil
+		}
+	}
+}
+
+// SimplifyExpr rewrites an expression into an equivalent but cheaper to evaluate form
+//
+//   - constant sub-expressions are folded       (1 + 2)          =>  3
+//   - always-true/false predicates are removed  x = 5 AND true   =>  x = 5
+//   - nested AND/OR chains are flattened, duplicate terms dropped
+//   - single element IN lists become equality   x IN ("a")       =>  x = "a"
+//
+// Functions are never folded as they may be non-deterministic (now()) or aggregates,
+// and only literal arithmetic/comparisons are folded, the rest is left to the vm.
+func SimplifyExpr(n expr.Node) expr.Node {
+	switch nt := n.(type) {
+	case *expr.BinaryNode:
+		return simplifyBinary(nt)
+	case *expr.UnaryNode:
+		return simplifyUnary(nt)
+	case *expr.TriNode:
+		for i, arg := range nt.Args {
+			nt.Args[i] = SimplifyExpr(arg)
+		}
+		return foldConstant(nt)
+	case *expr.FuncNode:
+		for i, arg := range nt.Args {
+			nt.Args[i] = SimplifyExpr(arg)
+		}
+	}
+	return n
+}
+
+func simplifyBinary(n *expr.BinaryNode) expr.Node {
+
+	switch n.Operator.T {
+	case lex.TokenLogicAnd, lex.TokenAnd, lex.TokenLogicOr, lex.TokenOr:
+		return simplifyLogical(n)
+	case lex.TokenIN:
+		n.Args[0] = SimplifyExpr(n.Args[0])
+		if an, ok := n.Args[1].(*expr.ArrayNode); ok {
+			for i, arg := range an.Args {
+				an.Args[i] = SimplifyExpr(arg)
+			}
+			if len(an.Args) == 1 {
+				eq := expr.NewBinaryNode(lex.Token{T: lex.TokenEqual, V: "="}, n.Args[0], an.Args[0])
+				eq.Paren = n.Paren
+				return foldConstant(eq)
+			}
+		}
+		return foldConstant(n)
+	}
+
+	for i, arg := range n.Args {
+		n.Args[i] = SimplifyExpr(arg)
+	}
+	return foldConstant(n)
+}
+
+// simplifyLogical flattens a chain of the same AND/OR operator into
+// its terms, simplifies each, removes identity/duplicate terms and
+// short-circuits on the absorbing literal.
+func simplifyLogical(n *expr.BinaryNode) expr.Node {
+
+	isAnd := n.Operator.T == lex.TokenLogicAnd || n.Operator.T == lex.TokenAnd
+
+	terms := make([]expr.Node, 0, 4)
+	seen := make(map[string]struct{})
+	for _, term := range flattenLogical(n, nil) {
+		term = SimplifyExpr(term)
+		if bn, ok := term.(*expr.BinaryNode); ok && sameLogicalOp(bn.Operator.T, n.Operator.T) {
+			// simplifying a term may have surfaced a nested chain of our own operator
+			for _, sub := range flattenLogical(bn, nil) {
+				terms, seen = appendTerm(terms, seen, sub)
+			}
+			continue
+		}
+		terms, seen = appendTerm(terms, seen, term)
+	}
+
+	out := make([]expr.Node, 0, len(terms))
+	for _, term := range terms {
+		if isBoolLiteral(term, !isAnd) {
+			// false AND x => false,   true OR x => true
+			return boolNode(!isAnd)
+		}
+		if isBoolLiteral(term, isAnd) {
+			// true AND x => x,   false OR x => x
+			continue
+		}
+		out = append(out, term)
+	}
+
+	if len(out) == 0 {
+		return boolNode(isAnd)
+	}
+
+	var result expr.Node = out[0]
+	for _, term := range out[1:] {
+		if bn, ok := term.(*expr.BinaryNode); ok && sameLogicalOp(bn.Operator.T, n.Operator.T) {
+			bn.Paren = false
+		}
+		result = expr.NewBinaryNode(n.Operator, result, term)
+	}
+	if bn, ok := result.(*expr.BinaryNode); ok && len(out) > 1 {
+		bn.Paren = n.Paren
+	}
+	return result
+}
+
+func flattenLogical(n *expr.BinaryNode, terms []expr.Node) []expr.Node {
+	for _, arg := range n.Args {
+		if bn, ok := arg.(*expr.BinaryNode); ok && sameLogicalOp(bn.Operator.T, n.Operator.T) {
+			terms = flattenLogical(bn, terms)
+			continue
+		}
+		terms = append(terms, arg)
+	}
+	return terms
+}
+
+// andTerms the AND-ed terms of node, node itself if it is not an AND
+func andTerms(node expr.Node) []expr.Node {
+	if bn, ok := node.(*expr.BinaryNode); ok && sameLogicalOp(bn.Operator.T, lex.TokenLogicAnd) {
+		return flattenLogical(bn, nil)
+	}
+	return []expr.Node{node}
+}
+
+func appendTerm(terms []expr.Node, seen map[string]struct{}, term expr.Node) ([]expr.Node, map[string]struct{}) {
+	key := term.String()
+	if _, dupe := seen[key]; dupe {
+		return terms, seen
+	}
+	seen[key] = struct{}{}
+	return append(terms, term), seen
+}
+
+func sameLogicalOp(a, b lex.TokenType) bool {
+	switch a {
+	case lex.TokenLogicAnd, lex.TokenAnd:
+		return b == lex.TokenLogicAnd || b == lex.TokenAnd
+	case lex.TokenLogicOr, lex.TokenOr:
+		return b == lex.TokenLogicOr || b == lex.TokenOr
+	}
+	return false
+}
+
+func simplifyUnary(n *expr.UnaryNode) expr.Node {
+	n.Arg = SimplifyExpr(n.Arg)
+	if n.Operator.T == lex.TokenNegate {
+		switch arg := n.Arg.(type) {
+		case *expr.IdentityNode:
+			if arg.IsBooleanIdentity() {
+				return boolNode(!arg.Bool())
+			}
+		case *expr.BinaryNode:
+			// NOT x IN ("a")  was rewritten to  NOT x = "a"  =>   x != "a"
+			if arg.Operator.T == lex.TokenEqual || arg.Operator.T == lex.TokenEqualEqual {
+				ne := expr.NewBinaryNode(lex.Token{T: lex.TokenNE, V: "!="}, arg.Args[0], arg.Args[1])
+				ne.Paren = arg.Paren
+				return ne
+			}
+		}
+	}
+	return foldConstant(n)
+}
+
+// foldConstant evaluates an expression whose arguments are all literals
+// (numbers, strings, booleans) and replaces it with its literal result.
+// Anything it does not understand is left for the vm to evaluate at runtime.
+func foldConstant(n expr.Node) expr.Node {
+	switch nt := n.(type) {
+	case *expr.BinaryNode:
+		switch lhs := nt.Args[0].(type) {
+		case *expr.NumberNode:
+			switch rhs := nt.Args[1].(type) {
+			case *expr.NumberNode:
+				if folded := foldNumbers(nt.Operator.T, lhs, rhs); folded != nil {
+					return folded
+				}
+			case *expr.ArrayNode:
+				if folded := foldIn(nt.Operator.T, lhs, rhs); folded != nil {
+					return folded
+				}
+			}
+		case *expr.StringNode:
+			switch rhs := nt.Args[1].(type) {
+			case *expr.StringNode:
+				// the vm only compares strings for (in)equality, it errors on < >
+				switch nt.Operator.T {
+				case lex.TokenEqual, lex.TokenEqualEqual, lex.TokenNE:
+					cmp, _ := compareResult(nt.Operator.T, strings.Compare(lhs.Text, rhs.Text))
+					return boolNode(cmp)
+				}
+			case *expr.ArrayNode:
+				if folded := foldIn(nt.Operator.T, lhs, rhs); folded != nil {
+					return folded
+				}
+			}
+		case *expr.IdentityNode:
+			if rhs, ok := nt.Args[1].(*expr.IdentityNode); ok && lhs.IsBooleanIdentity() && rhs.IsBooleanIdentity() {
+				switch nt.Operator.T {
+				case lex.TokenEqual, lex.TokenEqualEqual:
+					return boolNode(lhs.Bool() == rhs.Bool())
+				case lex.TokenNE:
+					return boolNode(lhs.Bool() != rhs.Bool())
+				}
+			}
+		}
+	case *expr.UnaryNode:
+		if nt.Operator.T == lex.TokenMinus {
+			if nn, ok := nt.Arg.(*expr.NumberNode); ok {
+				if nn.IsInt && float64(nn.Int64) == nn.Float64 {
+					return numberNode(strconv.FormatInt(-nn.Int64, 10))
+				}
+				return numberNode(strconv.FormatFloat(-nn.Float64, 'f', -1, 64))
+			}
+		}
+	case *expr.TriNode:
+		if nt.Operator.T != lex.TokenBetween {
+			return n
+		}
+		val, ok1 := nt.Args[0].(*expr.NumberNode)
+		lower, ok2 := nt.Args[1].(*expr.NumberNode)
+		upper, ok3 := nt.Args[2].(*expr.NumberNode)
+		if ok1 && ok2 && ok3 {
+			return boolNode(val.Float64 >= lower.Float64 && val.Float64 <= upper.Float64)
+		}
+	}
+	return n
+}
+
+func foldNumbers(op lex.TokenType, lhs, rhs *expr.NumberNode) expr.Node {
+	bothInt := lhs.IsInt && rhs.IsInt && float64(lhs.Int64) == lhs.Float64 && float64(rhs.Int64) == rhs.Float64
+	a, b := lhs.Float64, rhs.Float64
+	switch op {
+	case lex.TokenPlus:
+		if bothInt {
+			return numberNode(strconv.FormatInt(lhs.Int64+rhs.Int64, 10))
+		}
+		return numberNode(strconv.FormatFloat(a+b, 'f', -1, 64))
+	case lex.TokenMinus:
+		if bothInt {
+			return numberNode(strconv.FormatInt(lhs.Int64-rhs.Int64, 10))
+		}
+		return numberNode(strconv.FormatFloat(a-b, 'f', -1, 64))
+	case lex.TokenStar, lex.TokenMultiply:
+		if bothInt {
+			return numberNode(strconv.FormatInt(lhs.Int64*rhs.Int64, 10))
+		}
+		return numberNode(strconv.FormatFloat(a*b, 'f', -1, 64))
+	case lex.TokenDivide, lex.TokenModulus:
+		// leave division by zero and integer/float semantics to the vm
+		return nil
+	}
+	if cmp, ok := compareResult(op, compareFloats(a, b)); ok {
+		return boolNode(cmp)
+	}
+	return nil
+}
+
+// foldIn evaluates   literal IN (literal, literal, ...)   of literals all of
+// the type of the left hand side, the vm coerces mixed types  3 IN ("3")
+func foldIn(op lex.TokenType, lhs expr.Node, arr *expr.ArrayNode) expr.Node {
+	if op != lex.TokenIN {
+		return nil
+	}
+	found := false
+	for _, arg := range arr.Args {
+		switch at := arg.(type) {
+		case *expr.NumberNode:
+			ln, ok := lhs.(*expr.NumberNode)
+			if !ok {
+				return nil
+			}
+			found = found || ln.Float64 == at.Float64
+		case *expr.StringNode:
+			ls, ok := lhs.(*expr.StringNode)
+			if !ok {
+				return nil
+			}
+			found = found || ls.Text == at.Text
+		default:
+			// not all literals, can't be sure it is not a match
+			return nil
+		}
+	}
+	return boolNode(found)
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareResult(op lex.TokenType, cmp int) (bool, bool) {
+	switch op {
+	case lex.TokenEqual, lex.TokenEqualEqual:
+		return cmp == 0, true
+	case lex.TokenNE:
+		return cmp != 0, true
+	case lex.TokenGT:
+		return cmp > 0, true
+	case lex.TokenGE:
+		return cmp >= 0, true
+	case lex.TokenLT:
+		return cmp < 0, true
+	case lex.TokenLE:
+		return cmp <= 0, true
+	}
+	return false, false
+}
+
+func numberNode(text string) expr.Node {
+	nn, err := expr.NewNumberStr(text)
+	if err != nil {
+		return nil
+	}
+	return nn
+}
+
+func boolNode(b bool) *expr.IdentityNode {
+	if b {
+		return expr.NewIdentityNodeVal("true")
+	}
+	return expr.NewIdentityNodeVal("false")
+}
+
+func isBoolLiteral(n expr.Node, b bool) bool {
+	if in, ok := n.(*expr.IdentityNode); ok && in.IsBooleanIdentity() {
+		return in.Bool() == b
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+)
+
+type simplifyTest struct {
+	in  string
+	out string
+}
+
+var simplifyTests = []simplifyTest{
+	{`1 + 2`, `3`},
+	{`x = 1 + 2`, `x = 3`},
+	{`x = 5 AND true`, `x = 5`},
+	{`x = 5 AND 1 = 1`, `x = 5`},
+	{`x = 5 AND 1 = 2`, `false`},
+	{`x = 5 OR 1 = 1`, `true`},
+	{`x = 5 OR false`, `x = 5`},
+	{`x = 5 AND (y = 2 AND x = 5)`, `x = 5 AND y = 2`},
+	{`x IN ("a")`, `x = "a"`},
+	{`x NOT IN ("a")`, `x != "a"`},
+	{`x IN ("a", "b")`, `x IN ("a", "b")`},
+	{`NOT true`, `false`},
+	{`"a" = "b" OR x = 1`, `x = 1`},
+	{`5 BETWEEN 1 AND 10`, `true`},
+	{`10 BETWEEN 1 AND 10`, `true`},
+	{`11 BETWEEN 1 AND 10`, `false`},
+	{`3 IN (1, 2, 3)`, `true`},
+	{`4 IN (1, 2, 3)`, `false`},
+	{`"b" IN ("a", "b")`, `true`},
+	// the vm coerces literals of other types, left to it
+	{`3 IN (1, 2, "3")`, `3 IN (1, 2, "3")`},
+	{`"3" IN (3)`, `"3" = 3`},
+	{`"a" != "b"`, `true`},
+	// the vm does not order strings
+	{`"10" > "9"`, `"10" > "9"`},
+	{`"a" <= "b"`, `"a" <= "b"`},
+	{`x / 0 > 1`, `x / 0 > 1`},
+	{`x > now()`, `x > now()`},
+}
+
+func TestSimplifyExpr(t *testing.T) {
+	for _, st := range simplifyTests {
+		n, err := expr.ParseExpression(st.in)
+		assert.Tf(t, err == nil, "parse err=%v for %s", err, st.in)
+		out := plan.SimplifyExpr(n.Root)
+		assert.Tf(t, out.String() == st.out, "expected %q got %q for %q", st.out, out.String(), st.in)
+	}
+}
+
+func TestSimplifyStatement(t *testing.T) {
+	stmt, err := rel.ParseSqlSelect(`SELECT a FROM users WHERE 1 = 1 AND true`)
+	assert.T(t, err == nil)
+	plan.SimplifyStatement(stmt)
+	assert.Tf(t, stmt.Where == nil, "expected where removed: %v", stmt.Where)
+}
@@ -146,8 +146,10 @@ func RewriteShowAsSelect(stmt *rel.SqlShow, ctx *Context) (*rel.SqlSelect, error
 		switch scope {
 		case "session", "":
 			scope = "session"
+		case "slave":
+			return nil, fmt.Errorf("Unsupported show slave status")
 		}
-		sqlStatement = fmt.Sprintf("select Variable_name, Value from `context`.`%s_variables`;", scope)
+		sqlStatement = fmt.Sprintf("select Variable_name, Value from `context`.`%s_status`;", scope)
 		/*
 			mysql> show global status;
 			+--------------------------------+-----------------+
@@ -177,6 +179,16 @@ func RewriteShowAsSelect(stmt *rel.SqlShow, ctx *Context) (*rel.SqlSelect, error
 			| FEDERATED          | NO      | Federated MySQL storage engine                                             | NULL         | NULL | NULL       |
 			+--------------------+---------+----------------------------------------------------------------------------+--------------+------+------------+
 		*/
+	case "processlist":
+		// SHOW [FULL] PROCESSLIST
+		sqlStatement = "select Id, User, Host, db, Command, `Time`, State, Info from `context`.`processlist`;"
+	case "grants":
+		// SHOW GRANTS [FOR user]
+		user := ctx.User()
+		if stmt.Identity != "" && stmt.Identity != user {
+			return nil, fmt.Errorf("There is no such grant defined for user %q", stmt.Identity)
+		}
+		sqlStatement = fmt.Sprintf("select Grants AS `Grants for %s@%%` from `context`.`grants`;", user)
 	case "procedure", "function":
 		/*
 			show procuedure status;
@@ -186,6 +198,12 @@ func RewriteShowAsSelect(stmt *rel.SqlShow, ctx *Context) (*rel.SqlSelect, error
 		*/
 		sqlStatement = fmt.Sprintf("SELECT Db, Name, Type, Definer, Modified, Created, Security_type, Comment, character_set_client, `collation_connection`, `Database Collation` from `context`.`%ss`;", showType)
 
+	case "functions":
+		// SHOW FUNCTIONS [like_or_where]   see expr.FuncsInfo
+		sqlStatement = "select Name, Category, Signature, Type, Returns, Description from `context`.`funcs`;"
+	case "explain":
+		// EXPLAIN SELECT ...   the plan to explain is on the context
+		sqlStatement = "select id, parent_id, task, detail from `context`.`explain`;"
 	default:
 		u.Warnf("unhandled sql rewrite statement %s", raw)
 		return nil, fmt.Errorf("Unrecognized:   %s", raw)
@@ -228,6 +246,10 @@ func RewriteShowAsSelect(stmt *rel.SqlShow, ctx *Context) (*rel.SqlSelect, error
 	return sel, nil
 }
 func RewriteDescribeAsSelect(stmt *rel.SqlDescribe, ctx *Context) (*rel.SqlSelect, error) {
+	if stmt.Stmt != nil {
+		s := &rel.SqlShow{ShowType: "explain", Raw: stmt.Raw}
+		return RewriteShowAsSelect(s, ctx)
+	}
 	s := &rel.SqlShow{ShowType: "columns", Identity: stmt.Identity, Raw: stmt.Raw}
 	return RewriteShowAsSelect(s, ctx)
 }
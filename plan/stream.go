@@ -0,0 +1,159 @@
+package plan
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/rel"
+)
+
+var (
+	_ CheckpointStore = (*memCheckpoints)(nil)
+	_ CheckpointStore = (*fileCheckpoints)(nil)
+)
+
+// TumbleWindow the tumbling windows of event time a group by aggregates
+// over, from a group by on the tumble() (or timebucket()) function:
+//
+//   SELECT tumble(ts, "1m") AS minute, url, count(*) AS ct
+//   FROM clicks GROUP BY tumble(ts, "1m"), url
+//
+// or date_trunc() of a unit of fixed length, second to week.
+//
+// Each row is aggregated into the window of Size its Time falls in, and the
+// groups of a window are emitted once the rows have moved on past its end,
+// so a query over a stream emits results as it goes instead of at the end.
+type TumbleWindow struct {
+	Time expr.Node     // event time of a row
+	Size time.Duration // length of each window
+}
+
+// truncUnits the lengths of the date_trunc units windows may be of, months
+// and years are not of a fixed length.  Weeks start on monday as time.Time
+// truncation of 7 days does.
+var truncUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+// WindowOf the tumbling window of a group by, nil if not grouped by one
+func WindowOf(stmt *rel.SqlSelect) *TumbleWindow {
+	if stmt == nil {
+		return nil
+	}
+	for _, col := range stmt.GroupBy {
+		fn, ok := col.Expr.(*expr.FuncNode)
+		if !ok || len(fn.Args) != 2 {
+			continue
+		}
+		switch strings.ToLower(fn.Name) {
+		case "tumble", "timebucket":
+			sn, ok := fn.Args[1].(*expr.StringNode)
+			if !ok {
+				continue
+			}
+			size, err := time.ParseDuration(sn.Text)
+			if err != nil || size <= 0 {
+				continue
+			}
+			return &TumbleWindow{Time: fn.Args[0], Size: size}
+		case "date_trunc":
+			sn, ok := fn.Args[0].(*expr.StringNode)
+			if !ok {
+				continue
+			}
+			if size, ok := truncUnits[strings.ToLower(sn.Text)]; ok {
+				return &TumbleWindow{Time: fn.Args[1], Size: size}
+			}
+		}
+	}
+	return nil
+}
+
+// CheckpointStore a durable store of the operator state of continuous
+// queries by key, so a restarted query resumes from its last checkpoint
+// instead of losing the windows it had open.
+type CheckpointStore interface {
+	Save(key string, state []byte) error
+	// Load the state last saved for key, nil if none
+	Load(key string) ([]byte, error)
+}
+
+// Checkpointing is a CheckpointStore and key configured
+func (m *Context) Checkpointing() bool {
+	return m != nil && m.Checkpoints != nil && m.CheckpointKey != ""
+}
+
+type memCheckpoints struct {
+	mu    sync.Mutex
+	state map[string][]byte
+}
+
+// NewMemCheckpoints an in-memory checkpoint store, state survives restarts
+// of a query but not of the process
+func NewMemCheckpoints() CheckpointStore {
+	return &memCheckpoints{state: make(map[string][]byte)}
+}
+
+func (m *memCheckpoints) Save(key string, state []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state[key] = append([]byte(nil), state...)
+	return nil
+}
+func (m *memCheckpoints) Load(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state[key], nil
+}
+
+type fileCheckpoints struct {
+	dir string
+}
+
+// NewFileCheckpoints a checkpoint store of a file per key in dir, each
+// replaced atomically (written to a temp file and renamed) on save
+func NewFileCheckpoints(dir string) CheckpointStore {
+	return &fileCheckpoints{dir: dir}
+}
+
+func (m *fileCheckpoints) path(key string) string {
+	return filepath.Join(m.dir, url.QueryEscape(key)+".checkpoint")
+}
+func (m *fileCheckpoints) Save(key string, state []byte) error {
+	f, err := ioutil.TempFile(m.dir, "qlbridge-checkpoint-")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(state); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), m.path(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("could not save checkpoint %q: %v", key, err)
+	}
+	return nil
+}
+func (m *fileCheckpoints) Load(key string) ([]byte, error) {
+	state, err := ioutil.ReadFile(m.path(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return state, err
+}
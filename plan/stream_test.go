@@ -0,0 +1,44 @@
+package plan_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+)
+
+func TestWindowOf(t *testing.T) {
+	tests := []struct {
+		sql  string
+		size time.Duration
+		ts   string
+	}{
+		{`SELECT count(*) FROM clicks GROUP BY tumble(ts, "1m")`, time.Minute, "ts"},
+		{`SELECT count(*) FROM clicks GROUP BY url, timebucket(ts, "5m")`, 5 * time.Minute, "ts"},
+		{`SELECT count(*) FROM clicks GROUP BY date_trunc("hour", created)`, time.Hour, "created"},
+		{`SELECT count(*) FROM clicks GROUP BY date_trunc("WEEK", ts)`, 7 * 24 * time.Hour, "ts"},
+		// months are not of a fixed length
+		{`SELECT count(*) FROM clicks GROUP BY date_trunc("month", ts)`, 0, ""},
+		{`SELECT count(*) FROM clicks GROUP BY timebucket(ts, "fortnight")`, 0, ""},
+		{`SELECT count(*) FROM clicks GROUP BY url`, 0, ""},
+	}
+	for _, tt := range tests {
+		stmt, err := rel.ParseSqlSelect(tt.sql)
+		assert.Tf(t, err == nil, "no error %v", err)
+		w := plan.WindowOf(stmt)
+		if tt.size == 0 {
+			assert.Tf(t, w == nil, "no window of %s", tt.sql)
+			continue
+		}
+		assert.Tf(t, w != nil, "window of %s", tt.sql)
+		assert.Equal(t, tt.size, w.Size)
+		assert.Equal(t, tt.ts, w.Time.String())
+	}
+
+	// windows of a week start on monday, as date_trunc does
+	sunday := time.Date(2016, 1, 3, 15, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Monday, sunday.Truncate(7*24*time.Hour).Weekday())
+}
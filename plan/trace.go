@@ -0,0 +1,102 @@
+package plan
+
+import (
+	"golang.org/x/net/context"
+)
+
+var (
+	_ Span = noopSpan{}
+)
+
+// Tracer starts spans of the work of a query on a tracing system.  If set
+// on a Context the query is traced as a span, child of the span of the
+// Context (ie the request the query is run for), and its parse, plan and
+// each exec operator as children of the query span.  The context of the
+// query span is set as the Context given to the sources, so the calls they
+// make to their backends are children of it too and query latency can be
+// correlated with them.  A tracing system is adapted by implementing it, ie for
+// OpenTelemetry:
+//
+//   func (m *otelTracer) StartSpan(ctx context.Context, name string) (context.Context, plan.Span) {
+//       ctx, span := m.tracer.Start(ctx, name)
+//       return ctx, &otelSpan{span}
+//   }
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span a timed unit of work of a query, attributes are set on it (rows,
+// pushdowns) before it is ended
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) RecordError(err error)                      {}
+func (noopSpan) End()                                       {}
+
+// Tracing is a Tracer configured
+func (m *Context) Tracing() bool {
+	return m != nil && m.Tracer != nil
+}
+
+// StartTrace start the span of the query, its context replacing the
+// Context so the spans started after it, and the calls of the sources
+// with the Context, are its children.  No-op if no Tracer or started.
+func (m *Context) StartTrace() {
+	if !m.Tracing() || m.traceSpan != nil {
+		return
+	}
+	m.Context, m.traceSpan = m.Tracer.StartSpan(m.traceParent(), "query")
+}
+
+// EndTrace end the span of the query, recording err if not nil
+func (m *Context) EndTrace(err error) {
+	if m == nil || m.traceSpan == nil {
+		return
+	}
+	EndSpan(m.traceSpan, err)
+	m.traceSpan = nil
+}
+
+// StartSpan start a span of this query, a no-op span if no Tracer
+func (m *Context) StartSpan(name string) Span {
+	if !m.Tracing() {
+		return noopSpan{}
+	}
+	_, span := m.Tracer.StartSpan(m.traceParent(), name)
+	return span
+}
+
+func (m *Context) traceParent() context.Context {
+	if m.Context == nil {
+		return context.Background()
+	}
+	return m.Context
+}
+
+// EndSpan end span, recording err if not nil
+func EndSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// TracePlan set the decisions of the planner (rules applied, pushdowns) on
+// the span of planning
+func (m *Context) TracePlan(span Span) {
+	if m.Metrics == nil {
+		return
+	}
+	if len(m.Metrics.Rules) > 0 {
+		span.SetAttribute("rules", m.Metrics.Rules)
+	}
+	for _, pd := range m.Metrics.Pushdowns {
+		span.SetAttribute("pushdown."+pd.Source+"."+pd.Kind, pd.Accepted)
+	}
+}
@@ -0,0 +1,40 @@
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	td "github.com/araddon/qlbridge/datasource/mockcsvtestdata"
+	"github.com/araddon/qlbridge/expr/translate"
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+)
+
+func sourceNative(t *testing.T, q string) interface{} {
+	ctx := td.TestContext(q)
+	stmt, err := rel.ParseSql(q)
+	assert.Tf(t, err == nil, "Must parse %s but got %v", q, err)
+	pln, err := plan.WalkStmt(ctx, stmt, plan.NewPlanner(ctx))
+	assert.Tf(t, err == nil, "no error for %s but got %v", q, err)
+	for _, task := range pln.Children() {
+		if src, ok := task.(*plan.Source); ok {
+			return src.Native
+		}
+	}
+	t.Fatalf("no source for %s", q)
+	return nil
+}
+
+func TestTranslateWhere(t *testing.T) {
+	td.LoadTestDataOnce()
+	translate.Register("mockcsv", translate.NewSqlTranslator('\'', '"'))
+
+	assert.Equal(t, `referral_count > 2`,
+		sourceNative(t, `SELECT user_id FROM users WHERE referral_count > 2`))
+	// un-translatable, evaluated in-process
+	assert.Equal(t, nil,
+		sourceNative(t, `SELECT user_id FROM users WHERE toint(referral_count) > 2`))
+	assert.Equal(t, nil,
+		sourceNative(t, `/*+ NO_PUSHDOWN */ SELECT user_id FROM users WHERE referral_count > 2`))
+}
@@ -0,0 +1,88 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/araddon/qlbridge/schema"
+)
+
+// ErrTxDone the transaction was already committed or rolled back
+var ErrTxDone = fmt.Errorf("QLBridge.plan: transaction has already been committed or rolled back")
+
+// Tx a transaction spanning the statements of a session (ie a database/sql
+// Tx), set as the Tx of the Context of each of them.  The writes of each
+// table are of a schema.Transaction of its source begun by the first write
+// of the table, so reads of other connections (and of the statements of
+// this one) do not see them until Commit.
+type Tx struct {
+	mu     sync.Mutex
+	tables []string
+	txs    map[string]schema.Transaction
+	done   bool
+}
+
+// NewTx a transaction, of no writes until its statements write
+func NewTx() *Tx {
+	return &Tx{txs: make(map[string]schema.Transaction)}
+}
+
+// mutator the transaction of writes of table of conn, begun on first write
+func (m *Tx) mutator(table string, conn interface{}) (schema.Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.done {
+		return nil, ErrTxDone
+	}
+	key := strings.ToLower(table)
+	if tx, ok := m.txs[key]; ok {
+		return tx, nil
+	}
+	txConn, ok := conn.(schema.ConnTransaction)
+	if !ok || !CapabilitiesOf(conn).Transactions {
+		return nil, fmt.Errorf("%T of table %q does not support transactions", conn, table)
+	}
+	tx, err := txConn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	m.tables = append(m.tables, key)
+	m.txs[key] = tx
+	return tx, nil
+}
+
+// Commit the writes of each table, in the order they were begun.  Sources
+// commit on their own so if one fails those after it are rolled back but
+// those before it stay committed.
+func (m *Tx) Commit() error {
+	return m.end(true)
+}
+
+// Rollback discard the writes of each table
+func (m *Tx) Rollback() error {
+	return m.end(false)
+}
+
+func (m *Tx) end(commit bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.done {
+		return ErrTxDone
+	}
+	m.done = true
+	var err error
+	for _, table := range m.tables {
+		tx := m.txs[table]
+		if commit && err == nil {
+			if err = tx.Commit(); err != nil {
+				err = fmt.Errorf("commit of %q failed: %v", table, err)
+			}
+			continue
+		}
+		if rerr := tx.Rollback(); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,462 @@
+package plan
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+)
+
+var _ = u.EMPTY
+
+// maxViewDepth limits how many levels of views-of-views are expanded
+const maxViewDepth = 10
+
+// ExpandViews rewrites a select statement whose FROM references a view registered
+// on the schema into an equivalent statement against the view's underlying source.
+//
+//   view:    CREATE VIEW big_orders AS SELECT id, price * qty AS total FROM orders WHERE qty > 10
+//   query:   SELECT total FROM big_orders WHERE total > 100
+//   becomes: SELECT price * qty AS total FROM orders AS big_orders WHERE price * qty > 100 AND qty > 10
+//
+// Only views of a single source without aggregation, distinct or limit are expanded inline.
+// Views of views are expanded until a table, a view referenced again on the
+// way (ie a -> b -> a) is an error of the cycle.  Returns the original
+// statement if it does not reference a view.
+func ExpandViews(ctx *Context, stmt *rel.SqlSelect) (*rel.SqlSelect, error) {
+	if ctx == nil || ctx.Schema == nil {
+		return stmt, nil
+	}
+	expanded := make([]string, 0)
+	for depth := 0; depth < maxViewDepth; depth++ {
+		view := viewForStatement(ctx.Schema, stmt)
+		if view == nil {
+			return stmt, nil
+		}
+		for _, name := range expanded {
+			if name == view.Name {
+				return nil, fmt.Errorf("views reference themselves: %s -> %s", strings.Join(expanded, " -> "), view.Name)
+			}
+		}
+		expanded = append(expanded, view.Name)
+		if len(stmt.From) > 1 {
+			return nil, fmt.Errorf("view %q is not supported in a join", view.Name)
+		}
+		next, err := expandView(ctx, stmt, view)
+		if err != nil {
+			return nil, err
+		}
+		stmt = next
+	}
+	return nil, fmt.Errorf("views nested more than %d deep", maxViewDepth)
+}
+
+// RewriteMaterializedViews looks for a materialized view that subsumes the
+// statement (same source, the view's filters are a subset of the query's, and the
+// view exposes every column the query needs) and if found rewrites the statement to
+// read from the materialized table.  Returns the original statement otherwise.
+func RewriteMaterializedViews(ctx *Context, stmt *rel.SqlSelect) (*rel.SqlSelect, error) {
+	if ctx == nil || ctx.Schema == nil || len(stmt.From) != 1 || stmt.From[0].SubQuery != nil {
+		return stmt, nil
+	}
+	from := stmt.From[0]
+	for _, view := range ctx.Schema.Views() {
+		if view.MaterializedTable == "" || !isSimpleView(view.Stmt) {
+			continue
+		}
+		vfrom := view.Stmt.From[0]
+		if !strings.EqualFold(vfrom.Name, from.Name) || !strings.EqualFold(vfrom.Schema, from.Schema) {
+			continue
+		}
+		remaining, ok := subsumedWhere(view.Stmt, stmt)
+		if !ok || !viewHasColumns(view.Stmt, stmt) {
+			continue
+		}
+		alias := from.Alias
+		if alias == "" {
+			alias = from.Name
+		}
+		u.Debugf("rewriting query to use materialized view %q table=%q", view.Name, view.MaterializedTable)
+		return reparseSelect(ctx, stmt, stmt.Columns, &rel.SqlSource{Name: view.MaterializedTable, Alias: alias}, remaining)
+	}
+	return stmt, nil
+}
+
+// rewriteViews expand any views referenced by this select and, if enabled
+// on the context, substitute materialized views.
+func (m *PlannerDefault) rewriteViews(p *Select) error {
+	stmt, err := ExpandViews(m.Ctx, p.Stmt)
+	if err != nil {
+		return err
+	}
+	if stmt != p.Stmt {
+		m.Ctx.RuleApplied("expand-views")
+	}
+	if m.Ctx.UseMaterializedViews {
+		expanded := stmt
+		if stmt, err = RewriteMaterializedViews(m.Ctx, stmt); err != nil {
+			return err
+		}
+		if stmt != expanded {
+			m.Ctx.RuleApplied("materialized-view")
+		}
+	}
+	if stmt != p.Stmt {
+		*p.Stmt = *stmt
+	}
+	return nil
+}
+
+func viewForStatement(s *schema.Schema, stmt *rel.SqlSelect) *schema.View {
+	for _, from := range stmt.From {
+		if from.SubQuery != nil || from.Name == "" {
+			continue
+		}
+		if view, ok := s.View(from.Name); ok {
+			return view
+		}
+	}
+	return nil
+}
+
+// isSimpleView can this view be merged into the referencing query
+func isSimpleView(vs *rel.SqlSelect) bool {
+	if vs == nil || len(vs.From) != 1 || vs.From[0].SubQuery != nil {
+		return false
+	}
+	if vs.Distinct || vs.IsAggQuery() || len(vs.GroupBy) > 0 || vs.Having != nil || vs.Limit > 0 {
+		return false
+	}
+	if vs.Where != nil && vs.Where.Source != nil {
+		return false
+	}
+	return true
+}
+
+func expandView(ctx *Context, stmt *rel.SqlSelect, view *schema.View) (*rel.SqlSelect, error) {
+
+	if !isSimpleView(view.Stmt) {
+		return nil, fmt.Errorf("view %q cannot be expanded, must be a single source without aggregates/distinct/limit: %v", view.Name, ErrNotImplemented)
+	}
+
+	from := stmt.From[0]
+	alias := from.Alias
+	if alias == "" {
+		alias = from.Name
+	}
+
+	// map of view column name -> underlying expression
+	viewCols := make(map[string]expr.Node)
+	viewStar := false
+	for _, col := range view.Stmt.Columns {
+		if col.Star {
+			viewStar = true
+			continue
+		}
+		viewCols[strings.ToLower(col.As)] = col.Expr
+	}
+
+	var err error
+	rewrite := func(n expr.Node) expr.Node {
+		if n == nil || err != nil {
+			return n
+		}
+		var rn expr.Node
+		rn, err = rewriteViewIdentities(n, alias, viewCols, viewStar)
+		if err != nil {
+			err = fmt.Errorf("view %q: %v", view.Name, err)
+		}
+		return rn
+	}
+
+	cols := make(rel.Columns, 0, len(stmt.Columns))
+	for _, col := range stmt.Columns {
+		if col.Star {
+			if viewStar {
+				cols = append(cols, col)
+				continue
+			}
+			cols = append(cols, view.Stmt.Columns...)
+			continue
+		}
+		cols = append(cols, &rel.Column{As: col.As, Order: col.Order, Expr: rewrite(col.Expr)})
+	}
+
+	var where expr.Node
+	if stmt.Where != nil {
+		if stmt.Where.Source != nil {
+			return nil, fmt.Errorf("view %q with sub-query where: %v", view.Name, ErrNotImplemented)
+		}
+		where = rewrite(stmt.Where.Expr)
+	}
+	if view.Stmt.Where != nil && view.Stmt.Where.Expr != nil {
+		where = andNodes(where, view.Stmt.Where.Expr)
+	}
+	for _, col := range stmt.GroupBy {
+		col.Expr = rewrite(col.Expr)
+	}
+	for _, col := range stmt.OrderBy {
+		col.Expr = rewrite(col.Expr)
+	}
+	stmt.Having = rewrite(stmt.Having)
+	if err != nil {
+		return nil, err
+	}
+
+	vfrom := view.Stmt.From[0]
+	src := &rel.SqlSource{Name: vfrom.Name, Schema: vfrom.Schema, Alias: alias}
+	return reparseSelect(ctx, stmt, cols, src, where)
+}
+
+// rewriteViewIdentities replace identities referencing view columns with the
+// underlying view column expression
+func rewriteViewIdentities(n expr.Node, alias string, viewCols map[string]expr.Node, viewStar bool) (expr.Node, error) {
+	var err error
+	switch nt := n.(type) {
+	case *expr.IdentityNode:
+		if nt.IsBooleanIdentity() || nt.Text == "*" {
+			return n, nil
+		}
+		name := nt.Text
+		if left, right, hasLeft := nt.LeftRight(); hasLeft {
+			if !strings.EqualFold(left, alias) {
+				return n, nil
+			}
+			name = right
+		}
+		if vn, ok := viewCols[strings.ToLower(name)]; ok {
+			return vn, nil
+		}
+		if viewStar {
+			return n, nil
+		}
+		return nil, fmt.Errorf("column %q not found in view", name)
+	case *expr.BinaryNode:
+		for i, arg := range nt.Args {
+			if nt.Args[i], err = rewriteViewIdentities(arg, alias, viewCols, viewStar); err != nil {
+				return nil, err
+			}
+		}
+	case *expr.TriNode:
+		for i, arg := range nt.Args {
+			if nt.Args[i], err = rewriteViewIdentities(arg, alias, viewCols, viewStar); err != nil {
+				return nil, err
+			}
+		}
+	case *expr.FuncNode:
+		for i, arg := range nt.Args {
+			if nt.Args[i], err = rewriteViewIdentities(arg, alias, viewCols, viewStar); err != nil {
+				return nil, err
+			}
+		}
+	case *expr.ArrayNode:
+		for i, arg := range nt.Args {
+			if nt.Args[i], err = rewriteViewIdentities(arg, alias, viewCols, viewStar); err != nil {
+				return nil, err
+			}
+		}
+	case *expr.UnaryNode:
+		if nt.Arg, err = rewriteViewIdentities(nt.Arg, alias, viewCols, viewStar); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// subsumedWhere determine if every filter of the view is also a filter in the
+// query, returning the query filters not already applied by the view
+func subsumedWhere(vs, stmt *rel.SqlSelect) (expr.Node, bool) {
+
+	queryTerms := whereTerms(stmt)
+	if stmt.Where != nil && stmt.Where.Source != nil {
+		return nil, false
+	}
+	remaining := make(map[string]expr.Node, len(queryTerms))
+	order := make([]string, 0, len(queryTerms))
+	for _, term := range queryTerms {
+		key := term.String()
+		remaining[key] = term
+		order = append(order, key)
+	}
+	for _, term := range whereTerms(vs) {
+		key := term.String()
+		if _, ok := remaining[key]; !ok {
+			return nil, false
+		}
+		delete(remaining, key)
+	}
+	var where expr.Node
+	for _, key := range order {
+		if term, ok := remaining[key]; ok {
+			where = andNodes(where, term)
+			delete(remaining, key)
+		}
+	}
+	return where, true
+}
+
+func whereTerms(stmt *rel.SqlSelect) []expr.Node {
+	if stmt.Where == nil || stmt.Where.Expr == nil {
+		return nil
+	}
+	if bn, ok := stmt.Where.Expr.(*expr.BinaryNode); ok && sameLogicalOp(bn.Operator.T, lex.TokenLogicAnd) {
+		return flattenLogical(bn, nil)
+	}
+	return []expr.Node{stmt.Where.Expr}
+}
+
+// viewHasColumns does the view expose un-modified every column referenced in stmt
+func viewHasColumns(vs, stmt *rel.SqlSelect) bool {
+	exposed := make(map[string]struct{})
+	for _, col := range vs.Columns {
+		if col.Star {
+			return true
+		}
+		in, ok := col.Expr.(*expr.IdentityNode)
+		if !ok {
+			continue
+		}
+		_, right, _ := in.LeftRight()
+		if right == "" {
+			right = in.Text
+		}
+		if !strings.EqualFold(right, col.As) {
+			// renamed column
+			continue
+		}
+		exposed[strings.ToLower(col.As)] = struct{}{}
+	}
+	has := func(n expr.Node) bool {
+		for _, name := range expr.FindAllIdentityField(n) {
+			if name == "*" {
+				continue
+			}
+			if _, right, hasLeft := expr.LeftRight(name); hasLeft {
+				name = right
+			}
+			if _, ok := exposed[strings.ToLower(name)]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+	for _, col := range stmt.Columns {
+		if col.Star || !has(col.Expr) {
+			return false
+		}
+	}
+	for _, col := range stmt.GroupBy {
+		if !has(col.Expr) {
+			return false
+		}
+	}
+	for _, col := range stmt.OrderBy {
+		if !has(col.Expr) {
+			return false
+		}
+	}
+	if stmt.Having != nil && !has(stmt.Having) {
+		return false
+	}
+	if stmt.Where != nil && stmt.Where.Expr != nil && !has(stmt.Where.Expr) {
+		return false
+	}
+	return true
+}
+
+func andNodes(left, right expr.Node) expr.Node {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+	bn := expr.NewBinaryNode(lex.Token{T: lex.TokenLogicAnd, V: "AND"}, left, right)
+	return bn
+}
+
+// reparseSelect writes out a new select statement from the parts of @stmt with
+// given columns, source and where, and parses it so all of the derived
+// column/source info is consistent.
+func reparseSelect(ctx *Context, stmt *rel.SqlSelect, cols rel.Columns, from *rel.SqlSource, where expr.Node) (*rel.SqlSelect, error) {
+
+	w := expr.NewDefaultWriter()
+	io.WriteString(w, "SELECT ")
+	if stmt.Distinct {
+		io.WriteString(w, "DISTINCT ")
+	}
+	for i, col := range cols {
+		if i != 0 {
+			io.WriteString(w, ", ")
+		}
+		if col.Star || col.Expr == nil {
+			col.WriteDialect(w)
+			continue
+		}
+		start := w.Len()
+		col.Expr.WriteDialect(w)
+		if col.As != "" && w.String()[start:] != col.As {
+			io.WriteString(w, " AS ")
+			w.WriteIdentity(col.As)
+		}
+		if col.Order != "" {
+			io.WriteString(w, " ")
+			io.WriteString(w, col.Order)
+		}
+	}
+	io.WriteString(w, " FROM ")
+	if from.Schema != "" {
+		w.WriteIdentity(from.Schema)
+		io.WriteString(w, ".")
+	}
+	w.WriteIdentity(from.Name)
+	if from.Alias != "" && !strings.EqualFold(from.Alias, from.Name) {
+		io.WriteString(w, " AS ")
+		w.WriteIdentity(from.Alias)
+	}
+	if where != nil {
+		io.WriteString(w, " WHERE ")
+		where.WriteDialect(w)
+	}
+	if len(stmt.GroupBy) > 0 {
+		io.WriteString(w, " GROUP BY ")
+		stmt.GroupBy.WriteDialect(w)
+	}
+	if stmt.Having != nil {
+		io.WriteString(w, " HAVING ")
+		stmt.Having.WriteDialect(w)
+	}
+	if len(stmt.OrderBy) > 0 {
+		io.WriteString(w, " ORDER BY ")
+		stmt.OrderBy.WriteDialect(w)
+	}
+	if stmt.Limit > 0 {
+		fmt.Fprintf(w, " LIMIT %d", stmt.Limit)
+	}
+	if stmt.Offset > 0 {
+		fmt.Fprintf(w, " OFFSET %d", stmt.Offset)
+	}
+
+	sql := w.String()
+	var ns *rel.SqlSelect
+	var err error
+	if ctx.Funcs != nil {
+		ns, err = rel.ParseSqlSelectResolver(sql, ctx.Funcs)
+	} else {
+		ns, err = rel.ParseSqlSelect(sql)
+	}
+	if err != nil {
+		u.Warnf("could not parse re-written view query %q err=%v", sql, err)
+		return nil, err
+	}
+	ns.Db = stmt.Db
+	ns.Alias = stmt.Alias
+	ns.With = stmt.With
+	return ns, nil
+}
@@ -0,0 +1,115 @@
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/plan"
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+)
+
+func viewSchema(t *testing.T) *schema.Schema {
+	s := schema.NewSchema("views")
+	for name, sql := range map[string]string{
+		"big_orders":  `SELECT order_id, user_id, price * item_count AS total FROM orders WHERE item_count > 10`,
+		"all_users":   `SELECT * FROM users`,
+		"nested_view": `SELECT order_id, total FROM big_orders`,
+	} {
+		stmt, err := rel.ParseSqlSelect(sql)
+		assert.Tf(t, err == nil, "err=%v", err)
+		s.AddView(schema.NewView(name, stmt))
+	}
+	return s
+}
+
+type viewTest struct {
+	q   string
+	out string
+}
+
+var viewTests = []viewTest{
+	{`SELECT total FROM big_orders WHERE total > 100`,
+		"SELECT price * item_count AS total FROM orders AS big_orders WHERE price * item_count > 100 AND item_count > 10"},
+	{`SELECT email FROM all_users WHERE user_id = "abc"`,
+		"SELECT email FROM users AS all_users WHERE user_id = \"abc\""},
+	{`SELECT total FROM nested_view`,
+		"SELECT price * item_count AS total FROM orders AS nested_view WHERE item_count > 10"},
+	{`SELECT email FROM users`,
+		"SELECT email FROM users"},
+}
+
+func TestExpandViews(t *testing.T) {
+	s := viewSchema(t)
+	for _, vt := range viewTests {
+		ctx := plan.NewContext(vt.q)
+		ctx.Schema = s
+		stmt, err := rel.ParseSqlSelect(vt.q)
+		assert.Tf(t, err == nil, "err=%v", err)
+		out, err := plan.ExpandViews(ctx, stmt)
+		assert.Tf(t, err == nil, "err=%v", err)
+		assert.Tf(t, out.String() == vt.out, "expected\n%s\ngot\n%s", vt.out, out.String())
+	}
+
+	// columns not exposed by the view are an error
+	ctx := plan.NewContext("")
+	ctx.Schema = s
+	stmt, _ := rel.ParseSqlSelect(`SELECT item_id FROM big_orders`)
+	_, err := plan.ExpandViews(ctx, stmt)
+	assert.T(t, err != nil)
+}
+
+func TestMaterializedViews(t *testing.T) {
+	s := schema.NewSchema("mviews")
+	vs, err := rel.ParseSqlSelect(`SELECT order_id, user_id, price FROM orders WHERE price > 10`)
+	assert.T(t, err == nil)
+	v := schema.NewView("expensive_orders", vs)
+	v.MaterializedTable = "expensive_orders_mv"
+	s.AddView(v)
+
+	ctx := plan.NewContext("")
+	ctx.Schema = s
+
+	stmt, _ := rel.ParseSqlSelect(`SELECT order_id FROM orders WHERE price > 10 AND user_id = "abc"`)
+	out, err := plan.RewriteMaterializedViews(ctx, stmt)
+	assert.T(t, err == nil)
+	assert.Tf(t, out.String() == `SELECT order_id FROM expensive_orders_mv AS orders WHERE user_id = "abc"`, "got %s", out)
+
+	// not subsumed, missing the view's filter
+	stmt, _ = rel.ParseSqlSelect(`SELECT order_id FROM orders WHERE user_id = "abc"`)
+	out, err = plan.RewriteMaterializedViews(ctx, stmt)
+	assert.T(t, err == nil)
+	assert.T(t, out == stmt)
+
+	// not subsumed, needs a column the view does not have
+	stmt, _ = rel.ParseSqlSelect(`SELECT item_id FROM orders WHERE price > 10`)
+	out, err = plan.RewriteMaterializedViews(ctx, stmt)
+	assert.T(t, err == nil)
+	assert.T(t, out == stmt)
+}
+
+func TestExpandViewsCycle(t *testing.T) {
+	s := schema.NewSchema("cycles")
+	for name, sql := range map[string]string{
+		"view_a": `SELECT id FROM view_b`,
+		"view_b": `SELECT id FROM view_c`,
+		"view_c": `SELECT id FROM view_a`,
+		"self":   `SELECT id FROM self`,
+	} {
+		stmt, err := rel.ParseSqlSelect(sql)
+		assert.Tf(t, err == nil, "err=%v", err)
+		s.AddView(schema.NewView(name, stmt))
+	}
+	for q, msg := range map[string]string{
+		`SELECT id FROM view_a`: "views reference themselves: view_a -> view_b -> view_c -> view_a",
+		`SELECT id FROM self`:   "views reference themselves: self -> self",
+	} {
+		ctx := plan.NewContext(q)
+		ctx.Schema = s
+		stmt, err := rel.ParseSqlSelect(q)
+		assert.Tf(t, err == nil, "err=%v", err)
+		_, err = plan.ExpandViews(ctx, stmt)
+		assert.Tf(t, err != nil && err.Error() == msg, "%s: %v", q, err)
+	}
+}
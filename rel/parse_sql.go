@@ -109,6 +109,10 @@ func (m *Sqlbridge) parse() (SqlStatement, error) {
 		return m.parseShow()
 	case lex.TokenExplain, lex.TokenDescribe, lex.TokenDesc:
 		return m.parseDescribe()
+	case lex.TokenCreate:
+		return m.parseCreate()
+	case lex.TokenAnalyze:
+		return m.parseAnalyze()
 	case lex.TokenSet, lex.TokenUse:
 		return m.parseCommand()
 	case lex.TokenRollback, lex.TokenCommit:
@@ -162,6 +166,10 @@ func (m *Sqlbridge) parseSqlSelect() (*SqlSelect, error) {
 
 	req := NewSqlSelect()
 	req.Raw = m.l.RawInput()
+	if strings.HasPrefix(m.comment, "+") {
+		// optimizer hints   /*+ NO_PUSHDOWN(users) */ SELECT ...
+		req.Hints = strings.TrimSpace(m.comment[1:])
+	}
 	m.Next() // Consume Select?
 
 	// Optional DISTINCT keyword always immediately after SELECT KW
@@ -236,6 +244,12 @@ func (m *Sqlbridge) parseSqlSelect() (*SqlSelect, error) {
 		return nil, err
 	}
 
+	// UNION, INTERSECT, EXCEPT
+	discardComments(m)
+	if err := m.parseSetOps(req); err != nil {
+		return nil, err
+	}
+
 	// WITH
 	discardComments(m)
 	with, err := ParseWith(m.SqlTokenPager)
@@ -498,9 +512,20 @@ func (m *Sqlbridge) parseDescribe() (SqlStatement, error) {
 	req := &SqlDescribe{Raw: m.l.RawInput()}
 	req.Tok = m.Cur()
 	m.Next() // Consume Describe
+	discardComments(m)
 
 	//u.Debugf("token:  %v", m.Cur())
-	switch nextWord := strings.ToLower(m.Cur().V); nextWord {
+	nextWord := strings.ToLower(m.Cur().V)
+	if m.Cur().T == lex.TokenError {
+		// the describe lexer errors on keywords such as SELECT, peek at raw text
+		raw := m.l.RawInput()
+		if idx := strings.Index(raw, req.Tok.V); idx >= 0 {
+			if words := strings.Fields(raw[idx+len(req.Tok.V):]); len(words) > 0 {
+				nextWord = strings.ToLower(words[0])
+			}
+		}
+	}
+	switch nextWord {
 	case "select":
 		// TODO:  make the lexer handle this
 		sqlText := strings.Replace(m.l.RawInput(), req.Tok.V, "", 1)
@@ -531,6 +556,260 @@ func (m *Sqlbridge) parseDescribe() (SqlStatement, error) {
 	return req, nil
 }
 
+// First keyword was CREATE
+//
+//   CREATE TABLE [IF NOT EXISTS] name (col type [NOT NULL] [DEFAULT v] [PRIMARY KEY] [REFERENCES t (col)], ...) [WITH ...]
+func (m *Sqlbridge) parseCreate() (*SqlCreate, error) {
+
+	req := &SqlCreate{Raw: m.l.RawInput()}
+	m.Next() // Consume CREATE
+	if m.Cur().T != lex.TokenTable {
+		return nil, fmt.Errorf("expected TABLE but got: %v", m.Cur())
+	}
+	m.Next()
+
+	if m.Cur().T == lex.TokenIf {
+		m.Next()
+		if m.Cur().T != lex.TokenNegate {
+			return nil, fmt.Errorf("expected IF NOT EXISTS but got: %v", m.Cur())
+		}
+		m.Next()
+		if m.Cur().T != lex.TokenExists {
+			return nil, fmt.Errorf("expected IF NOT EXISTS but got: %v", m.Cur())
+		}
+		m.Next()
+		req.IfNotExists = true
+	}
+
+	if m.Cur().T != lex.TokenIdentity {
+		return nil, fmt.Errorf("expected table name but got: %v", m.Cur())
+	}
+	req.Identity = m.Cur().V
+	m.Next()
+
+	if m.Cur().T != lex.TokenLeftParenthesis {
+		return nil, fmt.Errorf("expected ( of columns but got: %v", m.Cur())
+	}
+	m.Next()
+	for {
+		switch m.Cur().T {
+		case lex.TokenPrimaryKey:
+			m.Next()
+			names, err := m.parseDdlNames()
+			if err != nil {
+				return nil, err
+			}
+			req.PrimaryKey = names
+		case lex.TokenForeignKey:
+			m.Next()
+			names, err := m.parseDdlNames()
+			if err != nil {
+				return nil, err
+			}
+			fk, err := m.parseDdlReferences(names)
+			if err != nil {
+				return nil, err
+			}
+			req.ForeignKeys = append(req.ForeignKeys, fk)
+		case lex.TokenIdentity:
+			col, err := m.parseDdlColumn()
+			if err != nil {
+				return nil, err
+			}
+			if col.PrimaryKey {
+				req.PrimaryKey = append(req.PrimaryKey, col.Name)
+			}
+			if col.References != nil {
+				req.ForeignKeys = append(req.ForeignKeys, col.References)
+			}
+			req.Cols = append(req.Cols, col)
+		default:
+			return nil, fmt.Errorf("expected column definition but got: %v", m.Cur())
+		}
+		switch m.Cur().T {
+		case lex.TokenComma:
+			m.Next()
+			continue
+		case lex.TokenRightParenthesis:
+			m.Next()
+		default:
+			return nil, fmt.Errorf("expected , or ) of columns but got: %v", m.Cur())
+		}
+		break
+	}
+	if len(req.Cols) == 0 {
+		return nil, fmt.Errorf("CREATE TABLE %s requires columns", req.Identity)
+	}
+
+	discardComments(m)
+	with, err := ParseWith(m.SqlTokenPager)
+	if err != nil {
+		return nil, err
+	}
+	req.With = with
+
+	switch m.Cur().T {
+	case lex.TokenEOS, lex.TokenEOF:
+	default:
+		return nil, fmt.Errorf("unexpected token after CREATE TABLE: %v", m.Cur())
+	}
+	return req, nil
+}
+
+// First keyword was ANALYZE
+//
+//   ANALYZE TABLE name
+func (m *Sqlbridge) parseAnalyze() (*SqlAnalyze, error) {
+
+	req := &SqlAnalyze{Raw: m.l.RawInput()}
+	m.Next() // Consume ANALYZE
+	if m.Cur().T != lex.TokenTable {
+		return nil, fmt.Errorf("expected TABLE but got: %v", m.Cur())
+	}
+	m.Next()
+	if m.Cur().T != lex.TokenIdentity {
+		return nil, fmt.Errorf("expected table name but got: %v", m.Cur())
+	}
+	req.Identity = m.Cur().V
+	m.Next()
+
+	discardComments(m)
+	switch m.Cur().T {
+	case lex.TokenEOS, lex.TokenEOF:
+	default:
+		return nil, fmt.Errorf("unexpected token after ANALYZE TABLE: %v", m.Cur())
+	}
+	return req, nil
+}
+
+// a column definition of CREATE TABLE
+func (m *Sqlbridge) parseDdlColumn() (*DdlColumn, error) {
+
+	col := &DdlColumn{Name: m.Cur().V}
+	m.Next()
+	if m.Cur().T != lex.TokenDataType {
+		return nil, fmt.Errorf("expected data type of %s but got: %v", col.Name, m.Cur())
+	}
+	col.DataType = strings.ToLower(m.Cur().V)
+	m.Next()
+
+	if m.Cur().T == lex.TokenLeftParenthesis {
+		m.Next()
+		for m.Cur().T == lex.TokenInteger {
+			n, err := strconv.Atoi(m.Cur().V)
+			if err != nil {
+				return nil, fmt.Errorf("invalid size of %s: %v", col.Name, err)
+			}
+			col.Size = append(col.Size, n)
+			m.Next()
+			if m.Cur().T == lex.TokenComma {
+				m.Next()
+			}
+		}
+		if m.Cur().T != lex.TokenRightParenthesis {
+			return nil, fmt.Errorf("expected ) of size of %s but got: %v", col.Name, m.Cur())
+		}
+		m.Next()
+	}
+
+	for {
+		switch m.Cur().T {
+		case lex.TokenNegate:
+			m.Next()
+			if m.Cur().T != lex.TokenNull {
+				return nil, fmt.Errorf("expected NOT NULL but got: %v", m.Cur())
+			}
+			col.NoNulls = true
+		case lex.TokenNull:
+			col.NoNulls = false
+		case lex.TokenDefault:
+			m.Next()
+			tok := m.Cur()
+			switch tok.T {
+			case lex.TokenNull:
+				col.Default = value.NewNilValue()
+			case lex.TokenInteger:
+				iv, err := strconv.ParseInt(tok.V, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid default of %s: %v", col.Name, err)
+				}
+				col.Default = value.NewIntValue(iv)
+			case lex.TokenFloat:
+				fv, err := strconv.ParseFloat(tok.V, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid default of %s: %v", col.Name, err)
+				}
+				col.Default = value.NewNumberValue(fv)
+			case lex.TokenBool:
+				col.Default = value.NewBoolValue(strings.ToLower(tok.V) == "true")
+			case lex.TokenValue, lex.TokenValueWithSingleQuote:
+				col.Default = value.NewStringValue(tok.V)
+			default:
+				return nil, fmt.Errorf("expected default value of %s but got: %v", col.Name, tok)
+			}
+		case lex.TokenPrimaryKey:
+			col.PrimaryKey = true
+		case lex.TokenReferences:
+			fk, err := m.parseDdlReferences([]string{col.Name})
+			if err != nil {
+				return nil, err
+			}
+			col.References = fk
+			continue
+		default:
+			return col, nil
+		}
+		m.Next()
+	}
+}
+
+// the REFERENCES table (columns) of a foreign key of cols
+func (m *Sqlbridge) parseDdlReferences(cols []string) (*DdlForeignKey, error) {
+	if m.Cur().T != lex.TokenReferences {
+		return nil, fmt.Errorf("expected REFERENCES but got: %v", m.Cur())
+	}
+	m.Next()
+	if m.Cur().T != lex.TokenIdentity {
+		return nil, fmt.Errorf("expected referenced table name but got: %v", m.Cur())
+	}
+	fk := &DdlForeignKey{Columns: cols, RefTable: m.Cur().V}
+	m.Next()
+	refs, err := m.parseDdlNames()
+	if err != nil {
+		return nil, err
+	}
+	if len(refs) != len(cols) {
+		return nil, fmt.Errorf("foreign key of %d columns references %d columns of %s", len(cols), len(refs), fk.RefTable)
+	}
+	fk.RefColumns = refs
+	return fk, nil
+}
+
+// the '(' name [, name]* ')' of a PRIMARY KEY
+func (m *Sqlbridge) parseDdlNames() ([]string, error) {
+	if m.Cur().T != lex.TokenLeftParenthesis {
+		return nil, fmt.Errorf("expected ( of columns but got: %v", m.Cur())
+	}
+	m.Next()
+	names := make([]string, 0)
+	for {
+		switch m.Cur().T {
+		case lex.TokenIdentity:
+			names = append(names, m.Cur().V)
+		case lex.TokenComma:
+		case lex.TokenRightParenthesis:
+			m.Next()
+			if len(names) == 0 {
+				return nil, fmt.Errorf("expected columns of PRIMARY KEY")
+			}
+			return names, nil
+		default:
+			return nil, fmt.Errorf("expected column name but got: %v", m.Cur())
+		}
+		m.Next()
+	}
+}
+
 // First keyword was SHOW
 func (m *Sqlbridge) parseShow() (*SqlShow, error) {
 
@@ -545,6 +824,7 @@ func (m *Sqlbridge) parseShow() (*SqlShow, error) {
 		SHOW CREATE VIEW view_name
 		SHOW DATABASES [like_or_where]
 		SHOW ENGINE engine_name {STATUS | MUTEX}
+		SHOW FUNCTIONS [like_or_where]
 		SHOW [STORAGE] ENGINES
 		SHOW INDEX FROM tbl_name [FROM db_name]
 		SHOW [FULL] TABLES [FROM db_name] [like_or_where]
@@ -598,18 +878,38 @@ func (m *Sqlbridge) parseShow() (*SqlShow, error) {
 		req.ShowType = "status"
 		likeLhs = "Variable_name"
 		m.Next()
-	case "engine":
-		req.ShowType = "status"
+	case "engine", "engines":
+		req.ShowType = "engines"
 		likeLhs = "Engine"
 		m.Next()
-	case "engines":
-		req.ShowType = "status"
-		likeLhs = "Engine"
+	case "processlist":
+		req.ShowType = "processlist"
+		likeLhs = "Info"
 		m.Next()
+	case "grants":
+		// SHOW GRANTS [FOR user]
+		// the show lexer doesn't know FOR user@host, so read it from raw text
+		req.ShowType = "grants"
+		raw := strings.TrimRight(strings.TrimSpace(req.Raw), ";")
+		if idx := strings.Index(strings.ToLower(raw), "grants"); idx >= 0 {
+			words := strings.Fields(raw[idx+len("grants"):])
+			if len(words) > 1 && strings.ToLower(words[0]) == "for" {
+				user := strings.SplitN(words[1], "@", 2)[0]
+				if !strings.HasPrefix(strings.ToLower(user), "current_user") {
+					req.Identity = strings.Trim(user, "'\"`")
+				}
+			}
+		}
+		return req, nil
 	case "procedure", "function":
 		req.ShowType = objectType
 		likeLhs = "Name"
 		m.Next()
+	case "functions":
+		// SHOW FUNCTIONS [like_or_where]   the builtin and user functions
+		req.ShowType = objectType
+		likeLhs = "Name"
+		m.Next()
 	case "columns":
 		m.Next() // consume columns
 		likeLhs = "Field"
@@ -737,6 +1037,16 @@ func parseColumns(m expr.TokenPager, fr expr.FuncResolver, buildVm bool, stmt Co
 				}
 			}
 			col.Agg = expr.IsAgg(funcName)
+			if m.Cur().T == lex.TokenOver {
+				// window function, evaluated per row over its window not
+				// aggregated to a single row
+				win, err := parseWindow(m)
+				if err != nil {
+					return err
+				}
+				col.Over = win
+				col.Agg = false
+			}
 
 			if m.Cur().T != lex.TokenAs {
 				switch n := col.Expr.(type) {
@@ -839,6 +1149,132 @@ func parseColumns(m expr.TokenPager, fr expr.FuncResolver, buildVm bool, stmt Co
 	return nil
 }
 
+// parseWindow the OVER (...) window spec of a window function column
+func parseWindow(m expr.TokenPager) (*Window, error) {
+
+	m.Next() // Consume OVER
+	if m.Cur().T != lex.TokenLeftParenthesis {
+		return nil, fmt.Errorf("expected ( after OVER but got: %v", m.Cur())
+	}
+	m.Next()
+
+	win := &Window{}
+	for {
+		switch m.Cur().T {
+		case lex.TokenRightParenthesis:
+			m.Next()
+			return win, nil
+		case lex.TokenPartitionBy:
+			m.Next()
+			for m.Cur().T == lex.TokenIdentity {
+				tok := m.Cur()
+				win.PartitionBy = append(win.PartitionBy, expr.NewIdentityNode(&tok))
+				m.Next()
+				if m.Cur().T != lex.TokenComma {
+					break
+				}
+				m.Next()
+			}
+			if len(win.PartitionBy) == 0 {
+				return nil, fmt.Errorf("expected PARTITION BY column but got: %v", m.Cur())
+			}
+		case lex.TokenOrderBy:
+			m.Next()
+			for m.Cur().T == lex.TokenIdentity {
+				tok := m.Cur()
+				col := NewColumnFromToken(tok)
+				col.Expr = expr.NewIdentityNode(&tok)
+				m.Next()
+				switch m.Cur().T {
+				case lex.TokenAsc, lex.TokenDesc:
+					col.Order = strings.ToUpper(m.Cur().V)
+					m.Next()
+				}
+				win.OrderBy = append(win.OrderBy, col)
+				if m.Cur().T != lex.TokenComma {
+					break
+				}
+				m.Next()
+			}
+			if len(win.OrderBy) == 0 {
+				return nil, fmt.Errorf("expected ORDER BY column but got: %v", m.Cur())
+			}
+		case lex.TokenRows, lex.TokenRange:
+			frame, err := parseWindowFrame(m)
+			if err != nil {
+				return nil, err
+			}
+			win.Frame = frame
+		default:
+			return nil, fmt.Errorf("expected window spec but got: %v", m.Cur())
+		}
+	}
+}
+
+// parseWindowFrame the ROWS | RANGE frame of a window, a single bound is the
+// start with the current row as end
+func parseWindowFrame(m expr.TokenPager) (*WindowFrame, error) {
+	frame := &WindowFrame{Range: m.Cur().T == lex.TokenRange}
+	m.Next()
+	var err error
+	if m.Cur().T != lex.TokenBetween {
+		if frame.Start, err = parseWindowBound(m); err != nil {
+			return nil, err
+		}
+	} else {
+		m.Next()
+		if frame.Start, err = parseWindowBound(m); err != nil {
+			return nil, err
+		}
+		if m.Cur().T != lex.TokenLogicAnd {
+			return nil, fmt.Errorf("expected AND in frame but got: %v", m.Cur())
+		}
+		m.Next()
+		if frame.End, err = parseWindowBound(m); err != nil {
+			return nil, err
+		}
+	}
+	if frame.Range && (!frame.Start.Unbounded && frame.Start.Offset != 0 ||
+		!frame.End.Unbounded && frame.End.Offset != 0) {
+		return nil, fmt.Errorf("RANGE frames only support UNBOUNDED and CURRENT ROW bounds")
+	}
+	if frame.Start.Unbounded && frame.Start.Offset > 0 || frame.End.Unbounded && frame.End.Offset < 0 ||
+		!frame.Start.Unbounded && !frame.End.Unbounded && frame.Start.Offset > frame.End.Offset {
+		return nil, fmt.Errorf("invalid window frame %s AND %s", frame.Start, frame.End)
+	}
+	return frame, nil
+}
+
+func parseWindowBound(m expr.TokenPager) (WindowBound, error) {
+	b := WindowBound{}
+	switch m.Cur().T {
+	case lex.TokenCurrentRow:
+		m.Next()
+		return b, nil
+	case lex.TokenUnbounded:
+		b.Unbounded = true
+		b.Offset = 1
+	case lex.TokenInteger:
+		n, err := strconv.Atoi(m.Cur().V)
+		if err != nil {
+			return b, fmt.Errorf("invalid frame offset %v", m.Cur().V)
+		}
+		b.Offset = n
+	default:
+		return b, fmt.Errorf("expected frame bound but got: %v", m.Cur())
+	}
+	m.Next()
+	switch m.Cur().T {
+	case lex.TokenPreceding:
+		b.Offset = -b.Offset
+	case lex.TokenFollowing:
+	default:
+		return b, fmt.Errorf("expected PRECEDING or FOLLOWING but got: %v", m.Cur())
+	}
+	m.Next()
+	return b, nil
+}
+
 func (m *Sqlbridge) parseFieldList() (Columns, error) {
 
 	if m.Cur().T != lex.TokenLeftParenthesis {
@@ -890,6 +1326,8 @@ func (m *Sqlbridge) parseUpdateList() (map[string]*ValueColumn, error) {
 		case lex.TokenInteger:
 			iv, _ := strconv.ParseInt(m.Cur().V, 10, 64)
 			cols[lastColName] = &ValueColumn{Value: value.NewIntValue(iv)}
+		case lex.TokenNull:
+			cols[lastColName] = &ValueColumn{Value: value.NewNilValue()}
 		case lex.TokenComma, lex.TokenEqual:
 			// don't need to do anything
 		case lex.TokenIdentity:
@@ -930,13 +1368,17 @@ func (m *Sqlbridge) parseValueList() ([][]*ValueColumn, error) {
 		//u.Debug(m.Cur().String())
 		switch m.Cur().T {
 		case lex.TokenLeftParenthesis:
-			// start of row
-			if len(row) > 0 {
+			// start of row, the end of the previous row may have been
+			// consumed by an expression ending it
+			if row != nil {
 				values = append(values, row)
 			}
 			row = make([]*ValueColumn, 0)
 		case lex.TokenRightParenthesis:
-			values = append(values, row)
+			if row != nil {
+				values = append(values, row)
+				row = nil
+			}
 		case lex.TokenFrom, lex.TokenInto, lex.TokenLimit, lex.TokenEOS, lex.TokenEOF:
 			if len(row) > 0 {
 				values = append(values, row)
@@ -967,10 +1409,14 @@ func (m *Sqlbridge) parseValueList() ([][]*ValueColumn, error) {
 			lv := m.Cur().V
 			if bv, err := strconv.ParseBool(lv); err == nil {
 				row = append(row, &ValueColumn{Value: value.NewBoolValue(bv)})
+			} else if strings.ToLower(lv) == "null" {
+				row = append(row, &ValueColumn{Value: value.NewNilValue()})
 			} else {
 				// error?
 				u.Warnf("Could not figure out how to use: %v", m.Cur())
 			}
+		case lex.TokenNull:
+			row = append(row, &ValueColumn{Value: value.NewNilValue()})
 		case lex.TokenLeftBracket:
 			// an array of values?
 			m.Next() // Consume the [
@@ -1041,7 +1487,8 @@ func (m *Sqlbridge) parseSources(req *SqlSelect) error {
 				return err
 			}
 		case lex.TokenEOF, lex.TokenEOS, lex.TokenWhere, lex.TokenGroupBy, lex.TokenLimit,
-			lex.TokenOffset, lex.TokenWith, lex.TokenAlias, lex.TokenOrderBy:
+			lex.TokenOffset, lex.TokenWith, lex.TokenAlias, lex.TokenOrderBy,
+			lex.TokenUnion, lex.TokenIntersect, lex.TokenExcept:
 			return nil
 		default:
 
@@ -1190,7 +1637,7 @@ func (m *Sqlbridge) parseNode(tree *expr.Tree) error {
 	return err
 }
 
-func (m *Sqlbridge) parseWhereSubSelect(req *SqlSelect) error {
+func (m *Sqlbridge) parseWhereSubSelect(where *SqlWhere) error {
 
 	if m.Cur().T != lex.TokenSelect {
 		return nil
@@ -1200,7 +1647,11 @@ func (m *Sqlbridge) parseWhereSubSelect(req *SqlSelect) error {
 		return err
 	}
 	//u.Infof("found sub-select %+v", stmt)
-	req = stmt
+	where.Source = stmt
+	if m.Cur().T != lex.TokenRightParenthesis {
+		return fmt.Errorf("expected ) after sub-select but got %v", m.Cur())
+	}
+	m.Next() // consume )
 	return nil
 }
 
@@ -1213,12 +1664,6 @@ func (m *Sqlbridge) parseWhereSelect(req *SqlSelect) error {
 	defer func() {
 		if r := recover(); r != nil {
 			u.Errorf("where error? %v \n %v\n%s", r, m.Cur(), m.Lexer().RawInput())
-			if m.Cur().T == lex.TokenSelect {
-				// TODO this is deeply flawed, need to fix/use tokenpager
-				//    with rewind ability
-				err = m.parseWhereSubSelect(req)
-				return
-			}
 			err = fmt.Errorf("panic err: %v", r)
 		}
 	}()
@@ -1244,22 +1689,34 @@ func (m *Sqlbridge) parseWhere() (*SqlWhere, error) {
 
 	where := SqlWhere{}
 
-	// We are going to Peek forward at the next 3 tokens used
+	// We are going to Peek forward at the next 5 tokens used
 	// to determine which type of where clause
-	//t1 := m.Cur().T
-	m.Next() // x
-	t2 := m.Cur().T
-	m.Next()
-	t3 := m.Cur().T
-	m.Next()
-	t4 := m.Cur().T
-	m.Backup()
-	m.Backup()
-	m.Backup()
+	var t [5]lex.TokenType
+	var words [5]string
+	moved := 0
+	for i := range t {
+		t[i] = m.Cur().T
+		words[i] = m.Cur().V
+		if t[i] == lex.TokenEOF || t[i] == lex.TokenEOS {
+			break
+		}
+		m.Next()
+		moved++
+	}
+	for ; moved > 0; moved-- {
+		m.Backup()
+	}
+	// EXISTS may lex as a function
+	isExists := func(i int) bool {
+		return t[i] == lex.TokenExists || t[i] == lex.TokenUdfExpr && strings.EqualFold(words[i], "exists")
+	}
 
 	// Check for Types of Where
-	//                                 t1            T2      T3     T4
+	//                                 t0            t1      t2     t3
 	//    SELECT x FROM user   WHERE user_id         IN      (      SELECT user_id from orders where ...)
+	//    SELECT x FROM user   WHERE user_id         NOT     IN     (      SELECT user_id from orders)
+	//    SELECT x FROM user   WHERE EXISTS          (       SELECT 1 from orders where ...)
+	//    SELECT x FROM user   WHERE NOT             EXISTS  (      SELECT 1 from orders where ...)
 	//    SELECT * FROM t1     WHERE column1         =       (      SELECT column1 FROM t2);
 	//    select a FROM movies WHERE director        IN      (     "Quentin","copola","Bay","another")
 	//    select b FROM movies WHERE director        =       "bob";
@@ -1268,15 +1725,37 @@ func (m *Sqlbridge) parseWhere() (*SqlWhere, error) {
 	// TODO:
 	//    SELECT * FROM t3     WHERE ROW(5*t2.s1,77) =       (      SELECT 50,11*s1 FROM t4)
 	switch {
-	case (t2 == lex.TokenIN || t2 == lex.TokenEqual) && t3 == lex.TokenLeftParenthesis && t4 == lex.TokenSelect:
-		//u.Infof("in parseWhere: %v", m.Cur())
-		m.Next() // T1  ?? this might be udf?
-		m.Next() // t2  (IN | =)
-		m.Next() // t3 = (
-		//m.Next() // t4 = SELECT
-		where.Op = t2
-		where.Source = &SqlSelect{}
-		return &where, m.parseWhereSubSelect(where.Source)
+	case t[0] == lex.TokenIdentity && (t[1] == lex.TokenIN || t[1] == lex.TokenEqual) &&
+		t[2] == lex.TokenLeftParenthesis && t[3] == lex.TokenSelect:
+		lhs := m.Next()
+		where.Lhs = expr.NewIdentityNode(&lhs)
+		where.Op = t[1]
+		m.Next() // IN | =
+		m.Next() // (
+		return &where, m.parseWhereSubSelect(&where)
+	case t[0] == lex.TokenIdentity && t[1] == lex.TokenNegate && t[2] == lex.TokenIN &&
+		t[3] == lex.TokenLeftParenthesis && t[4] == lex.TokenSelect:
+		lhs := m.Next()
+		where.Lhs = expr.NewIdentityNode(&lhs)
+		where.Negate = true
+		where.Op = lex.TokenIN
+		m.Next() // NOT
+		m.Next() // IN
+		m.Next() // (
+		return &where, m.parseWhereSubSelect(&where)
+	case isExists(0) && t[1] == lex.TokenLeftParenthesis && t[2] == lex.TokenSelect:
+		where.Op = lex.TokenExists
+		m.Next() // EXISTS
+		m.Next() // (
+		return &where, m.parseWhereSubSelect(&where)
+	case t[0] == lex.TokenNegate && isExists(1) &&
+		t[2] == lex.TokenLeftParenthesis && t[3] == lex.TokenSelect:
+		where.Op = lex.TokenExists
+		where.Negate = true
+		m.Next() // NOT
+		m.Next() // EXISTS
+		m.Next() // (
+		return &where, m.parseWhereSubSelect(&where)
 	}
 	//u.Debugf("doing Where: %v %v", m.Cur(), m.Peek())
 	tree := expr.NewTreeFuncs(m.SqlTokenPager, m.funcs)
@@ -1568,6 +2047,47 @@ func convertIdentityToValue(n expr.Node) {
 	}
 }
 
+// parseSetOps the selects following a UNION, INTERSECT, EXCEPT.  The next
+// select is parsed recursively, the selects following it are flattened into
+// req so they are in order, and the ORDER BY, LIMIT of the last select moved
+// to req as they apply to the whole set operation.
+func (m *Sqlbridge) parseSetOps(req *SqlSelect) error {
+	switch m.Cur().T {
+	case lex.TokenUnion, lex.TokenIntersect, lex.TokenExcept:
+	default:
+		return nil
+	}
+	if len(req.OrderBy) > 0 || req.Limit > 0 {
+		return fmt.Errorf("ORDER BY, LIMIT must follow the last select of %s", m.Cur().V)
+	}
+	op := &SqlSetOp{Op: m.Cur().T}
+	m.Next()
+	switch m.Cur().T {
+	case lex.TokenAll:
+		op.All = true
+		m.Next()
+	case lex.TokenDistinct:
+		m.Next()
+	}
+	if m.Cur().T != lex.TokenSelect {
+		return fmt.Errorf("expected SELECT after %s but got: %v", op.Op, m.Cur().V)
+	}
+	sel, err := m.parseSqlSelect()
+	if err != nil {
+		return err
+	}
+	req.SetOps = append(req.SetOps, op)
+	req.SetOps = append(req.SetOps, sel.SetOps...)
+	op.Select, sel.SetOps = sel, nil
+
+	// sel is the last select, or already has them from the last select
+	req.OrderBy, sel.OrderBy = sel.OrderBy, nil
+	req.Limit, sel.Limit = sel.Limit, 0
+	req.Offset, sel.Offset = sel.Offset, 0
+	req.With, sel.With = sel.With, nil
+	return nil
+}
+
 func (m *Sqlbridge) parseLimit(req *SqlSelect) error {
 	if m.Cur().T != lex.TokenLimit {
 		return nil
@@ -1923,7 +2443,8 @@ func (m *SqlTokenPager) ClauseEnd() bool {
 	//u.Debugf("IsEnd()? tok:  %v", tok)
 	switch tok.T {
 	case lex.TokenEOF, lex.TokenEOS, lex.TokenFrom, lex.TokenHaving, lex.TokenComma,
-		lex.TokenIf, lex.TokenAs, lex.TokenLimit, lex.TokenSelect:
+		lex.TokenIf, lex.TokenAs, lex.TokenLimit, lex.TokenSelect,
+		lex.TokenUnion, lex.TokenIntersect, lex.TokenExcept:
 		return true
 	}
 	return false
@@ -2,6 +2,7 @@ package rel
 
 import (
 	"flag"
+	"strings"
 	"testing"
 
 	u "github.com/araddon/gou"
@@ -43,6 +44,12 @@ func TestSqlShow(t *testing.T) {
 	parseSqlTest(t, "SHOW FULL TABLES FROM `temp_schema` LIKE '%'")
 	parseSqlTest(t, "SHOW CREATE TABLE `temp_schema`.`users`")
 	parseSqlTest(t, `show session status like "ssl_cipher"`)
+	parseSqlTest(t, `show grants`)
+	parseSqlTest(t, `show grants for current_user()`)
+	parseSqlTest(t, `show processlist`)
+	parseSqlTest(t, `show engines`)
+	parseSqlTest(t, `show functions`)
+	parseSqlTest(t, `show functions like "array%"`)
 }
 
 func TestSqlKeywordEscape(t *testing.T) {
@@ -391,6 +398,96 @@ func TestSqlParseFromTypes(t *testing.T) {
 	u.Info(sel.String())
 }
 
+func TestSqlWhereSubQuery(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		sql    string
+		op     lex.TokenType
+		negate bool
+		lhs    string
+		sub    string
+	}{
+		{`SELECT user_id FROM users WHERE user_id IN (SELECT user_id FROM orders) LIMIT 10`,
+			lex.TokenIN, false, "user_id", "SELECT user_id FROM orders"},
+		{`SELECT user_id FROM users WHERE user_id = (SELECT user_id FROM orders WHERE price > 10)`,
+			lex.TokenEqual, false, "user_id", "SELECT user_id FROM orders WHERE price > 10"},
+		{`SELECT user_id FROM users WHERE user_id NOT IN (SELECT user_id FROM orders) ORDER BY user_id`,
+			lex.TokenIN, true, "user_id", "SELECT user_id FROM orders"},
+		{`SELECT user_id FROM users AS u WHERE EXISTS (SELECT 1 FROM orders AS o WHERE o.user_id = u.user_id)`,
+			lex.TokenExists, false, "", "SELECT 1 FROM orders AS o WHERE o.user_id = u.user_id"},
+		{`SELECT user_id FROM users AS u WHERE NOT EXISTS (SELECT 1 FROM orders AS o WHERE o.user_id = u.user_id)`,
+			lex.TokenExists, true, "", "SELECT 1 FROM orders AS o WHERE o.user_id = u.user_id"},
+	}
+	for _, tt := range tests {
+		sel, err := ParseSqlSelect(tt.sql)
+		assert.Tf(t, err == nil, "Must parse: %s  \n\t%v", tt.sql, err)
+		assert.Tf(t, sel.Where != nil && sel.Where.Source != nil, "must have sub-query %s", tt.sql)
+		assert.Equalf(t, tt.op, sel.Where.Op, "op for %s", tt.sql)
+		assert.Equalf(t, tt.negate, sel.Where.Negate, "negate for %s", tt.sql)
+		if tt.lhs == "" {
+			assert.Tf(t, sel.Where.Lhs == nil, "no lhs for %s", tt.sql)
+		} else {
+			assert.Tf(t, sel.Where.Lhs != nil, "lhs for %s", tt.sql)
+			assert.Equal(t, tt.lhs, sel.Where.Lhs.String())
+		}
+		assert.Equal(t, tt.sub, sel.Where.Source.String())
+
+		// re-parse of String() and round-trip through protobuf
+		sel2, err := ParseSqlSelect(sel.String())
+		assert.Tf(t, err == nil, "Must parse: %s  \n\t%v", sel.String(), err)
+		assert.Tf(t, sel.Equal(sel2), "re-parse must be equal %s", sel.String())
+		assert.Tf(t, sel.Equal(sel.Copy()), "pb copy must be equal %s", sel.String())
+	}
+}
+
+func TestSqlSetOps(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		sql     string
+		ops     string // op of each following select, with ALL
+		orderBy string
+		limit   int
+	}{
+		{`SELECT user_id FROM users UNION SELECT user_id FROM orders`,
+			"union", "", 0},
+		{`SELECT user_id FROM users UNION DISTINCT SELECT user_id FROM orders WHERE price > 10`,
+			"union", "", 0},
+		{`SELECT user_id, email FROM users WHERE user_id > 3
+			UNION ALL SELECT user_id, item_id FROM orders
+			EXCEPT SELECT user_id, email FROM banned
+			ORDER BY user_id DESC LIMIT 10`,
+			"union all,except", "user_id DESC", 10},
+		{`SELECT user_id FROM users INTERSECT ALL SELECT user_id FROM orders GROUP BY user_id LIMIT 5`,
+			"intersect all", "", 5},
+	}
+	for _, tt := range tests {
+		sel, err := ParseSqlSelect(tt.sql)
+		assert.Tf(t, err == nil, "Must parse: %s  \n\t%v", tt.sql, err)
+		ops := make([]string, len(sel.SetOps))
+		for i, op := range sel.SetOps {
+			ops[i] = op.Op.String()
+			if op.All {
+				ops[i] += " all"
+			}
+			// ORDER BY, LIMIT of last select are moved to the set operation
+			assert.Tf(t, len(op.Select.OrderBy) == 0 && op.Select.Limit == 0, "no order/limit %s", op.Select)
+		}
+		assert.Equalf(t, tt.ops, strings.Join(ops, ","), "ops for %s", tt.sql)
+		assert.Equalf(t, tt.orderBy, sel.OrderBy.String(), "order by for %s", tt.sql)
+		assert.Equalf(t, tt.limit, sel.Limit, "limit for %s", tt.sql)
+
+		sel2, err := ParseSqlSelect(sel.String())
+		assert.Tf(t, err == nil, "Must parse: %s  \n\t%v", sel.String(), err)
+		assert.Equal(t, sel.String(), sel2.String())
+		assert.Tf(t, sel.Equal(sel.Copy()), "copy must be equal %s", sel.String())
+	}
+
+	// ORDER BY, LIMIT only after the last select
+	parseSqlError(t, `SELECT user_id FROM users ORDER BY user_id UNION SELECT user_id FROM orders`)
+	parseSqlError(t, `SELECT user_id FROM users LIMIT 3 UNION ALL SELECT user_id FROM orders`)
+	parseSqlError(t, `SELECT user_id FROM users UNION user_id FROM orders`)
+}
+
 func TestSqlShowAst(t *testing.T) {
 	t.Parallel()
 	/*
@@ -414,6 +511,30 @@ func TestSqlShowAst(t *testing.T) {
 	assert.Tf(t, show.Db == "dbx", "has SHOW db: %q", show.Db)
 	assert.Tf(t, show.Identity == "tablex", "has identity: %q", show.Identity)
 	assert.Tf(t, show.Like.String() == "Field LIKE \"%\"", "has Like? %q", show.Like.String())
+
+	sql = "SHOW GRANTS FOR 'bob'@'localhost';"
+	req, err = ParseSql(sql)
+	assert.Tf(t, err == nil && req != nil, "Must parse: %s  \n\t%v", sql, err)
+	show, ok = req.(*SqlShow)
+	assert.Tf(t, ok, "is SqlShow: %T", req)
+	assert.Tf(t, show.ShowType == "grants", "has SHOW 'Grants'? %#v", show)
+	assert.Tf(t, show.Identity == "bob", "has identity: %q", show.Identity)
+
+	sql = "SHOW FUNCTIONS LIKE 'json_%';"
+	req, err = ParseSql(sql)
+	assert.Tf(t, err == nil && req != nil, "Must parse: %s  \n\t%v", sql, err)
+	show, ok = req.(*SqlShow)
+	assert.Tf(t, ok, "is SqlShow: %T", req)
+	assert.Tf(t, show.ShowType == "functions", "has SHOW 'Functions'? %#v", show)
+	assert.Tf(t, show.Like.String() == "Name LIKE \"json_%\"", "has Like? %q", show.Like.String())
+
+	sql = "SHOW FULL PROCESSLIST"
+	req, err = ParseSql(sql)
+	assert.Tf(t, err == nil && req != nil, "Must parse: %s  \n\t%v", sql, err)
+	show, ok = req.(*SqlShow)
+	assert.Tf(t, ok, "is SqlShow: %T", req)
+	assert.T(t, show.Full, "Wanted full")
+	assert.Tf(t, show.ShowType == "processlist", "has SHOW 'Processlist'? %#v", show)
 }
 
 func TestSqlCommands(t *testing.T) {
@@ -473,6 +594,29 @@ func TestSqlUpsert(t *testing.T) {
 	//assert.Tf(t, sel.Alias == "user_query", "has alias: %v", sel.Alias)
 }
 
+func TestSqlInsertRows(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		sql  string
+		rows int
+	}{
+		{`insert into mytable (id, str) values (0, "a")`, 1},
+		{`insert into mytable (id, str) values (0, "a"),(1,"b");`, 2},
+		{`insert into mytable (id, str, d) values (0, "a", now()), (1, "b", now())`, 2},
+		{`insert into mytable (id, d, str) values (0, now(), "a"), (1, now(), "b"), (2, now(), "c")`, 3},
+	}
+	for _, tt := range tests {
+		req, err := ParseSql(tt.sql)
+		assert.Tf(t, err == nil && req != nil, "Must parse: %s  \n\t%v", tt.sql, err)
+		ins, ok := req.(*SqlInsert)
+		assert.Tf(t, ok, "is SqlInsert: %T", req)
+		assert.Equalf(t, tt.rows, len(ins.Rows), "rows of %s", tt.sql)
+		for _, row := range ins.Rows {
+			assert.Equalf(t, len(ins.Columns), len(row), "values of row %s", tt.sql)
+		}
+	}
+}
+
 func TestSqlMultiStatement(t *testing.T) {
 	t.Parallel()
 	sql := `SET @var1 = "hello"; select a, b from accounts where name = @var1;`
@@ -500,6 +644,73 @@ func TestSqlUpdate(t *testing.T) {
 	assert.Tf(t, len(up.Values) == 2, "%v", up)
 }
 
+func TestSqlCreate(t *testing.T) {
+	t.Parallel()
+	sql := `CREATE TABLE IF NOT EXISTS users (
+			user_id bigint NOT NULL PRIMARY KEY,
+			name varchar(255) DEFAULT 'anon',
+			score decimal(10,2) DEFAULT 1.5,
+			active bool DEFAULT true
+		) WITH {"source":"mem"};`
+	req, err := ParseSql(sql)
+	assert.Tf(t, err == nil && req != nil, "Must parse: %s  \n\t%v", sql, err)
+	cr, ok := req.(*SqlCreate)
+	assert.Tf(t, ok, "is SqlCreate: %T", req)
+	assert.Equal(t, "users", cr.Identity)
+	assert.T(t, cr.IfNotExists)
+	assert.Equal(t, []string{"user_id"}, cr.PrimaryKey)
+	assert.Equal(t, "mem", cr.With.String("source"))
+	assert.Equal(t, 4, len(cr.Cols))
+	assert.Tf(t, cr.Cols[0].DataType == "bigint" && cr.Cols[0].NoNulls && cr.Cols[0].PrimaryKey, "%+v", cr.Cols[0])
+	assert.Equal(t, []int{255}, cr.Cols[1].Size)
+	assert.Equal(t, "anon", cr.Cols[1].Default.ToString())
+	assert.Equal(t, []int{10, 2}, cr.Cols[2].Size)
+	assert.Equal(t, 1.5, cr.Cols[2].Default.Value())
+	assert.Equal(t, true, cr.Cols[3].Default.Value())
+
+	// the String() of create parses the same
+	req, err = ParseSql(cr.String())
+	assert.Tf(t, err == nil, "Must parse: %s  \n\t%v", cr.String(), err)
+	assert.Equal(t, cr.String(), req.String())
+
+	req, err = ParseSql("CREATE TABLE orders (id int, user_id int NULL, PRIMARY KEY (id, user_id))")
+	assert.Tf(t, err == nil, "Must parse %v", err)
+	cr = req.(*SqlCreate)
+	assert.T(t, !cr.IfNotExists)
+	assert.Equal(t, []string{"id", "user_id"}, cr.PrimaryKey)
+
+	parseSqlError(t, "CREATE TABLE nocols ()")
+	parseSqlError(t, "CREATE TABLE notype (id)")
+	parseSqlError(t, "CREATE TABLE badopt (id int UNIQUE)")
+
+	req, err = ParseSql("CREATE TABLE orders (id int, user_id int NOT NULL REFERENCES users (id), item int, FOREIGN KEY (item) REFERENCES items (id))")
+	assert.Tf(t, err == nil, "Must parse %v", err)
+	cr = req.(*SqlCreate)
+	assert.Equal(t, 2, len(cr.ForeignKeys))
+	assert.Equal(t, &DdlForeignKey{Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}}, cr.ForeignKeys[0])
+	assert.Equal(t, &DdlForeignKey{Columns: []string{"item"}, RefTable: "items", RefColumns: []string{"id"}}, cr.ForeignKeys[1])
+	assert.T(t, cr.Cols[1].NoNulls && cr.Cols[1].References == cr.ForeignKeys[0])
+	req, err = ParseSql(cr.String())
+	assert.Tf(t, err == nil, "Must parse: %s  \n\t%v", cr.String(), err)
+	assert.Equal(t, cr.String(), req.String())
+
+	parseSqlError(t, "CREATE TABLE badfk (id int, FOREIGN KEY (id) REFERENCES users (a, b))")
+	parseSqlError(t, "CREATE TABLE badfk (id int, FOREIGN KEY (id) users (id))")
+}
+
+func TestSqlAnalyze(t *testing.T) {
+	t.Parallel()
+	req, err := ParseSql("ANALYZE TABLE `users`;")
+	assert.Tf(t, err == nil, "Must parse %v", err)
+	an, ok := req.(*SqlAnalyze)
+	assert.Tf(t, ok, "is SqlAnalyze: %T", req)
+	assert.Equal(t, "users", an.Identity)
+	assert.Equal(t, "ANALYZE TABLE users", an.String())
+
+	parseSqlError(t, "ANALYZE users")
+	parseSqlError(t, "ANALYZE TABLE users, orders")
+}
+
 func TestWithNameValue(t *testing.T) {
 	t.Parallel()
 	// some sql dialects support a WITH name=value syntax
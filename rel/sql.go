@@ -29,6 +29,8 @@ var (
 	_ SqlStatement = (*SqlDelete)(nil)
 	_ SqlStatement = (*SqlShow)(nil)
 	_ SqlStatement = (*SqlDescribe)(nil)
+	_ SqlStatement = (*SqlCreate)(nil)
+	_ SqlStatement = (*SqlAnalyze)(nil)
 	_ SqlStatement = (*SqlCommand)(nil)
 	_ SqlStatement = (*SqlInto)(nil)
 
@@ -97,6 +99,8 @@ type (
 		Offset    int
 		Alias     string       // Non-Standard sql, alias/name of sql another way of expression Prepared Statement
 		With      u.JsonHelper // Non-Standard SQL for properties/config info, similar to Cassandra with, purse json
+		Hints     string       // Optimizer hints from a leading /*+ hint(args) */ comment
+		SetOps    []*SqlSetOp  // UNION, INTERSECT, EXCEPT selects following this one
 		proj      *Projection  // Projected fields
 		isAgg     bool         // is this an aggregate query?  has group-by, or aggregate selector expressions (count, cardinality etc)
 		finalized bool         // have we already finalized, ie formalized left/right aliases
@@ -106,6 +110,16 @@ type (
 		pb            *SqlStatementPb
 		fingerprintid int64
 	}
+	// SqlSetOp a set operation combining the rows of the selects before it
+	// with the rows of Select.  ORDER BY, LIMIT following the last select
+	// apply to the whole set operation, and are on the first select.
+	//
+	//     SELECT a FROM x UNION ALL SELECT a FROM y ORDER BY a
+	SqlSetOp struct {
+		Op     lex.TokenType // TokenUnion, TokenIntersect, TokenExcept
+		All    bool          // ALL, keep duplicate rows
+		Select *SqlSelect
+	}
 	// Source is a table name, sub-query, or join as used in
 	// SELECT <columns> FROM <SQLSOURCE>
 	//  - SELECT .. FROM table_name
@@ -140,8 +154,10 @@ type (
 	// - WHERE tolower(x) IN (select name from q)
 	SqlWhere struct {
 		// Either Op + Source exists
-		Op     lex.TokenType // (In|=|ON)  for Select Clauses operators
+		Op     lex.TokenType // (In|=|ON|Exists)  for Select Clauses operators
 		Source *SqlSelect    // IN (SELECT a,b,c from z)
+		Lhs    expr.Node     // left hand side of   lhs IN (SELECT ...), nil for EXISTS
+		Negate bool          // NOT IN (SELECT ...),  NOT EXISTS (SELECT ...)
 
 		// OR expr but not both
 		Expr expr.Node // x = y AND q > 5
@@ -195,6 +211,39 @@ type (
 		Tok      lex.Token // Explain, Describe, Desc
 		Stmt     SqlStatement
 	}
+	// SqlCreate CREATE TABLE statement
+	SqlCreate struct {
+		Raw         string       // full original raw statement
+		Identity    string       // name of table
+		IfNotExists bool         // CREATE TABLE IF NOT EXISTS
+		Cols        []*DdlColumn // column definitions
+		PrimaryKey  []string     // PRIMARY KEY (a, b) of the table, or of a column
+		ForeignKeys []*DdlForeignKey
+		With        u.JsonHelper // WITH {"source":"name"}
+	}
+	// DdlForeignKey a FOREIGN KEY (a) REFERENCES t (b) of a CREATE TABLE, or
+	// the REFERENCES t (b) of a column
+	DdlForeignKey struct {
+		Columns    []string
+		RefTable   string
+		RefColumns []string
+	}
+	// SqlAnalyze ANALYZE TABLE statement, computing the stats of the columns
+	// of a table
+	SqlAnalyze struct {
+		Raw      string // full original raw statement
+		Identity string // name of table
+	}
+	// DdlColumn the definition of a column of a CREATE TABLE
+	DdlColumn struct {
+		Name       string
+		DataType   string         // bigint, varchar, etc, lower cased
+		Size       []int          // varchar(255), decimal(10,2)
+		NoNulls    bool           // NOT NULL
+		Default    value.Value    // DEFAULT value, nil if none
+		PrimaryKey bool           // PRIMARY KEY of the column
+		References *DdlForeignKey // REFERENCES t (col) of the column, nil if none
+	}
 	// SqlInto   INTO statement   (select a,b,c from y INTO z)
 	SqlInto struct {
 		Table string
@@ -229,6 +278,31 @@ type (
 		Agg             bool      // aggregate function column?   count(*), avg(x) etc
 		Expr            expr.Node // Expression, optional, often Identity.Node
 		Guard           expr.Node // column If guard, non-standard sql column guard
+		Over            *Window   // window of a window function column, optional
+	}
+	// Window the OVER clause of a window function column
+	//
+	//     row_number() OVER (PARTITION BY a ORDER BY b DESC)
+	//     sum(x) OVER (ORDER BY b ROWS BETWEEN 2 PRECEDING AND CURRENT ROW)
+	Window struct {
+		PartitionBy []expr.Node  // partition keys, all rows one partition if empty
+		OrderBy     Columns      // order of rows within a partition
+		Frame       *WindowFrame // optional, nil for default frame
+	}
+	// WindowFrame the rows of a partition a window function is evaluated over,
+	// relative to the current row.  ROWS frames are row offsets, RANGE frames
+	// include the peers (rows with equal order by keys) of the current row.
+	WindowFrame struct {
+		Range bool
+		Start WindowBound
+		End   WindowBound
+	}
+	// WindowBound a frame boundary, Offset rows from the current row, negative
+	// for PRECEDING, positive for FOLLOWING, 0 for CURRENT ROW.  Unbounded
+	// boundaries are start (Offset < 0) or end (Offset > 0) of partition.
+	WindowBound struct {
+		Unbounded bool
+		Offset    int
 	}
 	// List of Value columns in INSERT into TABLE (colnames) VALUES (valuecolumns)
 	ValueColumn struct {
@@ -598,6 +672,10 @@ func (m *Column) WriteDialect(w expr.DialectWriter) {
 		}
 	}
 
+	if m.Over != nil {
+		io.WriteString(w, " ")
+		m.Over.WriteDialect(w)
+	}
 	if m.asQuoteByte != 0 && m.originalAs != "" {
 		io.WriteString(w, " AS ")
 		w.WriteIdentity(m.As)
@@ -724,6 +802,9 @@ func (m *Column) Equal(c *Column) bool {
 			return false
 		}
 	}
+	if !m.Over.Equal(c.Over) {
+		return false
+	}
 	return true
 }
 
@@ -760,6 +841,7 @@ func (m *Column) Copy() *Column {
 		Star:            m.Star,
 		Expr:            m.Expr,
 		Guard:           m.Guard,
+		Over:            m.Over,
 	}
 }
 func (m *Column) ToPB() *ColumnPb {
@@ -818,6 +900,85 @@ func columnFromPb(c *ColumnPb) *Column {
 	}
 }
 
+func (m *Window) String() string {
+	w := expr.NewDefaultWriter()
+	m.WriteDialect(w)
+	return w.String()
+}
+func (m *Window) WriteDialect(w expr.DialectWriter) {
+	io.WriteString(w, "OVER (")
+	sep := ""
+	if len(m.PartitionBy) > 0 {
+		io.WriteString(w, "PARTITION BY ")
+		for i, n := range m.PartitionBy {
+			if i > 0 {
+				io.WriteString(w, ", ")
+			}
+			n.WriteDialect(w)
+		}
+		sep = " "
+	}
+	if len(m.OrderBy) > 0 {
+		io.WriteString(w, sep)
+		io.WriteString(w, "ORDER BY ")
+		for i, col := range m.OrderBy {
+			if i > 0 {
+				io.WriteString(w, ", ")
+			}
+			col.WriteDialect(w)
+		}
+		sep = " "
+	}
+	if m.Frame != nil {
+		io.WriteString(w, sep)
+		m.Frame.WriteDialect(w)
+	}
+	io.WriteString(w, ")")
+}
+func (m *Window) Equal(w *Window) bool {
+	if m == nil || w == nil {
+		return m == nil && w == nil
+	}
+	if len(m.PartitionBy) != len(w.PartitionBy) {
+		return false
+	}
+	for i, n := range m.PartitionBy {
+		if !n.Equal(w.PartitionBy[i]) {
+			return false
+		}
+	}
+	if !m.OrderBy.Equal(w.OrderBy) {
+		return false
+	}
+	if m.Frame == nil || w.Frame == nil {
+		return m.Frame == nil && w.Frame == nil
+	}
+	return *m.Frame == *w.Frame
+}
+func (m *WindowFrame) WriteDialect(w expr.DialectWriter) {
+	if m.Range {
+		io.WriteString(w, "RANGE BETWEEN ")
+	} else {
+		io.WriteString(w, "ROWS BETWEEN ")
+	}
+	io.WriteString(w, m.Start.String())
+	io.WriteString(w, " AND ")
+	io.WriteString(w, m.End.String())
+}
+func (m WindowBound) String() string {
+	switch {
+	case m.Unbounded && m.Offset < 0:
+		return "UNBOUNDED PRECEDING"
+	case m.Unbounded:
+		return "UNBOUNDED FOLLOWING"
+	case m.Offset < 0:
+		return fmt.Sprintf("%d PRECEDING", -m.Offset)
+	case m.Offset > 0:
+		return fmt.Sprintf("%d FOLLOWING", m.Offset)
+	}
+	return "CURRENT ROW"
+}
+
 // Return left, right values if is of form   `table.column` and
 // also return true/false for if it even has left/right
 func (m *Column) LeftRight() (string, string, bool) {
@@ -859,6 +1020,9 @@ func (m *SqlSelect) ToPB() *SqlSelectPb {
 func (m *SqlSelect) Copy() *SqlSelect {
 	pb := m.ToPB()
 	selCopy := SqlSelectFromPb(pb)
+	for _, op := range m.SetOps {
+		selCopy.SetOps = append(selCopy.SetOps, &SqlSetOp{Op: op.Op, All: op.All, Select: op.Select.Copy()})
+	}
 	return selCopy
 }
 
@@ -1004,6 +1168,14 @@ func (m *SqlSelect) Equal(ss SqlStatement) bool {
 			return false
 		}
 	}
+	if len(m.SetOps) != len(s.SetOps) {
+		return false
+	}
+	for i, op := range m.SetOps {
+		if !op.Equal(s.SetOps[i]) {
+			return false
+		}
+	}
 	if !m.proj.Equal(s.proj) {
 		return false
 	}
@@ -1063,6 +1235,33 @@ func (m *SqlSelect) IsAggQuery() bool {
 	}
 	return false
 }
+
+// IsWindowQuery does this select have window function columns
+func (m *SqlSelect) IsWindowQuery() bool {
+	for _, col := range m.Columns {
+		if col.Over != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// SetOpSelects the selects of a set operation in order.  The first is this
+// select without its set operations, and the ORDER BY, LIMIT which apply to
+// the whole set operation.
+func (m *SqlSelect) SetOpSelects() []*SqlSelect {
+	first := *m
+	first.SetOps = nil
+	first.OrderBy = nil
+	first.Limit, first.Offset = 0, 0
+	first.With = nil
+	first.pb, first.fingerprintid = nil, 0
+	sels := []*SqlSelect{&first}
+	for _, op := range m.SetOps {
+		sels = append(sels, op.Select)
+	}
+	return sels
+}
 func (m *SqlSelect) String() string {
 	w := NewSqlDialect()
 	m.writeDialectDepth(0, w)
@@ -1108,6 +1307,9 @@ func (m *SqlSelect) writeDialectDepth(depth int, w expr.DialectWriter) {
 		io.WriteString(w, " HAVING ")
 		m.Having.WriteDialect(w)
 	}
+	for _, op := range m.SetOps {
+		op.writeDialectDepth(depth, w)
+	}
 	if len(m.OrderBy) > 0 {
 		io.WriteString(w, " ORDER BY ")
 		m.OrderBy.WriteDialect(w)
@@ -1119,6 +1321,32 @@ func (m *SqlSelect) writeDialectDepth(depth int, w expr.DialectWriter) {
 		io.WriteString(w, fmt.Sprintf(" OFFSET %d", m.Offset))
 	}
 }
+func (m *SqlSetOp) String() string {
+	w := NewSqlDialect()
+	m.writeDialectDepth(0, w)
+	return w.String()
+}
+func (m *SqlSetOp) writeDialectDepth(depth int, w expr.DialectWriter) {
+	io.WriteString(w, " ")
+	io.WriteString(w, strings.ToUpper(m.Op.String()))
+	if m.All {
+		io.WriteString(w, " ALL")
+	}
+	io.WriteString(w, " ")
+	m.Select.writeDialectDepth(depth, w)
+}
+func (m *SqlSetOp) Equal(s *SqlSetOp) bool {
+	if m == nil && s == nil {
+		return true
+	}
+	if m == nil || s == nil {
+		return false
+	}
+	if m.Op != s.Op || m.All != s.All {
+		return false
+	}
+	return m.Select.Equal(s.Select)
+}
 func (m *SqlSelect) FingerPrintID() int64 {
 	if m.fingerprintid == 0 {
 		h := fnv.New64()
@@ -1386,7 +1614,8 @@ func (m *SqlSource) BuildColIndex(colNames []string) error {
 				}
 			}
 			if !found {
-				if !col.IsLiteral() {
+				// window function values are added after the source
+				if !col.IsLiteral() && col.Over == nil {
 					u.Warnf("missing column?  %s", col)
 					return fmt.Errorf("Missing Column in source: %q", col.String())
 				}
@@ -1597,6 +1826,7 @@ func joinNodesForFrom(stmt *SqlSelect, from *SqlSource, node expr.Node, depth in
 			}
 		}
 		fn := expr.NewFuncNode(nt.Name, nt.F)
+		fn.Distinct = nt.Distinct
 		fn.Args = args
 		if depth == 1 {
 			//u.Infof("adding func: %s", fn.String())
@@ -1752,6 +1982,7 @@ func rewriteNode(from *SqlSource, node expr.Node) expr.Node {
 		}
 	case *expr.FuncNode:
 		fn := expr.NewFuncNode(nt.Name, nt.F)
+		fn.Distinct = nt.Distinct
 		fn.Args = make([]expr.Node, len(nt.Args))
 		for i, arg := range nt.Args {
 			fn.Args[i] = rewriteNode(from, arg)
@@ -2016,7 +2247,14 @@ func (m *SqlWhere) writeDialectDepth(depth int, w expr.DialectWriter) {
 	// Op = subselect or in etc
 	//  SELECT ... WHERE IN (SELECT ...)
 	if int(m.Op) != 0 && m.Source != nil {
-		io.WriteString(w, m.Op.String())
+		if m.Lhs != nil {
+			m.Lhs.WriteDialect(w)
+			io.WriteString(w, " ")
+		}
+		if m.Negate {
+			io.WriteString(w, "NOT ")
+		}
+		io.WriteString(w, strings.ToUpper(m.Op.String()))
 		io.WriteString(w, " (")
 		m.Source.writeDialectDepth(depth+1, w)
 		io.WriteString(w, ")")
@@ -2040,13 +2278,16 @@ func (m *SqlWhere) Equal(s *SqlWhere) bool {
 	if m != nil && s == nil {
 		return false
 	}
-	if m.Op != s.Op {
+	if m.Op != s.Op || m.Negate != s.Negate {
+		return false
+	}
+	if m.Lhs != nil && !m.Lhs.Equal(s.Lhs) || m.Lhs == nil && s.Lhs != nil {
 		return false
 	}
 	if !m.Source.Equal(s.Source) {
 		return false
 	}
-	if !m.Expr.Equal(s.Expr) {
+	if m.Expr != nil && !m.Expr.Equal(s.Expr) || m.Expr == nil && s.Expr != nil {
 		return false
 	}
 	return true
@@ -2060,11 +2301,19 @@ func SqlWhereToPb(m *SqlWhere) *SqlWherePb {
 	if m.Expr != nil {
 		s.Expr = m.Expr.ToPB()
 	}
+	s.Negate = m.Negate
+	if m.Lhs != nil {
+		s.Lhs = m.Lhs.ToPB()
+	}
 	return &s
 }
 func SqlWhereFromPb(pb *SqlWherePb) *SqlWhere {
 	w := SqlWhere{
-		Op: lex.TokenType(pb.GetOp()),
+		Op:     lex.TokenType(pb.GetOp()),
+		Negate: pb.GetNegate(),
+	}
+	if pb.Lhs != nil {
+		w.Lhs = expr.NodeFromNodePb(pb.GetLhs())
 	}
 	if pb.Source != nil {
 		w.Source = SqlSelectFromPb(pb.Source)
@@ -2229,6 +2478,98 @@ func (m *SqlDescribe) Keyword() lex.TokenType            { return lex.TokenDescr
 func (m *SqlDescribe) String() string                    { return fmt.Sprintf("%s ", m.Keyword()) }
 func (m *SqlDescribe) WriteDialect(w expr.DialectWriter) {}
 
+func (m *SqlCreate) Keyword() lex.TokenType { return lex.TokenCreate }
+func (m *SqlCreate) String() string {
+	buf := bytes.Buffer{}
+	buf.WriteString("CREATE TABLE ")
+	if m.IfNotExists {
+		buf.WriteString("IF NOT EXISTS ")
+	}
+	buf.WriteString(expr.IdentityMaybeQuote('`', m.Identity))
+	buf.WriteString(" (")
+	for i, col := range m.Cols {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(col.String())
+	}
+	if len(m.PrimaryKey) > 0 {
+		buf.WriteString(", PRIMARY KEY (")
+		for i, name := range m.PrimaryKey {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(expr.IdentityMaybeQuote('`', name))
+		}
+		buf.WriteByte(')')
+	}
+	for _, fk := range m.ForeignKeys {
+		buf.WriteString(", ")
+		buf.WriteString(fk.String())
+	}
+	buf.WriteByte(')')
+	if len(m.With) > 0 {
+		by, _ := json.Marshal(m.With)
+		buf.WriteString(" WITH ")
+		buf.Write(by)
+	}
+	return buf.String()
+}
+func (m *SqlCreate) WriteDialect(w expr.DialectWriter) {}
+
+func (m *SqlAnalyze) Keyword() lex.TokenType { return lex.TokenAnalyze }
+func (m *SqlAnalyze) String() string {
+	return "ANALYZE TABLE " + expr.IdentityMaybeQuote('`', m.Identity)
+}
+func (m *SqlAnalyze) WriteDialect(w expr.DialectWriter) {}
+
+func (m *DdlForeignKey) String() string {
+	quoted := func(names []string) string {
+		q := make([]string, len(names))
+		for i, name := range names {
+			q[i] = expr.IdentityMaybeQuote('`', name)
+		}
+		return strings.Join(q, ", ")
+	}
+	return fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)", quoted(m.Columns),
+		expr.IdentityMaybeQuote('`', m.RefTable), quoted(m.RefColumns))
+}
+
+func (m *DdlColumn) String() string {
+	buf := bytes.Buffer{}
+	buf.WriteString(expr.IdentityMaybeQuote('`', m.Name))
+	buf.WriteByte(' ')
+	buf.WriteString(m.DataType)
+	if len(m.Size) > 0 {
+		buf.WriteByte('(')
+		for i, n := range m.Size {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			fmt.Fprintf(&buf, "%d", n)
+		}
+		buf.WriteByte(')')
+	}
+	if m.NoNulls {
+		buf.WriteString(" NOT NULL")
+	}
+	if m.Default != nil {
+		buf.WriteString(" DEFAULT ")
+		switch dv := m.Default.(type) {
+		case value.StringValue:
+			buf.WriteString(expr.LiteralQuoteEscape('\'', dv.Val()))
+		case value.NilValue:
+			buf.WriteString("NULL")
+		default:
+			buf.WriteString(dv.ToString())
+		}
+	}
+	if m.PrimaryKey {
+		buf.WriteString(" PRIMARY KEY")
+	}
+	return buf.String()
+}
+
 func (m *SqlShow) Keyword() lex.TokenType            { return lex.TokenShow }
 func (m *SqlShow) String() string                    { return fmt.Sprintf("%s ", m.Keyword()) }
 func (m *SqlShow) WriteDialect(w expr.DialectWriter) {}
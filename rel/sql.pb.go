@@ -83,8 +83,8 @@ type SqlSelectPb struct {
 	Into             *string        `protobuf:"bytes,7,opt,name=into" json:"into,omitempty"`
 	Where            *SqlWherePb    `protobuf:"bytes,8,opt,name=where" json:"where,omitempty"`
 	Having           *expr.NodePb   `protobuf:"bytes,9,opt,name=having" json:"having,omitempty"`
-	GroupBy          []*ColumnPb    `protobuf:"bytes,11,rep,name=groupBy" json:"groupBy,omitempty"`
-	OrderBy          []*ColumnPb    `protobuf:"bytes,10,rep,name=orderBy" json:"orderBy,omitempty"`
+	GroupBy          []*ColumnPb    `protobuf:"bytes,10,rep,name=groupBy" json:"groupBy,omitempty"`
+	OrderBy          []*ColumnPb    `protobuf:"bytes,11,rep,name=orderBy" json:"orderBy,omitempty"`
 	Limit            int32          `protobuf:"varint,12,opt,name=limit" json:"limit"`
 	Offset           int32          `protobuf:"varint,13,opt,name=offset" json:"offset"`
 	Alias            *string        `protobuf:"bytes,14,opt,name=alias" json:"alias,omitempty"`
@@ -364,9 +364,12 @@ func (m *SqlSourcePb) GetSeekable() bool {
 }
 
 type SqlWherePb struct {
-	Op               int32        `protobuf:"varint,1,req,name=op" json:"op"`
-	Source           *SqlSelectPb `protobuf:"bytes,2,opt,name=source" json:"source,omitempty"`
-	Expr             *expr.NodePb `protobuf:"bytes,3,opt,name=Expr,json=expr" json:"Expr,omitempty"`
+	Op     int32        `protobuf:"varint,1,req,name=op" json:"op"`
+	Source *SqlSelectPb `protobuf:"bytes,2,opt,name=source" json:"source,omitempty"`
+	Expr   *expr.NodePb `protobuf:"bytes,3,opt,name=Expr,json=expr" json:"Expr,omitempty"`
+	// optional bytes Expr = 3 [(gogoproto.customtype) = "github.com/araddon/qlbridge/expr.NodePb", (gogoproto.nullable) = true];
+	Negate           bool         `protobuf:"varint,4,opt,name=negate" json:"negate"`
+	Lhs              *expr.NodePb `protobuf:"bytes,5,opt,name=lhs" json:"lhs,omitempty"`
 	XXX_unrecognized []byte       `json:"-"`
 }
 
@@ -396,6 +399,20 @@ func (m *SqlWherePb) GetExpr() *expr.NodePb {
 	return nil
 }
 
+func (m *SqlWherePb) GetNegate() bool {
+	if m != nil {
+		return m.Negate
+	}
+	return false
+}
+
+func (m *SqlWherePb) GetLhs() *expr.NodePb {
+	if m != nil {
+		return m.Lhs
+	}
+	return nil
+}
+
 type ProjectionPb struct {
 	Distinct         bool              `protobuf:"varint,1,req,name=distinct" json:"distinct"`
 	Final            bool              `protobuf:"varint,2,req,name=final" json:"final"`
@@ -840,8 +857,8 @@ func (m *SqlSelectPb) MarshalTo(data []byte) (int, error) {
 		}
 		i += n5
 	}
-	if len(m.OrderBy) > 0 {
-		for _, msg := range m.OrderBy {
+	if len(m.GroupBy) > 0 {
+		for _, msg := range m.GroupBy {
 			data[i] = 0x52
 			i++
 			i = encodeVarintSql(data, i, uint64(msg.Size()))
@@ -852,8 +869,8 @@ func (m *SqlSelectPb) MarshalTo(data []byte) (int, error) {
 			i += n
 		}
 	}
-	if len(m.GroupBy) > 0 {
-		for _, msg := range m.GroupBy {
+	if len(m.OrderBy) > 0 {
+		for _, msg := range m.OrderBy {
 			data[i] = 0x5a
 			i++
 			i = encodeVarintSql(data, i, uint64(msg.Size()))
@@ -1098,6 +1115,24 @@ func (m *SqlWherePb) MarshalTo(data []byte) (int, error) {
 		}
 		i += n11
 	}
+	data[i] = 0x20
+	i++
+	if m.Negate {
+		data[i] = 1
+	} else {
+		data[i] = 0
+	}
+	i++
+	if m.Lhs != nil {
+		data[i] = 0x2a
+		i++
+		i = encodeVarintSql(data, i, uint64(m.Lhs.Size()))
+		n12, err := m.Lhs.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n12
+	}
 	if m.XXX_unrecognized != nil {
 		i += copy(data[i:], m.XXX_unrecognized)
 	}
@@ -1204,11 +1239,11 @@ func (m *ResultColumnPb) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x22
 		i++
 		i = encodeVarintSql(data, i, uint64(m.Column.Size()))
-		n12, err := m.Column.MarshalTo(data[i:])
+		n13, err := m.Column.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n12
+		i += n13
 	}
 	if m.Star != nil {
 		data[i] = 0x28
@@ -1361,11 +1396,11 @@ func (m *ColumnPb) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintSql(data, i, uint64(m.Expr.Size()))
-		n13, err := m.Expr.MarshalTo(data[i:])
+		n14, err := m.Expr.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n13
+		i += n14
 	}
 	if m.Guard != nil {
 		data[i] = 0x8a
@@ -1373,11 +1408,11 @@ func (m *ColumnPb) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintSql(data, i, uint64(m.Guard.Size()))
-		n14, err := m.Guard.MarshalTo(data[i:])
+		n15, err := m.Guard.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n14
+		i += n15
 	}
 	if m.XXX_unrecognized != nil {
 		i += copy(data[i:], m.XXX_unrecognized)
@@ -1404,11 +1439,11 @@ func (m *CommandColumnPb) MarshalTo(data []byte) (int, error) {
 		data[i] = 0xa
 		i++
 		i = encodeVarintSql(data, i, uint64(m.Expr.Size()))
-		n15, err := m.Expr.MarshalTo(data[i:])
+		n16, err := m.Expr.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n15
+		i += n16
 	}
 	data[i] = 0x12
 	i++
@@ -1501,14 +1536,14 @@ func (m *SqlSelectPb) Size() (n int) {
 		l = m.Having.Size()
 		n += 1 + l + sovSql(uint64(l))
 	}
-	if len(m.OrderBy) > 0 {
-		for _, e := range m.OrderBy {
+	if len(m.GroupBy) > 0 {
+		for _, e := range m.GroupBy {
 			l = e.Size()
 			n += 1 + l + sovSql(uint64(l))
 		}
 	}
-	if len(m.GroupBy) > 0 {
-		for _, e := range m.GroupBy {
+	if len(m.OrderBy) > 0 {
+		for _, e := range m.OrderBy {
 			l = e.Size()
 			n += 1 + l + sovSql(uint64(l))
 		}
@@ -1602,6 +1637,11 @@ func (m *SqlWherePb) Size() (n int) {
 		l = m.Expr.Size()
 		n += 1 + l + sovSql(uint64(l))
 	}
+	n += 2
+	if m.Lhs != nil {
+		l = m.Lhs.Size()
+		n += 1 + l + sovSql(uint64(l))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -2196,7 +2236,7 @@ func (m *SqlSelectPb) Unmarshal(data []byte) error {
 			iNdEx = postIndex
 		case 10:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field OrderBy", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field GroupBy", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -2220,14 +2260,14 @@ func (m *SqlSelectPb) Unmarshal(data []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.OrderBy = append(m.OrderBy, &ColumnPb{})
-			if err := m.OrderBy[len(m.OrderBy)-1].Unmarshal(data[iNdEx:postIndex]); err != nil {
+			m.GroupBy = append(m.GroupBy, &ColumnPb{})
+			if err := m.GroupBy[len(m.GroupBy)-1].Unmarshal(data[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
 		case 11:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field GroupBy", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field OrderBy", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -2251,8 +2291,8 @@ func (m *SqlSelectPb) Unmarshal(data []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.GroupBy = append(m.GroupBy, &ColumnPb{})
-			if err := m.GroupBy[len(m.GroupBy)-1].Unmarshal(data[iNdEx:postIndex]); err != nil {
+			m.OrderBy = append(m.OrderBy, &ColumnPb{})
+			if err := m.OrderBy[len(m.OrderBy)-1].Unmarshal(data[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
@@ -3080,6 +3120,59 @@ func (m *SqlWherePb) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Negate", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSql
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Negate = bool(v != 0)
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Lhs", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSql
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthSql
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Lhs == nil {
+				m.Lhs = &expr.NodePb{}
+			}
+			if err := m.Lhs.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipSql(data[iNdEx:])
@@ -4322,72 +4415,73 @@ var (
 )
 
 var fileDescriptorSql = []byte{
-	// 1067 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x8c, 0x56, 0xcd, 0x6e, 0xdb, 0x46,
-	0x10, 0x0e, 0x25, 0x51, 0x96, 0x56, 0x8e, 0xed, 0x6c, 0x82, 0x62, 0x61, 0x14, 0xae, 0x41, 0x14,
-	0x81, 0xd1, 0x34, 0x52, 0x91, 0x1e, 0x7a, 0x8e, 0x83, 0xb6, 0x30, 0x0a, 0xa4, 0x8e, 0x5c, 0xa0,
-	0x67, 0x4a, 0x5c, 0x51, 0x8c, 0x29, 0xae, 0xbc, 0x24, 0xe5, 0x38, 0x4f, 0xd2, 0x4b, 0x81, 0x5e,
-	0x7b, 0xeb, 0x33, 0xf4, 0xe4, 0x63, 0x9f, 0xa0, 0xe8, 0x0f, 0xfa, 0x1e, 0x9d, 0x99, 0x25, 0x97,
-	0x6b, 0x47, 0x72, 0x72, 0x10, 0xc0, 0xfd, 0xe6, 0xdb, 0xdd, 0x99, 0xd9, 0x6f, 0x66, 0xc4, 0xfa,
-	0xf9, 0x45, 0x3a, 0x5c, 0x6a, 0x55, 0x28, 0xde, 0xd6, 0x32, 0xdd, 0x7f, 0x12, 0x27, 0xc5, 0xbc,
-	0x9c, 0x0c, 0xa7, 0x6a, 0x31, 0x0a, 0x75, 0x18, 0x45, 0x2a, 0x1b, 0x5d, 0xa4, 0x13, 0x9d, 0x44,
-	0xb1, 0x1c, 0xc9, 0x37, 0x4b, 0x3d, 0xca, 0x54, 0x24, 0xcd, 0x8e, 0xfd, 0xa7, 0x0e, 0x39, 0x56,
-	0xb1, 0x1a, 0x11, 0x3c, 0x29, 0x67, 0xb4, 0xa2, 0x05, 0x7d, 0x19, 0x7a, 0xf0, 0xab, 0xc7, 0x76,
-	0xce, 0x2e, 0xd2, 0xb3, 0x22, 0x2c, 0xe4, 0x42, 0x66, 0xc5, 0xe9, 0x84, 0x0f, 0x59, 0x37, 0x97,
-	0xa9, 0x9c, 0x16, 0xc2, 0x3b, 0xf4, 0x8e, 0x06, 0xcf, 0xf6, 0x86, 0xe0, 0xc4, 0x10, 0x49, 0x84,
-	0x9e, 0x4e, 0x8e, 0x3b, 0xd7, 0x7f, 0x7e, 0xe2, 0x8d, 0x2b, 0x16, 0xf1, 0x55, 0xa9, 0xa7, 0x52,
-	0xb4, 0x6e, 0xf1, 0x09, 0x75, 0xf8, 0xb4, 0xe6, 0x5f, 0x31, 0x06, 0x77, 0xbf, 0x86, 0xad, 0x89,
-	0xca, 0x44, 0x87, 0xf6, 0x3c, 0xa0, 0x3d, 0xa7, 0x16, 0xb6, 0x9b, 0x1c, 0x6a, 0xf0, 0x9b, 0xcf,
-	0x06, 0x8e, 0x1b, 0xfc, 0x11, 0x6b, 0x45, 0x13, 0x70, 0xb2, 0x75, 0xd4, 0x27, 0xf6, 0xbd, 0x31,
-	0xac, 0xf9, 0x47, 0xac, 0xad, 0xc3, 0x4b, 0xf0, 0xa5, 0x81, 0x11, 0xe0, 0x82, 0x75, 0xf2, 0x22,
-	0xd4, 0xa2, 0x0d, 0x86, 0x5e, 0x65, 0x20, 0x84, 0x1f, 0xb2, 0x5e, 0x94, 0xe4, 0x45, 0x92, 0x41,
-	0xc8, 0x1d, 0xc7, 0x6a, 0x51, 0xfe, 0x94, 0x6d, 0x4d, 0x55, 0x5a, 0x2e, 0xb2, 0x5c, 0xf8, 0x87,
-	0x6d, 0xf0, 0xf7, 0x3e, 0xf9, 0xfb, 0x82, 0x30, 0xeb, 0x6b, 0xcd, 0xe1, 0x9f, 0xb1, 0xce, 0x4c,
-	0xab, 0x85, 0xe8, 0x12, 0x77, 0x53, 0x3e, 0x88, 0x83, 0x6e, 0x25, 0x59, 0xa1, 0xc4, 0x16, 0xe4,
-	0xa1, 0x5f, 0x5b, 0x10, 0xe1, 0x4f, 0x98, 0x7f, 0x39, 0x97, 0x5a, 0x8a, 0x1e, 0xa5, 0x68, 0xb7,
-	0x3e, 0xe6, 0x47, 0x04, 0xed, 0x29, 0x86, 0x03, 0x57, 0x76, 0xe7, 0xe1, 0x2a, 0xc9, 0x62, 0xd1,
-	0x27, 0xf6, 0xf6, 0x10, 0x85, 0x31, 0x7c, 0x09, 0xc2, 0x68, 0x1e, 0xc0, 0x30, 0x30, 0x1a, 0xa5,
-	0x23, 0xa9, 0x8f, 0xaf, 0x04, 0xbb, 0x23, 0x9a, 0x8a, 0x83, 0xf4, 0x58, 0xab, 0x72, 0x09, 0xf4,
-	0xc1, 0x1d, 0xf4, 0x8a, 0xc3, 0xf7, 0x99, 0x9f, 0x26, 0x8b, 0xa4, 0x10, 0xdb, 0xe0, 0x88, 0x5f,
-	0xa5, 0xd2, 0x40, 0xfc, 0x63, 0xd6, 0x55, 0xb3, 0x59, 0x2e, 0x0b, 0x71, 0xdf, 0x31, 0x56, 0x18,
-	0xee, 0x0c, 0xd3, 0x24, 0xcc, 0xc5, 0x8e, 0x93, 0x0b, 0x03, 0xdd, 0x12, 0xcd, 0xee, 0x07, 0x8b,
-	0x06, 0x0f, 0x4d, 0xf2, 0xe7, 0x71, 0x2c, 0xf6, 0x9c, 0x97, 0x35, 0x10, 0x0f, 0x58, 0x7f, 0x96,
-	0x64, 0x70, 0xc1, 0x5b, 0x19, 0x89, 0x07, 0x8e, 0xbd, 0x81, 0x91, 0x93, 0x4f, 0xe7, 0x72, 0x11,
-	0x5e, 0xe8, 0x2b, 0xc1, 0x5d, 0x8e, 0x85, 0xf1, 0x0d, 0x2f, 0xa1, 0xea, 0xc4, 0x43, 0x70, 0x6b,
-	0xbb, 0x7e, 0x43, 0x44, 0x82, 0xdf, 0x3b, 0x46, 0xb2, 0xd5, 0xcb, 0xa3, 0x37, 0x74, 0x34, 0x95,
-	0x96, 0xf5, 0x86, 0x20, 0xfe, 0x29, 0x63, 0x14, 0xeb, 0x49, 0x96, 0x49, 0x4d, 0xb5, 0x54, 0xe7,
-	0xc0, 0xc1, 0x5d, 0x29, 0xb6, 0x3f, 0x40, 0x8a, 0x9f, 0xb3, 0x1e, 0x7c, 0x9e, 0x64, 0x91, 0x7c,
-	0x03, 0xda, 0x46, 0x3e, 0x23, 0xfe, 0x77, 0xab, 0x93, 0xac, 0xa8, 0x75, 0x5e, 0x33, 0xf8, 0x17,
-	0xac, 0xff, 0x5a, 0x25, 0x19, 0xaa, 0xa6, 0x56, 0xfa, 0x3a, 0x21, 0x35, 0x24, 0xa7, 0xf8, 0xbb,
-	0xef, 0x69, 0x16, 0xa6, 0xf8, 0xab, 0xea, 0x6c, 0xd4, 0xde, 0x54, 0x67, 0x16, 0x2e, 0x8c, 0xd6,
-	0x6b, 0x03, 0x21, 0x8d, 0x2a, 0xfa, 0x8e, 0xa9, 0x52, 0x05, 0x74, 0x00, 0xb5, 0x04, 0x11, 0xb7,
-	0xac, 0x96, 0x60, 0xcd, 0x1f, 0xb3, 0x41, 0x2a, 0x67, 0xc5, 0xf7, 0x7a, 0x9c, 0xc4, 0xf3, 0x02,
-	0x44, 0xdb, 0x98, 0x5d, 0x03, 0xd6, 0x3d, 0x06, 0xf2, 0xc3, 0xd5, 0x52, 0x82, 0x58, 0x1b, 0x92,
-	0x45, 0x21, 0x3a, 0xfa, 0xfe, 0x1a, 0x32, 0x40, 0x8a, 0x5d, 0x9f, 0x0e, 0xcb, 0xe1, 0xcf, 0x58,
-	0x2f, 0x2f, 0x27, 0xaf, 0x4a, 0x09, 0x5a, 0xd9, 0xb9, 0x33, 0x1f, 0x96, 0x87, 0x5e, 0xe4, 0x52,
-	0x9e, 0x87, 0x93, 0x54, 0x92, 0xae, 0x6d, 0xf7, 0xa9, 0xd1, 0xe0, 0x2d, 0x63, 0x4d, 0xd9, 0x57,
-	0x31, 0x7b, 0xb7, 0x62, 0xde, 0xdc, 0x84, 0xd7, 0xbf, 0xc3, 0x63, 0xd6, 0xa1, 0xa8, 0xda, 0x1b,
-	0xa3, 0xea, 0x20, 0x14, 0xfc, 0xec, 0xb1, 0x6d, 0xb7, 0xc2, 0x6e, 0x34, 0x4b, 0x6f, 0x6d, 0xb3,
-	0xb4, 0x1a, 0x6f, 0xb9, 0x15, 0x67, 0x34, 0xbe, 0x4f, 0x72, 0x7c, 0x09, 0xef, 0x6a, 0xe4, 0xdb,
-	0x1f, 0xdb, 0x35, 0xff, 0xb2, 0x51, 0xb6, 0x51, 0xea, 0x43, 0x8a, 0x61, 0x2c, 0xf3, 0x32, 0x2d,
-	0x36, 0xe8, 0x3b, 0xf8, 0x0f, 0xe6, 0xd7, 0x4d, 0xc6, 0xba, 0x1a, 0xf3, 0xea, 0xfb, 0x6b, 0x99,
-	0xb9, 0xd3, 0xc1, 0xc8, 0x0c, 0x5a, 0x13, 0x9c, 0x79, 0xaa, 0x72, 0x1a, 0x10, 0xb6, 0x35, 0x19,
-	0x0c, 0x7a, 0x71, 0xd7, 0xdc, 0x58, 0xcd, 0xab, 0xb5, 0x45, 0x57, 0x51, 0xec, 0xa4, 0xf1, 0x9d,
-	0xfb, 0xcd, 0xa4, 0x81, 0xb7, 0x03, 0x21, 0x77, 0xdd, 0x89, 0x05, 0x2a, 0x86, 0x16, 0xb3, 0x0a,
-	0xd3, 0x52, 0x92, 0x10, 0xb7, 0x9c, 0xdb, 0x1b, 0x38, 0x18, 0x31, 0x9f, 0x4a, 0x96, 0x73, 0xe6,
-	0x9d, 0xdf, 0x98, 0x79, 0xde, 0x39, 0x62, 0x2b, 0x0a, 0xa9, 0xde, 0xe8, 0xad, 0x82, 0x5f, 0x3a,
-	0xac, 0x67, 0x53, 0x02, 0x15, 0x61, 0xde, 0xfd, 0x55, 0xa9, 0x0a, 0x49, 0x89, 0xa9, 0xfb, 0x94,
-	0x6b, 0x40, 0x5e, 0x98, 0xd3, 0xe7, 0xf1, 0x55, 0x61, 0xa4, 0x64, 0x79, 0x8e, 0x01, 0x5b, 0x95,
-	0xd2, 0x49, 0x8c, 0x29, 0x7d, 0x9e, 0x93, 0x86, 0x6c, 0xab, 0x6a, 0x70, 0xcc, 0x03, 0x96, 0x1b,
-	0xa5, 0xcc, 0x8e, 0x36, 0x44, 0xf0, 0x89, 0x34, 0xd5, 0xa6, 0xef, 0x76, 0x7a, 0x82, 0xd0, 0x87,
-	0x65, 0xa8, 0xe1, 0xaf, 0x88, 0x69, 0x5a, 0x5d, 0x67, 0x50, 0xb8, 0x06, 0x6a, 0xec, 0xc4, 0xd8,
-	0x72, 0xe7, 0x0c, 0x41, 0x4d, 0xbc, 0xe6, 0x8c, 0x9e, 0x7b, 0x86, 0x63, 0x68, 0x78, 0xdf, 0x24,
-	0x32, 0x8d, 0x9c, 0x0e, 0x63, 0xf3, 0x42, 0x86, 0xea, 0xdd, 0x06, 0x4e, 0x03, 0xc2, 0x77, 0x3b,
-	0x40, 0xc1, 0x2e, 0xf0, 0x5f, 0x13, 0xcd, 0xba, 0x7e, 0xa3, 0x4d, 0x02, 0xd1, 0x43, 0x9a, 0xa1,
-	0xd4, 0x3a, 0x6c, 0x94, 0x04, 0x59, 0x8d, 0xec, 0xbc, 0xa3, 0x11, 0xe8, 0x90, 0x21, 0x8c, 0x2b,
-	0xb7, 0x15, 0x20, 0x60, 0x2b, 0x76, 0xef, 0xee, 0x8a, 0xe5, 0x47, 0xcc, 0xff, 0xb6, 0x0c, 0x35,
-	0x0e, 0xb4, 0x4d, 0x44, 0x3f, 0x46, 0x42, 0x70, 0xc6, 0x76, 0x5f, 0x80, 0xab, 0x61, 0x16, 0x39,
-	0x42, 0x31, 0x97, 0x78, 0xef, 0xb9, 0x64, 0x63, 0x1d, 0x1d, 0x3f, 0xba, 0xfe, 0xfb, 0xc0, 0xbb,
-	0xfe, 0xe7, 0xc0, 0xfb, 0x03, 0x7e, 0x7f, 0xc1, 0xef, 0xa7, 0x7f, 0x0f, 0xee, 0xfd, 0x1f, 0x00,
-	0x00, 0xff, 0xff, 0xa8, 0x40, 0xb5, 0x23, 0xd3, 0x0a, 0x00, 0x00,
+	// 1083 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x56, 0xcd, 0x6e, 0x1b, 0x37,
+	0x17, 0x0d, 0x47, 0x23, 0x59, 0xa2, 0xe4, 0x9f, 0x30, 0x41, 0x40, 0x18, 0x1f, 0xf4, 0x0d, 0x84,
+	0xc0, 0x10, 0xe2, 0x46, 0x2a, 0xdc, 0x45, 0xd7, 0x71, 0xd0, 0x16, 0x46, 0x81, 0xd4, 0x91, 0x0b,
+	0x74, 0x4d, 0x69, 0xa8, 0xd1, 0xc4, 0x33, 0x43, 0x99, 0xc3, 0xb1, 0xad, 0x3e, 0x49, 0x37, 0x05,
+	0xba, 0xed, 0xae, 0x4f, 0xd0, 0x45, 0x57, 0x5e, 0xf6, 0x09, 0x8a, 0xd6, 0x45, 0xdf, 0xa3, 0xe0,
+	0x9d, 0xbf, 0x6b, 0x57, 0xb2, 0xb3, 0xd3, 0x9c, 0x7b, 0x48, 0x5e, 0xde, 0x7b, 0xce, 0xa5, 0x68,
+	0x27, 0xbd, 0x88, 0x46, 0x4b, 0xad, 0x8c, 0x62, 0x0d, 0x2d, 0xa3, 0xfd, 0xc3, 0x20, 0x34, 0x8b,
+	0x6c, 0x3a, 0x9a, 0xa9, 0x78, 0x2c, 0xb4, 0xf0, 0x7d, 0x95, 0x8c, 0x2f, 0xa2, 0xa9, 0x0e, 0xfd,
+	0x40, 0x8e, 0xe5, 0xf5, 0x52, 0x8f, 0x13, 0xe5, 0xcb, 0x7c, 0xc5, 0xfe, 0x6b, 0x44, 0x0e, 0x54,
+	0xa0, 0xc6, 0x00, 0x4f, 0xb3, 0x39, 0x7c, 0xc1, 0x07, 0xfc, 0xca, 0xe9, 0x83, 0x9f, 0x09, 0xdd,
+	0x39, 0xbb, 0x88, 0xce, 0x8c, 0x30, 0x32, 0x96, 0x89, 0x39, 0x9d, 0xb2, 0x11, 0x6d, 0xa5, 0x32,
+	0x92, 0x33, 0xc3, 0x89, 0x47, 0x86, 0xdd, 0xa3, 0xbd, 0x91, 0x96, 0xd1, 0xc8, 0x92, 0x00, 0x3d,
+	0x9d, 0x1e, 0xbb, 0x37, 0x7f, 0xfc, 0x9f, 0x4c, 0x0a, 0x16, 0xf0, 0x55, 0xa6, 0x67, 0x92, 0x3b,
+	0xf7, 0xf8, 0x80, 0x22, 0x3e, 0x7c, 0xb3, 0xcf, 0x29, 0x5d, 0x6a, 0xf5, 0x41, 0xce, 0x4c, 0xa8,
+	0x12, 0xee, 0xc2, 0x9a, 0xa7, 0xb0, 0xe6, 0xb4, 0x82, 0xab, 0x45, 0x88, 0x3a, 0xf8, 0xa5, 0x49,
+	0xbb, 0x28, 0x0d, 0xf6, 0x9c, 0x3a, 0xfe, 0x94, 0x13, 0xcf, 0x19, 0x76, 0x80, 0xfd, 0x64, 0xe2,
+	0xf8, 0x53, 0xf6, 0x82, 0x36, 0xb4, 0xb8, 0xe2, 0x0e, 0x82, 0x2d, 0xc0, 0x38, 0x75, 0x53, 0x23,
+	0x34, 0x6f, 0x78, 0xce, 0xb0, 0x5d, 0x04, 0x00, 0x61, 0x1e, 0x6d, 0xfb, 0x61, 0x6a, 0xc2, 0x64,
+	0x66, 0xb8, 0x8b, 0xa2, 0x15, 0xca, 0x5e, 0xd3, 0xad, 0x99, 0x8a, 0xb2, 0x38, 0x49, 0x79, 0xd3,
+	0x6b, 0x0c, 0xbb, 0x47, 0xdb, 0x90, 0xef, 0x5b, 0xc0, 0xaa, 0x5c, 0x4b, 0x0e, 0x7b, 0x45, 0xdd,
+	0xb9, 0x56, 0x31, 0x6f, 0x79, 0x8d, 0x07, 0xea, 0x01, 0x1c, 0x9b, 0x56, 0x98, 0x18, 0xc5, 0xb7,
+	0x3c, 0x52, 0xe4, 0x4b, 0x26, 0x80, 0xb0, 0x43, 0xda, 0xbc, 0x5a, 0x48, 0x2d, 0x79, 0x1b, 0x4a,
+	0xb4, 0x5b, 0x6e, 0xf3, 0x9d, 0x05, 0xab, 0x5d, 0x72, 0x0e, 0x7b, 0x45, 0x5b, 0x0b, 0x71, 0x19,
+	0x26, 0x01, 0xef, 0x00, 0xbb, 0x37, 0xb2, 0xc2, 0x18, 0xbd, 0x53, 0x3e, 0x6a, 0x40, 0xce, 0xb0,
+	0xb7, 0x09, 0xb4, 0xca, 0x96, 0xc7, 0x2b, 0x4e, 0x1f, 0xb8, 0x4d, 0xc1, 0xb1, 0x74, 0xa5, 0x7d,
+	0xa9, 0x8f, 0x57, 0xbc, 0xfb, 0x00, 0xbd, 0xe0, 0xb0, 0x7d, 0xda, 0x8c, 0xc2, 0x38, 0x34, 0xbc,
+	0xe7, 0x91, 0x61, 0xb3, 0x28, 0x65, 0x0e, 0xb1, 0xff, 0xd1, 0x96, 0x9a, 0xcf, 0x53, 0x69, 0xf8,
+	0x36, 0x0a, 0x16, 0x98, 0x5d, 0x29, 0xa2, 0x50, 0xa4, 0x7c, 0x07, 0xd5, 0x22, 0x87, 0xee, 0x89,
+	0x66, 0xf7, 0xa3, 0x45, 0x63, 0x37, 0x0d, 0xd3, 0x37, 0x41, 0xc0, 0xf7, 0x50, 0x67, 0x73, 0x88,
+	0x0d, 0x68, 0x67, 0x1e, 0x26, 0x22, 0x0a, 0xbf, 0x97, 0x3e, 0x7f, 0x8a, 0xe2, 0x35, 0x6c, 0x39,
+	0xe9, 0x6c, 0x21, 0x63, 0x71, 0xa1, 0x57, 0x9c, 0x61, 0x4e, 0x05, 0xdb, 0x1e, 0x5e, 0x85, 0x66,
+	0xc1, 0x9f, 0x79, 0x64, 0xd8, 0x2b, 0x7b, 0x68, 0x91, 0xc1, 0x6f, 0x2e, 0xed, 0xa2, 0xce, 0xdb,
+	0x6c, 0x60, 0x6b, 0xb0, 0x56, 0x95, 0x0d, 0x40, 0xec, 0x25, 0xa5, 0x70, 0xd7, 0x93, 0x24, 0x91,
+	0x9a, 0x3b, 0xa8, 0x06, 0x08, 0xc7, 0x52, 0x6c, 0x7c, 0x84, 0x14, 0x3f, 0xa1, 0xed, 0x99, 0x8a,
+	0x4e, 0x12, 0x5f, 0x5e, 0x73, 0x17, 0xf8, 0x14, 0xf8, 0x5f, 0x5f, 0x9e, 0x24, 0xa6, 0xd4, 0x79,
+	0xc9, 0x60, 0x9f, 0xd2, 0xce, 0x07, 0x15, 0x26, 0x56, 0x35, 0xa5, 0xd2, 0xd7, 0x09, 0xa9, 0x26,
+	0x21, 0xf3, 0xb7, 0x1e, 0x19, 0x16, 0xb9, 0xf9, 0x0b, 0x77, 0xd6, 0x6a, 0xaf, 0xdd, 0x99, 0x88,
+	0x38, 0xd7, 0x7a, 0x19, 0x00, 0xa4, 0x56, 0x45, 0x07, 0x85, 0x72, 0xc8, 0x4e, 0x00, 0xb5, 0xe4,
+	0xd4, 0x73, 0x2a, 0x2d, 0x39, 0x6a, 0xc9, 0x0e, 0x68, 0x37, 0x92, 0x73, 0xf3, 0x8d, 0x9e, 0x84,
+	0xc1, 0xc2, 0xf0, 0x2e, 0x0a, 0xe3, 0x80, 0xf5, 0xbd, 0xbd, 0xc8, 0xb7, 0xab, 0xa5, 0xe4, 0x3d,
+	0x44, 0xaa, 0x50, 0x36, 0xca, 0x19, 0x5f, 0x5c, 0x2f, 0x35, 0x28, 0x76, 0x7d, 0x39, 0x2a, 0x0e,
+	0x3b, 0xa2, 0xed, 0x34, 0x9b, 0xbe, 0xcf, 0xa4, 0x5e, 0xf1, 0x9d, 0x07, 0xeb, 0x51, 0xf1, 0x6c,
+	0x16, 0xa9, 0x94, 0xe7, 0x62, 0x1a, 0x49, 0xbe, 0x8b, 0x54, 0x51, 0xa1, 0x83, 0x5f, 0x09, 0xa5,
+	0xb5, 0xef, 0x8b, 0x4b, 0x93, 0x7b, 0x97, 0xde, 0x3c, 0x85, 0xd7, 0x37, 0xe2, 0x80, 0xba, 0x70,
+	0xad, 0xc6, 0xc6, 0x6b, 0xb9, 0x16, 0xb2, 0x96, 0x4d, 0x64, 0x20, 0x8c, 0xe4, 0x2e, 0x4a, 0xae,
+	0xc0, 0xd8, 0x4b, 0xda, 0x88, 0x16, 0x56, 0x2a, 0x9b, 0x36, 0xb1, 0xe1, 0xc1, 0x8f, 0x84, 0xf6,
+	0xb0, 0x4d, 0xef, 0x4c, 0x5c, 0xb2, 0x76, 0xe2, 0x56, 0x46, 0x71, 0xb0, 0x6d, 0x01, 0x62, 0xfb,
+	0xa0, 0xe9, 0x77, 0x22, 0x96, 0xb9, 0x07, 0x3a, 0x93, 0xea, 0x9b, 0x7d, 0x56, 0xdb, 0x23, 0x97,
+	0xfb, 0x33, 0xa8, 0xc3, 0x44, 0xa6, 0x59, 0x64, 0x36, 0x98, 0x64, 0xf0, 0x0f, 0xa1, 0x3b, 0x77,
+	0x19, 0xeb, 0x8c, 0x4a, 0xca, 0xf3, 0x4b, 0xad, 0xe2, 0x27, 0x06, 0x10, 0x5b, 0xac, 0x99, 0x8a,
+	0x4e, 0x55, 0xca, 0x1b, 0xa8, 0x3d, 0x05, 0xc6, 0x0e, 0x21, 0x9a, 0xc5, 0xe5, 0xa3, 0xb7, 0xd6,
+	0xb9, 0x05, 0xa5, 0x7a, 0xae, 0x9a, 0xe8, 0x7c, 0x40, 0x6c, 0xff, 0x45, 0xca, 0x5b, 0xf8, 0xd9,
+	0x13, 0xa9, 0x9d, 0x53, 0x97, 0x22, 0xca, 0x24, 0xa8, 0x79, 0x0b, 0x9d, 0x5e, 0xc3, 0x83, 0x31,
+	0x6d, 0x82, 0xef, 0x19, 0xa3, 0xe4, 0xfc, 0xce, 0xc3, 0x49, 0xce, 0x2d, 0x76, 0xc9, 0x1d, 0xb4,
+	0x90, 0x5c, 0x0e, 0x7e, 0x72, 0x69, 0xbb, 0x2a, 0xc9, 0x01, 0xed, 0xe6, 0xda, 0x79, 0x9f, 0x29,
+	0x23, 0x39, 0x41, 0xc3, 0x0e, 0x07, 0x2c, 0x4f, 0xa4, 0xf0, 0xf3, 0x78, 0x65, 0x72, 0x39, 0x56,
+	0x3c, 0x14, 0xb0, 0xf3, 0x4e, 0xe9, 0x30, 0xb0, 0x25, 0x7d, 0x93, 0x82, 0x0e, 0xab, 0x79, 0x57,
+	0xe3, 0xb6, 0x0e, 0xd6, 0xb3, 0xdc, 0x45, 0x71, 0x40, 0x6c, 0x8b, 0x34, 0x18, 0xbc, 0x89, 0x42,
+	0x39, 0x64, 0x73, 0x58, 0x0a, 0x2d, 0x13, 0x93, 0x4f, 0xbe, 0x16, 0x7a, 0x6d, 0x70, 0x00, 0x5e,
+	0x07, 0x60, 0x6c, 0xe1, 0xc7, 0x0a, 0xa0, 0xfa, 0xbe, 0xf9, 0x1e, 0x6d, 0xbc, 0x07, 0x0a, 0xd4,
+	0xbc, 0x2f, 0x43, 0x19, 0xf9, 0x68, 0x4c, 0x91, 0x09, 0x0e, 0x14, 0x7d, 0xeb, 0x7a, 0xe4, 0x4e,
+	0xdf, 0xfa, 0x56, 0xb0, 0xb1, 0xfd, 0xeb, 0xc5, 0x7b, 0x55, 0x88, 0x4c, 0x4a, 0xd0, 0x66, 0x08,
+	0x2f, 0x2b, 0xdf, 0x46, 0xd1, 0x1c, 0xaa, 0x34, 0xb2, 0xf3, 0x1f, 0x8d, 0xbc, 0xa0, 0x0d, 0x11,
+	0x04, 0x77, 0xe6, 0x89, 0x05, 0x2a, 0xd7, 0xef, 0x3d, 0xe2, 0xfa, 0x21, 0x6d, 0x7e, 0x95, 0x09,
+	0x6d, 0x5f, 0xc5, 0x4d, 0xc4, 0x66, 0x60, 0x09, 0x83, 0x33, 0xba, 0xfb, 0x56, 0xc5, 0xb1, 0x48,
+	0x7c, 0x24, 0x94, 0xfc, 0x10, 0xf2, 0xc8, 0x21, 0x1b, 0x7d, 0x74, 0xfc, 0xfc, 0xe6, 0xaf, 0x3e,
+	0xb9, 0xb9, 0xed, 0x93, 0xdf, 0x6f, 0xfb, 0xe4, 0xcf, 0xdb, 0x3e, 0xf9, 0xe1, 0xef, 0xfe, 0x93,
+	0x7f, 0x07, 0x00, 0xf4, 0xec, 0xb1, 0x2f, 0x18, 0x0b, 0x00, 0x00,
 }
@@ -53,6 +53,20 @@ WHERE
 		FROM users AS u 
 		INNER JOIN orders AS o 
 		ON u.user_id = o.user_id;
+	`,
+		`SELECT
+			user_id
+			, row_number() OVER (PARTITION BY user_id ORDER BY price DESC) AS rn
+			, sum(price) OVER (PARTITION BY user_id ORDER BY item_id ROWS BETWEEN 2 PRECEDING AND CURRENT ROW) AS recent
+			, lag(price) OVER (ORDER BY item_id) AS prev
+		FROM orders
+	`,
+		`SELECT user_id, email FROM users WHERE user_id > 3
+		UNION ALL
+		SELECT user_id, item_id FROM orders
+		INTERSECT
+		SELECT user_id, email FROM banned
+		ORDER BY user_id LIMIT 10
 	`}
 )
 
@@ -112,6 +126,7 @@ func compareAstColumn(t *testing.T, colLeft, colRight *Column) {
 	assert.Tf(t, colLeft.Comment == colRight.Comment, "Comments?  '%s' '%s'", colLeft.Comment, colRight.Comment)
 	compareNode(t, colLeft.Guard, colRight.Guard)
 	compareNode(t, colLeft.Expr, colRight.Expr)
+	assert.Tf(t, colLeft.Over.Equal(colRight.Over), "Over: '%v' != '%v'", colLeft.Over, colRight.Over)
 }
 
 func compareAst(t *testing.T, in1, in2 SqlStatement) {
@@ -128,6 +143,12 @@ func compareAst(t *testing.T, in1, in2 SqlStatement) {
 		}
 		//compareWhere(s1.Where)
 		compareFroms(t, s1.From, s2.From)
+		assert.Tf(t, len(s1.SetOps) == len(s2.SetOps), "SetOps: %d != %d", len(s1.SetOps), len(s2.SetOps))
+		for i, op := range s1.SetOps {
+			assert.Equal(t, op.Op, s2.SetOps[i].Op)
+			assert.Equal(t, op.All, s2.SetOps[i].All)
+			compareAst(t, op.Select, s2.SetOps[i].Select)
+		}
 	default:
 		t.Fatalf("Must be SqlSelect")
 	}
@@ -0,0 +1,115 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// IdentifierCase how a schema matches the names of its tables and columns
+// of statements to those of its sources
+type IdentifierCase uint32
+
+const (
+	// CaseFold names are lower cased, and shown so, matching any case (as
+	// mysql of lower_case_table_names=1, ie on linux).  The default.
+	CaseFold IdentifierCase = iota
+	// CaseExact names are kept as the source names them, and must be
+	// written the same.  Tables whose names differ only by case are not
+	// supported.
+	CaseExact
+	// CaseInsensitive names are kept as the source names them, matching
+	// any case
+	CaseInsensitive
+)
+
+func (m IdentifierCase) String() string {
+	switch m {
+	case CaseExact:
+		return "exact"
+	case CaseInsensitive:
+		return "insensitive"
+	}
+	return "fold"
+}
+
+// ParseIdentifierCase the IdentifierCase of its name ("fold", "exact",
+// "insensitive"), CaseFold for empty
+func ParseIdentifierCase(name string) (IdentifierCase, error) {
+	switch strings.ToLower(name) {
+	case "", "fold", "lower":
+		return CaseFold, nil
+	case "exact":
+		return CaseExact, nil
+	case "insensitive":
+		return CaseInsensitive, nil
+	}
+	return CaseFold, fmt.Errorf("unknown identifier case %q, expected fold, exact or insensitive", name)
+}
+
+// Equal do names a and b match
+func (m IdentifierCase) Equal(a, b string) bool {
+	if m == CaseExact {
+		return a == b
+	}
+	return strings.EqualFold(a, b)
+}
+
+// Name the name of tbl as shown, ie by SHOW TABLES
+func (m IdentifierCase) Name(tbl *Table) string {
+	if m == CaseFold || tbl.NameOriginal == "" {
+		return tbl.Name
+	}
+	return tbl.NameOriginal
+}
+
+// named is tbl of name, of case c
+func (m IdentifierCase) named(tbl *Table, name string) bool {
+	if m != CaseExact {
+		return true
+	}
+	if tbl.NameOriginal != "" {
+		return name == tbl.NameOriginal
+	}
+	return name == tbl.Name
+}
+
+// IdentifierCase how this schema matches names of tables and columns
+func (m *Schema) IdentifierCase() IdentifierCase {
+	return IdentifierCase(atomic.LoadUint32((*uint32)(&m.identCase)))
+}
+
+// SetIdentifierCase how this schema matches names of tables and columns,
+// of lookups of tables (see Table, Source) and of columns (see Table.Field)
+func (m *Schema) SetIdentifierCase(c IdentifierCase) {
+	atomic.StoreUint32((*uint32)(&m.identCase), uint32(c))
+}
+
+// identifierCase the IdentifierCase of the schema of this table, CaseFold
+// for tables of no schema
+func (m *Table) identifierCase() IdentifierCase {
+	if m.SchemaSource == nil {
+		return CaseFold
+	}
+	if s := m.SchemaSource.Schema(); s != nil {
+		return s.IdentifierCase()
+	}
+	return CaseFold
+}
+
+// Field the field of the column of name, matched by the IdentifierCase of
+// the schema of this table
+func (m *Table) Field(name string) (*Field, bool) {
+	if f, ok := m.FieldMap[name]; ok {
+		return f, true
+	}
+	if m.identifierCase() == CaseExact {
+		return nil, false
+	}
+	for _, f := range m.Fields {
+		if strings.EqualFold(f.Name, name) {
+			return f, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,120 @@
+package schema
+
+import (
+	"fmt"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/value"
+)
+
+const (
+	// ComputedExtra the Extra of computed columns, shown by DESCRIBE
+	ComputedExtra = "VIRTUAL GENERATED"
+)
+
+// IsComputed is this a computed column, not stored by its source
+func (m *Field) IsComputed() bool { return m.Computed != "" }
+
+// ComputedExpr the expression of a computed column, nil if stored
+func (m *Field) ComputedExpr() expr.Node { return m.computed }
+
+// AddComputed add a computed (virtual) column of name to this table, the
+// value of the expression exprText of its other columns, computed by the
+// executor as the rows of its source are scanned:
+//
+//   tbl.AddComputed("full_name", value.StringType, `join(first, last, " ")`)
+//
+// The expression may refer to the columns computed before it.  A type of
+// value.UnknownType is that of the expression, where known.  Computed
+// columns are not Columns of the source, wheres and sorts of them are not
+// pushed down to it.
+func (m *Table) AddComputed(name string, valType value.ValueType, exprText string) error {
+	if f, ok := m.FieldMap[name]; ok && !f.IsComputed() {
+		return fmt.Errorf("column %q of table %q is not computed", name, m.Name)
+	}
+	if len(m.ComputedFields()) == len(m.Fields) {
+		// a table of columns only, ie of a source of no schema, its columns
+		// are the fields before the computed ones, of types not known
+		for _, col := range m.cols {
+			if _, ok := m.FieldMap[col]; !ok {
+				m.AddField(NewFieldBase(col, value.UnknownType, 0, ""))
+			}
+		}
+	}
+	node, err := m.parseComputed(name, exprText)
+	if err != nil {
+		return err
+	}
+	if valType == value.UnknownType {
+		valType = m.computedType(node)
+	}
+	// its expression is the comment shown by DESCRIBE FULL
+	f := NewFieldBase(name, valType, 0, exprText)
+	f.Extra = ComputedExtra
+	f.Computed = exprText
+	f.computed = node
+	m.AddField(f)
+	return nil
+}
+
+// ComputedFields the computed columns of this table, in the order added
+func (m *Table) ComputedFields() []*Field {
+	var fields []*Field
+	for _, f := range m.Fields {
+		if f.IsComputed() {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// parseComputed the expression of the computed column of name, of the
+// columns of this table only
+func (m *Table) parseComputed(name, exprText string) (expr.Node, error) {
+	tree, err := expr.ParseExpression(exprText)
+	if err != nil {
+		return nil, fmt.Errorf("bad expression of computed column %q: %v", name, err)
+	}
+	for _, ident := range expr.FindAllIdentityField(tree.Root) {
+		_, col, _ := expr.LeftRight(ident)
+		if col == name {
+			return nil, fmt.Errorf("computed column %q refers to itself", name)
+		}
+		if _, ok := m.Field(col); !ok {
+			return nil, fmt.Errorf("computed column %q refers to %q, not a column of table %q", name, col, m.Name)
+		}
+	}
+	return tree.Root, nil
+}
+
+// computedType the type of the values of node, string if not known
+func (m *Table) computedType(node expr.Node) value.ValueType {
+	if in, ok := node.(*expr.IdentityNode); ok {
+		_, col, _ := in.LeftRight()
+		if f, ok := m.Field(col); ok {
+			return f.Type
+		}
+	}
+	if fn, ok := node.(*expr.FuncNode); ok && fn.F.ReturnValueType != value.UnknownType {
+		return fn.F.ReturnValueType
+	}
+	if vt := expr.ValueTypeFromNode(node); vt != value.UnknownType {
+		return vt
+	}
+	return value.StringType
+}
+
+// keepComputed add the computed columns of cur to tbl replacing it, of
+// those whose columns tbl still has
+func keepComputed(tbl, cur *Table) {
+	for _, f := range cur.ComputedFields() {
+		if _, ok := tbl.FieldMap[f.Name]; ok {
+			continue
+		}
+		if err := tbl.AddComputed(f.Name, f.Type, f.Computed); err != nil {
+			u.Warnf("dropping computed column of refreshed table %q: %v", tbl.Name, err)
+		}
+	}
+}
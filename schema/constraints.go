@@ -0,0 +1,155 @@
+package schema
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/araddon/qlbridge/value"
+)
+
+const (
+	// ErrCodeBadNull a NULL written to a NOT NULL column
+	ErrCodeBadNull = 1048
+	// ErrCodeNoDefault a NOT NULL column of no default omitted by an insert
+	ErrCodeNoDefault = 1364
+	// ErrCodeDataTooLong a value longer than the Length of its column
+	ErrCodeDataTooLong = 1406
+)
+
+var (
+	// ColumnsColumns the columns of the information_schema columns table
+	ColumnsColumns = []string{"TABLE_SCHEMA", "TABLE_NAME", "COLUMN_NAME", "ORDINAL_POSITION", "COLUMN_DEFAULT",
+		"IS_NULLABLE", "DATA_TYPE", "CHARACTER_MAXIMUM_LENGTH", "COLUMN_KEY", "EXTRA", "GENERATION_EXPRESSION"}
+)
+
+// ConstraintError a write of a value its column does not allow, of the
+// error code and SQLSTATE mysql gives it
+//
+//   Error 1048 (23000): Column 'email' cannot be null
+type ConstraintError struct {
+	Code    uint16
+	State   string
+	Message string
+}
+
+func (m *ConstraintError) Error() string {
+	return fmt.Sprintf("Error %d (%s): %s", m.Code, m.State, m.Message)
+}
+
+// Nullable may this column be NULL
+func (m *Field) Nullable() bool { return !m.NoNulls }
+
+// CheckValue check v written to this column, of the row (from 1) of its
+// write: not NULL if NoNulls, and of no more than Length characters if a
+// string (bytes if binary)
+func (m *Field) CheckValue(v driver.Value, row int) error {
+	if v == nil {
+		if m.NoNulls {
+			return &ConstraintError{ErrCodeBadNull, "23000", fmt.Sprintf("Column '%s' cannot be null", m.Name)}
+		}
+		return nil
+	}
+	if m.Length == 0 {
+		return nil
+	}
+	n := -1
+	switch m.Type {
+	case value.StringType:
+		if s, ok := v.(string); ok {
+			n = utf8.RuneCountInString(s)
+		}
+	case value.ByteSliceType:
+		if b, ok := v.([]byte); ok {
+			n = len(b)
+		}
+	}
+	if n > int(m.Length) {
+		return &ConstraintError{ErrCodeDataTooLong, "22001", fmt.Sprintf("Data too long for column '%s' at row %d", m.Name, row)}
+	}
+	return nil
+}
+
+// WriteRow fill in the defaults of the columns of vals (in the order of
+// Columns) not given by a write, and check each value against its field
+// (see Field.CheckValue).  given nil is all of them given.  row is the row
+// (from 1) of the write, of errors.
+//
+//   INSERT INTO users (id) VALUES (1)     users (id, status DEFAULT "new")
+//   =>  [1, "new"]
+func (m *Table) WriteRow(vals []driver.Value, given []bool, row int) error {
+	for i, col := range m.cols {
+		if i >= len(vals) {
+			break
+		}
+		f, ok := m.FieldMap[col]
+		if !ok {
+			continue
+		}
+		if given != nil && !given[i] {
+			if f.DefaultValue != nil {
+				vals[i] = f.DefaultValue
+			} else if f.NoNulls {
+				return &ConstraintError{ErrCodeNoDefault, "HY000", fmt.Sprintf("Field '%s' doesn't have a default value", f.Name)}
+			}
+			continue
+		}
+		if err := f.CheckValue(vals[i], row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasConstraints has this table defaults, NOT NULL or lengths of its
+// columns to apply to writes
+func (m *Table) HasConstraints() bool {
+	for _, f := range m.Fields {
+		if f.NoNulls || f.DefaultValue != nil || (f.Length > 0 && (f.Type == value.StringType || f.Type == value.ByteSliceType)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ColumnsRows the rows of the information_schema columns table of the
+// tables of this schema, see ColumnsColumns
+func (m *Schema) ColumnsRows() [][]driver.Value {
+	rows := make([][]driver.Value, 0)
+	for _, name := range m.Tables() {
+		tbl, err := m.Table(name)
+		if err != nil || tbl == nil {
+			continue
+		}
+		for i, f := range tbl.Fields {
+			nullable := "YES"
+			if f.NoNulls {
+				nullable = "NO"
+			}
+			var maxLen driver.Value
+			if f.Length > 0 && (f.Type == value.StringType || f.Type == value.ByteSliceType) {
+				maxLen = int64(f.Length)
+			}
+			var def driver.Value
+			if f.DefaultValue != nil {
+				def = fmt.Sprint(f.DefaultValue)
+			}
+			rows = append(rows, []driver.Value{m.Name, tbl.Name, f.Name, int64(i + 1), def,
+				nullable, f.Type.String(), maxLen, fieldKey(tbl, f), f.Extra, f.Computed})
+		}
+	}
+	return rows
+}
+
+// fieldKey the COLUMN_KEY of f of tbl, PRI of the primary key
+func fieldKey(tbl *Table, f *Field) string {
+	for _, col := range tbl.PrimaryKey() {
+		if col == f.Name {
+			return "PRI"
+		}
+	}
+	if f.Indexed {
+		return "MUL"
+	}
+	return f.Key
+}
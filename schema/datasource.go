@@ -3,6 +3,8 @@ package schema
 import (
 	"database/sql/driver"
 	"fmt"
+	"strings"
+	"time"
 
 	"golang.org/x/net/context"
 
@@ -42,10 +44,41 @@ type (
 	SourceSetup interface {
 		Setup(*SchemaSource) error
 	}
+	// SourceFactory A registered DataSource type that creates a new source
+	//  per source config (ie per connection settings) instead of all configs
+	//  of its type sharing the registered source.  See datasource Registry.Apply
+	SourceFactory interface {
+		NewSource(conf *ConfigSource) (Source, error)
+	}
+	// SourceCapabilities a DataSource (or Conn) that describes what it can do
+	//  itself, see Capabilities.  Sources that don't are assumed capable of
+	//  what the interfaces they implement allow.
+	SourceCapabilities interface {
+		Capabilities() *Capabilities
+	}
 	// SourceTableSchema A data source provider that also provides table schema info
 	SourceTableSchema interface {
 		Table(table string) (*Table, error)
 	}
+	// TableCreator a DataSource that can physically create a table of the
+	//  columns of a Table, ie for CREATE TABLE, after which the table is one
+	//  of its Tables.
+	TableCreator interface {
+		CreateTable(tbl *Table) error
+	}
+	// SourceChanges a DataSource that pushes the inserts, updates and deletes
+	//  of its tables to subscribers as they happen (change data capture), for
+	//  live queries instead of scanning snapshots of its tables.
+	SourceChanges interface {
+		// Subscribe to the changes of table, "" for all tables
+		Subscribe(table string) (Subscription, error)
+	}
+	// HealthChecker a DataSource that can check it is working (ie ping its
+	//  backend), probed in the background by the registry so queries of an
+	//  unhealthy source fail fast instead of timing out.
+	HealthChecker interface {
+		CheckHealth() error
+	}
 	// SourcePartitionable DataSource that is partitionable into ranges for splitting
 	//  reads, writes onto different nodes.
 	SourcePartitionable interface {
@@ -102,6 +135,15 @@ type (
 		Conn
 		Iterator
 	}
+	// ConnBatchScanner a scanner that reads its rows in batches (ie the
+	//  record batches of columnar files, decoded into rows), a batch is
+	//  sent on whole to the exec operators that take batches of rows
+	//  instead of row by row.
+	ConnBatchScanner interface {
+		ConnScanner
+		// NextBatch the rows of the next batch, nil at the end
+		NextBatch() []Message
+	}
 	// ConnScannerIterator Another advanced iterator, probably deprecate?
 	ConnScannerIterator interface {
 		//Conn
@@ -109,6 +151,13 @@ type (
 		CreateIterator() Iterator
 		MesgChan() <-chan Message
 	}
+	// ConnSorted a connection whose scans return rows in ascending order of
+	//  these columns (ie, scanning a btree on primary key), allows the planner
+	//  to merge join without sorting first, and skip sorting for an ORDER BY
+	//  of them.
+	ConnSorted interface {
+		SortedBy() []string
+	}
 	// ConnSeeker is a datsource that is Key-Value store, allows relational
 	//  implementation to be faster for Seeking row values instead of scanning
 	ConnSeeker interface {
@@ -119,6 +168,22 @@ type (
 		Get(key driver.Value) (Message, error)
 		MultiGet(keys []driver.Value) ([]Message, error)
 	}
+	// ConnKeyed a ConnSeeker whose Get and MultiGet keys are the values of
+	//  this column (ie its primary key), allows the planner to join by
+	//  looking up rows by key instead of scanning.
+	ConnKeyed interface {
+		KeyColumn() string
+	}
+	// ConnAcker a scan of messages (ie of a queue) that are acknowledged
+	//  once the rows of them are written by an INSERT ... SELECT, so the
+	//  messages of failed writes are redelivered.  Row ids must increase in
+	//  the order rows are scanned.
+	ConnAcker interface {
+		// Ack the messages of the rows of ids up to and including id
+		Ack(id uint64) error
+		// Nack the messages not acked, to be redelivered
+		Nack() error
+	}
 	// ConnMutation creates a Mutator connection similar to Open() connection for select
 	//  - accepts the plan context used in this upsert/insert/update
 	//  - returns a connection which must be closed
@@ -130,6 +195,18 @@ type (
 		ConnUpsert
 		ConnDeletion
 	}
+	// ConnTransaction a connection whose writes may be grouped into a
+	//  Transaction, ie the writes of the statements of a database/sql Tx.
+	ConnTransaction interface {
+		Begin() (Transaction, error)
+	}
+	// Transaction a mutator whose writes are not visible to other
+	//  connections until committed, and are discarded on rollback.
+	Transaction interface {
+		ConnMutator
+		Commit() error
+		Rollback() error
+	}
 	// ConnUpsert Mutation interface for Put
 	//  - assumes datasource understands key(s?)
 	ConnUpsert interface {
@@ -149,3 +226,89 @@ type (
 		DeleteExpression(p interface{} /* plan.Delete */, n expr.Node) (int, error)
 	}
 )
+
+// Capabilities what a source can do itself instead of in-process, consulted
+// by the planner to decide what to push down to it.  A source declares
+// these (see SourceCapabilities) to advertise less than the interfaces it
+// implements allow (ie a wrapper of another source, or a backend without
+// some operators), features not declared are not used even if implemented.
+type Capabilities struct {
+	Scan         bool     // rows may be scanned (ConnScanner)
+	Filter       bool     // wheres may be pushed down, translated (see expr/translate)
+	FilterOps    []string // operators and functions of the wheres it evaluates, nil for all
+	Projection   bool     // plans its own select, columns where and all (see plan.SourcePlanner)
+	Limit        bool     // stops its scan after a LIMIT (see plan.SourceLimiter)
+	Order        bool     // sorts its rows for an ORDER BY (see plan.SourceOrderer)
+	SortedBy     []string // scans in ascending order of these columns (ConnSorted)
+	Aggregates   bool     // computes partial aggregates (see plan.SourcePartialAggregator)
+	Seek         bool     // rows may be looked up by key (ConnSeeker)
+	KeyColumn    string   // the column of the keys of Seek (ConnKeyed)
+	Partitions   bool     // scanned in parallel by partition (SourcePartitionable)
+	Writes       bool     // rows may be inserted, updated by key and upserted (ConnUpsert)
+	PatchWhere   bool     // rows matching a where may be updated (ConnPatchWhere)
+	Deletes      bool     // rows may be deleted (ConnDeletion)
+	Transactions bool     // writes may be grouped into transactions (ConnTransaction)
+}
+
+// FilterOp is the operator (or function) op of a where one the source
+// evaluates, logical AND, OR and NOT always are.
+func (m *Capabilities) FilterOp(op string) bool {
+	if !m.Filter {
+		return false
+	}
+	switch op = strings.ToLower(op); op {
+	case "and", "or", "not", "&&", "||", "!":
+		return true
+	}
+	if m.FilterOps == nil {
+		return true
+	}
+	for _, fop := range m.FilterOps {
+		if strings.ToLower(fop) == op {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeOp the operation of a Change
+type ChangeOp uint8
+
+const (
+	ChangeInsert ChangeOp = iota + 1
+	ChangeUpdate
+	ChangeDelete
+)
+
+func (m ChangeOp) String() string {
+	switch m {
+	case ChangeInsert:
+		return "insert"
+	case ChangeUpdate:
+		return "update"
+	case ChangeDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// Change an insert, update or delete of a row of a table of a SourceChanges
+type Change struct {
+	Op    ChangeOp
+	Table string
+	Key   driver.Value   // of the row, if the table is keyed
+	Row   []driver.Value // the row after an insert or update, before a delete
+	Old   []driver.Value // the row before an update, nil if not known
+	Ts    time.Time
+}
+
+// Subscription the changes of a table of a SourceChanges, read until it is
+// closed.  Subscribers that can not keep up with the changes are closed,
+// with an error.
+type Subscription interface {
+	// Changes the channel of changes, closed when the subscription is
+	Changes() <-chan *Change
+	// Err why the subscription was closed, nil if closed by Close
+	Err() error
+	Close() error
+}
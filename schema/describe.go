@@ -0,0 +1,115 @@
+package schema
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// SchemaDescription a document of a Schema as it is now, of its sources,
+	// their tables (with fields, indexes and stats) and the versions of each,
+	// see Schema.Describe.  Serializes to json, ie for admin tools.
+	SchemaDescription struct {
+		Name           string               `json:"name"`
+		Version        uint64               `json:"version"`
+		IdentifierCase string               `json:"identifier_case"`
+		Sources        []*SourceDescription `json:"sources"`
+		Views          []*ViewDef           `json:"views,omitempty"`
+		Described      time.Time            `json:"described"`
+	}
+
+	// SourceDescription a source of a SchemaDescription.  Tables listed by
+	// the source but not yet loaded (so of no fields known) are Unloaded.
+	SourceDescription struct {
+		Name     string              `json:"name"`
+		Type     string              `json:"type,omitempty"`
+		Alias    string              `json:"alias,omitempty"`
+		Refresh  time.Duration       `json:"refresh,omitempty"`
+		Tables   []*TableDescription `json:"tables"`
+		Unloaded []string            `json:"unloaded,omitempty"`
+	}
+
+	// TableDescription a table of a SourceDescription, its definition and
+	// the version and stats of it now
+	TableDescription struct {
+		*TableDef
+		Version  uint64    `json:"version"`
+		Rows     int64     `json:"rows,omitempty"` // when last analyzed, see Analyzed
+		Analyzed time.Time `json:"analyzed"`
+	}
+)
+
+// Describe a document of this schema, of its sources sorted by name and the
+// tables of each loaded, without asking the sources of any not loaded.
+//
+//   doc, _ := json.Marshal(s.Describe())
+func (m *Schema) Describe() *SchemaDescription {
+	doc := &SchemaDescription{
+		Name:           m.Name,
+		Version:        m.Version(),
+		IdentifierCase: m.IdentifierCase().String(),
+		Described:      time.Now(),
+	}
+	sources := m.SchemaSources()
+	doc.Sources = make([]*SourceDescription, 0, len(sources))
+	for _, ss := range sources {
+		doc.Sources = append(doc.Sources, ss.Describe())
+	}
+	m.mu.RLock()
+	doc.Views = m.viewDefsUnlocked()
+	m.mu.RUnlock()
+	return doc
+}
+
+// Describe a document of this source and the tables of it loaded, see
+// Schema.Describe
+func (m *SchemaSource) Describe() *SourceDescription {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	doc := &SourceDescription{
+		Name:    m.Name,
+		Alias:   m.Alias,
+		Refresh: m.Refresh,
+		Tables:  make([]*TableDescription, 0, len(m.tableNames)),
+	}
+	if m.Conf != nil {
+		doc.Type = m.Conf.SourceType
+	}
+	for _, name := range m.tableNames {
+		tbl := m.tableMap[strings.ToLower(name)]
+		if tbl == nil {
+			doc.Unloaded = append(doc.Unloaded, name)
+			continue
+		}
+		doc.Tables = append(doc.Tables, tbl.Describe())
+	}
+	return doc
+}
+
+// Describe a document of this table, see Schema.Describe
+func (m *Table) Describe() *TableDescription {
+	rows, _ := m.RowCount()
+	return &TableDescription{
+		TableDef: TableDefOf(m),
+		Version:  m.Version(),
+		Rows:     rows,
+		Analyzed: m.Analyzed(),
+	}
+}
+
+// viewDefsUnlocked the definitions of the views of this schema, sorted by
+// name, of the lock held
+func (m *Schema) viewDefsUnlocked() []*ViewDef {
+	names := make([]string, 0, len(m.views))
+	for name := range m.views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	defs := make([]*ViewDef, 0, len(names))
+	for _, name := range names {
+		v := m.views[name]
+		defs = append(defs, &ViewDef{Name: v.Name, Sql: v.Stmt.String(), MaterializedTable: v.MaterializedTable})
+	}
+	return defs
+}
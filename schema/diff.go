@@ -0,0 +1,333 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DiffType the kind of a Difference of two schemas
+type DiffType uint8
+
+const (
+	DiffTableAdded DiffType = iota + 1
+	DiffTableRemoved
+	DiffTableChanged // of its source, indexes or partitioning, see Difference.What
+	DiffColumnAdded
+	DiffColumnRemoved
+	DiffColumnChanged
+	DiffViewAdded
+	DiffViewRemoved
+	DiffViewChanged
+)
+
+func (m DiffType) String() string {
+	switch m {
+	case DiffTableAdded:
+		return "table_added"
+	case DiffTableRemoved:
+		return "table_removed"
+	case DiffTableChanged:
+		return "table_changed"
+	case DiffColumnAdded:
+		return "column_added"
+	case DiffColumnRemoved:
+		return "column_removed"
+	case DiffColumnChanged:
+		return "column_changed"
+	case DiffViewAdded:
+		return "view_added"
+	case DiffViewRemoved:
+		return "view_removed"
+	case DiffViewChanged:
+		return "view_changed"
+	}
+	return "unknown"
+}
+
+// MarshalJSON the name of the type, see String
+func (m DiffType) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(m.String())), nil
+}
+
+// UnmarshalJSON the type of its name
+func (m *DiffType) UnmarshalJSON(data []byte) error {
+	name, err := strconv.Unquote(string(data))
+	if err != nil {
+		return err
+	}
+	for t := DiffTableAdded; t <= DiffViewChanged; t++ {
+		if t.String() == name {
+			*m = t
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown schema difference %q", name)
+}
+
+// Difference a table, column or view added, removed or changed between two
+// schemas.  What is the property of a change (ie "type" of a column), of
+// its Old and New values.  Columns added are New of their type.
+//
+//   column_changed users.email type: string -> int
+//   column_added users.status string (not null)
+type Difference struct {
+	Type   DiffType `json:"type"`
+	Table  string   `json:"table"`
+	Column string   `json:"column,omitempty"`
+	What   string   `json:"what,omitempty"`
+	Old    string   `json:"old,omitempty"`
+	New    string   `json:"new,omitempty"`
+}
+
+func (m *Difference) String() string {
+	name := m.Table
+	if m.Column != "" {
+		name += "." + m.Column
+	}
+	switch m.Type {
+	case DiffTableChanged, DiffColumnChanged, DiffViewChanged:
+		return fmt.Sprintf("%s %s %s: %s -> %s", m.Type, name, m.What, m.Old, m.New)
+	}
+	s := m.Type.String() + " " + name
+	if m.New != "" {
+		s += " " + m.New
+	}
+	if m.What != "" {
+		s += " (" + m.What + ")"
+	}
+	return s
+}
+
+// Breaking may this difference break readers (or writers) of the old
+// schema: tables, columns and views removed, columns of another type or
+// shorter, and NOT NULL columns of no default added (or made so).
+func (m *Difference) Breaking() bool {
+	switch m.Type {
+	case DiffTableRemoved, DiffColumnRemoved, DiffViewRemoved:
+		return true
+	case DiffColumnAdded:
+		return m.What == "not null"
+	case DiffColumnChanged:
+		switch m.What {
+		case "type", "computed":
+			return true
+		case "not null":
+			return m.New == "true"
+		case "length":
+			// 0 is of no limit
+			oldLen, _ := strconv.Atoi(m.Old)
+			newLen, _ := strconv.Atoi(m.New)
+			return newLen > 0 && (oldLen == 0 || newLen < oldLen)
+		}
+	}
+	return false
+}
+
+// Diff the differences of the tables, columns and views of next from old,
+// ie of the definitions of the same schema of two environments.  Tables
+// are matched by name whatever their source, and are sorted by name, their
+// columns in the order of next (of those removed, after).
+//
+//   for _, d := range schema.Diff(prod.Def(), staging.Def()) {
+//       if d.Breaking() {
+//           ...
+//       }
+//   }
+func Diff(old, next *SchemaDef) []*Difference {
+	diffs := make([]*Difference, 0)
+	oldTables, nextTables := defTables(old), defTables(next)
+	names := make(map[string]bool, len(nextTables))
+	for name := range oldTables {
+		names[name] = true
+	}
+	for name := range nextTables {
+		names[name] = true
+	}
+	for _, name := range sortedKeys(names) {
+		ot, nt := oldTables[name], nextTables[name]
+		switch {
+		case ot == nil:
+			diffs = append(diffs, &Difference{Type: DiffTableAdded, Table: name})
+			for _, fd := range nt.def.Fields {
+				diffs = append(diffs, addedColumn(name, fd))
+			}
+		case nt == nil:
+			diffs = append(diffs, &Difference{Type: DiffTableRemoved, Table: name})
+		default:
+			diffs = append(diffs, diffTables(name, ot, nt)...)
+		}
+	}
+	oldViews, nextViews := defViews(old), defViews(next)
+	names = make(map[string]bool, len(nextViews))
+	for name := range oldViews {
+		names[name] = true
+	}
+	for name := range nextViews {
+		names[name] = true
+	}
+	for _, name := range sortedKeys(names) {
+		ov, nv := oldViews[name], nextViews[name]
+		switch {
+		case ov == nil:
+			diffs = append(diffs, &Difference{Type: DiffViewAdded, Table: name})
+		case nv == nil:
+			diffs = append(diffs, &Difference{Type: DiffViewRemoved, Table: name})
+		default:
+			diffs = changed(diffs, DiffViewChanged, name, "", "sql", ov.Sql, nv.Sql)
+			diffs = changed(diffs, DiffViewChanged, name, "", "materialized table", ov.MaterializedTable, nv.MaterializedTable)
+		}
+	}
+	return diffs
+}
+
+// sourcedTable a table of a schema definition, and the name of its source
+type sourcedTable struct {
+	source string
+	def    *TableDef
+}
+
+func defTables(def *SchemaDef) map[string]*sourcedTable {
+	tables := make(map[string]*sourcedTable)
+	if def == nil {
+		return tables
+	}
+	for _, sd := range def.Sources {
+		for _, td := range sd.Tables {
+			name := strings.ToLower(td.Name)
+			if _, ok := tables[name]; !ok {
+				tables[name] = &sourcedTable{source: sd.Name, def: td}
+			}
+		}
+	}
+	return tables
+}
+
+func defViews(def *SchemaDef) map[string]*ViewDef {
+	views := make(map[string]*ViewDef)
+	if def == nil {
+		return views
+	}
+	for _, vd := range def.Views {
+		views[strings.ToLower(vd.Name)] = vd
+	}
+	return views
+}
+
+// sortedKeys the names of seen, sorted
+func sortedKeys(seen map[string]bool) []string {
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// diffTables the differences of the table of name of ot to nt
+func diffTables(name string, ot, nt *sourcedTable) []*Difference {
+	diffs := make([]*Difference, 0)
+	diffs = changed(diffs, DiffTableChanged, name, "", "source", ot.source, nt.source)
+	diffs = changed(diffs, DiffTableChanged, name, "", "partition", partitionText(ot.def.Partition), partitionText(nt.def.Partition))
+	oldIndexes, nextIndexes := indexTexts(ot.def.Indexes), indexTexts(nt.def.Indexes)
+	idxNames := make(map[string]bool, len(nextIndexes))
+	for idx := range oldIndexes {
+		idxNames[idx] = true
+	}
+	for idx := range nextIndexes {
+		idxNames[idx] = true
+	}
+	for _, idx := range sortedKeys(idxNames) {
+		diffs = changed(diffs, DiffTableChanged, name, "", "index "+idx, oldIndexes[idx], nextIndexes[idx])
+	}
+
+	oldFields := make(map[string]*FieldDef, len(ot.def.Fields))
+	for _, fd := range ot.def.Fields {
+		oldFields[strings.ToLower(fd.Name)] = fd
+	}
+	for _, fd := range nt.def.Fields {
+		key := strings.ToLower(fd.Name)
+		of, ok := oldFields[key]
+		if !ok {
+			diffs = append(diffs, addedColumn(name, fd))
+			continue
+		}
+		delete(oldFields, key)
+		diffs = diffFields(diffs, name, of, fd)
+	}
+	for _, fd := range ot.def.Fields {
+		if _, removed := oldFields[strings.ToLower(fd.Name)]; removed {
+			diffs = append(diffs, &Difference{Type: DiffColumnRemoved, Table: name, Column: fd.Name})
+		}
+	}
+	return diffs
+}
+
+// diffFields the changes of the column of table of of to nf
+func diffFields(diffs []*Difference, table string, of, nf *FieldDef) []*Difference {
+	col := nf.Name
+	diffs = changed(diffs, DiffColumnChanged, table, col, "type", of.Type.String(), nf.Type.String())
+	diffs = changed(diffs, DiffColumnChanged, table, col, "native type", of.NativeType.String(), nf.NativeType.String())
+	diffs = changed(diffs, DiffColumnChanged, table, col, "length", fmt.Sprint(of.Length), fmt.Sprint(nf.Length))
+	diffs = changed(diffs, DiffColumnChanged, table, col, "not null", fmt.Sprint(of.NoNulls), fmt.Sprint(nf.NoNulls))
+	diffs = changed(diffs, DiffColumnChanged, table, col, "default", defaultText(of), defaultText(nf))
+	diffs = changed(diffs, DiffColumnChanged, table, col, "key", of.Key, nf.Key)
+	diffs = changed(diffs, DiffColumnChanged, table, col, "indexed", fmt.Sprint(of.Indexed), fmt.Sprint(nf.Indexed))
+	diffs = changed(diffs, DiffColumnChanged, table, col, "collation", of.Collation, nf.Collation)
+	diffs = changed(diffs, DiffColumnChanged, table, col, "computed", of.Computed, nf.Computed)
+	return diffs
+}
+
+// addedColumn the difference of column fd added to table, of What "not
+// null" if it is NOT NULL of no default (so breaks writes that omit it)
+func addedColumn(table string, fd *FieldDef) *Difference {
+	d := &Difference{Type: DiffColumnAdded, Table: table, Column: fd.Name, New: fd.Type.String()}
+	if fd.NoNulls && fd.DefaultValue == nil && fd.Computed == "" {
+		d.What = "not null"
+	}
+	return d
+}
+
+// changed diffs and a difference of what of old to next, if they differ
+func changed(diffs []*Difference, typ DiffType, table, col, what, old, next string) []*Difference {
+	if old == next {
+		return diffs
+	}
+	return append(diffs, &Difference{Type: typ, Table: table, Column: col, What: what, Old: old, New: next})
+}
+
+func defaultText(fd *FieldDef) string {
+	if fd.DefaultValue == nil {
+		return ""
+	}
+	return fmt.Sprint(fd.DefaultValue)
+}
+
+func partitionText(tp *TablePartition) string {
+	if tp == nil {
+		return ""
+	}
+	method := tp.Method
+	if method == "" {
+		method = PartitionRange
+	}
+	n := len(tp.Partitions)
+	if tp.Hashed() {
+		n = int(tp.Buckets)
+	}
+	return fmt.Sprintf("%s(%s) %d", method, strings.Join(tp.Keys, ","), n)
+}
+
+// indexTexts the fields of each index, by index name
+func indexTexts(indexes []*Index) map[string]string {
+	texts := make(map[string]string, len(indexes))
+	for _, idx := range indexes {
+		text := strings.Join(idx.Fields, ",")
+		if idx.PrimaryKey {
+			text = "PRIMARY KEY(" + text + ")"
+		}
+		texts[idx.Name] = text
+	}
+	return texts
+}
@@ -0,0 +1,83 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+func TestSchemaDiff(t *testing.T) {
+	users := func() *schema.Table {
+		tbl := schema.NewTable("users")
+		tbl.AddField(schema.NewFieldBase("id", value.IntType, 64, "user id"))
+		tbl.AddField(schema.NewField("name", value.StringType, 255, schema.NoNulls, "anon", "", "utf8", ""))
+		tbl.AddField(schema.NewFieldBase("email", value.StringType, 128, "email"))
+		tbl.SetColumns([]string{"id", "name", "email"})
+		tbl.Indexes = []*schema.Index{{Name: "PRIMARY", Fields: []string{"id"}, PrimaryKey: true}}
+		return tbl
+	}
+	def := func(tables ...*schema.Table) *schema.SchemaDef {
+		sd := &schema.SourceDef{Name: "pg"}
+		for _, tbl := range tables {
+			sd.Tables = append(sd.Tables, schema.TableDefOf(tbl))
+		}
+		return &schema.SchemaDef{Name: "app", Sources: []*schema.SourceDef{sd}}
+	}
+
+	old := def(users(), schema.NewTable("audit"))
+	old.Views = []*schema.ViewDef{{Name: "active", Sql: "SELECT id FROM users"}}
+
+	changed := users()
+	changed.FieldMap["email"].Type = value.IntType
+	changed.FieldMap["name"].Length = 64
+	changed.AddField(schema.NewField("status", value.StringType, 16, schema.NoNulls, nil, "", "", ""))
+	changed.AddField(schema.NewFieldBase("note", value.StringType, 0, ""))
+	changed.Indexes = append(changed.Indexes, &schema.Index{Name: "by_email", Fields: []string{"email"}})
+	next := def(changed, schema.NewTable("orders"))
+	next.Views = []*schema.ViewDef{{Name: "active", Sql: "SELECT id FROM users WHERE id > 1"}}
+
+	diffs := schema.Diff(old, next)
+	got := make([]string, len(diffs))
+	breaking := make([]string, 0)
+	for i, d := range diffs {
+		got[i] = d.String()
+		if d.Breaking() {
+			breaking = append(breaking, d.String())
+		}
+	}
+	assert.Equal(t, []string{
+		"table_removed audit",
+		"table_added orders",
+		"table_changed users index by_email:  -> email",
+		"column_changed users.name length: 255 -> 64",
+		"column_changed users.email type: string -> int",
+		"column_added users.status string (not null)",
+		"column_added users.note string",
+		"view_changed active sql: SELECT id FROM users -> SELECT id FROM users WHERE id > 1",
+	}, got)
+	assert.Equal(t, []string{
+		"table_removed audit",
+		"column_changed users.name length: 255 -> 64",
+		"column_changed users.email type: string -> int",
+		"column_added users.status string (not null)",
+	}, breaking)
+
+	// the same schema has none, of a json round trip of its definition
+	by, err := json.Marshal(next)
+	assert.Tf(t, err == nil, "no error %v", err)
+	loaded := &schema.SchemaDef{}
+	assert.T(t, json.Unmarshal(by, loaded) == nil)
+	assert.Equal(t, 0, len(schema.Diff(next, loaded)))
+
+	// the differences serialize for tooling
+	by, err = json.Marshal(diffs[3])
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, `{"type":"column_changed","table":"users","column":"name","what":"length","old":"255","new":"64"}`, string(by))
+	d := &schema.Difference{}
+	assert.T(t, json.Unmarshal(by, d) == nil)
+	assert.Equal(t, diffs[3], d)
+}
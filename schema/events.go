@@ -0,0 +1,152 @@
+package schema
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+)
+
+var (
+	// EventBufferSize events buffered per subscriber of a schema, a
+	// subscriber more events behind than this is closed with ErrSlowEvents
+	EventBufferSize = 256
+
+	// ErrSlowEvents the error of a subscription of schema events closed for
+	// falling behind them
+	ErrSlowEvents = fmt.Errorf("subscriber could not keep up with schema events")
+)
+
+// EventType the type of change of a SchemaEvent
+type EventType uint8
+
+const (
+	TableAdded EventType = iota + 1
+	TableRemoved
+	TableAltered
+	SourceAdded
+	SourceRemoved
+)
+
+func (m EventType) String() string {
+	switch m {
+	case TableAdded:
+		return "table_added"
+	case TableRemoved:
+		return "table_removed"
+	case TableAltered:
+		return "table_altered"
+	case SourceAdded:
+		return "source_added"
+	case SourceRemoved:
+		return "source_removed"
+	}
+	return "unknown"
+}
+
+// SchemaEvent a change of the tables or sources of a Schema, ie for
+// frontends to invalidate cached plans of a table or push its new metadata
+// to clients.  Table is empty for events of sources, Source for those of
+// views.
+type SchemaEvent struct {
+	Type    EventType
+	Schema  string
+	Source  string
+	Table   string
+	Version uint64 // Version of the schema after the change
+	Ts      time.Time
+}
+
+// SchemaSubscription the events of a schema, read until it is closed.  A
+// subscriber that can not keep up is closed, with ErrSlowEvents.
+type SchemaSubscription interface {
+	// Events the channel of events, closed when the subscription is
+	Events() <-chan *SchemaEvent
+	// Err why the subscription was closed, nil if closed by Close
+	Err() error
+	Close() error
+}
+
+// eventBus fans out the events of a schema to its subscribers, never
+// blocking on one
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[*eventSub]struct{}
+}
+
+type eventSub struct {
+	bus    *eventBus
+	ch     chan *SchemaEvent
+	err    error
+	closed bool
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[*eventSub]struct{})}
+}
+
+func (m *eventBus) subscribe() *eventSub {
+	sub := &eventSub{bus: m, ch: make(chan *SchemaEvent, EventBufferSize)}
+	m.mu.Lock()
+	m.subs[sub] = struct{}{}
+	m.mu.Unlock()
+	return sub
+}
+
+func (m *eventBus) publish(ev *SchemaEvent) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for sub := range m.subs {
+		select {
+		case sub.ch <- ev:
+		default:
+			u.Warnf("closing subscriber of schema %q, %d events behind", ev.Schema, len(sub.ch))
+			sub.closeUnlocked(ErrSlowEvents)
+		}
+	}
+}
+
+func (m *eventSub) Events() <-chan *SchemaEvent { return m.ch }
+
+func (m *eventSub) Err() error {
+	m.bus.mu.Lock()
+	defer m.bus.mu.Unlock()
+	return m.err
+}
+
+func (m *eventSub) Close() error {
+	m.bus.mu.Lock()
+	defer m.bus.mu.Unlock()
+	m.closeUnlocked(nil)
+	return nil
+}
+
+func (m *eventSub) closeUnlocked(err error) {
+	if m.closed {
+		return
+	}
+	m.closed, m.err = true, err
+	delete(m.bus.subs, m)
+	close(m.ch)
+}
+
+// Subscribe to the events of the tables and sources of this schema, of
+// changes after now.
+//
+//   sub := s.Subscribe()
+//   defer sub.Close()
+//   for ev := range sub.Events() {
+//       planCache.Invalidate(ev.Table)
+//   }
+func (m *Schema) Subscribe() SchemaSubscription {
+	return m.events.subscribe()
+}
+
+func (m *Schema) publish(typ EventType, source, table string) {
+	v := m.bumpVersion()
+	m.events.publish(&SchemaEvent{Type: typ, Schema: m.Name, Source: source, Table: table, Version: v, Ts: time.Now()})
+}
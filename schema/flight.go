@@ -0,0 +1,71 @@
+package schema
+
+import (
+	"fmt"
+	"sync"
+
+	u "github.com/araddon/gou"
+)
+
+// flightGroup runs a call of a key once of concurrent calls of it, the
+// callers waiting on the one running share its result.  So a burst of
+// queries of a table not yet loaded asks a slow source for it once, not
+// once per query.  The zero value is ready to use.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+// do run fn of key, unless a call of key is running, then wait for it and
+// return its result.  Shared is true of callers given the result of another.
+func (m *flightGroup) do(key string, fn func() (interface{}, error)) (interface{}, error, bool) {
+	m.mu.Lock()
+	if m.calls == nil {
+		m.calls = make(map[string]*flight)
+	}
+	if f, ok := m.calls[key]; ok {
+		m.mu.Unlock()
+		f.wg.Wait()
+		return f.val, f.err, true
+	}
+	f := &flight{}
+	f.wg.Add(1)
+	m.calls[key] = f
+	m.mu.Unlock()
+
+	defer func() {
+		f.wg.Done()
+		m.mu.Lock()
+		delete(m.calls, key)
+		m.mu.Unlock()
+	}()
+	f.val, f.err = fn()
+	return f.val, f.err, false
+}
+
+// describe ask the source for the table of name, once of concurrent asks
+// of the same table
+func (m *SchemaSource) describe(tableName string) (*Table, error) {
+	sourceTable, ok := m.DS.(SourceTableSchema)
+	if !ok {
+		u.Warnf("ss:%p ds:%T ds:%p could not find table %q from tables:%v", m, m.DS, m.DS, tableName, m.DS.Tables())
+		return nil, fmt.Errorf("Could not find that table: %v", tableName)
+	}
+	v, err, _ := m.flights.do(tableName, func() (interface{}, error) {
+		return sourceTable.Table(tableName)
+	})
+	if err != nil {
+		return nil, err
+	}
+	tbl, _ := v.(*Table)
+	if tbl == nil {
+		return nil, ErrNotFound
+	}
+	return tbl, nil
+}
@@ -24,6 +24,13 @@ type (
 	Iterator interface {
 		Next() Message
 	}
+	// IteratorErr an Iterator whose Next returns nil when its scan fails as
+	// well as at the end of its rows, Err is the error that stopped the scan,
+	// nil if all rows were read.
+	IteratorErr interface {
+		Iterator
+		Err() error
+	}
 	// Key interface is the Unique Key identifying a row
 	Key interface {
 		Key() driver.Value
@@ -40,3 +47,15 @@ func NewKeyUint(key uint64) *KeyUint { return &KeyUint{key} }
 
 // Key is key interface
 func (m *KeyUint) Key() driver.Value { return driver.Value(m.ID) }
+
+// KeyCol implements Key interface and is a named column = value key
+type KeyCol struct {
+	Name string
+	Val  driver.Value
+}
+
+// NewKeyCol new column key
+func NewKeyCol(name string, val driver.Value) KeyCol { return KeyCol{name, val} }
+
+// Key is key interface
+func (m KeyCol) Key() driver.Value { return m.Val }
@@ -0,0 +1,172 @@
+package schema
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/araddon/qlbridge/lex"
+)
+
+const (
+	// PartitionRange partitions of ranges of the values of the key, each of
+	// its Left (inclusive) to its Right (exclusive).  The default Method.
+	PartitionRange = "range"
+	// PartitionHash partitions of the hash of the values of the keys into
+	// Buckets, the i'th partition holding bucket i
+	PartitionHash = "hash"
+)
+
+// Hashed are the partitions of the hash of the keys (else ranges)
+func (m *TablePartition) Hashed() bool { return m.Method == PartitionHash }
+
+// Bucket the bucket of the hash of the values of the keys of a row, of the
+// Buckets of a hash partitioning.  Numbers hash the same whatever their Go
+// type, so the int64 of a row and the float64 of a literal agree.
+func (m *TablePartition) Bucket(vals ...driver.Value) int {
+	if m.Buckets <= 0 {
+		return 0
+	}
+	h := fnv.New32a()
+	for _, v := range vals {
+		h.Write([]byte(partitionKey(v)))
+		h.Write([]byte{0})
+	}
+	return int(h.Sum32() % uint32(m.Buckets))
+}
+
+// PartitionFor the partition holding the row of the values of the keys, nil
+// if none does (or the partitions are not listed)
+//
+//   tp.PartitionFor(int64(42))  // user_id 42
+func (m *TablePartition) PartitionFor(vals ...driver.Value) *Partition {
+	if m.Hashed() {
+		if len(m.Partitions) != int(m.Buckets) || len(m.Partitions) == 0 {
+			return nil
+		}
+		return m.Partitions[m.Bucket(vals...)]
+	}
+	if len(vals) != 1 {
+		return nil
+	}
+	for _, p := range m.Partitions {
+		if p.Contains(vals[0]) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Contains is v in the range of this partition, Left <= v < Right, of an
+// empty bound unbounded
+func (m *Partition) Contains(v driver.Value) bool {
+	return m.MayHold(lex.TokenEqual, v)
+}
+
+// MayHold may this range partition hold values of the key of op to v, ie
+// for key > 10 any partition of a Right above 10.  Compared as numbers if
+// v is one and the bounds parse as numbers, else as strings.
+func (m *Partition) MayHold(op lex.TokenType, v driver.Value) bool {
+	if v == nil {
+		return false
+	}
+	aboveLeft := m.Left == "" || compareBound(v, m.Left) >= 0
+	belowRight := m.Right == "" || compareBound(v, m.Right) < 0
+	switch op {
+	case lex.TokenEqual, lex.TokenEqualEqual:
+		return aboveLeft && belowRight
+	case lex.TokenLT:
+		return m.Left == "" || compareBound(v, m.Left) > 0
+	case lex.TokenLE:
+		return aboveLeft
+	case lex.TokenGT, lex.TokenGE:
+		return belowRight
+	}
+	return true
+}
+
+// SetPartition set the partitioning of this table, its keys must be columns
+// of it, and a hash partitioning of Buckets lists a partition per bucket
+// (or none).  Used by sources that know how their tables are partitioned,
+// and by the planner to prune partitions (see plan.Source.Partitions).
+func (m *Table) SetPartition(tp *TablePartition) error {
+	if tp == nil {
+		m.Partition, m.PartitionCt = nil, 0
+		m.Changed()
+		return nil
+	}
+	switch tp.Method {
+	case "", PartitionRange, PartitionHash:
+	default:
+		return fmt.Errorf("unknown partition method %q of table %q", tp.Method, m.Name)
+	}
+	if len(tp.Keys) == 0 {
+		return fmt.Errorf("no partition keys of table %q", m.Name)
+	}
+	for _, key := range tp.Keys {
+		if !m.hasColumn(key) {
+			return fmt.Errorf("partition key %q is not a column of table %q", key, m.Name)
+		}
+	}
+	if tp.Hashed() {
+		if tp.Buckets <= 0 {
+			return fmt.Errorf("hash partitioning of table %q has no buckets", m.Name)
+		}
+		if len(tp.Partitions) > 0 && len(tp.Partitions) != int(tp.Buckets) {
+			return fmt.Errorf("hash partitioning of table %q has %d partitions for %d buckets",
+				m.Name, len(tp.Partitions), tp.Buckets)
+		}
+	} else if len(tp.Keys) != 1 && len(tp.Partitions) > 0 {
+		return fmt.Errorf("range partitioning of table %q is of one key, not %d", m.Name, len(tp.Keys))
+	}
+	if tp.Table == "" {
+		tp.Table = m.Name
+	}
+	m.Partition = tp
+	m.PartitionCt = len(tp.Partitions)
+	if m.PartitionCt == 0 {
+		m.PartitionCt = int(tp.Buckets)
+	}
+	m.Changed()
+	return nil
+}
+
+// hasColumn is name a field (or column, of tables of no fields) of this table
+func (m *Table) hasColumn(name string) bool {
+	if _, ok := m.Field(name); ok {
+		return true
+	}
+	for _, col := range m.cols {
+		if strings.EqualFold(col, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareBound compare v to the bound of a partition, -1, 0, 1
+func compareBound(v driver.Value, bound string) int {
+	if f, ok := toStatsFloat(v); ok {
+		if bf, err := strconv.ParseFloat(bound, 64); err == nil {
+			switch {
+			case f < bf:
+				return -1
+			case f > bf:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(v), bound)
+}
+
+// partitionKey the text of v hashed to its bucket
+func partitionKey(v driver.Value) string {
+	if f, ok := toStatsFloat(v); ok && f == math.Trunc(f) && math.Abs(f) < 1e18 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return fmt.Sprint(v)
+}
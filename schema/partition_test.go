@@ -0,0 +1,89 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+func TestTablePartition(t *testing.T) {
+	tbl := schema.NewTable("events")
+	tbl.AddFieldType("id", value.IntType)
+	tbl.AddFieldType("day", value.StringType)
+	tbl.SetColumns([]string{"id", "day"})
+
+	tests := []struct {
+		tp  *schema.TablePartition
+		err bool
+	}{
+		{&schema.TablePartition{Keys: []string{"id"}, Method: schema.PartitionHash, Buckets: 4}, false},
+		{&schema.TablePartition{Keys: []string{"day"}, Partitions: []*schema.Partition{{Id: "a", Right: "2016-03"}}}, false},
+		{&schema.TablePartition{Keys: []string{"nope"}, Method: schema.PartitionHash, Buckets: 4}, true},
+		{&schema.TablePartition{Keys: []string{"id"}, Method: "list"}, true},
+		{&schema.TablePartition{Keys: []string{"id"}, Method: schema.PartitionHash}, true},
+		{&schema.TablePartition{Keys: []string{"id"}, Method: schema.PartitionHash, Buckets: 3,
+			Partitions: []*schema.Partition{{Id: "0"}}}, true},
+		{&schema.TablePartition{Keys: []string{"id", "day"}, Partitions: []*schema.Partition{{Id: "a"}}}, true},
+	}
+	for _, tt := range tests {
+		v := tbl.Version()
+		err := tbl.SetPartition(tt.tp)
+		assert.Equalf(t, tt.err, err != nil, "%v: %v", tt.tp, err)
+		if err == nil {
+			assert.T(t, tbl.Version() > v)
+			assert.Equal(t, tt.tp, tbl.Partition)
+			assert.Equal(t, "events", tt.tp.Table)
+		}
+	}
+
+	// numbers hash alike whatever their type
+	hash := &schema.TablePartition{Keys: []string{"id"}, Method: schema.PartitionHash, Buckets: 4}
+	for i := int64(0); i < 20; i++ {
+		b := hash.Bucket(i)
+		assert.T(t, b >= 0 && b < 4)
+		assert.Equal(t, b, hash.Bucket(float64(i)))
+	}
+	assert.T(t, hash.PartitionFor(int64(1)) == nil)
+	hash.Partitions = []*schema.Partition{{Id: "0"}, {Id: "1"}, {Id: "2"}, {Id: "3"}}
+	assert.Equal(t, hash.Partitions[hash.Bucket(int64(7))], hash.PartitionFor(int64(7)))
+
+	ranges := &schema.TablePartition{Keys: []string{"id"}, Partitions: []*schema.Partition{
+		{Id: "low", Right: "100"},
+		{Id: "mid", Left: "100", Right: "1000"},
+		{Id: "high", Left: "1000"},
+	}}
+	assert.Equal(t, "low", ranges.PartitionFor(int64(99)).Id)
+	assert.Equal(t, "mid", ranges.PartitionFor(int64(100)).Id)
+	assert.Equal(t, "high", ranges.PartitionFor(5000.5).Id)
+
+	mid := ranges.Partitions[1]
+	holds := []struct {
+		op   lex.TokenType
+		v    interface{}
+		hold bool
+	}{
+		{lex.TokenEqual, int64(500), true},
+		{lex.TokenEqual, int64(1000), false},
+		{lex.TokenLT, int64(100), false},
+		{lex.TokenLE, int64(100), true},
+		{lex.TokenGT, int64(999), true},
+		{lex.TokenGE, int64(1000), false},
+		{lex.TokenNE, int64(500), true},
+	}
+	for _, tt := range holds {
+		assert.Equalf(t, tt.hold, mid.MayHold(tt.op, tt.v), "%v %v", tt.op, tt.v)
+	}
+
+	// the location and source of partitions are serialized
+	tp := &schema.TablePartition{Table: "events", Keys: []string{"id"}, Method: schema.PartitionHash, Buckets: 2,
+		Partitions: []*schema.Partition{{Id: "0", Location: "10.0.0.1:9000", Source: "shard0"}, {Id: "1", Source: "shard1"}}}
+	by, err := tp.Marshal()
+	assert.Tf(t, err == nil, "no error %v", err)
+	got := &schema.TablePartition{}
+	assert.Tf(t, got.Unmarshal(by) == nil, "unmarshal")
+	assert.Equal(t, tp, got)
+}
@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/araddon/qlbridge/expr"
+)
+
+// SourceOf the source of the schema of qualifier, its Alias or Name, nil
+// if none.  Tables of the same name of several sources are told apart by
+// the qualifier of their source:
+//
+//   SELECT * FROM es.users
+//   SELECT * FROM pg.users
+func (m *Schema) SourceOf(qualifier string) *SchemaSource {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sourceOfUnlocked(qualifier)
+}
+
+func (m *Schema) sourceOfUnlocked(qualifier string) *SchemaSource {
+	if qualifier == "" {
+		return nil
+	}
+	ic := m.IdentifierCase()
+	// aliases first, a source may be aliased by the name of another
+	for _, ss := range m.schemaSources {
+		if ss.Alias != "" && ic.Equal(ss.Alias, qualifier) {
+			return ss
+		}
+	}
+	for _, ss := range m.schemaSources {
+		if ic.Equal(ss.Name, qualifier) {
+			return ss
+		}
+	}
+	return nil
+}
+
+// qualifiedUnlocked the source and name of the table of a name qualified
+// by a source (see SourceOf), false if name is not
+func (m *Schema) qualifiedUnlocked(name string) (*SchemaSource, string, bool) {
+	left, right, ok := expr.LeftRight(name)
+	if !ok {
+		return nil, "", false
+	}
+	ss := m.sourceOfUnlocked(left)
+	if ss == nil {
+		return nil, "", false
+	}
+	return ss, right, true
+}
+
+// TableSources the sources of the tables of name, in order of the sources
+// added to the schema.  The first is the source of the table of the name
+// unqualified (see Table).
+func (m *Schema) TableSources(tableName string) []*SchemaSource {
+	tableName = strings.ToLower(tableName)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sources := make([]*SchemaSource, 0)
+	for _, ss := range m.sourcesUnlocked() {
+		if ss.loaded(tableName) != nil {
+			sources = append(sources, ss)
+		}
+	}
+	return sources
+}
+
+// sourcesUnlocked the sources of the schema in the order added
+func (m *Schema) sourcesUnlocked() []*SchemaSource {
+	sources := make([]*SchemaSource, 0, len(m.schemaSources))
+	for _, ss := range m.schemaSources {
+		sources = append(sources, ss)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].order < sources[j].order })
+	return sources
+}
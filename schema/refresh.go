@@ -0,0 +1,212 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+)
+
+const (
+	// RefreshNever the refresh interval of tables that are never refreshed
+	// of their source once loaded, ie huge static ones, unless invalidated
+	RefreshNever time.Duration = -1
+)
+
+// refreshMu guards when the tables were last refreshed, read by planners
+// while refreshed in the background
+var refreshMu sync.RWMutex
+
+// RefreshInterval how often the table of name of this source is refreshed
+// of it, the first of
+//   - Refresh of the table (see Table.RefreshInterval)
+//   - Conf.TableRefresh of the table
+//   - Refresh of this source
+//   - Conf.Refresh
+//   - SchemaRefreshInterval
+func (m *SchemaSource) RefreshInterval(table string) time.Duration {
+	if m.Conf != nil {
+		if policy, ok := m.Conf.TableRefresh[table]; ok {
+			dur, err := parseRefresh(policy)
+			if err == nil {
+				return dur
+			}
+			u.Warnf("bad refresh of table %q of source %q: %v", table, m.Name, err)
+		}
+	}
+	if m.Refresh != 0 {
+		return m.Refresh
+	}
+	if m.Conf != nil && m.Conf.Refresh != "" {
+		dur, err := parseRefresh(m.Conf.Refresh)
+		if err == nil {
+			return dur
+		}
+		u.Warnf("bad refresh of source %q: %v", m.Name, err)
+	}
+	return -SchemaRefreshInterval
+}
+
+// parseRefresh the interval of a refresh policy of config, a duration
+// ("30s", "1h") or "never"
+func parseRefresh(policy string) (time.Duration, error) {
+	if strings.ToLower(policy) == "never" {
+		return RefreshNever, nil
+	}
+	dur, err := time.ParseDuration(policy)
+	if err != nil {
+		return 0, err
+	}
+	if dur <= 0 {
+		return 0, fmt.Errorf("refresh interval must be positive: %q", policy)
+	}
+	return dur, nil
+}
+
+// RefreshInterval how often this table is refreshed of its source, its
+// Refresh else that of its source (see SchemaSource.RefreshInterval)
+func (m *Table) RefreshInterval() time.Duration {
+	if m.Refresh != 0 {
+		return m.Refresh
+	}
+	if m.SchemaSource != nil {
+		return m.SchemaSource.RefreshInterval(m.Name)
+	}
+	return -SchemaRefreshInterval
+}
+
+// Refreshed when this table was last refreshed of its source, zero if it
+// was invalidated
+func (m *Table) Refreshed() time.Time {
+	refreshMu.RLock()
+	defer refreshMu.RUnlock()
+	return m.lastRefreshed
+}
+
+// Invalid was this table invalidated (see Schema.Invalidate) since it was
+// last refreshed
+func (m *Table) Invalid() bool {
+	refreshMu.RLock()
+	defer refreshMu.RUnlock()
+	return m.invalid
+}
+
+// Invalidate mark the table of name stale, so it is reloaded of its source
+// on its next use (see Schema.Table), whatever its refresh interval.
+func (m *Schema) Invalidate(tableName string) error {
+	tbl, err := m.table(tableName)
+	if err != nil {
+		return ErrNotFound
+	}
+	refreshMu.Lock()
+	tbl.lastRefreshed = time.Time{}
+	tbl.invalid = true
+	refreshMu.Unlock()
+	return nil
+}
+
+// RefreshNow reload the table of name of its source now, replacing it (and
+// publishing TableAltered) if the source gives a new one.  The relations,
+// stats and refresh interval of the table are kept, unless the new one
+// has its own.
+func (m *Schema) RefreshNow(tableName string) error {
+	tableName = strings.ToLower(tableName)
+	m.mu.RLock()
+	ss, ok := m.tableSources[tableName]
+	cur := m.tableMap[tableName]
+	m.mu.RUnlock()
+	if !ok || ss == nil {
+		return ErrNotFound
+	}
+	if err := ss.reloadTable(tableName, cur); err != nil {
+		return err
+	}
+	m.AddTableName(tableName, ss)
+	return nil
+}
+
+// RefreshStale reload the tables of this schema that are not Current (of
+// their refresh intervals) of their sources, of those whose sources
+// describe their tables.  Returns the names of the tables refreshed.
+func (m *Schema) RefreshStale() []string {
+	m.mu.RLock()
+	tables := make([]*Table, 0, len(m.tableNames))
+	for _, name := range m.tableNames {
+		if tbl := m.tableMap[strings.ToLower(name)]; tbl != nil && tbl.SchemaSource != nil {
+			tables = append(tables, tbl)
+		}
+	}
+	m.mu.RUnlock()
+
+	refreshed := make([]string, 0)
+	for _, tbl := range tables {
+		if _, ok := tbl.SchemaSource.DS.(SourceTableSchema); !ok || tbl.Current() {
+			continue
+		}
+		if err := m.RefreshNow(tbl.Name); err != nil {
+			u.Warnf("could not refresh table %q of schema %q: %v", tbl.Name, m.Name, err)
+			continue
+		}
+		refreshed = append(refreshed, tbl.Name)
+	}
+	return refreshed
+}
+
+// reloadTable ask the source for the table of name again, replacing cur,
+// once of concurrent reloads of it (ie by the queries of a table
+// invalidated)
+func (m *SchemaSource) reloadTable(tableName string, cur *Table) error {
+	if _, ok := m.DS.(SourceTableSchema); !ok {
+		return fmt.Errorf("source %q can not describe its table %q to refresh it", m.Name, tableName)
+	}
+	_, err, _ := m.flights.do("refresh "+tableName, func() (interface{}, error) {
+		tbl, err := m.describe(tableName)
+		if err != nil {
+			return nil, err
+		}
+		if cur != nil && cur != tbl {
+			keepTable(tbl, cur)
+		}
+		tbl.SchemaSource = m
+		tbl.SetRefreshed()
+		m.mu.Lock()
+		m.tableMap[tbl.Name] = tbl
+		m.mu.Unlock()
+		return tbl, nil
+	})
+	return err
+}
+
+// keepTable carry the metadata of cur that its source does not know of
+// over to tbl replacing it
+func keepTable(tbl, cur *Table) {
+	tbl.tblId = cur.tblId
+	if tbl.Partition == nil {
+		tbl.Partition = cur.Partition
+	}
+	if tbl.Refresh == 0 {
+		tbl.Refresh = cur.Refresh
+	}
+	if len(tbl.Relations) == 0 {
+		for _, r := range cur.Relations {
+			if err := tbl.AddRelation(r); err != nil {
+				u.Warnf("dropping relation of refreshed table %q: %v", tbl.Name, err)
+			}
+		}
+	}
+	keepComputed(tbl, cur)
+	stats := make(map[string]*ColumnStats)
+	for _, f := range tbl.Fields {
+		if f.ColumnStats() != nil {
+			continue
+		}
+		if cf, ok := cur.FieldMap[f.Name]; ok && cf.ColumnStats() != nil {
+			stats[f.Name] = cf.ColumnStats()
+		}
+	}
+	if len(stats) > 0 {
+		tbl.SetStats(stats)
+	}
+}
@@ -0,0 +1,146 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type (
+	// Relation a relationship of columns of a table to the columns of
+	// another, a FOREIGN KEY of the source, or a logical join declared of
+	// tables whose source does not know of it.
+	//
+	//   orders.user_id -> users.user_id
+	Relation struct {
+		Name       string   `json:"name,omitempty"`
+		Table      string   `json:"table"` // table of Columns
+		Columns    []string `json:"columns"`
+		RefTable   string   `json:"ref_table"`
+		RefColumns []string `json:"ref_columns"`
+		Logical    bool     `json:"logical,omitempty"` // declared, not enforced by the source
+	}
+
+	// JoinSuggestion a join of a table to another by a relation of either,
+	// ie for a frontend to offer the joins of the table of a query
+	JoinSuggestion struct {
+		Table    string    // the other table
+		On       string    // the join condition, ie "orders.user_id = users.user_id"
+		Relation *Relation // the relation joined on
+	}
+)
+
+func (m *Relation) String() string {
+	return fmt.Sprintf("%s(%s) -> %s(%s)", m.Table, strings.Join(m.Columns, ", "),
+		m.RefTable, strings.Join(m.RefColumns, ", "))
+}
+
+// On the equality of the columns of the relation, of tables named (or
+// aliased) left and ref
+func (m *Relation) On(left, ref string) string {
+	conds := make([]string, len(m.Columns))
+	for i, col := range m.Columns {
+		conds[i] = fmt.Sprintf("%s.%s = %s.%s", left, col, ref, m.RefColumns[i])
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// Joins does the relation join the columns of its table cols to refCols of
+// the table it references, in any order of pairs
+func (m *Relation) Joins(cols, refCols []string) bool {
+	if len(cols) != len(m.Columns) || len(refCols) != len(m.RefColumns) {
+		return false
+	}
+	for i, col := range m.Columns {
+		found := false
+		for j := range cols {
+			if strings.EqualFold(cols[j], col) && strings.EqualFold(refCols[j], m.RefColumns[i]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// AddRelation add a relation of columns of this table to another, of the
+// same count of columns, which must be fields of this table if it has any.
+//
+//   tbl.AddRelation(&schema.Relation{Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"user_id"}})
+func (m *Table) AddRelation(r *Relation) error {
+	if len(r.Columns) == 0 || len(r.Columns) != len(r.RefColumns) {
+		return fmt.Errorf("relation of table %q must have the same columns as referenced of %q", m.Name, r.RefTable)
+	}
+	if r.RefTable == "" {
+		return fmt.Errorf("relation of table %q references no table", m.Name)
+	}
+	if len(m.Fields) > 0 {
+		for _, col := range r.Columns {
+			if _, ok := m.FieldMap[col]; !ok {
+				return fmt.Errorf("relation of %q to %q of unknown column %q", m.Name, r.RefTable, col)
+			}
+		}
+	}
+	r.Table = m.Name
+	r.RefTable = strings.ToLower(r.RefTable)
+	m.Relations = append(m.Relations, r)
+	for _, col := range r.Columns {
+		if f, ok := m.FieldMap[col]; ok {
+			f.Relations = append(f.Relations, r)
+		}
+	}
+	m.Changed()
+	return nil
+}
+
+// RelationsBetween the relations of table a to b, and of b to a
+func (m *Schema) RelationsBetween(a, b string) []*Relation {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rels := make([]*Relation, 0)
+	for _, pair := range [][2]string{{a, b}, {b, a}} {
+		if tbl := m.tableMap[pair[0]]; tbl != nil {
+			for _, r := range tbl.Relations {
+				if r.RefTable == pair[1] {
+					rels = append(rels, r)
+				}
+			}
+		}
+	}
+	return rels
+}
+
+// JoinSuggestions the joins of table to the tables of this schema, by the
+// relations of table and those of the tables referencing it, sorted by
+// table
+func (m *Schema) JoinSuggestions(table string) []*JoinSuggestion {
+	table = strings.ToLower(table)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	joins := make([]*JoinSuggestion, 0)
+	if tbl := m.tableMap[table]; tbl != nil {
+		for _, r := range tbl.Relations {
+			if _, ok := m.tableMap[r.RefTable]; ok {
+				joins = append(joins, &JoinSuggestion{Table: r.RefTable, On: r.On(table, r.RefTable), Relation: r})
+			}
+		}
+	}
+	for _, name := range m.tableNames {
+		name = strings.ToLower(name)
+		tbl := m.tableMap[name]
+		if tbl == nil || name == table {
+			continue
+		}
+		for _, r := range tbl.Relations {
+			if r.RefTable == table {
+				joins = append(joins, &JoinSuggestion{Table: name, On: r.On(name, table), Relation: r})
+			}
+		}
+	}
+	sort.SliceStable(joins, func(i, j int) bool { return joins[i].Table < joins[j].Table })
+	return joins
+}
@@ -0,0 +1,66 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+func relTable(name string, cols ...string) *schema.Table {
+	tbl := schema.NewTable(name)
+	for _, col := range cols {
+		tbl.AddField(schema.NewFieldBase(col, value.IntType, 64, ""))
+	}
+	tbl.SetColumns(cols)
+	return tbl
+}
+
+func TestRelations(t *testing.T) {
+	users := relTable("users", "user_id", "org_id")
+	orders := relTable("orders", "order_id", "user_id")
+	orgs := relTable("orgs", "org_id")
+
+	fk := &schema.Relation{Name: "fk_user", Columns: []string{"user_id"}, RefTable: "Users", RefColumns: []string{"user_id"}}
+	assert.T(t, orders.AddRelation(fk) == nil)
+	assert.Equal(t, "orders", fk.Table)
+	assert.Equal(t, "users", fk.RefTable)
+	assert.Equal(t, []*schema.Relation{fk}, orders.FieldMap["user_id"].Relations)
+	assert.Equal(t, "orders(user_id) -> users(user_id)", fk.String())
+	assert.T(t, fk.Joins([]string{"user_id"}, []string{"user_id"}))
+	assert.T(t, !fk.Joins([]string{"order_id"}, []string{"user_id"}))
+
+	logical := &schema.Relation{Columns: []string{"org_id"}, RefTable: "orgs", RefColumns: []string{"org_id"}, Logical: true}
+	assert.T(t, users.AddRelation(logical) == nil)
+
+	assert.T(t, orders.AddRelation(&schema.Relation{Columns: []string{"nope"}, RefTable: "users", RefColumns: []string{"user_id"}}) != nil)
+	assert.T(t, orders.AddRelation(&schema.Relation{Columns: []string{"user_id"}, RefTable: "users"}) != nil)
+	assert.T(t, orders.AddRelation(&schema.Relation{Columns: []string{"user_id"}, RefColumns: []string{"user_id"}}) != nil)
+	assert.Equal(t, 1, len(orders.Relations))
+
+	s := schema.NewSchema("rels")
+	ss := schema.NewSchemaSource("rels", "mem")
+	s.AddSourceSchema(ss)
+	for _, tbl := range []*schema.Table{users, orders, orgs} {
+		ss.AddTable(tbl)
+	}
+	assert.Equal(t, []*schema.Relation{fk}, s.RelationsBetween("users", "orders"))
+	assert.Equal(t, []*schema.Relation{fk}, s.RelationsBetween("orders", "users"))
+	assert.Equal(t, 0, len(s.RelationsBetween("orders", "orgs")))
+
+	joins := s.JoinSuggestions("users")
+	assert.Equal(t, 2, len(joins))
+	assert.Equal(t, "orders", joins[0].Table)
+	assert.Equal(t, "orders.user_id = users.user_id", joins[0].On)
+	assert.Equal(t, "orgs", joins[1].Table)
+	assert.Equal(t, "users.org_id = orgs.org_id", joins[1].On)
+	assert.Equal(t, 0, len(s.JoinSuggestions("nope")))
+
+	// saved with the definition of the table
+	loaded := schema.TableDefOf(orders).Table()
+	assert.Equal(t, 1, len(loaded.Relations))
+	assert.Equal(t, "users", loaded.Relations[0].RefTable)
+	assert.Equal(t, 1, len(loaded.FieldMap["user_id"].Relations))
+}
@@ -14,6 +14,7 @@ import (
 	u "github.com/araddon/gou"
 
 	"github.com/araddon/qlbridge/expr"
+	"github.com/araddon/qlbridge/rel"
 	"github.com/araddon/qlbridge/value"
 )
 
@@ -33,6 +34,11 @@ var (
 	ShowTableColumns     = []string{"Table", "Table_Type"}
 	ShowVariablesColumns = []string{"Variable_name", "Value"}
 	ShowDatabasesColumns = []string{"Database"}
+	ProcessListColumns   = []string{"Id", "User", "Host", "db", "Command", "Time", "State", "Info"}
+	GrantsColumns        = []string{"Grants"}
+	ExplainColumns       = []string{"id", "parent_id", "task", "detail"}
+	HealthColumns        = []string{"source", "healthy", "latency_ms", "last_error", "last_check", "failures"}
+	FuncsColumns         = []string{"Name", "Category", "Signature", "Type", "Returns", "Description"}
 	//columnColumns       = []string{"Field", "Type", "Null", "Key", "Default", "Extra"}
 	ShowTableColumnMap = map[string]int{"Table": 0}
 	//columnsColumnMap = map[string]int{"Field": 0, "Type": 1, "Null": 2, "Key": 3, "Default": 4, "Extra": 5}
@@ -70,10 +76,28 @@ type (
 		tableSources  map[string]*SchemaSource // Tables to source map
 		tableMap      map[string]*Table        // Tables and their field info, flattened from all sources
 		tableNames    []string                 // List Table names, flattened all sources into one list
+		views         map[string]*View         // Views registered on this schema
+		store         Store                    // Store of the definition of this schema, optional
+		removed       map[string]bool          // sources removed, not kept of the store on Save
+		events        *eventBus                // subscribers of the changes of tables and sources
+		identCase     IdentifierCase           // how names of tables and columns match, see SetIdentifierCase
+		sourceCt      int                      // sources ever added, of the order of each
+		version       uint64                   // generation of the last change, see Version
 		lastRefreshed time.Time                // Last time we refreshed this schema
 		mu            sync.RWMutex
 	}
 
+	// View is a named select statement registered on a Schema, that is
+	// expanded inline by the planner when referenced in a FROM clause.
+	//  - MaterializedTable optionally names a table in this schema holding the
+	//    pre-computed results of the view, which the planner may substitute
+	//    for queries that the view subsumes.
+	View struct {
+		Name              string         // Name of view lowercased
+		Stmt              *rel.SqlSelect // Select statement defining this view
+		MaterializedTable string         // optional table holding pre-computed view results
+	}
+
 	// SchemaSource is a schema for a single DataSource (elasticsearch, mysql, filesystem, elasticsearch)
 	//  each DataSource would have multiple tables
 	SchemaSource struct {
@@ -81,9 +105,14 @@ type (
 		Conf       *ConfigSource     // source configuration
 		Partitions []*TablePartition // List of partitions per table (optional)
 		DS         Source            // This datasource Interface
+		Refresh    time.Duration     // How often to refresh its tables, 0 for that of Conf, or RefreshNever
+		Alias      string            // Qualifier of its tables, ie es of es.users, besides its Name
+		order      int               // of the sources of the schema, earlier own tables of the same name
 		schema     *Schema           // Schema this is participating in
 		tableMap   map[string]*Table // Tables from this Source
 		tableNames []string          // List Table names
+		stored     map[string]*Table // Tables loaded of a Store, see LoadDef
+		flights    flightGroup       // Tables being asked of DS, see describe
 		address    string
 		mu         sync.RWMutex
 	}
@@ -102,11 +131,15 @@ type (
 		Partition      *TablePartition        // Partitions in this table, optional may be empty
 		PartitionCt    int                    // Partition Count
 		Indexes        []*Index               // List of indexes for this table
+		Relations      []*Relation            // Foreign keys, and logical joins, of this table to others
+		Refresh        time.Duration          // How often to refresh this table, 0 for that of its source, or RefreshNever
 		Context        map[string]interface{} // During schema discovery of underlying source, may need to store additional info
 		tblId          uint64                 // internal tableid, hash of table name + schema?
 		cols           []string               // array of column names
 		lastRefreshed  time.Time              // Last time we refreshed this schema
+		invalid        bool                   // Invalidate'd, reloaded on next use
 		rows           [][]driver.Value
+		version        uint64 // generation of the last change, see Version
 	}
 
 	// Field Describes the column info, name, data type, defaults, index, null
@@ -130,7 +163,11 @@ type (
 		Collation          string                 // ie, utf8, none
 		Roles              []string               // ie, {select,insert,update,delete}
 		Indexes            []*Index               // Indexes this participates in
+		Relations          []*Relation            // Relations of the table this is a column of
 		Context            map[string]interface{} // During schema discovery of underlying source, may need to store additional info
+		Stats              *ColumnStats           // Stats of the values of column, nil until analyzed, see Table.SetStats
+		Computed           string                 // Expression of a computed column of the other columns, empty if stored, see Table.AddComputed
+		computed           expr.Node              // Computed parsed
 	}
 	FieldData []byte
 
@@ -147,9 +184,11 @@ type (
 	//  - config to map name to multiple sources
 	//  - connection info
 	ConfigSchema struct {
-		Name       string   `json:"name"`    // Virtual Schema Name, must be unique
-		Sources    []string `json:"sources"` // List of sources , the names of the "Db" in source
-		ConfigNode []string `json:"-"`       // List of backend Servers
+		Name       string            `json:"name"`            // Virtual Schema Name, must be unique
+		Sources    []string          `json:"sources"`         // List of sources , the names of the "Db" in source
+		Case       string            `json:"identifier_case"` // How names of tables and columns match: fold (default), exact, insensitive
+		Aliases    map[string]string `json:"source_aliases"`  // Qualifier of the tables of a source, by source name, ie {"elasticsearch_prod": "es"}
+		ConfigNode []string          `json:"-"`               // List of backend Servers
 	}
 
 	// Config for Source are storage/database/csvfiles
@@ -165,6 +204,9 @@ type (
 		Settings     u.JsonHelper      `json:"settings"`        // Arbitrary settings specific to each source type
 		Partitions   []*TablePartition `json:"partitions"`      // List of partitions per table (optional)
 		PartitionCt  int               `json:"partition_count"` // Instead of array of per table partitions, raw partition count
+		Parallelism  int               `json:"parallelism"`     // Degree of parallelism for queries against this source, 0 for query default
+		Refresh      string            `json:"refresh"`         // How often to refresh the tables of this source ("10m", "never"), see SchemaSource.RefreshInterval
+		TableRefresh map[string]string `json:"table_refresh"`   // Refresh of tables of this source, by table name
 	}
 
 	// Nodes are Servers/Services, ie a running instance of said Source
@@ -189,22 +231,25 @@ func NewSchema(schemaName string) *Schema {
 		tableMap:      make(map[string]*Table),
 		tableSources:  make(map[string]*SchemaSource),
 		tableNames:    make([]string, 0),
+		views:         make(map[string]*View),
+		events:        newEventBus(),
+		version:       nextGeneration(),
 	}
 	return m
 }
 
-// RefreshSchema force a refresh of the underlying schema
+// RefreshSchema force a refresh of the underlying schema.  The sources are
+// asked for their tables without holding the lock of the schema, so
+// queries of the tables already loaded do not wait on slow sources.
 func (m *Schema) RefreshSchema() {
+	sources := m.SchemaSources()
+	for _, ss := range sources {
+		ss.refreshSchema()
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.refreshSchemaUnlocked()
-}
-func (m *Schema) refreshSchemaUnlocked() {
-	for _, ss := range m.schemaSources {
-		ss.refreshSchema()
+	for _, ss := range sources {
 		for _, tableName := range ss.Tables() {
-			//tbl := ss.tableMap[tableName]
-			//u.Debugf("s:%p ss:%p add table name %s  tbl:%#v", m, ss, tableName, tbl)
 			m.addTableNameUnlocked(tableName, ss)
 		}
 	}
@@ -213,9 +258,48 @@ func (m *Schema) refreshSchemaUnlocked() {
 func (m *Schema) AddSourceSchema(ss *SchemaSource) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	_, exists := m.schemaSources[ss.Name]
 	m.schemaSources[ss.Name] = ss
+	delete(m.removed, ss.Name)
+	ss.mu.Lock()
 	ss.schema = m
-	//m.refreshSchemaUnlocked()
+	if !exists {
+		m.sourceCt++
+		ss.order = m.sourceCt
+	}
+	ss.mu.Unlock()
+	if !exists {
+		m.publish(SourceAdded, ss.Name, "")
+	}
+}
+
+// RemoveSourceSchema remove the source of name, and its tables, from this
+// schema
+func (m *Schema) RemoveSourceSchema(source string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ss, ok := m.schemaSources[source]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.schemaSources, source)
+	if m.removed == nil {
+		m.removed = make(map[string]bool)
+	}
+	m.removed[source] = true
+	for _, tableName := range append([]string(nil), m.tableNames...) {
+		if m.tableSources[strings.ToLower(tableName)] == ss {
+			m.removeTableUnlocked(strings.ToLower(tableName))
+		}
+	}
+	// the tables of the same names of the other sources take their place
+	for _, other := range m.sourcesUnlocked() {
+		for _, tableName := range other.Tables() {
+			m.addTableNameUnlocked(tableName, other)
+		}
+	}
+	m.publish(SourceRemoved, source, "")
+	return nil
 }
 
 // SchemaSource Find a SchemaSource for given source name
@@ -229,17 +313,50 @@ func (m *Schema) SchemaSource(source string) (*SchemaSource, error) {
 	return nil, fmt.Errorf("Could not find a SchemaSource for that source %q", source)
 }
 
-// Source Find a SchemaSource for given Table
+// SchemaSources the sources of this schema, sorted by name
+func (m *Schema) SchemaSources() []*SchemaSource {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sources := make([]*SchemaSource, 0, len(m.schemaSources))
+	for _, ss := range m.schemaSources {
+		sources = append(sources, ss)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Name < sources[j].Name })
+	return sources
+}
+
+// Source Find a SchemaSource for given Table, of name matched by the
+// IdentifierCase of this schema
 func (m *Schema) Source(tableName string) (*SchemaSource, error) {
 
-	// We always lower-case table names
+	// We always lower-case the keys of table names
+	name := tableName
 	tableName = strings.ToLower(tableName)
 
 	m.mu.RLock()
+	if _, ok := m.tableSources[tableName]; !ok {
+		if qs, qname, ok := m.qualifiedUnlocked(name); ok {
+			m.mu.RUnlock()
+			if tbl := qs.loaded(strings.ToLower(qname)); tbl != nil && m.IdentifierCase().named(tbl, qname) && qs.DS != nil {
+				return qs, nil
+			}
+			return nil, ErrNotFound
+		}
+		if _, right, ok := expr.LeftRight(tableName); ok {
+			// schema.table
+			_, name, _ = expr.LeftRight(name)
+			tableName = right
+		}
+	}
 	ss, ok := m.tableSources[tableName]
 	if ok && ss != nil && ss.DS != nil {
+		tbl := m.tableMap[tableName]
+		if tbl == nil || m.IdentifierCase().named(tbl, name) {
+			m.mu.RUnlock()
+			return ss, nil
+		}
 		m.mu.RUnlock()
-		return ss, nil
+		return nil, ErrNotFound
 	}
 
 	// In the event of schema tables, we are going to
@@ -294,25 +411,53 @@ func (m *Schema) Open(tableName string) (Conn, error) {
 }
 
 // Is this schema uptodate?
-func (m *Schema) Current() bool    { return m.Since(SchemaRefreshInterval) }
-func (m *Schema) Tables() []string { return m.tableNames }
+func (m *Schema) Current() bool { return m.Since(SchemaRefreshInterval) }
+
+// Tables the names of the tables of this schema, sorted
+func (m *Schema) Tables() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.tableNames...)
+}
 func (m *Schema) Table(tableName string) (*Table, error) {
+	tbl, err := m.table(tableName)
+	if err != nil || !tbl.Invalid() {
+		return tbl, err
+	}
+	if err := m.RefreshNow(tbl.Name); err != nil {
+		u.Warnf("could not reload invalidated table %q: %v", tbl.Name, err)
+		return tbl, nil
+	}
+	return m.table(tableName)
+}
+func (m *Schema) table(tableName string) (*Table, error) {
 
+	name := tableName
 	tableName = strings.ToLower(tableName)
+	ic := m.IdentifierCase()
 
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	tbl, ok := m.tableMap[tableName]
-	if ok && tbl != nil {
+	if ok && tbl != nil && ic.named(tbl, name) {
 		return tbl, nil
 	}
 
+	// source.table, of the source of a qualifier
+	if ss, qname, ok := m.qualifiedUnlocked(name); ok {
+		if tbl := ss.loaded(strings.ToLower(qname)); tbl != nil && ic.named(tbl, qname) {
+			return tbl, nil
+		}
+		return nil, fmt.Errorf("Could not find that table: %v", name)
+	}
+
 	// Lets see if it is   `schema`.`table` format
 	_, tableName, ok = expr.LeftRight(tableName)
 	if ok {
+		_, name, _ = expr.LeftRight(name)
 		tbl, ok = m.tableMap[tableName]
-		if ok && tbl != nil {
+		if ok && tbl != nil && ic.named(tbl, name) {
 			return tbl, nil
 		}
 	}
@@ -327,9 +472,12 @@ func (m *Schema) AddTableName(tableName string, ss *SchemaSource) {
 	m.addTableNameUnlocked(tableName, ss)
 }
 func (m *Schema) addTableNameUnlocked(tableName string, ss *SchemaSource) {
+	// the names are listed as the source names them, the tables keyed by
+	// their lower cased names
+	key := strings.ToLower(tableName)
 	found := false
 	for _, curTableName := range m.tableNames {
-		if tableName == curTableName {
+		if strings.ToLower(curTableName) == key {
 			found = true
 		}
 	}
@@ -337,12 +485,64 @@ func (m *Schema) addTableNameUnlocked(tableName string, ss *SchemaSource) {
 		//u.Debugf("Schema:%p addTableNameUnlocked ss:%p %q  ", m, ss, tableName)
 		m.tableNames = append(m.tableNames, tableName)
 		sort.Strings(m.tableNames)
-		tbl := ss.tableMap[tableName]
-		if _, ok := m.tableMap[tableName]; !ok {
-			m.tableSources[tableName] = ss
-			m.tableMap[tableName] = tbl
+		tbl := ss.loaded(tableName)
+		if _, ok := m.tableMap[key]; !ok {
+			m.tableSources[key] = ss
+			m.tableMap[key] = tbl
+		}
+		m.publish(TableAdded, ss.Name, tableName)
+		return
+	}
+	// the table of the source replaced, ie altered
+	tbl := ss.loaded(tableName)
+	owner := m.tableSources[key]
+	if cur := m.tableMap[key]; tbl != nil && cur != nil && cur != tbl && owner == ss {
+		m.tableMap[key] = tbl
+		m.publish(TableAltered, ss.Name, tableName)
+		return
+	}
+	// a table of the same name of another source, the table of the name is
+	// that of the source added first, whichever source lists it first
+	if owner != nil && owner != ss && tbl != nil {
+		if ss.order < owner.order {
+			u.Debugf("table %q of sources %q and %q, unqualified of %q", tableName, owner.Name, ss.Name, ss.Name)
+			m.tableSources[key] = ss
+			m.tableMap[key] = tbl
+			m.publish(TableAltered, ss.Name, tableName)
+		}
+	}
+}
+
+// RemoveTable remove the table of name from this schema and its source
+func (m *Schema) RemoveTable(tableName string) error {
+	tableName = strings.ToLower(tableName)
+	m.mu.RLock()
+	ss, ok := m.tableSources[tableName]
+	m.mu.RUnlock()
+	if !ok {
+		return ErrNotFound
+	}
+	ss.removeTable(tableName)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeTableUnlocked(tableName)
+	return nil
+}
+func (m *Schema) removeTableUnlocked(tableName string) {
+	ss := m.tableSources[tableName]
+	delete(m.tableSources, tableName)
+	delete(m.tableMap, tableName)
+	for i, name := range m.tableNames {
+		if strings.ToLower(name) == tableName {
+			m.tableNames = append(m.tableNames[:i:i], m.tableNames[i+1:]...)
+			break
 		}
 	}
+	source := ""
+	if ss != nil {
+		source = ss.Name
+	}
+	m.publish(TableRemoved, source, tableName)
 }
 func (m *Schema) addTable(tbl *Table) {
 	//u.Infof("add table %+v", tbl)
@@ -351,8 +551,60 @@ func (m *Schema) addTable(tbl *Table) {
 	m.addTableNameUnlocked(tbl.Name, tbl.SchemaSource)
 }
 
+// AddView register a view on this schema, replacing any existing view of same name
+func (m *Schema) AddView(v *View) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, exists := m.views[v.Name]
+	m.views[v.Name] = v
+	if exists {
+		m.publish(TableAltered, "", v.Name)
+	} else {
+		m.publish(TableAdded, "", v.Name)
+	}
+}
+
+// RemoveView remove the view of name from this schema
+func (m *Schema) RemoveView(name string) error {
+	name = strings.ToLower(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.views[name]; !ok {
+		return ErrNotFound
+	}
+	delete(m.views, name)
+	m.publish(TableRemoved, "", name)
+	return nil
+}
+
+// View get a view by name, nil and false if not found
+func (m *Schema) View(name string) (*View, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.views[strings.ToLower(name)]
+	return v, ok
+}
+
+// Views list of all views registered on this schema
+func (m *Schema) Views() []*View {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.views))
+	for name := range m.views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	views := make([]*View, len(names))
+	for i, name := range names {
+		views[i] = m.views[name]
+	}
+	return views
+}
+
 // Is this schema object within time window described by @dur time ago ?
 func (m *Schema) Since(dur time.Duration) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	if m.lastRefreshed.IsZero() {
 		return false
 	}
@@ -372,43 +624,79 @@ func NewSchemaSource(name, sourceType string) *SchemaSource {
 	return m
 }
 func (m *SchemaSource) Schema() *Schema {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.schema
 }
+
+// AddTableName add a table of this source by name, loading it of the store
+// or the source if not already.  Loaded without holding the lock of this
+// source, and once of concurrent adds of the same table.
 func (m *SchemaSource) AddTableName(tableName string) {
+	if !m.loads(tableName) {
+		return
+	}
+	m.mu.RLock()
+	_, known := m.tableMap[tableName]
+	m.mu.RUnlock()
+	var tbl *Table
+	if !known {
+		tbl, _ = m.loadTable(tableName)
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if _, ok := m.tableMap[tableName]; !ok {
+		m.tableMap[tableName] = nil
+		if tbl != nil {
+			m.tableMap[tbl.Name] = tbl
+		}
+	}
 	m.addTableNameUnlocked(tableName)
 }
-func (m *SchemaSource) addTableNameUnlocked(tableName string) {
 
-	// check if we only want to load certain tables from this source
+// loads does this source load the table of name, of its Conf.TablesToLoad
+func (m *SchemaSource) loads(tableName string) bool {
+	if m.Conf == nil || len(m.Conf.TablesToLoad) == 0 {
+		return true
+	}
 	lowerTable := strings.ToLower(tableName)
-	if len(m.Conf.TablesToLoad) > 0 {
-		loadTable := false
-		for _, tblToLoad := range m.Conf.TablesToLoad {
-			if strings.ToLower(tblToLoad) == lowerTable {
-				loadTable = true
-				break
-			}
-		}
-		if !loadTable {
-			return
+	for _, tblToLoad := range m.Conf.TablesToLoad {
+		if strings.ToLower(tblToLoad) == lowerTable {
+			return true
 		}
 	}
+	return false
+}
 
+func (m *SchemaSource) addTableNameUnlocked(tableName string) {
 	// see if we already have this table
-	found := false
 	for _, curTableName := range m.tableNames {
 		if tableName == curTableName {
-			found = true
+			return
 		}
 	}
-	if !found {
-		m.tableNames = append(m.tableNames, tableName)
-		sort.Strings(m.tableNames)
-		if _, ok := m.tableMap[tableName]; !ok {
-			m.tableMap[tableName] = nil
-			m.loadTable(tableName)
+	m.tableNames = append(m.tableNames, tableName)
+	sort.Strings(m.tableNames)
+}
+
+// loaded the table of name loaded, nil if none
+func (m *SchemaSource) loaded(tableName string) *Table {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if tbl := m.tableMap[tableName]; tbl != nil {
+		return tbl
+	}
+	return m.tableMap[strings.ToLower(tableName)]
+}
+
+func (m *SchemaSource) removeTable(tableName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.tableMap, tableName)
+	for i, name := range m.tableNames {
+		if strings.ToLower(name) == tableName {
+			m.tableNames = append(m.tableNames[:i:i], m.tableNames[i+1:]...)
+			break
 		}
 	}
 }
@@ -418,17 +706,14 @@ func (m *SchemaSource) refreshSchema() {
 		//u.Debugf("No DS for Schema?  %#v", m.Name)
 		return
 	}
-	m.mu.Lock()
-	defer m.mu.Unlock()
 	for _, tableName := range m.DS.Tables() {
-		m.addTableNameUnlocked(tableName)
+		m.AddTableName(tableName)
 	}
 }
 func (m *SchemaSource) AddTable(tbl *Table) {
 
 	//u.Debugf("ss:%p AddTable %#v", m, tbl)
 	m.mu.Lock()
-	defer m.mu.Unlock()
 
 	// Does this need to be locked?
 	hash := fnv.New64()
@@ -449,30 +734,38 @@ func (m *SchemaSource) AddTable(tbl *Table) {
 
 	//u.Infof("add table: %v partitionct:%v conf:%+v", tbl.Name, tbl.PartitionCt, m.Conf)
 	m.addTableNameUnlocked(tbl.Name)
-	if m.schema == nil {
+	s := m.schema
+	// the lock of the source is not held adding to the schema, which
+	// refreshes its sources holding its own
+	m.mu.Unlock()
+	if s == nil {
 		panic("schema is required")
 		u.Errorf("ss:%p may not have nil schema", m)
 		return
 	}
-	m.schema.AddTableName(tbl.Name, m)
+	s.AddTableName(tbl.Name, m)
 }
 
-func (m *SchemaSource) loadTable(tableName string) error {
+// loadTable the table of name of the store, else asking the source for it
+// (once of concurrent loads of the same table, see describe)
+func (m *SchemaSource) loadTable(tableName string) (*Table, error) {
 
 	//u.Debugf("ss:%p  find: %v  tableMap:%v", m, tableName, m.tableMap)
 
-	sourceTable, ok := m.DS.(SourceTableSchema)
-	if !ok {
-		u.Warnf("ss:%p ds:%T ds:%p could not find table %q from tables:%v", m, m.DS, m.DS, tableName, m.DS.Tables())
-		return fmt.Errorf("Could not find that table: %v", tableName)
+	m.mu.Lock()
+	if tbl, ok := m.stored[tableName]; ok {
+		// of the definition saved, instead of discovered again
+		delete(m.stored, tableName)
+		tbl.SchemaSource = m
+		m.mu.Unlock()
+		return tbl, nil
 	}
-	tbl, err := sourceTable.Table(tableName)
+	m.mu.Unlock()
+
+	tbl, err := m.describe(tableName)
 	if err != nil {
 		u.Errorf("could not find table %q", tableName)
-		return err
-	}
-	if tbl == nil {
-		return ErrNotFound
+		return nil, err
 	}
 	tbl.SchemaSource = m
 
@@ -485,12 +778,15 @@ func (m *SchemaSource) loadTable(tableName string) error {
 			// }
 		}
 	}
-	//u.Infof("ss:%p about to add table %q", m, tableName)
-	m.tableMap[tbl.Name] = tbl
-	return nil
+	return tbl, nil
 }
 
-func (m *SchemaSource) Tables() []string { return m.tableNames }
+// Tables the names of the tables of this source, sorted
+func (m *SchemaSource) Tables() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.tableNames...)
+}
 func (m *SchemaSource) Table(tableName string) (*Table, error) {
 
 	tableName = strings.ToLower(tableName)
@@ -512,12 +808,18 @@ func (m *SchemaSource) HasTable(table string) bool {
 	return hasTable
 }
 
+// NewView create a view of @name defined by the select statement
+func NewView(name string, stmt *rel.SqlSelect) *View {
+	return &View{Name: strings.ToLower(name), Stmt: stmt}
+}
+
 func NewTable(table string) *Table {
 	t := &Table{
 		Name:         strings.ToLower(table),
 		NameOriginal: table,
 		Fields:       make([]*Field, 0),
 		FieldMap:     make(map[string]*Field),
+		version:      nextGeneration(),
 	}
 	t.SetRefreshed()
 	return t
@@ -551,8 +853,27 @@ func (m *Table) AddField(fld *Field) {
 	if !found {
 		fld.idx = uint64(len(m.Fields))
 		m.Fields = append(m.Fields, fld)
+		if !fld.IsComputed() {
+			// computed columns stay last, after the columns of the source
+			for i := len(m.Fields) - 1; i > 0 && m.Fields[i-1].IsComputed(); i-- {
+				m.Fields[i], m.Fields[i-1] = m.Fields[i-1], m.Fields[i]
+				m.Fields[i].idx, m.Fields[i-1].idx = uint64(i), uint64(i-1)
+			}
+		}
 	}
 	m.FieldMap[fld.Name] = fld
+	m.rows = nil
+	m.Changed()
+}
+
+// PrimaryKey the columns of the primary key index of the table, nil if none
+func (m *Table) PrimaryKey() []string {
+	for _, idx := range m.Indexes {
+		if idx.PrimaryKey {
+			return idx.Fields
+		}
+	}
+	return nil
 }
 
 func (m *Table) AddFieldType(name string, valType value.ValueType) {
@@ -568,6 +889,7 @@ func (m *Table) SetColumns(cols []string) {
 		cols[idx] = col
 	}
 	m.cols = cols
+	m.Changed()
 }
 
 func (m *Table) Columns() []string { return m.cols }
@@ -590,19 +912,31 @@ func (m *Table) SetRows(rows [][]driver.Value) {
 func (m *Table) FieldNamesPositions() map[string]int { return m.FieldPositions }
 
 // Is this schema object current?  ie, have we refreshed it from
-//  source since refresh interval
-func (m *Table) Current() bool { return m.Since(SchemaRefreshInterval) }
+//  source since its refresh interval, see RefreshInterval
+func (m *Table) Current() bool {
+	dur := m.RefreshInterval()
+	if dur == RefreshNever {
+		return !m.Refreshed().IsZero()
+	}
+	return m.Since(-dur)
+}
 
 // update the refreshed date to now
-func (m *Table) SetRefreshed() { m.lastRefreshed = time.Now() }
+func (m *Table) SetRefreshed() {
+	refreshMu.Lock()
+	m.lastRefreshed = time.Now()
+	m.invalid = false
+	refreshMu.Unlock()
+}
 
 // Is this schema object within time window described by @dur time ago ?
 func (m *Table) Since(dur time.Duration) bool {
 	u.Debugf("table?  %+v", m)
-	if m.lastRefreshed.IsZero() {
+	lastRefreshed := m.Refreshed()
+	if lastRefreshed.IsZero() {
 		return false
 	}
-	if m.lastRefreshed.After(time.Now().Add(dur)) {
+	if lastRefreshed.After(time.Now().Add(dur)) {
 		return true
 	}
 	return false
@@ -650,8 +984,14 @@ func (m *Field) AsRow() []driver.Value {
 	m.row[1] = m.Type.String() // should we send this through a dialect-writer?  bc dialect specific?
 	m.row[2] = m.Collation
 	m.row[3] = ""
+	if m.NoNulls {
+		m.row[3] = "NO"
+	}
 	m.row[4] = ""
 	m.row[5] = ""
+	if m.DefaultValue != nil {
+		m.row[5] = fmt.Sprint(m.DefaultValue)
+	}
 	m.row[6] = m.Extra
 	m.row[7] = ""
 	m.row[8] = m.Description // should we put native type in here?
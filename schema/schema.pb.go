@@ -3,14 +3,16 @@
 // DO NOT EDIT!
 
 /*
-	Package schema is a generated protocol buffer package.
+Package schema is a generated protocol buffer package.
 
-	It is generated from these files:
-		schema.proto
+It is generated from these files:
 
-	It has these top-level messages:
-		TablePartition
-		Partition
+	schema.proto
+
+It has these top-level messages:
+
+	TablePartition
+	Partition
 */
 package schema
 
@@ -34,6 +36,8 @@ type TablePartition struct {
 	Table            string       `protobuf:"bytes,1,req,name=table" json:"table"`
 	Keys             []string     `protobuf:"bytes,2,rep,name=keys" json:"keys"`
 	Partitions       []*Partition `protobuf:"bytes,3,rep,name=partitions" json:"partitions"`
+	Method           string       `protobuf:"bytes,4,opt,name=method" json:"method,omitempty"`
+	Buckets          int32        `protobuf:"varint,5,opt,name=buckets" json:"buckets,omitempty"`
 	XXX_unrecognized []byte       `json:"-"`
 }
 
@@ -48,6 +52,8 @@ type Partition struct {
 	Id               string `protobuf:"bytes,1,req,name=id" json:"id"`
 	Left             string `protobuf:"bytes,2,req,name=left" json:"left"`
 	Right            string `protobuf:"bytes,3,req,name=right" json:"right"`
+	Location         string `protobuf:"bytes,4,opt,name=location" json:"location,omitempty"`
+	Source           string `protobuf:"bytes,5,opt,name=source" json:"source,omitempty"`
 	XXX_unrecognized []byte `json:"-"`
 }
 
@@ -105,6 +111,13 @@ func (m *TablePartition) MarshalTo(data []byte) (int, error) {
 			i += n
 		}
 	}
+	data[i] = 0x22
+	i++
+	i = encodeVarintSchema(data, i, uint64(len(m.Method)))
+	i += copy(data[i:], m.Method)
+	data[i] = 0x28
+	i++
+	i = encodeVarintSchema(data, i, uint64(m.Buckets))
 	if m.XXX_unrecognized != nil {
 		i += copy(data[i:], m.XXX_unrecognized)
 	}
@@ -138,6 +151,14 @@ func (m *Partition) MarshalTo(data []byte) (int, error) {
 	i++
 	i = encodeVarintSchema(data, i, uint64(len(m.Right)))
 	i += copy(data[i:], m.Right)
+	data[i] = 0x22
+	i++
+	i = encodeVarintSchema(data, i, uint64(len(m.Location)))
+	i += copy(data[i:], m.Location)
+	data[i] = 0x2a
+	i++
+	i = encodeVarintSchema(data, i, uint64(len(m.Source)))
+	i += copy(data[i:], m.Source)
 	if m.XXX_unrecognized != nil {
 		i += copy(data[i:], m.XXX_unrecognized)
 	}
@@ -188,6 +209,9 @@ func (m *TablePartition) Size() (n int) {
 			n += 1 + l + sovSchema(uint64(l))
 		}
 	}
+	l = len(m.Method)
+	n += 1 + l + sovSchema(uint64(l))
+	n += 1 + sovSchema(uint64(m.Buckets))
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -203,6 +227,10 @@ func (m *Partition) Size() (n int) {
 	n += 1 + l + sovSchema(uint64(l))
 	l = len(m.Right)
 	n += 1 + l + sovSchema(uint64(l))
+	l = len(m.Location)
+	n += 1 + l + sovSchema(uint64(l))
+	l = len(m.Source)
+	n += 1 + l + sovSchema(uint64(l))
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -342,6 +370,54 @@ func (m *TablePartition) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Method", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSchema
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthSchema
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Method = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Buckets", wireType)
+			}
+			m.Buckets = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSchema
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.Buckets |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipSchema(data[iNdEx:])
@@ -487,6 +563,64 @@ func (m *Partition) Unmarshal(data []byte) error {
 			m.Right = string(data[iNdEx:postIndex])
 			iNdEx = postIndex
 			hasFields[0] |= uint64(0x00000004)
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Location", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSchema
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthSchema
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Location = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Source", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSchema
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthSchema
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Source = string(data[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipSchema(data[iNdEx:])
@@ -1 +1,447 @@
 package schema_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+// slowSource a source of one table, counting the asks for its schema
+type slowSource struct {
+	asked int
+}
+
+func (m *slowSource) Tables() []string                       { return []string{"users"} }
+func (m *slowSource) Open(table string) (schema.Conn, error) { return nil, schema.ErrNotImplemented }
+func (m *slowSource) Close() error                           { return nil }
+func (m *slowSource) Table(table string) (*schema.Table, error) {
+	m.asked++
+	tbl := schema.NewTable(table)
+	tbl.AddField(schema.NewFieldBase("id", value.IntType, 64, "user id"))
+	tbl.AddField(schema.NewField("name", value.StringType, 255, schema.NoNulls, "anon", "", "utf8", ""))
+	tbl.SetColumns([]string{"id", "name"})
+	return tbl, nil
+}
+
+func newSchema(ds schema.Source) (*schema.Schema, *schema.SchemaSource) {
+	s := schema.NewSchema("users")
+	ss := schema.NewSchemaSource("users", "slow")
+	ss.DS = ds
+	s.AddSourceSchema(ss)
+	return s, ss
+}
+
+func TestSchemaStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qlbridge-schema")
+	assert.T(t, err == nil)
+	defer os.RemoveAll(dir)
+	store, err := schema.NewFileStore(dir)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	_, err = store.Load("users")
+	assert.Equal(t, schema.ErrNotFound, err)
+
+	ds := &slowSource{}
+	s, _ := newSchema(ds)
+	s.SetStore(store)
+	s.RefreshSchema()
+	assert.Equal(t, 1, ds.asked)
+	assert.Tf(t, s.Save() == nil, "saved")
+
+	def, err := store.Load("users")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, "users", def.Name)
+	assert.T(t, !def.Saved.IsZero())
+	sd := def.Source("users")
+	assert.Equal(t, "slow", sd.Type)
+	assert.Equal(t, 1, len(sd.Tables))
+	assert.Equal(t, []string{"id", "name"}, sd.Tables[0].Columns)
+
+	// restarted, the table is that of the store
+	ds = &slowSource{}
+	s, ss := newSchema(ds)
+	ss.LoadDef(sd)
+	s.RefreshSchema()
+	assert.Equal(t, 0, ds.asked)
+	tbl, err := s.Table("users")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, []string{"id", "name"}, tbl.Columns())
+	assert.Equal(t, value.IntType, tbl.FieldMap["id"].Type)
+	assert.Equal(t, "user id", tbl.FieldMap["id"].Description)
+	name := tbl.FieldMap["name"]
+	assert.Tf(t, name.NoNulls && name.DefaultValue == "anon" && name.Length == 255, "%+v", name)
+	assert.Equal(t, ss, tbl.SchemaSource)
+	assert.T(t, tbl.Current())
+
+	assert.T(t, store.Delete("users") == nil)
+	_, err = store.Load("users")
+	assert.Equal(t, schema.ErrNotFound, err)
+	assert.T(t, store.Delete("users") == nil)
+}
+
+func TestSchemaEvents(t *testing.T) {
+	s := schema.NewSchema("events")
+	sub := s.Subscribe()
+
+	ss := schema.NewSchemaSource("users", "slow")
+	ss.DS = &slowSource{}
+	s.AddSourceSchema(ss)
+	s.RefreshSchema()
+	// refreshed again, nothing changed
+	s.RefreshSchema()
+
+	altered := schema.NewTable("users")
+	altered.AddField(schema.NewFieldBase("id", value.StringType, 64, "user id"))
+	altered.SetColumns([]string{"id"})
+	ss.AddTable(altered)
+	tbl, err := s.Table("users")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, altered, tbl)
+
+	assert.T(t, s.RemoveTable("users") == nil)
+	assert.Equal(t, 0, len(s.Tables()))
+	assert.Equal(t, 0, len(ss.Tables()))
+	assert.Equal(t, schema.ErrNotFound, s.RemoveTable("users"))
+
+	s.RefreshSchema()
+	assert.T(t, s.RemoveSourceSchema("users") == nil)
+	assert.Equal(t, 0, len(s.Tables()))
+	assert.T(t, sub.Close() == nil)
+
+	events := make([]string, 0)
+	for ev := range sub.Events() {
+		assert.Equal(t, "events", ev.Schema)
+		assert.Equal(t, "users", ev.Source)
+		events = append(events, ev.Type.String()+" "+ev.Table)
+	}
+	assert.Equal(t, []string{
+		"source_added ",
+		"table_added users",
+		"table_altered users",
+		"table_removed users",
+		"table_added users",
+		"table_removed users",
+		"source_removed ",
+	}, events)
+	assert.Equal(t, nil, sub.Err())
+
+	// a subscriber not keeping up is closed
+	schema.EventBufferSize = 1
+	defer func() { schema.EventBufferSize = 256 }()
+	slow := s.Subscribe()
+	s.AddSourceSchema(ss)
+	s.RefreshSchema()
+	_, open := <-slow.Events()
+	assert.T(t, open)
+	_, open = <-slow.Events()
+	assert.T(t, !open)
+	assert.Equal(t, schema.ErrSlowEvents, slow.Err())
+}
+
+func TestSchemaViews(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qlbridge-views")
+	assert.T(t, err == nil)
+	defer os.RemoveAll(dir)
+	store, err := schema.NewFileStore(dir)
+	assert.Tf(t, err == nil, "no error %v", err)
+
+	s, _ := newSchema(&slowSource{})
+	s.SetStore(store)
+	sub := s.Subscribe()
+	stmt, err := rel.ParseSqlSelect(`SELECT id, name FROM users WHERE id > 10`)
+	assert.Tf(t, err == nil, "no error %v", err)
+	s.AddView(schema.NewView("new_users", stmt))
+	s.AddView(schema.NewView("new_users", stmt))
+	assert.T(t, s.Save() == nil)
+
+	def, err := store.Load("users")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, 1, len(def.Views))
+	assert.Equal(t, "new_users", def.Views[0].Name)
+	assert.Equal(t, stmt.String(), def.Views[0].Sql)
+
+	assert.T(t, s.RemoveView("new_users") == nil)
+	assert.Equal(t, schema.ErrNotFound, s.RemoveView("new_users"))
+	assert.T(t, sub.Close() == nil)
+	events := make([]string, 0)
+	for ev := range sub.Events() {
+		assert.Equal(t, "", ev.Source)
+		events = append(events, ev.Type.String()+" "+ev.Table)
+	}
+	assert.Equal(t, []string{"table_added new_users", "table_altered new_users", "table_removed new_users"}, events)
+
+	// restarted, the views are those of the store
+	s, _ = newSchema(&slowSource{})
+	assert.T(t, s.LoadViews(def) == nil)
+	v, ok := s.View("new_users")
+	assert.T(t, ok)
+	assert.Equal(t, stmt.String(), v.Stmt.String())
+}
+
+func TestSchemaVersion(t *testing.T) {
+	s, ss := newSchema(&slowSource{})
+	v := s.Version()
+	assert.T(t, v > 0)
+	assert.Equal(t, v, s.Version())
+
+	sub := s.Subscribe()
+	s.RefreshSchema()
+	assert.T(t, s.Version() > v)
+	ev := <-sub.Events()
+	assert.Equal(t, s.Version(), ev.Version)
+	sub.Close()
+
+	// a change of a table is a change of its schema
+	tbl, err := s.Table("users")
+	assert.Tf(t, err == nil, "no error %v", err)
+	v, tv := s.Version(), tbl.Version()
+	tbl.AddFieldType("email", value.StringType)
+	assert.T(t, tbl.Version() > tv)
+	assert.T(t, s.Version() > v)
+
+	v, tv = s.Version(), tbl.Version()
+	tbl.SetStats(map[string]*schema.ColumnStats{"id": {RowCount: 10}})
+	assert.T(t, tbl.Version() > tv)
+	assert.T(t, s.Version() > v)
+
+	// a table replacing another of its name is newer than it
+	altered := schema.NewTable("users")
+	altered.AddFieldType("id", value.IntType)
+	ss.AddTable(altered)
+	tbl, _ = s.Table("users")
+	assert.T(t, tbl == altered)
+	assert.T(t, altered.Version() > tv)
+
+	// no change, same version
+	v = s.Version()
+	_, err = s.Table("users")
+	assert.T(t, err == nil)
+	assert.Equal(t, v, s.Version())
+}
+
+func TestSchemaRefresh(t *testing.T) {
+	ds := &slowSource{}
+	s, ss := newSchema(ds)
+	s.RefreshSchema()
+	assert.Equal(t, 1, ds.asked)
+	tbl, err := s.Table("users")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, -schema.SchemaRefreshInterval, tbl.RefreshInterval())
+	assert.T(t, tbl.Current())
+	assert.Equal(t, 0, len(s.RefreshStale()))
+
+	// a source refreshing often
+	ss.Conf.Refresh = "1ns"
+	time.Sleep(time.Millisecond)
+	assert.Equal(t, time.Nanosecond, tbl.RefreshInterval())
+	assert.T(t, !tbl.Current())
+	tbl.SetStats(map[string]*schema.ColumnStats{"id": {RowCount: 10}})
+	assert.Equal(t, []string{"users"}, s.RefreshStale())
+	assert.Equal(t, 2, ds.asked)
+	refreshed, _ := s.Table("users")
+	assert.T(t, refreshed != tbl)
+	assert.T(t, refreshed.Version() > tbl.Version())
+	rows, ok := refreshed.RowCount()
+	assert.Tf(t, ok && rows == 10, "stats kept %v", rows)
+
+	// but its table never
+	ss.Conf.TableRefresh = map[string]string{"users": "never"}
+	time.Sleep(time.Millisecond)
+	assert.Equal(t, schema.RefreshNever, refreshed.RefreshInterval())
+	assert.T(t, refreshed.Current())
+	assert.Equal(t, 0, len(s.RefreshStale()))
+	assert.Equal(t, 2, ds.asked)
+
+	// unless invalidated, reloaded on next use
+	assert.T(t, s.Invalidate("users") == nil)
+	assert.T(t, refreshed.Invalid())
+	assert.Equal(t, 2, ds.asked)
+	tbl, err = s.Table("users")
+	assert.Tf(t, err == nil, "no error %v", err)
+	assert.Equal(t, 3, ds.asked)
+	assert.T(t, tbl != refreshed && !tbl.Invalid() && tbl.Current())
+	assert.Equal(t, schema.ErrNotFound, s.Invalidate("nope"))
+
+	assert.T(t, s.RefreshNow("users") == nil)
+	assert.Equal(t, 4, ds.asked)
+	assert.Equal(t, schema.ErrNotFound, s.RefreshNow("nope"))
+}
+
+// blockingSource a source of a table described once released, counting
+// the asks for it
+type blockingSource struct {
+	asked   int32
+	release chan struct{}
+}
+
+func (m *blockingSource) Tables() []string { return []string{"users"} }
+func (m *blockingSource) Open(table string) (schema.Conn, error) {
+	return nil, schema.ErrNotImplemented
+}
+func (m *blockingSource) Close() error { return nil }
+func (m *blockingSource) Table(table string) (*schema.Table, error) {
+	atomic.AddInt32(&m.asked, 1)
+	<-m.release
+	tbl := schema.NewTable(table)
+	tbl.AddFieldType("id", value.IntType)
+	tbl.SetColumns([]string{"id"})
+	return tbl, nil
+}
+
+func TestSchemaConcurrentLoads(t *testing.T) {
+	ds := &blockingSource{release: make(chan struct{})}
+	s, ss := newSchema(ds)
+	close(ds.release)
+	s.RefreshSchema()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&ds.asked))
+
+	// a burst of queries of a table invalidated asks the source once
+	ds.release = make(chan struct{})
+	assert.T(t, s.Invalidate("users") == nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tbl, err := s.Table("users")
+			assert.Tf(t, err == nil && tbl != nil, "no error %v", err)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(ds.release)
+	wg.Wait()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&ds.asked))
+
+	// and refreshes do not race the queries of the schema
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.RefreshSchema()
+			ss.AddTableName("users")
+		}()
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, []string{"users"}, s.Tables())
+			_, err := s.Table("users")
+			assert.T(t, err == nil)
+		}()
+	}
+	wg.Wait()
+}
+
+// casedSource a source of a table named in mixed case
+type casedSource struct{}
+
+func (m *casedSource) Tables() []string                       { return []string{"UserEvents"} }
+func (m *casedSource) Open(table string) (schema.Conn, error) { return nil, schema.ErrNotImplemented }
+func (m *casedSource) Close() error                           { return nil }
+func (m *casedSource) Table(table string) (*schema.Table, error) {
+	tbl := schema.NewTable(table)
+	tbl.AddFieldType("userId", value.StringType)
+	tbl.SetColumns([]string{"userId"})
+	return tbl, nil
+}
+
+func TestIdentifierCase(t *testing.T) {
+	s, _ := newSchema(&casedSource{})
+	s.RefreshSchema()
+	assert.Equal(t, schema.CaseFold, s.IdentifierCase())
+
+	tests := []struct {
+		ic      schema.IdentifierCase
+		table   string
+		found   bool
+		column  string
+		colOk   bool
+		display string
+	}{
+		{schema.CaseFold, "userevents", true, "USERID", true, "userevents"},
+		{schema.CaseFold, "UserEvents", true, "userId", true, "userevents"},
+		{schema.CaseExact, "UserEvents", true, "userId", true, "UserEvents"},
+		{schema.CaseExact, "userevents", false, "userid", false, "UserEvents"},
+		{schema.CaseInsensitive, "USEREVENTS", true, "userid", true, "UserEvents"},
+	}
+	for _, tt := range tests {
+		s.SetIdentifierCase(tt.ic)
+		tbl, err := s.Table(tt.table)
+		assert.Equalf(t, tt.found, err == nil, "%s %q: %v", tt.ic, tt.table, err)
+		_, err = s.Source(tt.table)
+		assert.Equalf(t, tt.found, err == nil, "%s source %q: %v", tt.ic, tt.table, err)
+		if tbl == nil {
+			tbl, _ = s.Table("UserEvents")
+		}
+		_, ok := tbl.Field(tt.column)
+		assert.Equalf(t, tt.colOk, ok, "%s column %q", tt.ic, tt.column)
+		assert.Equal(t, tt.display, tt.ic.Name(tbl))
+	}
+
+	ic, err := schema.ParseIdentifierCase("Exact")
+	assert.T(t, err == nil && ic == schema.CaseExact)
+	_, err = schema.ParseIdentifierCase("upper")
+	assert.T(t, err != nil)
+}
+
+// partialSource a slowSource also listing a table it can not describe
+type partialSource struct {
+	slowSource
+}
+
+func (m *partialSource) Tables() []string { return []string{"users", "broken"} }
+func (m *partialSource) Table(table string) (*schema.Table, error) {
+	if table == "broken" {
+		return nil, schema.ErrNotFound
+	}
+	return m.slowSource.Table(table)
+}
+
+func TestSchemaDescribe(t *testing.T) {
+	s, ss := newSchema(&partialSource{})
+	ss.Alias = "u"
+	s.RefreshSchema()
+	tbl, err := s.Table("users")
+	assert.Tf(t, err == nil, "no error %v", err)
+	tbl.Indexes = append(tbl.Indexes, &schema.Index{Name: "PRIMARY", Fields: []string{"id"}, PrimaryKey: true})
+	tbl.SetStats(map[string]*schema.ColumnStats{"id": {RowCount: 10, Distinct: 10}})
+	stmt, err := rel.ParseSqlSelect(`SELECT id FROM users WHERE id > 5`)
+	assert.Tf(t, err == nil, "no error %v", err)
+	s.AddView(schema.NewView("recent", stmt))
+
+	doc := s.Describe()
+	assert.Equal(t, "users", doc.Name)
+	assert.Equal(t, s.Version(), doc.Version)
+	assert.Equal(t, "fold", doc.IdentifierCase)
+	assert.Equal(t, 1, len(doc.Sources))
+	src := doc.Sources[0]
+	assert.Equal(t, "slow", src.Type)
+	assert.Equal(t, "u", src.Alias)
+	assert.Equal(t, []string{"broken"}, src.Unloaded)
+	assert.Equal(t, 1, len(src.Tables))
+	td := src.Tables[0]
+	assert.Equal(t, tbl.Version(), td.Version)
+	assert.Equal(t, int64(10), td.Rows)
+	assert.Equal(t, []string{"id", "name"}, td.Columns)
+	assert.Equal(t, "anon", td.Fields[1].DefaultValue)
+	assert.Equal(t, "PRIMARY", td.Indexes[0].Name)
+	assert.Equal(t, "recent", doc.Views[0].Name)
+
+	// the document round trips json
+	by, err := json.Marshal(doc)
+	assert.Tf(t, err == nil, "no error %v", err)
+	got := &schema.SchemaDescription{}
+	assert.Tf(t, json.Unmarshal(by, got) == nil, "unmarshal %s", by)
+	assert.Equal(t, doc.Version, got.Version)
+	assert.Equal(t, "users", got.Sources[0].Tables[0].Name)
+	assert.Equal(t, int64(10), got.Sources[0].Tables[0].Fields[0].Stats.Distinct)
+	assert.Equal(t, tbl.Version(), got.Sources[0].Tables[0].Version)
+}
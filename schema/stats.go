@@ -0,0 +1,411 @@
+package schema
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/araddon/qlbridge/lex"
+)
+
+var (
+	// StatsHistogramBuckets buckets of the equi-depth histograms of the
+	// columns analyzed, 0 for none
+	StatsHistogramBuckets = 16
+
+	// StatsSampleSize values of a column sampled (reservoir) for its
+	// histogram
+	StatsSampleSize = 10000
+
+	// statsMu guards the Stats of the fields of all tables, replaced whole
+	// by ANALYZE while planners read them
+	statsMu sync.RWMutex
+)
+
+type (
+	// ColumnStats statistics of the values of a column, of the last ANALYZE
+	// TABLE (or background refresh) of its table, used by the planner to
+	// estimate the rows of a source.  Min, Max and the bounds of the
+	// Histogram are nil for columns of values that do not order (or of
+	// mixed types).
+	ColumnStats struct {
+		RowCount  int64              `json:"rows"`
+		NullCount int64              `json:"nulls"`
+		Distinct  int64              `json:"ndv"` // number of distinct non-null values
+		Min       driver.Value       `json:"min,omitempty"`
+		Max       driver.Value       `json:"max,omitempty"`
+		Histogram []*HistogramBucket `json:"histogram,omitempty"`
+		Analyzed  time.Time          `json:"analyzed"`
+	}
+
+	// HistogramBucket a bucket of an equi-depth histogram, of the non-null
+	// values <= Upper and > the Upper of the bucket before
+	HistogramBucket struct {
+		Upper driver.Value `json:"upper"`
+		Count int64        `json:"count"`
+	}
+
+	// StatsBuilder computes the ColumnStats of the columns of a table of its
+	// rows, one Add per row.  Distinct values are counted exactly.
+	StatsBuilder struct {
+		cols  []string
+		rows  int64
+		stats []*columnBuilder
+		rnd   *rand.Rand
+	}
+
+	columnBuilder struct {
+		nulls    int64
+		seen     int64
+		distinct map[interface{}]struct{}
+		min, max driver.Value
+		ordered  bool
+		sample   []driver.Value
+	}
+)
+
+// NewStatsBuilder a StatsBuilder of the values of rows of cols, in order
+func NewStatsBuilder(cols []string) *StatsBuilder {
+	m := &StatsBuilder{cols: cols, stats: make([]*columnBuilder, len(cols)), rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	for i := range cols {
+		m.stats[i] = &columnBuilder{distinct: make(map[interface{}]struct{}), ordered: true}
+	}
+	return m
+}
+
+// Add the values of a row, in the order of the columns of the builder
+func (m *StatsBuilder) Add(row []driver.Value) {
+	m.rows++
+	for i, cb := range m.stats {
+		if i >= len(row) || row[i] == nil {
+			cb.nulls++
+			continue
+		}
+		v := row[i]
+		cb.seen++
+		cb.distinct[statsKey(v)] = struct{}{}
+		if !cb.ordered {
+			continue
+		}
+		if cb.min == nil {
+			cb.min, cb.max = v, v
+		} else {
+			lo, ok1 := compareStats(v, cb.min)
+			hi, ok2 := compareStats(v, cb.max)
+			if !ok1 || !ok2 {
+				cb.ordered, cb.min, cb.max, cb.sample = false, nil, nil, nil
+				continue
+			}
+			if lo < 0 {
+				cb.min = v
+			}
+			if hi > 0 {
+				cb.max = v
+			}
+		}
+		if len(cb.sample) < StatsSampleSize {
+			cb.sample = append(cb.sample, v)
+		} else if j := m.rnd.Int63n(cb.seen); j < int64(StatsSampleSize) {
+			cb.sample[j] = v
+		}
+	}
+}
+
+// Stats the ColumnStats of the rows added, by column name
+func (m *StatsBuilder) Stats() map[string]*ColumnStats {
+	now := time.Now()
+	stats := make(map[string]*ColumnStats, len(m.cols))
+	for i, col := range m.cols {
+		cb := m.stats[i]
+		cs := &ColumnStats{
+			RowCount:  m.rows,
+			NullCount: cb.nulls,
+			Distinct:  int64(len(cb.distinct)),
+			Min:       cb.min,
+			Max:       cb.max,
+			Analyzed:  now,
+		}
+		if cb.ordered && len(cb.sample) > 0 && StatsHistogramBuckets > 0 {
+			cs.Histogram = histogram(cb.sample, cb.seen)
+		}
+		stats[col] = cs
+	}
+	return stats
+}
+
+// histogram the equi-depth histogram of the sorted sample of seen values
+func histogram(sample []driver.Value, seen int64) []*HistogramBucket {
+	sort.Slice(sample, func(i, j int) bool {
+		c, _ := compareStats(sample[i], sample[j])
+		return c < 0
+	})
+	n := len(sample)
+	buckets := StatsHistogramBuckets
+	if buckets > n {
+		buckets = n
+	}
+	hist := make([]*HistogramBucket, 0, buckets)
+	start := 0
+	for i := 1; i <= buckets; i++ {
+		end := i * n / buckets
+		// values equal to the upper bound stay in the same bucket
+		for end < n {
+			if c, _ := compareStats(sample[end], sample[end-1]); c != 0 {
+				break
+			}
+			end++
+		}
+		if end <= start {
+			continue
+		}
+		hist = append(hist, &HistogramBucket{
+			Upper: sample[end-1],
+			Count: int64(end-start) * seen / int64(n),
+		})
+		start = end
+		if end == n {
+			break
+		}
+	}
+	return hist
+}
+
+// Selectivity estimated fraction of the rows of the table with the column
+// compared by op (=, !=, <, <=, >, >=) to v, 1 if the stats can not tell.
+//
+//   sel := tbl.ColumnStats("age").Selectivity(lex.TokenGT, 21)
+func (m *ColumnStats) Selectivity(op lex.TokenType, v driver.Value) float64 {
+	if m == nil || m.RowCount == 0 || v == nil {
+		return 1
+	}
+	nonNull := float64(m.RowCount-m.NullCount) / float64(m.RowCount)
+	eq := 0.0
+	if m.Distinct > 0 {
+		eq = nonNull / float64(m.Distinct)
+	}
+	if m.Min != nil {
+		lo, ok1 := compareStats(v, m.Min)
+		hi, ok2 := compareStats(v, m.Max)
+		if ok1 && ok2 && (lo < 0 || hi > 0) {
+			eq = 0
+		}
+	}
+	switch op {
+	case lex.TokenEqual, lex.TokenEqualEqual:
+		return eq
+	case lex.TokenNE:
+		return nonNull - eq
+	case lex.TokenLT, lex.TokenLE, lex.TokenGT, lex.TokenGE:
+		below, ok := m.fractionBelow(v)
+		if !ok {
+			return nonNull / 3
+		}
+		if op == lex.TokenLE {
+			below += eq
+		}
+		if op == lex.TokenLT || op == lex.TokenLE {
+			return clampFraction(below, nonNull)
+		}
+		above := nonNull - below - eq
+		if op == lex.TokenGE {
+			above += eq
+		}
+		return clampFraction(above, nonNull)
+	}
+	return 1
+}
+
+// fractionBelow estimated fraction of the rows with values < v, of the
+// histogram, else interpolated between min and max of numbers
+func (m *ColumnStats) fractionBelow(v driver.Value) (float64, bool) {
+	if len(m.Histogram) > 0 {
+		total, below := int64(0), 0.0
+		for _, b := range m.Histogram {
+			total += b.Count
+		}
+		if total == 0 {
+			return 0, false
+		}
+		for _, b := range m.Histogram {
+			c, ok := compareStats(v, b.Upper)
+			if !ok {
+				return 0, false
+			}
+			if c <= 0 {
+				// about half of the bucket v falls in is below it
+				below += float64(b.Count) / 2
+				break
+			}
+			below += float64(b.Count)
+		}
+		return below / float64(m.RowCount), true
+	}
+	lo, ok1 := toStatsFloat(m.Min)
+	hi, ok2 := toStatsFloat(m.Max)
+	f, ok3 := toStatsFloat(v)
+	if !ok1 || !ok2 || !ok3 || hi <= lo {
+		return 0, false
+	}
+	nonNull := float64(m.RowCount-m.NullCount) / float64(m.RowCount)
+	return nonNull * (f - lo) / (hi - lo), true
+}
+
+func clampFraction(f, max float64) float64 {
+	switch {
+	case f < 0:
+		return 0
+	case f > max:
+		return max
+	}
+	return f
+}
+
+// SetStats replace the Stats of the fields of this table, of stats by
+// field name
+func (m *Table) SetStats(stats map[string]*ColumnStats) {
+	statsMu.Lock()
+	for _, f := range m.Fields {
+		if cs, ok := stats[f.Name]; ok {
+			f.Stats = cs
+		}
+	}
+	statsMu.Unlock()
+	m.Changed()
+}
+
+// ColumnStats the stats of the column of name, nil if not analyzed
+func (m *Table) ColumnStats(name string) *ColumnStats {
+	f, ok := m.FieldMap[name]
+	if !ok {
+		return nil
+	}
+	statsMu.RLock()
+	defer statsMu.RUnlock()
+	return f.Stats
+}
+
+// ColumnStats the stats of this field, nil if not analyzed
+func (m *Field) ColumnStats() *ColumnStats {
+	statsMu.RLock()
+	defer statsMu.RUnlock()
+	return m.Stats
+}
+
+// RowCount the rows of this table when last analyzed, false if it has not
+// been
+func (m *Table) RowCount() (int64, bool) {
+	statsMu.RLock()
+	defer statsMu.RUnlock()
+	for _, f := range m.Fields {
+		if f.Stats != nil {
+			return f.Stats.RowCount, true
+		}
+	}
+	return 0, false
+}
+
+// Analyzed when the stats of this table were last computed, zero if never
+func (m *Table) Analyzed() time.Time {
+	statsMu.RLock()
+	defer statsMu.RUnlock()
+	for _, f := range m.Fields {
+		if f.Stats != nil {
+			return f.Stats.Analyzed
+		}
+	}
+	return time.Time{}
+}
+
+// statsKey the key of v counted as distinct, the same of equal numbers of
+// different types
+func statsKey(v driver.Value) interface{} {
+	if f, ok := toStatsFloat(v); ok {
+		return f
+	}
+	switch vt := v.(type) {
+	case string:
+		return vt
+	case []byte:
+		return string(vt)
+	case time.Time:
+		return vt.UnixNano()
+	case bool:
+		return vt
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// compareStats -1, 0, 1 of a <, ==, > b, false if they do not compare
+func compareStats(a, b driver.Value) (int, bool) {
+	if fa, ok := toStatsFloat(a); ok {
+		fb, ok := toStatsFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	switch at := a.(type) {
+	case string:
+		bt, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case at < bt:
+			return -1, true
+		case at > bt:
+			return 1, true
+		}
+		return 0, true
+	case time.Time:
+		bt, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case at.Before(bt):
+			return -1, true
+		case at.After(bt):
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func toStatsFloat(v driver.Value) (float64, bool) {
+	switch vt := v.(type) {
+	case int:
+		return float64(vt), true
+	case int8:
+		return float64(vt), true
+	case int16:
+		return float64(vt), true
+	case int32:
+		return float64(vt), true
+	case int64:
+		return float64(vt), true
+	case uint8:
+		return float64(vt), true
+	case uint16:
+		return float64(vt), true
+	case uint32:
+		return float64(vt), true
+	case uint64:
+		return float64(vt), true
+	case float32:
+		return float64(vt), true
+	case float64:
+		return vt, true
+	}
+	return 0, false
+}
@@ -0,0 +1,96 @@
+package schema_test
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/bmizerany/assert"
+
+	"github.com/araddon/qlbridge/lex"
+	"github.com/araddon/qlbridge/schema"
+	"github.com/araddon/qlbridge/value"
+)
+
+func TestColumnStats(t *testing.T) {
+	tbl := schema.NewTable("users")
+	tbl.AddField(schema.NewFieldBase("id", value.IntType, 64, ""))
+	tbl.AddField(schema.NewFieldBase("name", value.StringType, 64, ""))
+	tbl.AddField(schema.NewFieldBase("mixed", value.StringType, 64, ""))
+	tbl.SetColumns([]string{"id", "name", "mixed"})
+
+	_, ok := tbl.RowCount()
+	assert.T(t, !ok)
+	assert.T(t, tbl.Analyzed().IsZero())
+
+	b := schema.NewStatsBuilder(tbl.Columns())
+	for i := 0; i < 100; i++ {
+		var name, mixed driver.Value
+		if i%4 != 0 {
+			name = []string{"alice", "bob", "carol"}[i%3]
+		}
+		if i%2 == 0 {
+			mixed = int64(i)
+		} else {
+			mixed = "x"
+		}
+		b.Add([]driver.Value{int64(i), name, mixed})
+	}
+	tbl.SetStats(b.Stats())
+
+	rows, ok := tbl.RowCount()
+	assert.T(t, ok)
+	assert.Equal(t, int64(100), rows)
+	assert.T(t, !tbl.Analyzed().IsZero())
+
+	id := tbl.ColumnStats("id")
+	assert.Tf(t, id.NullCount == 0 && id.Distinct == 100, "%+v", id)
+	assert.Equal(t, int64(0), id.Min)
+	assert.Equal(t, int64(99), id.Max)
+	assert.Equal(t, 16, len(id.Histogram))
+	total := int64(0)
+	for _, bucket := range id.Histogram {
+		total += bucket.Count
+	}
+	assert.Equal(t, int64(100), total)
+
+	name := tbl.ColumnStats("name")
+	assert.Tf(t, name.NullCount == 25 && name.Distinct == 3, "%+v", name)
+	assert.Equal(t, "alice", name.Min)
+	assert.Equal(t, "carol", name.Max)
+
+	// values that do not order have no min, max or histogram
+	mixed := tbl.ColumnStats("mixed")
+	assert.Tf(t, mixed.Distinct == 51 && mixed.Min == nil && len(mixed.Histogram) == 0, "%+v", mixed)
+	assert.Equal(t, (*schema.ColumnStats)(nil), tbl.ColumnStats("nope"))
+
+	tests := []struct {
+		stats *schema.ColumnStats
+		op    lex.TokenType
+		v     driver.Value
+		lo    float64
+		hi    float64
+	}{
+		{id, lex.TokenEqual, int64(5), 0.01, 0.01},
+		{id, lex.TokenEqual, int64(500), 0, 0},
+		{id, lex.TokenNE, int64(5), 0.99, 0.99},
+		{id, lex.TokenLT, int64(50), 0.4, 0.6},
+		{id, lex.TokenGE, int64(90), 0.05, 0.15},
+		{id, lex.TokenGT, int64(500), 0, 0},
+		{name, lex.TokenEqual, "bob", 0.25, 0.25},
+		{name, lex.TokenNE, "bob", 0.5, 0.5},
+		{mixed, lex.TokenLT, int64(10), 0.33, 0.34},
+		{nil, lex.TokenEqual, int64(1), 1, 1},
+	}
+	for _, tt := range tests {
+		sel := tt.stats.Selectivity(tt.op, tt.v)
+		assert.Tf(t, sel >= tt.lo-1e-9 && sel <= tt.hi+1e-9, "%v %v: %v not in [%v, %v]", tt.op, tt.v, sel, tt.lo, tt.hi)
+	}
+
+	// saved with the definition of the table
+	def := schema.TableDefOf(tbl)
+	loaded := def.Table()
+	rows, ok = loaded.RowCount()
+	assert.T(t, ok)
+	assert.Equal(t, int64(100), rows)
+	assert.Equal(t, int64(3), loaded.ColumnStats("name").Distinct)
+}
@@ -0,0 +1,384 @@
+package schema
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	u "github.com/araddon/gou"
+
+	"github.com/araddon/qlbridge/rel"
+	"github.com/araddon/qlbridge/value"
+)
+
+var (
+	// Different Features of the Schema Stores
+	_ Store = (*FileStore)(nil)
+)
+
+type (
+	// Store a durable store of the definitions of schemas, their sources,
+	// tables and fields, so a schema discovered of slow sources is loaded
+	// of the store on start instead of introspected again.  A Schema of a
+	// store is saved once loaded, and by DDL of its tables (see Schema.Save).
+	Store interface {
+		// Load the definition of schema, ErrNotFound if none saved
+		Load(schema string) (*SchemaDef, error)
+		Save(def *SchemaDef) error
+		Delete(schema string) error
+	}
+
+	// SchemaDef the definition of a Schema saved to a Store
+	SchemaDef struct {
+		Name    string       `json:"name"`
+		Sources []*SourceDef `json:"sources"`
+		Views   []*ViewDef   `json:"views,omitempty"`
+		Saved   time.Time    `json:"saved"`
+	}
+
+	// ViewDef the definition of a View, of the sql of its select
+	ViewDef struct {
+		Name              string `json:"name"`
+		Sql               string `json:"sql"`
+		MaterializedTable string `json:"materialized_table,omitempty"`
+	}
+
+	// SourceDef the definition of a SchemaSource and its tables
+	SourceDef struct {
+		Name   string      `json:"name"`
+		Type   string      `json:"type,omitempty"`
+		Tables []*TableDef `json:"tables"`
+	}
+
+	// TableDef the definition of a Table, without the Context of the
+	// source that discovered it
+	TableDef struct {
+		Name         string          `json:"name"`
+		NameOriginal string          `json:"name_original,omitempty"`
+		Columns      []string        `json:"columns,omitempty"`
+		Fields       []*FieldDef     `json:"fields"`
+		Indexes      []*Index        `json:"indexes,omitempty"`
+		Relations    []*Relation     `json:"relations,omitempty"`
+		Charset      uint16          `json:"charset,omitempty"`
+		Partition    *TablePartition `json:"partition,omitempty"`
+		PartitionCt  int             `json:"partition_count,omitempty"`
+		Refresh      time.Duration   `json:"refresh,omitempty"`
+		Refreshed    time.Time       `json:"refreshed"`
+	}
+
+	// FieldDef the definition of a Field
+	FieldDef struct {
+		Name         string          `json:"name"`
+		Description  string          `json:"description,omitempty"`
+		Key          string          `json:"key,omitempty"`
+		Extra        string          `json:"extra,omitempty"`
+		Length       uint32          `json:"length,omitempty"`
+		Type         value.ValueType `json:"type"`
+		NativeType   value.ValueType `json:"native_type,omitempty"`
+		DefaultValue driver.Value    `json:"default,omitempty"`
+		Indexed      bool            `json:"indexed,omitempty"`
+		NoNulls      bool            `json:"no_nulls,omitempty"`
+		Collation    string          `json:"collation,omitempty"`
+		Roles        []string        `json:"roles,omitempty"`
+		Stats        *ColumnStats    `json:"stats,omitempty"`
+		Computed     string          `json:"computed,omitempty"`
+	}
+
+	// FileStore a Store of a directory of a json file per schema, written
+	// whole (to a temp file renamed over the last) on each save
+	FileStore struct {
+		dir string
+		mu  sync.Mutex
+	}
+)
+
+// Source the definition of the source of name, nil if none
+func (m *SchemaDef) Source(name string) *SourceDef {
+	for _, sd := range m.Sources {
+		if sd.Name == name {
+			return sd
+		}
+	}
+	return nil
+}
+
+// TableDefOf the definition of tbl
+func TableDefOf(tbl *Table) *TableDef {
+	def := &TableDef{
+		Name:         tbl.Name,
+		NameOriginal: tbl.NameOriginal,
+		Columns:      tbl.Columns(),
+		Fields:       make([]*FieldDef, len(tbl.Fields)),
+		Indexes:      tbl.Indexes,
+		Relations:    tbl.Relations,
+		Charset:      tbl.Charset,
+		Partition:    tbl.Partition,
+		PartitionCt:  tbl.PartitionCt,
+		Refresh:      tbl.Refresh,
+		Refreshed:    tbl.Refreshed(),
+	}
+	for i, f := range tbl.Fields {
+		def.Fields[i] = FieldDefOf(f)
+	}
+	return def
+}
+
+// Table the table of the definition
+func (m *TableDef) Table() *Table {
+	name := m.NameOriginal
+	if name == "" {
+		name = m.Name
+	}
+	tbl := NewTable(name)
+	cols := m.Columns
+	for _, fd := range m.Fields {
+		if fd.Computed == "" {
+			tbl.AddField(fd.Field())
+			if len(m.Columns) == 0 {
+				cols = append(cols, fd.Name)
+			}
+		}
+	}
+	// computed columns last, of the columns stored before them
+	for _, fd := range m.Fields {
+		if fd.Computed == "" {
+			continue
+		}
+		if err := tbl.AddComputed(fd.Name, fd.Type, fd.Computed); err != nil {
+			u.Warnf("dropping computed column of table %q of store: %v", tbl.Name, err)
+		}
+	}
+	tbl.SetColumns(append([]string(nil), cols...))
+	tbl.Indexes = m.Indexes
+	for _, r := range m.Relations {
+		if err := tbl.AddRelation(r); err != nil {
+			u.Warnf("dropping relation of table %q of store: %v", tbl.Name, err)
+		}
+	}
+	tbl.Charset = m.Charset
+	tbl.Partition = m.Partition
+	tbl.PartitionCt = m.PartitionCt
+	tbl.Refresh = m.Refresh
+	if !m.Refreshed.IsZero() {
+		tbl.lastRefreshed = m.Refreshed
+	}
+	return tbl
+}
+
+// FieldDefOf the definition of f
+func FieldDefOf(f *Field) *FieldDef {
+	return &FieldDef{
+		Name:         f.Name,
+		Description:  f.Description,
+		Key:          f.Key,
+		Extra:        f.Extra,
+		Length:       f.Length,
+		Type:         f.Type,
+		NativeType:   f.NativeType,
+		DefaultValue: f.DefaultValue,
+		Indexed:      f.Indexed,
+		NoNulls:      f.NoNulls,
+		Collation:    f.Collation,
+		Roles:        f.Roles,
+		Stats:        f.ColumnStats(),
+		Computed:     f.Computed,
+	}
+}
+
+// Field the field of the definition
+func (m *FieldDef) Field() *Field {
+	return &Field{
+		Name:         m.Name,
+		Description:  m.Description,
+		Key:          m.Key,
+		Extra:        m.Extra,
+		Length:       m.Length,
+		Type:         m.Type,
+		NativeType:   m.NativeType,
+		DefaultValue: m.DefaultValue,
+		Indexed:      m.Indexed,
+		NoNulls:      m.NoNulls,
+		Collation:    m.Collation,
+		Roles:        m.Roles,
+		Stats:        m.Stats,
+	}
+}
+
+// NewFileStore a Store of the json files of dir, created if it does not
+// exist
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create schema store %q: %v", dir, err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (m *FileStore) path(schema string) string {
+	return filepath.Join(m.dir, strings.ToLower(schema)+".json")
+}
+
+func (m *FileStore) Load(schema string) (*SchemaDef, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	by, err := ioutil.ReadFile(m.path(schema))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	def := &SchemaDef{}
+	if err := json.Unmarshal(by, def); err != nil {
+		return nil, fmt.Errorf("could not read schema %q of store: %v", schema, err)
+	}
+	return def, nil
+}
+
+func (m *FileStore) Save(def *SchemaDef) error {
+	by, err := json.MarshalIndent(def, "", "  ")
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := ioutil.TempFile(m.dir, ".schema")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(by); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), m.path(def.Name))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("could not save schema %q to store: %v", def.Name, err)
+	}
+	return nil
+}
+
+func (m *FileStore) Delete(schema string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := os.Remove(m.path(schema)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// SetStore the Store this schema is saved to, and its sources load their
+// tables of (see SchemaSource.LoadDef)
+func (m *Schema) SetStore(store Store) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+}
+
+// Store the Store of this schema, nil if none
+func (m *Schema) Store() Store {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.store
+}
+
+// Def the definition of this schema, of the tables of its sources loaded
+func (m *Schema) Def() *SchemaDef {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	def := &SchemaDef{Name: m.Name, Sources: make([]*SourceDef, 0, len(m.schemaSources))}
+	names := make([]string, 0, len(m.schemaSources))
+	for name := range m.schemaSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		def.Sources = append(def.Sources, m.schemaSources[name].Def())
+	}
+	if len(m.views) > 0 {
+		def.Views = m.viewDefsUnlocked()
+	}
+	return def
+}
+
+// LoadViews add the views of def not already on this schema
+func (m *Schema) LoadViews(def *SchemaDef) error {
+	for _, vd := range def.Views {
+		if _, ok := m.View(vd.Name); ok {
+			continue
+		}
+		stmt, err := rel.ParseSqlSelect(vd.Sql)
+		if err != nil {
+			return fmt.Errorf("could not parse view %q of store: %v", vd.Name, err)
+		}
+		v := NewView(vd.Name, stmt)
+		v.MaterializedTable = vd.MaterializedTable
+		m.AddView(v)
+	}
+	return nil
+}
+
+// Save the definition of this schema to its Store, after its tables are
+// changed (ie by DDL), a no-op if it has none.  The sources saved of the
+// store not on this schema are kept, as the sources of a schema are loaded
+// (and saved) one at a time, unless removed (see RemoveSourceSchema).
+func (m *Schema) Save() error {
+	store := m.Store()
+	if store == nil {
+		return nil
+	}
+	def := m.Def()
+	stored, err := store.Load(m.Name)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	if stored != nil {
+		m.mu.RLock()
+		for _, sd := range stored.Sources {
+			if def.Source(sd.Name) == nil && !m.removed[sd.Name] {
+				def.Sources = append(def.Sources, sd)
+			}
+		}
+		m.mu.RUnlock()
+		sort.Slice(def.Sources, func(i, j int) bool { return def.Sources[i].Name < def.Sources[j].Name })
+	}
+	def.Saved = time.Now()
+	return store.Save(def)
+}
+
+// Def the definition of this source, of its tables loaded
+func (m *SchemaSource) Def() *SourceDef {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	def := &SourceDef{Name: m.Name, Tables: make([]*TableDef, 0, len(m.tableMap))}
+	if m.Conf != nil {
+		def.Type = m.Conf.SourceType
+	}
+	for _, name := range m.tableNames {
+		if tbl := m.tableMap[strings.ToLower(name)]; tbl != nil {
+			def.Tables = append(def.Tables, TableDefOf(tbl))
+		}
+	}
+	return def
+}
+
+// LoadDef the tables of def, used for the tables of the source of the same
+// name instead of asking the source for their schema.  The tables of the
+// source are still those it lists.
+func (m *SchemaSource) LoadDef(def *SourceDef) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stored = make(map[string]*Table, len(def.Tables))
+	for _, td := range def.Tables {
+		m.stored[td.Name] = td.Table()
+	}
+}
@@ -0,0 +1,50 @@
+package schema
+
+import (
+	"sync/atomic"
+)
+
+// generation the last version given a Schema or Table, of all of them so a
+// table (or schema) replacing another always has a higher version than the
+// one it replaced
+var generation uint64
+
+func nextGeneration() uint64 {
+	return atomic.AddUint64(&generation, 1)
+}
+
+// Version the generation of this schema, higher after any change of its
+// sources, tables or views (or of a field of one of its tables).  Cheap to
+// compare with the version a plan (or a remote copy of the schema) was made
+// of, to tell if it is stale.
+//
+//   if s.Version() != cached.version {
+//       // plan again
+//   }
+func (m *Schema) Version() uint64 {
+	return atomic.LoadUint64(&m.version)
+}
+
+func (m *Schema) bumpVersion() uint64 {
+	v := nextGeneration()
+	atomic.StoreUint64(&m.version, v)
+	return v
+}
+
+// Version the generation of this table, higher after any change of its
+// fields, columns, relations or stats.  A table replacing another of the
+// same name (ie altered) has a higher version than it.
+func (m *Table) Version() uint64 {
+	return atomic.LoadUint64(&m.version)
+}
+
+// Changed bump the version of this table (and of its schema), for changes
+// made directly to its exported fields, ie its Indexes
+func (m *Table) Changed() {
+	atomic.StoreUint64(&m.version, nextGeneration())
+	if m.SchemaSource != nil {
+		if s := m.SchemaSource.Schema(); s != nil {
+			s.bumpVersion()
+		}
+	}
+}
@@ -812,6 +812,8 @@ func walkUnary(ctx expr.EvalContext, node *expr.UnaryNode) (value.Value, bool) {
 //
 //     A   BETWEEN   B  AND C
 //
+// BETWEEN is inclusive of B and C as in sql, as it is translated for
+// sources the where is pushed down to.
 func walkTri(ctx expr.EvalContext, node *expr.TriNode) (value.Value, bool) {
 
 	a, aok := Eval(ctx, node.Args[0])
@@ -842,7 +844,7 @@ func walkTri(ctx expr.EvalContext, node *expr.TriNode) (value.Value, bool) {
 			if !ok {
 				return value.BoolValueFalse, false
 			}
-			if av > bv && av < cv {
+			if av >= bv && av <= cv {
 				return value.NewBoolValue(true), true
 			}
 
@@ -858,7 +860,7 @@ func walkTri(ctx expr.EvalContext, node *expr.TriNode) (value.Value, bool) {
 			if !ok {
 				return value.BoolValueFalse, false
 			}
-			if av > bv && av < cv {
+			if av >= bv && av <= cv {
 				return value.NewBoolValue(true), true
 			}
 
@@ -875,7 +877,7 @@ func walkTri(ctx expr.EvalContext, node *expr.TriNode) (value.Value, bool) {
 			if !ok {
 				return value.BoolValueFalse, false
 			}
-			if av.Unix() > bv.Unix() && av.Unix() < cv.Unix() {
+			if av.Unix() >= bv.Unix() && av.Unix() <= cv.Unix() {
 				return value.NewBoolValue(true), true
 			}
 
@@ -922,71 +924,13 @@ func walkFunc(ctx expr.EvalContext, node *expr.FuncNode) (value.Value, bool) {
 		var nilArg expr.EvalContext
 		funcArgs = append(funcArgs, reflect.ValueOf(&nilArg).Elem())
 	}
-	for _, a := range node.Args {
-
-		//u.Debugf("arg %v  %T %v", a, a, a)
-
-		var v interface{}
-
-		switch t := a.(type) {
-		case *expr.StringNode: // String Literal
-			v = value.NewStringValue(t.Text)
-		case *expr.IdentityNode: // Identity node = lookup in context
-
-			if t.IsBooleanIdentity() {
-				v = value.NewBoolValue(t.Bool())
-			} else {
-				v, ok = ctx.Get(t.Text)
-				//u.Infof("%#v", ctx)
-				//u.Debugf("get '%s'? %T %v %v", t.String(), v, v, ok)
-				if !ok {
-					// nil arguments are valid
-					v = value.NewNilValue()
-				}
-			}
-
-		case *expr.NumberNode:
-			v, ok = numberNodeToValue(t)
-		case *expr.FuncNode:
-			//u.Debugf("descending to %v()", t.Name)
-			v, ok = walkFunc(ctx, t)
-			if !ok {
-				// nil arguments are valid
-				v = value.NewNilValue()
-			}
-			//u.Debugf("result of %v() = %v, %T", t.Name, v, v)
-		case *expr.UnaryNode:
-			v, ok = walkUnary(ctx, t)
-			if !ok {
-				// nil arguments are valid ??
-				v = value.NewNilValue()
-			}
-		case *expr.BinaryNode:
-			v, ok = walkBinary(ctx, t)
-		case *expr.ValueNode:
-			v = t.Value
-		default:
-			u.Errorf("expr: unknown func arg type %T %v", a, a)
-		}
-
-		if v == nil {
-			//u.Warnf("Nil vals?  %v  %T  arg:%T", v, v, a)
-			// What do we do with Nil Values?
-			switch a.(type) {
-			case *expr.StringNode: // String Literal
-				u.Warnf("NOT IMPLEMENTED T:%T v:%v", a, a)
-			case *expr.IdentityNode: // Identity node = lookup in context
-				v = value.NewStringValue("")
-			default:
-				u.Warnf("un-handled type:  %v  %T", v, v)
-			}
-
-			funcArgs = append(funcArgs, reflect.ValueOf(v))
-		} else {
-			//u.Debugf(`found func arg:  "%v"  %T  arg:%T`, v, v, a)
-			funcArgs = append(funcArgs, reflect.ValueOf(v))
+	for i, a := range node.Args {
+		if node.F.LazyArgs && i >= len(node.F.Args)-1 {
+			// evaluated only when the function asks
+			funcArgs = append(funcArgs, reflect.ValueOf(lazyFuncArg(ctx, a)))
+			continue
 		}
-
+		funcArgs = append(funcArgs, reflect.ValueOf(funcArg(ctx, a)))
 	}
 	// Get the result of calling our Function (Value,bool)
 	//u.Debugf("Calling func:%v(%v) %v", node.F.Name, funcArgs, node.F.F)
@@ -1009,6 +953,82 @@ func walkFunc(ctx expr.EvalContext, node *expr.FuncNode) (value.Value, bool) {
 	return vv, true
 }
 
+// funcArg the value of an argument of a function call
+func funcArg(ctx expr.EvalContext, a expr.Node) interface{} {
+	var ok bool
+	//u.Debugf("arg %v  %T %v", a, a, a)
+
+	var v interface{}
+
+	switch t := a.(type) {
+	case *expr.StringNode: // String Literal
+		v = value.NewStringValue(t.Text)
+	case *expr.IdentityNode: // Identity node = lookup in context
+
+		if t.IsBooleanIdentity() {
+			v = value.NewBoolValue(t.Bool())
+		} else {
+			v, ok = ctx.Get(t.Text)
+			//u.Infof("%#v", ctx)
+			//u.Debugf("get '%s'? %T %v %v", t.String(), v, v, ok)
+			if !ok {
+				// nil arguments are valid
+				v = value.NewNilValue()
+			}
+		}
+
+	case *expr.NumberNode:
+		v, ok = numberNodeToValue(t)
+	case *expr.FuncNode:
+		//u.Debugf("descending to %v()", t.Name)
+		v, ok = walkFunc(ctx, t)
+		if !ok {
+			// nil arguments are valid
+			v = value.NewNilValue()
+		}
+		//u.Debugf("result of %v() = %v, %T", t.Name, v, v)
+	case *expr.UnaryNode:
+		v, ok = walkUnary(ctx, t)
+		if !ok {
+			// nil arguments are valid ??
+			v = value.NewNilValue()
+		}
+	case *expr.BinaryNode:
+		v, ok = walkBinary(ctx, t)
+	case *expr.ValueNode:
+		v = t.Value
+	case *expr.NullNode:
+		v = value.NewNilValue()
+	default:
+		u.Errorf("expr: unknown func arg type %T %v", a, a)
+	}
+
+	if v == nil {
+		//u.Warnf("Nil vals?  %v  %T  arg:%T", v, v, a)
+		// What do we do with Nil Values?
+		switch a.(type) {
+		case *expr.StringNode: // String Literal
+			u.Warnf("NOT IMPLEMENTED T:%T v:%v", a, a)
+		case *expr.IdentityNode: // Identity node = lookup in context
+			v = value.NewStringValue("")
+		default:
+			u.Warnf("un-handled type:  %v  %T", v, v)
+		}
+	}
+	//u.Debugf(`found func arg:  "%v"  %T  arg:%T`, v, v, a)
+	return v
+}
+
+// lazyFuncArg an argument of a function call evaluated when called
+func lazyFuncArg(ctx expr.EvalContext, a expr.Node) expr.LazyArg {
+	return func() value.Value {
+		if v, ok := funcArg(ctx, a).(value.Value); ok && v != nil {
+			return v
+		}
+		return value.NewNilValue()
+	}
+}
+
 func operateNumbers(op lex.Token, av, bv value.NumberValue) value.Value {
 	switch op.T {
 	case lex.TokenPlus, lex.TokenStar, lex.TokenMultiply, lex.TokenDivide, lex.TokenMinus,
@@ -117,6 +117,9 @@ var (
 		vmt(`10 BETWEEN 1 AND "55.5"`, true, noError),
 		vmt(`15.5 BETWEEN 1 AND "55.5"`, true, noError),
 		vmt(`10 BETWEEN 20 AND 50`, false, noError),
+		vmt(`10 BETWEEN 10 AND 50`, true, noError),
+		vmt(`50.5 BETWEEN 1 AND 50.5`, true, noError),
+		vmt(`51 BETWEEN 10 AND 50`, false, noError),
 		vmt(`10 BETWEEN 5 AND toint("50.5")`, true, noError),
 		vmt(`10 BETWEEN int5 AND 50`, true, noError),
 		vmtall(`10 BETWEEN 20 AND true`, nil, parseOk, evalError),